@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"aws-documentor/modules/browse"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+// Region scan outcomes
+const (
+	regionScanned = "scanned" // The core resources of the region were scanned
+	regionFailed  = "failed"  // A scan of the region failed, so its resources are missing
+)
+
+// InfrastructureReport is the JSON output of -all-regions: the core resources of every enabled region, grouped by region
+type InfrastructureReport struct {
+	AccountID string            `json:"account_id"` // Account that was scanned (empty if unknown)
+	ScannedAt string            `json:"scanned_at"` // When the scan started (RFC 3339)
+	Regions   []RegionInventory `json:"regions"`    // Per-region outcome and resources, sorted by region
+	Failed    int               `json:"failed"`     // Regions whose scan failed
+}
+
+// RegionInventory is the outcome and the core resources of one region of an -all-regions scan
+// The resources are those of a single-region scan; their ARNs name the region too.
+type RegionInventory struct {
+	Region           string                             `json:"region"`            // Region that was scanned
+	Status           string                             `json:"status"`            // scanned or failed
+	Error            string                             `json:"error,omitempty"`   // Why the scan of the region failed
+	DurationMs       int64                              `json:"duration_ms"`       // Duration of the scan in milliseconds
+	VPCs             []vpc.VPCInfo                      `json:"vpcs"`              // VPCs of the region
+	Subnets          []vpc.SubnetInfo                   `json:"subnets"`           // Subnets of the VPCs
+	RouteTables      []vpc.RouteTableInfo               `json:"route_tables"`      // Route tables of the VPCs
+	SecurityGroups   []vpc.SecurityGroupInfo            `json:"security_groups"`   // Security groups of the VPCs
+	InternetGateways []vpc.InternetGatewayInfo          `json:"internet_gateways"` // Internet gateways of the VPCs
+	NatGateways      []vpc.NatGatewayInfo               `json:"nat_gateways"`      // NAT gateways of the VPCs
+	TransitGateways  []vpc.TransitGatewayInfo           `json:"transit_gateways"`  // Transit gateways of the region
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`   // Transit gateway attachments of the region
+}
+
+// regionScan scans the core resources of several regions concurrently
+type regionScan struct {
+	scan        func(ctx context.Context, region string) (*browse.Report, error) // Scans the core resources of one region
+	parallelism int                                                              // Regions scanned at the same time
+	now         func() time.Time                                                 // Clock, replaceable for tests
+}
+
+// run scans every region, at most parallelism at a time
+// A region whose scan fails is recorded and the others carry on.
+// ctx: Context for the scans
+// regions: Region names, in the order of the report
+// Returns: Report with one entry per region; the caller fills in the account and scan time
+func (r *regionScan) run(ctx context.Context, regions []string) *InfrastructureReport {
+	report := &InfrastructureReport{Regions: make([]RegionInventory, len(regions))}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(r.parallelism, 1))
+	for i, region := range regions {
+		inventory := &report.Regions[i]
+		inventory.Region = region
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			start := r.now()
+			resources, err := r.scan(ctx, inventory.Region)
+			inventory.DurationMs = r.now().Sub(start).Milliseconds()
+			if err != nil {
+				inventory.Status, inventory.Error = regionFailed, err.Error()
+				return
+			}
+			inventory.Status = regionScanned
+			inventory.VPCs = resources.VPCs
+			inventory.Subnets = resources.Subnets
+			inventory.RouteTables = resources.RouteTables
+			inventory.SecurityGroups = resources.SecurityGroups
+			inventory.InternetGateways = resources.InternetGateways
+			inventory.NatGateways = resources.NatGateways
+			inventory.TransitGateways = resources.TransitGateways
+			inventory.TGWAttachments = resources.TGWAttachments
+		}()
+	}
+	wg.Wait()
+
+	for _, inventory := range report.Regions {
+		if inventory.Status != regionScanned {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// scanRegionResources scans the core VPC resources of one region
+// Unlike scanCoreResources it returns the first error instead of exiting, so the other regions carry on.
+// cfg: AWS config of the scan; a copy with the region set is used, so its clients share the call budget
+// accountID: Account for the ARNs of resources the APIs return no owner for
+// opts: Tag filters of -tag
+// prepare: Labels the managers and hides the system tags of the resources, as for a single-region scan
+func scanRegionResources(ctx context.Context, cfg aws.Config, region, accountID string, opts vpc.ScanOptions, prepare func(resources interface{})) (*browse.Report, error) {
+	regionCfg := cfg.Copy()
+	regionCfg.Region = region
+	scanner := vpc.NewScanner(regionCfg)
+	scanner.SetAccountID(accountID)
+
+	scan, err := scanner.ScanAll(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	report := coreReport(scan)
+	prepare(report)
+	return report, nil
+}
+
+// regionDiagramResources returns the resources of the scanned regions for GenerateRegionsDiagram
+// Regions whose scan failed are left out, as there is nothing to draw.
+func regionDiagramResources(report *InfrastructureReport) []diagram.RegionResources {
+	var regions []diagram.RegionResources
+	for _, inventory := range report.Regions {
+		if inventory.Status != regionScanned {
+			continue
+		}
+		regions = append(regions, diagram.RegionResources{
+			Region:           inventory.Region,
+			VPCs:             inventory.VPCs,
+			Subnets:          inventory.Subnets,
+			InternetGateways: inventory.InternetGateways,
+			NatGateways:      inventory.NatGateways,
+			TransitGateways:  inventory.TransitGateways,
+			TGWAttachments:   inventory.TGWAttachments,
+		})
+	}
+	return regions
+}
+
+// scanAllRegions scans the core resources of every enabled region with -all-regions, each with its own scanner
+// ctx: Context for the AWS calls
+// Returns: Error that stopped the run; a failed region only makes the scan partial
+func (run *scanRun) scanAllRegions(ctx context.Context) error {
+	regions, err := run.scanner.GetEnabledRegions(ctx)
+	if err := run.result.scanFailure(err); err != nil {
+		return err
+	}
+	scans := &regionScan{
+		scan: func(ctx context.Context, region string) (*browse.Report, error) {
+			return scanRegionResources(ctx, run.cfg, region, run.accountID, run.scanOptions, run.prepareTags)
+		},
+		parallelism: *run.regionParallelism,
+		now:         time.Now,
+	}
+	report := scans.run(ctx, regions)
+	report.AccountID = run.accountID
+	report.ScannedAt = run.scannedAt.UTC().Format(time.RFC3339)
+	run.result.Region = strings.Join(regions, ",")
+
+	counts := make(map[string]int)
+	for _, inventory := range report.Regions {
+		if inventory.Status != regionScanned {
+			run.result.skipf("region %s: %s", inventory.Region, inventory.Error)
+			continue
+		}
+		fmt.Fprintf(run.stdout, "%s: %d VPCs, %d subnets, %d security groups, %d transit gateways (%d ms)\n",
+			inventory.Region, len(inventory.VPCs), len(inventory.Subnets), len(inventory.SecurityGroups), len(inventory.TransitGateways), inventory.DurationMs)
+		counts["vpcs"] += len(inventory.VPCs)
+		counts["subnets"] += len(inventory.Subnets)
+		counts["route_tables"] += len(inventory.RouteTables)
+		counts["security_groups"] += len(inventory.SecurityGroups)
+		counts["internet_gateways"] += len(inventory.InternetGateways)
+		counts["nat_gateways"] += len(inventory.NatGateways)
+		counts["transit_gateways"] += len(inventory.TransitGateways)
+		counts["tgw_attachments"] += len(inventory.TGWAttachments)
+	}
+	for resourceType, n := range counts {
+		run.result.count(resourceType, n)
+	}
+	if len(regions) > 0 && report.Failed == len(regions) {
+		return fmt.Errorf("the scans of all %d regions failed: %s", len(regions), report.Regions[0].Error)
+	}
+
+	if run.opts.JSON {
+		reportJSON, _ := output.Marshal(report, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	if *run.generateDiagram {
+		fmt.Fprintln(run.stdout, "\nGenerating draw.io diagram...")
+		diagramGen := diagram.NewDiagramGenerator()
+		diagramGen.SetScanContext("", run.accountID)
+		diagramGen.SetPlainCells(*run.diagramPlain)
+		diagramGen.SetDimManaged(splitList(*run.dimManaged))
+		diagramXML, err := diagramGen.GenerateRegionsDiagram(regionDiagramResources(report))
+		if err != nil {
+			return fmt.Errorf("Failed to generate diagram: %w", err)
+		}
+		if err := writeDiagram(run.stdout, run.result, diagramXML); err != nil {
+			return err
+		}
+	}
+	return nil
+}
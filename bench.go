@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"text/tabwriter"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/browse"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/graph"
+	"aws-documentor/modules/testgen"
+	"aws-documentor/modules/vpc"
+)
+
+// benchGrowthLimit is how much the time per resource of a hot path may grow from the small to the large
+// environment before -check fails; every hot path is meant to be roughly linear in the number of resources
+const benchGrowthLimit = 3.0
+
+// hotPath is a post-processing step timed by the bench subcommand
+type hotPath struct {
+	name string                                             // Name shown in the results
+	run  func(env *testgen.Environment, old *diff.Snapshot) // One run over the environment
+}
+
+// hotPaths are the post-processing steps whose cost grows with the size of the account
+var hotPaths = []hotPath{
+	{"sg-references", func(env *testgen.Environment, _ *diff.Snapshot) {
+		analysis.AnalyzeSecurityGroupReferences(env.SecurityGroups, env.RouteTables)
+	}},
+	{"graph", func(env *testgen.Environment, _ *diff.Snapshot) {
+		graph.Build(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+			env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+	}},
+	{"reference-index", func(env *testgen.Environment, _ *diff.Snapshot) {
+		browse.NewIndex(&browse.Report{
+			VPCs:             env.VPCs,
+			Subnets:          env.Subnets,
+			RouteTables:      env.RouteTables,
+			SecurityGroups:   env.SecurityGroups,
+			InternetGateways: env.InternetGateways,
+			NatGateways:      env.NatGateways,
+			TransitGateways:  env.TransitGateways,
+			TGWAttachments:   env.TGWAttachments,
+		})
+	}},
+	{"connectivity", func(env *testgen.Environment, _ *diff.Snapshot) {
+		analysis.AnalyzeIsolation(env.VPCs, env.RouteTables, env.NatGateways, env.TGWAttachments, nil)
+	}},
+	{"diff", func(env *testgen.Environment, old *diff.Snapshot) {
+		diff.Compare(old, snapshotOf(env))
+	}},
+	{"diagram-layout", func(env *testgen.Environment, _ *diff.Snapshot) {
+		layout := diagram.ComputeLayout(env.VPCs, env.Subnets, env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+		layout.Bounds()
+	}},
+	{"overview-diagram", func(env *testgen.Environment, _ *diff.Snapshot) {
+		if _, err := diagram.NewDiagramGenerator().GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+			env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments); err != nil {
+			log.Fatalf("Failed to generate diagram: %v", err)
+		}
+	}},
+}
+
+// benchResult is the measurement of one hot path at one size
+type benchResult struct {
+	size      testgen.Size
+	resources int
+	path      string
+	result    testing.BenchmarkResult
+}
+
+// nsPerResource returns the time per run divided by the number of resources
+func (r benchResult) nsPerResource() float64 {
+	return float64(r.result.NsPerOp()) / float64(r.resources)
+}
+
+// runBench implements "aws-documentor bench [flags]"
+// It generates synthetic environments and times the post-processing hot paths on them with the Go
+// benchmark runner, so that a step turning quadratic shows up before a large account hits it.
+// args: Command-line arguments after the subcommand
+func runBench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	sizeFlag := flags.String("size", "all", "Environment size: small, medium, large or all")
+	check := flags.Bool("check", false, fmt.Sprintf("Exit with status 4 if a hot path's time per resource grows more than %gx from the small to the large environment", benchGrowthLimit))
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	sizes := testgen.Sizes
+	if *sizeFlag != "all" {
+		size, err := testgen.ParseSize(*sizeFlag)
+		problems.Check("-size", err)
+		sizes = []testgen.Size{size}
+	}
+	if *check && *sizeFlag != "all" {
+		problems.Addf("-check", 0, "needs -size all, as it compares the small and the large environment")
+	}
+	if flags.NArg() > 0 {
+		problems.Addf(flags.Arg(0), 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	var results []benchResult
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "SIZE\tRESOURCES\tHOT PATH\tNS/OP\tNS/RESOURCE\tALLOCS/OP\tBYTES/OP\t")
+	for _, size := range sizes {
+		env := testgen.Generate(size)
+		old := previousSnapshot(env)
+		for _, path := range hotPaths {
+			result := testing.Benchmark(func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					path.run(env, old)
+				}
+			})
+			r := benchResult{size: size, resources: env.Resources(), path: path.name, result: result}
+			results = append(results, r)
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%.0f\t%d\t%d\t\n", size.Name, r.resources, path.name,
+				result.NsPerOp(), r.nsPerResource(), result.AllocsPerOp(), result.AllocedBytesPerOp())
+			tw.Flush()
+		}
+	}
+
+	if !*check {
+		return
+	}
+	failed := false
+	for _, small := range results {
+		if small.size.Name != testgen.Small.Name {
+			continue
+		}
+		for _, large := range results {
+			if large.size.Name != testgen.Large.Name || large.path != small.path {
+				continue
+			}
+			growth := large.nsPerResource() / small.nsPerResource()
+			if growth > benchGrowthLimit {
+				fmt.Fprintf(os.Stderr, "%s: time per resource grew %.1fx from small to large (limit %gx)\n", small.path, growth, benchGrowthLimit)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(exitFindings)
+	}
+}
+
+// snapshotOf returns the sections of an environment the diff engine compares
+func snapshotOf(env *testgen.Environment) diff.Snapshot {
+	return diff.Snapshot{
+		VPCs:             env.VPCs,
+		Subnets:          env.Subnets,
+		RouteTables:      env.RouteTables,
+		SecurityGroups:   env.SecurityGroups,
+		InternetGateways: env.InternetGateways,
+		NatGateways:      env.NatGateways,
+		TransitGateways:  env.TransitGateways,
+	}
+}
+
+// previousSnapshot returns an earlier version of an environment for the diff hot path
+// Every tenth security group had one rule fewer and every tenth subnet did not exist yet, so the
+// diff has changes of every kind to report.
+func previousSnapshot(env *testgen.Environment) *diff.Snapshot {
+	old := snapshotOf(env)
+	old.SecurityGroups = append([]vpc.SecurityGroupInfo{}, env.SecurityGroups...)
+	for i := 0; i < len(old.SecurityGroups); i += 10 {
+		if rules := old.SecurityGroups[i].Rules; len(rules) > 1 {
+			old.SecurityGroups[i].Rules = rules[1:]
+		}
+	}
+	old.Subnets = nil
+	for i, subnet := range env.Subnets {
+		if i%10 != 0 {
+			old.Subnets = append(old.Subnets, subnet)
+		}
+	}
+	return &old
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"aws-documentor/modules/testgen"
+)
+
+// BenchmarkHotPaths times the hot paths of the bench subcommand with go test
+// Sub-benchmarks are named size/hot path, so one can be picked with -bench, for example
+// -bench 'HotPaths/large/diff'. The environments are generated outside the timed loop.
+func BenchmarkHotPaths(b *testing.B) {
+	for _, size := range testgen.Sizes {
+		env := testgen.Generate(size)
+		old := previousSnapshot(env)
+		for _, path := range hotPaths {
+			b.Run(size.Name+"/"+path.name, func(b *testing.B) {
+				b.ReportAllocs()
+				b.ReportMetric(float64(env.Resources()), "resources")
+				for i := 0; i < b.N; i++ {
+					path.run(env, old)
+				}
+			})
+		}
+	}
+}
+
+// TestHotPathsRun runs every hot path once on the small environment, so a broken one fails go test
+func TestHotPathsRun(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	old := previousSnapshot(env)
+	for _, path := range hotPaths {
+		t.Run(path.name, func(t *testing.T) {
+			path.run(env, old)
+		})
+	}
+}
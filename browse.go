@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/browse"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/vpc"
+)
+
+// runBrowse implements "aws-documentor browse [flags]"
+// It opens a read-only terminal browser over a saved JSON report, or over a fresh scan of the core
+// VPC resources when no report is given.
+// args: Command-line arguments after the subcommand
+func runBrowse(args []string) {
+	flags := flag.NewFlagSet("browse", flag.ExitOnError)
+	fromFile := flags.String("from-file", "", "JSON report from an earlier scan to browse instead of scanning")
+	freshness := registerFreshnessFlags(flags, "-from-file")
+	region := flags.String("region", "", "AWS region to scan (optional, uses default config if not specified)")
+	proxy := flags.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	if *region != "" {
+		problems.Check("-region", config.CheckRegion(*region))
+	}
+	if *proxy != "" {
+		_, err := awsconfig.ParseProxy(*proxy)
+		problems.Check("-proxy", err)
+	}
+	if *fromFile != "" && (*region != "" || *proxy != "") {
+		problems.Addf("-from-file", 0, "cannot be combined with -region or -proxy")
+	}
+	freshness.validate(problems, *fromFile != "")
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	var report *browse.Report
+	if *fromFile != "" {
+		snapshot, err := diff.LoadSnapshot(*fromFile)
+		if err != nil {
+			log.Fatalf("Failed to load report: %v", err)
+		}
+		freshness.checkSubcommand(snapshot.ScannedAt)
+		report = &browse.Report{
+			VPCs:             snapshot.VPCs,
+			Subnets:          snapshot.Subnets,
+			RouteTables:      snapshot.RouteTables,
+			SecurityGroups:   snapshot.SecurityGroups,
+			InternetGateways: snapshot.InternetGateways,
+			NatGateways:      snapshot.NatGateways,
+			TransitGateways:  snapshot.TransitGateways,
+		}
+	} else {
+		ctx := context.Background()
+		report = scanCoreResources(ctx, loadSubcommandConfig(ctx, *region, *proxy))
+	}
+
+	if _, err := tea.NewProgram(browse.NewModel(browse.NewIndex(report)), tea.WithAltScreen()).Run(); err != nil {
+		log.Fatalf("Browser failed: %v", err)
+	}
+}
+
+// loadSubcommandConfig loads the AWS config of a subcommand, exiting if it cannot be loaded
+// region: AWS region to scan, empty for the default config
+// proxy: Proxy URL for AWS API requests, empty for HTTPS_PROXY
+func loadSubcommandConfig(ctx context.Context, region, proxy string) aws.Config {
+	httpOptions := awsconfig.DefaultHTTPOptions()
+	httpOptions.Proxy = proxy
+	cfg, _, err := awsconfig.Load(ctx, region, httpOptions)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+	return cfg
+}
+
+// scanCoreResources scans the core VPC resources that the subcommands (browse, whatsnew, query, ...) work on
+func scanCoreResources(ctx context.Context, cfg aws.Config) *browse.Report {
+	scanner := vpc.NewScanner(cfg)
+	if accountID, err := awsconfig.AccountID(ctx, cfg); err != nil {
+		log.Printf("Warning: %v; ARNs of resources without an owner ID are left empty", err)
+	} else {
+		scanner.SetAccountID(accountID)
+	}
+
+	log.Printf("Scanning %s...", cfg.Region)
+	scan, err := scanner.ScanAll(ctx)
+	if err != nil {
+		exitOnScanError(err)
+	}
+	report := coreReport(scan)
+	analysis.NewManagerClassifier(nil).Label(report)
+	return report
+}
+
+// coreReport returns the resources of a ScanAll scan as a browse report
+func coreReport(scan *vpc.ScanResult) *browse.Report {
+	return &browse.Report{
+		VPCs:             scan.VPCs,
+		Subnets:          scan.Subnets,
+		RouteTables:      scan.RouteTables,
+		SecurityGroups:   scan.SecurityGroups,
+		InternetGateways: scan.InternetGateways,
+		NatGateways:      scan.NatGateways,
+		TransitGateways:  scan.TransitGateways,
+		TGWAttachments:   scan.TGWAttachments,
+	}
+}
@@ -0,0 +1,528 @@
+// Command lambda runs the VPC scan as an AWS Lambda function and writes the results to S3 and SNS
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/schema"
+	"aws-documentor/modules/vpc"
+)
+
+// uploadReserve is the part of the remaining execution time kept back for writing outputs
+const uploadReserve = 10 * time.Second
+
+// snsFindingLimit caps the number of findings listed in the SNS message
+const snsFindingLimit = 20
+
+// ScanEvent is the Lambda input describing what to scan and where to write the results
+type ScanEvent struct {
+	Regions   []string `json:"regions"`    // Regions to scan (defaults to the function's region)
+	VpcIDs    []string `json:"vpc_ids"`    // Only report these VPCs and their resources (all VPCs when empty)
+	Bucket    string   `json:"bucket"`     // S3 bucket for the JSON report and draw.io diagram (skipped when empty)
+	KeyPrefix string   `json:"key_prefix"` // Key prefix; objects are written to <prefix><region>/report.json and vpc-diagram.drawio
+	TopicArn  string   `json:"topic_arn"`  // SNS topic to publish the findings summary to (skipped when empty)
+
+	InstanceNetwork bool `json:"instance_network"` // Roll up the network limits of the instances per subnet into subnet_network
+}
+
+// RegionSummary contains the scan outcome for a single region
+type RegionSummary struct {
+	Region   string         `json:"region"`          // Region that was scanned
+	Counts   map[string]int `json:"counts"`          // Number of resources found per resource type
+	Findings map[string]int `json:"findings"`        // Number of findings per classification
+	Partial  bool           `json:"partial"`         // Whether the scan stopped before all resource types were scanned
+	Error    string         `json:"error,omitempty"` // Error that stopped the scan (if any)
+	Outputs  []string       `json:"outputs"`         // S3 URIs of the objects written
+	Warnings int            `json:"data_warnings"`   // Number of missing or unrecognized fields in the API responses
+}
+
+// ScanSummary is the Lambda result
+type ScanSummary struct {
+	Regions    []RegionSummary `json:"regions"`       // Per-region outcome
+	Counts     map[string]int  `json:"counts"`        // Resource counts across all regions
+	Findings   map[string]int  `json:"findings"`      // Finding counts across all regions
+	DurationMs int64           `json:"duration_ms"`   // Total handler duration in milliseconds
+	Partial    bool            `json:"partial"`       // Whether any region was only partially scanned
+	Warnings   int             `json:"data_warnings"` // Data-quality warnings across all regions
+}
+
+// regionReport is the JSON document written to S3 for each region
+// Its shape is frozen as version 0 of the report schema; see "aws-documentor schema diff v0 v1".
+type regionReport = schema.ReportV0
+
+// regionScanner is the subset of vpc.Scanner used by the handler
+type regionScanner interface {
+	GetVPCs(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.VPCInfo, error)
+	GetSubnets(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SubnetInfo, error)
+	GetRouteTables(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.RouteTableInfo, error)
+	GetSecurityGroups(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SecurityGroupInfo, error)
+	GetInternetGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.InternetGatewayInfo, error)
+	GetNatGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.NatGatewayInfo, error)
+	GetTransitGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.TransitGatewayInfo, error)
+	GetTransitGatewayAttachments(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.TransitGatewayAttachmentInfo, error)
+	GetRouteAppliances(ctx context.Context, routeTables []vpc.RouteTableInfo) ([]vpc.RouteApplianceInfo, error)
+	GetInstances(ctx context.Context) ([]vpc.InstanceInfo, error)
+	GetInstanceTypes(ctx context.Context, names []string) (map[string]vpc.InstanceTypeNetworkInfo, error)
+	DataWarnings() []vpc.DataWarning
+}
+
+// objectStore writes report objects (S3 in production)
+type objectStore interface {
+	PutObject(ctx context.Context, bucket, key, contentType string, body []byte) error
+}
+
+// notifier publishes the findings summary (SNS in production)
+type notifier interface {
+	Publish(ctx context.Context, topicArn, subject, message string) error
+}
+
+// handler runs scans for Lambda invocations
+type handler struct {
+	cfg        aws.Config                         // Base AWS config; the region is overridden per scanned region
+	newScanner func(cfg aws.Config) regionScanner // Creates a scanner for a region
+	store      objectStore                        // Destination for report objects
+	notify     notifier                           // Destination for the findings summary
+	accountID  string                             // Account of the credentials, written to the diagram cells
+	logger     *slog.Logger                       // Structured logger
+	now        func() time.Time                   // Clock, replaceable for tests
+}
+
+func main() {
+	// One HTTP client for every service client and region, so connections are reused across the scan
+	cfg, _, err := awsconfig.Load(context.Background(), "", awsconfig.DefaultHTTPOptions())
+	if err != nil {
+		slog.Error("failed to load AWS config", "error", err)
+		os.Exit(1)
+	}
+
+	// The account goes into the ARNs of resources the APIs return no owner for
+	accountID, err := awsconfig.AccountID(context.Background(), cfg)
+	if err != nil {
+		slog.Warn("ARNs of resources without an owner ID are left empty", "error", err)
+	}
+
+	// Instance types are the same in every region, so the regions share their descriptions
+	instanceTypes := vpc.NewInstanceTypeCache()
+	h := &handler{
+		cfg:       cfg,
+		accountID: accountID,
+		newScanner: func(cfg aws.Config) regionScanner {
+			scanner := vpc.NewScanner(cfg)
+			scanner.SetAccountID(accountID)
+			scanner.SetInstanceTypeCache(instanceTypes)
+			return scanner
+		},
+		store:  &s3Store{client: s3.NewFromConfig(cfg)},
+		notify: &snsNotifier{client: sns.NewFromConfig(cfg)},
+		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		now:    time.Now,
+	}
+	lambda.Start(h.Handle)
+}
+
+// Handle scans every requested region, writes the outputs and returns a summary
+// ctx: Lambda invocation context; its deadline bounds the scan
+// event: Regions, filters and output destinations
+// Returns: Summary with counts, findings, duration and partial flag, or error if no region could be processed
+func (h *handler) Handle(ctx context.Context, event ScanEvent) (*ScanSummary, error) {
+	start := h.now()
+	summary := &ScanSummary{
+		Regions:  []RegionSummary{},
+		Counts:   make(map[string]int),
+		Findings: make(map[string]int),
+	}
+
+	regions := event.Regions
+	if len(regions) == 0 {
+		regions = []string{h.cfg.Region}
+	}
+
+	// Stop scanning early enough to still write what was collected
+	scanCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		scanCtx, cancel = context.WithDeadline(ctx, deadline.Add(-uploadReserve))
+		defer cancel()
+		h.logger.Info("scan started", "regions", regions, "deadline", deadline.Add(-uploadReserve).UTC().Format(time.RFC3339))
+	} else {
+		h.logger.Info("scan started", "regions", regions)
+	}
+
+	var failed int
+	for _, region := range regions {
+		regionSummary := h.scanRegion(ctx, scanCtx, region, event)
+		if regionSummary.Error != "" && !regionSummary.Partial {
+			failed++
+		}
+
+		for resourceType, count := range regionSummary.Counts {
+			summary.Counts[resourceType] += count
+		}
+		for classification, count := range regionSummary.Findings {
+			summary.Findings[classification] += count
+		}
+		summary.Partial = summary.Partial || regionSummary.Partial
+		summary.Warnings += regionSummary.Warnings
+		summary.Regions = append(summary.Regions, regionSummary)
+	}
+
+	summary.DurationMs = h.now().Sub(start).Milliseconds()
+	h.logger.Info("scan finished", "duration_ms", summary.DurationMs, "partial", summary.Partial, "counts", summary.Counts, "findings", summary.Findings, "data_warnings", summary.Warnings)
+
+	if failed == len(regions) {
+		return summary, fmt.Errorf("scan failed in all %d region(s)", failed)
+	}
+	return summary, nil
+}
+
+// scanRegion scans a single region and writes its outputs
+// ctx: Invocation context, used for writing outputs
+// scanCtx: Context with the scan deadline, used for API calls
+func (h *handler) scanRegion(ctx, scanCtx context.Context, region string, event ScanEvent) RegionSummary {
+	logger := h.logger.With("region", region)
+	regionSummary := RegionSummary{
+		Region:   region,
+		Counts:   make(map[string]int),
+		Findings: make(map[string]int),
+		Outputs:  []string{},
+	}
+
+	cfg := h.cfg.Copy()
+	cfg.Region = region
+	scanner := h.newScanner(cfg)
+
+	report := &regionReport{Region: region, ScannedAt: h.now().UTC().Format(time.RFC3339)}
+
+	// Each step fills one resource type; a failure stops the remaining steps
+	steps := []struct {
+		resourceType string
+		scan         func() (int, error)
+	}{
+		{"vpcs", func() (n int, err error) { report.VPCs, err = scanner.GetVPCs(scanCtx); return len(report.VPCs), err }},
+		{"subnets", func() (n int, err error) {
+			report.Subnets, err = scanner.GetSubnets(scanCtx)
+			return len(report.Subnets), err
+		}},
+		{"route_tables", func() (n int, err error) {
+			report.RouteTables, err = scanner.GetRouteTables(scanCtx)
+			return len(report.RouteTables), err
+		}},
+		{"security_groups", func() (n int, err error) {
+			report.SecurityGroups, err = scanner.GetSecurityGroups(scanCtx)
+			return len(report.SecurityGroups), err
+		}},
+		{"internet_gateways", func() (n int, err error) {
+			report.InternetGateways, err = scanner.GetInternetGateways(scanCtx)
+			return len(report.InternetGateways), err
+		}},
+		{"nat_gateways", func() (n int, err error) {
+			report.NatGateways, err = scanner.GetNatGateways(scanCtx)
+			return len(report.NatGateways), err
+		}},
+		{"transit_gateways", func() (n int, err error) {
+			report.TransitGateways, err = scanner.GetTransitGateways(scanCtx)
+			return len(report.TransitGateways), err
+		}},
+		{"tgw_attachments", func() (n int, err error) {
+			report.TGWAttachments, err = scanner.GetTransitGatewayAttachments(scanCtx)
+			return len(report.TGWAttachments), err
+		}},
+		{"legacy_nat_instances", func() (n int, err error) {
+			appliances, err := scanner.GetRouteAppliances(scanCtx, report.RouteTables)
+			report.LegacyNATInstances = analysis.AnalyzeRouteAppliances(appliances).LegacyNATInstances
+			return len(report.LegacyNATInstances), err
+		}},
+	}
+	if event.InstanceNetwork {
+		steps = append(steps, struct {
+			resourceType string
+			scan         func() (int, error)
+		}{"instances", func() (n int, err error) {
+			instances, err := scanner.GetInstances(scanCtx)
+			if err != nil {
+				return 0, err
+			}
+			var typeNames []string
+			for _, instance := range instances {
+				typeNames = append(typeNames, instance.InstanceType)
+			}
+			instanceTypes, err := scanner.GetInstanceTypes(scanCtx, typeNames)
+			report.SubnetNetwork = analysis.AnalyzeSubnetNetworkCapacity(report.Subnets, instances, instanceTypes).Subnets
+			return len(instances), err
+		}})
+	}
+
+	for i, step := range steps {
+		stepStart := h.now()
+		count, err := step.scan()
+		if err != nil {
+			regionSummary.Error = err.Error()
+			if errors.Is(scanCtx.Err(), context.DeadlineExceeded) {
+				logger.Warn("scan deadline reached", "resource_type", step.resourceType)
+			} else {
+				logger.Error("scan failed", "resource_type", step.resourceType, "error", err)
+			}
+			// Without VPCs there is nothing worth writing
+			if i == 0 {
+				return regionSummary
+			}
+			report.Partial = true
+			regionSummary.Partial = true
+			break
+		}
+		logger.Info("scanned", "resource_type", step.resourceType, "count", count, "duration_ms", h.now().Sub(stepStart).Milliseconds())
+	}
+
+	if len(event.VpcIDs) > 0 {
+		filterByVPC(report, event.VpcIDs)
+	}
+	vpc.ClassifyLocalRoutes(report.VPCs, report.RouteTables)
+	analysis.NewManagerClassifier(nil).Label(report)
+	report.Findings = analysis.AnalyzeSecurityGroupReferences(report.SecurityGroups, report.RouteTables).Findings
+
+	// The scan keeps resources with missing or unexpected fields; the warnings say which ones
+	report.DataWarnings = scanner.DataWarnings()
+	regionSummary.Warnings = len(report.DataWarnings)
+	for _, warning := range report.DataWarnings {
+		logger.Warn("data quality", "resource_type", warning.ResourceType, "resource_id", warning.ResourceID, "field", warning.Field, "raw_value", warning.RawValue, "problem", warning.Problem)
+	}
+
+	regionSummary.Counts = map[string]int{
+		"vpcs":                 len(report.VPCs),
+		"subnets":              len(report.Subnets),
+		"route_tables":         len(report.RouteTables),
+		"security_groups":      len(report.SecurityGroups),
+		"internet_gateways":    len(report.InternetGateways),
+		"nat_gateways":         len(report.NatGateways),
+		"transit_gateways":     len(report.TransitGateways),
+		"tgw_attachments":      len(report.TGWAttachments),
+		"legacy_nat_instances": len(report.LegacyNATInstances),
+	}
+	if report.SubnetNetwork != nil {
+		regionSummary.Counts["subnet_network"] = len(report.SubnetNetwork)
+	}
+	for _, finding := range report.Findings {
+		regionSummary.Findings[finding.Classification]++
+	}
+
+	if event.Bucket != "" {
+		regionSummary.Outputs = h.writeOutputs(ctx, logger, event, report)
+	}
+	if event.TopicArn != "" && len(report.Findings) > 0 {
+		subject, message := findingsMessage(report)
+		if err := h.notify.Publish(ctx, event.TopicArn, subject, message); err != nil {
+			logger.Error("failed to publish findings", "topic_arn", event.TopicArn, "error", err)
+		} else {
+			logger.Info("published findings", "topic_arn", event.TopicArn, "findings", len(report.Findings))
+		}
+	}
+
+	return regionSummary
+}
+
+// writeOutputs writes the JSON report and draw.io diagram for a region
+// Returns: S3 URIs of the objects that were written
+func (h *handler) writeOutputs(ctx context.Context, logger *slog.Logger, event ScanEvent, report *regionReport) []string {
+	var written []string
+	prefix := event.KeyPrefix + report.Region + "/"
+
+	reportJSON, err := output.MarshalIndent(report, output.FieldStyleSnake)
+	if err != nil {
+		logger.Error("failed to marshal report", "error", err)
+	} else if err := h.store.PutObject(ctx, event.Bucket, prefix+"report.json", "application/json", reportJSON); err != nil {
+		logger.Error("failed to write report", "bucket", event.Bucket, "key", prefix+"report.json", "error", err)
+	} else {
+		written = append(written, fmt.Sprintf("s3://%s/%sreport.json", event.Bucket, prefix))
+	}
+
+	generator := diagram.NewDiagramGenerator()
+	generator.SetScanContext(report.Region, h.accountID)
+	diagramXML, err := generator.GenerateVPCDiagram(
+		report.VPCs,
+		report.Subnets,
+		report.RouteTables,
+		report.SecurityGroups,
+		report.InternetGateways,
+		report.NatGateways,
+		report.TransitGateways,
+		report.TGWAttachments,
+	)
+	if err != nil {
+		logger.Error("failed to generate diagram", "error", err)
+	} else if err := h.store.PutObject(ctx, event.Bucket, prefix+"vpc-diagram.drawio", "application/xml", []byte(diagramXML)); err != nil {
+		logger.Error("failed to write diagram", "bucket", event.Bucket, "key", prefix+"vpc-diagram.drawio", "error", err)
+	} else {
+		written = append(written, fmt.Sprintf("s3://%s/%svpc-diagram.drawio", event.Bucket, prefix))
+	}
+
+	logger.Info("wrote outputs", "outputs", written)
+	return written
+}
+
+// filterByVPC keeps only the resources that belong to the given VPCs
+// Transit gateways are kept; their attachments are kept unless they attach a VPC outside the filter
+func filterByVPC(report *regionReport, vpcIDs []string) {
+	keep := make(map[string]bool)
+	for _, id := range vpcIDs {
+		keep[id] = true
+	}
+
+	vpcs := []vpc.VPCInfo{}
+	for _, v := range report.VPCs {
+		if keep[v.VpcID] {
+			vpcs = append(vpcs, v)
+		}
+	}
+	report.VPCs = vpcs
+
+	subnets := []vpc.SubnetInfo{}
+	for _, s := range report.Subnets {
+		if keep[s.VpcID] {
+			subnets = append(subnets, s)
+		}
+	}
+	report.Subnets = subnets
+
+	routeTables := []vpc.RouteTableInfo{}
+	for _, rt := range report.RouteTables {
+		if keep[rt.VpcID] {
+			routeTables = append(routeTables, rt)
+		}
+	}
+	report.RouteTables = routeTables
+
+	securityGroups := []vpc.SecurityGroupInfo{}
+	for _, sg := range report.SecurityGroups {
+		if keep[sg.VpcID] {
+			securityGroups = append(securityGroups, sg)
+		}
+	}
+	report.SecurityGroups = securityGroups
+
+	internetGateways := []vpc.InternetGatewayInfo{}
+	for _, igw := range report.InternetGateways {
+		if keep[igw.VpcID] {
+			internetGateways = append(internetGateways, igw)
+		}
+	}
+	report.InternetGateways = internetGateways
+
+	natGateways := []vpc.NatGatewayInfo{}
+	for _, ngw := range report.NatGateways {
+		if keep[ngw.VpcID] {
+			natGateways = append(natGateways, ngw)
+		}
+	}
+	report.NatGateways = natGateways
+
+	attachments := []vpc.TransitGatewayAttachmentInfo{}
+	for _, attachment := range report.TGWAttachments {
+		if attachment.ResourceType != "vpc" || keep[attachment.ResourceID] {
+			attachments = append(attachments, attachment)
+		}
+	}
+	report.TGWAttachments = attachments
+
+	natInstances := []vpc.RouteApplianceInfo{}
+	for _, appliance := range report.LegacyNATInstances {
+		if keep[appliance.VpcID] {
+			natInstances = append(natInstances, appliance)
+		}
+	}
+	report.LegacyNATInstances = natInstances
+
+	if report.SubnetNetwork != nil {
+		subnetNetwork := []analysis.SubnetNetworkCapacity{}
+		for _, rollup := range report.SubnetNetwork {
+			if keep[rollup.VpcID] {
+				subnetNetwork = append(subnetNetwork, rollup)
+			}
+		}
+		report.SubnetNetwork = subnetNetwork
+	}
+}
+
+// findingsMessage builds the SNS subject and body for a region's findings
+func findingsMessage(report *regionReport) (string, string) {
+	counts := make(map[string]int)
+	for _, finding := range report.Findings {
+		counts[finding.Classification]++
+	}
+	var classes []string
+	for classification := range counts {
+		classes = append(classes, classification)
+	}
+	sort.Strings(classes)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "aws-documentor found %d security group reference finding(s) in %s.\n\n", len(report.Findings), report.Region)
+	for _, classification := range classes {
+		fmt.Fprintf(&b, "  %s: %d\n", classification, counts[classification])
+	}
+	b.WriteString("\n")
+	for i, finding := range report.Findings {
+		if i == snsFindingLimit {
+			fmt.Fprintf(&b, "... and %d more\n", len(report.Findings)-snsFindingLimit)
+			break
+		}
+		fmt.Fprintf(&b, "- %s (%s) -> %s: %s\n", finding.GroupID, finding.GroupName, finding.ReferencedGroupID, finding.Reason)
+	}
+
+	// SNS subjects are limited to 100 characters
+	subject := fmt.Sprintf("aws-documentor: %d finding(s) in %s", len(report.Findings), report.Region)
+	return subject, b.String()
+}
+
+// s3Store writes objects to S3
+type s3Store struct {
+	client *s3.Client
+}
+
+// PutObject uploads a single object
+func (s *s3Store) PutObject(ctx context.Context, bucket, key, contentType string, body []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(body),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put s3://%s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// snsNotifier publishes messages to SNS
+type snsNotifier struct {
+	client *sns.Client
+}
+
+// Publish sends a message to a topic
+func (n *snsNotifier) Publish(ctx context.Context, topicArn, subject, message string) error {
+	_, err := n.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(topicArn),
+		Subject:  aws.String(subject),
+		Message:  aws.String(message),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topicArn, err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"aws-documentor/modules/vpc"
+)
+
+// fakeScanner returns fixed resources, failing at one resource type
+type fakeScanner struct {
+	failAt   string    // Resource type whose call fails ("" for none): vpcs, subnets, ...
+	deadline time.Time // Deadline of the context of the GetVPCs call
+}
+
+// fail returns the error of a resource type's call, or nil
+func (s *fakeScanner) fail(resourceType string) error {
+	if s.failAt == resourceType {
+		return errors.New("UnauthorizedOperation: " + resourceType)
+	}
+	return nil
+}
+
+func (s *fakeScanner) GetVPCs(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.VPCInfo, error) {
+	s.deadline, _ = ctx.Deadline()
+	return []vpc.VPCInfo{{VpcID: "vpc-a", CidrBlock: "10.0.0.0/16"}, {VpcID: "vpc-b", CidrBlock: "10.1.0.0/16"}}, s.fail("vpcs")
+}
+
+func (s *fakeScanner) GetSubnets(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SubnetInfo, error) {
+	return []vpc.SubnetInfo{{SubnetID: "subnet-a", VpcID: "vpc-a", CidrBlock: "10.0.0.0/24"}, {SubnetID: "subnet-b", VpcID: "vpc-b", CidrBlock: "10.1.0.0/24"}}, s.fail("subnets")
+}
+
+func (s *fakeScanner) GetRouteTables(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.RouteTableInfo, error) {
+	return []vpc.RouteTableInfo{{RouteTableID: "rtb-a", VpcID: "vpc-a"}}, s.fail("route_tables")
+}
+
+// GetSecurityGroups returns a group of vpc-a with a dangling reference, which is a finding, and one of vpc-b
+func (s *fakeScanner) GetSecurityGroups(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SecurityGroupInfo, error) {
+	return []vpc.SecurityGroupInfo{
+		{GroupID: "sg-a", GroupName: "web", VpcID: "vpc-a", OwnerID: "123456789012",
+			Rules: []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 443, ToPort: 443, GroupID: "sg-deleted", GroupOwnerID: "123456789012"}}},
+		{GroupID: "sg-b", GroupName: "db", VpcID: "vpc-b", OwnerID: "123456789012"},
+	}, s.fail("security_groups")
+}
+
+func (s *fakeScanner) GetInternetGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.InternetGatewayInfo, error) {
+	return []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-a", VpcID: "vpc-a"}}, s.fail("internet_gateways")
+}
+
+func (s *fakeScanner) GetNatGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.NatGatewayInfo, error) {
+	return nil, s.fail("nat_gateways")
+}
+
+func (s *fakeScanner) GetTransitGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.TransitGatewayInfo, error) {
+	return []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-1"}}, s.fail("transit_gateways")
+}
+
+func (s *fakeScanner) GetTransitGatewayAttachments(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.TransitGatewayAttachmentInfo, error) {
+	return []vpc.TransitGatewayAttachmentInfo{
+		{AttachmentID: "tgw-attach-a", TransitGatewayID: "tgw-1", ResourceType: "vpc", ResourceID: "vpc-a"},
+		{AttachmentID: "tgw-attach-b", TransitGatewayID: "tgw-1", ResourceType: "vpc", ResourceID: "vpc-b"},
+		{AttachmentID: "tgw-attach-vpn", TransitGatewayID: "tgw-1", ResourceType: "vpn", ResourceID: "vpn-1"},
+	}, s.fail("tgw_attachments")
+}
+
+func (s *fakeScanner) GetRouteAppliances(ctx context.Context, routeTables []vpc.RouteTableInfo) ([]vpc.RouteApplianceInfo, error) {
+	return nil, s.fail("legacy_nat_instances")
+}
+
+func (s *fakeScanner) GetInstances(ctx context.Context) ([]vpc.InstanceInfo, error) {
+	return []vpc.InstanceInfo{{InstanceID: "i-1", InstanceType: "m5.large", SubnetID: "subnet-a", VpcID: "vpc-a"}}, s.fail("instances")
+}
+
+func (s *fakeScanner) GetInstanceTypes(ctx context.Context, names []string) (map[string]vpc.InstanceTypeNetworkInfo, error) {
+	return map[string]vpc.InstanceTypeNetworkInfo{}, nil
+}
+
+// DataWarnings returns one warning per region
+func (s *fakeScanner) DataWarnings() []vpc.DataWarning {
+	return []vpc.DataWarning{{ResourceType: "subnet", ResourceID: "subnet-b", Field: "State", RawValue: "pending-delete", Problem: "unrecognized"}}
+}
+
+// fakeStore records the objects written, failing every write if err is set
+type fakeStore struct {
+	mu      sync.Mutex
+	err     error
+	objects map[string][]byte // Body by bucket/key
+}
+
+func (s *fakeStore) PutObject(ctx context.Context, bucket, key, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.objects == nil {
+		s.objects = make(map[string][]byte)
+	}
+	s.objects[bucket+"/"+key] = body
+	return nil
+}
+
+// fakeNotifier records the messages published, failing every publish if err is set
+type fakeNotifier struct {
+	err      error
+	subjects []string
+	messages []string
+}
+
+func (n *fakeNotifier) Publish(ctx context.Context, topicArn, subject, message string) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.subjects = append(n.subjects, subject)
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+// newTestHandler returns a handler with fake scanners, store and notifier
+// failAt: Resource type whose call fails, by region
+func newTestHandler(failAt map[string]string) (*handler, map[string]*fakeScanner, *fakeStore, *fakeNotifier) {
+	scanners := make(map[string]*fakeScanner)
+	store := &fakeStore{}
+	notify := &fakeNotifier{}
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	h := &handler{
+		cfg: aws.Config{Region: "eu-west-1"},
+		newScanner: func(cfg aws.Config) regionScanner {
+			scanner := &fakeScanner{failAt: failAt[cfg.Region]}
+			scanners[cfg.Region] = scanner
+			return scanner
+		},
+		store:  store,
+		notify: notify,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		now:    func() time.Time { return now },
+	}
+	return h, scanners, store, notify
+}
+
+// TestHandle checks the summary, the S3 objects and the SNS messages of a scan of two regions
+func TestHandle(t *testing.T) {
+	h, scanners, store, notify := newTestHandler(nil)
+	event := ScanEvent{Regions: []string{"eu-west-1", "us-east-1"}, Bucket: "docs", KeyPrefix: "scans/", TopicArn: "arn:aws:sns:eu-west-1:123456789012:findings"}
+	summary, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summary.Regions) != 2 || len(scanners) != 2 || summary.Partial {
+		t.Fatalf("summary = %+v, want two complete regions", summary)
+	}
+	if summary.Counts["vpcs"] != 4 || summary.Counts["tgw_attachments"] != 6 || summary.Findings["dangling"] != 2 || summary.Warnings != 2 {
+		t.Errorf("counts = %v, findings = %v, %d data warnings; want 4 VPCs, 6 attachments, 2 dangling references and 2 warnings",
+			summary.Counts, summary.Findings, summary.Warnings)
+	}
+	wantOutputs := []string{"s3://docs/scans/eu-west-1/report.json", "s3://docs/scans/eu-west-1/vpc-diagram.drawio"}
+	if !slices.Equal(summary.Regions[0].Outputs, wantOutputs) {
+		t.Errorf("outputs = %v, want %v", summary.Regions[0].Outputs, wantOutputs)
+	}
+	if len(store.objects) != 4 {
+		t.Errorf("wrote %d objects, want a report and a diagram per region", len(store.objects))
+	}
+
+	var report regionReport
+	if err := json.Unmarshal(store.objects["docs/scans/us-east-1/report.json"], &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Region != "us-east-1" || report.ScannedAt != "2024-03-10T12:00:00Z" || len(report.VPCs) != 2 || len(report.Findings) != 1 || report.SubnetNetwork != nil {
+		t.Errorf("report.json = %+v", report)
+	}
+	if !strings.Contains(string(store.objects["docs/scans/eu-west-1/vpc-diagram.drawio"]), "vpc-a") {
+		t.Error("the diagram does not draw vpc-a")
+	}
+
+	if len(notify.subjects) != 2 || notify.subjects[0] != "aws-documentor: 1 finding(s) in eu-west-1" {
+		t.Errorf("published %q, want one message per region", notify.subjects)
+	}
+	if !strings.Contains(notify.messages[0], "sg-a (web) -> sg-deleted") {
+		t.Errorf("message = %q, want the dangling reference", notify.messages[0])
+	}
+}
+
+// TestHandleFilterByVPC checks that vpc_ids keeps only the resources of those VPCs, and attachments of other types
+func TestHandleFilterByVPC(t *testing.T) {
+	h, _, store, _ := newTestHandler(nil)
+	summary, err := h.Handle(context.Background(), ScanEvent{VpcIDs: []string{"vpc-b"}, Bucket: "docs", InstanceNetwork: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := summary.Regions[0].Counts
+	if counts["vpcs"] != 1 || counts["subnets"] != 1 || counts["route_tables"] != 0 || counts["internet_gateways"] != 0 ||
+		counts["tgw_attachments"] != 2 || counts["subnet_network"] != 0 || len(summary.Findings) != 0 {
+		t.Errorf("counts = %v, findings = %v; want only the resources of vpc-b", counts, summary.Findings)
+	}
+
+	var report regionReport
+	if err := json.Unmarshal(store.objects["docs/eu-west-1/report.json"], &report); err != nil {
+		t.Fatal(err)
+	}
+	for _, attachment := range report.TGWAttachments {
+		if attachment.ResourceID == "vpc-a" {
+			t.Errorf("the attachment of vpc-a was kept")
+		}
+	}
+	if report.SubnetNetwork == nil {
+		t.Error("instance_network did not set subnet_network")
+	}
+}
+
+// TestHandleFailures covers a partial region, failed regions and failing outputs
+func TestHandleFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		failAt      map[string]string
+		storeErr    error
+		notifyErr   error
+		wantErr     bool
+		wantPartial bool
+		wantOutputs int // Outputs of the first region
+	}{
+		{name: "partial", failAt: map[string]string{"eu-west-1": "security_groups"}, wantPartial: true, wantOutputs: 2},
+		{name: "one region without VPCs", failAt: map[string]string{"eu-west-1": "vpcs"}},
+		{name: "every region without VPCs", failAt: map[string]string{"eu-west-1": "vpcs", "us-east-1": "vpcs"}, wantErr: true},
+		{name: "S3 fails", storeErr: errors.New("AccessDenied"), wantOutputs: 0},
+		{name: "SNS fails", notifyErr: errors.New("AuthorizationError"), wantOutputs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, store, notify := newTestHandler(tt.failAt)
+			store.err = tt.storeErr
+			notify.err = tt.notifyErr
+			summary, err := h.Handle(context.Background(), ScanEvent{Regions: []string{"eu-west-1", "us-east-1"}, Bucket: "docs", TopicArn: "arn:aws:sns:eu-west-1:123456789012:findings"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Handle() error = %v, want error %v", err, tt.wantErr)
+			}
+			first := summary.Regions[0]
+			if summary.Partial != tt.wantPartial || first.Partial != tt.wantPartial {
+				t.Errorf("partial = %v, region partial = %v; want %v", summary.Partial, first.Partial, tt.wantPartial)
+			}
+			if tt.failAt["eu-west-1"] != "" && !strings.Contains(first.Error, tt.failAt["eu-west-1"]) {
+				t.Errorf("region error = %q, want the failed %s call", first.Error, tt.failAt["eu-west-1"])
+			}
+			if len(first.Outputs) != tt.wantOutputs {
+				t.Errorf("outputs = %v, want %d", first.Outputs, tt.wantOutputs)
+			}
+			if second := summary.Regions[1]; tt.failAt["us-east-1"] == "" && second.Counts["vpcs"] != 2 {
+				t.Errorf("us-east-1 counts = %v, want its scan to carry on", second.Counts)
+			}
+		})
+	}
+}
+
+// TestHandleDeadline checks that the scan stops uploadReserve before the invocation deadline
+func TestHandleDeadline(t *testing.T) {
+	h, scanners, _, _ := newTestHandler(nil)
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if _, err := h.Handle(ctx, ScanEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanners["eu-west-1"].deadline; !got.Equal(deadline.Add(-uploadReserve)) {
+		t.Errorf("scan deadline = %v, want %v", got, deadline.Add(-uploadReserve))
+	}
+}
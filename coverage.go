@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/coverage"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+// runCoverage implements "aws-documentor coverage [flags]"
+// It counts the resources of the account per tagging API type and prints which of them this tool scans,
+// with the types the last scan covered according to its -scan-manifest.
+// args: Command-line arguments after the subcommand
+func runCoverage(args []string) {
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	region := flags.String("region", "", "AWS region to probe (optional, uses default config if not specified)")
+	scanManifest := flags.String("scan-manifest", "", "Manifest written by the last scan with -scan-manifest, to show which types it covered")
+	outputJSON := flags.Bool("json", false, "Print the coverage report as JSON instead of a table")
+	maxAPICalls := flags.Int("max-api-calls", 0, "Stop calling AWS after this many API requests and report the counts read so far (default: no limit)")
+	proxy := flags.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	if *region != "" {
+		problems.Check("-region", config.CheckRegion(*region))
+	}
+	if *proxy != "" {
+		_, err := awsconfig.ParseProxy(*proxy)
+		problems.Check("-proxy", err)
+	}
+	if *maxAPICalls < 0 {
+		problems.Addf("-max-api-calls", 0, "must not be negative")
+	}
+	var manifest *coverage.Manifest
+	if *scanManifest != "" {
+		var err error
+		manifest, err = coverage.LoadManifest(*scanManifest)
+		problems.Check("-scan-manifest", err)
+	}
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	ctx := context.Background()
+	httpOptions := awsconfig.DefaultHTTPOptions()
+	httpOptions.Proxy = *proxy
+	cfg, _, err := awsconfig.Load(ctx, *region, httpOptions)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+	budget := awsconfig.NewCallBudget(*maxAPICalls)
+	cfg.APIOptions = append(cfg.APIOptions, budget.AddMiddleware)
+
+	counts, err := coverage.NewScanner(cfg).CountResources(ctx)
+	switch {
+	case errors.Is(err, awsconfig.ErrCallBudgetExceeded):
+		log.Printf("Warning: -max-api-calls %d reached; counts are from the pages read before it", *maxAPICalls)
+	case errors.Is(err, vpc.ErrAccessDenied):
+		log.Fatalf("%v\nThe coverage report needs tag:GetResources; without it the account cannot be probed", err)
+	case err != nil:
+		exitOnScanError(err)
+	}
+
+	if manifest != nil && manifest.Region != cfg.Region {
+		log.Printf("Warning: the scan manifest is for %s, the probe for %s", manifest.Region, cfg.Region)
+	}
+	report := coverage.Compare(coverage.ResourceTypes, counts, manifest)
+	if *outputJSON {
+		reportJSON, _ := output.MarshalIndent(report, output.FieldStyleSnake)
+		fmt.Printf("%s\n", reportJSON)
+		return
+	}
+	writeCoverage(os.Stdout, report, cfg.Region)
+}
+
+// writeCoverage prints the coverage report as a table, followed by the network types missing from the documentation
+func writeCoverage(w io.Writer, report *coverage.Report, region string) {
+	fmt.Fprintf(w, "Resource type coverage for %s (counts are tagged resources):\n", region)
+	fmt.Fprintf(w, "  %-38s %-9s %6s  %-9s %s\n", "resource type", "supported", "count", "last scan", "enabled by")
+	for _, row := range report.Rows {
+		enabledBy := row.Flags
+		if enabledBy == "" && row.Supported != coverage.SupportNo {
+			enabledBy = "default scan"
+		}
+		line := fmt.Sprintf("  %-38s %-9s %6d  %-9s %s", row.ResourceType, row.Supported, row.Count, row.InLastScan, enabledBy)
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	if len(report.MissingNetworkTypes) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nNetwork resources in the account that are not documented:")
+	for _, row := range report.MissingNetworkTypes {
+		fmt.Fprintf(w, "  %s: %d (%s)\n", row.ResourceType, row.Count, row.Description)
+	}
+}
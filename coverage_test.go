@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"aws-documentor/modules/coverage"
+)
+
+// TestWriteCoverage checks the coverage table, without trailing spaces where no flag enables a type, the
+// enabling flags of default scans and the list of network resources missing from the documentation
+func TestWriteCoverage(t *testing.T) {
+	missing := coverage.Row{ResourceType: "ec2:prefix-list", Description: "Managed prefix lists", Supported: coverage.SupportNo, Network: true,
+		Count: 2, InLastScan: coverage.LastScanNo}
+	report := &coverage.Report{
+		Rows: []coverage.Row{
+			missing,
+			{ResourceType: "ec2:vpc", Supported: coverage.SupportYes, Count: 3, InLastScan: coverage.LastScanYes},
+			{ResourceType: "route53:hostedzone", Supported: coverage.SupportPartial, Flags: "-dns", Count: 4, InLastScan: coverage.LastScanNo},
+		},
+		MissingNetworkTypes: []coverage.Row{missing},
+	}
+	var out bytes.Buffer
+	writeCoverage(&out, report, "eu-west-1")
+	want := `Resource type coverage for eu-west-1 (counts are tagged resources):
+  resource type                          supported  count  last scan enabled by
+  ec2:prefix-list                        no             2  no
+  ec2:vpc                                yes            3  yes       default scan
+  route53:hostedzone                     partial        4  no        -dns
+
+Network resources in the account that are not documented:
+  ec2:prefix-list: 2 (Managed prefix lists)
+`
+	if out.String() != want {
+		t.Errorf("writeCoverage() =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	report.MissingNetworkTypes = nil
+	writeCoverage(&out, report, "eu-west-1")
+	if bytes.Contains(out.Bytes(), []byte("not documented")) {
+		t.Errorf("writeCoverage() lists missing network types although there are none:\n%s", out.String())
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/query"
+)
+
+// runDiagramCheck implements "aws-documentor diagram-check -file FILE [flags]"
+// It reads the VPCs, subnets and gateways a draw.io diagram depicts and lists where the diagram and AWS
+// (a saved JSON report or a fresh scan of the core VPC resources) disagree. Exits with status 4 (exitFindings) on drift.
+// args: Command-line arguments after the subcommand
+func runDiagramCheck(args []string) {
+	flags := flag.NewFlagSet("diagram-check", flag.ExitOnError)
+	file := flags.String("file", "", "draw.io diagram to check, generated by this tool or drawn by hand with the AWS shapes")
+	fromFile := flags.String("from-file", "", "JSON report from an earlier scan to check against instead of scanning")
+	freshness := registerFreshnessFlags(flags, "-from-file")
+	vpcs := flags.String("vpc", "", "Comma-separated VPC IDs the diagram documents; resources of other VPCs and transit gateways are then not reported as missing from the diagram")
+	outputJSON := flags.Bool("json", false, "Print the differences as JSON instead of a table")
+	region := flags.String("region", "", "AWS region to scan (optional, uses default config if not specified)")
+	proxy := flags.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	var nodes []diagram.ImportedNode
+	if *file == "" {
+		problems.Addf("-file", 0, "missing, for example: diagram-check -file architecture.drawio")
+	} else if data, err := os.ReadFile(*file); err != nil {
+		problems.Addf("-file", 0, "cannot read diagram: %v", err)
+	} else {
+		// Read before scanning, so a file draw.io cannot open does not cost a scan
+		nodes, err = diagram.ImportDrawIO(data)
+		problems.Check("-file", err)
+	}
+	if *region != "" {
+		problems.Check("-region", config.CheckRegion(*region))
+	}
+	if *proxy != "" {
+		_, err := awsconfig.ParseProxy(*proxy)
+		problems.Check("-proxy", err)
+	}
+	if *fromFile != "" && (*region != "" || *proxy != "") {
+		problems.Addf("-from-file", 0, "cannot be combined with -region or -proxy")
+	}
+	freshness.validate(problems, *fromFile != "")
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	var inventory diagram.Inventory
+	if *fromFile != "" {
+		report, err := query.LoadReport(*fromFile)
+		if err != nil {
+			log.Fatalf("Failed to load report: %v", err)
+		}
+		freshness.checkSubcommand(report.ScannedAt)
+		inventory = diagram.Inventory{
+			VPCs:             report.VPCs,
+			Subnets:          report.Subnets,
+			InternetGateways: report.InternetGateways,
+			NatGateways:      report.NatGateways,
+			TransitGateways:  report.TransitGateways,
+		}
+	} else {
+		ctx := context.Background()
+		scan := scanCoreResources(ctx, loadSubcommandConfig(ctx, *region, *proxy))
+		inventory = diagram.Inventory{
+			VPCs:             scan.VPCs,
+			Subnets:          scan.Subnets,
+			InternetGateways: scan.InternetGateways,
+			NatGateways:      scan.NatGateways,
+			TransitGateways:  scan.TransitGateways,
+		}
+	}
+
+	drifts := diagram.CheckDrift(nodes, inventory, splitList(*vpcs))
+	if *outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(drifts); err != nil {
+			log.Fatalf("Failed to write differences: %v", err)
+		}
+	} else {
+		writeDrifts(len(nodes), drifts)
+	}
+	if len(drifts) > 0 {
+		os.Exit(exitFindings)
+	}
+}
+
+// writeDrifts prints the differences between a diagram and AWS as a table
+// nodes: Number of VPCs, subnets and gateways found in the diagram
+// drifts: Differences from CheckDrift
+func writeDrifts(nodes int, drifts []diagram.Drift) {
+	if len(drifts) == 0 {
+		fmt.Printf("No drift: the %d VPCs, subnets and gateways of the diagram match AWS\n", nodes)
+		return
+	}
+	fmt.Printf("%d differences between the diagram (%d VPCs, subnets and gateways) and AWS:\n\n", len(drifts), nodes)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROBLEM\tKIND\tRESOURCE\tDIAGRAM\tAWS")
+	for _, drift := range drifts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", drift.Problem, drift.Kind, orDash(drift.ResourceID), orDash(drift.Diagram), orDash(drift.AWS))
+	}
+	tw.Flush()
+}
+
+// orDash returns "-" for an empty table cell
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
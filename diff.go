@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"aws-documentor/modules/changepolicy"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/report"
+)
+
+// ANSI colors of the -text lines of the diff subcommand
+const (
+	colorAdded    = "\033[32m" // Green
+	colorRemoved  = "\033[31m" // Red
+	colorModified = "\033[33m" // Yellow
+	colorReset    = "\033[0m"
+)
+
+// runDiff implements "aws-documentor diff [flags] BEFORE AFTER"
+// It compares two scan results written by -save and lists the resources added, removed and modified
+// between them, as JSON or as +/- lines. Needs no AWS access. Exits with status 4 if anything changed,
+// so a change is told apart from a failure (1) or usage error (2). With -policy, the changes are judged
+// by a change policy instead, and only a change the policy fails exits with 4.
+// args: Command-line arguments after the subcommand
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	text := flags.Bool("text", false, "Print the changes as +/- lines instead of JSON, colored when stdout is a terminal (NO_COLOR turns the colors off)")
+	policyFile := flags.String("policy", "", "Judge the changes by this change policy file (\"default\" for the built-in policy) and exit with status 4 only if a change fails it")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	paths := flags.Args()
+	if len(paths) != 2 {
+		problems.Addf("diff", 0, "needs two scan results, for example: diff monday.json tuesday.json")
+	}
+	if *policyFile != "" && *policyFile != "default" {
+		problems.PolicyFile(*policyFile)
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+	var policy *changepolicy.Policy
+	if *policyFile == "default" {
+		policy = changepolicy.DefaultPolicy()
+	} else if *policyFile != "" {
+		var err error
+		if policy, err = changepolicy.LoadPolicy(*policyFile); err != nil {
+			log.Fatalf("Failed to load policy: %v", err)
+		}
+	}
+
+	before, err := report.Load(paths[0])
+	if err != nil {
+		log.Fatalf("Failed to load scan: %v", err)
+	}
+	after, err := report.Load(paths[1])
+	if err != nil {
+		log.Fatalf("Failed to load scan: %v", err)
+	}
+	// Every resource of another region or account would show as added or removed
+	if before.Region != after.Region || before.AccountID != after.AccountID {
+		log.Printf("Warning: the scans are of different regions or accounts (%s/%s and %s/%s)",
+			before.AccountID, before.Region, after.AccountID, after.Region)
+	}
+
+	scanDiff := report.Diff(before, after)
+	var verdict *changepolicy.Verdict
+	if policy != nil {
+		verdict = policy.Evaluate(changepolicy.FromDiff(scanDiff))
+	}
+	if *text {
+		color := stdoutIsTerminal() && os.Getenv("NO_COLOR") == ""
+		writeScanDiff(os.Stdout, scanDiff, color)
+		if verdict != nil {
+			writeVerdict(os.Stdout, verdict, color)
+		}
+	} else if verdict != nil {
+		diffJSON, _ := output.MarshalIndent(map[string]interface{}{"diff": scanDiff, "policy": verdict}, output.FieldStyleSnake)
+		fmt.Printf("%s\n", diffJSON)
+	} else {
+		diffJSON, _ := output.MarshalIndent(scanDiff, output.FieldStyleSnake)
+		fmt.Printf("%s\n", diffJSON)
+	}
+	if code := diffStatus(scanDiff, verdict); code != exitSuccess {
+		os.Exit(code)
+	}
+}
+
+// diffStatus decides the exit code of the diff subcommand
+// Without a policy every change counts; with one, only a change the policy fails does, and warnings
+// are printed without failing the pipeline.
+// verdict: Verdict of the -policy (nil without one)
+// Returns: exitFindings or exitSuccess
+func diffStatus(scanDiff *report.ScanDiff, verdict *changepolicy.Verdict) int {
+	if verdict != nil {
+		if verdict.Result == changepolicy.ResultFail {
+			return exitFindings
+		}
+		return exitSuccess
+	}
+	if !scanDiff.Empty() {
+		return exitFindings
+	}
+	return exitSuccess
+}
+
+// writeVerdict prints the changes a policy warned about or failed, with the rule that matched each
+// color: Whether to color the lines with ANSI escapes
+func writeVerdict(w io.Writer, verdict *changepolicy.Verdict, color bool) {
+	fmt.Fprintf(w, "\nPolicy: %s (%d fail, %d warn, %d allow)\n", verdict.Result,
+		verdict.Counts[changepolicy.ActionFail], verdict.Counts[changepolicy.ActionWarn], verdict.Counts[changepolicy.ActionAllow])
+	for _, entry := range verdict.Changes {
+		if entry.Action == changepolicy.ActionAllow {
+			continue
+		}
+		rule := entry.Rule
+		if rule == "" {
+			rule = "unmatched"
+		}
+		target := entry.Change.ResourceID
+		if entry.Change.Field != "" {
+			target += " " + entry.Change.Field
+		}
+		line := fmt.Sprintf("%s %s %s %s (%s)", entry.Action, entry.Change.Kind, entry.Change.ResourceType, target, rule)
+		if color && entry.Action == changepolicy.ActionFail {
+			line = colorRemoved + line + colorReset
+		} else if color {
+			line = colorModified + line + colorReset
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// writeScanDiff prints the changes of a diff section by section: + added, - removed, ~ modified with its fields
+// color: Whether to color the lines with ANSI escapes
+func writeScanDiff(w io.Writer, scanDiff *report.ScanDiff, color bool) {
+	changes := scanDiff.Changes()
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Change]++
+	}
+	fmt.Fprintf(w, "Changes from %s to %s: %d added, %d removed, %d modified\n", scanDiff.BeforeScanTime, scanDiff.AfterScanTime,
+		counts[diff.ChangeAdded], counts[diff.ChangeRemoved], counts[diff.ChangeModified])
+
+	section := ""
+	for _, change := range changes {
+		if change.Section != section {
+			section = change.Section
+			fmt.Fprintf(w, "\n%s\n", section)
+		}
+		name := ""
+		if change.Name != "" && change.Name != change.ResourceID {
+			name = fmt.Sprintf(" (%s)", change.Name)
+		}
+		var marker, lineColor, fields string
+		switch change.Change {
+		case diff.ChangeAdded:
+			marker, lineColor = "+", colorAdded
+		case diff.ChangeRemoved:
+			marker, lineColor = "-", colorRemoved
+		default:
+			marker, lineColor = "~", colorModified
+			fields = ": " + strings.Join(change.Fields, ", ")
+		}
+		line := fmt.Sprintf("%s %s%s%s", marker, change.ResourceID, name, fields)
+		if color {
+			line = lineColor + line + colorReset
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	if len(scanDiff.SkippedSections) > 0 {
+		fmt.Fprintf(w, "\nNot compared (not scanned in one of the scans): %s\n", strings.Join(scanDiff.SkippedSections, ", "))
+	}
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal rather than a file or pipe
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/changepolicy"
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/vpc"
+)
+
+// TestDiffStatus checks the exit codes of the diff subcommand with and without a change policy
+func TestDiffStatus(t *testing.T) {
+	scan := func(cidr string) *report.ScanResult {
+		return &report.ScanResult{ScanTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Region: "eu-west-1",
+			VPCs: []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: cidr}}}
+	}
+	unchanged := report.Diff(scan("10.0.0.0/16"), scan("10.0.0.0/16"))
+	changed := report.Diff(scan("10.0.0.0/16"), scan("10.1.0.0/16"))
+	judge := func(action string) *changepolicy.Verdict {
+		policy := &changepolicy.Policy{Rules: []changepolicy.Rule{{Field: "cidr_block", Action: action}}}
+		return policy.Evaluate(changepolicy.FromDiff(changed))
+	}
+
+	tests := []struct {
+		name     string
+		scanDiff *report.ScanDiff
+		verdict  *changepolicy.Verdict
+		want     int
+	}{
+		{name: "no changes", scanDiff: unchanged, want: exitSuccess},
+		{name: "changes", scanDiff: changed, want: exitFindings},
+		{name: "policy allows", scanDiff: changed, verdict: judge(changepolicy.ActionAllow), want: exitSuccess},
+		{name: "policy warns", scanDiff: changed, verdict: judge(changepolicy.ActionWarn), want: exitSuccess},
+		{name: "policy fails", scanDiff: changed, verdict: judge(changepolicy.ActionFail), want: exitFindings},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffStatus(tt.scanDiff, tt.verdict); got != tt.want {
+				t.Errorf("diffStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	writeVerdict(&out, judge(changepolicy.ActionFail), false)
+	if want := "\nPolicy: fail (1 fail, 0 warn, 0 allow)\nfail modified vpc vpc-0a1 cidr_block (rule 1)\n"; out.String() != want {
+		t.Errorf("writeVerdict() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
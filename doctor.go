@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"aws-documentor/modules/arnbuild"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/doctor"
+	"aws-documentor/modules/output"
+)
+
+// partitionRegions are regions every account of a partition has enabled, for the opt-in lookup
+var partitionRegions = map[string]string{
+	"aws":        "us-east-1",
+	"aws-cn":     "cn-north-1",
+	"aws-us-gov": "us-gov-west-1",
+	"aws-iso":    "us-iso-east-1",
+	"aws-iso-b":  "us-isob-east-1",
+}
+
+// runDoctor implements "aws-documentor doctor [flags]"
+// It checks credentials, permissions, region, network, clock, output directories and the age of the
+// build, printing pass, warn, fail or skip per check with what to do. The exit status is 4 if a
+// critical check fails.
+// args: Command-line arguments after the subcommand
+func runDoctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	region := flags.String("region", "", "AWS region the scans use (optional, uses default config if not specified)")
+	profile := flags.String("profile", "", "Profile of the shared AWS config files to check (default: AWS_PROFILE or the default credentials)")
+	proxy := flags.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	dirs := flags.String("dirs", ".", "Comma-separated output and cache directories the scans write to, such as those of -pdf, -graph-out and -checkpoint-dir")
+	timeout := flags.Duration("timeout", 10*time.Second, "Limit for each check")
+	outputJSON := flags.Bool("json", false, "Print the results as JSON instead of a list")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	if *region != "" {
+		problems.Check("-region", config.CheckRegion(*region))
+	}
+	if *proxy != "" {
+		_, err := awsconfig.ParseProxy(*proxy)
+		problems.Check("-proxy", err)
+	}
+	if len(splitList(*dirs)) == 0 {
+		problems.Addf("-dirs", 0, "must name at least one directory")
+	}
+	if *timeout <= 0 {
+		problems.Addf("-timeout", 0, "must be positive")
+	}
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	ctx := context.Background()
+	httpOptions := awsconfig.DefaultHTTPOptions()
+	httpOptions.Proxy = *proxy
+	httpOptions.Timeout = *timeout
+	cfg, _, loadErr := awsconfig.LoadProfile(ctx, *region, *profile, httpOptions)
+	released, _ := time.Parse("2006-01-02", releaseDate)
+	checks := doctorChecks(ctx, cfg, loadErr, *profile, *proxy)
+	checks = append(checks,
+		&doctor.WritableCheck{Dirs: splitList(*dirs)},
+		&doctor.VersionCheck{Version: version, ReleasedAt: released, MaxAge: 180 * 24 * time.Hour},
+	)
+
+	report := doctor.Run(ctx, checks, *timeout)
+	if *outputJSON {
+		reportJSON, _ := output.MarshalIndent(report, output.FieldStyleSnake)
+		fmt.Printf("%s\n", reportJSON)
+	} else {
+		writeDoctor(os.Stdout, report)
+	}
+	if report.CriticalFailures > 0 {
+		os.Exit(exitFindings)
+	}
+}
+
+// doctorChecks returns the checks that need AWS, or skipped stand-ins when the config cannot be loaded
+// ctx: Context for resolving the endpoint
+// cfg: AWS config of the profile and region
+// loadErr: Error loading cfg (nil if it loaded)
+// profile: Profile of cfg, for the output
+// proxy: -proxy value ("" to take the proxy from the environment)
+func doctorChecks(ctx context.Context, cfg aws.Config, loadErr error, profile, proxy string) []doctor.Check {
+	credentials := &doctor.CredentialsCheck{LoadErr: loadErr, Profile: profile, ExpiryAhead: 15 * time.Minute}
+	if loadErr != nil {
+		reason := "the AWS config cannot be loaded"
+		return []doctor.Check{
+			credentials,
+			doctor.Skipped{CheckName: "identity (STS)", IsCritical: true, Reason: reason},
+			doctor.Skipped{CheckName: "ec2:DescribeVpcs permission", IsCritical: true, Reason: reason},
+			doctor.Skipped{CheckName: "region", IsCritical: true, Reason: reason},
+			doctor.Skipped{CheckName: "endpoint reachability", IsCritical: true, Reason: reason},
+			doctor.Skipped{CheckName: "clock skew", IsCritical: true, Reason: reason},
+		}
+	}
+	credentials.Provider = cfg.Credentials
+
+	// A region that is not enabled rejects every call, including the opt-in lookup
+	lookupRegion := partitionRegions[arnbuild.Partition(cfg.Region)]
+	checks := []doctor.Check{
+		credentials,
+		&doctor.IdentityCheck{Client: sts.NewFromConfig(cfg), SlowAfter: 2 * time.Second},
+		&doctor.PermissionCheck{Client: ec2.NewFromConfig(cfg)},
+		&doctor.RegionCheck{Client: ec2.NewFromConfig(cfg, func(o *ec2.Options) { o.Region = lookupRegion }), Region: cfg.Region},
+	}
+
+	endpoint, err := ec2.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, ec2.EndpointParameters{Region: aws.String(cfg.Region)})
+	if cfg.Region == "" || err != nil {
+		reason := "no region is configured"
+		if err != nil {
+			reason = fmt.Sprintf("no EC2 endpoint for %s: %v", cfg.Region, err)
+		}
+		return append(checks,
+			doctor.Skipped{CheckName: "endpoint reachability", IsCritical: true, Reason: reason},
+			doctor.Skipped{CheckName: "clock skew", IsCritical: true, Reason: reason},
+		)
+	}
+	url := endpoint.URI.String()
+	return append(checks,
+		&doctor.EndpointCheck{Client: cfg.HTTPClient, URL: url, Proxy: proxyFor(url, proxy)},
+		&doctor.ClockCheck{Client: cfg.HTTPClient, URL: url, WarnAfter: time.Minute, FailAfter: 5 * time.Minute},
+	)
+}
+
+// proxyFor returns the proxy requests to a URL go through, with any password masked
+// url: Request URL
+// proxy: -proxy value ("" to take the proxy from HTTPS_PROXY, HTTP_PROXY and NO_PROXY)
+// Returns: Proxy URL, or "" for a direct connection
+func proxyFor(url, proxy string) string {
+	if proxy != "" {
+		proxyURL, err := awsconfig.ParseProxy(proxy)
+		if err != nil {
+			return proxy
+		}
+		return proxyURL.Redacted()
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return ""
+	}
+	proxyURL, err := http.ProxyFromEnvironment(req)
+	if err != nil || proxyURL == nil {
+		return ""
+	}
+	return proxyURL.Redacted()
+}
+
+// writeDoctor prints one line per check, the remedy of every warning and failure, and the counts
+func writeDoctor(w io.Writer, report *doctor.Report) {
+	labels := map[string]string{doctor.StatusPass: "PASS", doctor.StatusWarn: "WARN", doctor.StatusFail: "FAIL", doctor.StatusSkip: "SKIP"}
+	for _, result := range report.Results {
+		fmt.Fprintf(w, "%-4s  %-28s %s\n", labels[result.Status], result.Name, result.Detail)
+		if result.Remedy != "" {
+			fmt.Fprintf(w, "      %-28s -> %s\n", "", result.Remedy)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed (%d critical), %d skipped\n",
+		report.Passed, report.Warnings, report.Failures, report.CriticalFailures, report.Skipped)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// scanScale is what is known about the account before the scan
+type scanScale struct {
+	VPCs int // Number of VPCs from the DescribeVpcs probe
+}
+
+// scanSelection lists the optional scanners the flags enable
+type scanSelection struct {
+	EffectiveDNS    bool // -dns: DHCP options and VPC DNS attributes
+	VPCDhcpOptions  bool // -verbose with JSON output: DHCP options embedded in the VPCs
+	PrivateDNS      bool // -dns: Route 53 private zones and Resolver endpoints
+	InspectionPaths bool // -inspection-paths or -isolation (transit gateway route tables)
+	Endpoints       bool // -endpoint-coverage, -dns, -third-party, -egress-profiles or a diagram
+	EndpointAZs     bool // -endpoint-coverage: endpoint network interfaces
+	ThirdParty      bool // -third-party
+	ASGs            bool // -asgs
+	Containers      bool // -containers or -sg-usage
+	SGUsage         bool // -sg-usage or -effective-sources: network interface security groups
+	Directories     bool // -directories
+	PrivateAPIs     bool // -private-apis
+	DRReplication   bool // -dr-replication
+	DRRegions       int  // Number of -dr-regions
+	CloudWAN        bool // -cloudwan
+	PublicIPs       bool // -public-ips or -public-ips-csv
+	PublicRecords   bool // -resolve-dns: Route 53 public zones
+	InstanceNetwork bool // -instance-network: the instance types of the instances
+	AutoExpand      bool // -auto-expand-regions
+	AssumeRole      bool // -role-arn
+}
+
+// scanStep is one scanner of a run with its API call formula
+type scanStep struct {
+	Name    string                    // Scanner as named in the estimate
+	Formula string                    // How the call count is derived
+	Calls   func(scale scanScale) int // Estimated calls for the scale
+}
+
+// fixedCalls returns a formula for a scanner making the same number of calls regardless of scale
+func fixedCalls(n int) func(scanScale) int {
+	return func(scanScale) int { return n }
+}
+
+// scanSteps lists the scanners of a run in scan order with their call-count formulas
+// Paginated calls are counted as one page. Per-item calls for items the VPC probe cannot count
+// (hosted zones, transit gateway route tables, core networks, file systems) assume one item per
+// VPC for hosted zones and a single item otherwise, so large accounts exceed the estimate.
+func scanSteps(sel scanSelection) []scanStep {
+	var steps []scanStep
+	if sel.AssumeRole {
+		steps = append(steps, scanStep{"assume_role", "AssumeRole", fixedCalls(1)})
+	}
+	steps = append(steps,
+		scanStep{"account_id", "GetCallerIdentity", fixedCalls(1)},
+		scanStep{"vpcs", "DescribeVpcs", fixedCalls(1)},
+	)
+	if sel.VPCDhcpOptions {
+		steps = append(steps, scanStep{"vpc_dhcp_options", "DescribeDhcpOptions", fixedCalls(1)})
+	}
+	if sel.EffectiveDNS {
+		steps = append(steps, scanStep{"effective_dns", "DescribeDhcpOptions + 2 DescribeVpcAttribute per VPC", func(s scanScale) int { return 1 + 2*s.VPCs }})
+	}
+	steps = append(steps,
+		scanStep{"subnets", "DescribeSubnets", fixedCalls(1)},
+		scanStep{"route_tables", "DescribeRouteTables", fixedCalls(1)},
+		scanStep{"security_groups", "DescribeSecurityGroups", fixedCalls(1)},
+		scanStep{"internet_gateways", "DescribeInternetGateways", fixedCalls(1)},
+		scanStep{"nat_gateways", "DescribeNatGateways", fixedCalls(1)},
+		scanStep{"route_appliances", "up to DescribeNetworkInterfaces + DescribeInstances", fixedCalls(2)},
+		scanStep{"transit_gateways", "DescribeTransitGateways", fixedCalls(1)},
+		scanStep{"tgw_attachments", "DescribeTransitGatewayAttachments + DescribeTransitGatewayVpcAttachments + DescribeTransitGatewayPeeringAttachments", fixedCalls(3)},
+		scanStep{"vpc_peering", "DescribeVpcPeeringConnections", fixedCalls(1)},
+		scanStep{"network_acls", "DescribeNetworkAcls", fixedCalls(1)},
+		scanStep{"carrier_gateways", "DescribeCarrierGateways", fixedCalls(1)},
+		scanStep{"vpn_gateways", "DescribeVpnGateways", fixedCalls(1)},
+		scanStep{"customer_gateways", "DescribeCustomerGateways", fixedCalls(1)},
+		scanStep{"vpn_connections", "DescribeVpnConnections", fixedCalls(1)},
+		scanStep{"dhcp_options", "DescribeDhcpOptions", fixedCalls(1)},
+		scanStep{"network_interfaces", "DescribeNetworkInterfaces", fixedCalls(1)},
+		scanStep{"instances", "DescribeInstances", fixedCalls(1)},
+		scanStep{"flow_logs", "DescribeFlowLogs", fixedCalls(1)},
+		scanStep{"elastic_ips", "DescribeAddresses", fixedCalls(1)},
+	)
+	if sel.InspectionPaths {
+		steps = append(steps, scanStep{"tgw_route_tables", "DescribeTransitGatewayRouteTables + SearchTransitGatewayRoutes per route table", fixedCalls(2)})
+	}
+	if sel.Endpoints {
+		steps = append(steps, scanStep{"vpc_endpoints", "DescribeVpcEndpoints", fixedCalls(1)})
+	}
+	if sel.EndpointAZs {
+		steps = append(steps, scanStep{"endpoint_interfaces", "DescribeNetworkInterfaces", fixedCalls(1)})
+	}
+	if sel.ASGs {
+		steps = append(steps, scanStep{"auto_scaling_groups", "DescribeAutoScalingGroups", fixedCalls(1)})
+	}
+	if sel.Containers {
+		steps = append(steps, scanStep{"containers", "ECS ListClusters + ListServices and DescribeServices per cluster + EKS ListClusters + DescribeCluster, ListNodegroups and ListFargateProfiles per cluster", fixedCalls(6)})
+	}
+	if sel.Directories {
+		steps = append(steps, scanStep{"directories", "DescribeDirectories + DescribeWorkspaces", fixedCalls(2)})
+	}
+	if sel.PrivateAPIs {
+		steps = append(steps, scanStep{"private_apis", "GetRestApis", fixedCalls(1)})
+	}
+	if sel.PrivateDNS {
+		steps = append(steps,
+			scanStep{"private_hosted_zones", "ListHostedZones + GetHostedZone and ListResourceRecordSets per zone + ListHostedZonesByVPC per VPC", func(s scanScale) int { return 1 + 3*s.VPCs }},
+			scanStep{"resolver_endpoints", "ListResolverEndpoints", fixedCalls(1)},
+		)
+	}
+	if sel.DRReplication {
+		regions := 1 + sel.DRRegions
+		steps = append(steps, scanStep{"dr_replication", "DescribeDBInstances and DescribeReplicationConfigurations per region + 3 per DR region for peering and attachments",
+			func(scanScale) int { return 2*regions + 3*sel.DRRegions }})
+	}
+	if sel.CloudWAN {
+		steps = append(steps, scanStep{"cloudwan", "DescribeGlobalNetworks + ListCoreNetworks + GetCoreNetwork, GetCoreNetworkPolicy and ListAttachments per core network", fixedCalls(5)})
+	}
+	if sel.PublicIPs {
+		steps = append(steps, scanStep{"public_ips", "DescribeAddresses + DescribeNetworkInterfaces + DescribeInstances + ListAccelerators + ListCustomRoutingAccelerators", fixedCalls(5)})
+	}
+	if sel.PublicRecords {
+		steps = append(steps, scanStep{"public_records", "ListHostedZones + ListResourceRecordSets per public zone (zones counted as 1)", fixedCalls(2)})
+	}
+	if sel.InstanceNetwork {
+		steps = append(steps, scanStep{"instance_network", "DescribeInstanceTypes per 100 instance types present", fixedCalls(1)})
+	}
+	if sel.SGUsage {
+		steps = append(steps, scanStep{"interface_groups", "DescribeNetworkInterfaces", fixedCalls(1)})
+	}
+	if sel.AutoExpand {
+		steps = append(steps, scanStep{"scan_gap_follow_ups", "DescribeVpcs + DescribeTransitGateways per peer region", fixedCalls(2)})
+	}
+	if sel.ThirdParty {
+		steps = append(steps, scanStep{"endpoint_services", "DescribeVpcEndpointServices", fixedCalls(1)})
+	}
+	return steps
+}
+
+// writeEstimate prints the estimated calls per scanner and returns the total
+// w: Destination of the estimate
+// steps: Scanners from scanSteps
+// scale: Result of the probe
+// maxCalls: The -max-api-calls cap, 0 for none
+// Returns: Estimated total number of API calls
+func writeEstimate(w io.Writer, steps []scanStep, scale scanScale, maxCalls int) int {
+	total := 0
+	fmt.Fprintf(w, "\nEstimated API calls for %d VPCs:\n", scale.VPCs)
+	for _, step := range steps {
+		calls := step.Calls(scale)
+		total += calls
+		fmt.Fprintf(w, "  %-22s %4d  (%s)\n", step.Name, calls, step.Formula)
+	}
+	fmt.Fprintf(w, "  %-22s %4d\n", "total", total)
+	if maxCalls > 0 && total > maxCalls {
+		fmt.Fprintf(w, "The estimate exceeds -max-api-calls %d; scanners after the cap is reached are skipped\n", maxCalls)
+	}
+	return total
+}
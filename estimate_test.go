@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/vpc"
+)
+
+// fakeResponses are the fixture results of the fake EC2 and STS endpoint, by action
+// Every other action answers with an empty result. The fixture has two VPCs, a route to an appliance
+// interface, an interface endpoint, VPC and peering attachments and a transit gateway route table, so
+// the per-item calls happen.
+var fakeResponses = map[string]string{
+	"AssumeRole": `<AssumeRoleResult><Credentials><AccessKeyId>ASIAFAKE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>` +
+		`<SessionToken>token</SessionToken><Expiration>%s</Expiration></Credentials></AssumeRoleResult>`,
+	"GetCallerIdentity": `<GetCallerIdentityResult><Account>123456789012</Account><Arn>arn:aws:sts::123456789012:assumed-role/docs/session</Arn>` +
+		`<UserId>AROAFAKE:session</UserId></GetCallerIdentityResult>`,
+	"DescribeVpcs": `<vpcSet>` +
+		`<item><vpcId>vpc-1</vpcId><ownerId>123456789012</ownerId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state><dhcpOptionsId>dopt-1</dhcpOptionsId></item>` +
+		`<item><vpcId>vpc-2</vpcId><ownerId>123456789012</ownerId><cidrBlock>10.1.0.0/16</cidrBlock><state>available</state><dhcpOptionsId>dopt-1</dhcpOptionsId></item>` +
+		`</vpcSet>`,
+	"DescribeRouteTables": `<routeTableSet><item><routeTableId>rtb-1</routeTableId><vpcId>vpc-1</vpcId><ownerId>123456789012</ownerId><routeSet>` +
+		`<item><destinationCidrBlock>0.0.0.0/0</destinationCidrBlock><networkInterfaceId>eni-1</networkInterfaceId><state>active</state></item>` +
+		`</routeSet></item></routeTableSet>`,
+	"DescribeNetworkInterfaces": `<networkInterfaceSet><item><networkInterfaceId>eni-1</networkInterfaceId><vpcId>vpc-1</vpcId><subnetId>subnet-1</subnetId>` +
+		`<attachment><instanceId>i-1</instanceId></attachment></item></networkInterfaceSet>`,
+	"DescribeVpcEndpoints": `<vpcEndpointSet><item><vpcEndpointId>vpce-1</vpcEndpointId><vpcEndpointType>Interface</vpcEndpointType><vpcId>vpc-1</vpcId>` +
+		`<serviceName>com.amazonaws.eu-west-1.sqs</serviceName><networkInterfaceIdSet><item>eni-1</item></networkInterfaceIdSet></item></vpcEndpointSet>`,
+	"DescribeTransitGatewayAttachments": `<transitGatewayAttachments>` +
+		`<item><transitGatewayAttachmentId>tgw-attach-1</transitGatewayAttachmentId><transitGatewayId>tgw-1</transitGatewayId><resourceType>vpc</resourceType><resourceId>vpc-1</resourceId><state>available</state></item>` +
+		`<item><transitGatewayAttachmentId>tgw-attach-2</transitGatewayAttachmentId><transitGatewayId>tgw-1</transitGatewayId><resourceType>peering</resourceType><resourceId>tgw-2</resourceId><state>available</state></item>` +
+		`</transitGatewayAttachments>`,
+	"DescribeTransitGatewayRouteTables": `<transitGatewayRouteTables><item><transitGatewayRouteTableId>tgw-rtb-1</transitGatewayRouteTableId>` +
+		`<transitGatewayId>tgw-1</transitGatewayId><state>available</state></item></transitGatewayRouteTables>`,
+}
+
+// newFakeAWS starts an EC2 and STS query API endpoint answering from fakeResponses
+// Returns: The server and the number of requests it received per action
+func newFakeAWS(t *testing.T) (*httptest.Server, map[string]int) {
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		action := r.Form.Get("Action")
+		requests[action]++
+
+		namespace := "http://ec2.amazonaws.com/doc/2016-11-15/"
+		if action == "AssumeRole" || action == "GetCallerIdentity" {
+			namespace = "https://sts.amazonaws.com/doc/2011-06-15/"
+		}
+		body := fakeResponses[action]
+		if action == "AssumeRole" {
+			body = fmt.Sprintf(body, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%sResponse xmlns="%s">%s<requestId>req-1</requestId></%sResponse>`, action, namespace, body, action)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// TestEstimateMatchesCountedCalls runs the EC2 and STS scanners of the estimate against a fake endpoint
+// Each step's formula must give the calls the budget counted for it; formulas starting with "up to" are
+// upper bounds. The selection enables every optional scanner served by EC2, so new steps need a runner here.
+func TestEstimateMatchesCountedCalls(t *testing.T) {
+	server, requests := newFakeAWS(t)
+	ctx := context.Background()
+
+	cfg := aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	}
+	budget := awsconfig.NewCallBudget(0)
+	cfg.APIOptions = append(cfg.APIOptions, budget.AddMiddleware)
+	cfg = awsconfig.AssumeRoleSession(cfg, "arn:aws:iam::123456789012:role/docs", "", "")
+	scanner := vpc.NewScanner(cfg)
+
+	var vpcs []vpc.VPCInfo
+	var routeTables []vpc.RouteTableInfo
+	var endpoints []vpc.VpcEndpointInfo
+	ignore := func(_ interface{}, err error) error { return err }
+	runners := map[string]func() error{
+		"assume_role": func() error { return ignore(cfg.Credentials.Retrieve(ctx)) },
+		"account_id":  func() error { return ignore(awsconfig.AccountID(ctx, cfg)) },
+		"vpcs": func() (err error) {
+			vpcs, err = scanner.GetVPCs(ctx)
+			return err
+		},
+		"vpc_dhcp_options": func() error { return ignore(scanner.GetDhcpOptions(ctx)) },
+		"effective_dns":    func() error { return scanner.AddEffectiveDNS(ctx, vpcs) },
+		"subnets":          func() error { return ignore(scanner.GetSubnets(ctx)) },
+		"route_tables": func() (err error) {
+			routeTables, err = scanner.GetRouteTables(ctx)
+			return err
+		},
+		"security_groups":    func() error { return ignore(scanner.GetSecurityGroups(ctx)) },
+		"internet_gateways":  func() error { return ignore(scanner.GetInternetGateways(ctx)) },
+		"nat_gateways":       func() error { return ignore(scanner.GetNatGateways(ctx)) },
+		"route_appliances":   func() error { return ignore(scanner.GetRouteAppliances(ctx, routeTables)) },
+		"transit_gateways":   func() error { return ignore(scanner.GetTransitGateways(ctx)) },
+		"tgw_attachments":    func() error { return ignore(scanner.GetTransitGatewayAttachments(ctx)) },
+		"vpc_peering":        func() error { return ignore(scanner.GetVpcPeeringConnections(ctx)) },
+		"network_acls":       func() error { return ignore(scanner.GetNetworkACLs(ctx)) },
+		"carrier_gateways":   func() error { return ignore(scanner.GetCarrierGateways(ctx)) },
+		"vpn_gateways":       func() error { return ignore(scanner.GetVpnGateways(ctx)) },
+		"customer_gateways":  func() error { return ignore(scanner.GetCustomerGateways(ctx)) },
+		"vpn_connections":    func() error { return ignore(scanner.GetVpnConnections(ctx)) },
+		"dhcp_options":       func() error { return ignore(scanner.GetDhcpOptions(ctx)) },
+		"network_interfaces": func() error { return ignore(scanner.GetNetworkInterfaces(ctx)) },
+		"instances":          func() error { return ignore(scanner.GetInstances(ctx)) },
+		"flow_logs":          func() error { return ignore(scanner.GetFlowLogs(ctx)) },
+		"elastic_ips":        func() error { return ignore(scanner.GetElasticIPs(ctx)) },
+		"tgw_route_tables":   func() error { return ignore(scanner.GetTransitGatewayRouteTables(ctx)) },
+		"vpc_endpoints": func() (err error) {
+			endpoints, err = scanner.GetVpcEndpoints(ctx)
+			return err
+		},
+		"endpoint_interfaces": func() error { return ignore(scanner.GetEndpointInterfaces(ctx, endpoints)) },
+		"interface_groups":    func() error { return ignore(scanner.GetInterfaceGroups(ctx)) },
+		"endpoint_services": func() error {
+			return ignore(scanner.GetVpcEndpointServices(ctx, []string{"com.amazonaws.eu-west-1.sqs"}))
+		},
+	}
+
+	steps := scanSteps(scanSelection{
+		AssumeRole:      true,
+		VPCDhcpOptions:  true,
+		EffectiveDNS:    true,
+		InspectionPaths: true,
+		Endpoints:       true,
+		EndpointAZs:     true,
+		SGUsage:         true,
+		ThirdParty:      true,
+	})
+	scale := scanScale{VPCs: 2}
+	estimated, counted := 0, int64(0)
+	for _, step := range steps {
+		run, ok := runners[step.Name]
+		if !ok {
+			t.Errorf("step %s has no runner in this test", step.Name)
+			continue
+		}
+		before := budget.Calls()
+		if err := run(); err != nil {
+			t.Fatalf("step %s: %v", step.Name, err)
+		}
+		calls := budget.Calls() - before
+		want := step.Calls(scale)
+		estimated += want
+		counted += calls
+		switch {
+		case strings.HasPrefix(step.Formula, "up to ") && calls > int64(want):
+			t.Errorf("step %s made %d calls, more than the %d of %q", step.Name, calls, want, step.Formula)
+		case !strings.HasPrefix(step.Formula, "up to ") && calls != int64(want):
+			t.Errorf("step %s made %d calls, estimated %d (%s)", step.Name, calls, want, step.Formula)
+		}
+	}
+
+	if counted > int64(estimated) {
+		t.Errorf("the scan made %d calls, more than the estimate of %d", counted, estimated)
+	}
+	total := 0
+	for _, n := range requests {
+		total += n
+	}
+	if int64(total) != counted {
+		t.Errorf("the endpoint received %d requests, the budget counted %d: %v", total, counted, requests)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/forecast"
+	"aws-documentor/modules/output"
+)
+
+// runForecast implements "aws-documentor forecast -dir DIR [flags]"
+// It fits a usage trend to every subnet and VPC across the JSON reports in a directory and reports
+// the ones projected to run out of IPv4 addresses within the horizon. Needs no AWS access.
+// args: Command-line arguments after the subcommand
+func runForecast(args []string) {
+	flags := flag.NewFlagSet("forecast", flag.ExitOnError)
+	dir := flags.String("dir", "", "Directory of JSON reports from earlier scans (searched recursively)")
+	horizonFlag := flags.String("horizon", "180d", "How far ahead exhaustion is reported as a finding (180d, 26w or a duration such as 720h)")
+	outputJSON := flags.Bool("json", false, "Print the forecast as JSON instead of tables")
+	sparklines := flags.String("sparklines", "", "Write an SVG sparkline of the usage history and projection of every flagged subnet to this directory")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	horizon, err := config.ParseWindow(*horizonFlag)
+	problems.Check("-horizon", err)
+	if *dir == "" {
+		problems.Addf("-dir", 0, "missing, for example: forecast -dir snapshots/")
+	} else if info, err := os.Stat(*dir); err != nil || !info.IsDir() {
+		problems.Addf("-dir", 0, "%s is not a directory", *dir)
+	}
+	if *sparklines != "" {
+		problems.Check("-sparklines", config.CheckOutputDir(*sparklines))
+	}
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	snapshots := loadSnapshotDir(*dir)
+	if len(snapshots) == 0 {
+		log.Fatalf("No scan reports with a scan time in %s", *dir)
+	}
+	report, err := forecast.Build(snapshots, horizon)
+	if err != nil {
+		log.Fatalf("Failed to build forecast: %v", err)
+	}
+
+	if *sparklines != "" {
+		if err := writeSparklines(*sparklines, report); err != nil {
+			log.Fatalf("Failed to write sparklines: %v", err)
+		}
+	}
+	if *outputJSON {
+		reportJSON, _ := output.MarshalIndent(report, output.FieldStyleSnake)
+		fmt.Printf("%s\n", reportJSON)
+		return
+	}
+	writeForecast(report)
+}
+
+// loadSnapshotDir reads every JSON report in a directory tree
+// Files that are not scan reports, such as scan manifests, and reports without a scan time are
+// skipped with a warning, so an archive can hold other files.
+// dir: Directory of the archive
+// Returns: The reports with a scan time
+func loadSnapshotDir(dir string) []*diff.Snapshot {
+	var snapshots []*diff.Snapshot
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return nil
+		}
+		snapshot, err := diff.LoadSnapshot(path)
+		switch {
+		case err != nil:
+			log.Printf("Warning: skipping %v", err)
+		case snapshot.ScannedAt == "":
+			log.Printf("Warning: skipping %s: it has no scan time", path)
+		default:
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	return snapshots
+}
+
+// writeForecast prints the forecast as tables: subnets, VPCs, then the findings
+func writeForecast(report *forecast.Report) {
+	fmt.Printf("Forecast from %d snapshots (%s to %s), horizon %d days\n\n", report.Snapshots, report.From, report.Until, report.Horizon)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBNET\tVPC\tCIDR\tUSED\tFREE\tPER DAY\tTREND\tEXHAUSTS\tCONFIDENCE\tPOINTS\tSTD DEV\tWORST CASE")
+	for _, subnet := range report.Subnets {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%d\t%s\t%s\n", subnet.SubnetID, subnet.VpcID, subnet.CidrBlock,
+			subnet.Used, subnet.Capacity, subnet.Free, trendColumns(subnet.Trend), worstCaseColumn(subnet.WorstCase))
+	}
+	tw.Flush()
+
+	fmt.Println()
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VPC\tSUBNETS\tUSED\tFREE\tPER DAY\tTREND\tEXHAUSTS\tCONFIDENCE\tPOINTS\tSTD DEV")
+	for _, v := range report.VPCs {
+		fmt.Fprintf(tw, "%s\t%d\t%d/%d\t%d\t%s\n", v.VpcID, v.Subnets, v.Used, v.Capacity, v.Free, trendColumns(v.Trend))
+	}
+	tw.Flush()
+
+	fmt.Println()
+	if len(report.Findings) == 0 {
+		fmt.Printf("No subnet or VPC is projected to run out of addresses within %d days\n", report.Horizon)
+		return
+	}
+	fmt.Println("Capacity findings:")
+	for _, finding := range report.Findings {
+		label := finding.Severity
+		if finding.WorstCase {
+			label += ", worst case"
+		}
+		fmt.Printf("  [%s] %s\n", label, finding.Reason)
+	}
+}
+
+// trendColumns formats the trend columns of a forecast table
+func trendColumns(trend forecast.Trend) string {
+	exhausts := "-"
+	if trend.ExhaustsAt != "" {
+		exhausts = fmt.Sprintf("%s (%dd)", trend.ExhaustsAt, *trend.DaysLeft)
+	}
+	if trend.Direction == forecast.DirectionInsufficient {
+		return fmt.Sprintf("-\t%s\t%s\t%s\t%d\t-", trend.Direction, exhausts, trend.Confidence, trend.Points)
+	}
+	points := fmt.Sprintf("%d", trend.Points)
+	if trend.Outliers > 0 {
+		points += fmt.Sprintf(" (+%d outliers)", trend.Outliers)
+	}
+	return fmt.Sprintf("%+.2f\t%s\t%s\t%s\t%s\t%.1f", trend.PerDay, trend.Direction, exhausts, trend.Confidence, points, trend.StdDev)
+}
+
+// worstCaseColumn formats the worst case of a subnet: the additional demand and when it exhausts
+func worstCaseColumn(worst *forecast.WorstCase) string {
+	switch {
+	case worst == nil:
+		return "-"
+	case worst.ExhaustsAt == "":
+		return fmt.Sprintf("+%d", worst.AdditionalDemand)
+	}
+	return fmt.Sprintf("+%d, %s (%dd)", worst.AdditionalDemand, worst.ExhaustsAt, *worst.DaysLeft)
+}
+
+// writeSparklines writes the sparkline of every subnet with a finding to <subnet ID>.svg
+func writeSparklines(dir string, report *forecast.Report) error {
+	flagged := make(map[string]bool)
+	for _, finding := range report.Findings {
+		if finding.ResourceType == "subnet" {
+			flagged[finding.ResourceID] = true
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, subnet := range report.Subnets {
+		svg := forecast.Sparkline(subnet, report)
+		if !flagged[subnet.SubnetID] || svg == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, subnet.SubnetID+".svg"), []byte(svg), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
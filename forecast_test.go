@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"aws-documentor/modules/forecast"
+)
+
+// TestLoadSnapshotDir checks that reports are read from the whole tree and manifests, reports without a scan
+// time and other files are skipped
+func TestLoadSnapshotDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"2026-01/report.json":   `{"scanned_at": "2026-01-01T00:00:00Z", "vpcs": []}`,
+		"2026-02/report.JSON":   `{"scannedAt": "2026-02-01T00:00:00Z", "vpcs": [], "subnets": []}`,
+		"2026-02/manifest.json": `{"profiles": []}`,
+		"untimed.json":          `{"vpcs": []}`,
+		"broken.json":           `{"vpcs": [`,
+		"notes.txt":             `{"scanned_at": "2026-03-01T00:00:00Z", "vpcs": []}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var scannedAt []string
+	for _, snapshot := range loadSnapshotDir(dir) {
+		scannedAt = append(scannedAt, snapshot.ScannedAt)
+	}
+	sort.Strings(scannedAt)
+	if want := []string{"2026-01-01T00:00:00Z", "2026-02-01T00:00:00Z"}; !reflect.DeepEqual(scannedAt, want) {
+		t.Errorf("loaded snapshots = %v, want %v", scannedAt, want)
+	}
+}
+
+// TestWriteSparklines checks that only subnets with a finding and a history get a chart
+func TestWriteSparklines(t *testing.T) {
+	history := []forecast.Sample{{ScannedAt: "2026-01-01T00:00:00Z", Used: 200}}
+	report := &forecast.Report{From: "2026-01-01T00:00:00Z", Until: "2026-01-01T00:00:00Z", Horizon: 180,
+		Subnets: []forecast.SubnetForecast{
+			{SubnetID: "subnet-0full", CidrBlock: "10.0.1.0/24", Capacity: 251, History: history},
+			{SubnetID: "subnet-0new", CidrBlock: "10.0.2.0/24", Capacity: 251, History: []forecast.Sample{}},
+			{SubnetID: "subnet-0fine", CidrBlock: "10.0.3.0/24", Capacity: 251, History: history},
+		},
+		VPCs: []forecast.VPCForecast{{VpcID: "vpc-0a1"}},
+		Findings: []forecast.Finding{
+			{ResourceType: "subnet", ResourceID: "subnet-0full"},
+			{ResourceType: "subnet", ResourceID: "subnet-0new"},
+			{ResourceType: "vpc", ResourceID: "vpc-0a1"},
+		},
+	}
+	dir := filepath.Join(t.TempDir(), "sparklines")
+	if err := writeSparklines(dir, report); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"subnet-0full.svg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sparklines = %v, want %v", names, want)
+	}
+}
+
+// TestWorstCaseColumn checks the worst-case column of the forecast table
+func TestWorstCaseColumn(t *testing.T) {
+	days := 6
+	tests := []struct {
+		name  string
+		worst *forecast.WorstCase
+		want  string
+	}{
+		{name: "no instance data", want: "-"},
+		{name: "not exhausting", worst: &forecast.WorstCase{AdditionalDemand: 50, Used: 150}, want: "+50"},
+		{name: "exhausting", worst: &forecast.WorstCase{AdditionalDemand: 55, Used: 195, ExhaustsAt: "2026-01-11", DaysLeft: &days}, want: "+55, 2026-01-11 (6d)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := worstCaseColumn(tt.worst); got != tt.want {
+				t.Errorf("worstCaseColumn() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
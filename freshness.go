@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/report"
+)
+
+// freshnessFlags are -max-age and -allow-stale of the commands that can read a saved scan instead of scanning
+type freshnessFlags struct {
+	source     string         // Flag naming the saved scan, -load or -from-file
+	maxAge     *time.Duration // -max-age
+	allowStale *bool          // -allow-stale
+}
+
+// registerFreshnessFlags registers -max-age and -allow-stale
+// flags: Flag set of the command
+// source: Flag naming the saved scan, -load or -from-file
+// Returns: The flag values, set once the flag set is parsed
+func registerFreshnessFlags(flags *flag.FlagSet, source string) *freshnessFlags {
+	return &freshnessFlags{
+		source:     source,
+		maxAge:     flags.Duration("max-age", 0, "Refuse a "+source+" scan older than this, such as 24h, so automation does not keep working from a snapshot whose upstream scan broke (default: any age)"),
+		allowStale: flags.Bool("allow-stale", false, "Use a "+source+" scan older than -max-age anyway, with a warning"),
+	}
+}
+
+// validate checks the flags
+// problems: Validator the problems are added to
+// loading: Whether a saved scan is read
+func (f *freshnessFlags) validate(problems *config.Validator, loading bool) {
+	if *f.maxAge < 0 {
+		problems.Addf("-max-age", 0, "must be positive")
+	}
+	if *f.maxAge != 0 && !loading {
+		problems.Addf("-max-age", 0, "only applies to a %s scan", f.source)
+	}
+	if *f.allowStale && *f.maxAge == 0 {
+		problems.Addf("-allow-stale", 0, "needs -max-age")
+	}
+}
+
+// check checks the age of the saved scan against -max-age
+// scannedAt: When the saved scan started (zero if the report does not say)
+// now: Time of the check
+// warnf: Logs the warning about a stale scan -allow-stale accepts
+// Returns: Whether the scan is stale, and an error if it is and -allow-stale is not set
+func (f *freshnessFlags) check(scannedAt, now time.Time, warnf func(format string, args ...interface{})) (bool, error) {
+	err := report.CheckAge(scannedAt, *f.maxAge, now)
+	var stale *report.StaleError
+	if !errors.As(err, &stale) {
+		return false, err
+	}
+	if !*f.allowStale {
+		return true, fmt.Errorf("%s %w; scan again, or set -allow-stale to use it anyway", f.source, err)
+	}
+	warnf("%s is STALE: %v; using it because of -allow-stale", f.source, err)
+	return true, nil
+}
+
+// checkSubcommand checks the age of the -from-file report of a subcommand, exiting if it is too old
+// scannedAt: scanned_at of the report
+func (f *freshnessFlags) checkSubcommand(scannedAt string) {
+	if _, err := f.check(report.ParseScanTime(scannedAt), time.Now(), func(format string, args ...interface{}) {
+		log.Printf("Warning: "+format, args...)
+	}); err != nil {
+		log.Fatalf("Refusing the report: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/anonymize"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/query"
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/vpc"
+)
+
+// testFreshness returns freshness flags parsed from a command line
+func testFreshness(t *testing.T, source string, args ...string) *freshnessFlags {
+	t.Helper()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	freshness := registerFreshnessFlags(flags, source)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return freshness
+}
+
+// TestFreshnessCheck covers fresh, stale and allowed stale scans, and scans without a scan time
+func TestFreshnessCheck(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		args      []string
+		scannedAt time.Time
+		wantStale bool
+		wantErr   bool
+		wantWarn  bool
+	}{
+		{name: "no -max-age", scannedAt: now.AddDate(-1, 0, 0)},
+		{name: "fresh", args: []string{"-max-age", "24h"}, scannedAt: now.Add(-23 * time.Hour)},
+		{name: "exactly -max-age old", args: []string{"-max-age", "24h"}, scannedAt: now.Add(-24 * time.Hour)},
+		{name: "stale", args: []string{"-max-age", "24h"}, scannedAt: now.Add(-25 * time.Hour), wantStale: true, wantErr: true},
+		{name: "stale allowed", args: []string{"-max-age", "24h", "-allow-stale"}, scannedAt: now.Add(-25 * time.Hour), wantStale: true, wantWarn: true},
+		{name: "no scan time", args: []string{"-max-age", "24h"}, wantStale: true, wantErr: true},
+		{name: "no scan time allowed", args: []string{"-max-age", "24h", "-allow-stale"}, wantStale: true, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freshness := testFreshness(t, "-from-file", tt.args...)
+			warnings := 0
+			stale, err := freshness.check(tt.scannedAt, now, func(string, ...interface{}) { warnings++ })
+			if stale != tt.wantStale || (err != nil) != tt.wantErr || (warnings > 0) != tt.wantWarn {
+				t.Fatalf("check() = %v, %v with %d warnings; want stale %v, error %v, warning %v", stale, err, warnings, tt.wantStale, tt.wantErr, tt.wantWarn)
+			}
+			var staleErr *report.StaleError
+			if tt.wantErr && !errors.As(err, &staleErr) {
+				t.Errorf("error %v is not a *report.StaleError", err)
+			}
+		})
+	}
+}
+
+// TestFreshnessLoad checks that a stale -load scan stops the run, and that -allow-stale
+// counts the warning and marks the run result stale
+func TestFreshnessLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.json")
+	saved := &report.ScanResult{ScanTime: time.Now().Add(-48 * time.Hour).UTC(), Region: "eu-west-1", VPCs: []vpc.VPCInfo{{VpcID: "vpc-0a1"}}}
+	if err := report.Save(path, saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := report.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantErr   bool
+		wantStale bool
+	}{
+		{name: "fresh", args: []string{"-max-age", "72h"}},
+		{name: "stale", args: []string{"-max-age", "24h"}, wantErr: true},
+		{name: "stale allowed", args: []string{"-max-age", "24h", "-allow-stale"}, wantStale: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newRunResult()
+			stale, err := testFreshness(t, "-load", tt.args...).check(loaded.ScanTime, time.Now(), result.warnf)
+			result.Stale = stale
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if code := result.finish(err); code != exitFailed {
+					t.Errorf("finish() = %d, want %d", code, exitFailed)
+				}
+				return
+			}
+			if result.Stale != tt.wantStale || (result.Warnings == 1) != tt.wantStale {
+				t.Errorf("run result stale %v with %d warnings, want stale %v", result.Stale, result.Warnings, tt.wantStale)
+			}
+		})
+	}
+}
+
+// TestFreshnessFromFile checks that the reports of every -from-file subcommand carry the scan time the check needs
+func TestFreshnessFromFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, scannedAt time.Time) string {
+		path := filepath.Join(dir, name)
+		data := `{"region": "eu-west-1", "scanned_at": "` + scannedAt.UTC().Format(time.RFC3339) + `", "vpcs": [{"vpc_id": "vpc-0a1"}]}`
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	fresh := write("fresh.json", time.Now().Add(-time.Hour))
+	stale := write("stale.json", time.Now().Add(-48*time.Hour))
+
+	// The loaders of browse and whatsnew, query and diagram-check, and repro-bundle
+	loaders := map[string]func(path string) (string, error){
+		"snapshot": func(path string) (string, error) {
+			snapshot, err := diff.LoadSnapshot(path)
+			if err != nil {
+				return "", err
+			}
+			return snapshot.ScannedAt, nil
+		},
+		"query": func(path string) (string, error) {
+			report, err := query.LoadReport(path)
+			if err != nil {
+				return "", err
+			}
+			return report.ScannedAt, nil
+		},
+		"anonymize": func(path string) (string, error) {
+			report, err := anonymize.LoadReport(path)
+			if err != nil {
+				return "", err
+			}
+			return report.ScannedAt, nil
+		},
+	}
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range []struct {
+				path    string
+				args    []string
+				wantErr bool
+			}{
+				{path: fresh, args: []string{"-max-age", "24h"}},
+				{path: stale, args: []string{"-max-age", "24h"}, wantErr: true},
+				{path: stale, args: []string{"-max-age", "24h", "-allow-stale"}},
+			} {
+				scannedAt, err := load(tt.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = testFreshness(t, "-from-file", tt.args...).check(report.ParseScanTime(scannedAt), time.Now(), func(string, ...interface{}) {})
+				if (err != nil) != tt.wantErr {
+					t.Errorf("%s %v: check() error = %v, want error %v", filepath.Base(tt.path), tt.args, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+// TestFreshnessValidate checks the flag combinations -max-age and -allow-stale reject
+func TestFreshnessValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		loading bool
+		want    int // Problems reported
+	}{
+		{name: "no flags", want: 0},
+		{name: "with a saved scan", args: []string{"-max-age", "24h", "-allow-stale"}, loading: true, want: 0},
+		{name: "negative", args: []string{"-max-age", "-1h"}, loading: true, want: 1},
+		{name: "without a saved scan", args: []string{"-max-age", "24h"}, want: 1},
+		{name: "-allow-stale alone", args: []string{"-allow-stale"}, loading: true, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := &config.Validator{}
+			testFreshness(t, "-from-file", tt.args...).validate(problems, tt.loading)
+			if got := len(problems.Problems()); got != tt.want {
+				t.Errorf("validate() reported %d problems, want %d: %v", got, tt.want, problems.Err())
+			}
+		})
+	}
+}
@@ -2,209 +2,625 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/checkpoint"
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/containers"
 	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/graph"
+	"aws-documentor/modules/naming"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/pdf"
+	"aws-documentor/modules/privateapi"
+	"aws-documentor/modules/query"
+	"aws-documentor/modules/replication"
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/templates"
+	"aws-documentor/modules/terraform"
 	"aws-documentor/modules/vpc"
 )
 
 func main() {
-	// Parse command-line flags
-	region := flag.String("region", "", "AWS region to scan (optional, uses default config if not specified)")
-	generateDiagram := flag.Bool("diagram", false, "Generate draw.io diagram file (saves to vpc-diagram.drawio)")
-	outputJSON := flag.Bool("json", true, "Output JSON data to stdout (default: true)")
-	flag.Parse()
+	// "aws-documentor coverage [flags]" compares this tool's scanners with the account instead of scanning
+	if len(os.Args) > 1 && os.Args[1] == "coverage" {
+		runCoverage(os.Args[2:])
+		return
+	}
+	// "aws-documentor browse [flags]" opens the terminal browser instead of printing the scan
+	if len(os.Args) > 1 && os.Args[1] == "browse" {
+		runBrowse(os.Args[2:])
+		return
+	}
+	// "aws-documentor repro-bundle [flags]" writes an anonymized report and diagram for bug reports
+	if len(os.Args) > 1 && os.Args[1] == "repro-bundle" {
+		runReproBundle(os.Args[2:])
+		return
+	}
+	// "aws-documentor whatsnew [flags]" reports the changes within a time window instead of the scan
+	if len(os.Args) > 1 && os.Args[1] == "whatsnew" {
+		runWhatsNew(os.Args[2:])
+		return
+	}
+	// "aws-documentor query [flags] EXPRESSION" prints what an expression selects from the report
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		runQuery(os.Args[2:])
+		return
+	}
+	// "aws-documentor bench [flags]" times the post-processing on synthetic environments instead of scanning
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
+	// "aws-documentor diagram-check -file FILE [flags]" compares a draw.io diagram with AWS instead of scanning
+	if len(os.Args) > 1 && os.Args[1] == "diagram-check" {
+		runDiagramCheck(os.Args[2:])
+		return
+	}
+	// "aws-documentor forecast -dir DIR [flags]" projects subnet address exhaustion from earlier reports instead of scanning
+	if len(os.Args) > 1 && os.Args[1] == "forecast" {
+		runForecast(os.Args[2:])
+		return
+	}
+	// "aws-documentor diff [flags] BEFORE AFTER" lists the changes between two scans saved with -save
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+	// "aws-documentor run -target NAME | -all [flags]" scans the targets of a project file instead of one account
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		runRun(os.Args[2:])
+		return
+	}
 
-	ctx := context.Background()
+	// "aws-documentor doctor [flags]" diagnoses the setup instead of scanning
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		runDoctor(os.Args[2:])
+		return
+	}
+	// "aws-documentor schema fields|diff|check|sample" describes the versions of the report document
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		runSchema(os.Args[2:])
+		return
+	}
 
-	// Load AWS config with optional region override
-	var cfg aws.Config
-	var err error
-	if *region != "" {
-		cfg, err = config.LoadDefaultConfig(ctx, config.WithRegion(*region))
-		fmt.Printf("Scanning AWS region: %s\n\n", *region)
+	result := newRunResult()
+	err := runScan(result)
+	var logged *runError
+	if err != nil && !(errors.As(err, &logged) && logged.Logged) {
+		log.Print(err)
+	}
+	os.Exit(result.finish(err))
+}
+
+// runScan parses the flags of a scan, scans and writes the outputs
+// Nothing in the scan exits the process: errors are returned so main can write the -result-file.
+// result: Summary of the run, filled in as the scan goes
+// Returns: Error that stopped the run, with the category that decides the exit code
+func runScan(result *RunResult) error {
+	run := &scanRun{scanFlags: registerScanFlags(), result: result}
+
+	// "aws-documentor validate [flags] [policy files]" checks everything without scanning
+	run.validateCommand = len(os.Args) > 1 && os.Args[1] == "validate"
+	if run.validateCommand {
+		flag.CommandLine.Parse(os.Args[2:])
 	} else {
-		cfg, err = config.LoadDefaultConfig(ctx)
-		fmt.Printf("Scanning AWS region: %s (from default config)\n\n", cfg.Region)
+		flag.Parse()
 	}
-	if err != nil {
-		log.Fatalf("Failed to load AWS config: %v", err)
+	run.result.file = *run.resultFile
+	if *run.templateSchema {
+		if err := writeTemplateSchema(os.Stdout); err != nil {
+			return fmt.Errorf("Failed to write template schema: %w", err)
+		}
+		return nil
 	}
 
-	scanner := vpc.NewScanner(cfg)
+	problems := run.checkFlags()
+	if run.validateCommand || *run.validateOnly {
+		if err := problems.Err(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems.Problems()))
+			return &runError{Category: errInvalidConfig, Err: err, Logged: true}
+		}
+		fmt.Println("Configuration is valid")
+		return nil
+	}
+	if err := problems.Err(); err != nil {
+		return configError(fmt.Errorf("Invalid flags:\n%w", err))
+	}
 
-	fmt.Println("Scanning VPCs...")
-	vpcs, err := scanner.GetVPCs(ctx)
-	if err != nil {
-		log.Fatalf("Failed to get VPCs: %v", err)
+	// With -profiles, this run only resolves the profiles and runs one scan per profile with the other flags
+	if *run.profiles != "" {
+		return run.scanProfiles()
+	}
+
+	if err := run.loadSettings(); err != nil {
+		return err
+	}
+	// With -load, the outputs are generated from a saved scan instead, without AWS credentials
+	if run.loaded != nil {
+		return run.generateFromLoad()
 	}
 
-	if *outputJSON {
-		fmt.Printf("Found %d VPCs:\n", len(vpcs))
-		for _, v := range vpcs {
-			vpcJSON, _ := json.MarshalIndent(v, "", "  ")
-			fmt.Printf("%s\n", vpcJSON)
-			fmt.Println("---")
+	ctx := context.Background()
+	// The calls are recorded however the scan ends, from the moment the budget exists
+	defer func() {
+		if run.budget != nil {
+			result.APICalls = run.budget.Calls()
+			result.Connections = run.dials.Dials()
 		}
-	} else {
-		fmt.Printf("Found %d VPCs\n", len(vpcs))
+	}()
+	if err := run.connect(ctx); err != nil {
+		return err
+	}
+	// With -all-regions, the core resources of every enabled region are scanned instead, each with its own scanner
+	if *run.allRegions {
+		return run.scanAllRegions(ctx)
 	}
 
-	fmt.Println("\nScanning Subnets...")
-	subnets, err := scanner.GetSubnets(ctx)
-	if err != nil {
-		log.Fatalf("Failed to get subnets: %v", err)
+	if err := run.scanCore(ctx); err != nil {
+		return err
+	}
+	if err := run.scanNetworkResources(ctx); err != nil {
+		return err
+	}
+	if err := run.scanServices(ctx); err != nil {
+		return err
+	}
+	if err := run.summarizeScan(); err != nil {
+		return err
 	}
+	if err := run.analyze(ctx); err != nil {
+		return err
+	}
+	if err := run.writeDiagrams(); err != nil {
+		return err
+	}
+	if err := run.buildFindings(); err != nil {
+		return err
+	}
+	if err := run.writeReports(); err != nil {
+		return err
+	}
+	return run.writeSummaries()
+}
+
+// scanRun holds the state a scan builds up as its steps run
+// The flags come first, then the scanned resources, then the analyses and outputs built from them.
+type scanRun struct {
+	*scanFlags
+	result                  *RunResult                         // Summary of the run, recorded in the -result-file
+	validateCommand         bool                               // Whether the run is the validate subcommand
+	fieldStyle              output.FieldStyle                  // Field name style of the structured output
+	format                  output.Format                      // Format of the console output
+	graphFormat             graph.Format                       // Format of the -graph-out export
+	opts                    outputOptions                      // Output options from the flags
+	sectionLimits           output.SectionLimits               // Per-section limits of the console output
+	egressOptions           analysis.EgressOptions             // Options of the egress analysis
+	tagFilters              map[string]string                  // Tag filters of -tag
+	scanOptions             vpc.ScanOptions                    // Options passed to the VPC scanner
+	userTemplate            *templates.Template                // Template of -template, nil without one
+	loaded                  *report.ScanResult                 // Scan loaded with -load, nil for a live scan
+	earlierReport           *diff.Snapshot                     // Earlier report of -compare-with, nil without one
+	stabilityOptions        analysis.StabilityOptions          // Options of the stability analysis
+	httpOptions             awsconfig.HTTPOptions              // HTTP client options of the AWS config
+	catalog                 *analysis.SaaSCatalog              // SaaS endpoint catalog
+	rangeLabeler            *analysis.RangeLabeler             // Labels for known address ranges
+	managers                *analysis.ManagerClassifier        // Classifier of the resources managed by other tools
+	pathProperties          *analysis.PathProperties           // Properties of the traffic paths to check
+	stdout                  io.Writer                          // Writer of the console output
+	cfg                     aws.Config                         // AWS config of the scanned account and region
+	dials                   *awsconfig.DialCounter             // Counter of the connections made
+	budget                  *awsconfig.CallBudget              // Budget of the API calls
+	scanner                 *vpc.Scanner                       // Scanner of the VPC resources
+	prepareTags             func(resources interface{})        // Applies the tag filters to a scanned resource slice
+	scannedAt               time.Time                          // Time the scan started
+	accountID               string                             // ID of the scanned account
+	checkpoints             *checkpoint.Store                  // Checkpoint store of -checkpoint-dir, nil without one
+	vpcs                    []vpc.VPCInfo                      // Scanned VPCs
+	estimate                int                                // Estimated number of API calls
+	subnets                 []vpc.SubnetInfo                   // Scanned subnets
+	routeTables             []vpc.RouteTableInfo               // Scanned route tables
+	localRouteFindings      []analysis.LocalRouteFinding       // Findings of the local route check
+	routeTargetFindings     []analysis.RouteTargetFinding      // Findings of the route target check
+	securityGroups          []vpc.SecurityGroupInfo            // Scanned security groups
+	internetGateways        []vpc.InternetGatewayInfo          // Scanned internet gateways
+	natGateways             []vpc.NatGatewayInfo               // Scanned NAT gateways
+	routeAppliances         []vpc.RouteApplianceInfo           // Instances and interfaces that routes point to
+	legacyNATInstances      []vpc.RouteApplianceInfo           // Route appliances that act as NAT instances
+	transitGateways         []vpc.TransitGatewayInfo           // Scanned transit gateways
+	tgwAttachments          []vpc.TransitGatewayAttachmentInfo // Scanned transit gateway attachments
+	consistencyWarnings     []vpc.DataWarning                  // Warnings of the consistency checks
+	peerings                []vpc.VpcPeeringConnectionInfo     // Scanned VPC peering connections
+	networkACLs             []vpc.NetworkACLInfo               // Scanned network ACLs
+	carrierGateways         []vpc.CarrierGatewayInfo           // Scanned carrier gateways
+	vpnGateways             []vpc.VpnGatewayInfo               // Scanned virtual private gateways
+	customerGateways        []vpc.CustomerGatewayInfo          // Scanned customer gateways
+	vpnConnections          []vpc.VpnConnectionInfo            // Scanned VPN connections
+	dhcpOptions             []vpc.DhcpOptionsInfo              // Scanned DHCP option sets
+	networkInterfaces       []vpc.NetworkInterfaceInfo         // Scanned network interfaces
+	instances               []vpc.InstanceInfo                 // Scanned EC2 instances
+	flowLogs                []vpc.FlowLogInfo                  // Scanned flow logs
+	elasticIPs              []vpc.ElasticIPInfo                // Scanned Elastic IPs
+	tgwRouteTables          []vpc.TransitGatewayRouteTableInfo // Scanned transit gateway route tables
+	vpcEndpoints            []vpc.VpcEndpointInfo              // Scanned VPC endpoints
+	endpointConnections     []vpc.VpcEndpointConnectionInfo    // Endpoints connected to the account's endpoint services, for -save
+	endpointInterfaces      []vpc.EndpointInterfaceInfo        // Network interfaces of the interface endpoints
+	unavailableServices     []analysis.UnavailableService      // Services that could not be scanned
+	autoScalingGroups       []asg.AutoScalingGroupInfo         // Scanned Auto Scaling groups
+	ecsServices             []containers.ECSServiceInfo        // Scanned ECS services
+	eksClusters             []containers.EKSClusterInfo        // Scanned EKS clusters
+	directories             []directory.DirectoryInfo          // Scanned Directory Service directories
+	privateAPIs             []privateapi.PrivateAPIInfo        // Scanned private API Gateway APIs
+	dnsReport               *dns.Report                        // Route 53 scan, nil when not scanned
+	dnsFindings             []analysis.DNSFinding              // Findings of the DNS checks
+	relationships           []replication.Relationship         // Replication relationships between resources
+	coreNetworks            []cloudwan.CoreNetworkInfo         // Scanned Cloud WAN core networks
+	publicIPReport          *analysis.PublicIPReport           // Public IP inventory
+	subnetNetworkReport     *analysis.SubnetNetworkReport      // Network capacity of the subnets
+	subnetNetwork           []analysis.SubnetNetworkCapacity   // Per-subnet network capacity
+	scanNotes               []string                           // Notes about the scan shown in the reports
+	dataWarnings            []vpc.DataWarning                  // Warnings about the scanned data
+	untrustedSourceFindings []analysis.UntrustedSourceFinding  // Findings of the untrusted source check
+	skipManagers            []string                           // Managers of -skip-managed
+	managedBy               map[string]string                  // Manager of each managed resource by ID
+	sgUsageReport           *analysis.SGUsageReport            // Security group usage
+	effectiveSourcesReport  *analysis.EffectiveSourcesReport   // Effective sources of the security group rules
+	pathMTUFindings         []analysis.PathMTUFinding          // Findings of the path MTU check
+	collectFindings         bool                               // Whether an output needs the findings
+	isolationReport         *analysis.IsolationReport          // Isolation analysis
+	egressReport            *analysis.EgressReport             // Egress analysis
+	stabilityReport         *analysis.StabilityReport          // Stability analysis
+	endpointReport          *analysis.EndpointCoverageReport   // VPC endpoint coverage
+	endpointAZReport        *analysis.EndpointAZReport         // VPC endpoint AZ coverage
+	thirdPartyReport        *analysis.ThirdPartyReport         // Third-party connectivity
+	privateAPIReport        *analysis.PrivateAPIReport         // Private API reachability
+	truncations             output.Truncations                 // Sections truncated in the output
+	shownVPCs               []vpc.VPCInfo                      // VPCs shown after the section limits
+	shownSubnets            []vpc.SubnetInfo                   // Subnets shown after the section limits
+	shownRouteTables        []vpc.RouteTableInfo               // Route tables shown after the section limits
+	shownSecurityGroups     []vpc.SecurityGroupInfo            // Security groups shown after the section limits
+	shownNatGateways        []vpc.NatGatewayInfo               // NAT gateways shown after the section limits
+	shownTGWAttachments     []vpc.TransitGatewayAttachmentInfo // Transit gateway attachments shown after the section limits
+	shownDataWarnings       []vpc.DataWarning                  // Data warnings shown after the section limits
+	diagramNotes            []string                           // Notes shown on the diagrams
+	findings                []pdf.Finding                      // Findings of all analyses
+	privateZones            []dns.PrivateZoneInfo              // Private hosted zones
+	endpointAZs             []analysis.EndpointAZCoverage      // Per-endpoint AZ coverage
+	vendors                 []analysis.VendorConnectivity      // Connectivity of third-party vendors
+	pdfPublicIPs            []analysis.PublicIPEntry           // Public IPs shown in the PDF report
+	egressProfileList       []analysis.EgressProfile           // Egress profiles of the subnets
+}
 
-	if *outputJSON {
-		fmt.Printf("Found %d Subnets:\n", len(subnets))
-		for _, s := range subnets {
-			subnetJSON, _ := json.MarshalIndent(s, "", "  ")
-			fmt.Printf("%s\n", subnetJSON)
-			fmt.Println("---")
+// loadSettings loads the config files the flags name and picks where the scan output goes
+// The files were checked by checkFlags already, so an error here means they changed in between.
+// Returns: Error if a config file cannot be loaded
+func (run *scanRun) loadSettings() error {
+	var err error
+	run.catalog = analysis.DefaultSaaSCatalog()
+	if *run.saasCatalog != "" {
+		if run.catalog, err = config.LoadSaaSCatalog(*run.saasCatalog); err != nil {
+			return configError(fmt.Errorf("Invalid -saas-catalog:\n%w", err))
 		}
-	} else {
-		fmt.Printf("Found %d Subnets\n", len(subnets))
 	}
 
-	fmt.Println("\nScanning Route Tables...")
-	routeTables, err := scanner.GetRouteTables(ctx)
-	if err != nil {
-		log.Fatalf("Failed to get route tables: %v", err)
+	var namedRanges *analysis.NamedRanges
+	if *run.namedRangesFile != "" {
+		if namedRanges, err = config.LoadNamedRanges(*run.namedRangesFile); err != nil {
+			return configError(fmt.Errorf("Invalid -named-ranges:\n%w", err))
+		}
 	}
+	run.rangeLabeler = analysis.NewRangeLabeler(namedRanges)
 
-	if *outputJSON {
-		fmt.Printf("Found %d Route Tables:\n", len(routeTables))
-		for _, rt := range routeTables {
-			routeTableJSON, _ := json.MarshalIndent(rt, "", "  ")
-			fmt.Printf("%s\n", routeTableJSON)
-			fmt.Println("---")
+	var managerRules *analysis.ManagerRules
+	if *run.managedByRules != "" {
+		if managerRules, err = config.LoadManagerRules(*run.managedByRules); err != nil {
+			return configError(fmt.Errorf("Invalid -managed-by-rules:\n%w", err))
 		}
-	} else {
-		fmt.Printf("Found %d Route Tables\n", len(routeTables))
 	}
+	run.managers = analysis.NewManagerClassifier(managerRules)
 
-	fmt.Println("\nScanning Security Groups...")
-	securityGroups, err := scanner.GetSecurityGroups(ctx)
-	if err != nil {
-		log.Fatalf("Failed to get security groups: %v", err)
+	run.pathProperties = analysis.DefaultPathProperties()
+	if *run.pathPropertiesFile != "" {
+		if run.pathProperties, err = config.LoadPathProperties(*run.pathPropertiesFile); err != nil {
+			return configError(fmt.Errorf("Invalid -path-properties:\n%w", err))
+		}
+	}
+	if *run.mtuThreshold > 0 {
+		run.pathProperties.MTUThreshold = *run.mtuThreshold
 	}
 
-	if *outputJSON {
-		fmt.Printf("Found %d Security Groups:\n", len(securityGroups))
-		for _, sg := range securityGroups {
-			sgJSON, _ := json.MarshalIndent(sg, "", "  ")
-			fmt.Printf("%s\n", sgJSON)
-			fmt.Println("---")
-		}
-	} else {
-		fmt.Printf("Found %d Security Groups\n", len(securityGroups))
+	run.stdout = os.Stdout
+	if run.opts.Silent {
+		run.stdout = io.Discard
+	}
+	// The versioned report is the only thing on stdout, so it can be piped as is
+	if run.opts.Report {
+		run.stdout = os.Stderr
 	}
+	return nil
+}
 
-	fmt.Println("\nScanning Internet Gateways...")
-	internetGateways, err := scanner.GetInternetGateways(ctx)
+// connect loads the AWS config, sets up the call budget and the scanner and identifies the account
+// ctx: Context for the AWS calls
+// Returns: Error if there are no usable credentials or the -role-arn role cannot be assumed
+func (run *scanRun) connect(ctx context.Context) error {
+	var err error
+	// Load AWS config with optional region override; all service clients share one HTTP client
+	run.cfg, run.dials, err = awsconfig.Load(ctx, *run.region, run.httpOptions)
 	if err != nil {
-		log.Fatalf("Failed to get internet gateways: %v", err)
+		return &runError{Category: errNoCredentials, Err: fmt.Errorf("Failed to load AWS config: %w", err)}
 	}
-
-	if *outputJSON {
-		fmt.Printf("Found %d Internet Gateways:\n", len(internetGateways))
-		for _, igw := range internetGateways {
-			igwJSON, _ := json.MarshalIndent(igw, "", "  ")
-			fmt.Printf("%s\n", igwJSON)
-			fmt.Println("---")
+	run.result.Region = run.cfg.Region
+	// Every client created from cfg, including the DR region copies and the STS client assuming -role-arn,
+	// counts against the same budget
+	run.budget = awsconfig.NewCallBudget(*run.maxAPICalls)
+	run.cfg.APIOptions = append(run.cfg.APIOptions, run.budget.AddMiddleware)
+	run.result.exceeded = run.budget.Exceeded
+	// Every client works in the account of the role, so the role replaces the credentials of cfg itself;
+	// it is assumed here, as a denied role would otherwise only surface as a failed first scan
+	if *run.roleARN != "" {
+		run.cfg = awsconfig.AssumeRoleSession(run.cfg, *run.roleARN, awsconfig.DefaultRoleSessionName, *run.externalID)
+		if _, err := run.cfg.Credentials.Retrieve(ctx); err != nil {
+			return &runError{Category: errNoCredentials, Err: fmt.Errorf("Failed to assume -role-arn %s: %w", *run.roleARN, err)}
 		}
+	}
+	if *run.allRegions {
+		fmt.Fprintf(run.stdout, "Scanning every enabled region (listed from %s)\n\n", run.cfg.Region)
+	} else if *run.region != "" {
+		fmt.Fprintf(run.stdout, "Scanning AWS region: %s\n\n", *run.region)
 	} else {
-		fmt.Printf("Found %d Internet Gateways\n", len(internetGateways))
+		fmt.Fprintf(run.stdout, "Scanning AWS region: %s (from default config)\n\n", run.cfg.Region)
 	}
 
-	fmt.Println("\nScanning NAT Gateways...")
-	natGateways, err := scanner.GetNatGateways(ctx)
-	if err != nil {
-		log.Fatalf("Failed to get NAT gateways: %v", err)
-	}
+	run.scanner = vpc.NewScanner(run.cfg)
 
-	if *outputJSON {
-		fmt.Printf("Found %d NAT Gateways:\n", len(natGateways))
-		for _, ngw := range natGateways {
-			ngwJSON, _ := json.MarshalIndent(ngw, "", "  ")
-			fmt.Printf("%s\n", ngwJSON)
-			fmt.Println("---")
+	// Managers are detected and system tags removed right after each scan, so every output and report
+	// sees the same resources; managers first, as CloudFormation is recognized by its system tag
+	run.prepareTags = func(resources interface{}) {
+		run.managers.Label(resources)
+		if *run.hideSystemTags {
+			vpc.HideSystemTags(resources)
 		}
-	} else {
-		fmt.Printf("Found %d NAT Gateways\n", len(natGateways))
 	}
 
-	fmt.Println("\nScanning Transit Gateways...")
-	transitGateways, err := scanner.GetTransitGateways(ctx)
+	run.scannedAt = time.Now()
+
+	// The account goes into the ARNs of resources the APIs return no owner for; checkpoints only
+	// resume for the same account and region, so they cannot do without it
+	run.accountID, err = awsconfig.AccountID(ctx, run.cfg)
 	if err != nil {
-		log.Fatalf("Failed to get transit gateways: %v", err)
+		if *run.checkpointDir != "" {
+			return &runError{Category: errNoCredentials, Err: fmt.Errorf("Failed to identify the account for -checkpoint-dir: %w", err)}
+		}
+		run.result.warnf("%v; ARNs of resources without an owner ID are left empty", err)
 	}
+	run.scanner.SetAccountID(run.accountID)
+	run.result.AccountID = run.accountID
+	return nil
+}
 
-	if *outputJSON {
-		fmt.Printf("Found %d Transit Gateways:\n", len(transitGateways))
-		for _, tgw := range transitGateways {
-			tgwJSON, _ := json.MarshalIndent(tgw, "", "  ")
-			fmt.Printf("%s\n", tgwJSON)
-			fmt.Println("---")
-		}
-	} else {
-		fmt.Printf("Found %d Transit Gateways\n", len(transitGateways))
+// writeDiagram saves the overview diagram as vpc-diagram.drawio in the current directory
+// diagramXML: draw.io document from the diagram generator
+// Returns: Error if the file cannot be written
+func writeDiagram(stdout io.Writer, result *RunResult, diagramXML string) error {
+	filename := "vpc-diagram.drawio"
+	if err := os.WriteFile(filename, []byte(diagramXML), 0644); err != nil {
+		return fmt.Errorf("Failed to write diagram file: %w", err)
 	}
+	result.output(filename)
+	fmt.Fprintf(stdout, "Diagram saved to: %s\n", filename)
+	fmt.Fprintln(stdout, "You can open this file in draw.io (https://app.diagrams.net)")
+	return nil
+}
 
-	fmt.Println("\nScanning Transit Gateway Attachments...")
-	tgwAttachments, err := scanner.GetTransitGatewayAttachments(ctx)
-	if err != nil {
-		log.Fatalf("Failed to get transit gateway attachments: %v", err)
+// writeMermaidDiagram saves the overview diagram as vpc-diagram.md in the current directory
+// markdown: Document from MermaidGenerator.Markdown
+// Returns: Error if the file cannot be written
+func writeMermaidDiagram(stdout io.Writer, result *RunResult, markdown string) error {
+	filename := "vpc-diagram.md"
+	if err := os.WriteFile(filename, []byte(markdown), 0644); err != nil {
+		return fmt.Errorf("Failed to write diagram file: %w", err)
 	}
+	result.output(filename)
+	fmt.Fprintf(stdout, "Diagram saved to: %s\n", filename)
+	fmt.Fprintln(stdout, "GitHub, GitLab and Notion render its mermaid block")
+	return nil
+}
 
-	if *outputJSON {
-		fmt.Printf("Found %d Transit Gateway Attachments:\n", len(tgwAttachments))
-		for _, attachment := range tgwAttachments {
-			attachmentJSON, _ := json.MarshalIndent(attachment, "", "  ")
-			fmt.Printf("%s\n", attachmentJSON)
-			fmt.Println("---")
-		}
+// writeTerraformImports saves the Terraform import blocks of a scan to the file of -terraform-out
+// scan: Resources of the scan, or the scan loaded by -load
+// Returns: Error if the file cannot be written
+func writeTerraformImports(stdout io.Writer, result *RunResult, path string, scan *report.ScanResult) error {
+	imports := terraform.NewTerraformExporter().GenerateImportBlocks(scan)
+	if err := os.WriteFile(path, []byte(imports), 0644); err != nil {
+		return fmt.Errorf("Failed to write Terraform import blocks: %w", err)
+	}
+	result.output(path)
+	fmt.Fprintf(stdout, "\nTerraform import blocks saved to: %s\n", path)
+	return nil
+}
+
+// writeSecurityReport prints the open security group rules of -security-report, most severe first, and the
+// Elastic IPs associated with nothing
+// findings: Findings from analysis.AnalyzeSecurityGroups
+// elasticIPs: Elastic IPs of the scan (nil if they were not scanned, which leaves them out of the report)
+func writeSecurityReport(w io.Writer, findings []analysis.SecurityGroupFinding, elasticIPs []vpc.ElasticIPInfo) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "\nSecurity report: no security group rules open to the internet")
 	} else {
-		fmt.Printf("Found %d Transit Gateway Attachments\n", len(tgwAttachments))
+		fmt.Fprintf(w, "\nSecurity report: %d open security group rule(s)\n", len(findings))
+		for _, finding := range findings {
+			fmt.Fprintf(w, "  %-6s %s (%s) rule %d: %s\n", finding.Severity, finding.GroupID, finding.GroupName, finding.RuleIndex, finding.Description)
+		}
 	}
 
-	fmt.Println("\nVPC infrastructure scan complete!")
+	var unattached []vpc.ElasticIPInfo
+	for _, eip := range elasticIPs {
+		if eip.IsUnattached {
+			unattached = append(unattached, eip)
+		}
+	}
+	if len(unattached) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%d unattached Elastic IP(s), billed by the hour while associated with nothing:\n", len(unattached))
+	for _, eip := range unattached {
+		name := ""
+		if eip.Tags["Name"] != "" {
+			name = fmt.Sprintf(" (%s)", eip.Tags["Name"])
+		}
+		fmt.Fprintf(w, "  %-6s %s%s: %s\n", analysis.SeverityLow, eip.AllocationID, name, eip.PublicIp)
+	}
+}
 
-	// Generate diagram if requested
-	if *generateDiagram {
-		fmt.Println("\nGenerating draw.io diagram...")
-		diagramGen := diagram.NewDiagramGenerator()
+// writeDetailDiagrams saves the detail diagrams to a directory, one file per VPC
+// Files are named after the VPC Name tag; duplicates, emoji and reserved names are resolved by naming.
+// dir: Directory of -detail-diagrams, created if missing
+// details: Diagrams from GenerateVPCDetailDiagrams
+// vpcs: VPCs whose Name tags name the files
+// Returns: Error if the directory or a file cannot be written
+func writeDetailDiagrams(stdout io.Writer, result *RunResult, dir string, details []diagram.DetailDiagram, vpcs []vpc.VPCInfo) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Failed to create detail diagram directory: %w", err)
+	}
+	vpcNames := make(map[string]string, len(vpcs))
+	for _, v := range vpcs {
+		vpcNames[v.VpcID] = v.Tags["Name"]
+	}
+	files := naming.NewNamer(naming.StyleFilename)
+	for _, detail := range details {
+		filename := filepath.Join(dir, files.Name(detail.VpcID, vpcNames[detail.VpcID])+".drawio")
+		if err := os.WriteFile(filename, []byte(detail.XML), 0644); err != nil {
+			return fmt.Errorf("Failed to write detail diagram file: %w", err)
+		}
+		result.output(filename)
+	}
+
+	fmt.Fprintf(stdout, "%d detail diagrams saved to: %s\n", len(details), dir)
+	printRenames(stdout, files.Renames(), ".drawio")
+	return nil
+}
 
-		diagramXML, err := diagramGen.GenerateVPCDiagram(
-			vpcs,
-			subnets,
-			routeTables,
-			securityGroups,
-			internetGateways,
-			natGateways,
-			transitGateways,
-			tgwAttachments,
-		)
-		if err != nil {
-			log.Fatalf("Failed to generate diagram: %v", err)
+// printRenames lists the files whose names differ from the Name tag they were derived from
+// Tag values are free-form, so this is how a sanitized or de-duplicated file is traced back to its resource
+func printRenames(w io.Writer, renames []naming.Rename, extension string) {
+	if len(renames) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Renamed files:")
+	for _, rename := range renames {
+		name := "no Name tag"
+		if rename.Name != "" {
+			name = fmt.Sprintf("Name %q", rename.Name)
 		}
+		fmt.Fprintf(w, "  %s%s <- %s (%s): %s\n", rename.Identifier, extension, rename.ResourceID, name, strings.Join(rename.Reasons, ", "))
+	}
+}
+
+// allRegionsFlags are the flags that can be combined with -all-regions: output settings and the core scan
+var allRegionsFlags = map[string]bool{
+	"all-regions": true, "region-parallelism": true, "diagram": true, "diagram-plain": true, "dim-managed": true,
+	"json": true, "silent": true, "field-style": true, "format": true, "hide-system-tags": true, "managed-by-rules": true,
+	"proxy": true, "http-timeout": true, "max-idle-conns": true, "max-api-calls": true, "result-file": true, "validate-only": true,
+	"tag": true, "role-arn": true, "external-id": true,
+}
+
+// Formats of the -diagram output
+const (
+	diagramDrawio  = "drawio"  // draw.io document, vpc-diagram.drawio
+	diagramMermaid = "mermaid" // Markdown with a Mermaid flowchart, vpc-diagram.md
+)
+
+// loadFlags are the flags that can be combined with -load: the outputs drawn from the saved resources and their settings
+var loadFlags = map[string]bool{
+	"load": true, "json": true, "silent": true, "field-style": true, "format": true, "diagram": true, "detail-diagrams": true,
+	"diagram-plain": true, "dim-managed": true, "managed-by-rules": true, "hide-default-egress": true, "result-file": true, "validate-only": true,
+	"diagram-format": true, "terraform-out": true, "security-report": true, "max-age": true, "allow-stale": true,
+}
 
-		// Write diagram to file
-		filename := "vpc-diagram.drawio"
-		err = os.WriteFile(filename, []byte(diagramXML), 0644)
-		if err != nil {
-			log.Fatalf("Failed to write diagram file: %v", err)
+// profileOutputFlags are the flags naming files or directories a scan writes
+var profileOutputFlags = []string{"pdf", "plantuml", "public-ips-csv", "scan-manifest", "graph-out", "backstage-out", "detail-diagrams", "checkpoint-dir", "template-out", "result-file", "report-out", "save", "terraform-out"}
+
+// profileInputFlags are the flags naming files a scan reads, which the per-profile scans need as absolute paths
+var profileInputFlags = map[string]bool{"compare-with": true, "saas-catalog": true, "path-properties": true, "named-ranges": true, "template": true, "managed-by-rules": true, "stability-history": true}
+
+// profileScanArgs returns the flags given on the command line for the scan of each profile
+// The -profiles flags are left out and input files made absolute, as the scans run in the profile directories.
+func profileScanArgs() []string {
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "profiles", "profile-parallelism", "profiles-dir", "pending-threshold":
+			return
+		}
+		// Repeated flags are passed on once per value, as their String joins the values
+		if values, ok := f.Value.(*repeatedFlag); ok {
+			for _, value := range *values {
+				args = append(args, "-"+f.Name+"="+value)
+			}
+			return
+		}
+		value := f.Value.String()
+		if profileInputFlags[f.Name] && value != "" {
+			if abs, err := filepath.Abs(value); err == nil {
+				value = abs
+			}
 		}
+		args = append(args, "-"+f.Name+"="+value)
+	})
+	return args
+}
 
-		fmt.Printf("Diagram saved to: %s\n", filename)
-		fmt.Println("You can open this file in draw.io (https://app.diagrams.net)")
+// findingOrder is the sort key of a PDF finding for -max-items-per-section: high severity first, then by resource
+func findingOrder(finding pdf.Finding) string {
+	rank := map[string]string{analysis.SeverityHigh: "0", analysis.SeverityMedium: "1", analysis.SeverityLow: "2"}[finding.Severity]
+	if rank == "" {
+		rank = "3"
+	}
+	return rank + " " + finding.ResourceID + " " + finding.Title
+}
+
+// writeTemplateSchema lists the data and helper functions of -template
+// The fields are generated from templates.Data, so the listing always matches what templates see.
+func writeTemplateSchema(w io.Writer) error {
+	if err := query.WriteTypeSchema(w, "Fields of the -template data (the root is .):", reflect.TypeOf(templates.Data{})); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nHelper functions:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, helper := range templates.Helpers {
+		fmt.Fprintf(tw, "  %s\t%s\n", helper[0], helper[1])
+	}
+	return tw.Flush()
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries and surrounding spaces
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,104 @@
+// Package accelerator provides functionality for scanning AWS Global Accelerator accelerators and their static IPs
+package accelerator
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/globalaccelerator"
+	"github.com/aws/aws-sdk-go-v2/service/globalaccelerator/types"
+
+	"aws-documentor/modules/vpc"
+)
+
+// homeRegion is the only region serving the Global Accelerator API
+// Accelerators are global resources, so they are scanned once regardless of -region
+const homeRegion = "us-west-2"
+
+// Accelerator types
+const (
+	TypeStandard      = "standard"       // Routes to endpoints by proximity and health
+	TypeCustomRouting = "custom-routing" // Maps listener ports to specific instance destinations
+)
+
+// AcceleratorInfo contains information about a Global Accelerator accelerator
+type AcceleratorInfo struct {
+	AcceleratorArn string   `json:"accelerator_arn"` // ARN of the accelerator
+	Name           string   `json:"name"`            // Name of the accelerator
+	Type           string   `json:"type"`            // standard or custom-routing
+	Enabled        bool     `json:"enabled"`         // Whether the accelerator accepts traffic
+	Status         string   `json:"status"`          // Deployment status (DEPLOYED, IN_PROGRESS)
+	DnsName        string   `json:"dns_name"`        // DNS name pointing to the static IPs
+	IpAddresses    []string `json:"ip_addresses"`    // Static anycast IP addresses of all IP sets
+}
+
+// Scanner provides methods for retrieving Global Accelerator information
+type Scanner struct {
+	client *globalaccelerator.Client // AWS Global Accelerator client for making API calls
+}
+
+// NewScanner creates a new Global Accelerator scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials; the region is replaced by the Global Accelerator home region
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		client: globalaccelerator.NewFromConfig(cfg, func(o *globalaccelerator.Options) {
+			o.Region = homeRegion
+		}),
+	}
+}
+
+// GetAccelerators retrieves every standard and custom routing accelerator in the account
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Standard accelerators followed by custom routing ones, or error if an operation fails
+func (s *Scanner) GetAccelerators(ctx context.Context) ([]AcceleratorInfo, error) {
+	accelerators := []AcceleratorInfo{}
+
+	standard := globalaccelerator.NewListAcceleratorsPaginator(s.client, &globalaccelerator.ListAcceleratorsInput{})
+	for standard.HasMorePages() {
+		page, err := standard.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("globalaccelerator", "accelerators", "ListAccelerators", err)
+		}
+		for _, a := range page.Accelerators {
+			accelerators = append(accelerators, AcceleratorInfo{
+				AcceleratorArn: aws.ToString(a.AcceleratorArn),
+				Name:           aws.ToString(a.Name),
+				Type:           TypeStandard,
+				Enabled:        aws.ToBool(a.Enabled),
+				Status:         string(a.Status),
+				DnsName:        aws.ToString(a.DnsName),
+				IpAddresses:    ipAddresses(a.IpSets),
+			})
+		}
+	}
+
+	custom := globalaccelerator.NewListCustomRoutingAcceleratorsPaginator(s.client, &globalaccelerator.ListCustomRoutingAcceleratorsInput{})
+	for custom.HasMorePages() {
+		page, err := custom.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("globalaccelerator", "custom routing accelerators", "ListCustomRoutingAccelerators", err)
+		}
+		for _, a := range page.Accelerators {
+			accelerators = append(accelerators, AcceleratorInfo{
+				AcceleratorArn: aws.ToString(a.AcceleratorArn),
+				Name:           aws.ToString(a.Name),
+				Type:           TypeCustomRouting,
+				Enabled:        aws.ToBool(a.Enabled),
+				Status:         string(a.Status),
+				DnsName:        aws.ToString(a.DnsName),
+				IpAddresses:    ipAddresses(a.IpSets),
+			})
+		}
+	}
+
+	return accelerators, nil
+}
+
+// ipAddresses flattens the addresses of the IP sets of an accelerator
+func ipAddresses(ipSets []types.IpSet) []string {
+	addresses := []string{}
+	for _, ipSet := range ipSets {
+		addresses = append(addresses, ipSet.IpAddresses...)
+	}
+	return addresses
+}
@@ -0,0 +1,108 @@
+package accelerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/vpc"
+)
+
+// newTestScanner returns a scanner whose Global Accelerator endpoint answers each operation with its page
+// for the request's NextToken
+// Operations without an entry fail with AccessDeniedException.
+// pages: Response bodies by operation (the part of X-Amz-Target after the dot) and NextToken ("" for the first page)
+func newTestScanner(t *testing.T, pages map[string]map[string]string) *Scanner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, operation, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+		var input struct{ NextToken string }
+		json.NewDecoder(r.Body).Decode(&input)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		body, ok := pages[operation][input.NextToken]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"AccessDeniedException","message":"not authorized"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewScanner(aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+}
+
+// TestGetAccelerators reads standard accelerators from two pages and a custom routing one, flattening the
+// addresses of every IP set
+func TestGetAccelerators(t *testing.T) {
+	scanner := newTestScanner(t, map[string]map[string]string{
+		"ListAccelerators": {
+			"": `{"NextToken": "page-2", "Accelerators": [{"AcceleratorArn": "arn:aws:globalaccelerator::111122223333:accelerator/0web",
+				"Name": "web", "Enabled": true, "Status": "DEPLOYED", "DnsName": "a0web.awsglobalaccelerator.com",
+				"IpSets": [{"IpFamily": "IPv4", "IpAddresses": ["75.2.0.1", "99.83.0.1"]}]}]}`,
+			"page-2": `{"Accelerators": [{"AcceleratorArn": "arn:aws:globalaccelerator::111122223333:accelerator/0old",
+				"Name": "old", "Enabled": false, "Status": "IN_PROGRESS", "IpSets": []}]}`,
+		},
+		"ListCustomRoutingAccelerators": {
+			"": `{"Accelerators": [{"AcceleratorArn": "arn:aws:globalaccelerator::111122223333:accelerator/0game",
+				"Name": "game", "Enabled": true, "Status": "DEPLOYED", "DnsName": "a0game.awsglobalaccelerator.com",
+				"IpSets": [{"IpFamily": "IPv4", "IpAddresses": ["15.197.0.1"]}, {"IpFamily": "IPv4", "IpAddresses": ["3.33.0.1"]}]}]}`,
+		},
+	})
+
+	got, err := scanner.GetAccelerators(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AcceleratorInfo{
+		{AcceleratorArn: "arn:aws:globalaccelerator::111122223333:accelerator/0web", Name: "web", Type: TypeStandard, Enabled: true,
+			Status: "DEPLOYED", DnsName: "a0web.awsglobalaccelerator.com", IpAddresses: []string{"75.2.0.1", "99.83.0.1"}},
+		{AcceleratorArn: "arn:aws:globalaccelerator::111122223333:accelerator/0old", Name: "old", Type: TypeStandard,
+			Status: "IN_PROGRESS", IpAddresses: []string{}},
+		{AcceleratorArn: "arn:aws:globalaccelerator::111122223333:accelerator/0game", Name: "game", Type: TypeCustomRouting, Enabled: true,
+			Status: "DEPLOYED", DnsName: "a0game.awsglobalaccelerator.com", IpAddresses: []string{"15.197.0.1", "3.33.0.1"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAccelerators() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestScanErrors checks that a denied call of either accelerator type fails the scan with an access denied error
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     map[string]map[string]string
+		operation string
+	}{
+		{name: "standard", operation: "ListAccelerators"},
+		{name: "custom routing", pages: map[string]map[string]string{"ListAccelerators": {"": `{"Accelerators": []}`}},
+			operation: "ListCustomRoutingAccelerators"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestScanner(t, tt.pages).GetAccelerators(context.Background())
+			var scanErr *vpc.ScanError
+			if !errors.As(err, &scanErr) || scanErr.Operation != tt.operation {
+				t.Fatalf("error = %v, want a scan error of %s", err, tt.operation)
+			}
+			if !errors.Is(err, vpc.ErrAccessDenied) {
+				t.Errorf("error = %v, want access denied", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Package analysis provides functionality for analyzing scanned VPC infrastructure for configuration problems
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Security group reference classes
+const (
+	ReferenceValid                = "valid"                  // Referenced group exists, or is reachable through an active peering connection
+	ReferenceDangling             = "dangling"               // Referenced group is not in the scan and belongs to the same account
+	ReferenceOrphanedCrossAccount = "orphaned-cross-account" // Referenced group is foreign and no active peering path exists
+)
+
+// SGReferenceFinding describes a security group rule that references another security group
+type SGReferenceFinding struct {
+	GroupID                string `json:"group_id"`                  // ID of the security group containing the rule
+	GroupName              string `json:"group_name"`                // Name of the security group containing the rule
+	VpcID                  string `json:"vpc_id"`                    // ID of the VPC of the security group containing the rule
+	IsEgress               bool   `json:"is_egress"`                 // Whether the rule is an egress rule
+	IpProtocol             string `json:"ip_protocol"`               // IP protocol of the rule
+	FromPort               int32  `json:"from_port"`                 // Start of port range
+	ToPort                 int32  `json:"to_port"`                   // End of port range
+	ReferencedGroupID      string `json:"referenced_group_id"`       // ID of the referenced security group
+	ReferencedOwnerID      string `json:"referenced_owner_id"`       // AWS account ID that owns the referenced group
+	VpcPeeringConnectionID string `json:"vpc_peering_connection_id"` // Peering connection the reference relies on (if any)
+	Classification         string `json:"classification"`            // valid, dangling or orphaned-cross-account
+	Reason                 string `json:"reason"`                    // Human-readable explanation of the classification
+}
+
+// SGReferenceAnnotation summarizes the reference classifications of a single security group
+type SGReferenceAnnotation struct {
+	Valid                int `json:"valid"`                  // Number of rules with valid references
+	Dangling             int `json:"dangling"`               // Number of rules referencing deleted groups
+	OrphanedCrossAccount int `json:"orphaned_cross_account"` // Number of rules referencing foreign groups without a peering path
+}
+
+// SGReferenceReport contains the results of the security group reference analysis
+type SGReferenceReport struct {
+	Findings []SGReferenceFinding             `json:"findings"` // Dangling and orphaned references (valid references are only counted)
+	Groups   map[string]SGReferenceAnnotation `json:"groups"`   // Per-group annotation keyed by security group ID
+}
+
+// AnalyzeSecurityGroupReferences classifies every security group rule that references another security group
+// securityGroups: Security groups from the scan (the referenced groups are looked up among these)
+// routeTables: Route tables from the scan, used to find active peering connections
+// Returns: Report with findings for dangling and orphaned references plus a per-group annotation
+func AnalyzeSecurityGroupReferences(securityGroups []vpc.SecurityGroupInfo, routeTables []vpc.RouteTableInfo) *SGReferenceReport {
+	report := &SGReferenceReport{
+		Findings: []SGReferenceFinding{},
+		Groups:   make(map[string]SGReferenceAnnotation),
+	}
+
+	// Index the groups present in the scan
+	knownGroups := make(map[string]bool)
+	for _, sg := range securityGroups {
+		knownGroups[sg.GroupID] = true
+	}
+
+	// A peering connection is usable from a VPC when one of its route tables has an active route over it
+	activePeerings := make(map[string]map[string]bool)
+	for _, rt := range routeTables {
+		for _, route := range rt.Routes {
+			if route.VpcPeeringConnectionID == "" || route.State != "active" {
+				continue
+			}
+			if activePeerings[rt.VpcID] == nil {
+				activePeerings[rt.VpcID] = make(map[string]bool)
+			}
+			activePeerings[rt.VpcID][route.VpcPeeringConnectionID] = true
+		}
+	}
+
+	for _, sg := range securityGroups {
+		annotation := report.Groups[sg.GroupID]
+
+		for _, rule := range sg.Rules {
+			if rule.GroupID == "" {
+				continue
+			}
+
+			classification, reason := classifyReference(sg, rule, knownGroups, activePeerings[sg.VpcID])
+			switch classification {
+			case ReferenceValid:
+				annotation.Valid++
+				continue
+			case ReferenceDangling:
+				annotation.Dangling++
+			case ReferenceOrphanedCrossAccount:
+				annotation.OrphanedCrossAccount++
+			}
+
+			report.Findings = append(report.Findings, SGReferenceFinding{
+				GroupID:                sg.GroupID,
+				GroupName:              sg.GroupName,
+				VpcID:                  sg.VpcID,
+				IsEgress:               rule.IsEgress,
+				IpProtocol:             rule.IpProtocol,
+				FromPort:               rule.FromPort,
+				ToPort:                 rule.ToPort,
+				ReferencedGroupID:      rule.GroupID,
+				ReferencedOwnerID:      rule.GroupOwnerID,
+				VpcPeeringConnectionID: rule.VpcPeeringConnectionID,
+				Classification:         classification,
+				Reason:                 reason,
+			})
+		}
+
+		report.Groups[sg.GroupID] = annotation
+	}
+
+	// Deterministic order for reports and diffs
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].GroupID != report.Findings[j].GroupID {
+			return report.Findings[i].GroupID < report.Findings[j].GroupID
+		}
+		return report.Findings[i].ReferencedGroupID < report.Findings[j].ReferencedGroupID
+	})
+
+	return report
+}
+
+// classifyReference determines whether a rule's group reference is functional
+// sg: Security group containing the rule
+// rule: Rule referencing another security group
+// knownGroups: IDs of security groups present in the scan
+// peerings: Active peering connection IDs usable from the rule's VPC
+// Returns: Classification and a human-readable reason
+func classifyReference(sg vpc.SecurityGroupInfo, rule vpc.SecurityGroupRule, knownGroups map[string]bool, peerings map[string]bool) (string, string) {
+	foreign := rule.GroupOwnerID != "" && sg.OwnerID != "" && rule.GroupOwnerID != sg.OwnerID
+
+	// Same-account references that resolve in the scan are always valid
+	if !foreign && knownGroups[rule.GroupID] {
+		return ReferenceValid, "referenced group exists"
+	}
+
+	// References through a peering connection work only while the peering is active
+	if foreign || rule.VpcPeeringConnectionID != "" {
+		switch {
+		case rule.VpcPeeringConnectionID == "":
+			return ReferenceOrphanedCrossAccount, fmt.Sprintf("group is owned by account %s and the rule names no peering connection", rule.GroupOwnerID)
+		case rule.PeeringStatus == "active" || peerings[rule.VpcPeeringConnectionID]:
+			return ReferenceValid, fmt.Sprintf("reachable through active peering connection %s", rule.VpcPeeringConnectionID)
+		case rule.PeeringStatus != "":
+			return ReferenceOrphanedCrossAccount, fmt.Sprintf("peering connection %s is %s", rule.VpcPeeringConnectionID, rule.PeeringStatus)
+		default:
+			return ReferenceOrphanedCrossAccount, fmt.Sprintf("no active route uses peering connection %s", rule.VpcPeeringConnectionID)
+		}
+	}
+
+	return ReferenceDangling, "referenced group was not found in this account"
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Accounts of the security group reference tests
+const (
+	testAccount    = "111122223333"
+	foreignAccount = "444455556666"
+)
+
+// peeringRoutes are route tables of vpc-0a1 with an active route over pcx-0active and a blackhole route over
+// pcx-0blackhole, and of vpc-0b2 with an active route over pcx-0other
+var peeringRoutes = []vpc.RouteTableInfo{
+	{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", Routes: []vpc.RouteInfo{
+		{DestinationCidrBlock: "10.1.0.0/16", VpcPeeringConnectionID: "pcx-0active", State: "active"},
+		{DestinationCidrBlock: "10.2.0.0/16", VpcPeeringConnectionID: "pcx-0blackhole", State: "blackhole"},
+	}},
+	{RouteTableID: "rtb-0b2", VpcID: "vpc-0b2", Routes: []vpc.RouteInfo{
+		{DestinationCidrBlock: "10.3.0.0/16", VpcPeeringConnectionID: "pcx-0other", State: "active"},
+	}},
+}
+
+// TestAnalyzeSecurityGroupReferences classifies a reference of every class, including cross-account
+// references that are valid through an active peering connection
+func TestAnalyzeSecurityGroupReferences(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       vpc.SecurityGroupRule
+		want       string
+		wantReason string
+	}{
+		{name: "group in the scan", rule: vpc.SecurityGroupRule{GroupID: "sg-0db", GroupOwnerID: testAccount},
+			want: ReferenceValid, wantReason: "referenced group exists"},
+		{name: "deleted group", rule: vpc.SecurityGroupRule{GroupID: "sg-0deleted", GroupOwnerID: testAccount},
+			want: ReferenceDangling, wantReason: "referenced group was not found in this account"},
+		{name: "deleted group without an owner", rule: vpc.SecurityGroupRule{GroupID: "sg-0deleted"},
+			want: ReferenceDangling, wantReason: "referenced group was not found in this account"},
+		{name: "foreign group over an active peering", rule: vpc.SecurityGroupRule{GroupID: "sg-0peer", GroupOwnerID: foreignAccount,
+			VpcPeeringConnectionID: "pcx-0gone", PeeringStatus: "active"},
+			want: ReferenceValid, wantReason: "reachable through active peering connection pcx-0gone"},
+		{name: "foreign group over an actively routed peering", rule: vpc.SecurityGroupRule{GroupID: "sg-0peer", GroupOwnerID: foreignAccount,
+			VpcPeeringConnectionID: "pcx-0active"},
+			want: ReferenceValid, wantReason: "reachable through active peering connection pcx-0active"},
+		{name: "foreign group without a peering", rule: vpc.SecurityGroupRule{GroupID: "sg-0peer", GroupOwnerID: foreignAccount},
+			want: ReferenceOrphanedCrossAccount, wantReason: "group is owned by account 444455556666 and the rule names no peering connection"},
+		{name: "foreign group over a deleted peering", rule: vpc.SecurityGroupRule{GroupID: "sg-0peer", GroupOwnerID: foreignAccount,
+			VpcPeeringConnectionID: "pcx-0deleted", PeeringStatus: "deleted"},
+			want: ReferenceOrphanedCrossAccount, wantReason: "peering connection pcx-0deleted is deleted"},
+		{name: "foreign group over a blackhole route", rule: vpc.SecurityGroupRule{GroupID: "sg-0peer", GroupOwnerID: foreignAccount,
+			VpcPeeringConnectionID: "pcx-0blackhole"},
+			want: ReferenceOrphanedCrossAccount, wantReason: "no active route uses peering connection pcx-0blackhole"},
+		{name: "foreign group over a peering routed from another VPC", rule: vpc.SecurityGroupRule{GroupID: "sg-0peer", GroupOwnerID: foreignAccount,
+			VpcPeeringConnectionID: "pcx-0other"},
+			want: ReferenceOrphanedCrossAccount, wantReason: "no active route uses peering connection pcx-0other"},
+		{name: "foreign group with the ID of a local one", rule: vpc.SecurityGroupRule{GroupID: "sg-0db", GroupOwnerID: foreignAccount},
+			want: ReferenceOrphanedCrossAccount, wantReason: "group is owned by account 444455556666 and the rule names no peering connection"},
+		{name: "same-account group in a peered VPC", rule: vpc.SecurityGroupRule{GroupID: "sg-0remote", GroupOwnerID: testAccount,
+			VpcPeeringConnectionID: "pcx-0active"},
+			want: ReferenceValid, wantReason: "reachable through active peering connection pcx-0active"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			web := vpc.SecurityGroupInfo{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", OwnerID: testAccount,
+				Rules: []vpc.SecurityGroupRule{tt.rule, {CidrBlock: "0.0.0.0/0", IpProtocol: "tcp", FromPort: 443, ToPort: 443}}}
+			db := vpc.SecurityGroupInfo{GroupID: "sg-0db", GroupName: "db", VpcID: "vpc-0a1", OwnerID: testAccount}
+			report := AnalyzeSecurityGroupReferences([]vpc.SecurityGroupInfo{web, db}, peeringRoutes)
+
+			annotation := report.Groups["sg-0web"]
+			counts := map[string]int{ReferenceValid: annotation.Valid, ReferenceDangling: annotation.Dangling,
+				ReferenceOrphanedCrossAccount: annotation.OrphanedCrossAccount}
+			for class, count := range counts {
+				want := 0
+				if class == tt.want {
+					want = 1
+				}
+				if count != want {
+					t.Errorf("annotation counts %d %s references, want %d", count, class, want)
+				}
+			}
+			if report.Groups["sg-0db"] != (SGReferenceAnnotation{}) {
+				t.Errorf("group without references annotated %+v", report.Groups["sg-0db"])
+			}
+
+			if tt.want == ReferenceValid {
+				if len(report.Findings) != 0 {
+					t.Errorf("valid reference reported as %+v", report.Findings)
+				}
+				if _, reason := classifyReference(web, tt.rule, map[string]bool{"sg-0web": true, "sg-0db": true}, map[string]bool{"pcx-0active": true}); reason != tt.wantReason {
+					t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+				}
+				return
+			}
+			if len(report.Findings) != 1 {
+				t.Fatalf("got %d findings, want 1: %+v", len(report.Findings), report.Findings)
+			}
+			finding := report.Findings[0]
+			if finding.Classification != tt.want || finding.Reason != tt.wantReason {
+				t.Errorf("finding = %s (%s), want %s (%s)", finding.Classification, finding.Reason, tt.want, tt.wantReason)
+			}
+			if finding.GroupID != "sg-0web" || finding.GroupName != "web" || finding.ReferencedGroupID != tt.rule.GroupID ||
+				finding.ReferencedOwnerID != tt.rule.GroupOwnerID || finding.VpcPeeringConnectionID != tt.rule.VpcPeeringConnectionID {
+				t.Errorf("finding = %+v, want the rule of sg-0web", finding)
+			}
+		})
+	}
+}
+
+// TestSecurityGroupReferenceOrder checks that findings are sorted by group and referenced group and counted per group
+func TestSecurityGroupReferenceOrder(t *testing.T) {
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0b", VpcID: "vpc-0a1", OwnerID: testAccount, Rules: []vpc.SecurityGroupRule{
+			{GroupID: "sg-0z"},
+			{GroupID: "sg-0peer", GroupOwnerID: foreignAccount},
+			{GroupID: "sg-0a", IsEgress: true},
+		}},
+		{GroupID: "sg-0a", VpcID: "vpc-0a1", OwnerID: testAccount, Rules: []vpc.SecurityGroupRule{
+			{GroupID: "sg-0y"},
+		}},
+	}
+	report := AnalyzeSecurityGroupReferences(groups, nil)
+
+	want := []struct{ group, referenced string }{{"sg-0a", "sg-0y"}, {"sg-0b", "sg-0peer"}, {"sg-0b", "sg-0z"}}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(report.Findings), len(want), report.Findings)
+	}
+	for i, w := range want {
+		if got := report.Findings[i]; got.GroupID != w.group || got.ReferencedGroupID != w.referenced {
+			t.Errorf("finding %d = %s -> %s, want %s -> %s", i, got.GroupID, got.ReferencedGroupID, w.group, w.referenced)
+		}
+	}
+	if got := report.Groups["sg-0b"]; got != (SGReferenceAnnotation{Valid: 1, Dangling: 1, OrphanedCrossAccount: 1}) {
+		t.Errorf("annotation of sg-0b = %+v, want one reference of each class", got)
+	}
+
+	if empty := AnalyzeSecurityGroupReferences(nil, nil); empty.Findings == nil || len(empty.Findings) != 0 {
+		t.Errorf("findings of an empty scan = %#v, want an empty list", empty.Findings)
+	}
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Route appliance finding classes
+const (
+	ApplianceSingleNATInstance      = "single-nat-instance"       // A NAT instance without an Auto Scaling group to replace it
+	ApplianceSourceDestCheckEnabled = "source-dest-check-enabled" // Routes target an interface that drops traffic not addressed to it
+)
+
+// ApplianceFinding describes a problem with an instance or network interface that routes target
+type ApplianceFinding struct {
+	InstanceID         string   `json:"instance_id"`          // ID of the instance (empty for a network interface without an instance)
+	NetworkInterfaceID string   `json:"network_interface_id"` // ID of the routed network interface (if routes name one)
+	VpcID              string   `json:"vpc_id"`               // ID of the VPC of the instance or interface
+	RouteTableIDs      []string `json:"route_table_ids"`      // Route tables that depend on it
+	Classification     string   `json:"classification"`       // single-nat-instance or source-dest-check-enabled
+	Severity           string   `json:"severity"`             // Always high
+	Reason             string   `json:"reason"`               // Human-readable explanation
+}
+
+// RouteApplianceReport contains the legacy NAT instances and the problems found with routed appliances
+type RouteApplianceReport struct {
+	LegacyNATInstances []vpc.RouteApplianceInfo `json:"legacy_nat_instances"` // Routing appliances that receive a default route
+	Findings           []ApplianceFinding       `json:"findings"`             // Availability and configuration problems
+}
+
+// AnalyzeRouteAppliances lists NAT instances and checks every routed instance or interface
+// A NAT instance outside an Auto Scaling group has no recovery mechanism the scan can see (CloudWatch
+// auto-recovery alarms are not scanned), so every route table depending on it loses its default route
+// when the instance fails. A routed interface with source/destination checking still enabled drops
+// all forwarded traffic.
+// appliances: Routed instances and interfaces from GetRouteAppliances
+// Returns: Report in appliance order
+func AnalyzeRouteAppliances(appliances []vpc.RouteApplianceInfo) *RouteApplianceReport {
+	report := &RouteApplianceReport{
+		LegacyNATInstances: []vpc.RouteApplianceInfo{},
+		Findings:           []ApplianceFinding{},
+	}
+
+	for _, appliance := range appliances {
+		base := ApplianceFinding{
+			InstanceID:         appliance.InstanceID,
+			NetworkInterfaceID: appliance.NetworkInterfaceID,
+			VpcID:              appliance.VpcID,
+			RouteTableIDs:      appliance.RouteTableIDs,
+			Severity:           SeverityHigh,
+		}
+
+		switch {
+		case appliance.State == "not-found":
+			// Routes to deleted targets are blackholes, which the route state already shows
+			continue
+		case appliance.SourceDestCheck:
+			finding := base
+			finding.Classification = ApplianceSourceDestCheckEnabled
+			finding.Reason = fmt.Sprintf("routes to %s for %s are dropped: source/destination check is enabled",
+				applianceID(appliance), strings.Join(appliance.Destinations, ", "))
+			report.Findings = append(report.Findings, finding)
+		case appliance.IsNATInstance:
+			report.LegacyNATInstances = append(report.LegacyNATInstances, appliance)
+			if appliance.AutoScalingGroup == "" {
+				finding := base
+				finding.Classification = ApplianceSingleNATInstance
+				finding.Reason = fmt.Sprintf("NAT instance %s is not in an Auto Scaling group; route tables %s lose outbound access if it fails",
+					appliance.InstanceID, strings.Join(appliance.RouteTableIDs, ", "))
+				report.Findings = append(report.Findings, finding)
+			}
+		}
+	}
+
+	return report
+}
+
+// applianceID returns the instance ID of an appliance, or its interface ID without an instance
+func applianceID(appliance vpc.RouteApplianceInfo) string {
+	if appliance.InstanceID != "" {
+		return appliance.InstanceID
+	}
+	return appliance.NetworkInterfaceID
+}
@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeRouteAppliances checks the legacy NAT instance list and findings for a NAT instance, a NAT
+// instance in an Auto Scaling group, an appliance interface without an instance, a routed instance with
+// source/destination checking still enabled and a route to a deleted instance
+func TestAnalyzeRouteAppliances(t *testing.T) {
+	nat := vpc.RouteApplianceInfo{InstanceID: "i-0nat", NetworkInterfaceID: "eni-0nat", VpcID: "vpc-0legacy", State: "running",
+		IsRoutingAppliance: true, IsNATInstance: true, RouteTableIDs: []string{"rtb-0a", "rtb-0b"}, Destinations: []string{"0.0.0.0/0"}}
+	scaled := vpc.RouteApplianceInfo{InstanceID: "i-0scaled", VpcID: "vpc-0legacy", State: "running", IsRoutingAppliance: true,
+		IsNATInstance: true, AutoScalingGroup: "nat", RouteTableIDs: []string{"rtb-0c"}, Destinations: []string{"0.0.0.0/0"}}
+	appliance := vpc.RouteApplianceInfo{NetworkInterfaceID: "eni-0appliance", VpcID: "vpc-0legacy", State: "available",
+		IsRoutingAppliance: true, RouteTableIDs: []string{"rtb-0c"}, Destinations: []string{"10.50.0.0/16", "0.0.0.0/0"}}
+	checked := vpc.RouteApplianceInfo{InstanceID: "i-0check", VpcID: "vpc-0legacy", State: "running", SourceDestCheck: true,
+		RouteTableIDs: []string{"rtb-0c"}, Destinations: []string{"192.168.0.0/16", "172.16.0.0/12"}}
+	checkedENI := vpc.RouteApplianceInfo{NetworkInterfaceID: "eni-0check", VpcID: "vpc-0legacy", State: "in-use", SourceDestCheck: true,
+		RouteTableIDs: []string{"rtb-0d"}, Destinations: []string{"10.60.0.0/16"}}
+	gone := vpc.RouteApplianceInfo{InstanceID: "i-0gone", State: "not-found", SourceDestCheck: true,
+		RouteTableIDs: []string{"rtb-0c"}, Destinations: []string{"10.70.0.0/16"}}
+
+	report := AnalyzeRouteAppliances([]vpc.RouteApplianceInfo{appliance, checked, checkedENI, gone, nat, scaled})
+
+	if !reflect.DeepEqual(report.LegacyNATInstances, []vpc.RouteApplianceInfo{nat, scaled}) {
+		t.Errorf("LegacyNATInstances = %+v, want i-0nat and i-0scaled", report.LegacyNATInstances)
+	}
+	want := []ApplianceFinding{
+		{InstanceID: "i-0check", VpcID: "vpc-0legacy", RouteTableIDs: []string{"rtb-0c"},
+			Classification: ApplianceSourceDestCheckEnabled, Severity: SeverityHigh,
+			Reason: "routes to i-0check for 192.168.0.0/16, 172.16.0.0/12 are dropped: source/destination check is enabled"},
+		{NetworkInterfaceID: "eni-0check", VpcID: "vpc-0legacy", RouteTableIDs: []string{"rtb-0d"},
+			Classification: ApplianceSourceDestCheckEnabled, Severity: SeverityHigh,
+			Reason: "routes to eni-0check for 10.60.0.0/16 are dropped: source/destination check is enabled"},
+		{InstanceID: "i-0nat", NetworkInterfaceID: "eni-0nat", VpcID: "vpc-0legacy", RouteTableIDs: []string{"rtb-0a", "rtb-0b"},
+			Classification: ApplianceSingleNATInstance, Severity: SeverityHigh,
+			Reason: "NAT instance i-0nat is not in an Auto Scaling group; route tables rtb-0a, rtb-0b lose outbound access if it fails"},
+	}
+	if !reflect.DeepEqual(report.Findings, want) {
+		t.Errorf("Findings = %+v\nwant %+v", report.Findings, want)
+	}
+
+	if empty := AnalyzeRouteAppliances(nil); empty.LegacyNATInstances == nil || empty.Findings == nil {
+		t.Errorf("report without appliances = %+v, want empty lists", empty)
+	}
+}
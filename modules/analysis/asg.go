@@ -0,0 +1,83 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/vpc"
+)
+
+// Auto Scaling group finding classes
+const (
+	ASGMissingSubnet = "missing-subnet" // The VPC zone identifier names a subnet that is not in the scan
+	ASGAZImbalance   = "az-imbalance"   // Instances are spread unevenly across the group's availability zones
+)
+
+// ASGFinding describes a problem with the subnet spread of an Auto Scaling group
+type ASGFinding struct {
+	AutoScalingGroupName string         `json:"auto_scaling_group_name"` // Name of the Auto Scaling group
+	SubnetID             string         `json:"subnet_id"`               // Missing subnet (for missing-subnet)
+	InstancesPerAZ       map[string]int `json:"instances_per_az"`        // Current instances per availability zone (for az-imbalance)
+	Classification       string         `json:"classification"`          // missing-subnet or az-imbalance
+	Reason               string         `json:"reason"`                  // Human-readable explanation of the classification
+}
+
+// AnalyzeAutoScalingGroups checks Auto Scaling groups against the scanned subnets and their AZ distribution
+// A group is imbalanced when the busiest and the emptiest of its availability zones differ by more than
+// one instance; Auto Scaling rebalances to within one, so a larger gap means launches are failing in a zone
+// groups: Auto Scaling groups from the ASG scan
+// subnets: Subnets from the VPC scan
+// Returns: Findings sorted by group name
+func AnalyzeAutoScalingGroups(groups []asg.AutoScalingGroupInfo, subnets []vpc.SubnetInfo) []ASGFinding {
+	findings := []ASGFinding{}
+
+	knownSubnets := make(map[string]bool)
+	for _, subnet := range subnets {
+		knownSubnets[subnet.SubnetID] = true
+	}
+
+	for _, group := range groups {
+		for _, subnetID := range group.SubnetIDs {
+			if knownSubnets[subnetID] {
+				continue
+			}
+			findings = append(findings, ASGFinding{
+				AutoScalingGroupName: group.AutoScalingGroupName,
+				SubnetID:             subnetID,
+				Classification:       ASGMissingSubnet,
+				Reason:               fmt.Sprintf("subnet %s was not found in the scan; it may have been deleted", subnetID),
+			})
+		}
+
+		if len(group.AvailabilityZones) < 2 || len(group.InstanceIDs) == 0 {
+			continue
+		}
+		perAZ := make(map[string]int)
+		for _, az := range group.AvailabilityZones {
+			perAZ[az] = group.InstancesPerAZ[az]
+		}
+		lowest, highest := -1, 0
+		for _, count := range perAZ {
+			if lowest == -1 || count < lowest {
+				lowest = count
+			}
+			if count > highest {
+				highest = count
+			}
+		}
+		if highest-lowest > 1 {
+			findings = append(findings, ASGFinding{
+				AutoScalingGroupName: group.AutoScalingGroupName,
+				InstancesPerAZ:       perAZ,
+				Classification:       ASGAZImbalance,
+				Reason:               fmt.Sprintf("availability zones hold between %d and %d instances", lowest, highest),
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].AutoScalingGroupName < findings[j].AutoScalingGroupName
+	})
+	return findings
+}
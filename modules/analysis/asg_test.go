@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeAutoScalingGroups checks groups spanning scanned subnets, a group referencing a deleted subnet
+// and the AZ balance of groups by their current instances
+func TestAnalyzeAutoScalingGroups(t *testing.T) {
+	subnets := []vpc.SubnetInfo{{SubnetID: "subnet-0a1"}, {SubnetID: "subnet-0b2"}, {SubnetID: "subnet-0c3"}}
+	zones := []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"}
+	tests := []struct {
+		name  string
+		group asg.AutoScalingGroupInfo
+		want  []ASGFinding
+	}{
+		{name: "spans scanned subnets, balanced",
+			group: asg.AutoScalingGroupInfo{SubnetIDs: []string{"subnet-0a1", "subnet-0b2", "subnet-0c3"}, AvailabilityZones: zones,
+				InstanceIDs: []string{"i-1", "i-2", "i-3", "i-4"}, InstancesPerAZ: map[string]int{"eu-west-1a": 2, "eu-west-1b": 1, "eu-west-1c": 1}}},
+		{name: "deleted subnet",
+			group: asg.AutoScalingGroupInfo{SubnetIDs: []string{"subnet-0a1", "subnet-0gone"}},
+			want: []ASGFinding{{SubnetID: "subnet-0gone", Classification: ASGMissingSubnet,
+				Reason: "subnet subnet-0gone was not found in the scan; it may have been deleted"}}},
+		{name: "imbalanced",
+			group: asg.AutoScalingGroupInfo{SubnetIDs: []string{"subnet-0a1"}, AvailabilityZones: zones,
+				InstanceIDs: []string{"i-1", "i-2", "i-3"}, InstancesPerAZ: map[string]int{"eu-west-1a": 3}},
+			want: []ASGFinding{{InstancesPerAZ: map[string]int{"eu-west-1a": 3, "eu-west-1b": 0, "eu-west-1c": 0}, Classification: ASGAZImbalance,
+				Reason: "availability zones hold between 0 and 3 instances"}}},
+		{name: "instances in a zone the group no longer uses",
+			group: asg.AutoScalingGroupInfo{AvailabilityZones: zones[:2],
+				InstanceIDs: []string{"i-1", "i-2", "i-3"}, InstancesPerAZ: map[string]int{"eu-west-1a": 1, "eu-west-1b": 1, "eu-west-1c": 1}}},
+		{name: "one zone", group: asg.AutoScalingGroupInfo{AvailabilityZones: zones[:1],
+			InstanceIDs: []string{"i-1", "i-2"}, InstancesPerAZ: map[string]int{"eu-west-1a": 2}}},
+		{name: "scaled to zero", group: asg.AutoScalingGroupInfo{AvailabilityZones: zones, InstanceIDs: []string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.group.AutoScalingGroupName = "web"
+			want := []ASGFinding{}
+			for _, finding := range tt.want {
+				finding.AutoScalingGroupName = "web"
+				want = append(want, finding)
+			}
+			if got := AnalyzeAutoScalingGroups([]asg.AutoScalingGroupInfo{tt.group}, subnets); !reflect.DeepEqual(got, want) {
+				t.Errorf("findings = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	groups := []asg.AutoScalingGroupInfo{
+		{AutoScalingGroupName: "worker", SubnetIDs: []string{"subnet-0gone"}},
+		{AutoScalingGroupName: "api", SubnetIDs: []string{"subnet-0old", "subnet-0b2"}},
+	}
+	var order []string
+	for _, finding := range AnalyzeAutoScalingGroups(groups, subnets) {
+		order = append(order, finding.AutoScalingGroupName+"/"+finding.SubnetID)
+	}
+	if want := []string{"api/subnet-0old", "worker/subnet-0gone"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("findings in order %v, want %v", order, want)
+	}
+}
@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// Directory finding classes
+const (
+	DirectoryWidenedSecurityGroup = "widened-security-group" // The directory's security group admits sources outside its VPC
+	DirectoryVPCNotScanned        = "vpc-not-scanned"        // The directory's VPC or security group is not in the scan
+)
+
+// DirectoryFinding describes a problem with the network footprint of a directory
+type DirectoryFinding struct {
+	DirectoryID     string `json:"directory_id"`      // ID of the directory
+	DirectoryName   string `json:"directory_name"`    // Fully qualified domain name of the directory
+	VpcID           string `json:"vpc_id"`            // ID of the directory's VPC
+	SecurityGroupID string `json:"security_group_id"` // ID of the security group created for the directory
+	Rule            string `json:"rule"`              // Ingress rule that widens the group (for widened-security-group)
+	Classification  string `json:"classification"`    // widened-security-group or vpc-not-scanned
+	Reason          string `json:"reason"`            // Human-readable explanation of the classification
+}
+
+// AnalyzeDirectorySecurityGroups flags directories whose auto-created security group has been widened
+// Directory Service creates the group with ingress from the directory's VPC only, so any ingress rule
+// from a CIDR block outside the VPC's CIDR blocks, from a prefix list, or from a security group in
+// another VPC was added by hand
+// directories: Directories from the directory scan
+// securityGroups: Security groups from the VPC scan
+// vpcs: VPCs from the VPC scan
+// Returns: Findings sorted by directory ID
+func AnalyzeDirectorySecurityGroups(directories []directory.DirectoryInfo, securityGroups []vpc.SecurityGroupInfo, vpcs []vpc.VPCInfo) []DirectoryFinding {
+	findings := []DirectoryFinding{}
+
+	groups := make(map[string]vpc.SecurityGroupInfo)
+	for _, sg := range securityGroups {
+		groups[sg.GroupID] = sg
+	}
+	vpcCIDRs := make(map[string][]string)
+	for _, v := range vpcs {
+		vpcCIDRs[v.VpcID] = append([]string{v.CidrBlock}, v.AssociateCidrBlocks...)
+	}
+
+	for _, d := range directories {
+		if d.SecurityGroupID == "" {
+			continue
+		}
+		base := DirectoryFinding{
+			DirectoryID:     d.DirectoryID,
+			DirectoryName:   d.Name,
+			VpcID:           d.VpcID,
+			SecurityGroupID: d.SecurityGroupID,
+		}
+
+		sg, ok := groups[d.SecurityGroupID]
+		cidrs, vpcScanned := vpcCIDRs[d.VpcID]
+		if !ok || !vpcScanned {
+			finding := base
+			finding.Classification = DirectoryVPCNotScanned
+			finding.Reason = fmt.Sprintf("VPC %s or security group %s was not found in the scan", d.VpcID, d.SecurityGroupID)
+			findings = append(findings, finding)
+			continue
+		}
+
+		for _, rule := range sg.Rules {
+			if rule.IsEgress {
+				continue
+			}
+			reason := widenedReason(rule, cidrs, d.VpcID, groups)
+			if reason == "" {
+				continue
+			}
+			finding := base
+			finding.Rule = describeRule(rule)
+			finding.Classification = DirectoryWidenedSecurityGroup
+			finding.Reason = reason
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].DirectoryID < findings[j].DirectoryID
+	})
+	return findings
+}
+
+// widenedReason explains why an ingress rule admits traffic from outside the directory's VPC
+// Returns: Reason, or an empty string if the rule only admits traffic from within the VPC
+func widenedReason(rule vpc.SecurityGroupRule, vpcCIDRs []string, vpcID string, groups map[string]vpc.SecurityGroupInfo) string {
+	switch {
+	case rule.PrefixListID != "":
+		return fmt.Sprintf("ingress from prefix list %s", rule.PrefixListID)
+	case rule.Ipv6CidrBlock != "":
+		return fmt.Sprintf("ingress from IPv6 block %s", rule.Ipv6CidrBlock)
+	case rule.GroupID != "":
+		if referenced, ok := groups[rule.GroupID]; ok && referenced.VpcID == vpcID {
+			return ""
+		}
+		return fmt.Sprintf("ingress from security group %s outside %s", rule.GroupID, vpcID)
+	case rule.CidrBlock != "":
+		for _, cidr := range vpcCIDRs {
+			if contains, err := netcalc.CIDRContains(cidr, rule.CidrBlock); err == nil && contains {
+				return ""
+			}
+		}
+		return fmt.Sprintf("ingress from %s, outside the VPC CIDR blocks", rule.CidrBlock)
+	}
+	return ""
+}
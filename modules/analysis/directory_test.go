@@ -0,0 +1,83 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeDirectorySecurityGroups checks every way a directory security group can be widened, and
+// flags a directory in a VPC that was not scanned
+func TestAnalyzeDirectorySecurityGroups(t *testing.T) {
+	vpcs := []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", AssociateCidrBlocks: []string{"100.64.0.0/16"}}}
+	peer := vpc.SecurityGroupInfo{GroupID: "sg-0app", VpcID: "vpc-0a1"}
+	foreign := vpc.SecurityGroupInfo{GroupID: "sg-0other", VpcID: "vpc-0b2"}
+	connector := directory.DirectoryInfo{DirectoryID: "d-0connector", Name: "corp.example.com", VpcID: "vpc-0a1", SecurityGroupID: "sg-0connector"}
+
+	tests := []struct {
+		name       string
+		rule       vpc.SecurityGroupRule
+		wantReason string // Empty when the rule is not a widening
+	}{
+		{name: "VPC CIDR", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 389, ToPort: 389, CidrBlock: "10.0.0.0/16"}},
+		{name: "subnet of a secondary CIDR", rule: vpc.SecurityGroupRule{IpProtocol: "-1", CidrBlock: "100.64.8.0/24"}},
+		{name: "group in the VPC", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 88, ToPort: 88, GroupID: "sg-0app"}},
+		{name: "egress anywhere", rule: vpc.SecurityGroupRule{IpProtocol: "-1", CidrBlock: "0.0.0.0/0", IsEgress: true}},
+		{name: "anywhere", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 389, ToPort: 389, CidrBlock: "0.0.0.0/0"},
+			wantReason: "ingress from 0.0.0.0/0, outside the VPC CIDR blocks"},
+		{name: "supernet of the VPC", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 389, ToPort: 389, CidrBlock: "10.0.0.0/8"},
+			wantReason: "ingress from 10.0.0.0/8, outside the VPC CIDR blocks"},
+		{name: "IPv6", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 389, ToPort: 389, Ipv6CidrBlock: "::/0"},
+			wantReason: "ingress from IPv6 block ::/0"},
+		{name: "prefix list", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 389, ToPort: 389, PrefixListID: "pl-0corp"},
+			wantReason: "ingress from prefix list pl-0corp"},
+		{name: "group in another VPC", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 389, ToPort: 389, GroupID: "sg-0other"},
+			wantReason: "ingress from security group sg-0other outside vpc-0a1"},
+		{name: "group outside the scan", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 389, ToPort: 389, GroupID: "sg-0gone"},
+			wantReason: "ingress from security group sg-0gone outside vpc-0a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sg := vpc.SecurityGroupInfo{GroupID: "sg-0connector", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{tt.rule}}
+			findings := AnalyzeDirectorySecurityGroups([]directory.DirectoryInfo{connector}, []vpc.SecurityGroupInfo{sg, peer, foreign}, vpcs)
+			if tt.wantReason == "" {
+				if len(findings) != 0 {
+					t.Errorf("findings = %+v, want none", findings)
+				}
+				return
+			}
+			want := []DirectoryFinding{{DirectoryID: "d-0connector", DirectoryName: "corp.example.com", VpcID: "vpc-0a1",
+				SecurityGroupID: "sg-0connector", Rule: describeRule(tt.rule), Classification: DirectoryWidenedSecurityGroup, Reason: tt.wantReason}}
+			if !reflect.DeepEqual(findings, want) {
+				t.Errorf("findings = %+v\nwant %+v", findings, want)
+			}
+		})
+	}
+}
+
+// TestDirectoryOutsideScan checks that directories whose VPC or group is missing are flagged once, ahead of
+// their rules, and directories without a security group are skipped
+func TestDirectoryOutsideScan(t *testing.T) {
+	directories := []directory.DirectoryInfo{
+		{DirectoryID: "d-0managed", Name: "vdi.example.com", VpcID: "vpc-0gone", SecurityGroupID: "sg-0managed"},
+		{DirectoryID: "d-0nogroup", VpcID: "vpc-0a1", SecurityGroupID: "sg-0deleted"},
+		{DirectoryID: "d-0creating", Stage: "Creating"},
+	}
+	groups := []vpc.SecurityGroupInfo{{GroupID: "sg-0managed", VpcID: "vpc-0gone", Rules: []vpc.SecurityGroupRule{{IpProtocol: "-1", CidrBlock: "0.0.0.0/0"}}}}
+	vpcs := []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16"}}
+
+	want := []DirectoryFinding{
+		{DirectoryID: "d-0managed", DirectoryName: "vdi.example.com", VpcID: "vpc-0gone", SecurityGroupID: "sg-0managed",
+			Classification: DirectoryVPCNotScanned, Reason: "VPC vpc-0gone or security group sg-0managed was not found in the scan"},
+		{DirectoryID: "d-0nogroup", VpcID: "vpc-0a1", SecurityGroupID: "sg-0deleted",
+			Classification: DirectoryVPCNotScanned, Reason: "VPC vpc-0a1 or security group sg-0deleted was not found in the scan"},
+	}
+	if got := AnalyzeDirectorySecurityGroups(directories, groups, vpcs); !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %+v\nwant %+v", got, want)
+	}
+	if got := AnalyzeDirectorySecurityGroups(nil, nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("findings without directories = %#v, want an empty list", got)
+	}
+}
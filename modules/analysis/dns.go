@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// Private DNS finding classes
+const (
+	DNSOutboundWithoutPrivateZone = "outbound-resolver-without-private-zone" // A VPC forwards DNS out through a Resolver endpoint but resolves no private zone
+	DNSCustomServerNotInSubnet    = "custom-dns-server-not-in-subnet"        // DHCP options hand out a DNS server address outside every scanned subnet
+)
+
+// SeverityMedium marks findings that are usually a misconfiguration but do not break traffic on their own
+const SeverityMedium = "medium"
+
+// DNSFinding describes a problem with the private DNS setup of a VPC
+type DNSFinding struct {
+	VpcID               string   `json:"vpc_id"`                // ID of the VPC
+	ResolverEndpointIDs []string `json:"resolver_endpoint_ids"` // Outbound Resolver endpoints in the VPC
+	DNSServers          []string `json:"dns_servers"`           // Custom DNS servers not found in any scanned subnet
+	Classification      string   `json:"classification"`        // outbound-resolver-without-private-zone or custom-dns-server-not-in-subnet
+	Severity            string   `json:"severity"`              // Always medium
+	Reason              string   `json:"reason"`                // Human-readable explanation
+}
+
+// AnalyzePrivateDNS flags VPCs that contain an outbound Resolver endpoint but have no private hosted zone association
+// A VPC hosting outbound endpoints is normally the shared DNS hub, which resolves the private zones
+// for the spokes; without any association its own workloads and the forwarding rules see none of them.
+// vpcs: VPCs from the scan
+// report: Private zones and Resolver endpoints from dns.BuildReport
+// Returns: Findings in VPC order
+func AnalyzePrivateDNS(vpcs []vpc.VPCInfo, report *dns.Report) []DNSFinding {
+	findings := []DNSFinding{}
+
+	for _, v := range vpcs {
+		if len(report.ZonesByVPC[v.VpcID]) > 0 {
+			continue
+		}
+		var endpointIDs []string
+		for _, endpoint := range report.ResolverEndpoints {
+			if endpoint.VpcID == v.VpcID && endpoint.Direction == dns.DirectionOutbound {
+				endpointIDs = append(endpointIDs, endpoint.ResolverEndpointID)
+			}
+		}
+		if len(endpointIDs) == 0 {
+			continue
+		}
+
+		findings = append(findings, DNSFinding{
+			VpcID:               v.VpcID,
+			ResolverEndpointIDs: endpointIDs,
+			DNSServers:          []string{},
+			Classification:      DNSOutboundWithoutPrivateZone,
+			Severity:            SeverityMedium,
+			Reason: fmt.Sprintf("VPC %s has outbound Resolver endpoint(s) %s but no private hosted zone association",
+				v.VpcID, strings.Join(endpointIDs, ", ")),
+		})
+	}
+
+	return findings
+}
+
+// AnalyzeCustomDNSServers flags VPCs whose DHCP options hand out DNS server addresses that are in no scanned subnet
+// Custom servers are normally domain controllers or resolvers inside the network; an address
+// outside every subnet of the scan is most likely a decommissioned server. Servers given as
+// names rather than addresses, and the Amazon link-local resolver, are not checked.
+// vpcs: VPCs with EffectiveDNS set by AddEffectiveDNS (others are skipped)
+// subnets: Subnets from the scan
+// Returns: Findings in VPC order
+func AnalyzeCustomDNSServers(vpcs []vpc.VPCInfo, subnets []vpc.SubnetInfo) []DNSFinding {
+	findings := []DNSFinding{}
+
+	for _, v := range vpcs {
+		if v.EffectiveDNS == nil {
+			continue
+		}
+		missing := []string{}
+		for _, server := range v.EffectiveDNS.CustomServers {
+			ip := net.ParseIP(server)
+			if ip == nil || ip.To4() == nil || server == "169.254.169.253" {
+				continue
+			}
+			found := false
+			for _, subnet := range subnets {
+				if contains, err := netcalc.CIDRContains(subnet.CidrBlock, server+"/32"); err == nil && contains {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, server)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		findings = append(findings, DNSFinding{
+			VpcID:               v.VpcID,
+			ResolverEndpointIDs: []string{},
+			DNSServers:          missing,
+			Classification:      DNSCustomServerNotInSubnet,
+			Severity:            SeverityMedium,
+			Reason: fmt.Sprintf("DHCP options %s of VPC %s hand out DNS server(s) %s, which are in no scanned subnet (likely decommissioned)",
+				v.EffectiveDNS.DhcpOptionsID, v.VpcID, strings.Join(missing, ", ")),
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzePrivateDNS checks that only VPCs with an outbound Resolver endpoint and no private zone are flagged
+func TestAnalyzePrivateDNS(t *testing.T) {
+	vpcs := []vpc.VPCInfo{{VpcID: "vpc-0hub"}, {VpcID: "vpc-0zoned"}, {VpcID: "vpc-0inbound"}, {VpcID: "vpc-0plain"}}
+	report := dns.BuildReport(
+		[]dns.PrivateZoneInfo{{Name: "corp.internal.", VPCs: []dns.ZoneAssociation{{VpcID: "vpc-0zoned", VpcRegion: "eu-west-1"}}}},
+		[]dns.ResolverEndpointInfo{
+			{ResolverEndpointID: "rslvr-out-0a1", Direction: dns.DirectionOutbound, VpcID: "vpc-0hub"},
+			{ResolverEndpointID: "rslvr-out-0b2", Direction: dns.DirectionOutbound, VpcID: "vpc-0hub"},
+			{ResolverEndpointID: "rslvr-out-0c3", Direction: dns.DirectionOutbound, VpcID: "vpc-0zoned"},
+			{ResolverEndpointID: "rslvr-in-0d4", Direction: dns.DirectionInbound, VpcID: "vpc-0inbound"},
+		})
+
+	want := []DNSFinding{{
+		VpcID:               "vpc-0hub",
+		ResolverEndpointIDs: []string{"rslvr-out-0a1", "rslvr-out-0b2"},
+		DNSServers:          []string{},
+		Classification:      DNSOutboundWithoutPrivateZone,
+		Severity:            SeverityMedium,
+		Reason:              "VPC vpc-0hub has outbound Resolver endpoint(s) rslvr-out-0a1, rslvr-out-0b2 but no private hosted zone association",
+	}}
+	if got := AnalyzePrivateDNS(vpcs, report); !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzePrivateDNS() = %+v\nwant %+v", got, want)
+	}
+	if got := AnalyzePrivateDNS(vpcs, dns.BuildReport(nil, nil)); got == nil || len(got) != 0 {
+		t.Errorf("findings without endpoints = %#v, want an empty list", got)
+	}
+}
+
+// TestAnalyzeCustomDNSServers checks custom, default and mixed DHCP options against the scanned subnets
+func TestAnalyzeCustomDNSServers(t *testing.T) {
+	subnets := []vpc.SubnetInfo{{SubnetID: "subnet-0dc", VpcID: "vpc-0shared", CidrBlock: "10.9.1.0/24"}}
+	vpcs := []vpc.VPCInfo{
+		{VpcID: "vpc-0custom", EffectiveDNS: &vpc.EffectiveDNS{DhcpOptionsID: "dopt-0custom", CustomServers: []string{"10.9.1.5", "10.9.2.5", "dc1.corp.example.com"}}},
+		{VpcID: "vpc-0default", EffectiveDNS: &vpc.EffectiveDNS{DhcpOptionsID: "default", UsesAmazonDNS: true, CustomServers: []string{}}},
+		{VpcID: "vpc-0mixed", EffectiveDNS: &vpc.EffectiveDNS{DhcpOptionsID: "dopt-0mixed", UsesAmazonDNS: true, CustomServers: []string{"169.254.169.253", "192.168.50.10"}}},
+		{VpcID: "vpc-0found", EffectiveDNS: &vpc.EffectiveDNS{DhcpOptionsID: "dopt-0found", CustomServers: []string{"10.9.1.6"}}},
+		{VpcID: "vpc-0unscanned"},
+	}
+
+	want := []DNSFinding{
+		{VpcID: "vpc-0custom", ResolverEndpointIDs: []string{}, DNSServers: []string{"10.9.2.5"}, Classification: DNSCustomServerNotInSubnet, Severity: SeverityMedium,
+			Reason: "DHCP options dopt-0custom of VPC vpc-0custom hand out DNS server(s) 10.9.2.5, which are in no scanned subnet (likely decommissioned)"},
+		{VpcID: "vpc-0mixed", ResolverEndpointIDs: []string{}, DNSServers: []string{"192.168.50.10"}, Classification: DNSCustomServerNotInSubnet, Severity: SeverityMedium,
+			Reason: "DHCP options dopt-0mixed of VPC vpc-0mixed hand out DNS server(s) 192.168.50.10, which are in no scanned subnet (likely decommissioned)"},
+	}
+	if got := AnalyzeCustomDNSServers(vpcs, subnets); !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeCustomDNSServers() = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,227 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// MaxExpandedAddresses is the number of interface addresses above which a referenced group is
+// summarized to the CIDR blocks of its interfaces' subnets instead of listing every address
+const MaxExpandedAddresses = 64
+
+// EffectiveSourcesNote labels the expansion as a snapshot of the attachments at scan time
+const EffectiveSourcesNote = "Point-in-time: security group references are expanded to the network interfaces attached at scan time; instances and tasks started later are allowed too."
+
+// GroupExpansion is a referenced security group resolved to the addresses of its network interfaces
+type GroupExpansion struct {
+	GroupID             string           `json:"group_id"`              // ID of the referenced security group
+	VpcID               string           `json:"vpc_id"`                // VPC of the referenced group (another VPC for references across a peering connection; empty if not scanned)
+	OwnerID             string           `json:"owner_id"`              // Account that owns the referenced group
+	Interfaces          int              `json:"interfaces"`            // Network interfaces the group is attached to
+	CIDRs               []string         `json:"cidrs"`                 // Addresses of those interfaces, summarized to CIDR blocks
+	SummarizedToSubnets bool             `json:"summarized_to_subnets"` // Whether the interfaces had more than MaxExpandedAddresses addresses and CIDRs lists their subnets instead
+	Note                string           `json:"note"`                  // Why the expansion is empty or incomplete (empty if it is complete)
+	Upstream            []GroupExpansion `json:"upstream"`              // Groups the referenced group allows ingress from, expanded one level (empty below the first level)
+}
+
+// EffectiveSources is the expanded source set of the ingress rules of one protocol and port range
+type EffectiveSources struct {
+	Protocol         string            `json:"protocol"`              // Normalized protocol (-1 for all)
+	FromPort         int32             `json:"from_port"`             // Start of the port range (or ICMP type)
+	ToPort           int32             `json:"to_port"`               // End of the port range (or ICMP code)
+	CIDRs            []string          `json:"cidrs"`                 // Every address allowed directly or through a referenced group, summarized to CIDR blocks
+	PrefixLists      []string          `json:"prefix_lists"`          // Prefix lists allowed (not expanded)
+	ReferencedGroups []GroupExpansion  `json:"referenced_groups"`     // Referenced groups with the addresses they contribute
+	CIDRLabels       map[string]string `json:"cidr_labels,omitempty"` // Named range of each block of CIDRs that falls within one (set with -named-ranges)
+}
+
+// GroupEffectiveSources lists the effective sources of one security group per protocol and port range
+type GroupEffectiveSources struct {
+	GroupID   string             `json:"group_id"`   // ID of the security group
+	GroupName string             `json:"group_name"` // Name of the security group
+	VpcID     string             `json:"vpc_id"`     // ID of the VPC of the security group
+	Ports     []EffectiveSources `json:"ports"`      // Sources per protocol and port range, in rule order
+}
+
+// EffectiveSourcesReport contains the expanded ingress sources of every security group
+type EffectiveSourcesReport struct {
+	Note   string                  `json:"note"`              // EffectiveSourcesNote
+	Groups []GroupEffectiveSources `json:"effective_sources"` // Groups with ingress rules, sorted by VPC and group ID
+}
+
+// portKey identifies the rules of one protocol and port range
+type portKey struct {
+	protocol string
+	from, to int32
+}
+
+// sourceExpander resolves security groups to the addresses of their network interfaces
+type sourceExpander struct {
+	groups     map[string]*vpc.SecurityGroupInfo
+	interfaces map[string][]vpc.InterfaceGroupsInfo // Interfaces by attached group ID
+	subnets    map[string]string                    // Subnet CIDR blocks by subnet ID
+}
+
+// AnalyzeEffectiveSources expands the ingress rules of every security group into the addresses that can connect
+// Rules are grouped by protocol and port range. CIDR sources are taken as they are; a referenced
+// group contributes the private addresses of the network interfaces it is attached to, or the CIDR
+// blocks of their subnets when there are more than MaxExpandedAddresses addresses. Each referenced
+// group is expanded one level further into the groups it allows ingress from itself ("upstream"),
+// skipping groups already on the chain, so reference cycles end. Upstream addresses are not part of
+// the CIDRs of the port range: they can reach the referenced group's interfaces, not the group itself.
+// securityGroups: Security groups from the scan
+// subnets: Subnets from the scan, for summarizing large groups
+// interfaces: Security groups and addresses per network interface
+// Returns: Report with the effective sources of every group that has ingress rules
+func AnalyzeEffectiveSources(securityGroups []vpc.SecurityGroupInfo, subnets []vpc.SubnetInfo, interfaces []vpc.InterfaceGroupsInfo) *EffectiveSourcesReport {
+	report := &EffectiveSourcesReport{
+		Note:   EffectiveSourcesNote,
+		Groups: []GroupEffectiveSources{},
+	}
+
+	e := &sourceExpander{
+		groups:     make(map[string]*vpc.SecurityGroupInfo, len(securityGroups)),
+		interfaces: make(map[string][]vpc.InterfaceGroupsInfo),
+		subnets:    make(map[string]string, len(subnets)),
+	}
+	for i := range securityGroups {
+		e.groups[securityGroups[i].GroupID] = &securityGroups[i]
+	}
+	for _, eni := range interfaces {
+		for _, groupID := range eni.GroupIDs {
+			e.interfaces[groupID] = append(e.interfaces[groupID], eni)
+		}
+	}
+	for _, subnet := range subnets {
+		e.subnets[subnet.SubnetID] = subnet.CidrBlock
+	}
+
+	for i := range securityGroups {
+		sg := &securityGroups[i]
+		var keys []portKey
+		byPort := make(map[portKey]*EffectiveSources)
+		addresses := make(map[portKey][]string)
+		for _, rule := range sg.Rules {
+			if rule.IsEgress {
+				continue
+			}
+			key := portKey{netcalc.NormalizeProtocol(rule.IpProtocol), rule.FromPort, rule.ToPort}
+			sources := byPort[key]
+			if sources == nil {
+				sources = &EffectiveSources{Protocol: key.protocol, FromPort: key.from, ToPort: key.to,
+					CIDRs: []string{}, PrefixLists: []string{}, ReferencedGroups: []GroupExpansion{}}
+				byPort[key] = sources
+				keys = append(keys, key)
+			}
+
+			switch {
+			case rule.GroupID != "":
+				expansion := e.expand(sg, rule, map[string]bool{sg.GroupID: true}, true)
+				sources.ReferencedGroups = append(sources.ReferencedGroups, expansion)
+				addresses[key] = append(addresses[key], expansion.CIDRs...)
+			case rule.PrefixListID != "":
+				sources.PrefixLists = append(sources.PrefixLists, rule.PrefixListID)
+			case rule.CidrBlock != "":
+				addresses[key] = append(addresses[key], rule.CidrBlock)
+			case rule.Ipv6CidrBlock != "":
+				addresses[key] = append(addresses[key], rule.Ipv6CidrBlock)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		group := GroupEffectiveSources{GroupID: sg.GroupID, GroupName: sg.GroupName, VpcID: sg.VpcID, Ports: []EffectiveSources{}}
+		for _, key := range keys {
+			// Addresses come from the API or were summarized already, so they always parse
+			if summarized, err := netcalc.SummarizeCIDRs(addresses[key]); err == nil {
+				byPort[key].CIDRs = summarized
+			}
+			group.Ports = append(group.Ports, *byPort[key])
+		}
+		report.Groups = append(report.Groups, group)
+	}
+
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		if report.Groups[i].VpcID != report.Groups[j].VpcID {
+			return report.Groups[i].VpcID < report.Groups[j].VpcID
+		}
+		return report.Groups[i].GroupID < report.Groups[j].GroupID
+	})
+	return report
+}
+
+// expand resolves the group a rule references
+// from: Group the rule belongs to
+// chain: Groups already being expanded, which upstream references skip
+// upstream: Whether to expand the groups the referenced group allows ingress from
+func (e *sourceExpander) expand(from *vpc.SecurityGroupInfo, rule vpc.SecurityGroupRule, chain map[string]bool, upstream bool) GroupExpansion {
+	expansion := GroupExpansion{
+		GroupID:  rule.GroupID,
+		VpcID:    rule.GroupVpcID,
+		OwnerID:  rule.GroupOwnerID,
+		CIDRs:    []string{},
+		Upstream: []GroupExpansion{},
+	}
+	referenced := e.groups[rule.GroupID]
+	if referenced != nil {
+		expansion.VpcID, expansion.OwnerID = referenced.VpcID, referenced.OwnerID
+	}
+
+	members := e.interfaces[rule.GroupID]
+	expansion.Interfaces = len(members)
+	var addresses, subnetBlocks []string
+	for _, eni := range members {
+		addresses = append(addresses, eni.PrivateIPs...)
+		block := e.subnets[eni.SubnetID]
+		for _, address := range eni.PrivateIPs {
+			// The subnet block only covers IPv4 addresses; IPv6 addresses and interfaces of
+			// unscanned subnets (peered VPCs) keep their addresses
+			if block != "" && !strings.Contains(address, ":") {
+				subnetBlocks = append(subnetBlocks, block)
+			} else {
+				subnetBlocks = append(subnetBlocks, address)
+			}
+		}
+	}
+	if len(addresses) > MaxExpandedAddresses {
+		addresses = subnetBlocks
+		expansion.SummarizedToSubnets = true
+	}
+	if summarized, err := netcalc.SummarizeCIDRs(addresses); err == nil {
+		expansion.CIDRs = summarized
+	}
+
+	switch {
+	case len(members) > 0:
+	case referenced == nil && rule.GroupOwnerID != "" && rule.GroupOwnerID != from.OwnerID:
+		expansion.Note = fmt.Sprintf("group of account %s; its network interfaces are not visible to this scan", rule.GroupOwnerID)
+	case referenced == nil && rule.GroupVpcID != "" && rule.GroupVpcID != from.VpcID:
+		expansion.Note = fmt.Sprintf("group of %s, which was not scanned; its network interfaces are unknown", rule.GroupVpcID)
+	case referenced == nil:
+		expansion.Note = "group was not found in the scan; its network interfaces are unknown"
+	default:
+		expansion.Note = "no network interfaces are attached; nothing can connect through this reference right now"
+	}
+
+	if !upstream || referenced == nil {
+		return expansion
+	}
+	// Groups on the chain or already expanded are skipped, which also ends reference cycles
+	skip := map[string]bool{rule.GroupID: true}
+	for groupID := range chain {
+		skip[groupID] = true
+	}
+	for _, upstreamRule := range referenced.Rules {
+		if upstreamRule.IsEgress || upstreamRule.GroupID == "" || skip[upstreamRule.GroupID] {
+			continue
+		}
+		skip[upstreamRule.GroupID] = true
+		expansion.Upstream = append(expansion.Upstream, e.expand(referenced, upstreamRule, skip, false))
+	}
+	return expansion
+}
@@ -0,0 +1,200 @@
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// effectiveSourcesFixture is a three-tier application in vpc-0a1:
+//   - sg-0db allows 5432 from sg-0app, from a peered group and from a group of another account
+//   - sg-0app allows 8080 from sg-0alb and from sg-0db, which closes a reference cycle
+//   - sg-0alb allows 443 from anywhere and from sg-0idle, which has no attachments
+func effectiveSourcesFixture() ([]vpc.SecurityGroupInfo, []vpc.SubnetInfo, []vpc.InterfaceGroupsInfo) {
+	ingress := func(port int32, source string) vpc.SecurityGroupRule {
+		rule := vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: port, ToPort: port}
+		if source[0] == 's' {
+			rule.GroupID = source
+		} else {
+			rule.CidrBlock = source
+		}
+		return rule
+	}
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0db", GroupName: "db", VpcID: "vpc-0a1", OwnerID: "111122223333", Rules: []vpc.SecurityGroupRule{
+			ingress(5432, "sg-0app"),
+			{IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0peer", GroupVpcID: "vpc-0peer", VpcPeeringConnectionID: "pcx-0a1"},
+			{IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0audit", GroupOwnerID: "444455556666"},
+			{IpProtocol: "-1", CidrBlock: "0.0.0.0/0", IsEgress: true},
+		}},
+		{GroupID: "sg-0app", GroupName: "app", VpcID: "vpc-0a1", OwnerID: "111122223333", Rules: []vpc.SecurityGroupRule{
+			ingress(8080, "sg-0alb"),
+			ingress(8080, "sg-0db"),
+			ingress(22, "10.0.9.0/24"),
+			{IpProtocol: "tcp", FromPort: 22, ToPort: 22, PrefixListID: "pl-0vpn"},
+		}},
+		{GroupID: "sg-0alb", GroupName: "alb", VpcID: "vpc-0a1", OwnerID: "111122223333", Rules: []vpc.SecurityGroupRule{
+			ingress(443, "0.0.0.0/0"),
+			ingress(443, "sg-0idle"),
+		}},
+		{GroupID: "sg-0idle", GroupName: "idle", VpcID: "vpc-0a1", OwnerID: "111122223333"},
+		{GroupID: "sg-0egress", VpcID: "vpc-0a1", OwnerID: "111122223333", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "-1", CidrBlock: "0.0.0.0/0", IsEgress: true},
+		}},
+	}
+	subnets := []vpc.SubnetInfo{
+		{SubnetID: "subnet-0app", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24"},
+		{SubnetID: "subnet-0alb", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24"},
+	}
+	interfaces := []vpc.InterfaceGroupsInfo{
+		{NetworkInterfaceID: "eni-0app1", SubnetID: "subnet-0app", PrivateIPs: []string{"10.0.1.10"}, GroupIDs: []string{"sg-0app"}},
+		{NetworkInterfaceID: "eni-0app2", SubnetID: "subnet-0app", PrivateIPs: []string{"10.0.1.11"}, GroupIDs: []string{"sg-0app", "sg-0ops"}},
+		{NetworkInterfaceID: "eni-0alb", SubnetID: "subnet-0alb", PrivateIPs: []string{"10.0.2.40", "10.0.2.41", "10.0.2.42", "10.0.2.43"}, GroupIDs: []string{"sg-0alb"}},
+		{NetworkInterfaceID: "eni-0db", SubnetID: "subnet-0app", PrivateIPs: []string{"10.0.1.50"}, GroupIDs: []string{"sg-0db"}},
+		{NetworkInterfaceID: "eni-0peer", VpcID: "vpc-0peer", SubnetID: "subnet-0peer", PrivateIPs: []string{"10.9.1.7"}, GroupIDs: []string{"sg-0peer"}},
+	}
+	return groups, subnets, interfaces
+}
+
+// group returns the effective sources of a group from a report
+func (r *EffectiveSourcesReport) group(t *testing.T, groupID string) GroupEffectiveSources {
+	t.Helper()
+	for _, group := range r.Groups {
+		if group.GroupID == groupID {
+			return group
+		}
+	}
+	t.Fatalf("no effective sources for %s", groupID)
+	return GroupEffectiveSources{}
+}
+
+// TestEffectiveSourcesChain expands the reference chain of the fixture: the database group reaches the
+// application servers directly and the load balancers one level upstream, and the cycle back to the
+// database group ends
+func TestEffectiveSourcesChain(t *testing.T) {
+	report := AnalyzeEffectiveSources(effectiveSourcesFixture())
+	if report.Note != EffectiveSourcesNote {
+		t.Errorf("Note = %q, want the point-in-time label", report.Note)
+	}
+	var order []string
+	for _, group := range report.Groups {
+		order = append(order, group.GroupID)
+	}
+	if want := []string{"sg-0alb", "sg-0app", "sg-0db"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("groups %q, want %q: groups without ingress rules are left out", order, want)
+	}
+
+	idle := GroupExpansion{GroupID: "sg-0idle", VpcID: "vpc-0a1", OwnerID: "111122223333", CIDRs: []string{}, Upstream: []GroupExpansion{},
+		Note: "no network interfaces are attached; nothing can connect through this reference right now"}
+	alb := GroupExpansion{GroupID: "sg-0alb", VpcID: "vpc-0a1", OwnerID: "111122223333", Interfaces: 1,
+		CIDRs: []string{"10.0.2.40/30"}, Upstream: []GroupExpansion{}}
+	want := []EffectiveSources{{Protocol: "tcp", FromPort: 5432, ToPort: 5432,
+		CIDRs:       []string{"10.0.1.10/31", "10.9.1.7/32"},
+		PrefixLists: []string{},
+		ReferencedGroups: []GroupExpansion{
+			{GroupID: "sg-0app", VpcID: "vpc-0a1", OwnerID: "111122223333", Interfaces: 2, CIDRs: []string{"10.0.1.10/31"},
+				Upstream: []GroupExpansion{alb}},
+			{GroupID: "sg-0peer", VpcID: "vpc-0peer", Interfaces: 1, CIDRs: []string{"10.9.1.7/32"}, Upstream: []GroupExpansion{}},
+			{GroupID: "sg-0audit", OwnerID: "444455556666", CIDRs: []string{}, Upstream: []GroupExpansion{},
+				Note: "group of account 444455556666; its network interfaces are not visible to this scan"},
+		},
+	}}
+	if got := report.group(t, "sg-0db"); !reflect.DeepEqual(got.Ports, want) || got.GroupName != "db" || got.VpcID != "vpc-0a1" {
+		t.Errorf("sg-0db = %+v\nwant ports %+v", got, want)
+	}
+
+	want = []EffectiveSources{
+		{Protocol: "tcp", FromPort: 8080, ToPort: 8080, CIDRs: []string{"10.0.1.50/32", "10.0.2.40/30"}, PrefixLists: []string{},
+			ReferencedGroups: []GroupExpansion{
+				{GroupID: "sg-0alb", VpcID: "vpc-0a1", OwnerID: "111122223333", Interfaces: 1, CIDRs: []string{"10.0.2.40/30"}, Upstream: []GroupExpansion{idle}},
+				{GroupID: "sg-0db", VpcID: "vpc-0a1", OwnerID: "111122223333", Interfaces: 1, CIDRs: []string{"10.0.1.50/32"}, Upstream: []GroupExpansion{
+					{GroupID: "sg-0peer", VpcID: "vpc-0peer", Interfaces: 1, CIDRs: []string{"10.9.1.7/32"}, Upstream: []GroupExpansion{}},
+					{GroupID: "sg-0audit", OwnerID: "444455556666", CIDRs: []string{}, Upstream: []GroupExpansion{},
+						Note: "group of account 444455556666; its network interfaces are not visible to this scan"},
+				}},
+			}},
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDRs: []string{"10.0.9.0/24"}, PrefixLists: []string{"pl-0vpn"}, ReferencedGroups: []GroupExpansion{}},
+	}
+	if got := report.group(t, "sg-0app").Ports; !reflect.DeepEqual(got, want) {
+		t.Errorf("sg-0app ports = %+v\nwant %+v", got, want)
+	}
+
+	want = []EffectiveSources{{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDRs: []string{"0.0.0.0/0"}, PrefixLists: []string{},
+		ReferencedGroups: []GroupExpansion{idle}}}
+	if got := report.group(t, "sg-0alb").Ports; !reflect.DeepEqual(got, want) {
+		t.Errorf("sg-0alb ports = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestEffectiveSourcesNotes covers the notes of referenced groups whose interfaces are unknown
+func TestEffectiveSourcesNotes(t *testing.T) {
+	tests := []struct {
+		name string
+		rule vpc.SecurityGroupRule
+		want string
+	}{
+		{name: "other account", rule: vpc.SecurityGroupRule{GroupID: "sg-0partner", GroupOwnerID: "444455556666"},
+			want: "group of account 444455556666; its network interfaces are not visible to this scan"},
+		{name: "same account", rule: vpc.SecurityGroupRule{GroupID: "sg-0gone", GroupOwnerID: "111122223333"},
+			want: "group was not found in the scan; its network interfaces are unknown"},
+		{name: "unscanned VPC", rule: vpc.SecurityGroupRule{GroupID: "sg-0peer", GroupVpcID: "vpc-0peer"},
+			want: "group of vpc-0peer, which was not scanned; its network interfaces are unknown"},
+		{name: "not scanned", rule: vpc.SecurityGroupRule{GroupID: "sg-0gone"},
+			want: "group was not found in the scan; its network interfaces are unknown"},
+		{name: "no attachments", rule: vpc.SecurityGroupRule{GroupID: "sg-0idle"},
+			want: "no network interfaces are attached; nothing can connect through this reference right now"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.IpProtocol = "tcp"
+			groups := []vpc.SecurityGroupInfo{
+				{GroupID: "sg-0web", VpcID: "vpc-0a1", OwnerID: "111122223333", Rules: []vpc.SecurityGroupRule{tt.rule}},
+				{GroupID: "sg-0idle", VpcID: "vpc-0a1", OwnerID: "111122223333"},
+			}
+			sources := AnalyzeEffectiveSources(groups, nil, nil).group(t, "sg-0web").Ports[0]
+			if len(sources.ReferencedGroups) != 1 || sources.ReferencedGroups[0].Note != tt.want || len(sources.CIDRs) != 0 {
+				t.Errorf("sources = %+v, want one empty expansion noted %q", sources, tt.want)
+			}
+		})
+	}
+}
+
+// TestEffectiveSourcesSummarizedToSubnets checks that a group with more than MaxExpandedAddresses addresses
+// is summarized to its subnets, keeping the IPv6 addresses and the interfaces of unscanned subnets
+func TestEffectiveSourcesSummarizedToSubnets(t *testing.T) {
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0db", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0tasks"}}},
+	}
+	subnets := []vpc.SubnetInfo{{SubnetID: "subnet-0a1", CidrBlock: "10.0.0.0/20"}, {SubnetID: "subnet-0b2", CidrBlock: "10.0.16.0/20"}}
+	var interfaces []vpc.InterfaceGroupsInfo
+	for i := 0; i < MaxExpandedAddresses; i++ {
+		subnetID := []string{"subnet-0a1", "subnet-0b2"}[i%2]
+		interfaces = append(interfaces, vpc.InterfaceGroupsInfo{NetworkInterfaceID: fmt.Sprintf("eni-%03d", i), SubnetID: subnetID,
+			PrivateIPs: []string{fmt.Sprintf("10.0.%d.%d", i%2*16, i+4)}, GroupIDs: []string{"sg-0tasks"}})
+	}
+	interfaces[0].PrivateIPs = append(interfaces[0].PrivateIPs, "2001:db8::4")
+
+	sources := AnalyzeEffectiveSources(groups, subnets, interfaces).Groups[0].Ports[0]
+	expansion := sources.ReferencedGroups[0]
+	if want := []string{"10.0.0.0/19", "2001:db8::4/128"}; !reflect.DeepEqual(expansion.CIDRs, want) || !expansion.SummarizedToSubnets {
+		t.Errorf("expansion = %+v, want %q summarized to subnets", expansion, want)
+	}
+	if expansion.Interfaces != MaxExpandedAddresses || !reflect.DeepEqual(sources.CIDRs, expansion.CIDRs) {
+		t.Errorf("sources = %+v, want %d interfaces and the expansion's CIDRs", sources, MaxExpandedAddresses)
+	}
+
+	interfaces = append(interfaces, vpc.InterfaceGroupsInfo{NetworkInterfaceID: "eni-0peer", SubnetID: "subnet-0peer",
+		PrivateIPs: []string{"10.9.1.7"}, GroupIDs: []string{"sg-0tasks"}})
+	expansion = AnalyzeEffectiveSources(groups, subnets, interfaces).Groups[0].Ports[0].ReferencedGroups[0]
+	if want := []string{"10.0.0.0/19", "10.9.1.7/32", "2001:db8::4/128"}; !reflect.DeepEqual(expansion.CIDRs, want) {
+		t.Errorf("CIDRs with an unscanned subnet = %q, want %q", expansion.CIDRs, want)
+	}
+
+	interfaces = interfaces[:MaxExpandedAddresses-1]
+	expansion = AnalyzeEffectiveSources(groups, subnets, interfaces).Groups[0].Ports[0].ReferencedGroups[0]
+	if expansion.SummarizedToSubnets || len(expansion.CIDRs) < 2 {
+		t.Errorf("expansion of %d addresses = %+v, want the addresses themselves", MaxExpandedAddresses, expansion)
+	}
+}
@@ -0,0 +1,439 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// Egress profiles of a VPC, from the most to the least restrictive
+const (
+	EgressNoEgress      = "no-egress"      // Nothing can reach the internet or AWS services outside the VPC
+	EgressEndpointsOnly = "endpoints-only" // AWS services are reached through VPC endpoints; the security groups allow no internet egress
+	EgressProxyOnly     = "proxy-only"     // The security groups send outbound traffic to a proxy, which alone reaches the internet
+	EgressUnrestricted  = "unrestricted"   // The security groups allow direct internet egress through the VPC's default routes
+)
+
+// Egress patterns of a security group or rule, from the least to the most permissive
+const (
+	EgressPatternInternal = "internal" // Private ranges, other security groups and prefix lists only
+	EgressPatternProxy    = "proxy"    // A proxy security group, or a specific host or network on a proxy port
+	EgressPatternDirect   = "direct"   // Internet addresses
+)
+
+// EgressProfileTag is the VPC tag that declares the egress profile a VPC must have at most
+const EgressProfileTag = "aws-documentor:egress-profile"
+
+// Egress finding classes
+const (
+	EgressProfileExceeded  = "egress-profile-exceeded"  // A VPC's profile is less restrictive than its egress-profile tag allows
+	EgressPatternViolation = "egress-pattern-violation" // A security group allows more than the dominant pattern of its VPC
+	EgressDirectSMTP       = "direct-smtp-egress"       // A security group opens SMTP straight to the internet
+	EgressInvalidTag       = "egress-profile-tag-invalid"
+)
+
+// smtpPorts are the SMTP submission and relay ports
+var smtpPorts = []int32{25, 465, 587}
+
+// EgressOptions are the heuristics of the egress profile analysis
+type EgressOptions struct {
+	ProxyPorts    []int32  // Ports of the forward proxies (3128, 8080, ...)
+	ProxyTagKey   string   // Tag key of the proxy security groups
+	ProxyTagValue string   // Value of that tag ("" for any value)
+	PrivateRanges []string // Ranges that are not the internet, DefaultPrivateRanges plus any corporate ranges
+}
+
+// DefaultEgressOptions returns the heuristics used without -proxy-ports and -proxy-sg-tag
+func DefaultEgressOptions() EgressOptions {
+	return EgressOptions{
+		ProxyPorts:    []int32{3128, 8080},
+		ProxyTagKey:   "aws-documentor:egress-role",
+		ProxyTagValue: "proxy",
+		PrivateRanges: DefaultPrivateRanges,
+	}
+}
+
+// EgressViolation is a security group that allows more than the dominant pattern of its VPC
+type EgressViolation struct {
+	GroupID   string `json:"group_id"`   // ID of the security group
+	GroupName string `json:"group_name"` // Name of the security group
+	Pattern   string `json:"pattern"`    // Egress pattern of the group: direct or proxy
+	Rule      string `json:"rule"`       // The most permissive egress rule ("tcp/443 -> 0.0.0.0/0")
+}
+
+// EgressProfile is the outbound behavior of a VPC
+type EgressProfile struct {
+	VpcID         string            `json:"vpc_id"`         // ID of the VPC
+	Profile       string            `json:"profile"`        // no-egress, endpoints-only, proxy-only or unrestricted
+	Summary       string            `json:"summary"`        // The profile with its path, such as "restricted to proxy sg-1 on 3128"
+	Intent        string            `json:"intent"`         // Value of the aws-documentor:egress-profile tag (empty if untagged)
+	EgressPaths   []string          `json:"egress_paths"`   // Default routes out of the VPC ("rtb-1 0.0.0.0/0 -> nat-1")
+	ChokePoints   []string          `json:"choke_points"`   // Resources outbound traffic funnels through: gateways, appliances, proxy groups, SES endpoints
+	ProxyGroups   []string          `json:"proxy_groups"`   // Security groups tagged as proxies, which may egress directly
+	PatternCounts map[string]int    `json:"pattern_counts"` // Other security groups per egress pattern
+	Endpoints     int               `json:"endpoints"`      // VPC endpoints of the VPC
+	Violations    []EgressViolation `json:"violations"`     // Security groups allowing more than the dominant pattern
+	SMTPRules     []string          `json:"smtp_rules"`     // Egress rules opening SMTP straight to the internet ("sg-1 tcp/25 -> 0.0.0.0/0")
+}
+
+// EgressFinding is a VPC or security group whose egress contradicts the VPC's tag or dominant pattern
+type EgressFinding struct {
+	VpcID          string `json:"vpc_id"`         // ID of the VPC
+	GroupID        string `json:"group_id"`       // ID of the security group (empty for findings about the whole VPC)
+	Profile        string `json:"profile"`        // Egress profile of the VPC
+	Intent         string `json:"intent"`         // Value of the aws-documentor:egress-profile tag
+	Classification string `json:"classification"` // egress-profile-exceeded, egress-pattern-violation, direct-smtp-egress or egress-profile-tag-invalid
+	Severity       string `json:"severity"`       // high for an exceeded profile, medium for violations and SMTP, low for invalid tags
+	Reason         string `json:"reason"`         // Human-readable explanation
+}
+
+// EgressReport contains the egress profile of every VPC and the findings
+type EgressReport struct {
+	VPCs     []EgressProfile `json:"vpcs"`     // One entry per VPC, by VPC ID
+	Findings []EgressFinding `json:"findings"` // Exceeded profiles, violations of the dominant pattern and direct SMTP
+}
+
+// egressRanks orders the profiles from the most to the least restrictive
+var egressRanks = map[string]int{EgressNoEgress: 0, EgressEndpointsOnly: 1, EgressProxyOnly: 2, EgressUnrestricted: 3}
+
+// patternRanks orders the egress patterns from the least to the most permissive
+var patternRanks = map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 1, EgressPatternDirect: 2}
+
+// AnalyzeEgressProfiles classifies the outbound behavior of every VPC
+// The routes decide whether anything can leave: a default route to an internet gateway, NAT gateway,
+// appliance (instance, network interface, Gateway Load Balancer endpoint) or hub (transit gateway,
+// Cloud WAN, VPN gateway) is an egress path. The security groups then decide how. Each egress rule
+// is direct when its destination is outside the private ranges, proxy when it targets a proxy security
+// group or a specific host or network on proxy ports only, and internal otherwise; a group takes the
+// pattern of its most permissive rule. Proxy groups may egress directly and are left out. The pattern
+// most groups share is the dominant one, the stricter on a tie: direct makes the VPC unrestricted,
+// proxy makes it proxy-only, and internal makes it endpoints-only when it has VPC endpoints. Without
+// an egress path the VPC is endpoints-only or no-egress whatever its groups allow. Groups more
+// permissive than the dominant pattern are listed as violations, and rules opening SMTP ports to the
+// internet as direct mail egress that bypasses a relay or the SES SMTP endpoint.
+// vpcs: VPCs from the VPC scan
+// routeTables: VPC route tables from the VPC scan
+// securityGroups: Security groups from the VPC scan
+// natGateways: NAT gateways, to tell public from private NAT
+// endpoints: VPC endpoints (nil if not scanned)
+// opts: Proxy ports, proxy tag and private ranges
+// Returns: Report with the profile of every VPC and the findings
+func AnalyzeEgressProfiles(vpcs []vpc.VPCInfo, routeTables []vpc.RouteTableInfo, securityGroups []vpc.SecurityGroupInfo, natGateways []vpc.NatGatewayInfo, endpoints []vpc.VpcEndpointInfo, opts EgressOptions) *EgressReport {
+	report := &EgressReport{
+		VPCs:     []EgressProfile{},
+		Findings: []EgressFinding{},
+	}
+
+	privateNAT := make(map[string]bool)
+	for _, ngw := range natGateways {
+		privateNAT[ngw.NatGatewayID] = ngw.ConnectivityType == "private"
+	}
+	tablesByVPC := make(map[string][]vpc.RouteTableInfo)
+	for _, rt := range routeTables {
+		tablesByVPC[rt.VpcID] = append(tablesByVPC[rt.VpcID], rt)
+	}
+	groupsByVPC := make(map[string][]vpc.SecurityGroupInfo)
+	proxyGroups := make(map[string]bool)
+	for _, sg := range securityGroups {
+		groupsByVPC[sg.VpcID] = append(groupsByVPC[sg.VpcID], sg)
+		if value, ok := sg.Tags[opts.ProxyTagKey]; ok && opts.ProxyTagKey != "" && (opts.ProxyTagValue == "" || value == opts.ProxyTagValue) {
+			proxyGroups[sg.GroupID] = true
+		}
+	}
+	endpointsByVPC := make(map[string][]vpc.VpcEndpointInfo)
+	for _, endpoint := range endpoints {
+		endpointsByVPC[endpoint.VpcID] = append(endpointsByVPC[endpoint.VpcID], endpoint)
+	}
+
+	sorted := append([]vpc.VPCInfo{}, vpcs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].VpcID < sorted[j].VpcID })
+
+	for _, v := range sorted {
+		profile := EgressProfile{
+			VpcID:         v.VpcID,
+			Intent:        v.Tags[EgressProfileTag],
+			EgressPaths:   []string{},
+			ChokePoints:   []string{},
+			ProxyGroups:   []string{},
+			PatternCounts: map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 0, EgressPatternDirect: 0},
+			Endpoints:     len(endpointsByVPC[v.VpcID]),
+			Violations:    []EgressViolation{},
+			SMTPRules:     []string{},
+		}
+
+		// Default routes out of the VPC, and the gateways and appliances they lead through
+		seen := make(map[string]bool)
+		var via []string
+		for _, rt := range tablesByVPC[v.VpcID] {
+			for _, route := range rt.Routes {
+				destination := routeDestination(route)
+				if route.State == "blackhole" || (destination != defaultRouteDestination && destination != defaultIpv6Destination) {
+					continue
+				}
+				kind := egressPathKind(route.Target, privateNAT)
+				if kind == "" {
+					continue
+				}
+				profile.EgressPaths = append(profile.EgressPaths, fmt.Sprintf("%s %s -> %s", rt.RouteTableID, destination, route.Target.ID))
+				if !seen[route.Target.ID] {
+					seen[route.Target.ID] = true
+					profile.ChokePoints = append(profile.ChokePoints, route.Target.ID)
+				}
+				if !seen[kind] {
+					seen[kind] = true
+					via = append(via, kind)
+				}
+			}
+		}
+		for _, endpoint := range endpointsByVPC[v.VpcID] {
+			if strings.HasSuffix(endpoint.ServiceName, ".email-smtp") {
+				profile.ChokePoints = append(profile.ChokePoints, endpoint.VpcEndpointID+" (SES SMTP)")
+			}
+		}
+
+		// The egress pattern of every group except the proxies
+		type groupPattern struct {
+			sg      vpc.SecurityGroupInfo
+			pattern string
+			rule    string
+		}
+		var groups []groupPattern
+		proxyPorts := make(map[string]bool)
+		usedProxies := make(map[string]bool)
+		for _, sg := range groupsByVPC[v.VpcID] {
+			if proxyGroups[sg.GroupID] {
+				profile.ProxyGroups = append(profile.ProxyGroups, sg.GroupID)
+				profile.ChokePoints = append(profile.ChokePoints, sg.GroupID+" (proxy)")
+				continue
+			}
+			group := groupPattern{sg: sg, pattern: EgressPatternInternal}
+			for _, rule := range sg.Rules {
+				if !rule.IsEgress {
+					continue
+				}
+				pattern := egressRulePattern(rule, proxyGroups, opts)
+				if pattern == EgressPatternProxy {
+					if rule.GroupID != "" {
+						usedProxies[rule.GroupID] = true
+					}
+					proxyPorts[portRange(rule)] = true
+				}
+				if pattern == EgressPatternDirect && opensAnyPort(rule, smtpPorts) {
+					profile.SMTPRules = append(profile.SMTPRules, sg.GroupID+" "+describeEgressRule(rule))
+				}
+				if patternRanks[pattern] > patternRanks[group.pattern] {
+					group.pattern, group.rule = pattern, describeEgressRule(rule)
+				}
+			}
+			profile.PatternCounts[group.pattern]++
+			groups = append(groups, group)
+		}
+
+		// The pattern most groups share; the stricter one wins a tie
+		dominant := EgressPatternInternal
+		for _, pattern := range []string{EgressPatternProxy, EgressPatternDirect} {
+			if profile.PatternCounts[pattern] > profile.PatternCounts[dominant] {
+				dominant = pattern
+			}
+		}
+
+		switch {
+		case len(profile.EgressPaths) == 0 && profile.Endpoints > 0:
+			profile.Profile = EgressEndpointsOnly
+			profile.Summary = fmt.Sprintf("endpoints only: no default route leaves the VPC, %d VPC endpoint(s)", profile.Endpoints)
+		case len(profile.EgressPaths) == 0:
+			profile.Profile = EgressNoEgress
+			profile.Summary = "no egress: no default route leaves the VPC and it has no VPC endpoints"
+		case dominant == EgressPatternDirect:
+			profile.Profile = EgressUnrestricted
+			profile.Summary = "unrestricted via " + strings.Join(via, ", ")
+		case dominant == EgressPatternProxy:
+			profile.Profile = EgressProxyOnly
+			proxies := sortedKeys(usedProxies)
+			if len(proxies) == 0 {
+				proxies = []string{"hosts"}
+			}
+			profile.Summary = fmt.Sprintf("restricted to proxy %s on %s", strings.Join(proxies, ", "), strings.Join(sortedKeys(proxyPorts), ", "))
+		case profile.Endpoints > 0:
+			profile.Profile = EgressEndpointsOnly
+			profile.Summary = fmt.Sprintf("endpoints only: the security groups allow no internet egress, %d VPC endpoint(s)", profile.Endpoints)
+		default:
+			profile.Profile = EgressNoEgress
+			profile.Summary = "no egress: the security groups allow no internet egress and the VPC has no endpoints"
+		}
+
+		// Without an egress path no group can reach beyond the VPC, whatever it allows
+		if len(profile.EgressPaths) > 0 {
+			for _, group := range groups {
+				if patternRanks[group.pattern] > patternRanks[dominant] {
+					profile.Violations = append(profile.Violations, EgressViolation{
+						GroupID:   group.sg.GroupID,
+						GroupName: group.sg.GroupName,
+						Pattern:   group.pattern,
+						Rule:      group.rule,
+					})
+				}
+			}
+		} else {
+			profile.SMTPRules = []string{}
+		}
+		sort.Slice(profile.Violations, func(i, j int) bool { return profile.Violations[i].GroupID < profile.Violations[j].GroupID })
+		sort.Strings(profile.SMTPRules)
+
+		report.VPCs = append(report.VPCs, profile)
+		report.Findings = append(report.Findings, egressFindings(profile)...)
+	}
+
+	return report
+}
+
+// egressPathKind names the way a default route leaves the VPC, or returns empty if it does not
+func egressPathKind(target vpc.RouteTarget, privateNAT map[string]bool) string {
+	switch target.Type {
+	case vpc.RouteTargetInternetGateway, vpc.RouteTargetEgressOnlyInternetGateway, vpc.RouteTargetCarrierGateway:
+		return "internet gateway"
+	case vpc.RouteTargetNatGateway:
+		if privateNAT[target.ID] {
+			return "private NAT"
+		}
+		return "NAT gateway"
+	case vpc.RouteTargetInstance, vpc.RouteTargetNetworkInterface, vpc.RouteTargetVpcEndpoint:
+		return "appliance"
+	case vpc.RouteTargetTransitGateway, vpc.RouteTargetCoreNetwork, vpc.RouteTargetVpnGateway, vpc.RouteTargetLocalGateway:
+		return "hub"
+	}
+	return ""
+}
+
+// egressRulePattern classifies where an egress rule lets traffic go
+func egressRulePattern(rule vpc.SecurityGroupRule, proxyGroups map[string]bool, opts EgressOptions) string {
+	if rule.GroupID != "" && proxyGroups[rule.GroupID] {
+		return EgressPatternProxy
+	}
+	destination := rule.CidrBlock
+	if destination == "" {
+		destination = rule.Ipv6CidrBlock
+	}
+	if destination == "" {
+		// Security group references and prefix lists stay within the network or reach AWS services
+		return EgressPatternInternal
+	}
+	if destination != defaultRouteDestination && destination != defaultIpv6Destination && onlyPorts(rule, opts.ProxyPorts) {
+		return EgressPatternProxy
+	}
+	for _, private := range opts.PrivateRanges {
+		if within, err := netcalc.CIDRContains(private, destination); err == nil && within {
+			return EgressPatternInternal
+		}
+	}
+	return EgressPatternDirect
+}
+
+// onlyPorts reports whether a rule allows TCP to some of the given ports and nothing else
+func onlyPorts(rule vpc.SecurityGroupRule, ports []int32) bool {
+	if netcalc.NormalizeProtocol(rule.IpProtocol) != netcalc.ProtocolTCP || rule.FromPort < 0 || int(rule.ToPort-rule.FromPort) >= len(ports) {
+		return false
+	}
+	for port := rule.FromPort; port <= rule.ToPort; port++ {
+		found := false
+		for _, p := range ports {
+			found = found || p == port
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// opensAnyPort reports whether a rule opens TCP to any of the given ports on purpose
+// Rules for all protocols or all ports, such as the default egress rule, open them only incidentally;
+// they are what makes the VPC unrestricted and would otherwise repeat that for every group.
+func opensAnyPort(rule vpc.SecurityGroupRule, ports []int32) bool {
+	if netcalc.NormalizeProtocol(rule.IpProtocol) != netcalc.ProtocolTCP || portRange(rule) == "all ports" {
+		return false
+	}
+	for _, port := range ports {
+		if netcalc.PortRangeContains(rule.FromPort, rule.ToPort, port, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// portRange formats the ports of a rule: 3128, 8080-8081, or all
+func portRange(rule vpc.SecurityGroupRule) string {
+	switch {
+	case !netcalc.HasPorts(rule.IpProtocol) || rule.FromPort == -1 || (rule.FromPort <= 0 && rule.ToPort >= 65535):
+		return "all ports"
+	case rule.FromPort == rule.ToPort:
+		return strconv.Itoa(int(rule.FromPort))
+	}
+	return fmt.Sprintf("%d-%d", rule.FromPort, rule.ToPort)
+}
+
+// describeEgressRule formats an egress rule as "tcp/443 -> 0.0.0.0/0"
+func describeEgressRule(rule vpc.SecurityGroupRule) string {
+	destination := rule.CidrBlock
+	for _, candidate := range []string{rule.Ipv6CidrBlock, rule.GroupID, rule.PrefixListID} {
+		if destination == "" {
+			destination = candidate
+		}
+	}
+	protocol := netcalc.NormalizeProtocol(rule.IpProtocol)
+	switch {
+	case protocol == netcalc.ProtocolAll:
+		protocol = "all traffic"
+	case netcalc.HasPorts(rule.IpProtocol):
+		protocol += "/" + portRange(rule)
+	}
+	return protocol + " -> " + destination
+}
+
+// egressFindings checks the profile of a VPC against its tag and its dominant pattern
+func egressFindings(profile EgressProfile) []EgressFinding {
+	var findings []EgressFinding
+	base := EgressFinding{VpcID: profile.VpcID, Profile: profile.Profile, Intent: profile.Intent}
+
+	if profile.Intent != "" {
+		required, known := egressRanks[profile.Intent]
+		switch {
+		case !known:
+			finding := base
+			finding.Classification = EgressInvalidTag
+			finding.Severity = SeverityLow
+			finding.Reason = fmt.Sprintf("%s has %s=%q; expected %s, %s, %s or %s, so its egress is not checked", profile.VpcID, EgressProfileTag, profile.Intent,
+				EgressNoEgress, EgressEndpointsOnly, EgressProxyOnly, EgressUnrestricted)
+			findings = append(findings, finding)
+		case egressRanks[profile.Profile] > required:
+			finding := base
+			finding.Classification = EgressProfileExceeded
+			finding.Severity = SeverityHigh
+			finding.Reason = fmt.Sprintf("%s is tagged %s=%s but is %s", profile.VpcID, EgressProfileTag, profile.Intent, profile.Summary)
+			findings = append(findings, finding)
+		}
+	}
+
+	for _, violation := range profile.Violations {
+		finding := base
+		finding.GroupID = violation.GroupID
+		finding.Classification = EgressPatternViolation
+		finding.Severity = SeverityMedium
+		finding.Reason = fmt.Sprintf("%s (%s) allows %s egress (%s) in a VPC whose other security groups are %s", violation.GroupID, violation.GroupName, violation.Pattern, violation.Rule, profile.Profile)
+		findings = append(findings, finding)
+	}
+
+	for _, rule := range profile.SMTPRules {
+		finding := base
+		finding.GroupID, _, _ = strings.Cut(rule, " ")
+		finding.Classification = EgressDirectSMTP
+		finding.Severity = SeverityMedium
+		finding.Reason = fmt.Sprintf("%s allows SMTP straight to the internet; mail should leave through a relay or the SES SMTP endpoint", rule)
+		findings = append(findings, finding)
+	}
+	return findings
+}
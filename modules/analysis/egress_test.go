@@ -0,0 +1,365 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// egressFixture is one VPC with its route tables, security groups, NAT gateways and endpoints
+type egressFixture struct {
+	tags        map[string]string
+	routes      []vpc.RouteInfo
+	groups      []vpc.SecurityGroupInfo
+	natGateways []vpc.NatGatewayInfo
+	endpoints   []vpc.VpcEndpointInfo
+}
+
+// analyze runs the egress analysis on the fixture as vpc-0a1 with the default options
+func (f egressFixture) analyze(t *testing.T) (EgressProfile, []EgressFinding) {
+	t.Helper()
+	for i := range f.groups {
+		f.groups[i].VpcID = "vpc-0a1"
+	}
+	for i := range f.endpoints {
+		f.endpoints[i].VpcID = "vpc-0a1"
+	}
+	report := AnalyzeEgressProfiles(
+		[]vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", Tags: f.tags}},
+		[]vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", Routes: f.routes}},
+		f.groups, f.natGateways, f.endpoints, DefaultEgressOptions())
+	if len(report.VPCs) != 1 {
+		t.Fatalf("profiles = %+v, want one", report.VPCs)
+	}
+	return report.VPCs[0], report.Findings
+}
+
+// defaultRoute returns a 0.0.0.0/0 route to a target
+func defaultRoute(targetType, id string) vpc.RouteInfo {
+	return vpc.RouteInfo{DestinationCidrBlock: "0.0.0.0/0", State: "active", Target: vpc.RouteTarget{Type: targetType, ID: id}}
+}
+
+// egressGroup returns a security group with egress rules
+func egressGroup(id string, tags map[string]string, rules ...vpc.SecurityGroupRule) vpc.SecurityGroupInfo {
+	for i := range rules {
+		rules[i].IsEgress = true
+	}
+	return vpc.SecurityGroupInfo{GroupID: id, GroupName: strings.TrimPrefix(id, "sg-0"), Tags: tags, Rules: rules}
+}
+
+// Egress rules of the fixtures
+var (
+	allOut       = vpc.SecurityGroupRule{IpProtocol: "-1", FromPort: -1, ToPort: -1, CidrBlock: "0.0.0.0/0"}
+	httpsOut     = vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"}
+	internalOut  = vpc.SecurityGroupRule{IpProtocol: "-1", FromPort: -1, ToPort: -1, CidrBlock: "10.0.0.0/8"}
+	proxySGOut   = vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 3128, ToPort: 3128, GroupID: "sg-0proxy"}
+	proxyHostOut = vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 8080, ToPort: 8080, CidrBlock: "203.0.113.10/32"}
+	proxyTag     = map[string]string{"aws-documentor:egress-role": "proxy"}
+)
+
+// TestEgressProfiles classifies a fixture VPC for every profile and checks its summary, paths and choke points
+func TestEgressProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     egressFixture
+		profile     string
+		summary     string
+		paths       []string
+		chokePoints []string
+		counts      map[string]int
+	}{
+		{
+			name: "unrestricted via NAT",
+			fixture: egressFixture{routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")},
+				groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, allOut), egressGroup("sg-0web", nil, httpsOut)}},
+			profile: EgressUnrestricted, summary: "unrestricted via NAT gateway",
+			paths: []string{"rtb-0a1 0.0.0.0/0 -> nat-0a1"}, chokePoints: []string{"nat-0a1"},
+			counts: map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 0, EgressPatternDirect: 2},
+		},
+		{
+			name: "unrestricted via internet gateway and IPv6",
+			fixture: egressFixture{routes: []vpc.RouteInfo{
+				defaultRoute(vpc.RouteTargetInternetGateway, "igw-0a1"),
+				{DestinationIpv6Block: "::/0", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetEgressOnlyInternetGateway, ID: "eigw-0a1"}},
+				{DestinationCidrBlock: "10.0.0.0/16", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}},
+			}, groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, vpc.SecurityGroupRule{IpProtocol: "-1", Ipv6CidrBlock: "::/0"})}},
+			profile: EgressUnrestricted, summary: "unrestricted via internet gateway",
+			paths:       []string{"rtb-0a1 0.0.0.0/0 -> igw-0a1", "rtb-0a1 ::/0 -> eigw-0a1"},
+			chokePoints: []string{"igw-0a1", "eigw-0a1"},
+			counts:      map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 0, EgressPatternDirect: 1},
+		},
+		{
+			name: "restricted to a proxy group",
+			fixture: egressFixture{routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")},
+				groups: []vpc.SecurityGroupInfo{
+					egressGroup("sg-0proxy", proxyTag, allOut),
+					egressGroup("sg-0app", nil, proxySGOut, internalOut),
+					egressGroup("sg-0batch", nil, proxySGOut),
+				}},
+			profile: EgressProxyOnly, summary: "restricted to proxy sg-0proxy on 3128",
+			paths: []string{"rtb-0a1 0.0.0.0/0 -> nat-0a1"}, chokePoints: []string{"nat-0a1", "sg-0proxy (proxy)"},
+			counts: map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 2, EgressPatternDirect: 0},
+		},
+		{
+			name: "restricted to a proxy host",
+			fixture: egressFixture{routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetTransitGateway, "tgw-0a1")},
+				groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, proxyHostOut)}},
+			profile: EgressProxyOnly, summary: "restricted to proxy hosts on 8080",
+			paths: []string{"rtb-0a1 0.0.0.0/0 -> tgw-0a1"}, chokePoints: []string{"tgw-0a1"},
+			counts: map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 1, EgressPatternDirect: 0},
+		},
+		{
+			name: "endpoints only without a default route",
+			fixture: egressFixture{groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, allOut)},
+				endpoints: []vpc.VpcEndpointInfo{{VpcEndpointID: "vpce-0s3", ServiceName: "com.amazonaws.eu-west-1.s3"},
+					{VpcEndpointID: "vpce-0ses", ServiceName: "com.amazonaws.eu-west-1.email-smtp"}}},
+			profile: EgressEndpointsOnly, summary: "endpoints only: no default route leaves the VPC, 2 VPC endpoint(s)",
+			paths: []string{}, chokePoints: []string{"vpce-0ses (SES SMTP)"},
+			counts: map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 0, EgressPatternDirect: 1},
+		},
+		{
+			name: "endpoints only by its security groups",
+			fixture: egressFixture{routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")},
+				groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, internalOut,
+					vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 443, ToPort: 443, PrefixListID: "pl-0s3"})},
+				endpoints: []vpc.VpcEndpointInfo{{VpcEndpointID: "vpce-0s3", ServiceName: "com.amazonaws.eu-west-1.s3"}}},
+			profile: EgressEndpointsOnly, summary: "endpoints only: the security groups allow no internet egress, 1 VPC endpoint(s)",
+			paths: []string{"rtb-0a1 0.0.0.0/0 -> nat-0a1"}, chokePoints: []string{"nat-0a1"},
+			counts: map[string]int{EgressPatternInternal: 1, EgressPatternProxy: 0, EgressPatternDirect: 0},
+		},
+		{
+			name: "no egress",
+			fixture: egressFixture{routes: []vpc.RouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", State: "blackhole", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0gone"}},
+				defaultRoute(vpc.RouteTargetVpcPeeringConnection, "pcx-0a1"),
+			}, groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, allOut)}},
+			profile: EgressNoEgress, summary: "no egress: no default route leaves the VPC and it has no VPC endpoints",
+			paths: []string{}, chokePoints: []string{},
+			counts: map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 0, EgressPatternDirect: 1},
+		},
+		{
+			name: "no egress by its security groups",
+			fixture: egressFixture{routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetInstance, "i-0fw")},
+				groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, internalOut), egressGroup("sg-0empty", nil)}},
+			profile: EgressNoEgress, summary: "no egress: the security groups allow no internet egress and the VPC has no endpoints",
+			paths: []string{"rtb-0a1 0.0.0.0/0 -> i-0fw"}, chokePoints: []string{"i-0fw"},
+			counts: map[string]int{EgressPatternInternal: 2, EgressPatternProxy: 0, EgressPatternDirect: 0},
+		},
+		{
+			name: "private NAT and an appliance",
+			fixture: egressFixture{routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0priv"),
+				{DestinationIpv6Block: "::/0", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetVpcEndpoint, ID: "vpce-0gwlb"}}},
+				groups:      []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, httpsOut)},
+				natGateways: []vpc.NatGatewayInfo{{NatGatewayID: "nat-0priv", ConnectivityType: "private"}}},
+			profile: EgressUnrestricted, summary: "unrestricted via private NAT, appliance",
+			paths: []string{"rtb-0a1 0.0.0.0/0 -> nat-0priv", "rtb-0a1 ::/0 -> vpce-0gwlb"}, chokePoints: []string{"nat-0priv", "vpce-0gwlb"},
+			counts: map[string]int{EgressPatternInternal: 0, EgressPatternProxy: 0, EgressPatternDirect: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, _ := tt.fixture.analyze(t)
+			if profile.Profile != tt.profile || profile.Summary != tt.summary {
+				t.Errorf("profile = %s (%q), want %s (%q)", profile.Profile, profile.Summary, tt.profile, tt.summary)
+			}
+			if !reflect.DeepEqual(profile.EgressPaths, tt.paths) || !reflect.DeepEqual(profile.ChokePoints, tt.chokePoints) {
+				t.Errorf("paths = %q, choke points = %q, want %q, %q", profile.EgressPaths, profile.ChokePoints, tt.paths, tt.chokePoints)
+			}
+			if !reflect.DeepEqual(profile.PatternCounts, tt.counts) {
+				t.Errorf("PatternCounts = %v, want %v", profile.PatternCounts, tt.counts)
+			}
+		})
+	}
+}
+
+// TestEgressViolations checks the groups that break the dominant pattern, ties between patterns, direct SMTP and
+// that a VPC without an egress path reports neither
+func TestEgressViolations(t *testing.T) {
+	smtpOut := vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 587, ToPort: 587, CidrBlock: "0.0.0.0/0"}
+	natRoute := []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")}
+	tests := []struct {
+		name       string
+		fixture    egressFixture
+		profile    string
+		violations []EgressViolation
+		smtp       []string
+	}{
+		{
+			name: "direct HTTPS in a proxy-only VPC",
+			fixture: egressFixture{routes: natRoute, groups: []vpc.SecurityGroupInfo{
+				egressGroup("sg-0proxy", proxyTag, allOut),
+				egressGroup("sg-0app", nil, proxySGOut),
+				egressGroup("sg-0batch", nil, proxySGOut),
+				egressGroup("sg-0legacy", nil, proxySGOut, httpsOut),
+			}},
+			profile:    EgressProxyOnly,
+			violations: []EgressViolation{{GroupID: "sg-0legacy", GroupName: "legacy", Pattern: EgressPatternDirect, Rule: "tcp/443 -> 0.0.0.0/0"}},
+			smtp:       []string{},
+		},
+		{
+			name: "proxy and direct groups in an endpoints-only VPC",
+			fixture: egressFixture{routes: natRoute, groups: []vpc.SecurityGroupInfo{
+				egressGroup("sg-0a", nil, internalOut), egressGroup("sg-0b", nil, internalOut),
+				egressGroup("sg-0c", nil, proxyHostOut), egressGroup("sg-0d", nil, allOut),
+			}, endpoints: []vpc.VpcEndpointInfo{{VpcEndpointID: "vpce-0s3"}}},
+			profile: EgressEndpointsOnly,
+			violations: []EgressViolation{
+				{GroupID: "sg-0c", GroupName: "c", Pattern: EgressPatternProxy, Rule: "tcp/8080 -> 203.0.113.10/32"},
+				{GroupID: "sg-0d", GroupName: "d", Pattern: EgressPatternDirect, Rule: "all traffic -> 0.0.0.0/0"},
+			},
+			smtp: []string{},
+		},
+		{
+			name: "a tie goes to the stricter pattern",
+			fixture: egressFixture{routes: natRoute, groups: []vpc.SecurityGroupInfo{
+				egressGroup("sg-0a", nil, proxyHostOut), egressGroup("sg-0b", nil, httpsOut),
+			}},
+			profile:    EgressProxyOnly,
+			violations: []EgressViolation{{GroupID: "sg-0b", GroupName: "b", Pattern: EgressPatternDirect, Rule: "tcp/443 -> 0.0.0.0/0"}},
+			smtp:       []string{},
+		},
+		{
+			name: "direct SMTP, not the default rule",
+			fixture: egressFixture{routes: natRoute, groups: []vpc.SecurityGroupInfo{
+				egressGroup("sg-0mail", nil, smtpOut, vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 20, ToPort: 30, CidrBlock: "198.51.100.0/24"},
+					vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 25, ToPort: 25, CidrBlock: "10.0.0.0/8"}),
+				egressGroup("sg-0default", nil, allOut, vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 0, ToPort: 65535, CidrBlock: "0.0.0.0/0"}),
+			}},
+			profile:    EgressUnrestricted,
+			violations: []EgressViolation{},
+			smtp:       []string{"sg-0mail tcp/20-30 -> 198.51.100.0/24", "sg-0mail tcp/587 -> 0.0.0.0/0"},
+		},
+		{
+			name: "nothing leaves the VPC",
+			fixture: egressFixture{groups: []vpc.SecurityGroupInfo{
+				egressGroup("sg-0a", nil, proxySGOut), egressGroup("sg-0b", nil, proxySGOut), egressGroup("sg-0mail", nil, smtpOut),
+			}},
+			profile:    EgressNoEgress,
+			violations: []EgressViolation{},
+			smtp:       []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, _ := tt.fixture.analyze(t)
+			if profile.Profile != tt.profile {
+				t.Errorf("profile = %s, want %s", profile.Profile, tt.profile)
+			}
+			if !reflect.DeepEqual(profile.Violations, tt.violations) {
+				t.Errorf("violations = %+v, want %+v", profile.Violations, tt.violations)
+			}
+			if !reflect.DeepEqual(profile.SMTPRules, tt.smtp) {
+				t.Errorf("SMTPRules = %q, want %q", profile.SMTPRules, tt.smtp)
+			}
+		})
+	}
+}
+
+// TestEgressFindings checks the findings of the egress-profile tag, violations and SMTP rules
+func TestEgressFindings(t *testing.T) {
+	unrestricted := egressFixture{routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")},
+		groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, allOut), egressGroup("sg-0web", nil, httpsOut)}}
+	tests := []struct {
+		name    string
+		intent  string
+		fixture egressFixture
+		want    []string // Classification, severity and group of each finding
+	}{
+		{name: "untagged", fixture: unrestricted},
+		{name: "tag allows the profile", intent: EgressUnrestricted, fixture: unrestricted},
+		{name: "tag exceeded", intent: EgressProxyOnly, fixture: unrestricted, want: []string{"egress-profile-exceeded high "}},
+		{name: "stricter profile than the tag", intent: EgressProxyOnly, fixture: egressFixture{
+			groups: []vpc.SecurityGroupInfo{egressGroup("sg-0app", nil, allOut)}}},
+		{name: "invalid tag", intent: "proxy", fixture: unrestricted, want: []string{"egress-profile-tag-invalid low "}},
+		{name: "violation and SMTP", intent: EgressNoEgress, fixture: egressFixture{
+			routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")},
+			groups: []vpc.SecurityGroupInfo{
+				egressGroup("sg-0proxy", proxyTag, allOut),
+				egressGroup("sg-0app", nil, proxySGOut),
+				egressGroup("sg-0mail", nil, vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 25, ToPort: 25, CidrBlock: "0.0.0.0/0"}),
+				egressGroup("sg-0web", nil, proxySGOut),
+			}},
+			want: []string{"egress-profile-exceeded high ", "egress-pattern-violation medium sg-0mail", "direct-smtp-egress medium sg-0mail"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.intent != "" {
+				tt.fixture.tags = map[string]string{EgressProfileTag: tt.intent}
+			}
+			profile, findings := tt.fixture.analyze(t)
+			var got []string
+			for _, finding := range findings {
+				if finding.VpcID != "vpc-0a1" || finding.Profile != profile.Profile || finding.Intent != tt.intent || finding.Reason == "" {
+					t.Errorf("finding = %+v", finding)
+				}
+				got = append(got, finding.Classification+" "+finding.Severity+" "+finding.GroupID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	profile, findings := egressFixture{tags: map[string]string{EgressProfileTag: EgressEndpointsOnly}, routes: unrestricted.routes,
+		groups: unrestricted.groups}.analyze(t)
+	want := "vpc-0a1 is tagged aws-documentor:egress-profile=endpoints-only but is unrestricted via NAT gateway"
+	if profile.Intent != EgressEndpointsOnly || len(findings) != 1 || findings[0].Reason != want {
+		t.Errorf("findings = %+v, want %q", findings, want)
+	}
+}
+
+// TestEgressOptions checks proxy ports, the proxy tag with and without a value, and corporate private ranges
+func TestEgressOptions(t *testing.T) {
+	corporateOut := vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "100.70.0.0/16"}
+	tests := []struct {
+		name   string
+		rule   vpc.SecurityGroupRule
+		tags   map[string]string // Tags of sg-0proxy
+		change func(opts *EgressOptions)
+		want   string
+	}{
+		{name: "proxy port to a host", rule: proxyHostOut, want: EgressPatternProxy},
+		{name: "proxy port range", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 3128, ToPort: 3129, CidrBlock: "203.0.113.10/32"},
+			change: func(opts *EgressOptions) { opts.ProxyPorts = []int32{3128, 3129} }, want: EgressPatternProxy},
+		{name: "range beyond the proxy ports", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 3128, ToPort: 3129, CidrBlock: "203.0.113.10/32"},
+			want: EgressPatternDirect},
+		{name: "proxy port to anywhere", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 3128, ToPort: 3128, CidrBlock: "0.0.0.0/0"},
+			want: EgressPatternDirect},
+		{name: "UDP on a proxy port", rule: vpc.SecurityGroupRule{IpProtocol: "udp", FromPort: 8080, ToPort: 8080, CidrBlock: "203.0.113.10/32"},
+			want: EgressPatternDirect},
+		{name: "custom proxy port", rule: vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 8443, ToPort: 8443, CidrBlock: "203.0.113.10/32"},
+			change: func(opts *EgressOptions) { opts.ProxyPorts = []int32{8443} }, want: EgressPatternProxy},
+		{name: "default port no longer a proxy port", rule: proxyHostOut,
+			change: func(opts *EgressOptions) { opts.ProxyPorts = []int32{8443} }, want: EgressPatternDirect},
+		{name: "tagged proxy group", rule: proxySGOut, tags: proxyTag, want: EgressPatternProxy},
+		{name: "group with another tag value", rule: proxySGOut, tags: map[string]string{"aws-documentor:egress-role": "nat"}, want: EgressPatternInternal},
+		{name: "custom tag with any value", rule: proxySGOut, tags: map[string]string{"Role": "squid"},
+			change: func(opts *EgressOptions) { opts.ProxyTagKey, opts.ProxyTagValue = "Role", "" }, want: EgressPatternProxy},
+		{name: "public range", rule: corporateOut, want: EgressPatternDirect},
+		{name: "corporate range", rule: corporateOut,
+			change: func(opts *EgressOptions) { opts.PrivateRanges = append(opts.PrivateRanges, "100.64.0.0/10") }, want: EgressPatternInternal},
+		{name: "IPv6 unique local", rule: vpc.SecurityGroupRule{IpProtocol: "-1", Ipv6CidrBlock: "fd00:1::/64"}, want: EgressPatternInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultEgressOptions()
+			opts.PrivateRanges = append([]string{}, opts.PrivateRanges...)
+			if tt.change != nil {
+				tt.change(&opts)
+			}
+			groups := []vpc.SecurityGroupInfo{egressGroup("sg-0proxy", tt.tags), egressGroup("sg-0app", nil, tt.rule)}
+			for i := range groups {
+				groups[i].VpcID = "vpc-0a1"
+			}
+			report := AnalyzeEgressProfiles([]vpc.VPCInfo{{VpcID: "vpc-0a1"}},
+				[]vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", Routes: []vpc.RouteInfo{defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")}}},
+				groups, nil, nil, opts)
+			counts := report.VPCs[0].PatternCounts
+			if counts[tt.want] == 0 {
+				t.Errorf("PatternCounts = %v, want sg-0app counted as %s", counts, tt.want)
+			}
+		})
+	}
+}
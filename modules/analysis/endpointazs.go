@@ -0,0 +1,169 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/vpc"
+)
+
+// EndpointPartialAZCoverage is the finding class for an interface endpoint missing from AZs with workload subnets
+const EndpointPartialAZCoverage = "partial-az-coverage"
+
+// EndpointAZCoverage describes which availability zones one interface endpoint serves
+type EndpointAZCoverage struct {
+	VpcEndpointID     string   `json:"vpc_endpoint_id"`    // ID of the endpoint
+	VpcID             string   `json:"vpc_id"`             // VPC of the endpoint
+	ServiceName       string   `json:"service_name"`       // Full service name
+	EndpointAZs       []string `json:"endpoint_azs"`       // AZs with an endpoint network interface, sorted
+	WorkloadAZs       []string `json:"workload_azs"`       // AZs with workload subnets in the VPC, sorted
+	MissingAZs        []string `json:"missing_azs"`        // Workload AZs without an endpoint network interface
+	NetworkInterfaces int      `json:"network_interfaces"` // Number of endpoint network interfaces
+	IPv4Addresses     int      `json:"ipv4_addresses"`     // IPv4 addresses the interfaces take from their subnets
+	IPv6Addresses     int      `json:"ipv6_addresses"`     // IPv6 addresses of the interfaces
+}
+
+// SubnetEndpointIPs counts the addresses endpoint interfaces take from one subnet
+type SubnetEndpointIPs struct {
+	SubnetID          string `json:"subnet_id"`          // ID of the subnet
+	AvailabilityZone  string `json:"availability_zone"`  // AZ of the subnet
+	NetworkInterfaces int    `json:"network_interfaces"` // Endpoint network interfaces in the subnet
+	IPv4Addresses     int    `json:"ipv4_addresses"`     // IPv4 addresses they take
+}
+
+// EndpointAZFinding describes an interface endpoint that does not cover every workload AZ of its VPC
+type EndpointAZFinding struct {
+	VpcEndpointID  string   `json:"vpc_endpoint_id"` // ID of the endpoint
+	VpcID          string   `json:"vpc_id"`          // VPC of the endpoint
+	MissingAZs     []string `json:"missing_azs"`     // Workload AZs without an endpoint network interface
+	Classification string   `json:"classification"`  // Always partial-az-coverage
+	Severity       string   `json:"severity"`        // Always medium
+	Reason         string   `json:"reason"`          // Human-readable explanation
+}
+
+// EndpointAZReport contains the per-AZ coverage and address consumption of interface endpoints
+type EndpointAZReport struct {
+	Endpoints []EndpointAZCoverage `json:"endpoints"` // Interface and Gateway Load Balancer endpoints, in scan order
+	Subnets   []SubnetEndpointIPs  `json:"subnets"`   // Addresses taken by endpoints per subnet, sorted by subnet ID
+	Findings  []EndpointAZFinding  `json:"findings"`  // Endpoints missing from workload AZs
+}
+
+// AnalyzeEndpointAZCoverage compares the AZs of each interface endpoint with the AZs of its VPC's workload subnets
+// Workload subnets are those without a default route to an internet gateway; public subnets hold load
+// balancers and NAT gateways rather than the clients of the endpoint. Clients in an AZ without an endpoint
+// interface reach the service through another AZ, paying cross-AZ data charges and losing the service when
+// that AZ fails. Where the interfaces were not scanned, the endpoint subnets stand in for them at one
+// IPv4 address each.
+// subnets: Subnets from the scan
+// routeTables: Route tables from the scan
+// endpoints: VPC endpoints from GetVpcEndpoints
+// interfaces: Endpoint network interfaces from GetEndpointInterfaces (nil if not scanned)
+// Returns: Report with the coverage of every endpoint, the addresses taken per subnet and the findings
+func AnalyzeEndpointAZCoverage(subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo, endpoints []vpc.VpcEndpointInfo, interfaces []vpc.EndpointInterfaceInfo) *EndpointAZReport {
+	report := &EndpointAZReport{
+		Endpoints: []EndpointAZCoverage{},
+		Subnets:   []SubnetEndpointIPs{},
+		Findings:  []EndpointAZFinding{},
+	}
+
+	subnetAZ := make(map[string]string, len(subnets))
+	for _, subnet := range subnets {
+		subnetAZ[subnet.SubnetID] = subnet.AvailabilityZone
+	}
+
+	// Workload AZs per VPC from the tier of each subnet's route table
+	public := make(map[string]bool)
+	tableSubnets := routeTableSubnets(subnets, routeTables)
+	for _, rt := range routeTables {
+		if tier, _, _ := defaultRouteEgress(rt); tier == TierPublic {
+			for _, subnetID := range tableSubnets[rt.RouteTableID] {
+				public[subnetID] = true
+			}
+		}
+	}
+	workloadAZs := make(map[string]map[string]bool)
+	for _, subnet := range subnets {
+		if public[subnet.SubnetID] {
+			continue
+		}
+		if workloadAZs[subnet.VpcID] == nil {
+			workloadAZs[subnet.VpcID] = make(map[string]bool)
+		}
+		workloadAZs[subnet.VpcID][subnet.AvailabilityZone] = true
+	}
+
+	byEndpoint := make(map[string][]vpc.EndpointInterfaceInfo)
+	for _, eni := range interfaces {
+		byEndpoint[eni.VpcEndpointID] = append(byEndpoint[eni.VpcEndpointID], eni)
+	}
+
+	perSubnet := make(map[string]*SubnetEndpointIPs)
+	for _, endpoint := range endpoints {
+		if endpoint.EndpointType == vpc.EndpointTypeGateway {
+			continue
+		}
+
+		enis, scanned := byEndpoint[endpoint.VpcEndpointID]
+		if !scanned {
+			for _, subnetID := range endpoint.SubnetIDs {
+				enis = append(enis, vpc.EndpointInterfaceInfo{SubnetID: subnetID, AvailabilityZone: subnetAZ[subnetID]})
+			}
+		}
+
+		coverage := EndpointAZCoverage{
+			VpcEndpointID: endpoint.VpcEndpointID,
+			VpcID:         endpoint.VpcID,
+			ServiceName:   endpoint.ServiceName,
+			WorkloadAZs:   sortedKeys(workloadAZs[endpoint.VpcID]),
+			MissingAZs:    []string{},
+		}
+		endpointAZs := make(map[string]bool)
+		for _, eni := range enis {
+			az := eni.AvailabilityZone
+			if az == "" {
+				az = subnetAZ[eni.SubnetID]
+			}
+			ipv4 := len(eni.PrivateIps)
+			if !scanned {
+				ipv4 = 1
+			}
+			endpointAZs[az] = true
+			coverage.NetworkInterfaces++
+			coverage.IPv4Addresses += ipv4
+			coverage.IPv6Addresses += len(eni.Ipv6Addresses)
+
+			total, ok := perSubnet[eni.SubnetID]
+			if !ok {
+				total = &SubnetEndpointIPs{SubnetID: eni.SubnetID, AvailabilityZone: az}
+				perSubnet[eni.SubnetID] = total
+			}
+			total.NetworkInterfaces++
+			total.IPv4Addresses += ipv4
+		}
+		coverage.EndpointAZs = sortedKeys(endpointAZs)
+		for _, az := range coverage.WorkloadAZs {
+			if !endpointAZs[az] {
+				coverage.MissingAZs = append(coverage.MissingAZs, az)
+			}
+		}
+		report.Endpoints = append(report.Endpoints, coverage)
+
+		if len(coverage.MissingAZs) > 0 {
+			report.Findings = append(report.Findings, EndpointAZFinding{
+				VpcEndpointID:  endpoint.VpcEndpointID,
+				VpcID:          endpoint.VpcID,
+				MissingAZs:     coverage.MissingAZs,
+				Classification: EndpointPartialAZCoverage,
+				Severity:       SeverityMedium,
+				Reason: fmt.Sprintf("%s (%s) has no network interface in %s, where %s has workload subnets; clients there cross AZs to reach it and lose it if another AZ fails",
+					endpoint.VpcEndpointID, EndpointServiceName(endpoint.ServiceName), joinList(coverage.MissingAZs), endpoint.VpcID),
+			})
+		}
+	}
+
+	for _, total := range perSubnet {
+		report.Subnets = append(report.Subnets, *total)
+	}
+	sort.Slice(report.Subnets, func(i, j int) bool { return report.Subnets[i].SubnetID < report.Subnets[j].SubnetID })
+	return report
+}
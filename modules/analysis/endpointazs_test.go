@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeEndpointAZCoverage checks full and partial coverage, an endpoint in a VPC with only public subnets,
+// and the subnet stand-ins of an endpoint whose interfaces were not scanned
+func TestAnalyzeEndpointAZCoverage(t *testing.T) {
+	subnets := []vpc.SubnetInfo{
+		{SubnetID: "subnet-0a", VpcID: "vpc-0a1", AvailabilityZone: "eu-west-1a"},
+		{SubnetID: "subnet-0b", VpcID: "vpc-0a1", AvailabilityZone: "eu-west-1b"},
+		{SubnetID: "subnet-0c", VpcID: "vpc-0a1", AvailabilityZone: "eu-west-1c"},
+		{SubnetID: "subnet-0pub", VpcID: "vpc-0a1", AvailabilityZone: "eu-west-1d"},
+		{SubnetID: "subnet-0edge", VpcID: "vpc-0edge", AvailabilityZone: "eu-west-1a"},
+	}
+	internet := vpc.RouteInfo{DestinationCidrBlock: "0.0.0.0/0", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}}
+	routeTables := []vpc.RouteTableInfo{
+		{RouteTableID: "rtb-0main", VpcID: "vpc-0a1", IsMainRouteTable: true},
+		{RouteTableID: "rtb-0pub", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0pub"}, Routes: []vpc.RouteInfo{internet}},
+		{RouteTableID: "rtb-0edge", VpcID: "vpc-0edge", SubnetIDs: []string{"subnet-0edge"}, Routes: []vpc.RouteInfo{internet}},
+	}
+	endpoints := []vpc.VpcEndpointInfo{
+		{VpcEndpointID: "vpce-0full", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.ssm", EndpointType: vpc.EndpointTypeInterface},
+		{VpcEndpointID: "vpce-0partial", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.ecr.api", EndpointType: vpc.EndpointTypeInterface},
+		{VpcEndpointID: "vpce-0edge", VpcID: "vpc-0edge", ServiceName: "com.amazonaws.eu-west-1.sts", EndpointType: vpc.EndpointTypeInterface},
+		{VpcEndpointID: "vpce-0unscanned", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.logs", EndpointType: vpc.EndpointTypeInterface,
+			SubnetIDs: []string{"subnet-0a", "subnet-0c"}},
+		{VpcEndpointID: "vpce-0s3", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.s3", EndpointType: vpc.EndpointTypeGateway},
+	}
+	interfaces := []vpc.EndpointInterfaceInfo{
+		{NetworkInterfaceID: "eni-0f1", VpcEndpointID: "vpce-0full", SubnetID: "subnet-0a", AvailabilityZone: "eu-west-1a", PrivateIps: []string{"10.0.1.5"}},
+		{NetworkInterfaceID: "eni-0f2", VpcEndpointID: "vpce-0full", SubnetID: "subnet-0b", AvailabilityZone: "eu-west-1b", PrivateIps: []string{"10.0.2.5"},
+			Ipv6Addresses: []string{"2001:db8::5"}},
+		{NetworkInterfaceID: "eni-0f3", VpcEndpointID: "vpce-0full", SubnetID: "subnet-0c", PrivateIps: []string{"10.0.3.5"}},
+		{NetworkInterfaceID: "eni-0p1", VpcEndpointID: "vpce-0partial", SubnetID: "subnet-0a", AvailabilityZone: "eu-west-1a", PrivateIps: []string{"10.0.1.6", "10.0.1.7"}},
+		{NetworkInterfaceID: "eni-0e1", VpcEndpointID: "vpce-0edge", SubnetID: "subnet-0edge", AvailabilityZone: "eu-west-1a", PrivateIps: []string{"10.9.0.5"}},
+	}
+
+	report := AnalyzeEndpointAZCoverage(subnets, routeTables, endpoints, interfaces)
+
+	workload := []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"}
+	want := []EndpointAZCoverage{
+		{VpcEndpointID: "vpce-0full", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.ssm", EndpointAZs: workload, WorkloadAZs: workload,
+			MissingAZs: []string{}, NetworkInterfaces: 3, IPv4Addresses: 3, IPv6Addresses: 1},
+		{VpcEndpointID: "vpce-0partial", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.ecr.api", EndpointAZs: []string{"eu-west-1a"},
+			WorkloadAZs: workload, MissingAZs: []string{"eu-west-1b", "eu-west-1c"}, NetworkInterfaces: 1, IPv4Addresses: 2},
+		{VpcEndpointID: "vpce-0edge", VpcID: "vpc-0edge", ServiceName: "com.amazonaws.eu-west-1.sts", EndpointAZs: []string{"eu-west-1a"},
+			WorkloadAZs: []string{}, MissingAZs: []string{}, NetworkInterfaces: 1, IPv4Addresses: 1},
+		{VpcEndpointID: "vpce-0unscanned", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.logs", EndpointAZs: []string{"eu-west-1a", "eu-west-1c"},
+			WorkloadAZs: workload, MissingAZs: []string{"eu-west-1b"}, NetworkInterfaces: 2, IPv4Addresses: 2},
+	}
+	if !reflect.DeepEqual(report.Endpoints, want) {
+		t.Errorf("endpoints = %+v\nwant %+v", report.Endpoints, want)
+	}
+
+	wantSubnets := []SubnetEndpointIPs{
+		{SubnetID: "subnet-0a", AvailabilityZone: "eu-west-1a", NetworkInterfaces: 3, IPv4Addresses: 4},
+		{SubnetID: "subnet-0b", AvailabilityZone: "eu-west-1b", NetworkInterfaces: 1, IPv4Addresses: 1},
+		{SubnetID: "subnet-0c", AvailabilityZone: "eu-west-1c", NetworkInterfaces: 2, IPv4Addresses: 2},
+		{SubnetID: "subnet-0edge", AvailabilityZone: "eu-west-1a", NetworkInterfaces: 1, IPv4Addresses: 1},
+	}
+	if !reflect.DeepEqual(report.Subnets, wantSubnets) {
+		t.Errorf("subnets = %+v\nwant %+v", report.Subnets, wantSubnets)
+	}
+
+	wantFindings := []EndpointAZFinding{
+		{VpcEndpointID: "vpce-0partial", VpcID: "vpc-0a1", MissingAZs: []string{"eu-west-1b", "eu-west-1c"}, Classification: EndpointPartialAZCoverage,
+			Severity: SeverityMedium, Reason: "vpce-0partial (ecr.api) has no network interface in eu-west-1b and eu-west-1c, where vpc-0a1 has workload subnets; clients there cross AZs to reach it and lose it if another AZ fails"},
+		{VpcEndpointID: "vpce-0unscanned", VpcID: "vpc-0a1", MissingAZs: []string{"eu-west-1b"}, Classification: EndpointPartialAZCoverage,
+			Severity: SeverityMedium, Reason: "vpce-0unscanned (logs) has no network interface in eu-west-1b, where vpc-0a1 has workload subnets; clients there cross AZs to reach it and lose it if another AZ fails"},
+	}
+	if !reflect.DeepEqual(report.Findings, wantFindings) {
+		t.Errorf("findings = %+v\nwant %+v", report.Findings, wantFindings)
+	}
+}
@@ -0,0 +1,351 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/vpc"
+)
+
+// DefaultEndpointServices is the checklist of services looked at in every VPC, in addition to the
+// services that already have an endpoint somewhere in the region
+var DefaultEndpointServices = []string{"s3", "dynamodb", "ecr.api", "ecr.dkr", "logs", "sts", "ssm", "secretsmanager", "kms"}
+
+// gatewayEndpointServices are the services that offer (free) gateway endpoints
+var gatewayEndpointServices = map[string]bool{"s3": true, "dynamodb": true}
+
+// Subnet tiers, from the default route of the subnet's route table
+const (
+	TierPublic   = "public"   // Default route to an internet gateway
+	TierPrivate  = "private"  // Default route to a NAT gateway
+	TierRouted   = "routed"   // Default route to anything else (transit gateway, appliance, peering, ...)
+	TierIsolated = "isolated" // No default route
+)
+
+// Ways traffic to a service leaves a subnet
+const (
+	EgressEndpoint        = "endpoint"         // A gateway endpoint on the route table, or an interface endpoint with private DNS
+	EgressNATGateway      = "nat-gateway"      // The default route through a NAT gateway
+	EgressInternetGateway = "internet-gateway" // The default route through an internet gateway
+	EgressOther           = "other"            // The default route through another target
+	EgressNone            = "none"             // The service is unreachable from the subnet
+)
+
+// Where a service in the coverage report came from
+const (
+	ServiceSourceEndpoint  = "endpoint"  // An endpoint for the service exists in the region
+	ServiceSourceChecklist = "checklist" // The service is on the candidate checklist only
+)
+
+// RouteTableEgress describes how one route table's subnets reach a service
+type RouteTableEgress struct {
+	RouteTableID string   `json:"route_table_id"` // ID of the route table
+	SubnetIDs    []string `json:"subnet_ids"`     // Subnets using the route table (explicitly or as the main table)
+	Tier         string   `json:"tier"`           // public, private, routed or isolated
+	Egress       string   `json:"egress"`         // endpoint, nat-gateway, internet-gateway, other or none
+	TargetID     string   `json:"target_id"`      // ID of the endpoint or default route target used (empty for none)
+}
+
+// ServiceCoverage describes how a VPC reaches one AWS service
+type ServiceCoverage struct {
+	Service           string             `json:"service"`             // Short service name (s3, ecr.api, ...)
+	Source            string             `json:"source"`              // endpoint or checklist
+	EndpointIDs       []string           `json:"endpoint_ids"`        // Available endpoints for the service in the VPC
+	GatewayEndpointID string             `json:"gateway_endpoint_id"` // Gateway endpoint for the service in the VPC (if any)
+	RouteTables       []RouteTableEgress `json:"route_tables"`        // Egress per route table with subnets
+}
+
+// VPCEndpointCoverage contains the service coverage of one VPC
+type VPCEndpointCoverage struct {
+	VpcID    string            `json:"vpc_id"`   // ID of the VPC
+	Services []ServiceCoverage `json:"services"` // Coverage per service, sorted by service name
+}
+
+// EndpointRecommendation suggests an endpoint that takes a service's traffic off NAT gateways
+type EndpointRecommendation struct {
+	VpcID              string   `json:"vpc_id"`               // ID of the VPC
+	Service            string   `json:"service"`              // Short service name
+	EndpointType       string   `json:"endpoint_type"`        // Gateway or Interface
+	ExistingEndpointID string   `json:"existing_endpoint_id"` // Gateway endpoint to associate instead of adding one (if any)
+	RouteTableIDs      []string `json:"route_table_ids"`      // Route tables whose service traffic uses a NAT gateway
+	NatGatewayIDs      []string `json:"nat_gateway_ids"`      // NAT gateways the traffic would no longer pass through
+	Recommendation     string   `json:"recommendation"`       // Human-readable recommendation
+}
+
+// EndpointCoverageReport contains per-VPC service coverage and the resulting recommendations
+type EndpointCoverageReport struct {
+	VPCs            []VPCEndpointCoverage    `json:"vpcs"`            // Coverage per VPC, in scan order
+	Recommendations []EndpointRecommendation `json:"recommendations"` // Endpoints that would remove NAT traffic
+}
+
+// AnalyzeEndpointCoverage works out, per VPC and route table, whether traffic to AWS services uses an endpoint or the default route
+// The services are the checklist plus every service with an endpoint in the region. A subnet uses its
+// explicitly associated route table or the VPC's main route table. Gateway endpoints cover the route
+// tables they are associated with; interface endpoints cover the whole VPC when private DNS is enabled,
+// since clients only use them through the service's default DNS name. Otherwise traffic follows the
+// active IPv4 default route. Route tables without subnets are left out.
+// vpcs: VPCs from the scan
+// subnets: Subnets from the scan
+// routeTables: Route tables from the scan
+// endpoints: VPC endpoints from GetVpcEndpoints
+// checklist: Short names of candidate services (DefaultEndpointServices unless configured)
+// Returns: Report with coverage per VPC and recommendations from RecommendEndpoints
+func AnalyzeEndpointCoverage(vpcs []vpc.VPCInfo, subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo, endpoints []vpc.VpcEndpointInfo, checklist []string) *EndpointCoverageReport {
+	report := &EndpointCoverageReport{
+		VPCs:            []VPCEndpointCoverage{},
+		Recommendations: []EndpointRecommendation{},
+	}
+
+	// Services with an endpoint anywhere count as in use in every VPC
+	sources := make(map[string]string)
+	for _, service := range checklist {
+		sources[service] = ServiceSourceChecklist
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.EndpointType == vpc.EndpointTypeGatewayLoadBalancer {
+			continue
+		}
+		sources[EndpointServiceName(endpoint.ServiceName)] = ServiceSourceEndpoint
+	}
+	services := make([]string, 0, len(sources))
+	for service := range sources {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	tableSubnets := routeTableSubnets(subnets, routeTables)
+
+	for _, v := range vpcs {
+		coverage := VPCEndpointCoverage{VpcID: v.VpcID, Services: []ServiceCoverage{}}
+
+		for _, service := range services {
+			entry := ServiceCoverage{
+				Service:     service,
+				Source:      sources[service],
+				EndpointIDs: []string{},
+				RouteTables: []RouteTableEgress{},
+			}
+
+			gateways := make(map[string]string) // route table ID -> gateway endpoint ID
+			interfaceEndpoint := ""
+			for _, endpoint := range endpoints {
+				if endpoint.VpcID != v.VpcID || EndpointServiceName(endpoint.ServiceName) != service || !strings.EqualFold(endpoint.State, "available") {
+					continue
+				}
+				switch endpoint.EndpointType {
+				case vpc.EndpointTypeGateway:
+					entry.EndpointIDs = append(entry.EndpointIDs, endpoint.VpcEndpointID)
+					if entry.GatewayEndpointID == "" {
+						entry.GatewayEndpointID = endpoint.VpcEndpointID
+					}
+					for _, rtID := range endpoint.RouteTableIDs {
+						gateways[rtID] = endpoint.VpcEndpointID
+					}
+				case vpc.EndpointTypeInterface:
+					entry.EndpointIDs = append(entry.EndpointIDs, endpoint.VpcEndpointID)
+					if endpoint.PrivateDnsEnabled && interfaceEndpoint == "" {
+						interfaceEndpoint = endpoint.VpcEndpointID
+					}
+				}
+			}
+
+			for _, rt := range routeTables {
+				if rt.VpcID != v.VpcID || len(tableSubnets[rt.RouteTableID]) == 0 {
+					continue
+				}
+				tier, egress, target := defaultRouteEgress(rt)
+				switch {
+				case gateways[rt.RouteTableID] != "":
+					egress, target = EgressEndpoint, gateways[rt.RouteTableID]
+				case interfaceEndpoint != "":
+					egress, target = EgressEndpoint, interfaceEndpoint
+				}
+				entry.RouteTables = append(entry.RouteTables, RouteTableEgress{
+					RouteTableID: rt.RouteTableID,
+					SubnetIDs:    tableSubnets[rt.RouteTableID],
+					Tier:         tier,
+					Egress:       egress,
+					TargetID:     target,
+				})
+			}
+
+			coverage.Services = append(coverage.Services, entry)
+		}
+
+		report.VPCs = append(report.VPCs, coverage)
+	}
+
+	report.Recommendations = RecommendEndpoints(report)
+	return report
+}
+
+// RecommendEndpoints lists the endpoints that would take service traffic off NAT gateways
+// One recommendation is made per VPC and service with at least one route table sending the service's
+// traffic through a NAT gateway. S3 and DynamoDB get a gateway endpoint on those route tables (or the
+// existing gateway endpoint associated with them); other services get an interface endpoint for the VPC.
+// Traffic through internet gateways or other targets is not NAT-processed and gets no recommendation.
+// report: Coverage report from AnalyzeEndpointCoverage
+// Returns: Recommendations in report order
+func RecommendEndpoints(report *EndpointCoverageReport) []EndpointRecommendation {
+	recommendations := []EndpointRecommendation{}
+
+	for _, coverage := range report.VPCs {
+		for _, service := range coverage.Services {
+			var routeTableIDs []string
+			natGateways := make(map[string]bool)
+			for _, rt := range service.RouteTables {
+				if rt.Egress != EgressNATGateway {
+					continue
+				}
+				routeTableIDs = append(routeTableIDs, rt.RouteTableID)
+				natGateways[rt.TargetID] = true
+			}
+			if len(routeTableIDs) == 0 {
+				continue
+			}
+
+			recommendation := EndpointRecommendation{
+				VpcID:         coverage.VpcID,
+				Service:       service.Service,
+				EndpointType:  vpc.EndpointTypeInterface,
+				RouteTableIDs: routeTableIDs,
+				NatGatewayIDs: sortedKeys(natGateways),
+			}
+			natList := joinList(recommendation.NatGatewayIDs)
+			label := serviceLabel(service.Service)
+
+			if gatewayEndpointServices[service.Service] {
+				recommendation.EndpointType = vpc.EndpointTypeGateway
+				recommendation.ExistingEndpointID = service.GatewayEndpointID
+				if recommendation.ExistingEndpointID != "" {
+					recommendation.Recommendation = fmt.Sprintf("associating %s gateway endpoint %s with %s would remove %s traffic from %s",
+						label, recommendation.ExistingEndpointID, joinList(routeTableIDs), label, natList)
+				} else {
+					recommendation.Recommendation = fmt.Sprintf("adding %s %s gateway endpoint to %s would remove %s traffic from %s",
+						article(label), label, joinList(routeTableIDs), label, natList)
+				}
+			} else {
+				recommendation.Recommendation = fmt.Sprintf("adding an interface endpoint for %s with private DNS to %s would remove %s traffic from %s",
+					label, coverage.VpcID, label, natList)
+			}
+
+			recommendations = append(recommendations, recommendation)
+		}
+	}
+
+	return recommendations
+}
+
+// defaultRouteEgress classifies a route table by its active IPv4 default route
+// Returns: Subnet tier, egress and default route target ID
+func defaultRouteEgress(rt vpc.RouteTableInfo) (string, string, string) {
+	for _, route := range rt.Routes {
+		if route.DestinationCidrBlock != "0.0.0.0/0" || route.State != "active" {
+			continue
+		}
+		switch route.Target.Type {
+		case vpc.RouteTargetNatGateway:
+			return TierPrivate, EgressNATGateway, route.Target.ID
+		case vpc.RouteTargetInternetGateway:
+			return TierPublic, EgressInternetGateway, route.Target.ID
+		default:
+			return TierRouted, EgressOther, route.Target.ID
+		}
+	}
+	return TierIsolated, EgressNone, ""
+}
+
+// routeTableSubnets maps route table IDs to the subnets that use them
+// Subnets without an explicit association use their VPC's main route table
+func routeTableSubnets(subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo) map[string][]string {
+	result := make(map[string][]string)
+	associated := make(map[string]bool)
+	mainTables := make(map[string]string)
+	for _, rt := range routeTables {
+		for _, subnetID := range rt.SubnetIDs {
+			result[rt.RouteTableID] = append(result[rt.RouteTableID], subnetID)
+			associated[subnetID] = true
+		}
+		if rt.IsMainRouteTable {
+			mainTables[rt.VpcID] = rt.RouteTableID
+		}
+	}
+	for _, subnet := range subnets {
+		if associated[subnet.SubnetID] || mainTables[subnet.VpcID] == "" {
+			continue
+		}
+		rtID := mainTables[subnet.VpcID]
+		result[rtID] = append(result[rtID], subnet.SubnetID)
+	}
+	return result
+}
+
+// EndpointServiceName returns the short name of an endpoint service
+// com.amazonaws.<region>.<service> becomes <service>; other names (partner and private services, including
+// com.amazonaws.vpce.<region>.vpce-svc-*) are kept as-is
+func EndpointServiceName(serviceName string) string {
+	parts := strings.SplitN(serviceName, ".", 4)
+	if len(parts) == 4 && parts[0] == "com" && parts[1] == "amazonaws" && parts[2] != "vpce" {
+		return parts[3]
+	}
+	return serviceName
+}
+
+// serviceLabel returns the name used for a service in recommendations
+func serviceLabel(service string) string {
+	switch service {
+	case "s3":
+		return "S3"
+	case "dynamodb":
+		return "DynamoDB"
+	}
+	return service
+}
+
+// article returns the indefinite article for a service label as it is read out ("an S3", "a DynamoDB")
+func article(label string) string {
+	if label == "S3" {
+		return "an"
+	}
+	return "a"
+}
+
+// joinList joins IDs as "a", "a and b" or "a, b and c"
+func joinList(ids []string) string {
+	if len(ids) <= 1 {
+		return strings.Join(ids, "")
+	}
+	return strings.Join(ids[:len(ids)-1], ", ") + " and " + ids[len(ids)-1]
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SubnetTiers classifies every subnet by the default route of its route table
+// Subnets without a route table (a VPC without a main route table) are isolated.
+// Returns: public, private, routed or isolated keyed by subnet ID
+func SubnetTiers(subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo) map[string]string {
+	tables := make(map[string]vpc.RouteTableInfo, len(routeTables))
+	for _, rt := range routeTables {
+		tables[rt.RouteTableID] = rt
+	}
+	tiers := make(map[string]string, len(subnets))
+	for _, subnet := range subnets {
+		tiers[subnet.SubnetID] = TierIsolated
+	}
+	for rtID, subnetIDs := range routeTableSubnets(subnets, routeTables) {
+		tier, _, _ := defaultRouteEgress(tables[rtID])
+		for _, subnetID := range subnetIDs {
+			tiers[subnetID] = tier
+		}
+	}
+	return tiers
+}
@@ -0,0 +1,254 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// coverageFixture returns a VPC with a public route table, two private route tables behind different NAT
+// gateways (one of them the main table), an isolated route table and a route table without subnets
+func coverageFixture() ([]vpc.VPCInfo, []vpc.SubnetInfo, []vpc.RouteTableInfo) {
+	vpcs := []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16"}}
+	subnets := []vpc.SubnetInfo{
+		{SubnetID: "subnet-0pub", VpcID: "vpc-0a1"},
+		{SubnetID: "subnet-0app", VpcID: "vpc-0a1"},
+		{SubnetID: "subnet-0main", VpcID: "vpc-0a1"},
+		{SubnetID: "subnet-0db", VpcID: "vpc-0a1"},
+	}
+	defaultRoute := func(targetType, id string) []vpc.RouteInfo {
+		return []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}, State: "active"},
+			{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: targetType, ID: id}, State: "active"},
+		}
+	}
+	routeTables := []vpc.RouteTableInfo{
+		{RouteTableID: "rtb-0pub", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0pub"}, Routes: defaultRoute(vpc.RouteTargetInternetGateway, "igw-0a1")},
+		{RouteTableID: "rtb-0app", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0app"}, Routes: defaultRoute(vpc.RouteTargetNatGateway, "nat-0b2")},
+		{RouteTableID: "rtb-0main", VpcID: "vpc-0a1", IsMainRouteTable: true, Routes: defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")},
+		{RouteTableID: "rtb-0db", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0db"}, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}, State: "active"},
+			{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0gone"}, State: "blackhole"},
+		}},
+		{RouteTableID: "rtb-0unused", VpcID: "vpc-0a1", Routes: defaultRoute(vpc.RouteTargetNatGateway, "nat-0a1")},
+	}
+	return vpcs, subnets, routeTables
+}
+
+// TestAnalyzeEndpointCoverage checks the egress of every route table with full, partial and zero endpoint
+// coverage of a service, and which endpoints count
+func TestAnalyzeEndpointCoverage(t *testing.T) {
+	vpcs, subnets, routeTables := coverageFixture()
+	allTables := []string{"rtb-0pub", "rtb-0app", "rtb-0main", "rtb-0db"}
+
+	tests := []struct {
+		name        string
+		endpoints   []vpc.VpcEndpointInfo
+		service     string
+		wantSource  string
+		wantIDs     []string
+		wantEgress  []string // Egress of each route table with subnets, in allTables order
+		wantTargets []string
+	}{
+		{
+			name:        "zero coverage",
+			service:     "s3",
+			wantSource:  ServiceSourceChecklist,
+			wantIDs:     []string{},
+			wantEgress:  []string{EgressInternetGateway, EgressNATGateway, EgressNATGateway, EgressNone},
+			wantTargets: []string{"igw-0a1", "nat-0b2", "nat-0a1", ""},
+		},
+		{
+			name: "partial gateway coverage",
+			endpoints: []vpc.VpcEndpointInfo{{VpcEndpointID: "vpce-0s3", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.s3",
+				EndpointType: vpc.EndpointTypeGateway, State: "available", RouteTableIDs: []string{"rtb-0app", "rtb-0unused"}}},
+			service:     "s3",
+			wantSource:  ServiceSourceEndpoint,
+			wantIDs:     []string{"vpce-0s3"},
+			wantEgress:  []string{EgressInternetGateway, EgressEndpoint, EgressNATGateway, EgressNone},
+			wantTargets: []string{"igw-0a1", "vpce-0s3", "nat-0a1", ""},
+		},
+		{
+			name: "full interface coverage",
+			endpoints: []vpc.VpcEndpointInfo{
+				{VpcEndpointID: "vpce-0nodns", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.sts",
+					EndpointType: vpc.EndpointTypeInterface, State: "available"},
+				{VpcEndpointID: "vpce-0sts", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.sts",
+					EndpointType: vpc.EndpointTypeInterface, State: "Available", PrivateDnsEnabled: true},
+			},
+			service:     "sts",
+			wantSource:  ServiceSourceEndpoint,
+			wantIDs:     []string{"vpce-0nodns", "vpce-0sts"},
+			wantEgress:  []string{EgressEndpoint, EgressEndpoint, EgressEndpoint, EgressEndpoint},
+			wantTargets: []string{"vpce-0sts", "vpce-0sts", "vpce-0sts", "vpce-0sts"},
+		},
+		{
+			name: "interface endpoint without private DNS",
+			endpoints: []vpc.VpcEndpointInfo{{VpcEndpointID: "vpce-0nodns", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.sts",
+				EndpointType: vpc.EndpointTypeInterface, State: "available"}},
+			service:     "sts",
+			wantSource:  ServiceSourceEndpoint,
+			wantIDs:     []string{"vpce-0nodns"},
+			wantEgress:  []string{EgressInternetGateway, EgressNATGateway, EgressNATGateway, EgressNone},
+			wantTargets: []string{"igw-0a1", "nat-0b2", "nat-0a1", ""},
+		},
+		{
+			name: "pending endpoint and endpoint of another VPC",
+			endpoints: []vpc.VpcEndpointInfo{
+				{VpcEndpointID: "vpce-0pending", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.s3",
+					EndpointType: vpc.EndpointTypeGateway, State: "pending", RouteTableIDs: []string{"rtb-0app"}},
+				{VpcEndpointID: "vpce-0other", VpcID: "vpc-0b2", ServiceName: "com.amazonaws.eu-west-1.s3",
+					EndpointType: vpc.EndpointTypeGateway, State: "available", RouteTableIDs: []string{"rtb-0app"}},
+			},
+			service:     "s3",
+			wantSource:  ServiceSourceEndpoint,
+			wantIDs:     []string{},
+			wantEgress:  []string{EgressInternetGateway, EgressNATGateway, EgressNATGateway, EgressNone},
+			wantTargets: []string{"igw-0a1", "nat-0b2", "nat-0a1", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := AnalyzeEndpointCoverage(vpcs, subnets, routeTables, tt.endpoints, []string{"s3"})
+			if len(report.VPCs) != 1 {
+				t.Fatalf("got %d VPCs, want 1", len(report.VPCs))
+			}
+			var coverage *ServiceCoverage
+			for i, service := range report.VPCs[0].Services {
+				if service.Service == tt.service {
+					coverage = &report.VPCs[0].Services[i]
+				}
+			}
+			if coverage == nil {
+				t.Fatalf("no coverage of %s in %+v", tt.service, report.VPCs[0].Services)
+			}
+			if coverage.Source != tt.wantSource || !reflect.DeepEqual(coverage.EndpointIDs, tt.wantIDs) {
+				t.Errorf("source %s, endpoints %v, want %s, %v", coverage.Source, coverage.EndpointIDs, tt.wantSource, tt.wantIDs)
+			}
+			var tables, egress, targets []string
+			for _, rt := range coverage.RouteTables {
+				tables = append(tables, rt.RouteTableID)
+				egress = append(egress, rt.Egress)
+				targets = append(targets, rt.TargetID)
+			}
+			if !reflect.DeepEqual(tables, allTables) {
+				t.Errorf("route tables = %v, want %v", tables, allTables)
+			}
+			if !reflect.DeepEqual(egress, tt.wantEgress) || !reflect.DeepEqual(targets, tt.wantTargets) {
+				t.Errorf("egress = %v via %v, want %v via %v", egress, targets, tt.wantEgress, tt.wantTargets)
+			}
+		})
+	}
+}
+
+// TestEndpointCoverageServices checks that the services are the checklist plus every service with an endpoint,
+// sorted, and that Gateway Load Balancer endpoints and main route table subnets are handled
+func TestEndpointCoverageServices(t *testing.T) {
+	vpcs, subnets, routeTables := coverageFixture()
+	endpoints := []vpc.VpcEndpointInfo{
+		{VpcEndpointID: "vpce-0ecr", VpcID: "vpc-0b2", ServiceName: "com.amazonaws.eu-west-1.ecr.api", EndpointType: vpc.EndpointTypeInterface, State: "available"},
+		{VpcEndpointID: "vpce-0gwlb", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0fw", EndpointType: vpc.EndpointTypeGatewayLoadBalancer, State: "available"},
+	}
+	report := AnalyzeEndpointCoverage(vpcs, subnets, routeTables, endpoints, []string{"s3", "logs"})
+
+	var services, sources []string
+	for _, service := range report.VPCs[0].Services {
+		services = append(services, service.Service)
+		sources = append(sources, service.Source)
+	}
+	if want := []string{"ecr.api", "logs", "s3"}; !reflect.DeepEqual(services, want) {
+		t.Errorf("services = %v, want %v", services, want)
+	}
+	if want := []string{ServiceSourceEndpoint, ServiceSourceChecklist, ServiceSourceChecklist}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("sources = %v, want %v", sources, want)
+	}
+	main := report.VPCs[0].Services[0].RouteTables[2]
+	if main.RouteTableID != "rtb-0main" || !reflect.DeepEqual(main.SubnetIDs, []string{"subnet-0main"}) || main.Tier != TierPrivate {
+		t.Errorf("main route table coverage = %+v, want subnet-0main in the private tier", main)
+	}
+}
+
+// TestRecommendEndpoints checks the recommendations for full, partial and zero endpoint coverage
+func TestRecommendEndpoints(t *testing.T) {
+	natTable := func(rtID, natID string) RouteTableEgress {
+		return RouteTableEgress{RouteTableID: rtID, Tier: TierPrivate, Egress: EgressNATGateway, TargetID: natID}
+	}
+	endpointTable := func(rtID, endpointID string) RouteTableEgress {
+		return RouteTableEgress{RouteTableID: rtID, Tier: TierPrivate, Egress: EgressEndpoint, TargetID: endpointID}
+	}
+	publicTable := RouteTableEgress{RouteTableID: "rtb-0pub", Tier: TierPublic, Egress: EgressInternetGateway, TargetID: "igw-0a1"}
+
+	tests := []struct {
+		name     string
+		services []ServiceCoverage
+		want     []EndpointRecommendation
+	}{
+		{
+			name: "full coverage",
+			services: []ServiceCoverage{
+				{Service: "s3", GatewayEndpointID: "vpce-0s3", RouteTables: []RouteTableEgress{endpointTable("rtb-0x", "vpce-0s3"), endpointTable("rtb-0y", "vpce-0s3")}},
+				{Service: "sts", RouteTables: []RouteTableEgress{endpointTable("rtb-0x", "vpce-0sts"), publicTable}},
+			},
+			want: []EndpointRecommendation{},
+		},
+		{
+			name: "partial coverage",
+			services: []ServiceCoverage{
+				{Service: "s3", GatewayEndpointID: "vpce-0s3", RouteTables: []RouteTableEgress{endpointTable("rtb-0x", "vpce-0s3"), natTable("rtb-0y", "nat-123")}},
+			},
+			want: []EndpointRecommendation{{VpcID: "vpc-0a1", Service: "s3", EndpointType: vpc.EndpointTypeGateway, ExistingEndpointID: "vpce-0s3",
+				RouteTableIDs: []string{"rtb-0y"}, NatGatewayIDs: []string{"nat-123"},
+				Recommendation: "associating S3 gateway endpoint vpce-0s3 with rtb-0y would remove S3 traffic from nat-123"}},
+		},
+		{
+			name: "zero coverage",
+			services: []ServiceCoverage{
+				{Service: "dynamodb", RouteTables: []RouteTableEgress{natTable("rtb-0x", "nat-123"), natTable("rtb-0y", "nat-456"), natTable("rtb-0z", "nat-123")}},
+				{Service: "s3", RouteTables: []RouteTableEgress{publicTable, natTable("rtb-0x", "nat-123"), natTable("rtb-0y", "nat-123")}},
+				{Service: "sts", RouteTables: []RouteTableEgress{natTable("rtb-0x", "nat-123")}},
+			},
+			want: []EndpointRecommendation{
+				{VpcID: "vpc-0a1", Service: "dynamodb", EndpointType: vpc.EndpointTypeGateway,
+					RouteTableIDs: []string{"rtb-0x", "rtb-0y", "rtb-0z"}, NatGatewayIDs: []string{"nat-123", "nat-456"},
+					Recommendation: "adding a DynamoDB gateway endpoint to rtb-0x, rtb-0y and rtb-0z would remove DynamoDB traffic from nat-123 and nat-456"},
+				{VpcID: "vpc-0a1", Service: "s3", EndpointType: vpc.EndpointTypeGateway,
+					RouteTableIDs: []string{"rtb-0x", "rtb-0y"}, NatGatewayIDs: []string{"nat-123"},
+					Recommendation: "adding an S3 gateway endpoint to rtb-0x and rtb-0y would remove S3 traffic from nat-123"},
+				{VpcID: "vpc-0a1", Service: "sts", EndpointType: vpc.EndpointTypeInterface,
+					RouteTableIDs: []string{"rtb-0x"}, NatGatewayIDs: []string{"nat-123"},
+					Recommendation: "adding an interface endpoint for sts with private DNS to vpc-0a1 would remove sts traffic from nat-123"},
+			},
+		},
+		{
+			name:     "internet gateway only",
+			services: []ServiceCoverage{{Service: "s3", RouteTables: []RouteTableEgress{publicTable}}},
+			want:     []EndpointRecommendation{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &EndpointCoverageReport{VPCs: []VPCEndpointCoverage{{VpcID: "vpc-0a1", Services: tt.services}}}
+			if got := RecommendEndpoints(report); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RecommendEndpoints() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEndpointServiceName checks the short names of AWS, partner and private endpoint services
+func TestEndpointServiceName(t *testing.T) {
+	tests := map[string]string{
+		"com.amazonaws.eu-west-1.s3":                  "s3",
+		"com.amazonaws.eu-west-1.ecr.dkr":             "ecr.dkr",
+		"com.amazonaws.vpce.eu-west-1.vpce-svc-0a1b2": "com.amazonaws.vpce.eu-west-1.vpce-svc-0a1b2",
+		"aws.sagemaker.eu-west-1.notebook":            "aws.sagemaker.eu-west-1.notebook",
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := EndpointServiceName(name); got != want {
+				t.Errorf("EndpointServiceName(%q) = %q, want %q", name, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// Traffic path classes
+const (
+	PathDirect           = "direct"             // The transit gateway delivers traffic straight to the destination VPC
+	PathInspected        = "inspected"          // Traffic passes a firewall endpoint in an inspection VPC in both directions
+	PathBypassed         = "bypassed"           // Traffic goes direct although the transit gateway inspects other VPC pairs
+	PathBrokenReturnPath = "broken-return-path" // The return traffic does not take the forward path's inspection VPC, or is not routed at all
+	PathUnreachable      = "unreachable"        // No route leads from the source VPC to the destination VPC
+)
+
+// Path hop types
+const (
+	HopVPC              = "vpc"                         // A VPC the traffic enters
+	HopAttachment       = "transit-gateway-attachment"  // A transit gateway attachment the traffic crosses
+	HopRouteTable       = "transit-gateway-route-table" // The transit gateway route table that chose the next attachment
+	HopFirewallEndpoint = "firewall-endpoint"           // The endpoint, interface or instance the inspection VPC routes through
+)
+
+// InspectionApplianceModeDisabled marks an inspection VPC attached without appliance mode
+const InspectionApplianceModeDisabled = "appliance-mode-disabled"
+
+// SeverityHigh marks findings that can break traffic or silently weaken a security control
+const SeverityHigh = "high"
+
+// PathHop is one step of a traffic path
+type PathHop struct {
+	Type   string `json:"type"`   // One of the Hop* constants
+	ID     string `json:"id"`     // ID of the VPC, attachment, route table or firewall endpoint
+	Detail string `json:"detail"` // Route that led to the next hop, if any
+}
+
+// TrafficPath describes how traffic from one VPC reaches another through a transit gateway
+type TrafficPath struct {
+	SourceVpcID      string      `json:"source_vpc_id"`      // ID of the VPC the traffic starts in
+	DestinationVpcID string      `json:"destination_vpc_id"` // ID of the VPC the traffic is addressed to
+	Destination      string      `json:"destination"`        // Primary CIDR block of the destination VPC, used for route lookups
+	TransitGatewayID string      `json:"transit_gateway_id"` // ID of the transit gateway both VPCs are attached to
+	Classification   string      `json:"classification"`     // direct, inspected, bypassed, broken-return-path or unreachable
+	InspectionVpcID  string      `json:"inspection_vpc_id"`  // ID of the inspection VPC on the forward path (if any)
+	Hops             []PathHop   `json:"hops"`               // Forward path, from the source VPC to as far as it was routed
+	ReturnHops       []PathHop   `json:"return_hops"`        // Return path, from the destination VPC back to the source
+	Limits           *PathLimits `json:"limits,omitempty"`   // Path MTU and bandwidth cap from AnnotatePathLimits (nil if not annotated or not reachable)
+	Reason           string      `json:"reason"`             // Human-readable explanation of the classification
+}
+
+// InspectionFinding describes a configuration problem of an inspection VPC
+type InspectionFinding struct {
+	AttachmentID     string `json:"attachment_id"`      // ID of the inspection VPC's transit gateway attachment
+	TransitGatewayID string `json:"transit_gateway_id"` // ID of the transit gateway
+	VpcID            string `json:"vpc_id"`             // ID of the inspection VPC
+	Classification   string `json:"classification"`     // appliance-mode-disabled
+	Severity         string `json:"severity"`           // Always high
+	Reason           string `json:"reason"`             // Human-readable explanation
+}
+
+// InspectionReport contains the results of the inspection path analysis
+type InspectionReport struct {
+	Paths     []TrafficPath                `json:"paths"`                // One path per ordered pair of VPCs attached to the same transit gateway (inspection VPCs excluded)
+	Matrix    map[string]map[string]string `json:"matrix"`               // Classification keyed by source VPC ID and destination VPC ID
+	MTUMatrix map[string]map[string]int    `json:"mtu_matrix,omitempty"` // Path MTU keyed by source VPC ID and destination VPC ID, from AnnotatePathLimits
+	Findings  []InspectionFinding          `json:"findings"`             // Problems with the inspection VPCs found on any path
+}
+
+// AnalyzeInspectionPaths traces traffic between every pair of VPCs attached to the same transit gateway
+// A path is inspected when the source attachment's route table sends the destination to another VPC's
+// attachment, that VPC's attachment subnets route the destination to a firewall endpoint, one of its
+// route tables sends the destination back to the transit gateway, and the inspection attachment's route
+// table delivers it to the destination VPC. A single inspection VPC per direction is followed; the
+// return path must pass the same inspection VPC, otherwise stateful firewalls drop the replies.
+// Inspection VPC attachments without appliance mode are reported as findings: without it the transit
+// gateway may hand the two directions of a flow to firewall endpoints in different availability zones.
+// vpcs: VPCs from the VPC scan
+// routeTables: VPC route tables from the VPC scan
+// attachments: Transit gateway attachments, enriched with subnets and appliance mode
+// tgwRouteTables: Transit gateway route tables with their routes
+// Returns: Report with one path per ordered pair of non-inspection VPCs, the resulting matrix and inspection findings
+func AnalyzeInspectionPaths(vpcs []vpc.VPCInfo, routeTables []vpc.RouteTableInfo, attachments []vpc.TransitGatewayAttachmentInfo, tgwRouteTables []vpc.TransitGatewayRouteTableInfo) *InspectionReport {
+	report := &InspectionReport{
+		Paths:    []TrafficPath{},
+		Matrix:   make(map[string]map[string]string),
+		Findings: []InspectionFinding{},
+	}
+	g := newTransitGraph(vpcs, routeTables, attachments, tgwRouteTables)
+
+	for _, tgwID := range g.transitGatewayIDs() {
+		attached := g.vpcAttachments[tgwID]
+		vpcIDs := make([]string, 0, len(attached))
+		for vpcID := range attached {
+			vpcIDs = append(vpcIDs, vpcID)
+		}
+		sort.Strings(vpcIDs)
+
+		// Trace every ordered pair first; whether a direct path counts as bypassed depends on the others
+		traces := make(map[[2]string]pathTrace)
+		inspectionVPCs := make(map[string]bool)
+		transitVPCs := make(map[string]bool)
+		for _, src := range vpcIDs {
+			for _, dst := range vpcIDs {
+				if src == dst || g.cidrs[dst] == "" {
+					continue
+				}
+				trace := g.trace(attached[src], dst, g.cidrs[dst])
+				traces[[2]string{src, dst}] = trace
+				if trace.inspectionVpcID != "" {
+					inspectionVPCs[trace.inspectionVpcID] = true
+				}
+				if trace.transitVpcID != "" {
+					transitVPCs[trace.transitVpcID] = true
+				}
+			}
+		}
+
+		// Inspection and other transit VPCs are part of the paths, not endpoints of their own
+		for _, src := range vpcIDs {
+			for _, dst := range vpcIDs {
+				forward, ok := traces[[2]string{src, dst}]
+				if !ok || transitVPCs[src] || transitVPCs[dst] {
+					continue
+				}
+				back, ok := traces[[2]string{dst, src}]
+				if !ok {
+					back = pathTrace{reason: fmt.Sprintf("%s has no CIDR block to route back to", src)}
+				}
+				path := classifyPath(src, dst, forward, back, len(inspectionVPCs) > 0)
+				path.Destination = g.cidrs[dst]
+				path.TransitGatewayID = tgwID
+
+				report.Paths = append(report.Paths, path)
+				if report.Matrix[src] == nil {
+					report.Matrix[src] = make(map[string]string)
+				}
+				report.Matrix[src][dst] = path.Classification
+			}
+		}
+
+		// Appliance mode is checked once per inspection VPC
+		inspectionIDs := make([]string, 0, len(inspectionVPCs))
+		for vpcID := range inspectionVPCs {
+			inspectionIDs = append(inspectionIDs, vpcID)
+		}
+		sort.Strings(inspectionIDs)
+		for _, vpcID := range inspectionIDs {
+			attachment := attached[vpcID]
+			if attachment == nil || attachment.ApplianceModeSupport == "enable" {
+				continue
+			}
+			report.Findings = append(report.Findings, InspectionFinding{
+				AttachmentID:     attachment.AttachmentID,
+				TransitGatewayID: tgwID,
+				VpcID:            vpcID,
+				Classification:   InspectionApplianceModeDisabled,
+				Severity:         SeverityHigh,
+				Reason:           fmt.Sprintf("inspection VPC %s is attached without appliance mode; the two directions of a flow can reach firewall endpoints in different availability zones", vpcID),
+			})
+		}
+	}
+
+	return report
+}
+
+// classifyPath combines the forward and return traces of a VPC pair into a path
+func classifyPath(src, dst string, forward, back pathTrace, tgwInspects bool) TrafficPath {
+	path := TrafficPath{
+		SourceVpcID:      src,
+		DestinationVpcID: dst,
+		InspectionVpcID:  forward.inspectionVpcID,
+		Hops:             forward.hops,
+		ReturnHops:       back.hops,
+	}
+	if path.Hops == nil {
+		path.Hops = []PathHop{}
+	}
+	if path.ReturnHops == nil {
+		path.ReturnHops = []PathHop{}
+	}
+
+	switch {
+	case !forward.reached:
+		path.Classification = PathUnreachable
+		path.Reason = forward.reason
+	case !back.reached:
+		path.Classification = PathBrokenReturnPath
+		path.Reason = fmt.Sprintf("return traffic from %s is not routed back: %s", dst, back.reason)
+	case back.inspectionVpcID != forward.inspectionVpcID && forward.inspectionVpcID != "" && back.inspectionVpcID != "":
+		path.Classification = PathBrokenReturnPath
+		path.Reason = fmt.Sprintf("forward traffic is inspected in %s but return traffic in %s", forward.inspectionVpcID, back.inspectionVpcID)
+	case forward.inspectionVpcID != "" && back.inspectionVpcID == "":
+		path.Classification = PathBrokenReturnPath
+		path.Reason = fmt.Sprintf("forward traffic is inspected in %s but return traffic bypasses it", forward.inspectionVpcID)
+	case forward.inspectionVpcID == "" && back.inspectionVpcID != "":
+		path.Classification = PathBrokenReturnPath
+		path.Reason = fmt.Sprintf("return traffic is inspected in %s but forward traffic bypasses it", back.inspectionVpcID)
+	case forward.inspectionVpcID != "":
+		path.Classification = PathInspected
+		path.Reason = fmt.Sprintf("inspected in %s in both directions", forward.inspectionVpcID)
+	case forward.reason != "" || tgwInspects:
+		// A VPC that forwards without a firewall endpoint is a bypass just like a direct route
+		path.Classification = PathBypassed
+		path.Reason = forward.reason
+		if path.Reason == "" {
+			path.Reason = "routed directly although the transit gateway sends other traffic through an inspection VPC"
+		}
+	default:
+		path.Classification = PathDirect
+		path.Reason = "routed directly by the transit gateway"
+	}
+	return path
+}
+
+// pathTrace is the result of following one direction of traffic
+type pathTrace struct {
+	hops            []PathHop // Hops as far as the traffic was routed
+	reached         bool      // Whether the traffic reached the destination VPC
+	inspectionVpcID string    // VPC whose firewall endpoint the traffic passed (if any)
+	transitVpcID    string    // VPC the transit gateway sent the traffic through on its way (if any)
+	reason          string    // Why the traffic did not arrive, or why a reached path is not inspected
+}
+
+// transitGraph indexes the scanned resources needed to follow traffic through transit gateways
+type transitGraph struct {
+	cidrs          map[string]string                                       // Primary CIDR block by VPC ID
+	vpcAttachments map[string]map[string]*vpc.TransitGatewayAttachmentInfo // VPC attachments by transit gateway ID and VPC ID
+	attachments    map[string]*vpc.TransitGatewayAttachmentInfo            // All attachments by ID
+	tgwRouteTables map[string]*vpc.TransitGatewayRouteTableInfo            // Transit gateway route tables by ID
+	subnetTables   map[string]*vpc.RouteTableInfo                          // Explicitly associated route table by subnet ID
+	mainTables     map[string]*vpc.RouteTableInfo                          // Main route table by VPC ID
+	vpcRouteTables map[string][]*vpc.RouteTableInfo                        // All route tables by VPC ID
+}
+
+// newTransitGraph builds the indexes for tracing
+func newTransitGraph(vpcs []vpc.VPCInfo, routeTables []vpc.RouteTableInfo, attachments []vpc.TransitGatewayAttachmentInfo, tgwRouteTables []vpc.TransitGatewayRouteTableInfo) *transitGraph {
+	g := &transitGraph{
+		cidrs:          make(map[string]string),
+		vpcAttachments: make(map[string]map[string]*vpc.TransitGatewayAttachmentInfo),
+		attachments:    make(map[string]*vpc.TransitGatewayAttachmentInfo),
+		tgwRouteTables: make(map[string]*vpc.TransitGatewayRouteTableInfo),
+		subnetTables:   make(map[string]*vpc.RouteTableInfo),
+		mainTables:     make(map[string]*vpc.RouteTableInfo),
+		vpcRouteTables: make(map[string][]*vpc.RouteTableInfo),
+	}
+
+	for _, v := range vpcs {
+		g.cidrs[v.VpcID] = v.CidrBlock
+	}
+	for i := range routeTables {
+		rt := &routeTables[i]
+		g.vpcRouteTables[rt.VpcID] = append(g.vpcRouteTables[rt.VpcID], rt)
+		if rt.IsMainRouteTable {
+			g.mainTables[rt.VpcID] = rt
+		}
+		for _, subnetID := range rt.SubnetIDs {
+			g.subnetTables[subnetID] = rt
+		}
+	}
+	for i := range tgwRouteTables {
+		g.tgwRouteTables[tgwRouteTables[i].RouteTableID] = &tgwRouteTables[i]
+	}
+	for i := range attachments {
+		attachment := &attachments[i]
+		g.attachments[attachment.AttachmentID] = attachment
+		if attachment.ResourceType != "vpc" || attachment.State != "available" {
+			continue
+		}
+		if g.vpcAttachments[attachment.TransitGatewayID] == nil {
+			g.vpcAttachments[attachment.TransitGatewayID] = make(map[string]*vpc.TransitGatewayAttachmentInfo)
+		}
+		g.vpcAttachments[attachment.TransitGatewayID][attachment.ResourceID] = attachment
+	}
+	return g
+}
+
+// transitGatewayIDs returns the transit gateways with VPC attachments in a stable order
+func (g *transitGraph) transitGatewayIDs() []string {
+	ids := make([]string, 0, len(g.vpcAttachments))
+	for id := range g.vpcAttachments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// trace follows traffic for destination from a source attachment through at most one inspection VPC
+func (g *transitGraph) trace(source *vpc.TransitGatewayAttachmentInfo, dstVpcID, destination string) pathTrace {
+	t := pathTrace{hops: []PathHop{
+		{Type: HopVPC, ID: source.ResourceID},
+		{Type: HopAttachment, ID: source.AttachmentID},
+	}}
+
+	next, reason := g.nextAttachment(&t, source, destination)
+	if next == nil {
+		t.reason = reason
+		return t
+	}
+	if next.ResourceID == dstVpcID {
+		t.hops = append(t.hops, PathHop{Type: HopAttachment, ID: next.AttachmentID}, PathHop{Type: HopVPC, ID: dstVpcID})
+		t.reached = true
+		return t
+	}
+	if next.ResourceType != "vpc" {
+		t.reason = fmt.Sprintf("%s is routed to %s attachment %s instead of %s", destination, next.ResourceType, next.AttachmentID, dstVpcID)
+		return t
+	}
+
+	// The traffic enters another VPC: follow it through that VPC's firewall and back out
+	inspection := next
+	t.transitVpcID = inspection.ResourceID
+	t.hops = append(t.hops, PathHop{Type: HopAttachment, ID: inspection.AttachmentID}, PathHop{Type: HopVPC, ID: inspection.ResourceID})
+
+	entry := g.attachmentRouteTable(inspection)
+	if entry == nil {
+		t.reason = fmt.Sprintf("no route table found for the attachment subnets of %s in %s", inspection.AttachmentID, inspection.ResourceID)
+		return t
+	}
+	route := longestVPCRoute(entry, destination)
+	if route == nil {
+		t.reason = fmt.Sprintf("route table %s in %s has no route for %s", entry.RouteTableID, inspection.ResourceID, destination)
+		return t
+	}
+
+	switch route.Target.Type {
+	case vpc.RouteTargetVpcEndpoint, vpc.RouteTargetNetworkInterface, vpc.RouteTargetInstance:
+		t.inspectionVpcID = inspection.ResourceID
+		t.hops = append(t.hops, PathHop{Type: HopFirewallEndpoint, ID: route.Target.ID, Detail: fmt.Sprintf("%s via %s", destination, entry.RouteTableID)})
+
+		// After the firewall, some route table of the inspection VPC must hand the traffic back to the transit gateway
+		if !g.routesToTransitGateway(inspection.ResourceID, inspection.TransitGatewayID, destination) {
+			t.reason = fmt.Sprintf("no route table in %s sends %s back to %s after the firewall", inspection.ResourceID, destination, inspection.TransitGatewayID)
+			return t
+		}
+	case vpc.RouteTargetTransitGateway:
+		if route.Target.ID != inspection.TransitGatewayID {
+			t.reason = fmt.Sprintf("%s routes %s to another transit gateway %s", inspection.ResourceID, destination, route.Target.ID)
+			return t
+		}
+	default:
+		t.reason = fmt.Sprintf("%s routes %s to %s instead of a firewall endpoint", inspection.ResourceID, destination, route.Target)
+		return t
+	}
+
+	final, reason := g.nextAttachment(&t, inspection, destination)
+	if final == nil {
+		t.reason = reason
+		return t
+	}
+	if final.ResourceID != dstVpcID {
+		t.reason = fmt.Sprintf("after %s, %s is routed to attachment %s instead of %s", inspection.ResourceID, destination, final.AttachmentID, dstVpcID)
+		return t
+	}
+	t.hops = append(t.hops, PathHop{Type: HopAttachment, ID: final.AttachmentID}, PathHop{Type: HopVPC, ID: dstVpcID})
+	t.reached = true
+	if t.inspectionVpcID == "" {
+		t.reason = fmt.Sprintf("%s forwards %s straight back to the transit gateway without a firewall endpoint", inspection.ResourceID, destination)
+	}
+	return t
+}
+
+// nextAttachment looks up destination in the route table associated with an attachment
+// The route table hop is appended to the trace; the first attachment of an ECMP route is followed
+// Returns: The attachment the transit gateway sends the traffic to, or nil and the reason it is not routed
+func (g *transitGraph) nextAttachment(t *pathTrace, from *vpc.TransitGatewayAttachmentInfo, destination string) (*vpc.TransitGatewayAttachmentInfo, string) {
+	rtID := from.Association["route_table_id"]
+	if rtID == "" {
+		return nil, fmt.Sprintf("attachment %s is not associated with a transit gateway route table", from.AttachmentID)
+	}
+	rt, ok := g.tgwRouteTables[rtID]
+	if !ok {
+		return nil, fmt.Sprintf("transit gateway route table %s was not found in the scan", rtID)
+	}
+
+	var best *vpc.TransitGatewayRouteInfo
+	bestLength := -1
+	for i := range rt.Routes {
+		route := &rt.Routes[i]
+		if route.DestinationCidrBlock == "" {
+			continue
+		}
+		contains, err := netcalc.CIDRContains(route.DestinationCidrBlock, destination)
+		if err != nil || !contains {
+			continue
+		}
+		if length, _ := netcalc.PrefixLength(route.DestinationCidrBlock); length > bestLength {
+			best, bestLength = route, length
+		}
+	}
+	if best == nil {
+		return nil, fmt.Sprintf("transit gateway route table %s has no route for %s", rtID, destination)
+	}
+
+	t.hops = append(t.hops, PathHop{Type: HopRouteTable, ID: rtID, Detail: fmt.Sprintf("%s (%s)", best.DestinationCidrBlock, best.Type)})
+	if best.State == "blackhole" || len(best.AttachmentIDs) == 0 {
+		return nil, fmt.Sprintf("route %s in %s is a blackhole", best.DestinationCidrBlock, rtID)
+	}
+	next, ok := g.attachments[best.AttachmentIDs[0]]
+	if !ok {
+		return nil, fmt.Sprintf("attachment %s was not found in the scan", best.AttachmentIDs[0])
+	}
+	return next, ""
+}
+
+// attachmentRouteTable returns the VPC route table of a VPC attachment's subnets
+// All attachment subnets are expected to share routing; the first one with a known table is used
+func (g *transitGraph) attachmentRouteTable(attachment *vpc.TransitGatewayAttachmentInfo) *vpc.RouteTableInfo {
+	for _, subnetID := range attachment.SubnetIDs {
+		if rt, ok := g.subnetTables[subnetID]; ok {
+			return rt
+		}
+	}
+	return g.mainTables[attachment.ResourceID]
+}
+
+// routesToTransitGateway reports whether any route table of a VPC sends destination to the transit gateway
+func (g *transitGraph) routesToTransitGateway(vpcID, tgwID, destination string) bool {
+	for _, rt := range g.vpcRouteTables[vpcID] {
+		route := longestVPCRoute(rt, destination)
+		if route != nil && route.Target.Type == vpc.RouteTargetTransitGateway && route.Target.ID == tgwID {
+			return true
+		}
+	}
+	return false
+}
+
+// longestVPCRoute returns the most specific active route of a VPC route table that covers destination
+func longestVPCRoute(rt *vpc.RouteTableInfo, destination string) *vpc.RouteInfo {
+	var best *vpc.RouteInfo
+	bestLength := -1
+	for i := range rt.Routes {
+		route := &rt.Routes[i]
+		if route.DestinationCidrBlock == "" || route.State == "blackhole" {
+			continue
+		}
+		contains, err := netcalc.CIDRContains(route.DestinationCidrBlock, destination)
+		if err != nil || !contains {
+			continue
+		}
+		if length, _ := netcalc.PrefixLength(route.DestinationCidrBlock); length > bestLength {
+			best, bestLength = route, length
+		}
+	}
+	return best
+}
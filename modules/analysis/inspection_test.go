@@ -0,0 +1,252 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// inspectionFixture is a transit gateway with a centralized inspection VPC, as in the AWS Network Firewall
+// deployment guide:
+//   - vpc-0a1 and vpc-0b2 are associated with tgw-rtb-0spoke, which sends everything to the inspection VPC
+//   - vpc-0c3 and vpc-0d4 are associated with tgw-rtb-0direct, which knows vpc-0a1 and themselves but
+//     not vpc-0b2, and bypasses the firewall
+//   - vpc-0insp is associated with tgw-rtb-0firewall, which propagates every spoke; its attachment subnet
+//     routes to the firewall endpoint and its firewall subnet routes 10.0.0.0/8 back to the transit gateway
+type inspectionFixture struct {
+	vpcs           []vpc.VPCInfo
+	routeTables    []vpc.RouteTableInfo
+	attachments    []vpc.TransitGatewayAttachmentInfo
+	tgwRouteTables []vpc.TransitGatewayRouteTableInfo
+}
+
+// newInspectionFixture returns the inspection architecture with appliance mode enabled on the inspection VPC
+func newInspectionFixture() *inspectionFixture {
+	attachment := func(id, vpcID, routeTableID string, subnetIDs ...string) vpc.TransitGatewayAttachmentInfo {
+		return vpc.TransitGatewayAttachmentInfo{AttachmentID: id, TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: vpcID,
+			State: "available", Association: map[string]string{"route_table_id": routeTableID}, SubnetIDs: subnetIDs}
+	}
+	tgwRoute := func(cidr, attachmentID string) vpc.TransitGatewayRouteInfo {
+		return vpc.TransitGatewayRouteInfo{DestinationCidrBlock: cidr, Type: "propagated", State: "active", AttachmentIDs: []string{attachmentID}}
+	}
+	vpcRoute := func(cidr string, targetType, targetID string) vpc.RouteInfo {
+		return vpc.RouteInfo{DestinationCidrBlock: cidr, Target: vpc.RouteTarget{Type: targetType, ID: targetID}, State: "active"}
+	}
+
+	return &inspectionFixture{
+		vpcs: []vpc.VPCInfo{
+			{VpcID: "vpc-0a1", CidrBlock: "10.1.0.0/16"},
+			{VpcID: "vpc-0b2", CidrBlock: "10.2.0.0/16"},
+			{VpcID: "vpc-0c3", CidrBlock: "10.3.0.0/16"},
+			{VpcID: "vpc-0d4", CidrBlock: "10.4.0.0/16"},
+			{VpcID: "vpc-0insp", CidrBlock: "100.64.0.0/16"},
+		},
+		routeTables: []vpc.RouteTableInfo{
+			{RouteTableID: "rtb-0insp-tgw", VpcID: "vpc-0insp", SubnetIDs: []string{"subnet-0insp-tgw-a", "subnet-0insp-tgw-b"}, Routes: []vpc.RouteInfo{
+				vpcRoute("100.64.0.0/16", vpc.RouteTargetLocal, "local"),
+				vpcRoute("0.0.0.0/0", vpc.RouteTargetVpcEndpoint, "vpce-0firewall"),
+			}},
+			{RouteTableID: "rtb-0insp-fw", VpcID: "vpc-0insp", SubnetIDs: []string{"subnet-0insp-fw-a"}, Routes: []vpc.RouteInfo{
+				vpcRoute("100.64.0.0/16", vpc.RouteTargetLocal, "local"),
+				vpcRoute("10.0.0.0/8", vpc.RouteTargetTransitGateway, "tgw-0a1"),
+			}},
+			{RouteTableID: "rtb-0insp-main", VpcID: "vpc-0insp", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+				vpcRoute("100.64.0.0/16", vpc.RouteTargetLocal, "local"),
+			}},
+		},
+		attachments: []vpc.TransitGatewayAttachmentInfo{
+			attachment("tgw-attach-0a1", "vpc-0a1", "tgw-rtb-0spoke"),
+			attachment("tgw-attach-0b2", "vpc-0b2", "tgw-rtb-0spoke"),
+			attachment("tgw-attach-0c3", "vpc-0c3", "tgw-rtb-0direct"),
+			func() vpc.TransitGatewayAttachmentInfo {
+				a := attachment("tgw-attach-0insp", "vpc-0insp", "tgw-rtb-0firewall", "subnet-0insp-tgw-a", "subnet-0insp-tgw-b")
+				a.ApplianceModeSupport = "enable"
+				return a
+			}(),
+			attachment("tgw-attach-0d4", "vpc-0d4", "tgw-rtb-0direct"),
+			{AttachmentID: "tgw-attach-0vpn", TransitGatewayID: "tgw-0a1", ResourceType: "vpn", ResourceID: "vpn-0office", State: "available"},
+		},
+		tgwRouteTables: []vpc.TransitGatewayRouteTableInfo{
+			{RouteTableID: "tgw-rtb-0spoke", TransitGatewayID: "tgw-0a1", Routes: []vpc.TransitGatewayRouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", Type: "static", State: "active", AttachmentIDs: []string{"tgw-attach-0insp"}},
+			}},
+			{RouteTableID: "tgw-rtb-0direct", TransitGatewayID: "tgw-0a1", Routes: []vpc.TransitGatewayRouteInfo{
+				tgwRoute("10.1.0.0/16", "tgw-attach-0a1"),
+				tgwRoute("10.3.0.0/16", "tgw-attach-0c3"),
+				tgwRoute("10.4.0.0/16", "tgw-attach-0d4"),
+			}},
+			{RouteTableID: "tgw-rtb-0firewall", TransitGatewayID: "tgw-0a1", Routes: []vpc.TransitGatewayRouteInfo{
+				tgwRoute("10.1.0.0/16", "tgw-attach-0a1"),
+				tgwRoute("10.2.0.0/16", "tgw-attach-0b2"),
+				tgwRoute("10.3.0.0/16", "tgw-attach-0c3"),
+				tgwRoute("10.4.0.0/16", "tgw-attach-0d4"),
+			}},
+		},
+	}
+}
+
+// analyze runs the inspection analysis on the fixture
+func (f *inspectionFixture) analyze() *InspectionReport {
+	return AnalyzeInspectionPaths(f.vpcs, f.routeTables, f.attachments, f.tgwRouteTables)
+}
+
+// path returns the path of a VPC pair from a report
+func (r *InspectionReport) path(t *testing.T, src, dst string) TrafficPath {
+	t.Helper()
+	for _, path := range r.Paths {
+		if path.SourceVpcID == src && path.DestinationVpcID == dst {
+			return path
+		}
+	}
+	t.Fatalf("no path from %s to %s", src, dst)
+	return TrafficPath{}
+}
+
+// TestInspectionPaths classifies every pair of spoke VPCs of the fixture: inspected both ways, bypassed between
+// the spokes associated with the direct route table, and return paths that bypass the firewall or are not routed
+func TestInspectionPaths(t *testing.T) {
+	report := newInspectionFixture().analyze()
+
+	wantMatrix := map[string]map[string]string{
+		"vpc-0a1": {"vpc-0b2": PathInspected, "vpc-0c3": PathBrokenReturnPath, "vpc-0d4": PathBrokenReturnPath},
+		"vpc-0b2": {"vpc-0a1": PathInspected, "vpc-0c3": PathBrokenReturnPath, "vpc-0d4": PathBrokenReturnPath},
+		"vpc-0c3": {"vpc-0a1": PathBrokenReturnPath, "vpc-0b2": PathUnreachable, "vpc-0d4": PathBypassed},
+		"vpc-0d4": {"vpc-0a1": PathBrokenReturnPath, "vpc-0b2": PathUnreachable, "vpc-0c3": PathBypassed},
+	}
+	if !reflect.DeepEqual(report.Matrix, wantMatrix) {
+		t.Errorf("Matrix = %v\nwant %v", report.Matrix, wantMatrix)
+	}
+	if len(report.Paths) != 12 || len(report.Findings) != 0 {
+		t.Errorf("got %d paths and findings %+v, want 12 paths and no findings", len(report.Paths), report.Findings)
+	}
+
+	tests := []struct {
+		src, dst    string
+		wantReason  string
+		wantHops    []PathHop
+		wantReturn  int // Number of return hops
+		wantInspect string
+	}{
+		{src: "vpc-0a1", dst: "vpc-0b2", wantReason: "inspected in vpc-0insp in both directions", wantInspect: "vpc-0insp",
+			wantHops: []PathHop{
+				{Type: HopVPC, ID: "vpc-0a1"},
+				{Type: HopAttachment, ID: "tgw-attach-0a1"},
+				{Type: HopRouteTable, ID: "tgw-rtb-0spoke", Detail: "0.0.0.0/0 (static)"},
+				{Type: HopAttachment, ID: "tgw-attach-0insp"},
+				{Type: HopVPC, ID: "vpc-0insp"},
+				{Type: HopFirewallEndpoint, ID: "vpce-0firewall", Detail: "10.2.0.0/16 via rtb-0insp-tgw"},
+				{Type: HopRouteTable, ID: "tgw-rtb-0firewall", Detail: "10.2.0.0/16 (propagated)"},
+				{Type: HopAttachment, ID: "tgw-attach-0b2"},
+				{Type: HopVPC, ID: "vpc-0b2"},
+			}, wantReturn: 9},
+		{src: "vpc-0c3", dst: "vpc-0d4", wantReason: "routed directly although the transit gateway sends other traffic through an inspection VPC",
+			wantHops: []PathHop{
+				{Type: HopVPC, ID: "vpc-0c3"},
+				{Type: HopAttachment, ID: "tgw-attach-0c3"},
+				{Type: HopRouteTable, ID: "tgw-rtb-0direct", Detail: "10.4.0.0/16 (propagated)"},
+				{Type: HopAttachment, ID: "tgw-attach-0d4"},
+				{Type: HopVPC, ID: "vpc-0d4"},
+			}, wantReturn: 5},
+		{src: "vpc-0c3", dst: "vpc-0a1", wantReason: "return traffic is inspected in vpc-0insp but forward traffic bypasses it", wantReturn: 9},
+		{src: "vpc-0a1", dst: "vpc-0c3", wantReason: "forward traffic is inspected in vpc-0insp but return traffic bypasses it", wantInspect: "vpc-0insp",
+			wantReturn: 5},
+		{src: "vpc-0b2", dst: "vpc-0c3", wantInspect: "vpc-0insp",
+			wantReason: "return traffic from vpc-0c3 is not routed back: transit gateway route table tgw-rtb-0direct has no route for 10.2.0.0/16",
+			wantReturn: 2},
+		{src: "vpc-0c3", dst: "vpc-0b2", wantReason: "transit gateway route table tgw-rtb-0direct has no route for 10.2.0.0/16",
+			wantHops: []PathHop{{Type: HopVPC, ID: "vpc-0c3"}, {Type: HopAttachment, ID: "tgw-attach-0c3"}}, wantReturn: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src+" to "+tt.dst, func(t *testing.T) {
+			path := report.path(t, tt.src, tt.dst)
+			if path.Reason != tt.wantReason || path.InspectionVpcID != tt.wantInspect {
+				t.Errorf("reason %q, inspection VPC %q; want %q, %q", path.Reason, path.InspectionVpcID, tt.wantReason, tt.wantInspect)
+			}
+			if tt.wantHops != nil && !reflect.DeepEqual(path.Hops, tt.wantHops) {
+				t.Errorf("Hops = %+v\nwant %+v", path.Hops, tt.wantHops)
+			}
+			if len(path.ReturnHops) != tt.wantReturn {
+				t.Errorf("got %d return hops, want %d: %+v", len(path.ReturnHops), tt.wantReturn, path.ReturnHops)
+			}
+			if want := map[string]string{"vpc-0a1": "10.1.0.0/16", "vpc-0b2": "10.2.0.0/16", "vpc-0c3": "10.3.0.0/16", "vpc-0d4": "10.4.0.0/16"}[tt.dst]; path.TransitGatewayID != "tgw-0a1" || path.Destination != want {
+				t.Errorf("path through %s to %s, want tgw-0a1 to %s", path.TransitGatewayID, path.Destination, want)
+			}
+		})
+	}
+}
+
+// TestInspectionVPCMisrouted covers inspection VPCs that do not pass traffic through a firewall endpoint
+// or do not send it back to the transit gateway
+func TestInspectionVPCMisrouted(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(f *inspectionFixture)
+		want       string
+		wantReason string
+	}{
+		{name: "no route back after the firewall", change: func(f *inspectionFixture) {
+			f.routeTables[1].Routes = f.routeTables[1].Routes[:1]
+		}, want: PathUnreachable, wantReason: "no route table in vpc-0insp sends 10.2.0.0/16 back to tgw-0a1 after the firewall"},
+		{name: "attachment subnets route straight back", change: func(f *inspectionFixture) {
+			f.routeTables[0].Routes[1].Target = vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: "tgw-0a1"}
+		}, want: PathBypassed, wantReason: "vpc-0insp forwards 10.2.0.0/16 straight back to the transit gateway without a firewall endpoint"},
+		{name: "attachment subnets route to a NAT gateway", change: func(f *inspectionFixture) {
+			f.routeTables[0].Routes[1].Target = vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0a1"}
+		}, want: PathUnreachable, wantReason: "vpc-0insp routes 10.2.0.0/16 to nat-0a1 instead of a firewall endpoint"},
+		{name: "attachment subnets use the main route table", change: func(f *inspectionFixture) {
+			f.routeTables[0].SubnetIDs = nil
+		}, want: PathUnreachable, wantReason: "route table rtb-0insp-main in vpc-0insp has no route for 10.2.0.0/16"},
+		{name: "blackhole after the firewall", change: func(f *inspectionFixture) {
+			f.tgwRouteTables[2].Routes[1].State = "blackhole"
+		}, want: PathUnreachable, wantReason: "route 10.2.0.0/16 in tgw-rtb-0firewall is a blackhole"},
+		{name: "unassociated attachment", change: func(f *inspectionFixture) {
+			f.attachments[0].Association = map[string]string{}
+		}, want: PathUnreachable, wantReason: "attachment tgw-attach-0a1 is not associated with a transit gateway route table"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newInspectionFixture()
+			tt.change(f)
+			path := f.analyze().path(t, "vpc-0a1", "vpc-0b2")
+			if path.Classification != tt.want || path.Reason != tt.wantReason {
+				t.Errorf("path = %s (%s), want %s (%s)", path.Classification, path.Reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+// TestInspectionApplianceMode checks that an inspection VPC attached without appliance mode is a high
+// severity finding, reported once however many paths pass it
+func TestInspectionApplianceMode(t *testing.T) {
+	for _, mode := range []string{"disable", ""} {
+		f := newInspectionFixture()
+		f.attachments[3].ApplianceModeSupport = mode
+		want := []InspectionFinding{{AttachmentID: "tgw-attach-0insp", TransitGatewayID: "tgw-0a1", VpcID: "vpc-0insp",
+			Classification: InspectionApplianceModeDisabled, Severity: SeverityHigh,
+			Reason: "inspection VPC vpc-0insp is attached without appliance mode; the two directions of a flow can reach firewall endpoints in different availability zones"}}
+		if got := f.analyze().Findings; !reflect.DeepEqual(got, want) {
+			t.Errorf("appliance mode %q: findings = %+v\nwant %+v", mode, got, want)
+		}
+	}
+}
+
+// TestDirectPaths checks that a transit gateway without an inspection VPC routes its VPCs directly
+func TestDirectPaths(t *testing.T) {
+	f := newInspectionFixture()
+	f.vpcs = f.vpcs[:3]
+	f.attachments = f.attachments[:3]
+	f.tgwRouteTables[1].Routes = append(f.tgwRouteTables[1].Routes, vpc.TransitGatewayRouteInfo{
+		DestinationCidrBlock: "10.2.0.0/16", State: "active", AttachmentIDs: []string{"tgw-attach-0b2"}})
+	f.tgwRouteTables[0].Routes = f.tgwRouteTables[2].Routes
+
+	report := f.analyze()
+	for _, path := range report.Paths {
+		if path.Classification != PathDirect || path.Reason != "routed directly by the transit gateway" {
+			t.Errorf("%s to %s: %s (%s), want direct", path.SourceVpcID, path.DestinationVpcID, path.Classification, path.Reason)
+		}
+	}
+	if len(report.Paths) != 6 || len(report.Findings) != 0 {
+		t.Errorf("got %d paths and %d findings, want 6 direct paths", len(report.Paths), len(report.Findings))
+	}
+}
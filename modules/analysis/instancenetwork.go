@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/vpc"
+)
+
+// NetworkDemandExceedsFree is the finding class of a subnet whose instances could claim more addresses than it has free
+const NetworkDemandExceedsFree = "eni-demand-exceeds-free"
+
+// unknownPerformance is the performance class of instances whose type could not be described
+const unknownPerformance = "unknown"
+
+// SubnetNetworkCapacity rolls up the network limits of the instances in a subnet
+type SubnetNetworkCapacity struct {
+	SubnetID             string         `json:"subnet_id"`              // ID of the subnet
+	VpcID                string         `json:"vpc_id"`                 // VPC of the subnet
+	CidrBlock            string         `json:"cidr_block"`             // IPv4 block of the subnet
+	Instances            int            `json:"instances"`              // Instances in the subnet (pending, running, stopping or stopped)
+	InstanceTypes        map[string]int `json:"instance_types"`         // Instances per instance type
+	PerformanceClasses   map[string]int `json:"performance_classes"`    // Instances per network performance class, "unknown" for undescribed types and types without one
+	IPsInUse             int            `json:"ips_in_use"`             // Private IPv4 addresses the instances hold in the subnet now
+	WorstCaseIPDemand    int            `json:"worst_case_ip_demand"`   // Addresses the instances could hold with every interface attached and filled
+	AdditionalIPDemand   int            `json:"additional_ip_demand"`   // Worst-case demand beyond the addresses held now
+	FreeAddresses        *int           `json:"free_addresses"`         // Available addresses at scan time (null if the scan did not count them)
+	Shortfall            int            `json:"shortfall"`              // Additional demand the free addresses cannot cover (0 if they can or are unknown)
+	UnknownInstanceTypes []string       `json:"unknown_instance_types"` // Types that could not be described, left out of the demand
+}
+
+// SubnetNetworkFinding reports a subnet whose worst-case address demand exceeds its free addresses
+type SubnetNetworkFinding struct {
+	Severity       string `json:"severity"`       // high if no address is free, medium otherwise
+	SubnetID       string `json:"subnet_id"`      // ID of the subnet
+	VpcID          string `json:"vpc_id"`         // VPC of the subnet
+	Classification string `json:"classification"` // eni-demand-exceeds-free
+	Reason         string `json:"reason"`         // Demand, free addresses and the shortfall
+}
+
+// SubnetNetworkReport contains the network limits of the instances of every subnet that has any
+type SubnetNetworkReport struct {
+	Subnets       []SubnetNetworkCapacity       `json:"subnets"`        // Subnets with instances, largest shortfall first
+	InstanceTypes []vpc.InstanceTypeNetworkInfo `json:"instance_types"` // Network limits of the instance types present, by name
+	Findings      []SubnetNetworkFinding        `json:"findings"`       // Subnets whose worst-case demand exceeds their free addresses
+}
+
+// AnalyzeSubnetNetworkCapacity rolls up the network performance and address limits of the instances per subnet
+// The worst-case demand of an instance is its maximum network interfaces times the IPv4 addresses per
+// interface, as if every interface were attached in the instance's subnet and filled with secondary
+// addresses, as the VPC CNI of EKS does. Interfaces can be attached in other subnets of the same
+// availability zone, so this is an upper bound; prefix delegation, which hands out /28 blocks instead
+// of single addresses, is not modelled.
+// subnets: Subnets of the scan
+// instances: Instances of the scan
+// types: Network limits by instance type; types missing from it are listed as unknown
+// Returns: Rollup of every subnet with instances and the subnets whose demand exceeds their free addresses
+func AnalyzeSubnetNetworkCapacity(subnets []vpc.SubnetInfo, instances []vpc.InstanceInfo, types map[string]vpc.InstanceTypeNetworkInfo) *SubnetNetworkReport {
+	report := &SubnetNetworkReport{
+		Subnets:       []SubnetNetworkCapacity{},
+		InstanceTypes: []vpc.InstanceTypeNetworkInfo{},
+		Findings:      []SubnetNetworkFinding{},
+	}
+	subnetsByID := make(map[string]vpc.SubnetInfo, len(subnets))
+	for _, subnet := range subnets {
+		subnetsByID[subnet.SubnetID] = subnet
+	}
+
+	rollups := make(map[string]*SubnetNetworkCapacity)
+	present := make(map[string]bool)
+	unknown := make(map[string]bool) // Subnet ID and instance type of the unknown types listed so far
+	for _, instance := range instances {
+		if instance.SubnetID == "" {
+			continue
+		}
+		rollup := rollups[instance.SubnetID]
+		if rollup == nil {
+			rollup = &SubnetNetworkCapacity{
+				SubnetID:             instance.SubnetID,
+				VpcID:                instance.VpcID,
+				InstanceTypes:        make(map[string]int),
+				PerformanceClasses:   make(map[string]int),
+				UnknownInstanceTypes: []string{},
+			}
+			if subnet, ok := subnetsByID[instance.SubnetID]; ok {
+				rollup.CidrBlock = subnet.CidrBlock
+				rollup.FreeAddresses = subnet.AvailableIpAddressCount
+			}
+			rollups[instance.SubnetID] = rollup
+		}
+		rollup.Instances++
+		rollup.InstanceTypes[instance.InstanceType]++
+		rollup.IPsInUse += instance.PrivateIPv4InUse
+
+		info, ok := types[instance.InstanceType]
+		if !ok {
+			rollup.PerformanceClasses[unknownPerformance]++
+			if !unknown[instance.SubnetID+"/"+instance.InstanceType] {
+				unknown[instance.SubnetID+"/"+instance.InstanceType] = true
+				rollup.UnknownInstanceTypes = append(rollup.UnknownInstanceTypes, instance.InstanceType)
+			}
+			continue
+		}
+		present[instance.InstanceType] = true
+		performance := info.NetworkPerformance
+		if performance == "" {
+			performance = unknownPerformance
+		}
+		rollup.PerformanceClasses[performance]++
+		// An instance already holding more than its type allows (a resized instance, say) adds no demand
+		worstCase := max(info.MaxNetworkInterfaces*info.IPv4AddressesPerInterface, instance.PrivateIPv4InUse)
+		rollup.WorstCaseIPDemand += worstCase
+		rollup.AdditionalIPDemand += worstCase - instance.PrivateIPv4InUse
+	}
+
+	for _, rollup := range rollups {
+		sort.Strings(rollup.UnknownInstanceTypes)
+		if rollup.FreeAddresses != nil {
+			rollup.Shortfall = max(rollup.AdditionalIPDemand-*rollup.FreeAddresses, 0)
+		}
+		report.Subnets = append(report.Subnets, *rollup)
+		if rollup.Shortfall == 0 {
+			continue
+		}
+		severity := SeverityMedium
+		if *rollup.FreeAddresses == 0 {
+			severity = SeverityHigh
+		}
+		report.Findings = append(report.Findings, SubnetNetworkFinding{
+			Severity:       severity,
+			SubnetID:       rollup.SubnetID,
+			VpcID:          rollup.VpcID,
+			Classification: NetworkDemandExceedsFree,
+			Reason: fmt.Sprintf("%d instance(s) in %s could claim %d more address(es) with all network interfaces attached, but only %d are free (short by %d)",
+				rollup.Instances, rollup.SubnetID, rollup.AdditionalIPDemand, *rollup.FreeAddresses, rollup.Shortfall),
+		})
+	}
+	for name := range present {
+		report.InstanceTypes = append(report.InstanceTypes, types[name])
+	}
+
+	sort.SliceStable(report.Subnets, func(i, j int) bool {
+		a, b := report.Subnets[i], report.Subnets[j]
+		if a.Shortfall != b.Shortfall {
+			return a.Shortfall > b.Shortfall
+		}
+		return a.SubnetID < b.SubnetID
+	})
+	sort.SliceStable(report.InstanceTypes, func(i, j int) bool {
+		return report.InstanceTypes[i].InstanceType < report.InstanceTypes[j].InstanceType
+	})
+	sort.SliceStable(report.Findings, func(i, j int) bool { return report.Findings[i].SubnetID < report.Findings[j].SubnetID })
+	return report
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeSubnetNetworkCapacity checks the worst-case demand, performance classes and shortfall of subnets
+// with known, unknown and resized instance types, full and uncounted subnets, and instances outside scanned subnets
+func TestAnalyzeSubnetNetworkCapacity(t *testing.T) {
+	free := func(n int) *int { return &n }
+	subnets := []vpc.SubnetInfo{
+		{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailableIpAddressCount: free(20)},
+		{SubnetID: "subnet-0b2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/28", AvailableIpAddressCount: free(0)},
+		{SubnetID: "subnet-0c3", VpcID: "vpc-0a1", CidrBlock: "10.0.3.0/24"},
+		{SubnetID: "subnet-0d4", VpcID: "vpc-0a1", CidrBlock: "10.0.4.0/24", AvailableIpAddressCount: free(251)},
+	}
+	types := map[string]vpc.InstanceTypeNetworkInfo{
+		"m5.large":     {InstanceType: "m5.large", NetworkPerformance: "Up to 10 Gigabit", MaxNetworkInterfaces: 3, IPv4AddressesPerInterface: 10},
+		"c5n.18xlarge": {InstanceType: "c5n.18xlarge", NetworkPerformance: "100 Gigabit", MaxNetworkInterfaces: 15, IPv4AddressesPerInterface: 50},
+		"t3.nano":      {InstanceType: "t3.nano", NetworkPerformance: "Up to 5 Gigabit", MaxNetworkInterfaces: 2, IPv4AddressesPerInterface: 2},
+		"x1.custom":    {InstanceType: "x1.custom", MaxNetworkInterfaces: 1, IPv4AddressesPerInterface: 1},
+		"r5.large":     {InstanceType: "r5.large", NetworkPerformance: "Up to 10 Gigabit", MaxNetworkInterfaces: 3, IPv4AddressesPerInterface: 10},
+	}
+	instance := func(id, subnetID, instanceType string, inUse int) vpc.InstanceInfo {
+		return vpc.InstanceInfo{InstanceID: id, SubnetID: subnetID, VpcID: "vpc-0a1", InstanceType: instanceType, PrivateIPv4InUse: inUse}
+	}
+	instances := []vpc.InstanceInfo{
+		instance("i-1", "subnet-0a1", "m5.large", 2),
+		instance("i-2", "subnet-0a1", "m5.large", 1),
+		// Holds more addresses than its type allows, so it adds no demand
+		instance("i-3", "subnet-0a1", "t3.nano", 6),
+		instance("i-4", "subnet-0a1", "r9.future", 1),
+		instance("i-5", "subnet-0a1", "r9.future", 1),
+		instance("i-6", "subnet-0b2", "t3.nano", 1),
+		instance("i-7", "subnet-0c3", "c5n.18xlarge", 1),
+		instance("i-8", "", "m5.large", 1),
+		instance("i-9", "subnet-0gone", "x1.custom", 1),
+	}
+
+	report := AnalyzeSubnetNetworkCapacity(subnets, instances, types)
+	want := []SubnetNetworkCapacity{
+		{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", Instances: 5,
+			InstanceTypes:      map[string]int{"m5.large": 2, "t3.nano": 1, "r9.future": 2},
+			PerformanceClasses: map[string]int{"Up to 10 Gigabit": 2, "Up to 5 Gigabit": 1, "unknown": 2},
+			IPsInUse:           11, WorstCaseIPDemand: 66, AdditionalIPDemand: 57, FreeAddresses: free(20), Shortfall: 37,
+			UnknownInstanceTypes: []string{"r9.future"}},
+		{SubnetID: "subnet-0b2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/28", Instances: 1,
+			InstanceTypes: map[string]int{"t3.nano": 1}, PerformanceClasses: map[string]int{"Up to 5 Gigabit": 1},
+			IPsInUse: 1, WorstCaseIPDemand: 4, AdditionalIPDemand: 3, FreeAddresses: free(0), Shortfall: 3, UnknownInstanceTypes: []string{}},
+		{SubnetID: "subnet-0c3", VpcID: "vpc-0a1", CidrBlock: "10.0.3.0/24", Instances: 1,
+			InstanceTypes: map[string]int{"c5n.18xlarge": 1}, PerformanceClasses: map[string]int{"100 Gigabit": 1},
+			IPsInUse: 1, WorstCaseIPDemand: 750, AdditionalIPDemand: 749, UnknownInstanceTypes: []string{}},
+		{SubnetID: "subnet-0gone", VpcID: "vpc-0a1", Instances: 1,
+			InstanceTypes: map[string]int{"x1.custom": 1}, PerformanceClasses: map[string]int{"unknown": 1},
+			IPsInUse: 1, WorstCaseIPDemand: 1, UnknownInstanceTypes: []string{}},
+	}
+	if !reflect.DeepEqual(report.Subnets, want) {
+		t.Errorf("Subnets = %+v\nwant %+v", report.Subnets, want)
+	}
+
+	var present []string
+	for _, info := range report.InstanceTypes {
+		present = append(present, info.InstanceType)
+	}
+	if want := []string{"c5n.18xlarge", "m5.large", "t3.nano", "x1.custom"}; !reflect.DeepEqual(present, want) {
+		t.Errorf("InstanceTypes = %v, want the types present: %v", present, want)
+	}
+
+	wantFindings := []SubnetNetworkFinding{
+		{Severity: SeverityMedium, SubnetID: "subnet-0a1", VpcID: "vpc-0a1", Classification: NetworkDemandExceedsFree,
+			Reason: "5 instance(s) in subnet-0a1 could claim 57 more address(es) with all network interfaces attached, but only 20 are free (short by 37)"},
+		{Severity: SeverityHigh, SubnetID: "subnet-0b2", VpcID: "vpc-0a1", Classification: NetworkDemandExceedsFree,
+			Reason: "1 instance(s) in subnet-0b2 could claim 3 more address(es) with all network interfaces attached, but only 0 are free (short by 3)"},
+	}
+	if !reflect.DeepEqual(report.Findings, wantFindings) {
+		t.Errorf("Findings = %+v\nwant %+v", report.Findings, wantFindings)
+	}
+
+	empty := AnalyzeSubnetNetworkCapacity(subnets, nil, types)
+	if empty.Subnets == nil || empty.InstanceTypes == nil || empty.Findings == nil || len(empty.Subnets)+len(empty.InstanceTypes)+len(empty.Findings) != 0 {
+		t.Errorf("report without instances = %+v, want empty lists", empty)
+	}
+}
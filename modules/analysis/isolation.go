@@ -0,0 +1,226 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/vpc"
+)
+
+// VPC internet connectivity classes
+const (
+	ConnectivityInternet  = "internet-connected" // A route table sends traffic to an internet gateway, egress-only internet gateway or public NAT gateway
+	ConnectivityHubEgress = "egress-via-hub"     // The default route leads through a transit gateway, Cloud WAN or VPN gateway to another network's egress
+	ConnectivityIsolated  = "isolated"           // No route leads to the internet
+)
+
+// IsolationTag is the VPC tag that declares whether a VPC is meant to be isolated
+const IsolationTag = "aws-documentor:isolation"
+
+// Values of IsolationTag
+const (
+	IsolationRequired  = "required"  // The VPC must have no internet path
+	IsolationForbidden = "forbidden" // The VPC must reach the internet, directly or through a hub
+)
+
+// Isolation finding classes
+const (
+	IsolationBreached   = "isolation-breached"    // A VPC tagged isolation=required has an internet path
+	IsolationNoEgress   = "connected-no-egress"   // A VPC tagged isolation=forbidden has no internet path
+	IsolationInvalidTag = "isolation-tag-invalid" // The isolation tag has a value other than required or forbidden
+)
+
+// VPCConnectivity is the internet connectivity class of a VPC with the routes it was derived from
+type VPCConnectivity struct {
+	VpcID          string   `json:"vpc_id"`         // ID of the VPC
+	Classification string   `json:"classification"` // internet-connected, egress-via-hub or isolated
+	Intent         string   `json:"intent"`         // Value of the aws-documentor:isolation tag (empty if untagged)
+	EgressPaths    []string `json:"egress_paths"`   // Routes leading to the internet ("rtb-1 0.0.0.0/0 -> igw-1")
+	Reason         string   `json:"reason"`         // Human-readable explanation of the classification
+}
+
+// IsolationFinding describes a VPC whose connectivity contradicts its isolation tag
+type IsolationFinding struct {
+	VpcID          string `json:"vpc_id"`         // ID of the VPC
+	Intent         string `json:"intent"`         // Value of the aws-documentor:isolation tag
+	Connectivity   string `json:"connectivity"`   // Actual connectivity class of the VPC
+	Classification string `json:"classification"` // isolation-breached, connected-no-egress or isolation-tag-invalid
+	Severity       string `json:"severity"`       // high for breached isolation, medium for missing egress, low for invalid tags
+	Reason         string `json:"reason"`         // Human-readable explanation
+}
+
+// IsolationReport contains the connectivity class of every VPC and the intent findings
+type IsolationReport struct {
+	VPCs     []VPCConnectivity  `json:"vpcs"`     // One entry per VPC, by VPC ID
+	Findings []IsolationFinding `json:"findings"` // VPCs whose connectivity contradicts their isolation tag
+}
+
+// AnalyzeIsolation classifies the internet connectivity of every VPC and checks it against the isolation tags
+// A VPC is internet-connected when any active route of its route tables targets an internet gateway, an
+// egress-only internet gateway, a carrier gateway or a public NAT gateway. Otherwise a default route to a
+// transit gateway is followed through the route table of the VPC's attachment: it is egress via a hub
+// when it reaches the attachment of an internet-connected VPC. Without transit gateway route tables the
+// hub is assumed to provide egress. Default routes to Cloud WAN or a VPN gateway count as hub egress
+// as well, since the far side is not scanned. Everything else is isolated, which is only a finding when
+// the VPC is tagged isolation=forbidden; untagged isolated VPCs are taken to be deliberate.
+// vpcs: VPCs from the VPC scan
+// routeTables: VPC route tables from the VPC scan
+// natGateways: NAT gateways, to tell public from private NAT
+// attachments: Transit gateway attachments
+// tgwRouteTables: Transit gateway route tables with their routes (nil if not scanned)
+// Returns: Report with the class of every VPC and the intent findings
+func AnalyzeIsolation(vpcs []vpc.VPCInfo, routeTables []vpc.RouteTableInfo, natGateways []vpc.NatGatewayInfo, attachments []vpc.TransitGatewayAttachmentInfo, tgwRouteTables []vpc.TransitGatewayRouteTableInfo) *IsolationReport {
+	report := &IsolationReport{
+		VPCs:     []VPCConnectivity{},
+		Findings: []IsolationFinding{},
+	}
+	g := newTransitGraph(vpcs, routeTables, attachments, tgwRouteTables)
+
+	privateNAT := make(map[string]bool)
+	for _, ngw := range natGateways {
+		privateNAT[ngw.NatGatewayID] = ngw.ConnectivityType == "private"
+	}
+
+	sorted := append([]vpc.VPCInfo{}, vpcs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].VpcID < sorted[j].VpcID })
+
+	// Direct internet paths first, since hub egress depends on the class of the hub VPC
+	classes := make(map[string]*VPCConnectivity, len(sorted))
+	for _, v := range sorted {
+		c := &VPCConnectivity{VpcID: v.VpcID, Intent: v.Tags[IsolationTag], EgressPaths: []string{}}
+		for _, rt := range g.vpcRouteTables[v.VpcID] {
+			for _, route := range rt.Routes {
+				if route.State == "blackhole" || !internetTarget(route.Target, privateNAT) {
+					continue
+				}
+				c.EgressPaths = append(c.EgressPaths, fmt.Sprintf("%s %s -> %s", rt.RouteTableID, routeDestination(route), route.Target.ID))
+			}
+		}
+		if len(c.EgressPaths) > 0 {
+			c.Classification = ConnectivityInternet
+			c.Reason = fmt.Sprintf("%d route(s) lead to an internet gateway or public NAT gateway", len(c.EgressPaths))
+		}
+		classes[v.VpcID] = c
+	}
+
+	for _, v := range sorted {
+		c := classes[v.VpcID]
+		if c.Classification == "" {
+			g.classifyHubEgress(c, classes, tgwRouteTables != nil)
+		}
+		report.VPCs = append(report.VPCs, *c)
+
+		if finding, ok := isolationFinding(*c); ok {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	return report
+}
+
+// classifyHubEgress classifies a VPC without a direct internet path by its default routes
+// routesKnown: Whether transit gateway route tables were scanned, so hub paths can be verified
+func (g *transitGraph) classifyHubEgress(c *VPCConnectivity, classes map[string]*VPCConnectivity, routesKnown bool) {
+	var dead []string
+	for _, rt := range g.vpcRouteTables[c.VpcID] {
+		for _, route := range rt.Routes {
+			if route.State == "blackhole" || (route.DestinationCidrBlock != "0.0.0.0/0" && route.DestinationIpv6Block != "::/0") {
+				continue
+			}
+			prefix := fmt.Sprintf("%s %s -> %s", rt.RouteTableID, routeDestination(route), route.Target.ID)
+			switch route.Target.Type {
+			case vpc.RouteTargetCoreNetwork, vpc.RouteTargetVpnGateway:
+				c.EgressPaths = append(c.EgressPaths, prefix+" (not scanned beyond)")
+			case vpc.RouteTargetTransitGateway:
+				if !routesKnown {
+					c.EgressPaths = append(c.EgressPaths, prefix+" (transit gateway routes not scanned)")
+					continue
+				}
+				hub, reason := g.hubEgress(c.VpcID, route.Target.ID, classes)
+				if hub == "" {
+					dead = append(dead, prefix+": "+reason)
+					continue
+				}
+				c.EgressPaths = append(c.EgressPaths, prefix+" -> "+hub)
+			}
+		}
+	}
+
+	switch {
+	case len(c.EgressPaths) > 0:
+		c.Classification = ConnectivityHubEgress
+		c.Reason = "no internet gateway or public NAT gateway; the default route leads to another network's egress"
+	case len(dead) > 0:
+		c.Classification = ConnectivityIsolated
+		c.Reason = "the default route leads to a transit gateway without an egress path: " + strings.Join(dead, "; ")
+	default:
+		c.Classification = ConnectivityIsolated
+		c.Reason = "no route leads to an internet gateway, NAT gateway or hub; no internet path"
+	}
+}
+
+// hubEgress follows the default route of a VPC's transit gateway attachment
+// Returns: ID of the internet-connected VPC the transit gateway sends the default route to, or empty and the reason
+func (g *transitGraph) hubEgress(vpcID, tgwID string, classes map[string]*VPCConnectivity) (string, string) {
+	attachment, ok := g.vpcAttachments[tgwID][vpcID]
+	if !ok {
+		return "", fmt.Sprintf("the VPC has no available attachment to %s", tgwID)
+	}
+	next, reason := g.nextAttachment(&pathTrace{}, attachment, "0.0.0.0/0")
+	if next == nil {
+		return "", reason
+	}
+	if next.ResourceType != "vpc" {
+		return "", fmt.Sprintf("%s sends the default route to %s attachment %s, which is not scanned", tgwID, next.ResourceType, next.AttachmentID)
+	}
+	hub, ok := classes[next.ResourceID]
+	if !ok || hub.Classification != ConnectivityInternet {
+		return "", fmt.Sprintf("%s sends the default route to %s, which has no internet path", tgwID, next.ResourceID)
+	}
+	return next.ResourceID, ""
+}
+
+// internetTarget reports whether a route target gives direct internet access
+func internetTarget(target vpc.RouteTarget, privateNAT map[string]bool) bool {
+	switch target.Type {
+	case vpc.RouteTargetInternetGateway, vpc.RouteTargetEgressOnlyInternetGateway, vpc.RouteTargetCarrierGateway:
+		return true
+	case vpc.RouteTargetNatGateway:
+		return !privateNAT[target.ID]
+	}
+	return false
+}
+
+// routeDestination returns the IPv4 or IPv6 destination of a route
+func routeDestination(route vpc.RouteInfo) string {
+	if route.DestinationCidrBlock != "" {
+		return route.DestinationCidrBlock
+	}
+	return route.DestinationIpv6Block
+}
+
+// isolationFinding checks the connectivity of a VPC against its isolation tag
+// Returns: The finding, and whether there is one
+func isolationFinding(c VPCConnectivity) (IsolationFinding, bool) {
+	finding := IsolationFinding{VpcID: c.VpcID, Intent: c.Intent, Connectivity: c.Classification}
+	switch {
+	case c.Intent == "":
+		return finding, false
+	case c.Intent == IsolationRequired && c.Classification != ConnectivityIsolated:
+		finding.Classification = IsolationBreached
+		finding.Severity = SeverityHigh
+		finding.Reason = fmt.Sprintf("%s is tagged %s=%s but is %s: %s", c.VpcID, IsolationTag, c.Intent, c.Classification, strings.Join(c.EgressPaths, ", "))
+	case c.Intent == IsolationForbidden && c.Classification == ConnectivityIsolated:
+		finding.Classification = IsolationNoEgress
+		finding.Severity = SeverityMedium
+		finding.Reason = fmt.Sprintf("%s is tagged %s=%s but has no internet path, so workloads that need updates or external APIs fail: %s", c.VpcID, IsolationTag, c.Intent, c.Reason)
+	case c.Intent != IsolationRequired && c.Intent != IsolationForbidden:
+		finding.Classification = IsolationInvalidTag
+		finding.Severity = SeverityLow
+		finding.Reason = fmt.Sprintf("%s has %s=%q; expected %s or %s, so its connectivity is not checked", c.VpcID, IsolationTag, c.Intent, IsolationRequired, IsolationForbidden)
+	default:
+		return finding, false
+	}
+	return finding, true
+}
@@ -0,0 +1,203 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// isolationFixture is a transit gateway hub with an egress VPC and one VPC per way of reaching, or not
+// reaching, the internet
+type isolationFixture struct {
+	vpcs           []vpc.VPCInfo
+	routeTables    []vpc.RouteTableInfo
+	natGateways    []vpc.NatGatewayInfo
+	attachments    []vpc.TransitGatewayAttachmentInfo
+	tgwRouteTables []vpc.TransitGatewayRouteTableInfo
+}
+
+// newIsolationFixture returns VPCs whose isolation tag is given per VPC ID ("" leaves a VPC untagged)
+// The spokes send their default route to tgw-0a1, which forwards it to the egress VPC from tgw-rtb-0spoke
+// and drops it in tgw-rtb-0closed.
+func newIsolationFixture(intents map[string]string) *isolationFixture {
+	f := &isolationFixture{
+		natGateways: []vpc.NatGatewayInfo{
+			{NatGatewayID: "nat-0public", VpcID: "vpc-0nat", ConnectivityType: "public"},
+			{NatGatewayID: "nat-0private", VpcID: "vpc-0private", ConnectivityType: "private"},
+		},
+		attachments: []vpc.TransitGatewayAttachmentInfo{
+			{AttachmentID: "tgw-attach-0egress", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0egress",
+				State: "available", Association: map[string]string{"route_table_id": "tgw-rtb-0egress"}},
+			{AttachmentID: "tgw-attach-0spoke", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0spoke",
+				State: "available", Association: map[string]string{"route_table_id": "tgw-rtb-0spoke"}},
+			{AttachmentID: "tgw-attach-0closed", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0closed",
+				State: "available", Association: map[string]string{"route_table_id": "tgw-rtb-0closed"}},
+			{AttachmentID: "tgw-attach-0vpn", TransitGatewayID: "tgw-0a1", ResourceType: "vpn", ResourceID: "vpn-0office", State: "available"},
+			{AttachmentID: "tgw-attach-0onprem", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0onprem",
+				State: "available", Association: map[string]string{"route_table_id": "tgw-rtb-0onprem"}},
+		},
+		tgwRouteTables: []vpc.TransitGatewayRouteTableInfo{
+			{RouteTableID: "tgw-rtb-0spoke", TransitGatewayID: "tgw-0a1", Routes: []vpc.TransitGatewayRouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", Type: "static", State: "active", AttachmentIDs: []string{"tgw-attach-0egress"}},
+			}},
+			{RouteTableID: "tgw-rtb-0closed", TransitGatewayID: "tgw-0a1", Routes: []vpc.TransitGatewayRouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", Type: "static", State: "blackhole"},
+			}},
+			{RouteTableID: "tgw-rtb-0onprem", TransitGatewayID: "tgw-0a1", Routes: []vpc.TransitGatewayRouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", Type: "static", State: "active", AttachmentIDs: []string{"tgw-attach-0vpn"}},
+			}},
+		},
+	}
+
+	routes := map[string][]vpc.RouteInfo{
+		"vpc-0egress":    {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0egress"}, State: "active"}},
+		"vpc-0nat":       {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0public"}, State: "active"}},
+		"vpc-0ipv6":      {{DestinationIpv6Block: "::/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetEgressOnlyInternetGateway, ID: "eigw-0a1"}, State: "active"}},
+		"vpc-0private":   {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0private"}, State: "active"}},
+		"vpc-0blackhole": {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0deleted"}, State: "blackhole"}},
+		"vpc-0spoke":     {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: "tgw-0a1"}, State: "active"}},
+		"vpc-0closed":    {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: "tgw-0a1"}, State: "active"}},
+		"vpc-0onprem":    {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: "tgw-0a1"}, State: "active"}},
+		"vpc-0detached":  {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: "tgw-0a1"}, State: "active"}},
+		"vpc-0wan":       {{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetCoreNetwork, ID: "core-network-0a1"}, State: "active"}},
+		"vpc-0vault":     {{DestinationCidrBlock: "10.9.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetVpcPeeringConnection, ID: "pcx-0a1"}, State: "active"}},
+	}
+	for vpcID, vpcRoutes := range routes {
+		v := vpc.VPCInfo{VpcID: vpcID, Tags: map[string]string{}}
+		if intent := intents[vpcID]; intent != "" {
+			v.Tags[IsolationTag] = intent
+		}
+		f.vpcs = append(f.vpcs, v)
+		f.routeTables = append(f.routeTables, vpc.RouteTableInfo{RouteTableID: "rtb" + vpcID[3:], VpcID: vpcID, IsMainRouteTable: true,
+			Routes: append([]vpc.RouteInfo{{DestinationCidrBlock: "10.0.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}, State: "active"}}, vpcRoutes...)})
+	}
+	return f
+}
+
+// analyze runs the isolation analysis on the fixture
+func (f *isolationFixture) analyze() *IsolationReport {
+	return AnalyzeIsolation(f.vpcs, f.routeTables, f.natGateways, f.attachments, f.tgwRouteTables)
+}
+
+// connectivity returns the class of a VPC from a report
+func (r *IsolationReport) connectivity(t *testing.T, vpcID string) VPCConnectivity {
+	t.Helper()
+	for _, c := range r.VPCs {
+		if c.VpcID == vpcID {
+			return c
+		}
+	}
+	t.Fatalf("no connectivity for %s", vpcID)
+	return VPCConnectivity{}
+}
+
+// TestAnalyzeIsolation checks the class and egress paths of every kind of VPC
+func TestAnalyzeIsolation(t *testing.T) {
+	report := newIsolationFixture(nil).analyze()
+	tests := []struct {
+		vpcID     string
+		want      string
+		wantPaths []string
+		wantWhy   string // Reason of isolated VPCs
+	}{
+		{vpcID: "vpc-0egress", want: ConnectivityInternet, wantPaths: []string{"rtb-0egress 0.0.0.0/0 -> igw-0egress"}},
+		{vpcID: "vpc-0nat", want: ConnectivityInternet, wantPaths: []string{"rtb-0nat 0.0.0.0/0 -> nat-0public"}},
+		{vpcID: "vpc-0ipv6", want: ConnectivityInternet, wantPaths: []string{"rtb-0ipv6 ::/0 -> eigw-0a1"}},
+		{vpcID: "vpc-0spoke", want: ConnectivityHubEgress, wantPaths: []string{"rtb-0spoke 0.0.0.0/0 -> tgw-0a1 -> vpc-0egress"}},
+		{vpcID: "vpc-0wan", want: ConnectivityHubEgress, wantPaths: []string{"rtb-0wan 0.0.0.0/0 -> core-network-0a1 (not scanned beyond)"}},
+		{vpcID: "vpc-0private", want: ConnectivityIsolated, wantPaths: []string{},
+			wantWhy: "no route leads to an internet gateway, NAT gateway or hub; no internet path"},
+		{vpcID: "vpc-0blackhole", want: ConnectivityIsolated, wantPaths: []string{},
+			wantWhy: "no route leads to an internet gateway, NAT gateway or hub; no internet path"},
+		{vpcID: "vpc-0vault", want: ConnectivityIsolated, wantPaths: []string{},
+			wantWhy: "no route leads to an internet gateway, NAT gateway or hub; no internet path"},
+		{vpcID: "vpc-0closed", want: ConnectivityIsolated, wantPaths: []string{},
+			wantWhy: "the default route leads to a transit gateway without an egress path: rtb-0closed 0.0.0.0/0 -> tgw-0a1: route 0.0.0.0/0 in tgw-rtb-0closed is a blackhole"},
+		{vpcID: "vpc-0onprem", want: ConnectivityIsolated, wantPaths: []string{},
+			wantWhy: "the default route leads to a transit gateway without an egress path: rtb-0onprem 0.0.0.0/0 -> tgw-0a1: tgw-0a1 sends the default route to vpn attachment tgw-attach-0vpn, which is not scanned"},
+		{vpcID: "vpc-0detached", want: ConnectivityIsolated, wantPaths: []string{},
+			wantWhy: "the default route leads to a transit gateway without an egress path: rtb-0detached 0.0.0.0/0 -> tgw-0a1: the VPC has no available attachment to tgw-0a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.vpcID, func(t *testing.T) {
+			c := report.connectivity(t, tt.vpcID)
+			if c.Classification != tt.want {
+				t.Errorf("classification = %s, want %s (%s)", c.Classification, tt.want, c.Reason)
+			}
+			if !reflect.DeepEqual(c.EgressPaths, tt.wantPaths) {
+				t.Errorf("egress paths = %q, want %q", c.EgressPaths, tt.wantPaths)
+			}
+			if tt.wantWhy != "" && c.Reason != tt.wantWhy {
+				t.Errorf("reason = %q, want %q", c.Reason, tt.wantWhy)
+			}
+		})
+	}
+	for i := 1; i < len(report.VPCs); i++ {
+		if report.VPCs[i-1].VpcID >= report.VPCs[i].VpcID {
+			t.Errorf("VPCs are not sorted by ID: %s before %s", report.VPCs[i-1].VpcID, report.VPCs[i].VpcID)
+		}
+	}
+}
+
+// TestIsolationWithoutTransitGatewayRoutes checks that a default route to a transit gateway counts as hub
+// egress when the transit gateway route tables were not scanned
+func TestIsolationWithoutTransitGatewayRoutes(t *testing.T) {
+	f := newIsolationFixture(nil)
+	f.tgwRouteTables = nil
+	report := f.analyze()
+	for _, vpcID := range []string{"vpc-0spoke", "vpc-0closed", "vpc-0detached"} {
+		c := report.connectivity(t, vpcID)
+		want := []string{"rtb" + vpcID[3:] + " 0.0.0.0/0 -> tgw-0a1 (transit gateway routes not scanned)"}
+		if c.Classification != ConnectivityHubEgress || !reflect.DeepEqual(c.EgressPaths, want) {
+			t.Errorf("%s = %s %q, want %s %q", vpcID, c.Classification, c.EgressPaths, ConnectivityHubEgress, want)
+		}
+	}
+}
+
+// TestIsolationFindings checks every combination of isolation tag and connectivity class
+func TestIsolationFindings(t *testing.T) {
+	tests := []struct {
+		vpcID        string
+		intent       string
+		connectivity string
+		wantFinding  string // Empty when the connectivity matches the intent
+		wantSeverity string
+		wantReason   string
+	}{
+		{vpcID: "vpc-0private", connectivity: ConnectivityIsolated},
+		{vpcID: "vpc-0egress", connectivity: ConnectivityInternet},
+		{vpcID: "vpc-0private", intent: IsolationRequired, connectivity: ConnectivityIsolated},
+		{vpcID: "vpc-0nat", intent: IsolationRequired, connectivity: ConnectivityInternet, wantFinding: IsolationBreached, wantSeverity: SeverityHigh,
+			wantReason: "vpc-0nat is tagged aws-documentor:isolation=required but is internet-connected: rtb-0nat 0.0.0.0/0 -> nat-0public"},
+		{vpcID: "vpc-0spoke", intent: IsolationRequired, connectivity: ConnectivityHubEgress, wantFinding: IsolationBreached, wantSeverity: SeverityHigh,
+			wantReason: "vpc-0spoke is tagged aws-documentor:isolation=required but is egress-via-hub: rtb-0spoke 0.0.0.0/0 -> tgw-0a1 -> vpc-0egress"},
+		{vpcID: "vpc-0egress", intent: IsolationForbidden, connectivity: ConnectivityInternet},
+		{vpcID: "vpc-0spoke", intent: IsolationForbidden, connectivity: ConnectivityHubEgress},
+		{vpcID: "vpc-0closed", intent: IsolationForbidden, connectivity: ConnectivityIsolated, wantFinding: IsolationNoEgress, wantSeverity: SeverityMedium,
+			wantReason: "vpc-0closed is tagged aws-documentor:isolation=forbidden but has no internet path, so workloads that need updates or external APIs fail: the default route leads to a transit gateway without an egress path: rtb-0closed 0.0.0.0/0 -> tgw-0a1: route 0.0.0.0/0 in tgw-rtb-0closed is a blackhole"},
+		{vpcID: "vpc-0vault", intent: "yes", connectivity: ConnectivityIsolated, wantFinding: IsolationInvalidTag, wantSeverity: SeverityLow,
+			wantReason: `vpc-0vault has aws-documentor:isolation="yes"; expected required or forbidden, so its connectivity is not checked`},
+		{vpcID: "vpc-0egress", intent: "Required", connectivity: ConnectivityInternet, wantFinding: IsolationInvalidTag, wantSeverity: SeverityLow,
+			wantReason: `vpc-0egress has aws-documentor:isolation="Required"; expected required or forbidden, so its connectivity is not checked`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.vpcID+" "+tt.intent, func(t *testing.T) {
+			report := newIsolationFixture(map[string]string{tt.vpcID: tt.intent}).analyze()
+			if c := report.connectivity(t, tt.vpcID); c.Classification != tt.connectivity || c.Intent != tt.intent {
+				t.Fatalf("%s = %s with intent %q, want %s with %q", tt.vpcID, c.Classification, c.Intent, tt.connectivity, tt.intent)
+			}
+			if tt.wantFinding == "" {
+				if len(report.Findings) != 0 {
+					t.Errorf("findings = %+v, want none", report.Findings)
+				}
+				return
+			}
+			want := []IsolationFinding{{VpcID: tt.vpcID, Intent: tt.intent, Connectivity: tt.connectivity,
+				Classification: tt.wantFinding, Severity: tt.wantSeverity, Reason: tt.wantReason}}
+			if !reflect.DeepEqual(report.Findings, want) {
+				t.Errorf("findings = %+v\nwant %+v", report.Findings, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"fmt"
+
+	"aws-documentor/modules/vpc"
+)
+
+// LocalRouteStale classifies a local route whose destination matches no CIDR block associated with the VPC
+const LocalRouteStale = "stale-local-route"
+
+// LocalRouteFinding describes a local route left behind after its CIDR block was disassociated
+type LocalRouteFinding struct {
+	RouteTableID   string `json:"route_table_id"` // ID of the route table containing the route
+	VpcID          string `json:"vpc_id"`         // ID of the VPC of the route table
+	Destination    string `json:"destination"`    // Destination CIDR block of the route
+	Classification string `json:"classification"` // Always stale-local-route
+	Severity       string `json:"severity"`       // Always medium
+	Reason         string `json:"reason"`         // Human-readable explanation
+}
+
+// AnalyzeLocalRoutes flags local routes classified as local-unassociated-cidr by vpc.ClassifyLocalRoutes
+// Such a route claims an address range for the VPC that no longer belongs to it, so traffic to that
+// range cannot be routed elsewhere and reviewers cannot tell which block it came from.
+// routeTables: Route tables with local routes classified
+// Returns: Findings in route table order
+func AnalyzeLocalRoutes(routeTables []vpc.RouteTableInfo) []LocalRouteFinding {
+	findings := []LocalRouteFinding{}
+
+	for _, rt := range routeTables {
+		for _, route := range rt.Routes {
+			if route.RouteType != vpc.RouteTypeLocalUnassociatedCIDR {
+				continue
+			}
+			destination := route.DestinationCidrBlock
+			if destination == "" {
+				destination = route.DestinationIpv6Block
+			}
+			findings = append(findings, LocalRouteFinding{
+				RouteTableID:   rt.RouteTableID,
+				VpcID:          rt.VpcID,
+				Destination:    destination,
+				Classification: LocalRouteStale,
+				Severity:       SeverityMedium,
+				Reason:         fmt.Sprintf("local route for %s matches no CIDR block associated with %s; it is likely stale after the block was disassociated", destination, rt.VpcID),
+			})
+		}
+	}
+
+	return findings
+}
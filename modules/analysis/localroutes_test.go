@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeLocalRoutes checks that only local routes matching no VPC CIDR block are flagged, IPv6 ones included
+func TestAnalyzeLocalRoutes(t *testing.T) {
+	local := vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}
+	routeTables := []vpc.RouteTableInfo{
+		{RouteTableID: "rtb-0single", VpcID: "vpc-0a1", Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalPrimaryCIDR},
+		}},
+		{RouteTableID: "rtb-0multi", VpcID: "vpc-0b2", Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.1.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalPrimaryCIDR},
+			{DestinationCidrBlock: "100.64.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalSecondaryCIDR},
+			{DestinationIpv6Block: "2001:db8:1234:1a00::/56", Target: local, RouteType: vpc.RouteTypeLocalIPv6CIDR},
+		}},
+		{RouteTableID: "rtb-0stale", VpcID: "vpc-0c3", Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.2.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalPrimaryCIDR},
+			{DestinationCidrBlock: "172.16.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalUnassociatedCIDR},
+			{DestinationIpv6Block: "2001:db8:5678::/56", Target: local, RouteType: vpc.RouteTypeLocalUnassociatedCIDR},
+		}},
+	}
+
+	want := []LocalRouteFinding{
+		{RouteTableID: "rtb-0stale", VpcID: "vpc-0c3", Destination: "172.16.0.0/16", Classification: LocalRouteStale, Severity: SeverityMedium,
+			Reason: "local route for 172.16.0.0/16 matches no CIDR block associated with vpc-0c3; it is likely stale after the block was disassociated"},
+		{RouteTableID: "rtb-0stale", VpcID: "vpc-0c3", Destination: "2001:db8:5678::/56", Classification: LocalRouteStale, Severity: SeverityMedium,
+			Reason: "local route for 2001:db8:5678::/56 matches no CIDR block associated with vpc-0c3; it is likely stale after the block was disassociated"},
+	}
+	if got := AnalyzeLocalRoutes(routeTables); !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeLocalRoutes() = %+v\nwant %+v", got, want)
+	}
+	if got := AnalyzeLocalRoutes(routeTables[:2]); got == nil || len(got) != 0 {
+		t.Errorf("findings without stale routes = %#v, want an empty list", got)
+	}
+}
@@ -0,0 +1,241 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Managers of resources, the values of the managed_by fields
+const (
+	ManagerEKSLBController = "eks-lb-controller" // AWS Load Balancer Controller of an EKS cluster
+	ManagerKarpenter       = "karpenter"         // Karpenter node provisioning
+	ManagerCloudFormation  = "cloudformation"    // A CloudFormation stack
+	ManagerTerraform       = "terraform"         // Terraform, recognized by the tags of its default_tags conventions
+	ManagerEKS             = "eks"               // EKS or a controller of the cluster, from the cluster ownership tags
+	ManagerManual          = "manual"            // No rule matched: created by hand or by a tool that leaves no trace
+)
+
+// ManagerRule recognizes the resources of a manager by a tag, a description or both
+// Patterns are case-insensitive and * matches any characters, slashes included.
+type ManagerRule struct {
+	Manager     string `json:"manager"`     // Label set on matching resources
+	TagKey      string `json:"tag_key"`     // Pattern of a tag key the resource must have ("" to match on the description only)
+	TagValue    string `json:"tag_value"`   // Pattern of the value of that tag ("" for any value)
+	Description string `json:"description"` // Pattern of the description of security groups ("" to match on the tag only)
+}
+
+// ManagerRules is the -managed-by-rules file
+type ManagerRules struct {
+	Rules []ManagerRule `json:"rules"` // Rules checked before the built-in ones; the first rule that matches wins
+}
+
+// BuiltinManagerRules recognize the common controllers and infrastructure-as-code tools, in order of precedence
+// Controllers come first: their resources also carry the cluster ownership tag, and the Terraform and
+// CloudFormation tags only reach resources those tools create themselves. Discovery tags such as
+// kubernetes.io/cluster/NAME=shared and karpenter.sh/discovery mark resources a controller uses, not
+// ones it created, so they do not match.
+var BuiltinManagerRules = []ManagerRule{
+	{Manager: ManagerEKSLBController, TagKey: "elbv2.k8s.aws/cluster"},
+	{Manager: ManagerEKSLBController, TagKey: "ingress.k8s.aws/*"},
+	{Manager: ManagerEKSLBController, TagKey: "service.k8s.aws/*"},
+	{Manager: ManagerEKSLBController, Description: "[k8s] Managed SecurityGroup for LoadBalancer"},
+	{Manager: ManagerEKSLBController, Description: "[k8s] Shared Backend SecurityGroup for LoadBalancer"},
+	{Manager: ManagerKarpenter, TagKey: "karpenter.sh/nodepool"},
+	{Manager: ManagerKarpenter, TagKey: "karpenter.sh/nodeclaim"},
+	{Manager: ManagerKarpenter, TagKey: "karpenter.sh/provisioner-name"},
+	{Manager: ManagerKarpenter, TagKey: "karpenter.k8s.aws/ec2nodeclass"},
+	{Manager: ManagerCloudFormation, TagKey: "aws:cloudformation:stack-name"},
+	{Manager: ManagerTerraform, TagKey: "managedby", TagValue: "terraform*"},
+	{Manager: ManagerTerraform, TagKey: "managed_by", TagValue: "terraform*"},
+	{Manager: ManagerTerraform, TagKey: "managed-by", TagValue: "terraform*"},
+	{Manager: ManagerTerraform, TagKey: "terraform", TagValue: "true"},
+	{Manager: ManagerEKS, TagKey: "kubernetes.io/cluster/*", TagValue: "owned"},
+	{Manager: ManagerEKS, TagKey: "aws:eks:cluster-name"},
+	{Manager: ManagerEKS, Description: "Security group for Kubernetes ELB *"},
+}
+
+// ManagerClassifier labels resources with the manager that created them
+type ManagerClassifier struct {
+	rules []ManagerRule // User rules, then the built-in ones
+}
+
+// NewManagerClassifier prepares the rules for matching
+// extra: Rules of the -managed-by-rules file, checked before the built-in ones (nil for none)
+func NewManagerClassifier(extra *ManagerRules) *ManagerClassifier {
+	classifier := &ManagerClassifier{}
+	if extra != nil {
+		classifier.rules = append(classifier.rules, extra.Rules...)
+	}
+	classifier.rules = append(classifier.rules, BuiltinManagerRules...)
+	return classifier
+}
+
+// Managers lists every label the classifier can set, for validating flags that name managers
+func (c *ManagerClassifier) Managers() []string {
+	seen := map[string]bool{ManagerManual: true}
+	managers := []string{ManagerManual}
+	for _, rule := range c.rules {
+		if !seen[rule.Manager] {
+			seen[rule.Manager] = true
+			managers = append(managers, rule.Manager)
+		}
+	}
+	return managers
+}
+
+// Classify returns the manager of a resource
+// tags: Tags of the resource, aws: system tags included
+// description: Description of the resource ("" if it has none)
+// Returns: The manager of the first matching rule, or manual
+func (c *ManagerClassifier) Classify(tags map[string]string, description string) string {
+	for _, rule := range c.rules {
+		if rule.matches(tags, description) {
+			return rule.Manager
+		}
+	}
+	return ManagerManual
+}
+
+// matches reports whether a resource meets every condition of the rule
+func (r ManagerRule) matches(tags map[string]string, description string) bool {
+	if r.TagKey == "" && r.Description == "" {
+		return false
+	}
+	if r.Description != "" && !globMatch(r.Description, description) {
+		return false
+	}
+	if r.TagKey == "" {
+		return true
+	}
+	for key, value := range tags {
+		if globMatch(r.TagKey, key) && (r.TagValue == "" || globMatch(r.TagValue, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches a case-insensitive pattern in which * stands for any characters
+func globMatch(pattern, s string) bool {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// Label sets the ManagedBy field of scanned resources
+// It walks the value recursively like vpc.HideSystemTags, so it must run before system tags are
+// hidden: CloudFormation is recognized by aws:cloudformation:stack-name. Structs with a ManagedBy
+// string field are classified by their Tags map and, if they have one, their Description; the fields
+// of other structs, such as a whole report, are searched for resources.
+// resources: Pointer to, or slice of, scanned resources
+func (c *ManagerClassifier) Label(resources interface{}) {
+	c.label(reflect.ValueOf(resources))
+}
+
+// label classifies one value and recurses into its elements
+func (c *ManagerClassifier) label(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			c.label(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.label(v.Index(i))
+		}
+	case reflect.Struct:
+		field := v.FieldByName("ManagedBy")
+		if !field.IsValid() || field.Kind() != reflect.String || !field.CanSet() {
+			// A report or other container of resources
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					c.label(v.Field(i))
+				}
+			}
+			return
+		}
+		var tags map[string]string
+		if t := v.FieldByName("Tags"); t.IsValid() && t.CanInterface() {
+			tags, _ = t.Interface().(map[string]string)
+		}
+		var description string
+		if d := v.FieldByName("Description"); d.IsValid() && d.Kind() == reflect.String {
+			description = d.String()
+		}
+		field.SetString(c.Classify(tags, description))
+	}
+}
+
+// ManagedByID maps the IDs of the core resources to their manager
+// Returns: Manager keyed by VPC, subnet, route table, security group, gateway and attachment ID
+func ManagedByID(vpcs []vpc.VPCInfo, subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo, securityGroups []vpc.SecurityGroupInfo,
+	internetGateways []vpc.InternetGatewayInfo, natGateways []vpc.NatGatewayInfo, transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo) map[string]string {
+	managers := make(map[string]string)
+	for _, v := range vpcs {
+		managers[v.VpcID] = v.ManagedBy
+	}
+	for _, s := range subnets {
+		managers[s.SubnetID] = s.ManagedBy
+	}
+	for _, rt := range routeTables {
+		managers[rt.RouteTableID] = rt.ManagedBy
+	}
+	for _, sg := range securityGroups {
+		managers[sg.GroupID] = sg.ManagedBy
+	}
+	for _, igw := range internetGateways {
+		managers[igw.InternetGatewayID] = igw.ManagedBy
+	}
+	for _, ngw := range natGateways {
+		managers[ngw.NatGatewayID] = ngw.ManagedBy
+	}
+	for _, tgw := range transitGateways {
+		managers[tgw.TransitGatewayID] = tgw.ManagedBy
+	}
+	for _, attachment := range tgwAttachments {
+		managers[attachment.AttachmentID] = attachment.ManagedBy
+	}
+	return managers
+}
+
+// WithoutManaged drops the findings about resources of the given managers
+// Used by -skip-managed, so that for example orphaned groups of the load balancer controller, which
+// deletes them itself, are not reported.
+// findings: Findings of an analysis
+// resourceID: ID of the resource a finding is about
+// managedBy: Manager keyed by resource ID, from ManagedByID
+// skip: Managers whose resources get no findings
+// Returns: The remaining findings in their order
+func WithoutManaged[T any](findings []T, resourceID func(T) string, managedBy map[string]string, skip []string) []T {
+	if len(skip) == 0 {
+		return findings
+	}
+	skipped := make(map[string]bool, len(skip))
+	for _, manager := range skip {
+		skipped[manager] = true
+	}
+	kept := findings[:0:0]
+	for _, finding := range findings {
+		if manager, ok := managedBy[resourceID(finding)]; !ok || !skipped[manager] {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
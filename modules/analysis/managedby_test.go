@@ -0,0 +1,203 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestBuiltinManagerRules checks every built-in pattern, case-insensitive matching, and that discovery tags and
+// other tags do not match
+func TestBuiltinManagerRules(t *testing.T) {
+	classifier := NewManagerClassifier(nil)
+	tests := []struct {
+		name        string
+		tags        map[string]string
+		description string
+		want        string
+	}{
+		{name: "load balancer cluster tag", tags: map[string]string{"elbv2.k8s.aws/cluster": "prod"}, want: ManagerEKSLBController},
+		{name: "ingress tag", tags: map[string]string{"ingress.k8s.aws/stack": "web/api"}, want: ManagerEKSLBController},
+		{name: "service tag", tags: map[string]string{"service.k8s.aws/resource": "LoadBalancer"}, want: ManagerEKSLBController},
+		{name: "managed load balancer group", description: "[k8s] Managed SecurityGroup for LoadBalancer", want: ManagerEKSLBController},
+		{name: "shared backend group", description: "[k8s] Shared Backend SecurityGroup for LoadBalancer", want: ManagerEKSLBController},
+		{name: "Karpenter node pool", tags: map[string]string{"karpenter.sh/nodepool": "default"}, want: ManagerKarpenter},
+		{name: "Karpenter node claim", tags: map[string]string{"karpenter.sh/nodeclaim": "default-x7k2p"}, want: ManagerKarpenter},
+		{name: "Karpenter provisioner", tags: map[string]string{"karpenter.sh/provisioner-name": "default"}, want: ManagerKarpenter},
+		{name: "Karpenter node class", tags: map[string]string{"karpenter.k8s.aws/ec2nodeclass": "default"}, want: ManagerKarpenter},
+		{name: "CloudFormation stack", tags: map[string]string{"aws:cloudformation:stack-name": "network"}, want: ManagerCloudFormation},
+		{name: "Terraform ManagedBy", tags: map[string]string{"ManagedBy": "Terraform"}, want: ManagerTerraform},
+		{name: "Terraform managed_by", tags: map[string]string{"managed_by": "terraform-cloud"}, want: ManagerTerraform},
+		{name: "Terraform managed-by", tags: map[string]string{"Managed-By": "terraform"}, want: ManagerTerraform},
+		{name: "Terraform flag", tags: map[string]string{"Terraform": "TRUE"}, want: ManagerTerraform},
+		{name: "owned by a cluster", tags: map[string]string{"kubernetes.io/cluster/prod": "owned"}, want: ManagerEKS},
+		{name: "EKS cluster name", tags: map[string]string{"aws:eks:cluster-name": "prod"}, want: ManagerEKS},
+		{name: "legacy ELB group", description: "Security group for Kubernetes ELB a1b2c3 (web/api)", want: ManagerEKS},
+		{name: "shared with a cluster", tags: map[string]string{"kubernetes.io/cluster/prod": "shared"}, want: ManagerManual},
+		{name: "Karpenter discovery", tags: map[string]string{"karpenter.sh/discovery": "prod"}, want: ManagerManual},
+		{name: "ManagedBy another tool", tags: map[string]string{"ManagedBy": "pulumi"}, want: ManagerManual},
+		{name: "Terraform flag false", tags: map[string]string{"terraform": "false"}, want: ManagerManual},
+		{name: "description mentions the controller", description: "copy of [k8s] Managed SecurityGroup for LoadBalancer", want: ManagerManual},
+		{name: "no tags", want: ManagerManual},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifier.Classify(tt.tags, tt.description); got != tt.want {
+				t.Errorf("Classify(%v, %q) = %q, want %q", tt.tags, tt.description, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestManagerPrecedence checks which manager wins when several patterns match, and that user rules come first
+// and need every condition they set
+func TestManagerPrecedence(t *testing.T) {
+	user := &ManagerRules{Rules: []ManagerRule{
+		{Manager: "platform-team", TagKey: "team", TagValue: "platform"},
+		{Manager: "crossplane", TagKey: "crossplane-kind", Description: "managed by crossplane*"},
+		{Manager: "empty rule"},
+	}}
+	tests := []struct {
+		name        string
+		extra       *ManagerRules
+		tags        map[string]string
+		description string
+		want        string
+	}{
+		{name: "load balancer group owned by the cluster", want: ManagerEKSLBController,
+			tags: map[string]string{"kubernetes.io/cluster/prod": "owned", "elbv2.k8s.aws/cluster": "prod"}},
+		{name: "Karpenter node owned by the cluster", want: ManagerKarpenter,
+			tags: map[string]string{"kubernetes.io/cluster/prod": "owned", "karpenter.sh/nodepool": "default"}},
+		{name: "Terraform-deployed stack", want: ManagerCloudFormation,
+			tags: map[string]string{"ManagedBy": "terraform", "aws:cloudformation:stack-name": "network"}},
+		{name: "Terraform-tagged cluster resource", want: ManagerTerraform,
+			tags: map[string]string{"ManagedBy": "terraform", "kubernetes.io/cluster/prod": "owned"}},
+		{name: "controller description beats the cluster tag", want: ManagerEKSLBController,
+			tags: map[string]string{"kubernetes.io/cluster/prod": "owned"}, description: "[k8s] Shared Backend SecurityGroup for LoadBalancer"},
+		{name: "user rule beats built-in rules", extra: user, want: "platform-team",
+			tags: map[string]string{"team": "platform", "elbv2.k8s.aws/cluster": "prod"}},
+		{name: "user rule value mismatch", extra: user, want: ManagerEKSLBController,
+			tags: map[string]string{"team": "data", "elbv2.k8s.aws/cluster": "prod"}},
+		{name: "user rule with tag and description", extra: user, want: "crossplane",
+			tags: map[string]string{"crossplane-kind": "SecurityGroup"}, description: "Managed by Crossplane v1"},
+		{name: "user rule missing its description", extra: user, want: ManagerManual,
+			tags: map[string]string{"crossplane-kind": "SecurityGroup"}},
+		{name: "user rule missing its tag", extra: user, want: ManagerManual, description: "managed by crossplane"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewManagerClassifier(tt.extra).Classify(tt.tags, tt.description); got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	want := []string{ManagerManual, "platform-team", "crossplane", "empty rule", ManagerEKSLBController, ManagerKarpenter,
+		ManagerCloudFormation, ManagerTerraform, ManagerEKS}
+	if got := NewManagerClassifier(user).Managers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Managers() = %v, want %v", got, want)
+	}
+}
+
+// TestGlobMatch checks the * wildcard at the start, middle and end, overlapping parts and case folding
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{pattern: "kubernetes.io/cluster/*", s: "kubernetes.io/cluster/prod/eu", want: true},
+		{pattern: "kubernetes.io/cluster/*", s: "kubernetes.io/cluster/", want: true},
+		{pattern: "kubernetes.io/cluster/*", s: "kubernetes.io/clusters"},
+		{pattern: "*-prod", s: "Web-PROD", want: true},
+		{pattern: "a*b*c", s: "aXbYbZc", want: true},
+		{pattern: "a*b*c", s: "acb"},
+		{pattern: "ab*ab", s: "ab"},
+		{pattern: "ab*ab", s: "abab", want: true},
+		{pattern: "*", s: "", want: true},
+		{pattern: "exact", s: "Exact", want: true},
+		{pattern: "exact", s: "exactly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			if got := globMatch(tt.pattern, tt.s); got != tt.want {
+				t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLabelManagers checks that Label finds the resources inside a report, through pointers and slices, and
+// classifies security groups by their description
+func TestLabelManagers(t *testing.T) {
+	report := &struct {
+		VPCs           []vpc.VPCInfo
+		Subnets        []*vpc.SubnetInfo
+		SecurityGroups []vpc.SecurityGroupInfo
+		Nested         struct{ NatGateways []vpc.NatGatewayInfo }
+		ignored        []vpc.VPCInfo
+	}{
+		VPCs: []vpc.VPCInfo{{VpcID: "vpc-0a1", Tags: map[string]string{"aws:cloudformation:stack-name": "network"}}},
+		Subnets: []*vpc.SubnetInfo{{SubnetID: "subnet-0a1", Tags: map[string]string{"kubernetes.io/cluster/prod": "owned"}},
+			nil, {SubnetID: "subnet-0a2"}},
+		SecurityGroups: []vpc.SecurityGroupInfo{{GroupID: "sg-0a1", Description: "[k8s] Managed SecurityGroup for LoadBalancer"}},
+		ignored:        []vpc.VPCInfo{{VpcID: "vpc-0b2"}},
+	}
+	report.Nested.NatGateways = []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", Tags: map[string]string{"terraform": "true"}}}
+	NewManagerClassifier(nil).Label(report)
+
+	got := []string{report.VPCs[0].ManagedBy, report.Subnets[0].ManagedBy, report.Subnets[2].ManagedBy,
+		report.SecurityGroups[0].ManagedBy, report.Nested.NatGateways[0].ManagedBy, report.ignored[0].ManagedBy}
+	want := []string{ManagerCloudFormation, ManagerEKS, ManagerManual, ManagerEKSLBController, ManagerTerraform, ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ManagedBy = %q, want %q", got, want)
+	}
+
+	routeTables := []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", Tags: map[string]string{"karpenter.sh/nodepool": "default"}}}
+	NewManagerClassifier(nil).Label(routeTables)
+	if routeTables[0].ManagedBy != ManagerKarpenter {
+		t.Errorf("ManagedBy of a slice element = %q, want %q", routeTables[0].ManagedBy, ManagerKarpenter)
+	}
+}
+
+// TestWithoutManaged checks that findings about resources of skipped managers are dropped, and findings about
+// unknown resources are kept
+func TestWithoutManaged(t *testing.T) {
+	managedBy := ManagedByID(
+		[]vpc.VPCInfo{{VpcID: "vpc-0a1", ManagedBy: ManagerTerraform}},
+		[]vpc.SubnetInfo{{SubnetID: "subnet-0a1", ManagedBy: ManagerEKS}},
+		[]vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", ManagedBy: ManagerManual}},
+		[]vpc.SecurityGroupInfo{{GroupID: "sg-0a1", ManagedBy: ManagerEKSLBController}, {GroupID: "sg-0a2", ManagedBy: ManagerManual}},
+		[]vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", ManagedBy: ManagerTerraform}},
+		[]vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", ManagedBy: ManagerKarpenter}},
+		[]vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", ManagedBy: ManagerCloudFormation}},
+		[]vpc.TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0a1", ManagedBy: ManagerCloudFormation}},
+	)
+	if len(managedBy) != 9 || managedBy["tgw-attach-0a1"] != ManagerCloudFormation || managedBy["nat-0a1"] != ManagerKarpenter {
+		t.Errorf("ManagedByID() = %v", managedBy)
+	}
+
+	findings := []string{"sg-0a1", "sg-0a2", "subnet-0a1", "sg-0gone", "tgw-0a1"}
+	id := func(finding string) string { return finding }
+	tests := []struct {
+		name string
+		skip []string
+		want []string
+	}{
+		{name: "nothing skipped", want: findings},
+		{name: "load balancer controller", skip: []string{ManagerEKSLBController}, want: []string{"sg-0a2", "subnet-0a1", "sg-0gone", "tgw-0a1"}},
+		{name: "several managers", skip: []string{ManagerEKSLBController, ManagerEKS, ManagerCloudFormation}, want: []string{"sg-0a2", "sg-0gone"}},
+		{name: "manual", skip: []string{ManagerManual}, want: []string{"sg-0a1", "subnet-0a1", "sg-0gone", "tgw-0a1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WithoutManaged(findings, id, managedBy, tt.skip); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WithoutManaged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if len(findings) != 5 || findings[0] != "sg-0a1" {
+		t.Errorf("WithoutManaged() changed its input: %v", findings)
+	}
+}
@@ -0,0 +1,217 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Link types of a network path, each with its own MTU and bandwidth limits
+const (
+	LinkLocal              = "local"                   // Within a VPC, including instances acting as appliances
+	LinkPeering            = "peering"                 // VPC peering in the same region
+	LinkInterRegionPeering = "inter-region-peering"    // VPC peering between regions
+	LinkTransitGateway     = "transit-gateway"         // Transit gateway VPC attachment
+	LinkTGWPeering         = "transit-gateway-peering" // Transit gateway peering attachment
+	LinkVPN                = "vpn"                     // Site-to-Site VPN, standalone or attached to a transit gateway
+	LinkDirectConnect      = "direct-connect"          // Direct Connect gateway attachment
+	LinkEndpoint           = "endpoint"                // Gateway Load Balancer or interface endpoint
+)
+
+// PathMTUBelowThreshold marks a path whose smallest MTU is below the configured threshold
+const PathMTUBelowThreshold = "mtu-below-threshold"
+
+// DefaultMTUThreshold is the path MTU below which large-frame traffic is fragmented or dropped;
+// transit gateways themselves carry 8500 bytes, so only paths with smaller links are flagged
+const DefaultMTUThreshold = 8500
+
+// LinkProperties are the MTU and bandwidth limits of one link type
+type LinkProperties struct {
+	Link             string  `json:"link"`               // One of the Link* constants
+	MTU              int     `json:"mtu"`                // Largest packet the link carries, in bytes
+	BandwidthCapGbps float64 `json:"bandwidth_cap_gbps"` // Bandwidth limit per attachment, tunnel or flow in Gbps, 0 if there is none
+	Note             string  `json:"note"`               // What the limit applies to
+}
+
+// PathProperties is the table of link limits used to annotate traced paths
+type PathProperties struct {
+	Links        []LinkProperties `json:"links"`         // Limits per link type
+	MTUThreshold int              `json:"mtu_threshold"` // Paths with a smaller MTU are reported, 0 to keep the current threshold
+}
+
+// DefaultPathProperties returns the documented limits of each link type
+// Bandwidth caps change as AWS raises them; a -path-properties file overrides single link types.
+func DefaultPathProperties() *PathProperties {
+	return &PathProperties{
+		Links: []LinkProperties{
+			{LinkLocal, 9001, 0, "jumbo frames within the VPC"},
+			{LinkPeering, 9001, 0, "no aggregate limit, per-flow limits of the instances apply"},
+			{LinkInterRegionPeering, 1500, 0, "jumbo frames are not supported between regions"},
+			{LinkTransitGateway, 8500, 100, "per VPC attachment, burst"},
+			{LinkTGWPeering, 8500, 100, "per peering attachment, burst"},
+			{LinkVPN, 1500, 1.25, "per tunnel; the tunnel overhead lowers the usable MTU further"},
+			{LinkDirectConnect, 8500, 0, "through a transit gateway; the connection speed is the limit"},
+			{LinkEndpoint, 8500, 100, "per endpoint and availability zone"},
+		},
+		MTUThreshold: DefaultMTUThreshold,
+	}
+}
+
+// Merge returns the properties with the link types of other replacing those of p
+// other: Overrides, typically from a -path-properties file
+// Returns: New properties; the threshold of other applies when it is set
+func (p *PathProperties) Merge(other *PathProperties) *PathProperties {
+	merged := &PathProperties{MTUThreshold: p.MTUThreshold}
+	overrides := make(map[string]LinkProperties)
+	for _, link := range other.Links {
+		overrides[link.Link] = link
+	}
+	for _, link := range p.Links {
+		if override, ok := overrides[link.Link]; ok {
+			link = override
+			delete(overrides, link.Link)
+		}
+		merged.Links = append(merged.Links, link)
+	}
+	for _, link := range other.Links {
+		if _, ok := overrides[link.Link]; ok {
+			merged.Links = append(merged.Links, link)
+		}
+	}
+	if other.MTUThreshold > 0 {
+		merged.MTUThreshold = other.MTUThreshold
+	}
+	return merged
+}
+
+// Lookup returns the limits of a link type, with the local limits for unknown types
+func (p *PathProperties) Lookup(link string) LinkProperties {
+	for _, properties := range p.Links {
+		if properties.Link == link {
+			return properties
+		}
+	}
+	for _, properties := range p.Links {
+		if properties.Link == LinkLocal {
+			return properties
+		}
+	}
+	return LinkProperties{Link: link, MTU: 9001}
+}
+
+// PathLimits is the combined limit of the links of a path
+type PathLimits struct {
+	MTU              int     `json:"mtu"`                // Smallest MTU of any link, 0 if the path has no links
+	MTULink          string  `json:"mtu_link"`           // Link type with the smallest MTU (the first one on the path)
+	BandwidthCapGbps float64 `json:"bandwidth_cap_gbps"` // Smallest bandwidth cap of any link, 0 if no link is capped
+	BandwidthLink    string  `json:"bandwidth_link"`     // Link type with the smallest bandwidth cap
+}
+
+// ComputePathLimits returns the path minimum of the MTUs and bandwidth caps of a sequence of links
+// links: Link types in path order
+// properties: Limits per link type
+// Returns: The smallest MTU and bandwidth cap and the links that set them
+func ComputePathLimits(links []string, properties *PathProperties) PathLimits {
+	var limits PathLimits
+	for _, link := range links {
+		p := properties.Lookup(link)
+		if limits.MTU == 0 || p.MTU < limits.MTU {
+			limits.MTU, limits.MTULink = p.MTU, link
+		}
+		if p.BandwidthCapGbps > 0 && (limits.BandwidthCapGbps == 0 || p.BandwidthCapGbps < limits.BandwidthCapGbps) {
+			limits.BandwidthCapGbps, limits.BandwidthLink = p.BandwidthCapGbps, link
+		}
+	}
+	return limits
+}
+
+// PathMTUFinding describes a traced path that cannot carry packets of the threshold size
+type PathMTUFinding struct {
+	SourceVpcID      string `json:"source_vpc_id"`      // ID of the VPC the traffic starts in
+	DestinationVpcID string `json:"destination_vpc_id"` // ID of the VPC the traffic is addressed to
+	MTU              int    `json:"mtu"`                // Smallest MTU on the path
+	MTULink          string `json:"mtu_link"`           // Link type with the smallest MTU
+	Classification   string `json:"classification"`     // Always mtu-below-threshold
+	Severity         string `json:"severity"`           // Always medium
+	Reason           string `json:"reason"`             // Human-readable explanation
+}
+
+// AnnotatePathLimits adds the path MTU and bandwidth cap to every reachable path of an inspection report
+// The forward and return hops both count, because a flow needs both directions. Transit gateway
+// attachments map to their link type by resource type; Gateway Load Balancer endpoints (vpce-) count
+// as endpoint links and appliance instances or interfaces as local links.
+// report: Report from AnalyzeInspectionPaths, updated in place (Limits and the MTU matrix)
+// attachments: Transit gateway attachments from the scan
+// properties: Limits per link type and the MTU threshold
+// Returns: Findings for reachable paths whose MTU is below the threshold, sorted by source and destination
+func AnnotatePathLimits(report *InspectionReport, attachments []vpc.TransitGatewayAttachmentInfo, properties *PathProperties) []PathMTUFinding {
+	findings := []PathMTUFinding{}
+	attachmentLinks := make(map[string]string)
+	for _, attachment := range attachments {
+		attachmentLinks[attachment.AttachmentID] = attachmentLink(attachment.ResourceType)
+	}
+
+	report.MTUMatrix = make(map[string]map[string]int)
+	for i := range report.Paths {
+		path := &report.Paths[i]
+		if path.Classification == PathUnreachable || path.Classification == PathBrokenReturnPath {
+			continue
+		}
+
+		links := []string{LinkLocal}
+		for _, hop := range append(append([]PathHop{}, path.Hops...), path.ReturnHops...) {
+			switch hop.Type {
+			case HopAttachment:
+				links = append(links, attachmentLinks[hop.ID])
+			case HopFirewallEndpoint:
+				if strings.HasPrefix(hop.ID, "vpce-") {
+					links = append(links, LinkEndpoint)
+				}
+			}
+		}
+		limits := ComputePathLimits(links, properties)
+		path.Limits = &limits
+
+		if report.MTUMatrix[path.SourceVpcID] == nil {
+			report.MTUMatrix[path.SourceVpcID] = make(map[string]int)
+		}
+		report.MTUMatrix[path.SourceVpcID][path.DestinationVpcID] = limits.MTU
+
+		if properties.MTUThreshold > 0 && limits.MTU < properties.MTUThreshold {
+			findings = append(findings, PathMTUFinding{
+				SourceVpcID:      path.SourceVpcID,
+				DestinationVpcID: path.DestinationVpcID,
+				MTU:              limits.MTU,
+				MTULink:          limits.MTULink,
+				Classification:   PathMTUBelowThreshold,
+				Severity:         SeverityMedium,
+				Reason: fmt.Sprintf("the %s link limits the path to %d bytes, below the threshold of %d; larger packets are fragmented or dropped",
+					limits.MTULink, limits.MTU, properties.MTUThreshold),
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].SourceVpcID != findings[j].SourceVpcID {
+			return findings[i].SourceVpcID < findings[j].SourceVpcID
+		}
+		return findings[i].DestinationVpcID < findings[j].DestinationVpcID
+	})
+	return findings
+}
+
+// attachmentLink returns the link type of a transit gateway attachment resource type
+func attachmentLink(resourceType string) string {
+	switch resourceType {
+	case "peering":
+		return LinkTGWPeering
+	case "vpn":
+		return LinkVPN
+	case "direct-connect-gateway":
+		return LinkDirectConnect
+	default:
+		return LinkTransitGateway
+	}
+}
@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestComputePathLimits checks that the smallest MTU and bandwidth cap of mixed-hop paths are found, with
+// the first link that sets them
+func TestComputePathLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []string
+		want  PathLimits
+	}{
+		{name: "no links"},
+		{name: "within a VPC", links: []string{LinkLocal}, want: PathLimits{MTU: 9001, MTULink: LinkLocal}},
+		{name: "transit gateway peering", links: []string{LinkLocal, LinkTransitGateway, LinkTGWPeering, LinkTransitGateway},
+			want: PathLimits{MTU: 8500, MTULink: LinkTransitGateway, BandwidthCapGbps: 100, BandwidthLink: LinkTransitGateway}},
+		{name: "VPN behind a transit gateway", links: []string{LinkLocal, LinkTransitGateway, LinkVPN, LinkTransitGateway},
+			want: PathLimits{MTU: 1500, MTULink: LinkVPN, BandwidthCapGbps: 1.25, BandwidthLink: LinkVPN}},
+		{name: "uncapped links", links: []string{LinkPeering, LinkDirectConnect, LinkInterRegionPeering},
+			want: PathLimits{MTU: 1500, MTULink: LinkInterRegionPeering}},
+		{name: "unknown link counts as local", links: []string{"", LinkEndpoint},
+			want: PathLimits{MTU: 8500, MTULink: LinkEndpoint, BandwidthCapGbps: 100, BandwidthLink: LinkEndpoint}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputePathLimits(tt.links, DefaultPathProperties()); got != tt.want {
+				t.Errorf("ComputePathLimits() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPathPropertiesMerge checks that overrides replace their link types in place, new link types are
+// appended and a zero threshold keeps the current one
+func TestPathPropertiesMerge(t *testing.T) {
+	defaults := DefaultPathProperties()
+	merged := defaults.Merge(&PathProperties{Links: []LinkProperties{
+		{Link: "sd-wan", MTU: 1400, Note: "overlay"},
+		{Link: LinkVPN, MTU: 1436, BandwidthCapGbps: 5, Note: "large bandwidth tunnels"},
+	}})
+
+	if merged.MTUThreshold != DefaultMTUThreshold {
+		t.Errorf("MTUThreshold = %d, want %d", merged.MTUThreshold, DefaultMTUThreshold)
+	}
+	if len(merged.Links) != len(defaults.Links)+1 {
+		t.Fatalf("got %d links, want %d", len(merged.Links), len(defaults.Links)+1)
+	}
+	for i, link := range defaults.Links {
+		want := link
+		if link.Link == LinkVPN {
+			want = LinkProperties{Link: LinkVPN, MTU: 1436, BandwidthCapGbps: 5, Note: "large bandwidth tunnels"}
+		}
+		if merged.Links[i] != want {
+			t.Errorf("link %d = %+v, want %+v", i, merged.Links[i], want)
+		}
+	}
+	if last := merged.Links[len(merged.Links)-1]; last.Link != "sd-wan" {
+		t.Errorf("last link = %+v, want sd-wan", last)
+	}
+	if got := defaults.Lookup(LinkVPN).MTU; got != 1500 {
+		t.Errorf("defaults VPN MTU = %d after Merge, want 1500 unchanged", got)
+	}
+	if got := defaults.Merge(&PathProperties{MTUThreshold: 1400}).MTUThreshold; got != 1400 {
+		t.Errorf("MTUThreshold = %d, want the override 1400", got)
+	}
+}
+
+// TestAnnotatePathLimits annotates the reachable paths of the inspection fixture and flags the inspected
+// paths once the endpoint links carry less than the threshold
+func TestAnnotatePathLimits(t *testing.T) {
+	f := newInspectionFixture()
+	report := f.analyze()
+	if findings := AnnotatePathLimits(report, f.attachments, DefaultPathProperties()); len(findings) != 0 {
+		t.Errorf("findings with the built-in limits = %+v, want none", findings)
+	}
+	wantMatrix := map[string]map[string]int{
+		"vpc-0a1": {"vpc-0b2": 8500},
+		"vpc-0b2": {"vpc-0a1": 8500},
+		"vpc-0c3": {"vpc-0d4": 8500},
+		"vpc-0d4": {"vpc-0c3": 8500},
+	}
+	if !reflect.DeepEqual(report.MTUMatrix, wantMatrix) {
+		t.Errorf("MTUMatrix = %v\nwant %v", report.MTUMatrix, wantMatrix)
+	}
+	if limits := report.path(t, "vpc-0a1", "vpc-0c3").Limits; limits != nil {
+		t.Errorf("broken return path limits = %+v, want nil", limits)
+	}
+	want := PathLimits{MTU: 8500, MTULink: LinkTransitGateway, BandwidthCapGbps: 100, BandwidthLink: LinkTransitGateway}
+	if limits := report.path(t, "vpc-0a1", "vpc-0b2").Limits; limits == nil || *limits != want {
+		t.Errorf("inspected path limits = %+v, want %+v", limits, want)
+	}
+
+	report = f.analyze()
+	properties := DefaultPathProperties().Merge(&PathProperties{Links: []LinkProperties{{Link: LinkEndpoint, MTU: 1500}}})
+	findings := AnnotatePathLimits(report, f.attachments, properties)
+	wantFindings := []PathMTUFinding{
+		{SourceVpcID: "vpc-0a1", DestinationVpcID: "vpc-0b2", MTU: 1500, MTULink: LinkEndpoint, Classification: PathMTUBelowThreshold, Severity: SeverityMedium,
+			Reason: "the endpoint link limits the path to 1500 bytes, below the threshold of 8500; larger packets are fragmented or dropped"},
+		{SourceVpcID: "vpc-0b2", DestinationVpcID: "vpc-0a1", MTU: 1500, MTULink: LinkEndpoint, Classification: PathMTUBelowThreshold, Severity: SeverityMedium,
+			Reason: "the endpoint link limits the path to 1500 bytes, below the threshold of 8500; larger packets are fragmented or dropped"},
+	}
+	if !reflect.DeepEqual(findings, wantFindings) {
+		t.Errorf("findings = %+v\nwant %+v", findings, wantFindings)
+	}
+}
+
+// TestAttachmentLinks checks that peering, VPN and Direct Connect attachments on a path set its limits
+func TestAttachmentLinks(t *testing.T) {
+	report := &InspectionReport{Paths: []TrafficPath{{
+		SourceVpcID: "vpc-0a1", DestinationVpcID: "vpc-0b2", Classification: PathDirect,
+		Hops:       []PathHop{{Type: HopVPC, ID: "vpc-0a1"}, {Type: HopAttachment, ID: "tgw-attach-0peer"}, {Type: HopAttachment, ID: "tgw-attach-0dx"}},
+		ReturnHops: []PathHop{{Type: HopAttachment, ID: "tgw-attach-0vpn"}, {Type: HopFirewallEndpoint, ID: "i-0firewall"}},
+	}}}
+	attachments := []vpc.TransitGatewayAttachmentInfo{
+		{AttachmentID: "tgw-attach-0peer", ResourceType: "peering"},
+		{AttachmentID: "tgw-attach-0dx", ResourceType: "direct-connect-gateway"},
+		{AttachmentID: "tgw-attach-0vpn", ResourceType: "vpn"},
+	}
+	findings := AnnotatePathLimits(report, attachments, DefaultPathProperties())
+	want := PathLimits{MTU: 1500, MTULink: LinkVPN, BandwidthCapGbps: 1.25, BandwidthLink: LinkVPN}
+	if limits := report.Paths[0].Limits; limits == nil || *limits != want {
+		t.Errorf("limits = %+v, want %+v", limits, want)
+	}
+	if len(findings) != 1 || findings[0].MTULink != LinkVPN {
+		t.Errorf("findings = %+v, want one for the VPN link", findings)
+	}
+}
@@ -0,0 +1,193 @@
+package analysis
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/privateapi"
+	"aws-documentor/modules/vpc"
+)
+
+// Private API finding classes
+const (
+	PrivateAPIUnscannedEndpoint = "unscanned-endpoint"  // The resource policy allows a VPC endpoint that is not in the scan
+	PrivateAPIUnscannedVPC      = "unscanned-vpc"       // The resource policy allows a VPC that is not in the scan
+	PrivateAPINoSourceCondition = "no-source-condition" // The resource policy allows invocation through any VPC endpoint
+	PrivateAPIUnparsedPolicy    = "unparsed-policy"     // The resource policy could not be parsed
+)
+
+// PrivateAPIIngress lists the scanned VPC endpoints and VPCs a private API can be invoked from
+type PrivateAPIIngress struct {
+	RestApiID        string   `json:"rest_api_id"`        // ID of the API
+	Name             string   `json:"name"`               // Name of the API
+	BoundEndpointIDs []string `json:"bound_endpoint_ids"` // Endpoints of the API's endpoint configuration, which get Route 53 aliases for it
+	EndpointIDs      []string `json:"endpoint_ids"`       // Scanned execute-api endpoints the resource policy admits, sorted
+	VpcIDs           []string `json:"vpc_ids"`            // VPCs of those endpoints, sorted
+	Unrestricted     bool     `json:"unrestricted"`       // Whether the policy admits every execute-api endpoint, scanned or not
+}
+
+// PrivateAPIFinding describes a resource policy that admits sources outside the scan
+type PrivateAPIFinding struct {
+	RestApiID      string `json:"rest_api_id"`    // ID of the API
+	Name           string `json:"name"`           // Name of the API
+	Source         string `json:"source"`         // Endpoint or VPC ID (or pattern) from the policy, empty for the other classes
+	Classification string `json:"classification"` // unscanned-endpoint, unscanned-vpc, no-source-condition or unparsed-policy
+	Severity       string `json:"severity"`       // medium, or low for unparsed-policy
+	Reason         string `json:"reason"`         // Human-readable explanation
+}
+
+// PrivateAPIReport contains the ingress of every private API and the findings on their resource policies
+type PrivateAPIReport struct {
+	APIs     []PrivateAPIIngress `json:"apis"`     // Private APIs in scan order
+	Findings []PrivateAPIFinding `json:"findings"` // Policies admitting endpoints or VPCs outside the scan, or any endpoint
+}
+
+// AnalyzePrivateAPIs cross-links private APIs with the interface endpoints for execute-api that can reach them
+// An endpoint reaches an API when the resource policy names it with aws:SourceVpce or names its VPC with
+// aws:SourceVpc, or when the policy allows invocation without either condition. Binding an endpoint in the
+// endpoint configuration only adds a DNS alias, so bound endpoints are listed but not counted as reaching the API.
+// Endpoints and VPCs the policy names that are not in the scan may belong to another account and are flagged.
+// apis: Private APIs from GetPrivateAPIs
+// endpoints: VPC endpoints from the scan
+// vpcs: VPCs from the scan
+// Returns: Report with the ingress of every API and the findings
+func AnalyzePrivateAPIs(apis []privateapi.PrivateAPIInfo, endpoints []vpc.VpcEndpointInfo, vpcs []vpc.VPCInfo) *PrivateAPIReport {
+	report := &PrivateAPIReport{
+		APIs:     []PrivateAPIIngress{},
+		Findings: []PrivateAPIFinding{},
+	}
+
+	scannedEndpoints := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		scannedEndpoints[endpoint.VpcEndpointID] = true
+	}
+	scannedVPCs := make(map[string]bool, len(vpcs))
+	for _, v := range vpcs {
+		scannedVPCs[v.VpcID] = true
+	}
+	executeAPI := executeAPIEndpoints(endpoints)
+
+	for _, api := range apis {
+		ingress := privateAPIIngress(api, executeAPI)
+		report.APIs = append(report.APIs, ingress)
+
+		finding := func(source, classification, severity, reason string) {
+			report.Findings = append(report.Findings, PrivateAPIFinding{
+				RestApiID:      api.RestApiID,
+				Name:           api.Name,
+				Source:         source,
+				Classification: classification,
+				Severity:       severity,
+				Reason:         reason,
+			})
+		}
+		switch {
+		case api.PolicyError != "":
+			finding("", PrivateAPIUnparsedPolicy, SeverityLow,
+				fmt.Sprintf("the resource policy of %s (%s) could not be parsed, so the endpoints that can reach it are unknown: %s", api.Name, api.RestApiID, api.PolicyError))
+			continue
+		case !api.PolicyAllowsInvoke:
+			continue
+		case api.PolicyUnrestricted:
+			finding("", PrivateAPINoSourceCondition, SeverityMedium,
+				fmt.Sprintf("the resource policy of %s (%s) allows invocation without an aws:SourceVpce or aws:SourceVpc condition, so execute-api endpoints in any VPC and account can reach it", api.Name, api.RestApiID))
+		}
+
+		for _, source := range api.PolicyVpcEndpointIDs {
+			switch {
+			case isPattern(source):
+				finding(source, PrivateAPIUnscannedEndpoint, SeverityMedium,
+					fmt.Sprintf("the resource policy of %s (%s) allows endpoints matching %s, which can include endpoints outside the scan", api.Name, api.RestApiID, source))
+			case !scannedEndpoints[source]:
+				finding(source, PrivateAPIUnscannedEndpoint, SeverityMedium,
+					fmt.Sprintf("the resource policy of %s (%s) allows %s, which is not among the scanned VPC endpoints and may belong to another account", api.Name, api.RestApiID, source))
+			}
+		}
+		for _, source := range api.PolicyVpcIDs {
+			switch {
+			case isPattern(source):
+				finding(source, PrivateAPIUnscannedVPC, SeverityMedium,
+					fmt.Sprintf("the resource policy of %s (%s) allows VPCs matching %s, which can include VPCs outside the scan", api.Name, api.RestApiID, source))
+			case !scannedVPCs[source]:
+				finding(source, PrivateAPIUnscannedVPC, SeverityMedium,
+					fmt.Sprintf("the resource policy of %s (%s) allows %s, which is not among the scanned VPCs and may belong to another account", api.Name, api.RestApiID, source))
+			}
+		}
+	}
+
+	return report
+}
+
+// PrivateAPIsByEndpoint returns the names of the private APIs each scanned execute-api endpoint can reach
+// apis: Private APIs from GetPrivateAPIs
+// endpoints: VPC endpoints from the scan
+// Returns: Sorted API names by endpoint ID; endpoints that reach no API are missing
+func PrivateAPIsByEndpoint(apis []privateapi.PrivateAPIInfo, endpoints []vpc.VpcEndpointInfo) map[string][]string {
+	names := make(map[string][]string)
+	executeAPI := executeAPIEndpoints(endpoints)
+	for _, api := range apis {
+		for _, endpointID := range privateAPIIngress(api, executeAPI).EndpointIDs {
+			names[endpointID] = appendUnique(names[endpointID], valueOr(api.Name, api.RestApiID))
+		}
+	}
+	for _, apiNames := range names {
+		sort.Strings(apiNames)
+	}
+	return names
+}
+
+// privateAPIIngress finds the execute-api endpoints the resource policy of an API admits
+func privateAPIIngress(api privateapi.PrivateAPIInfo, executeAPI []vpc.VpcEndpointInfo) PrivateAPIIngress {
+	ingress := PrivateAPIIngress{
+		RestApiID:        api.RestApiID,
+		Name:             api.Name,
+		BoundEndpointIDs: append([]string{}, api.VpcEndpointIDs...),
+		EndpointIDs:      []string{},
+		VpcIDs:           []string{},
+		Unrestricted:     api.PolicyError == "" && api.PolicyAllowsInvoke && api.PolicyUnrestricted,
+	}
+	if api.PolicyError != "" || !api.PolicyAllowsInvoke {
+		return ingress
+	}
+
+	for _, endpoint := range executeAPI {
+		if ingress.Unrestricted || matchesAny(api.PolicyVpcEndpointIDs, endpoint.VpcEndpointID) || matchesAny(api.PolicyVpcIDs, endpoint.VpcID) {
+			ingress.EndpointIDs = append(ingress.EndpointIDs, endpoint.VpcEndpointID)
+			ingress.VpcIDs = appendUnique(ingress.VpcIDs, endpoint.VpcID)
+		}
+	}
+	sort.Strings(ingress.EndpointIDs)
+	sort.Strings(ingress.VpcIDs)
+	return ingress
+}
+
+// executeAPIEndpoints returns the interface endpoints for API Gateway, the only ones that carry private API traffic
+func executeAPIEndpoints(endpoints []vpc.VpcEndpointInfo) []vpc.VpcEndpointInfo {
+	var executeAPI []vpc.VpcEndpointInfo
+	for _, endpoint := range endpoints {
+		if endpoint.EndpointType == vpc.EndpointTypeInterface && EndpointServiceName(endpoint.ServiceName) == "execute-api" {
+			executeAPI = append(executeAPI, endpoint)
+		}
+	}
+	return executeAPI
+}
+
+// isPattern reports whether a policy value uses the wildcards of StringLike
+func isPattern(value string) bool {
+	return strings.ContainsAny(value, "*?")
+}
+
+// matchesAny reports whether an ID equals or, for StringLike wildcards, matches one of the policy values
+func matchesAny(values []string, id string) bool {
+	for _, value := range values {
+		if value == id {
+			return true
+		}
+		if ok, _ := path.Match(value, id); ok && isPattern(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/privateapi"
+	"aws-documentor/modules/vpc"
+)
+
+// Endpoints of the private API tests: execute-api endpoints in two VPCs and an S3 endpoint in the first
+var (
+	testVPCs = []vpc.VPCInfo{{VpcID: "vpc-0a1"}, {VpcID: "vpc-0b2"}}
+
+	testAPIEndpoints = []vpc.VpcEndpointInfo{
+		{VpcEndpointID: "vpce-0a1", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.execute-api", EndpointType: vpc.EndpointTypeInterface},
+		{VpcEndpointID: "vpce-0a2", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.execute-api", EndpointType: vpc.EndpointTypeInterface},
+		{VpcEndpointID: "vpce-0b1", VpcID: "vpc-0b2", ServiceName: "com.amazonaws.eu-west-1.execute-api", EndpointType: vpc.EndpointTypeInterface},
+		{VpcEndpointID: "vpce-0s3", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.s3", EndpointType: vpc.EndpointTypeInterface},
+	}
+)
+
+// testAPI returns a private API whose policy was parsed by ParsePolicy
+func testAPI(t *testing.T, id, policy string, bound ...string) privateapi.PrivateAPIInfo {
+	api := privateapi.PrivateAPIInfo{RestApiID: id, Name: id + "-api", VpcEndpointIDs: bound}
+	parsed, err := privateapi.ParsePolicy(policy)
+	if err != nil {
+		api.Policy, api.PolicyError = policy, err.Error()
+		return api
+	}
+	api.Policy = parsed.Text
+	api.PolicyVpcEndpointIDs = parsed.VpcEndpointIDs
+	api.PolicyVpcIDs = parsed.VpcIDs
+	api.PolicyAllowsInvoke = parsed.AllowsInvoke
+	api.PolicyUnrestricted = parsed.Unrestricted
+	return api
+}
+
+func TestAnalyzePrivateAPIs(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		bound        []string
+		wantIngress  PrivateAPIIngress // Expected ingress, without the ID and name
+		wantFindings []string          // Classification and source of the expected findings
+	}{
+		{
+			name: "bound to several endpoints in two VPCs",
+			policy: `{"Statement":[{"Effect":"Allow","Action":"execute-api:Invoke"},
+				{"Effect":"Deny","Action":"execute-api:Invoke","Condition":{"StringNotEquals":{"aws:SourceVpce":["vpce-0a1","vpce-0b1"]}}}]}`,
+			bound: []string{"vpce-0a1", "vpce-0b1"},
+			wantIngress: PrivateAPIIngress{BoundEndpointIDs: []string{"vpce-0a1", "vpce-0b1"},
+				EndpointIDs: []string{"vpce-0a1", "vpce-0b1"}, VpcIDs: []string{"vpc-0a1", "vpc-0b2"}},
+		},
+		{
+			name:   "bound endpoint the policy does not allow",
+			policy: `{"Statement":{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"StringEquals":{"aws:SourceVpce":"vpce-0a2"}}}}`,
+			bound:  []string{"vpce-0a1", "vpce-0a2"},
+			wantIngress: PrivateAPIIngress{BoundEndpointIDs: []string{"vpce-0a1", "vpce-0a2"},
+				EndpointIDs: []string{"vpce-0a2"}, VpcIDs: []string{"vpc-0a1"}},
+		},
+		{
+			name:   "no aws:SourceVpce condition",
+			policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"execute-api:Invoke","Resource":"execute-api:/*"}]}`,
+			wantIngress: PrivateAPIIngress{BoundEndpointIDs: []string{},
+				EndpointIDs: []string{"vpce-0a1", "vpce-0a2", "vpce-0b1"}, VpcIDs: []string{"vpc-0a1", "vpc-0b2"}, Unrestricted: true},
+			wantFindings: []string{PrivateAPINoSourceCondition + " "},
+		},
+		{
+			name:   "VPC condition",
+			policy: `{"Statement":[{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"StringEquals":{"aws:SourceVpc":"vpc-0a1"}}}]}`,
+			wantIngress: PrivateAPIIngress{BoundEndpointIDs: []string{},
+				EndpointIDs: []string{"vpce-0a1", "vpce-0a2"}, VpcIDs: []string{"vpc-0a1"}},
+		},
+		{
+			name: "endpoints and VPCs outside the scan",
+			policy: `{"Statement":[{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{
+				"StringEquals":{"aws:SourceVpce":["vpce-0a1","vpce-9f9"],"aws:SourceVpc":"vpc-9f9"}}}]}`,
+			wantIngress: PrivateAPIIngress{BoundEndpointIDs: []string{},
+				EndpointIDs: []string{"vpce-0a1"}, VpcIDs: []string{"vpc-0a1"}},
+			wantFindings: []string{PrivateAPIUnscannedEndpoint + " vpce-9f9", PrivateAPIUnscannedVPC + " vpc-9f9"},
+		},
+		{
+			name:   "endpoint pattern",
+			policy: `{"Statement":[{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"StringLike":{"aws:SourceVpce":"vpce-0a*"}}}]}`,
+			wantIngress: PrivateAPIIngress{BoundEndpointIDs: []string{},
+				EndpointIDs: []string{"vpce-0a1", "vpce-0a2"}, VpcIDs: []string{"vpc-0a1"}},
+			wantFindings: []string{PrivateAPIUnscannedEndpoint + " vpce-0a*"},
+		},
+		{
+			name:        "no policy",
+			wantIngress: PrivateAPIIngress{BoundEndpointIDs: []string{}, EndpointIDs: []string{}, VpcIDs: []string{}},
+		},
+		{
+			name:         "unparsed policy",
+			policy:       `{"Statement":`,
+			wantIngress:  PrivateAPIIngress{BoundEndpointIDs: []string{}, EndpointIDs: []string{}, VpcIDs: []string{}},
+			wantFindings: []string{PrivateAPIUnparsedPolicy + " "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := AnalyzePrivateAPIs([]privateapi.PrivateAPIInfo{testAPI(t, "a1", tt.policy, tt.bound...)}, testAPIEndpoints, testVPCs)
+			if len(report.APIs) != 1 {
+				t.Fatalf("got %d APIs, want 1", len(report.APIs))
+			}
+			want := tt.wantIngress
+			want.RestApiID, want.Name = "a1", "a1-api"
+			if !reflect.DeepEqual(report.APIs[0], want) {
+				t.Errorf("ingress = %+v\nwant %+v", report.APIs[0], want)
+			}
+
+			var got []string
+			for _, finding := range report.Findings {
+				got = append(got, finding.Classification+" "+finding.Source)
+				if finding.RestApiID != "a1" || finding.Reason == "" {
+					t.Errorf("finding %+v has no API or reason", finding)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantFindings) {
+				t.Errorf("findings = %q, want %q", got, tt.wantFindings)
+			}
+		})
+	}
+}
+
+// TestPrivateAPIsByEndpoint checks the API names the diagram puts on endpoints that reach several APIs
+func TestPrivateAPIsByEndpoint(t *testing.T) {
+	apis := []privateapi.PrivateAPIInfo{
+		testAPI(t, "orders", `{"Statement":[{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"StringEquals":{"aws:SourceVpce":["vpce-0a1","vpce-0b1"]}}}]}`),
+		testAPI(t, "billing", `{"Statement":[{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"StringEquals":{"aws:SourceVpc":"vpc-0a1"}}}]}`),
+		testAPI(t, "closed", ``, "vpce-0a1"),
+	}
+	want := map[string][]string{
+		"vpce-0a1": {"billing-api", "orders-api"},
+		"vpce-0a2": {"billing-api"},
+		"vpce-0b1": {"orders-api"},
+	}
+	if got := PrivateAPIsByEndpoint(apis, testAPIEndpoints); !reflect.DeepEqual(got, want) {
+		t.Errorf("PrivateAPIsByEndpoint() = %v, want %v", got, want)
+	}
+}
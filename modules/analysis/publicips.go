@@ -0,0 +1,182 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/accelerator"
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/vpc"
+)
+
+// PublicIPResourceGlobalAccelerator is the attached resource type of Global Accelerator static IPs
+const PublicIPResourceGlobalAccelerator = "global-accelerator"
+
+// PublicIPEphemeralLongLived is the finding class for an ephemeral public IP on a long-running instance
+const PublicIPEphemeralLongLived = "ephemeral-ip-long-lived-instance"
+
+// LongLivedInstanceAge is how long an instance must have been running for its ephemeral public IP to be a finding
+const LongLivedInstanceAge = 30 * 24 * time.Hour
+
+// PublicIPEntry is one public IP address of the account with its attachment and exposure
+type PublicIPEntry struct {
+	PublicIp             string   `json:"public_ip"`              // Public IPv4 address
+	Kind                 string   `json:"kind"`                   // static or ephemeral
+	AllocationID         string   `json:"allocation_id"`          // Allocation ID of an Elastic IP
+	Arn                  string   `json:"arn"`                    // ARN of the Elastic IP (empty for ephemeral addresses)
+	AttachedResourceType string   `json:"attached_resource_type"` // instance, nat-gateway, load-balancer, network-interface, global-accelerator or none
+	AttachedResourceID   string   `json:"attached_resource_id"`   // ID of the attached resource (ARN for accelerators)
+	NetworkInterfaceID   string   `json:"network_interface_id"`   // Network interface holding the address
+	VpcID                string   `json:"vpc_id"`                 // VPC of the network interface
+	SubnetID             string   `json:"subnet_id"`              // Subnet of the network interface
+	SecurityGroupIDs     []string `json:"security_group_ids"`     // Security groups of the network interface
+	WorldOpenPorts       []string `json:"world_open_ports"`       // Ports the security groups open to 0.0.0.0/0 or ::/0 ("tcp 443", "all traffic")
+	Exposure             string   `json:"exposure"`               // Summary of what the internet can reach on the address
+	ReverseDNS           []string `json:"reverse_dns,omitempty"`  // PTR names of the address (only with -resolve-dns)
+	DNSRecords           []string `json:"dns_records,omitempty"`  // Route 53 public records pointing at the address, directly or through an alias such as a load balancer name (only with -resolve-dns)
+}
+
+// PublicIPFinding describes an ephemeral public IP on an instance that has been running a long time
+type PublicIPFinding struct {
+	PublicIp       string   `json:"public_ip"`           // Public IPv4 address
+	InstanceID     string   `json:"instance_id"`         // Instance holding the address
+	VpcID          string   `json:"vpc_id"`              // VPC of the instance
+	Classification string   `json:"classification"`      // Always ephemeral-ip-long-lived-instance
+	Severity       string   `json:"severity"`            // Always medium
+	Reason         string   `json:"reason"`              // Human-readable explanation
+	DNSNames       []string `json:"dns_names,omitempty"` // Public records and PTR names of the address (only with -resolve-dns)
+}
+
+// PublicIPReport contains the public IP inventory of the account
+type PublicIPReport struct {
+	PublicIPs []PublicIPEntry   `json:"public_ips"` // EC2 addresses in address order, then Global Accelerator static IPs
+	Findings  []PublicIPFinding `json:"findings"`   // Ephemeral addresses on long-lived instances
+}
+
+// AnalyzePublicIPs combines the EC2 public IPs and the Global Accelerator static IPs into one inventory
+// The exposure of an address is the set of ports the security groups of its network interface open to
+// the whole internet. An ephemeral address on an instance launched more than LongLivedInstanceAge ago is
+// a finding: the instance keeps running long enough to end up in allowlists, but the address changes on
+// the next stop and start.
+// now: Reference time for instance ages
+// publicIPs: Addresses from GetPublicIPs
+// accelerators: Accelerators from GetAccelerators (nil if not scanned)
+// securityGroups: Security groups from the scan
+// Returns: Report with every address and the findings
+func AnalyzePublicIPs(now time.Time, publicIPs []vpc.PublicIPInfo, accelerators []accelerator.AcceleratorInfo, securityGroups []vpc.SecurityGroupInfo) *PublicIPReport {
+	report := &PublicIPReport{
+		PublicIPs: []PublicIPEntry{},
+		Findings:  []PublicIPFinding{},
+	}
+
+	groups := make(map[string]vpc.SecurityGroupInfo, len(securityGroups))
+	for _, sg := range securityGroups {
+		groups[sg.GroupID] = sg
+	}
+
+	for _, ip := range publicIPs {
+		entry := PublicIPEntry{
+			PublicIp:             ip.PublicIp,
+			Kind:                 ip.Kind,
+			AllocationID:         ip.AllocationID,
+			Arn:                  ip.Arn,
+			AttachedResourceType: ip.AttachedResourceType,
+			AttachedResourceID:   ip.AttachedResourceID,
+			NetworkInterfaceID:   ip.NetworkInterfaceID,
+			VpcID:                ip.VpcID,
+			SubnetID:             ip.SubnetID,
+			SecurityGroupIDs:     ip.SecurityGroupIDs,
+			WorldOpenPorts:       worldOpenPorts(ip.SecurityGroupIDs, groups),
+		}
+		entry.Exposure = exposureSummary(entry)
+		report.PublicIPs = append(report.PublicIPs, entry)
+
+		if ip.Kind != vpc.PublicIPEphemeral || ip.AttachedResourceType != vpc.PublicIPResourceInstance || ip.InstanceLaunchTime == "" {
+			continue
+		}
+		launched, err := time.Parse(time.RFC3339, ip.InstanceLaunchTime)
+		if err != nil || now.Sub(launched) < LongLivedInstanceAge {
+			continue
+		}
+		report.Findings = append(report.Findings, PublicIPFinding{
+			PublicIp:       ip.PublicIp,
+			InstanceID:     ip.AttachedResourceID,
+			VpcID:          ip.VpcID,
+			Classification: PublicIPEphemeralLongLived,
+			Severity:       SeverityMedium,
+			Reason: fmt.Sprintf("%s has been running for %d days with the ephemeral public IP %s, which changes on the next stop and start and breaks any allowlist containing it; associate an Elastic IP instead",
+				ip.AttachedResourceID, int(now.Sub(launched).Hours()/24), ip.PublicIp),
+		})
+	}
+
+	for _, a := range accelerators {
+		for _, address := range a.IpAddresses {
+			entry := PublicIPEntry{
+				PublicIp:             address,
+				Kind:                 vpc.PublicIPStatic,
+				AttachedResourceType: PublicIPResourceGlobalAccelerator,
+				AttachedResourceID:   a.AcceleratorArn,
+				SecurityGroupIDs:     []string{},
+				WorldOpenPorts:       []string{},
+			}
+			entry.Exposure = exposureSummary(entry)
+			report.PublicIPs = append(report.PublicIPs, entry)
+		}
+	}
+
+	return report
+}
+
+// worldOpenPorts lists the ports the ingress rules of the groups open to every IPv4 or IPv6 address
+func worldOpenPorts(groupIDs []string, groups map[string]vpc.SecurityGroupInfo) []string {
+	ports := []string{}
+	for _, groupID := range groupIDs {
+		for _, rule := range groups[groupID].Rules {
+			if !rule.IsEgress && (rule.CidrBlock == "0.0.0.0/0" || rule.Ipv6CidrBlock == "::/0") {
+				ports = appendUnique(ports, describePorts(rule))
+			}
+		}
+	}
+	return ports
+}
+
+// exposureSummary describes what the internet can reach on an address
+func exposureSummary(entry PublicIPEntry) string {
+	switch {
+	case entry.AttachedResourceType == vpc.PublicIPResourceNone:
+		return "not attached"
+	case entry.AttachedResourceType == vpc.PublicIPResourceNatGateway:
+		return "NAT gateway: outbound only"
+	case entry.AttachedResourceType == PublicIPResourceGlobalAccelerator:
+		return "accelerator listener ports (not scanned)"
+	case len(entry.SecurityGroupIDs) == 0:
+		return "no security groups: every listening port is reachable"
+	case len(entry.WorldOpenPorts) == 0:
+		return "no ports open to the internet"
+	}
+	return "open to the internet: " + strings.Join(entry.WorldOpenPorts, ", ")
+}
+
+// AddDNSNames attaches the names found by -resolve-dns to the addresses and their findings
+// Addresses whose lookups failed or found nothing keep empty name lists; the enrichment is best effort.
+// reverse: Reverse lookups by address
+// records: Public records pointing at each address, from dns.MatchRecords
+func (r *PublicIPReport) AddDNSNames(reverse map[string]dns.LookupResult, records map[string][]string) {
+	names := make(map[string][]string)
+	for i := range r.PublicIPs {
+		entry := &r.PublicIPs[i]
+		entry.ReverseDNS = reverse[entry.PublicIp].Names
+		entry.DNSRecords = records[entry.PublicIp]
+		for _, record := range entry.DNSRecords {
+			name, _, _ := strings.Cut(record, " ")
+			names[entry.PublicIp] = appendUnique(names[entry.PublicIp], name)
+		}
+		for _, name := range entry.ReverseDNS {
+			names[entry.PublicIp] = appendUnique(names[entry.PublicIp], name)
+		}
+	}
+	for i := range r.Findings {
+		r.Findings[i].DNSNames = names[r.Findings[i].PublicIp]
+	}
+}
@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/accelerator"
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzePublicIPs checks the exposure of an address of every source, including an Elastic IP attached
+// to nothing and the static IPs of an accelerator, and which instances get an ephemeral IP finding
+func TestAnalyzePublicIPs(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	publicIPs := []vpc.PublicIPInfo{
+		{PublicIp: "198.51.100.10", Kind: vpc.PublicIPStatic, AllocationID: "eipalloc-0web", AttachedResourceType: vpc.PublicIPResourceInstance,
+			AttachedResourceID: "i-0web", VpcID: "vpc-0a1", SecurityGroupIDs: []string{"sg-0web"}, InstanceLaunchTime: "2025-01-01T00:00:00Z"},
+		{PublicIp: "198.51.100.20", Kind: vpc.PublicIPStatic, AllocationID: "eipalloc-0nlb", AttachedResourceType: vpc.PublicIPResourceLoadBalancer,
+			AttachedResourceID: "net/ingress/73e2d6bc24d8a067", VpcID: "vpc-0a1", SecurityGroupIDs: []string{}},
+		{PublicIp: "198.51.100.30", Kind: vpc.PublicIPStatic, AllocationID: "eipalloc-0spare", AttachedResourceType: vpc.PublicIPResourceNone,
+			SecurityGroupIDs: []string{}},
+		{PublicIp: "203.0.113.20", Kind: vpc.PublicIPEphemeral, AttachedResourceType: vpc.PublicIPResourceNatGateway, AttachedResourceID: "nat-0a1",
+			VpcID: "vpc-0a1", SecurityGroupIDs: []string{}},
+		{PublicIp: "203.0.113.30", Kind: vpc.PublicIPEphemeral, AttachedResourceType: vpc.PublicIPResourceLoadBalancer,
+			AttachedResourceID: "app/web/50dc6c495c0c9188", VpcID: "vpc-0a1", SecurityGroupIDs: []string{"sg-0alb", "sg-0web"}},
+		{PublicIp: "203.0.113.5", Kind: vpc.PublicIPEphemeral, AttachedResourceType: vpc.PublicIPResourceInstance, AttachedResourceID: "i-0bastion",
+			VpcID: "vpc-0a1", SecurityGroupIDs: []string{"sg-0ssh"}, InstanceLaunchTime: "2026-01-05T08:00:00Z"},
+		{PublicIp: "203.0.113.6", Kind: vpc.PublicIPEphemeral, AttachedResourceType: vpc.PublicIPResourceInstance, AttachedResourceID: "i-0fresh",
+			VpcID: "vpc-0a1", SecurityGroupIDs: []string{"sg-0internal"}, InstanceLaunchTime: "2026-10-01T00:00:00Z"},
+		{PublicIp: "203.0.113.7", Kind: vpc.PublicIPEphemeral, AttachedResourceType: vpc.PublicIPResourceInstance, AttachedResourceID: "i-0gone",
+			VpcID: "vpc-0a1", SecurityGroupIDs: []string{"sg-0internal"}},
+	}
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0web", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"},
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, Ipv6CidrBlock: "::/0"},
+			{IpProtocol: "tcp", FromPort: 8080, ToPort: 8080, CidrBlock: "10.0.0.0/8"},
+			{IpProtocol: "-1", CidrBlock: "0.0.0.0/0", IsEgress: true},
+		}},
+		{GroupID: "sg-0alb", Rules: []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 80, ToPort: 80, CidrBlock: "0.0.0.0/0"}}},
+		{GroupID: "sg-0ssh", Rules: []vpc.SecurityGroupRule{{IpProtocol: "-1", Ipv6CidrBlock: "::/0"}}},
+		{GroupID: "sg-0internal", Rules: []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "10.0.0.0/8"}}},
+	}
+	accelerators := []accelerator.AcceleratorInfo{{AcceleratorArn: "arn:aws:globalaccelerator::111122223333:accelerator/0a1",
+		IpAddresses: []string{"75.2.0.1", "99.83.0.1"}}}
+
+	report := AnalyzePublicIPs(now, publicIPs, accelerators, groups)
+
+	want := map[string][2]string{ // Resource type and exposure by address
+		"198.51.100.10": {vpc.PublicIPResourceInstance, "open to the internet: tcp 443"},
+		"198.51.100.20": {vpc.PublicIPResourceLoadBalancer, "no security groups: every listening port is reachable"},
+		"198.51.100.30": {vpc.PublicIPResourceNone, "not attached"},
+		"203.0.113.20":  {vpc.PublicIPResourceNatGateway, "NAT gateway: outbound only"},
+		"203.0.113.30":  {vpc.PublicIPResourceLoadBalancer, "open to the internet: tcp 80, tcp 443"},
+		"203.0.113.5":   {vpc.PublicIPResourceInstance, "open to the internet: all traffic"},
+		"203.0.113.6":   {vpc.PublicIPResourceInstance, "no ports open to the internet"},
+		"203.0.113.7":   {vpc.PublicIPResourceInstance, "no ports open to the internet"},
+		"75.2.0.1":      {PublicIPResourceGlobalAccelerator, "accelerator listener ports (not scanned)"},
+		"99.83.0.1":     {PublicIPResourceGlobalAccelerator, "accelerator listener ports (not scanned)"},
+	}
+	var order []string
+	for _, entry := range report.PublicIPs {
+		order = append(order, entry.PublicIp)
+		if got := [2]string{entry.AttachedResourceType, entry.Exposure}; got != want[entry.PublicIp] {
+			t.Errorf("%s = %v, want %v", entry.PublicIp, got, want[entry.PublicIp])
+		}
+	}
+	wantOrder := []string{"198.51.100.10", "198.51.100.20", "198.51.100.30", "203.0.113.20", "203.0.113.30", "203.0.113.5", "203.0.113.6",
+		"203.0.113.7", "75.2.0.1", "99.83.0.1"}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("addresses = %v, want the EC2 ones in scan order, then the accelerator ones: %v", order, wantOrder)
+	}
+	if accelerated := report.PublicIPs[8]; accelerated.Kind != vpc.PublicIPStatic || accelerated.AttachedResourceID != accelerators[0].AcceleratorArn {
+		t.Errorf("accelerator address = %+v, want a static address attached to the accelerator", accelerated)
+	}
+
+	// Only the ephemeral address of the instance running for over 30 days is a finding
+	wantFindings := []PublicIPFinding{{PublicIp: "203.0.113.5", InstanceID: "i-0bastion", VpcID: "vpc-0a1",
+		Classification: PublicIPEphemeralLongLived, Severity: SeverityMedium,
+		Reason: "i-0bastion has been running for 284 days with the ephemeral public IP 203.0.113.5, which changes on the next stop and start and breaks any allowlist containing it; associate an Elastic IP instead"}}
+	if !reflect.DeepEqual(report.Findings, wantFindings) {
+		t.Errorf("findings = %+v\nwant %+v", report.Findings, wantFindings)
+	}
+
+	empty := AnalyzePublicIPs(now, nil, nil, nil)
+	if empty.PublicIPs == nil || empty.Findings == nil {
+		t.Errorf("AnalyzePublicIPs() without addresses = %+v, want empty lists", empty)
+	}
+}
+
+// TestPublicIPDNSNames checks that reverse and forward names reach the addresses and, deduplicated, their findings
+func TestPublicIPDNSNames(t *testing.T) {
+	report := &PublicIPReport{
+		PublicIPs: []PublicIPEntry{{PublicIp: "203.0.113.5"}, {PublicIp: "198.51.100.30"}},
+		Findings:  []PublicIPFinding{{PublicIp: "203.0.113.5"}},
+	}
+	report.AddDNSNames(
+		map[string]dns.LookupResult{"203.0.113.5": {Names: []string{"bastion.example.com", "ec2-203-0-113-5.eu-west-1.compute.amazonaws.com"}}},
+		map[string][]string{"203.0.113.5": {"bastion.example.com (A)", "ssh.example.com (A)"}},
+	)
+	if got := report.PublicIPs[0].DNSRecords; !reflect.DeepEqual(got, []string{"bastion.example.com (A)", "ssh.example.com (A)"}) {
+		t.Errorf("DNSRecords = %v", got)
+	}
+	want := []string{"bastion.example.com", "ssh.example.com", "ec2-203-0-113-5.eu-west-1.compute.amazonaws.com"}
+	if got := report.Findings[0].DNSNames; !reflect.DeepEqual(got, want) {
+		t.Errorf("finding DNSNames = %v, want %v", got, want)
+	}
+	if entry := report.PublicIPs[1]; entry.ReverseDNS != nil || entry.DNSRecords != nil {
+		t.Errorf("address without names = %+v, want no names", entry)
+	}
+}
@@ -0,0 +1,192 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// NamedRange is a network known by name, such as an office, a VPN pool or a partner's range
+type NamedRange struct {
+	Name      string   `json:"name"`      // Label shown next to addresses in the range ("Frankfurt office")
+	Kind      string   `json:"kind"`      // Free-form kind of network (office, vpn, partner, scanner, ...)
+	CIDRs     []string `json:"cidrs"`     // IPv4 and IPv6 blocks of the network
+	Untrusted bool     `json:"untrusted"` // Whether ingress from the network is reported as a finding
+}
+
+// NamedRanges is the -named-ranges file
+type NamedRanges struct {
+	Ranges []NamedRange `json:"ranges"` // Named networks; when blocks nest, the most specific one names an address
+}
+
+// rangeBlock is one CIDR block of a named range
+type rangeBlock struct {
+	cidr   string // CIDR block as written in the file
+	prefix int    // Prefix length of the block
+	index  int    // Index of the range in NamedRanges.Ranges
+}
+
+// RangeLabeler names CIDR blocks after the named range they fall within
+type RangeLabeler struct {
+	ranges []NamedRange
+	blocks []rangeBlock // Blocks of every range, most specific first
+}
+
+// NewRangeLabeler prepares the named ranges for matching
+// Blocks that are not valid CIDRs are skipped; the -named-ranges file is validated before it is loaded.
+// ranges: Named ranges (nil for none)
+func NewRangeLabeler(ranges *NamedRanges) *RangeLabeler {
+	labeler := &RangeLabeler{}
+	if ranges == nil {
+		return labeler
+	}
+	labeler.ranges = ranges.Ranges
+	for i, named := range ranges.Ranges {
+		for _, cidr := range named.CIDRs {
+			prefix, err := netcalc.PrefixLength(cidr)
+			if err != nil {
+				continue
+			}
+			labeler.blocks = append(labeler.blocks, rangeBlock{cidr: cidr, prefix: prefix, index: i})
+		}
+	}
+	// Of two equally specific blocks the one listed first wins
+	sort.SliceStable(labeler.blocks, func(i, j int) bool { return labeler.blocks[i].prefix > labeler.blocks[j].prefix })
+	return labeler
+}
+
+// Match returns the named range a CIDR block falls within
+// With nested ranges the longest prefix wins, so a VPN pool inside an office range is named as the pool.
+// IPv4 blocks only match IPv4 ranges and IPv6 blocks IPv6 ranges.
+// cidr: CIDR block of a rule or route
+// Returns: The range, and whether one contains the whole block
+func (l *RangeLabeler) Match(cidr string) (NamedRange, bool) {
+	if cidr == "" {
+		return NamedRange{}, false
+	}
+	for _, block := range l.blocks {
+		if contains, err := netcalc.CIDRContains(block.cidr, cidr); err == nil && contains {
+			return l.ranges[block.index], true
+		}
+	}
+	return NamedRange{}, false
+}
+
+// Label returns the name of the range a CIDR block falls within, or "" if there is none
+func (l *RangeLabeler) Label(cidr string) string {
+	named, _ := l.Match(cidr)
+	return named.Name
+}
+
+// LabelRules sets the source label of ingress rules and the destination label of egress rules
+// securityGroups: Security groups whose rules are labeled in place
+func (l *RangeLabeler) LabelRules(securityGroups []vpc.SecurityGroupInfo) {
+	for i := range securityGroups {
+		for j := range securityGroups[i].Rules {
+			rule := &securityGroups[i].Rules[j]
+			label := l.Label(ruleCIDR(*rule))
+			if rule.IsEgress {
+				rule.DestinationLabel = label
+			} else {
+				rule.SourceLabel = label
+			}
+		}
+	}
+}
+
+// LabelRoutes sets the destination label of every route
+// routeTables: Route tables whose routes are labeled in place
+func (l *RangeLabeler) LabelRoutes(routeTables []vpc.RouteTableInfo) {
+	for i := range routeTables {
+		for j := range routeTables[i].Routes {
+			route := &routeTables[i].Routes[j]
+			destination := route.DestinationCidrBlock
+			if destination == "" {
+				destination = route.DestinationIpv6Block
+			}
+			route.DestinationLabel = l.Label(destination)
+		}
+	}
+}
+
+// LabelEffectiveSources names the expanded source blocks that fall within a named range
+// report: Effective sources report, labeled in place (nil is ignored)
+func (l *RangeLabeler) LabelEffectiveSources(report *EffectiveSourcesReport) {
+	if report == nil {
+		return
+	}
+	for i := range report.Groups {
+		for j := range report.Groups[i].Ports {
+			ports := &report.Groups[i].Ports[j]
+			for _, cidr := range ports.CIDRs {
+				if label := l.Label(cidr); label != "" {
+					if ports.CIDRLabels == nil {
+						ports.CIDRLabels = make(map[string]string)
+					}
+					ports.CIDRLabels[cidr] = label
+				}
+			}
+		}
+	}
+}
+
+// UntrustedSourceFinding describes an ingress rule that allows traffic from a range marked untrusted
+type UntrustedSourceFinding struct {
+	GroupID   string `json:"group_id"`   // ID of the security group containing the rule
+	GroupName string `json:"group_name"` // Name of the security group containing the rule
+	VpcID     string `json:"vpc_id"`     // ID of the VPC of the security group
+	Rule      string `json:"rule"`       // The rule (protocol, ports and source)
+	Range     string `json:"range"`      // Name of the untrusted range the source falls within
+	Kind      string `json:"kind"`       // Kind of the untrusted range
+	Severity  string `json:"severity"`   // high for all traffic, medium otherwise
+	Reason    string `json:"reason"`     // Human-readable explanation
+}
+
+// AnalyzeUntrustedSources finds ingress rules whose source falls within a named range marked untrusted
+// securityGroups: Security groups from the scan
+// Returns: One finding per rule, sorted by VPC and group in rule order
+func (l *RangeLabeler) AnalyzeUntrustedSources(securityGroups []vpc.SecurityGroupInfo) []UntrustedSourceFinding {
+	findings := []UntrustedSourceFinding{}
+	for _, sg := range securityGroups {
+		for _, rule := range sg.Rules {
+			if rule.IsEgress {
+				continue
+			}
+			named, ok := l.Match(ruleCIDR(rule))
+			if !ok || !named.Untrusted {
+				continue
+			}
+			severity := SeverityMedium
+			if netcalc.NormalizeProtocol(rule.IpProtocol) == netcalc.ProtocolAll {
+				severity = SeverityHigh
+			}
+			findings = append(findings, UntrustedSourceFinding{
+				GroupID:   sg.GroupID,
+				GroupName: sg.GroupName,
+				VpcID:     sg.VpcID,
+				Rule:      describeRule(rule),
+				Range:     named.Name,
+				Kind:      named.Kind,
+				Severity:  severity,
+				Reason:    fmt.Sprintf("ingress from %s, which is marked untrusted", named.Name),
+			})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].VpcID != findings[j].VpcID {
+			return findings[i].VpcID < findings[j].VpcID
+		}
+		return findings[i].GroupID < findings[j].GroupID
+	})
+	return findings
+}
+
+// ruleCIDR returns the IPv4 or IPv6 block of a rule, or "" for group and prefix list references
+func ruleCIDR(rule vpc.SecurityGroupRule) string {
+	if rule.CidrBlock != "" {
+		return rule.CidrBlock
+	}
+	return rule.Ipv6CidrBlock
+}
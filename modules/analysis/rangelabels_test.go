@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// testNamedRanges has an office with a VPN pool nested in it, an IPv6 office block, two equally specific
+// overlapping ranges and an untrusted partner
+func testNamedRanges() *NamedRanges {
+	return &NamedRanges{Ranges: []NamedRange{
+		{Name: "Frankfurt office", Kind: "office", CIDRs: []string{"10.12.0.0/16", "2001:db8:12::/48"}},
+		{Name: "Frankfurt VPN", Kind: "vpn", CIDRs: []string{"10.12.200.0/22"}},
+		{Name: "Scanner A", Kind: "scanner", CIDRs: []string{"192.0.2.0/24"}},
+		{Name: "Scanner B", Kind: "scanner", CIDRs: []string{"192.0.2.0/24", "not-a-cidr"}},
+		{Name: "Partner", Kind: "partner", CIDRs: []string{"198.51.100.0/24"}, Untrusted: true},
+	}}
+}
+
+// TestRangeLabelerMatch covers nested ranges, exact matches, IPv6 ranges, blocks wider than any range,
+// ties between equally specific ranges and invalid blocks in the file
+func TestRangeLabelerMatch(t *testing.T) {
+	labeler := NewRangeLabeler(testNamedRanges())
+	tests := []struct {
+		name string
+		cidr string
+		want string
+	}{
+		{name: "within the office", cidr: "10.12.5.0/24", want: "Frankfurt office"},
+		{name: "exact office block", cidr: "10.12.0.0/16", want: "Frankfurt office"},
+		{name: "nested VPN pool", cidr: "10.12.201.7/32", want: "Frankfurt VPN"},
+		{name: "exact VPN pool", cidr: "10.12.200.0/22", want: "Frankfurt VPN"},
+		{name: "wider than the pool", cidr: "10.12.200.0/21", want: "Frankfurt office"},
+		{name: "wider than the office", cidr: "10.0.0.0/8"},
+		{name: "outside", cidr: "10.13.0.0/16"},
+		{name: "IPv6 within", cidr: "2001:db8:12:4::/64", want: "Frankfurt office"},
+		{name: "IPv6 exact", cidr: "2001:db8:12::/48", want: "Frankfurt office"},
+		{name: "IPv6 outside", cidr: "2001:db8:13::/48"},
+		{name: "IPv4-mapped IPv6 does not match IPv4", cidr: "::ffff:10.12.5.0/120"},
+		{name: "tie goes to the first listed", cidr: "192.0.2.10/32", want: "Scanner A"},
+		{name: "anywhere", cidr: "0.0.0.0/0"},
+		{name: "empty", cidr: ""},
+		{name: "invalid", cidr: "sg-0a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := labeler.Label(tt.cidr); got != tt.want {
+				t.Errorf("Label(%q) = %q, want %q", tt.cidr, got, tt.want)
+			}
+		})
+	}
+
+	if named, ok := NewRangeLabeler(nil).Match("10.12.0.0/16"); ok || named.Name != "" {
+		t.Errorf("Match() without named ranges = %+v, %v", named, ok)
+	}
+}
+
+// TestLabelResources checks the labels set on rules by direction, on IPv4 and IPv6 routes and on effective sources
+func TestLabelResources(t *testing.T) {
+	labeler := NewRangeLabeler(testNamedRanges())
+
+	groups := []vpc.SecurityGroupInfo{{GroupID: "sg-0a1", Rules: []vpc.SecurityGroupRule{
+		{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "10.12.200.0/24"},
+		{IpProtocol: "tcp", FromPort: 443, ToPort: 443, Ipv6CidrBlock: "2001:db8:12::/56"},
+		{IpProtocol: "-1", CidrBlock: "10.12.0.0/16", IsEgress: true},
+		{IpProtocol: "tcp", FromPort: 443, ToPort: 443, GroupID: "sg-0b2"},
+		{IpProtocol: "tcp", FromPort: 80, ToPort: 80, CidrBlock: "0.0.0.0/0", SourceLabel: "stale"},
+	}}}
+	labeler.LabelRules(groups)
+	var got [][2]string
+	for _, rule := range groups[0].Rules {
+		got = append(got, [2]string{rule.SourceLabel, rule.DestinationLabel})
+	}
+	want := [][2]string{{"Frankfurt VPN", ""}, {"Frankfurt office", ""}, {"", "Frankfurt office"}, {"", ""}, {"", ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rule labels (source, destination) = %q, want %q", got, want)
+	}
+
+	routeTables := []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", Routes: []vpc.RouteInfo{
+		{DestinationCidrBlock: "10.12.0.0/16"},
+		{DestinationIpv6Block: "2001:db8:12::/48"},
+		{DestinationCidrBlock: "0.0.0.0/0"},
+	}}}
+	labeler.LabelRoutes(routeTables)
+	for i, want := range []string{"Frankfurt office", "Frankfurt office", ""} {
+		if got := routeTables[0].Routes[i].DestinationLabel; got != want {
+			t.Errorf("route %d label = %q, want %q", i, got, want)
+		}
+	}
+
+	report := &EffectiveSourcesReport{Groups: []GroupEffectiveSources{{GroupID: "sg-0a1", Ports: []EffectiveSources{
+		{CIDRs: []string{"10.12.200.4/31", "10.20.0.0/16", "198.51.100.7/32"}},
+		{CIDRs: []string{"10.30.0.0/16"}},
+	}}}}
+	labeler.LabelEffectiveSources(report)
+	labeler.LabelEffectiveSources(nil)
+	if want := map[string]string{"10.12.200.4/31": "Frankfurt VPN", "198.51.100.7/32": "Partner"}; !reflect.DeepEqual(report.Groups[0].Ports[0].CIDRLabels, want) {
+		t.Errorf("CIDRLabels = %v, want %v", report.Groups[0].Ports[0].CIDRLabels, want)
+	}
+	if labels := report.Groups[0].Ports[1].CIDRLabels; labels != nil {
+		t.Errorf("CIDRLabels without named blocks = %v, want nil", labels)
+	}
+}
+
+// TestAnalyzeUntrustedSources checks that ingress from an untrusted range is reported by severity, and egress
+// to it or ingress from trusted ranges is not
+func TestAnalyzeUntrustedSources(t *testing.T) {
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0b2", GroupName: "api", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "198.51.100.0/25"},
+			{IpProtocol: "-1", CidrBlock: "198.51.100.0/24", IsEgress: true},
+		}},
+		{GroupID: "sg-0a1", GroupName: "batch", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "-1", CidrBlock: "198.51.100.9/32"},
+			{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "10.12.0.0/16"},
+			{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "198.51.0.0/16"},
+		}},
+	}
+	want := []UntrustedSourceFinding{
+		{GroupID: "sg-0a1", GroupName: "batch", VpcID: "vpc-0a1", Rule: "all traffic from 198.51.100.9/32", Range: "Partner", Kind: "partner",
+			Severity: SeverityHigh, Reason: "ingress from Partner, which is marked untrusted"},
+		{GroupID: "sg-0b2", GroupName: "api", VpcID: "vpc-0a1", Rule: "tcp 443 from 198.51.100.0/25", Range: "Partner", Kind: "partner",
+			Severity: SeverityMedium, Reason: "ingress from Partner, which is marked untrusted"},
+	}
+	if got := NewRangeLabeler(testNamedRanges()).AnalyzeUntrustedSources(groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeUntrustedSources() =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := NewRangeLabeler(nil).AnalyzeUntrustedSources(groups); got == nil || len(got) != 0 {
+		t.Errorf("AnalyzeUntrustedSources() without named ranges = %#v, want an empty list", got)
+	}
+}
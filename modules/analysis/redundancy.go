@@ -0,0 +1,167 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// Redundant rule kinds
+const (
+	RedundancyShadowed  = "shadowed"  // Rule is fully covered by a broader rule in the same group and direction
+	RedundancyDuplicate = "duplicate" // Rule is identical to an earlier rule in the same group and direction
+)
+
+// SeverityLow marks findings that add review noise but do not change what traffic is allowed
+const SeverityLow = "low"
+
+// SGRedundancyFinding describes a security group rule that can be removed without changing the allowed traffic
+type SGRedundancyFinding struct {
+	GroupID      string `json:"group_id"`      // ID of the security group containing the rule
+	GroupName    string `json:"group_name"`    // Name of the security group containing the rule
+	VpcID        string `json:"vpc_id"`        // ID of the VPC of the security group
+	IsEgress     bool   `json:"is_egress"`     // Whether the rules are egress rules
+	Rule         string `json:"rule"`          // The redundant rule (protocol, ports and source or destination)
+	CoveringRule string `json:"covering_rule"` // The rule that already allows the same traffic
+	Kind         string `json:"kind"`          // shadowed or duplicate
+	Severity     string `json:"severity"`      // Always low
+	Reason       string `json:"reason"`        // Human-readable explanation
+}
+
+// SGRedundancyReport contains the results of the redundant rule analysis
+type SGRedundancyReport struct {
+	Findings    []SGRedundancyFinding `json:"findings"`    // Shadowed and duplicate rules
+	Reclaimable map[string]int        `json:"reclaimable"` // Number of removable rules per security group ID (groups without any are omitted)
+}
+
+// AnalyzeRedundantRules finds rules that are shadowed by or duplicate another rule of the same group and direction
+// A rule covers another when the protocol matches (-1 covers all), the port range or ICMP type/code
+// contains the other's, and the source or destination contains the other's: CIDR containment within
+// the same address family, or the same security group or prefix list. CIDR rules never cover
+// group or prefix list references.
+// securityGroups: Security groups from the scan
+// Returns: Report with one finding per removable rule and a per-group count
+func AnalyzeRedundantRules(securityGroups []vpc.SecurityGroupInfo) *SGRedundancyReport {
+	report := &SGRedundancyReport{
+		Findings:    []SGRedundancyFinding{},
+		Reclaimable: make(map[string]int),
+	}
+
+	for _, sg := range securityGroups {
+		for j, rule := range sg.Rules {
+			for i, other := range sg.Rules {
+				if i == j || other.IsEgress != rule.IsEgress || !ruleCovers(other, rule) {
+					continue
+				}
+
+				// Of two identical rules only the later one is redundant
+				kind := RedundancyShadowed
+				if ruleCovers(rule, other) {
+					if i > j {
+						continue
+					}
+					kind = RedundancyDuplicate
+				}
+
+				finding := SGRedundancyFinding{
+					GroupID:      sg.GroupID,
+					GroupName:    sg.GroupName,
+					VpcID:        sg.VpcID,
+					IsEgress:     rule.IsEgress,
+					Rule:         describeRule(rule),
+					CoveringRule: describeRule(other),
+					Kind:         kind,
+					Severity:     SeverityLow,
+				}
+				if kind == RedundancyDuplicate {
+					finding.Reason = "rule duplicates another rule of the group"
+				} else {
+					finding.Reason = fmt.Sprintf("traffic is already allowed by %s", finding.CoveringRule)
+				}
+				report.Findings = append(report.Findings, finding)
+				report.Reclaimable[sg.GroupID]++
+				break
+			}
+		}
+	}
+
+	// Deterministic order for reports and diffs
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].GroupID < report.Findings[j].GroupID
+	})
+
+	return report
+}
+
+// ruleCovers reports whether outer allows all traffic inner allows (direction is checked by the caller)
+func ruleCovers(outer, inner vpc.SecurityGroupRule) bool {
+	outerProtocol := netcalc.NormalizeProtocol(outer.IpProtocol)
+	innerProtocol := netcalc.NormalizeProtocol(inner.IpProtocol)
+
+	switch {
+	case outerProtocol == netcalc.ProtocolAll:
+	case outerProtocol != innerProtocol:
+		return false
+	case netcalc.HasPorts(outerProtocol):
+		if !netcalc.PortRangeContains(outer.FromPort, outer.ToPort, inner.FromPort, inner.ToPort) {
+			return false
+		}
+	case netcalc.IsICMP(outerProtocol):
+		if !netcalc.ICMPContains(outer.FromPort, outer.ToPort, inner.FromPort, inner.ToPort) {
+			return false
+		}
+	}
+
+	return peerCovers(outer, inner)
+}
+
+// peerCovers reports whether the source or destination of outer includes that of inner
+func peerCovers(outer, inner vpc.SecurityGroupRule) bool {
+	switch {
+	case outer.GroupID != "" || inner.GroupID != "":
+		// Group references only match the same group; addresses say nothing about group membership
+		return outer.GroupID == inner.GroupID && outer.GroupOwnerID == inner.GroupOwnerID
+	case outer.PrefixListID != "" || inner.PrefixListID != "":
+		return outer.PrefixListID == inner.PrefixListID
+	case outer.CidrBlock != "" && inner.CidrBlock != "":
+		contains, err := netcalc.CIDRContains(outer.CidrBlock, inner.CidrBlock)
+		return err == nil && contains
+	case outer.Ipv6CidrBlock != "" && inner.Ipv6CidrBlock != "":
+		contains, err := netcalc.CIDRContains(outer.Ipv6CidrBlock, inner.Ipv6CidrBlock)
+		return err == nil && contains
+	}
+	return false
+}
+
+// describeRule renders a rule as "tcp 443 from 10.0.0.0/16" for findings
+func describeRule(rule vpc.SecurityGroupRule) string {
+	peer := rule.CidrBlock
+	for _, candidate := range []string{rule.Ipv6CidrBlock, rule.GroupID, rule.PrefixListID} {
+		if peer == "" {
+			peer = candidate
+		}
+	}
+	direction := "from"
+	if rule.IsEgress {
+		direction = "to"
+	}
+	return fmt.Sprintf("%s %s %s", describePorts(rule), direction, peer)
+}
+
+// describePorts renders the protocol and ports of a rule as "tcp 443", "udp 1000-2000" or "all traffic"
+func describePorts(rule vpc.SecurityGroupRule) string {
+	protocol := netcalc.NormalizeProtocol(rule.IpProtocol)
+	switch {
+	case protocol == netcalc.ProtocolAll:
+		return "all traffic"
+	case netcalc.HasPorts(protocol) && rule.FromPort == rule.ToPort:
+		return fmt.Sprintf("%s %d", protocol, rule.FromPort)
+	case netcalc.HasPorts(protocol):
+		return fmt.Sprintf("%s %d-%d", protocol, rule.FromPort, rule.ToPort)
+	case netcalc.IsICMP(protocol) && rule.FromPort != -1:
+		return fmt.Sprintf("%s type %d code %d", protocol, rule.FromPort, rule.ToPort)
+	}
+	return protocol
+}
@@ -0,0 +1,151 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// Route target classifications
+const (
+	PrivateRangeToInternet = "private-range-to-internet" // A private destination routes to an internet gateway, which drops it or sends it to the internet
+	PrivateRangeCovered    = "private-range-covered"     // A route to an internet gateway covers a private range without being the default route
+	DefaultRouteToPeering  = "default-route-to-peering"  // The default route targets a peering connection, which never forwards it
+)
+
+// Default route destinations
+const (
+	defaultRouteDestination = "0.0.0.0/0"
+	defaultIpv6Destination  = "::/0"
+)
+
+// DefaultPrivateRanges are the ranges always treated as private: the RFC 1918 blocks and the IPv6
+// unique local block, which egress-only internet gateways cannot route either
+var DefaultPrivateRanges = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
+// RouteTargetFinding describes a route whose destination and target cannot work together
+type RouteTargetFinding struct {
+	RouteTableID   string   `json:"route_table_id"` // ID of the route table containing the route
+	VpcID          string   `json:"vpc_id"`         // ID of the VPC of the route table
+	Destination    string   `json:"destination"`    // Destination CIDR block of the route
+	TargetType     string   `json:"target_type"`    // One of the vpc.RouteTarget* constants
+	TargetID       string   `json:"target_id"`      // ID of the gateway or peering connection
+	PrivateRange   string   `json:"private_range"`  // Private range the destination falls within or covers (empty for peering findings)
+	SubnetIDs      []string `json:"subnet_ids"`     // Subnets using the route table, explicitly or as the main route table
+	Classification string   `json:"classification"` // private-range-to-internet, private-range-covered or default-route-to-peering
+	Severity       string   `json:"severity"`       // high, or medium for covering routes
+	Reason         string   `json:"reason"`         // Human-readable explanation
+}
+
+// AnalyzeRouteTargets flags routes that send private address space to the internet or the default route to a peering connection
+// A destination within a private range that targets an internet gateway or egress-only internet
+// gateway leaves the VPC for the internet, where private addresses are dropped; a shorter prefix
+// that covers a private range without being the default route (10.0.0.0/7, 0.0.0.0/1) does the
+// same for that range wherever no more specific route exists. A default route to a peering
+// connection is blackholed, as peering does not support transitive or edge-to-edge routing.
+// routeTables: Route tables from the scan
+// subnets: Subnets from the scan, for the subnets implicitly associated with the main route tables
+// privateRanges: Ranges treated as private, DefaultPrivateRanges plus any corporate ranges
+// Returns: Findings sorted by route table and destination
+func AnalyzeRouteTargets(routeTables []vpc.RouteTableInfo, subnets []vpc.SubnetInfo, privateRanges []string) []RouteTargetFinding {
+	findings := []RouteTargetFinding{}
+
+	// Subnets without an explicit association use the main route table of their VPC
+	associated := make(map[string]bool)
+	for _, rt := range routeTables {
+		for _, subnetID := range rt.SubnetIDs {
+			associated[subnetID] = true
+		}
+	}
+	implicit := make(map[string][]string)
+	for _, subnet := range subnets {
+		if !associated[subnet.SubnetID] {
+			implicit[subnet.VpcID] = append(implicit[subnet.VpcID], subnet.SubnetID)
+		}
+	}
+
+	for _, rt := range routeTables {
+		subnetIDs := append([]string{}, rt.SubnetIDs...)
+		if rt.IsMainRouteTable {
+			subnetIDs = append(subnetIDs, implicit[rt.VpcID]...)
+		}
+		sort.Strings(subnetIDs)
+
+		for _, route := range rt.Routes {
+			destination := route.DestinationCidrBlock
+			if destination == "" {
+				destination = route.DestinationIpv6Block
+			}
+			if destination == "" {
+				continue
+			}
+			finding := RouteTargetFinding{
+				RouteTableID: rt.RouteTableID,
+				VpcID:        rt.VpcID,
+				Destination:  destination,
+				TargetType:   route.Target.Type,
+				TargetID:     route.Target.ID,
+				SubnetIDs:    subnetIDs,
+			}
+
+			switch route.Target.Type {
+			case vpc.RouteTargetInternetGateway, vpc.RouteTargetEgressOnlyInternetGateway:
+				privateRange, within := matchPrivateRange(destination, privateRanges)
+				if privateRange == "" {
+					continue
+				}
+				finding.PrivateRange = privateRange
+				if within {
+					finding.Classification = PrivateRangeToInternet
+					finding.Severity = SeverityHigh
+					finding.Reason = fmt.Sprintf("%s is within the private range %s but routes to %s; the traffic leaves the VPC for the internet instead of reaching a private network", destination, privateRange, route.Target.ID)
+				} else {
+					finding.Classification = PrivateRangeCovered
+					finding.Severity = SeverityMedium
+					finding.Reason = fmt.Sprintf("%s covers the private range %s and routes to %s; traffic to that range without a more specific route leaves the VPC for the internet", destination, privateRange, route.Target.ID)
+				}
+			case vpc.RouteTargetVpcPeeringConnection:
+				if destination != defaultRouteDestination && destination != defaultIpv6Destination {
+					continue
+				}
+				finding.Classification = DefaultRouteToPeering
+				finding.Severity = SeverityHigh
+				finding.Reason = fmt.Sprintf("default route %s targets the peering connection %s; peering does not forward traffic to other networks, so everything without a more specific route is dropped", destination, route.Target.ID)
+			default:
+				continue
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].RouteTableID != findings[j].RouteTableID {
+			return findings[i].RouteTableID < findings[j].RouteTableID
+		}
+		return findings[i].Destination < findings[j].Destination
+	})
+	return findings
+}
+
+// matchPrivateRange finds the private range a route destination falls within or covers
+// A range that contains the destination wins over one the destination only covers. The default
+// routes cover every range but are how gateways are meant to be used, so they never match.
+// Returns: The matching range (empty if none), and whether the destination is within it
+func matchPrivateRange(destination string, privateRanges []string) (string, bool) {
+	if destination == defaultRouteDestination || destination == defaultIpv6Destination {
+		return "", false
+	}
+	covered := ""
+	for _, privateRange := range privateRanges {
+		// Destinations come from the API and ranges were validated, so errors only mean no match
+		if within, err := netcalc.CIDRContains(privateRange, destination); err == nil && within {
+			return privateRange, true
+		}
+		if covers, err := netcalc.CIDRContains(destination, privateRange); err == nil && covers && covered == "" {
+			covered = privateRange
+		}
+	}
+	return covered, false
+}
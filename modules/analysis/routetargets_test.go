@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestMatchPrivateRange covers exact matches, destinations within and covering a private range, the default
+// routes, mixed address families and corporate ranges next to the RFC 1918 blocks
+func TestMatchPrivateRange(t *testing.T) {
+	corporate := append(append([]string{}, DefaultPrivateRanges...), "203.0.113.0/24", "100.64.0.0/10")
+	tests := []struct {
+		name        string
+		destination string
+		ranges      []string
+		want        string
+		wantWithin  bool
+	}{
+		{name: "exact match", destination: "10.0.0.0/8", ranges: DefaultPrivateRanges, want: "10.0.0.0/8", wantWithin: true},
+		{name: "within", destination: "172.20.0.0/16", ranges: DefaultPrivateRanges, want: "172.16.0.0/12", wantWithin: true},
+		{name: "host route", destination: "192.168.10.5/32", ranges: DefaultPrivateRanges, want: "192.168.0.0/16", wantWithin: true},
+		{name: "covering supernet", destination: "10.0.0.0/7", ranges: DefaultPrivateRanges, want: "10.0.0.0/8"},
+		{name: "half of the address space", destination: "128.0.0.0/1", ranges: DefaultPrivateRanges, want: "172.16.0.0/12"},
+		{name: "next to a range", destination: "172.32.0.0/16", ranges: DefaultPrivateRanges},
+		{name: "public", destination: "52.94.0.0/16", ranges: DefaultPrivateRanges},
+		{name: "default route", destination: "0.0.0.0/0", ranges: DefaultPrivateRanges},
+		{name: "IPv6 default route", destination: "::/0", ranges: DefaultPrivateRanges},
+		{name: "IPv6 unique local", destination: "fd12:3456::/48", ranges: DefaultPrivateRanges, want: "fc00::/7", wantWithin: true},
+		{name: "IPv6 global", destination: "2001:db8::/32", ranges: DefaultPrivateRanges},
+		{name: "within wins over covered", destination: "100.64.0.0/10", ranges: []string{"100.64.1.0/24", "100.0.0.0/8"}, want: "100.0.0.0/8", wantWithin: true},
+		{name: "corporate public range", destination: "203.0.113.128/25", ranges: corporate, want: "203.0.113.0/24", wantWithin: true},
+		{name: "corporate range without the override", destination: "203.0.113.128/25", ranges: DefaultPrivateRanges},
+		{name: "covering a corporate range", destination: "100.0.0.0/8", ranges: corporate, want: "100.64.0.0/10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, within := matchPrivateRange(tt.destination, tt.ranges)
+			if got != tt.want || within != tt.wantWithin {
+				t.Errorf("matchPrivateRange(%q) = %q, %v, want %q, %v", tt.destination, got, within, tt.want, tt.wantWithin)
+			}
+		})
+	}
+}
+
+// TestAnalyzeRouteTargets checks the findings of a route table with private ranges routed to internet gateways
+// and a default route to a peering connection, and the subnets listed for explicit and main route tables
+func TestAnalyzeRouteTargets(t *testing.T) {
+	route := func(destination, targetType, targetID string) vpc.RouteInfo {
+		r := vpc.RouteInfo{DestinationCidrBlock: destination, Target: vpc.RouteTarget{Type: targetType, ID: targetID}, State: "active"}
+		if destination[0] == ':' || destination[0] == 'f' {
+			r.DestinationCidrBlock, r.DestinationIpv6Block = "", destination
+		}
+		return r
+	}
+	routeTables := []vpc.RouteTableInfo{
+		{RouteTableID: "rtb-0public", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0b2", "subnet-0a1"}, Routes: []vpc.RouteInfo{
+			route("10.0.0.0/16", vpc.RouteTargetLocal, "local"),
+			route("0.0.0.0/0", vpc.RouteTargetInternetGateway, "igw-0a1"),
+			route("::/0", vpc.RouteTargetEgressOnlyInternetGateway, "eigw-0a1"),
+			route("10.0.0.0/8", vpc.RouteTargetInternetGateway, "igw-0a1"),
+			route("fd00::/8", vpc.RouteTargetEgressOnlyInternetGateway, "eigw-0a1"),
+			route("172.16.0.0/12", vpc.RouteTargetTransitGateway, "tgw-0a1"),
+			{Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}},
+		}},
+		{RouteTableID: "rtb-0main", VpcID: "vpc-0a1", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+			route("0.0.0.0/0", vpc.RouteTargetVpcPeeringConnection, "pcx-0a1"),
+			route("192.0.0.0/2", vpc.RouteTargetInternetGateway, "igw-0a1"),
+			route("10.1.0.0/16", vpc.RouteTargetVpcPeeringConnection, "pcx-0a1"),
+		}},
+		{RouteTableID: "rtb-0other", VpcID: "vpc-0b2", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+			route("0.0.0.0/0", vpc.RouteTargetNatGateway, "nat-0b2"),
+		}},
+	}
+	subnets := []vpc.SubnetInfo{
+		{SubnetID: "subnet-0a1", VpcID: "vpc-0a1"},
+		{SubnetID: "subnet-0b2", VpcID: "vpc-0a1"},
+		{SubnetID: "subnet-0c3", VpcID: "vpc-0a1"},
+		{SubnetID: "subnet-0d4", VpcID: "vpc-0b2"},
+	}
+
+	public := []string{"subnet-0a1", "subnet-0b2"}
+	main := []string{"subnet-0c3"}
+	want := []RouteTargetFinding{
+		{RouteTableID: "rtb-0main", VpcID: "vpc-0a1", Destination: "0.0.0.0/0", TargetType: vpc.RouteTargetVpcPeeringConnection, TargetID: "pcx-0a1",
+			SubnetIDs: main, Classification: DefaultRouteToPeering, Severity: SeverityHigh,
+			Reason: "default route 0.0.0.0/0 targets the peering connection pcx-0a1; peering does not forward traffic to other networks, so everything without a more specific route is dropped"},
+		{RouteTableID: "rtb-0main", VpcID: "vpc-0a1", Destination: "192.0.0.0/2", TargetType: vpc.RouteTargetInternetGateway, TargetID: "igw-0a1",
+			PrivateRange: "192.168.0.0/16", SubnetIDs: main, Classification: PrivateRangeCovered, Severity: SeverityMedium,
+			Reason: "192.0.0.0/2 covers the private range 192.168.0.0/16 and routes to igw-0a1; traffic to that range without a more specific route leaves the VPC for the internet"},
+		{RouteTableID: "rtb-0public", VpcID: "vpc-0a1", Destination: "10.0.0.0/8", TargetType: vpc.RouteTargetInternetGateway, TargetID: "igw-0a1",
+			PrivateRange: "10.0.0.0/8", SubnetIDs: public, Classification: PrivateRangeToInternet, Severity: SeverityHigh,
+			Reason: "10.0.0.0/8 is within the private range 10.0.0.0/8 but routes to igw-0a1; the traffic leaves the VPC for the internet instead of reaching a private network"},
+		{RouteTableID: "rtb-0public", VpcID: "vpc-0a1", Destination: "fd00::/8", TargetType: vpc.RouteTargetEgressOnlyInternetGateway, TargetID: "eigw-0a1",
+			PrivateRange: "fc00::/7", SubnetIDs: public, Classification: PrivateRangeToInternet, Severity: SeverityHigh,
+			Reason: "fd00::/8 is within the private range fc00::/7 but routes to eigw-0a1; the traffic leaves the VPC for the internet instead of reaching a private network"},
+	}
+	if got := AnalyzeRouteTargets(routeTables, subnets, DefaultPrivateRanges); !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeRouteTargets() =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := AnalyzeRouteTargets(nil, nil, DefaultPrivateRanges); got == nil || len(got) != 0 {
+		t.Errorf("AnalyzeRouteTargets() without route tables = %#v, want an empty list", got)
+	}
+}
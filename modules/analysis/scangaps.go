@@ -0,0 +1,258 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/replication"
+	"aws-documentor/modules/vpc"
+)
+
+// Scan gap kinds, named after the construct whose other side was not scanned
+const (
+	GapVpcPeering = "vpc-peering" // Peering connection to a VPC in another region or account
+	GapTGWPeering = "tgw-peering" // Transit gateway peering attachment to a gateway in another region or account
+	// Replication gaps use the replication kind (rds-read-replica, efs-replication)
+)
+
+// ResolvedPeer is what a follow-up scan found about the other side of a gap
+type ResolvedPeer struct {
+	Name       string   `json:"name"`        // Name tag of the VPC or transit gateway
+	State      string   `json:"state"`       // State of the VPC or transit gateway
+	CidrBlocks []string `json:"cidr_blocks"` // IPv4 and IPv6 CIDR blocks of a VPC (empty for transit gateways)
+}
+
+// ScanGap is a reference from a scanned resource to a resource in a region or account that was not scanned
+type ScanGap struct {
+	Kind             string        `json:"kind"`               // vpc-peering, tgw-peering, rds-read-replica or efs-replication
+	ResourceID       string        `json:"resource_id"`        // Peering connection, attachment or replica that holds the reference
+	LocalResourceID  string        `json:"local_resource_id"`  // Scanned VPC, transit gateway or replicated resource
+	RemoteResourceID string        `json:"remote_resource_id"` // Peer VPC, peer transit gateway or remote side of the replication
+	RemoteRegion     string        `json:"remote_region"`      // Region of the remote resource
+	RemoteAccountID  string        `json:"remote_account_id"`  // Account of the remote resource, empty if unknown
+	CrossAccount     bool          `json:"cross_account"`      // Whether the remote resource is owned by another account
+	Resolution       string        `json:"resolution"`         // Scan that would document the remote side
+	Resolved         *ResolvedPeer `json:"resolved,omitempty"` // Remote side found by -auto-expand-regions (nil if not scanned)
+}
+
+// UnavailableService is an optional scan skipped because its service cannot be used in a region
+type UnavailableService struct {
+	Service      string `json:"service"`       // IAM service prefix of the service (ecs, workspaces, ...)
+	ResourceType string `json:"resource_type"` // Resource type that was not scanned
+	Region       string `json:"region"`        // Region the service is not available in
+	Reason       string `json:"reason"`        // Why the service could not be used ("is not available in this region", ...)
+}
+
+// ScanGapReport lists the references that lead out of the scanned regions and accounts
+type ScanGapReport struct {
+	ScannedRegions      []string             `json:"scanned_regions"`      // Regions the scan covered
+	Gaps                []ScanGap            `json:"scan_gaps"`            // References to resources outside the scan, sorted by region and resource
+	MissingRegions      []string             `json:"missing_regions"`      // Regions that would resolve same-account gaps
+	MissingAccounts     []string             `json:"missing_accounts"`     // Accounts that would resolve cross-account gaps
+	UnavailableServices []UnavailableService `json:"unavailable_services"` // Optional scans skipped because their service cannot be used in the region
+}
+
+// FollowUpScope is the minimal scan of one region that resolves its same-account gaps
+type FollowUpScope struct {
+	Region            string   // Region to scan
+	VpcIDs            []string // Peer VPCs to describe
+	TransitGatewayIDs []string // Peer transit gateways to describe
+}
+
+// FindScanGaps finds peering connections, transit gateway peerings and replication links whose other side
+// is in a region or account the scan did not cover
+// A peering connection's local side is the side whose VPC was scanned; connections and attachments that
+// are deleted, rejected or failed are skipped. Transit gateway peerings without a peer region (the peering
+// attachments were not readable) cannot be placed and are skipped.
+// scannedRegions: The scanned region and any -dr-regions
+// vpcs: VPCs from the scan
+// transitGateways: Transit gateways from the scan, for the owning account
+// peerings: VPC peering connections, nil if they were not scanned
+// attachments: Transit gateway attachments from the scan
+// relationships: Replication relationships from -dr-replication, nil if not scanned
+// Returns: Report with one gap per reference and the regions and accounts that would resolve them
+func FindScanGaps(scannedRegions []string, vpcs []vpc.VPCInfo, transitGateways []vpc.TransitGatewayInfo,
+	peerings []vpc.VpcPeeringConnectionInfo, attachments []vpc.TransitGatewayAttachmentInfo,
+	relationships []replication.Relationship) *ScanGapReport {
+	report := &ScanGapReport{
+		ScannedRegions:      append([]string{}, scannedRegions...),
+		Gaps:                []ScanGap{},
+		MissingRegions:      []string{},
+		MissingAccounts:     []string{},
+		UnavailableServices: []UnavailableService{},
+	}
+
+	scanned := make(map[string]bool)
+	for _, region := range scannedRegions {
+		scanned[region] = true
+	}
+	scannedVPCs := make(map[string]bool)
+	for _, v := range vpcs {
+		scannedVPCs[v.VpcID] = true
+	}
+	tgwOwners := make(map[string]string)
+	for _, tgw := range transitGateways {
+		tgwOwners[tgw.TransitGatewayID] = tgw.OwnerID
+	}
+
+	for _, pcx := range peerings {
+		if inactivePeering(pcx.Status) {
+			continue
+		}
+		localVPC, localOwner := pcx.RequesterVpcID, pcx.RequesterOwnerID
+		remoteVPC, remoteRegion, remoteOwner := pcx.AccepterVpcID, pcx.AccepterRegion, pcx.AccepterOwnerID
+		if !scannedVPCs[localVPC] {
+			localVPC, localOwner = pcx.AccepterVpcID, pcx.AccepterOwnerID
+			remoteVPC, remoteRegion, remoteOwner = pcx.RequesterVpcID, pcx.RequesterRegion, pcx.RequesterOwnerID
+		}
+		if !scannedVPCs[localVPC] || scannedVPCs[remoteVPC] {
+			continue
+		}
+		crossAccount := remoteOwner != "" && localOwner != "" && remoteOwner != localOwner
+		if scanned[remoteRegion] && !crossAccount {
+			continue
+		}
+		report.Gaps = append(report.Gaps, newScanGap(GapVpcPeering, pcx.VpcPeeringConnectionID, localVPC, remoteVPC, remoteRegion, remoteOwner, crossAccount))
+	}
+
+	for _, attachment := range attachments {
+		if attachment.ResourceType != "peering" || attachment.PeerRegion == "" || inactiveAttachment(attachment.State) {
+			continue
+		}
+		localOwner := tgwOwners[attachment.TransitGatewayID]
+		crossAccount := attachment.ResourceOwnerID != "" && localOwner != "" && attachment.ResourceOwnerID != localOwner
+		if scanned[attachment.PeerRegion] && !crossAccount {
+			continue
+		}
+		report.Gaps = append(report.Gaps, newScanGap(GapTGWPeering, attachment.AttachmentID, attachment.TransitGatewayID,
+			attachment.ResourceID, attachment.PeerRegion, attachment.ResourceOwnerID, crossAccount))
+	}
+
+	for _, relationship := range relationships {
+		local, remote := relationship.Source, relationship.Target
+		if !scanned[local.Region] {
+			local, remote = remote, local
+		}
+		if !scanned[local.Region] || scanned[remote.Region] || remote.Region == "" {
+			continue
+		}
+		gap := newScanGap(relationship.Kind, relationship.Target.ResourceID, local.ResourceID, remote.ResourceID, remote.Region, "", false)
+		gap.Resolution = fmt.Sprintf("add %s to -dr-regions", remote.Region)
+		report.Gaps = append(report.Gaps, gap)
+	}
+
+	sort.Slice(report.Gaps, func(i, j int) bool {
+		if report.Gaps[i].RemoteRegion != report.Gaps[j].RemoteRegion {
+			return report.Gaps[i].RemoteRegion < report.Gaps[j].RemoteRegion
+		}
+		return report.Gaps[i].ResourceID < report.Gaps[j].ResourceID
+	})
+
+	regions := make(map[string]bool)
+	accounts := make(map[string]bool)
+	for _, gap := range report.Gaps {
+		if gap.CrossAccount {
+			accounts[gap.RemoteAccountID] = true
+		} else {
+			regions[gap.RemoteRegion] = true
+		}
+	}
+	report.MissingRegions = sortedKeys(regions)
+	report.MissingAccounts = sortedKeys(accounts)
+	return report
+}
+
+// newScanGap creates a gap with the scan that resolves it
+func newScanGap(kind, resourceID, localID, remoteID, region, account string, crossAccount bool) ScanGap {
+	gap := ScanGap{
+		Kind:             kind,
+		ResourceID:       resourceID,
+		LocalResourceID:  localID,
+		RemoteResourceID: remoteID,
+		RemoteRegion:     region,
+		RemoteAccountID:  account,
+		CrossAccount:     crossAccount,
+		Resolution:       fmt.Sprintf("scan with -region %s, or set -auto-expand-regions", region),
+	}
+	if crossAccount {
+		gap.Resolution = fmt.Sprintf("scan %s with credentials for account %s", region, account)
+	}
+	return gap
+}
+
+// FollowUpScopes returns the minimal follow-up scan per region that resolves the same-account peering gaps
+// Cross-account gaps need other credentials and replication gaps need the replication scanners, so
+// neither is scoped.
+// report: Report from FindScanGaps
+// Returns: One scope per region, sorted by region
+func FollowUpScopes(report *ScanGapReport) []FollowUpScope {
+	byRegion := make(map[string]*FollowUpScope)
+	var regions []string
+	for _, gap := range report.Gaps {
+		if gap.CrossAccount || (gap.Kind != GapVpcPeering && gap.Kind != GapTGWPeering) {
+			continue
+		}
+		scope, ok := byRegion[gap.RemoteRegion]
+		if !ok {
+			scope = &FollowUpScope{Region: gap.RemoteRegion, VpcIDs: []string{}, TransitGatewayIDs: []string{}}
+			byRegion[gap.RemoteRegion] = scope
+			regions = append(regions, gap.RemoteRegion)
+		}
+		if gap.Kind == GapVpcPeering {
+			scope.VpcIDs = appendUnique(scope.VpcIDs, gap.RemoteResourceID)
+		} else {
+			scope.TransitGatewayIDs = appendUnique(scope.TransitGatewayIDs, gap.RemoteResourceID)
+		}
+	}
+
+	sort.Strings(regions)
+	scopes := []FollowUpScope{}
+	for _, region := range regions {
+		scopes = append(scopes, *byRegion[region])
+	}
+	return scopes
+}
+
+// ResolveScanGaps fills in the remote side of the gaps in one region from a follow-up scan
+// report: Report from FindScanGaps, updated in place
+// region: Region of the follow-up scan
+// vpcs: Peer VPCs found in the region
+// transitGateways: Peer transit gateways found in the region
+func ResolveScanGaps(report *ScanGapReport, region string, vpcs []vpc.VPCInfo, transitGateways []vpc.TransitGatewayInfo) {
+	peers := make(map[string]*ResolvedPeer)
+	for _, v := range vpcs {
+		cidrs := append(append([]string{}, v.AssociateCidrBlocks...), v.Ipv6CidrBlocks...)
+		peers[v.VpcID] = &ResolvedPeer{Name: v.Tags["Name"], State: v.State, CidrBlocks: cidrs}
+	}
+	for _, tgw := range transitGateways {
+		peers[tgw.TransitGatewayID] = &ResolvedPeer{Name: tgw.Tags["Name"], State: tgw.State, CidrBlocks: []string{}}
+	}
+
+	for i := range report.Gaps {
+		gap := &report.Gaps[i]
+		if gap.RemoteRegion != region || gap.CrossAccount {
+			continue
+		}
+		if peer, ok := peers[gap.RemoteResourceID]; ok {
+			gap.Resolved = peer
+		}
+	}
+}
+
+// inactivePeering reports whether a peering connection status no longer links two VPCs
+func inactivePeering(status string) bool {
+	switch status {
+	case "deleted", "deleting", "rejected", "failed", "expired":
+		return true
+	}
+	return false
+}
+
+// inactiveAttachment reports whether a transit gateway attachment state no longer links two gateways
+func inactiveAttachment(state string) bool {
+	switch state {
+	case "deleted", "deleting", "rejected", "rejecting", "failed", "failing":
+		return true
+	}
+	return false
+}
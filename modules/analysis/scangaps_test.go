@@ -0,0 +1,152 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/replication"
+	"aws-documentor/modules/vpc"
+)
+
+// gapFixture is a scan of eu-west-1 in account 111122223333 with peerings and transit gateway peerings into
+// scanned and unscanned regions and accounts
+type gapFixture struct {
+	vpcs            []vpc.VPCInfo
+	transitGateways []vpc.TransitGatewayInfo
+	peerings        []vpc.VpcPeeringConnectionInfo
+	attachments     []vpc.TransitGatewayAttachmentInfo
+	relationships   []replication.Relationship
+}
+
+// newGapFixture returns the scan every scan gap test starts from
+func newGapFixture() gapFixture {
+	return gapFixture{
+		vpcs:            []vpc.VPCInfo{{VpcID: "vpc-0a1"}, {VpcID: "vpc-0b2"}},
+		transitGateways: []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", OwnerID: "111122223333"}},
+		peerings: []vpc.VpcPeeringConnectionInfo{
+			// Requested here, accepted in an unscanned region
+			{VpcPeeringConnectionID: "pcx-0us", RequesterVpcID: "vpc-0a1", RequesterRegion: "eu-west-1", RequesterOwnerID: "111122223333",
+				AccepterVpcID: "vpc-0us", AccepterRegion: "us-east-1", AccepterOwnerID: "111122223333", Status: "active"},
+			// Requested from an unscanned region, accepted here
+			{VpcPeeringConnectionID: "pcx-0ap", RequesterVpcID: "vpc-0ap", RequesterRegion: "ap-southeast-2", RequesterOwnerID: "111122223333",
+				AccepterVpcID: "vpc-0b2", AccepterRegion: "eu-west-1", AccepterOwnerID: "111122223333", Status: "pending-acceptance"},
+			// A second peering to the same peer VPC
+			{VpcPeeringConnectionID: "pcx-0us2", RequesterVpcID: "vpc-0b2", RequesterRegion: "eu-west-1", RequesterOwnerID: "111122223333",
+				AccepterVpcID: "vpc-0us", AccepterRegion: "us-east-1", AccepterOwnerID: "111122223333", Status: "active"},
+			// Another account in the scanned region
+			{VpcPeeringConnectionID: "pcx-0partner", RequesterVpcID: "vpc-0a1", RequesterRegion: "eu-west-1", RequesterOwnerID: "111122223333",
+				AccepterVpcID: "vpc-0partner", AccepterRegion: "eu-west-1", AccepterOwnerID: "444455556666", Status: "active"},
+			// Both sides scanned
+			{VpcPeeringConnectionID: "pcx-0local", RequesterVpcID: "vpc-0a1", RequesterRegion: "eu-west-1", RequesterOwnerID: "111122223333",
+				AccepterVpcID: "vpc-0b2", AccepterRegion: "eu-west-1", AccepterOwnerID: "111122223333", Status: "active"},
+			// Deleted
+			{VpcPeeringConnectionID: "pcx-0deleted", RequesterVpcID: "vpc-0a1", RequesterRegion: "eu-west-1", RequesterOwnerID: "111122223333",
+				AccepterVpcID: "vpc-0old", AccepterRegion: "us-west-2", AccepterOwnerID: "111122223333", Status: "deleted"},
+		},
+		attachments: []vpc.TransitGatewayAttachmentInfo{
+			{AttachmentID: "tgw-attach-0us", TransitGatewayID: "tgw-0a1", ResourceType: "peering", ResourceID: "tgw-0us",
+				ResourceOwnerID: "111122223333", State: "available", PeerRegion: "us-east-1"},
+			{AttachmentID: "tgw-attach-0partner", TransitGatewayID: "tgw-0a1", ResourceType: "peering", ResourceID: "tgw-0partner",
+				ResourceOwnerID: "444455556666", State: "pendingAcceptance", PeerRegion: "eu-central-1"},
+			{AttachmentID: "tgw-attach-0local", TransitGatewayID: "tgw-0a1", ResourceType: "peering", ResourceID: "tgw-0b2",
+				ResourceOwnerID: "111122223333", State: "available", PeerRegion: "eu-west-1"},
+			{AttachmentID: "tgw-attach-0unknown", TransitGatewayID: "tgw-0a1", ResourceType: "peering", ResourceID: "tgw-0unknown", State: "available"},
+			{AttachmentID: "tgw-attach-0rejected", TransitGatewayID: "tgw-0a1", ResourceType: "peering", ResourceID: "tgw-0sa",
+				ResourceOwnerID: "111122223333", State: "rejected", PeerRegion: "sa-east-1"},
+			{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0a1", State: "available"},
+		},
+		relationships: []replication.Relationship{
+			{Kind: "rds-read-replica", Source: replication.Endpoint{ResourceID: "arn:aws:rds:eu-west-1:111122223333:db:orders", Region: "eu-west-1"},
+				Target: replication.Endpoint{ResourceID: "arn:aws:rds:us-west-2:111122223333:db:orders-replica", Region: "us-west-2"}},
+		},
+	}
+}
+
+// TestFindScanGaps checks which peerings and replicas lead out of the scan and what resolves them
+func TestFindScanGaps(t *testing.T) {
+	f := newGapFixture()
+	report := FindScanGaps([]string{"eu-west-1"}, f.vpcs, f.transitGateways, f.peerings, f.attachments, f.relationships)
+
+	want := []ScanGap{
+		{Kind: GapVpcPeering, ResourceID: "pcx-0ap", LocalResourceID: "vpc-0b2", RemoteResourceID: "vpc-0ap", RemoteRegion: "ap-southeast-2",
+			RemoteAccountID: "111122223333", Resolution: "scan with -region ap-southeast-2, or set -auto-expand-regions"},
+		{Kind: GapTGWPeering, ResourceID: "tgw-attach-0partner", LocalResourceID: "tgw-0a1", RemoteResourceID: "tgw-0partner", RemoteRegion: "eu-central-1",
+			RemoteAccountID: "444455556666", CrossAccount: true, Resolution: "scan eu-central-1 with credentials for account 444455556666"},
+		{Kind: GapVpcPeering, ResourceID: "pcx-0partner", LocalResourceID: "vpc-0a1", RemoteResourceID: "vpc-0partner", RemoteRegion: "eu-west-1",
+			RemoteAccountID: "444455556666", CrossAccount: true, Resolution: "scan eu-west-1 with credentials for account 444455556666"},
+		{Kind: GapVpcPeering, ResourceID: "pcx-0us", LocalResourceID: "vpc-0a1", RemoteResourceID: "vpc-0us", RemoteRegion: "us-east-1",
+			RemoteAccountID: "111122223333", Resolution: "scan with -region us-east-1, or set -auto-expand-regions"},
+		{Kind: GapVpcPeering, ResourceID: "pcx-0us2", LocalResourceID: "vpc-0b2", RemoteResourceID: "vpc-0us", RemoteRegion: "us-east-1",
+			RemoteAccountID: "111122223333", Resolution: "scan with -region us-east-1, or set -auto-expand-regions"},
+		{Kind: GapTGWPeering, ResourceID: "tgw-attach-0us", LocalResourceID: "tgw-0a1", RemoteResourceID: "tgw-0us", RemoteRegion: "us-east-1",
+			RemoteAccountID: "111122223333", Resolution: "scan with -region us-east-1, or set -auto-expand-regions"},
+		{Kind: "rds-read-replica", ResourceID: "arn:aws:rds:us-west-2:111122223333:db:orders-replica", LocalResourceID: "arn:aws:rds:eu-west-1:111122223333:db:orders",
+			RemoteResourceID: "arn:aws:rds:us-west-2:111122223333:db:orders-replica", RemoteRegion: "us-west-2", Resolution: "add us-west-2 to -dr-regions"},
+	}
+	if !reflect.DeepEqual(report.Gaps, want) {
+		t.Errorf("gaps = %+v\nwant %+v", report.Gaps, want)
+	}
+	if wantRegions := []string{"ap-southeast-2", "us-east-1", "us-west-2"}; !reflect.DeepEqual(report.MissingRegions, wantRegions) {
+		t.Errorf("missing regions = %v, want %v", report.MissingRegions, wantRegions)
+	}
+	if wantAccounts := []string{"444455556666"}; !reflect.DeepEqual(report.MissingAccounts, wantAccounts) {
+		t.Errorf("missing accounts = %v, want %v", report.MissingAccounts, wantAccounts)
+	}
+}
+
+// TestScanGapsWithDRRegions checks that regions scanned with -dr-regions close their gaps, including a
+// replica whose primary is in the DR region
+func TestScanGapsWithDRRegions(t *testing.T) {
+	f := newGapFixture()
+	f.relationships = append(f.relationships, replication.Relationship{Kind: "efs-replication",
+		Source: replication.Endpoint{ResourceID: "fs-0us", Region: "us-east-1"}, Target: replication.Endpoint{ResourceID: "fs-0eu", Region: "eu-west-1"}})
+	report := FindScanGaps([]string{"eu-west-1", "us-east-1", "us-west-2"}, f.vpcs, f.transitGateways, f.peerings, f.attachments, f.relationships)
+
+	var got []string
+	for _, gap := range report.Gaps {
+		got = append(got, gap.ResourceID)
+	}
+	if want := []string{"pcx-0ap", "tgw-attach-0partner", "pcx-0partner"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("gaps = %v, want %v", got, want)
+	}
+	if want := []string{"ap-southeast-2"}; !reflect.DeepEqual(report.MissingRegions, want) {
+		t.Errorf("missing regions = %v, want %v", report.MissingRegions, want)
+	}
+}
+
+// TestFollowUpScopes checks that follow-up scans cover each same-account peer once per region and leave out
+// cross-account and replication gaps
+func TestFollowUpScopes(t *testing.T) {
+	f := newGapFixture()
+	report := FindScanGaps([]string{"eu-west-1"}, f.vpcs, f.transitGateways, f.peerings, f.attachments, f.relationships)
+
+	want := []FollowUpScope{
+		{Region: "ap-southeast-2", VpcIDs: []string{"vpc-0ap"}, TransitGatewayIDs: []string{}},
+		{Region: "us-east-1", VpcIDs: []string{"vpc-0us"}, TransitGatewayIDs: []string{"tgw-0us"}},
+	}
+	if got := FollowUpScopes(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("FollowUpScopes() = %+v\nwant %+v", got, want)
+	}
+	if got := FollowUpScopes(&ScanGapReport{}); got == nil || len(got) != 0 {
+		t.Errorf("FollowUpScopes() without gaps = %#v, want an empty list", got)
+	}
+}
+
+// TestResolveScanGaps checks that a follow-up scan fills in the peers of its region only
+func TestResolveScanGaps(t *testing.T) {
+	f := newGapFixture()
+	report := FindScanGaps([]string{"eu-west-1"}, f.vpcs, f.transitGateways, f.peerings, f.attachments, f.relationships)
+	ResolveScanGaps(report, "us-east-1",
+		[]vpc.VPCInfo{{VpcID: "vpc-0us", State: "available", Tags: map[string]string{"Name": "shared-us"},
+			AssociateCidrBlocks: []string{"10.20.0.0/16", "10.21.0.0/16"}, Ipv6CidrBlocks: []string{"2001:db8:20::/56"}}},
+		[]vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0us", State: "available", Tags: map[string]string{"Name": "core-us"}}})
+
+	vpcPeer := &ResolvedPeer{Name: "shared-us", State: "available", CidrBlocks: []string{"10.20.0.0/16", "10.21.0.0/16", "2001:db8:20::/56"}}
+	tgwPeer := &ResolvedPeer{Name: "core-us", State: "available", CidrBlocks: []string{}}
+	want := map[string]*ResolvedPeer{"pcx-0us": vpcPeer, "pcx-0us2": vpcPeer, "tgw-attach-0us": tgwPeer}
+	for _, gap := range report.Gaps {
+		if !reflect.DeepEqual(gap.Resolved, want[gap.ResourceID]) {
+			t.Errorf("%s resolved = %+v, want %+v", gap.ResourceID, gap.Resolved, want[gap.ResourceID])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/vpc"
+)
+
+// sensitivePorts are the TCP ports that should never be open to the internet, with the service they serve
+// Remote administration gives a shell on its own; the databases at least ask for their own credentials.
+var sensitivePorts = []struct {
+	port     int32
+	service  string
+	severity string
+}{
+	{22, "SSH", SeverityHigh},
+	{3389, "RDP", SeverityHigh},
+	{1433, "SQL Server", SeverityMedium},
+	{3306, "MySQL", SeverityMedium},
+	{5432, "PostgreSQL", SeverityMedium},
+	{27017, "MongoDB", SeverityMedium},
+}
+
+// SecurityGroupFinding describes a security group rule that opens the group wider than it should be
+type SecurityGroupFinding struct {
+	GroupID     string `json:"group_id"`    // ID of the security group containing the rule
+	GroupName   string `json:"group_name"`  // Name of the security group containing the rule
+	VpcID       string `json:"vpc_id"`      // ID of the VPC of the security group
+	RuleIndex   int    `json:"rule_index"`  // Index of the rule in the group's rules
+	Rule        string `json:"rule"`        // The rule (protocol, ports and source or destination)
+	Severity    string `json:"severity"`    // high for all traffic or remote administration from anywhere, medium for databases, low for egress
+	Description string `json:"description"` // Human-readable explanation
+}
+
+// AnalyzeSecurityGroups flags rules that open a security group to the whole internet (0.0.0.0/0 or ::/0)
+// Ingress of all traffic or of a sensitive port (SSH, RDP, SQL Server, MySQL, PostgreSQL, MongoDB) is
+// flagged, as is egress of all traffic. A rule covering several sensitive ports is reported once, at
+// the highest severity. AWS adds the egress rule to every new group, so expect a low finding for most groups.
+// groups: Security groups from the scan
+// Returns: Findings ordered by severity, then group ID and rule index
+func AnalyzeSecurityGroups(groups []vpc.SecurityGroupInfo) []SecurityGroupFinding {
+	findings := []SecurityGroupFinding{}
+
+	for _, sg := range groups {
+		for i, rule := range sg.Rules {
+			open := openPeer(rule)
+			if open == "" {
+				continue
+			}
+			finding := SecurityGroupFinding{
+				GroupID:   sg.GroupID,
+				GroupName: sg.GroupName,
+				VpcID:     sg.VpcID,
+				RuleIndex: i,
+				Rule:      describeRule(rule),
+			}
+
+			protocol := netcalc.NormalizeProtocol(rule.IpProtocol)
+			switch {
+			case rule.IsEgress && protocol == netcalc.ProtocolAll:
+				finding.Severity = SeverityLow
+				finding.Description = fmt.Sprintf("allows all outbound traffic to %s; data can leave to any address", open)
+			case rule.IsEgress:
+				continue
+			case protocol == netcalc.ProtocolAll:
+				finding.Severity = SeverityHigh
+				finding.Description = fmt.Sprintf("allows all inbound traffic from %s, every port of the group's resources is exposed", open)
+			case protocol == netcalc.ProtocolTCP:
+				var services []string
+				for _, sensitive := range sensitivePorts {
+					if !netcalc.PortRangeContains(rule.FromPort, rule.ToPort, sensitive.port, sensitive.port) {
+						continue
+					}
+					services = append(services, fmt.Sprintf("%s (%d)", sensitive.service, sensitive.port))
+					if finding.Severity != SeverityHigh {
+						finding.Severity = sensitive.severity
+					}
+				}
+				if len(services) == 0 {
+					continue
+				}
+				finding.Description = fmt.Sprintf("allows %s from %s", joinList(services), open)
+			default:
+				continue
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	// Most severe first, then a deterministic order for reports and diffs
+	rank := map[string]int{SeverityHigh: 0, SeverityMedium: 1, SeverityLow: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if rank[findings[i].Severity] != rank[findings[j].Severity] {
+			return rank[findings[i].Severity] < rank[findings[j].Severity]
+		}
+		if findings[i].GroupID != findings[j].GroupID {
+			return findings[i].GroupID < findings[j].GroupID
+		}
+		return findings[i].RuleIndex < findings[j].RuleIndex
+	})
+
+	return findings
+}
+
+// openPeer returns the CIDR of a rule that opens it to every address, or "" for a narrower peer
+func openPeer(rule vpc.SecurityGroupRule) string {
+	switch {
+	case rule.CidrBlock == "0.0.0.0/0":
+		return rule.CidrBlock
+	case rule.Ipv6CidrBlock == "::/0":
+		return rule.Ipv6CidrBlock
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/containers"
+	"aws-documentor/modules/vpc"
+)
+
+// Security group usage classes
+const (
+	SGUsageInUse    = "in-use"   // At least one network interface has the group attached
+	SGUsageWorkload = "workload" // No interface has the group, but an ECS service or EKS cluster is configured with it
+	SGUsageDefault  = "default"  // The default group of a VPC, which cannot be deleted
+	SGUsageOrphaned = "orphaned" // Nothing uses the group
+)
+
+// Security group user kinds
+const (
+	SGUserInterface      = "network-interface"
+	SGUserECSService     = "ecs-service"
+	SGUserEKSCluster     = "eks-cluster"
+	SGUserEKSNodegroup   = "eks-nodegroup"
+	SGUserFargateProfile = "eks-fargate-profile"
+)
+
+// SGUser is one resource using a security group
+type SGUser struct {
+	Kind   string `json:"kind"`   // network-interface, ecs-service, eks-cluster, eks-nodegroup or eks-fargate-profile
+	ID     string `json:"id"`     // Interface ID, cluster/service or cluster/node group name
+	Detail string `json:"detail"` // Interface type and description, or the workload's running and desired count
+}
+
+// SGUsage describes what uses one security group
+type SGUsage struct {
+	GroupID        string   `json:"group_id"`       // ID of the security group
+	GroupName      string   `json:"group_name"`     // Name of the security group
+	VpcID          string   `json:"vpc_id"`         // ID of the VPC of the security group
+	Classification string   `json:"classification"` // in-use, workload, default or orphaned
+	Users          []SGUser `json:"users"`          // Interfaces and container workloads using the group
+}
+
+// SGUsageFinding describes a security group nothing uses
+type SGUsageFinding struct {
+	GroupID   string `json:"group_id"`   // ID of the orphaned security group
+	GroupName string `json:"group_name"` // Name of the orphaned security group
+	VpcID     string `json:"vpc_id"`     // ID of the VPC of the security group
+	Severity  string `json:"severity"`   // Always low
+	Reason    string `json:"reason"`     // Human-readable explanation
+}
+
+// SGUsageReport contains the results of the security group usage analysis
+type SGUsageReport struct {
+	Groups   []SGUsage        `json:"groups"`   // Usage of every security group, sorted by VPC and group ID
+	Findings []SGUsageFinding `json:"findings"` // Orphaned groups
+}
+
+// AnalyzeSecurityGroupUsage maps every security group to the network interfaces and container workloads using it
+// Interfaces of awsvpc ECS tasks and Fargate pods exist only while tasks run, so the security groups of
+// ECS services and EKS node groups and Fargate profiles count as used even when they are scaled to zero.
+// A group that is only referenced by rules of other groups is still orphaned.
+// securityGroups: Security groups from the scan
+// interfaces: Security groups per network interface
+// services: ECS services, nil if they were not scanned
+// clusters: EKS clusters, nil if they were not scanned
+// Returns: Report with the users of every group and a finding per orphaned group
+func AnalyzeSecurityGroupUsage(securityGroups []vpc.SecurityGroupInfo, interfaces []vpc.InterfaceGroupsInfo,
+	services []containers.ECSServiceInfo, clusters []containers.EKSClusterInfo) *SGUsageReport {
+	report := &SGUsageReport{
+		Groups:   []SGUsage{},
+		Findings: []SGUsageFinding{},
+	}
+
+	users := make(map[string][]SGUser)
+	for _, eni := range interfaces {
+		for _, groupID := range eni.GroupIDs {
+			users[groupID] = append(users[groupID], SGUser{
+				Kind:   SGUserInterface,
+				ID:     eni.NetworkInterfaceID,
+				Detail: strings.TrimSpace(eni.InterfaceType + " " + eni.Description),
+			})
+		}
+	}
+	for _, service := range services {
+		for _, groupID := range service.SecurityGroupIDs {
+			users[groupID] = append(users[groupID], SGUser{
+				Kind:   SGUserECSService,
+				ID:     service.ClusterName + "/" + service.ServiceName,
+				Detail: fmt.Sprintf("%d of %d tasks running", service.RunningCount, service.DesiredCount),
+			})
+		}
+	}
+	for _, cluster := range clusters {
+		clusterGroups := append([]string{}, cluster.SecurityGroupIDs...)
+		if cluster.ClusterSecurityGroupID != "" {
+			clusterGroups = append(clusterGroups, cluster.ClusterSecurityGroupID)
+		}
+		for _, groupID := range clusterGroups {
+			users[groupID] = append(users[groupID], SGUser{Kind: SGUserEKSCluster, ID: cluster.ClusterName, Detail: "control plane"})
+		}
+		for _, nodegroup := range cluster.Nodegroups {
+			for _, groupID := range nodegroup.SecurityGroupIDs {
+				users[groupID] = append(users[groupID], SGUser{
+					Kind:   SGUserEKSNodegroup,
+					ID:     cluster.ClusterName + "/" + nodegroup.NodegroupName,
+					Detail: fmt.Sprintf("%d nodes desired", nodegroup.DesiredSize),
+				})
+			}
+		}
+		for _, profile := range cluster.FargateProfiles {
+			for _, groupID := range profile.SecurityGroupIDs {
+				users[groupID] = append(users[groupID], SGUser{
+					Kind:   SGUserFargateProfile,
+					ID:     cluster.ClusterName + "/" + profile.FargateProfileName,
+					Detail: profile.Status,
+				})
+			}
+		}
+	}
+
+	for _, sg := range securityGroups {
+		usage := SGUsage{
+			GroupID:   sg.GroupID,
+			GroupName: sg.GroupName,
+			VpcID:     sg.VpcID,
+			Users:     append([]SGUser{}, users[sg.GroupID]...),
+		}
+		usage.Classification = classifyUsage(sg, usage.Users)
+		report.Groups = append(report.Groups, usage)
+
+		if usage.Classification == SGUsageOrphaned {
+			report.Findings = append(report.Findings, SGUsageFinding{
+				GroupID:   sg.GroupID,
+				GroupName: sg.GroupName,
+				VpcID:     sg.VpcID,
+				Severity:  SeverityLow,
+				Reason:    "no network interface, ECS service or EKS cluster uses the group; it can be deleted once rules referencing it are removed",
+			})
+		}
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].VpcID != report.Groups[j].VpcID {
+			return report.Groups[i].VpcID < report.Groups[j].VpcID
+		}
+		return report.Groups[i].GroupID < report.Groups[j].GroupID
+	})
+	sort.Slice(report.Findings, func(i, j int) bool {
+		if report.Findings[i].VpcID != report.Findings[j].VpcID {
+			return report.Findings[i].VpcID < report.Findings[j].VpcID
+		}
+		return report.Findings[i].GroupID < report.Findings[j].GroupID
+	})
+	return report
+}
+
+// classifyUsage returns the usage class of a group from its users
+func classifyUsage(sg vpc.SecurityGroupInfo, users []SGUser) string {
+	for _, user := range users {
+		if user.Kind == SGUserInterface {
+			return SGUsageInUse
+		}
+	}
+	switch {
+	case len(users) > 0:
+		return SGUsageWorkload
+	case sg.GroupName == "default":
+		return SGUsageDefault
+	default:
+		return SGUsageOrphaned
+	}
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/containers"
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeSecurityGroupUsage checks that groups of interfaces are in use, groups of container workloads are
+// used even when scaled to zero, and only groups nothing references are orphaned
+func TestAnalyzeSecurityGroupUsage(t *testing.T) {
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1"},
+		{GroupID: "sg-0tasks", GroupName: "api-tasks", VpcID: "vpc-0a1"},
+		{GroupID: "sg-0idle", GroupName: "batch-tasks", VpcID: "vpc-0a1"},
+		{GroupID: "sg-0deleted", GroupName: "old-service", VpcID: "vpc-0a1"},
+		{GroupID: "sg-0default", GroupName: "default", VpcID: "vpc-0a1"},
+		{GroupID: "sg-0eks", GroupName: "eks-cluster-sg-prod", VpcID: "vpc-0b2"},
+		{GroupID: "sg-0control", GroupName: "prod-control-plane", VpcID: "vpc-0b2"},
+		{GroupID: "sg-0ssh", GroupName: "prod-remote-access", VpcID: "vpc-0b2"},
+	}
+	interfaces := []vpc.InterfaceGroupsInfo{
+		{NetworkInterfaceID: "eni-0web", InterfaceType: "interface", Description: "Primary network interface", GroupIDs: []string{"sg-0web"}},
+		{NetworkInterfaceID: "eni-0task", InterfaceType: "interface", Description: "arn:aws:ecs:eu-west-1:111122223333:attachment/1", GroupIDs: []string{"sg-0tasks", "sg-0web"}},
+	}
+	services := []containers.ECSServiceInfo{
+		{ClusterName: "web", ServiceName: "api", DesiredCount: 2, RunningCount: 1, SecurityGroupIDs: []string{"sg-0tasks"}},
+		{ClusterName: "batch", ServiceName: "worker", SecurityGroupIDs: []string{"sg-0idle"}},
+	}
+	clusters := []containers.EKSClusterInfo{{
+		ClusterName: "prod", SecurityGroupIDs: []string{"sg-0control"}, ClusterSecurityGroupID: "sg-0eks",
+		Nodegroups:      []containers.EKSNodegroupInfo{{NodegroupName: "general", DesiredSize: 3, SecurityGroupIDs: []string{"sg-0eks", "sg-0ssh"}}},
+		FargateProfiles: []containers.EKSFargateProfileInfo{{FargateProfileName: "system", Status: "ACTIVE", SecurityGroupIDs: []string{"sg-0eks"}}},
+	}}
+
+	report := AnalyzeSecurityGroupUsage(groups, interfaces, services, clusters)
+	want := []SGUsage{
+		{GroupID: "sg-0default", GroupName: "default", VpcID: "vpc-0a1", Classification: SGUsageDefault, Users: []SGUser{}},
+		{GroupID: "sg-0deleted", GroupName: "old-service", VpcID: "vpc-0a1", Classification: SGUsageOrphaned, Users: []SGUser{}},
+		{GroupID: "sg-0idle", GroupName: "batch-tasks", VpcID: "vpc-0a1", Classification: SGUsageWorkload,
+			Users: []SGUser{{Kind: SGUserECSService, ID: "batch/worker", Detail: "0 of 0 tasks running"}}},
+		{GroupID: "sg-0tasks", GroupName: "api-tasks", VpcID: "vpc-0a1", Classification: SGUsageInUse, Users: []SGUser{
+			{Kind: SGUserInterface, ID: "eni-0task", Detail: "interface arn:aws:ecs:eu-west-1:111122223333:attachment/1"},
+			{Kind: SGUserECSService, ID: "web/api", Detail: "1 of 2 tasks running"}}},
+		{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", Classification: SGUsageInUse, Users: []SGUser{
+			{Kind: SGUserInterface, ID: "eni-0web", Detail: "interface Primary network interface"},
+			{Kind: SGUserInterface, ID: "eni-0task", Detail: "interface arn:aws:ecs:eu-west-1:111122223333:attachment/1"}}},
+		{GroupID: "sg-0control", GroupName: "prod-control-plane", VpcID: "vpc-0b2", Classification: SGUsageWorkload,
+			Users: []SGUser{{Kind: SGUserEKSCluster, ID: "prod", Detail: "control plane"}}},
+		{GroupID: "sg-0eks", GroupName: "eks-cluster-sg-prod", VpcID: "vpc-0b2", Classification: SGUsageWorkload, Users: []SGUser{
+			{Kind: SGUserEKSCluster, ID: "prod", Detail: "control plane"},
+			{Kind: SGUserEKSNodegroup, ID: "prod/general", Detail: "3 nodes desired"},
+			{Kind: SGUserFargateProfile, ID: "prod/system", Detail: "ACTIVE"}}},
+		{GroupID: "sg-0ssh", GroupName: "prod-remote-access", VpcID: "vpc-0b2", Classification: SGUsageWorkload,
+			Users: []SGUser{{Kind: SGUserEKSNodegroup, ID: "prod/general", Detail: "3 nodes desired"}}},
+	}
+	if !reflect.DeepEqual(report.Groups, want) {
+		t.Errorf("groups = %+v\nwant %+v", report.Groups, want)
+	}
+	wantFindings := []SGUsageFinding{{GroupID: "sg-0deleted", GroupName: "old-service", VpcID: "vpc-0a1", Severity: SeverityLow,
+		Reason: "no network interface, ECS service or EKS cluster uses the group; it can be deleted once rules referencing it are removed"}}
+	if !reflect.DeepEqual(report.Findings, wantFindings) {
+		t.Errorf("findings = %+v\nwant %+v", report.Findings, wantFindings)
+	}
+}
+
+// TestSecurityGroupUsageWithoutContainers checks that without container data a service's group is orphaned
+// once its service is gone, and that a group referenced only by rules of another group stays orphaned
+func TestSecurityGroupUsageWithoutContainers(t *testing.T) {
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0lb", GroupName: "lb", VpcID: "vpc-0a1"},
+		{GroupID: "sg-0app", GroupName: "app", VpcID: "vpc-0a1",
+			Rules: []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 8080, ToPort: 8080, GroupID: "sg-0lb"}}},
+	}
+	tests := []struct {
+		name     string
+		services []containers.ECSServiceInfo
+		want     map[string]string
+	}{
+		{name: "service exists", services: []containers.ECSServiceInfo{{ClusterName: "web", ServiceName: "app", SecurityGroupIDs: []string{"sg-0app"}}},
+			want: map[string]string{"sg-0app": SGUsageWorkload, "sg-0lb": SGUsageOrphaned}},
+		{name: "service deleted", services: []containers.ECSServiceInfo{},
+			want: map[string]string{"sg-0app": SGUsageOrphaned, "sg-0lb": SGUsageOrphaned}},
+		{name: "containers not scanned", want: map[string]string{"sg-0app": SGUsageOrphaned, "sg-0lb": SGUsageOrphaned}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := AnalyzeSecurityGroupUsage(groups, nil, tt.services, nil)
+			got := map[string]string{}
+			for _, usage := range report.Groups {
+				got[usage.GroupID] = usage.Classification
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("classifications = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/vpc"
+)
+
+// StabilityFlapping is the finding class of an attachment that changed state too often within the window
+const StabilityFlapping = "attachment-flapping"
+
+// failedAttachmentStates are the attachment states that mean an attachment failed or was refused
+var failedAttachmentStates = map[string]bool{"failed": true, "failing": true, "rejected": true, "rejecting": true}
+
+// Deductions from the stability score of 100
+const (
+	scoreRecentTransition = 15 // Per state change within the window
+	scoreOldTransition    = 5  // Per state change before the window
+	scoreEverFailed       = 25 // Once for an attachment seen in a failed or rejected state
+)
+
+// AttachmentObservation is what one earlier report recorded about the transit gateway attachments
+type AttachmentObservation struct {
+	ScannedAt   time.Time                          // When the report's scan started
+	Attachments []vpc.TransitGatewayAttachmentInfo // Attachments of the report (nil if the report predates the attachments section)
+}
+
+// StabilityOptions control when an attachment counts as flapping
+type StabilityOptions struct {
+	Window    time.Duration // How far back from the scan state changes count toward flapping
+	Threshold int           // State changes within the window that make an attachment flapping
+}
+
+// DefaultStabilityOptions returns the options used without -flap-window and -flap-threshold
+func DefaultStabilityOptions() StabilityOptions {
+	return StabilityOptions{Window: 30 * 24 * time.Hour, Threshold: 3}
+}
+
+// AttachmentStability describes how steady the state of one attachment has been across the reports
+type AttachmentStability struct {
+	AttachmentID         string   `json:"attachment_id"`           // ID of the attachment; a recreated attachment has a new ID and its own history
+	TransitGatewayID     string   `json:"transit_gateway_id"`      // Transit gateway of the attachment
+	ResourceType         string   `json:"resource_type"`           // vpc, vpn, peering, ...
+	ResourceID           string   `json:"resource_id"`             // Attached VPC, VPN connection, peer transit gateway, ...
+	State                string   `json:"state"`                   // State at the scan
+	StateSince           string   `json:"state_since"`             // Scan time of the first report showing the current state (RFC 3339)
+	StateSinceLowerBound bool     `json:"state_since_lower_bound"` // The oldest report showing the attachment already had the state, so it may be older
+	HoursInState         float64  `json:"hours_in_state"`          // Hours from state_since to the scan
+	Observations         int      `json:"observations"`            // Reports showing the attachment, the scan included
+	Transitions          int      `json:"transitions"`             // State changes between consecutive reports showing the attachment
+	TransitionsInWindow  int      `json:"transitions_in_window"`   // State changes first seen within the window
+	StatesSeen           []string `json:"states_seen"`             // Every state the attachment was seen in, sorted
+	EverFailed           bool     `json:"ever_failed"`             // Seen in the failed, failing, rejected or rejecting state
+	Score                int      `json:"score"`                   // Stability score from 0 (unstable) to 100 (never changed state)
+	Flapping             bool     `json:"flapping"`                // Transitions within the window reached the threshold
+}
+
+// StabilityFinding reports a flapping attachment
+type StabilityFinding struct {
+	Severity         string `json:"severity"`           // medium
+	AttachmentID     string `json:"attachment_id"`      // ID of the attachment
+	TransitGatewayID string `json:"transit_gateway_id"` // Transit gateway of the attachment
+	ResourceType     string `json:"resource_type"`      // vpc, vpn, peering, ...
+	ResourceID       string `json:"resource_id"`        // Attached resource
+	Classification   string `json:"classification"`     // attachment-flapping
+	Reason           string `json:"reason"`             // State changes and the states involved
+}
+
+// StabilityReport contains the stability of every current attachment
+type StabilityReport struct {
+	Reports        int                   `json:"reports"`         // Earlier reports with an attachments section
+	SkippedReports int                   `json:"skipped_reports"` // Earlier reports without one, which say nothing about attachments
+	OldestReport   string                `json:"oldest_report"`   // Scan time of the oldest report used (empty if none)
+	Window         string                `json:"window"`          // Flapping window
+	Threshold      int                   `json:"threshold"`       // Flapping threshold
+	Attachments    []AttachmentStability `json:"attachments"`     // Current attachments, least stable first
+	Findings       []StabilityFinding    `json:"findings"`        // Flapping attachments
+}
+
+// AnalyzeAttachmentStability derives stability indicators of the current attachments from earlier reports
+// Attachments are followed by ID, so an attachment that was deleted and recreated for the same resource
+// starts a new history. Reports scanned before an attachment existed simply do not show it, and reports
+// without an attachments section are skipped, so archives of mixed versions can be used as they are.
+// Only state changes between consecutive reports are visible; a change and its reversal between two
+// scans go unnoticed, so the indicators are lower bounds that sharpen with more frequent scans.
+// history: Earlier reports, in any order
+// current: Attachments of the scan
+// scannedAt: When the scan started; reports scanned at or after it are ignored
+// opts: Flapping window and threshold
+// Returns: Stability of every current attachment and the flapping findings
+func AnalyzeAttachmentStability(history []AttachmentObservation, current []vpc.TransitGatewayAttachmentInfo, scannedAt time.Time, opts StabilityOptions) *StabilityReport {
+	report := &StabilityReport{
+		Window:      windowString(opts.Window),
+		Threshold:   opts.Threshold,
+		Attachments: []AttachmentStability{},
+		Findings:    []StabilityFinding{},
+	}
+
+	var timeline []AttachmentObservation
+	for _, observation := range history {
+		switch {
+		case !observation.ScannedAt.Before(scannedAt):
+			continue
+		case observation.Attachments == nil:
+			report.SkippedReports++
+			continue
+		}
+		timeline = append(timeline, observation)
+	}
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].ScannedAt.Before(timeline[j].ScannedAt) })
+	report.Reports = len(timeline)
+	if len(timeline) > 0 {
+		report.OldestReport = timeline[0].ScannedAt.UTC().Format(time.RFC3339)
+	}
+	timeline = append(timeline, AttachmentObservation{ScannedAt: scannedAt, Attachments: current})
+
+	// State of every attachment in every report that shows it, oldest first
+	type sighting struct {
+		at    time.Time
+		state string
+	}
+	sightings := make(map[string][]sighting)
+	for _, observation := range timeline {
+		for _, attachment := range observation.Attachments {
+			sightings[attachment.AttachmentID] = append(sightings[attachment.AttachmentID], sighting{at: observation.ScannedAt, state: attachment.State})
+		}
+	}
+
+	windowStart := scannedAt.Add(-opts.Window)
+	for _, attachment := range current {
+		seen := sightings[attachment.AttachmentID]
+		stability := AttachmentStability{
+			AttachmentID:     attachment.AttachmentID,
+			TransitGatewayID: attachment.TransitGatewayID,
+			ResourceType:     attachment.ResourceType,
+			ResourceID:       attachment.ResourceID,
+			State:            attachment.State,
+			Observations:     len(seen),
+		}
+
+		states := make(map[string]bool)
+		runStart := 0
+		for i, s := range seen {
+			states[s.state] = true
+			if failedAttachmentStates[s.state] {
+				stability.EverFailed = true
+			}
+			if i > 0 && s.state != seen[i-1].state {
+				stability.Transitions++
+				if !s.at.Before(windowStart) {
+					stability.TransitionsInWindow++
+				}
+				runStart = i
+			}
+		}
+		for state := range states {
+			stability.StatesSeen = append(stability.StatesSeen, state)
+		}
+		sort.Strings(stability.StatesSeen)
+
+		since := seen[runStart].at
+		stability.StateSince = since.UTC().Format(time.RFC3339)
+		stability.StateSinceLowerBound = runStart == 0
+		stability.HoursInState = scannedAt.Sub(since).Hours()
+		stability.Flapping = opts.Threshold > 0 && stability.TransitionsInWindow >= opts.Threshold
+
+		score := 100 - scoreRecentTransition*stability.TransitionsInWindow - scoreOldTransition*(stability.Transitions-stability.TransitionsInWindow)
+		if stability.EverFailed {
+			score -= scoreEverFailed
+		}
+		stability.Score = max(score, 0)
+
+		if stability.Flapping {
+			report.Findings = append(report.Findings, StabilityFinding{
+				Severity:         SeverityMedium,
+				AttachmentID:     attachment.AttachmentID,
+				TransitGatewayID: attachment.TransitGatewayID,
+				ResourceType:     attachment.ResourceType,
+				ResourceID:       attachment.ResourceID,
+				Classification:   StabilityFlapping,
+				Reason: fmt.Sprintf("%s attachment to %s changed state %d times within %s (threshold %d), through %s; now %s",
+					attachment.ResourceType, attachment.ResourceID, stability.TransitionsInWindow, report.Window, opts.Threshold,
+					strings.Join(stability.StatesSeen, ", "), vpc.OrUnknown(attachment.State)),
+			})
+		}
+		report.Attachments = append(report.Attachments, stability)
+	}
+
+	sort.SliceStable(report.Attachments, func(i, j int) bool {
+		a, b := report.Attachments[i], report.Attachments[j]
+		if a.Score != b.Score {
+			return a.Score < b.Score
+		}
+		return a.AttachmentID < b.AttachmentID
+	})
+	sort.SliceStable(report.Findings, func(i, j int) bool { return report.Findings[i].AttachmentID < report.Findings[j].AttachmentID })
+	return report
+}
+
+// windowString writes a window in whole days when it is one, as -flap-window takes it
+func windowString(window time.Duration) string {
+	const day = 24 * time.Hour
+	if window > 0 && window%day == 0 {
+		return fmt.Sprintf("%dd", window/day)
+	}
+	return window.String()
+}
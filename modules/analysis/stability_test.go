@@ -0,0 +1,191 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/vpc"
+)
+
+// stabilityScan is when the scan of the stability tests started
+var stabilityScan = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+// daysBefore returns the time the given number of days before the scan
+func daysBefore(days int) time.Time {
+	return stabilityScan.AddDate(0, 0, -days)
+}
+
+// sinceDays formats the time the given number of days before the scan as AttachmentStability.StateSince
+func sinceDays(days int) string {
+	return daysBefore(days).Format(time.RFC3339)
+}
+
+// stabilityAttachment returns an attachment of vpc-0a1 to tgw-0a1 in the given state
+func stabilityAttachment(id, state string) vpc.TransitGatewayAttachmentInfo {
+	return vpc.TransitGatewayAttachmentInfo{AttachmentID: id, TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0a1", State: state}
+}
+
+// sighting is the state of an attachment in an earlier report; an empty state is a report without the attachment
+type sighting struct {
+	days  int    // Days before the scan
+	state string // State of the attachment in the report
+}
+
+// observations returns the earlier reports of one attachment; reports without it list no attachments
+func observations(id string, sightings []sighting) []AttachmentObservation {
+	var history []AttachmentObservation
+	for _, s := range sightings {
+		observation := AttachmentObservation{ScannedAt: daysBefore(s.days), Attachments: []vpc.TransitGatewayAttachmentInfo{}}
+		if s.state != "" {
+			observation.Attachments = append(observation.Attachments, stabilityAttachment(id, s.state))
+		}
+		history = append(history, observation)
+	}
+	return history
+}
+
+// TestAttachmentStability follows one attachment through earlier reports: steady, new, created after the oldest
+// reports, flapping, failed, changed only before the window and seen in reports given out of order
+func TestAttachmentStability(t *testing.T) {
+	tests := []struct {
+		name      string
+		sightings []sighting
+		state     string // State at the scan
+		want      AttachmentStability
+	}{
+		{name: "steady since the oldest report", sightings: []sighting{{60, "available"}, {30, "available"}, {10, "available"}}, state: "available",
+			want: AttachmentStability{State: "available", StateSince: sinceDays(60), StateSinceLowerBound: true, HoursInState: 1440, Observations: 4,
+				StatesSeen: []string{"available"}, Score: 100}},
+		{name: "no earlier report", state: "available",
+			want: AttachmentStability{State: "available", StateSince: sinceDays(0), StateSinceLowerBound: true, Observations: 1,
+				StatesSeen: []string{"available"}, Score: 100}},
+		{name: "reports before the attachment existed", sightings: []sighting{{60, ""}, {40, ""}, {20, "pending"}, {10, "available"}}, state: "available",
+			want: AttachmentStability{State: "available", StateSince: sinceDays(10), HoursInState: 240, Observations: 3,
+				Transitions: 1, TransitionsInWindow: 1, StatesSeen: []string{"available", "pending"}, Score: 85}},
+		{name: "flapping", sightings: []sighting{{40, "available"}, {35, "modifying"}, {28, "available"}, {14, "modifying"}, {7, "available"}}, state: "available",
+			want: AttachmentStability{State: "available", StateSince: sinceDays(7), HoursInState: 168, Observations: 6,
+				Transitions: 4, TransitionsInWindow: 3, StatesSeen: []string{"available", "modifying"}, Score: 50, Flapping: true}},
+		{name: "changes at the window start count", sightings: []sighting{{31, "available"}, {30, "modifying"}, {20, "available"}}, state: "modifying",
+			want: AttachmentStability{State: "modifying", StateSince: sinceDays(0), Observations: 4,
+				Transitions: 3, TransitionsInWindow: 3, StatesSeen: []string{"available", "modifying"}, Score: 55, Flapping: true}},
+		{name: "failed", sightings: []sighting{{20, "pending"}, {10, "failed"}}, state: "failed",
+			want: AttachmentStability{State: "failed", StateSince: sinceDays(10), HoursInState: 240, Observations: 3,
+				Transitions: 1, TransitionsInWindow: 1, StatesSeen: []string{"failed", "pending"}, EverFailed: true, Score: 60}},
+		{name: "rejected once, accepted later", sightings: []sighting{{50, "pendingAcceptance"}, {45, "rejected"}, {40, "pendingAcceptance"}, {35, "available"}}, state: "available",
+			want: AttachmentStability{State: "available", StateSince: sinceDays(35), HoursInState: 840, Observations: 5,
+				Transitions: 3, StatesSeen: []string{"available", "pendingAcceptance", "rejected"}, EverFailed: true, Score: 60}},
+		{name: "changes only before the window", sightings: []sighting{{90, "pending"}, {60, "available"}}, state: "available",
+			want: AttachmentStability{State: "available", StateSince: sinceDays(60), HoursInState: 1440, Observations: 3,
+				Transitions: 1, StatesSeen: []string{"available", "pending"}, Score: 95}},
+		{name: "reports out of order", sightings: []sighting{{7, "available"}, {28, "available"}, {40, "available"}, {14, "modifying"}, {35, "modifying"}}, state: "available",
+			want: AttachmentStability{State: "available", StateSince: sinceDays(7), HoursInState: 168, Observations: 6,
+				Transitions: 4, TransitionsInWindow: 3, StatesSeen: []string{"available", "modifying"}, Score: 50, Flapping: true}},
+		{name: "score floor", sightings: []sighting{{9, "available"}, {8, "failed"}, {7, "available"}, {6, "failed"}, {5, "available"}, {4, "failed"}, {3, "available"}},
+			state: "failed",
+			want: AttachmentStability{State: "failed", StateSince: sinceDays(0), Observations: 8,
+				Transitions: 7, TransitionsInWindow: 7, StatesSeen: []string{"available", "failed"}, EverFailed: true, Score: 0, Flapping: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := []vpc.TransitGatewayAttachmentInfo{stabilityAttachment("tgw-attach-0a1", tt.state)}
+			report := AnalyzeAttachmentStability(observations("tgw-attach-0a1", tt.sightings), current, stabilityScan, DefaultStabilityOptions())
+
+			tt.want.AttachmentID, tt.want.TransitGatewayID, tt.want.ResourceType, tt.want.ResourceID = "tgw-attach-0a1", "tgw-0a1", "vpc", "vpc-0a1"
+			if len(report.Attachments) != 1 || !reflect.DeepEqual(report.Attachments[0], tt.want) {
+				t.Fatalf("Attachments = %+v\nwant [%+v]", report.Attachments, tt.want)
+			}
+			wantFindings := 0
+			if tt.want.Flapping {
+				wantFindings = 1
+			}
+			if len(report.Findings) != wantFindings {
+				t.Errorf("got %d findings, want %d", len(report.Findings), wantFindings)
+			}
+			if report.Reports != len(tt.sightings) || report.SkippedReports != 0 {
+				t.Errorf("reports = %d used, %d skipped; want %d used", report.Reports, report.SkippedReports, len(tt.sightings))
+			}
+		})
+	}
+}
+
+// TestAttachmentRecreated checks that an attachment deleted and recreated for the same VPC is a new attachment
+// with its own history, so the failures of the old one do not count against it
+func TestAttachmentRecreated(t *testing.T) {
+	history := observations("tgw-attach-0old", []sighting{{30, "available"}, {20, "failed"}, {10, "deleted"}})
+	history[2].Attachments = append(history[2].Attachments, stabilityAttachment("tgw-attach-0new", "pending"))
+	history = append(history, AttachmentObservation{ScannedAt: daysBefore(5), Attachments: []vpc.TransitGatewayAttachmentInfo{stabilityAttachment("tgw-attach-0new", "available")}})
+
+	report := AnalyzeAttachmentStability(history, []vpc.TransitGatewayAttachmentInfo{stabilityAttachment("tgw-attach-0new", "available")}, stabilityScan, DefaultStabilityOptions())
+	want := []AttachmentStability{{AttachmentID: "tgw-attach-0new", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0a1",
+		State: "available", StateSince: sinceDays(5), HoursInState: 120, Observations: 3, Transitions: 1, TransitionsInWindow: 1,
+		StatesSeen: []string{"available", "pending"}, Score: 85}}
+	if !reflect.DeepEqual(report.Attachments, want) {
+		t.Errorf("Attachments = %+v\nwant %+v", report.Attachments, want)
+	}
+}
+
+// TestStabilityReport checks the report of several attachments: reports without attachments skipped, reports
+// from the scan time on ignored, attachments least stable first and the findings of the flapping ones
+func TestStabilityReport(t *testing.T) {
+	vpn := func(state string) vpc.TransitGatewayAttachmentInfo {
+		return vpc.TransitGatewayAttachmentInfo{AttachmentID: "tgw-attach-0vpn", TransitGatewayID: "tgw-0a1", ResourceType: "vpn", ResourceID: "vpn-0office", State: state}
+	}
+	steady := stabilityAttachment("tgw-attach-0a1", "available")
+	history := []AttachmentObservation{
+		{ScannedAt: daysBefore(0), Attachments: []vpc.TransitGatewayAttachmentInfo{steady, vpn("deleted")}},
+		{ScannedAt: stabilityScan.Add(time.Hour), Attachments: []vpc.TransitGatewayAttachmentInfo{steady, vpn("deleted")}},
+		{ScannedAt: daysBefore(3), Attachments: []vpc.TransitGatewayAttachmentInfo{steady, vpn("available")}},
+		{ScannedAt: daysBefore(100)},
+		{ScannedAt: daysBefore(6), Attachments: []vpc.TransitGatewayAttachmentInfo{steady, vpn("modifying")}},
+		{ScannedAt: daysBefore(9), Attachments: []vpc.TransitGatewayAttachmentInfo{steady, vpn("available")}},
+		{ScannedAt: daysBefore(12), Attachments: []vpc.TransitGatewayAttachmentInfo{steady, vpn("modifying")}},
+		{ScannedAt: daysBefore(40)},
+	}
+	current := []vpc.TransitGatewayAttachmentInfo{steady, vpn("available")}
+
+	report := AnalyzeAttachmentStability(history, current, stabilityScan, DefaultStabilityOptions())
+	if report.Reports != 4 || report.SkippedReports != 2 || report.OldestReport != sinceDays(12) || report.Window != "30d" || report.Threshold != 3 {
+		t.Errorf("report = %d reports, %d skipped, oldest %s, window %s, threshold %d; want 4, 2, %s, 30d, 3",
+			report.Reports, report.SkippedReports, report.OldestReport, report.Window, report.Threshold, sinceDays(12))
+	}
+	if len(report.Attachments) != 2 || report.Attachments[0].AttachmentID != "tgw-attach-0vpn" || report.Attachments[1].AttachmentID != "tgw-attach-0a1" {
+		t.Fatalf("Attachments = %+v, want the VPN attachment first", report.Attachments)
+	}
+	if vpnStability := report.Attachments[0]; vpnStability.Transitions != 3 || vpnStability.Score != 55 || !vpnStability.Flapping {
+		t.Errorf("VPN attachment = %+v, want 3 transitions, score 55, flapping", vpnStability)
+	}
+	want := []StabilityFinding{{Severity: SeverityMedium, AttachmentID: "tgw-attach-0vpn", TransitGatewayID: "tgw-0a1", ResourceType: "vpn",
+		ResourceID: "vpn-0office", Classification: StabilityFlapping,
+		Reason: "vpn attachment to vpn-0office changed state 3 times within 30d (threshold 3), through available, modifying; now available"}}
+	if !reflect.DeepEqual(report.Findings, want) {
+		t.Errorf("Findings = %+v\nwant %+v", report.Findings, want)
+	}
+
+	report = AnalyzeAttachmentStability(history, current, stabilityScan, StabilityOptions{Window: 5 * 24 * time.Hour, Threshold: 2})
+	if len(report.Findings) != 0 || report.Attachments[0].TransitionsInWindow != 1 || report.Window != "5d" {
+		t.Errorf("5 day window: findings %+v, %d transitions in %s; want none, 1 in 5d", report.Findings, report.Attachments[0].TransitionsInWindow, report.Window)
+	}
+	if report := AnalyzeAttachmentStability(nil, nil, stabilityScan, DefaultStabilityOptions()); report.Attachments == nil || report.Findings == nil || report.OldestReport != "" {
+		t.Errorf("report without attachments = %+v, want empty lists", report)
+	}
+}
+
+// TestWindowString covers windows of whole days and other durations
+func TestWindowString(t *testing.T) {
+	tests := []struct {
+		window time.Duration
+		want   string
+	}{
+		{window: 30 * 24 * time.Hour, want: "30d"},
+		{window: 36 * time.Hour, want: "36h0m0s"},
+		{window: 0, want: "0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := windowString(tt.window); got != tt.want {
+				t.Errorf("windowString(%v) = %q, want %q", tt.window, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package analysis
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Connectivity classes of VPC endpoints
+const (
+	ConnectivityAWSService        = "aws-service"         // An AWS service (com.amazonaws.<region>.<service>)
+	ConnectivityInternal          = "internal"            // An endpoint service run by this account or an internal account
+	ConnectivityKnownSaaS         = "known-saas"          // An endpoint service of a known SaaS provider
+	ConnectivityUnknownThirdParty = "unknown-third-party" // An endpoint service of another account that matches no known provider
+)
+
+// SaaSProvider describes how to recognize the endpoint services of a SaaS vendor
+type SaaSProvider struct {
+	Vendor       string   `json:"vendor"`        // Vendor name reported for matching endpoints
+	ServiceNames []string `json:"service_names"` // Service names or IDs; "*" and "?" match any run of characters or one character
+	DNSDomains   []string `json:"dns_domains"`   // Domains of the private DNS names the vendor verified for its services
+}
+
+// SaaSCatalog lists the known SaaS providers and the accounts whose endpoint services count as internal
+type SaaSCatalog struct {
+	Providers        []SaaSProvider `json:"providers"`         // Known providers, checked in order
+	InternalAccounts []string       `json:"internal_accounts"` // Accounts besides the endpoint owner whose services are internal
+}
+
+// DefaultSaaSCatalog returns the built-in providers, recognized by the private DNS domains of their services
+// Vendors publish per-region service names, so those are left to user catalogs.
+func DefaultSaaSCatalog() *SaaSCatalog {
+	return &SaaSCatalog{
+		Providers: []SaaSProvider{
+			{Vendor: "Datadog", DNSDomains: []string{"datadoghq.com", "datadoghq.eu", "ddog-gov.com"}},
+			{Vendor: "Snowflake", DNSDomains: []string{"snowflakecomputing.com"}},
+			{Vendor: "MongoDB Atlas", DNSDomains: []string{"mongodb.net"}},
+			{Vendor: "Confluent", DNSDomains: []string{"confluent.cloud"}},
+			{Vendor: "Databricks", DNSDomains: []string{"cloud.databricks.com"}},
+			{Vendor: "Elastic", DNSDomains: []string{"elastic-cloud.com", "found.io"}},
+			{Vendor: "Splunk", DNSDomains: []string{"splunkcloud.com"}},
+			{Vendor: "New Relic", DNSDomains: []string{"newrelic.com"}},
+		},
+		InternalAccounts: []string{},
+	}
+}
+
+// Merge returns a catalog with the providers of other checked before the built-in ones
+// A vendor defined in both keeps the patterns of both.
+func (c *SaaSCatalog) Merge(other *SaaSCatalog) *SaaSCatalog {
+	merged := &SaaSCatalog{
+		Providers:        append(append([]SaaSProvider{}, other.Providers...), c.Providers...),
+		InternalAccounts: append(append([]string{}, c.InternalAccounts...), other.InternalAccounts...),
+	}
+	return merged
+}
+
+// EndpointConnectivity is the classification of one endpoint
+type EndpointConnectivity struct {
+	VpcEndpointID    string   `json:"vpc_endpoint_id"`    // ID of the endpoint
+	VpcID            string   `json:"vpc_id"`             // VPC of the endpoint
+	ServiceName      string   `json:"service_name"`       // Full service name
+	ServiceOwner     string   `json:"service_owner"`      // Account ID of the service provider (empty if the service was not found)
+	Classification   string   `json:"classification"`     // aws-service, internal, known-saas or unknown-third-party
+	Vendor           string   `json:"vendor"`             // Vendor of a known-saas service
+	SecurityGroupIDs []string `json:"security_group_ids"` // Security groups of the endpoint network interfaces
+}
+
+// VendorConnectivity groups the endpoints connecting to one third party
+type VendorConnectivity struct {
+	Vendor           string   `json:"vendor"`             // Vendor name, or the provider account for unknown third parties
+	Classification   string   `json:"classification"`     // known-saas or unknown-third-party
+	EndpointIDs      []string `json:"endpoint_ids"`       // Endpoints connecting to the vendor
+	ServiceNames     []string `json:"service_names"`      // Service names of the vendor in use
+	VpcIDs           []string `json:"vpc_ids"`            // VPCs with an endpoint to the vendor
+	SecurityGroupIDs []string `json:"security_group_ids"` // Security groups controlling access to the endpoints
+}
+
+// ThirdPartyFinding describes an endpoint to a service of an unrecognized account
+type ThirdPartyFinding struct {
+	VpcEndpointID  string `json:"vpc_endpoint_id"` // ID of the endpoint
+	VpcID          string `json:"vpc_id"`          // VPC of the endpoint
+	ServiceName    string `json:"service_name"`    // Full service name
+	Classification string `json:"classification"`  // Always unknown-third-party
+	Severity       string `json:"severity"`        // Always medium
+	Reason         string `json:"reason"`          // Human-readable explanation
+}
+
+// ThirdPartyReport contains the results of the third-party connectivity analysis
+type ThirdPartyReport struct {
+	Endpoints []EndpointConnectivity `json:"endpoints"` // Every interface and Gateway Load Balancer endpoint, classified
+	Vendors   []VendorConnectivity   `json:"vendors"`   // Known and unknown third parties, sorted by vendor
+	Findings  []ThirdPartyFinding    `json:"findings"`  // Endpoints to unknown third parties
+}
+
+// AnalyzeThirdPartyConnectivity classifies endpoints by who provides the service they connect to
+// AWS services are recognized by name. Any other service is internal when its provider account owns
+// the endpoint or is listed as internal, a known SaaS service when its name or verified private DNS
+// domain matches a catalog provider, and an unknown third party otherwise. Gateway endpoints only
+// reach AWS services and are skipped.
+// endpoints: VPC endpoints from the scan
+// services: Provider details of the endpoint services (services missing here count as unknown)
+// catalog: Known providers and internal accounts
+// Returns: Report with every endpoint classified and the third parties grouped by vendor
+func AnalyzeThirdPartyConnectivity(endpoints []vpc.VpcEndpointInfo, services []vpc.EndpointServiceInfo, catalog *SaaSCatalog) *ThirdPartyReport {
+	report := &ThirdPartyReport{
+		Endpoints: []EndpointConnectivity{},
+		Vendors:   []VendorConnectivity{},
+		Findings:  []ThirdPartyFinding{},
+	}
+
+	byName := make(map[string]vpc.EndpointServiceInfo, len(services))
+	for _, service := range services {
+		byName[service.ServiceName] = service
+	}
+	internal := make(map[string]bool, len(catalog.InternalAccounts))
+	for _, account := range catalog.InternalAccounts {
+		internal[account] = true
+	}
+
+	vendors := make(map[string]*VendorConnectivity)
+	for _, endpoint := range endpoints {
+		if endpoint.EndpointType == vpc.EndpointTypeGateway {
+			continue
+		}
+		service, found := byName[endpoint.ServiceName]
+		entry := EndpointConnectivity{
+			VpcEndpointID:    endpoint.VpcEndpointID,
+			VpcID:            endpoint.VpcID,
+			ServiceName:      endpoint.ServiceName,
+			ServiceOwner:     service.Owner,
+			SecurityGroupIDs: endpoint.SecurityGroupIDs,
+		}
+
+		switch {
+		case isAWSServiceName(endpoint.ServiceName) || service.Owner == "amazon":
+			entry.Classification = ConnectivityAWSService
+		case found && (service.Owner == endpoint.OwnerID || internal[service.Owner]):
+			entry.Classification = ConnectivityInternal
+		default:
+			if vendor := catalog.match(endpoint.ServiceName, service); vendor != "" {
+				entry.Classification = ConnectivityKnownSaaS
+				entry.Vendor = vendor
+			} else {
+				entry.Classification = ConnectivityUnknownThirdParty
+			}
+		}
+		report.Endpoints = append(report.Endpoints, entry)
+
+		if entry.Classification != ConnectivityKnownSaaS && entry.Classification != ConnectivityUnknownThirdParty {
+			continue
+		}
+
+		// Unknown third parties are grouped by provider account, as that is all that identifies them
+		vendor := entry.Vendor
+		if vendor == "" {
+			vendor = "account " + valueOr(service.Owner, "unknown")
+			report.Findings = append(report.Findings, ThirdPartyFinding{
+				VpcEndpointID:  endpoint.VpcEndpointID,
+				VpcID:          endpoint.VpcID,
+				ServiceName:    endpoint.ServiceName,
+				Classification: ConnectivityUnknownThirdParty,
+				Severity:       SeverityMedium,
+				Reason:         fmt.Sprintf("%s connects %s to %s, provided by %s, which matches no known SaaS provider or internal account", endpoint.VpcEndpointID, endpoint.VpcID, endpoint.ServiceName, vendor),
+			})
+		}
+		group, ok := vendors[vendor]
+		if !ok {
+			group = &VendorConnectivity{Vendor: vendor, Classification: entry.Classification, SecurityGroupIDs: []string{}}
+			vendors[vendor] = group
+		}
+		group.EndpointIDs = appendUnique(group.EndpointIDs, endpoint.VpcEndpointID)
+		group.ServiceNames = appendUnique(group.ServiceNames, endpoint.ServiceName)
+		group.VpcIDs = appendUnique(group.VpcIDs, endpoint.VpcID)
+		for _, groupID := range endpoint.SecurityGroupIDs {
+			group.SecurityGroupIDs = appendUnique(group.SecurityGroupIDs, groupID)
+		}
+	}
+
+	for _, group := range vendors {
+		report.Vendors = append(report.Vendors, *group)
+	}
+	sort.Slice(report.Vendors, func(i, j int) bool { return report.Vendors[i].Vendor < report.Vendors[j].Vendor })
+	return report
+}
+
+// match returns the vendor of the first provider whose service names or DNS domains match, or an empty string
+func (c *SaaSCatalog) match(serviceName string, service vpc.EndpointServiceInfo) string {
+	dnsName := strings.TrimSuffix(strings.TrimPrefix(service.PrivateDnsName, "*."), ".")
+	for _, provider := range c.Providers {
+		for _, pattern := range provider.ServiceNames {
+			if ok, _ := path.Match(pattern, serviceName); ok {
+				return provider.Vendor
+			}
+			if ok, _ := path.Match(pattern, service.ServiceID); ok && service.ServiceID != "" {
+				return provider.Vendor
+			}
+		}
+		for _, domain := range provider.DNSDomains {
+			if dnsName != "" && (dnsName == domain || strings.HasSuffix(dnsName, "."+domain)) {
+				return provider.Vendor
+			}
+		}
+	}
+	return ""
+}
+
+// isAWSServiceName reports whether a service name belongs to an AWS service rather than an endpoint service
+// Endpoint services of all providers are named com.amazonaws.vpce.<region>.vpce-svc-*
+func isAWSServiceName(serviceName string) bool {
+	if strings.HasPrefix(serviceName, "aws.") {
+		return true
+	}
+	return strings.HasPrefix(serviceName, "com.amazonaws.") && !strings.HasPrefix(serviceName, "com.amazonaws.vpce.")
+}
+
+// appendUnique appends s to values unless it is already present
+func appendUnique(values []string, s string) []string {
+	for _, value := range values {
+		if value == s {
+			return values
+		}
+	}
+	return append(values, s)
+}
+
+// valueOr returns value, or fallback when value is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
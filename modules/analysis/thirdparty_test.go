@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// TestAnalyzeThirdPartyConnectivity checks every classification, including a vendor known only from a user
+// catalog, and the grouping of known and unknown third parties
+func TestAnalyzeThirdPartyConnectivity(t *testing.T) {
+	const owner = "111122223333"
+	endpoint := func(id, vpcID, serviceName string, groups ...string) vpc.VpcEndpointInfo {
+		return vpc.VpcEndpointInfo{VpcEndpointID: id, VpcID: vpcID, ServiceName: serviceName, EndpointType: vpc.EndpointTypeInterface,
+			OwnerID: owner, SecurityGroupIDs: groups}
+	}
+	endpoints := []vpc.VpcEndpointInfo{
+		endpoint("vpce-0ssm", "vpc-0a1", "com.amazonaws.eu-west-1.ssm", "sg-0ssm"),
+		endpoint("vpce-0sagemaker", "vpc-0a1", "aws.sagemaker.eu-west-1.notebook"),
+		endpoint("vpce-0awsvpce", "vpc-0a1", "com.amazonaws.vpce.eu-west-1.vpce-svc-0aws"),
+		endpoint("vpce-0own", "vpc-0a1", "com.amazonaws.vpce.eu-west-1.vpce-svc-0own"),
+		endpoint("vpce-0shared", "vpc-0b2", "com.amazonaws.vpce.eu-west-1.vpce-svc-0shared"),
+		endpoint("vpce-0dd1", "vpc-0a1", "com.amazonaws.vpce.eu-west-1.vpce-svc-0datadog", "sg-0dd"),
+		endpoint("vpce-0dd2", "vpc-0b2", "com.amazonaws.vpce.eu-west-1.vpce-svc-0datadog", "sg-0dd", "sg-0b2"),
+		endpoint("vpce-0acme", "vpc-0b2", "com.amazonaws.vpce.eu-west-1.vpce-svc-0acme1234", "sg-0acme"),
+		endpoint("vpce-0mystery", "vpc-0a1", "com.amazonaws.vpce.eu-west-1.vpce-svc-0mystery", "sg-0mystery"),
+		endpoint("vpce-0gone", "vpc-0a1", "com.amazonaws.vpce.eu-west-1.vpce-svc-0gone"),
+		{VpcEndpointID: "vpce-0s3", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.eu-west-1.s3", EndpointType: vpc.EndpointTypeGateway},
+	}
+	services := []vpc.EndpointServiceInfo{
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0aws", ServiceID: "vpce-svc-0aws", Owner: "amazon"},
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0own", ServiceID: "vpce-svc-0own", Owner: owner},
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0shared", ServiceID: "vpce-svc-0shared", Owner: "222233334444"},
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0datadog", ServiceID: "vpce-svc-0datadog", Owner: "464622532012",
+			PrivateDnsName: "*.agent.datadoghq.eu."},
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0acme1234", ServiceID: "vpce-svc-0acme1234", Owner: "555566667777"},
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0mystery", ServiceID: "vpce-svc-0mystery", Owner: "999988887777",
+			PrivateDnsName: "api.mystery.example"},
+	}
+	user := &SaaSCatalog{
+		Providers:        []SaaSProvider{{Vendor: "Acme Metrics", ServiceNames: []string{"vpce-svc-0acme*"}}},
+		InternalAccounts: []string{"222233334444"},
+	}
+	report := AnalyzeThirdPartyConnectivity(endpoints, services, DefaultSaaSCatalog().Merge(user))
+
+	wantClasses := map[string][2]string{ // Classification and vendor by endpoint
+		"vpce-0ssm":       {ConnectivityAWSService, ""},
+		"vpce-0sagemaker": {ConnectivityAWSService, ""},
+		"vpce-0awsvpce":   {ConnectivityAWSService, ""},
+		"vpce-0own":       {ConnectivityInternal, ""},
+		"vpce-0shared":    {ConnectivityInternal, ""},
+		"vpce-0dd1":       {ConnectivityKnownSaaS, "Datadog"},
+		"vpce-0dd2":       {ConnectivityKnownSaaS, "Datadog"},
+		"vpce-0acme":      {ConnectivityKnownSaaS, "Acme Metrics"},
+		"vpce-0mystery":   {ConnectivityUnknownThirdParty, ""},
+		"vpce-0gone":      {ConnectivityUnknownThirdParty, ""},
+	}
+	if len(report.Endpoints) != len(wantClasses) {
+		t.Errorf("got %d classified endpoints, want %d (gateway endpoints are skipped)", len(report.Endpoints), len(wantClasses))
+	}
+	for _, entry := range report.Endpoints {
+		if got := [2]string{entry.Classification, entry.Vendor}; got != wantClasses[entry.VpcEndpointID] {
+			t.Errorf("%s = %v, want %v", entry.VpcEndpointID, got, wantClasses[entry.VpcEndpointID])
+		}
+	}
+
+	wantVendors := []VendorConnectivity{
+		{Vendor: "Acme Metrics", Classification: ConnectivityKnownSaaS, EndpointIDs: []string{"vpce-0acme"},
+			ServiceNames: []string{"com.amazonaws.vpce.eu-west-1.vpce-svc-0acme1234"}, VpcIDs: []string{"vpc-0b2"}, SecurityGroupIDs: []string{"sg-0acme"}},
+		{Vendor: "Datadog", Classification: ConnectivityKnownSaaS, EndpointIDs: []string{"vpce-0dd1", "vpce-0dd2"},
+			ServiceNames: []string{"com.amazonaws.vpce.eu-west-1.vpce-svc-0datadog"}, VpcIDs: []string{"vpc-0a1", "vpc-0b2"}, SecurityGroupIDs: []string{"sg-0dd", "sg-0b2"}},
+		{Vendor: "account 999988887777", Classification: ConnectivityUnknownThirdParty, EndpointIDs: []string{"vpce-0mystery"},
+			ServiceNames: []string{"com.amazonaws.vpce.eu-west-1.vpce-svc-0mystery"}, VpcIDs: []string{"vpc-0a1"}, SecurityGroupIDs: []string{"sg-0mystery"}},
+		{Vendor: "account unknown", Classification: ConnectivityUnknownThirdParty, EndpointIDs: []string{"vpce-0gone"},
+			ServiceNames: []string{"com.amazonaws.vpce.eu-west-1.vpce-svc-0gone"}, VpcIDs: []string{"vpc-0a1"}, SecurityGroupIDs: []string{}},
+	}
+	if !reflect.DeepEqual(report.Vendors, wantVendors) {
+		t.Errorf("vendors = %+v\nwant %+v", report.Vendors, wantVendors)
+	}
+
+	wantFindings := []ThirdPartyFinding{
+		{VpcEndpointID: "vpce-0mystery", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0mystery",
+			Classification: ConnectivityUnknownThirdParty, Severity: SeverityMedium,
+			Reason: "vpce-0mystery connects vpc-0a1 to com.amazonaws.vpce.eu-west-1.vpce-svc-0mystery, provided by account 999988887777, which matches no known SaaS provider or internal account"},
+		{VpcEndpointID: "vpce-0gone", VpcID: "vpc-0a1", ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0gone",
+			Classification: ConnectivityUnknownThirdParty, Severity: SeverityMedium,
+			Reason: "vpce-0gone connects vpc-0a1 to com.amazonaws.vpce.eu-west-1.vpce-svc-0gone, provided by account unknown, which matches no known SaaS provider or internal account"},
+	}
+	if !reflect.DeepEqual(report.Findings, wantFindings) {
+		t.Errorf("findings = %+v\nwant %+v", report.Findings, wantFindings)
+	}
+
+	// Without the user catalog the vendor and the internal account are unknown
+	builtIn := AnalyzeThirdPartyConnectivity(endpoints, services, DefaultSaaSCatalog())
+	for _, entry := range builtIn.Endpoints {
+		if (entry.VpcEndpointID == "vpce-0acme" || entry.VpcEndpointID == "vpce-0shared") && entry.Classification != ConnectivityUnknownThirdParty {
+			t.Errorf("%s = %s with the built-in catalog, want %s", entry.VpcEndpointID, entry.Classification, ConnectivityUnknownThirdParty)
+		}
+	}
+}
+
+// TestSaaSCatalogMatch covers service name and service ID patterns and DNS domains and their subdomains
+func TestSaaSCatalogMatch(t *testing.T) {
+	catalog := &SaaSCatalog{Providers: []SaaSProvider{
+		{Vendor: "ByName", ServiceNames: []string{"com.amazonaws.vpce.*.vpce-svc-0byname?"}},
+		{Vendor: "ByID", ServiceNames: []string{"vpce-svc-0byid*"}},
+		{Vendor: "ByDomain", DNSDomains: []string{"vendor.example"}},
+	}}
+	tests := []struct {
+		name    string
+		service vpc.EndpointServiceInfo
+		want    string
+	}{
+		{name: "service name pattern", service: vpc.EndpointServiceInfo{ServiceName: "com.amazonaws.vpce.us-east-1.vpce-svc-0byname1"}, want: "ByName"},
+		{name: "service ID pattern", service: vpc.EndpointServiceInfo{ServiceName: "com.amazonaws.vpce.us-east-1.vpce-svc-0byid42", ServiceID: "vpce-svc-0byid42"}, want: "ByID"},
+		{name: "domain", service: vpc.EndpointServiceInfo{ServiceName: "x", PrivateDnsName: "vendor.example"}, want: "ByDomain"},
+		{name: "wildcard subdomain", service: vpc.EndpointServiceInfo{ServiceName: "x", PrivateDnsName: "*.eu.vendor.example."}, want: "ByDomain"},
+		{name: "lookalike domain", service: vpc.EndpointServiceInfo{ServiceName: "x", PrivateDnsName: "notvendor.example"}},
+		{name: "no match", service: vpc.EndpointServiceInfo{ServiceName: "com.amazonaws.vpce.us-east-1.vpce-svc-0other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := catalog.match(tt.service.ServiceName, tt.service); got != tt.want {
+				t.Errorf("match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
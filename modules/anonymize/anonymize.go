@@ -0,0 +1,285 @@
+// Package anonymize replaces the confidential details of a scan report with structure-preserving fakes
+// The result is meant for bug reports: resource IDs, accounts, addresses, names and times are replaced,
+// while every relationship the diagram depends on (which subnet is in which VPC, which route points at
+// which gateway, which blocks overlap) stays the same.
+package anonymize
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+// Report is the part of a scan report that the overview diagram is generated from
+// The field names match the report.json of the Lambda function, so its reports load directly.
+type Report struct {
+	ScannedAt        string                             `json:"scanned_at"`        // When the report's scan started
+	VPCs             []vpc.VPCInfo                      `json:"vpcs"`              // VPCs of the report
+	Subnets          []vpc.SubnetInfo                   `json:"subnets"`           // Subnets of the report
+	RouteTables      []vpc.RouteTableInfo               `json:"route_tables"`      // Route tables of the report
+	SecurityGroups   []vpc.SecurityGroupInfo            `json:"security_groups"`   // Security groups of the report
+	InternetGateways []vpc.InternetGatewayInfo          `json:"internet_gateways"` // Internet gateways of the report
+	NatGateways      []vpc.NatGatewayInfo               `json:"nat_gateways"`      // NAT gateways of the report
+	TransitGateways  []vpc.TransitGatewayInfo           `json:"transit_gateways"`  // Transit gateways of the report
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`   // Transit gateway attachments of the report (nil in reports without them)
+}
+
+// ShiftedLatest is the time the latest timestamp of an anonymized report is moved to
+// All timestamps move by the same amount, so their order and distances are kept.
+var ShiftedLatest = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// freeTextFields are string fields that hold names or descriptions chosen by the account owner
+var freeTextFields = map[string]bool{
+	"name":              true, // Name tags, visited under this name by walk
+	"description":       true,
+	"group_name":        true,
+	"domain_name":       true,
+	"source_label":      true, // Named ranges, which name offices and partners
+	"destination_label": true,
+}
+
+// idPattern matches AWS resource IDs: a lowercase type prefix and 8 or 17 hexadecimal digits
+var idPattern = regexp.MustCompile(`^([a-z]+(?:-[a-z]+)*)-([0-9a-f]{17}|[0-9a-f]{8})$`)
+
+// accountPattern matches AWS account IDs
+var accountPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// LoadReport reads a JSON report written in any field style
+// Returns: Report, or error if the file cannot be read or is not a report
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var report Report
+	if err := output.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	if report.VPCs == nil {
+		return nil, fmt.Errorf("%s is not a scan report: it has no vpcs section", path)
+	}
+	return &report, nil
+}
+
+// anonymizer holds the replacements handed out so far, so that every occurrence of a value gets the same fake
+type anonymizer struct {
+	ids      map[string]string // Resource ID -> fake ID
+	counters map[string]int    // Number of fake IDs handed out per ID prefix
+	accounts map[string]string // Account ID -> fake account ID
+	names    map[string]string // Name tag or free text -> fake name
+	cidrs    *cidrMapper       // Address block remapping
+	latest   time.Time         // Latest timestamp of the report
+	shift    time.Duration     // Offset added to every timestamp
+}
+
+// Anonymize returns a copy of the report with every confidential value replaced
+// Resource IDs become sequential fake IDs with the same type prefix, account IDs fake accounts, and
+// ARNs are rebuilt from both. CIDR blocks and addresses are remapped into the documentation and
+// private ranges with containment and overlap preserved. Tags are dropped except for a fake Name, group
+// names, descriptions and domain names are replaced, and timestamps are shifted so the latest is
+// ShiftedLatest. Other values (states, protocols, ports, availability zones) are kept.
+// report: Report to anonymize; it is not modified
+// Returns: Anonymized copy, or error if the address blocks cannot be remapped
+func Anonymize(report *Report) (*Report, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var anonymized Report
+	if err := json.Unmarshal(data, &anonymized); err != nil {
+		return nil, err
+	}
+
+	a := &anonymizer{
+		ids:      make(map[string]string),
+		counters: make(map[string]int),
+		accounts: make(map[string]string),
+		names:    make(map[string]string),
+		cidrs:    newCIDRMapper(),
+	}
+
+	// The address forest and the time range must be known before anything is replaced
+	walk(reflect.ValueOf(&anonymized), "", a.collect)
+	if err := a.cidrs.build(); err != nil {
+		return nil, err
+	}
+	if !a.latest.IsZero() {
+		a.shift = ShiftedLatest.Sub(a.latest)
+	}
+
+	walk(reflect.ValueOf(&anonymized), "", a.replace)
+	return &anonymized, nil
+}
+
+// collect records the addresses and timestamps of a string value
+func (a *anonymizer) collect(field, s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		if t.After(a.latest) {
+			a.latest = t
+		}
+		return s
+	}
+	if strings.Contains(s, "/") || net.ParseIP(s) != nil {
+		a.cidrs.add(s)
+	}
+	return s
+}
+
+// replace returns the fake of a string value
+func (a *anonymizer) replace(field, s string) string {
+	switch {
+	case s == "":
+		return s
+	case freeTextFields[field]:
+		if field == "group_name" && s == "default" {
+			return s // The default group is recognized by its name
+		}
+		return a.name(field, s)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Add(a.shift).UTC().Format(time.RFC3339)
+	}
+	if strings.HasPrefix(s, "arn:") {
+		return a.arn(s)
+	}
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return a.cidrs.mapCIDR(s)
+	}
+	if net.ParseIP(s) != nil {
+		return a.cidrs.mapIP(s)
+	}
+	if accountPattern.MatchString(s) {
+		return a.account(s)
+	}
+	if idPattern.MatchString(s) {
+		return a.id(s)
+	}
+	return s
+}
+
+// id returns the fake of a resource ID, keeping its type prefix
+func (a *anonymizer) id(s string) string {
+	if fake, ok := a.ids[s]; ok {
+		return fake
+	}
+	prefix := idPattern.FindStringSubmatch(s)[1]
+	a.counters[prefix]++
+	fake := fmt.Sprintf("%s-%017x", prefix, a.counters[prefix])
+	a.ids[s] = fake
+	return fake
+}
+
+// account returns the fake of an account ID
+func (a *anonymizer) account(s string) string {
+	if fake, ok := a.accounts[s]; ok {
+		return fake
+	}
+	fake := fmt.Sprintf("1111%08d", len(a.accounts)+1)
+	a.accounts[s] = fake
+	return fake
+}
+
+// name returns the fake of a name or free text; equal originals get equal fakes
+func (a *anonymizer) name(kind, s string) string {
+	key := kind + "\x00" + s
+	if fake, ok := a.names[key]; ok {
+		return fake
+	}
+	fake := fmt.Sprintf("%s-%d", strings.ReplaceAll(kind, "_", "-"), len(a.names)+1)
+	a.names[key] = fake
+	return fake
+}
+
+// arn rebuilds an ARN with the fake account and resource IDs
+func (a *anonymizer) arn(s string) string {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) < 6 {
+		return s
+	}
+	if accountPattern.MatchString(parts[4]) {
+		parts[4] = a.account(parts[4])
+	}
+	segments := strings.Split(parts[5], "/")
+	for i, segment := range segments {
+		if idPattern.MatchString(segment) {
+			segments[i] = a.id(segment)
+		}
+	}
+	parts[5] = strings.Join(segments, "/")
+	return strings.Join(parts, ":")
+}
+
+// tagType is the type of TagList fields
+var tagType = reflect.TypeOf([]vpc.Tag{})
+
+// walk visits every string reachable from v and stores what visit returns
+// Maps are visited in key order and struct fields carry their JSON name, so the fakes are handed out
+// in the same order on every run. Tags are replaced as a whole: only the Name tag is kept, with a fake value.
+// field: JSON name of the field v was reached through
+func walk(v reflect.Value, field string, visit func(field, s string) string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem(), field, visit)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			walk(v.Field(i), name, visit)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type() == tagType && field == "tag_list" {
+			if v.IsNil() {
+				return
+			}
+			tags := []vpc.Tag{}
+			for _, tag := range v.Interface().([]vpc.Tag) {
+				if tag.Key == "Name" {
+					tags = append(tags, vpc.Tag{Key: "Name", Value: visit("name", tag.Value)})
+				}
+			}
+			v.Set(reflect.ValueOf(tags))
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), field, visit)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		if field == "tags" && v.Type() == reflect.TypeOf(map[string]string{}) {
+			tags := make(map[string]string)
+			if name, ok := v.Interface().(map[string]string)["Name"]; ok {
+				tags["Name"] = visit("name", name)
+			}
+			v.Set(reflect.ValueOf(tags))
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			walk(value, field, visit)
+			v.SetMapIndex(key, value)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(visit(field, v.String()))
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package anonymize
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/testgen"
+	"aws-documentor/modules/vpc"
+)
+
+// generatedReport returns the small synthetic environment as a report
+func generatedReport() *Report {
+	env := testgen.Generate(testgen.Small)
+	return &Report{
+		ScannedAt:        "2026-03-01T12:00:00Z",
+		VPCs:             env.VPCs,
+		Subnets:          env.Subnets,
+		RouteTables:      env.RouteTables,
+		SecurityGroups:   env.SecurityGroups,
+		InternetGateways: env.InternetGateways,
+		NatGateways:      env.NatGateways,
+		TransitGateways:  env.TransitGateways,
+		TGWAttachments:   env.TGWAttachments,
+	}
+}
+
+// generateDiagram returns the overview diagram of a report
+func generateDiagram(t *testing.T, report *Report) string {
+	t.Helper()
+	doc, err := diagram.NewDiagramGenerator().GenerateVPCDiagram(report.VPCs, report.Subnets, report.RouteTables,
+		report.SecurityGroups, report.InternetGateways, report.NatGateways, report.TransitGateways, report.TGWAttachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// paymentsReport returns a report of one VPC with IDs, names, addresses and times as a scan returns them
+func paymentsReport() *Report {
+	return &Report{
+		ScannedAt: "2026-03-01T12:00:00Z",
+		VPCs: []vpc.VPCInfo{{
+			VpcID: "vpc-0123456789abcdef0", Arn: "arn:aws:ec2:eu-west-1:444455556666:vpc/vpc-0123456789abcdef0", Region: "eu-west-1",
+			CidrBlock: "10.20.0.0/16", State: "available", Tags: map[string]string{"Name": "payments", "cost-center": "4711"},
+			TagList: []vpc.Tag{{Key: "cost-center", Value: "4711"}, {Key: "Name", Value: "payments"}},
+		}},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0aaaaaaaaaaaaaaa1", VpcID: "vpc-0123456789abcdef0", CidrBlock: "10.20.1.0/24", AvailabilityZone: "eu-west-1a",
+				Tags: map[string]string{"Name": "payments"}, TagList: []vpc.Tag{{Key: "team", Value: "core"}}},
+			{SubnetID: "subnet-0aaaaaaaaaaaaaaa2", VpcID: "vpc-0123456789abcdef0", CidrBlock: "10.20.2.0/24"},
+		},
+		SecurityGroups: []vpc.SecurityGroupInfo{
+			{GroupID: "sg-0aaaaaaaaaaaaaaa1", GroupName: "default", Description: "default VPC security group", OwnerID: "444455556666"},
+			{GroupID: "sg-0aaaaaaaaaaaaaaa2", GroupName: "payments-api", Description: "Payments API", OwnerID: "444455556666",
+				Rules: []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0", GroupID: "sg-0aaaaaaaaaaaaaaa1"}}},
+		},
+		NatGateways: []vpc.NatGatewayInfo{{NatGatewayID: "nat-0aaaaaaaaaaaaaaa1", SubnetID: "subnet-0aaaaaaaaaaaaaaa1", PrivateIp: "10.20.1.17",
+			PublicIp: "52.16.3.4", CreatedTime: "2026-02-28T12:00:00Z"}},
+	}
+}
+
+// TestAnonymizedDiagramStructure checks that the diagram of an anonymized report has the cell count and the
+// parent/child relations of the original's, and that the comparison notices a changed topology
+func TestAnonymizedDiagramStructure(t *testing.T) {
+	report := generatedReport()
+	// Two VPCs with the same block, which must stay overlapping
+	report.VPCs = append(report.VPCs, vpc.VPCInfo{VpcID: "vpc-0aaaaaaaaaaaaaaaa", CidrBlock: report.VPCs[0].CidrBlock, Tags: map[string]string{"Name": "copy"}})
+
+	anonymized, err := Anonymize(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied := anonymized.VPCs[len(anonymized.VPCs)-1].CidrBlock; copied != anonymized.VPCs[0].CidrBlock {
+		t.Errorf("VPCs with the same block got %s and %s", anonymized.VPCs[0].CidrBlock, copied)
+	}
+	original, originalCells, err := diagram.Structure(generateDiagram(t, report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	structure, cells, err := diagram.Structure(generateDiagram(t, anonymized))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cells != originalCells || structure != original {
+		t.Errorf("anonymized diagram has %d cells, want the %d of the original with the same nesting", cells, originalCells)
+	}
+
+	anonymized.Subnets = anonymized.Subnets[1:]
+	if changed, _, _ := diagram.Structure(generateDiagram(t, anonymized)); changed == original {
+		t.Error("structure does not change when a subnet is removed")
+	}
+}
+
+// TestAnonymizeLeavesNoOriginals checks that no ID, account, block or name of the report survives, and that
+// the input is not modified
+func TestAnonymizeLeavesNoOriginals(t *testing.T) {
+	report := paymentsReport()
+	before, _ := json.Marshal(report)
+	anonymized, err := Anonymize(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := json.Marshal(report); string(after) != string(before) {
+		t.Error("Anonymize modified its input")
+	}
+
+	data, _ := json.Marshal(anonymized)
+	for _, leak := range []string{"444455556666", "0123456789abcdef0", "0aaaaaaaaaaaaaaa", "10.20.", "52.16.3.4", "payments", "Payments", "cost-center", "4711", "team", "2026-"} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("anonymized report contains %q", leak)
+		}
+	}
+
+	again, err := Anonymize(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, anonymized) {
+		t.Error("anonymizing the same report twice gives different fakes")
+	}
+}
+
+// TestAnonymizeValues checks the replacement of every kind of value
+func TestAnonymizeValues(t *testing.T) {
+	anonymized, err := Anonymize(paymentsReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := anonymized.VPCs[0]
+	tests := []struct {
+		name, got, want string
+	}{
+		{"scan time", anonymized.ScannedAt, "2020-01-01T00:00:00Z"},
+		{"creation time", anonymized.NatGateways[0].CreatedTime, "2019-12-31T00:00:00Z"},
+		{"VPC ID", v.VpcID, "vpc-00000000000000001"},
+		{"ARN", v.Arn, "arn:aws:ec2:eu-west-1:111100000001:vpc/vpc-00000000000000001"},
+		{"owner", anonymized.SecurityGroups[1].OwnerID, "111100000001"},
+		{"subnet's VPC", anonymized.Subnets[1].VpcID, "vpc-00000000000000001"},
+		{"rule's group", anonymized.SecurityGroups[1].Rules[0].GroupID, anonymized.SecurityGroups[0].GroupID},
+		{"default group", anonymized.SecurityGroups[0].GroupName, "default"},
+		{"group name", anonymized.SecurityGroups[1].GroupName, "group-name-3"},
+		{"description", anonymized.SecurityGroups[1].Description, "description-4"},
+		{"equal names", anonymized.Subnets[0].Tags["Name"], v.Tags["Name"]},
+		{"region", v.Region, "eu-west-1"},
+		{"availability zone", anonymized.Subnets[0].AvailabilityZone, "eu-west-1a"},
+		{"state", v.State, "available"},
+		{"anywhere", anonymized.SecurityGroups[1].Rules[0].CidrBlock, "0.0.0.0/0"},
+		{"VPC block", v.CidrBlock, "10.0.0.0/16"},
+		{"subnet block", anonymized.Subnets[1].CidrBlock, "10.0.2.0/24"},
+		{"address in a subnet", anonymized.NatGateways[0].PrivateIp, "10.0.1.17"},
+		{"public address", anonymized.NatGateways[0].PublicIp, "192.0.2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+
+	if want := map[string]string{"Name": v.Tags["Name"]}; !reflect.DeepEqual(v.Tags, want) {
+		t.Errorf("VPC tags = %v, want only the fake Name %v", v.Tags, want)
+	}
+	if want := []vpc.Tag{{Key: "Name", Value: v.Tags["Name"]}}; !reflect.DeepEqual(v.TagList, want) {
+		t.Errorf("VPC tag list = %v, want only the fake Name %v", v.TagList, want)
+	}
+	if tags := anonymized.Subnets[0].TagList; tags == nil || len(tags) != 0 {
+		t.Errorf("tag list without a Name = %#v, want an empty list", tags)
+	}
+	if tags := anonymized.Subnets[1].TagList; tags != nil {
+		t.Errorf("missing tag list = %#v, want nil", tags)
+	}
+}
@@ -0,0 +1,217 @@
+package anonymize
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+
+	"aws-documentor/modules/netcalc"
+)
+
+// Replacement ranges, tried in order
+// Blocks of /24 and smaller go to the documentation ranges first; larger blocks only fit the private
+// and shared ranges. IPv6 blocks go to the documentation prefix.
+var (
+	smallIPv4Pools = []string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"}
+	largeIPv4Pools = []string{"10.0.0.0/8", "172.16.0.0/12", "100.64.0.0/10", "198.18.0.0/15"}
+	ipv6Pools      = []string{"2001:db8::/32"}
+)
+
+// block is an address block of the report
+type block struct {
+	base   *big.Int // Network address
+	prefix int      // Prefix length
+	bits   int      // 32 for IPv4, 128 for IPv6
+	mapped *big.Int // Network address of the replacement (roots only)
+}
+
+// contains reports whether b contains every address of other
+func (b *block) contains(other *block) bool {
+	contains, err := netcalc.CIDRContains(b.String(), other.String())
+	return err == nil && contains
+}
+
+// String formats the block in CIDR notation
+func (b *block) String() string {
+	return intToIP(b.base, b.bits).String() + "/" + strconv.Itoa(b.prefix)
+}
+
+// pool hands out aligned blocks from a replacement range
+type pool struct {
+	next   *big.Int // First address not handed out yet
+	end    *big.Int // First address after the range
+	prefix int      // Prefix length of the range
+	bits   int      // 32 for IPv4, 128 for IPv6
+}
+
+// cidrMapper remaps address blocks so that containment and overlap are preserved
+// Two CIDR blocks either nest or are disjoint, so the blocks of a report form a forest. Every root
+// (a block no other block contains) gets a fresh block of the same size from the replacement
+// ranges; blocks and addresses inside a root keep their offset from the root's network address.
+// The default routes 0.0.0.0/0 and ::/0 contain everything and are kept as they are.
+type cidrMapper struct {
+	blocks map[string]*block // Collected blocks by canonical CIDR
+	roots  []*block          // Blocks no other block contains, with their replacement
+}
+
+// newCIDRMapper creates an empty mapper
+func newCIDRMapper() *cidrMapper {
+	return &cidrMapper{blocks: make(map[string]*block)}
+}
+
+// add collects a CIDR block or address (as a host block)
+func (m *cidrMapper) add(s string) {
+	b, _, ok := parseBlock(s)
+	if !ok || b.prefix == 0 {
+		return
+	}
+	m.blocks[b.String()] = b
+}
+
+// build finds the roots and allocates their replacements
+// Returns: Error if a root is larger than every replacement range or the ranges are exhausted
+func (m *cidrMapper) build() error {
+	for _, b := range m.blocks {
+		root := true
+		for _, other := range m.blocks {
+			if other != b && other.prefix < b.prefix && other.contains(b) {
+				root = false
+				break
+			}
+		}
+		if root {
+			m.roots = append(m.roots, b)
+		}
+	}
+
+	// Largest blocks first, so the aligned allocations of the shared pools never overlap
+	sort.Slice(m.roots, func(i, j int) bool {
+		a, b := m.roots[i], m.roots[j]
+		if a.bits != b.bits {
+			return a.bits < b.bits
+		}
+		if a.prefix != b.prefix {
+			return a.prefix < b.prefix
+		}
+		return a.base.Cmp(b.base) < 0
+	})
+
+	small, large, ipv6 := newPools(smallIPv4Pools), newPools(largeIPv4Pools), newPools(ipv6Pools)
+	for _, root := range m.roots {
+		var candidates []*pool
+		switch {
+		case root.bits == 128:
+			candidates = ipv6
+		case root.prefix >= 24:
+			candidates = append(append(candidates, small...), large...)
+		default:
+			candidates = large
+		}
+		for _, p := range candidates {
+			if root.mapped = p.allocate(root.prefix); root.mapped != nil {
+				break
+			}
+		}
+		if root.mapped == nil {
+			return fmt.Errorf("cannot remap %s: no replacement range has room for a /%d", root, root.prefix)
+		}
+	}
+	return nil
+}
+
+// mapCIDR returns the replacement of a CIDR block, keeping host bits and prefix length
+func (m *cidrMapper) mapCIDR(s string) string {
+	b, ip, ok := parseBlock(s)
+	if !ok || b.prefix == 0 {
+		return s
+	}
+	return m.mapAddress(b, ip) + "/" + strconv.Itoa(b.prefix)
+}
+
+// mapIP returns the replacement of an address
+func (m *cidrMapper) mapIP(s string) string {
+	b, ip, ok := parseBlock(s)
+	if !ok {
+		return s
+	}
+	return m.mapAddress(b, ip)
+}
+
+// mapAddress moves an address of a block by the offset of the block's root
+func (m *cidrMapper) mapAddress(b *block, ip *big.Int) string {
+	for _, root := range m.roots {
+		if root.bits == b.bits && root.prefix <= b.prefix && root.contains(b) {
+			offset := new(big.Int).Sub(ip, root.base)
+			return intToIP(new(big.Int).Add(root.mapped, offset), b.bits).String()
+		}
+	}
+	// Not collected: leave no trace of the original address
+	if b.bits == 32 {
+		return "192.0.2.0"
+	}
+	return "2001:db8::"
+}
+
+// parseBlock parses a CIDR block, or an address as a host block
+// Returns: The block (network address and prefix), the original address, and whether s is either
+func parseBlock(s string) (*block, *big.Int, bool) {
+	ip, network, err := net.ParseCIDR(s)
+	if err != nil {
+		if ip = net.ParseIP(s); ip == nil {
+			return nil, nil, false
+		}
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	prefix, bits := network.Mask.Size()
+	return &block{base: ipToInt(network.IP, bits), prefix: prefix, bits: bits}, ipToInt(ip, bits), true
+}
+
+// newPools creates the pools of the given replacement ranges
+func newPools(cidrs []string) []*pool {
+	pools := make([]*pool, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		b, _, _ := parseBlock(cidr)
+		size := new(big.Int).Lsh(big.NewInt(1), uint(b.bits-b.prefix))
+		pools = append(pools, &pool{next: b.base, end: new(big.Int).Add(b.base, size), prefix: b.prefix, bits: b.bits})
+	}
+	return pools
+}
+
+// allocate hands out the next aligned block of the given prefix length
+// Returns: Network address of the block, or nil if the pool has no room
+func (p *pool) allocate(prefix int) *big.Int {
+	if prefix < p.prefix {
+		return nil
+	}
+	size := new(big.Int).Lsh(big.NewInt(1), uint(p.bits-prefix))
+	start := new(big.Int).Add(p.next, new(big.Int).Sub(size, big.NewInt(1)))
+	start.Div(start, size).Mul(start, size)
+	end := new(big.Int).Add(start, size)
+	if end.Cmp(p.end) > 0 {
+		return nil
+	}
+	p.next = end
+	return start
+}
+
+// ipToInt converts an address to an integer
+func ipToInt(ip net.IP, bits int) *big.Int {
+	if bits == 32 {
+		return new(big.Int).SetBytes(ip.To4())
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+// intToIP converts an integer back to an address of the given family
+func intToIP(n *big.Int, bits int) net.IP {
+	raw := n.Bytes()
+	ip := make(net.IP, bits/8)
+	copy(ip[len(ip)-len(raw):], raw)
+	return ip
+}
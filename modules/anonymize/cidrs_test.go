@@ -0,0 +1,115 @@
+package anonymize
+
+import (
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/netcalc"
+)
+
+// remap collects blocks and addresses and returns the replacement of each
+func remap(t *testing.T, values []string) map[string]string {
+	t.Helper()
+	m := newCIDRMapper()
+	for _, s := range values {
+		m.add(s)
+	}
+	if err := m.build(); err != nil {
+		t.Fatal(err)
+	}
+	mapped := make(map[string]string, len(values))
+	for _, s := range values {
+		if strings.Contains(s, "/") {
+			mapped[s] = m.mapCIDR(s)
+		} else {
+			mapped[s] = m.mapIP(s)
+		}
+	}
+	return mapped
+}
+
+// TestCIDRMapperPreservesRelations checks that every pair of blocks keeps its containment after remapping,
+// and that disjoint blocks stay disjoint
+func TestCIDRMapperPreservesRelations(t *testing.T) {
+	blocks := []string{
+		"10.20.0.0/16", "10.20.1.0/24", "10.20.2.0/24", "10.20.2.128/25", // A VPC with nested subnets
+		"10.20.0.0/16",                 // A second VPC with the same block
+		"10.30.0.0/16", "10.30.1.0/24", // A disjoint VPC
+		"172.31.0.0/16", "172.31.0.0/20", // A default VPC
+		"10.0.0.0/8",                       // A route covering all of 10/8
+		"192.168.5.0/24", "100.100.0.0/28", // Small roots, which go to the documentation ranges
+		"2600:1f18:aaaa:bb00::/56", "2600:1f18:aaaa:bb01::/64",
+	}
+	mapped := remap(t, blocks)
+
+	for _, a := range blocks {
+		for _, b := range blocks {
+			want, err := netcalc.CIDRContains(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := netcalc.CIDRContains(mapped[a], mapped[b])
+			if err != nil {
+				t.Fatalf("%s -> %s or %s -> %s is not a block: %v", a, mapped[a], b, mapped[b], err)
+			}
+			if got != want {
+				t.Errorf("%s contains %s = %v, but %s contains %s = %v", a, b, want, mapped[a], mapped[b], got)
+			}
+		}
+	}
+
+	pools := append(append(append([]string{}, smallIPv4Pools...), largeIPv4Pools...), ipv6Pools...)
+	for _, original := range blocks {
+		inPool := false
+		for _, p := range pools {
+			if contains, _ := netcalc.CIDRContains(p, mapped[original]); contains {
+				inPool = true
+			}
+		}
+		if !inPool {
+			t.Errorf("%s -> %s is outside the replacement ranges", original, mapped[original])
+		}
+	}
+	for _, small := range []string{"192.168.5.0/24", "100.100.0.0/28"} {
+		if contains, _ := netcalc.CIDRContains("192.0.2.0/24", mapped[small]); !contains {
+			if contains, _ := netcalc.CIDRContains("198.51.100.0/24", mapped[small]); !contains {
+				t.Errorf("%s -> %s, want a documentation range", small, mapped[small])
+			}
+		}
+	}
+}
+
+// TestCIDRMapperValues checks the replacement of single values
+func TestCIDRMapperValues(t *testing.T) {
+	mapped := remap(t, []string{"10.20.0.0/16", "10.20.1.17", "10.20.1.0/24", "2600:1f18::/56", "2600:1f18::10"})
+	m := newCIDRMapper()
+	tests := []struct {
+		name, got, want string
+	}{
+		{"block", mapped["10.20.0.0/16"], "10.0.0.0/16"},
+		{"nested block", mapped["10.20.1.0/24"], "10.0.1.0/24"},
+		{"address keeps its offset", mapped["10.20.1.17"], "10.0.1.17"},
+		{"IPv6 block", mapped["2600:1f18::/56"], "2001:db8::/56"},
+		{"IPv6 address", mapped["2600:1f18::10"], "2001:db8::10"},
+		{"default route", m.mapCIDR("0.0.0.0/0"), "0.0.0.0/0"},
+		{"uncollected address", m.mapIP("52.16.3.4"), "192.0.2.0"},
+		{"uncollected IPv6 address", m.mapIP("2600:1f18::1"), "2001:db8::"},
+		{"not an address", m.mapCIDR("pl-0aaaaaaaaaaaaaaa1"), "pl-0aaaaaaaaaaaaaaa1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCIDRMapperExhausted checks that a root larger than every replacement range is an error
+func TestCIDRMapperExhausted(t *testing.T) {
+	m := newCIDRMapper()
+	m.add("0.0.0.0/1")
+	if err := m.build(); err == nil {
+		t.Errorf("build() with a /1 block = nil, want an error")
+	}
+}
@@ -0,0 +1,114 @@
+// Package arnbuild constructs the ARNs of the resources this tool scans
+// Most describe APIs return only IDs, so the ARNs are put together from the partition of the region,
+// the owning account and the service and resource-type segment of each type.
+package arnbuild
+
+import (
+	"strings"
+)
+
+// Resource types with an ARN format
+const (
+	TypeVPC                      = "vpc"
+	TypeSubnet                   = "subnet"
+	TypeRouteTable               = "route-table"
+	TypeSecurityGroup            = "security-group"
+	TypeNetworkACL               = "network-acl"
+	TypeInternetGateway          = "internet-gateway"
+	TypeCarrierGateway           = "carrier-gateway"
+	TypeNatGateway               = "natgateway"
+	TypeTransitGateway           = "transit-gateway"
+	TypeTransitGatewayAttachment = "transit-gateway-attachment"
+	TypeTransitGatewayRouteTable = "transit-gateway-route-table"
+	TypeVpnGateway               = "vpn-gateway"
+	TypeCustomerGateway          = "customer-gateway"
+	TypeVpnConnection            = "vpn-connection"
+	TypeVpcPeeringConnection     = "vpc-peering-connection"
+	TypeVpcEndpoint              = "vpc-endpoint"
+	TypeVpcEndpointService       = "vpc-endpoint-service"
+	TypeDhcpOptions              = "dhcp-options"
+	TypeNetworkInterface         = "network-interface"
+	TypeElasticIP                = "elastic-ip"
+	TypeInstance                 = "instance"
+	TypeFlowLog                  = "vpc-flow-log"
+	TypeDirectory                = "directory"
+	TypeHostedZone               = "hostedzone"
+	TypeResolverEndpoint         = "resolver-endpoint"
+)
+
+// format is the ARN layout of one resource type
+type format struct {
+	service  string // Service segment of the ARN
+	resource string // Resource-type segment before the ID (the part before the slash)
+	global   bool   // Whether the ARN has no region and account (Route 53 hosted zones)
+}
+
+// formats maps every resource type to its ARN layout
+// The resource-type segment does not always match the type name of the API: NAT gateways are
+// "natgateway" and hosted zones "hostedzone" in the global route53 namespace. Types whose APIs return
+// an ARN (Auto Scaling groups, EKS, ECS, Global Accelerator, Cloud WAN) are not listed.
+var formats = map[string]format{
+	TypeVPC:                      {service: "ec2", resource: "vpc"},
+	TypeSubnet:                   {service: "ec2", resource: "subnet"},
+	TypeRouteTable:               {service: "ec2", resource: "route-table"},
+	TypeSecurityGroup:            {service: "ec2", resource: "security-group"},
+	TypeNetworkACL:               {service: "ec2", resource: "network-acl"},
+	TypeInternetGateway:          {service: "ec2", resource: "internet-gateway"},
+	TypeCarrierGateway:           {service: "ec2", resource: "carrier-gateway"},
+	TypeNatGateway:               {service: "ec2", resource: "natgateway"},
+	TypeTransitGateway:           {service: "ec2", resource: "transit-gateway"},
+	TypeTransitGatewayAttachment: {service: "ec2", resource: "transit-gateway-attachment"},
+	TypeTransitGatewayRouteTable: {service: "ec2", resource: "transit-gateway-route-table"},
+	TypeVpnGateway:               {service: "ec2", resource: "vpn-gateway"},
+	TypeCustomerGateway:          {service: "ec2", resource: "customer-gateway"},
+	TypeVpnConnection:            {service: "ec2", resource: "vpn-connection"},
+	TypeVpcPeeringConnection:     {service: "ec2", resource: "vpc-peering-connection"},
+	TypeVpcEndpoint:              {service: "ec2", resource: "vpc-endpoint"},
+	TypeVpcEndpointService:       {service: "ec2", resource: "vpc-endpoint-service"},
+	TypeDhcpOptions:              {service: "ec2", resource: "dhcp-options"},
+	TypeNetworkInterface:         {service: "ec2", resource: "network-interface"},
+	TypeElasticIP:                {service: "ec2", resource: "elastic-ip"},
+	TypeInstance:                 {service: "ec2", resource: "instance"},
+	TypeFlowLog:                  {service: "ec2", resource: "vpc-flow-log"},
+	TypeDirectory:                {service: "ds", resource: "directory"},
+	TypeHostedZone:               {service: "route53", resource: "hostedzone", global: true},
+	TypeResolverEndpoint:         {service: "route53resolver", resource: "resolver-endpoint"},
+}
+
+// Partition returns the partition a region belongs to
+// region: Region name such as us-east-1, cn-north-1 or us-gov-west-1
+// Returns: aws, aws-cn, aws-us-gov, aws-iso or aws-iso-b
+func Partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "aws-iso-b"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "aws-iso"
+	default:
+		return "aws"
+	}
+}
+
+// Build returns the ARN of a resource
+// resourceType: One of the Type* constants with an ARN format
+// region: Region of the resource; it also selects the partition
+// accountID: Account that owns the resource (ignored for global types)
+// id: Resource ID as returned by the describe API
+// Returns: The ARN, or an empty string if the type has no format or the ID, region or account is missing
+func Build(resourceType, region, accountID, id string) string {
+	f, ok := formats[resourceType]
+	if !ok || id == "" || region == "" {
+		return ""
+	}
+	if f.global {
+		return "arn:" + Partition(region) + ":" + f.service + ":::" + f.resource + "/" + id
+	}
+	if accountID == "" {
+		return ""
+	}
+	return "arn:" + Partition(region) + ":" + f.service + ":" + region + ":" + accountID + ":" + f.resource + "/" + id
+}
@@ -0,0 +1,110 @@
+package arnbuild
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBuild checks the ARN of every type in the commercial, GovCloud and China partitions
+func TestBuild(t *testing.T) {
+	const account = "123456789012"
+	// want is the ARN in us-east-1; the partition and region are replaced for the other partitions
+	tests := []struct {
+		resourceType string
+		id           string
+		want         string
+	}{
+		{TypeVPC, "vpc-0a1", "arn:aws:ec2:us-east-1:123456789012:vpc/vpc-0a1"},
+		{TypeSubnet, "subnet-0a1", "arn:aws:ec2:us-east-1:123456789012:subnet/subnet-0a1"},
+		{TypeRouteTable, "rtb-0a1", "arn:aws:ec2:us-east-1:123456789012:route-table/rtb-0a1"},
+		{TypeSecurityGroup, "sg-0a1", "arn:aws:ec2:us-east-1:123456789012:security-group/sg-0a1"},
+		{TypeNetworkACL, "acl-0a1", "arn:aws:ec2:us-east-1:123456789012:network-acl/acl-0a1"},
+		{TypeInternetGateway, "igw-0a1", "arn:aws:ec2:us-east-1:123456789012:internet-gateway/igw-0a1"},
+		{TypeCarrierGateway, "cagw-0a1", "arn:aws:ec2:us-east-1:123456789012:carrier-gateway/cagw-0a1"},
+		{TypeNatGateway, "nat-0a1", "arn:aws:ec2:us-east-1:123456789012:natgateway/nat-0a1"},
+		{TypeTransitGateway, "tgw-0a1", "arn:aws:ec2:us-east-1:123456789012:transit-gateway/tgw-0a1"},
+		{TypeTransitGatewayAttachment, "tgw-attach-0a1", "arn:aws:ec2:us-east-1:123456789012:transit-gateway-attachment/tgw-attach-0a1"},
+		{TypeTransitGatewayRouteTable, "tgw-rtb-0a1", "arn:aws:ec2:us-east-1:123456789012:transit-gateway-route-table/tgw-rtb-0a1"},
+		{TypeVpnGateway, "vgw-0a1", "arn:aws:ec2:us-east-1:123456789012:vpn-gateway/vgw-0a1"},
+		{TypeCustomerGateway, "cgw-0a1", "arn:aws:ec2:us-east-1:123456789012:customer-gateway/cgw-0a1"},
+		{TypeVpnConnection, "vpn-0a1", "arn:aws:ec2:us-east-1:123456789012:vpn-connection/vpn-0a1"},
+		{TypeVpcPeeringConnection, "pcx-0a1", "arn:aws:ec2:us-east-1:123456789012:vpc-peering-connection/pcx-0a1"},
+		{TypeVpcEndpoint, "vpce-0a1", "arn:aws:ec2:us-east-1:123456789012:vpc-endpoint/vpce-0a1"},
+		{TypeVpcEndpointService, "vpce-svc-0a1", "arn:aws:ec2:us-east-1:123456789012:vpc-endpoint-service/vpce-svc-0a1"},
+		{TypeDhcpOptions, "dopt-0a1", "arn:aws:ec2:us-east-1:123456789012:dhcp-options/dopt-0a1"},
+		{TypeNetworkInterface, "eni-0a1", "arn:aws:ec2:us-east-1:123456789012:network-interface/eni-0a1"},
+		{TypeElasticIP, "eipalloc-0a1", "arn:aws:ec2:us-east-1:123456789012:elastic-ip/eipalloc-0a1"},
+		{TypeInstance, "i-0a1", "arn:aws:ec2:us-east-1:123456789012:instance/i-0a1"},
+		{TypeFlowLog, "fl-0a1", "arn:aws:ec2:us-east-1:123456789012:vpc-flow-log/fl-0a1"},
+		{TypeDirectory, "d-0a1", "arn:aws:ds:us-east-1:123456789012:directory/d-0a1"},
+		{TypeHostedZone, "Z0A1", "arn:aws:route53:::hostedzone/Z0A1"},
+		{TypeResolverEndpoint, "rslvr-in-0a1", "arn:aws:route53resolver:us-east-1:123456789012:resolver-endpoint/rslvr-in-0a1"},
+	}
+	if len(tests) != len(formats) {
+		t.Fatalf("%d types tested, want every one of the %d formats", len(tests), len(formats))
+	}
+
+	partitions := []struct {
+		partition string
+		region    string
+	}{
+		{"aws", "us-east-1"},
+		{"aws-us-gov", "us-gov-west-1"},
+		{"aws-cn", "cn-north-1"},
+	}
+	for _, p := range partitions {
+		for _, tt := range tests {
+			t.Run(p.partition+"/"+tt.resourceType, func(t *testing.T) {
+				want := strings.Replace(tt.want, "arn:aws:", "arn:"+p.partition+":", 1)
+				want = strings.Replace(want, ":us-east-1:", ":"+p.region+":", 1)
+				if got := Build(tt.resourceType, p.region, account, tt.id); got != want {
+					t.Errorf("Build(%q, %q) = %q, want %q", tt.resourceType, p.region, got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestBuildMissingParts checks that an ARN is only built with a known type, an ID, a region and, unless global, an account
+func TestBuildMissingParts(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType string
+		region       string
+		accountID    string
+		id           string
+		want         string
+	}{
+		{name: "unknown type", resourceType: "load-balancer", region: "eu-west-1", accountID: "123456789012", id: "lb-1"},
+		{name: "no ID", resourceType: TypeVPC, region: "eu-west-1", accountID: "123456789012"},
+		{name: "no region", resourceType: TypeVPC, accountID: "123456789012", id: "vpc-1"},
+		{name: "no account", resourceType: TypeVPC, region: "eu-west-1", id: "vpc-1"},
+		{name: "global type without an account", resourceType: TypeHostedZone, region: "cn-northwest-1", id: "Z1", want: "arn:aws-cn:route53:::hostedzone/Z1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Build(tt.resourceType, tt.region, tt.accountID, tt.id); got != tt.want {
+				t.Errorf("Build() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPartition checks the partition of the regions of every partition
+func TestPartition(t *testing.T) {
+	tests := map[string]string{
+		"us-east-1":      "aws",
+		"eu-central-2":   "aws",
+		"us-gov-west-1":  "aws-us-gov",
+		"us-gov-east-1":  "aws-us-gov",
+		"cn-north-1":     "aws-cn",
+		"cn-northwest-1": "aws-cn",
+		"us-iso-east-1":  "aws-iso",
+		"us-isob-east-1": "aws-iso-b",
+	}
+	for region, want := range tests {
+		if got := Partition(region); got != want {
+			t.Errorf("Partition(%q) = %q, want %q", region, got, want)
+		}
+	}
+}
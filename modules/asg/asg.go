@@ -0,0 +1,138 @@
+// Package asg provides functionality for scanning Auto Scaling groups and their subnet spread
+package asg
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+
+	"aws-documentor/modules/vpc"
+)
+
+// LaunchTemplateRef identifies the launch template an Auto Scaling group launches instances from
+type LaunchTemplateRef struct {
+	LaunchTemplateID   string `json:"launch_template_id"`   // ID of the launch template
+	LaunchTemplateName string `json:"launch_template_name"` // Name of the launch template
+	Version            string `json:"version"`              // Version of the launch template ($Default, $Latest or a number)
+}
+
+// AutoScalingGroupInfo contains information about an Auto Scaling group and where its instances run
+type AutoScalingGroupInfo struct {
+	AutoScalingGroupName    string             `json:"auto_scaling_group_name"`   // Name of the Auto Scaling group
+	AutoScalingGroupArn     string             `json:"auto_scaling_group_arn"`    // ARN of the Auto Scaling group
+	MinSize                 int32              `json:"min_size"`                  // Minimum number of instances
+	MaxSize                 int32              `json:"max_size"`                  // Maximum number of instances
+	DesiredCapacity         int32              `json:"desired_capacity"`          // Desired number of instances
+	SubnetIDs               []string           `json:"subnet_ids"`                // Subnets from the VPC zone identifier
+	AvailabilityZones       []string           `json:"availability_zones"`        // Availability zones the group launches into
+	TargetGroupArns         []string           `json:"target_group_arns"`         // Load balancer target groups the group registers instances with
+	LaunchTemplate          *LaunchTemplateRef `json:"launch_template"`           // Launch template (also taken from a mixed instances policy), nil if a launch configuration is used
+	LaunchConfigurationName string             `json:"launch_configuration_name"` // Name of the launch configuration, if one is used instead of a launch template
+	InstanceIDs             []string           `json:"instance_ids"`              // IDs of the instances currently in the group
+	InstancesPerAZ          map[string]int     `json:"instances_per_az"`          // Number of current instances per availability zone
+	Tags                    map[string]string  `json:"tags"`                      // Key-value tags associated with the Auto Scaling group
+	TagList                 []vpc.Tag          `json:"tag_list"`                  // Tags in API order, including tags without a value
+}
+
+// Scanner provides methods for retrieving Auto Scaling group information
+type Scanner struct {
+	client *autoscaling.Client // AWS Auto Scaling client for making API calls
+}
+
+// NewScanner creates a new Auto Scaling group scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials and region information
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		client: autoscaling.NewFromConfig(cfg),
+	}
+}
+
+// GetAutoScalingGroups retrieves information about all Auto Scaling groups in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of AutoScalingGroupInfo structs sorted by name, or error if the operation fails
+func (s *Scanner) GetAutoScalingGroups(ctx context.Context) ([]AutoScalingGroupInfo, error) {
+	groups := []AutoScalingGroupInfo{}
+
+	input := &autoscaling.DescribeAutoScalingGroupsInput{}
+	for {
+		result, err := s.client.DescribeAutoScalingGroups(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("autoscaling", "Auto Scaling groups", "DescribeAutoScalingGroups", err)
+		}
+
+		for _, g := range result.AutoScalingGroups {
+			group := AutoScalingGroupInfo{
+				AutoScalingGroupName:    aws.ToString(g.AutoScalingGroupName),
+				AutoScalingGroupArn:     aws.ToString(g.AutoScalingGroupARN),
+				MinSize:                 aws.ToInt32(g.MinSize),
+				MaxSize:                 aws.ToInt32(g.MaxSize),
+				DesiredCapacity:         aws.ToInt32(g.DesiredCapacity),
+				SubnetIDs:               splitZoneIdentifier(aws.ToString(g.VPCZoneIdentifier)),
+				AvailabilityZones:       append([]string{}, g.AvailabilityZones...),
+				TargetGroupArns:         append([]string{}, g.TargetGroupARNs...),
+				LaunchTemplate:          launchTemplateRef(g),
+				LaunchConfigurationName: aws.ToString(g.LaunchConfigurationName),
+				InstanceIDs:             []string{},
+				InstancesPerAZ:          make(map[string]int),
+				Tags:                    make(map[string]string),
+				TagList:                 []vpc.Tag{},
+			}
+
+			for _, instance := range g.Instances {
+				group.InstanceIDs = append(group.InstanceIDs, aws.ToString(instance.InstanceId))
+				group.InstancesPerAZ[aws.ToString(instance.AvailabilityZone)]++
+			}
+			for _, tag := range g.Tags {
+				if tag.Key != nil && tag.Value != nil {
+					group.Tags[*tag.Key] = *tag.Value
+				}
+				if tag.Key != nil {
+					group.TagList = append(group.TagList, vpc.NewTag(tag.Key, tag.Value))
+				}
+			}
+
+			groups = append(groups, group)
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].AutoScalingGroupName < groups[j].AutoScalingGroupName
+	})
+	return groups, nil
+}
+
+// launchTemplateRef returns the launch template of a group, looking into the mixed instances policy if needed
+func launchTemplateRef(g types.AutoScalingGroup) *LaunchTemplateRef {
+	spec := g.LaunchTemplate
+	if spec == nil && g.MixedInstancesPolicy != nil && g.MixedInstancesPolicy.LaunchTemplate != nil {
+		spec = g.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+	}
+	if spec == nil {
+		return nil
+	}
+	return &LaunchTemplateRef{
+		LaunchTemplateID:   aws.ToString(spec.LaunchTemplateId),
+		LaunchTemplateName: aws.ToString(spec.LaunchTemplateName),
+		Version:            aws.ToString(spec.Version),
+	}
+}
+
+// splitZoneIdentifier splits a VPC zone identifier ("subnet-1,subnet-2") into subnet IDs
+func splitZoneIdentifier(zoneIdentifier string) []string {
+	subnetIDs := []string{}
+	for _, id := range strings.Split(zoneIdentifier, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			subnetIDs = append(subnetIDs, id)
+		}
+	}
+	return subnetIDs
+}
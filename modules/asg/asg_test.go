@@ -0,0 +1,110 @@
+package asg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/vpc"
+)
+
+// newTestScanner returns a scanner whose Auto Scaling endpoint answers DescribeAutoScalingGroups with the page
+// for the request's NextToken, or denies access when there is none
+// pages: Result elements by NextToken ("" for the first page)
+func newTestScanner(t *testing.T, pages map[string]string) *Scanner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "text/xml")
+		page, ok := pages[r.Form.Get("NextToken")]
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error><RequestId>req-1</RequestId></ErrorResponse>`)
+			return
+		}
+		fmt.Fprintf(w, `<DescribeAutoScalingGroupsResponse xmlns="http://autoscaling.amazonaws.com/doc/2011-01-01/">
+			<DescribeAutoScalingGroupsResult>%s</DescribeAutoScalingGroupsResult>
+			<ResponseMetadata><RequestId>req-1</RequestId></ResponseMetadata></DescribeAutoScalingGroupsResponse>`, page)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewScanner(aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+}
+
+// TestGetAutoScalingGroups reads groups with a launch template, a mixed instances policy and a launch
+// configuration from two pages and sorts them by name
+func TestGetAutoScalingGroups(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{
+		"": `<AutoScalingGroups><member>
+			<AutoScalingGroupName>web</AutoScalingGroupName>
+			<AutoScalingGroupARN>arn:aws:autoscaling:eu-west-1:111122223333:autoScalingGroup:1:autoScalingGroupName/web</AutoScalingGroupARN>
+			<MinSize>2</MinSize><MaxSize>6</MaxSize><DesiredCapacity>3</DesiredCapacity>
+			<VPCZoneIdentifier>subnet-0a1, subnet-0b2,,subnet-0c3</VPCZoneIdentifier>
+			<AvailabilityZones><member>eu-west-1a</member><member>eu-west-1b</member><member>eu-west-1c</member></AvailabilityZones>
+			<TargetGroupARNs><member>arn:aws:elasticloadbalancing:eu-west-1:111122223333:targetgroup/web/0a1</member></TargetGroupARNs>
+			<LaunchTemplate><LaunchTemplateId>lt-0web</LaunchTemplateId><LaunchTemplateName>web</LaunchTemplateName><Version>$Latest</Version></LaunchTemplate>
+			<Instances>
+				<member><InstanceId>i-01</InstanceId><AvailabilityZone>eu-west-1a</AvailabilityZone></member>
+				<member><InstanceId>i-02</InstanceId><AvailabilityZone>eu-west-1a</AvailabilityZone></member>
+				<member><InstanceId>i-03</InstanceId><AvailabilityZone>eu-west-1b</AvailabilityZone></member>
+			</Instances>
+			<Tags><member><Key>team</Key><Value>web</Value></member><member><Key>empty</Key></member></Tags>
+		</member></AutoScalingGroups><NextToken>page-2</NextToken>`,
+		"page-2": `<AutoScalingGroups><member>
+			<AutoScalingGroupName>spot</AutoScalingGroupName><MinSize>0</MinSize><MaxSize>10</MaxSize><DesiredCapacity>0</DesiredCapacity>
+			<VPCZoneIdentifier>subnet-0a1</VPCZoneIdentifier>
+			<MixedInstancesPolicy><LaunchTemplate><LaunchTemplateSpecification>
+				<LaunchTemplateId>lt-0spot</LaunchTemplateId><Version>3</Version>
+			</LaunchTemplateSpecification></LaunchTemplate></MixedInstancesPolicy>
+		</member><member>
+			<AutoScalingGroupName>legacy</AutoScalingGroupName><MinSize>1</MinSize><MaxSize>1</MaxSize><DesiredCapacity>1</DesiredCapacity>
+			<LaunchConfigurationName>legacy-2019</LaunchConfigurationName>
+			<AvailabilityZones><member>eu-west-1a</member></AvailabilityZones>
+		</member></AutoScalingGroups>`,
+	})
+
+	got, err := scanner.GetAutoScalingGroups(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AutoScalingGroupInfo{
+		{AutoScalingGroupName: "legacy", MinSize: 1, MaxSize: 1, DesiredCapacity: 1, SubnetIDs: []string{},
+			AvailabilityZones: []string{"eu-west-1a"}, TargetGroupArns: []string{}, LaunchConfigurationName: "legacy-2019",
+			InstanceIDs: []string{}, InstancesPerAZ: map[string]int{}, Tags: map[string]string{}, TagList: []vpc.Tag{}},
+		{AutoScalingGroupName: "spot", MaxSize: 10, SubnetIDs: []string{"subnet-0a1"}, AvailabilityZones: []string{}, TargetGroupArns: []string{},
+			LaunchTemplate: &LaunchTemplateRef{LaunchTemplateID: "lt-0spot", Version: "3"},
+			InstanceIDs:    []string{}, InstancesPerAZ: map[string]int{}, Tags: map[string]string{}, TagList: []vpc.Tag{}},
+		{AutoScalingGroupName: "web", AutoScalingGroupArn: "arn:aws:autoscaling:eu-west-1:111122223333:autoScalingGroup:1:autoScalingGroupName/web",
+			MinSize: 2, MaxSize: 6, DesiredCapacity: 3, SubnetIDs: []string{"subnet-0a1", "subnet-0b2", "subnet-0c3"},
+			AvailabilityZones: []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"},
+			TargetGroupArns:   []string{"arn:aws:elasticloadbalancing:eu-west-1:111122223333:targetgroup/web/0a1"},
+			LaunchTemplate:    &LaunchTemplateRef{LaunchTemplateID: "lt-0web", LaunchTemplateName: "web", Version: "$Latest"},
+			InstanceIDs:       []string{"i-01", "i-02", "i-03"}, InstancesPerAZ: map[string]int{"eu-west-1a": 2, "eu-west-1b": 1},
+			Tags:    map[string]string{"team": "web"},
+			TagList: []vpc.Tag{{Key: "team", Value: "web"}, {Key: "empty"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAutoScalingGroups() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestGetAutoScalingGroupsDenied checks that a denied call returns a scan error classified as access denied
+func TestGetAutoScalingGroupsDenied(t *testing.T) {
+	_, err := newTestScanner(t, nil).GetAutoScalingGroups(context.Background())
+	var scanErr *vpc.ScanError
+	if !errors.As(err, &scanErr) || scanErr.Operation != "DescribeAutoScalingGroups" || !errors.Is(err, vpc.ErrAccessDenied) {
+		t.Errorf("error = %v, want an access denied scan error of DescribeAutoScalingGroups", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Package awsconfig provides the shared AWS configuration and HTTP client used by every service client
+package awsconfig
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// HTTPOptions tunes the HTTP client shared by all AWS service clients
+type HTTPOptions struct {
+	Timeout             time.Duration // Limit for a whole request including reading the response (0 for none)
+	MaxIdleConnsPerHost int           // Idle connections kept open per service endpoint for reuse
+	Proxy               string        // Proxy URL; empty to use HTTPS_PROXY/HTTP_PROXY/NO_PROXY from the environment
+}
+
+// DefaultHTTPOptions returns the settings used when no flags override them
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Timeout:             60 * time.Second,
+		MaxIdleConnsPerHost: 32,
+	}
+}
+
+// DialCounter counts the connections the shared client opens
+// Connection reuse shows as far fewer dials than requests
+type DialCounter struct {
+	dials atomic.Int64
+}
+
+// Dials returns the number of connections opened so far
+func (c *DialCounter) Dials() int64 {
+	return c.dials.Load()
+}
+
+// NewHTTPClient builds a client with keep-alive connection pooling and HTTP/2
+// The SDK's per-client default transport does not share connections between service clients;
+// one client passed to all of them reuses TLS connections and keeps ephemeral port use bounded.
+// opts: Timeout, pool size and proxy settings
+// Returns: The client and a counter of the connections it dials, or error if the proxy URL is invalid
+func NewHTTPClient(opts HTTPOptions) (*http.Client, *DialCounter, error) {
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyURL, err := ParseProxy(opts.Proxy)
+		if err != nil {
+			return nil, nil, err
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	counter := &DialCounter{}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			counter.dials.Add(1)
+			return dialer.DialContext(ctx, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, counter, nil
+}
+
+// ParseProxy parses a proxy URL, which must name a host
+func ParseProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	return proxyURL, nil
+}
+
+// Load loads the default AWS configuration with the shared HTTP client
+// ctx: Context for loading credentials and settings
+// region: Region override (empty to use the default configuration's region)
+// opts: Settings for the shared HTTP client
+// Returns: AWS config for every service client, the client's dial counter, or error if loading fails
+func Load(ctx context.Context, region string, opts HTTPOptions) (aws.Config, *DialCounter, error) {
+	return LoadProfile(ctx, region, "", opts)
+}
+
+// LoadProfile loads a named profile of the shared config and credentials files with the shared HTTP client
+// SSO profiles use the token cached by "aws sso login"; profiles that assume a role with an MFA
+// device read the token code from stdin when the credentials are first retrieved.
+// ctx: Context for loading credentials and settings
+// region: Region override (empty to use the profile's region)
+// profile: Profile name (empty for the default configuration)
+// opts: Settings for the shared HTTP client
+// Returns: AWS config for every service client, the client's dial counter, or error if loading fails
+func LoadProfile(ctx context.Context, region, profile string, opts HTTPOptions) (aws.Config, *DialCounter, error) {
+	client, counter, err := NewHTTPClient(opts)
+	if err != nil {
+		return aws.Config{}, nil, err
+	}
+
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithHTTPClient(client),
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = stscreds.StdinTokenProvider
+		}),
+	}
+	if region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(region))
+	}
+	if profile != "" {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return aws.Config{}, nil, err
+	}
+	return cfg, counter, nil
+}
+
+// DefaultRoleSessionName is the session name of the roles the tool assumes, as shown in CloudTrail
+const DefaultRoleSessionName = "aws-documentor"
+
+// AssumeRole returns a copy of cfg whose credentials come from assuming a role with the credentials of cfg
+// The role is assumed when the credentials are first retrieved, and again before they expire.
+// cfg: AWS config from Load or LoadProfile
+// roleARN: ARN of the role to assume
+func AssumeRole(cfg aws.Config, roleARN string) aws.Config {
+	return AssumeRoleSession(cfg, roleARN, DefaultRoleSessionName, "")
+}
+
+// AssumeRoleSession is AssumeRole with a session name and an external ID
+// Roles that third parties assume usually require the external ID agreed with their owner.
+// sessionName: Session name of the assumed role (empty for DefaultRoleSessionName)
+// externalID: External ID the role's trust policy requires (empty for none)
+func AssumeRoleSession(cfg aws.Config, roleARN, sessionName, externalID string) aws.Config {
+	if sessionName == "" {
+		sessionName = DefaultRoleSessionName
+	}
+	assumed := cfg.Copy()
+	assumed.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+	}))
+	return assumed
+}
+
+// AccountID returns the account of the credentials in cfg
+// ctx: Context for the request
+// cfg: AWS config from Load
+// Returns: 12-digit account ID, or error if the credentials cannot be resolved
+func AccountID(ctx context.Context, cfg aws.Config) (string, error) {
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	return aws.ToString(identity.Account), nil
+}
@@ -0,0 +1,101 @@
+package awsconfig
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// TestSharedClientReusesConnections sends requests of several service clients through one shared HTTP client
+// The clients take turns, so without reuse every request would dial; with the shared pool the endpoint
+// sees a single connection and the counter a single dial.
+func TestSharedClientReusesConnections(t *testing.T) {
+	var connections atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("Action")
+		w.Header().Set("Content-Type", "text/xml")
+		if action == "GetCallerIdentity" {
+			fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">`+
+				`<GetCallerIdentityResult><Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`)
+			return
+		}
+		fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>req-1</requestId></%sResponse>`, action, action)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, dials, err := NewHTTPClient(DefaultHTTPOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   client,
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+	ec2Copy := ec2.NewFromConfig(cfg)
+	stsClient := sts.NewFromConfig(cfg)
+
+	ctx := context.Background()
+	const rounds = 5
+	for i := 0; i < rounds; i++ {
+		if _, err := ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ec2Copy.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := dials.Dials(); got != 1 {
+		t.Errorf("Dials() = %d after %d requests, want 1", got, 3*rounds)
+	}
+	if got := connections.Load(); got != dials.Dials() {
+		t.Errorf("the endpoint saw %d connections, the counter %d dials", got, dials.Dials())
+	}
+}
+
+// TestDialCounterCountsEveryConnection checks that connections that cannot be reused are all counted
+func TestDialCounterCountsEveryConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Closing after each response prevents reuse
+		w.Header().Set("Connection", "close")
+	}))
+	defer server.Close()
+
+	client, dials, err := NewHTTPClient(DefaultHTTPOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const requests = 4
+	for i := 0; i < requests; i++ {
+		response, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+	}
+	if got := dials.Dials(); got != requests {
+		t.Errorf("Dials() = %d, want %d", got, requests)
+	}
+}
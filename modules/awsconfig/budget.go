@@ -0,0 +1,75 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ErrCallBudgetExceeded is returned for API calls refused because the call budget is used up
+var ErrCallBudgetExceeded = errors.New("API call budget exceeded")
+
+// CallBudget counts the API requests of every service client and refuses them beyond a maximum
+// Retries are separate requests against the account's rate limits, so each attempt counts.
+type CallBudget struct {
+	max   int64        // Maximum number of requests, 0 for no limit
+	calls atomic.Int64 // Requests sent or refused so far
+
+	mu      sync.Mutex
+	refused []string        // Operations refused at least once, in order (service:Operation)
+	seen    map[string]bool // Operations in refused
+}
+
+// NewCallBudget creates a budget allowing max requests, or counting without a limit when max is 0
+func NewCallBudget(max int) *CallBudget {
+	return &CallBudget{max: int64(max), seen: make(map[string]bool)}
+}
+
+// Calls returns the number of requests sent so far
+func (b *CallBudget) Calls() int64 {
+	calls := b.calls.Load()
+	if b.max > 0 && calls > b.max {
+		return b.max
+	}
+	return calls
+}
+
+// Exceeded reports whether any request was refused
+func (b *CallBudget) Exceeded() bool {
+	return b.max > 0 && b.calls.Load() > b.max
+}
+
+// Refused returns the operations that were refused, in the order they were first refused
+// An operation that also appears before the refusal in the scan output was truncated mid-pagination.
+func (b *CallBudget) Refused() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.refused...)
+}
+
+// AddMiddleware registers the budget on a client's middleware stack
+// Append it to aws.Config.APIOptions so every client created from the config shares the budget.
+func (b *CallBudget) AddMiddleware(stack *middleware.Stack) error {
+	// After the retry middleware, so every attempt passes through
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("CallBudget", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		if calls := b.calls.Add(1); b.max > 0 && calls > b.max {
+			b.refuse(awsmiddleware.GetServiceID(ctx) + ":" + awsmiddleware.GetOperationName(ctx))
+			return middleware.FinalizeOutput{}, middleware.Metadata{}, ErrCallBudgetExceeded
+		}
+		return next.HandleFinalize(ctx, in)
+	}), middleware.After)
+}
+
+// refuse records a refused operation
+func (b *CallBudget) refuse(operation string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.seen[operation] {
+		b.seen[operation] = true
+		b.refused = append(b.refused, operation)
+	}
+}
@@ -0,0 +1,293 @@
+// Package backstage exports the scanned network as Backstage software catalog entities
+// Every VPC becomes a Resource entity owned by the team in its owner tag, with the transit gateways it is
+// attached to and the VPCs it is peered with as dependencies, so the developer portal shows who owns
+// which network and what it relies on.
+package backstage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/naming"
+	"aws-documentor/modules/vpc"
+)
+
+// Envelope values of the generated entities
+const (
+	APIVersion   = "backstage.io/v1alpha1" // apiVersion of every entity
+	KindResource = "Resource"              // Kind of the VPC, subnet tier and transit gateway entities
+	KindLocation = "Location"              // Kind of the entity listing the files
+)
+
+// Resource types in spec.type
+const (
+	TypeVPC            = "vpc"             // A VPC
+	TypeSubnetTier     = "subnet-tier"     // The subnets of a VPC that share a tier (public, private, routed, isolated)
+	TypeTransitGateway = "transit-gateway" // A transit gateway
+)
+
+// LocationFile is the file listing every entity file, the one to register in the catalog
+const LocationFile = "catalog-info.yaml"
+
+// annotationPrefix namespaces the annotations carrying the AWS identifiers
+const annotationPrefix = "aws-documentor/"
+
+// Options control the generated entities
+type Options struct {
+	Region       string   // Scanned region, annotated on every entity
+	AccountID    string   // Scanned account, annotated on every entity ("" if unknown)
+	OwnerTags    []string // Tag keys naming the owner, compared case-insensitively; the first one a resource has wins
+	DefaultOwner string   // Owner of resources without an owner tag
+	SubnetTiers  bool     // Whether to add a Resource per VPC and subnet tier
+}
+
+// Metadata is the metadata block of an entity
+type Metadata struct {
+	Name        string            // Unique name within the namespace, from naming.StyleBackstage
+	Title       string            // Display name, the Name tag or ID of the resource
+	Description string            // One-line description
+	Annotations map[string]string // AWS identifiers under the aws-documentor/ prefix
+	Tags        []string          // Catalog tags (aws, vpc, ...)
+}
+
+// Spec is the spec block of an entity
+type Spec struct {
+	Type      string   // Resource type (Resource entities only)
+	Owner     string   // Entity reference of the owner (Resource entities only)
+	DependsOn []string // Entity references of the resources this one depends on (Resource entities only)
+	Targets   []string // Entity files relative to the Location file (Location entities only)
+}
+
+// Entity is a Backstage catalog entity
+type Entity struct {
+	APIVersion string
+	Kind       string
+	Metadata   Metadata
+	Spec       Spec
+}
+
+// Catalog contains the generated entities
+type Catalog struct {
+	Entities []Entity        // VPCs, then subnet tiers, then transit gateways, each sorted by resource ID
+	Renames  []naming.Rename // Entity names that differ from the Name tag they were derived from
+}
+
+// Build derives the catalog entities from scanned resources
+// vpcs: Scanned VPCs
+// subnets: Scanned subnets (used with opts.SubnetTiers)
+// routeTables: Scanned route tables, for the tier of every subnet
+// transitGateways: Scanned transit gateways
+// tgwAttachments: Transit gateway attachments, for the dependencies of VPCs and peered transit gateways
+// peerings: VPC peering connections, for the dependencies between VPCs (nil if not scanned)
+// opts: Region, account, owner tags and whether to add subnet tiers
+// Returns: The catalog
+func Build(vpcs []vpc.VPCInfo, subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo, transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo, peerings []vpc.VpcPeeringConnectionInfo, opts Options) *Catalog {
+	sortedVPCs := append([]vpc.VPCInfo{}, vpcs...)
+	sort.Slice(sortedVPCs, func(i, j int) bool { return sortedVPCs[i].VpcID < sortedVPCs[j].VpcID })
+	sortedTGWs := append([]vpc.TransitGatewayInfo{}, transitGateways...)
+	sort.Slice(sortedTGWs, func(i, j int) bool { return sortedTGWs[i].TransitGatewayID < sortedTGWs[j].TransitGatewayID })
+
+	// Names are handed out before any entity is built, so dependencies can refer to them. They are
+	// file names too, so the name of the Location file is reserved first.
+	names := naming.NewNamer(naming.StyleBackstage)
+	names.Name(LocationFile, strings.TrimSuffix(LocationFile, ".yaml"))
+	refs := make(map[string]string)
+	for _, v := range sortedVPCs {
+		refs[v.VpcID] = "resource:" + names.Name(v.VpcID, v.Tags["Name"])
+	}
+	for _, tgw := range sortedTGWs {
+		refs[tgw.TransitGatewayID] = "resource:" + names.Name(tgw.TransitGatewayID, tgw.Tags["Name"])
+	}
+	dependencies := Dependencies(vpcs, transitGateways, tgwAttachments, peerings)
+	dependsOn := func(resourceID string) []string {
+		refList := []string{}
+		for _, id := range dependencies[resourceID] {
+			refList = append(refList, refs[id])
+		}
+		return refList
+	}
+
+	catalog := &Catalog{Entities: []Entity{}}
+	for _, v := range sortedVPCs {
+		entity := opts.resource(strings.TrimPrefix(refs[v.VpcID], "resource:"), TypeVPC, v.VpcID, v.Tags, "vpc")
+		entity.Metadata.Description = fmt.Sprintf("VPC %s (%s) in %s", v.VpcID, v.CidrBlock, opts.Region)
+		entity.Metadata.Annotations[annotationPrefix+"vpc-id"] = v.VpcID
+		if v.Arn != "" {
+			entity.Metadata.Annotations[annotationPrefix+"arn"] = v.Arn
+		}
+		entity.Metadata.Annotations[annotationPrefix+"cidr-blocks"] = cidrBlocks(v)
+		entity.Spec.DependsOn = dependsOn(v.VpcID)
+		catalog.Entities = append(catalog.Entities, entity)
+	}
+
+	if opts.SubnetTiers {
+		tiers := analysis.SubnetTiers(subnets, routeTables)
+		byTier := make(map[string][]vpc.SubnetInfo)
+		for _, subnet := range subnets {
+			key := subnet.VpcID + "/" + tiers[subnet.SubnetID]
+			byTier[key] = append(byTier[key], subnet)
+		}
+		vpcByID := make(map[string]vpc.VPCInfo, len(vpcs))
+		for _, v := range vpcs {
+			vpcByID[v.VpcID] = v
+		}
+		for _, key := range sortedKeys(byTier) {
+			members := byTier[key]
+			vpcID, tier, _ := strings.Cut(key, "/")
+			parent, scanned := vpcByID[vpcID]
+			if !scanned {
+				continue
+			}
+			title := parent.Tags["Name"]
+			if title == "" {
+				title = vpcID
+			}
+			entity := opts.resource(names.Name(key, title+"-"+tier), TypeSubnetTier, key, parent.Tags, "subnet")
+			entity.Metadata.Title = title + " " + tier + " subnets"
+			entity.Metadata.Description = fmt.Sprintf("%d %s subnet(s) of %s", len(members), tier, vpcID)
+			var subnetIDs, cidrs []string
+			for _, subnet := range members {
+				subnetIDs = append(subnetIDs, subnet.SubnetID)
+				cidrs = append(cidrs, subnet.CidrBlock)
+			}
+			entity.Metadata.Annotations[annotationPrefix+"vpc-id"] = vpcID
+			entity.Metadata.Annotations[annotationPrefix+"subnet-tier"] = tier
+			entity.Metadata.Annotations[annotationPrefix+"subnet-ids"] = strings.Join(subnetIDs, ",")
+			entity.Metadata.Annotations[annotationPrefix+"cidr-blocks"] = strings.Join(cidrs, ",")
+			entity.Spec.DependsOn = []string{refs[vpcID]}
+			catalog.Entities = append(catalog.Entities, entity)
+		}
+	}
+
+	for _, tgw := range sortedTGWs {
+		entity := opts.resource(strings.TrimPrefix(refs[tgw.TransitGatewayID], "resource:"), TypeTransitGateway, tgw.TransitGatewayID, tgw.Tags, "transit-gateway")
+		entity.Metadata.Description = fmt.Sprintf("Transit gateway %s in %s", tgw.TransitGatewayID, opts.Region)
+		entity.Metadata.Annotations[annotationPrefix+"transit-gateway-id"] = tgw.TransitGatewayID
+		if tgw.Arn != "" {
+			entity.Metadata.Annotations[annotationPrefix+"arn"] = tgw.Arn
+		}
+		entity.Spec.DependsOn = dependsOn(tgw.TransitGatewayID)
+		catalog.Entities = append(catalog.Entities, entity)
+	}
+
+	catalog.Renames = names.Renames()
+	return catalog
+}
+
+// resource starts a Resource entity with the common annotations, title and owner
+func (opts Options) resource(name, resourceType, resourceID string, tags map[string]string, kindTag string) Entity {
+	title := tags["Name"]
+	if title == "" {
+		title = resourceID
+	}
+	entity := Entity{
+		APIVersion: APIVersion,
+		Kind:       KindResource,
+		Metadata: Metadata{
+			Name:        name,
+			Title:       title,
+			Annotations: map[string]string{annotationPrefix + "region": opts.Region},
+			Tags:        []string{"aws", kindTag},
+		},
+		Spec: Spec{Type: resourceType, Owner: opts.owner(tags), DependsOn: []string{}},
+	}
+	if opts.AccountID != "" {
+		entity.Metadata.Annotations[annotationPrefix+"account-id"] = opts.AccountID
+	}
+	return entity
+}
+
+// owner maps the owner tag of a resource to an entity reference
+// Values that are references already (group:default/payments, user:jane) are kept; other values
+// name a group, sanitized like entity names. Keys differing only in case are tried in sorted order.
+func (opts Options) owner(tags map[string]string) string {
+	for _, ownerTag := range opts.OwnerTags {
+		for _, key := range sortedKeys(tags) {
+			value := tags[key]
+			if !strings.EqualFold(key, ownerTag) || strings.TrimSpace(value) == "" {
+				continue
+			}
+			if strings.Contains(value, ":") {
+				return strings.TrimSpace(value)
+			}
+			if name := naming.Sanitize(value, naming.StyleBackstage); name != "" {
+				return "group:" + name
+			}
+		}
+	}
+	return opts.DefaultOwner
+}
+
+// Dependencies derives the dependsOn edges between scanned resources
+// A VPC depends on the transit gateways it is attached to and on the VPCs it has an active peering
+// connection with; a transit gateway depends on the transit gateways it is peered with. A peering makes
+// both sides depend on each other, since either going away cuts the traffic. Only available
+// attachments and active peerings count, and edges to resources that were not scanned are dropped, as
+// their entities do not exist.
+// Returns: IDs of the resources each resource depends on, sorted, keyed by resource ID
+func Dependencies(vpcs []vpc.VPCInfo, transitGateways []vpc.TransitGatewayInfo, tgwAttachments []vpc.TransitGatewayAttachmentInfo, peerings []vpc.VpcPeeringConnectionInfo) map[string][]string {
+	scanned := make(map[string]bool)
+	for _, v := range vpcs {
+		scanned[v.VpcID] = true
+	}
+	for _, tgw := range transitGateways {
+		scanned[tgw.TransitGatewayID] = true
+	}
+
+	edges := make(map[string]map[string]bool)
+	add := func(from, to string) {
+		if from == to || !scanned[from] || !scanned[to] {
+			return
+		}
+		if edges[from] == nil {
+			edges[from] = make(map[string]bool)
+		}
+		edges[from][to] = true
+	}
+	for _, attachment := range tgwAttachments {
+		if attachment.State != "available" {
+			continue
+		}
+		switch attachment.ResourceType {
+		case "vpc":
+			add(attachment.ResourceID, attachment.TransitGatewayID)
+		case "peering":
+			add(attachment.TransitGatewayID, attachment.ResourceID)
+			add(attachment.ResourceID, attachment.TransitGatewayID)
+		}
+	}
+	for _, pcx := range peerings {
+		if pcx.Status != "active" {
+			continue
+		}
+		add(pcx.RequesterVpcID, pcx.AccepterVpcID)
+		add(pcx.AccepterVpcID, pcx.RequesterVpcID)
+	}
+
+	result := make(map[string][]string, len(edges))
+	for from, targets := range edges {
+		result[from] = sortedKeys(targets)
+	}
+	return result
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// cidrBlocks lists the IPv4 CIDR blocks of a VPC, falling back to the primary one for reports that lack the associations
+func cidrBlocks(v vpc.VPCInfo) string {
+	if len(v.AssociateCidrBlocks) == 0 {
+		return v.CidrBlock
+	}
+	return strings.Join(v.AssociateCidrBlocks, ",")
+}
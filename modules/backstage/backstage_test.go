@@ -0,0 +1,250 @@
+package backstage
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/naming"
+	"aws-documentor/modules/vpc"
+)
+
+// testOptions are the options of the tests, with the default owner tags of -backstage-owner-tags
+func testOptions() Options {
+	return Options{Region: "eu-west-1", AccountID: "111122223333", OwnerTags: []string{"Owner", "team"}, DefaultOwner: "unknown"}
+}
+
+// entityNames returns the names of the catalog entities keyed by their title
+func entityNames(c *Catalog) map[string]string {
+	names := make(map[string]string, len(c.Entities))
+	for _, entity := range c.Entities {
+		names[entity.Metadata.Title] = entity.Metadata.Name
+	}
+	return names
+}
+
+// TestDependencies checks every edge Dependencies derives and the attachments, peerings and unscanned
+// resources it leaves out
+func TestDependencies(t *testing.T) {
+	vpcs := []vpc.VPCInfo{{VpcID: "vpc-0a1"}, {VpcID: "vpc-0b2"}, {VpcID: "vpc-0c3"}}
+	tgws := []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1"}, {TransitGatewayID: "tgw-0b2"}}
+	attach := func(tgwID, resourceType, resourceID, state string) vpc.TransitGatewayAttachmentInfo {
+		return vpc.TransitGatewayAttachmentInfo{TransitGatewayID: tgwID, ResourceType: resourceType, ResourceID: resourceID, State: state}
+	}
+	peer := func(requester, accepter, status string) vpc.VpcPeeringConnectionInfo {
+		return vpc.VpcPeeringConnectionInfo{RequesterVpcID: requester, AccepterVpcID: accepter, Status: status}
+	}
+
+	tests := []struct {
+		name        string
+		attachments []vpc.TransitGatewayAttachmentInfo
+		peerings    []vpc.VpcPeeringConnectionInfo
+		want        map[string][]string
+	}{
+		{name: "VPC attachment", attachments: []vpc.TransitGatewayAttachmentInfo{attach("tgw-0a1", "vpc", "vpc-0a1", "available")},
+			want: map[string][]string{"vpc-0a1": {"tgw-0a1"}}},
+		{name: "VPC attached to two transit gateways", attachments: []vpc.TransitGatewayAttachmentInfo{
+			attach("tgw-0b2", "vpc", "vpc-0a1", "available"), attach("tgw-0a1", "vpc", "vpc-0a1", "available"), attach("tgw-0a1", "vpc", "vpc-0a1", "available")},
+			want: map[string][]string{"vpc-0a1": {"tgw-0a1", "tgw-0b2"}}},
+		{name: "pending attachment", attachments: []vpc.TransitGatewayAttachmentInfo{attach("tgw-0a1", "vpc", "vpc-0a1", "pending")},
+			want: map[string][]string{}},
+		{name: "attachment to a transit gateway that was not scanned", attachments: []vpc.TransitGatewayAttachmentInfo{attach("tgw-0shared", "vpc", "vpc-0a1", "available")},
+			want: map[string][]string{}},
+		{name: "VPN and Direct Connect attachments", attachments: []vpc.TransitGatewayAttachmentInfo{
+			attach("tgw-0a1", "vpn", "vpn-0office", "available"), attach("tgw-0a1", "direct-connect-gateway", "dxgw-0a1", "available")},
+			want: map[string][]string{}},
+		{name: "transit gateway peering", attachments: []vpc.TransitGatewayAttachmentInfo{attach("tgw-0a1", "peering", "tgw-0b2", "available")},
+			want: map[string][]string{"tgw-0a1": {"tgw-0b2"}, "tgw-0b2": {"tgw-0a1"}}},
+		{name: "peering with a transit gateway in another region", attachments: []vpc.TransitGatewayAttachmentInfo{attach("tgw-0a1", "peering", "tgw-0us", "available")},
+			want: map[string][]string{}},
+		{name: "active VPC peering", peerings: []vpc.VpcPeeringConnectionInfo{peer("vpc-0a1", "vpc-0b2", "active")},
+			want: map[string][]string{"vpc-0a1": {"vpc-0b2"}, "vpc-0b2": {"vpc-0a1"}}},
+		{name: "VPC peerings that are not active", peerings: []vpc.VpcPeeringConnectionInfo{
+			peer("vpc-0a1", "vpc-0b2", "pending-acceptance"), peer("vpc-0a1", "vpc-0c3", "deleted")},
+			want: map[string][]string{}},
+		{name: "peering with a VPC in another account", peerings: []vpc.VpcPeeringConnectionInfo{peer("vpc-0a1", "vpc-0partner", "active")},
+			want: map[string][]string{}},
+		{name: "attachments and peerings together",
+			attachments: []vpc.TransitGatewayAttachmentInfo{attach("tgw-0a1", "vpc", "vpc-0b2", "available"), attach("tgw-0a1", "vpc", "vpc-0c3", "available")},
+			peerings:    []vpc.VpcPeeringConnectionInfo{peer("vpc-0c3", "vpc-0a1", "active"), peer("vpc-0b2", "vpc-0c3", "active")},
+			want:        map[string][]string{"vpc-0a1": {"vpc-0c3"}, "vpc-0b2": {"tgw-0a1", "vpc-0c3"}, "vpc-0c3": {"tgw-0a1", "vpc-0a1", "vpc-0b2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Dependencies(vpcs, tgws, tt.attachments, tt.peerings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dependencies() = %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBuildNameCollisions checks that names clashing after sanitization, with the reserved Location file
+// or between kinds get a suffix from the resource ID, and that every rename is reported
+func TestBuildNameCollisions(t *testing.T) {
+	vpcs := []vpc.VPCInfo{
+		{VpcID: "vpc-0b2", Tags: map[string]string{"Name": "prod"}},
+		{VpcID: "vpc-0a1", Tags: map[string]string{"Name": "Prod"}},
+		{VpcID: "vpc-0c3", Tags: map[string]string{"Name": "catalog-info"}},
+		{VpcID: "vpc-0d4"},
+		{VpcID: "vpc-0e5", Tags: map[string]string{"Name": "生产"}},
+		{VpcID: "vpc-0f6", Tags: map[string]string{"Name": "Prod Public"}},
+	}
+	tgws := []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", Tags: map[string]string{"Name": "PROD"}}}
+	subnets := []vpc.SubnetInfo{{SubnetID: "subnet-0a1", VpcID: "vpc-0a1"}}
+	routeTables := []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+		{DestinationCidrBlock: "0.0.0.0/0", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}}}}}
+	opts := testOptions()
+	opts.SubnetTiers = true
+
+	catalog := Build(vpcs, subnets, routeTables, tgws, nil, nil, opts)
+	want := map[string]string{
+		"Prod":                "prod",
+		"prod":                "prod-0b2",
+		"catalog-info":        "catalog-info-0c3",
+		"vpc-0d4":             "vpc-0d4",
+		"生产":                  "vpc-0e5",
+		"Prod Public":         "prod-public",
+		"Prod public subnets": "prod-public-0a1-public",
+		"PROD":                "prod-0a1",
+	}
+	if got := entityNames(catalog); !reflect.DeepEqual(got, want) {
+		t.Errorf("entity names = %v\nwant %v", got, want)
+	}
+	for _, entity := range catalog.Entities {
+		if problems := Validate(entity); len(problems) > 0 {
+			t.Errorf("entity %s is invalid: %v", entity.Metadata.Name, problems)
+		}
+	}
+
+	reasons := make(map[string][]string)
+	for _, rename := range catalog.Renames {
+		if rename.Style != naming.StyleBackstage {
+			t.Errorf("rename %+v has style %s", rename, rename.Style)
+		}
+		reasons[rename.ResourceID] = rename.Reasons
+	}
+	wantReasons := map[string][]string{
+		"vpc-0a1":        {naming.ReasonReplaced},
+		"vpc-0b2":        {naming.ReasonCollision},
+		"vpc-0c3":        {naming.ReasonCollision},
+		"vpc-0e5":        {naming.ReasonReplaced, naming.ReasonEmpty},
+		"vpc-0f6":        {naming.ReasonReplaced},
+		"tgw-0a1":        {naming.ReasonReplaced, naming.ReasonCollision},
+		"vpc-0a1/public": {naming.ReasonReplaced, naming.ReasonCollision},
+	}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("rename reasons = %v\nwant %v", reasons, wantReasons)
+	}
+}
+
+// TestBuildEntities checks the annotations, owner, dependencies and order of the VPC, subnet tier and
+// transit gateway entities
+func TestBuildEntities(t *testing.T) {
+	vpcs := []vpc.VPCInfo{
+		{VpcID: "vpc-0b2", CidrBlock: "10.2.0.0/16", Tags: map[string]string{"Name": "shared", "team": "Platform Networking"}},
+		{VpcID: "vpc-0a1", Arn: "arn:aws:ec2:eu-west-1:111122223333:vpc/vpc-0a1", CidrBlock: "10.1.0.0/16",
+			AssociateCidrBlocks: []string{"10.1.0.0/16", "100.64.0.0/16"}, Tags: map[string]string{"Name": "payments", "Owner": "group:default/payments"}},
+	}
+	subnets := []vpc.SubnetInfo{
+		{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.1.1.0/24"},
+		{SubnetID: "subnet-0a2", VpcID: "vpc-0a1", CidrBlock: "10.1.2.0/24"},
+		{SubnetID: "subnet-0a3", VpcID: "vpc-0a1", CidrBlock: "10.1.3.0/24"},
+		{SubnetID: "subnet-0gone", VpcID: "vpc-0gone", CidrBlock: "10.9.1.0/24"},
+	}
+	routeTables := []vpc.RouteTableInfo{
+		{RouteTableID: "rtb-0public", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a3"}, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "0.0.0.0/0", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}}}},
+		{RouteTableID: "rtb-0main", VpcID: "vpc-0a1", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "0.0.0.0/0", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0a1"}}}},
+	}
+	tgws := []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", Arn: "arn:aws:ec2:eu-west-1:111122223333:transit-gateway/tgw-0a1", Tags: map[string]string{"Name": "core"}}}
+	attachments := []vpc.TransitGatewayAttachmentInfo{
+		{TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0a1", State: "available"},
+		{TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0b2", State: "available"},
+	}
+	peerings := []vpc.VpcPeeringConnectionInfo{{RequesterVpcID: "vpc-0a1", AccepterVpcID: "vpc-0b2", Status: "active"}}
+	opts := testOptions()
+	opts.SubnetTiers = true
+
+	annotations := func(extra map[string]string) map[string]string {
+		all := map[string]string{"aws-documentor/region": "eu-west-1", "aws-documentor/account-id": "111122223333"}
+		for key, value := range extra {
+			all["aws-documentor/"+key] = value
+		}
+		return all
+	}
+	want := []Entity{
+		{APIVersion: APIVersion, Kind: KindResource,
+			Metadata: Metadata{Name: "payments", Title: "payments", Description: "VPC vpc-0a1 (10.1.0.0/16) in eu-west-1",
+				Annotations: annotations(map[string]string{"vpc-id": "vpc-0a1", "arn": "arn:aws:ec2:eu-west-1:111122223333:vpc/vpc-0a1", "cidr-blocks": "10.1.0.0/16,100.64.0.0/16"}),
+				Tags:        []string{"aws", "vpc"}},
+			Spec: Spec{Type: TypeVPC, Owner: "group:default/payments", DependsOn: []string{"resource:core", "resource:shared"}}},
+		{APIVersion: APIVersion, Kind: KindResource,
+			Metadata: Metadata{Name: "shared", Title: "shared", Description: "VPC vpc-0b2 (10.2.0.0/16) in eu-west-1",
+				Annotations: annotations(map[string]string{"vpc-id": "vpc-0b2", "cidr-blocks": "10.2.0.0/16"}),
+				Tags:        []string{"aws", "vpc"}},
+			Spec: Spec{Type: TypeVPC, Owner: "group:platform-networking", DependsOn: []string{"resource:core", "resource:payments"}}},
+		{APIVersion: APIVersion, Kind: KindResource,
+			Metadata: Metadata{Name: "payments-private", Title: "payments private subnets", Description: "2 private subnet(s) of vpc-0a1",
+				Annotations: annotations(map[string]string{"vpc-id": "vpc-0a1", "subnet-tier": "private", "subnet-ids": "subnet-0a1,subnet-0a2", "cidr-blocks": "10.1.1.0/24,10.1.2.0/24"}),
+				Tags:        []string{"aws", "subnet"}},
+			Spec: Spec{Type: TypeSubnetTier, Owner: "group:default/payments", DependsOn: []string{"resource:payments"}}},
+		{APIVersion: APIVersion, Kind: KindResource,
+			Metadata: Metadata{Name: "payments-public", Title: "payments public subnets", Description: "1 public subnet(s) of vpc-0a1",
+				Annotations: annotations(map[string]string{"vpc-id": "vpc-0a1", "subnet-tier": "public", "subnet-ids": "subnet-0a3", "cidr-blocks": "10.1.3.0/24"}),
+				Tags:        []string{"aws", "subnet"}},
+			Spec: Spec{Type: TypeSubnetTier, Owner: "group:default/payments", DependsOn: []string{"resource:payments"}}},
+		{APIVersion: APIVersion, Kind: KindResource,
+			Metadata: Metadata{Name: "core", Title: "core", Description: "Transit gateway tgw-0a1 in eu-west-1",
+				Annotations: annotations(map[string]string{"transit-gateway-id": "tgw-0a1", "arn": "arn:aws:ec2:eu-west-1:111122223333:transit-gateway/tgw-0a1"}),
+				Tags:        []string{"aws", "transit-gateway"}},
+			Spec: Spec{Type: TypeTransitGateway, Owner: "unknown", DependsOn: []string{}}},
+	}
+	catalog := Build(vpcs, subnets, routeTables, tgws, attachments, peerings, opts)
+	if !reflect.DeepEqual(catalog.Entities, want) {
+		t.Errorf("Entities = %+v\nwant %+v", catalog.Entities, want)
+	}
+	if len(catalog.Renames) != 0 {
+		t.Errorf("Renames = %+v, want none", catalog.Renames)
+	}
+
+	opts.SubnetTiers = false
+	opts.AccountID = ""
+	catalog = Build(vpcs, subnets, routeTables, tgws, attachments, peerings, opts)
+	if len(catalog.Entities) != 3 {
+		t.Fatalf("got %d entities without subnet tiers, want 3", len(catalog.Entities))
+	}
+	if _, ok := catalog.Entities[0].Metadata.Annotations["aws-documentor/account-id"]; ok {
+		t.Errorf("annotations without an account = %v, want no account-id", catalog.Entities[0].Metadata.Annotations)
+	}
+	if catalog := Build(nil, nil, nil, nil, nil, nil, opts); catalog.Entities == nil || len(catalog.Entities) != 0 {
+		t.Errorf("Entities of an empty scan = %#v, want an empty list", catalog.Entities)
+	}
+}
+
+// TestOwner covers the owner tag precedence, case-insensitive keys, references kept as they are and the default owner
+func TestOwner(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want string
+	}{
+		{name: "no tags", tags: nil, want: "unknown"},
+		{name: "group name", tags: map[string]string{"Owner": "Payments Team"}, want: "group:payments-team"},
+		{name: "entity reference", tags: map[string]string{"Owner": "user:default/jane.doe"}, want: "user:default/jane.doe"},
+		{name: "case-insensitive key", tags: map[string]string{"OWNER": "payments"}, want: "group:payments"},
+		{name: "first owner tag wins", tags: map[string]string{"team": "platform", "Owner": "payments"}, want: "group:payments"},
+		{name: "second owner tag", tags: map[string]string{"team": "platform", "CostCenter": "cc-1"}, want: "group:platform"},
+		{name: "blank value", tags: map[string]string{"Owner": "  ", "team": "platform"}, want: "group:platform"},
+		{name: "nothing left after sanitizing", tags: map[string]string{"Owner": "✓✓", "team": "platform"}, want: "group:platform"},
+		{name: "keys differing in case", tags: map[string]string{"owner": "second", "Owner": "first", "OWNER": "aaa"}, want: "group:aaa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				if got := testOptions().owner(tt.tags); got != tt.want {
+					t.Fatalf("owner(%v) = %q, want %q", tt.tags, got, tt.want)
+				}
+			}
+		})
+	}
+}
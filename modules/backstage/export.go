@@ -0,0 +1,162 @@
+package backstage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Envelope rules of the Backstage catalog
+var (
+	entityName      = regexp.MustCompile(`^[A-Za-z0-9]+([-_.][A-Za-z0-9]+)*$`)                                // metadata.name, also the name part of an annotation key
+	dnsSubdomain    = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`) // Prefix of an annotation key
+	maxNameLength   = 63                                                                                      // Longest metadata.name and annotation key name
+	maxPrefixLength = 253                                                                                     // Longest annotation key prefix
+)
+
+// Validate checks an entity against the envelope rules the catalog enforces when it ingests a file
+// An entity that breaks them is rejected with the whole file, so Export refuses to write it.
+// Returns: Problems found, empty if the entity is valid
+func Validate(e Entity) []string {
+	var problems []string
+	if e.APIVersion == "" {
+		problems = append(problems, "apiVersion is required")
+	}
+	if e.Kind == "" {
+		problems = append(problems, "kind is required")
+	}
+	if !entityName.MatchString(e.Metadata.Name) || len(e.Metadata.Name) > maxNameLength {
+		problems = append(problems, fmt.Sprintf("metadata.name %q must be 1-%d letters, digits and single -, _ or . between them", e.Metadata.Name, maxNameLength))
+	}
+	for key := range e.Metadata.Annotations {
+		prefix, name, hasPrefix := strings.Cut(key, "/")
+		if !hasPrefix {
+			name = key
+		}
+		if hasPrefix && (!dnsSubdomain.MatchString(prefix) || len(prefix) > maxPrefixLength) {
+			problems = append(problems, fmt.Sprintf("annotation %q: prefix must be a DNS subdomain of at most %d characters", key, maxPrefixLength))
+		}
+		if !entityName.MatchString(name) || len(name) > maxNameLength {
+			problems = append(problems, fmt.Sprintf("annotation %q: name must be 1-%d letters, digits and single -, _ or . between them", key, maxNameLength))
+		}
+	}
+	if e.Kind == KindResource {
+		if e.Spec.Type == "" {
+			problems = append(problems, "spec.type is required")
+		}
+		if e.Spec.Owner == "" {
+			problems = append(problems, "spec.owner is required")
+		}
+	}
+	return problems
+}
+
+// Export writes every entity to a YAML file named after it, plus the Location file listing them
+// Register the Location file (catalog-info.yaml) in the catalog, as a static location or a URL in the
+// repository the directory is committed to. Nothing is written if an entity is invalid.
+// dir: Output directory, created if it does not exist
+// Returns: Paths of the written files, the Location file last, or error if an entity is invalid or a file cannot be written
+func Export(c *Catalog, dir string) ([]string, error) {
+	for _, entity := range c.Entities {
+		if problems := Validate(entity); len(problems) > 0 {
+			return nil, fmt.Errorf("invalid Backstage entity %s: %s", entity.Metadata.Name, strings.Join(problems, "; "))
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create Backstage output directory: %w", err)
+	}
+
+	location := Entity{
+		APIVersion: APIVersion,
+		Kind:       KindLocation,
+		Metadata: Metadata{
+			Name:        "aws-documentor",
+			Description: "Network resources found by aws-documentor",
+		},
+	}
+	var paths []string
+	for _, entity := range c.Entities {
+		file := entity.Metadata.Name + ".yaml"
+		path := filepath.Join(dir, file)
+		entity := entity
+		if err := writeFile(path, func(w io.Writer) error { return WriteEntity(w, entity) }); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		location.Spec.Targets = append(location.Spec.Targets, "./"+file)
+	}
+	path := filepath.Join(dir, LocationFile)
+	if err := writeFile(path, func(w io.Writer) error { return WriteEntity(w, location) }); err != nil {
+		return nil, err
+	}
+	return append(paths, path), nil
+}
+
+// WriteEntity writes an entity as a YAML document
+// Strings are written double-quoted, so tag values such as "yes" or "0123" keep their type.
+// Returns: Error if writing fails
+func WriteEntity(w io.Writer, e Entity) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "apiVersion: %s\n", quote(e.APIVersion))
+	fmt.Fprintf(&b, "kind: %s\n", quote(e.Kind))
+	b.WriteString("metadata:\n")
+	fmt.Fprintf(&b, "  name: %s\n", quote(e.Metadata.Name))
+	if e.Metadata.Title != "" {
+		fmt.Fprintf(&b, "  title: %s\n", quote(e.Metadata.Title))
+	}
+	if e.Metadata.Description != "" {
+		fmt.Fprintf(&b, "  description: %s\n", quote(e.Metadata.Description))
+	}
+	if len(e.Metadata.Annotations) > 0 {
+		b.WriteString("  annotations:\n")
+		for _, key := range sortedKeys(e.Metadata.Annotations) {
+			fmt.Fprintf(&b, "    %s: %s\n", quote(key), quote(e.Metadata.Annotations[key]))
+		}
+	}
+	writeList(&b, "  ", "tags", e.Metadata.Tags)
+	b.WriteString("spec:\n")
+	if e.Spec.Type != "" {
+		fmt.Fprintf(&b, "  type: %s\n", quote(e.Spec.Type))
+	}
+	if e.Spec.Owner != "" {
+		fmt.Fprintf(&b, "  owner: %s\n", quote(e.Spec.Owner))
+	}
+	writeList(&b, "  ", "dependsOn", e.Spec.DependsOn)
+	writeList(&b, "  ", "targets", e.Spec.Targets)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeList writes a block sequence, or nothing for an empty list
+func writeList(b *strings.Builder, indent, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s%s:\n", indent, key)
+	for _, value := range values {
+		fmt.Fprintf(b, "%s  - %s\n", indent, quote(value))
+	}
+}
+
+// quote returns a string as a double-quoted scalar; JSON strings are valid YAML
+func quote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// writeFile creates a file and fills it with write
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,140 @@
+package backstage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validEntity returns a Resource entity that passes Validate
+func validEntity() Entity {
+	return Entity{
+		APIVersion: APIVersion,
+		Kind:       KindResource,
+		Metadata:   Metadata{Name: "payments", Annotations: map[string]string{"aws-documentor/vpc-id": "vpc-0a1"}},
+		Spec:       Spec{Type: TypeVPC, Owner: "group:payments"},
+	}
+}
+
+// TestValidate covers the envelope rules of names, annotation keys and Resource specs
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(e *Entity)
+		want   []string
+	}{
+		{name: "valid", change: func(e *Entity) {}},
+		{name: "dots and underscores between alphanumerics", change: func(e *Entity) { e.Metadata.Name = "prod_eu.payments-1" }},
+		{name: "63 characters", change: func(e *Entity) { e.Metadata.Name = strings.Repeat("a", 63) }},
+		{name: "64 characters", change: func(e *Entity) { e.Metadata.Name = strings.Repeat("a", 64) },
+			want: []string{`metadata.name "` + strings.Repeat("a", 64) + `" must be 1-63 letters, digits and single -, _ or . between them`}},
+		{name: "empty name", change: func(e *Entity) { e.Metadata.Name = "" },
+			want: []string{`metadata.name "" must be 1-63 letters, digits and single -, _ or . between them`}},
+		{name: "double separator", change: func(e *Entity) { e.Metadata.Name = "prod--eu" },
+			want: []string{`metadata.name "prod--eu" must be 1-63 letters, digits and single -, _ or . between them`}},
+		{name: "trailing separator", change: func(e *Entity) { e.Metadata.Name = "prod-" },
+			want: []string{`metadata.name "prod-" must be 1-63 letters, digits and single -, _ or . between them`}},
+		{name: "annotation without a prefix", change: func(e *Entity) { e.Metadata.Annotations = map[string]string{"vpc-id": "vpc-0a1"} }},
+		{name: "annotation prefix with capitals", change: func(e *Entity) { e.Metadata.Annotations = map[string]string{"AWS/vpc-id": "vpc-0a1"} },
+			want: []string{`annotation "AWS/vpc-id": prefix must be a DNS subdomain of at most 253 characters`}},
+		{name: "annotation name with a space", change: func(e *Entity) { e.Metadata.Annotations = map[string]string{"aws-documentor/vpc id": "vpc-0a1"} },
+			want: []string{`annotation "aws-documentor/vpc id": name must be 1-63 letters, digits and single -, _ or . between them`}},
+		{name: "missing envelope and spec", change: func(e *Entity) { e.APIVersion, e.Kind, e.Spec = "", KindResource, Spec{} },
+			want: []string{"apiVersion is required", "spec.type is required", "spec.owner is required"}},
+		{name: "Location without type and owner", change: func(e *Entity) { e.Kind, e.Spec = KindLocation, Spec{Targets: []string{"./payments.yaml"}} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entity := validEntity()
+			tt.change(&entity)
+			if got := Validate(entity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteEntity checks the YAML of an entity: quoted scalars, sorted annotations and empty blocks left out
+func TestWriteEntity(t *testing.T) {
+	entity := Entity{
+		APIVersion: APIVersion,
+		Kind:       KindResource,
+		Metadata: Metadata{Name: "payments", Title: `Payments "EU"`,
+			Annotations: map[string]string{"aws-documentor/vpc-id": "vpc-0a1", "aws-documentor/account-id": "0123"},
+			Tags:        []string{"aws", "vpc"}},
+		Spec: Spec{Type: TypeVPC, Owner: "group:payments", DependsOn: []string{}},
+	}
+	var b strings.Builder
+	if err := WriteEntity(&b, entity); err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: "backstage.io/v1alpha1"
+kind: "Resource"
+metadata:
+  name: "payments"
+  title: "Payments \"EU\""
+  annotations:
+    "aws-documentor/account-id": "0123"
+    "aws-documentor/vpc-id": "vpc-0a1"
+  tags:
+    - "aws"
+    - "vpc"
+spec:
+  type: "vpc"
+  owner: "group:payments"
+`
+	if b.String() != want {
+		t.Errorf("WriteEntity() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+// TestExport checks that every entity gets a file listed by the Location file, and that nothing is
+// written when an entity is invalid
+func TestExport(t *testing.T) {
+	payments := validEntity()
+	shared := validEntity()
+	shared.Metadata.Name = "shared"
+	shared.Spec.DependsOn = []string{"resource:payments"}
+	dir := filepath.Join(t.TempDir(), "catalog")
+
+	paths, err := Export(&Catalog{Entities: []Entity{payments, shared}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPaths := []string{filepath.Join(dir, "payments.yaml"), filepath.Join(dir, "shared.yaml"), filepath.Join(dir, LocationFile)}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Export() = %v, want %v", paths, wantPaths)
+	}
+	location, err := os.ReadFile(filepath.Join(dir, LocationFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLocation := `apiVersion: "backstage.io/v1alpha1"
+kind: "Location"
+metadata:
+  name: "aws-documentor"
+  description: "Network resources found by aws-documentor"
+spec:
+  targets:
+    - "./payments.yaml"
+    - "./shared.yaml"
+`
+	if string(location) != wantLocation {
+		t.Errorf("%s =\n%s\nwant\n%s", LocationFile, location, wantLocation)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "shared.yaml")); err != nil || !strings.Contains(string(content), "  dependsOn:\n    - \"resource:payments\"\n") {
+		t.Errorf("shared.yaml = %s (%v), want a dependsOn list", content, err)
+	}
+
+	invalid := validEntity()
+	invalid.Metadata.Name = "bad--name"
+	invalidDir := filepath.Join(t.TempDir(), "invalid")
+	if _, err := Export(&Catalog{Entities: []Entity{payments, invalid}}, invalidDir); err == nil || !strings.Contains(err.Error(), "invalid Backstage entity bad--name") {
+		t.Errorf("Export() with an invalid entity: error = %v", err)
+	}
+	if _, err := os.Stat(invalidDir); !os.IsNotExist(err) {
+		t.Errorf("output directory of an invalid catalog exists (%v), want nothing written", err)
+	}
+}
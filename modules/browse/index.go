@@ -0,0 +1,244 @@
+// Package browse provides the model of the read-only terminal browser for scan results
+// The model is built from the report structs and the graph's relationships; rendering is kept in view.go.
+package browse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/graph"
+	"aws-documentor/modules/vpc"
+)
+
+// Resource kinds of the browser
+const (
+	KindVPC             = "vpc"
+	KindSubnet          = "subnet"
+	KindRouteTable      = "route-table"
+	KindSecurityGroup   = "security-group"
+	KindInternetGateway = "internet-gateway"
+	KindNatGateway      = "nat-gateway"
+	KindTransitGateway  = "transit-gateway"
+)
+
+// Report contains the sections of a scan the browser shows
+type Report struct {
+	VPCs             []vpc.VPCInfo                      // VPCs of the scan
+	Subnets          []vpc.SubnetInfo                   // Subnets of the scan
+	RouteTables      []vpc.RouteTableInfo               // Route tables of the scan
+	SecurityGroups   []vpc.SecurityGroupInfo            // Security groups of the scan
+	InternetGateways []vpc.InternetGatewayInfo          // Internet gateways of the scan
+	NatGateways      []vpc.NatGatewayInfo               // NAT gateways of the scan
+	TransitGateways  []vpc.TransitGatewayInfo           // Transit gateways of the scan
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo // Transit gateway attachments, nil when loaded from a report
+}
+
+// Reference is a relationship of a resource to another resource
+type Reference struct {
+	Outgoing bool   // Whether the relationship starts at the resource (it routes to, contains or allows the other)
+	Type     string // Relationship type from the graph (CONTAINS, ROUTES_TO, ...)
+	ID       string // ID of the other resource
+}
+
+// String formats the reference as shown in the detail view
+func (r Reference) String() string {
+	if r.Outgoing {
+		return fmt.Sprintf("-%s-> %s", r.Type, r.ID)
+	}
+	return fmt.Sprintf("<-%s- %s", r.Type, r.ID)
+}
+
+// Item is one browsable resource
+type Item struct {
+	Kind       string      // One of the Kind* constants
+	ID         string      // AWS resource ID
+	Name       string      // Name tag, or group name for security groups
+	VpcIDs     []string    // VPCs the resource belongs to (several for transit gateways)
+	CIDRs      []string    // CIDR blocks of VPCs and subnets
+	Resource   interface{} // The report struct of the resource, exported as is
+	References []Reference // Relationships in both directions, outgoing first
+	Findings   []string    // Findings of the analyses that concern the resource
+}
+
+// Label returns the ID with the name, as listed in the panes
+func (i *Item) Label() string {
+	if i.Name == "" {
+		return i.ID
+	}
+	return i.ID + " " + i.Name
+}
+
+// Index holds the items of a report with their references
+type Index struct {
+	Items []*Item          // All items in report order
+	byID  map[string]*Item // Items by resource ID
+}
+
+// NewIndex builds the items of a report, their references from the graph and their findings
+// report: Sections of the scan
+// Returns: Index of every resource in the report
+func NewIndex(report *Report) *Index {
+	idx := &Index{byID: make(map[string]*Item)}
+
+	for i := range report.VPCs {
+		v := &report.VPCs[i]
+		idx.add(&Item{Kind: KindVPC, ID: v.VpcID, Name: v.Tags["Name"], VpcIDs: []string{v.VpcID}, CIDRs: append(append([]string{}, v.AssociateCidrBlocks...), v.Ipv6CidrBlocks...), Resource: v})
+	}
+	for i := range report.Subnets {
+		s := &report.Subnets[i]
+		idx.add(&Item{Kind: KindSubnet, ID: s.SubnetID, Name: s.Tags["Name"], VpcIDs: []string{s.VpcID}, CIDRs: []string{s.CidrBlock}, Resource: s})
+	}
+	for i := range report.RouteTables {
+		rt := &report.RouteTables[i]
+		idx.add(&Item{Kind: KindRouteTable, ID: rt.RouteTableID, Name: rt.Tags["Name"], VpcIDs: []string{rt.VpcID}, Resource: rt})
+	}
+	for i := range report.SecurityGroups {
+		sg := &report.SecurityGroups[i]
+		idx.add(&Item{Kind: KindSecurityGroup, ID: sg.GroupID, Name: sg.GroupName, VpcIDs: []string{sg.VpcID}, Resource: sg})
+	}
+	for i := range report.InternetGateways {
+		igw := &report.InternetGateways[i]
+		idx.add(&Item{Kind: KindInternetGateway, ID: igw.InternetGatewayID, Name: igw.Tags["Name"], VpcIDs: []string{igw.VpcID}, Resource: igw})
+	}
+	for i := range report.NatGateways {
+		nat := &report.NatGateways[i]
+		idx.add(&Item{Kind: KindNatGateway, ID: nat.NatGatewayID, Name: nat.Tags["Name"], VpcIDs: []string{nat.VpcID}, Resource: nat})
+	}
+	tgwVPCs := make(map[string][]string)
+	for _, attachment := range report.TGWAttachments {
+		if attachment.ResourceType == "vpc" {
+			tgwVPCs[attachment.TransitGatewayID] = append(tgwVPCs[attachment.TransitGatewayID], attachment.ResourceID)
+		}
+	}
+	for i := range report.TransitGateways {
+		tgw := &report.TransitGateways[i]
+		idx.add(&Item{Kind: KindTransitGateway, ID: tgw.TransitGatewayID, Name: tgw.Tags["Name"], VpcIDs: tgwVPCs[tgw.TransitGatewayID], Resource: tgw})
+	}
+
+	// The graph already derives every relationship; each edge is a reference of both of its ends
+	g := graph.Build(report.VPCs, report.Subnets, report.RouteTables, report.SecurityGroups,
+		report.InternetGateways, report.NatGateways, report.TransitGateways, report.TGWAttachments)
+	for _, edge := range g.Edges {
+		if from := idx.byID[edge.From]; from != nil {
+			from.References = append(from.References, Reference{Outgoing: true, Type: edge.Type, ID: edge.To})
+		}
+		if to := idx.byID[edge.To]; to != nil {
+			to.References = append(to.References, Reference{Outgoing: false, Type: edge.Type, ID: edge.From})
+		}
+	}
+	for _, item := range idx.Items {
+		sort.SliceStable(item.References, func(i, j int) bool {
+			return item.References[i].Outgoing && !item.References[j].Outgoing
+		})
+	}
+
+	for _, finding := range analysis.AnalyzeSecurityGroupReferences(report.SecurityGroups, report.RouteTables).Findings {
+		idx.addFinding(finding.GroupID, fmt.Sprintf("%s: rule references %s: %s", finding.Classification, finding.ReferencedGroupID, finding.Reason))
+	}
+	for _, finding := range analysis.AnalyzeRedundantRules(report.SecurityGroups).Findings {
+		idx.addFinding(finding.GroupID, fmt.Sprintf("%s rule %s: %s", finding.Kind, finding.Rule, finding.Reason))
+	}
+	return idx
+}
+
+// add appends an item, keeping the first item of a duplicate ID in the lookup
+func (idx *Index) add(item *Item) {
+	idx.Items = append(idx.Items, item)
+	if _, ok := idx.byID[item.ID]; !ok {
+		idx.byID[item.ID] = item
+	}
+}
+
+// addFinding attaches a finding to the item with the given ID, if it is in the report
+func (idx *Index) addFinding(id, finding string) {
+	if item := idx.byID[id]; item != nil {
+		item.Findings = append(item.Findings, finding)
+	}
+}
+
+// Get returns the item with the given resource ID, or nil
+func (idx *Index) Get(id string) *Item {
+	return idx.byID[id]
+}
+
+// ByKind returns the items of the given kinds that belong to a VPC, in report order
+// vpcID: VPC the items belong to
+// kinds: Kinds to include
+func (idx *Index) ByKind(vpcID string, kinds ...string) []*Item {
+	var items []*Item
+	for _, item := range idx.Items {
+		if !containsString(kinds, item.Kind) || !containsString(item.VpcIDs, vpcID) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Search returns the items whose ID, name or CIDR blocks fuzzy-match the query, best matches first
+// A query matches a text when its characters appear in the text in order, ignoring case; matches
+// with the characters closer together and earlier in the text rank higher.
+// query: Search text typed by the user
+// Returns: Matching items, all items for an empty query
+func (idx *Index) Search(query string) []*Item {
+	if query == "" {
+		return append([]*Item{}, idx.Items...)
+	}
+
+	type match struct {
+		item  *Item
+		score int
+	}
+	var matches []match
+	for _, item := range idx.Items {
+		best := -1
+		for _, text := range append([]string{item.ID, item.Name}, item.CIDRs...) {
+			if score, ok := fuzzyScore(query, text); ok && (best < 0 || score < best) {
+				best = score
+			}
+		}
+		if best >= 0 {
+			matches = append(matches, match{item, best})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+
+	items := make([]*Item, len(matches))
+	for i, m := range matches {
+		items[i] = m.item
+	}
+	return items
+}
+
+// fuzzyScore matches the characters of query in order in text
+// Returns: Score weighting the span of the match over its start (lower is better), and whether text matches
+func fuzzyScore(query, text string) (int, bool) {
+	query, text = strings.ToLower(query), strings.ToLower(text)
+	if query == "" || text == "" {
+		return 0, false
+	}
+	start, pos := -1, 0
+	for _, r := range query {
+		i := strings.IndexRune(text[pos:], r)
+		if i < 0 {
+			return 0, false
+		}
+		if start < 0 {
+			start = pos + i
+		}
+		pos += i + len(string(r))
+	}
+	return 4*(pos-start) + start, true
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package browse
+
+import (
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// testReport is a scan of two VPCs on one transit gateway: vpc-0a1 has two subnets, a public route table,
+// an internet and a NAT gateway and a web security group with a shadowed rule and a rule referencing a
+// deleted group; vpc-0b2 has one subnet
+func testReport() *Report {
+	return &Report{
+		VPCs: []vpc.VPCInfo{
+			{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", AssociateCidrBlocks: []string{"10.0.0.0/16"}, Tags: map[string]string{"Name": "prod"}},
+			{VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/16", AssociateCidrBlocks: []string{"10.1.0.0/16"}, Ipv6CidrBlocks: []string{"2001:db8:1::/56"}, Tags: map[string]string{"Name": "dev"}},
+		},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", Tags: map[string]string{"Name": "public-a"}},
+			{SubnetID: "subnet-0a2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24", Tags: map[string]string{"Name": "private-a"}},
+			{SubnetID: "subnet-0b1", VpcID: "vpc-0b2", CidrBlock: "10.1.1.0/24"},
+		},
+		RouteTables: []vpc.RouteTableInfo{
+			{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a1"}, Tags: map[string]string{"Name": "public"}, Routes: []vpc.RouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}, State: "active"},
+			}},
+		},
+		SecurityGroups: []vpc.SecurityGroupInfo{
+			{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+				{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"},
+				{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "10.0.0.0/16"},
+				{IpProtocol: "tcp", FromPort: 8080, ToPort: 8080, GroupID: "sg-0gone"},
+			}},
+		},
+		InternetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1"}},
+		NatGateways:      []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", SubnetID: "subnet-0a1", VpcID: "vpc-0a1"}},
+		TransitGateways:  []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", Tags: map[string]string{"Name": "core"}}},
+		TGWAttachments: []vpc.TransitGatewayAttachmentInfo{
+			{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0a1"},
+			{AttachmentID: "tgw-attach-0b2", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0b2"},
+		},
+	}
+}
+
+// itemIDs returns the IDs of items
+func itemIDs(items []*Item) []string {
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+// TestNewIndex checks the items of every kind, their references in both directions with outgoing ones
+// first, and the findings attached to security groups
+func TestNewIndex(t *testing.T) {
+	idx := NewIndex(testReport())
+
+	wantIDs := []string{"vpc-0a1", "vpc-0b2", "subnet-0a1", "subnet-0a2", "subnet-0b1", "rtb-0a1", "sg-0web", "igw-0a1", "nat-0a1", "tgw-0a1"}
+	if got := itemIDs(idx.Items); !reflect.DeepEqual(got, wantIDs) {
+		t.Errorf("items = %v, want %v", got, wantIDs)
+	}
+	if item := idx.Get("tgw-0a1"); item == nil || !reflect.DeepEqual(item.VpcIDs, []string{"vpc-0a1", "vpc-0b2"}) || item.Label() != "tgw-0a1 core" {
+		t.Errorf("transit gateway = %+v, want both VPCs and the label with its name", item)
+	}
+	if item := idx.Get("subnet-0b1"); item == nil || item.Label() != "subnet-0b1" {
+		t.Errorf("unnamed subnet = %+v, want the ID as label", item)
+	}
+	if idx.Get("sg-0gone") != nil {
+		t.Error("Get() of a resource outside the report returned an item")
+	}
+
+	wantReferences := []Reference{
+		{Outgoing: true, Type: "CONTAINS", ID: "nat-0a1"},
+		{Outgoing: false, Type: "CONTAINS", ID: "vpc-0a1"},
+		{Outgoing: false, Type: "ATTACHED_TO", ID: "rtb-0a1"},
+	}
+	if got := idx.Get("subnet-0a1").References; !reflect.DeepEqual(got, wantReferences) {
+		t.Errorf("subnet references = %v\nwant %v", got, wantReferences)
+	}
+	if got, want := wantReferences[0].String()+" "+wantReferences[1].String(), "-CONTAINS-> nat-0a1 <-CONTAINS- vpc-0a1"; got != want {
+		t.Errorf("references formatted = %q, want %q", got, want)
+	}
+
+	findings := idx.Get("sg-0web").Findings
+	if len(findings) != 2 {
+		t.Fatalf("security group findings = %q, want the dangling reference and the shadowed rule", findings)
+	}
+	if len(idx.Get("vpc-0a1").Findings) != 0 {
+		t.Errorf("VPC findings = %q, want none", idx.Get("vpc-0a1").Findings)
+	}
+}
+
+// TestByKind checks that the tabs list the resources of one VPC only, with transit gateways in every VPC
+// they are attached to
+func TestByKind(t *testing.T) {
+	idx := NewIndex(testReport())
+	tests := []struct {
+		vpcID string
+		kinds []string
+		want  []string
+	}{
+		{vpcID: "vpc-0a1", kinds: []string{KindSubnet}, want: []string{"subnet-0a1", "subnet-0a2"}},
+		{vpcID: "vpc-0b2", kinds: []string{KindSubnet}, want: []string{"subnet-0b1"}},
+		{vpcID: "vpc-0a1", kinds: Tabs[3].Kinds, want: []string{"igw-0a1", "nat-0a1", "tgw-0a1"}},
+		{vpcID: "vpc-0b2", kinds: Tabs[3].Kinds, want: []string{"tgw-0a1"}},
+		{vpcID: "vpc-0b2", kinds: []string{KindSecurityGroup}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.vpcID+" "+tt.kinds[0], func(t *testing.T) {
+			if got := itemIDs(idx.ByKind(tt.vpcID, tt.kinds...)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ByKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSearch checks the fuzzy search across IDs, names and CIDR blocks and the ranking of tighter matches
+func TestSearch(t *testing.T) {
+	idx := NewIndex(testReport())
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "0a2", want: []string{"subnet-0a2"}},
+		{query: "PRIVATE", want: []string{"subnet-0a2"}},
+		{query: "10.1.1", want: []string{"subnet-0b1", "vpc-0b2"}},
+		{query: "2001:db8", want: []string{"vpc-0b2"}},
+		{query: "web", want: []string{"sg-0web"}},
+		{query: "sbnt0b", want: []string{"subnet-0b1"}},
+		{query: "nothing-matches", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := itemIDs(idx.Search(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+	if got := idx.Search(""); len(got) != len(idx.Items) {
+		t.Errorf("Search(\"\") returned %d items, want all %d", len(got), len(idx.Items))
+	}
+}
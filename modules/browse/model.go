@@ -0,0 +1,276 @@
+package browse
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Tabs of the right pane, each listing some resource kinds of the selected VPC
+var Tabs = []struct {
+	Title string   // Tab title
+	Kinds []string // Kinds listed on the tab
+}{
+	{"Subnets", []string{KindSubnet}},
+	{"Route tables", []string{KindRouteTable}},
+	{"Security groups", []string{KindSecurityGroup}},
+	{"Gateways", []string{KindInternetGateway, KindNatGateway, KindTransitGateway}},
+}
+
+// Pane is the part of the screen that receives the navigation keys
+type Pane int
+
+// Panes of the browser
+const (
+	PaneVPCs      Pane = iota // The VPC list on the left
+	PaneResources             // The tabbed resource list on the right
+	PaneSearch                // The search results replacing both lists
+	PaneDetail                // The detail view of one resource
+)
+
+// Model is the state of the browser
+// Update only changes the state and returns commands for side effects, so the navigation can be
+// driven with key messages without a terminal.
+type Model struct {
+	Index     *Index  // Resources of the report
+	VPCs      []*Item // Items of the left pane
+	VPCCursor int     // Selected VPC
+	Tab       int     // Selected tab of the right pane
+	Cursor    int     // Selected resource of the right pane
+	Focus     Pane    // Pane receiving the navigation keys
+	Query     string  // Search text
+	Results   []*Item // Search results for Query
+	Result    int     // Selected search result
+	Detail    *Item   // Resource shown in the detail view (PaneDetail)
+	back      Pane    // Pane to return to from the detail view
+	Status    string  // Message of the last export
+	Width     int     // Terminal width
+	Height    int     // Terminal height
+
+	ExportDir string    // Directory "e" writes <id>.json files to
+	Clipboard io.Writer // Terminal that "y" sends the OSC 52 clipboard sequence to
+}
+
+// NewModel creates the browser state for an index, with the first VPC selected
+// index: Resources of the report
+func NewModel(index *Index) *Model {
+	m := &Model{Index: index, ExportDir: ".", Clipboard: os.Stdout}
+	for _, item := range index.Items {
+		if item.Kind == KindVPC {
+			m.VPCs = append(m.VPCs, item)
+		}
+	}
+	return m
+}
+
+// statusMsg reports the outcome of an export command
+type statusMsg string
+
+// Init implements tea.Model; the browser needs no startup command
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Width, m.Height = msg.Width, msg.Height
+	case statusMsg:
+		m.Status = string(msg)
+	case tea.KeyMsg:
+		return m, m.handleKey(msg.String())
+	}
+	return m, nil
+}
+
+// handleKey applies one key press
+// Returns: Command for quitting or exporting, or nil
+func (m *Model) handleKey(key string) tea.Cmd {
+	if key == "ctrl+c" {
+		return tea.Quit
+	}
+	if m.Focus == PaneSearch {
+		return m.handleSearchKey(key)
+	}
+
+	switch key {
+	case "q":
+		return tea.Quit
+	case "/":
+		m.Focus, m.Query, m.Result = PaneSearch, "", 0
+		m.Results = m.Index.Search("")
+	case "esc":
+		if m.Focus == PaneDetail {
+			m.Focus = m.back
+		}
+	case "tab":
+		m.Tab, m.Cursor = (m.Tab+1)%len(Tabs), 0
+	case "shift+tab":
+		m.Tab, m.Cursor = (m.Tab+len(Tabs)-1)%len(Tabs), 0
+	case "left", "h":
+		if m.Focus == PaneResources {
+			m.Focus = PaneVPCs
+		}
+	case "right", "l":
+		if m.Focus == PaneVPCs {
+			m.Focus = PaneResources
+		}
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "enter":
+		switch m.Focus {
+		case PaneVPCs:
+			m.Focus, m.Cursor = PaneResources, 0
+		case PaneResources:
+			m.openDetail(m.Selected())
+		}
+	case "e":
+		return m.exportFile(m.Selected())
+	case "y":
+		return m.exportClipboard(m.Selected())
+	}
+	return nil
+}
+
+// handleSearchKey applies a key press while the search is open
+func (m *Model) handleSearchKey(key string) tea.Cmd {
+	switch key {
+	case "esc":
+		m.Focus = PaneVPCs
+	case "enter":
+		if m.Result < len(m.Results) {
+			m.openDetail(m.Results[m.Result])
+		}
+	case "up":
+		m.move(-1)
+	case "down":
+		m.move(1)
+	case "backspace":
+		if m.Query != "" {
+			runes := []rune(m.Query)
+			m.setQuery(string(runes[:len(runes)-1]))
+		}
+	default:
+		if len([]rune(key)) == 1 {
+			m.setQuery(m.Query + key)
+		}
+	}
+	return nil
+}
+
+// setQuery updates the search text and its results
+func (m *Model) setQuery(query string) {
+	m.Query, m.Result = query, 0
+	m.Results = m.Index.Search(query)
+}
+
+// move moves the cursor of the focused pane, staying within its list
+func (m *Model) move(delta int) {
+	clamp := func(i, n int) int {
+		if i >= n {
+			i = n - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		return i
+	}
+	switch m.Focus {
+	case PaneVPCs:
+		m.VPCCursor, m.Cursor = clamp(m.VPCCursor+delta, len(m.VPCs)), 0
+	case PaneResources:
+		m.Cursor = clamp(m.Cursor+delta, len(m.Resources()))
+	case PaneSearch:
+		m.Result = clamp(m.Result+delta, len(m.Results))
+	}
+}
+
+// openDetail shows an item in the detail view; closing it returns to the focused pane (including the search results)
+func (m *Model) openDetail(item *Item) {
+	if item == nil {
+		return
+	}
+	m.back, m.Detail, m.Focus = m.Focus, item, PaneDetail
+}
+
+// SelectedVPC returns the VPC selected in the left pane, or nil if the report has none
+func (m *Model) SelectedVPC() *Item {
+	if m.VPCCursor >= len(m.VPCs) {
+		return nil
+	}
+	return m.VPCs[m.VPCCursor]
+}
+
+// Resources returns the items of the selected tab in the selected VPC
+func (m *Model) Resources() []*Item {
+	v := m.SelectedVPC()
+	if v == nil {
+		return nil
+	}
+	return m.Index.ByKind(v.ID, Tabs[m.Tab].Kinds...)
+}
+
+// Selected returns the item the keys e, y and enter act on: the detail item, the search result,
+// the resource under the cursor or the selected VPC
+func (m *Model) Selected() *Item {
+	switch m.Focus {
+	case PaneDetail:
+		return m.Detail
+	case PaneSearch:
+		if m.Result < len(m.Results) {
+			return m.Results[m.Result]
+		}
+		return nil
+	case PaneResources:
+		if resources := m.Resources(); m.Cursor < len(resources) {
+			return resources[m.Cursor]
+		}
+		return nil
+	default:
+		return m.SelectedVPC()
+	}
+}
+
+// exportFile returns a command writing the resource of an item to <id>.json in ExportDir
+func (m *Model) exportFile(item *Item) tea.Cmd {
+	if item == nil {
+		return nil
+	}
+	dir := m.ExportDir
+	return func() tea.Msg {
+		data, err := json.MarshalIndent(item.Resource, "", "  ")
+		if err != nil {
+			return statusMsg(fmt.Sprintf("export failed: %v", err))
+		}
+		path := filepath.Join(dir, item.ID+".json")
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			return statusMsg(fmt.Sprintf("export failed: %v", err))
+		}
+		return statusMsg("exported " + path)
+	}
+}
+
+// exportClipboard returns a command copying the resource of an item to the clipboard
+// It uses the OSC 52 escape sequence, which terminals (including over SSH) turn into a clipboard write.
+func (m *Model) exportClipboard(item *Item) tea.Cmd {
+	if item == nil || m.Clipboard == nil {
+		return nil
+	}
+	w := m.Clipboard
+	return func() tea.Msg {
+		data, err := json.MarshalIndent(item.Resource, "", "  ")
+		if err != nil {
+			return statusMsg(fmt.Sprintf("copy failed: %v", err))
+		}
+		fmt.Fprintf(w, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString(data))
+		return statusMsg("copied " + item.ID + " to the clipboard")
+	}
+}
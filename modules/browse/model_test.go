@@ -0,0 +1,182 @@
+package browse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyMsg returns the message bubbletea sends for a key, named as tea.KeyMsg.String names it
+func keyMsg(key string) tea.KeyMsg {
+	switch key {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "shift+tab":
+		return tea.KeyMsg{Type: tea.KeyShiftTab}
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "left":
+		return tea.KeyMsg{Type: tea.KeyLeft}
+	case "right":
+		return tea.KeyMsg{Type: tea.KeyRight}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	case "ctrl+c":
+		return tea.KeyMsg{Type: tea.KeyCtrlC}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// press sends keys to a model and returns the command of the last one
+func press(m *Model, keys ...string) tea.Cmd {
+	var cmd tea.Cmd
+	for _, key := range keys {
+		_, cmd = m.Update(keyMsg(key))
+	}
+	return cmd
+}
+
+// TestNavigation drives the model with key sequences and checks the focused pane and the selected resource
+func TestNavigation(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		wantFocus Pane
+		wantTab   int
+		wantID    string // ID of Selected(), empty for none
+	}{
+		{name: "start", wantFocus: PaneVPCs, wantID: "vpc-0a1"},
+		{name: "next VPC", keys: []string{"j"}, wantFocus: PaneVPCs, wantID: "vpc-0b2"},
+		{name: "cursor stays in the list", keys: []string{"down", "down", "down", "k", "k"}, wantFocus: PaneVPCs, wantID: "vpc-0a1"},
+		{name: "enter opens the subnets", keys: []string{"enter"}, wantFocus: PaneResources, wantID: "subnet-0a1"},
+		{name: "second subnet", keys: []string{"enter", "down"}, wantFocus: PaneResources, wantID: "subnet-0a2"},
+		{name: "tab resets the cursor", keys: []string{"right", "down", "tab"}, wantFocus: PaneResources, wantTab: 1, wantID: "rtb-0a1"},
+		{name: "gateways of the second VPC", keys: []string{"j", "shift+tab", "l"}, wantFocus: PaneResources, wantTab: 3, wantID: "tgw-0a1"},
+		{name: "tab without resources", keys: []string{"j", "tab", "tab", "l"}, wantFocus: PaneResources, wantTab: 2},
+		{name: "back to the VPCs", keys: []string{"enter", "left", "down"}, wantFocus: PaneVPCs, wantID: "vpc-0b2"},
+		{name: "detail", keys: []string{"enter", "down", "enter"}, wantFocus: PaneDetail, wantID: "subnet-0a2"},
+		{name: "detail closed", keys: []string{"enter", "down", "enter", "esc"}, wantFocus: PaneResources, wantID: "subnet-0a2"},
+		{name: "search", keys: []string{"/", "n", "a", "t"}, wantFocus: PaneSearch, wantID: "nat-0a1"},
+		{name: "search keys are typed", keys: []string{"/", "q", "j", "backspace", "backspace"}, wantFocus: PaneSearch, wantID: "vpc-0a1"},
+		{name: "search result detail", keys: []string{"/", "0", "b", "down", "enter"}, wantFocus: PaneDetail, wantID: "subnet-0b1"},
+		{name: "search cancelled", keys: []string{"/", "w", "e", "b", "esc"}, wantFocus: PaneVPCs, wantID: "vpc-0a1"},
+		{name: "no search result", keys: []string{"/", "x", "y", "z", "enter"}, wantFocus: PaneSearch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewModel(NewIndex(testReport()))
+			press(m, tt.keys...)
+			if m.Focus != tt.wantFocus || m.Tab != tt.wantTab {
+				t.Errorf("focus %d on tab %d, want %d on tab %d", m.Focus, m.Tab, tt.wantFocus, tt.wantTab)
+			}
+			gotID := ""
+			if item := m.Selected(); item != nil {
+				gotID = item.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("Selected() = %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+// TestSearchDetailReturnsToResults checks that closing the detail of a search result goes back to the results
+func TestSearchDetailReturnsToResults(t *testing.T) {
+	m := NewModel(NewIndex(testReport()))
+	press(m, "/", "0", "b", "down", "enter", "esc")
+	if m.Focus != PaneSearch || m.Query != "0b" || m.Selected() == nil || m.Selected().ID != "subnet-0b1" {
+		t.Errorf("focus %d, query %q, selected %v; want the search results for 0b with subnet-0b1 selected", m.Focus, m.Query, m.Selected())
+	}
+}
+
+// TestQuit checks that q quits outside the search and ctrl+c everywhere
+func TestQuit(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		wantQuit bool
+	}{
+		{name: "q", keys: []string{"q"}, wantQuit: true},
+		{name: "q in the search", keys: []string{"/", "q"}},
+		{name: "ctrl+c in the search", keys: []string{"/", "ctrl+c"}, wantQuit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := press(NewModel(NewIndex(testReport())), tt.keys...)
+			quit := false
+			if cmd != nil {
+				_, quit = cmd().(tea.QuitMsg)
+			}
+			if quit != tt.wantQuit {
+				t.Errorf("quit = %v, want %v", quit, tt.wantQuit)
+			}
+		})
+	}
+}
+
+// TestExport checks that e writes the selected resource to a file and y sends it to the clipboard, and
+// that the outcome is shown as the status
+func TestExport(t *testing.T) {
+	var clipboard bytes.Buffer
+	m := NewModel(NewIndex(testReport()))
+	m.ExportDir, m.Clipboard = t.TempDir(), &clipboard
+
+	press(m, "enter", "tab", "tab")
+	m.Update(press(m, "e")())
+	path := filepath.Join(m.ExportDir, "sg-0web.json")
+	if m.Status != "exported "+path {
+		t.Errorf("status = %q, want exported %s", m.Status, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported map[string]interface{}
+	if err := json.Unmarshal(data, &exported); err != nil || exported["group_id"] != "sg-0web" {
+		t.Errorf("exported %s, want the security group as JSON", data)
+	}
+
+	m.Update(press(m, "y")())
+	if m.Status != "copied sg-0web to the clipboard" {
+		t.Errorf("status = %q, want the clipboard copy", m.Status)
+	}
+	sequence := clipboard.String()
+	if !strings.HasPrefix(sequence, "\x1b]52;c;") || !strings.HasSuffix(sequence, "\x07") {
+		t.Fatalf("clipboard = %q, want an OSC 52 sequence", sequence)
+	}
+	copied, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(sequence, "\x1b]52;c;"), "\x07"))
+	if err != nil || string(copied)+"\n" != string(data) {
+		t.Errorf("clipboard holds %q, want the exported JSON", copied)
+	}
+
+	m.ExportDir = filepath.Join(m.ExportDir, "missing")
+	m.Update(press(m, "e")())
+	if !strings.HasPrefix(m.Status, "export failed: ") {
+		t.Errorf("status = %q, want the export to fail", m.Status)
+	}
+}
+
+// TestEmptyReport checks that a report without VPCs can be navigated without selecting anything
+func TestEmptyReport(t *testing.T) {
+	m := NewModel(NewIndex(&Report{}))
+	for _, key := range []string{"down", "enter", "right", "down", "enter", "tab"} {
+		if cmd := press(m, key); cmd != nil {
+			t.Errorf("key %s returned a command", key)
+		}
+	}
+	if m.Selected() != nil || press(m, "e") != nil || press(m, "y") != nil {
+		t.Error("an empty report has a selection to export")
+	}
+}
@@ -0,0 +1,179 @@
+package browse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Layout of the screen
+const (
+	vpcPaneWidth  = 40 // Width of the VPC list
+	defaultHeight = 24 // Height used before the terminal reports its size
+	helpLine      = "tab: next tab  h/l: switch pane  j/k: move  enter: details  /: search  e: export  y: copy  q: quit"
+)
+
+// View implements tea.Model and renders the current state as plain text
+func (m *Model) View() string {
+	var b strings.Builder
+	switch m.Focus {
+	case PaneDetail:
+		m.renderDetail(&b)
+	case PaneSearch:
+		m.renderSearch(&b)
+	default:
+		m.renderPanes(&b)
+	}
+
+	b.WriteString("\n")
+	if m.Status != "" {
+		b.WriteString(m.Status + "\n")
+	}
+	b.WriteString(helpLine)
+	return b.String()
+}
+
+// listHeight returns the number of list rows that fit on the screen
+func (m *Model) listHeight() int {
+	height := m.Height
+	if height == 0 {
+		height = defaultHeight
+	}
+	if rows := height - 5; rows > 1 {
+		return rows
+	}
+	return 1
+}
+
+// renderPanes renders the VPC list next to the tabbed resource list
+func (m *Model) renderPanes(b *strings.Builder) {
+	var tabs []string
+	for i, tab := range Tabs {
+		if i == m.Tab {
+			tabs = append(tabs, "["+tab.Title+"]")
+		} else {
+			tabs = append(tabs, " "+tab.Title+" ")
+		}
+	}
+	fmt.Fprintf(b, "%-*s %s\n\n", vpcPaneWidth, paneTitle("VPCs", m.Focus == PaneVPCs), strings.Join(tabs, " "))
+
+	left := make([]string, len(m.VPCs))
+	for i, v := range m.VPCs {
+		left[i] = row(v.Label(), i == m.VPCCursor, m.Focus == PaneVPCs)
+	}
+	resources := m.Resources()
+	right := make([]string, len(resources))
+	for i, item := range resources {
+		label := item.Label()
+		if len(Tabs[m.Tab].Kinds) > 1 {
+			label = item.Kind + " " + label
+		}
+		if len(item.CIDRs) > 0 {
+			label += " " + strings.Join(item.CIDRs, ",")
+		}
+		if len(item.Findings) > 0 {
+			label += fmt.Sprintf(" (%d findings)", len(item.Findings))
+		}
+		right[i] = row(label, i == m.Cursor, m.Focus == PaneResources)
+	}
+	if len(right) == 0 {
+		right = []string{"  (none)"}
+	}
+
+	left = window(left, m.VPCCursor, m.listHeight())
+	right = window(right, m.Cursor, m.listHeight())
+	for i := 0; i < len(left) || i < len(right); i++ {
+		var l, r string
+		if i < len(left) {
+			l = truncate(left[i], vpcPaneWidth)
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		fmt.Fprintf(b, "%-*s %s\n", vpcPaneWidth, l, r)
+	}
+}
+
+// renderSearch renders the search prompt with the matching resources
+func (m *Model) renderSearch(b *strings.Builder) {
+	fmt.Fprintf(b, "/%s\n\n", m.Query)
+	rows := make([]string, len(m.Results))
+	for i, item := range m.Results {
+		rows[i] = row(fmt.Sprintf("%-16s %s", item.Kind, item.Label()), i == m.Result, true)
+	}
+	if len(rows) == 0 {
+		rows = []string{"  (no matches)"}
+	}
+	for _, r := range window(rows, m.Result, m.listHeight()) {
+		b.WriteString(r + "\n")
+	}
+}
+
+// renderDetail renders one resource with its references and findings
+func (m *Model) renderDetail(b *strings.Builder) {
+	item := m.Detail
+	fmt.Fprintf(b, "%s %s\n", item.Kind, item.Label())
+	if len(item.VpcIDs) > 0 {
+		fmt.Fprintf(b, "VPC: %s\n", strings.Join(item.VpcIDs, ", "))
+	}
+	if len(item.CIDRs) > 0 {
+		fmt.Fprintf(b, "CIDR: %s\n", strings.Join(item.CIDRs, ", "))
+	}
+
+	b.WriteString("\nReferences:\n")
+	if len(item.References) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, ref := range item.References {
+		fmt.Fprintf(b, "  %s\n", ref)
+	}
+
+	b.WriteString("\nFindings:\n")
+	if len(item.Findings) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, finding := range item.Findings {
+		fmt.Fprintf(b, "  - %s\n", finding)
+	}
+	b.WriteString("\nesc: back\n")
+}
+
+// paneTitle marks the title of the focused pane
+func paneTitle(title string, focused bool) string {
+	if focused {
+		return "> " + title
+	}
+	return "  " + title
+}
+
+// row prefixes a list row with a cursor marker, ">" in the focused pane and "*" in the other
+func row(label string, selected, focused bool) string {
+	switch {
+	case selected && focused:
+		return "> " + label
+	case selected:
+		return "* " + label
+	default:
+		return "  " + label
+	}
+}
+
+// window returns the rows of a list that fit in height, keeping the cursor row visible
+func window(rows []string, cursor, height int) []string {
+	if len(rows) <= height {
+		return rows
+	}
+	start := cursor - height + 1
+	if start < 0 {
+		start = 0
+	}
+	return rows[start : start+height]
+}
+
+// truncate shortens a row to width characters
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,228 @@
+package changepolicy
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/report"
+)
+
+// resourceTypes maps the sections of a scan diff to the resource types rules name
+var resourceTypes = map[string]string{
+	"vpcs":                "vpc",
+	"subnets":             "subnet",
+	"route_tables":        "route_table",
+	"security_groups":     "security_group",
+	"internet_gateways":   "internet_gateway",
+	"nat_gateways":        "nat_gateway",
+	"route_appliances":    "route_appliance",
+	"transit_gateways":    "transit_gateway",
+	"tgw_attachments":     "tgw_attachment",
+	"vpc_peerings":        "vpc_peering",
+	"network_acls":        "network_acl",
+	"carrier_gateways":    "carrier_gateway",
+	"vpn_gateways":        "vpn_gateway",
+	"customer_gateways":   "customer_gateway",
+	"vpn_connections":     "vpn_connection",
+	"dhcp_options":        "dhcp_options",
+	"network_interfaces":  "network_interface",
+	"instances":           "instance",
+	"flow_logs":           "flow_log",
+	"elastic_ips":         "elastic_ip",
+	"vpc_endpoints":       "vpc_endpoint",
+	"auto_scaling_groups": "auto_scaling_group",
+	"directories":         "directory",
+	"core_networks":       "core_network",
+	"private_apis":        "private_api",
+}
+
+// FromDiff lists the changes of a scan diff the way rules address them
+// An added or removed resource is one change without a field; the rules of an added security group
+// are listed as well, so a new group is judged by its rules. A modified resource has one change per
+// scalar that changed, per map key and per list element added or removed: list elements are compared
+// as a whole and in any order, so a changed route is a removed and an added routes.<n>.
+// scanDiff: Diff of two scan results
+// Returns: The changes in the order of the diff
+func FromDiff(scanDiff *report.ScanDiff) []Change {
+	changes := []Change{}
+	for _, resource := range scanDiff.Changes() {
+		resourceType := resourceTypes[resource.Section]
+		if resourceType == "" {
+			resourceType = resource.Section
+		}
+		before, after := jsonObject(resource.Before), jsonObject(resource.After)
+		tags := resourceTags(after)
+		if resource.Change == diff.ChangeRemoved {
+			tags = resourceTags(before)
+		}
+		emit := func(kind, field, oldValue, newValue string) {
+			changes = append(changes, Change{ResourceType: resourceType, ResourceID: resource.ResourceID, Kind: kind,
+				Field: field, OldValue: oldValue, NewValue: newValue, Tags: tags})
+		}
+
+		switch resource.Change {
+		case diff.ChangeAdded:
+			emit(KindAdded, "", "", "")
+			if resourceType == "security_group" {
+				compareValues("", splitRules(nil), splitRules(after["rules"]), emit)
+			}
+		case diff.ChangeRemoved:
+			emit(KindRemoved, "", "", "")
+		default:
+			for _, field := range resource.Fields {
+				if resourceType == "security_group" && field == "rules" {
+					compareValues("", splitRules(before["rules"]), splitRules(after["rules"]), emit)
+					continue
+				}
+				compareValues(field, before[field], after[field], emit)
+			}
+		}
+	}
+	return changes
+}
+
+// compareValues reports the differences between two JSON values at a field path
+// Objects are compared key by key and lists element by element; a value missing on one side is added or removed.
+// emit: Called with the kind, path and old and new value of every difference
+func compareValues(path string, before, after json.RawMessage, emit func(kind, field, oldValue, newValue string)) {
+	// A null list or map, as of a resource without tags, is no value
+	if string(before) == "null" {
+		before = nil
+	}
+	if string(after) == "null" {
+		after = nil
+	}
+	switch {
+	case before == nil && after == nil:
+	case before == nil:
+		emit(KindAdded, path, "", valueText(after))
+	case after == nil:
+		emit(KindRemoved, path, valueText(before), "")
+	case isJSON(before, '{') && isJSON(after, '{'):
+		var beforeFields, afterFields map[string]json.RawMessage
+		json.Unmarshal(before, &beforeFields)
+		json.Unmarshal(after, &afterFields)
+		keys := make(map[string]bool, len(beforeFields)+len(afterFields))
+		for key := range beforeFields {
+			keys[key] = true
+		}
+		for key := range afterFields {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			compareValues(joinPath(path, key), beforeFields[key], afterFields[key], emit)
+		}
+	case isJSON(before, '[') && isJSON(after, '['):
+		var beforeElements, afterElements []json.RawMessage
+		json.Unmarshal(before, &beforeElements)
+		json.Unmarshal(after, &afterElements)
+		compareElements(path, beforeElements, afterElements, emit)
+	default:
+		if string(before) != string(after) {
+			emit(KindModified, path, valueText(before), valueText(after))
+		}
+	}
+}
+
+// compareElements reports the elements only in after as added and those only in before as removed
+// Elements are compared whole and in any order, counting duplicates, and addressed by their index in their list.
+func compareElements(path string, before, after []json.RawMessage, emit func(kind, field, oldValue, newValue string)) {
+	remaining := make(map[string]int, len(before))
+	for _, element := range before {
+		remaining[string(element)]++
+	}
+	var added []int
+	for i, element := range after {
+		if remaining[string(element)] > 0 {
+			remaining[string(element)]--
+			continue
+		}
+		added = append(added, i)
+	}
+	for i, element := range before {
+		if remaining[string(element)] > 0 {
+			remaining[string(element)]--
+			emit(KindRemoved, joinPath(path, strconv.Itoa(i)), valueText(element), "")
+		}
+	}
+	for _, i := range added {
+		emit(KindAdded, joinPath(path, strconv.Itoa(i)), "", valueText(after[i]))
+	}
+}
+
+// splitRules turns the rules list of a security group into an object of its ingress and egress rules
+func splitRules(rules json.RawMessage) json.RawMessage {
+	var elements []json.RawMessage
+	json.Unmarshal(rules, &elements)
+	split := map[string][]json.RawMessage{"ingress": {}, "egress": {}}
+	for _, element := range elements {
+		var rule struct {
+			IsEgress bool `json:"is_egress"`
+		}
+		json.Unmarshal(element, &rule)
+		if rule.IsEgress {
+			split["egress"] = append(split["egress"], element)
+		} else {
+			split["ingress"] = append(split["ingress"], element)
+		}
+	}
+	data, _ := json.Marshal(split)
+	return data
+}
+
+// jsonObject encodes a resource and returns its top-level fields (nil for a nil resource)
+func jsonObject(resource interface{}) map[string]json.RawMessage {
+	if resource == nil {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// resourceTags returns the tags map of an encoded resource (nil if it has none)
+func resourceTags(fields map[string]json.RawMessage) map[string]string {
+	var tags map[string]string
+	json.Unmarshal(fields["tags"], &tags)
+	return tags
+}
+
+// valueText returns a JSON value as rules match it: strings without quotes, everything else as JSON
+func valueText(value json.RawMessage) string {
+	var text string
+	if json.Unmarshal(value, &text) == nil {
+		return text
+	}
+	return string(value)
+}
+
+// isJSON reports whether a JSON value starts with the given delimiter
+func isJSON(value json.RawMessage, delimiter byte) bool {
+	for _, c := range value {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return c == delimiter
+	}
+	return false
+}
+
+// joinPath appends a key or index to a field path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
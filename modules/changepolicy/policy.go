@@ -0,0 +1,260 @@
+// Package changepolicy provides functionality for judging infrastructure changes against a policy for CI pipelines
+package changepolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Change kinds reported in Change.Kind
+const (
+	KindAdded    = "added"    // The resource or list element is new
+	KindRemoved  = "removed"  // The resource or list element is gone
+	KindModified = "modified" // A field of the resource changed value
+)
+
+// Actions a rule maps a change to, in increasing order of severity
+const (
+	ActionAllow = "allow" // The change is expected
+	ActionWarn  = "warn"  // The change needs a look but does not fail the pipeline
+	ActionFail  = "fail"  // The change fails the pipeline
+)
+
+// Verdict results
+const (
+	ResultClean = "clean" // Every change was allowed
+	ResultWarn  = "warn"  // At least one change warned, none failed
+	ResultFail  = "fail"  // At least one change failed
+)
+
+// Change is a single entry of a diff between two scans, as FromDiff lists them
+// Fields are addressed by their JSON names joined with dots, with list elements by index
+// (tags.Owner, routes.2); security group rules are addressed as ingress.<n> and egress.<n>
+// so rules can tell the directions apart.
+type Change struct {
+	ResourceType string            `json:"resource_type"` // Type of the resource (vpc, subnet, route_table, security_group, ...)
+	ResourceID   string            `json:"resource_id"`   // ID of the resource
+	Kind         string            `json:"kind"`          // added, removed or modified
+	Field        string            `json:"field"`         // Path of the changed field (empty when a whole resource was added or removed)
+	OldValue     string            `json:"old_value"`     // Previous value (empty for additions)
+	NewValue     string            `json:"new_value"`     // New value (empty for removals)
+	Tags         map[string]string `json:"tags"`          // Tags of the resource (the old tags for removals)
+}
+
+// Rule maps the changes it matches to an action
+// Every non-empty condition must hold. Type, field, value and tag value conditions are patterns
+// in which "*" matches any run of characters and "?" any single character, so "ingress.*"
+// matches all ingress rule fields and "*0.0.0.0/0*" any value mentioning the IPv4 default route.
+type Rule struct {
+	Name         string            `json:"name"`          // Name reported for the changes the rule matches
+	ResourceType string            `json:"resource_type"` // Resource type to match (empty or * for any)
+	Field        string            `json:"field"`         // Pattern for the field path (empty for any, including whole resources)
+	Kinds        []string          `json:"kinds"`         // Change kinds to match (empty for any)
+	Tags         map[string]string `json:"tags"`          // Tag keys and value patterns the resource must carry
+	NewValue     string            `json:"new_value"`     // Pattern for the new value (empty for any)
+	Action       string            `json:"action"`        // allow, warn or fail
+}
+
+// Policy is an ordered list of rules; the first rule matching a change decides its action
+type Policy struct {
+	Rules     []Rule `json:"rules"`     // Rules in evaluation order
+	Unmatched string `json:"unmatched"` // Action for changes no rule matches (warn when empty)
+}
+
+// ChangeVerdict records which rule matched a change
+type ChangeVerdict struct {
+	Change Change `json:"change"` // The change
+	Rule   string `json:"rule"`   // Name of the matching rule, "rule <n>" if unnamed (empty when no rule matched)
+	Action string `json:"action"` // allow, warn or fail
+}
+
+// Verdict is the machine-readable outcome of judging a diff
+type Verdict struct {
+	Result  string          `json:"result"`  // clean, warn or fail
+	Counts  map[string]int  `json:"counts"`  // Number of changes per action
+	Changes []ChangeVerdict `json:"changes"` // Every change with the rule that matched it, in diff order
+}
+
+// DefaultPolicy returns the policy used when no policy file is given
+// Tag edits are allowed, new world-open ingress and changes to route tables or security groups
+// tagged Environment=prod fail, new subnets tagged Environment=dev are allowed, and everything else warns.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Rules: []Rule{
+			{Name: "tag-changes", Field: "tags.*", Action: ActionAllow},
+			{Name: "world-open-ingress", ResourceType: "security_group", Field: "ingress.*", Kinds: []string{KindAdded, KindModified}, NewValue: "*0.0.0.0/0*", Action: ActionFail},
+			{Name: "world-open-ingress-ipv6", ResourceType: "security_group", Field: "ingress.*", Kinds: []string{KindAdded, KindModified}, NewValue: "*::/0*", Action: ActionFail},
+			{Name: "prod-route-tables", ResourceType: "route_table", Tags: map[string]string{"Environment": "prod"}, Action: ActionFail},
+			{Name: "prod-security-groups", ResourceType: "security_group", Tags: map[string]string{"Environment": "prod"}, Action: ActionFail},
+			{Name: "new-dev-subnets", ResourceType: "subnet", Kinds: []string{KindAdded}, Tags: map[string]string{"Environment": "dev"}, Action: ActionAllow},
+		},
+		Unmatched: ActionWarn,
+	}
+}
+
+// LoadPolicy reads a JSON policy file
+// filename: Path of the policy file
+// Returns: The validated policy, or error if the file cannot be read or is invalid
+func LoadPolicy(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy decodes and validates a JSON policy
+// data: Policy document
+// Returns: The validated policy with Unmatched defaulted to warn, or error describing the first invalid rule
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	if policy.Unmatched == "" {
+		policy.Unmatched = ActionWarn
+	}
+	if err := CheckAction(policy.Unmatched); err != nil {
+		return nil, fmt.Errorf("unmatched: %w", err)
+	}
+
+	for i, rule := range policy.Rules {
+		if problems := rule.Check(); len(problems) > 0 {
+			return nil, fmt.Errorf("%s: %w", rule.DisplayName(i), problems[0])
+		}
+	}
+
+	return &policy, nil
+}
+
+// Evaluate judges every change by the first rule that matches it
+// changes: Diff entries in diff order, from FromDiff
+// Returns: Verdict with the strictest action as the result
+func (p *Policy) Evaluate(changes []Change) *Verdict {
+	verdict := &Verdict{
+		Result:  ResultClean,
+		Counts:  map[string]int{ActionAllow: 0, ActionWarn: 0, ActionFail: 0},
+		Changes: []ChangeVerdict{},
+	}
+
+	unmatched := p.Unmatched
+	if unmatched == "" {
+		unmatched = ActionWarn
+	}
+
+	for _, change := range changes {
+		entry := ChangeVerdict{Change: change, Action: unmatched}
+		for i, rule := range p.Rules {
+			if rule.Matches(change) {
+				entry.Rule = rule.DisplayName(i)
+				entry.Action = rule.Action
+				break
+			}
+		}
+
+		verdict.Counts[entry.Action]++
+		verdict.Changes = append(verdict.Changes, entry)
+
+		switch {
+		case entry.Action == ActionFail:
+			verdict.Result = ResultFail
+		case entry.Action == ActionWarn && verdict.Result == ResultClean:
+			verdict.Result = ResultWarn
+		}
+	}
+
+	return verdict
+}
+
+// Matches reports whether every condition of the rule holds for a change
+func (r Rule) Matches(change Change) bool {
+	if r.ResourceType != "" && !match(r.ResourceType, change.ResourceType) {
+		return false
+	}
+	if r.Field != "" && !match(r.Field, change.Field) {
+		return false
+	}
+	if len(r.Kinds) > 0 && !contains(r.Kinds, change.Kind) {
+		return false
+	}
+	if r.NewValue != "" && !match(r.NewValue, change.NewValue) {
+		return false
+	}
+	for key, pattern := range r.Tags {
+		value, ok := change.Tags[key]
+		if !ok || !match(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether value matches a pattern of literal characters, "*" and "?"
+// Unlike path.Match, "*" also matches "/", which CIDR blocks are full of
+func match(pattern, value string) bool {
+	p, v := 0, 0
+	star, resume := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, resume = p, v
+			p++
+		case star >= 0:
+			// Let the last star absorb one more character and retry
+			resume++
+			p, v = star+1, resume
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// CheckAction reports whether action is allow, warn or fail
+func CheckAction(action string) error {
+	if action != ActionAllow && action != ActionWarn && action != ActionFail {
+		return fmt.Errorf("unknown action %q (expected allow, warn or fail)", action)
+	}
+	return nil
+}
+
+// Check lists every invalid setting of the rule
+// Returns: One error per problem, empty when the rule is valid
+func (r Rule) Check() []error {
+	var problems []error
+	if err := CheckAction(r.Action); err != nil {
+		problems = append(problems, err)
+	}
+	for _, kind := range r.Kinds {
+		if kind != KindAdded && kind != KindRemoved && kind != KindModified {
+			problems = append(problems, fmt.Errorf("unknown change kind %q (expected added, removed or modified)", kind))
+		}
+	}
+	return problems
+}
+
+// DisplayName returns the rule's name for messages, or "rule <n>" for an unnamed rule at index i
+func (r Rule) DisplayName(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+// contains reports whether values contains s
+func contains(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package changepolicy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/vpc"
+)
+
+// TestRuleMatches checks every condition of a rule on its own, with patterns, and that all conditions must hold
+func TestRuleMatches(t *testing.T) {
+	ingress := Change{ResourceType: "security_group", ResourceID: "sg-0a1", Kind: KindAdded, Field: "ingress.2",
+		NewValue: `{"cidr_block":"0.0.0.0/0"}`, Tags: map[string]string{"Environment": "prod", "Team": "network"}}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{name: "empty rule", rule: Rule{}, want: true},
+		// resource type
+		{name: "type", rule: Rule{ResourceType: "security_group"}, want: true},
+		{name: "other type", rule: Rule{ResourceType: "route_table"}, want: false},
+		{name: "type star", rule: Rule{ResourceType: "*"}, want: true},
+		{name: "type pattern", rule: Rule{ResourceType: "security_*"}, want: true},
+		// field
+		{name: "field", rule: Rule{Field: "ingress.2"}, want: true},
+		{name: "field star", rule: Rule{Field: "ingress.*"}, want: true},
+		{name: "field question mark", rule: Rule{Field: "ingress.?"}, want: true},
+		{name: "other direction", rule: Rule{Field: "egress.*"}, want: false},
+		{name: "field prefix only", rule: Rule{Field: "ingress"}, want: false},
+		// kinds
+		{name: "kind", rule: Rule{Kinds: []string{KindAdded}}, want: true},
+		{name: "one of the kinds", rule: Rule{Kinds: []string{KindRemoved, KindAdded}}, want: true},
+		{name: "other kind", rule: Rule{Kinds: []string{KindModified}}, want: false},
+		// tags
+		{name: "tag", rule: Rule{Tags: map[string]string{"Environment": "prod"}}, want: true},
+		{name: "tag pattern", rule: Rule{Tags: map[string]string{"Environment": "pr*"}}, want: true},
+		{name: "all tags", rule: Rule{Tags: map[string]string{"Environment": "prod", "Team": "network"}}, want: true},
+		{name: "other tag value", rule: Rule{Tags: map[string]string{"Environment": "dev"}}, want: false},
+		{name: "missing tag", rule: Rule{Tags: map[string]string{"Owner": "*"}}, want: false},
+		{name: "one tag of two missing", rule: Rule{Tags: map[string]string{"Environment": "prod", "Owner": "*"}}, want: false},
+		// new value
+		{name: "new value", rule: Rule{NewValue: "*0.0.0.0/0*"}, want: true},
+		{name: "new value with slash", rule: Rule{NewValue: `{"cidr_block":"0.0.0.0/?"}`}, want: true},
+		{name: "other new value", rule: Rule{NewValue: "*::/0*"}, want: false},
+		// combined
+		{name: "all conditions", rule: Rule{ResourceType: "security_group", Field: "ingress.*", Kinds: []string{KindAdded}, Tags: map[string]string{"Team": "net*"}, NewValue: "*0.0.0.0/0*"}, want: true},
+		{name: "one condition fails", rule: Rule{ResourceType: "security_group", Field: "ingress.*", Kinds: []string{KindRemoved}, NewValue: "*0.0.0.0/0*"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(ingress); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMatch checks the wildcards of rule patterns, including "*" across the slashes of CIDR blocks
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"", "", true},
+		{"", "x", false},
+		{"*", "", true},
+		{"*", "10.0.0.0/8", true},
+		{"10.*/8", "10.0.0.0/8", true},
+		{"10.*/16", "10.0.0.0/8", false},
+		{"?", "a", true},
+		{"?", "", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"tags.*", "tags.Owner", true},
+		{"tags.*", "tag_list", false},
+	}
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestEvaluate checks that the first matching rule decides a change and the strictest action the result
+func TestEvaluate(t *testing.T) {
+	policy := &Policy{
+		Rules: []Rule{
+			{Name: "tags", Field: "tags.*", Action: ActionAllow},
+			{Name: "open", Field: "ingress.*", NewValue: "*0.0.0.0/0*", Action: ActionFail},
+			{Name: "any-ingress", Field: "ingress.*", Action: ActionWarn},
+		},
+		Unmatched: ActionAllow,
+	}
+	tagChange := Change{ResourceType: "vpc", Kind: KindModified, Field: "tags.Owner"}
+	openRule := Change{ResourceType: "security_group", Kind: KindAdded, Field: "ingress.0", NewValue: "0.0.0.0/0"}
+	closedRule := Change{ResourceType: "security_group", Kind: KindAdded, Field: "ingress.1", NewValue: "10.0.0.0/8"}
+	other := Change{ResourceType: "subnet", Kind: KindAdded}
+
+	tests := []struct {
+		name        string
+		changes     []Change
+		wantResult  string
+		wantActions []string
+		wantRules   []string
+	}{
+		{name: "no changes", wantResult: ResultClean},
+		{name: "allowed", changes: []Change{tagChange, other}, wantResult: ResultClean, wantActions: []string{ActionAllow, ActionAllow}, wantRules: []string{"tags", ""}},
+		{name: "warned", changes: []Change{tagChange, closedRule}, wantResult: ResultWarn, wantActions: []string{ActionAllow, ActionWarn}, wantRules: []string{"tags", "any-ingress"}},
+		{name: "first rule wins", changes: []Change{openRule}, wantResult: ResultFail, wantActions: []string{ActionFail}, wantRules: []string{"open"}},
+		{name: "fail outranks warn", changes: []Change{openRule, closedRule}, wantResult: ResultFail, wantActions: []string{ActionFail, ActionWarn}, wantRules: []string{"open", "any-ingress"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := policy.Evaluate(tt.changes)
+			if verdict.Result != tt.wantResult {
+				t.Errorf("result %s, want %s", verdict.Result, tt.wantResult)
+			}
+			if len(verdict.Changes) != len(tt.changes) {
+				t.Fatalf("got %d judged changes, want %d", len(verdict.Changes), len(tt.changes))
+			}
+			for i, entry := range verdict.Changes {
+				if entry.Action != tt.wantActions[i] || entry.Rule != tt.wantRules[i] {
+					t.Errorf("change %d: %s by %q, want %s by %q", i, entry.Action, entry.Rule, tt.wantActions[i], tt.wantRules[i])
+				}
+			}
+			if total := verdict.Counts[ActionAllow] + verdict.Counts[ActionWarn] + verdict.Counts[ActionFail]; total != len(tt.changes) {
+				t.Errorf("counts %v add up to %d, want %d", verdict.Counts, total, len(tt.changes))
+			}
+		})
+	}
+}
+
+// TestEvaluateUnmatched checks that changes no rule matches warn by default
+func TestEvaluateUnmatched(t *testing.T) {
+	verdict := (&Policy{}).Evaluate([]Change{{ResourceType: "vpc", Kind: KindRemoved}})
+	if verdict.Result != ResultWarn || verdict.Changes[0].Action != ActionWarn || verdict.Changes[0].Rule != "" {
+		t.Errorf("unmatched change: result %s, %+v", verdict.Result, verdict.Changes[0])
+	}
+}
+
+// TestParsePolicy checks the defaults and the validation of a policy document
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`{"rules": [{"name": "dev", "tags": {"Environment": "dev"}, "action": "allow"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Unmatched != ActionWarn || len(policy.Rules) != 1 || policy.Rules[0].Tags["Environment"] != "dev" {
+		t.Errorf("ParsePolicy() = %+v", policy)
+	}
+
+	for _, doc := range []string{
+		`{"rules": [{"action": "block"}]}`,
+		`{"rules": [{"action": "fail", "kinds": ["renamed"]}]}`,
+		`{"unmatched": "ignore"}`,
+		`{"rules": {}}`,
+	} {
+		if _, err := ParsePolicy([]byte(doc)); err == nil {
+			t.Errorf("ParsePolicy(%s) accepted an invalid policy", doc)
+		}
+	}
+}
+
+// diffScan returns a scan result with one VPC, one route table and one security group
+func diffScan() *report.ScanResult {
+	return &report.ScanResult{
+		ScanTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Region:   "eu-west-1",
+		VPCs:     []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", Tags: map[string]string{"Name": "prod", "Environment": "prod"}}},
+		RouteTables: []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}},
+			{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}},
+		}}},
+		SecurityGroups: []vpc.SecurityGroupInfo{{GroupID: "sg-0a1", GroupName: "web", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "10.0.0.0/8"},
+			{IsEgress: true, IpProtocol: "-1", CidrBlock: "0.0.0.0/0"},
+		}}},
+	}
+}
+
+// TestFromDiff checks the field paths, kinds and values FromDiff gives the changes of a scan diff
+func TestFromDiff(t *testing.T) {
+	before, after := diffScan(), diffScan()
+	after.VPCs[0].Tags = map[string]string{"Name": "prod", "Environment": "prod", "Owner": "network"}
+	after.RouteTables[0].Routes[1].Target.ID = "igw-0b2"
+	// Moving a rule is no change; the new world-open rule is the second ingress rule
+	after.SecurityGroups[0].Rules = []vpc.SecurityGroupRule{
+		after.SecurityGroups[0].Rules[1],
+		after.SecurityGroups[0].Rules[0],
+		{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "0.0.0.0/0"},
+	}
+	after.SecurityGroups = append(after.SecurityGroups, vpc.SecurityGroupInfo{GroupID: "sg-0b2", GroupName: "ssh", VpcID: "vpc-0a1",
+		Rules: []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 22, ToPort: 22, Ipv6CidrBlock: "::/0"}}})
+
+	changes := FromDiff(report.Diff(before, after))
+	got := make([]string, 0, len(changes))
+	for _, change := range changes {
+		got = append(got, strings.Join([]string{change.ResourceType, change.ResourceID, change.Kind, change.Field}, " "))
+	}
+	want := []string{
+		"vpc vpc-0a1 added tags.Owner",
+		"route_table rtb-0a1 removed routes.1",
+		"route_table rtb-0a1 added routes.1",
+		"security_group sg-0b2 added ",
+		"security_group sg-0b2 added ingress.0",
+		"security_group sg-0a1 added ingress.1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("FromDiff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if changes[0].NewValue != "network" || changes[0].Tags["Environment"] != "prod" {
+		t.Errorf("tag change %+v", changes[0])
+	}
+	if !strings.Contains(changes[1].OldValue, "igw-0a1") || !strings.Contains(changes[2].NewValue, "igw-0b2") {
+		t.Errorf("route changes %+v and %+v", changes[1], changes[2])
+	}
+	if changes[5].NewValue == "" || !strings.Contains(changes[5].NewValue, `"from_port":22`) {
+		t.Errorf("rule change %+v", changes[5])
+	}
+
+	verdict := DefaultPolicy().Evaluate(changes)
+	if verdict.Result != ResultFail {
+		t.Errorf("default policy result %s, want fail", verdict.Result)
+	}
+	for i, wantRule := range []string{"tag-changes", "", "", "", "world-open-ingress-ipv6", "world-open-ingress"} {
+		if verdict.Changes[i].Rule != wantRule {
+			t.Errorf("%s judged by %q, want %q", want[i], verdict.Changes[i].Rule, wantRule)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package checkpoint persists the progress of paginated scans so an interrupted run can resume where it stopped
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileSuffix is the suffix of checkpoint files in the checkpoint directory
+const fileSuffix = ".checkpoint.json"
+
+// State is the persisted progress of one paginated resource type
+type State struct {
+	Fingerprint  string          `json:"fingerprint"`   // Fingerprint of the run that wrote the checkpoint
+	ResourceType string          `json:"resource_type"` // Resource type being paginated
+	NextToken    string          `json:"next_token"`    // Token of the next page to fetch (empty once complete)
+	Complete     bool            `json:"complete"`      // Whether the last page was processed
+	Pages        int             `json:"pages"`         // Number of pages processed
+	StartedAt    string          `json:"started_at"`    // When the first page was fetched
+	SavedAt      string          `json:"saved_at"`      // When the checkpoint was written
+	Items        json.RawMessage `json:"items"`         // Items processed so far, as produced by the scanner
+}
+
+// Store reads and writes the checkpoints of one run in a directory
+// A nil Store is valid and does nothing, so scanners can checkpoint unconditionally.
+type Store struct {
+	dir         string // Directory holding one file per resource type
+	fingerprint string // Fingerprint of this run
+	resume      bool   // Whether checkpoints of an earlier run are used
+
+	mu      sync.Mutex
+	started map[string]string // Start time of each pagination, carried across resumes
+	pages   map[string]int    // Pages processed per resource type, carried across resumes
+	notes   []string          // Consistency caveats of resumed paginations
+	err     error             // First error writing a checkpoint
+}
+
+// Fingerprint identifies a run by the account, region and the settings that filter what is scanned
+// A checkpoint is only resumed by a run with the same fingerprint.
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Open prepares the checkpoint directory for a run
+// Without resume, checkpoints left by an earlier run are removed so they cannot be mixed in later.
+// dir: Directory for the checkpoint files (created if missing)
+// fingerprint: Fingerprint of this run
+// resume: Whether to continue from checkpoints of an earlier run with the same fingerprint
+// Returns: Store for the run, or error if the directory cannot be prepared
+func Open(dir, fingerprint string, resume bool) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	store := &Store{
+		dir:         dir,
+		fingerprint: fingerprint,
+		resume:      resume,
+		started:     make(map[string]string),
+		pages:       make(map[string]int),
+	}
+	if !resume {
+		if err := store.Clear(); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Load restores the items of a pagination an earlier run checkpointed
+// Checkpoints of a run with another fingerprint are ignored with a note.
+// resourceType: Resource type being paginated
+// items: Pointer to the slice the scanner collects items into
+// Returns: Token of the next page (empty to start from the first page), whether the pagination
+// had completed, or error if the checkpoint cannot be read
+func (s *Store) Load(resourceType string, items interface{}) (string, bool, error) {
+	if s == nil || !s.resume {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(s.path(resourceType))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s checkpoint: %w", resourceType, err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", false, fmt.Errorf("failed to parse %s checkpoint: %w", resourceType, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state.Fingerprint != s.fingerprint {
+		s.notes = append(s.notes, fmt.Sprintf("%s checkpoint ignored: it was written for another account, region or set of filters", resourceType))
+		return "", false, nil
+	}
+	if err := json.Unmarshal(state.Items, items); err != nil {
+		return "", false, fmt.Errorf("failed to parse %s checkpoint items: %w", resourceType, err)
+	}
+	s.started[resourceType] = state.StartedAt
+	s.pages[resourceType] = state.Pages
+	if state.Complete {
+		s.notes = append(s.notes, fmt.Sprintf("%s taken from the run started %s: changes since then are not included", resourceType, state.StartedAt))
+	} else {
+		s.notes = append(s.notes, fmt.Sprintf("%s resumed after page %d of the run started %s: items created or deleted since then on earlier pages are missing or stale", resourceType, state.Pages, state.StartedAt))
+	}
+	return state.NextToken, state.Complete, nil
+}
+
+// Save records that a page was processed
+// Write errors do not fail the scan; the first one is kept for Err and later saves are skipped.
+// resourceType: Resource type being paginated
+// nextToken: Token of the next page, empty after the last page
+// items: Every item processed so far, including those restored by Load
+func (s *Store) Save(resourceType, nextToken string, items interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if s.started[resourceType] == "" {
+		s.started[resourceType] = now
+	}
+	s.pages[resourceType]++
+
+	data, err := json.Marshal(items)
+	if err == nil {
+		data, err = json.Marshal(State{
+			Fingerprint:  s.fingerprint,
+			ResourceType: resourceType,
+			NextToken:    nextToken,
+			Complete:     nextToken == "",
+			Pages:        s.pages[resourceType],
+			StartedAt:    s.started[resourceType],
+			SavedAt:      now,
+			Items:        data,
+		})
+	}
+	if err == nil {
+		err = writeAtomic(s.path(resourceType), data)
+	}
+	if err != nil {
+		s.err = fmt.Errorf("failed to write %s checkpoint: %w", resourceType, err)
+	}
+}
+
+// Notes returns the consistency caveats of the checkpoints that were resumed or ignored
+func (s *Store) Notes() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.notes...)
+}
+
+// Err returns the first error writing a checkpoint, or nil
+func (s *Store) Err() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Clear removes every checkpoint file, after a completed run or before a fresh one
+func (s *Store) Clear() error {
+	if s == nil {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+fileSuffix))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// path returns the file of a resource type's checkpoint
+func (s *Store) path(resourceType string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(resourceType, " ", "-")+fileSuffix)
+}
+
+// writeAtomic writes a file through a temporary file, so an interruption never leaves a truncated checkpoint
+func writeAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
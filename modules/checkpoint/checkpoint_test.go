@@ -0,0 +1,164 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// TestLoadChecksFingerprint checks that only a run with the same account, region and filters resumes a checkpoint
+func TestLoadChecksFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	interrupted, err := Open(dir, Fingerprint("123456789012", "eu-west-1", "env=prod"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interrupted.Save("public_ips", "token-2", []string{"a", "b"})
+	if err := interrupted.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		wantToken   string
+		wantItems   int
+	}{
+		{"same filters", Fingerprint("123456789012", "eu-west-1", "env=prod"), "token-2", 2},
+		{"other filters", Fingerprint("123456789012", "eu-west-1", "env=dev"), "", 0},
+		{"no filters", Fingerprint("123456789012", "eu-west-1", ""), "", 0},
+		{"other region", Fingerprint("123456789012", "us-east-1", "env=prod"), "", 0},
+		{"other account", Fingerprint("210987654321", "eu-west-1", "env=prod"), "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := Open(dir, tt.fingerprint, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var items []string
+			token, complete, err := store.Load("public_ips", &items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != tt.wantToken || complete || len(items) != tt.wantItems {
+				t.Errorf("Load() = %q, %v, %d items; want %q, false, %d items", token, complete, len(items), tt.wantToken, tt.wantItems)
+			}
+			if len(store.Notes()) != 1 {
+				t.Errorf("Notes() = %q, want one note", store.Notes())
+			}
+		})
+	}
+}
+
+// fakePager is a paginated client that fails once when asked for one page, as an interrupted run would
+type fakePager struct {
+	pages  [][]string // Items of each page
+	failAt int        // Page whose first request fails (-1 for none)
+	calls  int        // Pages requested
+}
+
+// page returns the items of the page a token names and the token of the next page
+func (p *fakePager) page(token string) ([]string, string, error) {
+	index := 0
+	if token != "" {
+		fmt.Sscanf(token, "page-%d", &index)
+	}
+	p.calls++
+	if index == p.failAt {
+		p.failAt = -1
+		return nil, "", errors.New("connection reset")
+	}
+	next := ""
+	if index+1 < len(p.pages) {
+		next = fmt.Sprintf("page-%d", index+1)
+	}
+	return p.pages[index], next, nil
+}
+
+// paginate collects every page as the scanners do: from the checkpoint, saving after every page
+func paginate(store *Store, pager *fakePager) ([]string, error) {
+	var items []string
+	token, complete, err := store.Load("items", &items)
+	if err != nil || complete {
+		return items, err
+	}
+	for {
+		page, next, err := pager.page(token)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, page...)
+		store.Save("items", next, items)
+		if next == "" {
+			return items, nil
+		}
+		token = next
+	}
+}
+
+// TestResumeAfterInterruption interrupts a pagination at every page and checks that the resumed run
+// fetches only the pages left and ends with every item exactly once
+func TestResumeAfterInterruption(t *testing.T) {
+	pages := [][]string{{"a", "b"}, {"c", "d"}, {"e"}, {"f", "g"}}
+	want := []string{"a", "b", "c", "d", "e", "f", "g"}
+	fingerprint := Fingerprint("123456789012", "eu-west-1", "")
+
+	for failAt := 0; failAt < len(pages); failAt++ {
+		t.Run(fmt.Sprintf("interrupted at page %d", failAt+1), func(t *testing.T) {
+			dir := t.TempDir()
+			pager := &fakePager{pages: pages, failAt: failAt}
+			first, err := Open(dir, fingerprint, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := paginate(first, pager); err == nil {
+				t.Fatal("the interrupted run did not fail")
+			}
+			if err := first.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			pager.calls = 0
+			resumed, err := Open(dir, fingerprint, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			items, err := paginate(resumed, pager)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(items, want) {
+				t.Errorf("items = %v, want %v", items, want)
+			}
+			if pager.calls != len(pages)-failAt {
+				t.Errorf("the resumed run fetched %d pages, want the %d left", pager.calls, len(pages)-failAt)
+			}
+		})
+	}
+
+	// A run interrupted after its last page resumes without fetching anything
+	t.Run("interrupted after the last page", func(t *testing.T) {
+		dir := t.TempDir()
+		first, err := Open(dir, fingerprint, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := paginate(first, &fakePager{pages: pages, failAt: -1}); err != nil {
+			t.Fatal(err)
+		}
+		resumed, err := Open(dir, fingerprint, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pager := &fakePager{pages: pages, failAt: -1}
+		items, err := paginate(resumed, pager)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(items, want) || pager.calls != 0 {
+			t.Errorf("items = %v after %d pages, want %v without fetching", items, pager.calls, want)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+// Package cloudwan provides functionality for scanning AWS Cloud WAN global and core networks
+package cloudwan
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/networkmanager"
+	"github.com/aws/aws-sdk-go-v2/service/networkmanager/types"
+
+	"aws-documentor/modules/vpc"
+)
+
+// homeRegion is the region serving the Network Manager API in the commercial partition
+// Global and core networks are global resources, so they are scanned once regardless of -region
+const homeRegion = "us-west-2"
+
+// GlobalNetworkInfo contains information about a Network Manager global network
+type GlobalNetworkInfo struct {
+	GlobalNetworkID  string            `json:"global_network_id"`  // Unique identifier for the global network
+	GlobalNetworkArn string            `json:"global_network_arn"` // ARN of the global network
+	Description      string            `json:"description"`        // Description of the global network
+	State            string            `json:"state"`              // State of the global network (PENDING, AVAILABLE, DELETING, UPDATING)
+	Tags             map[string]string `json:"tags"`               // Key-value tags associated with the global network
+	TagList          []vpc.Tag         `json:"tag_list"`           // Tags in API order, including tags without a value
+}
+
+// SegmentInfo contains information about a core network segment as reported by the live network
+type SegmentInfo struct {
+	CoreNetworkID  string   `json:"core_network_id"` // ID of the core network the segment belongs to
+	Name           string   `json:"name"`            // Name of the segment
+	EdgeLocations  []string `json:"edge_locations"`  // Regions the segment is available in
+	SharedSegments []string `json:"shared_segments"` // Segments this segment shares routes with
+}
+
+// EdgeInfo contains information about a core network edge (one per edge location)
+type EdgeInfo struct {
+	EdgeLocation     string   `json:"edge_location"`      // Region of the edge
+	Asn              int64    `json:"asn"`                // ASN of the core network edge
+	InsideCidrBlocks []string `json:"inside_cidr_blocks"` // Inside IP addresses used for Connect peers
+}
+
+// AttachmentInfo contains information about a core network attachment
+type AttachmentInfo struct {
+	AttachmentID       string            `json:"attachment_id"`        // Unique identifier for the attachment
+	AttachmentType     string            `json:"attachment_type"`      // Type as used in attachment policies (vpc, connect, site-to-site-vpn, transit-gateway-route-table)
+	CoreNetworkID      string            `json:"core_network_id"`      // ID of the core network
+	EdgeLocation       string            `json:"edge_location"`        // Region of the attachment
+	OwnerAccountID     string            `json:"owner_account_id"`     // AWS account ID that owns the attachment
+	ResourceArn        string            `json:"resource_arn"`         // ARN of the attached resource
+	ResourceID         string            `json:"resource_id"`          // ID of the attached resource (vpc-..., tgw-rtb-..., ...)
+	State              string            `json:"state"`                // State of the attachment (AVAILABLE, PENDING_ATTACHMENT_ACCEPTANCE, ...)
+	SegmentName        string            `json:"segment_name"`         // Segment the attachment is associated with
+	PolicyRuleNumber   int32             `json:"policy_rule_number"`   // Attachment policy rule that associated the segment (as reported by AWS)
+	ResolvedSegment    string            `json:"resolved_segment"`     // Segment the live policy assigns to the attachment
+	ResolvedRuleNumber int               `json:"resolved_rule_number"` // Attachment policy rule that matched (0 if none)
+	SubnetArns         []string          `json:"subnet_arns"`          // Subnet ARNs of a VPC attachment
+	SubnetIDs          []string          `json:"subnet_ids"`           // Subnet IDs of a VPC attachment
+	Tags               map[string]string `json:"tags"`                 // Key-value tags associated with the attachment
+	TagList            []vpc.Tag         `json:"tag_list"`             // Tags in API order, including tags without a value
+}
+
+// CoreNetworkInfo contains information about a Cloud WAN core network with its live policy and attachments
+type CoreNetworkInfo struct {
+	CoreNetworkID   string            `json:"core_network_id"`   // Unique identifier for the core network
+	CoreNetworkArn  string            `json:"core_network_arn"`  // ARN of the core network (the target of core network routes)
+	GlobalNetworkID string            `json:"global_network_id"` // ID of the global network the core network belongs to
+	OwnerAccountID  string            `json:"owner_account_id"`  // AWS account ID that owns the core network
+	Description     string            `json:"description"`       // Description of the core network
+	State           string            `json:"state"`             // State of the core network (CREATING, UPDATING, AVAILABLE, DELETING)
+	PolicyVersionID int32             `json:"policy_version_id"` // Version of the live policy document (0 if no policy is attached)
+	Policy          *Policy           `json:"policy"`            // Parsed live policy document (nil if none)
+	Segments        []SegmentInfo     `json:"segments"`          // Segments of the core network
+	Edges           []EdgeInfo        `json:"edges"`             // Edges of the core network
+	Attachments     []AttachmentInfo  `json:"attachments"`       // Attachments to the core network
+	Tags            map[string]string `json:"tags"`              // Key-value tags associated with the core network
+	TagList         []vpc.Tag         `json:"tag_list"`          // Tags in API order, including tags without a value
+}
+
+// Scanner provides methods for retrieving Cloud WAN information
+type Scanner struct {
+	client *networkmanager.Client // AWS Network Manager client for making API calls
+}
+
+// NewScanner creates a new Cloud WAN scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials; the region is replaced by the Network Manager home region
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		client: networkmanager.NewFromConfig(cfg, func(o *networkmanager.Options) {
+			o.Region = homeRegion
+		}),
+	}
+}
+
+// GetGlobalNetworks retrieves information about all global networks in the account
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of GlobalNetworkInfo structs, or error if the operation fails
+func (s *Scanner) GetGlobalNetworks(ctx context.Context) ([]GlobalNetworkInfo, error) {
+	globalNetworks := []GlobalNetworkInfo{}
+
+	paginator := networkmanager.NewDescribeGlobalNetworksPaginator(s.client, &networkmanager.DescribeGlobalNetworksInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("networkmanager", "global networks", "DescribeGlobalNetworks", err)
+		}
+		for _, gn := range page.GlobalNetworks {
+			globalNetworks = append(globalNetworks, GlobalNetworkInfo{
+				GlobalNetworkID:  aws.ToString(gn.GlobalNetworkId),
+				GlobalNetworkArn: aws.ToString(gn.GlobalNetworkArn),
+				Description:      aws.ToString(gn.Description),
+				State:            string(gn.State),
+				Tags:             convertTags(gn.Tags),
+				TagList:          convertTagList(gn.Tags),
+			})
+		}
+	}
+
+	return globalNetworks, nil
+}
+
+// GetCoreNetworks retrieves every core network with its segments, edges, live policy and attachments
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of CoreNetworkInfo structs, or error if the operation fails
+func (s *Scanner) GetCoreNetworks(ctx context.Context) ([]CoreNetworkInfo, error) {
+	coreNetworks := []CoreNetworkInfo{}
+
+	paginator := networkmanager.NewListCoreNetworksPaginator(s.client, &networkmanager.ListCoreNetworksInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("networkmanager", "core networks", "ListCoreNetworks", err)
+		}
+		for _, summary := range page.CoreNetworks {
+			coreNetwork, err := s.getCoreNetwork(ctx, aws.ToString(summary.CoreNetworkId))
+			if err != nil {
+				return nil, err
+			}
+			coreNetwork.OwnerAccountID = aws.ToString(summary.OwnerAccountId)
+			coreNetworks = append(coreNetworks, *coreNetwork)
+		}
+	}
+
+	return coreNetworks, nil
+}
+
+// getCoreNetwork retrieves a single core network with its live policy and attachments
+func (s *Scanner) getCoreNetwork(ctx context.Context, coreNetworkID string) (*CoreNetworkInfo, error) {
+	result, err := s.client.GetCoreNetwork(ctx, &networkmanager.GetCoreNetworkInput{
+		CoreNetworkId: aws.String(coreNetworkID),
+	})
+	if err != nil {
+		return nil, vpc.NewServiceScanError("networkmanager", "core network "+coreNetworkID, "GetCoreNetwork", err)
+	}
+	cn := result.CoreNetwork
+
+	coreNetwork := &CoreNetworkInfo{
+		CoreNetworkID:   aws.ToString(cn.CoreNetworkId),
+		CoreNetworkArn:  aws.ToString(cn.CoreNetworkArn),
+		GlobalNetworkID: aws.ToString(cn.GlobalNetworkId),
+		Description:     aws.ToString(cn.Description),
+		State:           string(cn.State),
+		Segments:        []SegmentInfo{},
+		Edges:           []EdgeInfo{},
+		Attachments:     []AttachmentInfo{},
+		Tags:            convertTags(cn.Tags),
+		TagList:         convertTagList(cn.Tags),
+	}
+
+	for _, segment := range cn.Segments {
+		coreNetwork.Segments = append(coreNetwork.Segments, SegmentInfo{
+			CoreNetworkID:  coreNetwork.CoreNetworkID,
+			Name:           aws.ToString(segment.Name),
+			EdgeLocations:  nonNil(segment.EdgeLocations),
+			SharedSegments: nonNil(segment.SharedSegments),
+		})
+	}
+	for _, edge := range cn.Edges {
+		coreNetwork.Edges = append(coreNetwork.Edges, EdgeInfo{
+			EdgeLocation:     aws.ToString(edge.EdgeLocation),
+			Asn:              aws.ToInt64(edge.Asn),
+			InsideCidrBlocks: nonNil(edge.InsideCidrBlocks),
+		})
+	}
+
+	// The live policy drives segment assignment; a core network can exist without one
+	policyResult, err := s.client.GetCoreNetworkPolicy(ctx, &networkmanager.GetCoreNetworkPolicyInput{
+		CoreNetworkId: aws.String(coreNetworkID),
+		Alias:         types.CoreNetworkPolicyAliasLive,
+	})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+	case err != nil:
+		return nil, vpc.NewServiceScanError("networkmanager", "core network policy "+coreNetworkID, "GetCoreNetworkPolicy", err)
+	case policyResult.CoreNetworkPolicy != nil:
+		coreNetwork.PolicyVersionID = aws.ToInt32(policyResult.CoreNetworkPolicy.PolicyVersionId)
+		policy, err := ParsePolicy(aws.ToString(policyResult.CoreNetworkPolicy.PolicyDocument))
+		if err != nil {
+			return nil, err
+		}
+		coreNetwork.Policy = policy
+	}
+
+	attachments, err := s.getAttachments(ctx, coreNetworkID)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		if coreNetwork.Policy != nil {
+			attachment.ResolvedSegment, attachment.ResolvedRuleNumber = coreNetwork.Policy.ResolveSegment(AttachmentFacts{
+				Type:       attachment.AttachmentType,
+				ResourceID: attachment.ResourceID,
+				AccountID:  attachment.OwnerAccountID,
+				Region:     attachment.EdgeLocation,
+				Tags:       attachment.Tags,
+			})
+		}
+		coreNetwork.Attachments = append(coreNetwork.Attachments, attachment)
+	}
+
+	return coreNetwork, nil
+}
+
+// getAttachments retrieves the attachments of a core network, with subnets for VPC attachments
+func (s *Scanner) getAttachments(ctx context.Context, coreNetworkID string) ([]AttachmentInfo, error) {
+	var attachments []AttachmentInfo
+
+	paginator := networkmanager.NewListAttachmentsPaginator(s.client, &networkmanager.ListAttachmentsInput{
+		CoreNetworkId: aws.String(coreNetworkID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("networkmanager", "core network attachments", "ListAttachments", err)
+		}
+		for _, a := range page.Attachments {
+			attachment := AttachmentInfo{
+				AttachmentID:     aws.ToString(a.AttachmentId),
+				AttachmentType:   policyAttachmentType(a.AttachmentType),
+				CoreNetworkID:    aws.ToString(a.CoreNetworkId),
+				EdgeLocation:     aws.ToString(a.EdgeLocation),
+				OwnerAccountID:   aws.ToString(a.OwnerAccountId),
+				ResourceArn:      aws.ToString(a.ResourceArn),
+				ResourceID:       resourceIDFromArn(aws.ToString(a.ResourceArn)),
+				State:            string(a.State),
+				SegmentName:      aws.ToString(a.SegmentName),
+				PolicyRuleNumber: aws.ToInt32(a.AttachmentPolicyRuleNumber),
+				SubnetArns:       []string{},
+				SubnetIDs:        []string{},
+				Tags:             convertTags(a.Tags),
+				TagList:          convertTagList(a.Tags),
+			}
+
+			if a.AttachmentType == types.AttachmentTypeVpc {
+				result, err := s.client.GetVpcAttachment(ctx, &networkmanager.GetVpcAttachmentInput{
+					AttachmentId: a.AttachmentId,
+				})
+				if err != nil {
+					return nil, vpc.NewServiceScanError("networkmanager", "VPC attachment "+attachment.AttachmentID, "GetVpcAttachment", err)
+				}
+				if result.VpcAttachment != nil {
+					for _, subnetArn := range result.VpcAttachment.SubnetArns {
+						attachment.SubnetArns = append(attachment.SubnetArns, subnetArn)
+						attachment.SubnetIDs = append(attachment.SubnetIDs, resourceIDFromArn(subnetArn))
+					}
+				}
+			}
+
+			attachments = append(attachments, attachment)
+		}
+	}
+
+	return attachments, nil
+}
+
+// policyAttachmentType converts an API attachment type (SITE_TO_SITE_VPN) to its policy form (site-to-site-vpn)
+func policyAttachmentType(attachmentType types.AttachmentType) string {
+	return strings.ReplaceAll(strings.ToLower(string(attachmentType)), "_", "-")
+}
+
+// resourceIDFromArn returns the resource ID at the end of an ARN (arn:aws:ec2:us-east-1:111122223333:vpc/vpc-1234 -> vpc-1234)
+func resourceIDFromArn(arn string) string {
+	if i := strings.LastIndex(arn, "/"); i >= 0 {
+		return arn[i+1:]
+	}
+	return arn
+}
+
+// nonNil returns an empty slice instead of nil so the JSON output contains [] rather than null
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// convertTagList converts Network Manager tags to a list in API order, keeping tags without a value
+func convertTagList(tags []types.Tag) []vpc.Tag {
+	result := []vpc.Tag{}
+	for _, tag := range tags {
+		if tag.Key != nil {
+			result = append(result, vpc.NewTag(tag.Key, tag.Value))
+		}
+	}
+	return result
+}
+
+// convertTags converts Network Manager tags to a simple key-value map
+func convertTags(tags []types.Tag) map[string]string {
+	result := make(map[string]string)
+	for _, tag := range tags {
+		if tag.Key != nil && tag.Value != nil {
+			result[*tag.Key] = *tag.Value
+		}
+	}
+	return result
+}
@@ -0,0 +1,383 @@
+package cloudwan
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Attachment policy association methods
+const (
+	AssociationConstant = "constant" // The rule names the segment directly
+	AssociationTag      = "tag"      // The segment is the value of an attachment tag
+)
+
+// Policy is a parsed Cloud WAN core network policy document
+type Policy struct {
+	Version            string               `json:"version"`             // Policy document version (2021.12)
+	AsnRanges          []string             `json:"asn_ranges"`          // ASN ranges available to core network edges
+	EdgeLocations      []PolicyEdgeLocation `json:"edge_locations"`      // Regions the core network operates in
+	Segments           []PolicySegment      `json:"segments"`            // Segment definitions
+	Shares             []SegmentShare       `json:"shares"`              // Route sharing between segments
+	StaticRoutes       []StaticRoute        `json:"static_routes"`       // Static routes created in segments
+	AttachmentPolicies []AttachmentPolicy   `json:"attachment_policies"` // Rules mapping attachments to segments, ordered by rule number
+}
+
+// PolicyEdgeLocation is an edge location declared in the core network configuration
+type PolicyEdgeLocation struct {
+	Location string `json:"location"` // Region of the edge location
+	Asn      int64  `json:"asn"`      // ASN pinned for the edge (0 if allocated from the ASN ranges)
+}
+
+// PolicySegment is a segment definition from the policy document
+type PolicySegment struct {
+	Name                        string   `json:"name"`                          // Name of the segment
+	Description                 string   `json:"description"`                   // Description of the segment
+	EdgeLocations               []string `json:"edge_locations"`                // Regions the segment is limited to (empty means all edge locations)
+	IsolateAttachments          bool     `json:"isolate_attachments"`           // Whether attachments in the segment cannot reach each other
+	RequireAttachmentAcceptance bool     `json:"require_attachment_acceptance"` // Whether attachments need to be accepted before joining
+	AllowFilter                 []string `json:"allow_filter"`                  // Segments allowed to share with this segment
+	DenyFilter                  []string `json:"deny_filter"`                   // Segments not allowed to share with this segment
+}
+
+// SegmentShare is a share segment action, with the share-with list resolved to segment names
+type SegmentShare struct {
+	Segment   string   `json:"segment"`    // Segment whose routes are shared
+	Mode      string   `json:"mode"`       // Sharing mode (attachment-route)
+	ShareWith []string `json:"share_with"` // Segments the routes are shared with
+}
+
+// StaticRoute is a create-route segment action
+type StaticRoute struct {
+	Segment               string   `json:"segment"`                 // Segment the route is created in
+	DestinationCidrBlocks []string `json:"destination_cidr_blocks"` // Destinations of the route
+	Destinations          []string `json:"destinations"`            // Attachment IDs the route points to (or blackhole)
+}
+
+// AttachmentPolicy is a rule associating matching attachments with a segment
+type AttachmentPolicy struct {
+	RuleNumber        int                   `json:"rule_number"`        // Evaluation order; lower numbers are evaluated first
+	Description       string                `json:"description"`        // Description of the rule
+	ConditionLogic    string                `json:"condition_logic"`    // How conditions are combined (and, or)
+	Conditions        []AttachmentCondition `json:"conditions"`         // Conditions an attachment must meet
+	AssociationMethod string                `json:"association_method"` // constant or tag
+	Segment           string                `json:"segment"`            // Segment for constant associations
+	TagValueOfKey     string                `json:"tag_value_of_key"`   // Tag whose value names the segment for tag associations
+	RequireAcceptance bool                  `json:"require_acceptance"` // Whether matching attachments need to be accepted
+}
+
+// AttachmentCondition is a single attachment policy condition
+type AttachmentCondition struct {
+	Type     string `json:"type"`     // any, tag-exists, tag-value, account-id, region, resource-id or attachment-type
+	Operator string `json:"operator"` // equals, not-equals, contains or begins-with
+	Key      string `json:"key"`      // Tag key for tag conditions
+	Value    string `json:"value"`    // Value compared against
+}
+
+// AttachmentFacts are the properties of an attachment that attachment policy conditions test
+type AttachmentFacts struct {
+	Type       string            // Attachment type in policy form (vpc, connect, site-to-site-vpn, transit-gateway-route-table)
+	ResourceID string            // ID of the attached resource
+	AccountID  string            // AWS account ID that owns the attachment
+	Region     string            // Edge location of the attachment
+	Tags       map[string]string // Tags of the attachment
+}
+
+// policyDocument mirrors the JSON layout of a core network policy document
+type policyDocument struct {
+	Version                  string `json:"version"`
+	CoreNetworkConfiguration struct {
+		AsnRanges     []string `json:"asn-ranges"`
+		EdgeLocations []struct {
+			Location string `json:"location"`
+			Asn      int64  `json:"asn"`
+		} `json:"edge-locations"`
+	} `json:"core-network-configuration"`
+	Segments []struct {
+		Name                        string   `json:"name"`
+		Description                 string   `json:"description"`
+		EdgeLocations               []string `json:"edge-locations"`
+		IsolateAttachments          bool     `json:"isolate-attachments"`
+		RequireAttachmentAcceptance bool     `json:"require-attachment-acceptance"`
+		AllowFilter                 []string `json:"allow-filter"`
+		DenyFilter                  []string `json:"deny-filter"`
+	} `json:"segments"`
+	SegmentActions []struct {
+		Action                string          `json:"action"`
+		Segment               string          `json:"segment"`
+		Mode                  string          `json:"mode"`
+		ShareWith             json.RawMessage `json:"share-with"`
+		DestinationCidrBlocks []string        `json:"destination-cidr-blocks"`
+		Destinations          []string        `json:"destinations"`
+	} `json:"segment-actions"`
+	AttachmentPolicies []struct {
+		RuleNumber     int    `json:"rule-number"`
+		Description    string `json:"description"`
+		ConditionLogic string `json:"condition-logic"`
+		Conditions     []struct {
+			Type     string `json:"type"`
+			Operator string `json:"operator"`
+			Key      string `json:"key"`
+			Value    string `json:"value"`
+		} `json:"conditions"`
+		Action struct {
+			AssociationMethod string `json:"association-method"`
+			Segment           string `json:"segment"`
+			TagValueOfKey     string `json:"tag-value-of-key"`
+			RequireAcceptance bool   `json:"require-acceptance"`
+		} `json:"action"`
+	} `json:"attachment-policies"`
+}
+
+// ParsePolicy parses a core network policy document
+// document: Policy document JSON as returned by GetCoreNetworkPolicy
+// Returns: Parsed policy with share-with lists resolved and attachment policies sorted by rule number, or error if the document is invalid
+func ParsePolicy(document string) (*Policy, error) {
+	var doc policyDocument
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse core network policy: %w", err)
+	}
+
+	policy := &Policy{
+		Version:            doc.Version,
+		AsnRanges:          nonNil(doc.CoreNetworkConfiguration.AsnRanges),
+		EdgeLocations:      []PolicyEdgeLocation{},
+		Segments:           []PolicySegment{},
+		Shares:             []SegmentShare{},
+		StaticRoutes:       []StaticRoute{},
+		AttachmentPolicies: []AttachmentPolicy{},
+	}
+
+	for _, edge := range doc.CoreNetworkConfiguration.EdgeLocations {
+		policy.EdgeLocations = append(policy.EdgeLocations, PolicyEdgeLocation{Location: edge.Location, Asn: edge.Asn})
+	}
+
+	var segmentNames []string
+	for _, segment := range doc.Segments {
+		segmentNames = append(segmentNames, segment.Name)
+		policy.Segments = append(policy.Segments, PolicySegment{
+			Name:                        segment.Name,
+			Description:                 segment.Description,
+			EdgeLocations:               nonNil(segment.EdgeLocations),
+			IsolateAttachments:          segment.IsolateAttachments,
+			RequireAttachmentAcceptance: segment.RequireAttachmentAcceptance,
+			AllowFilter:                 nonNil(segment.AllowFilter),
+			DenyFilter:                  nonNil(segment.DenyFilter),
+		})
+	}
+
+	for _, action := range doc.SegmentActions {
+		switch action.Action {
+		case "share":
+			shareWith, err := resolveShareWith(action.ShareWith, action.Segment, segmentNames)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse core network policy: share action for segment %q: %w", action.Segment, err)
+			}
+			policy.Shares = append(policy.Shares, SegmentShare{
+				Segment:   action.Segment,
+				Mode:      action.Mode,
+				ShareWith: shareWith,
+			})
+		case "create-route":
+			policy.StaticRoutes = append(policy.StaticRoutes, StaticRoute{
+				Segment:               action.Segment,
+				DestinationCidrBlocks: nonNil(action.DestinationCidrBlocks),
+				Destinations:          nonNil(action.Destinations),
+			})
+		}
+	}
+
+	for _, rule := range doc.AttachmentPolicies {
+		attachmentPolicy := AttachmentPolicy{
+			RuleNumber:        rule.RuleNumber,
+			Description:       rule.Description,
+			ConditionLogic:    rule.ConditionLogic,
+			Conditions:        []AttachmentCondition{},
+			AssociationMethod: rule.Action.AssociationMethod,
+			Segment:           rule.Action.Segment,
+			TagValueOfKey:     rule.Action.TagValueOfKey,
+			RequireAcceptance: rule.Action.RequireAcceptance,
+		}
+		for _, condition := range rule.Conditions {
+			attachmentPolicy.Conditions = append(attachmentPolicy.Conditions, AttachmentCondition{
+				Type:     condition.Type,
+				Operator: condition.Operator,
+				Key:      condition.Key,
+				Value:    condition.Value,
+			})
+		}
+		policy.AttachmentPolicies = append(policy.AttachmentPolicies, attachmentPolicy)
+	}
+	sort.SliceStable(policy.AttachmentPolicies, func(i, j int) bool {
+		return policy.AttachmentPolicies[i].RuleNumber < policy.AttachmentPolicies[j].RuleNumber
+	})
+
+	return policy, nil
+}
+
+// resolveShareWith expands a share-with value ("*", a list of segments, or {"except": [...]}) to segment names
+// The sharing segment itself is never part of the result
+func resolveShareWith(raw json.RawMessage, segment string, segmentNames []string) ([]string, error) {
+	allExcept := func(excluded []string) []string {
+		skip := map[string]bool{segment: true}
+		for _, name := range excluded {
+			skip[name] = true
+		}
+		result := []string{}
+		for _, name := range segmentNames {
+			if !skip[name] {
+				result = append(result, name)
+			}
+		}
+		return result
+	}
+
+	var wildcard string
+	if err := json.Unmarshal(raw, &wildcard); err == nil {
+		if wildcard != "*" {
+			return nil, fmt.Errorf("unexpected share-with value %q", wildcard)
+		}
+		return allExcept(nil), nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(raw, &names); err == nil {
+		result := []string{}
+		for _, name := range names {
+			if name != segment {
+				result = append(result, name)
+			}
+		}
+		return result, nil
+	}
+
+	var except struct {
+		Except []string `json:"except"`
+	}
+	if err := json.Unmarshal(raw, &except); err != nil {
+		return nil, fmt.Errorf("unexpected share-with value %s", string(raw))
+	}
+	return allExcept(except.Except), nil
+}
+
+// ResolveSegment determines which segment the attachment policies assign to an attachment
+// Rules are evaluated in rule number order and the first rule whose conditions match decides
+// facts: Properties of the attachment
+// Returns: Segment name and matching rule number; the segment is empty if the matching rule's tag is
+// missing or names an unknown segment, and the rule number is 0 if no rule matches
+func (p *Policy) ResolveSegment(facts AttachmentFacts) (string, int) {
+	for _, rule := range p.AttachmentPolicies {
+		if !rule.matches(facts) {
+			continue
+		}
+
+		segment := rule.Segment
+		if rule.AssociationMethod == AssociationTag {
+			segment = facts.Tags[rule.TagValueOfKey]
+		}
+		if p.segment(segment) == nil {
+			return "", rule.RuleNumber
+		}
+		return segment, rule.RuleNumber
+	}
+	return "", 0
+}
+
+// ReachableSegments returns the segments an attachment in the given segment can reach: its own
+// segment (unless attachments are isolated) and every segment sharing routes with it in either direction
+// segment: Name of the source segment
+// Returns: Sorted segment names
+func (p *Policy) ReachableSegments(segment string) []string {
+	reachable := make(map[string]bool)
+	if definition := p.segment(segment); definition != nil && !definition.IsolateAttachments {
+		reachable[segment] = true
+	}
+
+	// Sharing is bidirectional: routes of both segments are propagated to each other
+	for _, share := range p.Shares {
+		if share.Segment == segment {
+			for _, name := range share.ShareWith {
+				reachable[name] = true
+			}
+			continue
+		}
+		for _, name := range share.ShareWith {
+			if name == segment {
+				reachable[share.Segment] = true
+			}
+		}
+	}
+
+	result := []string{}
+	for name := range reachable {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// segment returns the definition of a segment, or nil if the policy does not define it
+func (p *Policy) segment(name string) *PolicySegment {
+	for i := range p.Segments {
+		if p.Segments[i].Name == name {
+			return &p.Segments[i]
+		}
+	}
+	return nil
+}
+
+// matches reports whether an attachment meets the rule's conditions
+// Conditions are combined with "or" only when condition-logic says so
+func (rule AttachmentPolicy) matches(facts AttachmentFacts) bool {
+	if len(rule.Conditions) == 0 {
+		return false
+	}
+	orLogic := strings.EqualFold(rule.ConditionLogic, "or")
+	for _, condition := range rule.Conditions {
+		matched := condition.matches(facts)
+		if orLogic && matched {
+			return true
+		}
+		if !orLogic && !matched {
+			return false
+		}
+	}
+	return !orLogic
+}
+
+// matches reports whether an attachment meets a single condition
+func (condition AttachmentCondition) matches(facts AttachmentFacts) bool {
+	switch condition.Type {
+	case "any":
+		return true
+	case "tag-exists":
+		_, ok := facts.Tags[condition.Key]
+		return ok
+	case "tag-value":
+		value, ok := facts.Tags[condition.Key]
+		return ok && compare(condition.Operator, value, condition.Value)
+	case "account-id", "account":
+		return compare(condition.Operator, facts.AccountID, condition.Value)
+	case "region":
+		return compare(condition.Operator, facts.Region, condition.Value)
+	case "resource-id":
+		return compare(condition.Operator, facts.ResourceID, condition.Value)
+	case "attachment-type":
+		return compare(condition.Operator, facts.Type, condition.Value)
+	}
+	return false
+}
+
+// compare applies an attachment policy operator (equals when empty)
+func compare(operator, actual, expected string) bool {
+	switch operator {
+	case "not-equals":
+		return actual != expected
+	case "contains":
+		return strings.Contains(actual, expected)
+	case "begins-with":
+		return strings.HasPrefix(actual, expected)
+	}
+	return actual == expected
+}
@@ -0,0 +1,294 @@
+package cloudwan
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// loadPolicy parses the policy document of testdata/policy.json: five segments, shares with a wildcard,
+// an except list and a plain list, a static route and attachment policies out of rule number order
+func loadPolicy(t *testing.T) *Policy {
+	t.Helper()
+	document, err := os.ReadFile("testdata/policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := ParsePolicy(string(document))
+	if err != nil {
+		t.Fatalf("ParsePolicy() = %v", err)
+	}
+	return policy
+}
+
+// TestParsePolicy checks the edge locations, segments, resolved shares, static routes and sorted attachment policies of the fixture
+func TestParsePolicy(t *testing.T) {
+	policy := loadPolicy(t)
+
+	if policy.Version != "2021.12" || !reflect.DeepEqual(policy.AsnRanges, []string{"64512-64555"}) {
+		t.Errorf("version %q, ASN ranges %v", policy.Version, policy.AsnRanges)
+	}
+	wantEdges := []PolicyEdgeLocation{{Location: "eu-west-1"}, {Location: "us-east-1", Asn: 64520}}
+	if !reflect.DeepEqual(policy.EdgeLocations, wantEdges) {
+		t.Errorf("EdgeLocations = %+v, want %+v", policy.EdgeLocations, wantEdges)
+	}
+
+	var names []string
+	for _, segment := range policy.Segments {
+		names = append(names, segment.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"prod", "dev", "shared", "sandbox", "inspection"}) {
+		t.Errorf("segments = %v", names)
+	}
+	if prod := policy.Segments[0]; !prod.RequireAttachmentAcceptance || prod.Description != "Production" || prod.EdgeLocations == nil {
+		t.Errorf("prod = %+v, want acceptance required and an empty edge location list", prod)
+	}
+	if dev := policy.Segments[1]; !reflect.DeepEqual(dev.EdgeLocations, []string{"eu-west-1"}) || !reflect.DeepEqual(dev.DenyFilter, []string{"prod"}) {
+		t.Errorf("dev = %+v, want limited to eu-west-1 and denying prod", dev)
+	}
+	if sandbox := policy.Segments[3]; !sandbox.IsolateAttachments {
+		t.Errorf("sandbox = %+v, want isolated attachments", sandbox)
+	}
+
+	wantShares := []SegmentShare{
+		{Segment: "shared", Mode: "attachment-route", ShareWith: []string{"prod", "dev", "sandbox", "inspection"}},
+		{Segment: "inspection", Mode: "attachment-route", ShareWith: []string{"prod", "dev", "shared"}},
+		{Segment: "dev", Mode: "attachment-route", ShareWith: []string{"sandbox"}},
+	}
+	if !reflect.DeepEqual(policy.Shares, wantShares) {
+		t.Errorf("Shares = %+v, want %+v", policy.Shares, wantShares)
+	}
+	wantRoutes := []StaticRoute{{Segment: "prod", DestinationCidrBlocks: []string{"0.0.0.0/0"}, Destinations: []string{"attachment-0inspect"}}}
+	if !reflect.DeepEqual(policy.StaticRoutes, wantRoutes) {
+		t.Errorf("StaticRoutes = %+v, want %+v", policy.StaticRoutes, wantRoutes)
+	}
+
+	var rules []int
+	for _, rule := range policy.AttachmentPolicies {
+		rules = append(rules, rule.RuleNumber)
+	}
+	if !reflect.DeepEqual(rules, []int{100, 200, 250, 300}) {
+		t.Errorf("attachment policies in order %v, want sorted by rule number", rules)
+	}
+	tagRule := policy.AttachmentPolicies[0]
+	if tagRule.AssociationMethod != AssociationTag || tagRule.TagValueOfKey != "segment" || len(tagRule.Conditions) != 2 {
+		t.Errorf("rule 100 = %+v, want a tag association on the segment tag with two conditions", tagRule)
+	}
+	if shared := policy.AttachmentPolicies[1]; !shared.RequireAcceptance || shared.ConditionLogic != "or" {
+		t.Errorf("rule 200 = %+v, want or logic requiring acceptance", shared)
+	}
+}
+
+// TestParsePolicyErrors checks that documents that are not JSON or have an unknown share-with value are rejected
+func TestParsePolicyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{name: "not JSON", document: `{"segments": [`},
+		{name: "share with a name", document: `{"segments": [{"name": "a"}], "segment-actions": [{"action": "share", "segment": "a", "share-with": "all"}]}`},
+		{name: "share with a number", document: `{"segments": [{"name": "a"}], "segment-actions": [{"action": "share", "segment": "a", "share-with": 1}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if policy, err := ParsePolicy(tt.document); err == nil {
+				t.Errorf("ParsePolicy() = %+v, want an error", policy)
+			}
+		})
+	}
+
+	empty, err := ParsePolicy(`{"version": "2021.12"}`)
+	if err != nil {
+		t.Fatalf("ParsePolicy() of an empty policy = %v", err)
+	}
+	if empty.Segments == nil || empty.Shares == nil || empty.AttachmentPolicies == nil {
+		t.Errorf("empty policy = %+v, want empty lists rather than null", empty)
+	}
+}
+
+// TestResolveSegment checks which segment the fixture's attachment policies assign to attachments of every kind
+func TestResolveSegment(t *testing.T) {
+	policy := loadPolicy(t)
+	tests := []struct {
+		name        string
+		facts       AttachmentFacts
+		wantSegment string
+		wantRule    int
+	}{
+		{name: "segment tag", facts: AttachmentFacts{Type: "vpc", ResourceID: "vpc-0prod", Region: "eu-west-1", Tags: map[string]string{"segment": "prod"}},
+			wantSegment: "prod", wantRule: 100},
+		{name: "segment tag naming an unknown segment", facts: AttachmentFacts{Type: "vpc", Region: "eu-west-1", Tags: map[string]string{"segment": "finance"}},
+			wantRule: 100},
+		{name: "segment tag on a Connect attachment", facts: AttachmentFacts{Type: "connect", Region: "us-east-1", Tags: map[string]string{"segment": "prod"}},
+			wantSegment: "inspection", wantRule: 250},
+		{name: "resource ID prefix", facts: AttachmentFacts{Type: "vpc", ResourceID: "vpc-0shared01", Region: "eu-west-1"},
+			wantSegment: "shared", wantRule: 200},
+		{name: "Name tag contains", facts: AttachmentFacts{Type: "vpc", ResourceID: "vpc-0a1", Region: "eu-west-1", Tags: map[string]string{"Name": "core-shared-svc"}},
+			wantSegment: "shared", wantRule: 200},
+		{name: "account", facts: AttachmentFacts{Type: "site-to-site-vpn", AccountID: "111122223333", Region: "eu-west-1"},
+			wantSegment: "sandbox", wantRule: 300},
+		{name: "no rule matches", facts: AttachmentFacts{Type: "vpc", AccountID: "444455556666", Region: "eu-west-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment, rule := policy.ResolveSegment(tt.facts)
+			if segment != tt.wantSegment || rule != tt.wantRule {
+				t.Errorf("ResolveSegment() = %q, rule %d; want %q, rule %d", segment, rule, tt.wantSegment, tt.wantRule)
+			}
+		})
+	}
+}
+
+// TestAttachmentConditions covers every condition type and operator
+func TestAttachmentConditions(t *testing.T) {
+	facts := AttachmentFacts{Type: "vpc", ResourceID: "vpc-0a1", AccountID: "111122223333", Region: "eu-west-1", Tags: map[string]string{"env": "prod-eu"}}
+	tests := []struct {
+		condition AttachmentCondition
+		want      bool
+	}{
+		{AttachmentCondition{Type: "any"}, true},
+		{AttachmentCondition{Type: "tag-exists", Key: "env"}, true},
+		{AttachmentCondition{Type: "tag-exists", Key: "team"}, false},
+		{AttachmentCondition{Type: "tag-value", Key: "env", Operator: "equals", Value: "prod-eu"}, true},
+		{AttachmentCondition{Type: "tag-value", Key: "env", Operator: "begins-with", Value: "prod"}, true},
+		{AttachmentCondition{Type: "tag-value", Key: "env", Operator: "contains", Value: "-eu"}, true},
+		{AttachmentCondition{Type: "tag-value", Key: "env", Operator: "not-equals", Value: "prod-eu"}, false},
+		{AttachmentCondition{Type: "tag-value", Key: "team", Operator: "not-equals", Value: "x"}, false},
+		{AttachmentCondition{Type: "account-id", Value: "111122223333"}, true},
+		{AttachmentCondition{Type: "account", Operator: "not-equals", Value: "111122223333"}, false},
+		{AttachmentCondition{Type: "region", Operator: "equals", Value: "eu-west-1"}, true},
+		{AttachmentCondition{Type: "resource-id", Operator: "begins-with", Value: "vpc-"}, true},
+		{AttachmentCondition{Type: "attachment-type", Value: "connect"}, false},
+		{AttachmentCondition{Type: "unknown-type", Value: "vpc"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.condition.matches(facts); got != tt.want {
+			t.Errorf("%+v matches = %v, want %v", tt.condition, got, tt.want)
+		}
+	}
+
+	and := AttachmentPolicy{Conditions: []AttachmentCondition{{Type: "any"}, {Type: "tag-exists", Key: "team"}}}
+	or := AttachmentPolicy{ConditionLogic: "OR", Conditions: and.Conditions}
+	if and.matches(facts) || !or.matches(facts) {
+		t.Errorf("and matches %v, or matches %v; want only or", and.matches(facts), or.matches(facts))
+	}
+	if (AttachmentPolicy{}).matches(facts) {
+		t.Error("a rule without conditions matches")
+	}
+}
+
+// TestReachableSegments checks that shares work in both directions and isolated segments do not reach themselves
+func TestReachableSegments(t *testing.T) {
+	policy := loadPolicy(t)
+	tests := map[string][]string{
+		"prod":       {"inspection", "prod", "shared"},
+		"dev":        {"dev", "inspection", "sandbox", "shared"},
+		"sandbox":    {"dev", "shared"},
+		"shared":     {"dev", "inspection", "prod", "sandbox", "shared"},
+		"inspection": {"dev", "inspection", "prod", "shared"},
+		"finance":    {},
+	}
+	for segment, want := range tests {
+		if got := policy.ReachableSegments(segment); !reflect.DeepEqual(got, want) {
+			t.Errorf("ReachableSegments(%q) = %v, want %v", segment, got, want)
+		}
+	}
+}
+
+// TestAnalyzeRoutes resolves core network routes to segments through the live policy, the reported shared
+// segments and a policy with an isolated segment, and explains the routes that reach nothing
+func TestAnalyzeRoutes(t *testing.T) {
+	const (
+		arn         = "arn:aws:networkmanager::111122223333:core-network/core-network-0a1"
+		noPolicyArn = "arn:aws:networkmanager::111122223333:core-network/core-network-0b2"
+		isolatedArn = "arn:aws:networkmanager::111122223333:core-network/core-network-0c3"
+	)
+	isolated, err := ParsePolicy(`{"segments": [{"name": "quarantine", "isolate-attachments": true}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coreNetworks := []CoreNetworkInfo{
+		{CoreNetworkID: "core-network-0a1", CoreNetworkArn: arn, Policy: loadPolicy(t), Attachments: []AttachmentInfo{
+			{AttachmentID: "attachment-0prod", AttachmentType: "vpc", ResourceID: "vpc-0prod", State: "AVAILABLE", SegmentName: "prod"},
+			{AttachmentID: "attachment-0shared", AttachmentType: "vpc", ResourceID: "vpc-0shared", State: "AVAILABLE", SegmentName: "shared"},
+			{AttachmentID: "attachment-0dev", AttachmentType: "vpc", ResourceID: "vpc-0dev", State: "AVAILABLE", SegmentName: "dev"},
+			{AttachmentID: "attachment-0sandbox", AttachmentType: "vpc", ResourceID: "vpc-0sandbox", State: "PENDING_ATTACHMENT_ACCEPTANCE", ResolvedSegment: "sandbox"},
+			{AttachmentID: "attachment-0new", AttachmentType: "vpc", ResourceID: "vpc-0new", State: "CREATING"},
+			{AttachmentID: "attachment-0connect", AttachmentType: "connect", ResourceID: "vpc-0dev", State: "AVAILABLE", SegmentName: "prod"},
+		}},
+		{CoreNetworkID: "core-network-0b2", CoreNetworkArn: noPolicyArn,
+			Segments: []SegmentInfo{{Name: "legacy", SharedSegments: []string{"hub"}}},
+			Attachments: []AttachmentInfo{
+				{AttachmentID: "attachment-0legacy", AttachmentType: "vpc", ResourceID: "vpc-0legacy", State: "AVAILABLE", SegmentName: "legacy"},
+				{AttachmentID: "attachment-0hub", AttachmentType: "vpc", ResourceID: "vpc-0hub", State: "AVAILABLE", SegmentName: "hub"},
+			}},
+		{CoreNetworkID: "core-network-0c3", CoreNetworkArn: isolatedArn, Policy: isolated, Attachments: []AttachmentInfo{
+			{AttachmentID: "attachment-0quarantine", AttachmentType: "vpc", ResourceID: "vpc-0quarantine", State: "AVAILABLE", SegmentName: "quarantine"},
+		}},
+	}
+	vpcs := []vpc.VPCInfo{
+		{VpcID: "vpc-0prod", CidrBlock: "10.1.0.0/16"},
+		{VpcID: "vpc-0shared", CidrBlock: "10.2.0.0/16"},
+		{VpcID: "vpc-0dev", CidrBlock: "10.3.0.0/16"},
+		{VpcID: "vpc-0sandbox", CidrBlock: "10.4.0.0/16", AssociateCidrBlocks: []string{"100.64.0.0/16"}},
+		{VpcID: "vpc-0legacy", CidrBlock: "172.16.0.0/16"},
+		{VpcID: "vpc-0hub", CidrBlock: "172.17.0.0/16"},
+	}
+	route := func(vpcID, destination, coreNetworkArn string) vpc.RouteTableInfo {
+		return vpc.RouteTableInfo{RouteTableID: "rtb-" + vpcID[4:], VpcID: vpcID, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: vpcID[4:] + "-local", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}},
+			{DestinationCidrBlock: destination, Target: vpc.RouteTarget{Type: vpc.RouteTargetCoreNetwork, ID: coreNetworkArn}},
+		}}
+	}
+	routeTables := []vpc.RouteTableInfo{
+		route("vpc-0prod", "10.0.0.0/8", arn),
+		route("vpc-0sandbox", "10.3.0.0/16", arn),
+		route("vpc-0new", "0.0.0.0/0", arn),
+		route("vpc-0other", "10.0.0.0/8", arn),
+		route("vpc-0prod", "192.168.0.0/16", "arn:aws:networkmanager::444455556666:core-network/core-network-0ff"),
+		route("vpc-0legacy", "172.17.0.0/16", noPolicyArn),
+		route("vpc-0quarantine", "10.0.0.0/8", isolatedArn),
+	}
+
+	want := []CoreNetworkRoute{
+		{RouteTableID: "rtb-0prod", VpcID: "vpc-0prod", Destination: "10.0.0.0/8", CoreNetworkArn: arn, CoreNetworkID: "core-network-0a1",
+			Segment: "prod", ReachableSegments: []string{"inspection", "prod", "shared"}, DestinationVPCs: []string{"vpc-0shared"},
+			Reachable: true, Reason: "reachable via segment prod (shares routes with [inspection prod shared])"},
+		{RouteTableID: "rtb-0sandbox", VpcID: "vpc-0sandbox", Destination: "10.3.0.0/16", CoreNetworkArn: arn, CoreNetworkID: "core-network-0a1",
+			Segment: "sandbox", ReachableSegments: []string{"dev", "shared"}, DestinationVPCs: []string{"vpc-0dev"},
+			Reachable: true, Reason: "reachable via segment sandbox (shares routes with [dev shared])"},
+		{RouteTableID: "rtb-0new", VpcID: "vpc-0new", Destination: "0.0.0.0/0", CoreNetworkArn: arn, CoreNetworkID: "core-network-0a1",
+			ReachableSegments: []string{}, DestinationVPCs: []string{}, Reason: "attachment attachment-0new is not associated with a segment"},
+		{RouteTableID: "rtb-0other", VpcID: "vpc-0other", Destination: "10.0.0.0/8", CoreNetworkArn: arn, CoreNetworkID: "core-network-0a1",
+			ReachableSegments: []string{}, DestinationVPCs: []string{}, Reason: "vpc-0other has no attachment to core network core-network-0a1"},
+		{RouteTableID: "rtb-0prod", VpcID: "vpc-0prod", Destination: "192.168.0.0/16", CoreNetworkArn: "arn:aws:networkmanager::444455556666:core-network/core-network-0ff",
+			ReachableSegments: []string{}, DestinationVPCs: []string{}, Reason: "core network was not found in the Cloud WAN scan"},
+		{RouteTableID: "rtb-0legacy", VpcID: "vpc-0legacy", Destination: "172.17.0.0/16", CoreNetworkArn: noPolicyArn, CoreNetworkID: "core-network-0b2",
+			Segment: "legacy", ReachableSegments: []string{"hub", "legacy"}, DestinationVPCs: []string{"vpc-0hub"},
+			Reachable: true, Reason: "reachable via segment legacy (shares routes with [hub legacy])"},
+		{RouteTableID: "rtb-0quarantine", VpcID: "vpc-0quarantine", Destination: "10.0.0.0/8", CoreNetworkArn: isolatedArn, CoreNetworkID: "core-network-0c3",
+			Segment: "quarantine", ReachableSegments: []string{}, DestinationVPCs: []string{},
+			Reason: "segment quarantine isolates its attachments and shares no routes"},
+	}
+	got := AnalyzeRoutes(routeTables, vpcs, coreNetworks)
+	if len(got) != len(want) {
+		t.Fatalf("got %d core network routes, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("route %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+
+	// A route within the segment alone reads "reachable via segment X"
+	single, err := ParsePolicy(`{"segments": [{"name": "prod"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coreNetworks[0].Policy = single
+	if reason := AnalyzeRoutes(routeTables[:1], vpcs, coreNetworks)[0].Reason; reason != "reachable via segment prod" {
+		t.Errorf("Reason = %q, want reachable via segment prod", reason)
+	}
+}
@@ -0,0 +1,181 @@
+package cloudwan
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"aws-documentor/modules/vpc"
+)
+
+// CoreNetworkRoute describes where a VPC route targeting a core network can deliver traffic
+type CoreNetworkRoute struct {
+	RouteTableID      string   `json:"route_table_id"`     // ID of the route table containing the route
+	VpcID             string   `json:"vpc_id"`             // ID of the VPC of the route table
+	Destination       string   `json:"destination"`        // Destination CIDR block of the route
+	CoreNetworkArn    string   `json:"core_network_arn"`   // ARN of the targeted core network
+	CoreNetworkID     string   `json:"core_network_id"`    // ID of the targeted core network (empty if it was not scanned)
+	Segment           string   `json:"segment"`            // Segment of the VPC's attachment to the core network
+	ReachableSegments []string `json:"reachable_segments"` // Segments whose attachments the route can reach
+	DestinationVPCs   []string `json:"destination_vpcs"`   // Attached VPCs in reachable segments whose CIDR overlaps the destination
+	Reachable         bool     `json:"reachable"`          // Whether the route leads into at least one segment
+	Reason            string   `json:"reason"`             // Human-readable explanation (reachable via segment X, ...)
+}
+
+// AnalyzeRoutes resolves every VPC route that targets a core network to the segments it reaches
+// routeTables: Route tables from the VPC scan
+// vpcs: VPCs from the VPC scan, used to match destination CIDRs to attached VPCs
+// coreNetworks: Scanned core networks with their attachments and policies
+// Returns: One entry per core network route, in route table order
+func AnalyzeRoutes(routeTables []vpc.RouteTableInfo, vpcs []vpc.VPCInfo, coreNetworks []CoreNetworkInfo) []CoreNetworkRoute {
+	routes := []CoreNetworkRoute{}
+
+	byArn := make(map[string]*CoreNetworkInfo)
+	for i := range coreNetworks {
+		byArn[coreNetworks[i].CoreNetworkArn] = &coreNetworks[i]
+	}
+	vpcCIDRs := make(map[string][]string)
+	for _, v := range vpcs {
+		vpcCIDRs[v.VpcID] = append([]string{v.CidrBlock}, v.AssociateCidrBlocks...)
+	}
+
+	for _, rt := range routeTables {
+		for _, route := range rt.Routes {
+			if route.Target.Type != vpc.RouteTargetCoreNetwork {
+				continue
+			}
+			destination := route.DestinationCidrBlock
+			if destination == "" {
+				destination = route.DestinationIpv6Block
+			}
+			coreRoute := CoreNetworkRoute{
+				RouteTableID:      rt.RouteTableID,
+				VpcID:             rt.VpcID,
+				Destination:       destination,
+				CoreNetworkArn:    route.Target.ID,
+				ReachableSegments: []string{},
+				DestinationVPCs:   []string{},
+			}
+
+			coreNetwork, ok := byArn[route.Target.ID]
+			if !ok {
+				coreRoute.Reason = "core network was not found in the Cloud WAN scan"
+				routes = append(routes, coreRoute)
+				continue
+			}
+			coreRoute.CoreNetworkID = coreNetwork.CoreNetworkID
+
+			attachment := coreNetwork.vpcAttachment(rt.VpcID)
+			if attachment == nil {
+				coreRoute.Reason = fmt.Sprintf("%s has no attachment to core network %s", rt.VpcID, coreNetwork.CoreNetworkID)
+				routes = append(routes, coreRoute)
+				continue
+			}
+			coreRoute.Segment = attachment.SegmentName
+			if coreRoute.Segment == "" {
+				coreRoute.Segment = attachment.ResolvedSegment
+			}
+			if coreRoute.Segment == "" {
+				coreRoute.Reason = fmt.Sprintf("attachment %s is not associated with a segment", attachment.AttachmentID)
+				routes = append(routes, coreRoute)
+				continue
+			}
+
+			coreRoute.ReachableSegments = coreNetwork.ReachableSegments(coreRoute.Segment)
+			coreRoute.Reachable = len(coreRoute.ReachableSegments) > 0
+			coreRoute.DestinationVPCs = coreNetwork.destinationVPCs(rt.VpcID, destination, coreRoute.ReachableSegments, vpcCIDRs)
+			switch {
+			case !coreRoute.Reachable:
+				coreRoute.Reason = fmt.Sprintf("segment %s isolates its attachments and shares no routes", coreRoute.Segment)
+			case len(coreRoute.ReachableSegments) == 1 && coreRoute.ReachableSegments[0] == coreRoute.Segment:
+				coreRoute.Reason = fmt.Sprintf("reachable via segment %s", coreRoute.Segment)
+			default:
+				coreRoute.Reason = fmt.Sprintf("reachable via segment %s (shares routes with %v)", coreRoute.Segment, coreRoute.ReachableSegments)
+			}
+			routes = append(routes, coreRoute)
+		}
+	}
+
+	return routes
+}
+
+// ReachableSegments returns the segments an attachment in the given segment can reach
+// The live policy is used when present; otherwise the shared segments reported by GetCoreNetwork
+// segment: Name of the source segment
+// Returns: Sorted segment names
+func (cn *CoreNetworkInfo) ReachableSegments(segment string) []string {
+	if cn.Policy != nil {
+		return cn.Policy.ReachableSegments(segment)
+	}
+
+	reachable := map[string]bool{segment: true}
+	for _, s := range cn.Segments {
+		if s.Name == segment {
+			for _, shared := range s.SharedSegments {
+				reachable[shared] = true
+			}
+		}
+	}
+	result := []string{}
+	for name := range reachable {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// vpcAttachment returns the VPC attachment of a VPC, preferring one that is available
+func (cn *CoreNetworkInfo) vpcAttachment(vpcID string) *AttachmentInfo {
+	var found *AttachmentInfo
+	for i := range cn.Attachments {
+		attachment := &cn.Attachments[i]
+		if attachment.AttachmentType != "vpc" || attachment.ResourceID != vpcID {
+			continue
+		}
+		if attachment.State == "AVAILABLE" {
+			return attachment
+		}
+		if found == nil {
+			found = attachment
+		}
+	}
+	return found
+}
+
+// destinationVPCs lists the VPCs attached in the reachable segments whose CIDR blocks overlap the destination
+func (cn *CoreNetworkInfo) destinationVPCs(sourceVPC, destination string, segments []string, vpcCIDRs map[string][]string) []string {
+	inSegment := make(map[string]bool)
+	for _, segment := range segments {
+		inSegment[segment] = true
+	}
+
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, attachment := range cn.Attachments {
+		if attachment.AttachmentType != "vpc" || attachment.ResourceID == sourceVPC || seen[attachment.ResourceID] {
+			continue
+		}
+		if !inSegment[attachment.SegmentName] {
+			continue
+		}
+		for _, cidr := range vpcCIDRs[attachment.ResourceID] {
+			if cidrsOverlap(destination, cidr) {
+				seen[attachment.ResourceID] = true
+				result = append(result, attachment.ResourceID)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// cidrsOverlap reports whether two CIDR blocks share any address
+func cidrsOverlap(a, b string) bool {
+	_, netA, errA := net.ParseCIDR(a)
+	_, netB, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return netA.Contains(netB.IP) || netB.Contains(netA.IP)
+}
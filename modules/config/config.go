@@ -0,0 +1,152 @@
+// Package config provides validation of command-line flags and configuration files without making AWS calls
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Problem is a single validation failure
+type Problem struct {
+	Source  string // Flag name (-pdf) or file path the problem was found in
+	Line    int    // Line in the file (0 when unknown or not a file)
+	Message string // Description of the problem
+}
+
+// String formats the problem as source:line: message, leaving out the line when unknown
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.Source, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Source, p.Message)
+}
+
+// Problems is a list of validation failures reported together
+type Problems []Problem
+
+// Error lists every problem on its own line
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = problem.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validator collects problems so that all of them can be reported at once instead of stopping at the first
+type Validator struct {
+	problems Problems
+}
+
+// Addf records a problem
+// source: Flag name or file path
+// line: Line in the file, 0 when unknown
+func (v *Validator) Addf(source string, line int, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Source: source, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// Check records err as a problem of source when it is not nil
+func (v *Validator) Check(source string, err error) {
+	if err != nil {
+		v.Addf(source, 0, "%v", err)
+	}
+}
+
+// Problems returns the problems recorded so far in the order they were found
+func (v *Validator) Problems() Problems {
+	return v.problems
+}
+
+// Err returns the recorded problems as an error, or nil when there are none
+func (v *Validator) Err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+// regionPattern matches region names such as us-east-1, eu-central-2 and us-gov-west-1
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+$`)
+
+// CheckRegion reports whether name looks like an AWS region name
+// Only the shape is checked; whether the region exists or is enabled needs an AWS call.
+func CheckRegion(name string) error {
+	if !regionPattern.MatchString(name) {
+		return fmt.Errorf("%q is not a region name (expected a name like us-east-1)", name)
+	}
+	return nil
+}
+
+// CheckRoleARN reports whether arn looks like the ARN of an IAM role
+// Only the shape is checked; whether the role exists and can be assumed needs an AWS call.
+func CheckRoleARN(arn string) error {
+	if !strings.HasPrefix(arn, "arn:") || !strings.Contains(arn, ":role/") {
+		return fmt.Errorf("%q is not a role ARN (expected arn:aws:iam::<account>:role/<name>)", arn)
+	}
+	return nil
+}
+
+// CheckOutputFile reports whether a file can be created at path: its directory must exist and path must not be a directory
+func CheckOutputFile(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("directory %s does not exist", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
+
+// CheckOutputDir reports whether path can be used as an output directory
+// Missing directories are created by the tool, so only the nearest existing ancestor has to be a directory.
+func CheckOutputDir(path string) error {
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s exists and is not a directory", dir)
+			}
+			return nil
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return nil
+		}
+	}
+}
+
+// ParseWindow parses a look-back window such as 90d, 12w or a Go duration such as 36h
+// Days and weeks are not Go duration units, so they are handled here.
+// Returns: Length of the window, or error if s is not a positive duration
+func ParseWindow(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(s, "d") || strings.HasSuffix(s, "w"):
+		n, convErr := strconv.Atoi(s[:len(s)-1])
+		if convErr != nil {
+			return 0, fmt.Errorf("%q is not a window (expected a value like 90d, 12w or 36h)", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+		if strings.HasSuffix(s, "w") {
+			d *= 7
+		}
+	default:
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("%q is not a window (expected a value like 90d, 12w or 36h)", s)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%q is not a positive window", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,469 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/analysis"
+)
+
+// writeFile writes a config file into a temporary directory
+// Returns: Path of the file
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testOutputFlags are the outputs a project file may set in the tests
+var testOutputFlags = []string{"graph-out", "pdf"}
+
+// TestMalformedFiles feeds a malformed file of every config type to its validator and checks the
+// problems reported together, each with its line; {path} in the want lines stands for the file
+func TestMalformedFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		validate func(v *Validator, path string)
+		want     []string
+	}{
+		{
+			name: "policy",
+			content: `{
+  "unmatched": "ignore",
+  "rules": [
+    {"name": "tags", "kinds": ["renamed"], "action": "block"},
+    {"action": "warn", "severity": "high"}
+  ],
+  "mode": "strict"
+}`,
+			validate: (*Validator).PolicyFile,
+			want: []string{
+				`{path}:5: rules[1]: unknown key "severity"`,
+				`{path}:7: unknown key "mode"`,
+				`{path}:2: unmatched: unknown action "ignore" (expected allow, warn or fail)`,
+				`{path}:4: tags: unknown action "block" (expected allow, warn or fail)`,
+				`{path}:4: tags: unknown change kind "renamed" (expected added, removed or modified)`,
+			},
+		},
+		{
+			name: "policy syntax error",
+			content: `{
+  "rules": [
+    {"action": "warn",}
+  ]
+}`,
+			validate: (*Validator).PolicyFile,
+			want:     []string{`{path}:3: invalid JSON: invalid character ',' looking for beginning of value`},
+		},
+		{
+			name: "policy wrong type",
+			content: `{
+  "rules": [
+    {"action": "warn", "kinds": "added"}
+  ]
+}`,
+			validate: (*Validator).PolicyFile,
+			want:     []string{`{path}:3: rules.0.kinds: expected []string, found string`},
+		},
+		{
+			name: "managed-by rules",
+			content: `{
+  "rules": [
+    {"tag_key": "aws:cloudformation:stack-name"},
+    {"manager": "Terraform"},
+    {"manager": "Pulumi", "tag_value": "pulumi-*", "owner": "platform"}
+  ]
+}`,
+			validate: (*Validator).ManagerRulesFile,
+			want: []string{
+				`{path}:5: rules[2]: unknown key "owner"`,
+				`{path}:3: rule 1: manager is required`,
+				`{path}:4: rule 2 (Terraform): needs a tag_key or description to match anything`,
+				`{path}:5: rule 3 (Pulumi): needs a tag_key or description to match anything`,
+				`{path}:5: rule 3 (Pulumi): tag_value needs a tag_key`,
+			},
+		},
+		{
+			name: "path properties",
+			content: `{
+  "links": [
+    {"link": "satellite", "mtu": 1500},
+    {"link": "vpn", "mtu": 1436},
+    {"link": "vpn",
+     "mtu": 65535,
+     "bandwidth_cap_gbps": -1.25}
+  ],
+  "mtu_threshold": 10000
+}`,
+			validate: (*Validator).PathPropertiesFile,
+			want: []string{
+				`{path}:3: unknown link type "satellite"`,
+				`{path}:5: vpn: link type is listed twice`,
+				`{path}:6: vpn: mtu must be between 576 and 9001`,
+				`{path}:7: vpn: bandwidth_cap_gbps must not be negative`,
+				`{path}:9: mtu_threshold must be between 0 and 9001`,
+			},
+		},
+		{
+			name: "named ranges",
+			content: `{
+  "ranges": [
+    {"name": "office", "cidrs": ["192.0.2.0/24"]},
+    {"name": "office", "cidrs": ["198.51.100.0/33", "2001:db8::/129"]},
+    {"kind": "vpn"},
+    {"name": "partner", "cidrs": [], "trusted": true}
+  ]
+}`,
+			validate: (*Validator).NamedRangesFile,
+			want: []string{
+				`{path}:6: ranges[3]: unknown key "trusted"`,
+				`{path}:4: office: name is listed twice`,
+				`{path}:4: office: invalid CIDR block "198.51.100.0/33": invalid CIDR address: 198.51.100.0/33`,
+				`{path}:4: office: invalid CIDR block "2001:db8::/129": invalid CIDR address: 2001:db8::/129`,
+				`{path}:5: range 3: name is required`,
+				`{path}:5: range 3: needs cidrs to match anything`,
+				`{path}:6: partner: needs cidrs to match anything`,
+			},
+		},
+		{
+			name: "SaaS catalog",
+			content: `{
+  "providers": [
+    {"vendor": "Snowflake", "service_names": ["com.amazonaws.vpce.*.vpce-svc-[abc"]},
+    {"service_names": ["com.amazonaws.vpce.*"]},
+    {"vendor": "Datadog"}
+  ],
+  "internal_accounts": "111122223333"
+}`,
+			validate: (*Validator).SaaSCatalogFile,
+			want:     []string{`{path}:7: internal_accounts: expected []string, found string`},
+		},
+		{
+			name: "SaaS catalog providers",
+			content: `{
+  "providers": [
+    {"vendor": "Snowflake", "service_names": ["com.amazonaws.vpce.*.vpce-svc-[abc"]},
+    {"service_names": ["com.amazonaws.vpce.*"], "domains": ["example.com"]},
+    {"vendor": "Datadog"}
+  ]
+}`,
+			validate: (*Validator).SaaSCatalogFile,
+			want: []string{
+				`{path}:4: providers[1]: unknown key "domains"`,
+				`{path}:3: Snowflake: invalid service name pattern "com.amazonaws.vpce.*.vpce-svc-[abc"`,
+				`{path}:4: provider 2: vendor is required`,
+				`{path}:5: Datadog: needs service_names or dns_domains to match anything`,
+			},
+		},
+		{
+			name: "project",
+			content: `{
+  "defaults": {
+    "regions": ["eu-west-1"],
+    "outputs": {"pdf": "report.pdf"},
+    "region": "eu-west-1"
+  },
+  "targets": {
+    "prod eu": {"role_arn": "arn:aws:iam::111122223333:user/deploy"},
+    "stage": {
+      "regions": ["eu-west-1", "eu-west-1", "Frankfurt"],
+      "outputs": {"graph-out": "../graph.json", "html": "index.html"},
+      "flags": {"-diagram": "true", "region": "us-east-1", "pdf": "x.pdf"},
+      "policy_files": ["missing.json"]
+    },
+    "dev": {"regions": []}
+  }
+}`,
+			validate: func(v *Validator, path string) { v.ProjectFile(path, testOutputFlags) },
+			want: []string{
+				`{path}:5: defaults: unknown key "region"`,
+				`{path}:15: dev: regions is required`,
+				`{path}:8: target "prod eu": names may only contain letters, digits, - and _`,
+				`{path}:8: prod eu: "arn:aws:iam::111122223333:user/deploy" is not a role ARN (expected arn:aws:iam::<account>:role/<name>)`,
+				`{path}:10: stage: region eu-west-1 is listed twice`,
+				`{path}:10: stage: "Frankfurt" is not a region name (expected a name like us-east-1)`,
+				`{path}:11: stage: output graph-out must be a relative path inside the directory of the region, not "../graph.json"`,
+				`{path}:11: stage: unknown output "html" (known: graph-out, pdf)`,
+				`{path}:12: stage: flag "-diagram" must be given without the dash`,
+				`{path}:12: stage: pdf is an output; set it in outputs`,
+				`{path}:12: stage: flag region is set by the run subcommand`,
+				`{dir}/missing.json: cannot read policy: open {dir}/missing.json: no such file or directory`,
+			},
+		},
+		{
+			name: "project target keys",
+			content: `{
+  "targets": {
+    "prod.eu": {"regions": ["eu-west-1"], "theme": "dark"},
+    "prod": {
+      "regions": ["eu-west-1"],
+      "outputs": {"pdf": "prod.pdf"},
+      "filters": {"tag": "env=prod"}
+    }
+  }
+}`,
+			validate: func(v *Validator, path string) { v.ProjectFile(path, testOutputFlags) },
+			want: []string{
+				`{path}:3: targets[prod.eu]: unknown key "theme"`,
+				`{path}:7: targets[prod]: unknown key "filters"`,
+				`{path}:3: target "prod.eu": names may only contain letters, digits, - and _`,
+			},
+		},
+		{
+			name:     "project without targets",
+			content:  `{"output_dir": "docs", "targets": {}}`,
+			validate: func(v *Validator, path string) { v.ProjectFile(path, testOutputFlags) },
+			want:     []string{`{path}:1: no targets defined`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeFile(t, dir, "config.json", tt.content)
+			v := &Validator{}
+			tt.validate(v, path)
+
+			want := strings.NewReplacer("{path}", path, "{dir}", dir).Replace(strings.Join(tt.want, "\n"))
+			err := v.Err()
+			if err == nil {
+				t.Fatalf("no problems reported, want:\n%s", want)
+			}
+			if err.Error() != want {
+				t.Errorf("problems:\n%s\nwant:\n%s", err, want)
+			}
+		})
+	}
+}
+
+// TestValidatorAggregates checks that one validator reports the problems of every file and flag it
+// checked, in order, instead of stopping at the first, and that valid files add none
+func TestValidatorAggregates(t *testing.T) {
+	dir := t.TempDir()
+	policy := writeFile(t, dir, "policy.json", `{"rules": [{"action": "warn", "kinds": ["added"]}]}`)
+	ranges := writeFile(t, dir, "ranges.json", "{\n  \"ranges\": [{\"name\": \"lab\", \"cidrs\": [\"10.0.0.0/8\"]}],\n  \"extra\": 1\n}")
+	broken := writeFile(t, dir, "managers.json", "{\n  \"rules\": [\n")
+
+	v := &Validator{}
+	v.PolicyFile(policy)
+	v.Check("-region", CheckRegion("eu-west-1"))
+	v.NamedRangesFile(ranges)
+	v.Check("-region", CheckRegion("mars-north-1a"))
+	v.ManagerRulesFile(broken)
+	v.SaaSCatalogFile(filepath.Join(dir, "catalog.json"))
+
+	want := Problems{
+		{Source: ranges, Line: 3, Message: `unknown key "extra"`},
+		{Source: "-region", Message: `"mars-north-1a" is not a region name (expected a name like us-east-1)`},
+		{Source: broken, Line: 3, Message: "invalid JSON: unexpected end of JSON input"},
+		{Source: filepath.Join(dir, "catalog.json"), Message: "cannot read SaaS catalog: open " + filepath.Join(dir, "catalog.json") + ": no such file or directory"},
+	}
+	got := v.Problems()
+	if len(got) != len(want) {
+		t.Fatalf("got %d problems, want %d:\n%s", len(got), len(want), got.Error())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("problem %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if lines := strings.Split(v.Err().Error(), "\n"); len(lines) != len(want) || lines[1] != `-region: "mars-north-1a" is not a region name (expected a name like us-east-1)` {
+		t.Errorf("Err() = %q, want one line per problem without a line number for flags", lines)
+	}
+
+	if err := (&Validator{}).Err(); err != nil {
+		t.Errorf("Err() of an empty validator = %v, want nil", err)
+	}
+}
+
+// TestLoadRejectsMalformed checks that the loaders return every problem of a malformed file and load a valid one
+func TestLoadRejectsMalformed(t *testing.T) {
+	dir := t.TempDir()
+	malformed := writeFile(t, dir, "ranges.json", `{"ranges": [{"cidrs": ["10.0.0.0/40"]}]}`)
+	if _, err := LoadNamedRanges(malformed); err == nil || strings.Count(err.Error(), "\n") != 1 {
+		t.Errorf("LoadNamedRanges() = %v, want two problems", err)
+	}
+
+	valid := writeFile(t, dir, "paths.json", `{"links": [{"link": "vpn", "mtu": 1400}], "mtu_threshold": 1300}`)
+	properties, err := LoadPathProperties(valid)
+	if err != nil {
+		t.Fatalf("LoadPathProperties() = %v", err)
+	}
+	if properties.MTUThreshold != 1300 {
+		t.Errorf("MTUThreshold = %d, want 1300", properties.MTUThreshold)
+	}
+
+	project := writeFile(t, dir, "aws-documentor.json", `{"targets": {"prod": {"regions": ["eu-west-1"]}}}`)
+	loaded, err := LoadProject(project, testOutputFlags)
+	if err != nil {
+		t.Fatalf("LoadProject() = %v", err)
+	}
+	if loaded.OutputDir != DefaultProjectOutputDir || loaded.Dir != dir {
+		t.Errorf("LoadProject() = output_dir %q in %q, want %q in %q", loaded.OutputDir, loaded.Dir, DefaultProjectOutputDir, dir)
+	}
+}
+
+// TestLoadSaaSCatalog checks that user providers are merged ahead of the built-in ones and that
+// internal accounts are carried over
+func TestLoadSaaSCatalog(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "catalog.json", `{
+	"providers": [{"vendor": "Acme Metrics", "service_names": ["vpce-svc-0acme*"]},
+		{"vendor": "Datadog EU", "dns_domains": ["datadoghq.eu"]}],
+	"internal_accounts": ["222233334444"]
+}`)
+	catalog, err := LoadSaaSCatalog(path)
+	if err != nil {
+		t.Fatalf("LoadSaaSCatalog() = %v", err)
+	}
+	builtIn := analysis.DefaultSaaSCatalog()
+	if len(catalog.Providers) != len(builtIn.Providers)+2 {
+		t.Fatalf("got %d providers, want the %d built-in ones and 2 user ones", len(catalog.Providers), len(builtIn.Providers))
+	}
+	if catalog.Providers[0].Vendor != "Acme Metrics" || catalog.Providers[1].Vendor != "Datadog EU" {
+		t.Errorf("first providers = %q, %q, want the user providers in file order", catalog.Providers[0].Vendor, catalog.Providers[1].Vendor)
+	}
+	if catalog.Providers[2].Vendor != builtIn.Providers[0].Vendor {
+		t.Errorf("provider after the user ones = %q, want built-in %q", catalog.Providers[2].Vendor, builtIn.Providers[0].Vendor)
+	}
+	if len(catalog.InternalAccounts) != 1 || catalog.InternalAccounts[0] != "222233334444" {
+		t.Errorf("InternalAccounts = %v, want [222233334444]", catalog.InternalAccounts)
+	}
+}
+
+// TestFlagChecks covers the checks of flag values that need no AWS call
+func TestFlagChecks(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "existing.txt", "")
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "region", err: CheckRegion("eu-central-2")},
+		{name: "GovCloud region", err: CheckRegion("us-gov-west-1")},
+		{name: "ISO region", err: CheckRegion("us-isob-east-1")},
+		{name: "availability zone", err: CheckRegion("eu-west-1a"), wantErr: true},
+		{name: "upper-case region", err: CheckRegion("EU-WEST-1"), wantErr: true},
+		{name: "role ARN", err: CheckRoleARN("arn:aws:iam::111122223333:role/documentor")},
+		{name: "user ARN", err: CheckRoleARN("arn:aws:iam::111122223333:user/documentor"), wantErr: true},
+		{name: "role name only", err: CheckRoleARN("documentor"), wantErr: true},
+		{name: "output file", err: CheckOutputFile(filepath.Join(dir, "report.pdf"))},
+		{name: "output file is a directory", err: CheckOutputFile(dir), wantErr: true},
+		{name: "output file in a missing directory", err: CheckOutputFile(filepath.Join(dir, "missing", "report.pdf")), wantErr: true},
+		{name: "output file below a file", err: CheckOutputFile(filepath.Join(file, "report.pdf")), wantErr: true},
+		{name: "new output directory", err: CheckOutputDir(filepath.Join(dir, "a", "b"))},
+		{name: "output directory below a file", err: CheckOutputDir(filepath.Join(file, "docs")), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", tt.err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestParseWindow covers days, weeks, Go durations and values that are not positive windows
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "90d", want: 90 * 24 * time.Hour},
+		{input: "12w", want: 12 * 7 * 24 * time.Hour},
+		{input: "36h", want: 36 * time.Hour},
+		{input: "0d", wantErr: true},
+		{input: "-1w", wantErr: true},
+		{input: "d", wantErr: true},
+		{input: "ninety days", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseWindow(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseWindow(%q) = %v, %v; want %v, error %v", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestProjectTarget checks how targets inherit the defaults block: profile and role when left empty, lists
+// replaced when set, even to an empty list, and outputs and flags merged key by key with an empty value
+// removing the inherited one
+func TestProjectTarget(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "policy.json", `{"rules": [{"action": "warn", "kinds": ["added"]}]}`)
+	writeFile(t, dir, "prod-policy.json", `{"rules": [{"action": "fail", "kinds": ["removed"]}]}`)
+	path := writeFile(t, dir, "aws-documentor.json", `{
+  "output_dir": "site",
+  "defaults": {
+    "profile": "net-dev",
+    "role_arn": "arn:aws:iam::111122223333:role/documentor",
+    "regions": ["eu-west-1"],
+    "outputs": {"pdf": "report.pdf", "graph-out": "graph"},
+    "flags": {"diagram": "true", "dns": "true"},
+    "policy_files": ["policy.json"]
+  },
+  "targets": {
+    "dev": {},
+    "prod": {
+      "profile": "net-prod",
+      "regions": ["eu-west-1", "us-east-1"],
+      "outputs": {"pdf": "prod.pdf", "graph-out": ""},
+      "flags": {"dns": "", "hide-system-tags": "true"},
+      "policy_files": ["prod-policy.json"]
+    },
+    "sandbox": {"role_arn": "arn:aws:iam::444455556666:role/documentor", "policy_files": []}
+  }
+}`)
+	project, err := LoadProject(path, testOutputFlags)
+	if err != nil {
+		t.Fatalf("LoadProject() = %v", err)
+	}
+	if project.OutputDir != "site" || project.Path("policy.json") != filepath.Join(dir, "policy.json") || project.Path("/abs/policy.json") != "/abs/policy.json" {
+		t.Errorf("output_dir %q, paths %q and %q", project.OutputDir, project.Path("policy.json"), project.Path("/abs/policy.json"))
+	}
+	if names := project.TargetNames(); !reflect.DeepEqual(names, []string{"dev", "prod", "sandbox"}) {
+		t.Errorf("TargetNames() = %v", names)
+	}
+
+	tests := []struct {
+		name string
+		want ProjectTarget
+	}{
+		{name: "dev", want: ProjectTarget{Profile: "net-dev", RoleARN: "arn:aws:iam::111122223333:role/documentor", Regions: []string{"eu-west-1"},
+			Outputs: map[string]string{"pdf": "report.pdf", "graph-out": "graph"}, Flags: map[string]string{"diagram": "true", "dns": "true"},
+			PolicyFiles: []string{"policy.json"}}},
+		{name: "prod", want: ProjectTarget{Profile: "net-prod", RoleARN: "arn:aws:iam::111122223333:role/documentor", Regions: []string{"eu-west-1", "us-east-1"},
+			Outputs: map[string]string{"pdf": "prod.pdf"}, Flags: map[string]string{"diagram": "true", "hide-system-tags": "true"},
+			PolicyFiles: []string{"prod-policy.json"}}},
+		{name: "sandbox", want: ProjectTarget{Profile: "net-dev", RoleARN: "arn:aws:iam::444455556666:role/documentor", Regions: []string{"eu-west-1"},
+			Outputs: map[string]string{"pdf": "report.pdf", "graph-out": "graph"}, Flags: map[string]string{"diagram": "true", "dns": "true"},
+			PolicyFiles: []string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := project.Target(tt.name)
+			if !ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Target(%q) = %+v, %v\nwant %+v", tt.name, got, ok, tt.want)
+			}
+		})
+	}
+
+	dev, _ := project.Target("dev")
+	dev.Outputs["pdf"] = "changed.pdf"
+	if again, _ := project.Target("dev"); again.Outputs["pdf"] != "report.pdf" {
+		t.Errorf("changing a resolved target changed the defaults: pdf = %q", again.Outputs["pdf"])
+	}
+	if _, ok := project.Target("stage"); ok {
+		t.Error("Target(\"stage\") found a target the project does not define")
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+
+	"aws-documentor/modules/analysis"
+)
+
+// ManagerRulesFile checks a managed-by rules file and records every problem in it
+// Besides syntax errors, unknown keys and values of the wrong type, every rule needs a manager and a
+// tag key or description pattern, and a tag value pattern only makes sense with a tag key.
+// path: Path of the managed-by rules file
+func (v *Validator) ManagerRulesFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.Addf(path, 0, "cannot read managed-by rules: %v", err)
+		return
+	}
+
+	keys, err := keyLines(data)
+	if err != nil {
+		v.Addf(path, lineOf(data, errorOffset(err)), "invalid JSON: %v", err)
+		return
+	}
+	v.unknownKeys(path, keys, reflect.TypeOf(analysis.ManagerRules{}), map[string]reflect.Type{"rules": reflect.TypeOf(analysis.ManagerRule{})})
+
+	var rules analysis.ManagerRules
+	if !v.decode(path, data, &rules) {
+		return
+	}
+
+	for i, rule := range rules.Rules {
+		prefix := "rules." + strconv.Itoa(i) + "."
+		name := fmt.Sprintf("rule %d", i+1)
+		if rule.Manager == "" {
+			v.Addf(path, firstKeyLine(keys, prefix), "%s: manager is required", name)
+		} else {
+			name += " (" + rule.Manager + ")"
+		}
+		if rule.TagKey == "" && rule.Description == "" {
+			v.Addf(path, firstKeyLine(keys, prefix), "%s: needs a tag_key or description to match anything", name)
+		}
+		if rule.TagValue != "" && rule.TagKey == "" {
+			v.Addf(path, keyLine(keys, prefix+"tag_value"), "%s: tag_value needs a tag_key", name)
+		}
+	}
+}
+
+// LoadManagerRules reads a managed-by rules file
+// path: Path of the managed-by rules file
+// Returns: The rules, or every problem found in the file
+func LoadManagerRules(path string) (*analysis.ManagerRules, error) {
+	v := &Validator{}
+	v.ManagerRulesFile(path)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules analysis.ManagerRules
+	if !v.decode(path, data, &rules) {
+		return nil, v.Err()
+	}
+	return &rules, nil
+}
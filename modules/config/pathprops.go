@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+
+	"aws-documentor/modules/analysis"
+)
+
+// Bounds of a link MTU: the IPv4 minimum and the largest jumbo frame AWS supports
+const (
+	minLinkMTU = 576
+	maxLinkMTU = 9001
+)
+
+// PathPropertiesFile checks a path properties file and records every problem in it
+// Besides syntax errors, unknown keys and values of the wrong type, links need a known link type
+// and an MTU between 576 and 9001, bandwidth caps must not be negative and every link type may
+// appear only once.
+// path: Path of the path properties file
+func (v *Validator) PathPropertiesFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.Addf(path, 0, "cannot read path properties: %v", err)
+		return
+	}
+
+	keys, err := keyLines(data)
+	if err != nil {
+		v.Addf(path, lineOf(data, errorOffset(err)), "invalid JSON: %v", err)
+		return
+	}
+	v.unknownKeys(path, keys, reflect.TypeOf(analysis.PathProperties{}), map[string]reflect.Type{"links": reflect.TypeOf(analysis.LinkProperties{})})
+
+	var properties analysis.PathProperties
+	if !v.decode(path, data, &properties) {
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, link := range analysis.DefaultPathProperties().Links {
+		known[link.Link] = true
+	}
+	seen := make(map[string]bool)
+	for i, link := range properties.Links {
+		prefix := "links." + strconv.Itoa(i) + "."
+		switch {
+		case !known[link.Link]:
+			v.Addf(path, firstKeyLine(keys, prefix), "unknown link type %q", link.Link)
+		case seen[link.Link]:
+			v.Addf(path, firstKeyLine(keys, prefix), "%s: link type is listed twice", link.Link)
+		}
+		seen[link.Link] = true
+		if link.MTU < minLinkMTU || link.MTU > maxLinkMTU {
+			v.Addf(path, keyLine(keys, prefix+"mtu"), "%s: mtu must be between %d and %d", link.Link, minLinkMTU, maxLinkMTU)
+		}
+		if link.BandwidthCapGbps < 0 {
+			v.Addf(path, keyLine(keys, prefix+"bandwidth_cap_gbps"), "%s: bandwidth_cap_gbps must not be negative", link.Link)
+		}
+	}
+	if properties.MTUThreshold < 0 || properties.MTUThreshold > maxLinkMTU {
+		v.Addf(path, keyLine(keys, "mtu_threshold"), "mtu_threshold must be between 0 and %d", maxLinkMTU)
+	}
+}
+
+// LoadPathProperties reads a path properties file and merges it into the built-in link table
+// path: Path of the path properties file
+// Returns: The combined properties, or every problem found in the file
+func LoadPathProperties(path string) (*analysis.PathProperties, error) {
+	v := &Validator{}
+	v.PathPropertiesFile(path)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var properties analysis.PathProperties
+	if !v.decode(path, data, &properties) {
+		return nil, v.Err()
+	}
+	return analysis.DefaultPathProperties().Merge(&properties), nil
+}
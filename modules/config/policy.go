@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"aws-documentor/modules/changepolicy"
+)
+
+// PolicyFile checks a change policy file and records every problem in it
+// Syntax errors, unknown keys, values of the wrong type, unknown actions and unknown change kinds
+// are reported with the line they appear on.
+// path: Path of the policy file
+func (v *Validator) PolicyFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.Addf(path, 0, "cannot read policy: %v", err)
+		return
+	}
+
+	keys, err := keyLines(data)
+	if err != nil {
+		v.Addf(path, lineOf(data, errorOffset(err)), "invalid JSON: %v", err)
+		return
+	}
+
+	// Tag keys are free-form, so only the top level and the rules are checked
+	v.unknownKeys(path, keys, reflect.TypeOf(changepolicy.Policy{}), map[string]reflect.Type{"rules": reflect.TypeOf(changepolicy.Rule{})})
+
+	var policy changepolicy.Policy
+	if !v.decode(path, data, &policy) {
+		return
+	}
+
+	if policy.Unmatched != "" {
+		if err := changepolicy.CheckAction(policy.Unmatched); err != nil {
+			v.Addf(path, keyLine(keys, "unmatched"), "unmatched: %v", err)
+		}
+	}
+	for i, rule := range policy.Rules {
+		line := keyLine(keys, "rules."+strconv.Itoa(i)+".action")
+		for _, problem := range rule.Check() {
+			v.Addf(path, line, "%s: %v", rule.DisplayName(i), problem)
+		}
+	}
+}
+
+// jsonKey is an object key found in a JSON document
+type jsonKey struct {
+	path string // Dot-separated path of the key, with array indexes (rules.2.action)
+	line int    // Line the key is on
+}
+
+// keyLines lists every object key of a JSON document with its path and line
+// Returns: Keys in document order, or the syntax error that stopped the scan
+func keyLines(data []byte) ([]jsonKey, error) {
+	var keys []jsonKey
+
+	// One frame per open object or array: the path to it, and the next array index or pending key
+	type frame struct {
+		path      string
+		array     bool
+		index     int
+		expectKey bool
+	}
+	var stack []*frame
+	child := func(top *frame, name string) string {
+		if top.path == "" {
+			return name
+		}
+		return top.path + "." + name
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var pendingKey string
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			if len(stack) > 0 {
+				// The document ends inside an object or array; Unmarshal reports it with the offset of the end
+				return nil, json.Unmarshal(data, new(interface{}))
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		// A string in key position is a key; everything else is a value of the current slot
+		if top != nil && !top.array && top.expectKey {
+			if key, ok := token.(string); ok {
+				pendingKey = child(top, key)
+				keys = append(keys, jsonKey{path: pendingKey, line: lineOf(data, offset+leadingSpace(data[offset:]))})
+				top.expectKey = false
+				continue
+			}
+		}
+
+		slot := ""
+		if top != nil {
+			if top.array {
+				slot = child(top, strconv.Itoa(top.index))
+			} else {
+				slot = pendingKey
+			}
+		}
+
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, &frame{path: slot, expectKey: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &frame{path: slot, array: true})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+
+		// A value (or a closed container) completes the slot of the enclosing frame
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			if parent.array {
+				parent.index++
+			} else {
+				parent.expectKey = true
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// unknownKeys records every key that is not a field of the top-level type or of the element type of a top-level list
+// path: File the keys are from
+// keys: Keys from keyLines
+// top: Type of the document
+// lists: Element types of the top-level lists of objects, by key
+func (v *Validator) unknownKeys(path string, keys []jsonKey, top reflect.Type, lists map[string]reflect.Type) {
+	topKeys := jsonKeys(top)
+	listKeys := make(map[string]map[string]bool, len(lists))
+	for name, t := range lists {
+		listKeys[name] = jsonKeys(t)
+	}
+
+	for _, key := range keys {
+		parts := strings.Split(key.path, ".")
+		switch {
+		case len(parts) == 1 && !topKeys[parts[0]]:
+			v.Addf(path, key.line, "unknown key %q", parts[0])
+		case len(parts) == 3 && listKeys[parts[0]] != nil && !listKeys[parts[0]][parts[2]]:
+			v.Addf(path, key.line, "%s[%s]: unknown key %q", parts[0], parts[1], parts[2])
+		}
+	}
+}
+
+// decode unmarshals a document, recording a value of the wrong type with its line
+// Returns: Whether decoding succeeded
+func (v *Validator) decode(path string, data []byte, target interface{}) bool {
+	err := json.Unmarshal(data, target)
+	if err == nil {
+		return true
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		v.Addf(path, lineOf(data, typeErr.Offset), "%s: expected %s, found %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		return false
+	}
+	v.Addf(path, 0, "%v", err)
+	return false
+}
+
+// keyLine returns the line of the key at path, or 0 when the document does not contain it
+func keyLine(keys []jsonKey, path string) int {
+	for _, key := range keys {
+		if key.path == path {
+			return key.line
+		}
+	}
+	return 0
+}
+
+// jsonKeys returns the JSON names of the fields of a struct type
+func jsonKeys(t reflect.Type) map[string]bool {
+	keys := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// errorOffset returns the byte offset of a JSON syntax error, or -1 for other errors
+func errorOffset(err error) int64 {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Offset
+	}
+	return -1
+}
+
+// lineOf returns the 1-based line containing the byte at offset, or 0 for a negative offset
+func lineOf(data []byte, offset int64) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// leadingSpace returns the number of whitespace and separator bytes before the next token
+// InputOffset points just past the previous token, so the separator and any newline still follow it
+func leadingSpace(data []byte) int64 {
+	n := 0
+	for n < len(data) && strings.IndexByte(" \t\r\n,:", data[n]) >= 0 {
+		n++
+	}
+	return int64(n)
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultProjectFile is the project file the run subcommand reads by default
+const DefaultProjectFile = "aws-documentor.json"
+
+// DefaultProjectOutputDir holds the target directories and the index page when the project sets no output_dir
+const DefaultProjectOutputDir = "docs"
+
+// reservedProjectFlags are scan flags the run subcommand sets itself or that start a different kind of run
+var reservedProjectFlags = map[string]bool{
+	"region": true, "result-file": true, "validate-only": true, "template-schema": true,
+	"profiles": true, "profile-parallelism": true, "profiles-dir": true,
+}
+
+// targetName matches the names of targets; they become directory names and key paths, so dots are not allowed
+var targetName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ProjectTarget is one environment of a project file, such as dev, stage or prod
+// Every field may also be set in the defaults block; a target inherits the defaults it does not set.
+type ProjectTarget struct {
+	Profile     string            `json:"profile"`      // Profile of the shared AWS config files ("" for the default credentials)
+	RoleARN     string            `json:"role_arn"`     // Role to assume with the profile's credentials ("" for none)
+	Regions     []string          `json:"regions"`      // Regions to scan, one scan each
+	Outputs     map[string]string `json:"outputs"`      // Output flag (pdf, graph-out, ...) -> file or directory, relative to the directory of the region
+	Flags       map[string]string `json:"flags"`        // Further scan flags without the dash -> value ("true" for switches such as diagram)
+	PolicyFiles []string          `json:"policy_files"` // Change policy files checked before anything is scanned
+}
+
+// Project is a project file: named targets that share a defaults block
+type Project struct {
+	OutputDir string                   `json:"output_dir"` // Directory of the target directories and the index page (default: docs)
+	Defaults  ProjectTarget            `json:"defaults"`   // Settings every target inherits
+	Targets   map[string]ProjectTarget `json:"targets"`    // Targets by name
+	Dir       string                   `json:"-"`          // Directory of the project file, which relative paths in it are resolved against
+}
+
+// TargetNames returns the names of the targets in order
+func (p *Project) TargetNames() []string {
+	names := make([]string, 0, len(p.Targets))
+	for name := range p.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Target returns a target with the defaults applied
+// Profile and role take the default when the target leaves them empty, lists replace the default
+// list when set, and the outputs and flags maps are merged key by key; an empty value in the
+// target removes an inherited output or flag.
+// Returns: The target, and whether the project defines it
+func (p *Project) Target(name string) (ProjectTarget, bool) {
+	target, ok := p.Targets[name]
+	if !ok {
+		return ProjectTarget{}, false
+	}
+	resolved := ProjectTarget{
+		Profile:     firstNonEmpty(target.Profile, p.Defaults.Profile),
+		RoleARN:     firstNonEmpty(target.RoleARN, p.Defaults.RoleARN),
+		Regions:     p.Defaults.Regions,
+		Outputs:     mergeSettings(p.Defaults.Outputs, target.Outputs),
+		Flags:       mergeSettings(p.Defaults.Flags, target.Flags),
+		PolicyFiles: p.Defaults.PolicyFiles,
+	}
+	if target.Regions != nil {
+		resolved.Regions = target.Regions
+	}
+	if target.PolicyFiles != nil {
+		resolved.PolicyFiles = target.PolicyFiles
+	}
+	return resolved, true
+}
+
+// Path resolves a path of the project file against the directory of the file
+func (p *Project) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.Dir, path)
+}
+
+// ProjectFile checks a project file and records every problem in it
+// Besides syntax errors, unknown keys and values of the wrong type, every target is checked with its
+// defaults applied: it needs at least one region, outputs must be known output flags with a path
+// inside the directory of the region, flags must not be ones the run sets itself, and its policy
+// files must be valid change policies. Whether the flags exist is left to the scan's own validation.
+// path: Path of the project file
+// outputFlags: Scan flags the outputs block may set (pdf, graph-out, ...)
+func (v *Validator) ProjectFile(path string, outputFlags []string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.Addf(path, 0, "cannot read project: %v", err)
+		return
+	}
+
+	keys, err := keyLines(data)
+	if err != nil {
+		v.Addf(path, lineOf(data, errorOffset(err)), "invalid JSON: %v", err)
+		return
+	}
+	v.unknownKeys(path, keys, reflect.TypeOf(Project{}), nil)
+	targetKeys := jsonKeys(reflect.TypeOf(ProjectTarget{}))
+	for _, key := range keys {
+		if parts := strings.Split(key.path, "."); len(parts) == 2 && parts[0] == "defaults" && !targetKeys[parts[1]] {
+			v.Addf(path, key.line, "defaults: unknown key %q", parts[1])
+		}
+	}
+	// A dot in a target name splits key paths like nesting does, so the keys of a target are found by
+	// the longest name they start with rather than by position; the invalid name is reported below
+	var named struct {
+		Targets map[string]json.RawMessage `json:"targets"`
+	}
+	json.Unmarshal(data, &named)
+	for _, key := range keys {
+		name, inTargets := strings.CutPrefix(key.path, "targets.")
+		if _, isName := named.Targets[name]; !inTargets || isName {
+			continue
+		}
+		target, field := "", ""
+		for name := range named.Targets {
+			if rest, ok := strings.CutPrefix(key.path, "targets."+name+"."); ok && len(name) > len(target) {
+				target, field = name, rest
+			}
+		}
+		if target != "" && !strings.Contains(field, ".") && !targetKeys[field] {
+			v.Addf(path, key.line, "targets[%s]: unknown key %q", target, field)
+		}
+	}
+
+	project := &Project{Dir: filepath.Dir(path)}
+	if !v.decode(path, data, project) {
+		return
+	}
+	if len(project.Targets) == 0 {
+		v.Addf(path, keyLine(keys, "targets"), "no targets defined")
+	}
+
+	checkedPolicies := make(map[string]bool)
+	knownOutputs := make(map[string]bool, len(outputFlags))
+	for _, name := range outputFlags {
+		knownOutputs[name] = true
+	}
+	for _, name := range project.TargetNames() {
+		prefix := "targets." + name + "."
+		// Problems of an inherited setting are reported at the defaults block
+		line := func(field string) int {
+			if l := keyLine(keys, prefix+field); l > 0 {
+				return l
+			}
+			if l := keyLine(keys, "defaults."+field); l > 0 {
+				return l
+			}
+			return keyLine(keys, "targets."+name)
+		}
+		if !targetName.MatchString(name) {
+			v.Addf(path, keyLine(keys, "targets."+name), "target %q: names may only contain letters, digits, - and _", name)
+		}
+		target, _ := project.Target(name)
+
+		if len(target.Regions) == 0 {
+			v.Addf(path, line("regions"), "%s: regions is required", name)
+		}
+		seen := make(map[string]bool)
+		for _, region := range target.Regions {
+			if err := CheckRegion(region); err != nil {
+				v.Addf(path, line("regions"), "%s: %v", name, err)
+			} else if seen[region] {
+				v.Addf(path, line("regions"), "%s: region %s is listed twice", name, region)
+			}
+			seen[region] = true
+		}
+		if target.RoleARN != "" {
+			if err := CheckRoleARN(target.RoleARN); err != nil {
+				v.Addf(path, line("role_arn"), "%s: %v", name, err)
+			}
+		}
+		for _, output := range sortedSettings(target.Outputs) {
+			destination := target.Outputs[output]
+			switch {
+			case !knownOutputs[output]:
+				v.Addf(path, line("outputs"), "%s: unknown output %q (known: %s)", name, output, strings.Join(outputFlags, ", "))
+			case !filepath.IsLocal(destination):
+				v.Addf(path, line("outputs"), "%s: output %s must be a relative path inside the directory of the region, not %q", name, output, destination)
+			}
+		}
+		for _, flagName := range sortedSettings(target.Flags) {
+			switch {
+			case strings.HasPrefix(flagName, "-"):
+				v.Addf(path, line("flags"), "%s: flag %q must be given without the dash", name, flagName)
+			case reservedProjectFlags[flagName]:
+				v.Addf(path, line("flags"), "%s: flag %s is set by the run subcommand", name, flagName)
+			case knownOutputs[flagName]:
+				v.Addf(path, line("flags"), "%s: %s is an output; set it in outputs", name, flagName)
+			}
+		}
+		for _, policyFile := range target.PolicyFiles {
+			if policyPath := project.Path(policyFile); !checkedPolicies[policyPath] {
+				checkedPolicies[policyPath] = true
+				v.PolicyFile(policyPath)
+			}
+		}
+	}
+}
+
+// LoadProject reads a project file
+// path: Path of the project file
+// outputFlags: Scan flags the outputs block may set
+// Returns: The project with Dir set and a default output_dir, or every problem found in the file
+func LoadProject(path string, outputFlags []string) (*Project, error) {
+	v := &Validator{}
+	v.ProjectFile(path, outputFlags)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	project := &Project{Dir: filepath.Dir(path)}
+	if !v.decode(path, data, project) {
+		return nil, v.Err()
+	}
+	if project.OutputDir == "" {
+		project.OutputDir = DefaultProjectOutputDir
+	}
+	return project, nil
+}
+
+// mergeSettings applies the settings of a target to the inherited ones; an empty value removes the inherited setting
+func mergeSettings(defaults, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(overrides))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if value == "" {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// sortedSettings returns the keys of a settings map in order
+func sortedSettings(settings map[string]string) []string {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// firstNonEmpty returns the first value that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/netcalc"
+)
+
+// NamedRangesFile checks a named ranges file and records every problem in it
+// Besides syntax errors, unknown keys and values of the wrong type, ranges need a unique name and
+// at least one CIDR block, and every block must be a valid IPv4 or IPv6 CIDR.
+// path: Path of the named ranges file
+func (v *Validator) NamedRangesFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.Addf(path, 0, "cannot read named ranges: %v", err)
+		return
+	}
+
+	keys, err := keyLines(data)
+	if err != nil {
+		v.Addf(path, lineOf(data, errorOffset(err)), "invalid JSON: %v", err)
+		return
+	}
+	v.unknownKeys(path, keys, reflect.TypeOf(analysis.NamedRanges{}), map[string]reflect.Type{"ranges": reflect.TypeOf(analysis.NamedRange{})})
+
+	var ranges analysis.NamedRanges
+	if !v.decode(path, data, &ranges) {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for i, named := range ranges.Ranges {
+		prefix := "ranges." + strconv.Itoa(i) + "."
+		name := named.Name
+		switch {
+		case name == "":
+			name = fmt.Sprintf("range %d", i+1)
+			v.Addf(path, firstKeyLine(keys, prefix), "%s: name is required", name)
+		case seen[name]:
+			v.Addf(path, keyLine(keys, prefix+"name"), "%s: name is listed twice", name)
+		}
+		seen[named.Name] = true
+		if len(named.CIDRs) == 0 {
+			v.Addf(path, firstKeyLine(keys, prefix), "%s: needs cidrs to match anything", name)
+		}
+		for _, cidr := range named.CIDRs {
+			if _, err := netcalc.PrefixLength(cidr); err != nil {
+				v.Addf(path, keyLine(keys, prefix+"cidrs"), "%s: %v", name, err)
+			}
+		}
+	}
+}
+
+// LoadNamedRanges reads a named ranges file
+// path: Path of the named ranges file
+// Returns: The ranges, or every problem found in the file
+func LoadNamedRanges(path string) (*analysis.NamedRanges, error) {
+	v := &Validator{}
+	v.NamedRangesFile(path)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ranges analysis.NamedRanges
+	if !v.decode(path, data, &ranges) {
+		return nil, v.Err()
+	}
+	return &ranges, nil
+}
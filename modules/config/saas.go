@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"aws-documentor/modules/analysis"
+)
+
+// SaaSCatalogFile checks a SaaS provider catalog and records every problem in it
+// Besides syntax errors, unknown keys and values of the wrong type, providers need a vendor name
+// and at least one service name or DNS domain, and service name patterns must be valid.
+// path: Path of the catalog file
+func (v *Validator) SaaSCatalogFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.Addf(path, 0, "cannot read SaaS catalog: %v", err)
+		return
+	}
+
+	keys, err := keyLines(data)
+	if err != nil {
+		v.Addf(path, lineOf(data, errorOffset(err)), "invalid JSON: %v", err)
+		return
+	}
+	v.unknownKeys(path, keys, reflect.TypeOf(analysis.SaaSCatalog{}), map[string]reflect.Type{"providers": reflect.TypeOf(analysis.SaaSProvider{})})
+
+	var catalog analysis.SaaSCatalog
+	if !v.decode(path, data, &catalog) {
+		return
+	}
+
+	for i, provider := range catalog.Providers {
+		prefix := "providers." + strconv.Itoa(i) + "."
+		name := provider.Vendor
+		if name == "" {
+			name = fmt.Sprintf("provider %d", i+1)
+			v.Addf(path, firstKeyLine(keys, prefix), "%s: vendor is required", name)
+		}
+		if len(provider.ServiceNames) == 0 && len(provider.DNSDomains) == 0 {
+			v.Addf(path, firstKeyLine(keys, prefix), "%s: needs service_names or dns_domains to match anything", name)
+		}
+		for _, pattern := range provider.ServiceNames {
+			if _, err := pathMatch(pattern); err != nil {
+				v.Addf(path, keyLine(keys, prefix+"service_names"), "%s: invalid service name pattern %q", name, pattern)
+			}
+		}
+	}
+}
+
+// LoadSaaSCatalog reads a SaaS provider catalog and merges it into the built-in one
+// path: Path of the catalog file
+// Returns: The combined catalog, or every problem found in the file
+func LoadSaaSCatalog(path string) (*analysis.SaaSCatalog, error) {
+	v := &Validator{}
+	v.SaaSCatalogFile(path)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var catalog analysis.SaaSCatalog
+	if !v.decode(path, data, &catalog) {
+		return nil, v.Err()
+	}
+	return analysis.DefaultSaaSCatalog().Merge(&catalog), nil
+}
+
+// firstKeyLine returns the line of the first key under prefix, or 0 when there is none
+func firstKeyLine(keys []jsonKey, prefix string) int {
+	for _, key := range keys {
+		if strings.HasPrefix(key.path, prefix) {
+			return key.line
+		}
+	}
+	return 0
+}
+
+// pathMatch checks a pattern for syntax errors by matching it against an empty name
+func pathMatch(pattern string) (bool, error) {
+	return path.Match(pattern, "")
+}
@@ -0,0 +1,314 @@
+// Package consistency finds and re-checks references between scanned resources that the scan did not return
+// The EC2 API is eventually consistent: right after a change, one describe call can already show a
+// new subnet while another does not show its route table yet. Such gaps look like broken
+// resources in the outputs, so a re-check pass waits and fetches the affected types again.
+package consistency
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"aws-documentor/modules/graph"
+	"aws-documentor/modules/vpc"
+)
+
+// Fetcher fetches the collections a re-check can refresh; *vpc.Scanner implements it
+type Fetcher interface {
+	GetVPCs(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.VPCInfo, error)
+	GetSubnets(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SubnetInfo, error)
+	GetRouteTables(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.RouteTableInfo, error)
+	GetSecurityGroups(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SecurityGroupInfo, error)
+	GetInternetGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.InternetGatewayInfo, error)
+	GetNatGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.NatGatewayInfo, error)
+	GetTransitGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.TransitGatewayInfo, error)
+}
+
+// Report holds the collections of a scan; Recheck updates them in place
+type Report struct {
+	VPCs             []vpc.VPCInfo                      // VPCs of the scan
+	Subnets          []vpc.SubnetInfo                   // Subnets of the scan
+	RouteTables      []vpc.RouteTableInfo               // Route tables of the scan
+	SecurityGroups   []vpc.SecurityGroupInfo            // Security groups of the scan
+	InternetGateways []vpc.InternetGatewayInfo          // Internet gateways of the scan
+	NatGateways      []vpc.NatGatewayInfo               // NAT gateways of the scan
+	TransitGateways  []vpc.TransitGatewayInfo           // Transit gateways of the scan
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo // Transit gateway attachments of the scan (not re-fetched; they only reference)
+}
+
+// Inconsistency is a reference to a resource that is missing from its collection
+type Inconsistency struct {
+	MissingType    string `json:"missing_type"`    // Collection the resource is missing from (vpcs, subnets, route_tables, ...)
+	MissingID      string `json:"missing_id"`      // ID of the missing resource ("main route table of vpc-1" for a VPC without one)
+	ReferencedBy   string `json:"referenced_by"`   // ID of the resource that references it
+	ReferencerType string `json:"referencer_type"` // Type of that resource (subnet, route table, ...)
+	Field          string `json:"field"`           // API field of the reference (VpcId, SubnetId, ...)
+}
+
+// String formats the inconsistency for the log
+func (i Inconsistency) String() string {
+	return fmt.Sprintf("%s %s: %s %s is not in %s", i.ReferencerType, i.ReferencedBy, i.Field, i.MissingID, i.MissingType)
+}
+
+// Warning converts the inconsistency into a data-quality warning of the referencing resource
+func (i Inconsistency) Warning() vpc.DataWarning {
+	return vpc.DataWarning{
+		ResourceType: i.ReferencerType,
+		ResourceID:   i.ReferencedBy,
+		Field:        i.Field,
+		RawValue:     i.MissingID,
+		Problem:      vpc.ProblemInconsistent,
+	}
+}
+
+// Result summarizes a re-check pass
+type Result struct {
+	Found      int             `json:"found"`      // Inconsistencies of the initial scan
+	Refetched  []string        `json:"refetched"`  // Collections fetched again, in fetch order
+	Resolved   []Inconsistency `json:"resolved"`   // Inconsistencies the second fetch resolved
+	Persistent []Inconsistency `json:"persistent"` // Inconsistencies left after it; reported as data-quality warnings
+}
+
+// collection describes how a graph label maps to a collection of the report
+type collection struct {
+	name  string // Collection name as in the JSON report
+	field string // API field that references resources of the collection
+}
+
+// collections lists the graph labels of resources Recheck can fetch again
+var collections = map[string]collection{
+	graph.LabelVPC:             {"vpcs", "VpcId"},
+	graph.LabelSubnet:          {"subnets", "SubnetId"},
+	graph.LabelRouteTable:      {"route_tables", "RouteTableId"},
+	graph.LabelSecurityGroup:   {"security_groups", "GroupId"},
+	graph.LabelInternetGateway: {"internet_gateways", "GatewayId"},
+	graph.LabelNatGateway:      {"nat_gateways", "NatGatewayId"},
+	graph.LabelTransitGateway:  {"transit_gateways", "TransitGatewayId"},
+}
+
+// referencerTypes names the resource types of the graph labels as the data-quality warnings do
+var referencerTypes = map[string]string{
+	graph.LabelVPC:                      "VPC",
+	graph.LabelSubnet:                   "subnet",
+	graph.LabelRouteTable:               "route table",
+	graph.LabelSecurityGroup:            "security group",
+	graph.LabelInternetGateway:          "internet gateway",
+	graph.LabelNatGateway:               "NAT gateway",
+	graph.LabelTransitGateway:           "transit gateway",
+	graph.LabelTransitGatewayAttachment: "transit gateway attachment",
+}
+
+// Find lists the references to resources that are missing from the report
+// The graph already turns every reference to an unscanned resource into a placeholder node, so
+// the placeholders of scanned types are the candidates. References that legitimately point
+// outside the scan are skipped: peer VPCs, blackhole routes, security group references to other
+// accounts or across peering connections, and VPCs of other accounts attached to a transit gateway.
+// A VPC without a main route table is reported too, since its subnets would show no route table.
+// report: Collections of the scan
+// Returns: Inconsistencies sorted by missing type and ID
+func Find(report *Report) []Inconsistency {
+	g := graph.Build(report.VPCs, report.Subnets, report.RouteTables, report.SecurityGroups,
+		report.InternetGateways, report.NatGateways, report.TransitGateways, report.TGWAttachments)
+
+	labels := make(map[string]string, len(g.Nodes))
+	placeholders := make(map[string]bool)
+	for _, node := range g.Nodes {
+		labels[node.ID] = node.Label
+		if scanned, _ := node.Properties["scanned"].(bool); !scanned {
+			placeholders[node.ID] = true
+		}
+	}
+	localGroups := localGroupReferences(report.SecurityGroups)
+	foreignVPCs := foreignAttachedVPCs(report.TransitGateways, report.TGWAttachments)
+
+	var found []Inconsistency
+	seen := make(map[Inconsistency]bool)
+	add := func(inconsistency Inconsistency) {
+		if !seen[inconsistency] {
+			seen[inconsistency] = true
+			found = append(found, inconsistency)
+		}
+	}
+	for _, edge := range g.Edges {
+		for _, end := range []struct{ missing, referencer string }{{edge.To, edge.From}, {edge.From, edge.To}} {
+			if !placeholders[end.missing] || placeholders[end.referencer] {
+				continue
+			}
+			target, ok := collections[labels[end.missing]]
+			if !ok {
+				continue
+			}
+			switch {
+			case edge.Type == graph.EdgePeeredWith:
+				// Peer VPCs are found through references and are usually outside the scan
+				continue
+			case edge.Type == graph.EdgeRoutesTo && edge.Properties["state"] == "blackhole":
+				continue
+			case (edge.Type == graph.EdgeAllows || edge.Type == graph.EdgeReferences) && !localGroups[end.referencer+"|"+end.missing]:
+				continue
+			case labels[end.missing] == graph.LabelVPC && foreignVPCs[end.missing]:
+				continue
+			}
+			add(Inconsistency{
+				MissingType:    target.name,
+				MissingID:      end.missing,
+				ReferencedBy:   end.referencer,
+				ReferencerType: referencerTypes[labels[end.referencer]],
+				Field:          target.field,
+			})
+		}
+	}
+
+	mainTables := make(map[string]bool)
+	for _, rt := range report.RouteTables {
+		if rt.IsMainRouteTable {
+			mainTables[rt.VpcID] = true
+		}
+	}
+	for _, v := range report.VPCs {
+		if !mainTables[v.VpcID] {
+			add(Inconsistency{
+				MissingType:    "route_tables",
+				MissingID:      "main route table of " + v.VpcID,
+				ReferencedBy:   v.VpcID,
+				ReferencerType: "VPC",
+				Field:          "RouteTableId",
+			})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].MissingType != found[j].MissingType {
+			return found[i].MissingType < found[j].MissingType
+		}
+		return found[i].MissingID < found[j].MissingID
+	})
+	return found
+}
+
+// localGroupReferences returns "group|referenced group" keys of rules that reference a group of the
+// same account in the same VPC, the only references the scan must be able to resolve
+func localGroupReferences(securityGroups []vpc.SecurityGroupInfo) map[string]bool {
+	local := make(map[string]bool)
+	for _, sg := range securityGroups {
+		for _, rule := range sg.Rules {
+			if rule.GroupID == "" || (rule.GroupOwnerID != "" && rule.GroupOwnerID != sg.OwnerID) ||
+				(rule.GroupVpcID != "" && rule.GroupVpcID != sg.VpcID) {
+				continue
+			}
+			// Ingress edges run from the referenced group, egress and REFERENCES edges to it
+			local[sg.GroupID+"|"+rule.GroupID] = true
+			local[rule.GroupID+"|"+sg.GroupID] = true
+		}
+	}
+	return local
+}
+
+// foreignAttachedVPCs returns the VPCs attached to a scanned transit gateway by another account
+func foreignAttachedVPCs(transitGateways []vpc.TransitGatewayInfo, attachments []vpc.TransitGatewayAttachmentInfo) map[string]bool {
+	owners := make(map[string]string, len(transitGateways))
+	for _, tgw := range transitGateways {
+		owners[tgw.TransitGatewayID] = tgw.OwnerID
+	}
+	foreign := make(map[string]bool)
+	for _, attachment := range attachments {
+		if attachment.ResourceType == "vpc" && attachment.ResourceOwnerID != "" && attachment.ResourceOwnerID != owners[attachment.TransitGatewayID] {
+			foreign[attachment.ResourceID] = true
+		}
+	}
+	return foreign
+}
+
+// Recheck waits, fetches the collections with inconsistencies again and merges them into the report
+// Re-fetched resources replace the earlier copies with the same ID; resources the second fetch
+// no longer returns are kept, since eventual consistency can hide them just as well. Route tables
+// and VPCs are fetched again together so the local routes are classified against both.
+// ctx: Context for the wait and the API calls
+// fetcher: Scanner the report was made with
+// report: Collections of the scan, updated in place
+// wait: How long to wait before fetching again
+// opts: Tag filters of the scan, so the second fetch returns the same resources (none for all resources)
+// Returns: Result of the pass (nothing is fetched if the report is consistent), or error if a fetch fails
+func Recheck(ctx context.Context, fetcher Fetcher, report *Report, wait time.Duration, opts ...vpc.ScanOptions) (*Result, error) {
+	initial := Find(report)
+	result := &Result{Found: len(initial), Refetched: []string{}, Resolved: []Inconsistency{}, Persistent: []Inconsistency{}}
+	if len(initial) == 0 {
+		return result, nil
+	}
+
+	stale := make(map[string]bool)
+	for _, inconsistency := range initial {
+		stale[inconsistency.MissingType] = true
+	}
+	if stale["route_tables"] {
+		stale["vpcs"] = true
+	}
+
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var err error
+	if stale["vpcs"] {
+		err = refetch(ctx, result, "vpcs", &report.VPCs, fetcher.GetVPCs, opts, func(v vpc.VPCInfo) string { return v.VpcID })
+	}
+	if err == nil && stale["subnets"] {
+		err = refetch(ctx, result, "subnets", &report.Subnets, fetcher.GetSubnets, opts, func(s vpc.SubnetInfo) string { return s.SubnetID })
+	}
+	if err == nil && stale["route_tables"] {
+		err = refetch(ctx, result, "route_tables", &report.RouteTables, fetcher.GetRouteTables, opts, func(rt vpc.RouteTableInfo) string { return rt.RouteTableID })
+		vpc.ClassifyLocalRoutes(report.VPCs, report.RouteTables)
+	}
+	if err == nil && stale["security_groups"] {
+		err = refetch(ctx, result, "security_groups", &report.SecurityGroups, fetcher.GetSecurityGroups, opts, func(sg vpc.SecurityGroupInfo) string { return sg.GroupID })
+	}
+	if err == nil && stale["internet_gateways"] {
+		err = refetch(ctx, result, "internet_gateways", &report.InternetGateways, fetcher.GetInternetGateways, opts, func(igw vpc.InternetGatewayInfo) string { return igw.InternetGatewayID })
+	}
+	if err == nil && stale["nat_gateways"] {
+		err = refetch(ctx, result, "nat_gateways", &report.NatGateways, fetcher.GetNatGateways, opts, func(ngw vpc.NatGatewayInfo) string { return ngw.NatGatewayID })
+	}
+	if err == nil && stale["transit_gateways"] {
+		err = refetch(ctx, result, "transit_gateways", &report.TransitGateways, fetcher.GetTransitGateways, opts, func(tgw vpc.TransitGatewayInfo) string { return tgw.TransitGatewayID })
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make(map[Inconsistency]bool)
+	for _, inconsistency := range Find(report) {
+		remaining[inconsistency] = true
+		result.Persistent = append(result.Persistent, inconsistency)
+	}
+	for _, inconsistency := range initial {
+		if !remaining[inconsistency] {
+			result.Resolved = append(result.Resolved, inconsistency)
+		}
+	}
+	return result, nil
+}
+
+// refetch fetches one collection again and merges it into the report's copy by ID
+func refetch[T any](ctx context.Context, result *Result, name string, items *[]T, fetch func(context.Context, ...vpc.ScanOptions) ([]T, error), opts []vpc.ScanOptions, id func(T) string) error {
+	fresh, err := fetch(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s again: %w", name, err)
+	}
+	result.Refetched = append(result.Refetched, name)
+
+	positions := make(map[string]int, len(*items))
+	for i, item := range *items {
+		positions[id(item)] = i
+	}
+	for _, item := range fresh {
+		if i, ok := positions[id(item)]; ok {
+			(*items)[i] = item
+		} else {
+			*items = append(*items, item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/vpc"
+)
+
+// fakeFetcher returns the collections of the second fetch and counts the calls
+// A collection without an entry fails the test, so a re-check that fetches more than it needs is noticed.
+type fakeFetcher struct {
+	t              *testing.T
+	calls          []string                // Collections fetched, in call order
+	err            error                   // Error of every fetch (nil to succeed)
+	vpcs           []vpc.VPCInfo           // Result of GetVPCs
+	subnets        []vpc.SubnetInfo        // Result of GetSubnets
+	routeTables    []vpc.RouteTableInfo    // Result of GetRouteTables
+	securityGroups []vpc.SecurityGroupInfo // Result of GetSecurityGroups
+	natGateways    []vpc.NatGatewayInfo    // Result of GetNatGateways
+}
+
+// fetch records a call and returns the configured result
+func fetch[T any](f *fakeFetcher, name string, items []T) ([]T, error) {
+	f.calls = append(f.calls, name)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if items == nil {
+		f.t.Errorf("%s fetched again, but the test does not expect it", name)
+	}
+	return items, nil
+}
+
+func (f *fakeFetcher) GetVPCs(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.VPCInfo, error) {
+	return fetch(f, "vpcs", f.vpcs)
+}
+
+func (f *fakeFetcher) GetSubnets(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SubnetInfo, error) {
+	return fetch(f, "subnets", f.subnets)
+}
+
+func (f *fakeFetcher) GetRouteTables(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.RouteTableInfo, error) {
+	return fetch(f, "route_tables", f.routeTables)
+}
+
+func (f *fakeFetcher) GetSecurityGroups(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.SecurityGroupInfo, error) {
+	return fetch(f, "security_groups", f.securityGroups)
+}
+
+func (f *fakeFetcher) GetInternetGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.InternetGatewayInfo, error) {
+	return fetch[vpc.InternetGatewayInfo](f, "internet_gateways", nil)
+}
+
+func (f *fakeFetcher) GetNatGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.NatGatewayInfo, error) {
+	return fetch(f, "nat_gateways", f.natGateways)
+}
+
+func (f *fakeFetcher) GetTransitGateways(ctx context.Context, opts ...vpc.ScanOptions) ([]vpc.TransitGatewayInfo, error) {
+	return fetch[vpc.TransitGatewayInfo](f, "transit_gateways", nil)
+}
+
+// consistentReport returns a scan of one VPC whose references all resolve, including references that
+// legitimately leave the scan
+func consistentReport() *Report {
+	return &Report{
+		VPCs:    []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16"}},
+		Subnets: []vpc.SubnetInfo{{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24"}},
+		RouteTables: []vpc.RouteTableInfo{{RouteTableID: "rtb-0main", VpcID: "vpc-0a1", IsMainRouteTable: true, SubnetIDs: []string{"subnet-0a1"}, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}, State: "active"},
+			{DestinationCidrBlock: "10.9.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetVpcPeeringConnection, ID: "pcx-0a1"}, State: "active"},
+			{DestinationCidrBlock: "10.8.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0deleted"}, State: "blackhole"},
+		}}},
+		SecurityGroups: []vpc.SecurityGroupInfo{{GroupID: "sg-0app", VpcID: "vpc-0a1", OwnerID: "111122223333", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, GroupID: "sg-0partner", GroupOwnerID: "444455556666"},
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, GroupID: "sg-0peer", GroupVpcID: "vpc-0peer", VpcPeeringConnectionID: "pcx-0a1"},
+		}}},
+		InternetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1"}},
+		TransitGateways:  []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", OwnerID: "111122223333"}},
+		TGWAttachments: []vpc.TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0other", TransitGatewayID: "tgw-0a1",
+			ResourceType: "vpc", ResourceID: "vpc-0other", ResourceOwnerID: "777788889999"}},
+	}
+}
+
+// TestFind checks each kind of reference to a missing resource, and that references leaving the scan on
+// purpose are not reported
+// A VPC that is itself missing is not also reported for lacking a main route table.
+func TestFind(t *testing.T) {
+	if found := Find(consistentReport()); len(found) != 0 {
+		t.Errorf("Find() of a consistent report = %+v, want none", found)
+	}
+
+	report := consistentReport()
+	report.Subnets = append(report.Subnets, vpc.SubnetInfo{SubnetID: "subnet-0b2", VpcID: "vpc-0new"})
+	report.RouteTables[0].SubnetIDs = append(report.RouteTables[0].SubnetIDs, "subnet-0new")
+	report.RouteTables[0].Routes = append(report.RouteTables[0].Routes,
+		vpc.RouteInfo{DestinationCidrBlock: "10.7.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0new"}, State: "active"})
+	report.SecurityGroups[0].Rules = append(report.SecurityGroups[0].Rules,
+		vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0db", IsEgress: true})
+	report.InternetGateways = nil
+
+	want := []Inconsistency{
+		{MissingType: "internet_gateways", MissingID: "igw-0a1", ReferencedBy: "rtb-0main", ReferencerType: "route table", Field: "GatewayId"},
+		{MissingType: "nat_gateways", MissingID: "nat-0new", ReferencedBy: "rtb-0main", ReferencerType: "route table", Field: "NatGatewayId"},
+		{MissingType: "security_groups", MissingID: "sg-0db", ReferencedBy: "sg-0app", ReferencerType: "security group", Field: "GroupId"},
+		{MissingType: "subnets", MissingID: "subnet-0new", ReferencedBy: "rtb-0main", ReferencerType: "route table", Field: "SubnetId"},
+		{MissingType: "vpcs", MissingID: "vpc-0new", ReferencedBy: "subnet-0b2", ReferencerType: "subnet", Field: "VpcId"},
+	}
+	if got := Find(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// TestRecheck checks that only the affected collections are fetched again, that the second fetch's missing
+// association resolves an inconsistency and that the others stay as warnings
+func TestRecheck(t *testing.T) {
+	report := consistentReport()
+	report.RouteTables = append(report.RouteTables, vpc.RouteTableInfo{RouteTableID: "rtb-0new", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0new"}})
+	report.RouteTables[0].Routes = append(report.RouteTables[0].Routes,
+		vpc.RouteInfo{DestinationCidrBlock: "10.7.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0gone"}, State: "active"})
+	report.SecurityGroups[0].Rules = append(report.SecurityGroups[0].Rules, vpc.SecurityGroupRule{IpProtocol: "tcp", GroupID: "sg-0db", IsEgress: true})
+
+	fetcher := &fakeFetcher{
+		t: t,
+		subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", Tags: map[string]string{"Name": "refreshed"}},
+			{SubnetID: "subnet-0new", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24"},
+		},
+		securityGroups: report.SecurityGroups,
+		natGateways:    []vpc.NatGatewayInfo{},
+	}
+	result, err := Recheck(context.Background(), fetcher, report, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Result{
+		Found:     3,
+		Refetched: []string{"subnets", "security_groups", "nat_gateways"},
+		Resolved: []Inconsistency{
+			{MissingType: "subnets", MissingID: "subnet-0new", ReferencedBy: "rtb-0new", ReferencerType: "route table", Field: "SubnetId"},
+		},
+		Persistent: []Inconsistency{
+			{MissingType: "nat_gateways", MissingID: "nat-0gone", ReferencedBy: "rtb-0main", ReferencerType: "route table", Field: "NatGatewayId"},
+			{MissingType: "security_groups", MissingID: "sg-0db", ReferencedBy: "sg-0app", ReferencerType: "security group", Field: "GroupId"},
+		},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Recheck() =\n%+v\nwant\n%+v", result, want)
+	}
+	if !reflect.DeepEqual(fetcher.calls, want.Refetched) {
+		t.Errorf("fetched %q, want %q", fetcher.calls, want.Refetched)
+	}
+	if len(report.Subnets) != 2 || report.Subnets[0].Tags["Name"] != "refreshed" || report.Subnets[1].SubnetID != "subnet-0new" {
+		t.Errorf("subnets = %+v, want the refreshed subnet in place and the new one appended", report.Subnets)
+	}
+
+	warning := want.Persistent[0].Warning()
+	if wantWarning := (vpc.DataWarning{ResourceType: "route table", ResourceID: "rtb-0main", Field: "NatGatewayId", RawValue: "nat-0gone",
+		Problem: vpc.ProblemInconsistent}); warning != wantWarning {
+		t.Errorf("Warning() = %+v, want %+v", warning, wantWarning)
+	}
+}
+
+// TestRecheckMainRouteTable checks that a VPC without a main route table fetches the VPCs and route tables again
+func TestRecheckMainRouteTable(t *testing.T) {
+	report := consistentReport()
+	main := report.RouteTables[0]
+	report.RouteTables = nil
+
+	fetcher := &fakeFetcher{t: t, vpcs: report.VPCs, routeTables: []vpc.RouteTableInfo{main}}
+	result, err := Recheck(context.Background(), fetcher, report, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Found != 1 || len(result.Resolved) != 1 || len(result.Persistent) != 0 {
+		t.Errorf("Recheck() = %+v, want the missing main route table resolved", result)
+	}
+	if want := []string{"vpcs", "route_tables"}; !reflect.DeepEqual(fetcher.calls, want) {
+		t.Errorf("fetched %q, want %q", fetcher.calls, want)
+	}
+}
+
+// TestRecheckWithoutInconsistencies checks that a consistent report is not fetched again and not waited for
+func TestRecheckWithoutInconsistencies(t *testing.T) {
+	fetcher := &fakeFetcher{t: t}
+	result, err := Recheck(context.Background(), fetcher, consistentReport(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Result{Refetched: []string{}, Resolved: []Inconsistency{}, Persistent: []Inconsistency{}}
+	if !reflect.DeepEqual(result, want) || len(fetcher.calls) != 0 {
+		t.Errorf("Recheck() = %+v after fetching %q, want an empty result without fetching", result, fetcher.calls)
+	}
+}
+
+// TestRecheckErrors checks that a failed fetch and a cancelled wait are returned
+func TestRecheckErrors(t *testing.T) {
+	inconsistent := func() *Report {
+		report := consistentReport()
+		report.InternetGateways = nil
+		return report
+	}
+
+	denied := errors.New("not authorized")
+	_, err := Recheck(context.Background(), &fakeFetcher{t: t, err: denied}, inconsistent(), 0)
+	if !errors.Is(err, denied) || err.Error() != "failed to fetch internet_gateways again: not authorized" {
+		t.Errorf("Recheck() with a failing fetch = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fetcher := &fakeFetcher{t: t}
+	if _, err := Recheck(ctx, fetcher, inconsistent(), time.Hour); !errors.Is(err, context.Canceled) || len(fetcher.calls) != 0 {
+		t.Errorf("Recheck() with a cancelled context = %v after fetching %q, want the context error without fetching", err, fetcher.calls)
+	}
+}
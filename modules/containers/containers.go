@@ -0,0 +1,73 @@
+// Package containers provides functionality for scanning the network configuration of ECS services and EKS clusters
+// awsvpc tasks and Fargate pods get their network interfaces only while they run, so the subnets and
+// security groups of container workloads are read from the service and cluster configuration instead.
+package containers
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// ECSServiceInfo contains the network configuration of an ECS service
+type ECSServiceInfo struct {
+	ClusterName             string   `json:"cluster_name"`              // Name of the cluster the service runs in
+	ServiceName             string   `json:"service_name"`              // Name of the service
+	ServiceArn              string   `json:"service_arn"`               // ARN of the service
+	Status                  string   `json:"status"`                    // ACTIVE, DRAINING or INACTIVE
+	LaunchType              string   `json:"launch_type"`               // EC2, FARGATE or EXTERNAL, empty if a capacity provider strategy is used
+	NetworkMode             string   `json:"network_mode"`              // awsvpc when the service has a network configuration, empty otherwise
+	DesiredCount            int32    `json:"desired_count"`             // Number of tasks the service should run
+	RunningCount            int32    `json:"running_count"`             // Number of tasks running at scan time
+	SubnetIDs               []string `json:"subnet_ids"`                // Subnets of the awsvpc network configuration
+	SecurityGroupIDs        []string `json:"security_group_ids"`        // Security groups of the awsvpc network configuration
+	AssignPublicIP          bool     `json:"assign_public_ip"`          // Whether tasks get a public IP address
+	ServiceConnectNamespace string   `json:"service_connect_namespace"` // Cloud Map namespace of the primary deployment's Service Connect configuration, empty if not enabled
+}
+
+// EKSNodegroupInfo contains the network configuration of an EKS managed node group
+type EKSNodegroupInfo struct {
+	NodegroupName    string   `json:"nodegroup_name"`     // Name of the node group
+	Arn              string   `json:"arn"`                // ARN of the node group
+	Status           string   `json:"status"`             // Node group status (ACTIVE, DEGRADED, ...)
+	DesiredSize      int32    `json:"desired_size"`       // Desired number of nodes
+	SubnetIDs        []string `json:"subnet_ids"`         // Subnets the nodes are launched into
+	SecurityGroupIDs []string `json:"security_group_ids"` // Cluster security group (without a launch template) and remote access security group
+	LaunchTemplate   string   `json:"launch_template"`    // Launch template ID or name; its security groups replace the cluster security group and are not resolved
+}
+
+// EKSFargateProfileInfo contains the network configuration of an EKS Fargate profile
+type EKSFargateProfileInfo struct {
+	FargateProfileName string   `json:"fargate_profile_name"` // Name of the Fargate profile
+	Arn                string   `json:"arn"`                  // ARN of the Fargate profile
+	Status             string   `json:"status"`               // Profile status (ACTIVE, CREATING, ...)
+	SubnetIDs          []string `json:"subnet_ids"`           // Private subnets pods are launched into
+	SecurityGroupIDs   []string `json:"security_group_ids"`   // Cluster security group, which Fargate pods use unless a security group policy overrides it
+}
+
+// EKSClusterInfo contains the network configuration of an EKS cluster and its node groups and Fargate profiles
+type EKSClusterInfo struct {
+	ClusterName            string                  `json:"cluster_name"`              // Name of the cluster
+	Arn                    string                  `json:"arn"`                       // ARN of the cluster
+	VpcID                  string                  `json:"vpc_id"`                    // VPC of the cluster
+	SubnetIDs              []string                `json:"subnet_ids"`                // Subnets of the control plane network interfaces
+	SecurityGroupIDs       []string                `json:"security_group_ids"`        // Additional security groups of the control plane network interfaces
+	ClusterSecurityGroupID string                  `json:"cluster_security_group_id"` // Security group EKS created for the cluster
+	Nodegroups             []EKSNodegroupInfo      `json:"nodegroups"`                // Managed node groups
+	FargateProfiles        []EKSFargateProfileInfo `json:"fargate_profiles"`          // Fargate profiles
+}
+
+// Scanner provides methods for retrieving ECS and EKS network configuration
+type Scanner struct {
+	ecsClient *ecs.Client // AWS ECS client for making API calls
+	eksClient *eks.Client // AWS EKS client for making API calls
+}
+
+// NewScanner creates a new container scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials and region information
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		ecsClient: ecs.NewFromConfig(cfg),
+		eksClient: eks.NewFromConfig(cfg),
+	}
+}
@@ -0,0 +1,219 @@
+package containers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/vpc"
+)
+
+// newTestScanner returns a scanner whose ECS and EKS endpoints are served by handler
+func newTestScanner(t *testing.T, handler http.HandlerFunc) *Scanner {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewScanner(aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+}
+
+// denyAccess answers a request with AccessDeniedException in both the JSON and the REST JSON protocol
+func denyAccess(w http.ResponseWriter) {
+	w.Header().Set("X-Amzn-ErrorType", "AccessDeniedException")
+	w.WriteHeader(http.StatusForbidden)
+	fmt.Fprint(w, `{"__type":"AccessDeniedException","message":"not authorized"}`)
+}
+
+// clusterArn returns the ARN of an ECS cluster
+func clusterArn(name string) string {
+	return "arn:aws:ecs:eu-west-1:111122223333:cluster/" + name
+}
+
+// TestGetECSServices reads the network configuration of services on every page of clusters and services and
+// describes them in batches of at most ten
+func TestGetECSServices(t *testing.T) {
+	// The web cluster has eleven services, so describing them takes two calls
+	webServices := []string{"api"}
+	for i := 1; i <= 10; i++ {
+		webServices = append(webServices, fmt.Sprintf("cron-%02d", i))
+	}
+	var batches []int
+	scanner := newTestScanner(t, func(w http.ResponseWriter, r *http.Request) {
+		_, operation, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+		var input struct {
+			Cluster   string   `json:"cluster"`
+			NextToken string   `json:"nextToken"`
+			Services  []string `json:"services"`
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+		switch operation + " " + input.NextToken {
+		case "ListClusters ":
+			fmt.Fprintf(w, `{"clusterArns": [%q], "nextToken": "clusters-2"}`, clusterArn("web"))
+		case "ListClusters clusters-2":
+			fmt.Fprintf(w, `{"clusterArns": [%q]}`, clusterArn("batch"))
+		case "ListServices ":
+			if input.Cluster == clusterArn("batch") {
+				fmt.Fprint(w, `{"serviceArns": ["arn:aws:ecs:eu-west-1:111122223333:service/batch/worker"]}`)
+				return
+			}
+			var arns []string
+			for _, name := range webServices[:6] {
+				arns = append(arns, fmt.Sprintf("%q", "arn:aws:ecs:eu-west-1:111122223333:service/web/"+name))
+			}
+			fmt.Fprintf(w, `{"serviceArns": [%s], "nextToken": "services-2"}`, strings.Join(arns, ","))
+		case "ListServices services-2":
+			var arns []string
+			for _, name := range webServices[6:] {
+				arns = append(arns, fmt.Sprintf("%q", "arn:aws:ecs:eu-west-1:111122223333:service/web/"+name))
+			}
+			fmt.Fprintf(w, `{"serviceArns": [%s]}`, strings.Join(arns, ","))
+		case "DescribeServices ":
+			batches = append(batches, len(input.Services))
+			var described []string
+			for _, arn := range input.Services {
+				described = append(described, describedService(arn))
+			}
+			fmt.Fprintf(w, `{"services": [%s]}`, strings.Join(described, ","))
+		default:
+			denyAccess(w)
+		}
+	})
+
+	got, err := scanner.GetECSServices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(batches, []int{10, 1, 1}) {
+		t.Errorf("DescribeServices batch sizes = %v, want [10 1 1]", batches)
+	}
+	if len(got) != 12 {
+		t.Fatalf("got %d services, want 12", len(got))
+	}
+	wantWorker := ECSServiceInfo{ClusterName: "batch", ServiceName: "worker", ServiceArn: "arn:aws:ecs:eu-west-1:111122223333:service/batch/worker",
+		Status: "ACTIVE", LaunchType: "FARGATE", NetworkMode: "awsvpc", SubnetIDs: []string{"subnet-0c3"}, SecurityGroupIDs: []string{"sg-0idle"}}
+	wantAPI := ECSServiceInfo{ClusterName: "web", ServiceName: "api", ServiceArn: "arn:aws:ecs:eu-west-1:111122223333:service/web/api",
+		Status: "ACTIVE", NetworkMode: "awsvpc", DesiredCount: 2, RunningCount: 1, SubnetIDs: []string{"subnet-0a1", "subnet-0b2"},
+		SecurityGroupIDs: []string{"sg-0tasks"}, AssignPublicIP: true, ServiceConnectNamespace: "arn:aws:servicediscovery:eu-west-1:111122223333:namespace/ns-0web"}
+	wantCron := ECSServiceInfo{ClusterName: "web", ServiceName: "cron-01", ServiceArn: "arn:aws:ecs:eu-west-1:111122223333:service/web/cron-01",
+		Status: "ACTIVE", LaunchType: "EC2", DesiredCount: 1, RunningCount: 1, SubnetIDs: []string{}, SecurityGroupIDs: []string{}}
+	for i, want := range map[int]ECSServiceInfo{0: wantWorker, 1: wantAPI, 2: wantCron} {
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("service %d = %+v\nwant %+v", i, got[i], want)
+		}
+	}
+}
+
+// describedService returns the DescribeServices entry of a test service: api is an awsvpc service with
+// Service Connect, worker an awsvpc service scaled to zero and the others bridge services on EC2
+func describedService(arn string) string {
+	name := arn[strings.LastIndex(arn, "/")+1:]
+	switch name {
+	case "api":
+		return fmt.Sprintf(`{"serviceName": "api", "serviceArn": %q, "status": "ACTIVE", "desiredCount": 2, "runningCount": 1,
+			"networkConfiguration": {"awsvpcConfiguration": {"subnets": ["subnet-0a1", "subnet-0b2"], "securityGroups": ["sg-0tasks"], "assignPublicIp": "ENABLED"}},
+			"deployments": [
+				{"status": "ACTIVE", "serviceConnectConfiguration": {"enabled": true, "namespace": "arn:aws:servicediscovery:eu-west-1:111122223333:namespace/ns-0old"}},
+				{"status": "PRIMARY", "serviceConnectConfiguration": {"enabled": true, "namespace": "arn:aws:servicediscovery:eu-west-1:111122223333:namespace/ns-0web"}}]}`, arn)
+	case "worker":
+		return fmt.Sprintf(`{"serviceName": "worker", "serviceArn": %q, "status": "ACTIVE", "launchType": "FARGATE", "desiredCount": 0, "runningCount": 0,
+			"networkConfiguration": {"awsvpcConfiguration": {"subnets": ["subnet-0c3"], "securityGroups": ["sg-0idle"], "assignPublicIp": "DISABLED"}}}`, arn)
+	default:
+		return fmt.Sprintf(`{"serviceName": %q, "serviceArn": %q, "status": "ACTIVE", "launchType": "EC2", "desiredCount": 1, "runningCount": 1,
+			"deployments": [{"status": "PRIMARY", "serviceConnectConfiguration": {"enabled": false, "namespace": "ignored"}}]}`, name, arn)
+	}
+}
+
+// TestGetEKSClusters reads the control plane, node group and Fargate profile network configuration of every cluster
+func TestGetEKSClusters(t *testing.T) {
+	responses := map[string]string{
+		"/clusters":                              `{"clusters": ["prod"], "nextToken": "clusters-2"}`,
+		"/clusters?page=2":                       `{"clusters": ["dev"]}`,
+		"/clusters/prod":                         `{"cluster": {"name": "prod", "arn": "arn:aws:eks:eu-west-1:111122223333:cluster/prod", "resourcesVpcConfig": {"vpcId": "vpc-0b2", "subnetIds": ["subnet-0e5", "subnet-0f6"], "securityGroupIds": ["sg-0control"], "clusterSecurityGroupId": "sg-0eks"}}}`,
+		"/clusters/dev":                          `{"cluster": {"name": "dev", "arn": "arn:aws:eks:eu-west-1:111122223333:cluster/dev"}}`,
+		"/clusters/prod/node-groups":             `{"nodegroups": ["general", "custom"]}`,
+		"/clusters/prod/node-groups/general":     `{"nodegroup": {"nodegroupName": "general", "nodegroupArn": "arn:aws:eks:eu-west-1:111122223333:nodegroup/prod/general/1", "status": "ACTIVE", "subnets": ["subnet-0e5"], "scalingConfig": {"desiredSize": 3}, "resources": {"remoteAccessSecurityGroup": "sg-0ssh"}}}`,
+		"/clusters/prod/node-groups/custom":      `{"nodegroup": {"nodegroupName": "custom", "nodegroupArn": "arn:aws:eks:eu-west-1:111122223333:nodegroup/prod/custom/2", "status": "DEGRADED", "subnets": ["subnet-0f6"], "scalingConfig": {"desiredSize": 0}, "launchTemplate": {"name": "custom-nodes"}}}`,
+		"/clusters/prod/fargate-profiles":        `{"fargateProfileNames": ["system"]}`,
+		"/clusters/prod/fargate-profiles/system": `{"fargateProfile": {"fargateProfileName": "system", "fargateProfileArn": "arn:aws:eks:eu-west-1:111122223333:fargateprofile/prod/system/3", "status": "ACTIVE", "subnets": ["subnet-0e5", "subnet-0f6"]}}`,
+		"/clusters/dev/node-groups":              `{"nodegroups": []}`,
+		"/clusters/dev/fargate-profiles":         `{"fargateProfileNames": []}`,
+	}
+	scanner := newTestScanner(t, func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if r.URL.Query().Get("nextToken") == "clusters-2" {
+			key += "?page=2"
+		}
+		body, ok := responses[key]
+		if !ok {
+			denyAccess(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	})
+
+	got, err := scanner.GetEKSClusters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EKSClusterInfo{
+		{ClusterName: "dev", Arn: "arn:aws:eks:eu-west-1:111122223333:cluster/dev", SubnetIDs: []string{}, SecurityGroupIDs: []string{},
+			Nodegroups: []EKSNodegroupInfo{}, FargateProfiles: []EKSFargateProfileInfo{}},
+		{ClusterName: "prod", Arn: "arn:aws:eks:eu-west-1:111122223333:cluster/prod", VpcID: "vpc-0b2",
+			SubnetIDs: []string{"subnet-0e5", "subnet-0f6"}, SecurityGroupIDs: []string{"sg-0control"}, ClusterSecurityGroupID: "sg-0eks",
+			Nodegroups: []EKSNodegroupInfo{
+				{NodegroupName: "general", Arn: "arn:aws:eks:eu-west-1:111122223333:nodegroup/prod/general/1", Status: "ACTIVE", DesiredSize: 3,
+					SubnetIDs: []string{"subnet-0e5"}, SecurityGroupIDs: []string{"sg-0eks", "sg-0ssh"}},
+				{NodegroupName: "custom", Arn: "arn:aws:eks:eu-west-1:111122223333:nodegroup/prod/custom/2", Status: "DEGRADED",
+					SubnetIDs: []string{"subnet-0f6"}, SecurityGroupIDs: []string{}, LaunchTemplate: "custom-nodes"},
+			},
+			FargateProfiles: []EKSFargateProfileInfo{
+				{FargateProfileName: "system", Arn: "arn:aws:eks:eu-west-1:111122223333:fargateprofile/prod/system/3", Status: "ACTIVE",
+					SubnetIDs: []string{"subnet-0e5", "subnet-0f6"}, SecurityGroupIDs: []string{"sg-0eks"}},
+			}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetEKSClusters() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestScanErrors checks that denied calls return a scan error that main can classify as missing permissions
+func TestScanErrors(t *testing.T) {
+	scanner := newTestScanner(t, func(w http.ResponseWriter, r *http.Request) { denyAccess(w) })
+	tests := []struct {
+		name      string
+		scan      func() error
+		operation string
+	}{
+		{name: "ECS", scan: func() error { _, err := scanner.GetECSServices(context.Background()); return err }, operation: "ListClusters"},
+		{name: "EKS", scan: func() error { _, err := scanner.GetEKSClusters(context.Background()); return err }, operation: "ListClusters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scan()
+			var scanErr *vpc.ScanError
+			if !errors.As(err, &scanErr) || scanErr.Operation != tt.operation {
+				t.Fatalf("error = %v, want a scan error of %s", err, tt.operation)
+			}
+			if !errors.Is(err, vpc.ErrAccessDenied) {
+				t.Errorf("error = %v, want access denied", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package containers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"aws-documentor/modules/vpc"
+)
+
+// servicesPerDescribe is the largest number of services DescribeServices accepts
+const servicesPerDescribe = 10
+
+// GetECSServices retrieves the network configuration of every ECS service in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of ECSServiceInfo structs sorted by cluster and service name, or error if the operation fails
+func (s *Scanner) GetECSServices(ctx context.Context) ([]ECSServiceInfo, error) {
+	services := []ECSServiceInfo{}
+
+	clusters := ecs.NewListClustersPaginator(s.ecsClient, &ecs.ListClustersInput{})
+	for clusters.HasMorePages() {
+		page, err := clusters.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("ecs", "ECS clusters", "ListClusters", err)
+		}
+		for _, clusterArn := range page.ClusterArns {
+			clusterServices, err := s.getClusterServices(ctx, clusterArn)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, clusterServices...)
+		}
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].ClusterName != services[j].ClusterName {
+			return services[i].ClusterName < services[j].ClusterName
+		}
+		return services[i].ServiceName < services[j].ServiceName
+	})
+	return services, nil
+}
+
+// getClusterServices lists and describes the services of one cluster
+func (s *Scanner) getClusterServices(ctx context.Context, clusterArn string) ([]ECSServiceInfo, error) {
+	var serviceArns []string
+	paginator := ecs.NewListServicesPaginator(s.ecsClient, &ecs.ListServicesInput{Cluster: aws.String(clusterArn)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("ecs", "ECS services", "ListServices", err)
+		}
+		serviceArns = append(serviceArns, page.ServiceArns...)
+	}
+
+	services := []ECSServiceInfo{}
+	for start := 0; start < len(serviceArns); start += servicesPerDescribe {
+		end := start + servicesPerDescribe
+		if end > len(serviceArns) {
+			end = len(serviceArns)
+		}
+		result, err := s.ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(clusterArn),
+			Services: serviceArns[start:end],
+		})
+		if err != nil {
+			return nil, vpc.NewServiceScanError("ecs", "ECS services", "DescribeServices", err)
+		}
+		for _, service := range result.Services {
+			services = append(services, convertService(clusterArn, service))
+		}
+	}
+	return services, nil
+}
+
+// convertService converts an ECS service to ECSServiceInfo
+func convertService(clusterArn string, service types.Service) ECSServiceInfo {
+	info := ECSServiceInfo{
+		ClusterName:      nameFromArn(clusterArn),
+		ServiceName:      aws.ToString(service.ServiceName),
+		ServiceArn:       aws.ToString(service.ServiceArn),
+		Status:           aws.ToString(service.Status),
+		LaunchType:       string(service.LaunchType),
+		DesiredCount:     service.DesiredCount,
+		RunningCount:     service.RunningCount,
+		SubnetIDs:        []string{},
+		SecurityGroupIDs: []string{},
+	}
+	if network := service.NetworkConfiguration; network != nil && network.AwsvpcConfiguration != nil {
+		info.NetworkMode = "awsvpc"
+		info.SubnetIDs = append(info.SubnetIDs, network.AwsvpcConfiguration.Subnets...)
+		info.SecurityGroupIDs = append(info.SecurityGroupIDs, network.AwsvpcConfiguration.SecurityGroups...)
+		info.AssignPublicIP = network.AwsvpcConfiguration.AssignPublicIp == types.AssignPublicIpEnabled
+	}
+	for _, deployment := range service.Deployments {
+		if aws.ToString(deployment.Status) != "PRIMARY" || deployment.ServiceConnectConfiguration == nil {
+			continue
+		}
+		if deployment.ServiceConnectConfiguration.Enabled {
+			info.ServiceConnectNamespace = aws.ToString(deployment.ServiceConnectConfiguration.Namespace)
+		}
+	}
+	return info
+}
+
+// nameFromArn returns the part of an ARN after the last slash (the cluster name of a cluster ARN)
+func nameFromArn(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
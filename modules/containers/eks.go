@@ -0,0 +1,130 @@
+package containers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+
+	"aws-documentor/modules/vpc"
+)
+
+// GetEKSClusters retrieves the network configuration of every EKS cluster in the configured AWS region
+// with its managed node groups and Fargate profiles
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of EKSClusterInfo structs sorted by name, or error if the operation fails
+func (s *Scanner) GetEKSClusters(ctx context.Context) ([]EKSClusterInfo, error) {
+	clusters := []EKSClusterInfo{}
+
+	paginator := eks.NewListClustersPaginator(s.eksClient, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("eks", "EKS clusters", "ListClusters", err)
+		}
+		for _, name := range page.Clusters {
+			cluster, err := s.getCluster(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].ClusterName < clusters[j].ClusterName })
+	return clusters, nil
+}
+
+// getCluster describes one cluster with its node groups and Fargate profiles
+func (s *Scanner) getCluster(ctx context.Context, name string) (EKSClusterInfo, error) {
+	info := EKSClusterInfo{
+		ClusterName:      name,
+		SubnetIDs:        []string{},
+		SecurityGroupIDs: []string{},
+		Nodegroups:       []EKSNodegroupInfo{},
+		FargateProfiles:  []EKSFargateProfileInfo{},
+	}
+
+	result, err := s.eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(name)})
+	if err != nil {
+		return info, vpc.NewServiceScanError("eks", "EKS clusters", "DescribeCluster", err)
+	}
+	info.Arn = aws.ToString(result.Cluster.Arn)
+	if config := result.Cluster.ResourcesVpcConfig; config != nil {
+		info.VpcID = aws.ToString(config.VpcId)
+		info.SubnetIDs = append(info.SubnetIDs, config.SubnetIds...)
+		info.SecurityGroupIDs = append(info.SecurityGroupIDs, config.SecurityGroupIds...)
+		info.ClusterSecurityGroupID = aws.ToString(config.ClusterSecurityGroupId)
+	}
+
+	nodegroups := eks.NewListNodegroupsPaginator(s.eksClient, &eks.ListNodegroupsInput{ClusterName: aws.String(name)})
+	for nodegroups.HasMorePages() {
+		page, err := nodegroups.NextPage(ctx)
+		if err != nil {
+			return info, vpc.NewServiceScanError("eks", "EKS node groups", "ListNodegroups", err)
+		}
+		for _, nodegroupName := range page.Nodegroups {
+			nodegroup, err := s.eksClient.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{
+				ClusterName:   aws.String(name),
+				NodegroupName: aws.String(nodegroupName),
+			})
+			if err != nil {
+				return info, vpc.NewServiceScanError("eks", "EKS node groups", "DescribeNodegroup", err)
+			}
+			ng := nodegroup.Nodegroup
+			group := EKSNodegroupInfo{
+				NodegroupName:    nodegroupName,
+				Arn:              aws.ToString(ng.NodegroupArn),
+				Status:           string(ng.Status),
+				SubnetIDs:        append([]string{}, ng.Subnets...),
+				SecurityGroupIDs: []string{},
+			}
+			if ng.ScalingConfig != nil {
+				group.DesiredSize = aws.ToInt32(ng.ScalingConfig.DesiredSize)
+			}
+			if ng.LaunchTemplate != nil {
+				group.LaunchTemplate = aws.ToString(ng.LaunchTemplate.Id)
+				if group.LaunchTemplate == "" {
+					group.LaunchTemplate = aws.ToString(ng.LaunchTemplate.Name)
+				}
+			} else if info.ClusterSecurityGroupID != "" {
+				group.SecurityGroupIDs = append(group.SecurityGroupIDs, info.ClusterSecurityGroupID)
+			}
+			if ng.Resources != nil && aws.ToString(ng.Resources.RemoteAccessSecurityGroup) != "" {
+				group.SecurityGroupIDs = append(group.SecurityGroupIDs, aws.ToString(ng.Resources.RemoteAccessSecurityGroup))
+			}
+			info.Nodegroups = append(info.Nodegroups, group)
+		}
+	}
+
+	profiles := eks.NewListFargateProfilesPaginator(s.eksClient, &eks.ListFargateProfilesInput{ClusterName: aws.String(name)})
+	for profiles.HasMorePages() {
+		page, err := profiles.NextPage(ctx)
+		if err != nil {
+			return info, vpc.NewServiceScanError("eks", "EKS Fargate profiles", "ListFargateProfiles", err)
+		}
+		for _, profileName := range page.FargateProfileNames {
+			profile, err := s.eksClient.DescribeFargateProfile(ctx, &eks.DescribeFargateProfileInput{
+				ClusterName:        aws.String(name),
+				FargateProfileName: aws.String(profileName),
+			})
+			if err != nil {
+				return info, vpc.NewServiceScanError("eks", "EKS Fargate profiles", "DescribeFargateProfile", err)
+			}
+			fargate := EKSFargateProfileInfo{
+				FargateProfileName: profileName,
+				Arn:                aws.ToString(profile.FargateProfile.FargateProfileArn),
+				Status:             string(profile.FargateProfile.Status),
+				SubnetIDs:          append([]string{}, profile.FargateProfile.Subnets...),
+				SecurityGroupIDs:   []string{},
+			}
+			if info.ClusterSecurityGroupID != "" {
+				fargate.SecurityGroupIDs = append(fargate.SecurityGroupIDs, info.ClusterSecurityGroupID)
+			}
+			info.FargateProfiles = append(info.FargateProfiles, fargate)
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,246 @@
+// Package coverage compares the resource types this tool documents with the resource types present in an account
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Support levels of a resource type
+const (
+	SupportYes     = "yes"     // Every resource of the type is scanned
+	SupportPartial = "partial" // Only some resources or attributes of the type are scanned
+	SupportNo      = "no"      // The type is not scanned
+)
+
+// Values of Row.InLastScan
+const (
+	LastScanYes     = "yes"     // The type was scanned by the run that wrote the manifest
+	LastScanNo      = "no"      // The type was not scanned by that run
+	LastScanUnknown = "unknown" // No manifest was given
+)
+
+// ResourceType describes how this tool covers one resource type of the Resource Groups Tagging API
+type ResourceType struct {
+	TaggingType string // Type as the tagging API names it (service:type, such as ec2:vpc)
+	Description string // Human-readable name
+	Support     string // yes, partial or no
+	Flags       string // Flags that enable the scanner (empty for the default scan)
+	Network     bool   // Whether the type belongs in network documentation
+}
+
+// ResourceTypes is the maintained mapping of tagging API types to the scanners of this tool
+// Scanners added to the tool are added here too; unsupported network types are listed so the coverage
+// report can name what is present in an account but missing from its documentation.
+var ResourceTypes = []ResourceType{
+	{"ec2:vpc", "VPCs", SupportYes, "", true},
+	{"ec2:subnet", "Subnets", SupportYes, "", true},
+	{"ec2:route-table", "Route tables", SupportYes, "", true},
+	{"ec2:security-group", "Security groups", SupportYes, "", true},
+	{"ec2:network-acl", "Network ACLs", SupportYes, "", true},
+	{"ec2:internet-gateway", "Internet gateways", SupportYes, "", true},
+	{"ec2:natgateway", "NAT gateways", SupportYes, "", true},
+	{"ec2:carrier-gateway", "Carrier gateways", SupportYes, "", true},
+	{"ec2:vpn-gateway", "Virtual private gateways", SupportYes, "", true},
+	{"ec2:customer-gateway", "Customer gateways", SupportYes, "", true},
+	{"ec2:vpn-connection", "Site-to-Site VPN connections", SupportYes, "", true},
+	{"ec2:transit-gateway", "Transit gateways", SupportYes, "", true},
+	{"ec2:transit-gateway-attachment", "Transit gateway attachments", SupportYes, "", true},
+	{"ec2:transit-gateway-route-table", "Transit gateway route tables", SupportYes, "-inspection-paths", true},
+	{"ec2:vpc-endpoint", "VPC endpoints", SupportYes, "-endpoint-coverage, -dns, -third-party, -egress-profiles, -private-apis, -diagram or -detail-diagrams", true},
+	{"ec2:vpc-endpoint-service", "Endpoint services (only those the account's endpoints connect to)", SupportPartial, "-third-party", true},
+	{"ec2:dhcp-options", "DHCP option sets", SupportYes, "", true},
+	{"ec2:vpc-peering-connection", "VPC peering connections", SupportYes, "", true},
+	{"ec2:elastic-ip", "Elastic IP addresses", SupportYes, "", true},
+	{"ec2:network-interface", "Network interfaces", SupportYes, "", true},
+	{"ec2:instance", "Instances (network placement)", SupportYes, "", false},
+	{"ec2:vpc-flow-log", "VPC flow logs", SupportYes, "", true},
+	{"globalaccelerator:accelerator", "Global Accelerator accelerators", SupportYes, "-public-ips", true},
+	{"autoscaling:autoScalingGroup", "Auto Scaling groups", SupportYes, "-asgs", false},
+	{"ecs:service", "ECS services (network configuration)", SupportYes, "-containers or -sg-usage", false},
+	{"eks:cluster", "EKS clusters (network configuration)", SupportYes, "-containers or -sg-usage", false},
+	{"eks:nodegroup", "EKS managed node groups", SupportYes, "-containers or -sg-usage", false},
+	{"eks:fargateprofile", "EKS Fargate profiles", SupportYes, "-containers or -sg-usage", false},
+	{"ds:directory", "Directory Service directories", SupportYes, "-directories", false},
+	{"workspaces:workspace", "WorkSpaces", SupportYes, "-directories", false},
+	{"apigateway:restapis", "API Gateway REST APIs (private APIs only)", SupportPartial, "-private-apis", true},
+	{"route53:hostedzone", "Route 53 hosted zones (private zones only)", SupportPartial, "-dns", true},
+	{"route53resolver:resolver-endpoint", "Route 53 Resolver endpoints", SupportYes, "-dns", true},
+	{"networkmanager:global-network", "Cloud WAN global networks", SupportYes, "-cloudwan", true},
+	{"networkmanager:core-network", "Cloud WAN core networks", SupportYes, "-cloudwan", true},
+	{"rds:db", "RDS instances (cross-region read replicas only)", SupportPartial, "-dr-replication", false},
+	{"elasticfilesystem:file-system", "EFS file systems (replication only)", SupportPartial, "-dr-replication", false},
+	{"elasticloadbalancing:loadbalancer", "Load balancers (node addresses only)", SupportPartial, "-public-ips", true},
+	{"elasticloadbalancing:targetgroup", "Load balancer target groups", SupportNo, "", true},
+	{"ec2:egress-only-internet-gateway", "Egress-only internet gateways", SupportNo, "", true},
+	{"ec2:transit-gateway-connect-peer", "Transit gateway Connect peers", SupportNo, "", true},
+	{"ec2:transit-gateway-multicast-domain", "Transit gateway multicast domains", SupportNo, "", true},
+	{"ec2:prefix-list", "Managed prefix lists", SupportNo, "", true},
+	{"ec2:traffic-mirror-session", "Traffic mirror sessions", SupportNo, "", true},
+	{"ec2:traffic-mirror-target", "Traffic mirror targets", SupportNo, "", true},
+	{"ec2:ipam", "IPAM instances", SupportNo, "", true},
+	{"ec2:ipam-pool", "IPAM pools", SupportNo, "", true},
+	{"ec2:verified-access-instance", "Verified Access instances", SupportNo, "", true},
+	{"network-firewall:firewall", "Network Firewall firewalls", SupportNo, "", true},
+	{"network-firewall:firewall-policy", "Network Firewall policies", SupportNo, "", true},
+	{"route53resolver:resolver-rule", "Route 53 Resolver rules", SupportNo, "", true},
+	{"route53resolver:firewall-rule-group", "Route 53 Resolver DNS Firewall rule groups", SupportNo, "", true},
+	{"directconnect:dxcon", "Direct Connect connections", SupportNo, "", true},
+	{"directconnect:dxvif", "Direct Connect virtual interfaces", SupportNo, "", true},
+	{"directconnect:dxlag", "Direct Connect link aggregation groups", SupportNo, "", true},
+	{"vpc-lattice:servicenetwork", "VPC Lattice service networks", SupportNo, "", true},
+	{"vpc-lattice:service", "VPC Lattice services", SupportNo, "", true},
+}
+
+// TaggingType derives the tagging API resource type from an ARN
+// arn:aws:ec2:us-east-1:123456789012:vpc/vpc-1 gives ec2:vpc and arn:aws:rds:...:db:name gives rds:db;
+// resources without a type in the ARN (S3 buckets, SNS topics) give the service alone. API Gateway resources
+// start with a slash (arn:aws:apigateway:...::/restapis/id gives apigateway:restapis).
+// Returns: Resource type, empty if the ARN cannot be parsed
+func TaggingType(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] == "" {
+		return ""
+	}
+	resource := strings.TrimPrefix(parts[5], "/")
+	if i := strings.IndexAny(resource, "/:"); i >= 0 {
+		return parts[2] + ":" + resource[:i]
+	}
+	return parts[2]
+}
+
+// Manifest records which resource types a scan covered, for the coverage report of a later run
+type Manifest struct {
+	GeneratedAt   string         `json:"generated_at"`   // When the scan ran
+	Region        string         `json:"region"`         // Region that was scanned
+	Partial       bool           `json:"partial"`        // Whether -max-api-calls cut the scan short
+	ResourceTypes map[string]int `json:"resource_types"` // Resources scanned per tagging API type
+}
+
+// NewManifest creates an empty manifest for a scan
+func NewManifest(region string, generatedAt time.Time) *Manifest {
+	return &Manifest{
+		GeneratedAt:   generatedAt.UTC().Format(time.RFC3339),
+		Region:        region,
+		ResourceTypes: make(map[string]int),
+	}
+}
+
+// Record adds the resources a scanner found to the manifest
+// resourceType: Tagging API type from ResourceTypes
+// count: Number of resources scanned
+func (m *Manifest) Record(resourceType string, count int) {
+	m.ResourceTypes[resourceType] += count
+}
+
+// Write saves the manifest as JSON
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scan manifest: %w", err)
+	}
+	return nil
+}
+
+// LoadManifest reads a manifest written by a scan
+// Returns: Manifest, or error if the file cannot be read or parsed
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse scan manifest %s: %w", path, err)
+	}
+	if manifest.ResourceTypes == nil {
+		manifest.ResourceTypes = make(map[string]int)
+	}
+	return &manifest, nil
+}
+
+// Row is one resource type of the coverage report
+type Row struct {
+	ResourceType string `json:"resource_type"` // Tagging API type
+	Description  string `json:"description"`   // Human-readable name (empty for types not in the mapping)
+	Supported    string `json:"supported"`     // yes, partial or no
+	Flags        string `json:"flags"`         // Flags that enable the scanner (empty for the default scan)
+	Network      bool   `json:"network"`       // Whether the type belongs in network documentation
+	Count        int    `json:"count"`         // Tagged resources of the type in the account and region
+	InLastScan   string `json:"in_last_scan"`  // yes, no or unknown (no manifest)
+}
+
+// Report is the coverage of an account by this tool
+type Report struct {
+	Rows                []Row `json:"rows"`                  // Supported types and every type present in the account, sorted by type
+	MissingNetworkTypes []Row `json:"missing_network_types"` // Network types present in the account but not scanned
+}
+
+// Compare builds the coverage report from the resource counts of the account
+// Types without a mapping are reported as unsupported and not network-related.
+// types: Mapping of resource types, usually ResourceTypes
+// counts: Resources per tagging API type from CountResources
+// manifest: Manifest of the last scan (nil if none was given)
+// Returns: Report with one row per supported or present type
+func Compare(types []ResourceType, counts map[string]int, manifest *Manifest) *Report {
+	report := &Report{
+		Rows:                []Row{},
+		MissingNetworkTypes: []Row{},
+	}
+
+	known := make(map[string]ResourceType, len(types))
+	for _, t := range types {
+		known[t.TaggingType] = t
+	}
+	include := make(map[string]bool)
+	for _, t := range types {
+		if t.Support != SupportNo {
+			include[t.TaggingType] = true
+		}
+	}
+	for resourceType, count := range counts {
+		if count > 0 {
+			include[resourceType] = true
+		}
+	}
+
+	names := make([]string, 0, len(include))
+	for resourceType := range include {
+		names = append(names, resourceType)
+	}
+	sort.Strings(names)
+
+	for _, resourceType := range names {
+		t, ok := known[resourceType]
+		if !ok {
+			t = ResourceType{TaggingType: resourceType, Support: SupportNo}
+		}
+		row := Row{
+			ResourceType: resourceType,
+			Description:  t.Description,
+			Supported:    t.Support,
+			Flags:        t.Flags,
+			Network:      t.Network,
+			Count:        counts[resourceType],
+			InLastScan:   LastScanUnknown,
+		}
+		if manifest != nil {
+			row.InLastScan = LastScanNo
+			if _, scanned := manifest.ResourceTypes[resourceType]; scanned {
+				row.InLastScan = LastScanYes
+			}
+		}
+		report.Rows = append(report.Rows, row)
+		if row.Network && row.Supported == SupportNo && row.Count > 0 {
+			report.MissingNetworkTypes = append(report.MissingNetworkTypes, row)
+		}
+	}
+	return report
+}
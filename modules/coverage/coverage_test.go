@@ -0,0 +1,141 @@
+package coverage
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTaggingType derives the tagging API type from the ARN shapes of the mapped services
+func TestTaggingType(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{arn: "arn:aws:ec2:eu-west-1:111122223333:vpc/vpc-0a1", want: "ec2:vpc"},
+		{arn: "arn:aws:ec2::111122223333:ipam/ipam-0a1", want: "ec2:ipam"},
+		{arn: "arn:aws:rds:eu-west-1:111122223333:db:orders", want: "rds:db"},
+		{arn: "arn:aws:apigateway:eu-west-1::/restapis/a1b2c3", want: "apigateway:restapis"},
+		{arn: "arn:aws:route53:::hostedzone/Z0A1", want: "route53:hostedzone"},
+		{arn: "arn:aws:elasticloadbalancing:eu-west-1:111122223333:loadbalancer/app/web/50dc6c495c0c9188", want: "elasticloadbalancing:loadbalancer"},
+		{arn: "arn:aws:autoscaling:eu-west-1:111122223333:autoScalingGroup:1a2b:autoScalingGroupName/web", want: "autoscaling:autoScalingGroup"},
+		{arn: "arn:aws:eks:eu-west-1:111122223333:nodegroup/prod/workers/1a2b", want: "eks:nodegroup"},
+		{arn: "arn:aws:eks:eu-west-1:111122223333:cluster/prod", want: "eks:cluster"},
+		{arn: "arn:aws:eks:eu-west-1:111122223333:fargateprofile/prod/system/3c4d", want: "eks:fargateprofile"},
+		{arn: "arn:aws:ecs:eu-west-1:111122223333:service/web/api", want: "ecs:service"},
+		{arn: "arn:aws:globalaccelerator::111122223333:accelerator/1a2b", want: "globalaccelerator:accelerator"},
+		{arn: "arn:aws-us-gov:network-firewall:us-gov-west-1:111122223333:firewall/edge", want: "network-firewall:firewall"},
+		{arn: "arn:aws:s3:::my-bucket", want: "s3"},
+		{arn: "arn:aws:sns:eu-west-1:111122223333:alerts", want: "sns"},
+		{arn: "vpc-0a1"},
+		{arn: "arn:aws::eu-west-1:111122223333:vpc/vpc-0a1"},
+		{arn: "urn:aws:ec2:eu-west-1:111122223333:vpc/vpc-0a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			if got := TaggingType(tt.arn); got != tt.want {
+				t.Errorf("TaggingType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResourceTypes checks that the maintained mapping names every type once, in service:type form, with a
+// valid support level, and that only scanned types name enabling flags
+func TestResourceTypes(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, rt := range ResourceTypes {
+		if seen[rt.TaggingType] {
+			t.Errorf("%s is mapped twice", rt.TaggingType)
+		}
+		seen[rt.TaggingType] = true
+		if service, resource, ok := strings.Cut(rt.TaggingType, ":"); !ok || service == "" || resource == "" {
+			t.Errorf("%s is not in service:type form", rt.TaggingType)
+		}
+		switch rt.Support {
+		case SupportYes, SupportPartial:
+		case SupportNo:
+			if rt.Flags != "" {
+				t.Errorf("%s is not scanned but names flags %q", rt.TaggingType, rt.Flags)
+			}
+		default:
+			t.Errorf("%s has support level %q", rt.TaggingType, rt.Support)
+		}
+		if rt.Description == "" {
+			t.Errorf("%s has no description", rt.TaggingType)
+		}
+	}
+}
+
+// TestCompare checks the rows of supported, partially supported, unsupported and unmapped types, the
+// network types missing from the documentation and the last scan column with and without a manifest
+func TestCompare(t *testing.T) {
+	types := []ResourceType{
+		{"ec2:vpc", "VPCs", SupportYes, "", true},
+		{"ec2:vpc-endpoint", "VPC endpoints", SupportYes, "-endpoint-coverage", true},
+		{"route53:hostedzone", "Hosted zones", SupportPartial, "-dns", true},
+		{"ec2:prefix-list", "Managed prefix lists", SupportNo, "", true},
+		{"ec2:ipam", "IPAM instances", SupportNo, "", true},
+		{"rds:db", "RDS instances", SupportPartial, "-dr-replication", false},
+	}
+	counts := map[string]int{"ec2:vpc": 3, "ec2:prefix-list": 2, "ec2:ipam": 0, "s3": 12, "route53:hostedzone": 4}
+	manifest := NewManifest("eu-west-1", time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	manifest.Record("ec2:vpc", 3)
+	manifest.Record("ec2:vpc-endpoint", 0) // Scanned, none found
+
+	report := Compare(types, counts, manifest)
+	want := []Row{
+		{ResourceType: "ec2:prefix-list", Description: "Managed prefix lists", Supported: SupportNo, Network: true, Count: 2, InLastScan: LastScanNo},
+		{ResourceType: "ec2:vpc", Description: "VPCs", Supported: SupportYes, Network: true, Count: 3, InLastScan: LastScanYes},
+		{ResourceType: "ec2:vpc-endpoint", Description: "VPC endpoints", Supported: SupportYes, Flags: "-endpoint-coverage", Network: true, InLastScan: LastScanYes},
+		{ResourceType: "rds:db", Description: "RDS instances", Supported: SupportPartial, Flags: "-dr-replication", InLastScan: LastScanNo},
+		{ResourceType: "route53:hostedzone", Description: "Hosted zones", Supported: SupportPartial, Flags: "-dns", Network: true, Count: 4, InLastScan: LastScanNo},
+		{ResourceType: "s3", Supported: SupportNo, Count: 12, InLastScan: LastScanNo},
+	}
+	if !reflect.DeepEqual(report.Rows, want) {
+		t.Errorf("rows = %+v\nwant %+v", report.Rows, want)
+	}
+	if !reflect.DeepEqual(report.MissingNetworkTypes, want[:1]) {
+		t.Errorf("missing network types = %+v, want only the present unsupported network type", report.MissingNetworkTypes)
+	}
+
+	for _, row := range Compare(types, counts, nil).Rows {
+		if row.InLastScan != LastScanUnknown {
+			t.Errorf("%s in last scan = %q without a manifest, want %q", row.ResourceType, row.InLastScan, LastScanUnknown)
+		}
+	}
+}
+
+// TestManifestRoundTrip writes a manifest and reads it back, and reads a manifest without resource types
+func TestManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	manifest := NewManifest("eu-west-1", time.Date(2026, 10, 16, 11, 30, 0, 0, time.FixedZone("CEST", 2*60*60)))
+	manifest.Partial = true
+	manifest.Record("ec2:instance", 4)
+	manifest.Record("ec2:instance", 2)
+	path := filepath.Join(dir, "manifest.json")
+	if err := manifest.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Manifest{GeneratedAt: "2026-10-16T09:30:00Z", Region: "eu-west-1", Partial: true, ResourceTypes: map[string]int{"ec2:instance": 6}}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("LoadManifest() = %+v, want %+v", loaded, want)
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	if err := (&Manifest{Region: "eu-west-1"}).Write(empty); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadManifest(empty); err != nil || loaded.ResourceTypes == nil {
+		t.Errorf("LoadManifest() of a manifest without types = %+v, %v, want an empty type map", loaded, err)
+	}
+	if _, err := LoadManifest(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadManifest() of a missing file succeeded")
+	}
+}
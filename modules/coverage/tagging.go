@@ -0,0 +1,50 @@
+package coverage
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+
+	"aws-documentor/modules/vpc"
+)
+
+// resourcesPerPage is the largest page GetResources returns
+const resourcesPerPage = 100
+
+// Scanner counts the resources of an account through the Resource Groups Tagging API
+type Scanner struct {
+	client *resourcegroupstaggingapi.Client // Tagging API client for making API calls
+}
+
+// NewScanner creates a new tagging API scanner instance with the provided AWS configuration
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		client: resourcegroupstaggingapi.NewFromConfig(cfg),
+	}
+}
+
+// CountResources counts the resources of the region per tagging API type
+// The tagging API only returns resources that have or had tags, so untagged resources (often default
+// VPCs and their subnets) are not counted. Global resources are returned by their home region only.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Resources per type; on error, the counts of the pages read so far with the error
+func (s *Scanner) CountResources(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+
+	paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(s.client, &resourcegroupstaggingapi.GetResourcesInput{
+		ResourcesPerPage: aws.Int32(resourcesPerPage),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return counts, vpc.NewServiceScanError("tag", "tagged resources", "GetResources", err)
+		}
+		for _, mapping := range page.ResourceTagMappingList {
+			if resourceType := TaggingType(aws.ToString(mapping.ResourceARN)); resourceType != "" {
+				counts[resourceType]++
+			}
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,95 @@
+package coverage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/vpc"
+)
+
+// taggingPages are the GetResources pages of the tests by PaginationToken
+var taggingPages = map[string]string{
+	"": `{"PaginationToken": "page-2", "ResourceTagMappingList": [
+		{"ResourceARN": "arn:aws:ec2:eu-west-1:111122223333:vpc/vpc-0a1"},
+		{"ResourceARN": "arn:aws:ec2:eu-west-1:111122223333:subnet/subnet-0a1"},
+		{"ResourceARN": "arn:aws:ec2:eu-west-1:111122223333:subnet/subnet-0b2"}]}`,
+	"page-2": `{"PaginationToken": "", "ResourceTagMappingList": [
+		{"ResourceARN": "arn:aws:network-firewall:eu-west-1:111122223333:firewall/edge"},
+		{"ResourceARN": "not-an-arn"},
+		{"ResourceARN": "arn:aws:s3:::logs"}]}`,
+}
+
+// newTestScanner returns a scanner whose tagging API endpoint answers GetResources from taggingPages
+// denied: Whether every call fails with AccessDeniedException
+// maxCalls: API call budget (0 for no limit)
+// requests: Counts the requests that reached the endpoint
+func newTestScanner(t *testing.T, denied bool, maxCalls int, requests *atomic.Int32) *Scanner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var input struct{ PaginationToken string }
+		json.NewDecoder(r.Body).Decode(&input)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if denied {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"AccessDeniedException","message":"not authorized to perform tag:GetResources"}`)
+			return
+		}
+		fmt.Fprint(w, taggingPages[input.PaginationToken])
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	}
+	budget := awsconfig.NewCallBudget(maxCalls)
+	cfg.APIOptions = append(cfg.APIOptions, budget.AddMiddleware)
+	return NewScanner(cfg)
+}
+
+// TestCountResources counts the resources of every page per type, within the call budget and without permission
+func TestCountResources(t *testing.T) {
+	firstPage := map[string]int{"ec2:vpc": 1, "ec2:subnet": 2}
+	tests := []struct {
+		name         string
+		denied       bool
+		maxCalls     int
+		want         map[string]int
+		wantRequests int32
+		wantErr      error
+	}{
+		{name: "all pages", want: map[string]int{"ec2:vpc": 1, "ec2:subnet": 2, "network-firewall:firewall": 1, "s3": 1}, wantRequests: 2},
+		{name: "budget of one call", maxCalls: 1, want: firstPage, wantRequests: 1, wantErr: awsconfig.ErrCallBudgetExceeded},
+		{name: "denied", denied: true, want: map[string]int{}, wantRequests: 1, wantErr: vpc.ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			counts, err := newTestScanner(t, tt.denied, tt.maxCalls, &requests).CountResources(context.Background())
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("counts = %v, want %v", counts, tt.want)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("%d requests reached the API, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,469 @@
+// Package crossaccount correlates the constructs that span accounts across the saved scans of several accounts:
+// VPC peering connections, transit gateway attachments, transit gateways shared with RAM and PrivateLink connections
+// Each account only sees its own side of such a construct, so one account's scan shows a peering pending
+// acceptance and the other's shows nothing. Correlating the scans by resource ID merges the sides into one
+// organization-level object and reports the sides that disagree.
+package crossaccount
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/report"
+)
+
+// Kinds of cross-account objects
+const (
+	KindPeering       = "vpc-peering"    // VPC peering connection between VPCs of two accounts
+	KindTGWAttachment = "tgw-attachment" // Transit gateway attachment of a resource of another account
+	KindRAMShare      = "ram-share"      // Transit gateway shared with other accounts through RAM
+	KindPrivateLink   = "privatelink"    // Interface endpoint connected to an endpoint service of another account
+)
+
+// Statuses of a cross-account object
+const (
+	StatusMatched        = "matched"          // Every side was found in the scan of its account and region
+	StatusHalfPresent    = "half-present"     // The scan of a side's account and region does not have the object
+	StatusPeerNotScanned = "peer-not-scanned" // The account or region of a side was not scanned
+)
+
+// Classifications of the organization-level findings
+const (
+	FindingSideMissing       = "side-missing"       // One side has the object, the scan of the other does not
+	FindingStateMismatch     = "state-mismatch"     // The sides see the object in different states
+	FindingPendingAcceptance = "pending-acceptance" // The object has waited for acceptance longer than the threshold
+	FindingShareNotAccepted  = "share-not-accepted" // An account attaches to a shared transit gateway its scan does not see
+)
+
+// Side roles
+const (
+	RoleRequester     = "requester"      // Requester of a peering connection
+	RoleAccepter      = "accepter"       // Accepter of a peering connection
+	RoleOwner         = "owner"          // Owner of the transit gateway
+	RoleResourceOwner = "resource-owner" // Owner of the attached resource
+	RoleParticipant   = "participant"    // Account a transit gateway is shared with
+	RoleProvider      = "provider"       // Owner of the endpoint service
+	RoleConsumer      = "consumer"       // Owner of the endpoint
+)
+
+// DefaultPendingThreshold is how long an object may wait for acceptance before it is reported
+const DefaultPendingThreshold = 24 * time.Hour
+
+// AccountScan is the saved scan of one account and region
+type AccountScan struct {
+	Name string             // Profile, or target and region, the scan belongs to
+	Scan *report.ScanResult // Resources of the scan, as written by -save
+}
+
+// Options are the settings of Correlate
+type Options struct {
+	PendingThreshold time.Duration // How long an object may wait for acceptance before it is reported
+	Now              time.Time     // Time the waiting is measured to
+}
+
+// DefaultOptions returns the default threshold, measured to now
+func DefaultOptions() Options {
+	return Options{PendingThreshold: DefaultPendingThreshold, Now: time.Now()}
+}
+
+// Side is the view of one account of a cross-account object
+type Side struct {
+	Role      string `json:"role"`       // requester, accepter, owner, resource-owner, participant, provider or consumer
+	AccountID string `json:"account_id"` // Account of the side
+	Region    string `json:"region"`     // Region the side is seen in
+	Scan      string `json:"scan"`       // Scan of the account and region (empty if they were not scanned)
+	Present   bool   `json:"present"`    // Whether the scan has the object
+	State     string `json:"state"`      // State the scan sees (empty if the object is missing from it)
+}
+
+// Object is a construct spanning accounts, with the sides of all its accounts
+type Object struct {
+	Kind   string `json:"kind"`   // vpc-peering, tgw-attachment, ram-share or privatelink
+	ID     string `json:"id"`     // Peering connection, attachment, transit gateway or endpoint ID
+	Status string `json:"status"` // matched, half-present or peer-not-scanned
+	Sides  []Side `json:"sides"`  // Sides in role order
+}
+
+// Finding is a cross-account object whose sides disagree
+type Finding struct {
+	Kind           string `json:"kind"`           // Kind of the object
+	ID             string `json:"id"`             // ID of the object
+	Classification string `json:"classification"` // side-missing, state-mismatch, pending-acceptance or share-not-accepted
+	Severity       string `json:"severity"`       // medium, or low for pending-acceptance
+	Reason         string `json:"reason"`         // Human-readable explanation
+}
+
+// Correlation is the organization-level view of the scans
+type Correlation struct {
+	Objects  []Object  `json:"objects"`  // Cross-account objects by kind and ID
+	Findings []Finding `json:"findings"` // Findings in object order
+}
+
+// object collects the sides of an object while the scans are read
+type object struct {
+	kind     string
+	id       string
+	sides    []Side            // Expected sides, without presence and state
+	observed map[string]string // State by account and region of the scans that have the object
+	created  string            // Creation time, RFC 3339 (empty if unknown)
+}
+
+// correlator indexes the scans and the objects found in them
+type correlator struct {
+	scans   map[string]AccountScan // Scans by account and region; the first scan of an account and region wins
+	objects map[string]*object     // Objects by kind and ID
+}
+
+// sideKey identifies the scan of an account and region
+func sideKey(accountID, region string) string {
+	return accountID + "/" + region
+}
+
+// Correlate merges the sides of the cross-account objects of the scans and reports the sides that disagree
+// Objects are matched by ID: peering connections, and attachments, by their own ID; shared transit gateways
+// by the transit gateway ID; PrivateLink connections by the endpoint ID of the consumer, which the provider
+// sees among the connections of its endpoint services. Objects within one account are left out.
+// scans: Saved scans, one per account and region
+// options: Threshold for objects pending acceptance
+// Returns: The objects and findings
+func Correlate(scans []AccountScan, options Options) *Correlation {
+	c := &correlator{scans: map[string]AccountScan{}, objects: map[string]*object{}}
+	for _, scan := range scans {
+		key := sideKey(scan.Scan.AccountID, scan.Scan.Region)
+		if _, ok := c.scans[key]; !ok && scan.Scan.AccountID != "" {
+			c.scans[key] = scan
+		}
+	}
+	c.peerings()
+	c.attachments()
+	c.shares()
+	c.privateLinks()
+
+	correlation := &Correlation{Objects: []Object{}, Findings: []Finding{}}
+	keys := make([]string, 0, len(c.objects))
+	for key := range c.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		obj := c.resolve(c.objects[key])
+		correlation.Objects = append(correlation.Objects, obj)
+		correlation.Findings = append(correlation.Findings, judge(obj, c.objects[key].created, options)...)
+	}
+	return correlation
+}
+
+// object returns the object of a kind and ID, creating it with the given sides
+// Sides are only set by the first scan that finds the object; the others are matched to them by account and region.
+func (c *correlator) object(kind, id string, sides ...Side) *object {
+	obj, ok := c.objects[kind+"/"+id]
+	if !ok {
+		obj = &object{kind: kind, id: id, sides: sides, observed: map[string]string{}}
+		c.objects[kind+"/"+id] = obj
+	}
+	return obj
+}
+
+// addSide adds a side unless the object has one for the account and region already
+func (obj *object) addSide(side Side) {
+	for _, existing := range obj.sides {
+		if existing.AccountID == side.AccountID && existing.Region == side.Region {
+			return
+		}
+	}
+	obj.sides = append(obj.sides, side)
+}
+
+// observe records the state a scan sees the object in
+func (obj *object) observe(scan *report.ScanResult, state, created string) {
+	obj.observed[sideKey(scan.AccountID, scan.Region)] = state
+	if obj.created == "" {
+		obj.created = created
+	}
+}
+
+// sortedScans returns the scans in account and region order, so objects get their sides in a stable order
+func (c *correlator) sortedScans() []*report.ScanResult {
+	keys := make([]string, 0, len(c.scans))
+	for key := range c.scans {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	scans := make([]*report.ScanResult, len(keys))
+	for i, key := range keys {
+		scans[i] = c.scans[key].Scan
+	}
+	return scans
+}
+
+// peerings adds the peering connections between VPCs of different accounts
+func (c *correlator) peerings() {
+	for _, scan := range c.sortedScans() {
+		for _, peering := range scan.VpcPeerings {
+			if peering.RequesterOwnerID == "" || peering.AccepterOwnerID == "" || peering.RequesterOwnerID == peering.AccepterOwnerID {
+				continue
+			}
+			c.object(KindPeering, peering.VpcPeeringConnectionID,
+				Side{Role: RoleRequester, AccountID: peering.RequesterOwnerID, Region: peering.RequesterRegion},
+				Side{Role: RoleAccepter, AccountID: peering.AccepterOwnerID, Region: peering.AccepterRegion},
+			).observe(scan, peering.Status, "")
+		}
+	}
+}
+
+// attachments adds the transit gateway attachments of resources another account owns
+// The owner of the transit gateway sees the attachment as well as the owner of the resource. A scan that
+// lists the attachment of a resource of another account is the transit gateway owner's.
+func (c *correlator) attachments() {
+	gatewayOwners := c.gatewayOwners()
+	for _, scan := range c.sortedScans() {
+		for _, attachment := range scan.TGWAttachments {
+			owner := gatewayOwners[attachment.TransitGatewayID]
+			if owner == "" && attachment.ResourceOwnerID != scan.AccountID {
+				owner = scan.AccountID
+			}
+			if owner == "" || attachment.ResourceOwnerID == "" || owner == attachment.ResourceOwnerID {
+				continue
+			}
+			// A peering attachment connects transit gateways, and its other side is in the peer region
+			resourceRegion := scan.Region
+			if attachment.PeerRegion != "" {
+				resourceRegion = attachment.PeerRegion
+			}
+			c.object(KindTGWAttachment, attachment.AttachmentID,
+				Side{Role: RoleOwner, AccountID: owner, Region: scan.Region},
+				Side{Role: RoleResourceOwner, AccountID: attachment.ResourceOwnerID, Region: resourceRegion},
+			).observe(scan, attachment.State, attachment.CreationTime)
+		}
+	}
+}
+
+// gatewayOwners returns the owner of every transit gateway the scans list
+func (c *correlator) gatewayOwners() map[string]string {
+	owners := map[string]string{}
+	for _, scan := range c.sortedScans() {
+		for _, gateway := range scan.TransitGateways {
+			if gateway.OwnerID != "" {
+				owners[gateway.TransitGatewayID] = gateway.OwnerID
+			}
+		}
+	}
+	return owners
+}
+
+// shares adds the transit gateways shared with other accounts
+// A shared transit gateway is listed in the scans of the accounts it is shared with, owned by another
+// account. Accounts that attach resources to a transit gateway are expected to see it as well, so an
+// attachment from an account whose scan does not list the gateway points at a share not accepted.
+func (c *correlator) shares() {
+	for _, scan := range c.sortedScans() {
+		for _, gateway := range scan.TransitGateways {
+			if gateway.OwnerID == "" {
+				continue
+			}
+			owner := Side{Role: RoleOwner, AccountID: gateway.OwnerID, Region: scan.Region}
+			if gateway.OwnerID != scan.AccountID {
+				obj := c.object(KindRAMShare, gateway.TransitGatewayID, owner)
+				obj.addSide(Side{Role: RoleParticipant, AccountID: scan.AccountID, Region: scan.Region})
+				obj.observe(scan, gateway.State, gateway.CreationTime)
+				continue
+			}
+			// The owner's view: the participants are the accounts attaching resources
+			var participants []string
+			for _, attachment := range scan.TGWAttachments {
+				if attachment.TransitGatewayID == gateway.TransitGatewayID && attachment.ResourceType == "vpc" &&
+					attachment.ResourceOwnerID != "" && attachment.ResourceOwnerID != scan.AccountID {
+					participants = append(participants, attachment.ResourceOwnerID)
+				}
+			}
+			if len(participants) == 0 && c.objects[KindRAMShare+"/"+gateway.TransitGatewayID] == nil {
+				continue
+			}
+			obj := c.object(KindRAMShare, gateway.TransitGatewayID, owner)
+			for _, participant := range participants {
+				obj.addSide(Side{Role: RoleParticipant, AccountID: participant, Region: scan.Region})
+			}
+			obj.observe(scan, gateway.State, gateway.CreationTime)
+		}
+	}
+	// Gateways the owner lists before a participant's scan is read got no object above
+	for _, scan := range c.sortedScans() {
+		for _, gateway := range scan.TransitGateways {
+			if obj := c.objects[KindRAMShare+"/"+gateway.TransitGatewayID]; obj != nil && gateway.OwnerID == scan.AccountID {
+				obj.observe(scan, gateway.State, gateway.CreationTime)
+			}
+		}
+	}
+}
+
+// privateLinks adds the interface endpoints connected to endpoint services of another account
+// The provider sees the endpoints connected to its services; the consumer sees its endpoint, named after
+// the service. A service is known to be provided by a scanned account once any of its connections is seen.
+func (c *correlator) privateLinks() {
+	providers := map[string]*report.ScanResult{} // Service ID -> scan of the provider
+	for _, scan := range c.sortedScans() {
+		for _, connection := range scan.VpcEndpointConnections {
+			if _, ok := providers[connection.ServiceID]; !ok {
+				providers[connection.ServiceID] = scan
+			}
+			if connection.VpcEndpointOwner == "" || connection.VpcEndpointOwner == scan.AccountID {
+				continue
+			}
+			c.object(KindPrivateLink, connection.VpcEndpointID,
+				Side{Role: RoleProvider, AccountID: scan.AccountID, Region: scan.Region},
+				Side{Role: RoleConsumer, AccountID: connection.VpcEndpointOwner, Region: scan.Region},
+			).observe(scan, connection.State, connection.CreationTime)
+		}
+	}
+	for _, scan := range c.sortedScans() {
+		for _, endpoint := range scan.VpcEndpoints {
+			provider := providers[endpointServiceID(endpoint.ServiceName)]
+			consumer := endpoint.OwnerID
+			if consumer == "" {
+				consumer = scan.AccountID
+			}
+			if provider == nil || provider.AccountID == consumer {
+				continue
+			}
+			c.object(KindPrivateLink, endpoint.VpcEndpointID,
+				Side{Role: RoleProvider, AccountID: provider.AccountID, Region: provider.Region},
+				Side{Role: RoleConsumer, AccountID: consumer, Region: scan.Region},
+			).observe(scan, endpoint.State, endpoint.CreationTime)
+		}
+	}
+}
+
+// endpointServiceID returns the vpce-svc-* ID at the end of the name of an endpoint service (empty for AWS services)
+func endpointServiceID(serviceName string) string {
+	id := serviceName[strings.LastIndex(serviceName, ".")+1:]
+	if !strings.HasPrefix(id, "vpce-svc-") {
+		return ""
+	}
+	return id
+}
+
+// resolve fills in the scan, presence and state of every side and decides the status of an object
+func (c *correlator) resolve(obj *object) Object {
+	resolved := Object{Kind: obj.kind, ID: obj.id, Status: StatusMatched, Sides: make([]Side, len(obj.sides))}
+	for i, side := range obj.sides {
+		key := sideKey(side.AccountID, side.Region)
+		if scan, ok := c.scans[key]; ok {
+			side.Scan = scan.Name
+		}
+		side.State, side.Present = obj.observed[key]
+		resolved.Sides[i] = side
+	}
+	// The owner and requester sides come first, then the others by account
+	sort.SliceStable(resolved.Sides, func(i, j int) bool {
+		if roleRank(resolved.Sides[i].Role) != roleRank(resolved.Sides[j].Role) {
+			return roleRank(resolved.Sides[i].Role) < roleRank(resolved.Sides[j].Role)
+		}
+		return sideKey(resolved.Sides[i].AccountID, resolved.Sides[i].Region) < sideKey(resolved.Sides[j].AccountID, resolved.Sides[j].Region)
+	})
+	for _, side := range resolved.Sides {
+		switch {
+		case side.Scan == "":
+			resolved.Status = StatusPeerNotScanned
+		case !side.Present && resolved.Status == StatusMatched:
+			resolved.Status = StatusHalfPresent
+		}
+	}
+	return resolved
+}
+
+// roleRank orders the sides of an object
+func roleRank(role string) int {
+	switch role {
+	case RoleRequester, RoleOwner, RoleProvider:
+		return 0
+	}
+	return 1
+}
+
+// judge reports the sides of an object that disagree
+// A side missing from the scan of its account is only reported while the object is alive elsewhere, as
+// AWS stops listing deleted and rejected objects in one account before the other.
+// created: Creation time of the object (empty if unknown)
+func judge(obj Object, created string, options Options) []Finding {
+	findings := []Finding{}
+	add := func(classification, severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{Kind: obj.Kind, ID: obj.ID, Classification: classification, Severity: severity,
+			Reason: fmt.Sprintf(format, args...)})
+	}
+
+	var present []Side
+	alive := false
+	for _, side := range obj.Sides {
+		if side.Present {
+			present = append(present, side)
+			alive = alive || !ended(side.State)
+		}
+	}
+	if len(present) == 0 {
+		return findings
+	}
+	for _, side := range obj.Sides {
+		if side.Present || side.Scan == "" || !alive {
+			continue
+		}
+		seen := present[0]
+		if obj.Kind == KindRAMShare && side.Role == RoleParticipant {
+			add(FindingShareNotAccepted, analysis.SeverityMedium, "%s %s attaches resources to transit gateway %s of %s, but its scan %s does not list the gateway: the RAM share was not accepted or was removed",
+				side.Role, side.AccountID, obj.ID, seen.AccountID, side.Scan)
+			continue
+		}
+		add(FindingSideMissing, analysis.SeverityMedium, "%s %s is %s in %s %s (%s) but missing from the scan %s of %s %s (%s): it was deleted on one side",
+			obj.Kind, obj.ID, seen.State, seen.Role, seen.AccountID, seen.Region, side.Scan, side.Role, side.AccountID, side.Region)
+	}
+	for _, side := range present[1:] {
+		if normalizeState(side.State) != normalizeState(present[0].State) {
+			add(FindingStateMismatch, analysis.SeverityMedium, "%s %s is %s in %s %s but %s in %s %s",
+				obj.Kind, obj.ID, present[0].State, present[0].Role, present[0].AccountID, side.State, side.Role, side.AccountID)
+			break
+		}
+	}
+	for _, side := range present {
+		if normalizeState(side.State) != "pendingacceptance" {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, created)
+		switch {
+		case err != nil:
+			add(FindingPendingAcceptance, analysis.SeverityLow, "%s %s is waiting for %s %s to accept it, for an unknown time", obj.Kind, obj.ID, acceptingSide(obj).Role, acceptingSide(obj).AccountID)
+		case options.Now.Sub(createdAt) > options.PendingThreshold:
+			add(FindingPendingAcceptance, analysis.SeverityLow, "%s %s has been waiting for %s %s to accept it since %s, longer than %s", obj.Kind, obj.ID,
+				acceptingSide(obj).Role, acceptingSide(obj).AccountID, created, options.PendingThreshold)
+		}
+		break
+	}
+	return findings
+}
+
+// acceptingSide returns the side that accepts an object: the accepter of a peering, the owner of an attached
+// transit gateway, the provider of an endpoint service
+func acceptingSide(obj Object) Side {
+	for _, side := range obj.Sides {
+		switch side.Role {
+		case RoleAccepter, RoleOwner, RoleProvider:
+			return side
+		}
+	}
+	return obj.Sides[0]
+}
+
+// normalizeState makes the state names of the APIs comparable: pending-acceptance and pendingAcceptance are the same
+func normalizeState(state string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(state))
+}
+
+// ended reports whether a state is one AWS lists for a while after the object is gone
+func ended(state string) bool {
+	switch normalizeState(state) {
+	case "deleted", "deleting", "rejected", "rejecting", "expired", "failed", "failing":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,387 @@
+package crossaccount
+
+import (
+	"testing"
+	"time"
+
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/vpc"
+)
+
+// Accounts of the tests: a network account owning the transit gateway and providing a service, and two workload accounts
+const (
+	network  = "111111111111"
+	workload = "222222222222"
+	other    = "333333333333"
+)
+
+// testNow is the time the pending acceptances of the tests are measured to
+var testNow = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// accountScan returns an empty scan of an account in eu-west-1, named after the account
+func accountScan(accountID string) AccountScan {
+	return AccountScan{Name: "scan-" + accountID, Scan: &report.ScanResult{AccountID: accountID, Region: "eu-west-1"}}
+}
+
+// peering returns the peering connection pcx-1 from the network to the workload account, as both see it
+func peering(status string) vpc.VpcPeeringConnectionInfo {
+	return vpc.VpcPeeringConnectionInfo{VpcPeeringConnectionID: "pcx-1", RequesterVpcID: "vpc-n", RequesterOwnerID: network, RequesterRegion: "eu-west-1",
+		AccepterVpcID: "vpc-w", AccepterOwnerID: workload, AccepterRegion: "eu-west-1", Status: status}
+}
+
+// attachment returns the attachment of VPC vpc-w of the workload account to transit gateway tgw-1 of the network account
+func attachment(state, created string) vpc.TransitGatewayAttachmentInfo {
+	return vpc.TransitGatewayAttachmentInfo{AttachmentID: "tgw-attach-1", TransitGatewayID: "tgw-1", ResourceType: "vpc", ResourceID: "vpc-w",
+		ResourceOwnerID: workload, State: state, CreationTime: created}
+}
+
+// gateway returns transit gateway tgw-1 of the network account
+func gateway(state string) vpc.TransitGatewayInfo {
+	return vpc.TransitGatewayInfo{TransitGatewayID: "tgw-1", OwnerID: network, State: state}
+}
+
+// endpoint returns interface endpoint vpce-1 of the workload account, connected to service vpce-svc-1 of the network account
+func endpoint(state string) vpc.VpcEndpointInfo {
+	return vpc.VpcEndpointInfo{VpcEndpointID: "vpce-1", OwnerID: workload, ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-1",
+		EndpointType: "Interface", State: state, CreationTime: "2024-03-01T00:00:00Z"}
+}
+
+// connection returns the network account's view of the connection of vpce-1 to its service
+func connection(state string) vpc.VpcEndpointConnectionInfo {
+	return vpc.VpcEndpointConnectionInfo{VpcEndpointID: "vpce-1", ServiceID: "vpce-svc-1", VpcEndpointOwner: workload, State: state,
+		CreationTime: "2024-03-01T00:00:00Z"}
+}
+
+// findObject returns the object of a kind and ID, failing the test if it is missing
+func findObject(t *testing.T, correlation *Correlation, kind, id string) Object {
+	t.Helper()
+	for _, obj := range correlation.Objects {
+		if obj.Kind == kind && obj.ID == id {
+			return obj
+		}
+	}
+	t.Fatalf("no %s %s in %+v", kind, id, correlation.Objects)
+	return Object{}
+}
+
+// classifications returns the classifications of the findings of an object
+func classifications(correlation *Correlation, kind, id string) []string {
+	found := []string{}
+	for _, finding := range correlation.Findings {
+		if finding.Kind == kind && finding.ID == id {
+			found = append(found, finding.Classification)
+		}
+	}
+	return found
+}
+
+// TestCorrelate covers matched, half-present and state-mismatched pairs of every kind, and sides of accounts not scanned
+func TestCorrelate(t *testing.T) {
+	tests := []struct {
+		name       string
+		network    func(scan *report.ScanResult) // Resources of the network account's scan
+		workload   func(scan *report.ScanResult) // Resources of the workload account's scan (nil if not scanned)
+		kind       string
+		id         string
+		wantStatus string
+		wantStates []string // State of each side in role order ("" for a missing side)
+		want       []string // Classifications of the findings of the object
+	}{
+		{
+			name:       "peering matched",
+			network:    func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("active")) },
+			workload:   func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("active")) },
+			kind:       KindPeering,
+			id:         "pcx-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"active", "active"},
+			want:       []string{},
+		},
+		{
+			name:       "peering half-present",
+			network:    func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("active")) },
+			workload:   func(s *report.ScanResult) {},
+			kind:       KindPeering,
+			id:         "pcx-1",
+			wantStatus: StatusHalfPresent,
+			wantStates: []string{"active", ""},
+			want:       []string{FindingSideMissing},
+		},
+		{
+			name:       "deleted peering half-present",
+			network:    func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("deleted")) },
+			workload:   func(s *report.ScanResult) {},
+			kind:       KindPeering,
+			id:         "pcx-1",
+			wantStatus: StatusHalfPresent,
+			wantStates: []string{"deleted", ""},
+			want:       []string{},
+		},
+		{
+			name:       "peering state mismatch",
+			network:    func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("deleted")) },
+			workload:   func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("active")) },
+			kind:       KindPeering,
+			id:         "pcx-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"deleted", "active"},
+			want:       []string{FindingStateMismatch},
+		},
+		{
+			name:       "peering pending acceptance",
+			network:    func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("pending-acceptance")) },
+			workload:   func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("pending-acceptance")) },
+			kind:       KindPeering,
+			id:         "pcx-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"pending-acceptance", "pending-acceptance"},
+			want:       []string{FindingPendingAcceptance},
+		},
+		{
+			name:       "peering with an account not scanned",
+			network:    func(s *report.ScanResult) { s.VpcPeerings = append(s.VpcPeerings, peering("active")) },
+			kind:       KindPeering,
+			id:         "pcx-1",
+			wantStatus: StatusPeerNotScanned,
+			wantStates: []string{"active", ""},
+			want:       []string{},
+		},
+		{
+			name: "attachment matched",
+			network: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("available", "2024-03-01T00:00:00Z"))
+			},
+			workload: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("available", "2024-03-01T00:00:00Z"))
+			},
+			kind:       KindTGWAttachment,
+			id:         "tgw-attach-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"available", "available"},
+			want:       []string{},
+		},
+		{
+			name: "attachment half-present",
+			network: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+			},
+			workload: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("available", "2024-03-01T00:00:00Z"))
+			},
+			kind:       KindTGWAttachment,
+			id:         "tgw-attach-1",
+			wantStatus: StatusHalfPresent,
+			wantStates: []string{"", "available"},
+			want:       []string{FindingSideMissing},
+		},
+		{
+			name: "attachment state mismatch",
+			network: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("deleting", "2024-03-01T00:00:00Z"))
+			},
+			workload: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("available", "2024-03-01T00:00:00Z"))
+			},
+			kind:       KindTGWAttachment,
+			id:         "tgw-attach-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"deleting", "available"},
+			want:       []string{FindingStateMismatch},
+		},
+		{
+			name: "attachment pending beyond the threshold",
+			network: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("pendingAcceptance", "2024-03-01T00:00:00Z"))
+			},
+			workload: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("pendingAcceptance", "2024-03-01T00:00:00Z"))
+			},
+			kind:       KindTGWAttachment,
+			id:         "tgw-attach-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"pendingAcceptance", "pendingAcceptance"},
+			want:       []string{FindingPendingAcceptance},
+		},
+		{
+			name: "attachment pending within the threshold",
+			network: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("pendingAcceptance", "2024-03-10T06:00:00Z"))
+			},
+			workload: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("pendingAcceptance", "2024-03-10T06:00:00Z"))
+			},
+			kind:       KindTGWAttachment,
+			id:         "tgw-attach-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"pendingAcceptance", "pendingAcceptance"},
+			want:       []string{},
+		},
+		{
+			name:       "share matched",
+			network:    func(s *report.ScanResult) { s.TransitGateways = append(s.TransitGateways, gateway("available")) },
+			workload:   func(s *report.ScanResult) { s.TransitGateways = append(s.TransitGateways, gateway("available")) },
+			kind:       KindRAMShare,
+			id:         "tgw-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"available", "available"},
+			want:       []string{},
+		},
+		{
+			name:       "share half-present",
+			network:    func(s *report.ScanResult) {},
+			workload:   func(s *report.ScanResult) { s.TransitGateways = append(s.TransitGateways, gateway("available")) },
+			kind:       KindRAMShare,
+			id:         "tgw-1",
+			wantStatus: StatusHalfPresent,
+			wantStates: []string{"", "available"},
+			want:       []string{FindingSideMissing},
+		},
+		{
+			name: "share not accepted",
+			network: func(s *report.ScanResult) {
+				s.TransitGateways = append(s.TransitGateways, gateway("available"))
+				s.TGWAttachments = append(s.TGWAttachments, attachment("available", "2024-03-01T00:00:00Z"))
+			},
+			workload:   func(s *report.ScanResult) {},
+			kind:       KindRAMShare,
+			id:         "tgw-1",
+			wantStatus: StatusHalfPresent,
+			wantStates: []string{"available", ""},
+			want:       []string{FindingShareNotAccepted},
+		},
+		{
+			name:       "share state mismatch",
+			network:    func(s *report.ScanResult) { s.TransitGateways = append(s.TransitGateways, gateway("deleted")) },
+			workload:   func(s *report.ScanResult) { s.TransitGateways = append(s.TransitGateways, gateway("available")) },
+			kind:       KindRAMShare,
+			id:         "tgw-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"deleted", "available"},
+			want:       []string{FindingStateMismatch},
+		},
+		{
+			name: "privatelink matched",
+			network: func(s *report.ScanResult) {
+				s.VpcEndpointConnections = append(s.VpcEndpointConnections, connection("available"))
+			},
+			workload:   func(s *report.ScanResult) { s.VpcEndpoints = append(s.VpcEndpoints, endpoint("available")) },
+			kind:       KindPrivateLink,
+			id:         "vpce-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"available", "available"},
+			want:       []string{},
+		},
+		{
+			name: "privatelink half-present",
+			network: func(s *report.ScanResult) {
+				s.VpcEndpointConnections = append(s.VpcEndpointConnections, connection("available"))
+			},
+			workload:   func(s *report.ScanResult) {},
+			kind:       KindPrivateLink,
+			id:         "vpce-1",
+			wantStatus: StatusHalfPresent,
+			wantStates: []string{"available", ""},
+			want:       []string{FindingSideMissing},
+		},
+		{
+			name: "privatelink state mismatch pending acceptance",
+			network: func(s *report.ScanResult) {
+				s.VpcEndpointConnections = append(s.VpcEndpointConnections, connection("rejected"))
+			},
+			workload:   func(s *report.ScanResult) { s.VpcEndpoints = append(s.VpcEndpoints, endpoint("pendingAcceptance")) },
+			kind:       KindPrivateLink,
+			id:         "vpce-1",
+			wantStatus: StatusMatched,
+			wantStates: []string{"rejected", "pendingAcceptance"},
+			want:       []string{FindingStateMismatch, FindingPendingAcceptance},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networkScan := accountScan(network)
+			tt.network(networkScan.Scan)
+			scans := []AccountScan{networkScan}
+			if tt.workload != nil {
+				workloadScan := accountScan(workload)
+				tt.workload(workloadScan.Scan)
+				scans = append(scans, workloadScan)
+			}
+
+			correlation := Correlate(scans, Options{PendingThreshold: DefaultPendingThreshold, Now: testNow})
+			obj := findObject(t, correlation, tt.kind, tt.id)
+			if obj.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", obj.Status, tt.wantStatus)
+			}
+			if len(obj.Sides) != len(tt.wantStates) {
+				t.Fatalf("sides = %+v, want %d", obj.Sides, len(tt.wantStates))
+			}
+			for i, side := range obj.Sides {
+				if side.State != tt.wantStates[i] || side.Present != (tt.wantStates[i] != "") {
+					t.Errorf("side %d (%s %s) has state %q, present %v; want %q", i, side.Role, side.AccountID, side.State, side.Present, tt.wantStates[i])
+				}
+			}
+			got := classifications(correlation, tt.kind, tt.id)
+			if len(got) != len(tt.want) {
+				t.Fatalf("findings = %v, want %v (%+v)", got, tt.want, correlation.Findings)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("findings = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestCorrelateSkipsSingleAccount checks that peerings, attachments and endpoints within one account are left out
+func TestCorrelateSkipsSingleAccount(t *testing.T) {
+	scan := accountScan(network)
+	local := peering("active")
+	local.AccepterOwnerID = network
+	scan.Scan.VpcPeerings = append(scan.Scan.VpcPeerings, local)
+	scan.Scan.TransitGateways = append(scan.Scan.TransitGateways, gateway("available"))
+	own := attachment("available", "")
+	own.ResourceOwnerID = network
+	scan.Scan.TGWAttachments = append(scan.Scan.TGWAttachments, own)
+	ownEndpoint := endpoint("available")
+	ownEndpoint.OwnerID = network
+	scan.Scan.VpcEndpoints = append(scan.Scan.VpcEndpoints, ownEndpoint)
+	ownConnection := connection("available")
+	ownConnection.VpcEndpointOwner = network
+	scan.Scan.VpcEndpointConnections = append(scan.Scan.VpcEndpointConnections, ownConnection)
+
+	correlation := Correlate([]AccountScan{scan, accountScan(other)}, Options{PendingThreshold: DefaultPendingThreshold, Now: testNow})
+	if len(correlation.Objects) != 0 || len(correlation.Findings) != 0 {
+		t.Errorf("objects %+v, findings %+v; want none", correlation.Objects, correlation.Findings)
+	}
+}
+
+// TestCorrelateSharedWithSeveral checks that a gateway shared with two accounts is one object with a side per participant
+func TestCorrelateSharedWithSeveral(t *testing.T) {
+	networkScan, workloadScan, otherScan := accountScan(network), accountScan(workload), accountScan(other)
+	networkScan.Scan.TransitGateways = append(networkScan.Scan.TransitGateways, gateway("available"))
+	workloadScan.Scan.TransitGateways = append(workloadScan.Scan.TransitGateways, gateway("available"))
+	otherScan.Scan.TransitGateways = append(otherScan.Scan.TransitGateways, gateway("available"))
+
+	correlation := Correlate([]AccountScan{otherScan, workloadScan, networkScan}, Options{PendingThreshold: DefaultPendingThreshold, Now: testNow})
+	obj := findObject(t, correlation, KindRAMShare, "tgw-1")
+	if obj.Status != StatusMatched || len(obj.Sides) != 3 {
+		t.Fatalf("object = %+v, want matched with 3 sides", obj)
+	}
+	wantAccounts := []string{network, workload, other}
+	for i, side := range obj.Sides {
+		if side.AccountID != wantAccounts[i] || side.Scan != "scan-"+wantAccounts[i] {
+			t.Errorf("side %d = %+v, want account %s", i, side, wantAccounts[i])
+		}
+	}
+}
@@ -4,8 +4,16 @@ package diagram
 import (
 	"encoding/xml"
 	"fmt"
+	"math"
 	"strings"
-
+	"sync"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/privateapi"
 	"aws-documentor/modules/vpc"
 )
 
@@ -41,17 +49,103 @@ type Root struct {
 
 // Cell represents a shape, connection, or container in the diagram
 type Cell struct {
-	ID       string    `xml:"id,attr"`
-	Value    string    `xml:"value,attr,omitempty"`
-	Style    string    `xml:"style,attr,omitempty"`
-	Parent   string    `xml:"parent,attr,omitempty"`
-	Vertex   string    `xml:"vertex,attr,omitempty"`
-	Edge     string    `xml:"edge,attr,omitempty"`
-	Geometry *Geometry `xml:"mxGeometry,omitempty"`
+	ID        string     `xml:"id,attr,omitempty"`
+	Value     string     `xml:"value,attr,omitempty"`
+	Style     string     `xml:"style,attr,omitempty"`
+	Parent    string     `xml:"parent,attr,omitempty"`
+	Vertex    string     `xml:"vertex,attr,omitempty"`
+	Edge      string     `xml:"edge,attr,omitempty"`
+	Source    string     `xml:"source,attr,omitempty"`
+	Target    string     `xml:"target,attr,omitempty"`
+	Collapsed string     `xml:"collapsed,attr,omitempty"`
+	Geometry  *Geometry  `xml:"mxGeometry,omitempty"`
+	Tooltip   string     `xml:"-"` // Full text of a truncated label; the cell is then wrapped in an <object>
+	Data      []xml.Attr `xml:"-"` // Resource attributes shown by draw.io's Edit Data dialog; the cell is then wrapped in an <object>
+
+	wrapper  string // Element the cell was read from when wrapped (object or UserObject), empty for a bare mxCell
+	hasLabel bool   // Whether the wrapper had a label attribute
+	innerID  string // ID of the mxCell inside the wrapper, which draw.io expects to be empty
+}
+
+// plainCell is a Cell without its XML methods
+type plainCell Cell
+
+// cellObject is the <object> element draw.io wraps a cell in to give it a tooltip or custom attributes
+// The object carries the ID and label of the cell; the inner mxCell has neither.
+type cellObject struct {
+	XMLName xml.Name   // object when written; UserObject is read as well
+	ID      string     `xml:"id,attr"`
+	Label   string     `xml:"label,attr"`
+	Tooltip string     `xml:"tooltip,attr,omitempty"`
+	Data    []xml.Attr `xml:",any,attr"`
+	Cell    plainCell  `xml:"mxCell"`
+}
+
+// MarshalXML writes a cell as an mxCell, or as an object wrapping an mxCell if it has a tooltip or data
+func (c Cell) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if c.Tooltip == "" && len(c.Data) == 0 {
+		return e.EncodeElement(plainCell(c), start)
+	}
+	inner := plainCell(c)
+	inner.ID, inner.Value = "", ""
+	return e.Encode(cellObject{XMLName: xml.Name{Local: "object"}, ID: c.ID, Label: c.Value, Tooltip: c.Tooltip, Data: c.Data, Cell: inner})
+}
+
+// UnmarshalXML reads the cells of a root in document order, unwrapping cells wrapped in objects
+func (r *Root) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "mxCell":
+				var cell plainCell
+				if err := d.DecodeElement(&cell, &t); err != nil {
+					return err
+				}
+				r.Cells = append(r.Cells, Cell(cell))
+			case "object", "UserObject":
+				var object cellObject
+				if err := d.DecodeElement(&object, &t); err != nil {
+					return err
+				}
+				cell := Cell(object.Cell)
+				cell.innerID = cell.ID
+				cell.ID, cell.Value, cell.Tooltip, cell.Data = object.ID, object.Label, object.Tooltip, object.Data
+				cell.wrapper = t.Name.Local
+				for _, attr := range t.Attr {
+					cell.hasLabel = cell.hasLabel || attr.Name.Local == "label"
+				}
+				r.Cells = append(r.Cells, cell)
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
 }
 
 // Geometry defines the position and size of a cell
 type Geometry struct {
+	X        float64 `xml:"x,attr,omitempty"`
+	Y        float64 `xml:"y,attr,omitempty"`
+	Width    float64 `xml:"width,attr,omitempty"`
+	Height   float64 `xml:"height,attr,omitempty"`
+	Relative string  `xml:"relative,attr,omitempty"` // "1" for edges, whose geometry is relative to their ends
+	As       string  `xml:"as,attr"`
+
+	// AlternateBounds is the expanded size of a collapsed container
+	AlternateBounds *Rectangle `xml:"mxRectangle,omitempty"`
+}
+
+// Rectangle is a bare rectangle attached to a geometry
+type Rectangle struct {
 	X      float64 `xml:"x,attr,omitempty"`
 	Y      float64 `xml:"y,attr,omitempty"`
 	Width  float64 `xml:"width,attr,omitempty"`
@@ -60,22 +154,155 @@ type Geometry struct {
 }
 
 // DiagramGenerator generates draw.io diagrams from VPC data
+// Cell IDs are allocated per diagram, so once configured with the Set* methods a generator is safe
+// for concurrent use by multiple goroutines; the Set* methods must not run concurrently with generation
 type DiagramGenerator struct {
-	cellIDCounter int
+	coreNetworks      []cloudwan.CoreNetworkInfo     // Cloud WAN core networks added to the overview diagram
+	directories       []directory.DirectoryInfo      // Directory Service directories drawn across their subnets
+	asgs              []asg.AutoScalingGroupInfo     // Auto Scaling groups drawn across their subnets
+	appliances        []vpc.RouteApplianceInfo       // NAT instances and routing appliances drawn in their subnets
+	instances         map[string][]vpc.InstanceInfo  // Instances keyed by subnet ID, counted in the labels of their subnets
+	endpoints         []vpc.VpcEndpointInfo          // VPC endpoints drawn in their subnets, gateway lane and route table panels
+	privateAPIs       []privateapi.PrivateAPIInfo    // Private APIs named in the labels of the execute-api endpoints that reach them
+	apisByEndpoint    map[string][]string            // Names of the private APIs each execute-api endpoint reaches, keyed by endpoint ID
+	networkACLs       []vpc.NetworkACLInfo           // Network ACLs drawn as panels beside the security groups of detail diagrams
+	peerings          []vpc.VpcPeeringConnectionInfo // VPC peering connections drawn as edges between VPC containers
+	vpnConnections    []vpc.VpnConnectionInfo        // Site-to-site VPN connections drawn as edges to their customer gateways
+	customerGateways  []vpc.CustomerGatewayInfo      // Customer gateways, drawn as on-premises icons for their VPN connections
+	vpnGateways       []vpc.VpnGatewayInfo           // Virtual private gateways drawn in the gateway lane of their VPC
+	elasticIPs        []vpc.ElasticIPInfo            // Elastic IPs, whose addresses annotate the NAT gateways they belong to
+	hideDefaultEgress bool                           // Leave the default allow-all egress rules out of security group panels
+	connectivity      map[string]string              // Internet connectivity class keyed by VPC ID
+	flapping          map[string]int                 // State changes within the flapping window of flapping attachments, keyed by attachment ID
+	region            string                         // Region written to the resource attributes of cells
+	accountID         string                         // Account written to the resource attributes of cells that have no owner of their own
+	plainCells        bool                           // Write bare mxCell elements without resource attributes or tooltips
+	notes             []string                       // Lines of the note above the diagram, such as truncation markers
+	dimmed            map[string]bool                // Managers whose resources are drawn muted
 }
 
 // NewDiagramGenerator creates a new diagram generator
 func NewDiagramGenerator() *DiagramGenerator {
-	return &DiagramGenerator{
-		cellIDCounter: 2, // Start at 2 (0 and 1 are reserved for root cells)
+	return &DiagramGenerator{}
+}
+
+// SetCoreNetworks adds Cloud WAN core networks to the overview diagram, drawn with one lane per segment
+func (dg *DiagramGenerator) SetCoreNetworks(coreNetworks []cloudwan.CoreNetworkInfo) {
+	dg.coreNetworks = coreNetworks
+}
+
+// SetDirectories adds Directory Service directories to the overview diagram, drawn across the subnets they occupy
+func (dg *DiagramGenerator) SetDirectories(directories []directory.DirectoryInfo) {
+	dg.directories = directories
+}
+
+// SetAutoScalingGroups adds Auto Scaling groups to the overview diagram, drawn across the subnets they launch into
+func (dg *DiagramGenerator) SetAutoScalingGroups(groups []asg.AutoScalingGroupInfo) {
+	dg.asgs = groups
+}
+
+// SetRouteAppliances adds NAT instances and other routed instances or interfaces to the overview diagram
+func (dg *DiagramGenerator) SetRouteAppliances(appliances []vpc.RouteApplianceInfo) {
+	dg.appliances = appliances
+}
+
+// SetInstances adds the instances of the scan to the overview diagram; each subnet label counts the instances in it
+// nil, as left by a failed instance scan, keeps the count out of the labels rather than showing zero.
+func (dg *DiagramGenerator) SetInstances(instances []vpc.InstanceInfo) {
+	if instances == nil {
+		dg.instances = nil
+		return
+	}
+	dg.instances = make(map[string][]vpc.InstanceInfo)
+	for _, instance := range instances {
+		dg.instances[instance.SubnetID] = append(dg.instances[instance.SubnetID], instance)
+	}
+}
+
+// SetVpcEndpoints adds VPC endpoints to the diagrams: interface endpoints in each of their subnets, gateway
+// endpoints in the gateway lane of their VPC and, in the detail diagrams, beside the route tables they add routes to
+func (dg *DiagramGenerator) SetVpcEndpoints(endpoints []vpc.VpcEndpointInfo) {
+	dg.endpoints = endpoints
+	dg.apisByEndpoint = analysis.PrivateAPIsByEndpoint(dg.privateAPIs, dg.endpoints)
+}
+
+// SetPrivateAPIs labels the execute-api endpoints with the names of the private APIs their traffic can reach
+func (dg *DiagramGenerator) SetPrivateAPIs(apis []privateapi.PrivateAPIInfo) {
+	dg.privateAPIs = apis
+	dg.apisByEndpoint = analysis.PrivateAPIsByEndpoint(dg.privateAPIs, dg.endpoints)
+}
+
+// SetNetworkACLs adds a panel per network ACL to the detail diagrams, beside the security group panels
+func (dg *DiagramGenerator) SetNetworkACLs(acls []vpc.NetworkACLInfo) {
+	dg.networkACLs = acls
+}
+
+// SetVpcPeeringConnections connects the VPCs of every active peering connection in the overview diagram
+func (dg *DiagramGenerator) SetVpcPeeringConnections(peerings []vpc.VpcPeeringConnectionInfo) {
+	dg.peerings = peerings
+}
+
+// SetVpnConnections adds site-to-site VPN connections to the overview diagram: virtual private gateways in the
+// gateway lane of their VPC, and a dashed edge per connection from its transit gateway or virtual private gateway
+// to an on-premises icon for its customer gateway
+func (dg *DiagramGenerator) SetVpnConnections(connections []vpc.VpnConnectionInfo, customerGateways []vpc.CustomerGatewayInfo, vpnGateways []vpc.VpnGatewayInfo) {
+	dg.vpnConnections = connections
+	dg.customerGateways = customerGateways
+	dg.vpnGateways = vpnGateways
+}
+
+// SetElasticIPs annotates each NAT gateway of the overview diagram with the public IP of its Elastic IP
+// A gateway with secondary addresses shows how many more it has.
+func (dg *DiagramGenerator) SetElasticIPs(elasticIPs []vpc.ElasticIPInfo) {
+	dg.elasticIPs = elasticIPs
+}
+
+// SetVPCConnectivity marks isolated VPCs in the overview diagram with a badge and a distinct border
+func (dg *DiagramGenerator) SetVPCConnectivity(vpcs []analysis.VPCConnectivity) {
+	dg.connectivity = make(map[string]string, len(vpcs))
+	for _, c := range vpcs {
+		dg.connectivity[c.VpcID] = c.Classification
+	}
+}
+
+// SetAttachmentStability marks flapping transit gateway attachments in the overview diagram with a badge
+func (dg *DiagramGenerator) SetAttachmentStability(attachments []analysis.AttachmentStability) {
+	dg.flapping = make(map[string]int)
+	for _, a := range attachments {
+		if a.Flapping {
+			dg.flapping[a.AttachmentID] = a.TransitionsInWindow
+		}
 	}
 }
 
-// nextID generates the next unique cell ID
-func (dg *DiagramGenerator) nextID() string {
-	id := fmt.Sprintf("cell-%d", dg.cellIDCounter)
-	dg.cellIDCounter++
-	return id
+// SetHideDefaultEgress leaves the default allow-all egress rules out of the security group panels
+func (dg *DiagramGenerator) SetHideDefaultEgress(hide bool) {
+	dg.hideDefaultEgress = hide
+}
+
+// SetScanContext sets the region and account written to the resource attributes of every cell
+func (dg *DiagramGenerator) SetScanContext(region, accountID string) {
+	dg.region, dg.accountID = region, accountID
+}
+
+// SetPlainCells writes every cell as a bare mxCell, for tools that do not read draw.io object elements
+// The cells then carry no resource attributes, and truncated labels have no tooltip with the full text.
+func (dg *DiagramGenerator) SetPlainCells(plain bool) {
+	dg.plainCells = plain
+}
+
+// SetNotes adds a note above every diagram, one line per note (used for the markers of truncated sections)
+func (dg *DiagramGenerator) SetNotes(notes []string) {
+	dg.notes = notes
+}
+
+// SetDimManaged draws the resources of the given managers (eks-lb-controller, karpenter, ...) muted,
+// so resources a controller created and maintains recede behind the ones people look after
+func (dg *DiagramGenerator) SetDimManaged(managers []string) {
+	dg.dimmed = make(map[string]bool, len(managers))
+	for _, manager := range managers {
+		dg.dimmed[manager] = true
+	}
 }
 
 // GenerateVPCDiagram creates a comprehensive VPC architecture diagram
@@ -112,25 +339,26 @@ func (dg *DiagramGenerator) GenerateVPCDiagram(
 		},
 	}
 
-	// Build diagram cells
-	var cells []Cell
-
-	// Generate VPC containers with their contents
-	xOffset := 50.0
-	for _, v := range vpcs {
-		vpcCells := dg.generateVPCContainer(v, subnets, internetGateways, natGateways, xOffset, 50)
-		cells = append(cells, vpcCells...)
-		xOffset += 1200 // Space between VPCs
+	// Compute the layout and convert it to cells
+	layout := ComputeLayout(vpcs, subnets, internetGateways, natGateways, transitGateways, tgwAttachments)
+	layout.AddDirectories(dg.directories)
+	layout.AddAutoScalingGroups(dg.asgs)
+	layout.AddRouteAppliances(dg.appliances)
+	layout.AddVpcEndpoints(dg.endpoints)
+	layout.AddVpcPeerings(dg.peerings)
+	if len(dg.coreNetworks) > 0 {
+		width, _ := layout.Bounds()
+		layout.AddCoreNetworks(dg.coreNetworks, vpcs, width+100, 50)
 	}
-
-	// Generate Transit Gateway section if present
-	if len(transitGateways) > 0 {
-		tgwCells := dg.generateTransitGatewaySection(transitGateways, tgwAttachments, vpcs, 50, xOffset+100)
-		cells = append(cells, tgwCells...)
+	if len(dg.vpnConnections) > 0 {
+		width, _ := layout.Bounds()
+		layout.AddVpnConnections(dg.vpnConnections, dg.customerGateways, dg.vpnGateways, width+100, 50)
 	}
+	ids := newIDSpace("overview")
+	cells := append(dg.cellsFromLayout(layout, ids), dg.noteCells(ids)...)
 
 	// Add all cells to the root
-	drawio.Diagram.MxGraphModel.Root.Cells = append(drawio.Diagram.MxGraphModel.Root.Cells, cells...)
+	drawio.Diagram.MxGraphModel.Root.Cells = append(drawio.Diagram.MxGraphModel.Root.Cells, dg.finishCells(cells)...)
 
 	// Marshal to XML
 	output, err := xml.MarshalIndent(drawio, "", "  ")
@@ -138,128 +366,276 @@ func (dg *DiagramGenerator) GenerateVPCDiagram(
 		return "", fmt.Errorf("failed to marshal diagram XML: %w", err)
 	}
 
-	return xml.Header + string(output), nil
+	doc := xml.Header + string(output)
+	if err := Validate(doc); err != nil {
+		return "", fmt.Errorf("generated invalid diagram: %w", err)
+	}
+	return doc, nil
 }
 
-// generateVPCContainer creates a VPC container with subnets and gateways
-func (dg *DiagramGenerator) generateVPCContainer(
-	vpcInfo vpc.VPCInfo,
-	allSubnets []vpc.SubnetInfo,
-	allIGWs []vpc.InternetGatewayInfo,
-	allNGWs []vpc.NatGatewayInfo,
-	x, y float64,
-) []Cell {
-	var cells []Cell
+// GenerateRegionsDiagram creates an overview diagram of several regions, each drawn as a region container
+// holding the VPCs and transit gateways of the region as GenerateVPCDiagram draws them
+// The cells carry the region of their container instead of the region set with SetScanContext.
+// regions: Core resources per region, in the order the containers are drawn
+func (dg *DiagramGenerator) GenerateRegionsDiagram(regions []RegionResources) (string, error) {
+	drawio := DrawIO{
+		Host:    "app.diagrams.net",
+		Version: "21.0.0",
+		Type:    "device",
+		Diagram: Diagram{
+			Name: "AWS VPC Infrastructure (all regions)",
+			ID:   "regions-diagram",
+			MxGraphModel: MxGraphModel{
+				Grid:      1,
+				GridSize:  10,
+				Page:      1,
+				PageScale: 1,
+				Root: Root{
+					Cells: []Cell{
+						{ID: "0"},
+						{ID: "1", Parent: "0"},
+					},
+				},
+			},
+		},
+	}
 
-	// Get subnets for this VPC
-	var vpcSubnets []vpc.SubnetInfo
-	for _, subnet := range allSubnets {
-		if subnet.VpcID == vpcInfo.VpcID {
-			vpcSubnets = append(vpcSubnets, subnet)
-		}
+	ids := newIDSpace("regions")
+	cells := append(dg.cellsFromLayout(ComputeRegionsLayout(regions), ids), dg.noteCells(ids)...)
+	drawio.Diagram.MxGraphModel.Root.Cells = append(drawio.Diagram.MxGraphModel.Root.Cells, dg.finishCells(cells)...)
+
+	output, err := xml.MarshalIndent(drawio, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal diagram XML: %w", err)
 	}
 
-	// Get IGWs for this VPC
-	var vpcIGWs []vpc.InternetGatewayInfo
-	for _, igw := range allIGWs {
-		if igw.VpcID == vpcInfo.VpcID {
-			vpcIGWs = append(vpcIGWs, igw)
-		}
+	doc := xml.Header + string(output)
+	if err := Validate(doc); err != nil {
+		return "", fmt.Errorf("generated invalid diagram: %w", err)
 	}
+	return doc, nil
+}
+
+// cellsFromLayout converts layout nodes into draw.io cells, nesting each node in its parent's cell
+func (dg *DiagramGenerator) cellsFromLayout(layout *Layout, ids *idSpace) []Cell {
+	var cells []Cell
 
-	// Get NAT Gateways for this VPC
-	var vpcNGWs []vpc.NatGatewayInfo
-	for _, ngw := range allNGWs {
-		if ngw.VpcID == vpcInfo.VpcID {
-			vpcNGWs = append(vpcNGWs, ngw)
+	// Resource ID -> cell ID, so children can reference their container
+	cellIDs := make(map[string]string)
+	// Resource ID -> ID of the VPC containing it, inherited from the container
+	vpcIDs := make(map[string]string)
+	// Resource ID -> region container holding it, inherited like the VPC (empty outside multi-region diagrams)
+	regions := make(map[string]string)
+
+	for _, node := range layout.Nodes {
+		parentID := "1"
+		if node.ParentID != "" {
+			parentID = cellIDs[node.ParentID]
 		}
-	}
 
-	// Separate public and private subnets
-	var publicSubnets []vpc.SubnetInfo
-	var privateSubnets []vpc.SubnetInfo
-	for _, subnet := range vpcSubnets {
-		if subnet.MapPublicIpOnLaunch {
-			publicSubnets = append(publicSubnets, subnet)
-		} else {
-			privateSubnets = append(privateSubnets, subnet)
+		id := ids.id(node.Kind, node.ResourceID)
+		var cell Cell
+		switch resource := node.Resource.(type) {
+		case RegionResources:
+			cell = dg.createRegionCell(id, parentID, node)
+		case vpc.VPCInfo:
+			cell = dg.createVPCCell(id, resource, parentID, node)
+		case vpc.SubnetInfo:
+			cell = dg.createSubnetCell(id, resource, parentID, node)
+		case vpc.InternetGatewayInfo:
+			cell = dg.createInternetGatewayCell(id, resource, parentID, node.X, node.Y)
+		case vpc.NatGatewayInfo:
+			cell = dg.createNATGatewayCell(id, resource, parentID, node)
+		case vpc.RouteApplianceInfo:
+			cell = dg.createRouteApplianceCell(id, resource, parentID, node)
+		case vpc.VpcEndpointInfo:
+			cell = dg.createVpcEndpointCell(id, parentID, node)
+		case vpc.TransitGatewayInfo:
+			cell = dg.createTransitGatewayCell(id, resource, parentID, node.X, node.Y)
+		case vpc.VpnGatewayInfo:
+			cell = dg.createVpnGatewayCell(id, resource, parentID, node.X, node.Y)
+		case vpc.CustomerGatewayInfo:
+			cell = dg.createCustomerGatewayCell(id, resource, parentID, node.X, node.Y)
+		case vpc.TransitGatewayAttachmentInfo:
+			cell = dg.createTGWAttachmentCell(id, resource, parentID, node.X, node.Y)
+		case asg.AutoScalingGroupInfo:
+			cell = dg.createASGCell(id, resource, parentID, node)
+		case directory.DirectoryInfo:
+			cell = dg.createDirectoryCell(id, resource, parentID, node)
+		case cloudwan.CoreNetworkInfo:
+			cell = dg.createCoreNetworkCell(id, resource, parentID, node)
+		case cloudwan.SegmentInfo:
+			cell = dg.createSegmentCell(id, resource, parentID, node)
+		case cloudwan.AttachmentInfo:
+			cell = dg.createCoreNetworkAttachmentCell(id, resource, parentID, node.X, node.Y)
+		default:
+			continue
 		}
-	}
 
-	// Calculate VPC container size based on content
-	maxSubnets := len(publicSubnets)
-	if len(privateSubnets) > maxSubnets {
-		maxSubnets = len(privateSubnets)
+		vpcIDs[node.ResourceID] = vpcIDs[node.ParentID]
+		if node.Kind == NodeVPC {
+			vpcIDs[node.ResourceID] = node.ResourceID
+		}
+		regions[node.ResourceID] = regions[node.ParentID]
+		if node.Kind == NodeRegion {
+			regions[node.ResourceID] = node.ResourceID
+		}
+		cell.Data = dg.resourceData(node.Kind, node.ResourceID, vpcIDs[node.ResourceID], node.Resource)
+		if region := regions[node.ResourceID]; region != "" {
+			cell.Data = withRegion(cell.Data, region)
+		}
+		dg.dimManaged(&cell, node.Resource)
+
+		cellIDs[node.ResourceID] = cell.ID
+		cells = append(cells, cell)
 	}
 
-	vpcWidth := 250.0 + float64(maxSubnets)*240.0 // IGW space + subnet width * count
-	vpcHeight := 400.0 // Fixed height for two rows of subnets
+	// Edges after the nodes, so their ends exist; containment lines are implied by the nesting
+	for _, edge := range layout.Edges {
+		var cell Cell
+		switch resource := edge.Resource.(type) {
+		case vpc.VpcPeeringConnectionInfo:
+			cell = dg.createPeeringEdgeCell(ids.id(resourceVpcPeering, resource.VpcPeeringConnectionID), resource, cellIDs[edge.From], cellIDs[edge.To])
+			cell.Data = dg.resourceData(resourceVpcPeering, resource.VpcPeeringConnectionID, "", resource)
+		case vpc.VpnConnectionInfo:
+			cell = dg.createVpnEdgeCell(ids.id(resourceVpnConnection, resource.VpnConnectionID), resource, cellIDs[edge.From], cellIDs[edge.To])
+			cell.Data = dg.resourceData(resourceVpnConnection, resource.VpnConnectionID, vpcIDs[edge.From], resource)
+		default:
+			continue
+		}
+		if region := regions[edge.From]; region != "" {
+			cell.Data = withRegion(cell.Data, region)
+		}
+		cells = append(cells, cell)
+	}
 
-	// Create VPC container with AWS VPC style
-	vpcID := dg.nextID()
-	vpcName := getResourceName(vpcInfo.Tags, vpcInfo.VpcID)
-	vpcLabel := fmt.Sprintf("VPC\n%s\n%s", vpcName, vpcInfo.CidrBlock)
+	return cells
+}
 
-	vpcCell := Cell{
-		ID:    vpcID,
-		Value: escapeXML(vpcLabel),
-		Style: "points=[[0,0],[0.25,0],[0.5,0],[0.75,0],[1,0],[1,0.25],[1,0.5],[1,0.75],[1,1],[0.75,1],[0.5,1],[0.25,1],[0,1],[0,0.75],[0,0.5],[0,0.25]];outlineConnect=0;gradientColor=none;html=1;whiteSpace=wrap;fontSize=12;fontStyle=0;container=1;pointerEvents=0;collapsible=0;recursiveResize=0;shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_vpc2;strokeColor=#8C4FFF;fillColor=none;verticalAlign=top;align=left;spacingLeft=30;fontColor=#AAB7B8;dashed=0;",
+// noteCells creates the note set with SetNotes, as a text cell above the diagram origin
+// Returns: The note cell, or nothing if there are no notes
+func (dg *DiagramGenerator) noteCells(ids *idSpace) []Cell {
+	if len(dg.notes) == 0 {
+		return nil
+	}
+	height := float64(len(dg.notes))*15 + 10
+	return []Cell{{
+		ID:     ids.id("note", "notes"),
+		Value:  escapeXML(strings.Join(dg.notes, "\n")),
+		Style:  "text;html=1;whiteSpace=wrap;fontSize=11;fontStyle=2;fontColor=#B85450;align=left;verticalAlign=top;",
 		Parent: "1",
 		Vertex: "1",
 		Geometry: &Geometry{
-			X:      x,
-			Y:      y,
-			Width:  vpcWidth,
-			Height: vpcHeight,
+			X:      50,
+			Y:      40 - height,
+			Width:  1000,
+			Height: height,
 			As:     "geometry",
 		},
-	}
-	cells = append(cells, vpcCell)
+	}}
+}
 
-	// Add Internet Gateways (vertical stack on the left)
-	igwY := 40.0
-	for _, igw := range vpcIGWs {
-		igwCell := dg.createInternetGatewayCell(igw, vpcID, 20, igwY)
-		cells = append(cells, igwCell)
-		igwY += 90
-	}
+// createRegionCell creates a region container cell sized by the layout, labeled with the region and its VPC count
+func (dg *DiagramGenerator) createRegionCell(id, parentID string, node LayoutNode) Cell {
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "points=[[0,0],[0.25,0],[0.5,0],[0.75,0],[1,0],[1,0.25],[1,0.5],[1,0.75],[1,1],[0.75,1],[0.5,1],[0.25,1],[0,1],[0,0.75],[0,0.5],[0,0.25]];outlineConnect=0;gradientColor=none;html=1;whiteSpace=wrap;fontSize=14;fontStyle=1;container=1;pointerEvents=0;collapsible=0;recursiveResize=0;shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_region;strokeColor=#00A4A6;fillColor=none;verticalAlign=top;align=left;spacingLeft=30;fontColor=#147EBA;dashed=1;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, fmt.Sprintf("Region %s (%s)", node.Name, node.Detail), labelBox{Width: node.Width - 40, FontSize: 14, MinFontSize: minLabelFontSize, MaxLines: 1})
+}
 
-	// Add public subnets horizontally (top row)
-	subnetX := 150.0
-	subnetY := 40.0
-	for _, subnet := range publicSubnets {
-		subnetCells := dg.createSubnetCell(subnet, vpcID, subnetX, subnetY)
-		cells = append(cells, subnetCells...)
+// createPeeringEdgeCell creates the edge between the VPC containers of a peering connection
+// The edge leaves and enters at the top of the containers, so it runs above the VPCs drawn between them.
+func (dg *DiagramGenerator) createPeeringEdgeCell(id string, pcx vpc.VpcPeeringConnectionInfo, sourceID, targetID string) Cell {
+	return Cell{
+		ID:     id,
+		Value:  escapeXML(getResourceName(pcx.Tags, pcx.VpcPeeringConnectionID)),
+		Style:  "edgeStyle=orthogonalEdgeStyle;rounded=1;html=1;endArrow=none;startArrow=none;dashed=1;strokeColor=#8C4FFF;strokeWidth=2;fontSize=11;fontColor=#8C4FFF;labelBackgroundColor=#FFFFFF;exitX=0.5;exitY=0;exitDx=0;exitDy=0;entryX=0.5;entryY=0;entryDx=0;entryDy=0;",
+		Parent: "1",
+		Edge:   "1",
+		Source: sourceID,
+		Target: targetID,
+		Geometry: &Geometry{
+			Relative: "1",
+			As:       "geometry",
+		},
+	}
+}
 
-		// Check if this subnet has a NAT Gateway
-		for _, ngw := range vpcNGWs {
-			if ngw.SubnetID == subnet.SubnetID {
-				ngwCell := dg.createNATGatewayCell(ngw, subnet.SubnetID, 40, 50)
-				cells = append(cells, ngwCell)
-			}
-		}
+// createVpnEdgeCell creates the dashed edge between the AWS end and the customer gateway of a VPN connection
+// The label counts the tunnels that are up, so a connection running on one tunnel stands out.
+func (dg *DiagramGenerator) createVpnEdgeCell(id string, conn vpc.VpnConnectionInfo, sourceID, targetID string) Cell {
+	label := getResourceName(conn.Tags, conn.VpnConnectionID)
+	if len(conn.Tunnels) > 0 {
+		label += fmt.Sprintf("\n%d/%d tunnels up", conn.TunnelsUp(), len(conn.Tunnels))
+	}
+	return Cell{
+		ID:     id,
+		Value:  escapeXML(label),
+		Style:  "edgeStyle=orthogonalEdgeStyle;rounded=1;html=1;endArrow=none;startArrow=none;dashed=1;strokeColor=#8C4FFF;strokeWidth=2;fontSize=11;fontColor=#8C4FFF;labelBackgroundColor=#FFFFFF;",
+		Parent: "1",
+		Edge:   "1",
+		Source: sourceID,
+		Target: targetID,
+		Geometry: &Geometry{
+			Relative: "1",
+			As:       "geometry",
+		},
+	}
+}
 
-		subnetX += 240.0 // Move right for next subnet
+// createVPCCell creates a VPC container cell sized by the layout
+func (dg *DiagramGenerator) createVPCCell(id string, vpcInfo vpc.VPCInfo, parentID string, node LayoutNode) Cell {
+	vpcName := getResourceName(vpcInfo.Tags, vpcInfo.VpcID)
+	vpcLabel := fmt.Sprintf("VPC\n%s\n%s", vpcName, vpcCIDRs(vpcInfo))
+	vpcLines := 4
+	vpcStyle := "points=[[0,0],[0.25,0],[0.5,0],[0.75,0],[1,0],[1,0.25],[1,0.5],[1,0.75],[1,1],[0.75,1],[0.5,1],[0.25,1],[0,1],[0,0.75],[0,0.5],[0,0.25]];outlineConnect=0;gradientColor=none;html=1;whiteSpace=wrap;fontSize=12;fontStyle=0;container=1;pointerEvents=0;collapsible=0;recursiveResize=0;shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_vpc2;strokeColor=#8C4FFF;fillColor=none;verticalAlign=top;align=left;spacingLeft=30;fontColor=#AAB7B8;dashed=0;"
+
+	// Isolated VPCs are deliberate in regulated environments, so they get a badge rather than looking unfinished
+	if dg.connectivity[vpcInfo.VpcID] == analysis.ConnectivityIsolated {
+		vpcLabel = fmt.Sprintf("VPC [ISOLATED]\n%s\n%s\nno internet path", vpcName, vpcCIDRs(vpcInfo))
+		vpcLines = 5
+		vpcStyle = "points=[[0,0],[0.25,0],[0.5,0],[0.75,0],[1,0],[1,0.25],[1,0.5],[1,0.75],[1,1],[0.75,1],[0.5,1],[0.25,1],[0,1],[0,0.75],[0,0.5],[0,0.25]];outlineConnect=0;gradientColor=none;html=1;whiteSpace=wrap;fontSize=12;fontStyle=1;container=1;pointerEvents=0;collapsible=0;recursiveResize=0;shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_vpc2;strokeColor=#232F3E;strokeWidth=3;fillColor=#F4F4F4;verticalAlign=top;align=left;spacingLeft=30;fontColor=#232F3E;dashed=1;dashPattern=8 4;"
 	}
 
-	// Add private subnets horizontally (bottom row)
-	subnetX = 150.0
-	subnetY = 220.0 // Below public subnets
-	for _, subnet := range privateSubnets {
-		subnetCells := dg.createSubnetCell(subnet, vpcID, subnetX, subnetY)
-		cells = append(cells, subnetCells...)
+	return labelCell(Cell{
+		ID:     id,
+		Style:  vpcStyle,
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, vpcLabel, labelBox{Width: node.Width - 40, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: vpcLines})
+}
 
-		subnetX += 240.0 // Move right for next subnet
+// vpcCIDRs lists the CIDR blocks of a VPC for its label: the primary block, then any secondary IPv4 and IPv6 blocks
+func vpcCIDRs(vpcInfo vpc.VPCInfo) string {
+	blocks := []string{vpc.OrUnknown(vpcInfo.CidrBlock)}
+	for _, block := range vpcInfo.AssociateCidrBlocks {
+		if block != vpcInfo.CidrBlock {
+			blocks = append(blocks, block)
+		}
 	}
-
-	return cells
+	blocks = append(blocks, vpcInfo.Ipv6CidrBlocks...)
+	return strings.Join(blocks, ", ")
 }
 
 // createSubnetCell creates a subnet cell with details
-func (dg *DiagramGenerator) createSubnetCell(subnet vpc.SubnetInfo, parentID string, x, y float64) []Cell {
-	var cells []Cell
-
-	subnetID := dg.nextID()
+// The cell takes the height the layout gave it, which grows with the rows of its icon grid.
+func (dg *DiagramGenerator) createSubnetCell(id string, subnet vpc.SubnetInfo, parentID string, node LayoutNode) Cell {
 	subnetName := getResourceName(subnet.Tags, subnet.SubnetID)
 	subnetType := "Private subnet"
 	subnetStyle := "points=[[0,0],[0.25,0],[0.5,0],[0.75,0],[1,0],[1,0.25],[1,0.5],[1,0.75],[1,1],[0.75,1],[0.5,1],[0.25,1],[0,1],[0,0.75],[0,0.5],[0,0.25]];outlineConnect=0;gradientColor=none;html=1;whiteSpace=wrap;fontSize=12;fontStyle=0;container=1;pointerEvents=0;collapsible=0;recursiveResize=0;shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_security_group;grStroke=0;strokeColor=#00A4A6;fillColor=#E6F6F7;verticalAlign=top;align=left;spacingLeft=30;fontColor=#147EBA;dashed=0;"
@@ -269,35 +645,47 @@ func (dg *DiagramGenerator) createSubnetCell(subnet vpc.SubnetInfo, parentID str
 		subnetStyle = "points=[[0,0],[0.25,0],[0.5,0],[0.75,0],[1,0],[1,0.25],[1,0.5],[1,0.75],[1,1],[0.75,1],[0.5,1],[0.25,1],[0,1],[0,0.75],[0,0.5],[0,0.25]];outlineConnect=0;gradientColor=none;html=1;whiteSpace=wrap;fontSize=12;fontStyle=0;container=1;pointerEvents=0;collapsible=0;recursiveResize=0;shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_security_group;grStroke=0;strokeColor=#7AA116;fillColor=#F2F6E8;verticalAlign=top;align=left;spacingLeft=30;fontColor=#248814;dashed=0;"
 	}
 
-	subnetLabel := fmt.Sprintf("%s\n%s\n%s\nAZ: %s", subnetType, subnetName, subnet.CidrBlock, subnet.AvailabilityZone)
+	subnetLabel := fmt.Sprintf("%s\n%s\n%s\nAZ: %s", subnetType, subnetName, vpc.OrUnknown(subnet.CidrBlock), vpc.OrUnknown(subnet.AvailabilityZone))
+	if dg.instances != nil {
+		subnetLabel += fmt.Sprintf("\nInstances: %d", len(dg.instances[subnet.SubnetID]))
+	}
+	if bar := utilizationBar(subnet); bar != "" {
+		subnetLabel += "\n" + bar
+	}
 
-	subnetCell := Cell{
-		ID:     subnetID,
-		Value:  escapeXML(subnetLabel),
+	return labelCell(Cell{
+		ID:     id,
 		Style:  subnetStyle,
 		Parent: parentID,
 		Vertex: "1",
 		Geometry: &Geometry{
-			X:      x,
-			Y:      y,
-			Width:  200,
-			Height: 140,
+			X:      node.X,
+			Y:      node.Y,
+			Width:  subnetWidth,
+			Height: node.Height,
 			As:     "geometry",
 		},
-	}
-	cells = append(cells, subnetCell)
+	}, subnetLabel, labelBox{Width: 160, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 6})
+}
 
-	return cells
+// utilizationBar draws the share of a subnet's usable IPv4 addresses in use as a ten-block bar, such as
+// "██████░░░░ 58% used", so near-full subnets stand out in the diagram
+// Returns: The bar, or "" if the subnet has no IPv4 block or the unused addresses are unknown (older reports)
+func utilizationBar(subnet vpc.SubnetInfo) string {
+	if subnet.TotalIpAddressCount <= 0 || subnet.AvailableIpAddressCount == nil {
+		return ""
+	}
+	filled := int(math.Round(subnet.UtilizationPercent / 10))
+	return fmt.Sprintf("%s%s %.0f%% used", strings.Repeat("█", filled), strings.Repeat("░", 10-filled), subnet.UtilizationPercent)
 }
 
 // createInternetGatewayCell creates an Internet Gateway cell
-func (dg *DiagramGenerator) createInternetGatewayCell(igw vpc.InternetGatewayInfo, parentID string, x, y float64) Cell {
+func (dg *DiagramGenerator) createInternetGatewayCell(id string, igw vpc.InternetGatewayInfo, parentID string, x, y float64) Cell {
 	igwName := getResourceName(igw.Tags, igw.InternetGatewayID)
 	igwLabel := fmt.Sprintf("Internet Gateway\n%s", igwName)
 
-	return Cell{
-		ID:     dg.nextID(),
-		Value:  escapeXML(igwLabel),
+	return labelCell(Cell{
+		ID:     id,
 		Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.internet_gateway;",
 		Parent: parentID,
 		Vertex: "1",
@@ -308,20 +696,120 @@ func (dg *DiagramGenerator) createInternetGatewayCell(igw vpc.InternetGatewayInf
 			Height: 78,
 			As:     "geometry",
 		},
-	}
+	}, igwLabel, labelBox{Width: laneWidth - 10, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 2})
 }
 
-// createNATGatewayCell creates a NAT Gateway cell
-func (dg *DiagramGenerator) createNATGatewayCell(ngw vpc.NatGatewayInfo, parentID string, x, y float64) Cell {
+// createNATGatewayCell creates a NAT Gateway cell in the icon grid of its subnet
+// Once Elastic IPs are set, the first label line shows the public IP of the gateway rather than its type,
+// which the icon already tells.
+func (dg *DiagramGenerator) createNATGatewayCell(id string, ngw vpc.NatGatewayInfo, parentID string, node LayoutNode) Cell {
 	ngwName := getResourceName(ngw.Tags, ngw.NatGatewayID)
 	ngwLabel := fmt.Sprintf("NAT Gateway\n%s", ngwName)
+	if eips := vpc.NatGatewayElasticIPs([]vpc.NatGatewayInfo{ngw}, dg.elasticIPs)[ngw.NatGatewayID]; len(eips) > 0 {
+		publicIPs := eips[0].PublicIp
+		if len(eips) > 1 {
+			publicIPs += fmt.Sprintf(" +%d", len(eips)-1)
+		}
+		ngwLabel = fmt.Sprintf("%s\n%s", publicIPs, ngwName)
+	}
 
-	return Cell{
-		ID:     dg.nextID(),
-		Value:  escapeXML(ngwLabel),
+	return labelCell(Cell{
+		ID:     id,
 		Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.nat_gateway;",
 		Parent: parentID,
 		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, ngwLabel, labelBox{Width: gridCellWidth - 5, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 2})
+}
+
+// createRouteApplianceCell creates a router icon for a NAT instance or routing appliance
+// Appliances that drop forwarded traffic because source/destination checking is enabled are outlined in red
+func (dg *DiagramGenerator) createRouteApplianceCell(id string, appliance vpc.RouteApplianceInfo, parentID string, node LayoutNode) Cell {
+	applianceLabel := fmt.Sprintf("%s\n%s", node.Detail, node.Name)
+
+	strokeColor := "none"
+	if appliance.SourceDestCheck {
+		strokeColor = "#DD344C"
+	}
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=" + strokeColor + ";dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=10;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.router;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, applianceLabel, labelBox{Width: gridCellWidth - 5, FontSize: 10, MinFontSize: minLabelFontSize, MaxLines: 2})
+}
+
+// createVpcEndpointCell creates a VPC endpoint icon, in the icon grid of a subnet or the gateway lane of a VPC
+// Endpoints that are not available (pending acceptance, rejected, failed, ...) are drawn dashed.
+func (dg *DiagramGenerator) createVpcEndpointCell(id, parentID string, node LayoutNode) Cell {
+	endpoint := node.Resource.(vpc.VpcEndpointInfo)
+	kind := "Interface endpoint"
+	box := labelBox{Width: gridCellWidth - 5, FontSize: 10, MinFontSize: minLabelFontSize, MaxLines: 2}
+	switch {
+	case node.Kind == NodeGatewayEndpoint:
+		kind = "Gateway endpoint"
+		box = labelBox{Width: laneWidth - 10, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 2}
+	case endpoint.EndpointType == vpc.EndpointTypeGatewayLoadBalancer:
+		kind = "GWLB endpoint"
+	}
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  endpointStyle(endpoint),
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, fmt.Sprintf("%s\n%s", kind, endpointDetail(node.Detail, dg.apisByEndpoint[endpoint.VpcEndpointID])), box)
+}
+
+// endpointDetail returns the service line of an endpoint label, followed by the private APIs an execute-api endpoint reaches
+// Labels too long for the icon are truncated, with the full list in the tooltip.
+func endpointDetail(service string, apis []string) string {
+	if len(apis) == 0 {
+		return service
+	}
+	return fmt.Sprintf("%s: %s", service, strings.Join(apis, ", "))
+}
+
+// endpointStyle returns the icon style of a VPC endpoint, dashed unless it is available
+func endpointStyle(endpoint vpc.VpcEndpointInfo) string {
+	style := "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=10;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.endpoints;"
+	if !strings.EqualFold(endpoint.State, "available") {
+		style = strings.Replace(style, "strokeColor=none;dashed=0;", "strokeColor=#879196;dashed=1;", 1)
+	}
+	return style
+}
+
+// createTransitGatewayCell creates a Transit Gateway cell
+func (dg *DiagramGenerator) createTransitGatewayCell(id string, tgw vpc.TransitGatewayInfo, parentID string, x, y float64) Cell {
+	tgwName := getResourceName(tgw.Tags, tgw.TransitGatewayID)
+	tgwLabel := fmt.Sprintf("Transit Gateway\n%s\nASN: %d", tgwName, tgw.AmazonSideAsn)
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.transit_gateway;",
+		Parent: parentID,
+		Vertex: "1",
 		Geometry: &Geometry{
 			X:      x,
 			Y:      y,
@@ -329,68 +817,189 @@ func (dg *DiagramGenerator) createNATGatewayCell(ngw vpc.NatGatewayInfo, parentI
 			Height: 78,
 			As:     "geometry",
 		},
+	}, tgwLabel, labelBox{Width: iconLabelWidth, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 4})
+}
+
+// createVpnGatewayCell creates a virtual private gateway cell in the gateway lane of its VPC
+func (dg *DiagramGenerator) createVpnGatewayCell(id string, vgw vpc.VpnGatewayInfo, parentID string, x, y float64) Cell {
+	vgwLabel := fmt.Sprintf("VPN Gateway\n%s", getResourceName(vgw.Tags, vgw.VpnGatewayID))
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.vpn_gateway;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      x,
+			Y:      y,
+			Width:  78,
+			Height: 78,
+			As:     "geometry",
+		},
+	}, vgwLabel, labelBox{Width: laneWidth - 10, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 2})
+}
+
+// createCustomerGatewayCell creates the on-premises icon of a customer gateway, with its public IP address
+func (dg *DiagramGenerator) createCustomerGatewayCell(id string, cgw vpc.CustomerGatewayInfo, parentID string, x, y float64) Cell {
+	cgwLabel := fmt.Sprintf("On-premises\n%s", getResourceName(cgw.Tags, cgw.CustomerGatewayID))
+	if cgw.IpAddress != "" {
+		cgwLabel += "\n" + cgw.IpAddress
 	}
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#7D8998;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.customer_gateway;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      x,
+			Y:      y,
+			Width:  78,
+			Height: 78,
+			As:     "geometry",
+		},
+	}, cgwLabel, labelBox{Width: iconLabelWidth, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 3})
 }
 
-// generateTransitGatewaySection creates Transit Gateway visualization with attachments
-func (dg *DiagramGenerator) generateTransitGatewaySection(
-	transitGateways []vpc.TransitGatewayInfo,
-	tgwAttachments []vpc.TransitGatewayAttachmentInfo,
-	vpcs []vpc.VPCInfo,
-	x, y float64,
-) []Cell {
-	var cells []Cell
+// createTGWAttachmentCell creates a Transit Gateway attachment cell
+func (dg *DiagramGenerator) createTGWAttachmentCell(id string, attachment vpc.TransitGatewayAttachmentInfo, parentID string, x, y float64) Cell {
+	attachName := getResourceName(attachment.Tags, attachment.AttachmentID)
+	attachLabel := fmt.Sprintf("TGW Attachment\n%s\n%s", attachName, vpc.OrUnknown(attachment.State))
+	attachStyle := "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.transit_gateway_attachment;"
 
-	for i, tgw := range transitGateways {
-		tgwID := dg.nextID()
-		tgwName := getResourceName(tgw.Tags, tgw.TransitGatewayID)
-		tgwLabel := fmt.Sprintf("Transit Gateway\n%s\nASN: %d", tgwName, tgw.AmazonSideAsn)
+	// A flapping attachment looks healthy whenever it happens to be available at scan time, so the badge carries its history
+	if changes, ok := dg.flapping[attachment.AttachmentID]; ok {
+		attachLabel = fmt.Sprintf("TGW Attachment [FLAPPING]\n%s\n%s, %d state changes", attachName, vpc.OrUnknown(attachment.State), changes)
+		attachStyle = "sketch=0;outlineConnect=0;fontColor=#D13212;gradientColor=none;fillColor=#D13212;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=1;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.transit_gateway_attachment;"
+	}
 
-		tgwCell := Cell{
-			ID:     tgwID,
-			Value:  escapeXML(tgwLabel),
-			Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.transit_gateway;",
-			Parent: "1",
-			Vertex: "1",
-			Geometry: &Geometry{
-				X:      x,
-				Y:      y + float64(i)*150,
-				Width:  78,
-				Height: 78,
-				As:     "geometry",
-			},
-		}
-		cells = append(cells, tgwCell)
-
-		// Add attachment icons
-		attachY := y + float64(i)*150 + 100
-		for _, attachment := range tgwAttachments {
-			if attachment.TransitGatewayID == tgw.TransitGatewayID {
-				attachID := dg.nextID()
-				attachName := getResourceName(attachment.Tags, attachment.AttachmentID)
-				attachLabel := fmt.Sprintf("TGW Attachment\n%s\n%s", attachName, attachment.State)
-
-				attachCell := Cell{
-					ID:     attachID,
-					Value:  escapeXML(attachLabel),
-					Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.transit_gateway_attachment;",
-					Parent: "1",
-					Vertex: "1",
-					Geometry: &Geometry{
-						X:      x + 100,
-						Y:      attachY,
-						Width:  78,
-						Height: 78,
-						As:     "geometry",
-					},
-				}
-				cells = append(cells, attachCell)
-				attachY += 100
-			}
-		}
+	return labelCell(Cell{
+		ID:     id,
+		Style:  attachStyle,
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      x,
+			Y:      y,
+			Width:  78,
+			Height: 78,
+			As:     "geometry",
+		},
+	}, attachLabel, labelBox{Width: iconLabelWidth, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 4})
+}
+
+// createASGCell creates an Auto Scaling group bar with its desired and current instance counts
+func (dg *DiagramGenerator) createASGCell(id string, group asg.AutoScalingGroupInfo, parentID string, node LayoutNode) Cell {
+	asgLabel := fmt.Sprintf("Auto Scaling group %s (min %d / desired %d / max %d, %d running)",
+		group.AutoScalingGroupName, group.MinSize, group.DesiredCapacity, group.MaxSize, len(group.InstanceIDs))
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "rounded=1;whiteSpace=wrap;html=1;fillColor=#FDF1E6;strokeColor=#ED7100;fontColor=#232F3E;fontSize=10;dashed=1;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, asgLabel, labelBox{Width: node.Width - 10, FontSize: 10, MinFontSize: minLabelFontSize, MaxLines: 1})
+}
+
+// createDirectoryCell creates a directory bar spanning the subnets of its network interfaces
+func (dg *DiagramGenerator) createDirectoryCell(id string, d directory.DirectoryInfo, parentID string, node LayoutNode) Cell {
+	dirLabel := fmt.Sprintf("%s: %s (%s)", d.Type, d.Name, strings.Join(d.IPAddresses, ", "))
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "rounded=1;whiteSpace=wrap;html=1;fillColor=#FBE9EF;strokeColor=#DD344C;fontColor=#232F3E;fontSize=11;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, dirLabel, labelBox{Width: node.Width - 10, FontSize: 11, MinFontSize: minLabelFontSize, MaxLines: 1})
+}
+
+// createCoreNetworkCell creates a Cloud WAN core network container cell sized by the layout
+func (dg *DiagramGenerator) createCoreNetworkCell(id string, coreNetwork cloudwan.CoreNetworkInfo, parentID string, node LayoutNode) Cell {
+	cnName := getResourceName(coreNetwork.Tags, coreNetwork.CoreNetworkID)
+	cnLabel := fmt.Sprintf("Cloud WAN Core Network\n%s\n%s", cnName, coreNetwork.State)
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "points=[[0,0],[0.25,0],[0.5,0],[0.75,0],[1,0],[1,0.25],[1,0.5],[1,0.75],[1,1],[0.75,1],[0.5,1],[0.25,1],[0,1],[0,0.75],[0,0.5],[0,0.25]];outlineConnect=0;gradientColor=none;html=1;whiteSpace=wrap;fontSize=12;fontStyle=0;container=1;pointerEvents=0;collapsible=0;recursiveResize=0;shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_aws_cloud;strokeColor=#8C4FFF;fillColor=none;verticalAlign=top;align=left;spacingLeft=30;fontColor=#8C4FFF;dashed=1;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, cnLabel, labelBox{Width: node.Width - 40, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 4})
+}
+
+// createSegmentCell creates a segment lane inside a core network container
+func (dg *DiagramGenerator) createSegmentCell(id string, segment cloudwan.SegmentInfo, parentID string, node LayoutNode) Cell {
+	segmentLabel := fmt.Sprintf("Segment: %s", segment.Name)
+	if len(segment.SharedSegments) > 0 {
+		segmentLabel += fmt.Sprintf("\nShares with: %s", strings.Join(segment.SharedSegments, ", "))
 	}
 
-	return cells
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "swimlane;whiteSpace=wrap;html=1;startSize=40;container=1;collapsible=0;fillColor=#F4EDFF;strokeColor=#8C4FFF;fontColor=#232F3E;fontSize=12;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      node.X,
+			Y:      node.Y,
+			Width:  node.Width,
+			Height: node.Height,
+			As:     "geometry",
+		},
+	}, segmentLabel, labelBox{Width: node.Width - 10, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 2})
+}
+
+// createCoreNetworkAttachmentCell creates a core network attachment cell labeled with the attached resource
+func (dg *DiagramGenerator) createCoreNetworkAttachmentCell(id string, attachment cloudwan.AttachmentInfo, parentID string, x, y float64) Cell {
+	attachName := getResourceName(attachment.Tags, attachment.AttachmentID)
+	attachLabel := fmt.Sprintf("%s attachment\n%s\n%s\n%s", attachment.AttachmentType, attachName, attachment.ResourceID, attachment.EdgeLocation)
+
+	return labelCell(Cell{
+		ID:     id,
+		Style:  "sketch=0;outlineConnect=0;fontColor=#232F3E;gradientColor=none;fillColor=#8C4FFF;strokeColor=none;dashed=0;verticalLabelPosition=bottom;verticalAlign=top;align=center;html=1;fontSize=12;fontStyle=0;aspect=fixed;pointerEvents=1;shape=mxgraph.aws4.transit_gateway_attachment;",
+		Parent: parentID,
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      x,
+			Y:      y,
+			Width:  78,
+			Height: 78,
+			As:     "geometry",
+		},
+	}, attachLabel, labelBox{Width: iconLabelWidth, FontSize: 12, MinFontSize: minLabelFontSize, MaxLines: 5})
+}
+
+// appendUnique appends a value to a list unless it is empty or already listed
+func appendUnique(list []string, value string) []string {
+	if value == "" {
+		return list
+	}
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
 }
 
 // getResourceName extracts a friendly name from tags, falling back to the resource ID
@@ -444,21 +1053,35 @@ func (dg *DiagramGenerator) GenerateVPCDetailDiagram(
 	}
 
 	// Generate VPC container with all details
-	cells := dg.generateVPCContainer(vpcInfo, subnets, internetGateways, natGateways, 50, 50)
+	layout := &Layout{}
+	layout.addVPC(vpcInfo, subnets, internetGateways, natGateways, 50, 50)
+	layout.AddVpcEndpoints(dg.endpoints)
+	ids := newIDSpace(vpcInfo.VpcID)
+	cells := append(dg.cellsFromLayout(layout, ids), dg.noteCells(ids)...)
+
+	// Information panels go to the right of the VPC, however wide its subnet rows are
+	width, _ := layout.Bounds()
+	panelX := math.Max(1200, width+100)
 
 	// Add route tables information panel
+	sgY := 400.0
 	if len(routeTables) > 0 {
-		rtCells := dg.generateRouteTablePanel(routeTables, vpcInfo.VpcID, 1200, 50)
+		rtCells, rtBottom := dg.generateRouteTablePanel(ids, routeTables, vpcInfo.VpcID, panelX, 50)
 		cells = append(cells, rtCells...)
+		// Panels grow with their labels, so the security groups start below the last route table
+		sgY = math.Max(sgY, rtBottom)
 	}
 
 	// Add security groups information panel
 	if len(securityGroups) > 0 {
-		sgCells := dg.generateSecurityGroupPanel(securityGroups, vpcInfo.VpcID, 1200, 400)
+		sgCells := dg.generateSecurityGroupPanel(ids, securityGroups, vpcInfo.VpcID, panelX, sgY)
 		cells = append(cells, sgCells...)
 	}
 
-	drawio.Diagram.MxGraphModel.Root.Cells = append(drawio.Diagram.MxGraphModel.Root.Cells, cells...)
+	// Network ACLs get a column of their own beside the security groups
+	cells = append(cells, dg.generateNetworkACLPanel(ids, vpcInfo.VpcID, panelX+300, sgY)...)
+
+	drawio.Diagram.MxGraphModel.Root.Cells = append(drawio.Diagram.MxGraphModel.Root.Cells, dg.finishCells(cells)...)
 
 	// Marshal to XML
 	output, err := xml.MarshalIndent(drawio, "", "  ")
@@ -466,11 +1089,68 @@ func (dg *DiagramGenerator) GenerateVPCDetailDiagram(
 		return "", fmt.Errorf("failed to marshal diagram XML: %w", err)
 	}
 
-	return xml.Header + string(output), nil
+	doc := xml.Header + string(output)
+	if err := Validate(doc); err != nil {
+		return "", fmt.Errorf("generated invalid diagram: %w", err)
+	}
+	return doc, nil
+}
+
+// DetailDiagram is a generated detail diagram for one VPC
+type DetailDiagram struct {
+	VpcID string // ID of the VPC the diagram shows
+	XML   string // draw.io document
+}
+
+// GenerateVPCDetailDiagrams creates a detail diagram for every VPC, generating up to workers diagrams at a time
+// workers: Maximum number of diagrams generated concurrently (values below 1 mean 1)
+// Returns: Diagrams in the order of vpcs, or the error of the first VPC that failed
+func (dg *DiagramGenerator) GenerateVPCDetailDiagrams(
+	workers int,
+	vpcs []vpc.VPCInfo,
+	subnets []vpc.SubnetInfo,
+	routeTables []vpc.RouteTableInfo,
+	securityGroups []vpc.SecurityGroupInfo,
+	internetGateways []vpc.InternetGatewayInfo,
+	natGateways []vpc.NatGatewayInfo,
+) ([]DetailDiagram, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	diagrams := make([]DetailDiagram, len(vpcs))
+	errs := make([]error, len(vpcs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				doc, err := dg.GenerateVPCDetailDiagram(vpcs[i], subnets, routeTables, securityGroups, internetGateways, natGateways)
+				diagrams[i] = DetailDiagram{VpcID: vpcs[i].VpcID, XML: doc}
+				errs[i] = err
+			}
+		}()
+	}
+	for i := range vpcs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("detail diagram for %s: %w", vpcs[i].VpcID, err)
+		}
+	}
+	return diagrams, nil
 }
 
 // generateRouteTablePanel creates an information panel for route tables
-func (dg *DiagramGenerator) generateRouteTablePanel(routeTables []vpc.RouteTableInfo, vpcID string, x, y float64) []Cell {
+// Returns: The panel cells, and the y coordinate below the last of them
+func (dg *DiagramGenerator) generateRouteTablePanel(ids *idSpace, routeTables []vpc.RouteTableInfo, vpcID string, x, y float64) ([]Cell, float64) {
 	var cells []Cell
 
 	// Filter route tables for this VPC
@@ -482,7 +1162,18 @@ func (dg *DiagramGenerator) generateRouteTablePanel(routeTables []vpc.RouteTable
 	}
 
 	if len(vpcRouteTables) == 0 {
-		return cells
+		return cells, y
+	}
+
+	// Gateway endpoints are drawn beside the route tables they add their prefix list routes to
+	gatewayEndpoints := make(map[string][]vpc.VpcEndpointInfo)
+	for _, endpoint := range dg.endpoints {
+		if endpoint.EndpointType != vpc.EndpointTypeGateway || endpoint.VpcID != vpcID {
+			continue
+		}
+		for _, rtID := range endpoint.RouteTableIDs {
+			gatewayEndpoints[rtID] = append(gatewayEndpoints[rtID], endpoint)
+		}
 	}
 
 	yOffset := y
@@ -493,31 +1184,30 @@ func (dg *DiagramGenerator) generateRouteTablePanel(routeTables []vpc.RouteTable
 			mainText = " (Main)"
 		}
 
-		// Build routes text
-		var routesText []string
+		// Build routes text; local routes go into their own group so the other routes stand out
+		var routesText, localText []string
 		for _, route := range rt.Routes {
 			dest := route.DestinationCidrBlock
 			if dest == "" {
 				dest = route.DestinationIpv6Block
 			}
-			target := route.GatewayID
-			if target == "" {
-				target = route.NatGatewayID
+			if route.Target.Type == vpc.RouteTargetLocal {
+				localText = append(localText, fmt.Sprintf("  %s → local (%s)", dest, vpc.LocalRouteLabel(route.RouteType)))
+				continue
 			}
-			if target == "" {
-				target = route.TransitGatewayID
+			if route.DestinationLabel != "" {
+				dest += " (" + route.DestinationLabel + ")"
 			}
-			if target == "" {
-				target = "local"
-			}
-			routesText = append(routesText, fmt.Sprintf("  %s → %s", dest, target))
+			routesText = append(routesText, fmt.Sprintf("  %s → %s", dest, route.Target))
 		}
 
 		rtLabel := fmt.Sprintf("Route Table%s\n%s\n%s", mainText, rtName, strings.Join(routesText, "\n"))
+		rtLabelFit := fitLabel(rtLabel, panelLabelBox)
+		rtHeight := math.Max(100+float64(len(routesText)*15), rtLabelFit.Height()+panelPadding)
+		rtHeight = math.Max(rtHeight, float64(len(gatewayEndpoints[rt.RouteTableID]))*panelEndpointSpacing)
 
 		rtCell := Cell{
-			ID:     dg.nextID(),
-			Value:  escapeXML(rtLabel),
+			ID:     ids.id("route_table_panel", rt.RouteTableID),
 			Style:  "rounded=1;whiteSpace=wrap;html=1;fillColor=#f5f5f5;strokeColor=#666666;fontSize=9;align=left;verticalAlign=top;spacingLeft=5;spacingTop=5;",
 			Parent: "1",
 			Vertex: "1",
@@ -525,19 +1215,104 @@ func (dg *DiagramGenerator) generateRouteTablePanel(routeTables []vpc.RouteTable
 				X:      x,
 				Y:      yOffset,
 				Width:  300,
-				Height: 100 + float64(len(routesText)*15),
+				Height: rtHeight,
 				As:     "geometry",
 			},
 		}
+		rtCell.applyLabel(rtLabelFit, panelLabelBox)
+		rtCell.Data = dg.resourceData(resourceRouteTable, rt.RouteTableID, rt.VpcID, rt)
+		dg.dimManaged(&rtCell, rt)
 		cells = append(cells, rtCell)
-		yOffset += 120 + float64(len(routesText)*15)
+		for k, endpoint := range gatewayEndpoints[rt.RouteTableID] {
+			cells = append(cells, dg.panelEndpointCell(ids, endpoint, x+310, yOffset+float64(k)*panelEndpointSpacing))
+		}
+		yOffset += rtHeight
+
+		if len(localText) > 0 {
+			cells = append(cells, localRouteGroup(ids, rt.RouteTableID, localText, x, yOffset)...)
+			yOffset += localGroupHeaderHeight
+		}
+		yOffset += 20
 	}
 
-	return cells
+	return cells, yOffset
+}
+
+// panelEndpointSpacing is the distance between the gateway endpoints stacked beside a route table panel
+const panelEndpointSpacing = 75
+
+// panelEndpointCell creates the icon of a gateway endpoint beside a route table panel of a detail diagram
+func (dg *DiagramGenerator) panelEndpointCell(ids *idSpace, endpoint vpc.VpcEndpointInfo, x, y float64) Cell {
+	cell := labelCell(Cell{
+		ID:     ids.id("route_table_endpoint", endpoint.VpcEndpointID),
+		Style:  endpointStyle(endpoint),
+		Parent: "1",
+		Vertex: "1",
+		Geometry: &Geometry{
+			X:      x,
+			Y:      y,
+			Width:  40,
+			Height: 40,
+			As:     "geometry",
+		},
+	}, "Gateway endpoint\n"+analysis.EndpointServiceName(endpoint.ServiceName), labelBox{Width: gridCellWidth - 5, FontSize: 9, MinFontSize: minLabelFontSize, MaxLines: 2})
+	cell.Data = dg.resourceData(NodeGatewayEndpoint, endpoint.VpcEndpointID, endpoint.VpcID, endpoint)
+	return cell
+}
+
+// localGroupHeaderHeight is the height of a collapsed local route group
+const localGroupHeaderHeight = 22
+
+// localRouteGroup creates a collapsed group below a route table panel listing its local routes
+// Expanding the group in draw.io shows the routes; the collapsed header only counts them.
+func localRouteGroup(ids *idSpace, routeTableID string, localText []string, x, y float64) []Cell {
+	groupID := ids.id("local_routes", routeTableID)
+	textFit := fitLabel(strings.Join(localText, "\n"), panelLabelBox)
+	expandedHeight := localGroupHeaderHeight + 10 + math.Max(float64(len(localText)*15), textFit.Height())
+
+	group := []Cell{
+		{
+			ID:        groupID,
+			Style:     "swimlane;startSize=22;collapsible=1;rounded=1;html=1;fillColor=#f5f5f5;strokeColor=#666666;fontSize=9;fontStyle=0;align=left;spacingLeft=5;",
+			Parent:    "1",
+			Vertex:    "1",
+			Collapsed: "1",
+			Geometry: &Geometry{
+				X:      x,
+				Y:      y,
+				Width:  300,
+				Height: localGroupHeaderHeight,
+				As:     "geometry",
+				AlternateBounds: &Rectangle{
+					X:      x,
+					Y:      y,
+					Width:  300,
+					Height: expandedHeight,
+					As:     "alternateBounds",
+				},
+			},
+		},
+		{
+			ID:     ids.id("local_routes_text", routeTableID),
+			Style:  "text;html=1;whiteSpace=wrap;fontSize=9;align=left;verticalAlign=top;spacingLeft=5;",
+			Parent: groupID,
+			Vertex: "1",
+			Geometry: &Geometry{
+				Y:      localGroupHeaderHeight,
+				Width:  300,
+				Height: expandedHeight - localGroupHeaderHeight,
+				As:     "geometry",
+			},
+		},
+	}
+	headerBox := labelBox{Width: 290, FontSize: 9, MaxLines: 1}
+	group[0].applyLabel(fitLabel(fmt.Sprintf("Local routes (%d)", len(localText)), headerBox), headerBox)
+	group[1].applyLabel(textFit, panelLabelBox)
+	return group
 }
 
 // generateSecurityGroupPanel creates an information panel for security groups
-func (dg *DiagramGenerator) generateSecurityGroupPanel(securityGroups []vpc.SecurityGroupInfo, vpcID string, x, y float64) []Cell {
+func (dg *DiagramGenerator) generateSecurityGroupPanel(ids *idSpace, securityGroups []vpc.SecurityGroupInfo, vpcID string, x, y float64) []Cell {
 	var cells []Cell
 
 	// Filter security groups for this VPC
@@ -556,23 +1331,34 @@ func (dg *DiagramGenerator) generateSecurityGroupPanel(securityGroups []vpc.Secu
 	for _, sg := range vpcSecurityGroups {
 		sgName := getResourceName(sg.Tags, sg.GroupID)
 
-		// Count ingress/egress rules
-		ingressCount := 0
-		egressCount := 0
+		egressCount := sg.EgressRuleCount
+		var sources, destinations []string
 		for _, rule := range sg.Rules {
-			if rule.IsEgress {
-				egressCount++
-			} else {
-				ingressCount++
+			if dg.hideDefaultEgress && rule.IsDefaultEgress {
+				egressCount--
 			}
+			sources = appendUnique(sources, rule.SourceLabel)
+			destinations = appendUnique(destinations, rule.DestinationLabel)
 		}
-
 		sgLabel := fmt.Sprintf("Security Group\n%s\n%s\nIngress: %d rules\nEgress: %d rules",
-			sgName, sg.GroupName, ingressCount, egressCount)
+			sgName, sg.GroupName, sg.IngressRuleCount, egressCount)
+		if sg.EgressRestricted {
+			sgLabel += " (restricted)"
+		}
+		// Named ranges the rules allow, so well-known networks are recognizable without their CIDRs
+		if len(sources) > 0 {
+			sgLabel += "\nFrom: " + strings.Join(sources, ", ")
+		}
+		if len(destinations) > 0 {
+			sgLabel += "\nTo: " + strings.Join(destinations, ", ")
+		}
+
+		sgBox := labelBox{Width: 270, FontSize: 9}
+		sgLabelFit := fitLabel(sgLabel, sgBox)
+		sgHeight := math.Max(100, sgLabelFit.Height()+panelPadding)
 
 		sgCell := Cell{
-			ID:     dg.nextID(),
-			Value:  escapeXML(sgLabel),
+			ID:     ids.id("security_group_panel", sg.GroupID),
 			Style:  "rounded=1;whiteSpace=wrap;html=1;fillColor=#fff2cc;strokeColor=#d6b656;fontSize=9;align=left;verticalAlign=top;spacingLeft=5;spacingTop=5;",
 			Parent: "1",
 			Vertex: "1",
@@ -580,13 +1366,95 @@ func (dg *DiagramGenerator) generateSecurityGroupPanel(securityGroups []vpc.Secu
 				X:      x,
 				Y:      yOffset,
 				Width:  280,
-				Height: 100,
+				Height: sgHeight,
 				As:     "geometry",
 			},
 		}
+		sgCell.applyLabel(sgLabelFit, sgBox)
+		sgCell.Data = dg.resourceData(resourceSecurityGroup, sg.GroupID, sg.VpcID, sg)
+		dg.dimManaged(&sgCell, sg)
 		cells = append(cells, sgCell)
-		yOffset += 120
+		yOffset += sgHeight + 20
 	}
 
 	return cells
 }
+
+// generateNetworkACLPanel creates an information panel for the network ACLs of a VPC
+// Each panel lists the associated subnets and the inbound and outbound entries in evaluation order.
+func (dg *DiagramGenerator) generateNetworkACLPanel(ids *idSpace, vpcID string, x, y float64) []Cell {
+	var cells []Cell
+
+	yOffset := y
+	for _, acl := range dg.networkACLs {
+		if acl.VpcID != vpcID {
+			continue
+		}
+		title := "Network ACL"
+		if acl.IsDefault {
+			title += " (Default)"
+		}
+		var inbound, outbound []string
+		for _, entry := range acl.Entries {
+			if entry.Egress {
+				outbound = append(outbound, "  "+naclEntryText(entry))
+			} else {
+				inbound = append(inbound, "  "+naclEntryText(entry))
+			}
+		}
+		aclLabel := fmt.Sprintf("%s\n%s\nSubnets: %d\nInbound:\n%s\nOutbound:\n%s",
+			title, getResourceName(acl.Tags, acl.NetworkAclID), len(acl.SubnetIDs), strings.Join(inbound, "\n"), strings.Join(outbound, "\n"))
+
+		aclBox := labelBox{Width: 270, FontSize: 9}
+		aclLabelFit := fitLabel(aclLabel, aclBox)
+		aclHeight := math.Max(100, aclLabelFit.Height()+panelPadding)
+
+		aclCell := Cell{
+			ID:     ids.id("network_acl_panel", acl.NetworkAclID),
+			Style:  "rounded=1;whiteSpace=wrap;html=1;fillColor=#dae8fc;strokeColor=#6c8ebf;fontSize=9;align=left;verticalAlign=top;spacingLeft=5;spacingTop=5;",
+			Parent: "1",
+			Vertex: "1",
+			Geometry: &Geometry{
+				X:      x,
+				Y:      yOffset,
+				Width:  280,
+				Height: aclHeight,
+				As:     "geometry",
+			},
+		}
+		aclCell.applyLabel(aclLabelFit, aclBox)
+		aclCell.Data = dg.resourceData(resourceNetworkACL, acl.NetworkAclID, acl.VpcID, acl)
+		dg.dimManaged(&aclCell, acl)
+		cells = append(cells, aclCell)
+		yOffset += aclHeight + 20
+	}
+
+	return cells
+}
+
+// naclEntryText formats a network ACL entry as one panel line, such as "100 allow tcp 443 10.0.0.0/16"
+// The catch-all entry is numbered "*", as in the console.
+func naclEntryText(entry vpc.NetworkACLEntry) string {
+	number := fmt.Sprint(entry.RuleNumber)
+	if entry.RuleNumber == vpc.DefaultNACLRuleNumber {
+		number = "*"
+	}
+	protocol := netcalc.NormalizeProtocol(entry.Protocol)
+	switch {
+	case protocol == netcalc.ProtocolAll:
+		protocol = "all"
+	case entry.PortRange != nil && netcalc.HasPorts(protocol):
+		if entry.PortRange.From == entry.PortRange.To {
+			protocol += fmt.Sprintf(" %d", entry.PortRange.From)
+		} else {
+			protocol += fmt.Sprintf(" %d-%d", entry.PortRange.From, entry.PortRange.To)
+		}
+	case entry.IcmpType != nil && *entry.IcmpType != -1:
+		protocol += fmt.Sprintf(" type %d", *entry.IcmpType)
+	}
+	cidr := entry.CidrBlock
+	if cidr == "" {
+		cidr = entry.Ipv6CidrBlock
+	}
+	return fmt.Sprintf("%s %s %s %s", number, entry.RuleAction, protocol, cidr)
+}
@@ -0,0 +1,383 @@
+package diagram
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/privateapi"
+	"aws-documentor/modules/testgen"
+	"aws-documentor/modules/vpc"
+)
+
+// TestDetailDiagramsParallelMatchSequential checks that parallel detail diagrams are byte for byte the sequential ones
+// Cell IDs are allocated per diagram, so the number of workers must not change any output. Run with -race,
+// this also checks that the workers share no mutable state.
+func TestDetailDiagramsParallelMatchSequential(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	generate := func(workers int) []DetailDiagram {
+		dg := NewDiagramGenerator()
+		dg.SetScanContext("eu-west-1", testgen.Account)
+		details, err := dg.GenerateVPCDetailDiagrams(workers, env.VPCs, env.Subnets, env.RouteTables,
+			env.SecurityGroups, env.InternetGateways, env.NatGateways)
+		if err != nil {
+			t.Fatalf("GenerateVPCDetailDiagrams(%d): %v", workers, err)
+		}
+		return details
+	}
+
+	sequential := generate(1)
+	if len(sequential) != len(env.VPCs) {
+		t.Fatalf("got %d diagrams for %d VPCs", len(sequential), len(env.VPCs))
+	}
+	for _, detail := range sequential {
+		if err := Validate(detail.XML); err != nil {
+			t.Errorf("detail diagram of %s: %v", detail.VpcID, err)
+		}
+	}
+
+	for _, workers := range []int{2, 4, len(env.VPCs)} {
+		parallel := generate(workers)
+		for i := range sequential {
+			if parallel[i].VpcID != sequential[i].VpcID {
+				t.Errorf("%d workers: diagram %d is of %s, want %s", workers, i, parallel[i].VpcID, sequential[i].VpcID)
+			}
+			if parallel[i].XML != sequential[i].XML {
+				t.Errorf("%d workers: detail diagram of %s differs from the sequential one", workers, sequential[i].VpcID)
+			}
+		}
+	}
+}
+
+// TestDetailDiagramsSharedGenerator generates with one generator from several goroutines at once
+// GenerateVPCDetailDiagrams shares its generator between workers; calling it concurrently must be just as safe.
+func TestDetailDiagramsSharedGenerator(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	dg := NewDiagramGenerator()
+	want, err := dg.GenerateVPCDetailDiagram(env.VPCs[0], env.Subnets, env.RouteTables, env.SecurityGroups,
+		env.InternetGateways, env.NatGateways)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines = 8
+	results := make(chan string, goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			doc, err := dg.GenerateVPCDetailDiagram(env.VPCs[0], env.Subnets, env.RouteTables, env.SecurityGroups,
+				env.InternetGateways, env.NatGateways)
+			if err != nil {
+				doc = err.Error()
+			}
+			results <- doc
+		}()
+	}
+	for i := 0; i < goroutines; i++ {
+		if doc := <-results; doc != want {
+			t.Errorf("a concurrent detail diagram differs from the sequential one")
+		}
+	}
+}
+
+// TestEndpointLabelsNamePrivateAPIs checks that execute-api endpoints are labeled with the private APIs they reach
+// The labels of both generators must carry the names, whichever of SetVpcEndpoints and SetPrivateAPIs runs first.
+func TestEndpointLabelsNamePrivateAPIs(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	subnet := env.Subnets[0]
+	endpoints := []vpc.VpcEndpointInfo{
+		{VpcEndpointID: "vpce-0api", VpcID: subnet.VpcID, ServiceName: "com.amazonaws.eu-west-1.execute-api",
+			EndpointType: vpc.EndpointTypeInterface, State: "available", SubnetIDs: []string{subnet.SubnetID}},
+		{VpcEndpointID: "vpce-0sqs", VpcID: subnet.VpcID, ServiceName: "com.amazonaws.eu-west-1.sqs",
+			EndpointType: vpc.EndpointTypeInterface, State: "available", SubnetIDs: []string{subnet.SubnetID}},
+	}
+	apis := []privateapi.PrivateAPIInfo{
+		{RestApiID: "a1", Name: "orders", PolicyVpcEndpointIDs: []string{"vpce-0api"}, PolicyAllowsInvoke: true},
+		{RestApiID: "b2", Name: "billing", PolicyVpcIDs: []string{subnet.VpcID}, PolicyAllowsInvoke: true},
+	}
+
+	overview := NewDiagramGenerator()
+	overview.SetPrivateAPIs(apis)
+	overview.SetVpcEndpoints(endpoints)
+	doc, err := overview.GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+		env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	detail := NewDiagramGenerator()
+	detail.SetVpcEndpoints(endpoints)
+	detail.SetPrivateAPIs(apis)
+	detailDoc, err := detail.GenerateVPCDetailDiagram(env.VPCs[0], env.Subnets, env.RouteTables, env.SecurityGroups,
+		env.InternetGateways, env.NatGateways)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, doc := range map[string]string{"overview": doc, "detail": detailDoc} {
+		if !strings.Contains(doc, "execute-api: billing, orders") {
+			t.Errorf("%s diagram does not name the APIs of the execute-api endpoint", name)
+		}
+		if strings.Contains(doc, "sqs:") {
+			t.Errorf("%s diagram names APIs on the SQS endpoint", name)
+		}
+		if err := Validate(doc); err != nil {
+			t.Errorf("%s diagram: %v", name, err)
+		}
+	}
+}
+
+// TestRouteApplianceIcons checks that NAT instances and other routed instances are drawn as routers in their
+// subnet, outlined in red only while source/destination checking is still enabled
+func TestRouteApplianceIcons(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	subnet := env.Subnets[0]
+	appliances := []vpc.RouteApplianceInfo{
+		{InstanceID: "i-0nat", SubnetID: subnet.SubnetID, VpcID: subnet.VpcID, IsRoutingAppliance: true, IsNATInstance: true,
+			Tags: map[string]string{"Name": "legacy-nat"}},
+		{InstanceID: "i-0check", SubnetID: subnet.SubnetID, VpcID: subnet.VpcID, SourceDestCheck: true},
+		{InstanceID: "i-0elsewhere", SubnetID: "subnet-0gone", VpcID: subnet.VpcID, IsRoutingAppliance: true},
+	}
+
+	dg := NewDiagramGenerator()
+	dg.SetRouteAppliances(appliances)
+	doc, err := dg.GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+		env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Count(doc, "shape=mxgraph.aws4.router;"); got != 2 {
+		t.Errorf("diagram has %d router icons, want 2 (the appliance outside the scanned subnets is skipped)", got)
+	}
+	if got := strings.Count(doc, "fillColor=#8C4FFF;strokeColor=#DD344C;"); got != 1 {
+		t.Errorf("diagram outlines %d router icons in red, want only the one with source/dest check enabled", got)
+	}
+	for _, label := range []string{"NAT instance", "legacy-nat", "source/dest check enabled", "i-0check"} {
+		if !strings.Contains(doc, label) {
+			t.Errorf("diagram does not label a router with %q", label)
+		}
+	}
+	if strings.Contains(doc, "i-0elsewhere") {
+		t.Error("diagram draws the appliance whose subnet was not scanned")
+	}
+	if err := Validate(doc); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestSecurityGroupPanelDefaultEgress checks the egress counts of the security group panels with and without
+// -hide-default-egress, and that only groups without the default rule are labelled restricted
+func TestSecurityGroupPanelDefaultEgress(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	vpcInfo := env.VPCs[0]
+	defaultV4 := vpc.SecurityGroupRule{IsEgress: true, IpProtocol: "-1", CidrBlock: "0.0.0.0/0", IsDefaultEgress: true}
+	defaultV6 := vpc.SecurityGroupRule{IsEgress: true, IpProtocol: "-1", Ipv6CidrBlock: "::/0", IsDefaultEgress: true}
+	groups := []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0default", GroupName: "only-default", VpcID: vpcInfo.VpcID, EgressRuleCount: 2,
+			Rules: []vpc.SecurityGroupRule{defaultV4, defaultV6}},
+		{GroupID: "sg-0web", GroupName: "default-and-extras", VpcID: vpcInfo.VpcID, IngressRuleCount: 1, EgressRuleCount: 3,
+			Rules: []vpc.SecurityGroupRule{
+				{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"},
+				defaultV4,
+				defaultV6,
+				{IsEgress: true, IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0db"},
+			}},
+		{GroupID: "sg-0locked", GroupName: "default-removed", VpcID: vpcInfo.VpcID, EgressRuleCount: 1, EgressRestricted: true,
+			Rules: []vpc.SecurityGroupRule{{IsEgress: true, IpProtocol: "-1", CidrBlock: "10.0.0.0/16"}}},
+	}
+
+	tests := []struct {
+		name string
+		hide bool
+		want []string // Egress line of each panel, in group order
+	}{
+		{name: "shown", want: []string{"Egress: 2 rules", "Egress: 3 rules", "Egress: 1 rules (restricted)"}},
+		{name: "hidden", hide: true, want: []string{"Egress: 0 rules", "Egress: 1 rules", "Egress: 1 rules (restricted)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dg := NewDiagramGenerator()
+			dg.SetHideDefaultEgress(tt.hide)
+			doc, err := dg.GenerateVPCDetailDiagram(vpcInfo, env.Subnets, env.RouteTables, groups, env.InternetGateways, env.NatGateways)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, group := range groups {
+				_, panel, ok := strings.Cut(doc, group.GroupName)
+				if !ok {
+					t.Fatalf("diagram has no panel for %s", group.GroupID)
+				}
+				panel, _, _ = strings.Cut(panel, "Security Group")
+				if !strings.Contains(panel, tt.want[i]) {
+					t.Errorf("panel of %s does not contain %q", group.GroupID, tt.want[i])
+				}
+				if restricted := strings.Contains(panel, "(restricted)"); restricted != group.EgressRestricted {
+					t.Errorf("panel of %s labelled restricted = %v, want %v", group.GroupID, restricted, group.EgressRestricted)
+				}
+			}
+			if err := Validate(doc); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestLocalRouteGroup checks that local routes leave the route table panel for a collapsed group naming their CIDR block
+func TestLocalRouteGroup(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	vpcInfo := env.VPCs[0]
+	local := vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}
+	routeTables := []vpc.RouteTableInfo{{RouteTableID: "rtb-0multi", VpcID: vpcInfo.VpcID, Routes: []vpc.RouteInfo{
+		{DestinationCidrBlock: "10.1.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalPrimaryCIDR},
+		{DestinationCidrBlock: "100.64.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalSecondaryCIDR},
+		{DestinationCidrBlock: "172.16.0.0/16", Target: local, RouteType: vpc.RouteTypeLocalUnassociatedCIDR},
+		{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0a1"}},
+	}}}
+
+	doc, err := NewDiagramGenerator().GenerateVPCDetailDiagram(vpcInfo, env.Subnets, routeTables, nil, env.InternetGateways, env.NatGateways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var model DrawIO
+	if err := xml.Unmarshal([]byte(doc), &model); err != nil {
+		t.Fatal(err)
+	}
+	var panel, group, text Cell
+	for _, cell := range model.Diagram.MxGraphModel.Root.Cells {
+		switch {
+		case strings.Contains(cell.ID, "/route_table_panel/rtb-0multi"):
+			panel = cell
+		case strings.Contains(cell.ID, "/local_routes/rtb-0multi"):
+			group = cell
+		case strings.Contains(cell.ID, "/local_routes_text/rtb-0multi"):
+			text = cell
+		}
+	}
+	if panel.ID == "" || group.ID == "" || text.ID == "" {
+		t.Fatalf("diagram has panel %q, local route group %q and text %q, want all three", panel.ID, group.ID, text.ID)
+	}
+	if strings.Contains(panel.Value, "local") || !strings.Contains(panel.Value, "0.0.0.0/0") {
+		t.Errorf("route table panel = %q, want only the NAT route", panel.Value)
+	}
+	if group.Collapsed != "1" || !strings.Contains(group.Value, "Local routes (3)") {
+		t.Errorf("local route group = %q collapsed %q, want a collapsed header counting 3 routes", group.Value, group.Collapsed)
+	}
+	if text.Parent != group.ID {
+		t.Errorf("local route text is in %s, want the group %s", text.Parent, group.ID)
+	}
+	for _, line := range []string{"10.1.0.0/16 → local (primary CIDR)", "100.64.0.0/16 → local (secondary CIDR)", "172.16.0.0/16 → local (no associated CIDR, stale)"} {
+		if !strings.Contains(text.Value, line) {
+			t.Errorf("local routes %q do not contain %q", text.Value, line)
+		}
+	}
+	if err := Validate(doc); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestIsolatedVPCBadge checks that only isolated VPCs get the badge, the no internet path note and the dashed border
+func TestIsolatedVPCBadge(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	if len(env.VPCs) < 2 {
+		t.Fatalf("fixture has %d VPCs, want at least 2", len(env.VPCs))
+	}
+	isolated, connected := env.VPCs[0], env.VPCs[1]
+
+	dg := NewDiagramGenerator()
+	dg.SetVPCConnectivity([]analysis.VPCConnectivity{
+		{VpcID: isolated.VpcID, Classification: analysis.ConnectivityIsolated},
+		{VpcID: connected.VpcID, Classification: analysis.ConnectivityInternet},
+	})
+	doc, err := dg.GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+		env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var model DrawIO
+	if err := xml.Unmarshal([]byte(doc), &model); err != nil {
+		t.Fatal(err)
+	}
+	vpcCells := make(map[string]Cell)
+	for _, cell := range model.Diagram.MxGraphModel.Root.Cells {
+		if strings.Contains(cell.Style, "grIcon=mxgraph.aws4.group_vpc2;") {
+			for _, v := range env.VPCs {
+				if strings.Contains(cell.Value, v.CidrBlock) {
+					vpcCells[v.VpcID] = cell
+				}
+			}
+		}
+	}
+
+	badge := vpcCells[isolated.VpcID]
+	if !strings.HasPrefix(badge.Value, "VPC [ISOLATED]") || !strings.Contains(badge.Value, "no internet path") {
+		t.Errorf("isolated VPC label = %q, want the badge and the no internet path note", badge.Value)
+	}
+	if !strings.Contains(badge.Style, "dashed=1;") || !strings.Contains(badge.Style, "strokeWidth=3;") {
+		t.Errorf("isolated VPC style = %q, want a thick dashed border", badge.Style)
+	}
+	for _, v := range env.VPCs[1:] {
+		cell := vpcCells[v.VpcID]
+		if strings.Contains(cell.Value, "ISOLATED") || !strings.Contains(cell.Style, "dashed=0;") {
+			t.Errorf("VPC %s = %q with style %q, want the plain VPC container", v.VpcID, cell.Value, cell.Style)
+		}
+	}
+	if err := Validate(doc); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestFlappingAttachmentBadge checks that only flapping attachments get the badge with their state changes and the red icon
+func TestFlappingAttachmentBadge(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	if len(env.TGWAttachments) < 3 {
+		t.Fatalf("fixture has %d attachments, want at least 3", len(env.TGWAttachments))
+	}
+	flapping, unstable := env.TGWAttachments[0].AttachmentID, env.TGWAttachments[1].AttachmentID
+
+	dg := NewDiagramGenerator()
+	dg.SetAttachmentStability([]analysis.AttachmentStability{
+		{AttachmentID: flapping, TransitionsInWindow: 4, Flapping: true},
+		{AttachmentID: unstable, TransitionsInWindow: 2, Score: 70},
+	})
+	doc, err := dg.GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+		env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var model DrawIO
+	if err := xml.Unmarshal([]byte(doc), &model); err != nil {
+		t.Fatal(err)
+	}
+	attachmentCells := make(map[string]Cell)
+	for _, cell := range model.Diagram.MxGraphModel.Root.Cells {
+		if strings.Contains(cell.Style, "shape=mxgraph.aws4.transit_gateway_attachment;") {
+			for _, a := range env.TGWAttachments {
+				// Labels wrap long IDs after the dash
+				if strings.Contains(strings.ReplaceAll(cell.Value, "\n", ""), a.AttachmentID) {
+					attachmentCells[a.AttachmentID] = cell
+				}
+			}
+		}
+	}
+	if len(attachmentCells) != len(env.TGWAttachments) {
+		t.Fatalf("found %d attachment cells, want %d", len(attachmentCells), len(env.TGWAttachments))
+	}
+
+	badge := attachmentCells[flapping]
+	if !strings.HasPrefix(badge.Value, "TGW Attachment [FLAPPING]") || !strings.Contains(badge.Value, "available, 4 state changes") {
+		t.Errorf("flapping attachment label = %q, want the badge and the state changes", badge.Value)
+	}
+	if !strings.Contains(badge.Style, "fillColor=#D13212;") || !strings.Contains(badge.Style, "fontStyle=1;") {
+		t.Errorf("flapping attachment style = %q, want the red bold icon", badge.Style)
+	}
+	for _, a := range env.TGWAttachments[1:] {
+		cell := attachmentCells[a.AttachmentID]
+		if strings.Contains(cell.Value, "FLAPPING") || !strings.Contains(cell.Style, "fillColor=#8C4FFF;") {
+			t.Errorf("attachment %s = %q with style %q, want the plain attachment icon", a.AttachmentID, cell.Value, cell.Style)
+		}
+	}
+	if err := Validate(doc); err != nil {
+		t.Error(err)
+	}
+}
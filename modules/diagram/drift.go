@@ -0,0 +1,254 @@
+package diagram
+
+import (
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Problems a diagram check reports, in the order they are listed
+const (
+	DriftNotInAWS     = "not_in_aws"     // The diagram shows a resource AWS does not have
+	DriftNotInDiagram = "not_in_diagram" // AWS has a resource the diagram does not show
+	DriftCIDR         = "cidr_mismatch"  // The diagram shows other CIDR blocks than AWS has
+	DriftVPC          = "vpc_mismatch"   // The diagram draws the resource in another VPC
+	DriftLabel        = "label_mismatch" // The label shows neither the name nor the ID of the resource
+)
+
+// driftOrder ranks the problems for sorting
+var driftOrder = map[string]int{DriftNotInAWS: 0, DriftNotInDiagram: 1, DriftCIDR: 2, DriftVPC: 3, DriftLabel: 4}
+
+// Inventory is the set of resources a diagram is checked against
+type Inventory struct {
+	VPCs             []vpc.VPCInfo             // VPCs of the scan or report
+	Subnets          []vpc.SubnetInfo          // Subnets of the scan or report
+	InternetGateways []vpc.InternetGatewayInfo // Internet gateways; detached ones are not expected in a diagram
+	NatGateways      []vpc.NatGatewayInfo      // NAT gateways of the scan or report
+	TransitGateways  []vpc.TransitGatewayInfo  // Transit gateways of the scan or report
+}
+
+// Drift is a difference between a diagram and AWS
+type Drift struct {
+	Kind       string `json:"kind"`              // Node kind (vpc, subnet, internet_gateway, nat_gateway, transit_gateway)
+	ResourceID string `json:"resource_id"`       // ID of the resource ("" for a diagram node that shows no ID)
+	Problem    string `json:"problem"`           // not_in_aws, not_in_diagram, cidr_mismatch, vpc_mismatch or label_mismatch
+	Diagram    string `json:"diagram"`           // What the diagram shows (label, CIDR blocks or VPC)
+	AWS        string `json:"aws"`               // What AWS has
+	Page       string `json:"page,omitempty"`    // Diagram page of the node
+	CellID     string `json:"cell_id,omitempty"` // Cell ID of the node, to find it in draw.io
+}
+
+// awsNode is a resource of the inventory in the form of an imported node
+type awsNode struct {
+	kind    string
+	id      string
+	name    string // Name tag ("" if none)
+	vpcID   string
+	cidrs   []string
+	matched bool
+}
+
+// CheckDrift compares the nodes of a diagram with the resources in AWS
+// Nodes with a resource ID are matched by ID. Hand-drawn nodes without one are matched to a resource
+// of the same kind (and VPC, if known) by their first CIDR block, then by a label containing the
+// resource's name, and a gateway to the only gateway of its kind left in its VPC. Matched pairs are compared for CIDR blocks, VPC and label; labels match if they
+// contain the name or ID of the resource, ignoring whitespace, so wrapped labels still match.
+// nodes: Nodes from ImportDrawIO
+// inventory: Resources of the scan or report
+// scope: VPC IDs the diagram documents (nil for the whole inventory); with a scope, transit gateways
+// and resources of other VPCs are not reported as missing from the diagram
+// Returns: The differences, sorted by problem, kind and resource ID
+func CheckDrift(nodes []ImportedNode, inventory Inventory, scope []string) []Drift {
+	inScope := make(map[string]bool, len(scope))
+	for _, vpcID := range scope {
+		inScope[vpcID] = true
+	}
+	resources := inventoryNodes(inventory)
+	byID := make(map[string]*awsNode, len(resources))
+	for i := range resources {
+		byID[resources[i].kind+"/"+resources[i].id] = &resources[i]
+	}
+
+	drifts := []Drift{}
+	// Page and VPC cell ID -> VPC the cell depicts, for the contents of hand-drawn VPCs without an ID
+	vpcCells := make(map[string]string)
+	for _, node := range nodes {
+		if node.VpcID == "" && node.VPCCell != "" {
+			node.VpcID = vpcCells[node.Page+"/"+node.VPCCell]
+		}
+		if len(scope) > 0 && node.VpcID != "" && !inScope[node.VpcID] {
+			continue
+		}
+		var resource *awsNode
+		if node.ResourceID != "" {
+			resource = byID[node.Kind+"/"+node.ResourceID]
+		} else {
+			resource = matchUnidentified(node, resources)
+		}
+		if resource == nil {
+			drifts = append(drifts, nodeDrift(node, DriftNotInAWS, labelLine(node.Label), ""))
+			continue
+		}
+		resource.matched = true
+		if node.Kind == NodeVPC {
+			vpcCells[node.Page+"/"+node.CellID] = resource.id
+		}
+		drifts = append(drifts, compareNode(node, *resource)...)
+	}
+
+	for _, resource := range resources {
+		if resource.matched || (len(scope) > 0 && !inScope[resource.vpcID]) {
+			continue
+		}
+		aws := resource.name
+		if aws == "" {
+			aws = strings.Join(resource.cidrs, ", ")
+		}
+		drifts = append(drifts, Drift{Kind: resource.kind, ResourceID: resource.id, Problem: DriftNotInDiagram, AWS: aws})
+	}
+
+	sort.SliceStable(drifts, func(i, j int) bool {
+		a, b := drifts[i], drifts[j]
+		if a.Problem != b.Problem {
+			return driftOrder[a.Problem] < driftOrder[b.Problem]
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.ResourceID < b.ResourceID
+	})
+	return drifts
+}
+
+// inventoryNodes lists the resources a diagram of the inventory would show
+func inventoryNodes(inventory Inventory) []awsNode {
+	var resources []awsNode
+	for _, v := range inventory.VPCs {
+		blocks := []string{}
+		if v.CidrBlock != "" {
+			blocks = append(blocks, v.CidrBlock)
+		}
+		for _, block := range v.AssociateCidrBlocks {
+			if block != v.CidrBlock {
+				blocks = append(blocks, block)
+			}
+		}
+		blocks = append(blocks, v.Ipv6CidrBlocks...)
+		resources = append(resources, awsNode{kind: NodeVPC, id: v.VpcID, name: v.Tags["Name"], vpcID: v.VpcID, cidrs: blocks})
+	}
+	for _, subnet := range inventory.Subnets {
+		var blocks []string
+		if subnet.CidrBlock != "" {
+			blocks = []string{subnet.CidrBlock}
+		}
+		resources = append(resources, awsNode{kind: NodeSubnet, id: subnet.SubnetID, name: subnet.Tags["Name"], vpcID: subnet.VpcID, cidrs: blocks})
+	}
+	for _, igw := range inventory.InternetGateways {
+		if igw.VpcID == "" {
+			continue
+		}
+		resources = append(resources, awsNode{kind: NodeInternetGateway, id: igw.InternetGatewayID, name: igw.Tags["Name"], vpcID: igw.VpcID})
+	}
+	for _, ngw := range inventory.NatGateways {
+		resources = append(resources, awsNode{kind: NodeNATGateway, id: ngw.NatGatewayID, name: ngw.Tags["Name"], vpcID: ngw.VpcID})
+	}
+	for _, tgw := range inventory.TransitGateways {
+		resources = append(resources, awsNode{kind: NodeTransitGateway, id: tgw.TransitGatewayID, name: tgw.Tags["Name"]})
+	}
+	return resources
+}
+
+// matchUnidentified finds the unmatched resource a node without a resource ID depicts
+// Returns: The resource, or nil if none matches
+func matchUnidentified(node ImportedNode, resources []awsNode) *awsNode {
+	candidates := func(match func(awsNode) bool) *awsNode {
+		for i := range resources {
+			resource := &resources[i]
+			if resource.matched || resource.kind != node.Kind || (node.VpcID != "" && resource.vpcID != node.VpcID) {
+				continue
+			}
+			if match(*resource) {
+				return resource
+			}
+		}
+		return nil
+	}
+	if len(node.CIDRs) > 0 {
+		if resource := candidates(func(r awsNode) bool { return len(r.cidrs) > 0 && r.cidrs[0] == node.CIDRs[0] }); resource != nil {
+			return resource
+		}
+	}
+	if resource := candidates(func(r awsNode) bool { return r.name != "" && labelShows(node.Label, r.name) }); resource != nil {
+		return resource
+	}
+	// An unnamed gateway is the gateway of its VPC if the VPC has only one left
+	if node.VpcID == "" || (node.Kind != NodeInternetGateway && node.Kind != NodeNATGateway) {
+		return nil
+	}
+	var sole *awsNode
+	for i := range resources {
+		resource := &resources[i]
+		if resource.matched || resource.kind != node.Kind || resource.vpcID != node.VpcID {
+			continue
+		}
+		if sole != nil {
+			return nil
+		}
+		sole = resource
+	}
+	return sole
+}
+
+// compareNode compares a node with the resource it depicts
+func compareNode(node ImportedNode, resource awsNode) []Drift {
+	var drifts []Drift
+	node.ResourceID = resource.id
+	if len(node.CIDRs) > 0 && !sameBlocks(node.CIDRs, resource.cidrs) {
+		drifts = append(drifts, nodeDrift(node, DriftCIDR, strings.Join(node.CIDRs, ", "), strings.Join(resource.cidrs, ", ")))
+	}
+	if node.Kind != NodeVPC && node.VpcID != "" && resource.vpcID != "" && node.VpcID != resource.vpcID {
+		drifts = append(drifts, nodeDrift(node, DriftVPC, node.VpcID, resource.vpcID))
+	}
+	if node.Label != "" && !labelShows(node.Label, resource.id) && (resource.name == "" || !labelShows(node.Label, resource.name)) {
+		aws := resource.name
+		if aws == "" {
+			aws = resource.id
+		}
+		drifts = append(drifts, nodeDrift(node, DriftLabel, labelLine(node.Label), aws))
+	}
+	return drifts
+}
+
+// nodeDrift creates a drift of a diagram node
+func nodeDrift(node ImportedNode, problem, diagram, aws string) Drift {
+	return Drift{Kind: node.Kind, ResourceID: node.ResourceID, Problem: problem, Diagram: diagram, AWS: aws, Page: node.Page, CellID: node.CellID}
+}
+
+// labelShows reports whether a label contains a name or ID, ignoring whitespace and case
+func labelShows(label, text string) bool {
+	squash := func(s string) string { return strings.ToLower(strings.Join(strings.Fields(s), "")) }
+	return strings.Contains(squash(label), squash(text))
+}
+
+// sameBlocks reports whether two lists hold the same CIDR blocks in any order
+func sameBlocks(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int, len(a))
+	for _, block := range a {
+		count[strings.TrimSpace(block)]++
+	}
+	for _, block := range b {
+		if count[block]--; count[block] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// labelLine returns a label on one line, for listing it
+func labelLine(label string) string {
+	return strings.ReplaceAll(label, "\n", " / ")
+}
@@ -0,0 +1,127 @@
+package diagram
+
+import (
+	"testing"
+
+	"aws-documentor/modules/testgen"
+	"aws-documentor/modules/vpc"
+)
+
+// driftInventory returns the inventory of a generated environment, with copies of the slices a test may change
+func driftInventory(env *testgen.Environment) Inventory {
+	return Inventory{
+		VPCs:             append([]vpc.VPCInfo{}, env.VPCs...),
+		Subnets:          append([]vpc.SubnetInfo{}, env.Subnets...),
+		InternetGateways: append([]vpc.InternetGatewayInfo{}, env.InternetGateways...),
+		NatGateways:      append([]vpc.NatGatewayInfo{}, env.NatGateways...),
+		TransitGateways:  append([]vpc.TransitGatewayInfo{}, env.TransitGateways...),
+	}
+}
+
+// importGenerated generates the overview diagram of an environment and imports it again
+func importGenerated(t *testing.T, env *testgen.Environment) []ImportedNode {
+	t.Helper()
+	doc, err := NewDiagramGenerator().GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+		env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := ImportDrawIO([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
+
+// TestDriftRoundTrip checks that a generated diagram checked against the resources it was drawn from has no drift
+func TestDriftRoundTrip(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	nodes := importGenerated(t, env)
+	want := len(env.VPCs) + len(env.Subnets) + len(env.InternetGateways) + len(env.NatGateways) + len(env.TransitGateways)
+	if len(nodes) != want {
+		t.Errorf("imported %d nodes, want %d", len(nodes), want)
+	}
+	if drifts := CheckDrift(nodes, driftInventory(env), nil); len(drifts) != 0 {
+		t.Errorf("round trip has %d differences, first %+v", len(drifts), drifts[0])
+	}
+	if drifts := CheckDrift(nodes, driftInventory(env), []string{env.VPCs[0].VpcID}); len(drifts) != 0 {
+		t.Errorf("round trip scoped to %s has %d differences, first %+v", env.VPCs[0].VpcID, len(drifts), drifts[0])
+	}
+}
+
+// TestDriftMismatch changes the inventory after drawing it and checks that each change is reported as its problem
+func TestDriftMismatch(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	nodes := importGenerated(t, env)
+	subnet := env.Subnets[0]
+
+	tests := []struct {
+		name    string
+		change  func(inventory *Inventory)
+		want    Drift // Kind, ResourceID and Problem of the only difference
+		diagram string
+		aws     string
+	}{
+		{
+			name:   "subnet deleted",
+			change: func(inventory *Inventory) { inventory.Subnets = inventory.Subnets[1:] },
+			want:   Drift{Kind: NodeSubnet, ResourceID: subnet.SubnetID, Problem: DriftNotInAWS},
+		},
+		{
+			name: "subnet added",
+			change: func(inventory *Inventory) {
+				inventory.Subnets = append(inventory.Subnets, vpc.SubnetInfo{SubnetID: "subnet-0added", VpcID: subnet.VpcID, CidrBlock: "10.255.0.0/24"})
+			},
+			want: Drift{Kind: NodeSubnet, ResourceID: "subnet-0added", Problem: DriftNotInDiagram},
+			aws:  "10.255.0.0/24",
+		},
+		{
+			name:    "subnet CIDR changed",
+			change:  func(inventory *Inventory) { inventory.Subnets[0].CidrBlock = "10.254.0.0/24" },
+			want:    Drift{Kind: NodeSubnet, ResourceID: subnet.SubnetID, Problem: DriftCIDR},
+			diagram: subnet.CidrBlock,
+			aws:     "10.254.0.0/24",
+		},
+		{
+			name:    "subnet moved to another VPC",
+			change:  func(inventory *Inventory) { inventory.Subnets[0].VpcID = env.VPCs[1].VpcID },
+			want:    Drift{Kind: NodeSubnet, ResourceID: subnet.SubnetID, Problem: DriftVPC},
+			diagram: subnet.VpcID,
+			aws:     env.VPCs[1].VpcID,
+		},
+		{
+			name:   "NAT gateway deleted",
+			change: func(inventory *Inventory) { inventory.NatGateways = inventory.NatGateways[1:] },
+			want:   Drift{Kind: NodeNATGateway, ResourceID: env.NatGateways[0].NatGatewayID, Problem: DriftNotInAWS},
+		},
+		{
+			name:   "internet gateway detached",
+			change: func(inventory *Inventory) { inventory.InternetGateways[0].VpcID = "" },
+			want:   Drift{Kind: NodeInternetGateway, ResourceID: env.InternetGateways[0].InternetGatewayID, Problem: DriftNotInAWS},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := driftInventory(env)
+			tt.change(&inventory)
+			drifts := CheckDrift(nodes, inventory, nil)
+			if len(drifts) != 1 {
+				t.Fatalf("got %d differences, want 1: %+v", len(drifts), drifts)
+			}
+			got := drifts[0]
+			if got.Kind != tt.want.Kind || got.ResourceID != tt.want.ResourceID || got.Problem != tt.want.Problem {
+				t.Errorf("got %s %s %s, want %s %s %s", got.Kind, got.ResourceID, got.Problem, tt.want.Kind, tt.want.ResourceID, tt.want.Problem)
+			}
+			if tt.diagram != "" && got.Diagram != tt.diagram {
+				t.Errorf("diagram shows %q, want %q", got.Diagram, tt.diagram)
+			}
+			if tt.aws != "" && got.AWS != tt.aws {
+				t.Errorf("AWS has %q, want %q", got.AWS, tt.aws)
+			}
+			if got.Problem != DriftNotInDiagram && got.CellID == "" {
+				t.Errorf("difference of a diagram node has no cell ID")
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package diagram
+
+import "fmt"
+
+// idSpace allocates cell IDs for a single diagram
+// IDs are derived from a diagram-scoped namespace, the node kind and the resource ID, so generating
+// the same input always yields the same document and no state is shared between diagrams
+type idSpace struct {
+	namespace string         // Prefix shared by every cell of the diagram
+	used      map[string]int // Number of times each base ID has been handed out
+}
+
+// newIDSpace creates an allocator for one diagram
+// namespace: Prefix for every ID, unique per diagram (overview, or the VPC ID of a detail diagram)
+func newIDSpace(namespace string) *idSpace {
+	return &idSpace{namespace: namespace, used: make(map[string]int)}
+}
+
+// id returns the cell ID for a node of the given kind representing resourceID
+// A resource drawn more than once (an ASG spanning VPCs, a panel per VPC) gets a numeric suffix from the second time on
+func (c *idSpace) id(kind, resourceID string) string {
+	base := fmt.Sprintf("%s/%s/%s", c.namespace, kind, resourceID)
+	n := c.used[base]
+	c.used[base]++
+	if n == 0 {
+		return base
+	}
+	return fmt.Sprintf("%s#%d", base, n+1)
+}
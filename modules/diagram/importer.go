@@ -0,0 +1,321 @@
+package diagram
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ImportedNode is a VPC, subnet or gateway that a draw.io diagram depicts
+type ImportedNode struct {
+	Kind       string   // Layout node kind: vpc, subnet, internet_gateway, nat_gateway or transit_gateway
+	ResourceID string   // ID from the cell attributes, the cell ID or the label ("" if the diagram only names the resource)
+	VpcID      string   // VPC of the node, from the cell attributes or the VPC container it is drawn in
+	VPCCell    string   // Cell ID of the VPC container the node is drawn in ("" if none)
+	CIDRs      []string // CIDR blocks of a VPC or subnet, from the cell attributes or the label
+	Label      string   // Full label text, lines separated by \n
+	Page       string   // Name of the diagram page
+	CellID     string   // ID of the cell, to find it in draw.io
+}
+
+// importedKinds are the node kinds the importer extracts
+var importedKinds = map[string]bool{
+	NodeVPC:             true,
+	NodeSubnet:          true,
+	NodeInternetGateway: true,
+	NodeNATGateway:      true,
+	NodeTransitGateway:  true,
+}
+
+// idPrefixes are the resource ID prefixes of the imported node kinds
+var idPrefixes = map[string]string{
+	NodeVPC:             "vpc-",
+	NodeSubnet:          "subnet-",
+	NodeInternetGateway: "igw-",
+	NodeNATGateway:      "nat-",
+	NodeTransitGateway:  "tgw-",
+}
+
+// shapeKinds maps the AWS icon shapes of gateways to node kinds
+var shapeKinds = map[string]string{
+	"mxgraph.aws4.internet_gateway": NodeInternetGateway,
+	"mxgraph.aws4.nat_gateway":      NodeNATGateway,
+	"mxgraph.aws4.transit_gateway":  NodeTransitGateway,
+}
+
+var (
+	resourceIDPattern = regexp.MustCompile(`\b(vpc|subnet|igw|nat|tgw)-[0-9a-f]{8,17}\b`)
+	cidrPattern       = regexp.MustCompile(`[0-9A-Fa-f:.]+/[0-9]{1,3}`)
+	lineBreakPattern  = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>`)
+	tagPattern        = regexp.MustCompile(`<[^>]*>`)
+)
+
+// importFile is a saved draw.io file, whose pages are either plain XML or compressed
+type importFile struct {
+	Pages []importPage `xml:"diagram"`
+}
+
+// importPage is a page of a saved draw.io file
+type importPage struct {
+	Name    string        `xml:"name,attr"`
+	Model   *MxGraphModel `xml:"mxGraphModel"`
+	Content string        `xml:",chardata"` // Compressed model when Model is nil
+}
+
+// ImportDrawIO extracts the VPCs, subnets and gateways a draw.io file depicts
+// Reads diagrams generated by this tool as well as hand-drawn ones that use the AWS shapes: files with
+// one or more pages, pages saved compressed (base64 of deflated, URL-encoded XML), and a bare
+// mxGraphModel as copied from draw.io. Cell attributes (resource_id, resource_type, vpc_id, cidr) are
+// used when present; otherwise the kind comes from the cell ID or the shape, and IDs and CIDR blocks
+// from the label. A resource drawn more than once is returned once.
+// data: Content of the .drawio file
+// Returns: The nodes in document order, or error if the file is not a draw.io diagram
+func ImportDrawIO(data []byte) ([]ImportedNode, error) {
+	var root struct{ XMLName xml.Name }
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("malformed diagram XML: %w", err)
+	}
+
+	var pages []importPage
+	switch root.XMLName.Local {
+	case "mxfile":
+		var file importFile
+		if err := xml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("malformed diagram XML: %w", err)
+		}
+		pages = file.Pages
+	case "mxGraphModel":
+		var model MxGraphModel
+		if err := xml.Unmarshal(data, &model); err != nil {
+			return nil, fmt.Errorf("malformed diagram XML: %w", err)
+		}
+		pages = []importPage{{Model: &model}}
+	default:
+		return nil, fmt.Errorf("not a draw.io diagram: root element is <%s>, expected <mxfile> or <mxGraphModel>", root.XMLName.Local)
+	}
+
+	var nodes []ImportedNode
+	seen := make(map[string]bool)
+	for i, page := range pages {
+		name := page.Name
+		if name == "" {
+			name = fmt.Sprintf("page %d", i+1)
+		}
+		model := page.Model
+		if model == nil {
+			var err error
+			if model, err = inflatePage(page.Content); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		for _, node := range importCells(model.Root.Cells, name) {
+			key := node.Kind + "/" + node.ResourceID
+			if node.ResourceID != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// inflatePage decodes a compressed page: base64, then raw deflate, then URL encoding
+// content: Text content of the <diagram> element
+// Returns: The graph model of the page, or error if the content is not a compressed model
+func inflatePage(content string) (*MxGraphModel, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return &MxGraphModel{}, nil
+	}
+	compressed, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("compressed page is not base64: %w", err)
+	}
+	inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot inflate compressed page: %w", err)
+	}
+	text, err := url.PathUnescape(string(inflated))
+	if err != nil {
+		return nil, fmt.Errorf("compressed page is not URL-encoded: %w", err)
+	}
+	var model MxGraphModel
+	if err := xml.Unmarshal([]byte(text), &model); err != nil {
+		return nil, fmt.Errorf("malformed XML in compressed page: %w", err)
+	}
+	return &model, nil
+}
+
+// importCells classifies the vertices of a page and resolves the VPC each is drawn in
+func importCells(cells []Cell, page string) []ImportedNode {
+	parents := make(map[string]string, len(cells))
+	for _, cell := range cells {
+		parents[cell.ID] = cell.Parent
+	}
+
+	var nodes []ImportedNode
+	byCell := make(map[string]*ImportedNode)
+	for _, cell := range cells {
+		if cell.Vertex != "1" {
+			continue
+		}
+		if node, ok := importCell(cell); ok {
+			node.Page = page
+			nodes = append(nodes, node)
+		}
+	}
+	for i := range nodes {
+		byCell[nodes[i].CellID] = &nodes[i]
+	}
+
+	// Nodes without a vpc_id attribute are in the VPC container they are drawn in
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Kind == NodeVPC {
+			node.VpcID = node.ResourceID
+			continue
+		}
+		if node.Kind == NodeTransitGateway {
+			continue
+		}
+		visited := map[string]bool{node.CellID: true}
+		for parent := parents[node.CellID]; parent != "" && !visited[parent]; parent = parents[parent] {
+			visited[parent] = true
+			if container, ok := byCell[parent]; ok && container.Kind == NodeVPC {
+				node.VPCCell = container.CellID
+				if node.VpcID == "" {
+					node.VpcID = container.ResourceID
+				}
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+// importCell classifies a vertex as one of the imported node kinds
+// Returns: The node, and whether the cell is a VPC, subnet or gateway
+func importCell(cell Cell) (ImportedNode, bool) {
+	text := cell.Value
+	if cell.Tooltip != "" {
+		text = cell.Tooltip
+	}
+	node := ImportedNode{Label: labelText(text), CellID: cell.ID}
+
+	attrs := make(map[string]string, len(cell.Data))
+	for _, attr := range cell.Data {
+		attrs[attr.Name.Local] = attr.Value
+	}
+
+	// Kind: cell attribute, then the kind part of a generated cell ID, then the shape
+	node.Kind = attrs[AttrResourceType]
+	if !importedKinds[node.Kind] {
+		node.Kind, node.ResourceID = kindFromCellID(cell.ID)
+	}
+	if !importedKinds[node.Kind] {
+		node.Kind, node.ResourceID = kindFromStyle(cell.Style, node.Label), ""
+	}
+	if !importedKinds[node.Kind] {
+		return ImportedNode{}, false
+	}
+
+	if id := attrs[AttrResourceID]; id != "" {
+		node.ResourceID = id
+	}
+	if node.ResourceID == "" {
+		node.ResourceID = labelResourceID(node.Label, node.Kind)
+	}
+	node.VpcID = attrs[AttrVpcID]
+
+	if node.Kind == NodeVPC || node.Kind == NodeSubnet {
+		if cidr := attrs[AttrCIDR]; cidr != "" {
+			node.CIDRs = strings.Split(cidr, ",")
+		} else {
+			node.CIDRs = labelCIDRs(node.Label)
+		}
+	}
+	return node, true
+}
+
+// kindFromCellID reads the kind and resource ID of a generated cell ID (namespace/kind/resource ID)
+// A "#n" suffix of a resource drawn more than once is dropped.
+func kindFromCellID(id string) (string, string) {
+	parts := strings.SplitN(id, "/", 3)
+	if len(parts) != 3 {
+		return "", ""
+	}
+	resourceID, _, _ := strings.Cut(parts[2], "#")
+	return parts[1], resourceID
+}
+
+// kindFromStyle recognizes the AWS shapes of hand-drawn cells
+// Subnets use the generic group shape of the AWS library, so they are told apart by their label.
+func kindFromStyle(style, label string) string {
+	values := make(map[string]string)
+	for _, entry := range strings.Split(style, ";") {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			values[key] = value
+		}
+	}
+	for _, key := range []string{"shape", "resIcon"} {
+		if kind, ok := shapeKinds[values[key]]; ok {
+			return kind
+		}
+	}
+	switch values["grIcon"] {
+	case "mxgraph.aws4.group_vpc", "mxgraph.aws4.group_vpc2":
+		return NodeVPC
+	case "mxgraph.aws4.group_security_group":
+		if strings.Contains(strings.ToLower(label), "subnet") {
+			return NodeSubnet
+		}
+	}
+	return ""
+}
+
+// labelText turns a cell value into plain text with \n between lines
+// Generated labels are escaped text; hand-drawn ones may be HTML with <br> and <div> line breaks.
+func labelText(value string) string {
+	value = lineBreakPattern.ReplaceAllString(value, "\n")
+	value = html.UnescapeString(tagPattern.ReplaceAllString(value, ""))
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// labelResourceID returns the first resource ID of the node's kind in a label, or ""
+func labelResourceID(label, kind string) string {
+	for _, id := range resourceIDPattern.FindAllString(label, -1) {
+		if strings.HasPrefix(id, idPrefixes[kind]) {
+			return id
+		}
+	}
+	return ""
+}
+
+// labelCIDRs returns the valid CIDR blocks of a label in order
+// Lines are searched one at a time, so a block is not joined with the end of the line before it.
+func labelCIDRs(label string) []string {
+	var cidrs []string
+	for _, line := range strings.Split(label, "\n") {
+		for _, candidate := range cidrPattern.FindAllString(line, -1) {
+			if _, _, err := net.ParseCIDR(candidate); err == nil {
+				cidrs = append(cidrs, candidate)
+			}
+		}
+	}
+	return cidrs
+}
@@ -0,0 +1,191 @@
+package diagram
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Label measurement heuristics; draw.io measures with the browser's fonts, so these err on the wide side
+const (
+	narrowCharWidth = 0.6 // Width of a Latin character in font sizes (Helvetica averages about 0.55)
+	wideCharWidth   = 1.0 // Width of a CJK character or emoji in font sizes
+	lineHeight      = 1.2 // Line height in font sizes, as draw.io renders labels
+	ellipsis        = "…" // Marks a label that was cut at its line budget
+
+	minLabelFontSize = 8   // Smallest font size a label shrinks to before it is truncated
+	iconLabelWidth   = 140 // Width of the label below an icon, which may be wider than the icon
+	panelPadding     = 20  // Space above and below the text of a route table or security group panel
+)
+
+// panelLabelBox is the text area of the route table panels and their local route groups, which grow to fit
+var panelLabelBox = labelBox{Width: 290, FontSize: 9}
+
+// labelBox is the space a label may take in its cell
+type labelBox struct {
+	Width       float64 // Available width in pixels
+	FontSize    float64 // Font size of the cell style
+	MinFontSize float64 // Smallest font size the label may shrink to (FontSize if it must not shrink)
+	MaxLines    int     // Line budget; 0 means the cell grows to fit every line
+}
+
+// fittedLabel is a label wrapped and, if needed, shrunk and truncated to its box
+type fittedLabel struct {
+	Lines     []string // Lines to render
+	FontSize  float64  // Font size the lines fit at
+	Truncated bool     // Whether lines were cut at the line budget (the full text goes into the tooltip)
+	Full      string   // The label before fitting
+}
+
+// fitLabel fits a label into a box
+// Lines that are too wide are wrapped at spaces and commas, or between characters where there are
+// none. If the wrapped label exceeds the line budget, the font shrinks one point at a time down to
+// the box's minimum; if it still does not fit, the last line within the budget ends with "…".
+// Labels that fit unchanged are returned as they are, so ordinary names render as before.
+// text: Label with its lines separated by \n
+// box: Space of the label
+// Returns: The fitted label
+func fitLabel(text string, box labelBox) fittedLabel {
+	minFontSize := math.Min(box.MinFontSize, box.FontSize)
+	if minFontSize <= 0 {
+		minFontSize = box.FontSize
+	}
+
+	var lines []string
+	fontSize := box.FontSize
+	for ; ; fontSize-- {
+		lines = wrapLabel(text, box.Width, fontSize)
+		if box.MaxLines == 0 || len(lines) <= box.MaxLines || fontSize-1 < minFontSize {
+			break
+		}
+	}
+
+	fitted := fittedLabel{Lines: lines, FontSize: fontSize, Full: text}
+	if box.MaxLines > 0 && len(lines) > box.MaxLines {
+		fitted.Lines = append([]string{}, lines[:box.MaxLines]...)
+		last := box.MaxLines - 1
+		fitted.Lines[last] = truncateToWidth(fitted.Lines[last], box.Width, fontSize)
+		fitted.Truncated = true
+	}
+	return fitted
+}
+
+// Text returns the lines of the label joined for a cell value
+func (f fittedLabel) Text() string {
+	return strings.Join(f.Lines, "\n")
+}
+
+// Height returns the height the lines take in pixels
+func (f fittedLabel) Height() float64 {
+	return float64(len(f.Lines)) * f.FontSize * lineHeight
+}
+
+// fontSizePattern matches the font size of a cell style
+var fontSizePattern = regexp.MustCompile(`(^|;)fontSize=[0-9.]+;`)
+
+// applyLabel sets the value of a cell to a fitted label
+// A shrunk font replaces the fontSize of the style, and a truncated label keeps its full text
+// in the tooltip.
+func (c *Cell) applyLabel(label fittedLabel, box labelBox) {
+	c.Value = escapeXML(label.Text())
+	if label.FontSize != box.FontSize {
+		size := "fontSize=" + strconv.FormatFloat(label.FontSize, 'f', -1, 64) + ";"
+		c.Style = fontSizePattern.ReplaceAllString(c.Style, "${1}"+size)
+	}
+	if label.Truncated {
+		c.Tooltip = label.Full
+	}
+}
+
+// labelCell fits a label into a box and sets it as the value of a cell
+func labelCell(cell Cell, text string, box labelBox) Cell {
+	cell.applyLabel(fitLabel(text, box), box)
+	return cell
+}
+
+// wrapLabel wraps every line of a label that is wider than the available width
+func wrapLabel(text string, width, fontSize float64) []string {
+	var wrapped []string
+	for _, line := range strings.Split(text, "\n") {
+		wrapped = append(wrapped, wrapLine(line, width, fontSize)...)
+	}
+	return wrapped
+}
+
+// wrapLine breaks one line into lines no wider than width
+// Breaks go after the last space, comma or hyphen that fits; a word wider than the line is broken between characters.
+func wrapLine(line string, width, fontSize float64) []string {
+	if textWidth(line, fontSize) <= width {
+		return []string{line}
+	}
+
+	var lines []string
+	for line != "" {
+		end, breakAt := 0, 0
+		lineWidth := 0.0
+		for i, r := range line {
+			lineWidth += runeWidth(r, fontSize)
+			if lineWidth > width && end > 0 {
+				break
+			}
+			end = i + utf8.RuneLen(r)
+			if r == ' ' || r == ',' || r == '-' {
+				breakAt = end
+			}
+		}
+		if end < len(line) && breakAt > 0 {
+			end = breakAt
+		}
+		lines = append(lines, strings.TrimRight(line[:end], " "))
+		line = strings.TrimLeft(line[end:], " ")
+	}
+	return lines
+}
+
+// truncateToWidth shortens a line so that it and a trailing ellipsis fit the width
+func truncateToWidth(line string, width, fontSize float64) string {
+	budget := width - runeWidth('…', fontSize)
+	end := 0
+	lineWidth := 0.0
+	for i, r := range line {
+		lineWidth += runeWidth(r, fontSize)
+		if lineWidth > budget {
+			break
+		}
+		end = i + utf8.RuneLen(r)
+	}
+	return strings.TrimRight(line[:end], " ,") + ellipsis
+}
+
+// textWidth estimates the rendered width of a line in pixels
+func textWidth(line string, fontSize float64) float64 {
+	width := 0.0
+	for _, r := range line {
+		width += runeWidth(r, fontSize)
+	}
+	return width
+}
+
+// runeWidth estimates the rendered width of a character in pixels
+// Combining marks, variation selectors and joiners take no space; East Asian wide characters and
+// emoji take a full em.
+func runeWidth(r rune, fontSize float64) float64 {
+	switch {
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || r == '\u200d' || (r >= 0xfe00 && r <= 0xfe0f):
+		return 0
+	case isWide(r):
+		return wideCharWidth * fontSize
+	}
+	return narrowCharWidth * fontSize
+}
+
+// isWide reports whether a character is rendered about one em wide
+func isWide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x2e80 && r <= 0xa4cf) || // CJK radicals, symbols, punctuation and Yi
+		(r >= 0xff00 && r <= 0xff60) || (r >= 0xffe0 && r <= 0xffe6) || // Fullwidth forms
+		(r >= 0x1f300 && r <= 0x1faff) || (r >= 0x2600 && r <= 0x27bf) // Emoji and pictographs
+}
@@ -0,0 +1,169 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// longName is an 80-character name without spaces, as some Name tags are
+var longName = strings.Repeat("a", 80)
+
+// tenCIDRs are the CIDR blocks of a route table panel line with ten destinations
+var tenCIDRs = func() string {
+	var cidrs []string
+	for i := 0; i < 10; i++ {
+		cidrs = append(cidrs, fmt.Sprintf("10.%d.128.0/17", 100+i))
+	}
+	return strings.Join(cidrs, ", ")
+}()
+
+// checkLines fails the test if a line is wider than the width or splits a UTF-8 sequence
+func checkLines(t *testing.T, lines []string, width, fontSize float64) {
+	t.Helper()
+	for i, line := range lines {
+		if w := textWidth(line, fontSize); w > width {
+			t.Errorf("line %d %q is %.1f pixels wide, want at most %.1f", i, line, w, width)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d %q splits a UTF-8 sequence", i, line)
+		}
+	}
+}
+
+// TestWrapLine checks the geometry of wrapped lines and that wrapping loses no characters
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		width     float64
+		fontSize  float64
+		wantLines int
+		joiner    string // Separator that restores the line from the wrapped lines
+	}{
+		{name: "fits", line: "vpc-prod", width: 140, fontSize: 10, wantLines: 1},
+		// 6 pixels per character: 23 characters per line of 140 pixels
+		{name: "80 characters without breaks", line: longName, width: 140, fontSize: 10, wantLines: 4},
+		{name: "ten CIDRs", line: tenCIDRs, width: 140, fontSize: 10, wantLines: 10, joiner: " "},
+		// 10 pixels per CJK character: 10 characters per line of 100 pixels
+		{name: "CJK", line: strings.Repeat("本番環境", 8), width: 100, fontSize: 10, wantLines: 4},
+		{name: "emoji", line: strings.Repeat("🚀", 25), width: 100, fontSize: 10, wantLines: 3},
+		// Combining accents take no space, so 20 accented letters fit where 20 plain ones do
+		{name: "combining marks", line: strings.Repeat("e\u0301", 40), width: 120, fontSize: 10, wantLines: 2},
+		{name: "narrower than one character", line: "abc", width: 2, fontSize: 10, wantLines: 3},
+		{name: "hyphenated", line: "shared-services-transit-gateway-attachment", width: 100, fontSize: 10, wantLines: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := wrapLine(tt.line, tt.width, tt.fontSize)
+			if len(lines) != tt.wantLines {
+				t.Errorf("wrapLine() = %d lines %q, want %d", len(lines), lines, tt.wantLines)
+			}
+			if tt.width >= runeWidth('W', tt.fontSize) {
+				checkLines(t, lines, tt.width, tt.fontSize)
+			}
+			if got := strings.Join(lines, tt.joiner); got != tt.line {
+				t.Errorf("wrapped lines %q do not restore %q", lines, tt.line)
+			}
+		})
+	}
+
+	// Breaks go after the commas, so no CIDR block is split across lines
+	for _, line := range wrapLine(tenCIDRs, 140, 10) {
+		for _, cidr := range strings.Split(strings.TrimSuffix(line, ","), ", ") {
+			if !strings.HasPrefix(cidr, "10.") || !strings.HasSuffix(cidr, "/17") {
+				t.Errorf("line %q splits a CIDR block", line)
+			}
+		}
+	}
+}
+
+// TestTruncateToWidth checks that a truncated line and its ellipsis fit the width
+func TestTruncateToWidth(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		width    float64
+		fontSize float64
+		want     string
+	}{
+		// 6 pixels per character and for the ellipsis: 22 characters fit in 140 pixels
+		{name: "80 characters", line: longName, width: 140, fontSize: 10, want: strings.Repeat("a", 22) + "…"},
+		{name: "trailing comma removed", line: "10.100.128.0/17, 10.101.128.0/17", width: 112, fontSize: 10, want: "10.100.128.0/17…"},
+		{name: "CJK", line: strings.Repeat("本", 20), width: 100, fontSize: 10, want: strings.Repeat("本", 9) + "…"},
+		{name: "width below the ellipsis", line: "abc", width: 5, fontSize: 10, want: "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateToWidth(tt.line, tt.width, tt.fontSize)
+			if got != tt.want {
+				t.Errorf("truncateToWidth() = %q, want %q", got, tt.want)
+			}
+			if !strings.HasSuffix(got, ellipsis) || !utf8.ValidString(got) {
+				t.Errorf("truncateToWidth() = %q, want valid UTF-8 ending with %q", got, ellipsis)
+			}
+			if tt.width >= runeWidth('…', tt.fontSize) && textWidth(got, tt.fontSize) > tt.width {
+				t.Errorf("truncateToWidth() = %q is %.1f pixels wide, want at most %.1f", got, textWidth(got, tt.fontSize), tt.width)
+			}
+		})
+	}
+}
+
+// TestFitLabel checks shrinking, truncation at the line budget and labels that grow to fit
+func TestFitLabel(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		box           labelBox
+		wantFontSize  float64
+		wantLines     int
+		wantTruncated bool
+	}{
+		{name: "fits unchanged", text: "vpc-prod\n10.0.0.0/16", box: labelBox{Width: 140, FontSize: 10, MinFontSize: 8, MaxLines: 2}, wantFontSize: 10, wantLines: 2},
+		// 3 lines at 10 points, 2 lines at 9 points (25 characters per line)
+		{name: "shrinks to fit", text: strings.Repeat("b", 50), box: labelBox{Width: 140, FontSize: 10, MinFontSize: 8, MaxLines: 2}, wantFontSize: 9, wantLines: 2},
+		// Still 3 lines at 8 points (29 characters per line), so the second line is cut
+		{name: "80 characters truncated", text: longName, box: labelBox{Width: 140, FontSize: 10, MinFontSize: 8, MaxLines: 2}, wantFontSize: 8, wantLines: 2, wantTruncated: true},
+		{name: "must not shrink", text: longName, box: labelBox{Width: 140, FontSize: 10, MaxLines: 2}, wantFontSize: 10, wantLines: 2, wantTruncated: true},
+		{name: "ten CIDRs grow the cell", text: "Routes\n" + tenCIDRs, box: panelLabelBox, wantFontSize: 9, wantLines: 5},
+		{name: "ten CIDRs in one line", text: tenCIDRs, box: labelBox{Width: 140, FontSize: 10, MinFontSize: 8, MaxLines: 1}, wantFontSize: 8, wantLines: 1, wantTruncated: true},
+		{name: "multibyte truncated", text: strings.Repeat("本番環境", 10), box: labelBox{Width: iconLabelWidth, FontSize: 10, MinFontSize: 8, MaxLines: 2}, wantFontSize: 8, wantLines: 2, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fitted := fitLabel(tt.text, tt.box)
+			if fitted.FontSize != tt.wantFontSize || len(fitted.Lines) != tt.wantLines || fitted.Truncated != tt.wantTruncated {
+				t.Fatalf("fitLabel() = %d lines at %.0f points, truncated %v; want %d lines at %.0f points, truncated %v: %q",
+					len(fitted.Lines), fitted.FontSize, fitted.Truncated, tt.wantLines, tt.wantFontSize, tt.wantTruncated, fitted.Lines)
+			}
+			checkLines(t, fitted.Lines, tt.box.Width, fitted.FontSize)
+			if last := fitted.Lines[len(fitted.Lines)-1]; strings.HasSuffix(last, ellipsis) != tt.wantTruncated {
+				t.Errorf("last line %q, want an ellipsis only when truncated", last)
+			}
+			if fitted.Full != tt.text {
+				t.Errorf("Full = %q, want the label before fitting", fitted.Full)
+			}
+			if want := float64(tt.wantLines) * tt.wantFontSize * lineHeight; fitted.Height() != want {
+				t.Errorf("Height() = %.1f, want %.1f", fitted.Height(), want)
+			}
+		})
+	}
+}
+
+// TestLabelCell checks that a shrunk, truncated label sets the font size of the style and keeps its text in the tooltip
+func TestLabelCell(t *testing.T) {
+	box := labelBox{Width: 140, FontSize: 10, MinFontSize: 8, MaxLines: 2}
+	cell := labelCell(Cell{Style: "rounded=1;fontSize=10;html=1;"}, longName, box)
+	if cell.Style != "rounded=1;fontSize=8;html=1;" {
+		t.Errorf("Style = %q, want the font size 8", cell.Style)
+	}
+	if cell.Tooltip != longName {
+		t.Errorf("Tooltip = %q, want the full name", cell.Tooltip)
+	}
+
+	short := labelCell(Cell{Style: "fontSize=10;"}, "vpc-prod", box)
+	if short.Style != "fontSize=10;" || short.Tooltip != "" || short.Value != "vpc-prod" {
+		t.Errorf("labelCell() = %+v, want the name unchanged", short)
+	}
+}
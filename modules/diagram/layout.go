@@ -0,0 +1,905 @@
+package diagram
+
+import (
+	"fmt"
+	"math"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/vpc"
+)
+
+// Layout node kinds
+const (
+	NodeVPC             = "vpc"              // VPC container
+	NodeSubnet          = "subnet"           // Subnet container inside a VPC
+	NodeInternetGateway = "internet_gateway" // Internet gateway inside a VPC
+	NodeNATGateway      = "nat_gateway"      // NAT gateway inside its subnet
+	NodeTransitGateway  = "transit_gateway"  // Transit gateway at the top level
+	NodeTGWAttachment   = "tgw_attachment"   // Transit gateway attachment at the top level
+	NodeCoreNetwork     = "core_network"     // Cloud WAN core network container at the top level
+	NodeSegment         = "segment"          // Segment lane inside a core network
+	NodeCWANAttachment  = "cwan_attachment"  // Core network attachment inside its segment lane
+	NodeDirectory       = "directory"        // Directory Service directory spanning its subnets at the bottom of a VPC
+	NodeASG             = "asg"              // Auto Scaling group spanning its subnets between the subnet rows of a VPC
+	NodeRouteAppliance  = "route_appliance"  // NAT instance or other routing appliance inside its subnet
+	NodeVpcEndpoint     = "vpc_endpoint"     // Interface or Gateway Load Balancer endpoint inside each of its subnets
+	NodeGatewayEndpoint = "gateway_endpoint" // Gateway endpoint (S3, DynamoDB) in the gateway lane of its VPC
+	NodeRegion          = "region"           // Region container at the top level of a multi-region diagram
+	NodeVpnGateway      = "vpn_gateway"      // Virtual private gateway in the gateway lane of its VPC
+	NodeCustomerGateway = "customer_gateway" // On-premises end of VPN connections, in a column at the top level
+)
+
+// LayoutNode is a positioned shape in the diagram, independent of the output format
+type LayoutNode struct {
+	Kind       string      // Node kind (vpc, subnet, internet_gateway, ...)
+	ResourceID string      // AWS ID of the resource the node represents
+	ParentID   string      // ResourceID of the containing node (empty for top-level nodes)
+	Name       string      // Friendly name (Name tag, falling back to the resource ID)
+	Detail     string      // Short secondary text (CIDR block, ASN, state)
+	Public     bool        // Whether a subnet is public
+	X          float64     // X position relative to the parent node
+	Y          float64     // Y position relative to the parent node
+	Width      float64     // Width of the node
+	Height     float64     // Height of the node
+	Resource   interface{} // Scanned resource the node was built from (vpc.VPCInfo, vpc.SubnetInfo, ...)
+}
+
+// LayoutEdge connects two layout nodes by resource ID
+type LayoutEdge struct {
+	From     string      // ResourceID of the source node
+	To       string      // ResourceID of the target node
+	Resource interface{} // Scanned resource the edge stands for (vpc.VpcPeeringConnectionInfo, vpc.VpnConnectionInfo), nil for containment lines
+}
+
+// Layout is the computed position of every shape in a diagram, shared by the draw.io and PDF renderers
+type Layout struct {
+	Nodes []LayoutNode // Nodes in drawing order (containers before their children)
+	Edges []LayoutEdge // Connections between nodes
+
+	index    map[string]int   // Position in Nodes by resource ID, of the first node with the ID
+	children map[string][]int // Positions in Nodes of the children of each resource ID, in the order they were added
+}
+
+// Geometry of the inside of a VPC container
+const (
+	vpcHeaderHeight    = 40.0  // Space for the VPC label above the first subnet row
+	vpcMinHeight       = 460.0 // Height of a VPC with two rows of empty subnets
+	laneX              = 20.0  // X of the gateway lane along the left edge of a VPC
+	laneWidth          = 130.0 // Width of the gateway lane when the VPC has gateways (icon and label)
+	laneSpacing        = 120.0 // Distance between the gateways in the lane (icon and label)
+	subnetWidth        = 200.0 // Width of a subnet container
+	subnetMinHeight    = 170.0 // Height of a subnet whose icons fit one grid row
+	subnetSpacing      = 240.0 // Distance between the subnets of a row
+	subnetHeaderHeight = 90.0  // Space for the subnet label (six lines) above its icon grid
+	gridCellWidth      = 95.0  // Width of a cell of the icon grid of a subnet
+	gridCellHeight     = 80.0  // Height of a cell of the icon grid (icon and two label lines)
+	gridIconSize       = 48.0  // Size of the icons in the grid of a subnet
+	rowGap             = 40.0  // Space between the subnet rows, which holds two ASG bars and grows for more
+	asgBarHeight       = 16.0  // Height of an Auto Scaling group bar
+	directoryBarHeight = 26.0  // Height of a directory bar
+	directorySpacing   = 30.0  // Distance between the directory bars below the private subnets
+)
+
+// laneKinds are the node kinds stacked in the gateway lane of a VPC
+var laneKinds = map[string]bool{NodeInternetGateway: true, NodeGatewayEndpoint: true, NodeVpnGateway: true}
+
+// gridKinds are the node kinds placed in the icon grid of a subnet
+var gridKinds = map[string]bool{NodeNATGateway: true, NodeRouteAppliance: true, NodeVpcEndpoint: true}
+
+// PlacedNode is a layout node with its position resolved against its containers
+type PlacedNode struct {
+	LayoutNode
+	AbsoluteX float64 // X position relative to the diagram origin
+	AbsoluteY float64 // Y position relative to the diagram origin
+}
+
+// ComputeLayout computes the overview diagram layout used by GenerateVPCDiagram
+// Returns: Layout with VPC containers side by side followed by the transit gateway section
+func ComputeLayout(
+	vpcs []vpc.VPCInfo,
+	subnets []vpc.SubnetInfo,
+	internetGateways []vpc.InternetGatewayInfo,
+	natGateways []vpc.NatGatewayInfo,
+	transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo,
+) *Layout {
+	layout := &Layout{}
+
+	// Resources grouped by VPC once, so each VPC only looks at its own
+	subnetsByVPC := make(map[string][]vpc.SubnetInfo)
+	for _, subnet := range subnets {
+		subnetsByVPC[subnet.VpcID] = append(subnetsByVPC[subnet.VpcID], subnet)
+	}
+	igwsByVPC := make(map[string][]vpc.InternetGatewayInfo)
+	for _, igw := range internetGateways {
+		igwsByVPC[igw.VpcID] = append(igwsByVPC[igw.VpcID], igw)
+	}
+	ngwsByVPC := make(map[string][]vpc.NatGatewayInfo)
+	for _, ngw := range natGateways {
+		ngwsByVPC[ngw.VpcID] = append(ngwsByVPC[ngw.VpcID], ngw)
+	}
+
+	// VPC containers with their contents
+	xOffset := 50.0
+	for _, v := range vpcs {
+		layout.addVPC(v, subnetsByVPC[v.VpcID], igwsByVPC[v.VpcID], ngwsByVPC[v.VpcID], xOffset, 50)
+		node, _ := layout.find(v.VpcID)
+		xOffset += math.Max(1200, node.Width+100) // Space between VPCs
+	}
+
+	// Transit Gateway section if present, to the right of the VPCs
+	if len(transitGateways) > 0 {
+		layout.addTransitGateways(transitGateways, tgwAttachments, vpcs, xOffset, 50)
+	}
+
+	return layout
+}
+
+// RegionResources are the core resources of one region of a multi-region diagram
+type RegionResources struct {
+	Region           string                             // Region the resources are in
+	VPCs             []vpc.VPCInfo                      // VPCs of the region
+	Subnets          []vpc.SubnetInfo                   // Subnets of the VPCs
+	InternetGateways []vpc.InternetGatewayInfo          // Internet gateways of the VPCs
+	NatGateways      []vpc.NatGatewayInfo               // NAT gateways of the VPCs
+	TransitGateways  []vpc.TransitGatewayInfo           // Transit gateways of the region
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo // Transit gateway attachments of the region
+}
+
+// regionPadding is the space between a region container and the overview layout of its resources
+const regionPadding = 50.0
+
+// ComputeRegionsLayout computes the layout used by GenerateRegionsDiagram
+// Each region is laid out as by ComputeLayout and wrapped in a region container; the resource ID of
+// the container is the region name.
+// Returns: Layout with the region containers side by side
+func ComputeRegionsLayout(regions []RegionResources) *Layout {
+	layout := &Layout{}
+	xOffset := 50.0
+	for _, region := range regions {
+		inner := ComputeLayout(region.VPCs, region.Subnets, region.InternetGateways, region.NatGateways, region.TransitGateways, region.TGWAttachments)
+		width, height := inner.Bounds()
+		layout.add(LayoutNode{
+			Kind:       NodeRegion,
+			ResourceID: region.Region,
+			Name:       region.Region,
+			Detail:     fmt.Sprintf("%d VPCs", len(region.VPCs)),
+			X:          xOffset,
+			Y:          50,
+			Width:      math.Max(width, 400) + regionPadding,
+			Height:     math.Max(height, 200) + regionPadding,
+			Resource:   region,
+		})
+
+		// The inner layout already keeps 50 from its origin, which becomes the top and left padding
+		for _, node := range inner.Nodes {
+			if node.ParentID == "" {
+				node.ParentID = region.Region
+			}
+			layout.add(node)
+		}
+		layout.Edges = append(layout.Edges, inner.Edges...)
+
+		node, _ := layout.find(region.Region)
+		xOffset += node.Width + 100 // Space between regions
+	}
+	return layout
+}
+
+// AddVpcPeerings connects the VPC containers of every active peering connection with an edge
+// Connections to a VPC that is not in the diagram (another region or account) are left out; the scan
+// gaps list them.
+func (l *Layout) AddVpcPeerings(peerings []vpc.VpcPeeringConnectionInfo) {
+	for _, pcx := range peerings {
+		if pcx.Status != "active" || pcx.RequesterVpcID == pcx.AccepterVpcID {
+			continue
+		}
+		requester, okRequester := l.find(pcx.RequesterVpcID)
+		accepter, okAccepter := l.find(pcx.AccepterVpcID)
+		if !okRequester || !okAccepter || requester.Kind != NodeVPC || accepter.Kind != NodeVPC {
+			continue
+		}
+		l.Edges = append(l.Edges, LayoutEdge{From: pcx.RequesterVpcID, To: pcx.AccepterVpcID, Resource: pcx})
+	}
+}
+
+// AbsolutePosition returns the position of a node relative to the diagram origin
+func (l *Layout) AbsolutePosition(node LayoutNode) (float64, float64) {
+	x, y := node.X, node.Y
+	for parentID := node.ParentID; parentID != ""; {
+		parent, ok := l.find(parentID)
+		if !ok {
+			break
+		}
+		x += parent.X
+		y += parent.Y
+		parentID = parent.ParentID
+	}
+	return x, y
+}
+
+// Placed returns every node with its position relative to the diagram origin, in drawing order
+// This is the computed layout renderers draw from; containers come before their children.
+func (l *Layout) Placed() []PlacedNode {
+	placed := make([]PlacedNode, 0, len(l.Nodes))
+	origins := make(map[string][2]float64, len(l.Nodes))
+	for _, node := range l.Nodes {
+		x, y := node.X, node.Y
+		if origin, ok := origins[node.ParentID]; ok {
+			x, y = x+origin[0], y+origin[1]
+		} else if node.ParentID != "" {
+			x, y = l.AbsolutePosition(node)
+		}
+		if _, ok := origins[node.ResourceID]; !ok {
+			origins[node.ResourceID] = [2]float64{x, y}
+		}
+		placed = append(placed, PlacedNode{LayoutNode: node, AbsoluteX: x, AbsoluteY: y})
+	}
+	return placed
+}
+
+// Bounds returns the width and height of the area covered by all nodes
+func (l *Layout) Bounds() (float64, float64) {
+	var width, height float64
+	for _, node := range l.Placed() {
+		if node.AbsoluteX+node.Width > width {
+			width = node.AbsoluteX + node.Width
+		}
+		if node.AbsoluteY+node.Height > height {
+			height = node.AbsoluteY + node.Height
+		}
+	}
+	return width, height
+}
+
+// find returns the node for a resource ID
+func (l *Layout) find(resourceID string) (LayoutNode, bool) {
+	i, ok := l.index[resourceID]
+	if !ok {
+		return LayoutNode{}, false
+	}
+	return l.Nodes[i], true
+}
+
+// add appends a node, keeping the first node of a duplicate resource ID in the index
+func (l *Layout) add(node LayoutNode) {
+	if l.index == nil {
+		l.index = make(map[string]int)
+	}
+	if _, ok := l.index[node.ResourceID]; !ok {
+		l.index[node.ResourceID] = len(l.Nodes)
+	}
+	if node.ParentID != "" {
+		if l.children == nil {
+			l.children = make(map[string][]int)
+		}
+		l.children[node.ParentID] = append(l.children[node.ParentID], len(l.Nodes))
+	}
+	l.Nodes = append(l.Nodes, node)
+}
+
+// addVPC lays out a VPC container with its internet gateways, subnets and NAT gateways
+// Gateways go into the gateway lane, public subnets into the top row and private subnets into the
+// bottom row; arrangeVPC computes the positions.
+func (l *Layout) addVPC(
+	vpcInfo vpc.VPCInfo,
+	allSubnets []vpc.SubnetInfo,
+	allIGWs []vpc.InternetGatewayInfo,
+	allNGWs []vpc.NatGatewayInfo,
+	x, y float64,
+) {
+	// Separate public and private subnets for this VPC
+	var publicSubnets []vpc.SubnetInfo
+	var privateSubnets []vpc.SubnetInfo
+	for _, subnet := range allSubnets {
+		if subnet.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		if subnet.MapPublicIpOnLaunch {
+			publicSubnets = append(publicSubnets, subnet)
+		} else {
+			privateSubnets = append(privateSubnets, subnet)
+		}
+	}
+
+	l.add(LayoutNode{
+		Kind:       NodeVPC,
+		ResourceID: vpcInfo.VpcID,
+		Name:       getResourceName(vpcInfo.Tags, vpcInfo.VpcID),
+		Detail:     vpc.OrUnknown(vpcInfo.CidrBlock),
+		X:          x,
+		Y:          y,
+		Resource:   vpcInfo,
+	})
+
+	// Internet Gateways (gateway lane on the left)
+	for _, igw := range allIGWs {
+		if igw.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		l.add(LayoutNode{
+			Kind:       NodeInternetGateway,
+			ResourceID: igw.InternetGatewayID,
+			ParentID:   vpcInfo.VpcID,
+			Name:       getResourceName(igw.Tags, igw.InternetGatewayID),
+			Width:      78,
+			Height:     78,
+			Resource:   igw,
+		})
+	}
+
+	// Subnets, each with its NAT gateways
+	natGateways := make(map[string][]vpc.NatGatewayInfo)
+	for _, ngw := range allNGWs {
+		if ngw.VpcID == vpcInfo.VpcID {
+			natGateways[ngw.SubnetID] = append(natGateways[ngw.SubnetID], ngw)
+		}
+	}
+	for _, subnet := range append(publicSubnets, privateSubnets...) {
+		l.addSubnet(subnet, vpcInfo.VpcID)
+		for _, ngw := range natGateways[subnet.SubnetID] {
+			l.add(LayoutNode{
+				Kind:       NodeNATGateway,
+				ResourceID: ngw.NatGatewayID,
+				ParentID:   subnet.SubnetID,
+				Name:       getResourceName(ngw.Tags, ngw.NatGatewayID),
+				Width:      gridIconSize,
+				Height:     gridIconSize,
+				Resource:   ngw,
+			})
+		}
+	}
+
+	l.arrangeVPC(vpcInfo.VpcID)
+}
+
+// addSubnet adds a single subnet container; arrangeVPC positions and sizes it
+func (l *Layout) addSubnet(subnet vpc.SubnetInfo, parentID string) {
+	l.add(LayoutNode{
+		Kind:       NodeSubnet,
+		ResourceID: subnet.SubnetID,
+		ParentID:   parentID,
+		Name:       getResourceName(subnet.Tags, subnet.SubnetID),
+		Detail:     vpc.OrUnknown(subnet.CidrBlock),
+		Public:     subnet.MapPublicIpOnLaunch,
+		Width:      subnetWidth,
+		Height:     subnetMinHeight,
+		Resource:   subnet,
+	})
+}
+
+// arrangeVPC positions the contents of a VPC container and sizes the container to them
+// Gateways are stacked in a lane along the left edge, which is only as wide as its contents. Each
+// subnet places its icons in a grid below its label, wrapping to a new row when a row is full, and
+// grows to fit; each subnet row is as high as its highest subnet. Auto Scaling group bars are stacked
+// between the rows and directory bars below the private row, and the container grows to fit all of it.
+// Called again whenever nodes are added to the VPC, so later additions reflow the container.
+func (l *Layout) arrangeVPC(vpcID string) {
+	vpcIndex, ok := l.index[vpcID]
+	if !ok {
+		return
+	}
+
+	var lane, public, private, asgs, directories []int
+	for _, i := range l.children[vpcID] {
+		switch node := l.Nodes[i]; {
+		case laneKinds[node.Kind]:
+			lane = append(lane, i)
+		case node.Kind == NodeSubnet && node.Public:
+			public = append(public, i)
+		case node.Kind == NodeSubnet:
+			private = append(private, i)
+		case node.Kind == NodeASG:
+			asgs = append(asgs, i)
+		case node.Kind == NodeDirectory:
+			directories = append(directories, i)
+		}
+	}
+
+	// Bars span subnets, so they move with the subnets when a gateway lane first appears
+	subnetX := l.subnetsX(vpcID)
+	if subnets := append(public, private...); len(subnets) > 0 && l.Nodes[subnets[0]].X != 0 {
+		shift := subnetX - l.Nodes[subnets[0]].X
+		for _, i := range append(asgs, directories...) {
+			l.Nodes[i].X += shift
+		}
+	}
+
+	// Gateway lane
+	laneBottom := 0.0
+	for k, i := range lane {
+		l.Nodes[i].X = laneX
+		l.Nodes[i].Y = vpcHeaderHeight + float64(k)*laneSpacing
+		laneBottom = l.Nodes[i].Y + laneSpacing
+	}
+
+	// Subnet rows
+	publicHeight := l.arrangeSubnetRow(public, subnetX, vpcHeaderHeight)
+	asgBand := math.Max(rowGap, 6+float64(len(asgs))*(asgBarHeight+2))
+	privateY := vpcHeaderHeight + publicHeight + asgBand
+	privateHeight := l.arrangeSubnetRow(private, subnetX, privateY)
+	privateBottom := privateY + privateHeight
+
+	// Bars between and below the rows
+	for k, i := range asgs {
+		l.Nodes[i].Y = vpcHeaderHeight + publicHeight + 3 + float64(k)*(asgBarHeight+2)
+	}
+	for k, i := range directories {
+		l.Nodes[i].Y = privateBottom + 5 + float64(k)*directorySpacing
+	}
+
+	columns := math.Max(float64(len(public)), float64(len(private)))
+	vpcNode := &l.Nodes[vpcIndex]
+	vpcNode.Width = subnetX + columns*subnetSpacing + 100
+	vpcNode.Height = math.Max(vpcMinHeight, math.Max(privateBottom+5+float64(len(directories))*directorySpacing+5, laneBottom+20))
+}
+
+// subnetsX returns the X position of the first subnet of a VPC: right of the gateway lane, or at the
+// left edge if the VPC has no gateways
+func (l *Layout) subnetsX(vpcID string) float64 {
+	for _, i := range l.children[vpcID] {
+		if laneKinds[l.Nodes[i].Kind] {
+			return laneX + laneWidth
+		}
+	}
+	return 30
+}
+
+// arrangeSubnetRow positions a row of subnets and the icon grid inside each of them
+// Returns: Height of the row (the highest subnet, at least subnetMinHeight)
+func (l *Layout) arrangeSubnetRow(subnets []int, x, y float64) float64 {
+	rowHeight := subnetMinHeight
+	columns := math.Floor((subnetWidth - 10) / gridCellWidth)
+	for k, i := range subnets {
+		cells := 0
+		for _, child := range l.children[l.Nodes[i].ResourceID] {
+			node := &l.Nodes[child]
+			if !gridKinds[node.Kind] {
+				continue
+			}
+			column, row := math.Mod(float64(cells), columns), math.Floor(float64(cells)/columns)
+			node.X = 5 + column*gridCellWidth + (gridCellWidth-node.Width)/2
+			node.Y = subnetHeaderHeight + row*gridCellHeight
+			cells++
+		}
+		rows := math.Ceil(float64(cells) / columns)
+
+		subnet := &l.Nodes[i]
+		subnet.X = x + float64(k)*subnetSpacing
+		subnet.Y = y
+		subnet.Height = math.Max(subnetMinHeight, subnetHeaderHeight+rows*gridCellHeight)
+		rowHeight = math.Max(rowHeight, subnet.Height)
+	}
+	return rowHeight
+}
+
+// addTransitGateways lays out transit gateways in a column, each with its attachments stacked to its right
+// A transit gateway starts below the last attachment of the one before, so long stacks do not overlap.
+func (l *Layout) addTransitGateways(
+	transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo,
+	vpcs []vpc.VPCInfo,
+	x, y float64,
+) {
+	inDiagram := make(map[string]bool, len(vpcs))
+	for _, v := range vpcs {
+		inDiagram[v.VpcID] = true
+	}
+	attachments := make(map[string][]vpc.TransitGatewayAttachmentInfo)
+	for _, attachment := range tgwAttachments {
+		attachments[attachment.TransitGatewayID] = append(attachments[attachment.TransitGatewayID], attachment)
+	}
+
+	for _, tgw := range transitGateways {
+		l.add(LayoutNode{
+			Kind:       NodeTransitGateway,
+			ResourceID: tgw.TransitGatewayID,
+			Name:       getResourceName(tgw.Tags, tgw.TransitGatewayID),
+			Detail:     fmt.Sprintf("ASN: %d", tgw.AmazonSideAsn),
+			X:          x,
+			Y:          y,
+			Width:      78,
+			Height:     78,
+			Resource:   tgw,
+		})
+
+		// Attachments stacked beside their transit gateway
+		attachY := y
+		for _, attachment := range attachments[tgw.TransitGatewayID] {
+			l.add(LayoutNode{
+				Kind:       NodeTGWAttachment,
+				ResourceID: attachment.AttachmentID,
+				Name:       getResourceName(attachment.Tags, attachment.AttachmentID),
+				Detail:     vpc.OrUnknown(attachment.State),
+				X:          x + 200,
+				Y:          attachY,
+				Width:      78,
+				Height:     78,
+				Resource:   attachment,
+			})
+			l.Edges = append(l.Edges, LayoutEdge{From: tgw.TransitGatewayID, To: attachment.AttachmentID})
+
+			// Connect VPC attachments to the VPC container when it is in the diagram
+			if attachment.ResourceType == "vpc" && inDiagram[attachment.ResourceID] {
+				l.Edges = append(l.Edges, LayoutEdge{From: attachment.AttachmentID, To: attachment.ResourceID})
+			}
+			attachY += 150
+		}
+		y = math.Max(y+150, attachY) + 50
+	}
+}
+
+// AddCoreNetworks lays out Cloud WAN core networks as containers with one lane per segment
+// Attachments are stacked in the lane of their segment; VPC attachments are connected to the VPC container
+// coreNetworks: Scanned core networks
+// vpcs: VPCs in the diagram
+// x, y: Position of the first core network container
+func (l *Layout) AddCoreNetworks(coreNetworks []cloudwan.CoreNetworkInfo, vpcs []vpc.VPCInfo, x, y float64) {
+	inDiagram := make(map[string]bool)
+	for _, v := range vpcs {
+		inDiagram[v.VpcID] = true
+	}
+
+	for _, coreNetwork := range coreNetworks {
+		// Attachments per segment; attachments without a segment get their own lane
+		lanes := make(map[string][]cloudwan.AttachmentInfo)
+		var laneNames []string
+		for _, segment := range coreNetwork.Segments {
+			lanes[segment.Name] = nil
+			laneNames = append(laneNames, segment.Name)
+		}
+		for _, attachment := range coreNetwork.Attachments {
+			segment := attachment.SegmentName
+			if segment == "" {
+				segment = "(unassociated)"
+			}
+			if _, ok := lanes[segment]; !ok {
+				laneNames = append(laneNames, segment)
+			}
+			lanes[segment] = append(lanes[segment], attachment)
+		}
+
+		maxAttachments := 1
+		for _, attachments := range lanes {
+			if len(attachments) > maxAttachments {
+				maxAttachments = len(attachments)
+			}
+		}
+
+		width := 40.0 + float64(len(laneNames))*240.0
+		height := 120.0 + float64(maxAttachments)*130.0
+		l.add(LayoutNode{
+			Kind:       NodeCoreNetwork,
+			ResourceID: coreNetwork.CoreNetworkID,
+			Name:       getResourceName(coreNetwork.Tags, coreNetwork.CoreNetworkID),
+			Detail:     coreNetwork.State,
+			X:          x,
+			Y:          y,
+			Width:      width,
+			Height:     height,
+			Resource:   coreNetwork,
+		})
+
+		laneX := 20.0
+		for _, name := range laneNames {
+			laneID := coreNetwork.CoreNetworkID + "/" + name
+			segment := cloudwan.SegmentInfo{CoreNetworkID: coreNetwork.CoreNetworkID, Name: name, EdgeLocations: []string{}, SharedSegments: []string{}}
+			for _, s := range coreNetwork.Segments {
+				if s.Name == name {
+					segment = s
+				}
+			}
+			l.add(LayoutNode{
+				Kind:       NodeSegment,
+				ResourceID: laneID,
+				ParentID:   coreNetwork.CoreNetworkID,
+				Name:       name,
+				Detail:     fmt.Sprintf("%d attachment(s)", len(lanes[name])),
+				X:          laneX,
+				Y:          50,
+				Width:      220,
+				Height:     height - 70,
+				Resource:   segment,
+			})
+
+			attachY := 50.0
+			for _, attachment := range lanes[name] {
+				l.add(LayoutNode{
+					Kind:       NodeCWANAttachment,
+					ResourceID: attachment.AttachmentID,
+					ParentID:   laneID,
+					Name:       getResourceName(attachment.Tags, attachment.AttachmentID),
+					Detail:     attachment.ResourceID,
+					X:          71,
+					Y:          attachY,
+					Width:      78,
+					Height:     78,
+					Resource:   attachment,
+				})
+				if attachment.AttachmentType == "vpc" && inDiagram[attachment.ResourceID] {
+					l.Edges = append(l.Edges, LayoutEdge{From: attachment.AttachmentID, To: attachment.ResourceID})
+				}
+				attachY += 130
+			}
+			laneX += 240
+		}
+
+		y += height + 50
+	}
+}
+
+// AddVpnConnections lays out site-to-site VPN connections: virtual private gateways in the gateway lane of the
+// VPC they are attached to, customer gateways as on-premises icons in a column, and an edge per connection from
+// its transit gateway or virtual private gateway to its customer gateway
+// Deleted connections and connections whose AWS end is not in the layout are skipped; customer gateways are
+// only drawn for the connections that remain.
+// connections: VPN connections from the scan
+// customerGateways: Customer gateways from the scan, for their names and addresses
+// vpnGateways: Virtual private gateways from the scan
+// x, y: Position of the first customer gateway
+func (l *Layout) AddVpnConnections(connections []vpc.VpnConnectionInfo, customerGateways []vpc.CustomerGatewayInfo, vpnGateways []vpc.VpnGatewayInfo, x, y float64) {
+	var vpcIDs []string
+	for _, vgw := range vpnGateways {
+		for _, attachment := range vgw.VpcAttachments {
+			if attachment.State != "attached" {
+				continue
+			}
+			if _, ok := l.find(attachment.VpcID); !ok {
+				continue
+			}
+			l.add(LayoutNode{
+				Kind:       NodeVpnGateway,
+				ResourceID: vgw.VpnGatewayID,
+				ParentID:   attachment.VpcID,
+				Name:       getResourceName(vgw.Tags, vgw.VpnGatewayID),
+				Detail:     fmt.Sprintf("ASN: %d", vgw.AmazonSideAsn),
+				Width:      78,
+				Height:     78,
+				Resource:   vgw,
+			})
+			vpcIDs = append(vpcIDs, attachment.VpcID)
+		}
+	}
+	for _, vpcID := range vpcIDs {
+		l.arrangeVPC(vpcID)
+	}
+
+	gateways := make(map[string]vpc.CustomerGatewayInfo, len(customerGateways))
+	for _, cgw := range customerGateways {
+		gateways[cgw.CustomerGatewayID] = cgw
+	}
+	for _, conn := range connections {
+		awsEnd := conn.TransitGatewayID
+		if awsEnd == "" {
+			awsEnd = conn.VpnGatewayID
+		}
+		if _, ok := l.find(awsEnd); !ok || conn.State == "deleted" {
+			continue
+		}
+		if _, ok := l.find(conn.CustomerGatewayID); !ok {
+			// A customer gateway left out by -tag is still drawn, by its ID
+			cgw, ok := gateways[conn.CustomerGatewayID]
+			if !ok {
+				cgw = vpc.CustomerGatewayInfo{CustomerGatewayID: conn.CustomerGatewayID}
+			}
+			l.add(LayoutNode{
+				Kind:       NodeCustomerGateway,
+				ResourceID: conn.CustomerGatewayID,
+				Name:       getResourceName(cgw.Tags, cgw.CustomerGatewayID),
+				Detail:     cgw.IpAddress,
+				X:          x,
+				Y:          y,
+				Width:      78,
+				Height:     78,
+				Resource:   cgw,
+			})
+			y += 150
+		}
+		l.Edges = append(l.Edges, LayoutEdge{From: awsEnd, To: conn.CustomerGatewayID, Resource: conn})
+	}
+}
+
+// AddDirectories lays out each directory as a bar inside its VPC, spanning the subnets its network interfaces are in
+// Directories in VPCs that are not in the layout are skipped
+// directories: Directories from the directory scan
+func (l *Layout) AddDirectories(directories []directory.DirectoryInfo) {
+	arrange := make(map[string]bool) // VPCs that got bars
+	var vpcIDs []string
+	for _, d := range directories {
+		vpcNode, ok := l.find(d.VpcID)
+		if !ok {
+			continue
+		}
+
+		// Span the horizontal extent of the directory's subnets (both rows start at the same X)
+		left, right := 0.0, 0.0
+		for _, subnetID := range d.SubnetIDs {
+			subnet, ok := l.find(subnetID)
+			if !ok || subnet.ParentID != d.VpcID {
+				continue
+			}
+			if right == 0 || subnet.X < left {
+				left = subnet.X
+			}
+			if subnet.X+subnet.Width > right {
+				right = subnet.X + subnet.Width
+			}
+		}
+		if right == 0 {
+			left, right = l.subnetsX(d.VpcID), vpcNode.Width-20
+		}
+
+		l.add(LayoutNode{
+			Kind:       NodeDirectory,
+			ResourceID: d.DirectoryID,
+			ParentID:   d.VpcID,
+			Name:       d.Name,
+			Detail:     d.Type,
+			X:          left,
+			Width:      right - left,
+			Height:     directoryBarHeight,
+			Resource:   d,
+		})
+		if !arrange[d.VpcID] {
+			arrange[d.VpcID] = true
+			vpcIDs = append(vpcIDs, d.VpcID)
+		}
+	}
+	for _, vpcID := range vpcIDs {
+		l.arrangeVPC(vpcID)
+	}
+}
+
+// AddAutoScalingGroups lays out each Auto Scaling group as a bar between the subnet rows of every VPC it launches into,
+// spanning the subnets of its VPC zone identifier
+// Subnets that are not in the layout are ignored
+// groups: Auto Scaling groups from the ASG scan
+func (l *Layout) AddAutoScalingGroups(groups []asg.AutoScalingGroupInfo) {
+	arrange := make(map[string]bool) // VPCs that got bars
+	var arrangeIDs []string
+	for _, group := range groups {
+		// Horizontal extent of the group's subnets per VPC
+		type span struct{ left, right float64 }
+		spans := make(map[string]*span)
+		var vpcIDs []string
+		for _, subnetID := range group.SubnetIDs {
+			subnet, ok := l.find(subnetID)
+			if !ok || subnet.Kind != NodeSubnet {
+				continue
+			}
+			s, ok := spans[subnet.ParentID]
+			if !ok {
+				s = &span{left: subnet.X, right: subnet.X + subnet.Width}
+				spans[subnet.ParentID] = s
+				vpcIDs = append(vpcIDs, subnet.ParentID)
+			}
+			if subnet.X < s.left {
+				s.left = subnet.X
+			}
+			if subnet.X+subnet.Width > s.right {
+				s.right = subnet.X + subnet.Width
+			}
+		}
+
+		for _, vpcID := range vpcIDs {
+			s := spans[vpcID]
+			resourceID := group.AutoScalingGroupArn
+			if len(vpcIDs) > 1 {
+				resourceID += "/" + vpcID
+			}
+			l.add(LayoutNode{
+				Kind:       NodeASG,
+				ResourceID: resourceID,
+				ParentID:   vpcID,
+				Name:       group.AutoScalingGroupName,
+				Detail:     fmt.Sprintf("desired %d, current %d", group.DesiredCapacity, len(group.InstanceIDs)),
+				X:          s.left,
+				Width:      s.right - s.left,
+				Height:     asgBarHeight,
+				Resource:   group,
+			})
+			if !arrange[vpcID] {
+				arrange[vpcID] = true
+				arrangeIDs = append(arrangeIDs, vpcID)
+			}
+		}
+	}
+	for _, vpcID := range arrangeIDs {
+		l.arrangeVPC(vpcID)
+	}
+}
+
+// AddRouteAppliances lays out routed instances and interfaces in the icon grid of their subnets, after any NAT gateway
+// Appliances in subnets that are not in the layout are skipped
+// appliances: Routed instances and interfaces from the appliance scan
+func (l *Layout) AddRouteAppliances(appliances []vpc.RouteApplianceInfo) {
+	arrange := make(map[string]bool) // VPCs whose subnets got appliances
+	var vpcIDs []string
+	for _, appliance := range appliances {
+		subnet, ok := l.find(appliance.SubnetID)
+		if !ok {
+			continue
+		}
+		resourceID := appliance.InstanceID
+		if resourceID == "" {
+			resourceID = appliance.NetworkInterfaceID
+		}
+		detail := "routing appliance"
+		switch {
+		case appliance.IsNATInstance:
+			detail = "NAT instance"
+		case appliance.SourceDestCheck:
+			detail = "source/dest check enabled"
+		}
+
+		l.add(LayoutNode{
+			Kind:       NodeRouteAppliance,
+			ResourceID: resourceID,
+			ParentID:   appliance.SubnetID,
+			Name:       getResourceName(appliance.Tags, resourceID),
+			Detail:     detail,
+			Width:      gridIconSize,
+			Height:     gridIconSize,
+			Resource:   appliance,
+		})
+		if !arrange[subnet.ParentID] {
+			arrange[subnet.ParentID] = true
+			vpcIDs = append(vpcIDs, subnet.ParentID)
+		}
+	}
+	for _, vpcID := range vpcIDs {
+		l.arrangeVPC(vpcID)
+	}
+}
+
+// AddVpcEndpoints lays out VPC endpoints: interface and Gateway Load Balancer endpoints in the icon grid of
+// each of their subnets, gateway endpoints in the gateway lane of their VPC below its internet gateways
+// Endpoints whose subnets or VPC are not in the layout are skipped
+// endpoints: Endpoints from the endpoint scan
+func (l *Layout) AddVpcEndpoints(endpoints []vpc.VpcEndpointInfo) {
+	arrange := make(map[string]bool) // VPCs that got endpoints
+	var vpcIDs []string
+	added := func(vpcID string) {
+		if !arrange[vpcID] {
+			arrange[vpcID] = true
+			vpcIDs = append(vpcIDs, vpcID)
+		}
+	}
+	for _, endpoint := range endpoints {
+		node := LayoutNode{
+			ResourceID: endpoint.VpcEndpointID,
+			Name:       getResourceName(endpoint.Tags, endpoint.VpcEndpointID),
+			Detail:     analysis.EndpointServiceName(endpoint.ServiceName),
+			Width:      gridIconSize,
+			Height:     gridIconSize,
+			Resource:   endpoint,
+		}
+		if endpoint.EndpointType == vpc.EndpointTypeGateway {
+			if _, ok := l.find(endpoint.VpcID); !ok {
+				continue
+			}
+			node.Kind = NodeGatewayEndpoint
+			node.ParentID = endpoint.VpcID
+			node.Width, node.Height = 78, 78 // As large as the internet gateways of the lane
+			l.add(node)
+			added(endpoint.VpcID)
+			continue
+		}
+		for _, subnetID := range endpoint.SubnetIDs {
+			subnet, ok := l.find(subnetID)
+			if !ok {
+				continue
+			}
+			node.Kind = NodeVpcEndpoint
+			node.ParentID = subnetID
+			l.add(node)
+			added(subnet.ParentID)
+		}
+	}
+	for _, vpcID := range vpcIDs {
+		l.arrangeVPC(vpcID)
+	}
+}
@@ -0,0 +1,285 @@
+package diagram
+
+import (
+	"fmt"
+	"testing"
+
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/testgen"
+	"aws-documentor/modules/vpc"
+)
+
+// denseResources is a region whose VPCs are as crowded as the layout has to handle
+type denseResources struct {
+	RegionResources
+	directories []directory.DirectoryInfo  // Directories spanning private subnets
+	groups      []asg.AutoScalingGroupInfo // More Auto Scaling groups than the gap between the rows holds
+	appliances  []vpc.RouteApplianceInfo   // Routing appliances beside NAT gateways
+	endpoints   []vpc.VpcEndpointInfo      // Interface, Gateway Load Balancer and gateway endpoints
+	vpnGateways []vpc.VpnGatewayInfo       // Virtual private gateways of the VPCs
+	connections []vpc.VpnConnectionInfo    // VPN connections of the virtual private gateways
+	customers   []vpc.CustomerGatewayInfo  // Customer gateways of the VPN connections
+}
+
+// denseFixture builds a region with a crowded VPC, a VPC that only gets gateways after its bars are laid
+// out, and a transit gateway with an attachment for each VPC
+func denseFixture(region string) denseResources {
+	var d denseResources
+	d.Region = region
+	prefix := region + "-"
+
+	// vpc-dense: an internet gateway, five public and four private subnets, five NAT gateways in one subnet
+	dense := prefix + "vpc-dense"
+	d.VPCs = append(d.VPCs, vpc.VPCInfo{VpcID: dense, CidrBlock: "10.0.0.0/16"})
+	d.InternetGateways = append(d.InternetGateways, vpc.InternetGatewayInfo{InternetGatewayID: prefix + "igw-dense", VpcID: dense})
+	var private []string
+	for i := 0; i < 9; i++ {
+		subnet := vpc.SubnetInfo{SubnetID: fmt.Sprintf("%ssubnet-dense-%d", prefix, i), VpcID: dense,
+			CidrBlock: fmt.Sprintf("10.0.%d.0/24", i), MapPublicIpOnLaunch: i < 5}
+		d.Subnets = append(d.Subnets, subnet)
+		if !subnet.MapPublicIpOnLaunch {
+			private = append(private, subnet.SubnetID)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		d.NatGateways = append(d.NatGateways, vpc.NatGatewayInfo{NatGatewayID: fmt.Sprintf("%snat-%d", prefix, i),
+			VpcID: dense, SubnetID: prefix + "subnet-dense-0"})
+	}
+	for i := 0; i < 3; i++ {
+		d.appliances = append(d.appliances, vpc.RouteApplianceInfo{InstanceID: fmt.Sprintf("%si-appliance-%d", prefix, i),
+			VpcID: dense, SubnetID: prefix + "subnet-dense-0", IsNATInstance: i == 0})
+	}
+	d.endpoints = append(d.endpoints,
+		vpc.VpcEndpointInfo{VpcEndpointID: prefix + "vpce-all", VpcID: dense, EndpointType: "Interface",
+			ServiceName: "com.amazonaws." + region + ".ssm", SubnetIDs: private},
+		vpc.VpcEndpointInfo{VpcEndpointID: prefix + "vpce-gwlb", VpcID: dense, EndpointType: "GatewayLoadBalancer",
+			SubnetIDs: []string{prefix + "subnet-dense-1"}},
+		vpc.VpcEndpointInfo{VpcEndpointID: prefix + "vpce-s3", VpcID: dense, EndpointType: vpc.EndpointTypeGateway,
+			ServiceName: "com.amazonaws." + region + ".s3"},
+		vpc.VpcEndpointInfo{VpcEndpointID: prefix + "vpce-dynamodb", VpcID: dense, EndpointType: vpc.EndpointTypeGateway,
+			ServiceName: "com.amazonaws." + region + ".dynamodb"},
+	)
+	for i := 0; i < 6; i++ {
+		d.endpoints = append(d.endpoints, vpc.VpcEndpointInfo{VpcEndpointID: fmt.Sprintf("%svpce-%d", prefix, i), VpcID: dense,
+			EndpointType: "Interface", SubnetIDs: []string{private[0]}})
+	}
+	for i := 0; i < 4; i++ {
+		d.groups = append(d.groups, asg.AutoScalingGroupInfo{AutoScalingGroupName: fmt.Sprintf("web-%d", i),
+			AutoScalingGroupArn: fmt.Sprintf("%sasg-%d", prefix, i), SubnetIDs: private[i%2 : i%2+3]})
+	}
+	d.directories = append(d.directories,
+		directory.DirectoryInfo{DirectoryID: prefix + "d-corp", VpcID: dense, SubnetIDs: private[:2]},
+		directory.DirectoryInfo{DirectoryID: prefix + "d-connector", VpcID: dense, SubnetIDs: private[2:]},
+	)
+
+	// vpc-late: no internet gateway, so its gateway lane only appears with the gateway endpoint and the
+	// virtual private gateway, which are added after its Auto Scaling group and directory bars
+	late := prefix + "vpc-late"
+	d.VPCs = append(d.VPCs, vpc.VPCInfo{VpcID: late, CidrBlock: "10.1.0.0/16"})
+	for i := 0; i < 2; i++ {
+		d.Subnets = append(d.Subnets, vpc.SubnetInfo{SubnetID: fmt.Sprintf("%ssubnet-late-%d", prefix, i), VpcID: late,
+			CidrBlock: fmt.Sprintf("10.1.%d.0/24", i)})
+	}
+	lateSubnets := []string{prefix + "subnet-late-0", prefix + "subnet-late-1"}
+	d.groups = append(d.groups, asg.AutoScalingGroupInfo{AutoScalingGroupName: "batch", AutoScalingGroupArn: prefix + "asg-late", SubnetIDs: lateSubnets})
+	d.directories = append(d.directories, directory.DirectoryInfo{DirectoryID: prefix + "d-late", VpcID: late, SubnetIDs: lateSubnets})
+	d.endpoints = append(d.endpoints, vpc.VpcEndpointInfo{VpcEndpointID: prefix + "vpce-late-s3", VpcID: late, EndpointType: vpc.EndpointTypeGateway})
+	d.vpnGateways = append(d.vpnGateways, vpc.VpnGatewayInfo{VpnGatewayID: prefix + "vgw-late",
+		VpcAttachments: []vpc.VpnGatewayAttachment{{VpcID: late, State: "attached"}}})
+	d.customers = append(d.customers, vpc.CustomerGatewayInfo{CustomerGatewayID: prefix + "cgw-office"})
+	d.connections = append(d.connections, vpc.VpnConnectionInfo{VpnConnectionID: prefix + "vpn-office",
+		VpnGatewayID: prefix + "vgw-late", CustomerGatewayID: prefix + "cgw-office"})
+
+	// A transit gateway with an attachment per VPC
+	tgw := prefix + "tgw"
+	d.TransitGateways = append(d.TransitGateways, vpc.TransitGatewayInfo{TransitGatewayID: tgw})
+	for _, v := range d.VPCs {
+		d.TGWAttachments = append(d.TGWAttachments, vpc.TransitGatewayAttachmentInfo{AttachmentID: "tgw-attach-" + v.VpcID,
+			TransitGatewayID: tgw, ResourceType: "vpc", ResourceID: v.VpcID})
+	}
+	return d
+}
+
+// addAll adds the resources beyond the overview layout in the order GenerateVPCDiagram adds them
+func (d denseResources) addAll(layout *Layout) {
+	layout.AddDirectories(d.directories)
+	layout.AddAutoScalingGroups(d.groups)
+	layout.AddRouteAppliances(d.appliances)
+	layout.AddVpcEndpoints(d.endpoints)
+	width, _ := layout.Bounds()
+	layout.AddVpnConnections(d.connections, d.customers, d.vpnGateways, width+100, 50)
+}
+
+// overlaps reports whether two placed nodes share any area
+func overlaps(a, b PlacedNode) bool {
+	return a.AbsoluteX < b.AbsoluteX+b.Width && b.AbsoluteX < a.AbsoluteX+a.Width &&
+		a.AbsoluteY < b.AbsoluteY+b.Height && b.AbsoluteY < a.AbsoluteY+a.Height
+}
+
+// checkLayout fails the test if siblings overlap, a node leaves its container or the bounds miss a node
+func checkLayout(t *testing.T, layout *Layout) {
+	t.Helper()
+	placed := layout.Placed()
+	if len(placed) != len(layout.Nodes) {
+		t.Fatalf("Placed() returned %d nodes, want %d", len(placed), len(layout.Nodes))
+	}
+
+	// The same endpoint is placed once in each of its subnets, so nodes are told apart by parent too
+	byID := make(map[string]PlacedNode)
+	siblings := make(map[string][]PlacedNode)
+	for _, node := range placed {
+		if _, ok := byID[node.ResourceID]; !ok {
+			byID[node.ResourceID] = node
+		}
+		siblings[node.ParentID] = append(siblings[node.ParentID], node)
+	}
+
+	for parentID, nodes := range siblings {
+		for i := range nodes {
+			for _, other := range nodes[i+1:] {
+				if overlaps(nodes[i], other) {
+					t.Errorf("in %q, %s %s at (%.0f,%.0f %.0fx%.0f) overlaps %s %s at (%.0f,%.0f %.0fx%.0f)", parentID,
+						nodes[i].Kind, nodes[i].ResourceID, nodes[i].AbsoluteX, nodes[i].AbsoluteY, nodes[i].Width, nodes[i].Height,
+						other.Kind, other.ResourceID, other.AbsoluteX, other.AbsoluteY, other.Width, other.Height)
+				}
+			}
+		}
+	}
+
+	width, height := layout.Bounds()
+	for _, node := range placed {
+		if node.Width <= 0 || node.Height <= 0 {
+			t.Errorf("%s %s is %.0fx%.0f, want a positive size", node.Kind, node.ResourceID, node.Width, node.Height)
+		}
+		if node.AbsoluteX < 0 || node.AbsoluteY < 0 || node.AbsoluteX+node.Width > width || node.AbsoluteY+node.Height > height {
+			t.Errorf("%s %s lies outside the bounds %.0fx%.0f", node.Kind, node.ResourceID, width, height)
+		}
+		if node.ParentID == "" {
+			continue
+		}
+		parent, ok := byID[node.ParentID]
+		if !ok {
+			t.Errorf("%s %s is in %q, which is not in the layout", node.Kind, node.ResourceID, node.ParentID)
+			continue
+		}
+		if node.X < 0 || node.Y < 0 || node.X+node.Width > parent.Width || node.Y+node.Height > parent.Height {
+			t.Errorf("%s %s at (%.0f,%.0f %.0fx%.0f) leaves %s %s (%.0fx%.0f)", node.Kind, node.ResourceID,
+				node.X, node.Y, node.Width, node.Height, parent.Kind, parent.ResourceID, parent.Width, parent.Height)
+		}
+	}
+}
+
+// TestLayoutNoOverlap checks the computed layout of dense fixtures: no two nodes in the same container overlap,
+// every node stays inside its container and the bounds cover every node
+func TestLayoutNoOverlap(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	tests := []struct {
+		name   string
+		layout func() *Layout
+	}{
+		{name: "generated environment", layout: func() *Layout {
+			return ComputeLayout(env.VPCs, env.Subnets, env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+		}},
+		{name: "dense VPCs", layout: func() *Layout {
+			d := denseFixture("eu-west-1")
+			layout := ComputeLayout(d.VPCs, d.Subnets, d.InternetGateways, d.NatGateways, d.TransitGateways, d.TGWAttachments)
+			d.addAll(layout)
+			return layout
+		}},
+		{name: "dense regions", layout: func() *Layout {
+			var regions []RegionResources
+			for _, region := range []string{"eu-west-1", "us-east-1", "ap-southeast-2"} {
+				regions = append(regions, denseFixture(region).RegionResources)
+			}
+			return ComputeRegionsLayout(regions)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLayout(t, tt.layout())
+		})
+	}
+}
+
+// TestSubnetGridWraps checks that a subnet with more icons than a grid row holds wraps them and grows to fit
+func TestSubnetGridWraps(t *testing.T) {
+	d := denseFixture("eu-west-1")
+	layout := ComputeLayout(d.VPCs, d.Subnets, d.InternetGateways, d.NatGateways, d.TransitGateways, d.TGWAttachments)
+	d.addAll(layout)
+
+	// Five NAT gateways and three appliances in two columns are four rows
+	subnet, _ := layout.find("eu-west-1-subnet-dense-0")
+	if want := subnetHeaderHeight + 4*gridCellHeight; subnet.Height != want {
+		t.Errorf("subnet with 8 icons is %.0f high, want %.0f", subnet.Height, want)
+	}
+	// The rest of the public row is as high as its highest subnet
+	for _, node := range layout.Placed() {
+		if node.Kind == NodeSubnet && node.ParentID == "eu-west-1-vpc-dense" && node.Public && node.Y != subnet.Y {
+			t.Errorf("public subnet %s is at Y %.0f, want the row at %.0f", node.ResourceID, node.Y, subnet.Y)
+		}
+	}
+
+	// Auto Scaling group and directory bars follow the subnets of a VPC whose gateway lane appears later
+	for _, id := range []string{"eu-west-1-asg-late", "eu-west-1-d-late"} {
+		bar, _ := layout.find(id)
+		first, _ := layout.find("eu-west-1-subnet-late-0")
+		if bar.X != first.X {
+			t.Errorf("%s starts at X %.0f, want the first subnet at %.0f", id, bar.X, first.X)
+		}
+	}
+}
+
+// TestASGBarsSpanSubnets checks that an Auto Scaling group is drawn once per VPC across the subnets it
+// launches into, ignoring subnets that are not in the layout
+func TestASGBarsSpanSubnets(t *testing.T) {
+	d := denseFixture("eu-west-1")
+	layout := ComputeLayout(d.VPCs, d.Subnets, d.InternetGateways, d.NatGateways, d.TransitGateways, d.TGWAttachments)
+	layout.AddAutoScalingGroups([]asg.AutoScalingGroupInfo{
+		{AutoScalingGroupName: "web", AutoScalingGroupArn: "asg-web", DesiredCapacity: 4, InstanceIDs: []string{"i-1", "i-2", "i-3"},
+			SubnetIDs: []string{"eu-west-1-subnet-dense-7", "subnet-0deleted", "eu-west-1-subnet-dense-5"}},
+		{AutoScalingGroupName: "spread", AutoScalingGroupArn: "asg-spread",
+			SubnetIDs: []string{"eu-west-1-subnet-dense-6", "eu-west-1-subnet-late-1"}},
+		{AutoScalingGroupName: "orphan", AutoScalingGroupArn: "asg-orphan", SubnetIDs: []string{"subnet-0deleted"}},
+	})
+	checkLayout(t, layout)
+
+	span := func(subnetIDs ...string) (left, right float64) {
+		for i, id := range subnetIDs {
+			subnet, _ := layout.find(id)
+			if i == 0 || subnet.X < left {
+				left = subnet.X
+			}
+			if i == 0 || subnet.X+subnet.Width > right {
+				right = subnet.X + subnet.Width
+			}
+		}
+		return left, right
+	}
+	tests := []struct {
+		resourceID string
+		parentID   string
+		subnetIDs  []string
+		detail     string
+	}{
+		{"asg-web", "eu-west-1-vpc-dense", []string{"eu-west-1-subnet-dense-5", "eu-west-1-subnet-dense-7"}, "desired 4, current 3"},
+		{"asg-spread/eu-west-1-vpc-dense", "eu-west-1-vpc-dense", []string{"eu-west-1-subnet-dense-6"}, "desired 0, current 0"},
+		{"asg-spread/eu-west-1-vpc-late", "eu-west-1-vpc-late", []string{"eu-west-1-subnet-late-1"}, "desired 0, current 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resourceID, func(t *testing.T) {
+			bar, ok := layout.find(tt.resourceID)
+			if !ok {
+				t.Fatalf("no bar for %s", tt.resourceID)
+			}
+			left, right := span(tt.subnetIDs...)
+			if bar.Kind != NodeASG || bar.ParentID != tt.parentID || bar.X != left || bar.X+bar.Width != right || bar.Detail != tt.detail {
+				t.Errorf("bar = %s in %s from %.0f to %.0f (%s), want %s from %.0f to %.0f (%s)", bar.Kind, bar.ParentID,
+					bar.X, bar.X+bar.Width, bar.Detail, tt.parentID, left, right, tt.detail)
+			}
+		})
+	}
+	if bar, ok := layout.find("asg-orphan"); ok {
+		t.Errorf("group without a subnet in the layout is drawn: %+v", bar)
+	}
+}
@@ -0,0 +1,183 @@
+package diagram
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/vpc"
+)
+
+// Custom attributes of resource cells, in the order they are written
+const (
+	AttrResourceID   = "resource_id"   // ID of the resource (name for Auto Scaling groups and segments)
+	AttrResourceType = "resource_type" // Layout node kind of the resource (vpc, subnet, nat_gateway, ...), route_table, security_group and network_acl in detail diagrams, or vpc_peering and vpn_connection for edges
+	AttrVpcID        = "vpc_id"        // VPC the resource is in
+	AttrRegion       = "region"        // Region of the scan, or of the region container in multi-region diagrams
+	AttrAccount      = "account"       // Account that owns the resource, or the scanned account
+	AttrCIDR         = "cidr"          // CIDR blocks of a VPC or subnet, comma-separated
+	AttrState        = "state"         // State of the resource as the API reports it
+	AttrManagedBy    = "managed_by"    // Tool or controller that created the resource
+)
+
+// Resource types of the detail diagram panels and overview edges, which are not layout nodes
+const (
+	resourceRouteTable    = "route_table"
+	resourceSecurityGroup = "security_group"
+	resourceNetworkACL    = "network_acl"
+	resourceVpcPeering    = "vpc_peering"    // Edge between the VPCs of a peering connection in the overview diagram
+	resourceVpnConnection = "vpn_connection" // Edge between the AWS end and the customer gateway of a VPN connection
+)
+
+// resourceData returns the custom attributes of the cell of a resource
+// Attributes without a value are left out, except the resource ID and type.
+// resourceType: Layout node kind or panel resource type
+// resourceID: ID of the resource
+// vpcID: VPC containing the resource (empty for top-level resources)
+// resource: The resource, for its CIDR blocks, state and owner
+func (dg *DiagramGenerator) resourceData(resourceType, resourceID, vpcID string, resource interface{}) []xml.Attr {
+	var cidr, state, owner string
+	switch r := resource.(type) {
+	case vpc.VPCInfo:
+		vpcID, state = r.VpcID, r.State
+		blocks := []string{}
+		if r.CidrBlock != "" {
+			blocks = append(blocks, r.CidrBlock)
+		}
+		for _, block := range r.AssociateCidrBlocks {
+			if block != r.CidrBlock {
+				blocks = append(blocks, block)
+			}
+		}
+		cidr = strings.Join(append(blocks, r.Ipv6CidrBlocks...), ",")
+	case vpc.SubnetInfo:
+		vpcID, cidr, state = r.VpcID, r.CidrBlock, r.State
+	case vpc.InternetGatewayInfo:
+		state = r.State
+	case vpc.NatGatewayInfo:
+		vpcID, state = r.VpcID, r.State
+	case vpc.RouteApplianceInfo:
+		vpcID, state = r.VpcID, r.State
+	case vpc.VpcEndpointInfo:
+		vpcID, state, owner = r.VpcID, r.State, r.OwnerID
+	case vpc.TransitGatewayInfo:
+		state, owner = r.State, r.OwnerID
+	case vpc.TransitGatewayAttachmentInfo:
+		state = r.State
+		if r.ResourceType == "vpc" {
+			vpcID = r.ResourceID
+		}
+	case vpc.RouteTableInfo:
+		vpcID = r.VpcID
+	case vpc.SecurityGroupInfo:
+		vpcID, owner = r.VpcID, r.OwnerID
+	case vpc.NetworkACLInfo:
+		vpcID, owner = r.VpcID, r.OwnerID
+	case vpc.VpcPeeringConnectionInfo:
+		state, owner = r.Status, r.RequesterOwnerID
+	case vpc.VpnGatewayInfo:
+		state = r.State
+	case vpc.CustomerGatewayInfo:
+		state = r.State
+	case vpc.VpnConnectionInfo:
+		state = r.State
+	case asg.AutoScalingGroupInfo:
+	case directory.DirectoryInfo:
+		vpcID, state = r.VpcID, r.Stage
+	case cloudwan.CoreNetworkInfo:
+		state, owner = r.State, r.OwnerAccountID
+	case cloudwan.SegmentInfo:
+	case cloudwan.AttachmentInfo:
+		state, owner = r.State, r.OwnerAccountID
+	}
+	if owner == "" {
+		owner = dg.accountID
+	}
+
+	data := []xml.Attr{
+		{Name: xml.Name{Local: AttrResourceID}, Value: resourceID},
+		{Name: xml.Name{Local: AttrResourceType}, Value: resourceType},
+	}
+	for _, attr := range []xml.Attr{
+		{Name: xml.Name{Local: AttrVpcID}, Value: vpcID},
+		{Name: xml.Name{Local: AttrRegion}, Value: dg.region},
+		{Name: xml.Name{Local: AttrAccount}, Value: owner},
+		{Name: xml.Name{Local: AttrCIDR}, Value: cidr},
+		{Name: xml.Name{Local: AttrState}, Value: state},
+		{Name: xml.Name{Local: AttrManagedBy}, Value: managerOf(resource)},
+	} {
+		if attr.Value != "" {
+			data = append(data, attr)
+		}
+	}
+	return data
+}
+
+// withRegion sets the region attribute of a cell, for cells of a region other than the one of the scan context
+func withRegion(data []xml.Attr, region string) []xml.Attr {
+	for i, attr := range data {
+		if attr.Name.Local == AttrRegion {
+			data[i].Value = region
+			return data
+		}
+	}
+	// Attributes keep their order, so the region goes right after the VPC or, without one, the resource type
+	at := 2
+	if len(data) > at && data[at].Name.Local == AttrVpcID {
+		at++
+	}
+	data = append(data[:at], append([]xml.Attr{{Name: xml.Name{Local: AttrRegion}, Value: region}}, data[at:]...)...)
+	return data
+}
+
+// managerOf returns the managed_by label of a VPC resource, or "" for other resources
+func managerOf(resource interface{}) string {
+	switch r := resource.(type) {
+	case vpc.VPCInfo:
+		return r.ManagedBy
+	case vpc.SubnetInfo:
+		return r.ManagedBy
+	case vpc.RouteTableInfo:
+		return r.ManagedBy
+	case vpc.SecurityGroupInfo:
+		return r.ManagedBy
+	case vpc.NetworkACLInfo:
+		return r.ManagedBy
+	case vpc.InternetGatewayInfo:
+		return r.ManagedBy
+	case vpc.NatGatewayInfo:
+		return r.ManagedBy
+	case vpc.TransitGatewayInfo:
+		return r.ManagedBy
+	case vpc.TransitGatewayAttachmentInfo:
+		return r.ManagedBy
+	case vpc.VpnGatewayInfo:
+		return r.ManagedBy
+	case vpc.CustomerGatewayInfo:
+		return r.ManagedBy
+	case vpc.VpnConnectionInfo:
+		return r.ManagedBy
+	}
+	return ""
+}
+
+// dimManaged mutes the cell of a resource whose manager was passed to SetDimManaged
+func (dg *DiagramGenerator) dimManaged(cell *Cell, resource interface{}) {
+	if manager := managerOf(resource); manager != "" && dg.dimmed[manager] {
+		cell.Style += "opacity=40;textOpacity=50;"
+	}
+}
+
+// finishCells applies the output options to generated cells
+// With plain cells, data and tooltips are dropped, so every cell is written as a bare mxCell.
+func (dg *DiagramGenerator) finishCells(cells []Cell) []Cell {
+	if !dg.plainCells {
+		return cells
+	}
+	for i := range cells {
+		cells[i].Data, cells[i].Tooltip = nil, ""
+	}
+	return cells
+}
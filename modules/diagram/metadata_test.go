@@ -0,0 +1,259 @@
+package diagram
+
+import (
+	"encoding/xml"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// attrs builds resource attributes from name and value pairs
+func attrs(pairs ...string) []xml.Attr {
+	data := []xml.Attr{}
+	for i := 0; i < len(pairs); i += 2 {
+		data = append(data, xml.Attr{Name: xml.Name{Local: pairs[i]}, Value: pairs[i+1]})
+	}
+	return data
+}
+
+// TestCellDataRoundTrip checks that a cell with resource attributes is written as an object carrying its ID,
+// label and attributes around an mxCell without them, and reads back into the same cell
+func TestCellDataRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		cell      Cell
+		wantXML   string
+		wantLabel bool // Whether the written element has a label attribute
+	}{
+		{name: "data", cell: Cell{ID: "overview-3", Value: "prod (10.0.0.0/16)", Style: "rounded=1;", Parent: "1", Vertex: "1",
+			Geometry: &Geometry{Width: 200, Height: 100, As: "geometry"},
+			Data:     attrs(AttrResourceID, "vpc-0a1", AttrResourceType, NodeVPC, AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333", AttrCIDR, "10.0.0.0/16,2001:db8::/56", AttrState, "available")},
+			wantXML: `<object id="overview-3" label="prod (10.0.0.0/16)" resource_id="vpc-0a1" resource_type="vpc" vpc_id="vpc-0a1" region="eu-west-1" account="111122223333" cidr="10.0.0.0/16,2001:db8::/56" state="available">` +
+				`<mxCell style="rounded=1;" parent="1" vertex="1"><mxGeometry width="200" height="100" as="geometry"></mxGeometry></mxCell></object>`,
+			wantLabel: true},
+		{name: "data without label", cell: Cell{ID: "overview-4", Parent: "1", Vertex: "1", Geometry: &Geometry{Width: 10, Height: 10, As: "geometry"},
+			Data: attrs(AttrResourceID, "nat-0a1", AttrResourceType, NodeNATGateway)},
+			wantXML: `<object id="overview-4" label="" resource_id="nat-0a1" resource_type="nat_gateway">` +
+				`<mxCell parent="1" vertex="1"><mxGeometry width="10" height="10" as="geometry"></mxGeometry></mxCell></object>`,
+			wantLabel: true},
+		{name: "tooltip and data", cell: Cell{ID: "detail-9", Value: "rtb-0a1…", Tooltip: "rtb-0a1 (main)", Parent: "1", Vertex: "1",
+			Geometry: &Geometry{Width: 10, Height: 10, As: "geometry"}, Data: attrs(AttrResourceID, "rtb-0a1", AttrResourceType, resourceRouteTable)},
+			wantXML: `<object id="detail-9" label="rtb-0a1…" tooltip="rtb-0a1 (main)" resource_id="rtb-0a1" resource_type="route_table">` +
+				`<mxCell parent="1" vertex="1"><mxGeometry width="10" height="10" as="geometry"></mxGeometry></mxCell></object>`,
+			wantLabel: true},
+		{name: "plain", cell: Cell{ID: "e1", Parent: "1", Edge: "1", Source: "a", Target: "b"},
+			wantXML: `<mxCell id="e1" parent="1" edge="1" source="a" target="b"></mxCell>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written, err := xml.Marshal(Root{Cells: []Cell{tt.cell}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "<Root>" + tt.wantXML + "</Root>"; string(written) != want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", written, want)
+			}
+
+			var root Root
+			if err := xml.Unmarshal(written, &root); err != nil {
+				t.Fatal(err)
+			}
+			if len(root.Cells) != 1 {
+				t.Fatalf("read %d cells, want 1", len(root.Cells))
+			}
+			read := root.Cells[0]
+			if read.hasLabel != tt.wantLabel || read.innerID != "" {
+				t.Errorf("read label attribute %v and inner ID %q, want label attribute %v and no inner ID", read.hasLabel, read.innerID, tt.wantLabel)
+			}
+			read.wrapper, read.hasLabel = "", false
+			if !reflect.DeepEqual(read, tt.cell) {
+				t.Errorf("read %+v\nwant %+v", read, tt.cell)
+			}
+		})
+	}
+}
+
+// TestUserObjectData checks that the attributes of a UserObject, as draw.io writes after Edit Data, are read
+func TestUserObjectData(t *testing.T) {
+	doc := drawioDocument(rootCell, layerCell, `<UserObject label="web" id="a" resource_id="subnet-0a1" owner="network-team">`+
+		`<mxCell vertex="1" parent="1"><mxGeometry width="100" height="50" as="geometry"/></mxCell></UserObject>`)
+	if err := Validate(doc); err != nil {
+		t.Fatal(err)
+	}
+	var drawio DrawIO
+	if err := xml.Unmarshal([]byte(doc), &drawio); err != nil {
+		t.Fatal(err)
+	}
+	cell := drawio.Diagram.MxGraphModel.Root.Cells[2]
+	if want := attrs(AttrResourceID, "subnet-0a1", "owner", "network-team"); cell.ID != "a" || cell.Value != "web" || !reflect.DeepEqual(cell.Data, want) {
+		t.Errorf("cell %q (%q) data %+v, want a (web) with %+v", cell.ID, cell.Value, cell.Data, want)
+	}
+}
+
+// TestResourceData covers the attributes of each kind of resource: VPC CIDR blocks in order without duplicates,
+// owners overriding the scanned account, and attributes without a value left out
+func TestResourceData(t *testing.T) {
+	dg := NewDiagramGenerator()
+	dg.SetScanContext("eu-west-1", "111122223333")
+	tests := []struct {
+		name         string
+		resourceType string
+		resourceID   string
+		vpcID        string
+		resource     interface{}
+		want         []xml.Attr
+	}{
+		{name: "VPC", resourceType: NodeVPC, resourceID: "vpc-0a1", resource: vpc.VPCInfo{VpcID: "vpc-0a1", State: "available", CidrBlock: "10.0.0.0/16",
+			AssociateCidrBlocks: []string{"10.0.0.0/16", "100.64.0.0/16"}, Ipv6CidrBlocks: []string{"2001:db8::/56"}, ManagedBy: "terraform"},
+			want: attrs(AttrResourceID, "vpc-0a1", AttrResourceType, "vpc", AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333",
+				AttrCIDR, "10.0.0.0/16,100.64.0.0/16,2001:db8::/56", AttrState, "available", AttrManagedBy, "terraform")},
+		{name: "subnet", resourceType: NodeSubnet, resourceID: "subnet-0a1", resource: vpc.SubnetInfo{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", State: "available"},
+			want: attrs(AttrResourceID, "subnet-0a1", AttrResourceType, "subnet", AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333",
+				AttrCIDR, "10.0.1.0/24", AttrState, "available")},
+		{name: "internet gateway inherits the VPC", resourceType: NodeInternetGateway, resourceID: "igw-0a1", vpcID: "vpc-0a1",
+			resource: vpc.InternetGatewayInfo{InternetGatewayID: "igw-0a1", State: "attached"},
+			want:     attrs(AttrResourceID, "igw-0a1", AttrResourceType, "internet_gateway", AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333", AttrState, "attached")},
+		{name: "shared transit gateway", resourceType: NodeTransitGateway, resourceID: "tgw-0a1", resource: vpc.TransitGatewayInfo{TransitGatewayID: "tgw-0a1", State: "available", OwnerID: "444455556666"},
+			want: attrs(AttrResourceID, "tgw-0a1", AttrResourceType, "transit_gateway", AttrRegion, "eu-west-1", AttrAccount, "444455556666", AttrState, "available")},
+		{name: "VPC attachment", resourceType: NodeTGWAttachment, resourceID: "tgw-attach-0a1",
+			resource: vpc.TransitGatewayAttachmentInfo{AttachmentID: "tgw-attach-0a1", ResourceType: "vpc", ResourceID: "vpc-0b2", State: "available"},
+			want:     attrs(AttrResourceID, "tgw-attach-0a1", AttrResourceType, "tgw_attachment", AttrVpcID, "vpc-0b2", AttrRegion, "eu-west-1", AttrAccount, "111122223333", AttrState, "available")},
+		{name: "VPN attachment", resourceType: NodeTGWAttachment, resourceID: "tgw-attach-0vpn",
+			resource: vpc.TransitGatewayAttachmentInfo{AttachmentID: "tgw-attach-0vpn", ResourceType: "vpn", ResourceID: "vpn-0a1"},
+			want:     attrs(AttrResourceID, "tgw-attach-0vpn", AttrResourceType, "tgw_attachment", AttrRegion, "eu-west-1", AttrAccount, "111122223333")},
+		{name: "peering edge", resourceType: resourceVpcPeering, resourceID: "pcx-0a1",
+			resource: vpc.VpcPeeringConnectionInfo{VpcPeeringConnectionID: "pcx-0a1", Status: "active", RequesterOwnerID: "444455556666"},
+			want:     attrs(AttrResourceID, "pcx-0a1", AttrResourceType, "vpc_peering", AttrRegion, "eu-west-1", AttrAccount, "444455556666", AttrState, "active")},
+		{name: "unknown resource", resourceType: "segment", resourceID: "prod", resource: nil,
+			want: attrs(AttrResourceID, "prod", AttrResourceType, "segment", AttrRegion, "eu-west-1", AttrAccount, "111122223333")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dg.resourceData(tt.resourceType, tt.resourceID, tt.vpcID, tt.resource); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resourceData() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWithRegion checks that the region of a cell is replaced, or inserted in attribute order when the scan had none
+func TestWithRegion(t *testing.T) {
+	tests := []struct {
+		name string
+		data []xml.Attr
+		want []xml.Attr
+	}{
+		{name: "replaced", data: attrs(AttrResourceID, "vpc-0a1", AttrResourceType, "vpc", AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1"),
+			want: attrs(AttrResourceID, "vpc-0a1", AttrResourceType, "vpc", AttrVpcID, "vpc-0a1", AttrRegion, "us-east-1")},
+		{name: "after the VPC", data: attrs(AttrResourceID, "vpc-0a1", AttrResourceType, "vpc", AttrVpcID, "vpc-0a1", AttrState, "available"),
+			want: attrs(AttrResourceID, "vpc-0a1", AttrResourceType, "vpc", AttrVpcID, "vpc-0a1", AttrRegion, "us-east-1", AttrState, "available")},
+		{name: "after the type", data: attrs(AttrResourceID, "tgw-0a1", AttrResourceType, "transit_gateway", AttrAccount, "111122223333"),
+			want: attrs(AttrResourceID, "tgw-0a1", AttrResourceType, "transit_gateway", AttrRegion, "us-east-1", AttrAccount, "111122223333")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withRegion(tt.data, "us-east-1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withRegion() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGeneratedCellData checks that the cells of an overview diagram carry their resource attributes, that
+// resources inside a VPC inherit its ID, and that plain cells are written without object elements
+func TestGeneratedCellData(t *testing.T) {
+	vpcs := []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", State: "available"}}
+	subnets := []vpc.SubnetInfo{{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1a", State: "available"}}
+	igws := []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1", State: "attached"}}
+	nats := []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", VpcID: "vpc-0a1", SubnetID: "subnet-0a1", State: "available"}}
+
+	for _, plain := range []bool{false, true} {
+		dg := NewDiagramGenerator()
+		dg.SetScanContext("eu-west-1", "111122223333")
+		dg.SetPlainCells(plain)
+		doc, err := dg.GenerateVPCDiagram(vpcs, subnets, nil, nil, igws, nats, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain {
+			if strings.Contains(doc, "<object") || strings.Contains(doc, AttrResourceID) {
+				t.Errorf("plain diagram has object elements or resource attributes:\n%s", doc)
+			}
+			continue
+		}
+
+		var drawio DrawIO
+		if err := xml.Unmarshal([]byte(doc), &drawio); err != nil {
+			t.Fatal(err)
+		}
+		data := make(map[string][]xml.Attr)
+		for _, cell := range drawio.Diagram.MxGraphModel.Root.Cells {
+			if len(cell.Data) > 0 {
+				if cell.wrapper != "object" || !cell.hasLabel || cell.ID == "" {
+					t.Errorf("cell %q with data is written as %q with label attribute %v", cell.ID, cell.wrapper, cell.hasLabel)
+				}
+				data[cell.Data[0].Value] = cell.Data
+			}
+		}
+		want := map[string][]xml.Attr{
+			"vpc-0a1": attrs(AttrResourceID, "vpc-0a1", AttrResourceType, NodeVPC, AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333",
+				AttrCIDR, "10.0.0.0/16", AttrState, "available"),
+			"subnet-0a1": attrs(AttrResourceID, "subnet-0a1", AttrResourceType, NodeSubnet, AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333",
+				AttrCIDR, "10.0.1.0/24", AttrState, "available"),
+			"igw-0a1": attrs(AttrResourceID, "igw-0a1", AttrResourceType, NodeInternetGateway, AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333",
+				AttrState, "attached"),
+			"nat-0a1": attrs(AttrResourceID, "nat-0a1", AttrResourceType, NodeNATGateway, AttrVpcID, "vpc-0a1", AttrRegion, "eu-west-1", AttrAccount, "111122223333",
+				AttrState, "available"),
+		}
+		if !reflect.DeepEqual(data, want) {
+			t.Errorf("cell data =\n%+v\nwant\n%+v", data, want)
+		}
+	}
+}
+
+// TestDimManaged checks that only the cells of resources whose manager was passed to SetDimManaged are muted
+func TestDimManaged(t *testing.T) {
+	vpcs := []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", ManagedBy: "terraform"}}
+	subnets := []vpc.SubnetInfo{
+		{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1a", ManagedBy: "karpenter"},
+		{SubnetID: "subnet-0a2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24", AvailabilityZone: "eu-west-1b", ManagedBy: "manual"},
+	}
+	nats := []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", VpcID: "vpc-0a1", SubnetID: "subnet-0a1", ManagedBy: "eks-lb-controller"}}
+
+	tests := []struct {
+		name   string
+		dim    []string
+		dimmed []string
+	}{
+		{name: "none"},
+		{name: "one manager", dim: []string{"karpenter"}, dimmed: []string{"subnet-0a1"}},
+		{name: "several managers", dim: []string{"eks-lb-controller", "terraform", "cloudformation"}, dimmed: []string{"nat-0a1", "vpc-0a1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dg := NewDiagramGenerator()
+			dg.SetDimManaged(tt.dim)
+			doc, err := dg.GenerateVPCDiagram(vpcs, subnets, nil, nil, nil, nats, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var drawio DrawIO
+			if err := xml.Unmarshal([]byte(doc), &drawio); err != nil {
+				t.Fatal(err)
+			}
+			dimmed := []string{}
+			for _, cell := range drawio.Diagram.MxGraphModel.Root.Cells {
+				if strings.Contains(cell.Style, "opacity=40;") {
+					dimmed = append(dimmed, cell.Data[0].Value)
+				}
+			}
+			sort.Strings(dimmed)
+			if want := append([]string{}, tt.dimmed...); !reflect.DeepEqual(dimmed, want) {
+				t.Errorf("dimmed cells = %v, want %v", dimmed, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package diagram
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Structure returns the cell tree of a diagram in a canonical form
+// Each cell is reduced to its node kind and its children, and children are sorted, so two diagrams
+// of the same topology have the same structure even when their resource IDs, names and addresses
+// differ. Used to check that an anonymized report reproduces the layout of the original.
+// xmlString: draw.io XML as returned by GenerateVPCDiagram
+// Returns: Canonical form of the tree, and the number of cells; or error if the XML is malformed
+func Structure(xmlString string) (string, int, error) {
+	var drawio DrawIO
+	if err := xml.Unmarshal([]byte(xmlString), &drawio); err != nil {
+		return "", 0, fmt.Errorf("malformed diagram XML: %w", err)
+	}
+	cells := drawio.Diagram.MxGraphModel.Root.Cells
+
+	children := make(map[string][]string)
+	kinds := make(map[string]string, len(cells))
+	for _, cell := range cells {
+		children[cell.Parent] = append(children[cell.Parent], cell.ID)
+		// Cell IDs are namespace/kind/resource ID; the root cells have no kind
+		kind := cell.ID
+		if parts := strings.SplitN(cell.ID, "/", 3); len(parts) == 3 {
+			kind = parts[1]
+		}
+		if cell.Edge == "1" {
+			kind = "edge:" + kind
+		}
+		kinds[cell.ID] = kind
+	}
+
+	var canonical func(id string) string
+	canonical = func(id string) string {
+		forms := make([]string, 0, len(children[id]))
+		for _, child := range children[id] {
+			forms = append(forms, canonical(child))
+		}
+		sort.Strings(forms)
+		return kinds[id] + "(" + strings.Join(forms, ",") + ")"
+	}
+	return canonical("0"), len(cells), nil
+}
@@ -0,0 +1,87 @@
+package diagram
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+)
+
+// Validate checks the structural invariants draw.io relies on to open a diagram
+// xmlString: draw.io XML as returned by GenerateVPCDiagram or GenerateVPCDetailDiagram
+// Returns: Error describing the first problem found (malformed XML, missing or duplicate root cells,
+// duplicate cell IDs, object wrappers without a label or with an inner cell ID, dangling
+// parent/source/target references, vertices without geometry, invalid dimensions)
+// Cells may be bare mxCell elements or mxCells wrapped in object or UserObject elements.
+func Validate(xmlString string) error {
+	var drawio DrawIO
+	if err := xml.Unmarshal([]byte(xmlString), &drawio); err != nil {
+		return fmt.Errorf("malformed diagram XML: %w", err)
+	}
+	cells := drawio.Diagram.MxGraphModel.Root.Cells
+
+	// Cell IDs must be unique; this also makes the root cells unique
+	ids := make(map[string]bool, len(cells))
+	for i, cell := range cells {
+		if cell.ID == "" {
+			return fmt.Errorf("cell %d has no ID", i)
+		}
+		if ids[cell.ID] {
+			return fmt.Errorf("duplicate cell ID %q", cell.ID)
+		}
+		ids[cell.ID] = true
+	}
+
+	// Cell 0 is the model root and cell 1 the default layer inside it
+	if !ids["0"] || !ids["1"] {
+		return fmt.Errorf("root cells 0 and 1 must both exist")
+	}
+
+	for _, cell := range cells {
+		switch cell.ID {
+		case "0":
+			if cell.Parent != "" {
+				return fmt.Errorf("root cell 0 must not have a parent, has %q", cell.Parent)
+			}
+			continue
+		case "1":
+			if cell.Parent != "0" {
+				return fmt.Errorf("layer cell 1 must have parent 0, has %q", cell.Parent)
+			}
+			continue
+		}
+
+		// draw.io reads the ID and label of a wrapped cell from the wrapper
+		if cell.wrapper != "" && !cell.hasLabel {
+			return fmt.Errorf("%s %q has no label attribute", cell.wrapper, cell.ID)
+		}
+		if cell.wrapper != "" && cell.innerID != "" {
+			return fmt.Errorf("%s %q wraps an mxCell with its own ID %q", cell.wrapper, cell.ID, cell.innerID)
+		}
+
+		if cell.Parent == "" {
+			return fmt.Errorf("cell %q has no parent", cell.ID)
+		}
+		for attr, ref := range map[string]string{"parent": cell.Parent, "source": cell.Source, "target": cell.Target} {
+			if ref != "" && !ids[ref] {
+				return fmt.Errorf("cell %q references missing %s cell %q", cell.ID, attr, ref)
+			}
+		}
+
+		if cell.Vertex == "1" && cell.Geometry == nil {
+			return fmt.Errorf("vertex %q has no geometry", cell.ID)
+		}
+		if cell.Geometry != nil {
+			g := cell.Geometry
+			for name, value := range map[string]float64{"x": g.X, "y": g.Y, "width": g.Width, "height": g.Height} {
+				if math.IsNaN(value) || math.IsInf(value, 0) {
+					return fmt.Errorf("cell %q has invalid %s %v", cell.ID, name, value)
+				}
+			}
+			if g.Width < 0 || g.Height < 0 {
+				return fmt.Errorf("cell %q has negative dimensions %vx%v", cell.ID, g.Width, g.Height)
+			}
+		}
+	}
+
+	return nil
+}
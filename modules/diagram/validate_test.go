@@ -0,0 +1,121 @@
+package diagram
+
+import (
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/testgen"
+)
+
+// drawioDocument wraps cells in the document structure of a draw.io file
+func drawioDocument(cells ...string) string {
+	return `<mxfile host="app.diagrams.net"><diagram name="test" id="test"><mxGraphModel><root>` +
+		strings.Join(cells, "") + `</root></mxGraphModel></diagram></mxfile>`
+}
+
+// Cells shared by the Validate cases
+const (
+	rootCell   = `<mxCell id="0"/>`
+	layerCell  = `<mxCell id="1" parent="0"/>`
+	vertexCell = `<mxCell id="a" value="A" vertex="1" parent="1"><mxGeometry x="0" y="0" width="100" height="50" as="geometry"/></mxCell>`
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		err  string // Part of the expected error ("" for a valid diagram)
+	}{
+		{name: "valid", xml: drawioDocument(rootCell, layerCell, vertexCell,
+			`<mxCell id="b" value="B" vertex="1" parent="1"><mxGeometry x="200" y="0" width="100" height="50" as="geometry"/></mxCell>`,
+			`<mxCell id="e" edge="1" parent="1" source="a" target="b"><mxGeometry relative="1" as="geometry"/></mxCell>`)},
+		{name: "valid object wrapper", xml: drawioDocument(rootCell, layerCell,
+			`<object id="a" label="A" resource_id="vpc-1"><mxCell vertex="1" parent="1"><mxGeometry width="100" height="50" as="geometry"/></mxCell></object>`)},
+		{name: "malformed XML", xml: drawioDocument(rootCell, layerCell, `<mxCell id="a"`), err: "malformed diagram XML"},
+		{name: "duplicate ID", xml: drawioDocument(rootCell, layerCell, vertexCell, vertexCell), err: `duplicate cell ID "a"`},
+		{name: "cell without ID", xml: drawioDocument(rootCell, layerCell, `<mxCell parent="1"/>`), err: "has no ID"},
+		{name: "missing root cell 0", xml: drawioDocument(layerCell, vertexCell), err: "root cells 0 and 1 must both exist"},
+		{name: "missing layer cell 1", xml: drawioDocument(rootCell), err: "root cells 0 and 1 must both exist"},
+		{name: "duplicated root cell 0", xml: drawioDocument(rootCell, rootCell, layerCell), err: `duplicate cell ID "0"`},
+		{name: "duplicated layer cell 1", xml: drawioDocument(rootCell, layerCell, layerCell), err: `duplicate cell ID "1"`},
+		{name: "root cell with parent", xml: drawioDocument(`<mxCell id="0" parent="1"/>`, layerCell), err: "root cell 0 must not have a parent"},
+		{name: "layer cell outside root", xml: drawioDocument(rootCell, `<mxCell id="1"/>`), err: "layer cell 1 must have parent 0"},
+		{name: "cell without parent", xml: drawioDocument(rootCell, layerCell,
+			`<mxCell id="a" vertex="1"><mxGeometry width="1" height="1" as="geometry"/></mxCell>`), err: `cell "a" has no parent`},
+		{name: "dangling parent", xml: drawioDocument(rootCell, layerCell,
+			`<mxCell id="a" vertex="1" parent="vpc"><mxGeometry width="1" height="1" as="geometry"/></mxCell>`), err: `references missing parent cell "vpc"`},
+		{name: "dangling source", xml: drawioDocument(rootCell, layerCell, vertexCell,
+			`<mxCell id="e" edge="1" parent="1" source="x" target="a"><mxGeometry relative="1" as="geometry"/></mxCell>`), err: `references missing source cell "x"`},
+		{name: "dangling target", xml: drawioDocument(rootCell, layerCell, vertexCell,
+			`<mxCell id="e" edge="1" parent="1" source="a" target="y"><mxGeometry relative="1" as="geometry"/></mxCell>`), err: `references missing target cell "y"`},
+		{name: "vertex without geometry", xml: drawioDocument(rootCell, layerCell, `<mxCell id="a" vertex="1" parent="1"/>`), err: `vertex "a" has no geometry`},
+		{name: "NaN width", xml: drawioDocument(rootCell, layerCell,
+			`<mxCell id="a" vertex="1" parent="1"><mxGeometry width="NaN" height="50" as="geometry"/></mxCell>`), err: "invalid width NaN"},
+		{name: "infinite x", xml: drawioDocument(rootCell, layerCell,
+			`<mxCell id="a" vertex="1" parent="1"><mxGeometry x="+Inf" width="10" height="50" as="geometry"/></mxCell>`), err: "invalid x +Inf"},
+		{name: "negative width", xml: drawioDocument(rootCell, layerCell,
+			`<mxCell id="a" vertex="1" parent="1"><mxGeometry width="-10" height="50" as="geometry"/></mxCell>`), err: "negative dimensions"},
+		{name: "negative height", xml: drawioDocument(rootCell, layerCell,
+			`<mxCell id="a" vertex="1" parent="1"><mxGeometry width="10" height="-1" as="geometry"/></mxCell>`), err: "negative dimensions"},
+		{name: "object without label", xml: drawioDocument(rootCell, layerCell,
+			`<object id="a"><mxCell vertex="1" parent="1"><mxGeometry width="1" height="1" as="geometry"/></mxCell></object>`), err: `object "a" has no label attribute`},
+		{name: "object with inner ID", xml: drawioDocument(rootCell, layerCell,
+			`<UserObject id="a" label="A"><mxCell id="b" vertex="1" parent="1"><mxGeometry width="1" height="1" as="geometry"/></mxCell></UserObject>`), err: `wraps an mxCell with its own ID "b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.xml)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestGeneratedDiagramsValidate checks that every generator's output passes Validate, with and without resource attributes
+func TestGeneratedDiagramsValidate(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	for _, plain := range []bool{false, true} {
+		dg := NewDiagramGenerator()
+		dg.SetPlainCells(plain)
+		dg.SetScanContext("eu-west-1", testgen.Account)
+
+		overview, err := dg.GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+			env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+		if err != nil {
+			t.Fatalf("GenerateVPCDiagram (plain %v): %v", plain, err)
+		}
+		if err := Validate(overview); err != nil {
+			t.Errorf("GenerateVPCDiagram (plain %v): %v", plain, err)
+		}
+
+		detail, err := dg.GenerateVPCDetailDiagram(env.VPCs[0], env.Subnets, env.RouteTables, env.SecurityGroups,
+			env.InternetGateways, env.NatGateways)
+		if err != nil {
+			t.Fatalf("GenerateVPCDetailDiagram (plain %v): %v", plain, err)
+		}
+		if err := Validate(detail); err != nil {
+			t.Errorf("GenerateVPCDetailDiagram (plain %v): %v", plain, err)
+		}
+
+		regions, err := dg.GenerateRegionsDiagram([]RegionResources{
+			{Region: "eu-west-1", VPCs: env.VPCs[:5], Subnets: env.Subnets, InternetGateways: env.InternetGateways,
+				NatGateways: env.NatGateways, TransitGateways: env.TransitGateways, TGWAttachments: env.TGWAttachments},
+			{Region: "us-east-1", VPCs: env.VPCs[5:], Subnets: env.Subnets, InternetGateways: env.InternetGateways,
+				NatGateways: env.NatGateways},
+		})
+		if err != nil {
+			t.Fatalf("GenerateRegionsDiagram (plain %v): %v", plain, err)
+		}
+		if err := Validate(regions); err != nil {
+			t.Errorf("GenerateRegionsDiagram (plain %v): %v", plain, err)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package diff compares a scan with an earlier JSON report and lists what changed per resource
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+// Kinds of changes
+const (
+	ChangeAdded    = "added"    // The resource is new since the earlier report
+	ChangeRemoved  = "removed"  // The resource was in the earlier report but no longer exists
+	ChangeModified = "modified" // The resource exists in both with different attributes
+)
+
+// Resource types as named in the changes
+const (
+	TypeVPC             = "VPC"
+	TypeSubnet          = "Subnet"
+	TypeRouteTable      = "Route table"
+	TypeSecurityGroup   = "Security group"
+	TypeInternetGateway = "Internet gateway"
+	TypeNatGateway      = "NAT gateway"
+	TypeTransitGateway  = "Transit gateway"
+)
+
+// Snapshot is the part of a JSON report that is compared
+// Sections missing from the report, as in reports of older versions, stay nil and are not compared.
+type Snapshot struct {
+	ScannedAt        string                             `json:"scanned_at"`        // When the report's scan started
+	VPCs             []vpc.VPCInfo                      `json:"vpcs"`              // VPCs of the report
+	Subnets          []vpc.SubnetInfo                   `json:"subnets"`           // Subnets of the report
+	RouteTables      []vpc.RouteTableInfo               `json:"route_tables"`      // Route tables of the report
+	SecurityGroups   []vpc.SecurityGroupInfo            `json:"security_groups"`   // Security groups of the report
+	InternetGateways []vpc.InternetGatewayInfo          `json:"internet_gateways"` // Internet gateways of the report
+	NatGateways      []vpc.NatGatewayInfo               `json:"nat_gateways"`      // NAT gateways of the report
+	TransitGateways  []vpc.TransitGatewayInfo           `json:"transit_gateways"`  // Transit gateways of the report
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`   // Transit gateway attachments of the report (nil in reports without them)
+	SubnetNetwork    []analysis.SubnetNetworkCapacity   `json:"subnet_network"`    // Network limits of the instances per subnet (nil in reports without them)
+}
+
+// LoadSnapshot reads a JSON report written in any field style
+// Returns: Snapshot of the report, or error if the file cannot be read or is not a report
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var snapshot Snapshot
+	if err := output.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	if snapshot.VPCs == nil {
+		return nil, fmt.Errorf("%s is not a scan report: it has no vpcs section", path)
+	}
+	for i := range snapshot.Subnets {
+		snapshot.Subnets[i].SetAddressUsage()
+	}
+	return &snapshot, nil
+}
+
+// ResourceChange describes how one resource changed
+type ResourceChange struct {
+	ResourceType  string                  `json:"resource_type"`  // VPC, Subnet, Route table, ...
+	ResourceID    string                  `json:"resource_id"`    // ID of the resource
+	Name          string                  `json:"name"`           // Name tag or group name, falling back to the ID
+	Change        string                  `json:"change"`         // added, removed or modified
+	Fields        []string                `json:"fields"`         // Attributes that changed (modified resources), rules and routes excluded
+	AddedRules    []vpc.SecurityGroupRule `json:"added_rules"`    // Rules new in the security group
+	RemovedRules  []vpc.SecurityGroupRule `json:"removed_rules"`  // Rules no longer in the security group
+	AddedRoutes   []vpc.RouteInfo         `json:"added_routes"`   // Routes new in the route table
+	RemovedRoutes []vpc.RouteInfo         `json:"removed_routes"` // Routes no longer in the route table
+}
+
+// Changes is the result of comparing a scan with an earlier report
+type Changes struct {
+	Since           string           `json:"since"`            // When the earlier report's scan started
+	Resources       []ResourceChange `json:"resources"`        // Changed resources by type (VPCs first, then subnets, route tables, ...) and ID
+	MissingSections []string         `json:"missing_sections"` // Resource types the earlier report has no section for
+}
+
+// Find returns the change of a resource, or nil if it did not change
+func (c *Changes) Find(resourceID string) *ResourceChange {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Resources {
+		if c.Resources[i].ResourceID == resourceID {
+			return &c.Resources[i]
+		}
+	}
+	return nil
+}
+
+// Count returns the number of resources with the given kind of change
+func (c *Changes) Count(change string) int {
+	count := 0
+	for _, resource := range c.Resources {
+		if resource.Change == change {
+			count++
+		}
+	}
+	return count
+}
+
+// Compare lists the resources that were added, removed or modified since the earlier report
+// old: Snapshot of the earlier report
+// current: Snapshot of this scan
+// Returns: Changes by resource type and ID
+func Compare(old *Snapshot, current Snapshot) *Changes {
+	changes := &Changes{
+		Since:           old.ScannedAt,
+		Resources:       []ResourceChange{},
+		MissingSections: []string{},
+	}
+
+	compareSection(changes, TypeVPC, old.VPCs, current.VPCs,
+		func(v vpc.VPCInfo) string { return v.VpcID }, func(v vpc.VPCInfo) string { return nameTag(v.Tags, v.VpcID) })
+	compareSection(changes, TypeSubnet, old.Subnets, current.Subnets,
+		func(s vpc.SubnetInfo) string { return s.SubnetID }, func(s vpc.SubnetInfo) string { return nameTag(s.Tags, s.SubnetID) })
+	compareSection(changes, TypeRouteTable, old.RouteTables, current.RouteTables,
+		func(rt vpc.RouteTableInfo) string { return rt.RouteTableID }, func(rt vpc.RouteTableInfo) string { return nameTag(rt.Tags, rt.RouteTableID) })
+	compareSection(changes, TypeSecurityGroup, old.SecurityGroups, current.SecurityGroups,
+		func(sg vpc.SecurityGroupInfo) string { return sg.GroupID }, func(sg vpc.SecurityGroupInfo) string { return sg.GroupName })
+	compareSection(changes, TypeInternetGateway, old.InternetGateways, current.InternetGateways,
+		func(igw vpc.InternetGatewayInfo) string { return igw.InternetGatewayID }, func(igw vpc.InternetGatewayInfo) string { return nameTag(igw.Tags, igw.InternetGatewayID) })
+	compareSection(changes, TypeNatGateway, old.NatGateways, current.NatGateways,
+		func(ngw vpc.NatGatewayInfo) string { return ngw.NatGatewayID }, func(ngw vpc.NatGatewayInfo) string { return nameTag(ngw.Tags, ngw.NatGatewayID) })
+	compareSection(changes, TypeTransitGateway, old.TransitGateways, current.TransitGateways,
+		func(tgw vpc.TransitGatewayInfo) string { return tgw.TransitGatewayID }, func(tgw vpc.TransitGatewayInfo) string { return nameTag(tgw.Tags, tgw.TransitGatewayID) })
+	return changes
+}
+
+// compareSection records the changes of one resource type, with the rules and routes that were added
+// to or removed from a security group or route table listed one by one rather than as a changed field
+// old: Resources of the earlier report, nil if it has no section for the type
+func compareSection[T any](changes *Changes, resourceType string, old, current []T, id, name func(T) string) {
+	if old == nil {
+		changes.MissingSections = append(changes.MissingSections, resourceType)
+		return
+	}
+
+	added, removed, modified := Match(old, current, id)
+	var section []ResourceChange
+	for _, resource := range added {
+		section = append(section, newChange(resourceType, id(resource), name(resource), ChangeAdded, nil))
+	}
+	for _, resource := range removed {
+		section = append(section, newChange(resourceType, id(resource), name(resource), ChangeRemoved, nil))
+	}
+	for _, m := range modified {
+		change := newChange(resourceType, m.ResourceID, name(m.After), ChangeModified, m.Fields)
+		switch before := any(m.Before).(type) {
+		case vpc.SecurityGroupInfo:
+			change.AddedRules, change.RemovedRules = CompareLists(before.Rules, any(m.After).(vpc.SecurityGroupInfo).Rules)
+			change.Fields = withoutFields(change.Fields, "rules", "ingress_rule_count", "egress_rule_count")
+		case vpc.RouteTableInfo:
+			change.AddedRoutes, change.RemovedRoutes = CompareLists(before.Routes, any(m.After).(vpc.RouteTableInfo).Routes)
+			change.Fields = withoutFields(change.Fields, "routes")
+		}
+		// A modified resource whose only difference was the order of its rules or routes has no change left
+		if len(change.Fields) == 0 && len(change.AddedRules)+len(change.RemovedRules)+len(change.AddedRoutes)+len(change.RemovedRoutes) == 0 {
+			continue
+		}
+		section = append(section, change)
+	}
+	sort.SliceStable(section, func(i, j int) bool { return section[i].ResourceID < section[j].ResourceID })
+	changes.Resources = append(changes.Resources, section...)
+}
+
+// Modified is a resource that exists in both lists of a Match with different attributes
+type Modified[T any] struct {
+	ResourceID string   // ID of the resource
+	Fields     []string // JSON fields whose values differ, as ChangedFields lists them
+	Before     T        // The resource in the earlier list
+	After      T        // The resource in the later list
+}
+
+// Match pairs the resources of two lists by ID and compares the pairs with ChangedFields
+// It is the engine of both Compare and the diff of two scan results. Resources without an ID
+// (recorded as data-quality warnings by the scan) cannot be matched and are skipped.
+// before: Resources of the earlier scan or report
+// after: Resources of the later scan
+// id: ID of a resource
+// Returns: The resources only in after, those only in before and the modified pairs, each sorted by ID and never nil
+func Match[T any](before, after []T, id func(T) string) ([]T, []T, []Modified[T]) {
+	added, removed, modified := []T{}, []T{}, []Modified[T]{}
+	beforeByID := make(map[string]T, len(before))
+	for _, resource := range before {
+		beforeByID[id(resource)] = resource
+	}
+	afterIDs := make(map[string]bool, len(after))
+	for _, resource := range after {
+		resourceID := id(resource)
+		if resourceID == "" {
+			continue
+		}
+		afterIDs[resourceID] = true
+		previous, ok := beforeByID[resourceID]
+		if !ok {
+			added = append(added, resource)
+			continue
+		}
+		if fields := ChangedFields(previous, resource); len(fields) > 0 {
+			modified = append(modified, Modified[T]{ResourceID: resourceID, Fields: fields, Before: previous, After: resource})
+		}
+	}
+	for _, resource := range before {
+		if resourceID := id(resource); resourceID != "" && !afterIDs[resourceID] {
+			removed = append(removed, resource)
+		}
+	}
+
+	sort.SliceStable(added, func(i, j int) bool { return id(added[i]) < id(added[j]) })
+	sort.SliceStable(removed, func(i, j int) bool { return id(removed[i]) < id(removed[j]) })
+	sort.SliceStable(modified, func(i, j int) bool { return modified[i].ResourceID < modified[j].ResourceID })
+	return added, removed, modified
+}
+
+// newChange creates a change without rule or route details
+func newChange(resourceType, resourceID, name, change string, fields []string) ResourceChange {
+	if fields == nil {
+		fields = []string{}
+	}
+	return ResourceChange{
+		ResourceType:  resourceType,
+		ResourceID:    resourceID,
+		Name:          name,
+		Change:        change,
+		Fields:        fields,
+		AddedRules:    []vpc.SecurityGroupRule{},
+		RemovedRules:  []vpc.SecurityGroupRule{},
+		AddedRoutes:   []vpc.RouteInfo{},
+		RemovedRoutes: []vpc.RouteInfo{},
+	}
+}
+
+// ChangedFields returns the sorted JSON field names whose values differ between two resources
+// The tag list, ARN, free address count with the utilization derived from it and manager are not compared.
+func ChangedFields(old, current interface{}) []string {
+	oldFields, currentFields := jsonFields(old), jsonFields(current)
+	// The tag list repeats the tags map and the ARN the ID, and reports written before they existed do not have them
+	delete(oldFields, "tag_list")
+	delete(currentFields, "tag_list")
+	delete(oldFields, "arn")
+	delete(currentFields, "arn")
+	// Free addresses change with every instance launched; they are usage, not configuration. The address
+	// total and utilization are derived from them and the CIDR block, and older reports do not have them.
+	for _, derived := range []string{"available_ip_address_count", "total_ip_address_count", "utilization_percent"} {
+		delete(oldFields, derived)
+		delete(currentFields, derived)
+	}
+	// The manager follows from the tags, whose changes are reported themselves
+	delete(oldFields, "managed_by")
+	delete(currentFields, "managed_by")
+	seen := make(map[string]bool)
+	var fields []string
+	for name, value := range currentFields {
+		seen[name] = true
+		if string(oldFields[name]) != string(value) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range oldFields {
+		if !seen[name] {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// jsonFields encodes a resource and returns its top-level fields
+func jsonFields(v interface{}) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// CompareLists returns the elements only in current and only in old, compared by their JSON encoding
+// Order does not matter; duplicates are counted.
+func CompareLists[T any](old, current []T) ([]T, []T) {
+	remaining := make(map[string]int)
+	for _, element := range old {
+		remaining[jsonKey(element)]++
+	}
+	added := []T{}
+	for _, element := range current {
+		key := jsonKey(element)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		added = append(added, element)
+	}
+	removed := []T{}
+	for _, element := range old {
+		key := jsonKey(element)
+		if remaining[key] > 0 {
+			remaining[key]--
+			removed = append(removed, element)
+		}
+	}
+	return added, removed
+}
+
+// jsonKey returns the JSON encoding of a value as a map key
+func jsonKey(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// withoutFields removes field names from a list
+func withoutFields(fields []string, remove ...string) []string {
+	result := []string{}
+	for _, field := range fields {
+		keep := true
+		for _, name := range remove {
+			if field == name {
+				keep = false
+			}
+		}
+		if keep {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// nameTag returns the Name tag, falling back to the resource ID
+func nameTag(tags map[string]string, resourceID string) string {
+	if name := tags["Name"]; name != "" {
+		return name
+	}
+	return resourceID
+}
@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// Rules and routes shared by both snapshots
+var (
+	httpsRule   = vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"}
+	sshRule     = vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "10.0.0.0/8", Description: "admin"}
+	allEgress   = vpc.SecurityGroupRule{IpProtocol: "-1", CidrBlock: "0.0.0.0/0", IsEgress: true}
+	localRoute  = vpc.RouteInfo{DestinationCidrBlock: "10.0.0.0/16", GatewayID: "local", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}}
+	igwRoute    = vpc.RouteInfo{DestinationCidrBlock: "0.0.0.0/0", GatewayID: "igw-0a1", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}}
+	natRoute    = vpc.RouteInfo{DestinationCidrBlock: "0.0.0.0/0", NatGatewayID: "nat-0new", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0new"}}
+	peeredRoute = vpc.RouteInfo{DestinationCidrBlock: "10.1.0.0/16", VpcPeeringConnectionID: "pcx-0a1", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetVpcPeeringConnection, ID: "pcx-0a1"}}
+)
+
+// earlierSnapshot is the report of last quarter
+func earlierSnapshot() *Snapshot {
+	return &Snapshot{
+		ScannedAt: "2026-07-01T09:00:00Z",
+		VPCs: []vpc.VPCInfo{
+			{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", State: "available", Tags: map[string]string{"Name": "prod"}},
+			{VpcID: "vpc-0old", CidrBlock: "10.9.0.0/16", State: "available", Tags: map[string]string{"Name": "legacy"}},
+		},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1a", MapPublicIpOnLaunch: true},
+			{SubnetID: "subnet-0old", VpcID: "vpc-0old", CidrBlock: "10.9.1.0/24", AvailabilityZone: "eu-west-1a"},
+		},
+		RouteTables: []vpc.RouteTableInfo{
+			{RouteTableID: "rtb-0pub", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a1"}, Routes: []vpc.RouteInfo{localRoute, igwRoute}},
+			{RouteTableID: "rtb-0priv", VpcID: "vpc-0a1", Routes: []vpc.RouteInfo{localRoute, peeredRoute}, Tags: map[string]string{"Name": "private"}},
+		},
+		SecurityGroups: []vpc.SecurityGroupInfo{
+			{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", Description: "web servers", Rules: []vpc.SecurityGroupRule{httpsRule, sshRule, allEgress},
+				IngressRuleCount: 2, EgressRuleCount: 1},
+			{GroupID: "sg-0reordered", GroupName: "reordered", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{httpsRule, allEgress}},
+			{GroupID: "sg-0old", GroupName: "legacy", VpcID: "vpc-0old", Rules: []vpc.SecurityGroupRule{}},
+		},
+		InternetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1", State: "available"}},
+		NatGateways:      []vpc.NatGatewayInfo{},
+		TransitGateways:  []vpc.TransitGatewayInfo{},
+	}
+}
+
+// laterSnapshot is the current scan: the legacy VPC is gone, a NAT gateway and a private subnet were added,
+// the web group lost SSH and gained a rule, the private route table routes through the NAT gateway and
+// the prod VPC got a tag
+func laterSnapshot() Snapshot {
+	return Snapshot{
+		ScannedAt: "2026-10-16T09:00:00Z",
+		VPCs: []vpc.VPCInfo{
+			{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", State: "available", Tags: map[string]string{"Name": "prod", "team": "network"}},
+		},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1a", MapPublicIpOnLaunch: true},
+			{SubnetID: "subnet-0b2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24", AvailabilityZone: "eu-west-1b", Tags: map[string]string{"Name": "private-b"}},
+		},
+		RouteTables: []vpc.RouteTableInfo{
+			{RouteTableID: "rtb-0pub", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a1"}, Routes: []vpc.RouteInfo{localRoute, igwRoute}},
+			{RouteTableID: "rtb-0priv", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0b2"}, Routes: []vpc.RouteInfo{localRoute, natRoute},
+				Tags: map[string]string{"Name": "private"}},
+		},
+		SecurityGroups: []vpc.SecurityGroupInfo{
+			{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", Description: "web servers",
+				Rules:            []vpc.SecurityGroupRule{httpsRule, {IpProtocol: "tcp", FromPort: 80, ToPort: 80, CidrBlock: "0.0.0.0/0"}, allEgress},
+				IngressRuleCount: 2, EgressRuleCount: 1},
+			{GroupID: "sg-0reordered", GroupName: "reordered", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{allEgress, httpsRule}},
+		},
+		InternetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1", State: "available"}},
+		NatGateways:      []vpc.NatGatewayInfo{{NatGatewayID: "nat-0new", SubnetID: "subnet-0a1", VpcID: "vpc-0a1", State: "available"}},
+		TransitGateways:  []vpc.TransitGatewayInfo{},
+	}
+}
+
+// TestCompareGolden compares two snapshots with added, removed and modified VPCs, subnets, route tables,
+// security groups and NAT gateways against testdata/changes.json
+// Run go test -run CompareGolden -update after a deliberate change and review the golden diff.
+func TestCompareGolden(t *testing.T) {
+	changes := Compare(earlierSnapshot(), laterSnapshot())
+	got, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "changes.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("changes differ from %s (run go test -update if the change is intended):\n%s", golden, got)
+	}
+
+	for change, count := range map[string]int{ChangeAdded: 2, ChangeRemoved: 3, ChangeModified: 3} {
+		if got := changes.Count(change); got != count {
+			t.Errorf("Count(%s) = %d, want %d", change, got, count)
+		}
+	}
+	if changes.Find("sg-0reordered") != nil {
+		t.Error("a security group whose rules were only reordered is reported as changed")
+	}
+}
+
+// TestCompareOlderReport checks that sections an older report lacks are named instead of reporting every
+// resource of them as added
+func TestCompareOlderReport(t *testing.T) {
+	earlier := earlierSnapshot()
+	earlier.RouteTables, earlier.NatGateways, earlier.TransitGateways = nil, nil, nil
+
+	changes := Compare(earlier, laterSnapshot())
+	if want := []string{TypeRouteTable, TypeNatGateway, TypeTransitGateway}; !reflect.DeepEqual(changes.MissingSections, want) {
+		t.Errorf("MissingSections = %v, want %v", changes.MissingSections, want)
+	}
+	for _, resource := range changes.Resources {
+		if resource.ResourceType == TypeRouteTable || resource.ResourceType == TypeNatGateway {
+			t.Errorf("%s %s is reported although the earlier report has no section for it", resource.ResourceType, resource.ResourceID)
+		}
+	}
+	if changes.Find("sg-0web") == nil {
+		t.Error("the sections both reports have are no longer compared")
+	}
+}
+
+// TestLoadSnapshot reads an earlier report in camelCase with an older schema and rejects files that are not reports
+func TestLoadSnapshot(t *testing.T) {
+	snapshot, err := LoadSnapshot(filepath.Join("testdata", "earlier_camel.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ScannedAt != "2026-07-01T09:00:00Z" || len(snapshot.VPCs) != 1 || snapshot.VPCs[0].VpcID != "vpc-0a1" {
+		t.Errorf("LoadSnapshot() = %+v, want the prod VPC scanned on 2026-07-01", snapshot)
+	}
+	if len(snapshot.Subnets) != 1 || snapshot.Subnets[0].TotalIpAddressCount != 251 {
+		t.Errorf("subnets = %+v, want one with its address usage derived", snapshot.Subnets)
+	}
+	if snapshot.RouteTables != nil || snapshot.NatGateways != nil {
+		t.Errorf("sections the report lacks = %v, %v, want nil", snapshot.RouteTables, snapshot.NatGateways)
+	}
+
+	dir := t.TempDir()
+	notReport := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(notReport, []byte(`{"rules": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{notReport, filepath.Join(dir, "missing.json")} {
+		if _, err := LoadSnapshot(path); err == nil {
+			t.Errorf("LoadSnapshot(%s) succeeded", filepath.Base(path))
+		}
+	}
+	if _, err := LoadSnapshot(notReport); err == nil || !strings.Contains(err.Error(), "no vpcs section") {
+		t.Errorf("LoadSnapshot() of a file without VPCs = %v, want a missing vpcs section", err)
+	}
+}
+
+// TestCompareLists checks that order does not matter and duplicates are counted
+func TestCompareLists(t *testing.T) {
+	tests := []struct {
+		name        string
+		old         []string
+		current     []string
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{name: "reordered", old: []string{"a", "b"}, current: []string{"b", "a"}, wantAdded: []string{}, wantRemoved: []string{}},
+		{name: "added and removed", old: []string{"a", "b"}, current: []string{"b", "c"}, wantAdded: []string{"c"}, wantRemoved: []string{"a"}},
+		{name: "duplicate added", old: []string{"a"}, current: []string{"a", "a"}, wantAdded: []string{"a"}, wantRemoved: []string{}},
+		{name: "duplicate removed", old: []string{"a", "a", "b"}, current: []string{"a"}, wantAdded: []string{}, wantRemoved: []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := CompareLists(tt.old, tt.current)
+			if !reflect.DeepEqual(added, tt.wantAdded) || !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("CompareLists() = %v, %v, want %v, %v", added, removed, tt.wantAdded, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// TestLoadSnapshotAttachments reads the transit gateway attachments -stability-history follows from reports in
+// either field style, and leaves them nil for reports without the section
+func TestLoadSnapshotAttachments(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   []vpc.TransitGatewayAttachmentInfo
+	}{
+		{name: "snake_case", report: `{"scanned_at": "2026-09-01T00:00:00Z", "vpcs": [],
+			"tgw_attachments": [{"attachment_id": "tgw-attach-0a1", "transit_gateway_id": "tgw-0a1", "resource_type": "vpn", "state": "modifying"}]}`,
+			want: []vpc.TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0a1", ResourceType: "vpn", State: "modifying"}}},
+		{name: "camelCase", report: `{"scannedAt": "2026-09-01T00:00:00Z", "vpcs": [],
+			"tgwAttachments": [{"transitGatewayAttachmentId": "tgw-attach-0a1", "transitGatewayId": "tgw-0a1", "resourceType": "vpn", "state": "modifying"}]}`,
+			want: []vpc.TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0a1", ResourceType: "vpn", State: "modifying"}}},
+		{name: "no attachments", report: `{"scanned_at": "2026-09-01T00:00:00Z", "vpcs": [], "tgw_attachments": []}`,
+			want: []vpc.TransitGatewayAttachmentInfo{}},
+		{name: "older report", report: `{"scanned_at": "2026-09-01T00:00:00Z", "vpcs": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "report.json")
+			if err := os.WriteFile(path, []byte(tt.report), 0644); err != nil {
+				t.Fatal(err)
+			}
+			snapshot, err := LoadSnapshot(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(snapshot.TGWAttachments, tt.want) || snapshot.ScannedAt != "2026-09-01T00:00:00Z" {
+				t.Errorf("LoadSnapshot() = attachments %#v scanned at %q\nwant %#v", snapshot.TGWAttachments, snapshot.ScannedAt, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Package directory provides functionality for scanning the VPC footprint of Directory Service directories and WorkSpaces
+package directory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/directoryservice"
+	"github.com/aws/aws-sdk-go-v2/service/workspaces"
+
+	"aws-documentor/modules/arnbuild"
+	"aws-documentor/modules/vpc"
+)
+
+// DirectoryInfo contains the network footprint of a Directory Service directory
+type DirectoryInfo struct {
+	DirectoryID     string   `json:"directory_id"`      // Unique identifier for the directory
+	Arn             string   `json:"arn"`               // ARN of the directory (empty if the account is unknown)
+	Name            string   `json:"name"`              // Fully qualified domain name of the directory
+	ShortName       string   `json:"short_name"`        // NetBIOS name of the directory
+	Type            string   `json:"type"`              // Directory type (SimpleAD, ADConnector, MicrosoftAD, SharedMicrosoftAD)
+	Size            string   `json:"size"`              // Directory size (Small, Large) for Simple AD and AD Connector
+	Edition         string   `json:"edition"`           // Edition (Standard, Enterprise) for AWS Managed Microsoft AD
+	Stage           string   `json:"stage"`             // Current stage of the directory (Active, Creating, Impaired, ...)
+	VpcID           string   `json:"vpc_id"`            // ID of the VPC the directory's network interfaces are in
+	SubnetIDs       []string `json:"subnet_ids"`        // IDs of the subnets the directory's network interfaces are in
+	SecurityGroupID string   `json:"security_group_id"` // ID of the security group the directory service created for its network interfaces
+	IPAddresses     []string `json:"ip_addresses"`      // IP addresses of the network interfaces (domain controllers, or AD Connector endpoints)
+}
+
+// WorkspacePlacement counts the WorkSpaces of one directory and bundle in a subnet
+type WorkspacePlacement struct {
+	DirectoryID string         `json:"directory_id"` // ID of the directory the WorkSpaces are registered with
+	BundleID    string         `json:"bundle_id"`    // ID of the bundle the WorkSpaces were created from
+	SubnetID    string         `json:"subnet_id"`    // ID of the subnet the WorkSpaces are in
+	Count       int            `json:"count"`        // Number of WorkSpaces
+	States      map[string]int `json:"states"`       // Number of WorkSpaces per state (AVAILABLE, STOPPED, ...)
+}
+
+// Scanner provides methods for retrieving directory and WorkSpaces information
+type Scanner struct {
+	dsClient         *directoryservice.Client // AWS Directory Service client for making API calls
+	workspacesClient *workspaces.Client       // Amazon WorkSpaces client for making API calls
+	region           string                   // Region of the clients, for ARNs
+	accountID        string                   // Account of the credentials, for ARNs (empty if unknown)
+}
+
+// NewScanner creates a new directory scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials and region information
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		dsClient:         directoryservice.NewFromConfig(cfg),
+		workspacesClient: workspaces.NewFromConfig(cfg),
+		region:           cfg.Region,
+	}
+}
+
+// SetAccountID sets the account used in the ARNs of the directories
+// accountID: Account of the credentials, from awsconfig.AccountID
+func (s *Scanner) SetAccountID(accountID string) {
+	s.accountID = accountID
+}
+
+// GetDirectories retrieves the network footprint of all directories in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of DirectoryInfo structs, or error if the operation fails
+func (s *Scanner) GetDirectories(ctx context.Context) ([]DirectoryInfo, error) {
+	directories := []DirectoryInfo{}
+
+	input := &directoryservice.DescribeDirectoriesInput{}
+	for {
+		result, err := s.dsClient.DescribeDirectories(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("ds", "directories", "DescribeDirectories", err)
+		}
+
+		for _, d := range result.DirectoryDescriptions {
+			directory := DirectoryInfo{
+				DirectoryID: aws.ToString(d.DirectoryId),
+				Arn:         arnbuild.Build(arnbuild.TypeDirectory, s.region, s.accountID, aws.ToString(d.DirectoryId)),
+				Name:        aws.ToString(d.Name),
+				ShortName:   aws.ToString(d.ShortName),
+				Type:        string(d.Type),
+				Size:        string(d.Size),
+				Edition:     string(d.Edition),
+				Stage:       string(d.Stage),
+				SubnetIDs:   []string{},
+				IPAddresses: []string{},
+			}
+
+			// AD Connector reports its network settings separately from the directory types that run domain controllers
+			switch {
+			case d.ConnectSettings != nil:
+				directory.VpcID = aws.ToString(d.ConnectSettings.VpcId)
+				directory.SubnetIDs = append(directory.SubnetIDs, d.ConnectSettings.SubnetIds...)
+				directory.SecurityGroupID = aws.ToString(d.ConnectSettings.SecurityGroupId)
+				directory.IPAddresses = append(directory.IPAddresses, d.ConnectSettings.ConnectIps...)
+			case d.VpcSettings != nil:
+				directory.VpcID = aws.ToString(d.VpcSettings.VpcId)
+				directory.SubnetIDs = append(directory.SubnetIDs, d.VpcSettings.SubnetIds...)
+				directory.SecurityGroupID = aws.ToString(d.VpcSettings.SecurityGroupId)
+				directory.IPAddresses = append(directory.IPAddresses, d.DnsIpAddrs...)
+			}
+
+			directories = append(directories, directory)
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return directories, nil
+}
+
+// GetWorkspacePlacements retrieves WorkSpaces counts per directory, bundle and subnet
+// Individual WorkSpaces are not reported; they come and go with users
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Placements sorted by directory, subnet and bundle, or error if the operation fails
+func (s *Scanner) GetWorkspacePlacements(ctx context.Context) ([]WorkspacePlacement, error) {
+	placements := make(map[[3]string]*WorkspacePlacement)
+
+	input := &workspaces.DescribeWorkspacesInput{}
+	for {
+		result, err := s.workspacesClient.DescribeWorkspaces(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("workspaces", "WorkSpaces", "DescribeWorkspaces", err)
+		}
+
+		for _, ws := range result.Workspaces {
+			key := [3]string{aws.ToString(ws.DirectoryId), aws.ToString(ws.SubnetId), aws.ToString(ws.BundleId)}
+			placement, ok := placements[key]
+			if !ok {
+				placement = &WorkspacePlacement{
+					DirectoryID: key[0],
+					SubnetID:    key[1],
+					BundleID:    key[2],
+					States:      make(map[string]int),
+				}
+				placements[key] = placement
+			}
+			placement.Count++
+			placement.States[string(ws.State)]++
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	result := []WorkspacePlacement{}
+	for _, placement := range placements {
+		result = append(result, *placement)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DirectoryID != result[j].DirectoryID {
+			return result[i].DirectoryID < result[j].DirectoryID
+		}
+		if result[i].SubnetID != result[j].SubnetID {
+			return result[i].SubnetID < result[j].SubnetID
+		}
+		return result[i].BundleID < result[j].BundleID
+	})
+	return result, nil
+}
@@ -0,0 +1,137 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/vpc"
+)
+
+// newTestScanner returns a scanner whose Directory Service and WorkSpaces endpoints answer each operation
+// with its page for the request's NextToken
+// Operations without an entry fail with AccessDeniedException.
+// pages: Response bodies by operation (the part of X-Amz-Target after the dot) and NextToken ("" for the first page)
+func newTestScanner(t *testing.T, pages map[string]map[string]string) *Scanner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, operation, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+		var input struct{ NextToken string }
+		json.NewDecoder(r.Body).Decode(&input)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		body, ok := pages[operation][input.NextToken]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"AccessDeniedException","message":"not authorized"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	scanner := NewScanner(aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+	scanner.SetAccountID("111122223333")
+	return scanner
+}
+
+// TestGetDirectories reads the network settings of an AD Connector, a Managed Microsoft AD and a directory
+// without network settings from two pages
+func TestGetDirectories(t *testing.T) {
+	scanner := newTestScanner(t, map[string]map[string]string{"DescribeDirectories": {
+		"": `{"NextToken": "page-2", "DirectoryDescriptions": [{"DirectoryId": "d-0connector", "Name": "corp.example.com",
+			"ShortName": "CORP", "Type": "ADConnector", "Size": "Small", "Stage": "Active",
+			"ConnectSettings": {"VpcId": "vpc-0a1", "SubnetIds": ["subnet-0a1", "subnet-0b2"], "SecurityGroupId": "sg-0connector",
+				"ConnectIps": ["10.0.1.10", "10.0.2.10"], "CustomerDnsIps": ["192.168.0.2"]},
+			"DnsIpAddrs": ["192.168.0.2"]}]}`,
+		"page-2": `{"DirectoryDescriptions": [{"DirectoryId": "d-0managed", "Name": "vdi.example.com", "Type": "MicrosoftAD",
+			"Edition": "Enterprise", "Stage": "Active",
+			"VpcSettings": {"VpcId": "vpc-0gone", "SubnetIds": ["subnet-0c3", "subnet-0d4"], "SecurityGroupId": "sg-0managed",
+				"AvailabilityZones": ["eu-west-1a", "eu-west-1b"]},
+			"DnsIpAddrs": ["10.9.1.5", "10.9.2.5"]},
+			{"DirectoryId": "d-0shared", "Name": "shared.example.com", "Type": "SharedMicrosoftAD", "Stage": "Creating"}]}`,
+	}})
+
+	got, err := scanner.GetDirectories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DirectoryInfo{
+		{DirectoryID: "d-0connector", Arn: "arn:aws:ds:eu-west-1:111122223333:directory/d-0connector", Name: "corp.example.com",
+			ShortName: "CORP", Type: "ADConnector", Size: "Small", Stage: "Active", VpcID: "vpc-0a1",
+			SubnetIDs: []string{"subnet-0a1", "subnet-0b2"}, SecurityGroupID: "sg-0connector", IPAddresses: []string{"10.0.1.10", "10.0.2.10"}},
+		{DirectoryID: "d-0managed", Arn: "arn:aws:ds:eu-west-1:111122223333:directory/d-0managed", Name: "vdi.example.com",
+			Type: "MicrosoftAD", Edition: "Enterprise", Stage: "Active", VpcID: "vpc-0gone",
+			SubnetIDs: []string{"subnet-0c3", "subnet-0d4"}, SecurityGroupID: "sg-0managed", IPAddresses: []string{"10.9.1.5", "10.9.2.5"}},
+		{DirectoryID: "d-0shared", Arn: "arn:aws:ds:eu-west-1:111122223333:directory/d-0shared", Name: "shared.example.com",
+			Type: "SharedMicrosoftAD", Stage: "Creating", SubnetIDs: []string{}, IPAddresses: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDirectories() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestGetWorkspacePlacements checks that WorkSpaces from every page are counted per directory, subnet and bundle
+func TestGetWorkspacePlacements(t *testing.T) {
+	scanner := newTestScanner(t, map[string]map[string]string{"DescribeWorkspaces": {
+		"": `{"NextToken": "page-2", "Workspaces": [
+			{"WorkspaceId": "ws-01", "DirectoryId": "d-0managed", "SubnetId": "subnet-0d4", "BundleId": "wsb-0power", "State": "AVAILABLE"},
+			{"WorkspaceId": "ws-02", "DirectoryId": "d-0managed", "SubnetId": "subnet-0c3", "BundleId": "wsb-0std", "State": "AVAILABLE"},
+			{"WorkspaceId": "ws-03", "DirectoryId": "d-0managed", "SubnetId": "subnet-0c3", "BundleId": "wsb-0std", "State": "STOPPED"}]}`,
+		"page-2": `{"Workspaces": [
+			{"WorkspaceId": "ws-04", "DirectoryId": "d-0managed", "SubnetId": "subnet-0c3", "BundleId": "wsb-0std", "State": "AVAILABLE"},
+			{"WorkspaceId": "ws-05", "DirectoryId": "d-0connector", "SubnetId": "subnet-0a1", "BundleId": "wsb-0std", "State": "PENDING"}]}`,
+	}})
+
+	got, err := scanner.GetWorkspacePlacements(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WorkspacePlacement{
+		{DirectoryID: "d-0connector", BundleID: "wsb-0std", SubnetID: "subnet-0a1", Count: 1, States: map[string]int{"PENDING": 1}},
+		{DirectoryID: "d-0managed", BundleID: "wsb-0std", SubnetID: "subnet-0c3", Count: 3, States: map[string]int{"AVAILABLE": 2, "STOPPED": 1}},
+		{DirectoryID: "d-0managed", BundleID: "wsb-0power", SubnetID: "subnet-0d4", Count: 1, States: map[string]int{"AVAILABLE": 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetWorkspacePlacements() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestScanErrors checks that denied calls return a scan error that main can classify as missing permissions
+func TestScanErrors(t *testing.T) {
+	scanner := newTestScanner(t, nil)
+	tests := []struct {
+		name      string
+		scan      func() error
+		operation string
+	}{
+		{name: "directories", scan: func() error { _, err := scanner.GetDirectories(context.Background()); return err }, operation: "DescribeDirectories"},
+		{name: "WorkSpaces", scan: func() error { _, err := scanner.GetWorkspacePlacements(context.Background()); return err }, operation: "DescribeWorkspaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scan()
+			var scanErr *vpc.ScanError
+			if !errors.As(err, &scanErr) || scanErr.Operation != tt.operation {
+				t.Fatalf("error = %v, want a scan error of %s", err, tt.operation)
+			}
+			if !errors.Is(err, vpc.ErrAccessDenied) {
+				t.Errorf("error = %v, want access denied", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+// Package dns provides functionality for scanning Route 53 private hosted zones and Resolver endpoints
+package dns
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+
+	"aws-documentor/modules/arnbuild"
+	"aws-documentor/modules/vpc"
+)
+
+// Resolver endpoint directions reported in ResolverEndpointInfo.Direction
+const (
+	DirectionInbound  = "INBOUND"  // Resolves queries from outside the VPC (on-premises to AWS)
+	DirectionOutbound = "OUTBOUND" // Forwards queries matching resolver rules out of the VPC
+)
+
+// ZoneAssociation is a VPC a private hosted zone resolves in
+type ZoneAssociation struct {
+	VpcID     string `json:"vpc_id"`     // ID of the associated VPC
+	VpcRegion string `json:"vpc_region"` // Region of the associated VPC
+}
+
+// RecordSummary describes one record set without its values
+type RecordSummary struct {
+	Name        string `json:"name"`         // Record name
+	Type        string `json:"type"`         // Record type (A, CNAME, ...)
+	AliasTarget string `json:"alias_target"` // DNS name of the alias target (empty for non-alias records)
+}
+
+// PrivateZoneInfo contains a private hosted zone and the VPCs it is associated with
+type PrivateZoneInfo struct {
+	HostedZoneID  string            `json:"hosted_zone_id"`    // ID of the hosted zone (without the /hostedzone/ prefix)
+	Arn           string            `json:"arn"`               // ARN of the hosted zone (global, without region and account)
+	Name          string            `json:"name"`              // Domain name of the zone
+	Comment       string            `json:"comment"`           // Comment on the zone
+	CrossAccount  bool              `json:"cross_account"`     // Whether the zone belongs to another account and is only seen through its association with a scanned VPC
+	OwningAccount string            `json:"owning_account"`    // Account that owns the zone (set for cross-account zones)
+	OwningService string            `json:"owning_service"`    // Service that created the zone, if any (set for cross-account zones)
+	VPCs          []ZoneAssociation `json:"vpcs"`              // Associated VPCs, including VPCs of other accounts associated with our zones
+	RecordCount   int64             `json:"record_count"`      // Number of record sets (zero for cross-account zones)
+	RecordsByType map[string]int    `json:"records_by_type"`   // Number of record sets per type (empty for cross-account zones)
+	Records       []RecordSummary   `json:"records,omitempty"` // Name, type and alias target of every record set (only with record details requested)
+}
+
+// ResolverEndpointInfo contains information about a Route 53 Resolver endpoint
+type ResolverEndpointInfo struct {
+	ResolverEndpointID string   `json:"resolver_endpoint_id"` // Unique identifier for the endpoint
+	Arn                string   `json:"arn"`                  // ARN of the endpoint
+	Name               string   `json:"name"`                 // Name of the endpoint
+	Direction          string   `json:"direction"`            // INBOUND or OUTBOUND
+	VpcID              string   `json:"vpc_id"`               // ID of the VPC the endpoint's network interfaces are in
+	SecurityGroupIDs   []string `json:"security_group_ids"`   // Security groups of the endpoint's network interfaces
+	IpAddressCount     int32    `json:"ip_address_count"`     // Number of IP addresses (network interfaces) of the endpoint
+	Status             string   `json:"status"`               // Status of the endpoint (CREATING, OPERATIONAL, ACTION_NEEDED, ...)
+}
+
+// Report contains the private hosted zones keyed to the VPCs they resolve in
+type Report struct {
+	PrivateZones      []PrivateZoneInfo      `json:"private_zones"`      // Private hosted zones sorted by name
+	ZonesByVPC        map[string][]string    `json:"zones_by_vpc"`       // Names of the zones associated with each VPC
+	ResolverEndpoints []ResolverEndpointInfo `json:"resolver_endpoints"` // Resolver endpoints in the scanned region
+}
+
+// Scanner provides methods for retrieving Route 53 private DNS information
+type Scanner struct {
+	route53Client  *route53.Client         // Amazon Route 53 client for making API calls
+	resolverClient *route53resolver.Client // Route 53 Resolver client for making API calls
+}
+
+// NewScanner creates a new DNS scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials and region information
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		route53Client:  route53.NewFromConfig(cfg),
+		resolverClient: route53resolver.NewFromConfig(cfg),
+	}
+}
+
+// GetPrivateHostedZones retrieves the account's private hosted zones and the zones of other accounts associated with the given VPCs
+// Hosted zones are global, so zones associated only with VPCs in other regions are included too.
+// Zones owned by another account cannot be listed from this side; they are found by listing the zones
+// of each scanned VPC and only carry their name, owner and the association.
+// ctx: Context for the request, allowing for timeout and cancellation
+// region: Region of the scanned VPCs
+// vpcIDs: IDs of the scanned VPCs, checked for cross-account associations
+// includeRecords: Whether to list the name, type and alias target of every record set
+// Returns: Slice of PrivateZoneInfo structs sorted by name, or error if the operation fails
+func (s *Scanner) GetPrivateHostedZones(ctx context.Context, region string, vpcIDs []string, includeRecords bool) ([]PrivateZoneInfo, error) {
+	zones := []PrivateZoneInfo{}
+	byID := make(map[string]int)
+
+	input := &route53.ListHostedZonesInput{}
+	for {
+		result, err := s.route53Client.ListHostedZones(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("route53", "hosted zones", "ListHostedZones", err)
+		}
+
+		for _, z := range result.HostedZones {
+			if z.Config == nil || !z.Config.PrivateZone {
+				continue
+			}
+			zone := PrivateZoneInfo{
+				HostedZoneID:  trimZoneID(aws.ToString(z.Id)),
+				Arn:           arnbuild.Build(arnbuild.TypeHostedZone, region, "", trimZoneID(aws.ToString(z.Id))),
+				Name:          aws.ToString(z.Name),
+				Comment:       aws.ToString(z.Config.Comment),
+				VPCs:          []ZoneAssociation{},
+				RecordCount:   aws.ToInt64(z.ResourceRecordSetCount),
+				RecordsByType: make(map[string]int),
+			}
+
+			// Only GetHostedZone returns the associations
+			detail, err := s.route53Client.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: z.Id})
+			if err != nil {
+				return nil, vpc.NewServiceScanError("route53", "hosted zone "+zone.HostedZoneID, "GetHostedZone", err)
+			}
+			for _, v := range detail.VPCs {
+				zone.VPCs = append(zone.VPCs, ZoneAssociation{VpcID: aws.ToString(v.VPCId), VpcRegion: string(v.VPCRegion)})
+			}
+
+			if err := s.countRecords(ctx, &zone, includeRecords); err != nil {
+				return nil, err
+			}
+
+			byID[zone.HostedZoneID] = len(zones)
+			zones = append(zones, zone)
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		input.Marker = result.NextMarker
+	}
+
+	// Zones shared with our VPCs by other accounts only show up from the VPC side
+	for _, vpcID := range vpcIDs {
+		byVPCInput := &route53.ListHostedZonesByVPCInput{
+			VPCId:     aws.String(vpcID),
+			VPCRegion: route53types.VPCRegion(region),
+		}
+		for {
+			result, err := s.route53Client.ListHostedZonesByVPC(ctx, byVPCInput)
+			if err != nil {
+				return nil, vpc.NewServiceScanError("route53", "hosted zones of "+vpcID, "ListHostedZonesByVPC", err)
+			}
+
+			for _, summary := range result.HostedZoneSummaries {
+				id := trimZoneID(aws.ToString(summary.HostedZoneId))
+				if _, ok := byID[id]; !ok {
+					zone := PrivateZoneInfo{
+						HostedZoneID:  id,
+						Arn:           arnbuild.Build(arnbuild.TypeHostedZone, region, "", id),
+						Name:          aws.ToString(summary.Name),
+						CrossAccount:  true,
+						VPCs:          []ZoneAssociation{},
+						RecordsByType: make(map[string]int),
+					}
+					if summary.Owner != nil {
+						zone.OwningAccount = aws.ToString(summary.Owner.OwningAccount)
+						zone.OwningService = aws.ToString(summary.Owner.OwningService)
+					}
+					byID[id] = len(zones)
+					zones = append(zones, zone)
+				}
+				zone := &zones[byID[id]]
+				if zone.CrossAccount {
+					zone.VPCs = append(zone.VPCs, ZoneAssociation{VpcID: vpcID, VpcRegion: region})
+				}
+			}
+
+			if result.NextToken == nil {
+				break
+			}
+			byVPCInput.NextToken = result.NextToken
+		}
+	}
+
+	sort.SliceStable(zones, func(i, j int) bool {
+		return zones[i].Name < zones[j].Name
+	})
+	return zones, nil
+}
+
+// countRecords counts the record sets of a zone by type, and summarizes them if requested
+func (s *Scanner) countRecords(ctx context.Context, zone *PrivateZoneInfo, includeRecords bool) error {
+	if includeRecords {
+		zone.Records = []RecordSummary{}
+	}
+
+	input := &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zone.HostedZoneID)}
+	for {
+		result, err := s.route53Client.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return vpc.NewServiceScanError("route53", "records of hosted zone "+zone.HostedZoneID, "ListResourceRecordSets", err)
+		}
+
+		for _, record := range result.ResourceRecordSets {
+			zone.RecordsByType[string(record.Type)]++
+			if includeRecords {
+				summary := RecordSummary{Name: aws.ToString(record.Name), Type: string(record.Type)}
+				if record.AliasTarget != nil {
+					summary.AliasTarget = aws.ToString(record.AliasTarget.DNSName)
+				}
+				zone.Records = append(zone.Records, summary)
+			}
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		input.StartRecordName = result.NextRecordName
+		input.StartRecordType = result.NextRecordType
+		input.StartRecordIdentifier = result.NextRecordIdentifier
+	}
+	return nil
+}
+
+// GetResolverEndpoints retrieves information about all Route 53 Resolver endpoints in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of ResolverEndpointInfo structs sorted by ID, or error if the operation fails
+func (s *Scanner) GetResolverEndpoints(ctx context.Context) ([]ResolverEndpointInfo, error) {
+	endpoints := []ResolverEndpointInfo{}
+
+	input := &route53resolver.ListResolverEndpointsInput{}
+	for {
+		result, err := s.resolverClient.ListResolverEndpoints(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("route53resolver", "Resolver endpoints", "ListResolverEndpoints", err)
+		}
+
+		for _, e := range result.ResolverEndpoints {
+			endpoints = append(endpoints, ResolverEndpointInfo{
+				ResolverEndpointID: aws.ToString(e.Id),
+				Arn:                aws.ToString(e.Arn),
+				Name:               aws.ToString(e.Name),
+				Direction:          string(e.Direction),
+				VpcID:              aws.ToString(e.HostVPCId),
+				SecurityGroupIDs:   append([]string{}, e.SecurityGroupIds...),
+				IpAddressCount:     aws.ToInt32(e.IpAddressCount),
+				Status:             string(e.Status),
+			})
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].ResolverEndpointID < endpoints[j].ResolverEndpointID
+	})
+	return endpoints, nil
+}
+
+// BuildReport keys the private hosted zones to the VPCs they are associated with
+// zones: Zones from GetPrivateHostedZones
+// endpoints: Resolver endpoints from GetResolverEndpoints
+// Returns: Report with the zone names of every associated VPC, sorted
+func BuildReport(zones []PrivateZoneInfo, endpoints []ResolverEndpointInfo) *Report {
+	report := &Report{
+		PrivateZones:      zones,
+		ZonesByVPC:        make(map[string][]string),
+		ResolverEndpoints: endpoints,
+	}
+	if report.PrivateZones == nil {
+		report.PrivateZones = []PrivateZoneInfo{}
+	}
+	if report.ResolverEndpoints == nil {
+		report.ResolverEndpoints = []ResolverEndpointInfo{}
+	}
+
+	for _, zone := range report.PrivateZones {
+		for _, association := range zone.VPCs {
+			report.ZonesByVPC[association.VpcID] = append(report.ZonesByVPC[association.VpcID], zone.Name)
+		}
+	}
+	for vpcID := range report.ZonesByVPC {
+		sort.Strings(report.ZonesByVPC[vpcID])
+	}
+	return report
+}
+
+// trimZoneID removes the /hostedzone/ prefix Route 53 puts on zone IDs
+func trimZoneID(id string) string {
+	return strings.TrimPrefix(id, "/hostedzone/")
+}
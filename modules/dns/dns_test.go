@@ -0,0 +1,233 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/vpc"
+)
+
+// newTestScanner returns a scanner whose Route 53 and Resolver endpoints answer from responses
+// Route 53 requests are keyed by path, followed by the VPC ID and page parameter (marker, start record
+// name or next token) when set; Resolver requests by operation and NextToken. Requests without an entry are denied.
+// responses: Response bodies by request key
+func newTestScanner(t *testing.T, responses map[string]string) *Scanner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, operation, ok := strings.Cut(r.Header.Get("X-Amz-Target"), "."); ok {
+			var input struct{ NextToken string }
+			json.NewDecoder(r.Body).Decode(&input)
+			key := strings.TrimSpace(operation + " " + input.NextToken)
+
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			response, ok := responses[key]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"__type":"AccessDeniedException","message":"not authorized"}`)
+				return
+			}
+			fmt.Fprint(w, response)
+			return
+		}
+
+		key := r.URL.Path
+		for _, param := range []string{"vpcid", "marker", "name", "nexttoken"} {
+			if value := r.URL.Query().Get(param); value != "" {
+				key += " " + value
+			}
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		response, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error><RequestId>req-1</RequestId></ErrorResponse>`)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewScanner(aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+}
+
+// hostedZone returns a HostedZone element of a ListHostedZones or GetHostedZone response
+func hostedZone(id, name string, private bool, records int) string {
+	return fmt.Sprintf(`<HostedZone><Id>/hostedzone/%s</Id><Name>%s</Name><CallerReference>ref-%s</CallerReference>
+		<Config><Comment>zone %s</Comment><PrivateZone>%v</PrivateZone></Config><ResourceRecordSetCount>%d</ResourceRecordSetCount></HostedZone>`,
+		id, name, id, name, private, records)
+}
+
+// zoneResponses are two pages of hosted zones with a public zone, a private zone whose records span two
+// pages, and a private zone associated with a VPC of another account; vpc-0a1 also resolves a zone of
+// another account and vpc-0b2 pages through its zones
+var zoneResponses = map[string]string{
+	"/2013-04-01/hostedzone": `<ListHostedZonesResponse><HostedZones>` +
+		hostedZone("Z0PUBLIC", "example.com.", false, 2) + hostedZone("Z0CORP", "corp.internal.", true, 4) +
+		`</HostedZones><Marker></Marker><IsTruncated>true</IsTruncated><NextMarker>Z0SHARED</NextMarker><MaxItems>2</MaxItems></ListHostedZonesResponse>`,
+	"/2013-04-01/hostedzone Z0SHARED": `<ListHostedZonesResponse><HostedZones>` +
+		hostedZone("Z0SHARED", "shared.internal.", true, 2) +
+		`</HostedZones><Marker>Z0SHARED</Marker><IsTruncated>false</IsTruncated><MaxItems>2</MaxItems></ListHostedZonesResponse>`,
+
+	"/2013-04-01/hostedzone/Z0CORP": `<GetHostedZoneResponse>` + hostedZone("Z0CORP", "corp.internal.", true, 4) +
+		`<VPCs><VPC><VPCRegion>eu-west-1</VPCRegion><VPCId>vpc-0a1</VPCId></VPC><VPC><VPCRegion>us-east-1</VPCRegion><VPCId>vpc-0us</VPCId></VPC></VPCs></GetHostedZoneResponse>`,
+	"/2013-04-01/hostedzone/Z0SHARED": `<GetHostedZoneResponse>` + hostedZone("Z0SHARED", "shared.internal.", true, 2) +
+		`<VPCs><VPC><VPCRegion>eu-west-1</VPCRegion><VPCId>vpc-0b2</VPCId></VPC><VPC><VPCRegion>eu-west-1</VPCRegion><VPCId>vpc-0partner</VPCId></VPC></VPCs></GetHostedZoneResponse>`,
+
+	"/2013-04-01/hostedzone/Z0CORP/rrset": `<ListResourceRecordSetsResponse><ResourceRecordSets>
+		<ResourceRecordSet><Name>corp.internal.</Name><Type>SOA</Type><TTL>900</TTL></ResourceRecordSet>
+		<ResourceRecordSet><Name>corp.internal.</Name><Type>NS</Type><TTL>172800</TTL></ResourceRecordSet>
+		</ResourceRecordSets><IsTruncated>true</IsTruncated><NextRecordName>api.corp.internal.</NextRecordName><NextRecordType>A</NextRecordType><MaxItems>2</MaxItems></ListResourceRecordSetsResponse>`,
+	"/2013-04-01/hostedzone/Z0CORP/rrset api.corp.internal.": `<ListResourceRecordSetsResponse><ResourceRecordSets>
+		<ResourceRecordSet><Name>api.corp.internal.</Name><Type>A</Type><AliasTarget><HostedZoneId>Z0ELB</HostedZoneId>
+			<DNSName>internal-api-123.eu-west-1.elb.amazonaws.com.</DNSName><EvaluateTargetHealth>false</EvaluateTargetHealth></AliasTarget></ResourceRecordSet>
+		<ResourceRecordSet><Name>db.corp.internal.</Name><Type>A</Type><TTL>300</TTL></ResourceRecordSet>
+		</ResourceRecordSets><IsTruncated>false</IsTruncated><MaxItems>2</MaxItems></ListResourceRecordSetsResponse>`,
+	"/2013-04-01/hostedzone/Z0SHARED/rrset": `<ListResourceRecordSetsResponse><ResourceRecordSets>
+		<ResourceRecordSet><Name>shared.internal.</Name><Type>SOA</Type><TTL>900</TTL></ResourceRecordSet>
+		<ResourceRecordSet><Name>shared.internal.</Name><Type>NS</Type><TTL>172800</TTL></ResourceRecordSet>
+		</ResourceRecordSets><IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListResourceRecordSetsResponse>`,
+
+	"/2013-04-01/hostedzonesbyvpc vpc-0a1": `<ListHostedZonesByVPCResponse><HostedZoneSummaries>
+		<HostedZoneSummary><HostedZoneId>Z0CORP</HostedZoneId><Name>corp.internal.</Name><Owner><OwningAccount>111122223333</OwningAccount></Owner></HostedZoneSummary>
+		<HostedZoneSummary><HostedZoneId>Z0HUB</HostedZoneId><Name>hub.internal.</Name><Owner><OwningAccount>444455556666</OwningAccount></Owner></HostedZoneSummary>
+		</HostedZoneSummaries><MaxItems>100</MaxItems></ListHostedZonesByVPCResponse>`,
+	"/2013-04-01/hostedzonesbyvpc vpc-0b2": `<ListHostedZonesByVPCResponse><HostedZoneSummaries>
+		<HostedZoneSummary><HostedZoneId>Z0SHARED</HostedZoneId><Name>shared.internal.</Name><Owner><OwningAccount>111122223333</OwningAccount></Owner></HostedZoneSummary>
+		</HostedZoneSummaries><MaxItems>1</MaxItems><NextToken>page-2</NextToken></ListHostedZonesByVPCResponse>`,
+	"/2013-04-01/hostedzonesbyvpc vpc-0b2 page-2": `<ListHostedZonesByVPCResponse><HostedZoneSummaries>
+		<HostedZoneSummary><HostedZoneId>Z0HUB</HostedZoneId><Name>hub.internal.</Name><Owner><OwningAccount>444455556666</OwningAccount></Owner></HostedZoneSummary>
+		<HostedZoneSummary><HostedZoneId>Z0RDS</HostedZoneId><Name>rds.internal.</Name><Owner><OwningService>rds.amazonaws.com</OwningService></Owner></HostedZoneSummary>
+		</HostedZoneSummaries><MaxItems>1</MaxItems></ListHostedZonesByVPCResponse>`,
+}
+
+// TestGetPrivateHostedZones checks paginated zone and record listings, associations in other regions and
+// accounts, and zones of other accounts found through the scanned VPCs
+func TestGetPrivateHostedZones(t *testing.T) {
+	tests := []struct {
+		name           string
+		includeRecords bool
+		wantRecords    []RecordSummary // Records of corp.internal
+	}{
+		{name: "counts only"},
+		{name: "with records", includeRecords: true, wantRecords: []RecordSummary{
+			{Name: "corp.internal.", Type: "SOA"},
+			{Name: "corp.internal.", Type: "NS"},
+			{Name: "api.corp.internal.", Type: "A", AliasTarget: "internal-api-123.eu-west-1.elb.amazonaws.com."},
+			{Name: "db.corp.internal.", Type: "A"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := newTestScanner(t, zoneResponses)
+			got, err := scanner.GetPrivateHostedZones(context.Background(), "eu-west-1", []string{"vpc-0a1", "vpc-0b2"}, tt.includeRecords)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sharedRecords []RecordSummary
+			if tt.includeRecords {
+				sharedRecords = []RecordSummary{{Name: "shared.internal.", Type: "SOA"}, {Name: "shared.internal.", Type: "NS"}}
+			}
+			want := []PrivateZoneInfo{
+				{HostedZoneID: "Z0CORP", Arn: "arn:aws:route53:::hostedzone/Z0CORP", Name: "corp.internal.", Comment: "zone corp.internal.",
+					VPCs:        []ZoneAssociation{{VpcID: "vpc-0a1", VpcRegion: "eu-west-1"}, {VpcID: "vpc-0us", VpcRegion: "us-east-1"}},
+					RecordCount: 4, RecordsByType: map[string]int{"SOA": 1, "NS": 1, "A": 2}, Records: tt.wantRecords},
+				{HostedZoneID: "Z0HUB", Arn: "arn:aws:route53:::hostedzone/Z0HUB", Name: "hub.internal.", CrossAccount: true, OwningAccount: "444455556666",
+					VPCs:          []ZoneAssociation{{VpcID: "vpc-0a1", VpcRegion: "eu-west-1"}, {VpcID: "vpc-0b2", VpcRegion: "eu-west-1"}},
+					RecordsByType: map[string]int{}},
+				{HostedZoneID: "Z0RDS", Arn: "arn:aws:route53:::hostedzone/Z0RDS", Name: "rds.internal.", CrossAccount: true, OwningService: "rds.amazonaws.com",
+					VPCs: []ZoneAssociation{{VpcID: "vpc-0b2", VpcRegion: "eu-west-1"}}, RecordsByType: map[string]int{}},
+				{HostedZoneID: "Z0SHARED", Arn: "arn:aws:route53:::hostedzone/Z0SHARED", Name: "shared.internal.", Comment: "zone shared.internal.",
+					VPCs:        []ZoneAssociation{{VpcID: "vpc-0b2", VpcRegion: "eu-west-1"}, {VpcID: "vpc-0partner", VpcRegion: "eu-west-1"}},
+					RecordCount: 2, RecordsByType: map[string]int{"SOA": 1, "NS": 1}, Records: sharedRecords},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GetPrivateHostedZones() = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestGetResolverEndpoints checks that Resolver endpoints from every page are returned sorted by ID
+func TestGetResolverEndpoints(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{
+		"ListResolverEndpoints": `{"NextToken": "page-2", "ResolverEndpoints": [{"Id": "rslvr-out-0b2", "Arn": "arn:aws:route53resolver:eu-west-1:111122223333:resolver-endpoint/rslvr-out-0b2",
+			"Name": "to-onprem", "Direction": "OUTBOUND", "HostVPCId": "vpc-0hub", "SecurityGroupIds": ["sg-0dns"], "IpAddressCount": 2, "Status": "OPERATIONAL"}]}`,
+		"ListResolverEndpoints page-2": `{"ResolverEndpoints": [{"Id": "rslvr-in-0a1", "Direction": "INBOUND", "HostVPCId": "vpc-0hub", "IpAddressCount": 2, "Status": "CREATING"}]}`,
+	})
+	got, err := scanner.GetResolverEndpoints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResolverEndpointInfo{
+		{ResolverEndpointID: "rslvr-in-0a1", Direction: DirectionInbound, VpcID: "vpc-0hub", SecurityGroupIDs: []string{}, IpAddressCount: 2, Status: "CREATING"},
+		{ResolverEndpointID: "rslvr-out-0b2", Arn: "arn:aws:route53resolver:eu-west-1:111122223333:resolver-endpoint/rslvr-out-0b2", Name: "to-onprem",
+			Direction: DirectionOutbound, VpcID: "vpc-0hub", SecurityGroupIDs: []string{"sg-0dns"}, IpAddressCount: 2, Status: "OPERATIONAL"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetResolverEndpoints() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestScanErrors checks that denied calls return a scan error that main can classify as missing permissions
+func TestScanErrors(t *testing.T) {
+	scanner := newTestScanner(t, nil)
+	tests := []struct {
+		name      string
+		scan      func() error
+		operation string
+	}{
+		{name: "hosted zones", scan: func() error {
+			_, err := scanner.GetPrivateHostedZones(context.Background(), "eu-west-1", nil, false)
+			return err
+		}, operation: "ListHostedZones"},
+		{name: "Resolver endpoints", scan: func() error { _, err := scanner.GetResolverEndpoints(context.Background()); return err }, operation: "ListResolverEndpoints"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scan()
+			var scanErr *vpc.ScanError
+			if !errors.As(err, &scanErr) || scanErr.Operation != tt.operation {
+				t.Fatalf("error = %v, want a scan error of %s", err, tt.operation)
+			}
+			if !errors.Is(err, vpc.ErrAccessDenied) {
+				t.Errorf("error = %v, want access denied", err)
+			}
+		})
+	}
+}
+
+// TestBuildReport checks that every associated VPC lists the names of its zones, sorted
+func TestBuildReport(t *testing.T) {
+	zones := []PrivateZoneInfo{
+		{Name: "corp.internal.", VPCs: []ZoneAssociation{{VpcID: "vpc-0a1"}, {VpcID: "vpc-0b2"}}},
+		{Name: "apps.internal.", VPCs: []ZoneAssociation{{VpcID: "vpc-0b2"}}},
+		{Name: "unused.internal.", VPCs: []ZoneAssociation{}},
+	}
+	report := BuildReport(zones, nil)
+	want := map[string][]string{"vpc-0a1": {"corp.internal."}, "vpc-0b2": {"apps.internal.", "corp.internal."}}
+	if !reflect.DeepEqual(report.ZonesByVPC, want) {
+		t.Errorf("ZonesByVPC = %v, want %v", report.ZonesByVPC, want)
+	}
+	if report.ResolverEndpoints == nil {
+		t.Error("ResolverEndpoints is nil, want an empty list")
+	}
+	if empty := BuildReport(nil, nil); empty.PrivateZones == nil || len(empty.ZonesByVPC) != 0 {
+		t.Errorf("BuildReport(nil, nil) = %+v, want empty lists", empty)
+	}
+}
@@ -0,0 +1,100 @@
+package dns
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+
+	"aws-documentor/modules/vpc"
+)
+
+// PublicRecord is an address record of a public hosted zone
+type PublicRecord struct {
+	HostedZoneID string   `json:"hosted_zone_id"` // ID of the public hosted zone (without the /hostedzone/ prefix)
+	Name         string   `json:"name"`           // Record name, without the trailing dot
+	Type         string   `json:"type"`           // A or AAAA
+	Values       []string `json:"values"`         // Addresses of the record (empty for alias records)
+	AliasTarget  string   `json:"alias_target"`   // DNS name of the alias target, such as a load balancer (empty for plain records)
+}
+
+// GetPublicRecords retrieves the A and AAAA records of the account's public hosted zones
+// Only address records can point at a public IP, directly or through an alias to a load balancer,
+// accelerator or CloudFront distribution; other types are skipped.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Records sorted by name, or error if the operation fails
+func (s *Scanner) GetPublicRecords(ctx context.Context) ([]PublicRecord, error) {
+	records := []PublicRecord{}
+
+	input := &route53.ListHostedZonesInput{}
+	for {
+		result, err := s.route53Client.ListHostedZones(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("route53", "hosted zones", "ListHostedZones", err)
+		}
+
+		for _, z := range result.HostedZones {
+			if z.Config != nil && z.Config.PrivateZone {
+				continue
+			}
+			zoneRecords, err := s.addressRecords(ctx, trimZoneID(aws.ToString(z.Id)))
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, zoneRecords...)
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		input.Marker = result.NextMarker
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+	return records, nil
+}
+
+// addressRecords lists the A and AAAA records of one zone
+func (s *Scanner) addressRecords(ctx context.Context, zoneID string) ([]PublicRecord, error) {
+	var records []PublicRecord
+
+	input := &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneID)}
+	for {
+		result, err := s.route53Client.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("route53", "records of hosted zone "+zoneID, "ListResourceRecordSets", err)
+		}
+
+		for _, record := range result.ResourceRecordSets {
+			if record.Type != route53types.RRTypeA && record.Type != route53types.RRTypeAaaa {
+				continue
+			}
+			summary := PublicRecord{
+				HostedZoneID: zoneID,
+				Name:         strings.TrimSuffix(aws.ToString(record.Name), "."),
+				Type:         string(record.Type),
+				Values:       []string{},
+			}
+			for _, value := range record.ResourceRecords {
+				summary.Values = append(summary.Values, aws.ToString(value.Value))
+			}
+			if record.AliasTarget != nil {
+				summary.AliasTarget = strings.TrimSuffix(aws.ToString(record.AliasTarget.DNSName), ".")
+			}
+			records = append(records, summary)
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		input.StartRecordName = result.NextRecordName
+		input.StartRecordType = result.NextRecordType
+		input.StartRecordIdentifier = result.NextRecordIdentifier
+	}
+	return records, nil
+}
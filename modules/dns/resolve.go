@@ -0,0 +1,228 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of a lookup in LookupResult.Status
+const (
+	LookupResolved = "resolved"  // The lookup returned at least one name or address
+	LookupNotFound = "not-found" // NXDOMAIN, or the name exists without records of the type
+	LookupTimeout  = "timeout"   // No answer within the timeout
+	LookupFailed   = "failed"    // Any other error, such as a refused query or an unreachable server
+)
+
+// Resolver performs the lookups of the enrichment; *net.Resolver implements it
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error) // Reverse lookup: PTR names of an address
+	LookupHost(ctx context.Context, host string) ([]string, error) // Forward lookup: addresses of a name
+}
+
+// LookupOptions configure the lookups of -resolve-dns
+type LookupOptions struct {
+	Server      string        // DNS server as host or host:port ("" for the system resolver)
+	Timeout     time.Duration // Timeout of each lookup
+	Concurrency int           // Lookups running at the same time
+}
+
+// DefaultLookupOptions returns the options used without -dns-server, -dns-timeout and -dns-concurrency
+func DefaultLookupOptions() LookupOptions {
+	return LookupOptions{Timeout: 2 * time.Second, Concurrency: 8}
+}
+
+// LookupResult is the outcome of one reverse or forward lookup
+type LookupResult struct {
+	Names  []string `json:"names"`  // PTR names without the trailing dot, or addresses, sorted
+	Status string   `json:"status"` // resolved, not-found, timeout or failed
+	Error  string   `json:"error"`  // Error of a failed lookup (empty otherwise)
+}
+
+// NewResolver returns the resolver of the given DNS server
+// server: Host or host:port of the server; port 53 is assumed ("" for the system resolver)
+func NewResolver(server string) Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// Lookups resolves addresses and names with bounded concurrency and caches the results for the run
+// A lookup never returns an error: failures are recorded in the result, so the enrichment cannot
+// fail a scan.
+type Lookups struct {
+	resolver Resolver
+	opts     LookupOptions
+	mu       sync.Mutex
+	reverse  map[string]LookupResult // Results of reverse lookups by address
+	forward  map[string]LookupResult // Results of forward lookups by name
+}
+
+// NewLookups prepares the lookups of a run
+// resolver: Resolver to query, such as NewResolver(opts.Server)
+// opts: Timeout and concurrency; values of zero or less take the defaults
+func NewLookups(resolver Resolver, opts LookupOptions) *Lookups {
+	defaults := DefaultLookupOptions()
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaults.Concurrency
+	}
+	return &Lookups{
+		resolver: resolver,
+		opts:     opts,
+		reverse:  make(map[string]LookupResult),
+		forward:  make(map[string]LookupResult),
+	}
+}
+
+// Reverse looks up the PTR names of addresses
+// ctx: Context of the run; lookups still pending when it is canceled time out
+// addrs: Addresses to look up; duplicates and addresses looked up before are answered from the cache
+// Returns: Result of every address
+func (l *Lookups) Reverse(ctx context.Context, addrs []string) map[string]LookupResult {
+	return l.run(ctx, addrs, l.reverse, l.resolver.LookupAddr)
+}
+
+// Forward looks up the addresses of names
+// ctx: Context of the run; lookups still pending when it is canceled time out
+// hosts: Names to look up; duplicates and names looked up before are answered from the cache
+// Returns: Result of every name
+func (l *Lookups) Forward(ctx context.Context, hosts []string) map[string]LookupResult {
+	return l.run(ctx, hosts, l.forward, l.resolver.LookupHost)
+}
+
+// run performs the lookups that are not cached yet, at most opts.Concurrency at a time
+func (l *Lookups) run(ctx context.Context, keys []string, cache map[string]LookupResult, lookup func(context.Context, string) ([]string, error)) map[string]LookupResult {
+	var pending []string
+	seen := make(map[string]bool)
+	l.mu.Lock()
+	for _, key := range keys {
+		if _, ok := cache[key]; !ok && !seen[key] {
+			seen[key] = true
+			pending = append(pending, key)
+		}
+	}
+	l.mu.Unlock()
+
+	slots := make(chan struct{}, l.opts.Concurrency)
+	var wg sync.WaitGroup
+	for _, key := range pending {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(key string) {
+			defer func() { <-slots; wg.Done() }()
+			lookupCtx, cancel := context.WithTimeout(ctx, l.opts.Timeout)
+			defer cancel()
+			names, err := lookup(lookupCtx, key)
+			result := lookupResult(names, err)
+			l.mu.Lock()
+			cache[key] = result
+			l.mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+
+	results := make(map[string]LookupResult, len(keys))
+	l.mu.Lock()
+	for _, key := range keys {
+		results[key] = cache[key]
+	}
+	l.mu.Unlock()
+	return results
+}
+
+// lookupResult classifies the answer of a lookup
+func lookupResult(names []string, err error) LookupResult {
+	result := LookupResult{Names: []string{}, Status: LookupResolved}
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		result.Status = LookupNotFound
+		return result
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled), errors.As(err, &dnsErr) && dnsErr.IsTimeout:
+		result.Status = LookupTimeout
+		return result
+	default:
+		result.Status = LookupFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			result.Names = append(result.Names, name)
+		}
+	}
+	sort.Strings(result.Names)
+	if len(result.Names) == 0 {
+		result.Status = LookupNotFound
+	}
+	return result
+}
+
+// MatchRecords finds the public records that point at each address
+// A record matches an address it lists, or an address its alias target resolved to, so records
+// aliasing a load balancer match the current addresses of its nodes.
+// records: Records from GetPublicRecords
+// aliases: Forward lookups of the alias targets of the records (nil if they were not resolved)
+// Returns: Descriptions such as "www.example.com (A)" or "api.example.com (alias to my-lb-1.eu-west-1.elb.amazonaws.com)" keyed by address, sorted
+func MatchRecords(records []PublicRecord, aliases map[string]LookupResult) map[string][]string {
+	matches := make(map[string][]string)
+	add := func(addr, description string) {
+		for _, existing := range matches[addr] {
+			if existing == description {
+				return
+			}
+		}
+		matches[addr] = append(matches[addr], description)
+	}
+
+	for _, record := range records {
+		for _, value := range record.Values {
+			add(value, record.Name+" ("+record.Type+")")
+		}
+		if record.AliasTarget != "" {
+			for _, addr := range aliases[record.AliasTarget].Names {
+				add(addr, record.Name+" (alias to "+record.AliasTarget+")")
+			}
+		}
+	}
+	for addr := range matches {
+		sort.Strings(matches[addr])
+	}
+	return matches
+}
+
+// AliasTargets lists the distinct alias targets of the records, for a forward lookup
+func AliasTargets(records []PublicRecord) []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, record := range records {
+		if record.AliasTarget != "" && !seen[record.AliasTarget] {
+			seen[record.AliasTarget] = true
+			targets = append(targets, record.AliasTarget)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
@@ -0,0 +1,225 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/vpc"
+)
+
+// stubResolver answers lookups from fixed results and records how many run at once
+type stubResolver struct {
+	answers map[string]func(ctx context.Context) ([]string, error) // Answer by address or name; missing keys are NXDOMAIN
+	delay   time.Duration                                          // How long every lookup takes
+
+	mu      sync.Mutex
+	calls   map[string]int // Lookups by address or name
+	running int
+	peak    int // Most lookups running at the same time
+}
+
+func (s *stubResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return s.lookup(ctx, addr)
+}
+
+func (s *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return s.lookup(ctx, host)
+}
+
+// lookup records the call and answers it after the delay
+func (s *stubResolver) lookup(ctx context.Context, key string) ([]string, error) {
+	s.mu.Lock()
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[key]++
+	s.running++
+	s.peak = max(s.peak, s.running)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+	}()
+
+	time.Sleep(s.delay)
+	if answer, ok := s.answers[key]; ok {
+		return answer(ctx)
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: key, IsNotFound: true}
+}
+
+// answer returns a lookup answering with names
+func answer(names ...string) func(context.Context) ([]string, error) {
+	return func(context.Context) ([]string, error) { return names, nil }
+}
+
+// hang returns a lookup that answers only when its context ends
+func hang(ctx context.Context) ([]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestLookupResults checks how answers, NXDOMAIN, timeouts and other errors are recorded
+func TestLookupResults(t *testing.T) {
+	resolver := &stubResolver{answers: map[string]func(context.Context) ([]string, error){
+		"203.0.113.10": answer("ec2-203-0-113-10.eu-west-1.compute.amazonaws.com."),
+		"203.0.113.11": answer("www.example.com.", "api.example.com.", "www.example.com", "api.example.com."),
+		"203.0.113.12": answer(),
+		"203.0.113.14": hang,
+		"203.0.113.15": func(context.Context) ([]string, error) {
+			return nil, &net.DNSError{Err: "i/o timeout", Name: "203.0.113.15", IsTimeout: true}
+		},
+		"203.0.113.16": func(context.Context) ([]string, error) {
+			return nil, &net.DNSError{Err: "server misbehaving", Name: "203.0.113.16", Server: "10.0.0.2:53"}
+		},
+	}}
+	lookups := NewLookups(resolver, LookupOptions{Timeout: 50 * time.Millisecond})
+	got := lookups.Reverse(context.Background(), []string{
+		"203.0.113.10", "203.0.113.11", "203.0.113.12", "203.0.113.13", "203.0.113.14", "203.0.113.15", "203.0.113.16",
+	})
+	want := map[string]LookupResult{
+		"203.0.113.10": {Names: []string{"ec2-203-0-113-10.eu-west-1.compute.amazonaws.com"}, Status: LookupResolved},
+		"203.0.113.11": {Names: []string{"api.example.com", "www.example.com"}, Status: LookupResolved},
+		"203.0.113.12": {Names: []string{}, Status: LookupNotFound},
+		"203.0.113.13": {Names: []string{}, Status: LookupNotFound},
+		"203.0.113.14": {Names: []string{}, Status: LookupTimeout},
+		"203.0.113.15": {Names: []string{}, Status: LookupTimeout},
+		"203.0.113.16": {Names: []string{}, Status: LookupFailed, Error: "lookup 203.0.113.16 on 10.0.0.2:53: server misbehaving"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reverse() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// TestLookupsCanceled checks that lookups pending when the run is canceled time out instead of failing
+func TestLookupsCanceled(t *testing.T) {
+	resolver := &stubResolver{answers: map[string]func(context.Context) ([]string, error){"api.example.com": hang}}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	got := NewLookups(resolver, LookupOptions{Timeout: time.Minute}).Forward(ctx, []string{"api.example.com"})
+	if status := got["api.example.com"].Status; status != LookupTimeout {
+		t.Errorf("status = %q (%s), want %q", status, got["api.example.com"].Error, LookupTimeout)
+	}
+}
+
+// TestLookupsCacheAndConcurrency checks that every address is looked up once per run, duplicates included, and
+// that no more than the configured number of lookups run at once
+func TestLookupsCacheAndConcurrency(t *testing.T) {
+	resolver := &stubResolver{delay: 5 * time.Millisecond}
+	lookups := NewLookups(resolver, LookupOptions{Timeout: time.Second, Concurrency: 3})
+
+	var addrs []string
+	for i := 0; i < 20; i++ {
+		addrs = append(addrs, net.IPv4(198, 51, 100, byte(i)).String())
+	}
+	first := lookups.Reverse(context.Background(), append(addrs, addrs[:5]...))
+	second := lookups.Reverse(context.Background(), addrs[10:])
+	if len(first) != 20 || len(second) != 10 {
+		t.Errorf("results = %d and %d, want 20 and 10", len(first), len(second))
+	}
+	for _, addr := range addrs {
+		if resolver.calls[addr] != 1 {
+			t.Errorf("%s looked up %d times, want once", addr, resolver.calls[addr])
+		}
+	}
+	if resolver.peak > 3 || resolver.peak < 2 {
+		t.Errorf("peak concurrency = %d, want at most 3", resolver.peak)
+	}
+
+	// Forward lookups have a cache of their own
+	lookups.Forward(context.Background(), []string{addrs[0]})
+	if resolver.calls[addrs[0]] != 2 {
+		t.Errorf("forward lookup of %s answered from the reverse cache", addrs[0])
+	}
+}
+
+// TestNewLookupsDefaults checks that options of zero or less take the defaults
+func TestNewLookupsDefaults(t *testing.T) {
+	lookups := NewLookups(&stubResolver{}, LookupOptions{Timeout: -time.Second})
+	if !reflect.DeepEqual(lookups.opts, DefaultLookupOptions()) {
+		t.Errorf("options = %+v, want %+v", lookups.opts, DefaultLookupOptions())
+	}
+	if _, ok := NewResolver("").(*net.Resolver); !ok {
+		t.Error("NewResolver(\"\") is not a net.Resolver")
+	}
+}
+
+// TestMatchRecords checks that plain and alias records are matched to addresses, without duplicates, and that
+// aliases that were not resolved match nothing
+func TestMatchRecords(t *testing.T) {
+	records := []PublicRecord{
+		{Name: "api.example.com", Type: "A", Values: []string{}, AliasTarget: "dualstack.web-1.eu-west-1.elb.amazonaws.com"},
+		{Name: "api.example.com", Type: "AAAA", Values: []string{}, AliasTarget: "dualstack.web-1.eu-west-1.elb.amazonaws.com"},
+		{Name: "bastion.example.com", Type: "A", Values: []string{"203.0.113.10"}},
+		{Name: "vpn.example.com", Type: "A", Values: []string{"203.0.113.10", "203.0.113.11"}},
+		{Name: "old.example.com", Type: "A", Values: []string{}, AliasTarget: "gone-1.eu-west-1.elb.amazonaws.com"},
+		{Name: "cdn.example.com", Type: "A", Values: []string{}, AliasTarget: "d111111abcdef8.cloudfront.net"},
+	}
+	if got, want := AliasTargets(records), []string{"d111111abcdef8.cloudfront.net", "dualstack.web-1.eu-west-1.elb.amazonaws.com", "gone-1.eu-west-1.elb.amazonaws.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AliasTargets() = %v, want %v", got, want)
+	}
+
+	aliases := map[string]LookupResult{
+		"dualstack.web-1.eu-west-1.elb.amazonaws.com": {Names: []string{"198.51.100.20", "198.51.100.21", "2001:db8::20"}, Status: LookupResolved},
+		"gone-1.eu-west-1.elb.amazonaws.com":          {Names: []string{}, Status: LookupNotFound},
+	}
+	want := map[string][]string{
+		"203.0.113.10":  {"bastion.example.com (A)", "vpn.example.com (A)"},
+		"203.0.113.11":  {"vpn.example.com (A)"},
+		"198.51.100.20": {"api.example.com (alias to dualstack.web-1.eu-west-1.elb.amazonaws.com)"},
+		"198.51.100.21": {"api.example.com (alias to dualstack.web-1.eu-west-1.elb.amazonaws.com)"},
+		"2001:db8::20":  {"api.example.com (alias to dualstack.web-1.eu-west-1.elb.amazonaws.com)"},
+	}
+	if got := MatchRecords(records, aliases); !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchRecords() =\n%v\nwant\n%v", got, want)
+	}
+	if got := MatchRecords(records[:1], nil); len(got) != 0 {
+		t.Errorf("MatchRecords() without alias lookups = %v, want none", got)
+	}
+}
+
+// TestGetPublicRecords checks that the address records of public zones are listed across pages, and that
+// private zones and other record types are skipped
+func TestGetPublicRecords(t *testing.T) {
+	responses := map[string]string{
+		"/2013-04-01/hostedzone": `<ListHostedZonesResponse><HostedZones>` +
+			hostedZone("Z0PUBLIC", "example.com.", false, 5) + hostedZone("Z0CORP", "corp.internal.", true, 4) +
+			`</HostedZones><Marker></Marker><IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListHostedZonesResponse>`,
+		"/2013-04-01/hostedzone/Z0PUBLIC/rrset": `<ListResourceRecordSetsResponse><ResourceRecordSets>
+			<ResourceRecordSet><Name>example.com.</Name><Type>NS</Type><TTL>172800</TTL></ResourceRecordSet>
+			<ResourceRecordSet><Name>vpn.example.com.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords>
+				<ResourceRecord><Value>203.0.113.10</Value></ResourceRecord><ResourceRecord><Value>203.0.113.11</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+			</ResourceRecordSets><IsTruncated>true</IsTruncated><NextRecordName>api.example.com.</NextRecordName><NextRecordType>A</NextRecordType><MaxItems>2</MaxItems></ListResourceRecordSetsResponse>`,
+		"/2013-04-01/hostedzone/Z0PUBLIC/rrset api.example.com.": `<ListResourceRecordSetsResponse><ResourceRecordSets>
+			<ResourceRecordSet><Name>api.example.com.</Name><Type>AAAA</Type><AliasTarget><HostedZoneId>Z32O12XQLNTSW2</HostedZoneId>
+				<DNSName>dualstack.web-1.eu-west-1.elb.amazonaws.com.</DNSName><EvaluateTargetHealth>true</EvaluateTargetHealth></AliasTarget></ResourceRecordSet>
+			<ResourceRecordSet><Name>mail.example.com.</Name><Type>MX</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>10 mx.example.com.</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+			<ResourceRecordSet><Name>www.example.com.</Name><Type>CNAME</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>api.example.com</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+			</ResourceRecordSets><IsTruncated>false</IsTruncated><MaxItems>2</MaxItems></ListResourceRecordSetsResponse>`,
+	}
+	got, err := newTestScanner(t, responses).GetPublicRecords(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PublicRecord{
+		{HostedZoneID: "Z0PUBLIC", Name: "api.example.com", Type: "AAAA", Values: []string{}, AliasTarget: "dualstack.web-1.eu-west-1.elb.amazonaws.com"},
+		{HostedZoneID: "Z0PUBLIC", Name: "vpn.example.com", Type: "A", Values: []string{"203.0.113.10", "203.0.113.11"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPublicRecords() = %+v\nwant %+v", got, want)
+	}
+
+	delete(responses, "/2013-04-01/hostedzone/Z0PUBLIC/rrset api.example.com.")
+	if _, err := newTestScanner(t, responses).GetPublicRecords(context.Background()); !errors.Is(err, vpc.ErrAccessDenied) {
+		t.Errorf("GetPublicRecords() with a denied page error = %v, want access denied", err)
+	}
+}
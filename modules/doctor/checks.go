@@ -0,0 +1,374 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"aws-documentor/modules/vpc"
+)
+
+// STSAPI is the part of the STS client the identity check uses
+type STSAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// EC2API is the part of the EC2 client the permission and region checks use
+type EC2API interface {
+	DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
+}
+
+// HTTPDoer sends HTTP requests; the shared AWS HTTP client, with its proxy settings, is one
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// credentialsRemedy is the advice for credentials that cannot be resolved or are rejected
+const credentialsRemedy = "Run \"aws configure\", set AWS_PROFILE to a profile of ~/.aws/config, or run \"aws sso login\" for an SSO profile"
+
+// CredentialsCheck resolves the credentials the scan would use
+type CredentialsCheck struct {
+	LoadErr     error                   // Error loading the AWS config, which leaves no credentials to check
+	Provider    aws.CredentialsProvider // Credentials of the AWS config (nil if none were found)
+	Profile     string                  // Profile they come from, for the output ("" for the default chain)
+	ExpiryAhead time.Duration           // Warn when the credentials expire sooner than this
+	Now         func() time.Time        // Clock (time.Now when nil)
+}
+
+// Name returns the name of the credentials check
+func (c *CredentialsCheck) Name() string { return "credentials" }
+
+// Critical returns true: nothing can be scanned without credentials
+func (c *CredentialsCheck) Critical() bool { return true }
+
+// Run retrieves the credentials and checks when they expire
+func (c *CredentialsCheck) Run(ctx context.Context) Result {
+	if c.LoadErr != nil {
+		return fail(fmt.Sprintf("the AWS config cannot be loaded: %v", c.LoadErr), "Fix the profile in ~/.aws/config and ~/.aws/credentials, or unset AWS_PROFILE to use the default credentials")
+	}
+	if c.Provider == nil {
+		return fail("no credentials found in the environment, the shared config files or the instance metadata", credentialsRemedy)
+	}
+	creds, err := c.Provider.Retrieve(ctx)
+	if err != nil {
+		return fail(fmt.Sprintf("credentials cannot be resolved: %v", err), credentialsRemedy)
+	}
+
+	source := creds.Source
+	if c.Profile != "" {
+		source = fmt.Sprintf("%s (profile %s)", source, c.Profile)
+	}
+	if creds.CanExpire {
+		left := creds.Expires.Sub(now(c.Now))
+		if left <= 0 {
+			return fail(fmt.Sprintf("credentials from %s expired %s ago", source, (-left).Round(time.Second)),
+				"Refresh them (\"aws sso login\", or new temporary credentials); AWS rejects expired credentials")
+		}
+		if left < c.ExpiryAhead {
+			return warn(fmt.Sprintf("credentials from %s expire in %s", source, left.Round(time.Second)),
+				"Refresh them before a long scan (\"aws sso login\", or new temporary credentials), or a scan may stop half-way")
+		}
+		return pass(fmt.Sprintf("credentials from %s, valid for %s", source, left.Round(time.Minute)))
+	}
+	return pass(fmt.Sprintf("long-term credentials from %s", source))
+}
+
+// IdentityCheck calls STS to learn who the credentials belong to and how fast AWS answers
+type IdentityCheck struct {
+	Client    STSAPI        // STS client of the AWS config
+	SlowAfter time.Duration // Warn when the call takes longer than this
+}
+
+// Name returns the name of the identity check
+func (c *IdentityCheck) Name() string { return "identity (STS)" }
+
+// Critical returns true: AWS rejects credentials that STS rejects
+func (c *IdentityCheck) Critical() bool { return true }
+
+// Run calls GetCallerIdentity and times it
+func (c *IdentityCheck) Run(ctx context.Context) Result {
+	start := time.Now()
+	identity, err := c.Client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	latency := time.Since(start)
+	if err != nil {
+		if errors.Is(vpc.ClassifyError(err), vpc.ErrAccessDenied) {
+			return fail(fmt.Sprintf("AWS rejected the credentials: %v", err), "The keys are invalid, deactivated or expired. "+credentialsRemedy)
+		}
+		return fail(fmt.Sprintf("STS cannot be reached: %v", err), networkRemedy)
+	}
+
+	detail := fmt.Sprintf("%s in account %s, answered in %s", aws.ToString(identity.Arn), aws.ToString(identity.Account), latency.Round(time.Millisecond))
+	if latency > c.SlowAfter {
+		return warn(detail, "AWS answers slowly; a proxy or VPN in the path may be the cause. Large accounts then take long to scan; -http-timeout may need raising")
+	}
+	return pass(detail)
+}
+
+// PermissionCheck probes whether the credentials may describe VPCs, without describing any
+type PermissionCheck struct {
+	Client EC2API // EC2 client of the AWS config
+}
+
+// Name returns the name of the permission check
+func (c *PermissionCheck) Name() string { return "ec2:DescribeVpcs permission" }
+
+// Critical returns true: the scan starts with DescribeVpcs
+func (c *PermissionCheck) Critical() bool { return true }
+
+// Run calls DescribeVpcs with DryRun, which only checks the permission
+func (c *PermissionCheck) Run(ctx context.Context) Result {
+	_, err := c.Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{DryRun: aws.Bool(true)})
+	var apiErr smithy.APIError
+	switch {
+	case err == nil, errors.As(err, &apiErr) && apiErr.ErrorCode() == "DryRunOperation":
+		return pass("the credentials may call ec2:DescribeVpcs")
+	case errors.Is(vpc.ClassifyError(err), vpc.ErrAccessDenied):
+		return fail(fmt.Sprintf("ec2:DescribeVpcs is denied: %v", err),
+			"Attach a policy allowing the ec2:Describe* calls listed under Prerequisites in the README (the AWS managed ReadOnlyAccess policy covers them), and check SCPs and permission boundaries")
+	case errors.Is(vpc.ClassifyError(err), vpc.ErrRegionDisabled):
+		return fail(fmt.Sprintf("the region is not enabled for the account: %v", err), regionRemedy)
+	}
+	return fail(fmt.Sprintf("the permission probe failed: %v", err), networkRemedy)
+}
+
+// regionRemedy is the advice for a region the account cannot use
+const regionRemedy = "Enable the region under Account > AWS Regions in the console (or \"aws account enable-region\"), or scan another with -region"
+
+// RegionCheck checks that a region is configured, exists and is enabled for the account
+// The client should call a region that is always enabled, as a disabled region rejects every call.
+type RegionCheck struct {
+	Client EC2API // EC2 client for the default region of the partition
+	Region string // Region a scan would use ("" if none is configured)
+}
+
+// Name returns the name of the region check
+func (c *RegionCheck) Name() string { return "region" }
+
+// Critical returns true: a scan needs an enabled region
+func (c *RegionCheck) Critical() bool { return true }
+
+// Run looks up the opt-in status of the region
+func (c *RegionCheck) Run(ctx context.Context) Result {
+	if c.Region == "" {
+		return fail("no region is configured", "Pass -region, set AWS_REGION, or set region in the profile of ~/.aws/config")
+	}
+	out, err := c.Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true), RegionNames: []string{c.Region}})
+	switch category := vpc.ClassifyError(err); {
+	case err == nil:
+	case category == vpc.ErrInvalidInput:
+		return fail(fmt.Sprintf("%s is not a region: %v", c.Region, err), "Check the spelling of the region, such as eu-central-1")
+	case category == vpc.ErrRegionDisabled:
+		return fail(fmt.Sprintf("%s is not enabled for the account", c.Region), regionRemedy)
+	case category == vpc.ErrAccessDenied:
+		return warn(fmt.Sprintf("the opt-in status of %s cannot be read: %v", c.Region, err), "Allow ec2:DescribeRegions to let the doctor check it; scans only need it for -all-regions")
+	default:
+		return fail(fmt.Sprintf("the opt-in status of %s cannot be read: %v", c.Region, err), networkRemedy)
+	}
+
+	if len(out.Regions) == 0 {
+		return fail(fmt.Sprintf("%s is not a region of this partition", c.Region), "Check the spelling of the region, such as eu-central-1")
+	}
+	switch status := aws.ToString(out.Regions[0].OptInStatus); status {
+	case "opt-in-not-required", "opted-in":
+		return pass(fmt.Sprintf("%s is enabled (%s)", c.Region, status))
+	default:
+		return fail(fmt.Sprintf("%s is not enabled for the account: opt-in status %s", c.Region, vpc.OrUnknown(status)), regionRemedy)
+	}
+}
+
+// networkRemedy is the advice for AWS endpoints that cannot be reached
+const networkRemedy = "Check DNS, firewalls and the proxy: pass -proxy, or set HTTPS_PROXY (and NO_PROXY) when the network requires one"
+
+// EndpointCheck checks that an AWS API endpoint can be reached, through the proxy if one is configured
+type EndpointCheck struct {
+	Client HTTPDoer // HTTP client of the AWS config
+	URL    string   // Endpoint to reach, such as https://ec2.eu-central-1.amazonaws.com
+	Proxy  string   // Proxy the client uses for the endpoint, for the output ("" for a direct connection)
+}
+
+// Name returns the name of the endpoint check
+func (c *EndpointCheck) Name() string { return "endpoint reachability" }
+
+// Critical returns true: a scan cannot work without the endpoint
+func (c *EndpointCheck) Critical() bool { return true }
+
+// Run sends an unsigned request; any HTTP answer, even an error status, shows the endpoint is reachable
+func (c *EndpointCheck) Run(ctx context.Context) Result {
+	via := "directly"
+	if c.Proxy != "" {
+		via = "through proxy " + c.Proxy
+	}
+	start := time.Now()
+	resp, err := get(ctx, c.Client, c.URL)
+	if err != nil {
+		remedy := networkRemedy
+		if c.Proxy != "" {
+			remedy = fmt.Sprintf("Check that proxy %s is up and allows CONNECT to %s, or exclude the host with NO_PROXY", c.Proxy, hostOf(c.URL))
+		}
+		return fail(fmt.Sprintf("%s cannot be reached %s: %v", c.URL, via, err), remedy)
+	}
+	resp.Body.Close()
+	return pass(fmt.Sprintf("%s reached %s in %s (HTTP %d)", c.URL, via, time.Since(start).Round(time.Millisecond), resp.StatusCode))
+}
+
+// ClockCheck compares the local clock with the Date header of an AWS response
+// Signature Version 4 rejects requests signed more than 5 minutes off the AWS clock.
+type ClockCheck struct {
+	Client    HTTPDoer         // HTTP client of the AWS config
+	URL       string           // Endpoint whose Date header is read
+	WarnAfter time.Duration    // Warn when the clocks differ by more than this
+	FailAfter time.Duration    // Fail when the clocks differ by more than this
+	Now       func() time.Time // Clock (time.Now when nil)
+}
+
+// Name returns the name of the clock check
+func (c *ClockCheck) Name() string { return "clock skew" }
+
+// Critical returns true: skewed clocks make every signed request fail
+func (c *ClockCheck) Critical() bool { return true }
+
+// Run reads the Date header and compares it with the local time halfway through the request
+func (c *ClockCheck) Run(ctx context.Context) Result {
+	sent := now(c.Now)
+	resp, err := get(ctx, c.Client, c.URL)
+	if err != nil {
+		return Result{Status: StatusSkip, Detail: fmt.Sprintf("no AWS response to compare with: %v", err)}
+	}
+	resp.Body.Close()
+	received := now(c.Now)
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return warn(fmt.Sprintf("the response from %s has no valid Date header", c.URL), "A proxy may strip the header; check the system clock by hand")
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := local.Sub(date)
+	// The header has whole seconds, so up to a second of skew is rounding
+	detail := "local clock matches the AWS clock"
+	if skew.Abs() >= time.Second {
+		detail = fmt.Sprintf("local clock %s the AWS clock by %s", aheadOrBehind(skew), skew.Abs().Round(time.Second))
+	}
+	remedy := "Synchronize the clock with NTP (for example \"timedatectl set-ntp true\", or the time settings of the OS)"
+	switch {
+	case skew.Abs() > c.FailAfter:
+		return fail(detail+"; AWS rejects signed requests that far off", remedy)
+	case skew.Abs() > c.WarnAfter:
+		return warn(detail, remedy)
+	}
+	return pass(detail)
+}
+
+// WritableCheck checks that the scan can write to its output and cache directories
+type WritableCheck struct {
+	Dirs []string // Directories to check; missing ones must be creatable
+}
+
+// Name returns the name of the write access check
+func (c *WritableCheck) Name() string { return "output directories" }
+
+// Critical returns true: a scan whose outputs cannot be written is wasted
+func (c *WritableCheck) Critical() bool { return true }
+
+// Run creates and removes a file in every directory, or in the nearest existing parent of a missing one
+func (c *WritableCheck) Run(ctx context.Context) Result {
+	var writable, problems []string
+	for _, dir := range c.Dirs {
+		existing := filepath.Clean(dir)
+		for {
+			if _, err := os.Stat(existing); err == nil || filepath.Dir(existing) == existing {
+				break
+			}
+			existing = filepath.Dir(existing)
+		}
+		if info, err := os.Stat(existing); err == nil && !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%s is a file, not a directory", existing))
+			continue
+		}
+		probe, err := os.CreateTemp(existing, ".aws-documentor-doctor-*")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s is not writable: %v", existing, err))
+			continue
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+		if existing != filepath.Clean(dir) {
+			writable = append(writable, fmt.Sprintf("%s (created by the scan in %s)", dir, existing))
+		} else {
+			writable = append(writable, dir)
+		}
+	}
+	if len(problems) > 0 {
+		return fail(strings.Join(problems, "; "), "Choose a directory you can write to, or fix its permissions (chmod/chown) or the mount's read-only flag")
+	}
+	return pass("writable: " + strings.Join(writable, ", "))
+}
+
+// VersionCheck compares the release date of the build with the current date
+// The tool cannot ask for the latest release without network access of its own, so a build older
+// than MaxAge is reported as possibly outdated.
+type VersionCheck struct {
+	Version    string           // Version of the build
+	ReleasedAt time.Time        // Release date of the version
+	MaxAge     time.Duration    // Warn when the build is older than this
+	Now        func() time.Time // Clock (time.Now when nil)
+}
+
+// Name returns the name of the version check
+func (c *VersionCheck) Name() string { return "version" }
+
+// Critical returns false: an old build still scans
+func (c *VersionCheck) Critical() bool { return false }
+
+// Run compares the age of the build with MaxAge
+func (c *VersionCheck) Run(ctx context.Context) Result {
+	age := now(c.Now).Sub(c.ReleasedAt)
+	detail := fmt.Sprintf("aws-documentor %s, released %s", c.Version, c.ReleasedAt.Format("2006-01-02"))
+	if age > c.MaxAge {
+		return warn(fmt.Sprintf("%s, %d days ago", detail, int(age.Hours()/24)),
+			"Newer releases scan more resource types and fix bugs; update to the latest release")
+	}
+	return pass(detail)
+}
+
+// get sends a GET request with the context
+func get(ctx context.Context, client HTTPDoer, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// hostOf returns the host of a URL, or the URL itself if it has none
+func hostOf(url string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	return host
+}
+
+// aheadOrBehind describes the sign of a clock difference
+func aheadOrBehind(skew time.Duration) string {
+	if skew < 0 {
+		return "is behind"
+	}
+	return "is ahead of"
+}
+
+// now returns the time of a check's clock
+func now(clock func() time.Time) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return clock()
+}
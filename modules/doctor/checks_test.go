@@ -0,0 +1,347 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+// checkNow is the clock of the tests
+var checkNow = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+// apiError returns an AWS API error with the given code
+func apiError(code string) error {
+	return &smithy.GenericAPIError{Code: code, Message: "test error"}
+}
+
+// wantResult fails the test unless a result has the status and a detail containing the fragment, and
+// has a remedy exactly when it warns or fails
+func wantResult(t *testing.T, got Result, status, detail string) {
+	t.Helper()
+	if got.Status != status || !strings.Contains(got.Detail, detail) {
+		t.Errorf("Run() = %s %q, want %s containing %q", got.Status, got.Detail, status, detail)
+	}
+	if hasRemedy := status == StatusWarn || status == StatusFail; (got.Remedy != "") != hasRemedy {
+		t.Errorf("Run() remedy = %q, want one: %v", got.Remedy, hasRemedy)
+	}
+}
+
+type fakeSTS struct {
+	delay time.Duration
+	err   error
+}
+
+func (f fakeSTS) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &sts.GetCallerIdentityOutput{Arn: aws.String("arn:aws:iam::111122223333:user/jane"), Account: aws.String("111122223333")}, nil
+}
+
+type fakeEC2 struct {
+	vpcErr     error
+	regions    []types.Region
+	regionErr  error
+	regionsReq *ec2.DescribeRegionsInput
+	dryRun     bool
+}
+
+func (f *fakeEC2) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	f.dryRun = aws.ToBool(params.DryRun)
+	if f.vpcErr != nil {
+		return nil, f.vpcErr
+	}
+	return &ec2.DescribeVpcsOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	f.regionsReq = params
+	if f.regionErr != nil {
+		return nil, f.regionErr
+	}
+	return &ec2.DescribeRegionsOutput{Regions: f.regions}, nil
+}
+
+// doerFunc turns a function into an HTTPDoer
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// answer returns an HTTPDoer answering every request with the status and Date header ("" for none)
+func answer(status int, date string) HTTPDoer {
+	return doerFunc(func(req *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		if date != "" {
+			header.Set("Date", date)
+		}
+		return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+}
+
+// unreachable returns an HTTPDoer failing every request with the error
+func unreachable(err error) HTTPDoer {
+	return doerFunc(func(req *http.Request) (*http.Response, error) { return nil, err })
+}
+
+// TestCredentialsCheck covers a config that cannot be loaded, missing and unresolvable credentials, and
+// long-term, valid, expiring and expired temporary credentials
+func TestCredentialsCheck(t *testing.T) {
+	provider := func(creds aws.Credentials, err error) aws.CredentialsProvider {
+		return aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) { return creds, err })
+	}
+	temporary := func(expires time.Time) aws.Credentials {
+		return aws.Credentials{AccessKeyID: "ASIAFAKE", Source: "SSOProvider", CanExpire: true, Expires: expires}
+	}
+	tests := []struct {
+		name       string
+		check      CredentialsCheck
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "config not loaded", check: CredentialsCheck{LoadErr: errors.New("failed to get shared config profile, dev")},
+			wantStatus: StatusFail, wantDetail: "the AWS config cannot be loaded: failed to get shared config profile, dev"},
+		{name: "no provider", check: CredentialsCheck{},
+			wantStatus: StatusFail, wantDetail: "no credentials found"},
+		{name: "not resolvable", check: CredentialsCheck{Provider: provider(aws.Credentials{}, errors.New("the SSO session has expired"))},
+			wantStatus: StatusFail, wantDetail: "credentials cannot be resolved: the SSO session has expired"},
+		{name: "long-term", check: CredentialsCheck{Provider: provider(aws.Credentials{AccessKeyID: "AKIAFAKE", Source: "SharedConfigCredentials"}, nil)},
+			wantStatus: StatusPass, wantDetail: "long-term credentials from SharedConfigCredentials"},
+		{name: "temporary", check: CredentialsCheck{Provider: provider(temporary(checkNow.Add(time.Hour)), nil), Profile: "dev", ExpiryAhead: 15 * time.Minute},
+			wantStatus: StatusPass, wantDetail: "credentials from SSOProvider (profile dev), valid for 1h0m0s"},
+		{name: "expiring", check: CredentialsCheck{Provider: provider(temporary(checkNow.Add(5*time.Minute)), nil), ExpiryAhead: 15 * time.Minute},
+			wantStatus: StatusWarn, wantDetail: "credentials from SSOProvider expire in 5m0s"},
+		{name: "expired", check: CredentialsCheck{Provider: provider(temporary(checkNow.Add(-90*time.Second)), nil), ExpiryAhead: 15 * time.Minute},
+			wantStatus: StatusFail, wantDetail: "credentials from SSOProvider expired 1m30s ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check.Now = func() time.Time { return checkNow }
+			wantResult(t, tt.check.Run(context.Background()), tt.wantStatus, tt.wantDetail)
+		})
+	}
+}
+
+// TestIdentityCheck covers rejected credentials, an unreachable STS, and fast and slow answers
+func TestIdentityCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     fakeSTS
+		slowAfter  time.Duration
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "rejected", client: fakeSTS{err: apiError("InvalidClientTokenId")}, slowAfter: time.Hour,
+			wantStatus: StatusFail, wantDetail: "AWS rejected the credentials"},
+		{name: "expired token", client: fakeSTS{err: apiError("ExpiredToken")}, slowAfter: time.Hour,
+			wantStatus: StatusFail, wantDetail: "AWS rejected the credentials"},
+		{name: "unreachable", client: fakeSTS{err: errors.New("dial tcp: lookup sts.amazonaws.com: no such host")}, slowAfter: time.Hour,
+			wantStatus: StatusFail, wantDetail: "STS cannot be reached"},
+		{name: "fast", client: fakeSTS{}, slowAfter: time.Hour,
+			wantStatus: StatusPass, wantDetail: "arn:aws:iam::111122223333:user/jane in account 111122223333, answered in"},
+		{name: "slow", client: fakeSTS{delay: 20 * time.Millisecond}, slowAfter: 10 * time.Millisecond,
+			wantStatus: StatusWarn, wantDetail: "in account 111122223333, answered in"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &IdentityCheck{Client: tt.client, SlowAfter: tt.slowAfter}
+			wantResult(t, check.Run(context.Background()), tt.wantStatus, tt.wantDetail)
+		})
+	}
+}
+
+// TestPermissionCheck covers an allowed dry run, a denied call, a disabled region and other errors
+func TestPermissionCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "dry run allowed", err: apiError("DryRunOperation"), wantStatus: StatusPass, wantDetail: "may call ec2:DescribeVpcs"},
+		{name: "no error", wantStatus: StatusPass, wantDetail: "may call ec2:DescribeVpcs"},
+		{name: "denied", err: apiError("UnauthorizedOperation"), wantStatus: StatusFail, wantDetail: "ec2:DescribeVpcs is denied"},
+		{name: "region disabled", err: apiError("OptInRequired"), wantStatus: StatusFail, wantDetail: "the region is not enabled"},
+		{name: "network", err: errors.New("connection reset by peer"), wantStatus: StatusFail, wantDetail: "the permission probe failed: connection reset by peer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeEC2{vpcErr: tt.err}
+			check := &PermissionCheck{Client: client}
+			wantResult(t, check.Run(context.Background()), tt.wantStatus, tt.wantDetail)
+			if !client.dryRun {
+				t.Error("DescribeVpcs was called without DryRun")
+			}
+		})
+	}
+}
+
+// TestRegionCheck covers a missing, misspelt, unknown and disabled region, the lookup failing, and enabled regions
+func TestRegionCheck(t *testing.T) {
+	region := func(status string) []types.Region {
+		return []types.Region{{RegionName: aws.String("me-central-1"), OptInStatus: aws.String(status)}}
+	}
+	tests := []struct {
+		name       string
+		region     string
+		client     *fakeEC2
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "no region", client: &fakeEC2{}, wantStatus: StatusFail, wantDetail: "no region is configured"},
+		{name: "misspelt", region: "eu-centrl-1", client: &fakeEC2{regionErr: apiError("InvalidParameterValue")},
+			wantStatus: StatusFail, wantDetail: "eu-centrl-1 is not a region"},
+		{name: "not in the partition", region: "cn-north-1", client: &fakeEC2{},
+			wantStatus: StatusFail, wantDetail: "cn-north-1 is not a region of this partition"},
+		{name: "lookup region disabled", region: "me-central-1", client: &fakeEC2{regionErr: apiError("OptInRequired")},
+			wantStatus: StatusFail, wantDetail: "me-central-1 is not enabled for the account"},
+		{name: "lookup denied", region: "me-central-1", client: &fakeEC2{regionErr: apiError("UnauthorizedOperation")},
+			wantStatus: StatusWarn, wantDetail: "the opt-in status of me-central-1 cannot be read"},
+		{name: "lookup unreachable", region: "me-central-1", client: &fakeEC2{regionErr: errors.New("i/o timeout")},
+			wantStatus: StatusFail, wantDetail: "the opt-in status of me-central-1 cannot be read: i/o timeout"},
+		{name: "not opted in", region: "me-central-1", client: &fakeEC2{regions: region("not-opted-in")},
+			wantStatus: StatusFail, wantDetail: "me-central-1 is not enabled for the account: opt-in status not-opted-in"},
+		{name: "no opt-in status", region: "me-central-1", client: &fakeEC2{regions: []types.Region{{RegionName: aws.String("me-central-1")}}},
+			wantStatus: StatusFail, wantDetail: "opt-in status (unknown)"},
+		{name: "opted in", region: "me-central-1", client: &fakeEC2{regions: region("opted-in")},
+			wantStatus: StatusPass, wantDetail: "me-central-1 is enabled (opted-in)"},
+		{name: "default region", region: "eu-west-1", client: &fakeEC2{regions: region("opt-in-not-required")},
+			wantStatus: StatusPass, wantDetail: "eu-west-1 is enabled (opt-in-not-required)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &RegionCheck{Client: tt.client, Region: tt.region}
+			wantResult(t, check.Run(context.Background()), tt.wantStatus, tt.wantDetail)
+			if req := tt.client.regionsReq; req != nil && (!aws.ToBool(req.AllRegions) || len(req.RegionNames) != 1 || req.RegionNames[0] != tt.region) {
+				t.Errorf("DescribeRegions(%+v), want all regions filtered to %s", req, tt.region)
+			}
+		})
+	}
+}
+
+// TestEndpointCheck covers reaching the endpoint with any status, and failing directly and through a proxy
+func TestEndpointCheck(t *testing.T) {
+	url := "https://ec2.eu-west-1.amazonaws.com"
+	tests := []struct {
+		name       string
+		client     HTTPDoer
+		proxy      string
+		wantStatus string
+		wantDetail string
+		wantRemedy string
+	}{
+		{name: "reached", client: answer(http.StatusForbidden, ""),
+			wantStatus: StatusPass, wantDetail: url + " reached directly in"},
+		{name: "reached through a proxy", client: answer(http.StatusOK, ""), proxy: "http://proxy.corp:3128",
+			wantStatus: StatusPass, wantDetail: "through proxy http://proxy.corp:3128"},
+		{name: "unreachable", client: unreachable(errors.New("dial tcp: i/o timeout")),
+			wantStatus: StatusFail, wantDetail: url + " cannot be reached directly: dial tcp: i/o timeout", wantRemedy: networkRemedy},
+		{name: "proxy refuses", client: unreachable(errors.New("proxyconnect tcp: connection refused")), proxy: "http://proxy.corp:3128",
+			wantStatus: StatusFail, wantDetail: "cannot be reached through proxy http://proxy.corp:3128",
+			wantRemedy: "Check that proxy http://proxy.corp:3128 is up and allows CONNECT to ec2.eu-west-1.amazonaws.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &EndpointCheck{Client: tt.client, URL: url, Proxy: tt.proxy}
+			got := check.Run(context.Background())
+			wantResult(t, got, tt.wantStatus, tt.wantDetail)
+			if !strings.Contains(got.Remedy, tt.wantRemedy) {
+				t.Errorf("Run() remedy = %q, want %q", got.Remedy, tt.wantRemedy)
+			}
+			if tt.wantStatus == StatusPass && !strings.Contains(got.Detail, "(HTTP ") {
+				t.Errorf("Run() = %q, want the HTTP status", got.Detail)
+			}
+		})
+	}
+}
+
+// TestClockCheck covers clocks ahead and behind within and beyond the limits, a missing or invalid Date
+// header and no response at all
+func TestClockCheck(t *testing.T) {
+	date := func(offset time.Duration) string { return checkNow.Add(offset).Format(http.TimeFormat) }
+	tests := []struct {
+		name       string
+		client     HTTPDoer
+		ahead      time.Duration // Local clock minus the time of the Date header
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "in sync", client: answer(http.StatusOK, date(0)), wantStatus: StatusPass, wantDetail: "local clock matches the AWS clock"},
+		{name: "within the rounding", client: answer(http.StatusOK, date(0)), ahead: 900 * time.Millisecond, wantStatus: StatusPass, wantDetail: "local clock matches the AWS clock"},
+		{name: "slightly behind", client: answer(http.StatusOK, date(20*time.Second)), wantStatus: StatusPass, wantDetail: "local clock is behind the AWS clock by 20s"},
+		{name: "ahead beyond the warning", client: answer(http.StatusOK, date(-2*time.Minute)), wantStatus: StatusWarn, wantDetail: "local clock is ahead of the AWS clock by 2m0s"},
+		{name: "behind beyond the failure", client: answer(http.StatusOK, date(6*time.Minute)), wantStatus: StatusFail,
+			wantDetail: "local clock is behind the AWS clock by 6m0s; AWS rejects signed requests that far off"},
+		{name: "no Date header", client: answer(http.StatusOK, ""), wantStatus: StatusWarn, wantDetail: "has no valid Date header"},
+		{name: "invalid Date header", client: answer(http.StatusOK, "yesterday"), wantStatus: StatusWarn, wantDetail: "has no valid Date header"},
+		{name: "no response", client: unreachable(errors.New("connection refused")), wantStatus: StatusSkip, wantDetail: "no AWS response to compare with: connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &ClockCheck{Client: tt.client, URL: "https://ec2.eu-west-1.amazonaws.com", WarnAfter: time.Minute, FailAfter: 5 * time.Minute,
+				Now: func() time.Time { return checkNow.Add(tt.ahead) }}
+			wantResult(t, check.Run(context.Background()), tt.wantStatus, tt.wantDetail)
+		})
+	}
+}
+
+// TestWritableCheck covers an existing directory, missing directories below one, and a file in the way
+func TestWritableCheck(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		dirs       []string
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "existing", dirs: []string{dir}, wantStatus: StatusPass, wantDetail: "writable: " + dir},
+		{name: "missing", dirs: []string{filepath.Join(dir, "graph", "cache")},
+			wantStatus: StatusPass, wantDetail: "writable: " + filepath.Join(dir, "graph", "cache") + " (created by the scan in " + dir + ")"},
+		{name: "file", dirs: []string{dir, file}, wantStatus: StatusFail, wantDetail: file + " is a file, not a directory"},
+		{name: "below a file", dirs: []string{filepath.Join(file, "graph")}, wantStatus: StatusFail, wantDetail: file + " is a file, not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &WritableCheck{Dirs: tt.dirs}
+			wantResult(t, check.Run(context.Background()), tt.wantStatus, tt.wantDetail)
+		})
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("%s holds %d entries (%v) after the checks, want only %s", dir, len(entries), err, file)
+	}
+}
+
+// TestVersionCheck covers a recent and an outdated build
+func TestVersionCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		released   time.Time
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "recent", released: checkNow.AddDate(0, 0, -30), wantStatus: StatusPass, wantDetail: "aws-documentor 1.0.0, released 2026-09-16"},
+		{name: "outdated", released: checkNow.AddDate(-1, 0, 0), wantStatus: StatusWarn, wantDetail: "aws-documentor 1.0.0, released 2025-10-16, 365 days ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &VersionCheck{Version: "1.0.0", ReleasedAt: tt.released, MaxAge: 180 * 24 * time.Hour, Now: func() time.Time { return checkNow }}
+			wantResult(t, check.Run(context.Background()), tt.wantStatus, tt.wantDetail)
+		})
+	}
+}
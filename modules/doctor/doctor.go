@@ -0,0 +1,116 @@
+// Package doctor runs quick checks of what a scan needs and explains how to fix what is missing
+package doctor
+
+import (
+	"context"
+	"time"
+)
+
+// Statuses of a check result
+const (
+	StatusPass = "pass" // Nothing to do
+	StatusWarn = "warn" // A scan works, but something is off (slow, expiring, outdated, ...)
+	StatusFail = "fail" // A scan is likely to fail or miss data because of this
+	StatusSkip = "skip" // The check could not run, for example without an AWS config
+)
+
+// Check is one diagnosis of the battery
+// A check must not panic and must return within the context's deadline; problems are reported in the result.
+type Check interface {
+	Name() string                   // Short name shown in the output, such as "credentials"
+	Critical() bool                 // Whether a failure makes the doctor command exit non-zero
+	Run(ctx context.Context) Result // Runs the check; Name and Critical of the result are filled in by Run
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name       string `json:"name"`        // Name of the check
+	Status     string `json:"status"`      // pass, warn, fail or skip
+	Critical   bool   `json:"critical"`    // A failure of the check makes the command exit non-zero
+	Detail     string `json:"detail"`      // What was found
+	Remedy     string `json:"remedy"`      // How to fix a warning or failure (empty for pass)
+	DurationMS int64  `json:"duration_ms"` // How long the check took
+}
+
+// Report is the outcome of the whole battery
+type Report struct {
+	Results          []Result `json:"results"`           // One result per check, in the order of the battery
+	Passed           int      `json:"passed"`            // Checks that passed
+	Warnings         int      `json:"warnings"`          // Checks that warned
+	Failures         int      `json:"failures"`          // Checks that failed
+	Skipped          int      `json:"skipped"`           // Checks that could not run
+	CriticalFailures int      `json:"critical_failures"` // Failed checks that are critical
+}
+
+// Run runs every check one after the other, each with its own timeout
+// Checks run in order, so a slow network only makes the network checks slow.
+// ctx: Context of the whole battery
+// checks: Checks to run
+// timeout: Limit for each check (0 for none)
+// Returns: Results of all checks with the counts per status
+func Run(ctx context.Context, checks []Check, timeout time.Duration) *Report {
+	report := &Report{Results: []Result{}}
+	for _, check := range checks {
+		checkCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			checkCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		start := time.Now()
+		result := check.Run(checkCtx)
+		cancel()
+
+		result.Name = check.Name()
+		result.Critical = check.Critical()
+		result.DurationMS = time.Since(start).Milliseconds()
+		switch result.Status {
+		case StatusPass:
+			report.Passed++
+		case StatusWarn:
+			report.Warnings++
+		case StatusFail:
+			report.Failures++
+			if result.Critical {
+				report.CriticalFailures++
+			}
+		default:
+			result.Status = StatusSkip
+			report.Skipped++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// Skipped is a check that cannot run, reported with the reason
+// It keeps the battery's list of checks the same when, say, the AWS config fails to load.
+type Skipped struct {
+	CheckName  string // Name of the check that was skipped
+	IsCritical bool   // Whether the check is critical
+	Reason     string // Why the check could not run
+}
+
+// Name returns the name of the skipped check
+func (s Skipped) Name() string { return s.CheckName }
+
+// Critical returns whether the skipped check is critical
+func (s Skipped) Critical() bool { return s.IsCritical }
+
+// Run reports the check as skipped
+func (s Skipped) Run(ctx context.Context) Result {
+	return Result{Status: StatusSkip, Detail: s.Reason}
+}
+
+// pass returns a passing result
+func pass(detail string) Result {
+	return Result{Status: StatusPass, Detail: detail}
+}
+
+// warn returns a warning with what to do about it
+func warn(detail, remedy string) Result {
+	return Result{Status: StatusWarn, Detail: detail, Remedy: remedy}
+}
+
+// fail returns a failure with how to fix it
+func fail(detail, remedy string) Result {
+	return Result{Status: StatusFail, Detail: detail, Remedy: remedy}
+}
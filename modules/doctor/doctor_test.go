@@ -0,0 +1,70 @@
+package doctor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type stubCheck struct {
+	name     string
+	critical bool
+	result   Result
+	block    bool // Wait for the context to end and fail with its error
+}
+
+func (c stubCheck) Name() string   { return c.name }
+func (c stubCheck) Critical() bool { return c.critical }
+
+func (c stubCheck) Run(ctx context.Context) Result {
+	if c.block {
+		<-ctx.Done()
+		return fail(ctx.Err().Error(), "wait longer")
+	}
+	return c.result
+}
+
+// TestRun checks the order, names, criticality and counts of the results, that an unknown status counts as
+// skipped, and that a check hanging until its timeout does not stop the battery
+func TestRun(t *testing.T) {
+	checks := []Check{
+		stubCheck{name: "credentials", critical: true, result: Result{Name: "ignored", Status: StatusPass, Detail: "long-term"}},
+		stubCheck{name: "region", critical: true, result: fail("not enabled", "enable it")},
+		stubCheck{name: "version", result: fail("outdated", "update")},
+		stubCheck{name: "clock skew", critical: true, block: true},
+		stubCheck{name: "custom", result: Result{Status: "maybe"}},
+		stubCheck{name: "latency", result: warn("slow", "check the proxy")},
+		Skipped{CheckName: "endpoint reachability", IsCritical: true, Reason: "no region is configured"},
+	}
+	report := Run(context.Background(), checks, 20*time.Millisecond)
+
+	var got []Result
+	for _, result := range report.Results {
+		if result.Name != "clock skew" && result.DurationMS > 10 {
+			t.Errorf("%s took %d ms", result.Name, result.DurationMS)
+		}
+		result.DurationMS = 0
+		got = append(got, result)
+	}
+	want := []Result{
+		{Name: "credentials", Status: StatusPass, Critical: true, Detail: "long-term"},
+		{Name: "region", Status: StatusFail, Critical: true, Detail: "not enabled", Remedy: "enable it"},
+		{Name: "version", Status: StatusFail, Detail: "outdated", Remedy: "update"},
+		{Name: "clock skew", Status: StatusFail, Critical: true, Detail: "context deadline exceeded", Remedy: "wait longer"},
+		{Name: "custom", Status: StatusSkip},
+		{Name: "latency", Status: StatusWarn, Detail: "slow", Remedy: "check the proxy"},
+		{Name: "endpoint reachability", Status: StatusSkip, Critical: true, Detail: "no region is configured"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Results = %+v\nwant %+v", got, want)
+	}
+	if report.Passed != 1 || report.Warnings != 1 || report.Failures != 3 || report.Skipped != 2 || report.CriticalFailures != 2 {
+		t.Errorf("counts = %d passed, %d warnings, %d failures (%d critical), %d skipped; want 1, 1, 3 (2), 2",
+			report.Passed, report.Warnings, report.Failures, report.CriticalFailures, report.Skipped)
+	}
+
+	if report := Run(context.Background(), nil, 0); report.Results == nil || len(report.Results) != 0 {
+		t.Errorf("Results of no checks = %#v, want an empty list", report.Results)
+	}
+}
@@ -0,0 +1,513 @@
+// Package forecast projects when subnets and VPCs run out of IPv4 addresses from a history of scan reports
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/netcalc"
+)
+
+// Directions of a usage trend
+const (
+	DirectionGrowing      = "growing"           // Usage grows enough to matter within the horizon
+	DirectionFlat         = "flat"              // Usage changes by less than 1% of the capacity within the horizon
+	DirectionShrinking    = "shrinking"         // Usage declines; projected as flat
+	DirectionInsufficient = "insufficient_data" // Fewer than MinPoints snapshots have a usage count
+)
+
+// MinPoints is the number of snapshots with a usage count a trend needs
+const MinPoints = 3
+
+// Sample is the usage of a subnet in one snapshot
+type Sample struct {
+	ScannedAt string `json:"scanned_at"` // When the snapshot's scan started
+	Used      int    `json:"used"`       // Used addresses, clamped to the capacity
+}
+
+// Trend is a least-squares line through the usage of a subnet or VPC
+type Trend struct {
+	Points     int     `json:"points"`      // Snapshots the fit used
+	Outliers   int     `json:"outliers"`    // Snapshots left out of the fit because their usage is far off the line
+	PerDay     float64 `json:"per_day"`     // Fitted change of used addresses per day (negative when shrinking)
+	RSquared   float64 `json:"r_squared"`   // Share of the usage variance the line explains (1 for constant usage)
+	StdDev     float64 `json:"std_dev"`     // Standard deviation of the usage around the line, in addresses
+	Confidence string  `json:"confidence"`  // high, medium, low, or none without a trend
+	Direction  string  `json:"direction"`   // growing, flat, shrinking or insufficient_data
+	ExhaustsAt string  `json:"exhausts_at"` // Projected date the addresses run out (YYYY-MM-DD), "" if usage is not growing toward the capacity
+	DaysLeft   *int    `json:"days_left"`   // Days from the latest snapshot to ExhaustsAt (null if not projected)
+	fitted     float64 // Fitted usage at the latest snapshot, which a growing trend is projected from
+}
+
+// SubnetForecast is the usage trend of a subnet
+type SubnetForecast struct {
+	SubnetID  string     `json:"subnet_id"`  // ID of the subnet
+	VpcID     string     `json:"vpc_id"`     // ID of the VPC containing the subnet
+	Name      string     `json:"name"`       // Name tag, falling back to the ID
+	CidrBlock string     `json:"cidr_block"` // IPv4 block of the subnet
+	Capacity  int        `json:"capacity"`   // Usable addresses (the block minus the 5 AWS reserves)
+	Used      int        `json:"used"`       // Used addresses in the latest snapshot
+	Free      int        `json:"free"`       // Free addresses in the latest snapshot
+	History   []Sample   `json:"history"`    // Usage per snapshot with a count, oldest first
+	Trend     Trend      `json:"trend"`      // Fitted trend and projection
+	WorstCase *WorstCase `json:"worst_case"` // Trend shifted by what the instances could still claim (null without instance data)
+}
+
+// WorstCase is the usage trend of a subnet if its instances attached and filled every network interface they can
+// It is the fitted trend shifted up by the additional address demand of the latest snapshot.
+type WorstCase struct {
+	AdditionalDemand int    `json:"additional_demand"` // Addresses the instances could claim beyond those they hold
+	Used             int    `json:"used"`              // Used addresses plus the additional demand, clamped to the capacity
+	ExhaustsAt       string `json:"exhausts_at"`       // Projected date the addresses run out (YYYY-MM-DD), "" if they do not
+	DaysLeft         *int   `json:"days_left"`         // Days from the latest snapshot to ExhaustsAt (null if not projected)
+}
+
+// VPCForecast is the usage trend of the subnets of a VPC together
+// Subnets added over time raise the capacity; the projection is against the latest capacity.
+type VPCForecast struct {
+	VpcID    string `json:"vpc_id"`   // ID of the VPC
+	Name     string `json:"name"`     // Name tag, falling back to the ID
+	Subnets  int    `json:"subnets"`  // IPv4 subnets in the latest snapshot
+	Capacity int    `json:"capacity"` // Usable addresses of those subnets
+	Used     int    `json:"used"`     // Used addresses in the latest snapshot
+	Free     int    `json:"free"`     // Free addresses in the latest snapshot
+	Trend    Trend  `json:"trend"`    // Fitted trend of the used addresses of all subnets
+}
+
+// Finding is a subnet or VPC projected to run out of addresses within the horizon
+type Finding struct {
+	ResourceType string `json:"resource_type"` // subnet or vpc
+	ResourceID   string `json:"resource_id"`   // ID of the subnet or VPC
+	VpcID        string `json:"vpc_id"`        // ID of the VPC
+	Severity     string `json:"severity"`      // high within 30 days or already full, medium otherwise
+	ExhaustsAt   string `json:"exhausts_at"`   // Projected exhaustion date
+	Confidence   string `json:"confidence"`    // Confidence of the trend
+	WorstCase    bool   `json:"worst_case"`    // Only the worst-case line of the subnet exhausts within the horizon
+	Reason       string `json:"reason"`        // Human-readable explanation
+}
+
+// Report is the capacity forecast of a snapshot history
+type Report struct {
+	From      string           `json:"from"`      // Scan time of the oldest snapshot
+	Until     string           `json:"until"`     // Scan time of the latest snapshot; projections start here
+	Horizon   int              `json:"horizon"`   // Days after Until within which exhaustion is a finding
+	Snapshots int              `json:"snapshots"` // Snapshots read
+	Subnets   []SubnetForecast `json:"subnets"`   // Subnets of the latest snapshot, soonest exhaustion first
+	VPCs      []VPCForecast    `json:"vpcs"`      // VPCs of the latest snapshot, soonest exhaustion first
+	Findings  []Finding        `json:"findings"`  // Subnets and VPCs projected to run out within the horizon
+}
+
+// point is a usage count at a time, in days since the oldest snapshot
+type point struct {
+	day  float64
+	used float64
+}
+
+// Build fits a usage trend to every subnet and VPC of the latest snapshot
+// Used addresses are the usable addresses minus the available count of each scan, clamped to
+// [0, capacity]. Snapshots without a count (reports of older versions, subnets not yet created) leave
+// gaps; the fit works on the actual scan times, so gaps and uneven intervals do not skew it.
+// Declining usage is projected as flat, so only growing usage exhausts. Subnets of a latest snapshot
+// with instance network data also get a worst-case line, which is a finding of its own when only it
+// exhausts within the horizon.
+// snapshots: Reports with a scan time, in any order
+// horizon: How far after the latest snapshot exhaustion is a finding
+// Returns: The forecast, or error if there is no snapshot or a scan time cannot be parsed
+func Build(snapshots []*diff.Snapshot, horizon time.Duration) (*Report, error) {
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots to forecast from")
+	}
+	times := make([]time.Time, len(snapshots))
+	for i, snapshot := range snapshots {
+		at, err := time.Parse(time.RFC3339, snapshot.ScannedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan time %q: %w", snapshot.ScannedAt, err)
+		}
+		times[i] = at.UTC()
+	}
+	order := make([]int, len(snapshots))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return times[order[a]].Before(times[order[b]]) })
+	first, latest := times[order[0]], times[order[len(order)-1]]
+	days := func(t time.Time) float64 { return t.Sub(first).Hours() / 24 }
+
+	report := &Report{
+		From:      first.Format(time.RFC3339),
+		Until:     latest.Format(time.RFC3339),
+		Horizon:   int(horizon.Hours() / 24),
+		Snapshots: len(snapshots),
+		Subnets:   []SubnetForecast{},
+		VPCs:      []VPCForecast{},
+		Findings:  []Finding{},
+	}
+
+	// Usage per subnet and per VPC and snapshot
+	histories := make(map[string][]point)
+	samples := make(map[string][]Sample)
+	vpcUsage := make(map[string]map[int]float64)
+	for _, i := range order {
+		for _, subnet := range snapshots[i].Subnets {
+			capacity := netcalc.SubnetCapacity(subnet.CidrBlock)
+			if capacity == 0 || subnet.AvailableIpAddressCount == nil {
+				continue
+			}
+			used := clamp(capacity-*subnet.AvailableIpAddressCount, 0, capacity)
+			histories[subnet.SubnetID] = append(histories[subnet.SubnetID], point{days(times[i]), float64(used)})
+			samples[subnet.SubnetID] = append(samples[subnet.SubnetID], Sample{ScannedAt: times[i].Format(time.RFC3339), Used: used})
+			if vpcUsage[subnet.VpcID] == nil {
+				vpcUsage[subnet.VpcID] = make(map[int]float64)
+			}
+			vpcUsage[subnet.VpcID][i] += float64(used)
+		}
+	}
+
+	current := snapshots[order[len(order)-1]]
+	additionalDemand := make(map[string]int)
+	for _, rollup := range current.SubnetNetwork {
+		additionalDemand[rollup.SubnetID] = rollup.AdditionalIPDemand
+	}
+	vpcs := make(map[string]*VPCForecast)
+	for _, v := range current.VPCs {
+		vpcs[v.VpcID] = &VPCForecast{VpcID: v.VpcID, Name: nameTag(v.Tags, v.VpcID)}
+	}
+	for _, subnet := range current.Subnets {
+		capacity := netcalc.SubnetCapacity(subnet.CidrBlock)
+		if capacity == 0 {
+			continue
+		}
+		forecast := SubnetForecast{
+			SubnetID:  subnet.SubnetID,
+			VpcID:     subnet.VpcID,
+			Name:      nameTag(subnet.Tags, subnet.SubnetID),
+			CidrBlock: subnet.CidrBlock,
+			Capacity:  capacity,
+			History:   samples[subnet.SubnetID],
+		}
+		if forecast.History == nil {
+			forecast.History = []Sample{}
+		}
+		if n := len(forecast.History); n > 0 && forecast.History[n-1].ScannedAt == report.Until {
+			forecast.Used = forecast.History[n-1].Used
+		}
+		forecast.Free = capacity - forecast.Used
+		forecast.Trend = project(histories[subnet.SubnetID], capacity, forecast.Used, days(latest), latest, horizon)
+		if demand, ok := additionalDemand[subnet.SubnetID]; ok {
+			forecast.WorstCase = worstCase(forecast, demand, latest)
+		}
+		report.Subnets = append(report.Subnets, forecast)
+
+		if v := vpcs[subnet.VpcID]; v != nil {
+			v.Subnets++
+			v.Capacity += capacity
+			v.Used += forecast.Used
+		}
+	}
+	for _, v := range current.VPCs {
+		forecast := vpcs[v.VpcID]
+		forecast.Free = forecast.Capacity - forecast.Used
+		var history []point
+		for _, i := range order {
+			if used, ok := vpcUsage[v.VpcID][i]; ok {
+				history = append(history, point{days(times[i]), used})
+			}
+		}
+		forecast.Trend = project(history, forecast.Capacity, forecast.Used, days(latest), latest, horizon)
+		report.VPCs = append(report.VPCs, *forecast)
+	}
+
+	sort.SliceStable(report.Subnets, func(i, j int) bool {
+		a, b := report.Subnets[i], report.Subnets[j]
+		if sooner, decided := soonerFirst(a.Trend, b.Trend); decided {
+			return sooner
+		}
+		if a.VpcID != b.VpcID {
+			return a.VpcID < b.VpcID
+		}
+		return a.SubnetID < b.SubnetID
+	})
+	sort.SliceStable(report.VPCs, func(i, j int) bool {
+		if sooner, decided := soonerFirst(report.VPCs[i].Trend, report.VPCs[j].Trend); decided {
+			return sooner
+		}
+		return report.VPCs[i].VpcID < report.VPCs[j].VpcID
+	})
+
+	for _, subnet := range report.Subnets {
+		if finding, ok := exhaustionFinding("subnet", subnet.SubnetID, subnet.VpcID, subnet.Name, subnet.Trend, report.Horizon); ok {
+			report.Findings = append(report.Findings, finding)
+		} else if finding, ok := worstCaseFinding(subnet, report.Horizon); ok {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	for _, v := range report.VPCs {
+		if finding, ok := exhaustionFinding("vpc", v.VpcID, v.VpcID, v.Name, v.Trend, report.Horizon); ok {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	return report, nil
+}
+
+// project fits a trend to a usage history and projects when it reaches the capacity
+// history: Usage per snapshot, oldest first
+// capacity: Usable addresses
+// used: Used addresses in the latest snapshot
+// today: Day of the latest snapshot since the oldest one
+// latest: Time of the latest snapshot
+// horizon: How far ahead growth is judged
+func project(history []point, capacity, used int, today float64, latest time.Time, horizon time.Duration) Trend {
+	trend := Trend{Points: len(history), Confidence: "none", Direction: DirectionInsufficient}
+	full := capacity > 0 && used >= capacity
+	if full {
+		// A full subnet is exhausted whatever its history says
+		trend.ExhaustsAt, trend.DaysLeft = latest.Format("2006-01-02"), new(int)
+	}
+	if len(history) < MinPoints {
+		return trend
+	}
+
+	line, ok := fitLine(history)
+	if !ok {
+		return trend
+	}
+	if outliers := outlierPoints(history, line); len(outliers) > 0 && len(history)-len(outliers) >= MinPoints {
+		var kept []point
+		for i, p := range history {
+			if !outliers[i] {
+				kept = append(kept, p)
+			}
+		}
+		if refit, ok := fitLine(kept); ok {
+			line, trend.Points, trend.Outliers = refit, len(kept), len(outliers)
+		}
+	}
+	trend.PerDay, trend.RSquared, trend.StdDev = line.slope, line.rSquared, line.stdDev
+	trend.Confidence = confidence(trend.Points, line.rSquared)
+
+	horizonDays := horizon.Hours() / 24
+	threshold := math.Max(1, float64(capacity)/100)
+	switch change := line.slope * horizonDays; {
+	case math.Abs(change) < threshold:
+		trend.Direction = DirectionFlat
+	case change < 0:
+		trend.Direction = DirectionShrinking
+	default:
+		trend.Direction = DirectionGrowing
+	}
+	if full || trend.Direction != DirectionGrowing {
+		return trend
+	}
+
+	// Projected from the fitted usage today, so a single noisy scan does not move the date
+	trend.fitted = line.intercept + line.slope*today
+	remaining := float64(capacity) - trend.fitted
+	daysLeft := int(math.Max(0, math.Ceil(remaining/line.slope)))
+	trend.DaysLeft = &daysLeft
+	trend.ExhaustsAt = latest.AddDate(0, 0, daysLeft).Format("2006-01-02")
+	return trend
+}
+
+// worstCase shifts the trend of a subnet up by the additional address demand of its instances
+// Without a growing trend the worst case only exhausts if the demand alone fills the subnet.
+// subnet: Forecast of the subnet with its trend
+// demand: Additional address demand in the latest snapshot
+// latest: Time of the latest snapshot
+func worstCase(subnet SubnetForecast, demand int, latest time.Time) *WorstCase {
+	worst := &WorstCase{AdditionalDemand: demand, Used: min(subnet.Used+demand, subnet.Capacity)}
+	daysLeft := -1
+	switch {
+	case subnet.Used+demand >= subnet.Capacity:
+		daysLeft = 0
+	case subnet.Trend.DaysLeft != nil && subnet.Trend.PerDay > 0:
+		// From the fitted usage rather than DaysLeft, which is already rounded up to whole days
+		daysLeft = max(0, int(math.Ceil((float64(subnet.Capacity-demand)-subnet.Trend.fitted)/subnet.Trend.PerDay)))
+	}
+	if daysLeft >= 0 {
+		worst.DaysLeft = &daysLeft
+		worst.ExhaustsAt = latest.AddDate(0, 0, daysLeft).Format("2006-01-02")
+	}
+	return worst
+}
+
+// worstCaseFinding reports a subnet whose worst-case line exhausts within the horizon
+func worstCaseFinding(subnet SubnetForecast, horizon int) (Finding, bool) {
+	worst := subnet.WorstCase
+	if worst == nil || worst.DaysLeft == nil || *worst.DaysLeft > horizon {
+		return Finding{}, false
+	}
+	label := subnet.SubnetID
+	if subnet.Name != subnet.SubnetID {
+		label = fmt.Sprintf("%s (%s)", subnet.SubnetID, subnet.Name)
+	}
+	reason := fmt.Sprintf("subnet %s would run out of IPv4 addresses on %s, in %d days, if its instances claimed the %d addresses their network interfaces allow",
+		label, worst.ExhaustsAt, *worst.DaysLeft, worst.AdditionalDemand)
+	if *worst.DaysLeft == 0 {
+		reason = fmt.Sprintf("subnet %s has %d free IPv4 addresses, fewer than the %d its instances could claim with all network interfaces attached",
+			label, subnet.Free, worst.AdditionalDemand)
+	}
+	return Finding{
+		ResourceType: "subnet",
+		ResourceID:   subnet.SubnetID,
+		VpcID:        subnet.VpcID,
+		Severity:     "medium",
+		ExhaustsAt:   worst.ExhaustsAt,
+		Confidence:   subnet.Trend.Confidence,
+		WorstCase:    true,
+		Reason:       reason,
+	}, true
+}
+
+// line is a least-squares fit
+type line struct {
+	slope     float64 // Addresses per day
+	intercept float64 // Addresses on day 0
+	rSquared  float64 // Coefficient of determination
+	stdDev    float64 // Standard deviation of the residuals
+}
+
+// fitLine fits a line to points by ordinary least squares
+// Returns: The line, or false if the points span no time
+func fitLine(points []point) (line, bool) {
+	n := float64(len(points))
+	var meanX, meanY float64
+	for _, p := range points {
+		meanX += p.day
+		meanY += p.used
+	}
+	meanX, meanY = meanX/n, meanY/n
+	var sxx, sxy, syy float64
+	for _, p := range points {
+		sxx += (p.day - meanX) * (p.day - meanX)
+		sxy += (p.day - meanX) * (p.used - meanY)
+		syy += (p.used - meanY) * (p.used - meanY)
+	}
+	if sxx == 0 {
+		return line{}, false
+	}
+	fit := line{slope: sxy / sxx}
+	fit.intercept = meanY - fit.slope*meanX
+	var residuals float64
+	for _, p := range points {
+		r := p.used - (fit.intercept + fit.slope*p.day)
+		residuals += r * r
+	}
+	// Usage counts are whole addresses, so a variance below this is rounding error of constant usage
+	fit.rSquared = 1
+	if syy > 1e-9 {
+		fit.rSquared = 1 - residuals/syy
+	}
+	if len(points) > 2 {
+		fit.stdDev = math.Sqrt(residuals / (n - 2))
+	}
+	return fit, true
+}
+
+// outlierPoints returns the points whose residual is more than 3.5 median absolute deviations from the
+// median residual
+// The median is used because a single spike inflates the standard deviation of a few points so much
+// that it never stands out by it. Histories of fewer than 5 points have no outliers.
+// Returns: Indexes of the outliers
+func outlierPoints(points []point, fit line) map[int]bool {
+	if len(points) < 5 {
+		return nil
+	}
+	residuals := make([]float64, len(points))
+	for i, p := range points {
+		residuals[i] = p.used - (fit.intercept + fit.slope*p.day)
+	}
+	center := median(residuals)
+	deviations := make([]float64, len(residuals))
+	for i, r := range residuals {
+		deviations[i] = math.Abs(r - center)
+	}
+	// 1.4826 scales the MAD to a standard deviation for normal noise; one address is the finest resolution
+	spread := math.Max(1.4826*median(deviations), 1)
+	outliers := make(map[int]bool)
+	for i, deviation := range deviations {
+		if deviation > 3.5*spread {
+			outliers[i] = true
+		}
+	}
+	return outliers
+}
+
+// median returns the median of values without reordering them
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// confidence rates a trend by its number of points and goodness of fit
+func confidence(points int, rSquared float64) string {
+	switch {
+	case points >= 6 && rSquared >= 0.8:
+		return "high"
+	case points >= 4 && rSquared >= 0.5:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// soonerFirst orders trends by days left, projected ones first
+// Returns: Whether a comes first, and whether the days left decide the order
+func soonerFirst(a, b Trend) (bool, bool) {
+	switch {
+	case a.DaysLeft != nil && b.DaysLeft != nil && *a.DaysLeft != *b.DaysLeft:
+		return *a.DaysLeft < *b.DaysLeft, true
+	case (a.DaysLeft == nil) != (b.DaysLeft == nil):
+		return a.DaysLeft != nil, true
+	}
+	return false, false
+}
+
+// exhaustionFinding reports a trend projected to exhaust within the horizon
+func exhaustionFinding(resourceType, resourceID, vpcID, name string, trend Trend, horizon int) (Finding, bool) {
+	if trend.DaysLeft == nil || *trend.DaysLeft > horizon {
+		return Finding{}, false
+	}
+	severity := "medium"
+	if *trend.DaysLeft <= 30 {
+		severity = "high"
+	}
+	label := resourceID
+	if name != resourceID {
+		label = fmt.Sprintf("%s (%s)", resourceID, name)
+	}
+	reason := fmt.Sprintf("%s %s is projected to run out of IPv4 addresses on %s, in %d days (%s confidence, %d snapshots)",
+		resourceType, label, trend.ExhaustsAt, *trend.DaysLeft, trend.Confidence, trend.Points)
+	if *trend.DaysLeft == 0 {
+		reason = fmt.Sprintf("%s %s has no free IPv4 addresses left", resourceType, label)
+	}
+	return Finding{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		VpcID:        vpcID,
+		Severity:     severity,
+		ExhaustsAt:   trend.ExhaustsAt,
+		Confidence:   trend.Confidence,
+		Reason:       reason,
+	}, true
+}
+
+// clamp limits a value to [low, high]
+func clamp(value, low, high int) int {
+	return max(low, min(value, high))
+}
+
+// nameTag returns the Name tag, falling back to the resource ID
+func nameTag(tags map[string]string, resourceID string) string {
+	if name := tags["Name"]; name != "" {
+		return name
+	}
+	return resourceID
+}
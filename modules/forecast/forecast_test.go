@@ -0,0 +1,361 @@
+package forecast
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/vpc"
+)
+
+// day0 is the scan time of the oldest snapshot in the tests
+var day0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// noCount marks a snapshot in which a subnet has no available address count
+const noCount = math.MinInt
+
+// usageSnapshots returns one snapshot per day with the used addresses of subnet-0a1 (10.0.1.0/24, 251
+// usable addresses) in vpc-0a1
+// days: Day of each snapshot since day0
+// used: Used addresses per snapshot; noCount leaves the count out, values outside [0, 251] test clamping
+func usageSnapshots(days []int, used []int) []*diff.Snapshot {
+	var snapshots []*diff.Snapshot
+	for i, day := range days {
+		subnet := vpc.SubnetInfo{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24"}
+		if used[i] != noCount {
+			available := 251 - used[i]
+			subnet.AvailableIpAddressCount = &available
+		}
+		snapshots = append(snapshots, &diff.Snapshot{
+			ScannedAt: day0.AddDate(0, 0, day).Format(time.RFC3339),
+			VPCs:      []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16"}},
+			Subnets:   []vpc.SubnetInfo{subnet},
+		})
+	}
+	return snapshots
+}
+
+// intPtr returns a pointer to a copy of n
+func intPtr(n int) *int {
+	return &n
+}
+
+// TestTrend checks the fitted trend of a subnet for steady growth, flat usage, a subnet that shrank, too few
+// snapshots, gaps and uneven intervals, a spike, clamped counts and a full subnet
+func TestTrend(t *testing.T) {
+	tests := []struct {
+		name       string
+		days       []int
+		used       []int
+		direction  string
+		points     int
+		outliers   int
+		perDay     float64
+		confidence string
+		daysLeft   *int
+		exhaustsAt string
+	}{
+		{name: "steady growth", days: []int{0, 1, 2, 3, 4}, used: []int{100, 110, 120, 130, 140},
+			direction: DirectionGrowing, points: 5, perDay: 10, confidence: "medium", daysLeft: intPtr(12), exhaustsAt: "2026-01-17"},
+		{name: "steady growth over six snapshots", days: []int{0, 1, 2, 3, 4, 5}, used: []int{100, 110, 120, 130, 140, 150},
+			direction: DirectionGrowing, points: 6, perDay: 10, confidence: "high", daysLeft: intPtr(11), exhaustsAt: "2026-01-17"},
+		{name: "flat", days: []int{0, 7, 14, 21}, used: []int{50, 50, 50, 50},
+			direction: DirectionFlat, points: 4, confidence: "medium"},
+		{name: "growth below 1% of the capacity within the horizon", days: []int{0, 90, 180}, used: []int{50, 50, 51},
+			direction: DirectionFlat, points: 3, perDay: 1.0 / 180, confidence: "low"},
+		{name: "shrank", days: []int{0, 1, 2}, used: []int{150, 140, 130},
+			direction: DirectionShrinking, points: 3, perDay: -10, confidence: "low"},
+		{name: "too few snapshots", days: []int{0, 1}, used: []int{100, 110},
+			direction: DirectionInsufficient, points: 2, confidence: "none"},
+		{name: "too few snapshots with a count", days: []int{0, 1, 2}, used: []int{noCount, 100, 110},
+			direction: DirectionInsufficient, points: 2, confidence: "none"},
+		{name: "gaps and uneven intervals", days: []int{0, 2, 5, 6, 9}, used: []int{100, 120, noCount, 160, 190},
+			direction: DirectionGrowing, points: 4, perDay: 10, confidence: "medium", daysLeft: intPtr(7), exhaustsAt: "2026-01-17"},
+		{name: "spike left out", days: []int{0, 1, 2, 3, 4, 5, 6}, used: []int{100, 110, 120, 240, 140, 150, 160},
+			direction: DirectionGrowing, points: 6, outliers: 1, perDay: 10, confidence: "high", daysLeft: intPtr(10), exhaustsAt: "2026-01-17"},
+		{name: "counts above the capacity clamped", days: []int{0, 1, 2}, used: []int{-49, 0, 0},
+			direction: DirectionFlat, points: 3, confidence: "low"},
+		{name: "full", days: []int{0, 1, 2}, used: []int{200, 230, 260},
+			direction: DirectionGrowing, points: 3, perDay: 25.5, confidence: "low", daysLeft: intPtr(0), exhaustsAt: "2026-01-03"},
+		{name: "full without a trend", days: []int{0}, used: []int{251},
+			direction: DirectionInsufficient, points: 1, confidence: "none", daysLeft: intPtr(0), exhaustsAt: "2026-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Build(usageSnapshots(tt.days, tt.used), 180*24*time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trend := report.Subnets[0].Trend
+			if trend.Direction != tt.direction || trend.Points != tt.points || trend.Outliers != tt.outliers || trend.Confidence != tt.confidence {
+				t.Errorf("trend = %s over %d points (%d outliers, %s confidence), want %s over %d points (%d outliers, %s confidence)",
+					trend.Direction, trend.Points, trend.Outliers, trend.Confidence, tt.direction, tt.points, tt.outliers, tt.confidence)
+			}
+			if math.Abs(trend.PerDay-tt.perDay) > 1e-9 {
+				t.Errorf("PerDay = %v, want %v", trend.PerDay, tt.perDay)
+			}
+			if !reflect.DeepEqual(trend.DaysLeft, tt.daysLeft) || trend.ExhaustsAt != tt.exhaustsAt {
+				t.Errorf("exhaustion = %v on %q, want %v on %q", derefOr(trend.DaysLeft), trend.ExhaustsAt, derefOr(tt.daysLeft), tt.exhaustsAt)
+			}
+			if trend.Points >= MinPoints && trend.Outliers == 0 && trend.RSquared < 1-1e-9 && trend.StdDev == 0 {
+				t.Errorf("RSquared = %v with StdDev 0", trend.RSquared)
+			}
+		})
+	}
+}
+
+// derefOr formats an optional day count for error messages
+func derefOr(n *int) any {
+	if n == nil {
+		return "nil"
+	}
+	return *n
+}
+
+// TestFitQuality checks the reported goodness of fit of exact, noisy and constant usage
+func TestFitQuality(t *testing.T) {
+	tests := []struct {
+		name     string
+		used     []int
+		rSquared float64
+		stdDev   float64
+	}{
+		{name: "exact line", used: []int{100, 110, 120, 130}, rSquared: 1},
+		{name: "constant", used: []int{80, 80, 80, 80}, rSquared: 1},
+		// Fit 101 + 8x, residuals -1, 1, 1, -1
+		{name: "noisy", used: []int{100, 110, 118, 124}, rSquared: 1 - 4.0/324, stdDev: math.Sqrt(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Build(usageSnapshots([]int{0, 1, 2, 3}, tt.used), 180*24*time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trend := report.Subnets[0].Trend
+			if math.Abs(trend.RSquared-tt.rSquared) > 1e-9 || math.Abs(trend.StdDev-tt.stdDev) > 1e-9 {
+				t.Errorf("RSquared, StdDev = %v, %v, want %v, %v", trend.RSquared, trend.StdDev, tt.rSquared, tt.stdDev)
+			}
+		})
+	}
+}
+
+// TestBuild checks the history and latest usage of subnets, the VPC trend over subnets added later, the
+// ordering and the findings within the horizon, from snapshots given out of order
+func TestBuild(t *testing.T) {
+	counts := func(available ...int) []*int {
+		var pointers []*int
+		for _, n := range available {
+			if n == noCount {
+				pointers = append(pointers, nil)
+			} else {
+				pointers = append(pointers, intPtr(n))
+			}
+		}
+		return pointers
+	}
+	// Available addresses per day 0..3: the app subnet fills in about a month, the data subnet in about a
+	// year, the web subnet is flat and the batch subnet (a /28 of 11 addresses) is created on day 2 and full
+	subnets := []struct {
+		id, vpcID, cidr string
+		available       []*int
+	}{
+		{id: "subnet-0web", vpcID: "vpc-0a1", cidr: "10.0.1.0/24", available: counts(200, 200, 200, 200)},
+		{id: "subnet-0app", vpcID: "vpc-0a1", cidr: "10.0.2.0/24", available: counts(191, 185, 179, 173)},
+		{id: "subnet-0data", vpcID: "vpc-0b2", cidr: "10.1.0.0/24", available: counts(251, 250, 249, 248)},
+		{id: "subnet-0batch", vpcID: "vpc-0a1", cidr: "10.0.3.0/28", available: counts(noCount, noCount, 0, 0)},
+	}
+	var snapshots []*diff.Snapshot
+	for day := 3; day >= 0; day-- {
+		snapshot := &diff.Snapshot{
+			ScannedAt: day0.AddDate(0, 0, day).Format(time.RFC3339),
+			VPCs: []vpc.VPCInfo{{VpcID: "vpc-0a1", Tags: map[string]string{"Name": "prod"}},
+				{VpcID: "vpc-0b2"}, {VpcID: "vpc-0empty"}},
+		}
+		for _, subnet := range subnets {
+			if day < 2 && subnet.id == "subnet-0batch" {
+				continue
+			}
+			snapshot.Subnets = append(snapshot.Subnets, vpc.SubnetInfo{SubnetID: subnet.id, VpcID: subnet.vpcID,
+				CidrBlock: subnet.cidr, AvailableIpAddressCount: subnet.available[day]})
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	report, err := Build(snapshots, 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != "2026-01-01T00:00:00Z" || report.Until != "2026-01-04T00:00:00Z" || report.Horizon != 90 || report.Snapshots != 4 {
+		t.Errorf("span = %s to %s, horizon %d, %d snapshots", report.From, report.Until, report.Horizon, report.Snapshots)
+	}
+
+	var order []string
+	for _, subnet := range report.Subnets {
+		order = append(order, subnet.SubnetID)
+	}
+	if want := []string{"subnet-0batch", "subnet-0app", "subnet-0data", "subnet-0web"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("subnet order = %v, want %v", order, want)
+	}
+	app := report.Subnets[1]
+	wantHistory := []Sample{{"2026-01-01T00:00:00Z", 60}, {"2026-01-02T00:00:00Z", 66}, {"2026-01-03T00:00:00Z", 72}, {"2026-01-04T00:00:00Z", 78}}
+	if app.Capacity != 251 || app.Used != 78 || app.Free != 173 || !reflect.DeepEqual(app.History, wantHistory) {
+		t.Errorf("app subnet = %d of %d used, %d free, history %+v", app.Used, app.Capacity, app.Free, app.History)
+	}
+	if app.Trend.DaysLeft == nil || *app.Trend.DaysLeft != 29 {
+		t.Errorf("app subnet days left = %v, want 29", derefOr(app.Trend.DaysLeft))
+	}
+
+	vpcs := make(map[string]VPCForecast)
+	for _, v := range report.VPCs {
+		vpcs[v.VpcID] = v
+	}
+	// vpc-0a1 used 111, 117, 134, 140: the batch subnet adds 11 on day 2
+	prod := vpcs["vpc-0a1"]
+	if prod.Name != "prod" || prod.Subnets != 3 || prod.Capacity != 513 || prod.Used != 140 || prod.Free != 373 || prod.Trend.Points != 4 {
+		t.Errorf("vpc-0a1 = %+v", prod)
+	}
+	if math.Abs(prod.Trend.PerDay-10.4) > 1e-9 {
+		t.Errorf("vpc-0a1 PerDay = %v, want 10.4", prod.Trend.PerDay)
+	}
+	if empty := vpcs["vpc-0empty"]; empty.Subnets != 0 || empty.Trend.Direction != DirectionInsufficient || empty.Trend.DaysLeft != nil {
+		t.Errorf("VPC without subnets = %+v", empty)
+	}
+
+	var findings []string
+	for _, finding := range report.Findings {
+		findings = append(findings, finding.ResourceID+" "+finding.Severity+": "+finding.Reason)
+	}
+	want := []string{
+		"subnet-0batch high: subnet subnet-0batch has no free IPv4 addresses left",
+		"subnet-0app high: subnet subnet-0app is projected to run out of IPv4 addresses on 2026-02-02, in 29 days (medium confidence, 4 snapshots)",
+		"vpc-0a1 medium: vpc vpc-0a1 (prod) is projected to run out of IPv4 addresses on 2026-02-09, in 36 days (medium confidence, 4 snapshots)",
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("findings =\n%s\nwant\n%s", strings.Join(findings, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestBuildErrors checks that a forecast needs snapshots with parseable scan times
+func TestBuildErrors(t *testing.T) {
+	if _, err := Build(nil, time.Hour); err == nil {
+		t.Error("Build() without snapshots succeeded")
+	}
+	snapshots := usageSnapshots([]int{0, 1}, []int{1, 2})
+	snapshots[1].ScannedAt = "yesterday"
+	if _, err := Build(snapshots, time.Hour); err == nil || !strings.Contains(err.Error(), `"yesterday"`) {
+		t.Errorf("Build() error = %v, want the unparseable scan time", err)
+	}
+}
+
+// TestSparkline checks the chart of a growing subnet, of a subnet without history and of a single snapshot
+func TestSparkline(t *testing.T) {
+	report, err := Build(usageSnapshots([]int{0, 1, 2, 3, 4}, []int{100, 110, 120, 130, 140}), 180*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := Sparkline(report.Subnets[0], report)
+	for _, want := range []string{
+		"<title>subnet-0a1 (10.0.1.0/24): 140 of 251 addresses used</title>",
+		`<polyline points="2.0,28.5 `,
+		// The projection ends at the capacity on the exhaustion date, the right edge of the chart
+		`x2="238.0" y2="2.0" stroke="#8C4FFF"`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("sparkline lacks %q:\n%s", want, svg)
+		}
+	}
+	if strings.Contains(svg, "#FF9900") {
+		t.Errorf("sparkline without a worst case has a worst-case line:\n%s", svg)
+	}
+
+	subnet := report.Subnets[0]
+	subnet.History = nil
+	if svg := Sparkline(subnet, report); svg != "" {
+		t.Errorf("sparkline without history = %q, want none", svg)
+	}
+
+	single, err := Build(usageSnapshots([]int{0}, []int{100}), 180*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg = Sparkline(single.Subnets[0], single)
+	if !strings.Contains(svg, "<circle ") || strings.Contains(svg, "<polyline") || strings.Contains(svg, "stroke-dasharray") {
+		t.Errorf("sparkline of a single snapshot without a trend:\n%s", svg)
+	}
+}
+
+// TestWorstCase checks the worst-case line of a growing and a flat subnet, demand the subnet cannot hold
+// at all, the finding when only the worst case exhausts within the horizon, and its sparkline
+func TestWorstCase(t *testing.T) {
+	growing := []int{100, 110, 120, 130, 140}
+	flat := []int{100, 100, 100, 100, 100}
+	tests := []struct {
+		name       string
+		used       []int
+		demand     int // Additional demand of the latest snapshot (-1 for no instance data)
+		want       *WorstCase
+		wantReason string // Reason of the worst-case finding ("" for none)
+		wantLine   string // Worst-case line of the sparkline ("" for none)
+	}{
+		// 111 addresses left at 10 a day: the trend exhausts in 12 days, the worst case in ceil(5.6) days
+		{name: "growing", used: growing, demand: 55,
+			want:       &WorstCase{AdditionalDemand: 55, Used: 195, ExhaustsAt: "2026-01-11", DaysLeft: intPtr(6)},
+			wantReason: "subnet subnet-0a1 would run out of IPv4 addresses on 2026-01-11, in 6 days, if its instances claimed the 55 addresses their network interfaces allow",
+			wantLine:   `<line x1="69.4" y1="13.4" x2="238.0" y2="2.0" stroke="#FF9900"`},
+		{name: "demand beyond the free addresses", used: growing, demand: 200,
+			want:       &WorstCase{AdditionalDemand: 200, Used: 251, ExhaustsAt: "2026-01-05", DaysLeft: intPtr(0)},
+			wantReason: "subnet subnet-0a1 has 111 free IPv4 addresses, fewer than the 200 its instances could claim with all network interfaces attached",
+			wantLine:   `<line x1="69.4" y1="2.0" x2="238.0" y2="2.0" stroke="#FF9900"`},
+		{name: "flat", used: flat, demand: 50,
+			want:     &WorstCase{AdditionalDemand: 50, Used: 150},
+			wantLine: `<line x1="69.4" y1="19.7" x2="238.0" y2="19.7" stroke="#FF9900"`},
+		{name: "no additional demand", used: flat, demand: 0, want: &WorstCase{Used: 100}},
+		{name: "no instance data", used: growing, demand: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots := usageSnapshots([]int{0, 1, 2, 3, 4}, tt.used)
+			if tt.demand >= 0 {
+				snapshots[4].SubnetNetwork = []analysis.SubnetNetworkCapacity{{SubnetID: "subnet-0a1", AdditionalIPDemand: tt.demand}}
+			}
+			// The trend alone exhausts after the horizon of 10 days
+			report, err := Build(snapshots, 10*24*time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			subnet := report.Subnets[0]
+			if !reflect.DeepEqual(subnet.WorstCase, tt.want) {
+				t.Errorf("WorstCase = %+v (%v days left), want %+v (%v days left)", subnet.WorstCase, derefOr(worstDaysLeft(subnet.WorstCase)),
+					tt.want, derefOr(worstDaysLeft(tt.want)))
+			}
+
+			var reasons []string
+			for _, finding := range report.Findings {
+				if !finding.WorstCase || finding.Severity != "medium" || finding.Confidence != subnet.Trend.Confidence {
+					t.Errorf("finding %+v, want a medium worst-case finding with the confidence of the trend", finding)
+				}
+				reasons = append(reasons, finding.Reason)
+			}
+			if want := []string{tt.wantReason}; tt.wantReason == "" && len(reasons) > 0 || tt.wantReason != "" && !reflect.DeepEqual(reasons, want) {
+				t.Errorf("findings = %q, want %q", reasons, tt.wantReason)
+			}
+
+			svg := Sparkline(subnet, report)
+			if tt.wantLine == "" && strings.Contains(svg, "#FF9900") || !strings.Contains(svg, tt.wantLine) {
+				t.Errorf("sparkline lacks %q:\n%s", tt.wantLine, svg)
+			}
+		})
+	}
+}
+
+// worstDaysLeft returns the days left of a worst case, or nil without one
+func worstDaysLeft(worst *WorstCase) *int {
+	if worst == nil {
+		return nil
+	}
+	return worst.DaysLeft
+}
@@ -0,0 +1,87 @@
+package forecast
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Size of a sparkline
+const (
+	sparklineWidth  = 240.0
+	sparklineHeight = 48.0
+)
+
+// Sparkline draws the usage history of a subnet as a small SVG chart
+// The history is a solid line and the fitted projection a dashed line up to the exhaustion date or
+// the horizon. The top edge is the capacity, marked by a red line. A subnet with a worst case gets it as
+// an orange dashed line above the projection.
+// subnet: Forecast of the subnet
+// report: The forecast, for the time span and horizon
+// Returns: The SVG document, or "" if the subnet has no history
+func Sparkline(subnet SubnetForecast, report *Report) string {
+	if len(subnet.History) == 0 || subnet.Capacity == 0 {
+		return ""
+	}
+	from, _ := time.Parse(time.RFC3339, report.From)
+	until, _ := time.Parse(time.RFC3339, report.Until)
+	end := until.AddDate(0, 0, report.Horizon)
+	if subnet.Trend.DaysLeft != nil && *subnet.Trend.DaysLeft < report.Horizon {
+		end = until.AddDate(0, 0, *subnet.Trend.DaysLeft)
+	}
+	span := end.Sub(from).Hours()
+	if span <= 0 {
+		span = 1
+	}
+	x := func(t time.Time) float64 { return 2 + (sparklineWidth-4)*t.Sub(from).Hours()/span }
+	y := func(used float64) float64 {
+		return 2 + (sparklineHeight-4)*(1-used/float64(subnet.Capacity))
+	}
+
+	var history []string
+	for _, sample := range subnet.History {
+		at, _ := time.Parse(time.RFC3339, sample.ScannedAt)
+		history = append(history, fmt.Sprintf("%.1f,%.1f", x(at), y(float64(sample.Used))))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n",
+		sparklineWidth, sparklineHeight, sparklineWidth, sparklineHeight)
+	fmt.Fprintf(&b, "<title>%s (%s): %d of %d addresses used</title>\n", escapeSVG(subnet.SubnetID), escapeSVG(subnet.CidrBlock), subnet.Used, subnet.Capacity)
+	fmt.Fprintf(&b, `<line x1="0" y1="2" x2="%.0f" y2="2" stroke="#D13212" stroke-width="1"/>`+"\n", sparklineWidth)
+	if len(history) == 1 {
+		fmt.Fprintf(&b, `<circle cx="%s" r="2" fill="#232F3E"/>`+"\n", strings.Replace(history[0], ",", `" cy="`, 1))
+	} else {
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#232F3E" stroke-width="1.5"/>`+"\n", strings.Join(history, " "))
+	}
+	// The projection starts at the fitted usage of the latest snapshot
+	growing := subnet.Trend.Direction == DirectionGrowing
+	start, perDay := float64(subnet.History[len(subnet.History)-1].Used), 0.0
+	if growing {
+		perDay = subnet.Trend.PerDay
+		if subnet.Trend.DaysLeft != nil && *subnet.Trend.DaysLeft > 0 {
+			start = float64(subnet.Capacity) - perDay*float64(*subnet.Trend.DaysLeft)
+		}
+	}
+	days := end.Sub(until).Hours() / 24
+	capacity := float64(subnet.Capacity)
+	if growing {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#8C4FFF" stroke-width="1.5" stroke-dasharray="4 3"/>`+"\n",
+			x(until), y(start), x(end), y(min(start+perDay*days, capacity)))
+	}
+	if worst := subnet.WorstCase; worst != nil && worst.AdditionalDemand > 0 {
+		worstStart := start + float64(worst.AdditionalDemand)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#FF9900" stroke-width="1" stroke-dasharray="2 2"/>`+"\n",
+			x(until), y(min(worstStart, capacity)), x(end), y(min(worstStart+perDay*days, capacity)))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// escapeSVG escapes text for an SVG element
+func escapeSVG(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	return s
+}
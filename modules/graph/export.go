@@ -0,0 +1,235 @@
+package graph
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Format selects the file format of a graph export
+type Format string
+
+const (
+	FormatCypher Format = "cypher" // Cypher CREATE statements for cypher-shell or the Neo4j browser
+	FormatCSV    Format = "csv"    // Node and relationship CSV files for neo4j-admin database import
+)
+
+// ParseFormat validates a -graph-format flag value
+// s: The flag value (cypher or csv)
+// Returns: The matching Format, or error if the value is not recognized
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatCypher:
+		return FormatCypher, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("unknown graph format %q (expected cypher or csv)", s)
+}
+
+// Export writes the graph to a directory in the given format
+// dir: Output directory, created if it does not exist
+// format: FormatCypher writes graph.cypher; FormatCSV writes nodes.csv and edges.csv
+// Returns: Paths of the written files, or error if a file cannot be written
+func Export(g *Graph, dir string, format Format) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create graph output directory: %w", err)
+	}
+
+	switch format {
+	case FormatCypher:
+		path := filepath.Join(dir, "graph.cypher")
+		if err := writeFile(path, func(w io.Writer) error { return WriteCypher(w, g) }); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	case FormatCSV:
+		nodesPath := filepath.Join(dir, "nodes.csv")
+		edgesPath := filepath.Join(dir, "edges.csv")
+		if err := writeFile(nodesPath, func(w io.Writer) error { return WriteNodesCSV(w, g) }); err != nil {
+			return nil, err
+		}
+		if err := writeFile(edgesPath, func(w io.Writer) error { return WriteEdgesCSV(w, g) }); err != nil {
+			return nil, err
+		}
+		return []string{nodesPath, edgesPath}, nil
+	}
+	return nil, fmt.Errorf("unknown graph format %q", format)
+}
+
+// writeFile creates a file and fills it with write
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// WriteCypher writes the graph as Cypher statements
+// An index on id is created for every label so the relationship MATCH clauses stay fast on large graphs
+// Returns: Error if writing fails
+func WriteCypher(w io.Writer, g *Graph) error {
+	labels := make(map[string]bool)
+	for _, node := range g.Nodes {
+		labels[node.Label] = true
+	}
+	for _, label := range sortedKeys(labels) {
+		if _, err := fmt.Fprintf(w, "CREATE INDEX IF NOT EXISTS FOR (n:%s) ON (n.id);\n", label); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range g.Nodes {
+		properties := map[string]interface{}{"id": node.ID}
+		for key, value := range node.Properties {
+			properties[key] = value
+		}
+		if _, err := fmt.Fprintf(w, "CREATE (:%s %s);\n", node.Label, cypherMap(properties)); err != nil {
+			return err
+		}
+	}
+
+	for _, edge := range g.Edges {
+		fromLabel := g.Nodes[g.nodeIndex[edge.From]].Label
+		toLabel := g.Nodes[g.nodeIndex[edge.To]].Label
+		_, err := fmt.Fprintf(w, "MATCH (a:%s {id: %s}), (b:%s {id: %s}) CREATE (a)-[:%s %s]->(b);\n",
+			fromLabel, cypherString(edge.From), toLabel, cypherString(edge.To), edge.Type, cypherMap(edge.Properties))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cypherMap renders properties as a Cypher map literal with keys in sorted order
+func cypherMap(properties map[string]interface{}) string {
+	parts := make([]string, 0, len(properties))
+	for _, key := range sortedKeys(properties) {
+		parts = append(parts, fmt.Sprintf("%s: %s", key, cypherValue(properties[key])))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// cypherValue renders a property value as a Cypher literal
+func cypherValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return cypherString(v)
+	case bool, int64:
+		return fmt.Sprint(v)
+	}
+	return cypherString(fmt.Sprint(value))
+}
+
+// cypherReplacer escapes characters that would end or corrupt a single-quoted Cypher string
+var cypherReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`)
+
+// cypherString renders s as a single-quoted Cypher string literal
+func cypherString(s string) string {
+	return "'" + cypherReplacer.Replace(s) + "'"
+}
+
+// WriteNodesCSV writes the nodes in the neo4j-admin import format
+// Columns are id:ID, :LABEL and the union of all property keys, typed from their values
+// Returns: Error if writing fails
+func WriteNodesCSV(w io.Writer, g *Graph) error {
+	properties := make([]map[string]interface{}, len(g.Nodes))
+	for i, node := range g.Nodes {
+		properties[i] = node.Properties
+	}
+	keys, header := csvColumns(properties)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"id:ID", ":LABEL"}, header...)); err != nil {
+		return err
+	}
+	for _, node := range g.Nodes {
+		record := append([]string{node.ID, node.Label}, csvValues(node.Properties, keys)...)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteEdgesCSV writes the edges in the neo4j-admin import format
+// Columns are :START_ID, :END_ID, :TYPE and the union of all property keys, typed from their values
+// Returns: Error if writing fails
+func WriteEdgesCSV(w io.Writer, g *Graph) error {
+	properties := make([]map[string]interface{}, len(g.Edges))
+	for i, edge := range g.Edges {
+		properties[i] = edge.Properties
+	}
+	keys, header := csvColumns(properties)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{":START_ID", ":END_ID", ":TYPE"}, header...)); err != nil {
+		return err
+	}
+	for _, edge := range g.Edges {
+		record := append([]string{edge.From, edge.To, edge.Type}, csvValues(edge.Properties, keys)...)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumns returns the sorted property keys and their typed header names
+func csvColumns(properties []map[string]interface{}) ([]string, []string) {
+	types := make(map[string]string)
+	for _, props := range properties {
+		for key, value := range props {
+			if _, ok := types[key]; ok {
+				continue
+			}
+			switch value.(type) {
+			case int64:
+				types[key] = ":long"
+			case bool:
+				types[key] = ":boolean"
+			default:
+				types[key] = ""
+			}
+		}
+	}
+
+	keys := sortedKeys(types)
+	header := make([]string, len(keys))
+	for i, key := range keys {
+		header[i] = key + types[key]
+	}
+	return keys, header
+}
+
+// csvValues returns the values of the given keys, empty for keys the properties do not have
+func csvValues(properties map[string]interface{}, keys []string) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		if value, ok := properties[key]; ok {
+			values[i] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
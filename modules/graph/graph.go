@@ -0,0 +1,341 @@
+// Package graph provides functionality for exporting scanned AWS network resources as a property graph
+package graph
+
+import (
+	"sort"
+
+	"aws-documentor/modules/naming"
+	"aws-documentor/modules/vpc"
+)
+
+// Node labels
+const (
+	LabelVPC                       = "Vpc"
+	LabelSubnet                    = "Subnet"
+	LabelRouteTable                = "RouteTable"
+	LabelSecurityGroup             = "SecurityGroup"
+	LabelInternetGateway           = "InternetGateway"
+	LabelNatGateway                = "NatGateway"
+	LabelTransitGateway            = "TransitGateway"
+	LabelTransitGatewayAttachment  = "TransitGatewayAttachment"
+	LabelVpnGateway                = "VpnGateway"
+	LabelVpcEndpoint               = "VpcEndpoint"
+	LabelEgressOnlyInternetGateway = "EgressOnlyInternetGateway"
+	LabelVpcPeeringConnection      = "VpcPeeringConnection"
+	LabelCarrierGateway            = "CarrierGateway"
+	LabelLocalGateway              = "LocalGateway"
+	LabelCoreNetwork               = "CoreNetwork"
+	LabelNetworkInterface          = "NetworkInterface"
+	LabelInstance                  = "Instance"
+	LabelGateway                   = "Gateway"
+	LabelPrefixList                = "PrefixList"
+	LabelCIDR                      = "Cidr"
+)
+
+// Edge types
+const (
+	EdgeContains   = "CONTAINS"    // A VPC contains a subnet, route table or security group; a subnet contains a NAT gateway
+	EdgeRoutesTo   = "ROUTES_TO"   // A route table sends a destination to a target
+	EdgeAttachedTo = "ATTACHED_TO" // A gateway or attachment is attached to a VPC or transit gateway, or a route table to a subnet
+	EdgeAllows     = "ALLOWS"      // A security group rule allows traffic from a source to a group, or from a group to a destination
+	EdgePeeredWith = "PEERED_WITH" // Two VPCs are connected by a peering connection
+	EdgeReferences = "REFERENCES"  // A security group rule references another security group or a prefix list
+)
+
+// routeTargetLabels maps route target types to the label of the target node
+var routeTargetLabels = map[string]string{
+	vpc.RouteTargetInternetGateway:           LabelInternetGateway,
+	vpc.RouteTargetVpnGateway:                LabelVpnGateway,
+	vpc.RouteTargetVpcEndpoint:               LabelVpcEndpoint,
+	vpc.RouteTargetGateway:                   LabelGateway,
+	vpc.RouteTargetEgressOnlyInternetGateway: LabelEgressOnlyInternetGateway,
+	vpc.RouteTargetNatGateway:                LabelNatGateway,
+	vpc.RouteTargetTransitGateway:            LabelTransitGateway,
+	vpc.RouteTargetVpcPeeringConnection:      LabelVpcPeeringConnection,
+	vpc.RouteTargetCarrierGateway:            LabelCarrierGateway,
+	vpc.RouteTargetLocalGateway:              LabelLocalGateway,
+	vpc.RouteTargetCoreNetwork:               LabelCoreNetwork,
+	vpc.RouteTargetNetworkInterface:          LabelNetworkInterface,
+	vpc.RouteTargetInstance:                  LabelInstance,
+}
+
+// Node is a resource in the graph
+// Property values are strings, int64 or bool so every export format can type them
+type Node struct {
+	ID         string                 // AWS resource ID (CIDR blocks for address ranges)
+	Label      string                 // One of the Label* constants
+	Properties map[string]interface{} // Key properties of the resource; "scanned" is false for resources only known from references
+}
+
+// Edge is a typed relationship between two nodes
+type Edge struct {
+	From       string                 // ID of the start node
+	To         string                 // ID of the end node
+	Type       string                 // One of the Edge* constants
+	Properties map[string]interface{} // Properties of the relationship (route destination, rule ports, ...)
+}
+
+// Graph contains the nodes and edges derived from a scan
+type Graph struct {
+	Nodes []Node // Nodes in scan order, followed by referenced resources that were not scanned
+	Edges []Edge // Edges in derivation order
+
+	nodeIndex map[string]int  // Position in Nodes by node ID
+	edgeIndex map[string]bool // Keys of edges that must only appear once
+}
+
+// Build derives the graph from scanned resources
+// Every scanned resource becomes a node; targets and references that were not scanned become nodes
+// with scanned=false so that edges never dangle
+// Returns: The graph
+func Build(
+	vpcs []vpc.VPCInfo,
+	subnets []vpc.SubnetInfo,
+	routeTables []vpc.RouteTableInfo,
+	securityGroups []vpc.SecurityGroupInfo,
+	internetGateways []vpc.InternetGatewayInfo,
+	natGateways []vpc.NatGatewayInfo,
+	transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo,
+) *Graph {
+	g := &Graph{
+		Nodes:     []Node{},
+		Edges:     []Edge{},
+		nodeIndex: make(map[string]int),
+		edgeIndex: make(map[string]bool),
+	}
+
+	for _, v := range vpcs {
+		g.addNode(v.VpcID, LabelVPC, map[string]interface{}{
+			"name":       v.Tags["Name"],
+			"arn":        v.Arn,
+			"cidr_block": v.CidrBlock,
+			"state":      v.State,
+			"is_default": v.IsDefault,
+		})
+	}
+	for _, subnet := range subnets {
+		g.addNode(subnet.SubnetID, LabelSubnet, map[string]interface{}{
+			"name":                    subnet.Tags["Name"],
+			"arn":                     subnet.Arn,
+			"cidr_block":              subnet.CidrBlock,
+			"availability_zone":       subnet.AvailabilityZone,
+			"map_public_ip_on_launch": subnet.MapPublicIpOnLaunch,
+		})
+		g.addEdge(subnet.VpcID, LabelVPC, subnet.SubnetID, LabelSubnet, EdgeContains, nil)
+	}
+	for _, sg := range securityGroups {
+		g.addNode(sg.GroupID, LabelSecurityGroup, map[string]interface{}{
+			"name":        sg.GroupName,
+			"arn":         sg.Arn,
+			"description": sg.Description,
+			"owner_id":    sg.OwnerID,
+		})
+		g.addEdge(sg.VpcID, LabelVPC, sg.GroupID, LabelSecurityGroup, EdgeContains, nil)
+	}
+	for _, igw := range internetGateways {
+		g.addNode(igw.InternetGatewayID, LabelInternetGateway, map[string]interface{}{
+			"name":  igw.Tags["Name"],
+			"arn":   igw.Arn,
+			"state": igw.State,
+		})
+		if igw.VpcID != "" {
+			g.addEdge(igw.InternetGatewayID, LabelInternetGateway, igw.VpcID, LabelVPC, EdgeAttachedTo, nil)
+		}
+	}
+	for _, ngw := range natGateways {
+		g.addNode(ngw.NatGatewayID, LabelNatGateway, map[string]interface{}{
+			"name":              ngw.Tags["Name"],
+			"arn":               ngw.Arn,
+			"state":             ngw.State,
+			"connectivity_type": ngw.ConnectivityType,
+			"private_ip":        ngw.PrivateIp,
+			"public_ip":         ngw.PublicIp,
+		})
+		g.addEdge(ngw.SubnetID, LabelSubnet, ngw.NatGatewayID, LabelNatGateway, EdgeContains, nil)
+	}
+	for _, tgw := range transitGateways {
+		g.addNode(tgw.TransitGatewayID, LabelTransitGateway, map[string]interface{}{
+			"name":            tgw.Tags["Name"],
+			"arn":             tgw.Arn,
+			"state":           tgw.State,
+			"owner_id":        tgw.OwnerID,
+			"amazon_side_asn": tgw.AmazonSideAsn,
+		})
+	}
+	for _, attachment := range tgwAttachments {
+		g.addNode(attachment.AttachmentID, LabelTransitGatewayAttachment, map[string]interface{}{
+			"name":          attachment.Tags["Name"],
+			"arn":           attachment.Arn,
+			"state":         attachment.State,
+			"resource_type": attachment.ResourceType,
+			"resource_id":   attachment.ResourceID,
+		})
+		g.addEdge(attachment.AttachmentID, LabelTransitGatewayAttachment, attachment.TransitGatewayID, LabelTransitGateway, EdgeAttachedTo, nil)
+		if attachment.ResourceType == "vpc" {
+			g.addEdge(attachment.AttachmentID, LabelTransitGatewayAttachment, attachment.ResourceID, LabelVPC, EdgeAttachedTo, nil)
+		}
+	}
+
+	g.addRouteTables(routeTables)
+	g.addSecurityGroupRules(securityGroups)
+
+	return g
+}
+
+// addRouteTables adds route tables with their associations, routes and the peerings the routes reveal
+func (g *Graph) addRouteTables(routeTables []vpc.RouteTableInfo) {
+	peerings := make(map[string]map[string]bool)
+
+	for _, rt := range routeTables {
+		g.addNode(rt.RouteTableID, LabelRouteTable, map[string]interface{}{
+			"name":    rt.Tags["Name"],
+			"arn":     rt.Arn,
+			"is_main": rt.IsMainRouteTable,
+		})
+		g.addEdge(rt.VpcID, LabelVPC, rt.RouteTableID, LabelRouteTable, EdgeContains, nil)
+		for _, subnetID := range rt.SubnetIDs {
+			g.addEdge(rt.RouteTableID, LabelRouteTable, subnetID, LabelSubnet, EdgeAttachedTo, nil)
+		}
+
+		for _, route := range rt.Routes {
+			label, ok := routeTargetLabels[route.Target.Type]
+			if !ok || route.Target.ID == "" {
+				// Local routes stay in the VPC; unknown targets are already logged by the scanner
+				continue
+			}
+			destination := route.DestinationCidrBlock
+			if destination == "" {
+				destination = route.DestinationIpv6Block
+			}
+			g.addEdge(rt.RouteTableID, LabelRouteTable, route.Target.ID, label, EdgeRoutesTo, map[string]interface{}{
+				"destination": destination,
+				"state":       route.State,
+				"origin":      route.Origin,
+			})
+
+			if route.Target.Type == vpc.RouteTargetVpcPeeringConnection {
+				if peerings[route.Target.ID] == nil {
+					peerings[route.Target.ID] = make(map[string]bool)
+				}
+				peerings[route.Target.ID][rt.VpcID] = true
+			}
+		}
+	}
+
+	// A peering connection routed from both sides links the two VPCs directly
+	connectionIDs := make([]string, 0, len(peerings))
+	for id := range peerings {
+		connectionIDs = append(connectionIDs, id)
+	}
+	sort.Strings(connectionIDs)
+	for _, id := range connectionIDs {
+		vpcIDs := make([]string, 0, len(peerings[id]))
+		for vpcID := range peerings[id] {
+			vpcIDs = append(vpcIDs, vpcID)
+		}
+		if len(vpcIDs) != 2 {
+			continue
+		}
+		sort.Strings(vpcIDs)
+		g.addUniqueEdge(vpcIDs[0], LabelVPC, vpcIDs[1], LabelVPC, EdgePeeredWith, map[string]interface{}{
+			"vpc_peering_connection_id": id,
+		})
+	}
+}
+
+// addSecurityGroupRules adds an ALLOWS edge per rule and a REFERENCES edge per referenced group or prefix list
+// Ingress edges run from the source to the group, egress edges from the group to the destination
+func (g *Graph) addSecurityGroupRules(securityGroups []vpc.SecurityGroupInfo) {
+	for _, sg := range securityGroups {
+		for _, rule := range sg.Rules {
+			peerID, peerLabel := rule.CidrBlock, LabelCIDR
+			switch {
+			case rule.GroupID != "":
+				peerID, peerLabel = rule.GroupID, LabelSecurityGroup
+			case rule.PrefixListID != "":
+				peerID, peerLabel = rule.PrefixListID, LabelPrefixList
+			case rule.Ipv6CidrBlock != "":
+				peerID = rule.Ipv6CidrBlock
+			}
+			if peerID == "" {
+				continue
+			}
+
+			properties := map[string]interface{}{
+				"protocol":    rule.IpProtocol,
+				"from_port":   int64(rule.FromPort),
+				"to_port":     int64(rule.ToPort),
+				"direction":   "ingress",
+				"description": rule.Description,
+			}
+			if rule.IsEgress {
+				properties["direction"] = "egress"
+				g.addEdge(sg.GroupID, LabelSecurityGroup, peerID, peerLabel, EdgeAllows, properties)
+			} else {
+				g.addEdge(peerID, peerLabel, sg.GroupID, LabelSecurityGroup, EdgeAllows, properties)
+			}
+
+			if peerLabel == LabelSecurityGroup || peerLabel == LabelPrefixList {
+				g.addUniqueEdge(sg.GroupID, LabelSecurityGroup, peerID, peerLabel, EdgeReferences, nil)
+			}
+
+			// A reference across a peering connection also tells us both VPCs of the peering
+			if rule.VpcPeeringConnectionID != "" && rule.GroupVpcID != "" && rule.GroupVpcID != sg.VpcID {
+				from, to := sg.VpcID, rule.GroupVpcID
+				if to < from {
+					from, to = to, from
+				}
+				g.addUniqueEdge(from, LabelVPC, to, LabelVPC, EdgePeeredWith, map[string]interface{}{
+					"vpc_peering_connection_id": rule.VpcPeeringConnectionID,
+				})
+			}
+		}
+	}
+}
+
+// addNode adds a scanned resource, replacing a placeholder created for an earlier reference
+// IDs pass through naming.Sanitize so derived IDs (CIDR and prefix list nodes) are valid too
+func (g *Graph) addNode(id, label string, properties map[string]interface{}) {
+	id = naming.Sanitize(id, naming.StyleGraphID)
+	properties["scanned"] = true
+	if i, ok := g.nodeIndex[id]; ok {
+		g.Nodes[i].Properties = properties
+		return
+	}
+	g.nodeIndex[id] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, Node{ID: id, Label: label, Properties: properties})
+}
+
+// ensureNode adds a placeholder node for a referenced resource that has not been seen yet
+func (g *Graph) ensureNode(id, label string) {
+	if _, ok := g.nodeIndex[id]; ok {
+		return
+	}
+	g.nodeIndex[id] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, Node{ID: id, Label: label, Properties: map[string]interface{}{"scanned": false}})
+}
+
+// addEdge adds an edge, creating placeholder nodes for endpoints that are not in the scan
+func (g *Graph) addEdge(from, fromLabel, to, toLabel, edgeType string, properties map[string]interface{}) {
+	if from == "" || to == "" {
+		return
+	}
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	from, to = naming.Sanitize(from, naming.StyleGraphID), naming.Sanitize(to, naming.StyleGraphID)
+	g.ensureNode(from, fromLabel)
+	g.ensureNode(to, toLabel)
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Type: edgeType, Properties: properties})
+}
+
+// addUniqueEdge adds an edge only once per start node, end node and type
+func (g *Graph) addUniqueEdge(from, fromLabel, to, toLabel, edgeType string, properties map[string]interface{}) {
+	key := edgeType + "|" + from + "|" + to
+	if g.edgeIndex[key] {
+		return
+	}
+	g.edgeIndex[key] = true
+	g.addEdge(from, fromLabel, to, toLabel, edgeType, properties)
+}
@@ -0,0 +1,253 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// fixtureGraph builds the graph of two peered VPCs, one with an internet gateway, a NAT gateway, a transit
+// gateway attachment and two security groups whose rules reference a CIDR block, each other, a prefix list
+// and a group across the peering
+func fixtureGraph() *Graph {
+	route := func(destination, targetType, targetID string) vpc.RouteInfo {
+		return vpc.RouteInfo{DestinationCidrBlock: destination, Target: vpc.RouteTarget{Type: targetType, ID: targetID}, State: "active", Origin: "CreateRoute"}
+	}
+	return Build(
+		[]vpc.VPCInfo{
+			{VpcID: "vpc-0a1", CidrBlock: "10.1.0.0/16", State: "available", Tags: map[string]string{"Name": `prod's "core"`}},
+			{VpcID: "vpc-0b2", CidrBlock: "10.2.0.0/16", State: "available"},
+		},
+		[]vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.1.1.0/24", AvailabilityZone: "eu-west-1a", MapPublicIpOnLaunch: true},
+			{SubnetID: "subnet-0b2", VpcID: "vpc-0b2", CidrBlock: "10.2.1.0/24", AvailabilityZone: "eu-west-1b"},
+		},
+		[]vpc.RouteTableInfo{
+			{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a1"}, Routes: []vpc.RouteInfo{
+				route("10.1.0.0/16", vpc.RouteTargetLocal, "local"),
+				route("0.0.0.0/0", vpc.RouteTargetInternetGateway, "igw-0a1"),
+				route("10.2.0.0/16", vpc.RouteTargetVpcPeeringConnection, "pcx-0ab"),
+				route("10.9.0.0/16", vpc.RouteTargetTransitGateway, "tgw-0a1"),
+				{DestinationIpv6Block: "::/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetEgressOnlyInternetGateway, ID: "eigw-0gone"}, State: "active"},
+			}},
+			{RouteTableID: "rtb-0b2", VpcID: "vpc-0b2", IsMainRouteTable: true, SubnetIDs: []string{"subnet-0b2"}, Routes: []vpc.RouteInfo{
+				route("10.1.0.0/16", vpc.RouteTargetVpcPeeringConnection, "pcx-0ab"),
+			}},
+		},
+		[]vpc.SecurityGroupInfo{
+			{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", Description: "it's \\web\\\nfront end", Rules: []vpc.SecurityGroupRule{
+				{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0", Description: "HTTPS"},
+				{IpProtocol: "tcp", FromPort: 80, ToPort: 80, GroupID: "sg-0db"},
+				{IpProtocol: "tcp", FromPort: 22, ToPort: 22, PrefixListID: "pl-0corp"},
+				{IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0db", IsEgress: true},
+				{IpProtocol: "-1", GroupID: "sg-0remote", GroupVpcID: "vpc-0b2", VpcPeeringConnectionID: "pcx-0ab"},
+			}},
+			{GroupID: "sg-0db", GroupName: "db", VpcID: "vpc-0a1"},
+		},
+		[]vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1", State: "available"}},
+		[]vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", SubnetID: "subnet-0a1", State: "available", ConnectivityType: "public"}},
+		[]vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", State: "available", AmazonSideAsn: 64512}},
+		[]vpc.TransitGatewayAttachmentInfo{
+			{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0a1", ResourceType: "vpc", ResourceID: "vpc-0a1", State: "available"},
+			{AttachmentID: "tgw-attach-0vpn", TransitGatewayID: "tgw-0a1", ResourceType: "vpn", ResourceID: "vpn-0office", State: "available"},
+		},
+	)
+}
+
+// findEdge returns the edge of a type between two nodes
+func findEdge(t *testing.T, g *Graph, from, to, edgeType string) Edge {
+	t.Helper()
+	for _, edge := range g.Edges {
+		if edge.From == from && edge.To == to && edge.Type == edgeType {
+			return edge
+		}
+	}
+	t.Fatalf("no %s edge from %s to %s", edgeType, from, to)
+	return Edge{}
+}
+
+// TestBuild checks the node and edge counts of the fixture graph and spot-checks nodes and relationships
+func TestBuild(t *testing.T) {
+	g := fixtureGraph()
+
+	wantLabels := map[string]string{
+		"vpc-0a1": LabelVPC, "vpc-0b2": LabelVPC, "subnet-0a1": LabelSubnet, "subnet-0b2": LabelSubnet,
+		"sg-0web": LabelSecurityGroup, "sg-0db": LabelSecurityGroup, "igw-0a1": LabelInternetGateway, "nat-0a1": LabelNatGateway,
+		"tgw-0a1": LabelTransitGateway, "tgw-attach-0a1": LabelTransitGatewayAttachment, "tgw-attach-0vpn": LabelTransitGatewayAttachment,
+		"rtb-0a1": LabelRouteTable, "rtb-0b2": LabelRouteTable,
+		// Referenced but not scanned
+		"pcx-0ab": LabelVpcPeeringConnection, "eigw-0gone": LabelEgressOnlyInternetGateway, "0.0.0.0/0": LabelCIDR,
+		"pl-0corp": LabelPrefixList, "sg-0remote": LabelSecurityGroup,
+	}
+	unscanned := map[string]bool{"pcx-0ab": true, "eigw-0gone": true, "0.0.0.0/0": true, "pl-0corp": true, "sg-0remote": true}
+	if len(g.Nodes) != len(wantLabels) {
+		t.Errorf("got %d nodes, want %d", len(g.Nodes), len(wantLabels))
+	}
+	for _, node := range g.Nodes {
+		if wantLabels[node.ID] != node.Label {
+			t.Errorf("node %s is labelled %q, want %q", node.ID, node.Label, wantLabels[node.ID])
+		}
+		if scanned := node.Properties["scanned"]; scanned != !unscanned[node.ID] {
+			t.Errorf("node %s has scanned=%v", node.ID, scanned)
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, edge := range g.Edges {
+		counts[edge.Type]++
+	}
+	wantCounts := map[string]int{EdgeContains: 7, EdgeAttachedTo: 6, EdgeRoutesTo: 5, EdgePeeredWith: 1, EdgeAllows: 5, EdgeReferences: 3}
+	if !reflect.DeepEqual(counts, wantCounts) {
+		t.Errorf("edge counts = %v, want %v", counts, wantCounts)
+	}
+
+	tests := []struct {
+		from, to, edgeType string
+		want               map[string]interface{}
+	}{
+		{"vpc-0a1", "subnet-0a1", EdgeContains, map[string]interface{}{}},
+		{"subnet-0a1", "nat-0a1", EdgeContains, map[string]interface{}{}},
+		{"rtb-0a1", "subnet-0a1", EdgeAttachedTo, map[string]interface{}{}},
+		{"tgw-attach-0a1", "vpc-0a1", EdgeAttachedTo, map[string]interface{}{}},
+		{"tgw-attach-0vpn", "tgw-0a1", EdgeAttachedTo, map[string]interface{}{}},
+		{"rtb-0a1", "igw-0a1", EdgeRoutesTo, map[string]interface{}{"destination": "0.0.0.0/0", "state": "active", "origin": "CreateRoute"}},
+		{"rtb-0a1", "eigw-0gone", EdgeRoutesTo, map[string]interface{}{"destination": "::/0", "state": "active", "origin": ""}},
+		{"vpc-0a1", "vpc-0b2", EdgePeeredWith, map[string]interface{}{"vpc_peering_connection_id": "pcx-0ab"}},
+		{"0.0.0.0/0", "sg-0web", EdgeAllows, map[string]interface{}{"protocol": "tcp", "from_port": int64(443), "to_port": int64(443),
+			"direction": "ingress", "description": "HTTPS"}},
+		{"sg-0web", "sg-0db", EdgeAllows, map[string]interface{}{"protocol": "tcp", "from_port": int64(5432), "to_port": int64(5432),
+			"direction": "egress", "description": ""}},
+		{"sg-0web", "pl-0corp", EdgeReferences, map[string]interface{}{}},
+		{"sg-0web", "sg-0remote", EdgeReferences, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.edgeType+" "+tt.from+" "+tt.to, func(t *testing.T) {
+			if edge := findEdge(t, g, tt.from, tt.to, tt.edgeType); !reflect.DeepEqual(edge.Properties, tt.want) {
+				t.Errorf("properties = %v, want %v", edge.Properties, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteCypher checks the statement counts of the fixture and the escaping of quotes, backslashes and newlines
+func TestWriteCypher(t *testing.T) {
+	g := fixtureGraph()
+	var out bytes.Buffer
+	if err := WriteCypher(&out, g); err != nil {
+		t.Fatal(err)
+	}
+	cypher := out.String()
+
+	if n := strings.Count(cypher, "CREATE INDEX IF NOT EXISTS"); n != 12 {
+		t.Errorf("got %d index statements, want one per label (12)", n)
+	}
+	if n := strings.Count(cypher, "\nCREATE (:"); n != len(g.Nodes) {
+		t.Errorf("got %d node statements, want %d", n, len(g.Nodes))
+	}
+	if n := strings.Count(cypher, "\nMATCH "); n != len(g.Edges) {
+		t.Errorf("got %d edge statements, want %d", n, len(g.Edges))
+	}
+	if lines := strings.Count(cypher, "\n"); lines != 12+len(g.Nodes)+len(g.Edges) {
+		t.Errorf("got %d lines, want one per statement; a newline was not escaped", lines)
+	}
+
+	for _, want := range []string{
+		`CREATE (:Vpc {arn: '', cidr_block: '10.1.0.0/16', id: 'vpc-0a1', is_default: false, name: 'prod\'s "core"', scanned: true, state: 'available'});`,
+		`description: 'it\'s \\web\\\nfront end'`,
+		`MATCH (a:Cidr {id: '0.0.0.0/0'}), (b:SecurityGroup {id: 'sg-0web'}) CREATE (a)-[:ALLOWS {description: 'HTTPS', direction: 'ingress', from_port: 443, protocol: 'tcp', to_port: 443}]->(b);`,
+		`MATCH (a:RouteTable {id: 'rtb-0a1'}), (b:Subnet {id: 'subnet-0a1'}) CREATE (a)-[:ATTACHED_TO {}]->(b);`,
+		`CREATE (:PrefixList {id: 'pl-0corp', scanned: false});`,
+	} {
+		if !strings.Contains(cypher, want) {
+			t.Errorf("Cypher does not contain %s", want)
+		}
+	}
+
+	if got := cypherString(`a'b\'c`); got != `'a\'b\\\'c'` {
+		t.Errorf("cypherString() = %s", got)
+	}
+}
+
+// TestExportCSV checks the neo4j-admin headers, the row counts and that quoted values survive a CSV round trip
+func TestExportCSV(t *testing.T) {
+	g := fixtureGraph()
+	dir := filepath.Join(t.TempDir(), "graph")
+	paths, err := Export(g, dir, FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "nodes.csv"), filepath.Join(dir, "edges.csv")}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("Export() = %v, want %v", paths, want)
+	}
+
+	read := func(path string) [][]string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return records
+	}
+
+	nodes := read(paths[0])
+	if len(nodes) != len(g.Nodes)+1 {
+		t.Errorf("nodes.csv has %d rows, want a header and %d nodes", len(nodes), len(g.Nodes))
+	}
+	header := strings.Join(nodes[0], ",")
+	for _, column := range []string{"id:ID,:LABEL,", "amazon_side_asn:long", "is_default:boolean", "scanned:boolean", ",name,"} {
+		if !strings.Contains(header, column) {
+			t.Errorf("nodes.csv header %q has no column %s", header, column)
+		}
+	}
+	name := -1
+	for i, column := range nodes[0] {
+		if column == "name" {
+			name = i
+		}
+	}
+	if nodes[1][0] != "vpc-0a1" || nodes[1][1] != LabelVPC || nodes[1][name] != `prod's "core"` {
+		t.Errorf("first node = %v, want vpc-0a1 named prod's \"core\"", nodes[1])
+	}
+
+	edges := read(paths[1])
+	if len(edges) != len(g.Edges)+1 {
+		t.Errorf("edges.csv has %d rows, want a header and %d edges", len(edges), len(g.Edges))
+	}
+	if header := strings.Join(edges[0], ","); !strings.HasPrefix(header, ":START_ID,:END_ID,:TYPE,") || !strings.Contains(header, "from_port:long") {
+		t.Errorf("edges.csv header = %q", header)
+	}
+
+	cypherPaths, err := Export(g, dir, FormatCypher)
+	if err != nil || len(cypherPaths) != 1 || filepath.Base(cypherPaths[0]) != "graph.cypher" {
+		t.Errorf("Export(cypher) = %v, %v; want graph.cypher", cypherPaths, err)
+	}
+}
+
+// TestParseFormat checks the accepted -graph-format values
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Format
+		wantErr bool
+	}{
+		{"cypher", FormatCypher, false},
+		{"CSV", FormatCSV, false},
+		{"graphson", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.input)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package lifecycle provides functionality for computing resource ages and summarizing infrastructure lifecycle
+package lifecycle
+
+import (
+	"sort"
+	"time"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Timestamp sources, in the order they are consulted
+const (
+	SourceNative     = "native"     // Creation time reported by the resource's own Describe API
+	SourceConfig     = "config"     // Creation time recovered from AWS Config history
+	SourceCloudTrail = "cloudtrail" // Creation time recovered from the CloudTrail Create* event
+	SourceUnknown    = "unknown"    // No source could provide a creation time
+)
+
+// recentWindow is the window used for the "recently created" list
+const recentWindow = 30 * 24 * time.Hour
+
+// oldestLimit caps the number of entries in the oldest resources list
+const oldestLimit = 10
+
+// ageBuckets defines the histogram buckets as upper bounds in days (exclusive)
+var ageBuckets = []struct {
+	Label   string
+	MaxDays int
+}{
+	{"<30d", 30},
+	{"30-90d", 90},
+	{"90-180d", 180},
+	{"180d-1y", 365},
+	{"1-2y", 730},
+	{"2-3y", 1095},
+	{">3y", -1}, // No upper bound
+}
+
+// Resource identifies a scanned resource whose age should be computed
+type Resource struct {
+	ResourceType string // Resource type (vpc, subnet, nat_gateway, ...)
+	ResourceID   string // Unique identifier of the resource
+	Name         string // Name tag of the resource, falling back to the ID
+	CreatedTime  string // Creation time reported by the Describe API (empty if not exposed)
+}
+
+// TimestampSource resolves creation times for resources that do not expose one natively
+// whatsnew.CreationSource reads them from the CloudTrail event history.
+type TimestampSource interface {
+	// Name returns the source identifier recorded on resolved ages (config, cloudtrail)
+	Name() string
+	// CreationTime returns the creation time of a resource and whether it was found
+	CreationTime(resourceType, resourceID string) (time.Time, bool)
+}
+
+// ResourceAge contains the resolved age of a single resource
+type ResourceAge struct {
+	ResourceType string `json:"resource_type"`          // Resource type (vpc, subnet, nat_gateway, ...)
+	ResourceID   string `json:"resource_id"`            // Unique identifier of the resource
+	Name         string `json:"name"`                   // Name tag of the resource, falling back to the ID
+	CreatedTime  string `json:"created_time,omitempty"` // Resolved creation time (empty when unknown)
+	AgeDays      *int   `json:"age_days"`               // Age in whole days (null when unknown)
+	Source       string `json:"source"`                 // Where the creation time came from (native, config, cloudtrail, unknown)
+}
+
+// HistogramBucket contains the number of resources whose age falls into a bucket
+type HistogramBucket struct {
+	Label string `json:"label"` // Human-readable bucket label (<30d, 30-90d, ...)
+	Count int    `json:"count"` // Number of resources in the bucket
+}
+
+// Summary contains the lifecycle section of the scan summary
+type Summary struct {
+	GeneratedAt string                       `json:"generated_at"` // Reference time used to compute ages
+	Histogram   map[string][]HistogramBucket `json:"histogram"`    // Age histogram per resource type
+	Unknown     map[string]int               `json:"unknown"`      // Count of resources without a creation time per resource type
+	Oldest      []ResourceAge                `json:"oldest"`       // Oldest resources across all types
+	Recent      []ResourceAge                `json:"recent"`       // Resources created within the last 30 days
+	Resources   []ResourceAge                `json:"resources"`    // Resolved age of every resource
+}
+
+// CollectResources gathers the resources from a scan whose age can be reported
+// Returns: Slice of Resource entries, with CreatedTime set for types that expose a creation timestamp
+func CollectResources(
+	vpcs []vpc.VPCInfo,
+	subnets []vpc.SubnetInfo,
+	securityGroups []vpc.SecurityGroupInfo,
+	natGateways []vpc.NatGatewayInfo,
+	transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo,
+	vpnConnections []vpc.VpnConnectionInfo,
+	vpcEndpoints []vpc.VpcEndpointInfo,
+	instances []vpc.InstanceInfo,
+) []Resource {
+	var resources []Resource
+
+	// VPCs, subnets and security groups do not expose a creation time
+	for _, v := range vpcs {
+		resources = append(resources, Resource{ResourceType: "vpc", ResourceID: v.VpcID, Name: resourceName(v.Tags, v.VpcID)})
+	}
+	for _, s := range subnets {
+		resources = append(resources, Resource{ResourceType: "subnet", ResourceID: s.SubnetID, Name: resourceName(s.Tags, s.SubnetID)})
+	}
+	for _, sg := range securityGroups {
+		resources = append(resources, Resource{ResourceType: "security_group", ResourceID: sg.GroupID, Name: resourceName(sg.Tags, sg.GroupID)})
+	}
+
+	// Gateways, attachments and endpoints report their creation time natively
+	for _, ngw := range natGateways {
+		resources = append(resources, Resource{ResourceType: "nat_gateway", ResourceID: ngw.NatGatewayID, Name: resourceName(ngw.Tags, ngw.NatGatewayID), CreatedTime: ngw.CreatedTime})
+	}
+	for _, tgw := range transitGateways {
+		resources = append(resources, Resource{ResourceType: "transit_gateway", ResourceID: tgw.TransitGatewayID, Name: resourceName(tgw.Tags, tgw.TransitGatewayID), CreatedTime: tgw.CreationTime})
+	}
+	for _, attachment := range tgwAttachments {
+		resources = append(resources, Resource{ResourceType: "transit_gateway_attachment", ResourceID: attachment.AttachmentID, Name: resourceName(attachment.Tags, attachment.AttachmentID), CreatedTime: attachment.CreationTime})
+	}
+	for _, endpoint := range vpcEndpoints {
+		resources = append(resources, Resource{ResourceType: "vpc_endpoint", ResourceID: endpoint.VpcEndpointID, Name: resourceName(endpoint.Tags, endpoint.VpcEndpointID), CreatedTime: endpoint.CreationTime})
+	}
+
+	// VPN connections do not expose a creation time, and the launch time of an instance is its last start
+	for _, connection := range vpnConnections {
+		resources = append(resources, Resource{ResourceType: "vpn_connection", ResourceID: connection.VpnConnectionID, Name: resourceName(connection.Tags, connection.VpnConnectionID)})
+	}
+	for _, instance := range instances {
+		resources = append(resources, Resource{ResourceType: "instance", ResourceID: instance.InstanceID, Name: resourceName(instance.Tags, instance.InstanceID)})
+	}
+
+	return resources
+}
+
+// NeedsSources reports whether any resource lacks a native creation time, so fallback sources are worth asking
+func NeedsSources(resources []Resource) bool {
+	for _, resource := range resources {
+		if created, err := time.Parse(time.RFC3339, resource.CreatedTime); err != nil || created.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveAge determines the creation time of a resource, consulting sources in order
+// now: Reference time used to compute the age
+// resource: The resource to resolve
+// sources: Fallback sources (Config, then CloudTrail) consulted when no native timestamp exists
+// Returns: ResourceAge with Source set to the first source that provided a timestamp, or unknown
+func ResolveAge(now time.Time, resource Resource, sources ...TimestampSource) ResourceAge {
+	age := ResourceAge{
+		ResourceType: resource.ResourceType,
+		ResourceID:   resource.ResourceID,
+		Name:         resource.Name,
+		Source:       SourceUnknown,
+	}
+
+	// Native field first
+	if created, err := time.Parse(time.RFC3339, resource.CreatedTime); err == nil && !created.IsZero() {
+		setAge(&age, now, created, SourceNative)
+		return age
+	}
+
+	// Then each fallback source in the order given
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+		if created, ok := source.CreationTime(resource.ResourceType, resource.ResourceID); ok && !created.IsZero() {
+			setAge(&age, now, created, source.Name())
+			return age
+		}
+	}
+
+	return age
+}
+
+// Analyze computes the lifecycle summary for a set of resources
+// now: Reference time used to compute ages
+// resources: Resources to analyze, typically from CollectResources
+// sources: Fallback timestamp sources in resolution order
+// Returns: Summary with per-type histograms, oldest and recently created resources
+func Analyze(now time.Time, resources []Resource, sources ...TimestampSource) *Summary {
+	summary := &Summary{
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+		Histogram:   make(map[string][]HistogramBucket),
+		Unknown:     make(map[string]int),
+		Oldest:      []ResourceAge{},
+		Recent:      []ResourceAge{},
+		Resources:   []ResourceAge{},
+	}
+
+	var known []ResourceAge
+	for _, resource := range resources {
+		age := ResolveAge(now, resource, sources...)
+		summary.Resources = append(summary.Resources, age)
+
+		// Make sure every type has a histogram, even if all ages are unknown
+		if _, ok := summary.Histogram[age.ResourceType]; !ok {
+			summary.Histogram[age.ResourceType] = newHistogram()
+		}
+
+		if age.AgeDays == nil {
+			summary.Unknown[age.ResourceType]++
+			continue
+		}
+
+		buckets := summary.Histogram[age.ResourceType]
+		buckets[bucketIndex(*age.AgeDays)].Count++
+		known = append(known, age)
+
+		created, _ := time.Parse(time.RFC3339, age.CreatedTime)
+		if now.Sub(created) <= recentWindow {
+			summary.Recent = append(summary.Recent, age)
+		}
+	}
+
+	// Oldest first, ties broken by resource ID for deterministic output
+	sort.SliceStable(known, func(i, j int) bool {
+		if *known[i].AgeDays != *known[j].AgeDays {
+			return *known[i].AgeDays > *known[j].AgeDays
+		}
+		return known[i].ResourceID < known[j].ResourceID
+	})
+	if len(known) > oldestLimit {
+		known = known[:oldestLimit]
+	}
+	summary.Oldest = append(summary.Oldest, known...)
+
+	// Newest first
+	sort.SliceStable(summary.Recent, func(i, j int) bool {
+		return summary.Recent[i].CreatedTime > summary.Recent[j].CreatedTime
+	})
+
+	return summary
+}
+
+// setAge fills in the creation time, age and source on a ResourceAge
+func setAge(age *ResourceAge, now, created time.Time, source string) {
+	days := int(now.Sub(created).Hours() / 24)
+	if days < 0 {
+		days = 0
+	}
+	age.CreatedTime = created.UTC().Format(time.RFC3339)
+	age.AgeDays = &days
+	age.Source = source
+}
+
+// newHistogram creates an empty set of histogram buckets
+func newHistogram() []HistogramBucket {
+	buckets := make([]HistogramBucket, len(ageBuckets))
+	for i, b := range ageBuckets {
+		buckets[i].Label = b.Label
+	}
+	return buckets
+}
+
+// bucketIndex returns the histogram bucket for an age in days
+func bucketIndex(days int) int {
+	for i, b := range ageBuckets {
+		if b.MaxDays < 0 || days < b.MaxDays {
+			return i
+		}
+	}
+	return len(ageBuckets) - 1
+}
+
+// resourceName extracts a friendly name from tags, falling back to the resource ID
+func resourceName(tags map[string]string, resourceID string) string {
+	if name, ok := tags["Name"]; ok && name != "" {
+		return name
+	}
+	return resourceID
+}
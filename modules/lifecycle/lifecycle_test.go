@@ -0,0 +1,142 @@
+package lifecycle
+
+import (
+	"testing"
+	"time"
+
+	"aws-documentor/modules/vpc"
+)
+
+// fakeSource is a timestamp source with fixed creation times
+type fakeSource struct {
+	name    string
+	created map[string]time.Time // Creation time per resource ID
+	asked   []string             // Resource IDs the source was asked for
+}
+
+func (s *fakeSource) Name() string { return s.name }
+
+func (s *fakeSource) CreationTime(resourceType, resourceID string) (time.Time, bool) {
+	s.asked = append(s.asked, resourceID)
+	created, ok := s.created[resourceID]
+	return created, ok
+}
+
+// TestResolveAgeOrder checks that the native time wins, then AWS Config, then CloudTrail, and that a resource none of them knows is unknown
+func TestResolveAgeOrder(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	config := &fakeSource{name: SourceConfig, created: map[string]time.Time{
+		"nat-0a1":    now.AddDate(0, 0, -400),
+		"vpc-0a1":    now.AddDate(0, 0, -100),
+		"subnet-0a1": now.AddDate(0, 0, -50),
+	}}
+	cloudTrail := &fakeSource{name: SourceCloudTrail, created: map[string]time.Time{
+		"nat-0a1":    now.AddDate(0, 0, -1),
+		"vpc-0a1":    now.AddDate(0, 0, -2),
+		"subnet-0b2": now.AddDate(0, 0, -3),
+	}}
+
+	tests := []struct {
+		name     string
+		resource Resource
+		want     string // Source of the age
+		wantDays int    // Age in days (-1 for unknown)
+	}{
+		{name: "native before the sources", resource: Resource{ResourceType: "nat_gateway", ResourceID: "nat-0a1", CreatedTime: "2024-05-01T00:00:00Z"}, want: SourceNative, wantDays: 31},
+		{name: "config before cloudtrail", resource: Resource{ResourceType: "vpc", ResourceID: "vpc-0a1"}, want: SourceConfig, wantDays: 100},
+		{name: "config only", resource: Resource{ResourceType: "subnet", ResourceID: "subnet-0a1"}, want: SourceConfig, wantDays: 50},
+		{name: "cloudtrail when config has none", resource: Resource{ResourceType: "subnet", ResourceID: "subnet-0b2"}, want: SourceCloudTrail, wantDays: 3},
+		{name: "invalid native time", resource: Resource{ResourceType: "vpc", ResourceID: "vpc-0a1", CreatedTime: "yesterday"}, want: SourceConfig, wantDays: 100},
+		{name: "unknown", resource: Resource{ResourceType: "instance", ResourceID: "i-0a1"}, want: SourceUnknown, wantDays: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			age := ResolveAge(now, tt.resource, config, nil, cloudTrail)
+			if age.Source != tt.want {
+				t.Errorf("source %s, want %s", age.Source, tt.want)
+			}
+			switch {
+			case tt.wantDays < 0 && (age.AgeDays != nil || age.CreatedTime != ""):
+				t.Errorf("unknown age has %v days, created %q", *age.AgeDays, age.CreatedTime)
+			case tt.wantDays >= 0 && (age.AgeDays == nil || *age.AgeDays != tt.wantDays):
+				t.Errorf("age %v, want %d days", age.AgeDays, tt.wantDays)
+			}
+		})
+	}
+
+	// A native time means no source is asked
+	config.asked = nil
+	ResolveAge(now, Resource{ResourceType: "nat_gateway", ResourceID: "nat-0a1", CreatedTime: "2024-05-01T00:00:00Z"}, config)
+	if len(config.asked) != 0 {
+		t.Errorf("sources asked for a resource with a native time: %v", config.asked)
+	}
+}
+
+// TestCollectResources checks the types collected and which of them carry a native creation time
+func TestCollectResources(t *testing.T) {
+	resources := CollectResources(
+		[]vpc.VPCInfo{{VpcID: "vpc-0a1", Tags: map[string]string{"Name": "prod"}}},
+		[]vpc.SubnetInfo{{SubnetID: "subnet-0a1"}},
+		[]vpc.SecurityGroupInfo{{GroupID: "sg-0a1"}},
+		[]vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", CreatedTime: "2024-01-01T00:00:00Z"}},
+		[]vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0a1", CreationTime: "2024-01-02T00:00:00Z"}},
+		[]vpc.TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0a1", CreationTime: "2024-01-03T00:00:00Z"}},
+		[]vpc.VpnConnectionInfo{{VpnConnectionID: "vpn-0a1"}},
+		[]vpc.VpcEndpointInfo{{VpcEndpointID: "vpce-0a1", CreationTime: "2024-01-04T00:00:00Z"}},
+		[]vpc.InstanceInfo{{InstanceID: "i-0a1", Tags: map[string]string{"Name": "bastion"}}},
+	)
+
+	want := []Resource{
+		{ResourceType: "vpc", ResourceID: "vpc-0a1", Name: "prod"},
+		{ResourceType: "subnet", ResourceID: "subnet-0a1", Name: "subnet-0a1"},
+		{ResourceType: "security_group", ResourceID: "sg-0a1", Name: "sg-0a1"},
+		{ResourceType: "nat_gateway", ResourceID: "nat-0a1", Name: "nat-0a1", CreatedTime: "2024-01-01T00:00:00Z"},
+		{ResourceType: "transit_gateway", ResourceID: "tgw-0a1", Name: "tgw-0a1", CreatedTime: "2024-01-02T00:00:00Z"},
+		{ResourceType: "transit_gateway_attachment", ResourceID: "tgw-attach-0a1", Name: "tgw-attach-0a1", CreatedTime: "2024-01-03T00:00:00Z"},
+		{ResourceType: "vpc_endpoint", ResourceID: "vpce-0a1", Name: "vpce-0a1", CreatedTime: "2024-01-04T00:00:00Z"},
+		{ResourceType: "vpn_connection", ResourceID: "vpn-0a1", Name: "vpn-0a1"},
+		{ResourceType: "instance", ResourceID: "i-0a1", Name: "bastion"},
+	}
+	if len(resources) != len(want) {
+		t.Fatalf("got %d resources, want %d: %+v", len(resources), len(want), resources)
+	}
+	for i := range want {
+		if resources[i] != want[i] {
+			t.Errorf("resource %d = %+v, want %+v", i, resources[i], want[i])
+		}
+	}
+
+	if !NeedsSources(resources) {
+		t.Errorf("NeedsSources() = false with VPCs and instances")
+	}
+	if NeedsSources(resources[3:7]) {
+		t.Errorf("NeedsSources() = true when every resource has a native time")
+	}
+}
+
+// TestAnalyzeSources checks that the summary counts unknown ages and lists recent resources from every source
+func TestAnalyzeSources(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	resources := []Resource{
+		{ResourceType: "vpc", ResourceID: "vpc-0a1"},
+		{ResourceType: "vpc", ResourceID: "vpc-0b2"},
+		{ResourceType: "instance", ResourceID: "i-0a1"},
+		{ResourceType: "nat_gateway", ResourceID: "nat-0a1", CreatedTime: "2020-01-01T00:00:00Z"},
+	}
+	cloudTrail := &fakeSource{name: SourceCloudTrail, created: map[string]time.Time{"vpc-0a1": now.AddDate(0, 0, -5), "i-0a1": now.AddDate(0, 0, -60)}}
+
+	summary := Analyze(now, resources, cloudTrail)
+	if summary.Unknown["vpc"] != 1 || summary.Unknown["instance"] != 0 {
+		t.Errorf("unknown %v, want one VPC", summary.Unknown)
+	}
+	if len(summary.Recent) != 1 || summary.Recent[0].ResourceID != "vpc-0a1" || summary.Recent[0].Source != SourceCloudTrail {
+		t.Errorf("recent %+v, want vpc-0a1 from cloudtrail", summary.Recent)
+	}
+	if len(summary.Oldest) != 3 || summary.Oldest[0].ResourceID != "nat-0a1" || summary.Oldest[1].ResourceID != "i-0a1" {
+		t.Errorf("oldest %+v", summary.Oldest)
+	}
+	if summary.Histogram["instance"][1].Count != 1 {
+		t.Errorf("instance histogram %+v, want one in 30-90d", summary.Histogram["instance"])
+	}
+}
@@ -0,0 +1,333 @@
+// Package mermaid provides functionality for generating Mermaid flowcharts from AWS VPC infrastructure data
+// Mermaid renders natively in GitHub, GitLab and Notion, so the diagram can be read without draw.io.
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/naming"
+	"aws-documentor/modules/vpc"
+)
+
+// DefaultNodeThreshold is the number of nodes above which subnets are summarized per availability zone
+// The renderers of GitHub and GitLab give up on much larger flowcharts.
+const DefaultNodeThreshold = 250
+
+// MermaidGenerator generates Mermaid flowcharts from VPC data
+type MermaidGenerator struct {
+	NodeThreshold int      // Summarize subnets per AZ when the diagram would exceed this many nodes (0 disables)
+	Notes         []string // Lines shown above the diagram in the Markdown document, such as truncation markers
+
+	ids *naming.Namer // Node IDs already emitted, used to keep them unique
+}
+
+// NewMermaidGenerator creates a new Mermaid generator
+func NewMermaidGenerator() *MermaidGenerator {
+	return &MermaidGenerator{
+		NodeThreshold: DefaultNodeThreshold,
+	}
+}
+
+// GenerateVPCDiagram creates a top-down flowchart with VPC and AZ subgraphs, subnet and gateway nodes,
+// and arrows for route-derived connectivity and transit gateway attachments
+// Returns: Mermaid source starting with "graph TD", or error if the generated flowchart is invalid
+func (mg *MermaidGenerator) GenerateVPCDiagram(
+	vpcs []vpc.VPCInfo,
+	subnets []vpc.SubnetInfo,
+	routeTables []vpc.RouteTableInfo,
+	securityGroups []vpc.SecurityGroupInfo,
+	internetGateways []vpc.InternetGatewayInfo,
+	natGateways []vpc.NatGatewayInfo,
+	transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo,
+) (string, error) {
+	mg.ids = naming.NewNamer(naming.StyleDSL)
+
+	// Decide up front whether individual subnets fit under the node threshold
+	totalNodes := len(vpcs) + len(subnets) + len(internetGateways) + len(natGateways) + len(transitGateways)
+	summarize := mg.NodeThreshold > 0 && totalNodes > mg.NodeThreshold
+
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	b.WriteString("  classDef vpc fill:#F2F7EE,stroke:#7AA116\n")
+	b.WriteString("  classDef az fill:#FFFFFF,stroke:#879196,stroke-dasharray:4 4\n")
+	b.WriteString("  classDef public fill:#E6F2E0,stroke:#7AA116\n")
+	b.WriteString("  classDef private fill:#E0ECF7,stroke:#147EBA\n")
+	b.WriteString("  classDef gateway fill:#F5E6FF,stroke:#8C4FFF\n")
+
+	// Resource ID -> node ID, used when drawing arrows
+	idFor := make(map[string]string)
+
+	for _, v := range vpcs {
+		mg.writeVPC(&b, v, subnets, internetGateways, natGateways, summarize, idFor)
+	}
+
+	// Transit gateways live at the region level, outside any VPC
+	for _, tgw := range transitGateways {
+		id := mg.id(tgw.TransitGatewayID)
+		idFor[tgw.TransitGatewayID] = id
+		label := fmt.Sprintf("%s<br/>ASN %d", escapeLabel(getResourceName(tgw.Tags, tgw.TransitGatewayID)), tgw.AmazonSideAsn)
+		fmt.Fprintf(&b, "  %s[[\"%s\"]]:::gateway\n", id, label)
+	}
+
+	for _, edge := range routeEdges(subnets, routeTables, idFor) {
+		fmt.Fprintf(&b, "  %s --> %s\n", edge[0], edge[1])
+	}
+	for _, edge := range attachmentEdges(tgwAttachments, idFor) {
+		fmt.Fprintf(&b, "  %s -.->|%s| %s\n", edge[0], escapeLabel(edge[2]), edge[1])
+	}
+
+	doc := b.String()
+	if err := Validate(doc); err != nil {
+		return "", fmt.Errorf("generated invalid Mermaid flowchart: %w", err)
+	}
+	return doc, nil
+}
+
+// Markdown wraps a flowchart in a Markdown document that GitHub, GitLab and Notion render
+// source: Flowchart from GenerateVPCDiagram
+// Returns: Document with a heading, the notes of the generator and a mermaid code block
+func (mg *MermaidGenerator) Markdown(source string) string {
+	var b strings.Builder
+	b.WriteString("# VPC diagram\n\n")
+	for _, note := range mg.Notes {
+		b.WriteString("> " + note + "\n")
+	}
+	if len(mg.Notes) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("```mermaid\n")
+	b.WriteString(source)
+	b.WriteString("```\n")
+	return b.String()
+}
+
+// writeVPC emits a VPC subgraph containing its internet gateways and AZ subgraphs with subnets and NAT gateways
+func (mg *MermaidGenerator) writeVPC(
+	b *strings.Builder,
+	vpcInfo vpc.VPCInfo,
+	allSubnets []vpc.SubnetInfo,
+	allIGWs []vpc.InternetGatewayInfo,
+	allNGWs []vpc.NatGatewayInfo,
+	summarize bool,
+	idFor map[string]string,
+) {
+	vpcID := mg.id(vpcInfo.VpcID)
+	idFor[vpcInfo.VpcID] = vpcID
+
+	vpcLabel := fmt.Sprintf("%s<br/>%s", escapeLabel(getResourceName(vpcInfo.Tags, vpcInfo.VpcID)), escapeLabel(vpc.OrUnknown(vpcInfo.CidrBlock)))
+	fmt.Fprintf(b, "  subgraph %s[\"%s\"]\n", vpcID, vpcLabel)
+
+	// Internet gateways attached to this VPC
+	for _, igw := range allIGWs {
+		if igw.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		id := mg.id(igw.InternetGatewayID)
+		idFor[igw.InternetGatewayID] = id
+		fmt.Fprintf(b, "    %s([\"%s\"]):::gateway\n", id, escapeLabel(getResourceName(igw.Tags, igw.InternetGatewayID)))
+	}
+
+	// Group subnets by availability zone, sorted for deterministic output
+	subnetsByAZ := make(map[string][]vpc.SubnetInfo)
+	var azs []string
+	for _, subnet := range allSubnets {
+		if subnet.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		if _, ok := subnetsByAZ[subnet.AvailabilityZone]; !ok {
+			azs = append(azs, subnet.AvailabilityZone)
+		}
+		subnetsByAZ[subnet.AvailabilityZone] = append(subnetsByAZ[subnet.AvailabilityZone], subnet)
+	}
+	sort.Strings(azs)
+
+	for _, az := range azs {
+		azID := mg.id(vpcInfo.VpcID + "_" + az)
+		fmt.Fprintf(b, "    subgraph %s[\"%s\"]\n", azID, escapeLabel(vpc.OrUnknown(az)))
+
+		azSubnets := subnetsByAZ[az]
+		sort.Slice(azSubnets, func(i, j int) bool { return azSubnets[i].SubnetID < azSubnets[j].SubnetID })
+
+		if summarize {
+			// One node per AZ keeps very large environments renderable
+			public := 0
+			for _, subnet := range azSubnets {
+				if subnet.MapPublicIpOnLaunch {
+					public++
+				}
+			}
+			summaryID := mg.id(azID + "_subnets")
+			for _, subnet := range azSubnets {
+				idFor[subnet.SubnetID] = summaryID
+			}
+			fmt.Fprintf(b, "      %s[\"%d subnets<br/>%d public, %d private\"]:::private\n", summaryID, len(azSubnets), public, len(azSubnets)-public)
+		} else {
+			for _, subnet := range azSubnets {
+				id := mg.id(subnet.SubnetID)
+				idFor[subnet.SubnetID] = id
+				tier := "private"
+				if subnet.MapPublicIpOnLaunch {
+					tier = "public"
+				}
+				label := fmt.Sprintf("%s<br/>%s (%s)", escapeLabel(getResourceName(subnet.Tags, subnet.SubnetID)), escapeLabel(vpc.OrUnknown(subnet.CidrBlock)), tier)
+				fmt.Fprintf(b, "      %s[\"%s\"]:::%s\n", id, label, tier)
+			}
+		}
+
+		// NAT gateways sit in the AZ of the subnet that hosts them, even when subnets are summarized
+		for _, subnet := range azSubnets {
+			for _, ngw := range allNGWs {
+				if ngw.SubnetID != subnet.SubnetID {
+					continue
+				}
+				id := mg.id(ngw.NatGatewayID)
+				idFor[ngw.NatGatewayID] = id
+				fmt.Fprintf(b, "      %s{{\"%s\"}}:::gateway\n", id, escapeLabel(getResourceName(ngw.Tags, ngw.NatGatewayID)))
+			}
+		}
+
+		b.WriteString("    end\n")
+		fmt.Fprintf(b, "    class %s az\n", azID)
+	}
+
+	b.WriteString("  end\n")
+	fmt.Fprintf(b, "  class %s vpc\n", vpcID)
+}
+
+// id derives a unique, deterministic Mermaid node ID from a resource ID
+func (mg *MermaidGenerator) id(resourceID string) string {
+	return mg.ids.Name(resourceID, "")
+}
+
+// routeEdges derives subnet -> gateway arrows from the route table associated with each subnet
+// Returns: Sorted, de-duplicated [from, to] node ID pairs
+func routeEdges(subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo, idFor map[string]string) [][2]string {
+	// Index explicit associations and main route tables
+	explicit := make(map[string]vpc.RouteTableInfo)
+	mainTables := make(map[string]vpc.RouteTableInfo)
+	for _, rt := range routeTables {
+		for _, subnetID := range rt.SubnetIDs {
+			explicit[subnetID] = rt
+		}
+		if rt.IsMainRouteTable {
+			mainTables[rt.VpcID] = rt
+		}
+	}
+
+	seen := make(map[[2]string]bool)
+	var edges [][2]string
+	for _, subnet := range subnets {
+		from, ok := idFor[subnet.SubnetID]
+		if !ok {
+			continue
+		}
+		rt, ok := explicit[subnet.SubnetID]
+		if !ok {
+			if rt, ok = mainTables[subnet.VpcID]; !ok {
+				continue
+			}
+		}
+		for _, route := range rt.Routes {
+			to, ok := idFor[route.Target.ID]
+			if !ok || route.Target.ID == "" {
+				continue
+			}
+			edge := [2]string{from, to}
+			if !seen[edge] {
+				seen[edge] = true
+				edges = append(edges, edge)
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// attachmentEdges derives transit gateway -> VPC arrows from VPC attachments
+// Returns: Sorted [from, to, label] triples for attachments whose both ends are in the diagram
+func attachmentEdges(attachments []vpc.TransitGatewayAttachmentInfo, idFor map[string]string) [][3]string {
+	var edges [][3]string
+	for _, attachment := range attachments {
+		from, okFrom := idFor[attachment.TransitGatewayID]
+		to, okTo := idFor[attachment.ResourceID]
+		if !okFrom || !okTo {
+			continue
+		}
+		edges = append(edges, [3]string{from, to, vpc.OrUnknown(attachment.State)})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// labelEscapes replaces the characters that end a quoted label or are read as markup
+var labelEscapes = strings.NewReplacer("\"", "#quot;", "<", "#lt;", ">", "#gt;", "|", "#124;", "\n", " ")
+
+// escapeLabel makes a value safe to use inside a double-quoted Mermaid label or an edge label
+func escapeLabel(s string) string {
+	return labelEscapes.Replace(s)
+}
+
+// getResourceName extracts a friendly name from tags, falling back to the resource ID
+func getResourceName(tags map[string]string, resourceID string) string {
+	if name, ok := tags["Name"]; ok && name != "" {
+		return name
+	}
+	return resourceID
+}
+
+// nodePattern captures the ID of node and subgraph declarations
+var nodePattern = regexp.MustCompile(`^\s*(?:subgraph\s+)?(\w+)(?:\[|\(|\{)`)
+
+// Validate performs a syntactic sanity check on a generated Mermaid flowchart
+// doc: Mermaid source to check
+// Returns: Error describing the first problem found (missing header, unbalanced subgraphs, duplicate node IDs)
+func Validate(doc string) error {
+	lines := strings.Split(strings.TrimSpace(doc), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "graph TD" {
+		return fmt.Errorf("flowchart must start with graph TD")
+	}
+
+	depth := 0
+	ids := make(map[string]bool)
+	for i, line := range lines[1:] {
+		trimmed := strings.TrimSpace(line)
+		if m := nodePattern.FindStringSubmatch(line); m != nil {
+			if m[1] == "end" {
+				return fmt.Errorf("line %d: node ID %q is a keyword", i+2, m[1])
+			}
+			if ids[m[1]] {
+				return fmt.Errorf("line %d: duplicate node ID %q", i+2, m[1])
+			}
+			ids[m[1]] = true
+		}
+
+		if strings.HasPrefix(trimmed, "subgraph ") {
+			depth++
+		}
+		if trimmed == "end" {
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("line %d: end without subgraph", i+2)
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("%d unclosed subgraph(s)", depth)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+// Package naming derives file names and identifiers for the exporters from resource names
+// Name tags are free-form: they repeat, contain emoji and can be hundreds of characters long, none of which
+// survives as a Windows file name or a DSL identifier. Every exporter derives its identifiers here so the
+// same rules, collision handling and rename reporting apply everywhere.
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Style selects the character set and length limit of an identifier
+type Style string
+
+const (
+	StyleFilename  Style = "filename"  // File names valid on Windows, macOS and Linux
+	StyleTerraform Style = "terraform" // Terraform resource names
+	StyleDSL       Style = "dsl"       // Identifiers of diagram DSLs (PlantUML aliases, Structurizr identifiers)
+	StyleGraphID   Style = "graph-id"  // Node IDs of graph exports
+	StyleBackstage Style = "backstage" // Backstage entity names: lowercase, at most 63 characters, separators only between alphanumerics
+)
+
+// Reasons an identifier differs from the name it was derived from
+const (
+	ReasonReplaced  = "characters replaced" // Characters outside the style's set were replaced
+	ReasonReserved  = "reserved name"       // The name is reserved (CON, NUL, ... as file names)
+	ReasonTruncated = "truncated"           // The name exceeded the style's length and got a hash suffix
+	ReasonCollision = "collision"           // Another resource had the same identifier, so a suffix from the resource ID was added
+	ReasonEmpty     = "empty name"          // Nothing usable was left of the name, so the resource ID was used
+)
+
+// hashLength is the number of hex digits of the hash suffix of truncated identifiers
+const hashLength = 8
+
+// styleRule describes one style
+type styleRule struct {
+	invalid   *regexp.Regexp // Characters replaced by the separator
+	separator string         // Replacement for invalid characters
+	maxLength int            // Maximum length in bytes
+	leading   string         // Prefix added when the identifier does not start with a letter or underscore ("" to allow any start)
+}
+
+// styleRules defines the styles
+// File names stay within 100 bytes so a directory path plus the name remains below the Windows path limit.
+var styleRules = map[Style]styleRule{
+	StyleFilename:  {invalid: regexp.MustCompile(`[^A-Za-z0-9._-]+`), separator: "_", maxLength: 100},
+	StyleTerraform: {invalid: regexp.MustCompile(`[^A-Za-z0-9_-]+`), separator: "_", maxLength: 64, leading: "r_"},
+	StyleDSL:       {invalid: regexp.MustCompile(`[^A-Za-z0-9_]+`), separator: "_", maxLength: 64, leading: "r_"},
+	StyleGraphID:   {invalid: regexp.MustCompile(`[\x00-\x1f\x7f]+`), separator: "", maxLength: 128},
+	StyleBackstage: {invalid: regexp.MustCompile(`[^A-Za-z0-9._-]+`), separator: "-", maxLength: 63},
+}
+
+// separatorRun matches what Backstage does not allow between the alphanumeric parts of a name
+var separatorRun = regexp.MustCompile(`[._-]{2,}`)
+
+// windowsReserved lists the device names Windows does not allow as file names, with or without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Sanitize converts a name into an identifier of the given style
+// Invalid characters are replaced, reserved file names are prefixed with an underscore, and names over the
+// style's length are cut and suffixed with a hash of the full name, so long names stay distinct.
+// Returns: The identifier, empty if nothing usable is left of the name
+func Sanitize(name string, style Style) string {
+	identifier, _ := sanitize(name, style)
+	return identifier
+}
+
+// sanitize converts a name and returns the reasons it changed
+func sanitize(name string, style Style) (string, []string) {
+	rule, ok := styleRules[style]
+	if !ok {
+		rule = styleRules[StyleDSL]
+	}
+	var reasons []string
+
+	identifier := rule.invalid.ReplaceAllString(name, rule.separator)
+	switch {
+	case style == StyleFilename:
+		// Windows drops trailing dots and spaces, and a leading dot hides the file elsewhere
+		identifier = strings.Trim(identifier, "._")
+	case style == StyleBackstage:
+		// Backstage compares names case-insensitively, so lowercase names collide where the catalog would
+		identifier = tidy(strings.ToLower(identifier), style)
+	case rule.separator != "":
+		identifier = strings.Trim(identifier, rule.separator)
+	}
+	if identifier != name {
+		reasons = append(reasons, ReasonReplaced)
+	}
+	if identifier == "" {
+		return "", reasons
+	}
+
+	if style == StyleFilename {
+		base, _, _ := strings.Cut(identifier, ".")
+		if windowsReserved[strings.ToUpper(base)] {
+			identifier = "_" + identifier
+			reasons = append(reasons, ReasonReserved)
+		}
+	}
+	if rule.leading != "" {
+		first := rune(identifier[0])
+		if first != '_' && !unicode.IsLetter(first) {
+			identifier = rule.leading + identifier
+			if len(reasons) == 0 {
+				reasons = append(reasons, ReasonReplaced)
+			}
+		}
+	}
+
+	if len(identifier) > rule.maxLength {
+		identifier = truncate(identifier, rule.maxLength-hashLength-1) + "-" + shortHash(name)
+		if style == StyleDSL {
+			identifier = strings.ReplaceAll(identifier, "-", "_")
+		}
+		identifier = tidy(identifier, style)
+		reasons = append(reasons, ReasonTruncated)
+	}
+	return identifier, reasons
+}
+
+// Rename records an identifier that differs from the name it was derived from
+type Rename struct {
+	Style      Style    `json:"style"`       // Style of the identifier
+	ResourceID string   `json:"resource_id"` // Resource the identifier belongs to
+	Name       string   `json:"name"`        // Name the identifier was derived from
+	Identifier string   `json:"identifier"`  // Identifier that was used
+	Reasons    []string `json:"reasons"`     // Why it differs (characters replaced, truncated, collision, ...)
+}
+
+// Namer hands out unique identifiers of one style, such as the file names of one directory
+// Each resource keeps its identifier on repeated calls. When two resources map to the same identifier,
+// the later one gets a suffix from its resource ID rather than a counter, so its identifier does not
+// depend on how many other duplicates exist.
+type Namer struct {
+	style      Style
+	owners     map[string]string // Identifier -> resource ID
+	byResource map[string]string // Resource ID -> identifier
+	renames    []Rename
+}
+
+// NewNamer creates a namer for identifiers of the given style
+func NewNamer(style Style) *Namer {
+	return &Namer{
+		style:      style,
+		owners:     make(map[string]string),
+		byResource: make(map[string]string),
+	}
+}
+
+// Name returns the identifier of a resource
+// resourceID: Unique ID of the resource (used for collision suffixes and when the name is empty)
+// name: Preferred name, usually the Name tag (empty to use the resource ID)
+// Returns: Identifier unique within the namer
+func (n *Namer) Name(resourceID, name string) string {
+	if identifier, ok := n.byResource[resourceID]; ok {
+		return identifier
+	}
+
+	source := name
+	if source == "" {
+		source = resourceID
+	}
+	identifier, reasons := sanitize(source, n.style)
+	if identifier == "" {
+		identifier, _ = sanitize(resourceID, n.style)
+		reasons = append(reasons, ReasonEmpty)
+	}
+
+	if owner, taken := n.owners[identifier]; taken && owner != resourceID {
+		identifier = n.withSuffix(identifier, resourceID)
+		reasons = append(reasons, ReasonCollision)
+	}
+
+	n.owners[identifier] = resourceID
+	n.byResource[resourceID] = identifier
+	if identifier != source {
+		n.renames = append(n.renames, Rename{Style: n.style, ResourceID: resourceID, Name: name, Identifier: identifier, Reasons: reasons})
+	}
+	return identifier
+}
+
+// Renames returns every identifier that differs from its name, in the order they were handed out
+func (n *Namer) Renames() []Rename {
+	return append([]Rename{}, n.renames...)
+}
+
+// withSuffix appends a suffix derived from the resource ID to a taken identifier
+// The suffix is the part of the ID after the type prefix (vpc-0abc... gives 0abc...), sanitized for the style;
+// a hash of the ID is used if that is still taken.
+func (n *Namer) withSuffix(identifier, resourceID string) string {
+	rule := styleRules[n.style]
+	separator := "-"
+	if n.style == StyleDSL {
+		separator = "_"
+	}
+
+	suffix := resourceID
+	if _, rest, ok := strings.Cut(resourceID, "-"); ok && rest != "" {
+		suffix = rest
+	}
+	suffix, _ = sanitize(suffix, n.style)
+	for _, candidate := range []string{suffix, shortHash(resourceID)} {
+		if candidate == "" {
+			continue
+		}
+		max := rule.maxLength - len(candidate) - len(separator)
+		result := tidy(truncate(identifier, max)+separator+candidate, n.style)
+		if _, taken := n.owners[result]; !taken {
+			return result
+		}
+	}
+	fallback := shortHash(identifier + "\x00" + resourceID)
+	return tidy(truncate(identifier, rule.maxLength-len(fallback)-len(separator))+separator+fallback, n.style)
+}
+
+// tidy collapses the separator runs a Backstage name must not have, which replacing, cutting or
+// suffixing can leave behind, and trims separators from both ends; other styles are returned as they are
+func tidy(identifier string, style Style) string {
+	if style != StyleBackstage {
+		return identifier
+	}
+	return strings.Trim(separatorRun.ReplaceAllString(identifier, "-"), "._-")
+}
+
+// truncate cuts s to at most max bytes without splitting a UTF-8 sequence
+func truncate(s string, max int) string {
+	if max < 0 {
+		max = 0
+	}
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8Start(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// utf8Start reports whether b starts a UTF-8 sequence
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// shortHash returns the first hex digits of the SHA-256 of s
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
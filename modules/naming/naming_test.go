@@ -0,0 +1,144 @@
+package naming
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestSanitize covers replaced characters, unicode, reserved file names and the start rules of every style
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		style Style
+		want  string
+	}{
+		{name: "valid file name", input: "prod-vpc.drawio", style: StyleFilename, want: "prod-vpc.drawio"},
+		{name: "spaces and a check mark", input: "Prod VPC ✓", style: StyleFilename, want: "Prod_VPC"},
+		{name: "umlaut", input: "Zürich-Netz", style: StyleFilename, want: "Z_rich-Netz"},
+		{name: "CJK", input: "本番 VPC", style: StyleFilename, want: "VPC"},
+		{name: "emoji only", input: "🚀🔥", style: StyleFilename, want: ""},
+		{name: "dots only", input: "...", style: StyleFilename, want: ""},
+		{name: "path separators", input: `..\..\etc/passwd`, style: StyleFilename, want: "etc_passwd"},
+		{name: "CON", input: "CON", style: StyleFilename, want: "_CON"},
+		{name: "lower-case nul with an extension", input: "nul.txt", style: StyleFilename, want: "_nul.txt"},
+		{name: "COM1 with two extensions", input: "COM1.tar.gz", style: StyleFilename, want: "_COM1.tar.gz"},
+		{name: "reserved name as a prefix", input: "CONSOLE", style: StyleFilename, want: "CONSOLE"},
+		{name: "reserved only as a file name", input: "CON", style: StyleDSL, want: "CON"},
+		{name: "Terraform digit start", input: "1st vpc", style: StyleTerraform, want: "r_1st_vpc"},
+		{name: "DSL dashes", input: "my-vpc", style: StyleDSL, want: "my_vpc"},
+		{name: "DSL unicode", input: "café-net", style: StyleDSL, want: "caf_net"},
+		{name: "DSL leading separator", input: "_private", style: StyleDSL, want: "private"},
+		{name: "graph ID keeps unicode", input: "Zürich\tNetz\n", style: StyleGraphID, want: "ZürichNetz"},
+		{name: "Backstage", input: "My__VPC--Prod", style: StyleBackstage, want: "my-vpc-prod"},
+		{name: "Backstage unicode", input: "Ünïcode VPC", style: StyleBackstage, want: "n-code-vpc"},
+		{name: "unknown style is DSL", input: "a-b", style: Style("other"), want: "a_b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.input, tt.style); got != tt.want {
+				t.Errorf("Sanitize(%q, %s) = %q, want %q", tt.input, tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSanitizeMaxLength checks that long names are cut to the style's length with a hash suffix,
+// without splitting a multibyte character, and that names differing only at the end stay distinct
+func TestSanitizeMaxLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		style Style
+	}{
+		{name: "file name", input: strings.Repeat("a", 150), style: StyleFilename},
+		{name: "Terraform", input: strings.Repeat("vpc_", 30), style: StyleTerraform},
+		{name: "DSL", input: strings.Repeat("b", 65), style: StyleDSL},
+		{name: "graph ID multibyte", input: strings.Repeat("ü", 100), style: StyleGraphID},
+		{name: "Backstage", input: strings.Repeat("net-", 20), style: StyleBackstage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sanitize(tt.input, tt.style)
+			other := Sanitize(tt.input+"x", tt.style)
+			if len(got) > styleRules[tt.style].maxLength {
+				t.Errorf("Sanitize() = %d bytes, want at most %d", len(got), styleRules[tt.style].maxLength)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Sanitize() = %q, which splits a UTF-8 sequence", got)
+			}
+			if !strings.HasSuffix(got, shortHash(tt.input)) {
+				t.Errorf("Sanitize() = %q, want the hash suffix %s", got, shortHash(tt.input))
+			}
+			if got == other {
+				t.Errorf("names differing at the end both became %q", got)
+			}
+		})
+	}
+
+	exact := strings.Repeat("a", styleRules[StyleFilename].maxLength)
+	if got := Sanitize(exact, StyleFilename); got != exact {
+		t.Errorf("a name of exactly the maximum length became %q", got)
+	}
+}
+
+// TestNamerName covers duplicates, repeated calls, empty and unusable names and the reasons of the renames
+func TestNamerName(t *testing.T) {
+	namer := NewNamer(StyleFilename)
+	calls := []struct {
+		resourceID string
+		name       string
+		want       string
+		reasons    []string // Reasons of the rename (nil if the name is used as it is)
+	}{
+		{resourceID: "vpc-0aaa", name: "web", want: "web"},
+		{resourceID: "vpc-0bbb", name: "web", want: "web-0bbb", reasons: []string{ReasonCollision}},
+		{resourceID: "subnet-0bbb", name: "web", want: "web-" + shortHash("subnet-0bbb"), reasons: []string{ReasonCollision}},
+		{resourceID: "vpc-0aaa", name: "renamed", want: "web"},
+		{resourceID: "vpc-0ccc", name: "", want: "vpc-0ccc"},
+		{resourceID: "vpc-0ddd", name: "🚀", want: "vpc-0ddd", reasons: []string{ReasonReplaced, ReasonEmpty}},
+		{resourceID: "vpc-0eee", name: "NUL", want: "_NUL", reasons: []string{ReasonReserved}},
+		{resourceID: "vpc-0fff", name: "Prod ✓", want: "Prod", reasons: []string{ReasonReplaced}},
+		{resourceID: "vpc-0fa1", name: "Prod", want: "Prod-0fa1", reasons: []string{ReasonCollision}},
+	}
+	renames := 0
+	for _, call := range calls {
+		if got := namer.Name(call.resourceID, call.name); got != call.want {
+			t.Errorf("Name(%q, %q) = %q, want %q", call.resourceID, call.name, got, call.want)
+		}
+		if call.reasons == nil {
+			continue
+		}
+		all := namer.Renames()
+		if len(all) != renames+1 {
+			t.Fatalf("Name(%q, %q) recorded %d renames, want %d", call.resourceID, call.name, len(all), renames+1)
+		}
+		renames++
+		if last := all[len(all)-1]; last.ResourceID != call.resourceID || last.Identifier != call.want || !slices.Equal(last.Reasons, call.reasons) {
+			t.Errorf("rename = %+v, want %s -> %s for %v", last, call.resourceID, call.want, call.reasons)
+		}
+	}
+}
+
+// TestNamerLongDuplicates checks that duplicates of long names stay within the maximum length and distinct
+func TestNamerLongDuplicates(t *testing.T) {
+	for _, style := range []Style{StyleFilename, StyleTerraform, StyleDSL, StyleGraphID, StyleBackstage} {
+		t.Run(string(style), func(t *testing.T) {
+			namer := NewNamer(style)
+			name := strings.Repeat("ü-long-name-", 20)
+			seen := make(map[string]bool)
+			for _, id := range []string{"vpc-0aaa", "vpc-0bbb", "subnet-0bbb", "vpc-0ccc"} {
+				got := namer.Name(id, name)
+				if len(got) > styleRules[style].maxLength || !utf8.ValidString(got) {
+					t.Errorf("Name(%q) = %q (%d bytes), want valid UTF-8 of at most %d bytes", id, got, len(got), styleRules[style].maxLength)
+				}
+				if seen[got] {
+					t.Errorf("Name(%q) = %q, which another resource has", id, got)
+				}
+				seen[got] = true
+			}
+		})
+	}
+}
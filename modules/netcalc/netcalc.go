@@ -0,0 +1,216 @@
+// Package netcalc provides address, port and protocol containment checks used by the rule analyses
+package netcalc
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Normalized protocol names returned by NormalizeProtocol
+const (
+	ProtocolAll    = "-1"     // All protocols (and all ports)
+	ProtocolTCP    = "tcp"    // IP protocol 6
+	ProtocolUDP    = "udp"    // IP protocol 17
+	ProtocolICMP   = "icmp"   // IP protocol 1; ports are ICMP type and code
+	ProtocolICMPv6 = "icmpv6" // IP protocol 58; ports are ICMPv6 type and code
+)
+
+// protocolNames maps protocol numbers to the names the EC2 API uses for them
+var protocolNames = map[string]string{
+	"6":  ProtocolTCP,
+	"17": ProtocolUDP,
+	"1":  ProtocolICMP,
+	"58": ProtocolICMPv6,
+}
+
+// NormalizeProtocol converts a security group protocol (name or number) to a canonical form
+// protocol: IpProtocol as returned by the EC2 API (tcp, 6, -1, 50, ...)
+// Returns: Lower-case protocol name for tcp, udp, icmp and icmpv6, otherwise the value unchanged
+func NormalizeProtocol(protocol string) string {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if name, ok := protocolNames[protocol]; ok {
+		return name
+	}
+	if protocol == "all" {
+		return ProtocolAll
+	}
+	return protocol
+}
+
+// HasPorts reports whether FromPort/ToPort carry a port range for the protocol
+func HasPorts(protocol string) bool {
+	switch NormalizeProtocol(protocol) {
+	case ProtocolTCP, ProtocolUDP:
+		return true
+	}
+	return false
+}
+
+// IsICMP reports whether FromPort/ToPort carry an ICMP type and code for the protocol
+func IsICMP(protocol string) bool {
+	switch NormalizeProtocol(protocol) {
+	case ProtocolICMP, ProtocolICMPv6:
+		return true
+	}
+	return false
+}
+
+// PortRangeContains reports whether the port range outer covers the port range inner
+// A range of -1 (or -1 to -1) means all ports
+func PortRangeContains(outerFrom, outerTo, innerFrom, innerTo int32) bool {
+	if outerFrom == -1 || (outerFrom <= 0 && outerTo >= 65535) {
+		return true
+	}
+	if innerFrom == -1 {
+		return false
+	}
+	return outerFrom <= innerFrom && innerTo <= outerTo
+}
+
+// ICMPContains reports whether an ICMP type/code pair covers another
+// A type of -1 means all types (the code is then ignored); a code of -1 means all codes of the type
+func ICMPContains(outerType, outerCode, innerType, innerCode int32) bool {
+	if outerType == -1 {
+		return true
+	}
+	if innerType != outerType {
+		return false
+	}
+	return outerCode == -1 || outerCode == innerCode
+}
+
+// CIDRContains reports whether the CIDR block outer contains every address of inner
+// IPv4 and IPv6 are separate address spaces: a block never contains one of the other family
+// Returns: Containment, or error if either block is not a valid CIDR
+func CIDRContains(outer, inner string) (bool, error) {
+	_, outerNet, err := net.ParseCIDR(outer)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR block %q: %w", outer, err)
+	}
+	_, innerNet, err := net.ParseCIDR(inner)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR block %q: %w", inner, err)
+	}
+
+	outerOnes, outerBits := outerNet.Mask.Size()
+	innerOnes, innerBits := innerNet.Mask.Size()
+	if outerBits != innerBits {
+		return false, nil
+	}
+	return outerOnes <= innerOnes && outerNet.Contains(innerNet.IP), nil
+}
+
+// CIDROverlaps reports whether two CIDR blocks of the same family share any address
+// Returns: Overlap, or error if either block is not a valid CIDR
+func CIDROverlaps(a, b string) (bool, error) {
+	aContains, err := CIDRContains(a, b)
+	if err != nil || aContains {
+		return aContains, err
+	}
+	return CIDRContains(b, a)
+}
+
+// PrefixLength returns the number of leading one bits in the mask of a CIDR block
+// Used to pick the most specific of several matching routes
+// Returns: Prefix length, or error if the block is not a valid CIDR
+func PrefixLength(cidr string) (int, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CIDR block %q: %w", cidr, err)
+	}
+	ones, _ := network.Mask.Size()
+	return ones, nil
+}
+
+// ReservedSubnetAddresses are the addresses AWS reserves in every subnet (network, router, DNS, future use, broadcast)
+const ReservedSubnetAddresses = 5
+
+// SubnetCapacity returns the usable addresses of an IPv4 subnet block: its size less the reserved addresses
+// Returns: Usable addresses, or 0 if the block is not a valid IPv4 CIDR
+func SubnetCapacity(cidr string) int {
+	prefix, err := PrefixLength(cidr)
+	if err != nil || strings.Contains(cidr, ":") {
+		return 0
+	}
+	return 1<<(32-prefix) - ReservedSubnetAddresses
+}
+
+// HostAddress returns the IPv4 address at an offset from the network address of a CIDR block
+// Used for the addresses AWS reserves in every VPC and subnet (.1 router, .2 DNS resolver)
+// Returns: Dotted-quad address, or error if the block is not a valid IPv4 CIDR or too small
+func HostAddress(cidr string, offset uint32) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR block %q: %w", cidr, err)
+	}
+	base := network.IP.To4()
+	if base == nil {
+		return "", fmt.Errorf("CIDR block %q is not IPv4", cidr)
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones < 32 && offset >= uint32(1)<<uint(bits-ones) {
+		return "", fmt.Errorf("CIDR block %q has no address at offset %d", cidr, offset)
+	}
+
+	value := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	value += offset
+	return net.IPv4(byte(value>>24), byte(value>>16), byte(value>>8), byte(value)).String(), nil
+}
+
+// SummarizeCIDRs returns the smallest list of CIDR blocks covering exactly the given addresses and blocks
+// Blocks contained in another are dropped and adjacent blocks of the same size are merged, so
+// 10.0.1.0 and 10.0.1.1 become 10.0.1.0/31. The result never covers an address the input does not.
+// blocks: CIDR blocks and single IPv4 or IPv6 addresses (as host blocks)
+// Returns: Blocks sorted by address, IPv4 first, or error if an entry is neither an address nor a CIDR
+func SummarizeCIDRs(blocks []string) ([]string, error) {
+	prefixes := make([]netip.Prefix, 0, len(blocks))
+	for _, block := range blocks {
+		prefix, err := netip.ParsePrefix(block)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(block)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid CIDR block or address %q: %w", block, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	// Every pass drops contained blocks and merges sibling pairs; a merge can enable another
+	for merged := true; merged; {
+		merged = false
+		sort.Slice(prefixes, func(i, j int) bool {
+			if c := prefixes[i].Addr().Compare(prefixes[j].Addr()); c != 0 {
+				return c < 0
+			}
+			return prefixes[i].Bits() < prefixes[j].Bits()
+		})
+		summarized := make([]netip.Prefix, 0, len(prefixes))
+		for _, prefix := range prefixes {
+			if n := len(summarized); n > 0 {
+				last := summarized[n-1]
+				if last.Bits() <= prefix.Bits() && last.Contains(prefix.Addr()) {
+					continue
+				}
+				if last.Bits() == prefix.Bits() && last.Bits() > 0 {
+					if parent, _ := last.Addr().Prefix(last.Bits() - 1); parent.Contains(prefix.Addr()) {
+						summarized[n-1] = parent
+						merged = true
+						continue
+					}
+				}
+			}
+			summarized = append(summarized, prefix)
+		}
+		prefixes = summarized
+	}
+
+	result := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		result[i] = prefix.String()
+	}
+	return result, nil
+}
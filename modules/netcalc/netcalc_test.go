@@ -0,0 +1,165 @@
+package netcalc
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestPortRangeContains covers exact and partial ranges, single ports and the -1 and 0-65535 forms of all ports
+func TestPortRangeContains(t *testing.T) {
+	tests := []struct {
+		name               string
+		outerFrom, outerTo int32
+		innerFrom, innerTo int32
+		want               bool
+	}{
+		{name: "same range", outerFrom: 80, outerTo: 443, innerFrom: 80, innerTo: 443, want: true},
+		{name: "inside", outerFrom: 1024, outerTo: 65535, innerFrom: 8080, innerTo: 8090, want: true},
+		{name: "single port", outerFrom: 22, outerTo: 22, innerFrom: 22, innerTo: 22, want: true},
+		{name: "other single port", outerFrom: 22, outerTo: 22, innerFrom: 23, innerTo: 23},
+		{name: "overlapping below", outerFrom: 100, outerTo: 200, innerFrom: 50, innerTo: 150},
+		{name: "overlapping above", outerFrom: 100, outerTo: 200, innerFrom: 150, innerTo: 250},
+		{name: "wider", outerFrom: 100, outerTo: 200, innerFrom: 0, innerTo: 65535},
+		{name: "-1 covers a range", outerFrom: -1, outerTo: -1, innerFrom: 443, innerTo: 443, want: true},
+		{name: "-1 with no to port", outerFrom: -1, outerTo: 0, innerFrom: 1, innerTo: 65535, want: true},
+		{name: "-1 covers -1", outerFrom: -1, outerTo: -1, innerFrom: -1, innerTo: -1, want: true},
+		{name: "0-65535 covers -1", outerFrom: 0, outerTo: 65535, innerFrom: -1, innerTo: -1, want: true},
+		{name: "1-65535 does not cover -1", outerFrom: 1, outerTo: 65535, innerFrom: -1, innerTo: -1},
+		{name: "range does not cover -1", outerFrom: 80, outerTo: 80, innerFrom: -1, innerTo: -1},
+		{name: "range does not cover all ports", outerFrom: 1, outerTo: 65534, innerFrom: 0, innerTo: 65535},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PortRangeContains(tt.outerFrom, tt.outerTo, tt.innerFrom, tt.innerTo); got != tt.want {
+				t.Errorf("PortRangeContains(%d, %d, %d, %d) = %v, want %v", tt.outerFrom, tt.outerTo, tt.innerFrom, tt.innerTo, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestICMPContains covers the type and code wildcards of ICMP rules
+func TestICMPContains(t *testing.T) {
+	tests := []struct {
+		name                 string
+		outerType, outerCode int32
+		innerType, innerCode int32
+		want                 bool
+	}{
+		{name: "same type and code", outerType: 3, outerCode: 4, innerType: 3, innerCode: 4, want: true},
+		{name: "other code", outerType: 3, outerCode: 4, innerType: 3, innerCode: 1},
+		{name: "other type", outerType: 8, outerCode: 0, innerType: 0, innerCode: 0},
+		{name: "all types", outerType: -1, outerCode: -1, innerType: 3, innerCode: 4, want: true},
+		{name: "all types ignores the code", outerType: -1, outerCode: 5, innerType: 3, innerCode: 4, want: true},
+		{name: "all types covers all types", outerType: -1, outerCode: -1, innerType: -1, innerCode: -1, want: true},
+		{name: "all codes of the type", outerType: 3, outerCode: -1, innerType: 3, innerCode: 13, want: true},
+		{name: "all codes covers all codes", outerType: 3, outerCode: -1, innerType: 3, innerCode: -1, want: true},
+		{name: "all codes of another type", outerType: 3, outerCode: -1, innerType: 11, innerCode: 0},
+		{name: "one code does not cover all codes", outerType: 3, outerCode: 4, innerType: 3, innerCode: -1},
+		{name: "one type does not cover all types", outerType: 8, outerCode: -1, innerType: -1, innerCode: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ICMPContains(tt.outerType, tt.outerCode, tt.innerType, tt.innerCode); got != tt.want {
+				t.Errorf("ICMPContains(%d, %d, %d, %d) = %v, want %v", tt.outerType, tt.outerCode, tt.innerType, tt.innerCode, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCIDRContains covers IPv4 and IPv6 blocks, host routes, the default routes and mixed address families
+func TestCIDRContains(t *testing.T) {
+	tests := []struct {
+		name         string
+		outer, inner string
+		want         bool
+		wantErr      bool
+	}{
+		{name: "same block", outer: "10.0.0.0/16", inner: "10.0.0.0/16", want: true},
+		{name: "subnet", outer: "10.0.0.0/16", inner: "10.0.42.0/24", want: true},
+		{name: "host", outer: "10.0.0.0/16", inner: "10.0.255.255/32", want: true},
+		{name: "supernet", outer: "10.0.42.0/24", inner: "10.0.0.0/16"},
+		{name: "disjoint", outer: "10.0.0.0/16", inner: "10.1.0.0/24"},
+		{name: "host bits in the outer block", outer: "10.0.0.7/16", inner: "10.0.3.0/24", want: true},
+		{name: "IPv4 default route", outer: "0.0.0.0/0", inner: "192.0.2.1/32", want: true},
+		{name: "IPv6 subnet", outer: "2001:db8::/32", inner: "2001:db8:1234::/48", want: true},
+		{name: "IPv6 disjoint", outer: "2001:db8::/32", inner: "2001:db9::/48"},
+		{name: "IPv6 default route", outer: "::/0", inner: "2001:db8::1/128", want: true},
+		{name: "IPv4 default route and IPv6", outer: "0.0.0.0/0", inner: "2001:db8::/32"},
+		{name: "IPv6 default route and IPv4", outer: "::/0", inner: "10.0.0.0/8"},
+		{name: "IPv4-mapped IPv6 block and IPv4", outer: "::ffff:0:0/96", inner: "10.0.0.0/8"},
+		{name: "IPv4 and IPv4-mapped IPv6 block", outer: "10.0.0.0/8", inner: "::ffff:10.0.0.0/104"},
+		{name: "invalid outer block", outer: "10.0.0.0/33", inner: "10.0.0.0/16", wantErr: true},
+		{name: "invalid inner block", outer: "10.0.0.0/16", inner: "10.0.0.1", wantErr: true},
+		{name: "empty block", outer: "", inner: "10.0.0.0/16", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CIDRContains(tt.outer, tt.inner)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CIDRContains(%q, %q) error = %v, want error %v", tt.outer, tt.inner, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CIDRContains(%q, %q) = %v, want %v", tt.outer, tt.inner, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHostAddress covers the reserved addresses of VPC blocks, offsets past the end of small blocks and invalid blocks
+func TestHostAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		offset  uint32
+		want    string
+		wantErr bool
+	}{
+		{name: "resolver of a /16", cidr: "10.0.0.0/16", offset: 2, want: "10.0.0.2"},
+		{name: "host bits ignored", cidr: "172.31.16.7/20", offset: 2, want: "172.31.16.2"},
+		{name: "carry into the next octet", cidr: "10.1.0.0/16", offset: 300, want: "10.1.1.44"},
+		{name: "last address of a /28", cidr: "10.0.0.16/28", offset: 15, want: "10.0.0.31"},
+		{name: "past the end of a /28", cidr: "10.0.0.16/28", offset: 16, wantErr: true},
+		{name: "IPv6", cidr: "2001:db8::/56", offset: 2, wantErr: true},
+		{name: "not a CIDR", cidr: "10.0.0.0", offset: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HostAddress(tt.cidr, tt.offset)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("HostAddress(%q, %d) = %q, %v, want %q (error %v)", tt.cidr, tt.offset, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSummarizeCIDRs covers merging of adjacent addresses and blocks, contained and duplicate blocks, blocks
+// that are adjacent but not siblings, mixed address families and invalid entries
+func TestSummarizeCIDRs(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocks  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", blocks: nil, want: []string{}},
+		{name: "single address", blocks: []string{"10.0.1.10"}, want: []string{"10.0.1.10/32"}},
+		{name: "sibling addresses", blocks: []string{"10.0.1.11", "10.0.1.10"}, want: []string{"10.0.1.10/31"}},
+		{name: "adjacent but not siblings", blocks: []string{"10.0.1.11", "10.0.1.12"}, want: []string{"10.0.1.11/32", "10.0.1.12/32"}},
+		{name: "merges cascade", blocks: []string{"10.0.1.0", "10.0.1.1", "10.0.1.2", "10.0.1.3"}, want: []string{"10.0.1.0/30"}},
+		{name: "contained and duplicate", blocks: []string{"10.0.1.5", "10.0.0.0/16", "10.0.0.0/16", "10.0.200.0/24"}, want: []string{"10.0.0.0/16"}},
+		{name: "sibling blocks", blocks: []string{"10.0.1.0/24", "10.0.0.0/24", "10.0.2.0/23"}, want: []string{"10.0.0.0/22"}},
+		{name: "host bits ignored", blocks: []string{"10.0.1.7/24"}, want: []string{"10.0.1.0/24"}},
+		{name: "mixed families", blocks: []string{"2001:db8::1", "2001:db8::", "192.168.0.1", "10.0.0.0/8"},
+			want: []string{"10.0.0.0/8", "192.168.0.1/32", "2001:db8::/127"}},
+		{name: "everything", blocks: []string{"0.0.0.0/1", "128.0.0.0/1"}, want: []string{"0.0.0.0/0"}},
+		{name: "invalid entry", blocks: []string{"10.0.1.0/24", "sg-0a1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SummarizeCIDRs(tt.blocks)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SummarizeCIDRs(%q) = %q, %v, want %q (error %v)", tt.blocks, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
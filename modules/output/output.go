@@ -0,0 +1,434 @@
+// Package output provides functionality for rendering scan results in the supported JSON field naming styles
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"aws-documentor/modules/vpc"
+)
+
+// FieldStyle selects how JSON field names are rendered in the output
+type FieldStyle string
+
+const (
+	FieldStyleSnake  FieldStyle = "snake"  // snake_case names as declared in the struct tags (default)
+	FieldStyleCamel  FieldStyle = "camel"  // camelCase names matching the EC2 API response shapes
+	FieldStyleConfig FieldStyle = "config" // AWS Config configuration items wrapping camelCase resources
+)
+
+// fieldOverride is a field whose AWS-native name is not a plain camelCase conversion of its snake_case name
+type fieldOverride struct {
+	Snake  string   // snake_case name from the struct tag
+	Camel  string   // AWS-native camelCase name
+	Scopes []string // snake_case keys holding the objects that have the field, or arrays of them
+}
+
+// fieldOverrides are scoped to the keys that hold their type: attachment_id, for one, is also a field of
+// Cloud WAN attachments and of several findings, which keep the plain conversion
+var fieldOverrides = []fieldOverride{
+	{Snake: "destination_ipv6_block", Camel: "destinationIpv6CidrBlock", Scopes: []string{"routes", "added_routes", "removed_routes"}},
+	{Snake: "attachment_id", Camel: "transitGatewayAttachmentId", Scopes: []string{"tgw_attachments", "added_tgw_attachments", "removed_tgw_attachments"}},
+	{Snake: "created_time", Camel: "createTime", Scopes: []string{"nat_gateways", "added_nat_gateways", "removed_nat_gateways"}},
+}
+
+// typeScopes gives the scope of a value marshalled on its own, whose objects are held by no key
+var typeScopes = map[reflect.Type]string{
+	reflect.TypeOf(vpc.RouteInfo{}):                    "routes",
+	reflect.TypeOf(vpc.TransitGatewayAttachmentInfo{}): "tgw_attachments",
+	reflect.TypeOf(vpc.NatGatewayInfo{}):               "nat_gateways",
+}
+
+// camelOverrides and snakeOverrides index fieldOverrides by scope, then by the name to convert
+var camelOverrides, snakeOverrides = func() (map[string]map[string]string, map[string]map[string]string) {
+	camel := make(map[string]map[string]string)
+	snake := make(map[string]map[string]string)
+	for _, override := range fieldOverrides {
+		for _, scope := range override.Scopes {
+			if camel[scope] == nil {
+				camel[scope], snake[scope] = make(map[string]string), make(map[string]string)
+			}
+			camel[scope][override.Snake] = override.Camel
+			snake[scope][override.Camel] = override.Snake
+		}
+	}
+	return camel, snake
+}()
+
+// rootScope returns the scope of the top-level objects of v, the value or values of one type
+func rootScope(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	return typeScopes[t]
+}
+
+// freeFormMaps lists fields whose values are user-defined maps; their keys are never renamed
+var freeFormMaps = map[string]bool{
+	"tags": true,
+}
+
+// configItemVersion is the AWS Config configuration item schema version emitted in config style
+const configItemVersion = "1.3"
+
+// configurationItem mirrors the envelope AWS Config uses for configuration snapshots
+type configurationItem struct {
+	ConfigurationItemVersion string            `json:"configurationItemVersion"` // Schema version of the configuration item
+	ResourceType             string            `json:"resourceType"`             // CloudFormation-style resource type (AWS::EC2::VPC)
+	ResourceID               string            `json:"resourceId"`               // Unique identifier of the resource
+	ARN                      string            `json:"arn,omitempty"`            // ARN of the resource, if known
+	ResourceName             string            `json:"resourceName,omitempty"`   // Name tag of the resource, if set
+	Tags                     map[string]string `json:"tags"`                     // Key-value tags associated with the resource
+	Configuration            json.RawMessage   `json:"configuration"`            // Resource description in camelCase
+}
+
+// ParseFieldStyle validates a -field-style flag value
+// s: The flag value (snake, camel or config)
+// Returns: The matching FieldStyle, or error if the value is not recognized
+func ParseFieldStyle(s string) (FieldStyle, error) {
+	switch FieldStyle(strings.ToLower(s)) {
+	case FieldStyleSnake:
+		return FieldStyleSnake, nil
+	case FieldStyleCamel:
+		return FieldStyleCamel, nil
+	case FieldStyleConfig:
+		return FieldStyleConfig, nil
+	}
+	return "", fmt.Errorf("unknown field style %q (expected snake, camel or config)", s)
+}
+
+// MarshalIndent renders a value as indented JSON using the requested field style
+// v: The value to marshal, typically one of the vpc *Info structs
+// style: Field naming style to apply
+// Returns: Indented JSON bytes, or error if marshalling fails
+func MarshalIndent(v interface{}, style FieldStyle) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch style {
+	case FieldStyleCamel:
+		data, err = rewriteKeys(data, rootScope(v), toCamel)
+	case FieldStyleConfig:
+		data, err = toConfigItem(v, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Unmarshal decodes JSON written in any field style into v, detecting the style automatically
+// data: JSON bytes produced by MarshalIndent in any style
+// v: Pointer to the destination value
+// Returns: Error if the data cannot be decoded
+func Unmarshal(data []byte, v interface{}) error {
+	normalized, err := normalize(data, rootScope(v))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(normalized, v)
+}
+
+// Normalize converts JSON written in any field style back to the snake_case struct tag names
+// data: JSON bytes produced by MarshalIndent in any style
+// Returns: Equivalent JSON using snake_case names, or error if the data is malformed
+// The top-level objects take the overrides of no type; Unmarshal knows the type and uses its overrides.
+func Normalize(data []byte) ([]byte, error) {
+	return normalize(data, "")
+}
+
+// normalize converts JSON written in any field style back to snake_case names
+// scope: Scope of the top-level objects, from rootScope
+func normalize(data []byte, scope string) ([]byte, error) {
+	switch DetectFieldStyle(data) {
+	case FieldStyleConfig:
+		unwrapped, err := unwrapConfigItems(data)
+		if err != nil {
+			return nil, err
+		}
+		return rewriteKeys(unwrapped, scope, toSnake)
+	case FieldStyleCamel:
+		return rewriteKeys(data, scope, toSnake)
+	}
+	return data, nil
+}
+
+// DetectFieldStyle inspects the keys of the first JSON object found in data
+// data: JSON bytes to inspect
+// Returns: The detected style, defaulting to snake when no distinguishing field names are found
+func DetectFieldStyle(data []byte) FieldStyle {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return FieldStyleSnake
+	}
+	if style := detectStyle(v); style != "" {
+		return style
+	}
+	return FieldStyleSnake
+}
+
+// detectStyle classifies a decoded JSON value by its object keys, descending into arrays
+func detectStyle(v interface{}) FieldStyle {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if _, ok := t["configurationItemVersion"]; ok {
+			return FieldStyleConfig
+		}
+		for key := range t {
+			if strings.Contains(key, "_") {
+				return FieldStyleSnake
+			}
+			if strings.IndexFunc(key, unicode.IsUpper) > 0 {
+				return FieldStyleCamel
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			if style := detectStyle(item); style != "" {
+				return style
+			}
+		}
+	}
+	return ""
+}
+
+// toConfigItem wraps a marshalled resource in an AWS Config configuration item envelope
+// v: The original value, used to determine the Config resource type, ID and tags
+// data: The snake_case JSON for v
+// Returns: JSON for the configuration item, or camelCase JSON when Config does not support the type
+func toConfigItem(v interface{}, data []byte) ([]byte, error) {
+	camel, err := rewriteKeys(data, rootScope(v), toCamel)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceType, resourceID, arn, tags, ok := configResource(v)
+	if !ok {
+		return camel, nil
+	}
+
+	item := configurationItem{
+		ConfigurationItemVersion: configItemVersion,
+		ResourceType:             resourceType,
+		ResourceID:               resourceID,
+		ARN:                      arn,
+		ResourceName:             tags["Name"],
+		Tags:                     tags,
+		Configuration:            camel,
+	}
+	return json.Marshal(item)
+}
+
+// configResource maps a scanned resource to its AWS Config resource type
+// v: The value being marshalled
+// Returns: Resource type, resource ID, ARN, tags, and whether AWS Config supports the type
+func configResource(v interface{}) (string, string, string, map[string]string, bool) {
+	switch r := v.(type) {
+	case vpc.VPCInfo:
+		return "AWS::EC2::VPC", r.VpcID, r.Arn, r.Tags, true
+	case vpc.VPCWithDhcpOptions:
+		return "AWS::EC2::VPC", r.VpcID, r.Arn, r.Tags, true
+	case vpc.SubnetInfo:
+		return "AWS::EC2::Subnet", r.SubnetID, r.Arn, r.Tags, true
+	case vpc.RouteTableInfo:
+		return "AWS::EC2::RouteTable", r.RouteTableID, r.Arn, r.Tags, true
+	case vpc.SecurityGroupInfo:
+		return "AWS::EC2::SecurityGroup", r.GroupID, r.Arn, r.Tags, true
+	case vpc.InternetGatewayInfo:
+		return "AWS::EC2::InternetGateway", r.InternetGatewayID, r.Arn, r.Tags, true
+	case vpc.NatGatewayInfo:
+		return "AWS::EC2::NatGateway", r.NatGatewayID, r.Arn, r.Tags, true
+	case vpc.TransitGatewayInfo:
+		return "AWS::EC2::TransitGateway", r.TransitGatewayID, r.Arn, r.Tags, true
+	case vpc.TransitGatewayAttachmentInfo:
+		return "AWS::EC2::TransitGatewayAttachment", r.AttachmentID, r.Arn, r.Tags, true
+	case vpc.TransitGatewayRouteTableInfo:
+		return "AWS::EC2::TransitGatewayRouteTable", r.RouteTableID, r.Arn, r.Tags, true
+	case vpc.VpcEndpointInfo:
+		return "AWS::EC2::VPCEndpoint", r.VpcEndpointID, r.Arn, r.Tags, true
+	case vpc.VpnGatewayInfo:
+		return "AWS::EC2::VPNGateway", r.VpnGatewayID, r.Arn, r.Tags, true
+	case vpc.CarrierGatewayInfo:
+		return "AWS::EC2::CarrierGateway", r.CarrierGatewayID, r.Arn, r.Tags, true
+	case vpc.CustomerGatewayInfo:
+		return "AWS::EC2::CustomerGateway", r.CustomerGatewayID, r.Arn, r.Tags, true
+	case vpc.DhcpOptionsInfo:
+		return "AWS::EC2::DHCPOptions", r.DhcpOptionsID, r.Arn, r.Tags, true
+	case vpc.VpnConnectionInfo:
+		return "AWS::EC2::VPNConnection", r.VpnConnectionID, r.Arn, r.Tags, true
+	case vpc.NetworkInterfaceInfo:
+		return "AWS::EC2::NetworkInterface", r.NetworkInterfaceID, r.Arn, r.Tags, true
+	case vpc.FlowLogInfo:
+		return "AWS::EC2::FlowLog", r.FlowLogID, r.Arn, r.Tags, true
+	case vpc.ElasticIPInfo:
+		return "AWS::EC2::EIP", r.AllocationID, r.Arn, r.Tags, true
+	case vpc.InstanceInfo:
+		return "AWS::EC2::Instance", r.InstanceID, r.Arn, r.Tags, true
+	}
+	return "", "", "", nil, false
+}
+
+// unwrapConfigItems replaces configuration item envelopes with their configuration payloads
+// data: JSON containing a configuration item or an array of them
+// Returns: JSON containing only the camelCase configuration payloads
+func unwrapConfigItems(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		var unwrapped []json.RawMessage
+		for _, item := range items {
+			payload, err := unwrapConfigItems(item)
+			if err != nil {
+				return nil, err
+			}
+			unwrapped = append(unwrapped, payload)
+		}
+		return json.Marshal(unwrapped)
+	}
+
+	var item configurationItem
+	if err := json.Unmarshal(trimmed, &item); err != nil {
+		return nil, err
+	}
+	if item.Configuration == nil {
+		// Not an envelope (a type Config does not support), already plain camelCase
+		return trimmed, nil
+	}
+	return item.Configuration, nil
+}
+
+// rewriteKeys re-encodes JSON with every object key passed through rename, preserving key order
+// data: JSON bytes to rewrite
+// scope: Scope of the top-level objects, from rootScope
+// rename: Function mapping an existing key to its new name, given the snake_case key holding its object
+// Returns: Compact JSON with renamed keys, or error if the input is malformed
+func rewriteKeys(data []byte, scope string, rename func(scope, key string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := rewriteValue(dec, &buf, scope, rename); err != nil {
+		return nil, fmt.Errorf("failed to rewrite JSON field names: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rewriteValue copies the next JSON value from dec to buf, renaming object keys on the way
+// Array items keep the scope of their array; an object's values take the snake_case form of their key.
+func rewriteValue(dec *json.Decoder, buf *bytes.Buffer, scope string, rename func(scope, key string) string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		// Scalar value (string, number, bool or null)
+		encoded, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		buf.Write(encoded)
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		buf.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			encodedKey, _ := json.Marshal(rename(scope, key))
+			buf.Write(encodedKey)
+			buf.WriteByte(':')
+
+			// User-defined maps such as tags keep their keys verbatim
+			childRename := rename
+			if freeFormMaps[key] {
+				childRename = keepKey
+			}
+			if err := rewriteValue(dec, buf, snakeCase(key), childRename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case '[':
+		buf.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := rewriteValue(dec, buf, scope, rename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+
+	// Consume the closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// keepKey leaves a key unchanged; used for the contents of free-form maps
+func keepKey(_, key string) string {
+	return key
+}
+
+// toCamel converts a snake_case field name to its AWS-native camelCase form
+func toCamel(scope, key string) string {
+	if camel, ok := camelOverrides[scope][key]; ok {
+		return camel
+	}
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// toSnake converts an AWS-native camelCase field name back to snake_case
+func toSnake(scope, key string) string {
+	if snake, ok := snakeOverrides[scope][key]; ok {
+		return snake
+	}
+	return snakeCase(key)
+}
+
+// snakeCase converts a camelCase name to snake_case without overrides; snake_case names are returned unchanged
+func snakeCase(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
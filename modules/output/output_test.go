@@ -0,0 +1,143 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/vpc"
+)
+
+// Fixtures with every field that has an override, and a tag key that must not be renamed
+var (
+	testAttachment = vpc.TransitGatewayAttachmentInfo{
+		AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0c1", ResourceType: "vpc", ResourceID: "vpc-0a1",
+		State: "available", Association: map[string]string{"transit_gateway_route_table_id": "tgw-rtb-1"},
+		SubnetIDs: []string{"subnet-0a1"}, Tags: map[string]string{"cost_center": "net"},
+		TagList: []vpc.Tag{{Key: "cost_center", Value: "net"}},
+	}
+	testNatGateway = vpc.NatGatewayInfo{
+		NatGatewayID: "nat-0a1", SubnetID: "subnet-0a1", VpcID: "vpc-0a1", State: "available",
+		CreatedTime: "2024-01-02T03:04:05Z", Tags: map[string]string{"Name": "egress"},
+	}
+	testRouteTable = vpc.RouteTableInfo{
+		RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a1"},
+		Routes: []vpc.RouteInfo{{DestinationIpv6Block: "::/0", GatewayID: "igw-0a1",
+			Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}}},
+	}
+	testCoreAttachment = cloudwan.AttachmentInfo{AttachmentID: "attachment-0a1", CoreNetworkID: "core-network-0a1", SegmentName: "prod"}
+)
+
+// testReport holds resources under the keys the reports use
+type testReport struct {
+	NatGateways    []vpc.NatGatewayInfo               `json:"nat_gateways"`
+	RouteTables    []vpc.RouteTableInfo               `json:"route_tables"`
+	TGWAttachments []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`
+	Attachments    []cloudwan.AttachmentInfo          `json:"attachments"`
+}
+
+// TestRoundTrip checks that Unmarshal reads back what MarshalIndent writes, in every style and format
+func TestRoundTrip(t *testing.T) {
+	values := map[string]interface{}{
+		"transit gateway attachment":  testAttachment,
+		"NAT gateway":                 testNatGateway,
+		"route table":                 testRouteTable,
+		"route":                       testRouteTable.Routes[0],
+		"Cloud WAN attachment":        testCoreAttachment,
+		"list of attachments":         []vpc.TransitGatewayAttachmentInfo{testAttachment, testAttachment},
+		"report":                      testReport{[]vpc.NatGatewayInfo{testNatGateway}, []vpc.RouteTableInfo{testRouteTable}, []vpc.TransitGatewayAttachmentInfo{testAttachment}, []cloudwan.AttachmentInfo{testCoreAttachment}},
+		"VPC with its DHCP options":   vpc.VPCWithDhcpOptions{VPCInfo: vpc.VPCInfo{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16"}},
+		"pointer to an attachment":    &testAttachment,
+		"attachment without any tags": vpc.TransitGatewayAttachmentInfo{AttachmentID: "tgw-attach-0b2"},
+	}
+
+	for name, value := range values {
+		want, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, style := range []FieldStyle{FieldStyleSnake, FieldStyleCamel, FieldStyleConfig} {
+			t.Run(name+"/"+string(style), func(t *testing.T) {
+				data, err := MarshalIndent(value, style)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded := reflect.New(reflect.TypeOf(value))
+				if err := Unmarshal(data, decoded.Interface()); err != nil {
+					t.Fatalf("Unmarshal: %v\n%s", err, data)
+				}
+				got, _ := json.Marshal(decoded.Elem().Interface())
+				if !bytes.Equal(got, want) {
+					t.Errorf("round trip changed the value:\n got %s\nwant %s\nfrom %s", got, want, data)
+				}
+			})
+		}
+	}
+}
+
+// TestCamelOverridesAreScoped checks that the AWS-native names only apply in the type that has them
+func TestCamelOverridesAreScoped(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    []string // Keys the camelCase output must have
+		notWant []string // Keys it must not have
+	}{
+		{name: "transit gateway attachment", value: testAttachment,
+			want: []string{`"transitGatewayAttachmentId"`, `"transitGatewayId"`, `"cost_center"`}, notWant: []string{`"attachmentId"`, `"costCenter"`}},
+		{name: "Cloud WAN attachment", value: testCoreAttachment,
+			want: []string{`"attachmentId"`}, notWant: []string{`"transitGatewayAttachmentId"`}},
+		{name: "NAT gateway", value: testNatGateway, want: []string{`"createTime"`}, notWant: []string{`"createdTime"`}},
+		{name: "route table", value: testRouteTable, want: []string{`"destinationIpv6CidrBlock"`}, notWant: []string{`"destinationIpv6Block"`}},
+		{name: "report", value: testReport{Attachments: []cloudwan.AttachmentInfo{testCoreAttachment}, TGWAttachments: []vpc.TransitGatewayAttachmentInfo{testAttachment}},
+			want: []string{`"attachmentId"`, `"transitGatewayAttachmentId"`}},
+		{name: "other attachment_id fields", value: map[string]string{"attachment_id": "x", "created_time": "y"},
+			want: []string{`"attachmentId"`, `"createdTime"`}, notWant: []string{`"transitGatewayAttachmentId"`, `"createTime"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MarshalIndent(tt.value, FieldStyleCamel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.want {
+				if !strings.Contains(string(data), key+":") {
+					t.Errorf("missing key %s in\n%s", key, data)
+				}
+			}
+			for _, key := range tt.notWant {
+				if strings.Contains(string(data), key+":") {
+					t.Errorf("unexpected key %s in\n%s", key, data)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectFieldStyle(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		style FieldStyle
+	}{
+		{name: "snake", value: testNatGateway, style: FieldStyleSnake},
+		{name: "camel", value: testNatGateway, style: FieldStyleCamel},
+		{name: "config", value: testNatGateway, style: FieldStyleConfig},
+		{name: "camel list", value: []vpc.NatGatewayInfo{testNatGateway}, style: FieldStyleCamel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MarshalIndent(tt.value, tt.style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := DetectFieldStyle(data); got != tt.style {
+				t.Errorf("DetectFieldStyle() = %s, want %s", got, tt.style)
+			}
+		})
+	}
+}
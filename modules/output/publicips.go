@@ -0,0 +1,51 @@
+package output
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"aws-documentor/modules/analysis"
+)
+
+// publicIPColumns is the header of the public IP CSV export
+var publicIPColumns = []string{
+	"public_ip", "kind", "allocation_id", "arn", "attached_resource_type", "attached_resource_id",
+	"network_interface_id", "vpc_id", "subnet_id", "security_group_ids", "world_open_ports", "exposure",
+	"reverse_dns", "dns_records",
+}
+
+// WritePublicIPsCSV writes the public IP inventory as CSV, one address per row
+// List columns are joined with semicolons so a row stays one CSV record.
+// w: Destination of the CSV
+// entries: Addresses from AnalyzePublicIPs
+// Returns: Error if writing fails
+func WritePublicIPsCSV(w io.Writer, entries []analysis.PublicIPEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(publicIPColumns); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		record := []string{
+			entry.PublicIp,
+			entry.Kind,
+			entry.AllocationID,
+			entry.Arn,
+			entry.AttachedResourceType,
+			entry.AttachedResourceID,
+			entry.NetworkInterfaceID,
+			entry.VpcID,
+			entry.SubnetID,
+			strings.Join(entry.SecurityGroupIDs, ";"),
+			strings.Join(entry.WorldOpenPorts, ";"),
+			entry.Exposure,
+			strings.Join(entry.ReverseDNS, ";"),
+			strings.Join(entry.DNSRecords, ";"),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
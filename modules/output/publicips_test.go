@@ -0,0 +1,40 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	"aws-documentor/modules/analysis"
+)
+
+// TestWritePublicIPsCSV checks the header and that list columns are joined so every address stays one record
+func TestWritePublicIPsCSV(t *testing.T) {
+	entries := []analysis.PublicIPEntry{
+		{PublicIp: "203.0.113.30", Kind: "ephemeral", AttachedResourceType: "load-balancer", AttachedResourceID: "app/web/50dc6c495c0c9188",
+			NetworkInterfaceID: "eni-0alb", VpcID: "vpc-0a1", SubnetID: "subnet-0pub", SecurityGroupIDs: []string{"sg-0alb", "sg-0web"},
+			WorldOpenPorts: []string{"tcp 80", "tcp 443"}, Exposure: "open to the internet: tcp 80, tcp 443",
+			DNSRecords: []string{"www.example.com (alias to web-1.eu-west-1.elb.amazonaws.com)"}},
+		{PublicIp: "198.51.100.30", Kind: "static", AllocationID: "eipalloc-0spare", Arn: "arn:aws:ec2:eu-west-1:111122223333:elastic-ip/eipalloc-0spare",
+			AttachedResourceType: "none", SecurityGroupIDs: []string{}, WorldOpenPorts: []string{}, Exposure: "not attached"},
+	}
+	var buf bytes.Buffer
+	if err := WritePublicIPsCSV(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("the export is not valid CSV: %v", err)
+	}
+	want := [][]string{
+		publicIPColumns,
+		{"203.0.113.30", "ephemeral", "", "", "load-balancer", "app/web/50dc6c495c0c9188", "eni-0alb", "vpc-0a1", "subnet-0pub",
+			"sg-0alb;sg-0web", "tcp 80;tcp 443", "open to the internet: tcp 80, tcp 443", "", "www.example.com (alias to web-1.eu-west-1.elb.amazonaws.com)"},
+		{"198.51.100.30", "static", "eipalloc-0spare", "arn:aws:ec2:eu-west-1:111122223333:elastic-ip/eipalloc-0spare", "none", "", "", "", "",
+			"", "", "not attached", "", ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q\nwant %q", records, want)
+	}
+}
@@ -0,0 +1,189 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sections of the human-oriented outputs (PDF, draw.io and PlantUML diagrams) that can be capped
+const (
+	SectionVPCs           = "vpcs"
+	SectionSubnets        = "subnets"
+	SectionRouteTables    = "route-tables"
+	SectionSecurityGroups = "security-groups"
+	SectionNatGateways    = "nat-gateways"
+	SectionTGWAttachments = "tgw-attachments"
+	SectionFindings       = "findings"
+	SectionPublicIPs      = "public-ips"
+	SectionDataWarnings   = "data-warnings"
+)
+
+// sectionNouns names the items of each section in truncation markers
+var sectionNouns = map[string]string{
+	SectionVPCs:           "VPCs",
+	SectionSubnets:        "subnets",
+	SectionRouteTables:    "route tables",
+	SectionSecurityGroups: "security groups",
+	SectionNatGateways:    "NAT gateways",
+	SectionTGWAttachments: "transit gateway attachments",
+	SectionFindings:       "findings",
+	SectionPublicIPs:      "public IPs",
+	SectionDataWarnings:   "data-quality warnings",
+}
+
+// Sections returns the names of the sections that can be capped, sorted
+func Sections() []string {
+	sections := make([]string, 0, len(sectionNouns))
+	for section := range sectionNouns {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// SectionLimits caps the number of items each section of the human-oriented outputs shows
+type SectionLimits struct {
+	Default   int            // Items per section (0 for no limit)
+	Overrides map[string]int // Limits of individual sections, replacing Default (0 for no limit)
+}
+
+// ParseSectionLimits parses the per-section overrides of -max-items-per-section
+// defaultLimit: Items per section (0 for no limit)
+// overrides: Comma-separated section=limit pairs, such as "subnets=2000,findings=0"
+// Returns: The limits, or error if a section is unknown or a limit is not a non-negative number
+func ParseSectionLimits(defaultLimit int, overrides string) (SectionLimits, error) {
+	limits := SectionLimits{Default: defaultLimit, Overrides: make(map[string]int)}
+	for _, pair := range strings.Split(overrides, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		section, value, ok := strings.Cut(pair, "=")
+		section = strings.TrimSpace(section)
+		if !ok {
+			return SectionLimits{}, fmt.Errorf("%q is not section=limit", pair)
+		}
+		if _, known := sectionNouns[section]; !known {
+			return SectionLimits{}, fmt.Errorf("unknown section %q (valid: %s)", section, strings.Join(Sections(), ", "))
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 0 {
+			return SectionLimits{}, fmt.Errorf("limit of %s must be a non-negative number, got %q", section, value)
+		}
+		limits.Overrides[section] = limit
+	}
+	return limits, nil
+}
+
+// Limit returns the number of items a section shows (0 for no limit)
+func (l SectionLimits) Limit(section string) int {
+	if limit, ok := l.Overrides[section]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// Truncation records how many items of a section the human-oriented outputs show
+type Truncation struct {
+	Section string // Section name (subnets, findings, ...)
+	Shown   int    // Items shown
+	Total   int    // Items scanned or found
+}
+
+// Truncated reports whether items of the section were left out
+func (t Truncation) Truncated() bool {
+	return t.Shown < t.Total
+}
+
+// Marker describes the truncation for the reader of a human-oriented output
+func (t Truncation) Marker() string {
+	return fmt.Sprintf("showing %s of %s %s, full data in the JSON output",
+		groupDigits(t.Shown), groupDigits(t.Total), sectionNouns[t.Section])
+}
+
+// Truncations holds the truncation of every section, decided once so all human-oriented outputs agree
+type Truncations map[string]Truncation
+
+// Total returns the untruncated number of items of a section
+// section: Section name
+// shown: Number of items the caller has, returned if the section was not truncated
+func (t Truncations) Total(section string, shown int) int {
+	if truncation, ok := t[section]; ok {
+		return truncation.Total
+	}
+	return shown
+}
+
+// Marker returns the truncation marker of a section, or "" if all of its items are shown
+func (t Truncations) Marker(section string) string {
+	if truncation, ok := t[section]; ok && truncation.Truncated() {
+		return truncation.Marker()
+	}
+	return ""
+}
+
+// Markers returns the markers of every truncated section, in section name order
+func (t Truncations) Markers() []string {
+	var markers []string
+	for _, section := range Sections() {
+		if marker := t.Marker(section); marker != "" {
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
+
+// Truncate keeps the first items of a section in the order of their sort key and records the decision
+// Selection is deterministic: items are sorted by key (ties keep their order) before the limit is applied,
+// so the same scan always shows the same items. Sections within the limit are returned unchanged.
+// truncations: Decisions so far; the section's decision is added when items are left out
+// limits: Limits per section
+// section: Section name
+// items: All items of the section
+// key: Sort key of an item, such as its VPC ID and resource ID
+// Returns: The items to show
+func Truncate[T any](truncations Truncations, limits SectionLimits, section string, items []T, key func(T) string) []T {
+	limit := limits.Limit(section)
+	if limit == 0 || len(items) <= limit {
+		return items
+	}
+	sorted := append([]T{}, items...)
+	sort.SliceStable(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+	truncations[section] = Truncation{Section: section, Shown: limit, Total: truncations.Total(section, len(items))}
+	return sorted[:limit]
+}
+
+// Filter keeps the items of a section that belong to shown items of another section, such as the
+// subnets of the VPCs a truncated VPC section still shows, and records the decision
+// A later Truncate of the same section keeps the full count recorded here.
+// truncations: Decisions so far; the section's decision is added when items are left out
+// section: Section name
+// items: All items of the section
+// keep: Whether an item is shown
+// Returns: The items to show, in their original order
+func Filter[T any](truncations Truncations, section string, items []T, keep func(T) bool) []T {
+	var kept []T
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) < len(items) {
+		truncations[section] = Truncation{Section: section, Shown: len(kept), Total: len(items)}
+	}
+	return kept
+}
+
+// groupDigits formats a count with thousands separators (38112 as 38,112)
+func groupDigits(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package output
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseSectionLimits covers overrides, spacing, a limit of 0 lifting the default, and invalid overrides
+func TestParseSectionLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides string
+		want      map[string]int // Limit per section
+		wantErr   string         // Part of the expected error ("" for none)
+	}{
+		{name: "none", overrides: "", want: map[string]int{SectionSubnets: 500, SectionFindings: 500}},
+		{name: "overrides", overrides: " subnets = 2000, findings=0,", want: map[string]int{SectionSubnets: 2000, SectionFindings: 0, SectionVPCs: 500}},
+		{name: "unknown section", overrides: "enis=10", wantErr: `unknown section "enis" (valid: data-warnings, findings, nat-gateways, public-ips, route-tables, security-groups, subnets, tgw-attachments, vpcs)`},
+		{name: "not a pair", overrides: "subnets", wantErr: `"subnets" is not section=limit`},
+		{name: "negative", overrides: "subnets=-1", wantErr: `limit of subnets must be a non-negative number, got "-1"`},
+		{name: "not a number", overrides: "subnets=many", wantErr: `limit of subnets must be a non-negative number, got "many"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits, err := ParseSectionLimits(500, tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSectionLimits() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for section, want := range tt.want {
+				if got := limits.Limit(section); got != want {
+					t.Errorf("Limit(%s) = %d, want %d", section, got, want)
+				}
+			}
+		})
+	}
+}
+
+// resource is an item of a truncated section
+type resource struct{ vpcID, id string }
+
+// TestTruncate checks that the same items are kept whatever their order, that sections within their
+// limit are returned unchanged, and the markers of truncated sections
+func TestTruncate(t *testing.T) {
+	key := func(r resource) string { return r.vpcID + " " + r.id }
+	items := []resource{{"vpc-0b2", "subnet-0a"}, {"vpc-0a1", "subnet-0c"}, {"vpc-0a1", "subnet-0a"}, {"vpc-0c3", "subnet-0b"}, {"vpc-0a1", "subnet-0b"}}
+	reversed := make([]resource, len(items))
+	for i, item := range items {
+		reversed[len(items)-1-i] = item
+	}
+	want := []resource{{"vpc-0a1", "subnet-0a"}, {"vpc-0a1", "subnet-0b"}, {"vpc-0a1", "subnet-0c"}}
+
+	limits := SectionLimits{Default: 3, Overrides: map[string]int{SectionFindings: 0, SectionVPCs: 10}}
+	for _, order := range [][]resource{items, reversed} {
+		truncations := Truncations{}
+		if got := Truncate(truncations, limits, SectionSubnets, order, key); !reflect.DeepEqual(got, want) {
+			t.Errorf("Truncate(%v) = %v, want %v", order, got, want)
+		}
+		if marker := truncations.Marker(SectionSubnets); marker != "showing 3 of 5 subnets, full data in the JSON output" {
+			t.Errorf("Marker() = %q", marker)
+		}
+	}
+	if items[0].vpcID != "vpc-0b2" {
+		t.Errorf("Truncate() reordered its input: %v", items)
+	}
+
+	truncations := Truncations{}
+	for _, section := range []string{SectionFindings, SectionVPCs} {
+		if got := Truncate(truncations, limits, section, items, key); !reflect.DeepEqual(got, items) {
+			t.Errorf("Truncate(%s) within its limit = %v, want the items unchanged", section, got)
+		}
+	}
+	if got := Truncate(truncations, limits, SectionNatGateways, items[:3], key); !reflect.DeepEqual(got, items[:3]) {
+		t.Errorf("Truncate() of exactly the limit = %v, want the items unchanged", got)
+	}
+	if len(truncations) != 0 || truncations.Markers() != nil || truncations.Total(SectionFindings, 5) != 5 {
+		t.Errorf("truncations = %+v, want none", truncations)
+	}
+}
+
+// TestFilterThenTruncate checks that a section filtered by another and then truncated keeps its full count
+func TestFilterThenTruncate(t *testing.T) {
+	truncations := Truncations{}
+	limits := SectionLimits{Default: 2}
+	var subnets []resource
+	for _, vpcID := range []string{"vpc-0a1", "vpc-0b2", "vpc-0c3"} {
+		for _, id := range []string{"subnet-0a", "subnet-0b", "subnet-0c"} {
+			subnets = append(subnets, resource{vpcID, id})
+		}
+	}
+	vpcs := Truncate(truncations, limits, SectionVPCs, []resource{{"vpc-0c3", ""}, {"vpc-0b2", ""}, {"vpc-0a1", ""}}, func(r resource) string { return r.vpcID })
+	shown := map[string]bool{}
+	for _, v := range vpcs {
+		shown[v.vpcID] = true
+	}
+
+	kept := Filter(truncations, SectionSubnets, subnets, func(r resource) bool { return shown[r.vpcID] })
+	if len(kept) != 6 || truncations[SectionSubnets] != (Truncation{Section: SectionSubnets, Shown: 6, Total: 9}) {
+		t.Fatalf("Filter() kept %v, truncation %+v", kept, truncations[SectionSubnets])
+	}
+	kept = Truncate(truncations, limits, SectionSubnets, kept, func(r resource) string { return r.vpcID + " " + r.id })
+	if want := []resource{{"vpc-0a1", "subnet-0a"}, {"vpc-0a1", "subnet-0b"}}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("Truncate() after Filter() = %v, want %v", kept, want)
+	}
+
+	want := []string{
+		"showing 2 of 9 subnets, full data in the JSON output",
+		"showing 2 of 3 VPCs, full data in the JSON output",
+	}
+	if got := truncations.Markers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Markers() = %q, want %q", got, want)
+	}
+	if got := truncations.Total(SectionSubnets, len(kept)); got != 9 {
+		t.Errorf("Total(subnets) = %d, want the 9 scanned", got)
+	}
+}
+
+// TestTruncationMarker checks the thousands separators and nouns of markers
+func TestTruncationMarker(t *testing.T) {
+	tests := []struct {
+		truncation Truncation
+		want       string
+	}{
+		{truncation: Truncation{Section: SectionSubnets, Shown: 500, Total: 38112}, want: "showing 500 of 38,112 subnets, full data in the JSON output"},
+		{truncation: Truncation{Section: SectionFindings, Shown: 1000, Total: 1234567}, want: "showing 1,000 of 1,234,567 findings, full data in the JSON output"},
+		{truncation: Truncation{Section: SectionTGWAttachments, Shown: 0, Total: 100}, want: "showing 0 of 100 transit gateway attachments, full data in the JSON output"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.truncation.Marker(); got != tt.want {
+				t.Errorf("Marker() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
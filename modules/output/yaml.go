@@ -0,0 +1,195 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Format is the syntax the documents on stdout are written in
+type Format string
+
+const (
+	FormatJSON Format = "json" // Indented JSON (default)
+	FormatYAML Format = "yaml" // YAML block style with the fields and key order of the JSON
+)
+
+// ParseFormat validates a -format flag value
+// s: The flag value (json or yaml)
+// Returns: The matching Format, or error if the value is not recognized
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatYAML:
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("unknown format %q (expected json or yaml)", s)
+}
+
+// Marshal renders a value in the given field style and format
+// The YAML is converted from the JSON, so both hold the same fields in the same order.
+// v: The value to marshal, typically one of the vpc *Info structs or an analysis report
+// style: Field naming style to apply
+// format: Syntax of the document
+// Returns: The document without a trailing newline, or error if marshalling fails
+func Marshal(v interface{}, style FieldStyle, format Format) ([]byte, error) {
+	data, err := MarshalIndent(v, style)
+	if err != nil || format != FormatYAML {
+		return data, err
+	}
+	return ToYAML(data)
+}
+
+// yamlEntry is one key of a JSON object, kept in the order of the document
+type yamlEntry struct {
+	key   string
+	value interface{}
+}
+
+// plainKey matches keys that YAML reads as strings without quotes
+var plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// yamlKeywords are plain scalars YAML 1.1 parsers read as booleans or null, so such keys are quoted
+var yamlKeywords = map[string]bool{
+	"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true, "true": true, "false": true, "null": true,
+}
+
+// ToYAML converts a JSON document to YAML in block style
+// Strings are written as double-quoted scalars, whose escapes match JSON's, so no value changes type.
+// data: JSON bytes
+// Returns: YAML without a trailing newline, or error if the JSON is malformed
+func ToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert JSON to YAML: %w", err)
+	}
+
+	var buf bytes.Buffer
+	writeYAML(&buf, value, 0, true)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// decodeOrdered reads the next JSON value, with objects as []yamlEntry so their key order is kept
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	var value interface{}
+	switch delim {
+	case '{':
+		entries := []yamlEntry{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyTok.(string)
+			child, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, yamlEntry{key: key, value: child})
+		}
+		value = entries
+	case '[':
+		items := []interface{}{}
+		for dec.More() {
+			child, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, child)
+		}
+		value = items
+	}
+
+	// Consume the closing delimiter
+	_, err = dec.Token()
+	return value, err
+}
+
+// writeYAML writes a value that follows a "key:" or "-" already on the line
+// indent: Column of the entries of an object or list value
+// inline: Whether a collection starts on the current line (after "-", or at the top of the document)
+func writeYAML(buf *bytes.Buffer, value interface{}, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	switch v := value.(type) {
+	case []yamlEntry:
+		if len(v) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		for i, entry := range v {
+			switch {
+			case i == 0 && inline && indent > 0:
+				buf.WriteByte(' ')
+			case i == 0 && !inline:
+				buf.WriteString("\n" + pad)
+			default:
+				buf.WriteString(pad)
+			}
+			buf.WriteString(yamlKey(entry.key) + ":")
+			writeYAML(buf, entry.value, indent+2, false)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		for i, item := range v {
+			switch {
+			case i == 0 && inline && indent > 0:
+				buf.WriteByte(' ')
+			case i == 0 && !inline:
+				buf.WriteString("\n" + pad)
+			default:
+				buf.WriteString(pad)
+			}
+			buf.WriteByte('-')
+			writeYAML(buf, item, indent+2, true)
+		}
+	default:
+		if indent > 0 || !inline {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+// yamlKey writes an object key, quoted unless YAML reads it as the same string plainly
+func yamlKey(key string) string {
+	if plainKey.MatchString(key) && !yamlKeywords[strings.ToLower(key)] {
+		return key
+	}
+	return yamlScalar(key)
+}
+
+// yamlScalar writes a JSON scalar; strings become double-quoted YAML scalars
+func yamlScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(v)
+	case json.Number:
+		return v.String()
+	case string:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	return fmt.Sprint(value)
+}
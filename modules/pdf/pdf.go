@@ -0,0 +1,952 @@
+// Package pdf provides functionality for rendering an AWS VPC infrastructure report as a PDF document
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+// Page geometry in millimetres
+const (
+	pageMargin = 15.0 // Left, top and right margin
+	lineHeight = 5.0  // Height of a single line of table text
+	cellPad    = 1.0  // Horizontal padding inside table cells
+)
+
+// Finding is a single entry in the report's findings list
+type Finding struct {
+	Severity   string // Finding severity or classification (dangling, orphaned-cross-account, ...)
+	ResourceID string // ID of the resource the finding is about
+	Title      string // One-line summary of the finding
+	Detail     string // Additional explanation
+}
+
+// Report contains everything rendered into the PDF
+type Report struct {
+	AccountID         string                             // AWS account ID (derived from security group owners when empty)
+	Region            string                             // AWS region that was scanned
+	GeneratedAt       time.Time                          // Time the scan was run
+	VPCs              []vpc.VPCInfo                      // Scanned VPCs
+	Subnets           []vpc.SubnetInfo                   // Scanned subnets
+	RouteTables       []vpc.RouteTableInfo               // Scanned route tables
+	SecurityGroups    []vpc.SecurityGroupInfo            // Scanned security groups
+	InternetGateways  []vpc.InternetGatewayInfo          // Scanned internet gateways
+	NatGateways       []vpc.NatGatewayInfo               // Scanned NAT gateways
+	TransitGateways   []vpc.TransitGatewayInfo           // Scanned transit gateways
+	TGWAttachments    []vpc.TransitGatewayAttachmentInfo // Scanned transit gateway attachments
+	Findings          []Finding                          // Findings from the analyses
+	PrivateZones      []dns.PrivateZoneInfo              // Scanned private hosted zones (nil when DNS was not scanned)
+	VpcEndpoints      []vpc.VpcEndpointInfo              // Scanned VPC endpoints (for their DNS names)
+	ThirdPartyVendors []analysis.VendorConnectivity      // Third parties reached through PrivateLink (nil when not analyzed)
+	PublicIPs         []analysis.PublicIPEntry           // Public IP inventory (nil when not scanned)
+	EndpointAZs       []analysis.EndpointAZCoverage      // AZ coverage of interface endpoints (nil when not analyzed)
+	EgressProfiles    []analysis.EgressProfile           // Egress profile of every VPC (nil when not analyzed)
+	EffectiveSources  *analysis.EffectiveSourcesReport   // Expanded ingress sources per security group (nil when not analyzed)
+	SubnetNetwork     []analysis.SubnetNetworkCapacity   // Network limits of the instances per subnet (nil when not analyzed)
+	ScanNotes         []string                           // Consistency caveats of the scan, such as resumed paginations
+	DataWarnings      []vpc.DataWarning                  // Missing or unrecognized fields in the API responses
+	Changes           *diff.Changes                      // Changes since an earlier report (nil when not compared)
+	HideDefaultEgress bool                               // Omit the default allow-all egress rules from the security group tables
+	Truncations       output.Truncations                 // Sections shortened by -max-items-per-section, with their full counts (nil when complete)
+}
+
+// ReportGenerator renders reports as PDF documents
+type ReportGenerator struct {
+	pdf *gofpdf.Fpdf
+	tr  func(string) string // Translates UTF-8 text to the core fonts' code page
+}
+
+// NewReportGenerator creates a new PDF report generator
+func NewReportGenerator() *ReportGenerator {
+	return &ReportGenerator{}
+}
+
+// GenerateReport renders the report as a PDF document
+// report: Scan results and findings to render
+// Returns: PDF document bytes, or error if rendering fails
+func (rg *ReportGenerator) GenerateReport(report *Report) ([]byte, error) {
+	rg.pdf = gofpdf.New("P", "mm", "A4", "")
+	rg.tr = rg.pdf.UnicodeTranslatorFromDescriptor("")
+	rg.pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	rg.pdf.SetAutoPageBreak(true, pageMargin)
+	rg.pdf.SetTitle("AWS VPC Infrastructure Report", true)
+	rg.pdf.SetCreator("aws-documentor", true)
+	rg.pdf.AliasNbPages("{nb}")
+	rg.pdf.SetFooterFunc(func() {
+		rg.pdf.SetY(-10)
+		rg.pdf.SetFont("Helvetica", "I", 8)
+		rg.pdf.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", rg.pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	rg.writeTitlePage(report)
+	if report.Changes != nil {
+		rg.writeChangeSummary(report.Changes)
+	}
+	rg.writeDiagram(report)
+	rg.writeFindings(report.Findings, report.Truncations)
+	if report.PrivateZones != nil {
+		rg.writePrivateZones(report)
+	}
+	if report.ThirdPartyVendors != nil {
+		rg.writeThirdPartyVendors(report.ThirdPartyVendors)
+	}
+	if report.PublicIPs != nil {
+		rg.writePublicIPs(report.PublicIPs, report.Truncations)
+	}
+	if report.EndpointAZs != nil {
+		rg.writeEndpointAZs(report.EndpointAZs)
+	}
+	if len(report.DataWarnings) > 0 {
+		rg.writeDataWarnings(report.DataWarnings, report.Truncations)
+	}
+	for _, v := range report.VPCs {
+		rg.writeVPCSection(report, v)
+	}
+
+	var buf bytes.Buffer
+	if err := rg.pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTitlePage renders the title, scan metadata and resource count summary
+func (rg *ReportGenerator) writeTitlePage(report *Report) {
+	rg.pdf.AddPage()
+
+	rg.pdf.SetFont("Helvetica", "B", 24)
+	rg.pdf.Ln(40)
+	rg.pdf.CellFormat(0, 12, "AWS VPC Infrastructure Report", "", 1, "C", false, 0, "")
+	rg.pdf.Ln(10)
+
+	accountID := report.AccountID
+	if accountID == "" {
+		accountID = accountFromSecurityGroups(report.SecurityGroups)
+	}
+
+	rg.pdf.SetFont("Helvetica", "", 12)
+	rg.pdf.CellFormat(0, 8, rg.tr("Account: "+valueOrUnknown(accountID)), "", 1, "C", false, 0, "")
+	rg.pdf.CellFormat(0, 8, rg.tr("Region: "+valueOrUnknown(report.Region)), "", 1, "C", false, 0, "")
+	rg.pdf.CellFormat(0, 8, "Generated: "+report.GeneratedAt.UTC().Format(time.RFC3339), "", 1, "C", false, 0, "")
+	rg.pdf.Ln(15)
+
+	// Counts cover every scanned resource, including those truncated sections leave out
+	total := report.Truncations.Total
+	rg.heading("Summary")
+	rg.table([]string{"Resource type", "Count"}, []float64{120, 60}, [][]string{
+		{"VPCs", fmt.Sprint(total(output.SectionVPCs, len(report.VPCs)))},
+		{"Subnets", fmt.Sprint(total(output.SectionSubnets, len(report.Subnets)))},
+		{"Route tables", fmt.Sprint(total(output.SectionRouteTables, len(report.RouteTables)))},
+		{"Security groups", fmt.Sprint(total(output.SectionSecurityGroups, len(report.SecurityGroups)))},
+		{"Internet gateways", fmt.Sprint(len(report.InternetGateways))},
+		{"NAT gateways", fmt.Sprint(total(output.SectionNatGateways, len(report.NatGateways)))},
+		{"Transit gateways", fmt.Sprint(len(report.TransitGateways))},
+		{"Transit gateway attachments", fmt.Sprint(total(output.SectionTGWAttachments, len(report.TGWAttachments)))},
+		{"Findings", fmt.Sprint(total(output.SectionFindings, len(report.Findings)))},
+		{"Data-quality warnings", fmt.Sprint(total(output.SectionDataWarnings, len(report.DataWarnings)))},
+	})
+
+	if markers := report.Truncations.Markers(); len(markers) > 0 {
+		rg.subheading("Truncated sections")
+		for _, marker := range markers {
+			rg.paragraph("- " + marker)
+		}
+	}
+
+	if len(report.ScanNotes) > 0 {
+		rg.subheading("Scan notes")
+		for _, note := range report.ScanNotes {
+			rg.paragraph(note)
+		}
+	}
+}
+
+// writeDiagram renders the overview diagram as boxes and lines on a landscape page
+func (rg *ReportGenerator) writeDiagram(report *Report) {
+	layout := diagram.ComputeLayout(report.VPCs, report.Subnets, report.InternetGateways, report.NatGateways, report.TransitGateways, report.TGWAttachments)
+	if len(layout.Nodes) == 0 {
+		return
+	}
+
+	rg.pdf.AddPageFormat("L", rg.pdf.GetPageSizeStr("A4"))
+	rg.heading("Overview diagram")
+	rg.truncationNote(report.Truncations, output.SectionVPCs, output.SectionSubnets, output.SectionNatGateways, output.SectionTGWAttachments)
+
+	// Scale the layout to fit the remaining page area
+	pageWidth, pageHeight := rg.pdf.GetPageSize()
+	originX, originY := pageMargin, rg.pdf.GetY()
+	areaWidth := pageWidth - 2*pageMargin
+	areaHeight := pageHeight - originY - pageMargin - 5
+	layoutWidth, layoutHeight := layout.Bounds()
+	scale := math.Min(areaWidth/layoutWidth, areaHeight/layoutHeight)
+
+	placed := layout.Placed()
+	position := func(node diagram.PlacedNode) (float64, float64) {
+		return originX + node.AbsoluteX*scale, originY + node.AbsoluteY*scale
+	}
+	fontSize := math.Max(4, math.Min(9, 12*scale/0.25))
+
+	// Edges first so boxes are drawn over them
+	nodes := make(map[string]diagram.PlacedNode)
+	for _, node := range placed {
+		nodes[node.ResourceID] = node
+	}
+	rg.pdf.SetDrawColor(140, 79, 255)
+	rg.pdf.SetLineWidth(0.3)
+	for _, edge := range layout.Edges {
+		from, okFrom := nodes[edge.From]
+		to, okTo := nodes[edge.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		fx, fy := position(from)
+		tx, ty := position(to)
+		rg.pdf.Line(fx+from.Width*scale/2, fy+from.Height*scale/2, tx+to.Width*scale/2, ty+to.Height*scale/2)
+	}
+
+	for _, node := range placed {
+		x, y := position(node)
+		w, h := node.Width*scale, node.Height*scale
+
+		label := node.Name
+		switch node.Kind {
+		case diagram.NodeVPC:
+			rg.pdf.SetDrawColor(140, 79, 255)
+			rg.pdf.Rect(x, y, w, h, "D")
+			label = fmt.Sprintf("VPC %s %s", node.Name, node.Detail)
+		case diagram.NodeSubnet:
+			if node.Public {
+				rg.pdf.SetDrawColor(122, 161, 22)
+				rg.pdf.SetFillColor(242, 246, 232)
+			} else {
+				rg.pdf.SetDrawColor(0, 164, 166)
+				rg.pdf.SetFillColor(230, 246, 247)
+			}
+			rg.pdf.Rect(x, y, w, h, "FD")
+			label = fmt.Sprintf("%s %s", node.Name, node.Detail)
+		default:
+			rg.pdf.SetDrawColor(140, 79, 255)
+			rg.pdf.SetFillColor(140, 79, 255)
+			rg.pdf.Rect(x, y, w, h, "FD")
+		}
+
+		// Container labels sit inside the top edge, icon labels below the icon
+		rg.pdf.SetFont("Helvetica", "", fontSize)
+		labelY := y + 1
+		if node.Kind != diagram.NodeVPC && node.Kind != diagram.NodeSubnet {
+			labelY = y + h + 0.5
+		}
+		rg.pdf.SetXY(x, labelY)
+		rg.pdf.CellFormat(math.Max(w, 20), fontSize*0.45, rg.tr(label), "", 0, "L", false, 0, "")
+	}
+
+	rg.pdf.SetDrawColor(0, 0, 0)
+	rg.pdf.SetLineWidth(0.2)
+}
+
+// writeFindings renders the findings list
+func (rg *ReportGenerator) writeFindings(findings []Finding, truncations output.Truncations) {
+	rg.pdf.AddPage()
+	rg.heading("Findings")
+	rg.truncationNote(truncations, output.SectionFindings)
+
+	if len(findings) == 0 {
+		rg.paragraph("No findings.")
+		return
+	}
+
+	var rows [][]string
+	for _, finding := range findings {
+		rows = append(rows, []string{finding.Severity, finding.ResourceID, finding.Title, finding.Detail})
+	}
+	rg.table([]string{"Class", "Resource", "Finding", "Detail"}, []float64{35, 35, 55, 55}, rows)
+}
+
+// writePrivateZones renders which private hosted zones resolve in which scanned VPC
+// The VPCs are split over several tables so the columns stay readable
+func (rg *ReportGenerator) writePrivateZones(report *Report) {
+	rg.pdf.AddPage()
+	rg.heading("Private hosted zones")
+
+	if len(report.PrivateZones) == 0 || len(report.VPCs) == 0 {
+		rg.paragraph("No private hosted zones.")
+		return
+	}
+
+	const vpcsPerTable = 5
+	for start := 0; start < len(report.VPCs); start += vpcsPerTable {
+		vpcs := report.VPCs[start:int(math.Min(float64(start+vpcsPerTable), float64(len(report.VPCs))))]
+
+		headers := []string{"Zone"}
+		widths := []float64{180 - 25*float64(len(vpcs))}
+		for _, v := range vpcs {
+			headers = append(headers, getResourceName(v.Tags, v.VpcID))
+			widths = append(widths, 25)
+		}
+
+		var rows [][]string
+		for _, zone := range report.PrivateZones {
+			name := zone.Name
+			if zone.CrossAccount {
+				name += fmt.Sprintf(" (account %s)", zone.OwningAccount)
+			}
+			row := []string{name}
+			for _, v := range vpcs {
+				mark := ""
+				for _, association := range zone.VPCs {
+					if association.VpcID == v.VpcID {
+						mark = "x"
+					}
+				}
+				row = append(row, mark)
+			}
+			rows = append(rows, row)
+		}
+		rg.table(headers, widths, rows)
+	}
+}
+
+// writeThirdPartyVendors renders the third parties reached through PrivateLink, one row per vendor
+func (rg *ReportGenerator) writeThirdPartyVendors(vendors []analysis.VendorConnectivity) {
+	rg.pdf.AddPage()
+	rg.heading("Third-party connectivity")
+	if len(vendors) == 0 {
+		rg.paragraph("No endpoints connect to SaaS or other third-party services.")
+		return
+	}
+
+	var rows [][]string
+	for _, vendor := range vendors {
+		rows = append(rows, []string{
+			vendor.Vendor,
+			vendor.Classification,
+			strings.Join(vendor.VpcIDs, ", "),
+			strings.Join(vendor.EndpointIDs, ", "),
+			strings.Join(vendor.SecurityGroupIDs, ", "),
+		})
+	}
+	rg.table([]string{"Vendor", "Class", "VPCs", "Endpoints", "Security groups"}, []float64{35, 35, 35, 40, 35}, rows)
+}
+
+// writePublicIPs renders the public IP inventory, one row per address
+func (rg *ReportGenerator) writePublicIPs(entries []analysis.PublicIPEntry, truncations output.Truncations) {
+	rg.pdf.AddPage()
+	rg.heading("Public IP addresses")
+	rg.truncationNote(truncations, output.SectionPublicIPs)
+	if len(entries) == 0 {
+		rg.paragraph("The account has no public IPv4 addresses in this region and no Global Accelerator static IPs.")
+		return
+	}
+
+	var rows [][]string
+	for _, entry := range entries {
+		address := entry.PublicIp
+		if names := publicIPNames(entry); len(names) > 0 {
+			address += " (" + strings.Join(names, ", ") + ")"
+		}
+		rows = append(rows, []string{
+			address,
+			entry.Kind,
+			strings.TrimSpace(entry.AttachedResourceType + " " + entry.AttachedResourceID),
+			entry.VpcID,
+			entry.Exposure,
+		})
+	}
+	rg.table([]string{"Address", "Kind", "Attached to", "VPC", "Exposure"}, []float64{28, 20, 52, 30, 50}, rows)
+}
+
+// publicIPNames returns the names -resolve-dns found for an address: its public records, then its PTR names
+func publicIPNames(entry analysis.PublicIPEntry) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, record := range entry.DNSRecords {
+		name, _, _ := strings.Cut(record, " ")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range entry.ReverseDNS {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeDataWarnings renders the fields the API returned empty or with unexpected values
+// The tables of the VPC sections show these fields as "(unknown)".
+func (rg *ReportGenerator) writeDataWarnings(warnings []vpc.DataWarning, truncations output.Truncations) {
+	rg.pdf.AddPage()
+	rg.heading("Data-quality warnings")
+	rg.truncationNote(truncations, output.SectionDataWarnings)
+	rg.paragraph("The API returned these fields empty or with values this tool does not recognize. The scan continued; the tables below show them as " + vpc.UnknownValue + ".")
+
+	var rows [][]string
+	for _, w := range warnings {
+		rows = append(rows, []string{w.ResourceType, w.ResourceID, w.Field, w.Problem, w.RawValue})
+	}
+	rg.table([]string{"Resource type", "Resource", "Field", "Problem", "Value"}, []float64{35, 55, 35, 25, 30}, rows)
+}
+
+// writeSubnetNetwork renders the network limits of the instances in the subnets of a VPC that have any
+// The worst case is every instance attaching and filling all the network interfaces its type allows.
+func (rg *ReportGenerator) writeSubnetNetwork(rollups []analysis.SubnetNetworkCapacity, vpcID string) {
+	var rows [][]string
+	for _, rollup := range rollups {
+		if rollup.VpcID != vpcID {
+			continue
+		}
+		classes := make([]string, 0, len(rollup.PerformanceClasses))
+		for class, count := range rollup.PerformanceClasses {
+			classes = append(classes, fmt.Sprintf("%s: %d", class, count))
+		}
+		sort.Strings(classes)
+		free := "-"
+		if rollup.FreeAddresses != nil {
+			free = fmt.Sprint(*rollup.FreeAddresses)
+		}
+		rows = append(rows, []string{rollup.SubnetID, fmt.Sprint(rollup.Instances), strings.Join(classes, ", "),
+			fmt.Sprint(rollup.IPsInUse), fmt.Sprint(rollup.WorstCaseIPDemand), free, fmt.Sprint(rollup.Shortfall)})
+	}
+	if len(rows) == 0 {
+		return
+	}
+	rg.subheading("Instance network capacity")
+	rg.table([]string{"Subnet ID", "Instances", "Network performance", "IPs in use", "Worst case", "Free", "Shortfall"}, []float64{38, 17, 55, 17, 19, 15, 19}, rows)
+}
+
+// writeEndpointAZs renders the endpoint coverage matrix, one row per interface endpoint and one column per AZ
+// Services show by their short name, as in the findings. A cell shows "ENI" where the endpoint has a network
+// interface in the AZ, or "missing" for a workload AZ without one.
+func (rg *ReportGenerator) writeEndpointAZs(endpoints []analysis.EndpointAZCoverage) {
+	rg.pdf.AddPage()
+	rg.heading("Endpoint AZ coverage")
+	if len(endpoints) == 0 {
+		rg.paragraph("No interface or Gateway Load Balancer endpoints were found.")
+		return
+	}
+
+	zoneSet := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		for _, az := range append(append([]string{}, endpoint.EndpointAZs...), endpoint.WorkloadAZs...) {
+			zoneSet[az] = true
+		}
+	}
+	zones := make([]string, 0, len(zoneSet))
+	for az := range zoneSet {
+		zones = append(zones, az)
+	}
+	sort.Strings(zones)
+
+	headers := []string{"Endpoint", "Service", "IPs"}
+	widths := []float64{42, 48, 12}
+	for _, az := range zones {
+		headers = append(headers, az)
+		widths = append(widths, 78/float64(len(zones)))
+	}
+	var rows [][]string
+	for _, endpoint := range endpoints {
+		row := []string{endpoint.VpcEndpointID, analysis.EndpointServiceName(endpoint.ServiceName), fmt.Sprint(endpoint.IPv4Addresses)}
+		for _, az := range zones {
+			row = append(row, endpointAZCell(endpoint, az))
+		}
+		rows = append(rows, row)
+	}
+	rg.table(headers, widths, rows)
+}
+
+// endpointAZCell returns the matrix cell of an endpoint in one AZ
+func endpointAZCell(endpoint analysis.EndpointAZCoverage, az string) string {
+	for _, zone := range endpoint.EndpointAZs {
+		if zone == az {
+			return "ENI"
+		}
+	}
+	for _, zone := range endpoint.MissingAZs {
+		if zone == az {
+			return "missing"
+		}
+	}
+	return "-"
+}
+
+// writeChangeSummary renders the resources that changed since the earlier report, removed ones included
+func (rg *ReportGenerator) writeChangeSummary(changes *diff.Changes) {
+	rg.pdf.AddPage()
+	rg.heading("Changes since " + valueOrUnknown(changes.Since))
+	rg.paragraph(fmt.Sprintf("%d added, %d removed and %d modified resources. Changed resources are badged in the VPC sections, with their rule, route and attribute changes below their tables.",
+		changes.Count(diff.ChangeAdded), changes.Count(diff.ChangeRemoved), changes.Count(diff.ChangeModified)))
+	if len(changes.MissingSections) > 0 {
+		rg.paragraph("Not compared, because the earlier report has no section for them: " + strings.Join(changes.MissingSections, ", ") + ".")
+	}
+
+	var rows [][]string
+	for _, change := range changes.Resources {
+		var details []string
+		for _, line := range changeLines(&change) {
+			details = append(details, line.sign+" "+line.text)
+		}
+		rows = append(rows, []string{change.ResourceType, change.ResourceID, change.Name, change.Change, strings.Join(details, "\n")})
+	}
+	rg.table([]string{"Type", "Resource", "Name", "Change", "Details"}, []float64{25, 35, 35, 20, 65}, rows)
+}
+
+// changeLine is one line of a change fragment
+type changeLine struct {
+	sign string // + for added, - for removed, ~ for modified
+	text string
+}
+
+// changeLines lists the rule, route and attribute changes of a modified resource
+func changeLines(change *diff.ResourceChange) []changeLine {
+	var lines []changeLine
+	for _, rule := range change.AddedRules {
+		lines = append(lines, changeLine{"+", ruleSummary(rule)})
+	}
+	for _, rule := range change.RemovedRules {
+		lines = append(lines, changeLine{"-", ruleSummary(rule)})
+	}
+	for _, route := range change.AddedRoutes {
+		lines = append(lines, changeLine{"+", routeSummary(route)})
+	}
+	for _, route := range change.RemovedRoutes {
+		lines = append(lines, changeLine{"-", routeSummary(route)})
+	}
+	if len(change.Fields) > 0 {
+		lines = append(lines, changeLine{"~", "changed: " + strings.Join(change.Fields, ", ")})
+	}
+	return lines
+}
+
+// writeChangeFragment renders the changes of a modified resource below its table
+// Added lines are green, removed lines red and changed attributes amber; the fragment is the same for every
+// resource type, only the lines differ.
+func (rg *ReportGenerator) writeChangeFragment(changes *diff.Changes, resourceID string) {
+	change := changes.Find(resourceID)
+	if change == nil || change.Change != diff.ChangeModified {
+		return
+	}
+	lines := changeLines(change)
+	rg.ensureSpace(float64(len(lines)+1) * lineHeight)
+	rg.pdf.SetFont("Helvetica", "I", 9)
+	rg.pdf.CellFormat(0, lineHeight, rg.tr("Changes since "+valueOrUnknown(changes.Since)+":"), "", 1, "L", false, 0, "")
+	rg.pdf.SetFont("Helvetica", "", 9)
+	for _, line := range lines {
+		switch line.sign {
+		case "+":
+			rg.pdf.SetTextColor(0, 128, 0)
+		case "-":
+			rg.pdf.SetTextColor(192, 0, 0)
+		default:
+			rg.pdf.SetTextColor(176, 112, 0)
+		}
+		rg.pdf.MultiCell(0, lineHeight, rg.tr(line.sign+" "+line.text), "", "L", false)
+	}
+	rg.pdf.SetTextColor(0, 0, 0)
+	rg.pdf.Ln(2)
+}
+
+// changeBadge returns the badge appended to the title or ID of a new or modified resource
+func changeBadge(changes *diff.Changes, resourceID string) string {
+	switch change := changes.Find(resourceID); {
+	case change == nil:
+		return ""
+	case change.Change == diff.ChangeAdded:
+		return " [new]"
+	case change.Change == diff.ChangeModified:
+		return " [changed]"
+	}
+	return ""
+}
+
+// managerBadge returns the badge appended to the title of a resource a tool or controller created
+// Resources created by hand get none, so the badge points out the ones not to edit directly.
+func managerBadge(managedBy string) string {
+	if managedBy == "" || managedBy == analysis.ManagerManual {
+		return ""
+	}
+	return " [" + managedBy + "]"
+}
+
+// ruleSummary describes a security group rule on one line
+func ruleSummary(rule vpc.SecurityGroupRule) string {
+	direction, preposition := "Ingress", "from"
+	if rule.IsEgress {
+		direction, preposition = "Egress", "to"
+	}
+	summary := fmt.Sprintf("%s %s %s %s %s", direction, vpc.OrUnknown(rule.IpProtocol), portRange(rule), preposition, ruleTarget(rule))
+	if rule.Description != "" {
+		summary += " (" + rule.Description + ")"
+	}
+	return summary
+}
+
+// routeSummary describes a route on one line
+func routeSummary(route vpc.RouteInfo) string {
+	dest := route.DestinationCidrBlock
+	if dest == "" {
+		dest = route.DestinationIpv6Block
+	}
+	return fmt.Sprintf("%s -> %s (%s)", withLabel(vpc.OrUnknown(dest), route.DestinationLabel), route.Target.String(), vpc.OrUnknown(route.State))
+}
+
+// writeVPCSection renders the subnets, route tables and security groups of a single VPC
+func (rg *ReportGenerator) writeVPCSection(report *Report, vpcInfo vpc.VPCInfo) {
+	rg.pdf.AddPage()
+	rg.heading(fmt.Sprintf("VPC %s (%s)%s", getResourceName(vpcInfo.Tags, vpcInfo.VpcID), vpcInfo.VpcID, changeBadge(report.Changes, vpcInfo.VpcID)))
+	rg.writeChangeFragment(report.Changes, vpcInfo.VpcID)
+
+	properties := [][]string{
+		{"CIDR block", vpc.OrUnknown(vpcInfo.CidrBlock)},
+		{"Additional CIDR blocks", strings.Join(vpcInfo.AssociateCidrBlocks, ", ")},
+		{"State", vpc.OrUnknown(vpcInfo.State)},
+		{"Default VPC", fmt.Sprint(vpcInfo.IsDefault)},
+		{"Instance tenancy", vpc.OrUnknown(vpcInfo.InstanceTenancy)},
+		{"DHCP options", vpcInfo.DhcpOptionsID},
+		{"Managed by", vpcInfo.ManagedBy},
+	}
+	for _, profile := range report.EgressProfiles {
+		if profile.VpcID == vpcInfo.VpcID {
+			properties = append(properties,
+				[]string{"Egress profile", profile.Summary},
+				[]string{"Egress choke points", strings.Join(profile.ChokePoints, ", ")})
+		}
+	}
+	rg.table([]string{"Property", "Value"}, []float64{50, 130}, properties)
+
+	rg.writeVPCDNS(report, vpcInfo)
+
+	// Subnets
+	var subnetRows [][]string
+	for _, subnet := range report.Subnets {
+		if subnet.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		subnetType := "Private"
+		if subnet.MapPublicIpOnLaunch {
+			subnetType = "Public"
+		}
+		subnetRows = append(subnetRows, []string{vpc.OrUnknown(subnet.SubnetID) + changeBadge(report.Changes, subnet.SubnetID), getResourceName(subnet.Tags, subnet.SubnetID), vpc.OrUnknown(subnet.CidrBlock), vpc.OrUnknown(subnet.AvailabilityZone), subnetType, subnet.ManagedBy})
+	}
+	rg.subheading("Subnets")
+	rg.table([]string{"Subnet ID", "Name", "CIDR", "AZ", "Type", "Managed by"}, []float64{40, 42, 32, 25, 15, 26}, subnetRows)
+	rg.truncationNote(report.Truncations, output.SectionSubnets)
+	rg.writeSubnetNetwork(report.SubnetNetwork, vpcInfo.VpcID)
+
+	// Route tables
+	for _, rt := range report.RouteTables {
+		if rt.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		title := fmt.Sprintf("Route table %s", getResourceName(rt.Tags, rt.RouteTableID))
+		if rt.IsMainRouteTable {
+			title += " (main)"
+		}
+		title += managerBadge(rt.ManagedBy) + changeBadge(report.Changes, rt.RouteTableID)
+		// Local routes are summarized on one line below the table so the other routes stand out
+		var routeRows [][]string
+		var localRoutes []string
+		for _, route := range rt.Routes {
+			dest := route.DestinationCidrBlock
+			if dest == "" {
+				dest = route.DestinationIpv6Block
+			}
+			if route.Target.Type == vpc.RouteTargetLocal {
+				localRoutes = append(localRoutes, fmt.Sprintf("%s (%s)", dest, vpc.LocalRouteLabel(route.RouteType)))
+				continue
+			}
+			routeRows = append(routeRows, []string{withLabel(vpc.OrUnknown(dest), route.DestinationLabel), route.Target.String(), vpc.OrUnknown(route.State), vpc.OrUnknown(route.Origin)})
+		}
+		rg.subheading(title)
+		if len(routeRows) > 0 {
+			rg.table([]string{"Destination", "Target", "State", "Origin"}, []float64{45, 60, 25, 50}, routeRows)
+		}
+		if len(localRoutes) > 0 {
+			rg.paragraph("Local routes: " + strings.Join(localRoutes, ", "))
+		}
+		rg.writeChangeFragment(report.Changes, rt.RouteTableID)
+	}
+	rg.truncationNote(report.Truncations, output.SectionRouteTables)
+
+	// Security groups
+	for _, sg := range report.SecurityGroups {
+		if sg.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		var ruleRows [][]string
+		for _, rule := range sg.Rules {
+			if report.HideDefaultEgress && rule.IsDefaultEgress {
+				continue
+			}
+			direction := "Ingress"
+			if rule.IsEgress {
+				direction = "Egress"
+			}
+			ruleRows = append(ruleRows, []string{direction, vpc.OrUnknown(rule.IpProtocol), portRange(rule), ruleTarget(rule), rule.Description})
+		}
+		title := fmt.Sprintf("Security group %s (%s)", vpc.OrUnknown(sg.GroupName), vpc.OrUnknown(sg.GroupID))
+		if sg.EgressRestricted {
+			// Removing the default egress rule is deliberate hardening, so call it out
+			title += ", egress restricted"
+		}
+		rg.subheading(title + managerBadge(sg.ManagedBy) + changeBadge(report.Changes, sg.GroupID))
+		rg.table([]string{"Direction", "Protocol", "Ports", "Source / destination", "Description"}, []float64{20, 20, 25, 55, 60}, ruleRows)
+		rg.writeEffectiveSources(report.EffectiveSources, sg.GroupID)
+		rg.writeChangeFragment(report.Changes, sg.GroupID)
+	}
+	rg.truncationNote(report.Truncations, output.SectionSecurityGroups)
+}
+
+// writeEffectiveSources renders the expanded ingress sources of a security group below its rules
+// One row per port range and source: the CIDRs allowed directly, then every referenced group
+// with the addresses of its interfaces and the groups upstream of it.
+func (rg *ReportGenerator) writeEffectiveSources(report *analysis.EffectiveSourcesReport, groupID string) {
+	if report == nil {
+		return
+	}
+	for _, group := range report.Groups {
+		if group.GroupID != groupID {
+			continue
+		}
+		var rows [][]string
+		for _, ports := range group.Ports {
+			rule := vpc.SecurityGroupRule{IpProtocol: ports.Protocol, FromPort: ports.FromPort, ToPort: ports.ToPort}
+			var cidrs []string
+			for _, cidr := range ports.CIDRs {
+				cidrs = append(cidrs, withLabel(cidr, ports.CIDRLabels[cidr]))
+			}
+			rows = append(rows, []string{ports.Protocol, portRange(rule), "All sources", strings.Join(cidrs, ", ")})
+			for _, expansion := range ports.ReferencedGroups {
+				rows = append(rows, []string{"", "", "via " + expansion.GroupID, expansionSummary(expansion)})
+				for _, upstream := range expansion.Upstream {
+					rows = append(rows, []string{"", "", "  upstream " + upstream.GroupID, expansionSummary(upstream)})
+				}
+			}
+		}
+		rg.paragraph("Effective sources. " + report.Note)
+		rg.table([]string{"Protocol", "Ports", "Source", "Addresses"}, []float64{20, 25, 45, 90}, rows)
+	}
+}
+
+// expansionSummary describes the addresses a referenced group contributes
+func expansionSummary(expansion analysis.GroupExpansion) string {
+	summary := fmt.Sprintf("%d interface(s): %s", expansion.Interfaces, strings.Join(expansion.CIDRs, ", "))
+	if expansion.SummarizedToSubnets {
+		summary += " (their subnets)"
+	}
+	if expansion.Note != "" {
+		summary = expansion.Note
+	}
+	return summary
+}
+
+// writeVPCDNS renders the effective DNS settings of a VPC and the DNS names of its interface endpoints
+func (rg *ReportGenerator) writeVPCDNS(report *Report, vpcInfo vpc.VPCInfo) {
+	var endpointRows [][]string
+	for _, endpoint := range report.VpcEndpoints {
+		if endpoint.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		for _, entry := range endpoint.DnsEntries {
+			endpointRows = append(endpointRows, []string{endpoint.VpcEndpointID, endpoint.ServiceName, entry.Kind, entry.DnsName})
+		}
+	}
+	if vpcInfo.EffectiveDNS == nil && len(endpointRows) == 0 {
+		return
+	}
+
+	rg.subheading("DNS")
+	if settings := vpcInfo.EffectiveDNS; settings != nil {
+		servers := settings.CustomServers
+		if settings.UsesAmazonDNS {
+			servers = append([]string{vpc.AmazonProvidedDNS}, servers...)
+		}
+		rg.table([]string{"Setting", "Value"}, []float64{50, 130}, [][]string{
+			{"DHCP options", settings.DhcpOptionsID},
+			{"DNS servers", strings.Join(servers, ", ")},
+			{"Amazon resolver", settings.ResolverAddress},
+			{"Domain name", settings.DomainName},
+			{"DNS resolution", fmt.Sprint(settings.DnsSupport)},
+			{"DNS hostnames", fmt.Sprint(settings.DnsHostnames)},
+		})
+	}
+	if len(endpointRows) > 0 {
+		rg.table([]string{"Endpoint", "Service", "Kind", "DNS name"}, []float64{35, 45, 20, 80}, endpointRows)
+	}
+}
+
+// heading renders a section heading
+func (rg *ReportGenerator) heading(text string) {
+	rg.pdf.SetFont("Helvetica", "B", 16)
+	rg.pdf.CellFormat(0, 10, rg.tr(text), "", 1, "L", false, 0, "")
+	rg.pdf.Ln(2)
+}
+
+// subheading renders a subsection heading, moving to a new page if it would be stranded at the bottom
+func (rg *ReportGenerator) subheading(text string) {
+	rg.ensureSpace(8 + 3*lineHeight)
+	rg.pdf.Ln(3)
+	rg.pdf.SetFont("Helvetica", "B", 12)
+	rg.pdf.CellFormat(0, 7, rg.tr(text), "", 1, "L", false, 0, "")
+	rg.pdf.Ln(1)
+}
+
+// paragraph renders a block of body text
+func (rg *ReportGenerator) paragraph(text string) {
+	rg.pdf.SetFont("Helvetica", "", 10)
+	rg.pdf.MultiCell(0, lineHeight, rg.tr(text), "", "L", false)
+}
+
+// truncationNote renders the markers of the given sections that -max-items-per-section shortened
+func (rg *ReportGenerator) truncationNote(truncations output.Truncations, sections ...string) {
+	for _, section := range sections {
+		if marker := truncations.Marker(section); marker != "" {
+			rg.pdf.SetFont("Helvetica", "I", 10)
+			rg.pdf.MultiCell(0, lineHeight, rg.tr("Truncated: "+marker), "", "L", false)
+		}
+	}
+}
+
+// table renders a table, starting a new page with repeated headers whenever a row does not fit
+func (rg *ReportGenerator) table(headers []string, widths []float64, rows [][]string) {
+	if len(rows) == 0 {
+		rg.paragraph("None.")
+		return
+	}
+
+	rg.pdf.SetFont("Helvetica", "B", 9)
+	headerHeight := rg.rowHeight(headers, widths)
+	rg.pdf.SetFont("Helvetica", "", 9)
+	rg.ensureSpace(headerHeight + rg.rowHeight(rows[0], widths))
+	rg.tableHeader(headers, widths)
+
+	for _, row := range rows {
+		rg.pdf.SetFont("Helvetica", "", 9)
+		height := rg.rowHeight(row, widths)
+		if rg.ensureSpace(height) {
+			rg.tableHeader(headers, widths)
+			rg.pdf.SetFont("Helvetica", "", 9)
+		}
+		rg.tableRow(row, widths, height, false)
+	}
+	rg.pdf.Ln(2)
+}
+
+// tableHeader renders the shaded header row of a table
+func (rg *ReportGenerator) tableHeader(headers []string, widths []float64) {
+	rg.pdf.SetFont("Helvetica", "B", 9)
+	rg.pdf.SetFillColor(230, 230, 230)
+	rg.tableRow(headers, widths, rg.rowHeight(headers, widths), true)
+}
+
+// tableRow renders a row of wrapped cells with a common height
+func (rg *ReportGenerator) tableRow(cells []string, widths []float64, height float64, fill bool) {
+	style := "D"
+	if fill {
+		style = "FD"
+	}
+	x, y := rg.pdf.GetX(), rg.pdf.GetY()
+	for i, cell := range cells {
+		rg.pdf.Rect(x, y, widths[i], height, style)
+		for j, line := range rg.pdf.SplitLines([]byte(rg.tr(cell)), widths[i]-2*cellPad) {
+			rg.pdf.SetXY(x+cellPad, y+float64(j)*lineHeight)
+			rg.pdf.CellFormat(widths[i]-2*cellPad, lineHeight, string(line), "", 0, "L", false, 0, "")
+		}
+		x += widths[i]
+	}
+	rg.pdf.SetXY(pageMargin, y+height)
+}
+
+// rowHeight returns the height needed to render a row with the current font
+func (rg *ReportGenerator) rowHeight(cells []string, widths []float64) float64 {
+	maxLines := 1
+	for i, cell := range cells {
+		if lines := len(rg.pdf.SplitLines([]byte(rg.tr(cell)), widths[i]-2*cellPad)); lines > maxLines {
+			maxLines = lines
+		}
+	}
+	return float64(maxLines) * lineHeight
+}
+
+// ensureSpace starts a new page if the given height does not fit above the bottom margin
+// Returns: true if a page break was inserted
+func (rg *ReportGenerator) ensureSpace(height float64) bool {
+	_, pageHeight := rg.pdf.GetPageSize()
+	if rg.pdf.GetY()+height <= pageHeight-pageMargin {
+		return false
+	}
+	rg.pdf.AddPage()
+	return true
+}
+
+// ruleTarget returns the CIDR, group or prefix list a security group rule applies to
+// A CIDR within a named range is followed by the name of the range.
+func ruleTarget(rule vpc.SecurityGroupRule) string {
+	for _, target := range []string{rule.CidrBlock, rule.Ipv6CidrBlock, rule.GroupID, rule.PrefixListID} {
+		if target != "" {
+			return withLabel(target, rule.SourceLabel+rule.DestinationLabel)
+		}
+	}
+	return ""
+}
+
+// withLabel appends the named range of a CIDR block in parentheses, if it has one
+func withLabel(cidr, label string) string {
+	if label == "" {
+		return cidr
+	}
+	return cidr + " (" + label + ")"
+}
+
+// portRange formats the port range of a security group rule
+func portRange(rule vpc.SecurityGroupRule) string {
+	switch {
+	case rule.IpProtocol == "-1":
+		return "All"
+	case rule.FromPort == rule.ToPort:
+		return fmt.Sprint(rule.FromPort)
+	default:
+		return fmt.Sprintf("%d-%d", rule.FromPort, rule.ToPort)
+	}
+}
+
+// accountFromSecurityGroups returns the owner of the first security group, which is the scanned account
+func accountFromSecurityGroups(securityGroups []vpc.SecurityGroupInfo) string {
+	for _, sg := range securityGroups {
+		if sg.OwnerID != "" {
+			return sg.OwnerID
+		}
+	}
+	return ""
+}
+
+// valueOrUnknown returns the value, or "unknown" if it is empty
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+// getResourceName extracts a friendly name from tags, falling back to the resource ID
+func getResourceName(tags map[string]string, resourceID string) string {
+	if name, ok := tags["Name"]; ok && name != "" {
+		return name
+	}
+	return vpc.OrUnknown(resourceID)
+}
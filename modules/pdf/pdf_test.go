@@ -0,0 +1,496 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// streamPattern matches the compressed streams of a document; gofpdf writes one per page, in page order
+var streamPattern = regexp.MustCompile(`(?s)/Filter /FlateDecode /Length \d+>>\nstream\n(.*?)\nendstream`)
+
+// textPattern matches a string shown with Tj, with the escapes gofpdf writes
+var textPattern = regexp.MustCompile(`\(((?:[^()\\]|\\.)*)\) ?Tj`)
+
+// countPattern matches the page count of the page tree
+var countPattern = regexp.MustCompile(`/Type /Pages\n/Kids \[[^\]]*\]\n/Count (\d+)`)
+
+// pageTexts extracts the text of every page of a document, one string per page with a line per text operation
+func pageTexts(t *testing.T, doc []byte) []string {
+	t.Helper()
+	var pages []string
+	for _, match := range streamPattern.FindAllSubmatch(doc, -1) {
+		reader, err := zlib.NewReader(bytes.NewReader(match[1]))
+		if err != nil {
+			t.Fatalf("content stream: %v", err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("content stream: %v", err)
+		}
+		var lines []string
+		for _, text := range textPattern.FindAllSubmatch(content, -1) {
+			lines = append(lines, strings.NewReplacer(`\\`, `\`, `\(`, `(`, `\)`, `)`).Replace(string(text[1])))
+		}
+		pages = append(pages, strings.Join(lines, "\n"))
+	}
+
+	count := countPattern.FindSubmatch(doc)
+	if count == nil {
+		t.Fatal("document has no page tree")
+	}
+	if n, _ := strconv.Atoi(string(count[1])); n != len(pages) {
+		t.Fatalf("page tree counts %d pages, found %d content streams", n, len(pages))
+	}
+	return pages
+}
+
+// fixtureReport returns a report of one VPC whose security group has more rules than fit on a page
+func fixtureReport(rules int) *Report {
+	sg := vpc.SecurityGroupInfo{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", OwnerID: "111122223333"}
+	for i := 0; i < rules; i++ {
+		sg.Rules = append(sg.Rules, vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: int32(8000 + i), ToPort: int32(8000 + i),
+			CidrBlock: fmt.Sprintf("10.%d.0.0/16", i), Description: fmt.Sprintf("rule-%03d", i)})
+	}
+	return &Report{
+		Region:      "eu-west-1",
+		GeneratedAt: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+		VPCs:        []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", State: "available", Tags: map[string]string{"Name": "prod"}}},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1a", MapPublicIpOnLaunch: true},
+			{SubnetID: "subnet-0b2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24", AvailabilityZone: "eu-west-1b"},
+		},
+		RouteTables: []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}, State: "active", Origin: "CreateRoute"},
+		}}},
+		SecurityGroups:   []vpc.SecurityGroupInfo{sg},
+		InternetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1"}},
+		Findings: []Finding{
+			{Severity: "dangling", ResourceID: "sg-0web", Title: "References deleted group sg-0gone", Detail: "referenced group was not found in this account"},
+		},
+	}
+}
+
+// TestGenerateReportPages checks the page count, the page footers and the text of every section of fixture reports
+func TestGenerateReportPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		report    *Report
+		wantPages int
+		wantText  []string // Text that must appear in the document
+	}{
+		{
+			name:      "empty scan",
+			report:    &Report{Region: "eu-west-1", GeneratedAt: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)},
+			wantPages: 2, // Title and findings; no diagram without nodes
+			wantText:  []string{"AWS VPC Infrastructure Report", "Account: unknown", "Region: eu-west-1", "Generated: 2026-10-16T09:30:00Z", "No findings."},
+		},
+		{
+			name:      "one VPC",
+			report:    fixtureReport(10),
+			wantPages: 4, // Title, diagram, findings and the VPC section
+			wantText: []string{"Account: 111122223333", "Overview diagram", "VPC prod 10.0.0.0/16", "References deleted group sg-0gone",
+				"VPC prod (vpc-0a1)", "subnet-0a1", "Route table rtb-0a1 (main)", "0.0.0.0/0", "Security group web (sg-0web)", "rule-009"},
+		},
+		{
+			name:      "long security group",
+			report:    fixtureReport(150),
+			wantPages: 7, // The 150 rules continue over three more pages
+			wantText:  []string{"rule-000", "rule-149"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := NewReportGenerator().GenerateReport(tt.report)
+			if err != nil {
+				t.Fatalf("GenerateReport() = %v", err)
+			}
+			if !bytes.HasPrefix(doc, []byte("%PDF-")) {
+				t.Fatalf("document starts with %q, want a PDF header", doc[:8])
+			}
+			pages := pageTexts(t, doc)
+			if len(pages) != tt.wantPages {
+				t.Errorf("got %d pages, want %d", len(pages), tt.wantPages)
+			}
+			for i, page := range pages {
+				if footer := fmt.Sprintf("Page %d of %d", i+1, len(pages)); !strings.Contains(page, footer) {
+					t.Errorf("page %d has no footer %q", i+1, footer)
+				}
+			}
+			all := strings.Join(pages, "\n")
+			for _, text := range tt.wantText {
+				if !strings.Contains(all, text) {
+					t.Errorf("document text does not contain %q", text)
+				}
+			}
+		})
+	}
+}
+
+// TestLongTableRepeatsHeaders checks that a table spanning pages repeats its header on every page and
+// renders every row exactly once
+func TestLongTableRepeatsHeaders(t *testing.T) {
+	const rules = 150
+	doc, err := NewReportGenerator().GenerateReport(fixtureReport(rules))
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	pages := pageTexts(t, doc)
+
+	tablePages := 0
+	for i, page := range pages {
+		if !strings.Contains(page, "rule-") {
+			continue
+		}
+		tablePages++
+		if !strings.Contains(page, "Direction\nProtocol\nPorts\nSource / destination\nDescription") {
+			t.Errorf("page %d continues the rule table without its header", i+1)
+		}
+	}
+	if tablePages < 2 {
+		t.Fatalf("rule table spans %d pages, want a page break", tablePages)
+	}
+
+	all := strings.Join(pages, "\n")
+	for i := 0; i < rules; i++ {
+		if n := strings.Count(all, fmt.Sprintf("rule-%03d", i)); n != 1 {
+			t.Errorf("rule-%03d appears %d times, want once", i, n)
+		}
+	}
+}
+
+// TestHideDefaultEgress checks that -hide-default-egress leaves only the default egress rules out of the rule
+// tables, and that groups without the default rule are called out as egress restricted
+func TestHideDefaultEgress(t *testing.T) {
+	defaultV4 := vpc.SecurityGroupRule{IsEgress: true, IpProtocol: "-1", CidrBlock: "0.0.0.0/0", Description: "default-v4", IsDefaultEgress: true}
+	defaultV6 := vpc.SecurityGroupRule{IsEgress: true, IpProtocol: "-1", Ipv6CidrBlock: "::/0", Description: "default-v6", IsDefaultEgress: true}
+	report := fixtureReport(0)
+	report.SecurityGroups = []vpc.SecurityGroupInfo{
+		{GroupID: "sg-0default", GroupName: "default", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{defaultV4, defaultV6}},
+		{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0", Description: "https-in"},
+			defaultV4,
+			{IsEgress: true, IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0db", Description: "db-out"},
+		}},
+		{GroupID: "sg-0locked", GroupName: "locked", VpcID: "vpc-0a1", EgressRestricted: true, Rules: []vpc.SecurityGroupRule{
+			{IsEgress: true, IpProtocol: "-1", CidrBlock: "10.0.0.0/16", Description: "vpc-out"},
+		}},
+	}
+
+	tests := []struct {
+		name        string
+		hide        bool
+		wantDefault int // Rows of the default egress rules
+	}{
+		{name: "shown", wantDefault: 3},
+		{name: "hidden", hide: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report.HideDefaultEgress = tt.hide
+			doc, err := NewReportGenerator().GenerateReport(report)
+			if err != nil {
+				t.Fatalf("GenerateReport() = %v", err)
+			}
+			all := strings.Join(pageTexts(t, doc), "\n")
+			if got := strings.Count(all, "default-v4") + strings.Count(all, "default-v6"); got != tt.wantDefault {
+				t.Errorf("document has %d default egress rows, want %d", got, tt.wantDefault)
+			}
+			for _, text := range []string{"Security group default (sg-0default)", "https-in", "db-out", "vpc-out",
+				"Security group locked (sg-0locked), egress restricted"} {
+				if !strings.Contains(all, text) {
+					t.Errorf("document text does not contain %q", text)
+				}
+			}
+			if n := strings.Count(all, "egress restricted"); n != 1 {
+				t.Errorf("%d groups are called egress restricted, want only sg-0locked", n)
+			}
+		})
+	}
+}
+
+// TestPrivateZoneMatrix checks that the zone-to-VPC matrix marks each association and names zones of other accounts
+func TestPrivateZoneMatrix(t *testing.T) {
+	report := fixtureReport(0)
+	report.VPCs = append(report.VPCs, vpc.VPCInfo{VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/16", Tags: map[string]string{"Name": "staging"}})
+	report.PrivateZones = []dns.PrivateZoneInfo{
+		{Name: "corp.internal.", VPCs: []dns.ZoneAssociation{{VpcID: "vpc-0a1"}, {VpcID: "vpc-0b2"}}},
+		{Name: "prod.internal.", VPCs: []dns.ZoneAssociation{{VpcID: "vpc-0a1"}}},
+		{Name: "hub.internal.", CrossAccount: true, OwningAccount: "444455556666", VPCs: []dns.ZoneAssociation{{VpcID: "vpc-0b2"}}},
+	}
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	all := strings.Join(pageTexts(t, doc), "\n")
+	for _, rows := range []string{
+		"Zone\nprod\nstaging",
+		"corp.internal.\nx\nx",
+		"prod.internal.\nx\nhub.internal.", // Empty cells draw no text
+		"hub.internal. (account 444455556666)\nx",
+	} {
+		if !strings.Contains(all, rows) {
+			t.Errorf("zone matrix does not contain %q", rows)
+		}
+	}
+
+	report.PrivateZones = []dns.PrivateZoneInfo{}
+	doc, err = NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	if all := strings.Join(pageTexts(t, doc), "\n"); !strings.Contains(all, "No private hosted zones.") {
+		t.Error("report without zones does not say so")
+	}
+}
+
+// TestLocalRoutesSummary checks that local routes are summarized with their CIDR block below the route table
+func TestLocalRoutesSummary(t *testing.T) {
+	report := fixtureReport(0)
+	local := vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}
+	report.RouteTables[0].Routes = append(report.RouteTables[0].Routes,
+		vpc.RouteInfo{DestinationCidrBlock: "10.0.0.0/16", Target: local, State: "active", RouteType: vpc.RouteTypeLocalPrimaryCIDR},
+		vpc.RouteInfo{DestinationCidrBlock: "100.64.0.0/16", Target: local, State: "active", RouteType: vpc.RouteTypeLocalSecondaryCIDR})
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	all := strings.Join(pageTexts(t, doc), "\n")
+	if want := "Local routes: 10.0.0.0/16 (primary CIDR), 100.64.0.0/16 (secondary CIDR)"; !strings.Contains(all, want) {
+		t.Errorf("document text does not contain %q", want)
+	}
+	if strings.Contains(all, "100.64.0.0/16\nlocal") {
+		t.Error("local route is also listed in the route table")
+	}
+}
+
+// TestThirdPartyVendors checks the vendor table and the note shown when no endpoint reaches a third party
+func TestThirdPartyVendors(t *testing.T) {
+	report := fixtureReport(0)
+	report.ThirdPartyVendors = []analysis.VendorConnectivity{
+		{Vendor: "Datadog", Classification: analysis.ConnectivityKnownSaaS, EndpointIDs: []string{"vpce-0dd1", "vpce-0dd2"},
+			VpcIDs: []string{"vpc-0a1", "vpc-0b2"}, SecurityGroupIDs: []string{"sg-0dd"}},
+		{Vendor: "account 999988887777", Classification: analysis.ConnectivityUnknownThirdParty, EndpointIDs: []string{"vpce-0mystery"},
+			VpcIDs: []string{"vpc-0a1"}, SecurityGroupIDs: []string{}},
+	}
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	all := strings.Join(pageTexts(t, doc), "\n")
+	for _, rows := range []string{
+		"Vendor\nClass\nVPCs\nEndpoints\nSecurity groups",
+		"Datadog\nknown-saas\nvpc-0a1, vpc-0b2\nvpce-0dd1, vpce-0dd2\nsg-0dd",
+		"account\n999988887777\nunknown-third-party\nvpc-0a1\nvpce-0mystery", // Long cells wrap at spaces,
+	} {
+		if !strings.Contains(all, rows) {
+			t.Errorf("vendor table does not contain %q", rows)
+		}
+	}
+
+	report.ThirdPartyVendors = []analysis.VendorConnectivity{}
+	doc, err = NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	if all := strings.Join(pageTexts(t, doc), "\n"); !strings.Contains(all, "No endpoints connect to SaaS or other third-party services.") {
+		t.Error("report without third parties does not say so")
+	}
+}
+
+// TestPublicIPTable checks the public IP rows, with the DNS names after the address, and an Elastic IP attached
+// to nothing
+func TestPublicIPTable(t *testing.T) {
+	report := fixtureReport(0)
+	report.PublicIPs = []analysis.PublicIPEntry{
+		{PublicIp: "203.0.113.5", Kind: "ephemeral", AttachedResourceType: "instance", AttachedResourceID: "i-0bastion", VpcID: "vpc-0a1",
+			Exposure: "open to the internet: tcp 22", DNSRecords: []string{"bastion.example.com (A)"}, ReverseDNS: []string{"bastion.example.com"}},
+		{PublicIp: "198.51.100.30", Kind: "static", AttachedResourceType: "none", Exposure: "not attached"},
+	}
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	all := strings.Join(pageTexts(t, doc), "\n")
+	for _, rows := range []string{
+		"Address\nKind\nAttached to\nVPC\nExposure",
+		"203.0.113.5\n(bastion", // Names wrap within the address column
+		"ephemeral\ninstance i-0bastion\nvpc-0a1\nopen to the internet: tcp 22",
+		"198.51.100.30\nstatic\nnone\nnot attached", // No trailing space for a missing resource ID
+	} {
+		if !strings.Contains(all, rows) {
+			t.Errorf("public IP table does not contain %q", rows)
+		}
+	}
+}
+
+// TestEndpointAZMatrix checks the endpoint x AZ matrix with a covered, a missing and an unused AZ, and that
+// services show by their short name
+func TestEndpointAZMatrix(t *testing.T) {
+	report := fixtureReport(0)
+	workload := []string{"eu-west-1a", "eu-west-1b"}
+	report.EndpointAZs = []analysis.EndpointAZCoverage{
+		{VpcEndpointID: "vpce-0full", ServiceName: "com.amazonaws.eu-west-1.ssm", EndpointAZs: workload, WorkloadAZs: workload,
+			MissingAZs: []string{}, IPv4Addresses: 2},
+		{VpcEndpointID: "vpce-0partial", ServiceName: "com.amazonaws.eu-west-1.logs", EndpointAZs: []string{"eu-west-1a"}, WorkloadAZs: workload,
+			MissingAZs: []string{"eu-west-1b"}, IPv4Addresses: 1},
+		{VpcEndpointID: "vpce-0edge", ServiceName: "com.amazonaws.eu-west-1.sts", EndpointAZs: []string{"eu-west-1c"}, WorkloadAZs: []string{},
+			MissingAZs: []string{}, IPv4Addresses: 1},
+	}
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	all := strings.Join(pageTexts(t, doc), "\n")
+	for _, rows := range []string{
+		"Endpoint\nService\nIPs\neu-west-1a\neu-west-1b\neu-west-1c",
+		"vpce-0full\nssm\n2\nENI\nENI\n-",
+		"vpce-0partial\nlogs\n1\nENI\nmissing\n-",
+		"vpce-0edge\nsts\n1\n-\n-\nENI",
+	} {
+		if !strings.Contains(all, rows) {
+			t.Errorf("endpoint matrix does not contain %q", rows)
+		}
+	}
+
+	report.EndpointAZs = []analysis.EndpointAZCoverage{}
+	doc, err = NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	if all := strings.Join(pageTexts(t, doc), "\n"); !strings.Contains(all, "No interface or Gateway Load Balancer endpoints were found.") {
+		t.Error("report without endpoints does not say so")
+	}
+}
+
+// TestChangesGolden renders the change summary, badges and change fragments of added, removed and modified
+// VPCs, subnets, route tables, security groups and NAT gateways, and compares the text of every page with
+// testdata/changes.txt
+// Run go test -run ChangesGolden -update after a deliberate change and review the golden diff.
+func TestChangesGolden(t *testing.T) {
+	report := fixtureReport(2)
+	modified := func(resourceType, resourceID, name string, fields ...string) diff.ResourceChange {
+		return diff.ResourceChange{ResourceType: resourceType, ResourceID: resourceID, Name: name, Change: diff.ChangeModified, Fields: fields}
+	}
+	sg := modified(diff.TypeSecurityGroup, "sg-0web", "web")
+	sg.AddedRules = []vpc.SecurityGroupRule{report.SecurityGroups[0].Rules[1]}
+	sg.RemovedRules = []vpc.SecurityGroupRule{{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "0.0.0.0/0", Description: "ssh"}}
+	rt := modified(diff.TypeRouteTable, "rtb-0a1", "rtb-0a1")
+	rt.AddedRoutes = report.RouteTables[0].Routes
+	rt.RemovedRoutes = []vpc.RouteInfo{{DestinationCidrBlock: "0.0.0.0/0", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0old"}}}
+	report.Changes = &diff.Changes{
+		Since: "2026-07-01T09:00:00Z",
+		Resources: []diff.ResourceChange{
+			modified(diff.TypeVPC, "vpc-0a1", "prod", "tags"),
+			{ResourceType: diff.TypeSubnet, ResourceID: "subnet-0b2", Name: "subnet-0b2", Change: diff.ChangeAdded},
+			{ResourceType: diff.TypeSubnet, ResourceID: "subnet-0old", Name: "legacy-a", Change: diff.ChangeRemoved},
+			rt,
+			sg,
+			{ResourceType: diff.TypeNatGateway, ResourceID: "nat-0old", Name: "egress", Change: diff.ChangeRemoved},
+		},
+		MissingSections: []string{diff.TypeTransitGateway},
+	}
+
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	got := strings.Join(pageTexts(t, doc), "\n") + "\n"
+
+	golden := filepath.Join("testdata", "changes.txt")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("page text differs from %s (run go test -update if the change is intended):\n%s", golden, got)
+	}
+}
+
+// TestDataWarningPlaceholders checks that fields the API left empty are shown as (unknown) and that the
+// warnings are listed on their own page and counted in the summary
+func TestDataWarningPlaceholders(t *testing.T) {
+	report := fixtureReport(1)
+	report.VPCs[0].CidrBlock, report.VPCs[0].State = "", ""
+	report.RouteTables[0].Routes = append(report.RouteTables[0].Routes,
+		vpc.RouteInfo{Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}, Origin: "CreateRoute"})
+	report.SecurityGroups[0].GroupName = ""
+	report.SecurityGroups[0].Rules[0].IpProtocol = ""
+	report.DataWarnings = []vpc.DataWarning{
+		{ResourceType: "VPC", ResourceID: "vpc-0a1", Field: "CidrBlock", Problem: vpc.ProblemMissing},
+		{ResourceType: "route", ResourceID: "rtb-0a1 unknown destination", Field: "State", RawValue: "flapping", Problem: vpc.ProblemUnrecognized},
+	}
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	all := strings.Join(pageTexts(t, doc), "\n")
+	for _, want := range []string{
+		"CIDR block\n(unknown)",
+		"State\n(unknown)",
+		"(unknown)\nigw-0a1\n(unknown)\nCreateRoute", // Route without destination or state
+		"Security group (unknown) (sg-0web)",
+		"Ingress\n(unknown)\n8000",
+		"Data-quality warnings\n2",
+		"VPC\nvpc-0a1\nCidrBlock\nmissing",
+		"route\nrtb-0a1 unknown destination\nState\nunrecognized\nflapping",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("document text does not contain %q", want)
+		}
+	}
+}
+
+// TestTruncatedSections checks that the summary counts the items truncated sections leave out, and that the
+// title page and each cut section carry the truncation markers
+func TestTruncatedSections(t *testing.T) {
+	report := fixtureReport(1)
+	report.Truncations = output.Truncations{
+		output.SectionVPCs:     {Section: output.SectionVPCs, Shown: 1, Total: 3},
+		output.SectionSubnets:  {Section: output.SectionSubnets, Shown: 2, Total: 38112},
+		output.SectionFindings: {Section: output.SectionFindings, Shown: 1, Total: 12},
+	}
+	doc, err := NewReportGenerator().GenerateReport(report)
+	if err != nil {
+		t.Fatalf("GenerateReport() = %v", err)
+	}
+	all := strings.Join(pageTexts(t, doc), "\n")
+	for _, want := range []string{
+		"VPCs\n3",
+		"Subnets\n38112",
+		"Route tables\n1",
+		"Findings\n12",
+		"Truncated sections\n- showing 1 of 12 findings, full data in the JSON output\n- showing 2 of 38,112 subnets, full data in the JSON output\n- showing 1 of 3 VPCs, full data in the JSON output",
+		"Overview diagram\nTruncated: showing 1 of 3 VPCs, full data in the JSON output\nTruncated: showing 2 of 38,112 subnets, full data in the JSON output",
+		"Truncated: showing 1 of 12 findings, full data in the JSON output",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("document text does not contain %q", want)
+		}
+	}
+	if n := strings.Count(all, "Truncated: showing 2 of 38,112 subnets"); n != 2 {
+		t.Errorf("subnet marker appears %d times, want it on the diagram and the subnet table of the VPC", n)
+	}
+}
@@ -0,0 +1,369 @@
+// Package plantuml provides functionality for generating PlantUML deployment diagrams from AWS VPC infrastructure data
+package plantuml
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/naming"
+	"aws-documentor/modules/vpc"
+)
+
+// DefaultNodeThreshold is the number of nodes above which subnets are summarized per availability zone
+const DefaultNodeThreshold = 250
+
+// PlantUMLGenerator generates PlantUML deployment diagrams from VPC data
+type PlantUMLGenerator struct {
+	Plain         bool     // Use plain frames and nodes instead of the AWS icon library
+	NodeThreshold int      // Summarize subnets per AZ when the diagram would exceed this many nodes (0 disables)
+	Notes         []string // Lines of a legend at the top of the diagram, such as truncation markers
+
+	aliases *naming.Namer // Aliases already emitted, used to keep identifiers unique
+}
+
+// NewPlantUMLGenerator creates a new PlantUML generator
+// plain: When true, emit plain frames and nodes that render without the AWS icon library
+func NewPlantUMLGenerator(plain bool) *PlantUMLGenerator {
+	return &PlantUMLGenerator{
+		Plain:         plain,
+		NodeThreshold: DefaultNodeThreshold,
+	}
+}
+
+// GenerateVPCDiagram creates a deployment diagram with VPC and AZ frames, subnet and gateway nodes,
+// and arrows for route-derived connectivity and transit gateway attachments
+func (pg *PlantUMLGenerator) GenerateVPCDiagram(
+	vpcs []vpc.VPCInfo,
+	subnets []vpc.SubnetInfo,
+	routeTables []vpc.RouteTableInfo,
+	securityGroups []vpc.SecurityGroupInfo,
+	internetGateways []vpc.InternetGatewayInfo,
+	natGateways []vpc.NatGatewayInfo,
+	transitGateways []vpc.TransitGatewayInfo,
+	tgwAttachments []vpc.TransitGatewayAttachmentInfo,
+) (string, error) {
+	pg.aliases = naming.NewNamer(naming.StyleDSL)
+
+	// Decide up front whether individual subnets fit under the node threshold
+	totalNodes := len(vpcs) + len(subnets) + len(internetGateways) + len(natGateways) + len(transitGateways)
+	summarize := pg.NodeThreshold > 0 && totalNodes > pg.NodeThreshold
+
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	pg.writeHeader(&b)
+
+	// Resource ID -> alias, used when drawing arrows
+	aliasFor := make(map[string]string)
+
+	for _, v := range vpcs {
+		pg.writeVPC(&b, v, subnets, internetGateways, natGateways, summarize, aliasFor)
+	}
+
+	// Transit gateways live at the region level, outside any VPC
+	for _, tgw := range transitGateways {
+		alias := pg.alias(tgw.TransitGatewayID)
+		aliasFor[tgw.TransitGatewayID] = alias
+		label := fmt.Sprintf("%s\\nASN %d", getResourceName(tgw.Tags, tgw.TransitGatewayID), tgw.AmazonSideAsn)
+		pg.writeNode(&b, "", "TransitGateway", alias, label, "transit-gateway")
+	}
+
+	b.WriteString("\n")
+	for _, edge := range routeEdges(subnets, routeTables, aliasFor, summarize) {
+		fmt.Fprintf(&b, "%s --> %s\n", edge[0], edge[1])
+	}
+	for _, edge := range attachmentEdges(tgwAttachments, aliasFor) {
+		fmt.Fprintf(&b, "%s ..> %s : %s\n", edge[0], edge[1], edge[2])
+	}
+
+	if len(pg.Notes) > 0 {
+		b.WriteString("\nlegend top left\n")
+		for _, note := range pg.Notes {
+			b.WriteString(note + "\n")
+		}
+		b.WriteString("endlegend\n")
+	}
+
+	b.WriteString("@enduml\n")
+
+	doc := b.String()
+	if err := Validate(doc); err != nil {
+		return "", fmt.Errorf("generated invalid PlantUML: %w", err)
+	}
+	return doc, nil
+}
+
+// writeHeader emits the include directives or plain skin parameters
+func (pg *PlantUMLGenerator) writeHeader(b *strings.Builder) {
+	if pg.Plain {
+		b.WriteString("skinparam shadowing false\n")
+		b.WriteString("skinparam frame {\n  BorderColor #8C4FFF\n}\n")
+		b.WriteString("skinparam node {\n  BackgroundColor #FFFFFF\n  BorderColor #232F3E\n}\n\n")
+		return
+	}
+	b.WriteString("!include <awslib/AWSCommon>\n")
+	b.WriteString("!include <awslib/Groups/all>\n")
+	b.WriteString("!include <awslib/NetworkingContentDelivery/VPCInternetGateway>\n")
+	b.WriteString("!include <awslib/NetworkingContentDelivery/VPCNATGateway>\n")
+	b.WriteString("!include <awslib/NetworkingContentDelivery/TransitGateway>\n\n")
+}
+
+// writeVPC emits a VPC frame containing its gateways and AZ frames with subnets
+func (pg *PlantUMLGenerator) writeVPC(
+	b *strings.Builder,
+	vpcInfo vpc.VPCInfo,
+	allSubnets []vpc.SubnetInfo,
+	allIGWs []vpc.InternetGatewayInfo,
+	allNGWs []vpc.NatGatewayInfo,
+	summarize bool,
+	aliasFor map[string]string,
+) {
+	vpcAlias := pg.alias(vpcInfo.VpcID)
+	aliasFor[vpcInfo.VpcID] = vpcAlias
+
+	vpcLabel := fmt.Sprintf("%s\\n%s", getResourceName(vpcInfo.Tags, vpcInfo.VpcID), vpc.OrUnknown(vpcInfo.CidrBlock))
+	pg.openGroup(b, "", "VPCGroup", vpcAlias, vpcLabel, vpcInfo.CidrBlock)
+
+	// Internet gateways attached to this VPC
+	for _, igw := range allIGWs {
+		if igw.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		alias := pg.alias(igw.InternetGatewayID)
+		aliasFor[igw.InternetGatewayID] = alias
+		pg.writeNode(b, "  ", "VPCInternetGateway", alias, getResourceName(igw.Tags, igw.InternetGatewayID), "internet-gateway")
+	}
+
+	// Group subnets by availability zone, sorted for deterministic output
+	subnetsByAZ := make(map[string][]vpc.SubnetInfo)
+	var azs []string
+	for _, subnet := range allSubnets {
+		if subnet.VpcID != vpcInfo.VpcID {
+			continue
+		}
+		if _, ok := subnetsByAZ[subnet.AvailabilityZone]; !ok {
+			azs = append(azs, subnet.AvailabilityZone)
+		}
+		subnetsByAZ[subnet.AvailabilityZone] = append(subnetsByAZ[subnet.AvailabilityZone], subnet)
+	}
+	sort.Strings(azs)
+
+	for _, az := range azs {
+		azAlias := pg.alias(vpcInfo.VpcID + "_" + az)
+		pg.openGroup(b, "  ", "AvailabilityZoneGroup", azAlias, az, "")
+
+		azSubnets := subnetsByAZ[az]
+		sort.Slice(azSubnets, func(i, j int) bool { return azSubnets[i].SubnetID < azSubnets[j].SubnetID })
+
+		if summarize {
+			// One node per AZ keeps very large environments readable
+			public := 0
+			for _, subnet := range azSubnets {
+				if subnet.MapPublicIpOnLaunch {
+					public++
+				}
+			}
+			summaryAlias := pg.alias(azAlias + "_subnets")
+			for _, subnet := range azSubnets {
+				aliasFor[subnet.SubnetID] = summaryAlias
+			}
+			label := fmt.Sprintf("%d subnets\\n%d public, %d private", len(azSubnets), public, len(azSubnets)-public)
+			pg.writeNode(b, "    ", "", summaryAlias, label, "subnets")
+		} else {
+			for _, subnet := range azSubnets {
+				alias := pg.alias(subnet.SubnetID)
+				aliasFor[subnet.SubnetID] = alias
+				tier := "private"
+				if subnet.MapPublicIpOnLaunch {
+					tier = "public"
+				}
+				label := fmt.Sprintf("%s\\n(%s)", getResourceName(subnet.Tags, subnet.SubnetID), tier)
+				pg.writeNode(b, "    ", "", alias, label, subnet.CidrBlock)
+			}
+		}
+
+		// NAT gateways sit in the AZ of the subnet that hosts them, even when subnets are summarized
+		for _, subnet := range azSubnets {
+			for _, ngw := range allNGWs {
+				if ngw.SubnetID != subnet.SubnetID {
+					continue
+				}
+				ngwAlias := pg.alias(ngw.NatGatewayID)
+				aliasFor[ngw.NatGatewayID] = ngwAlias
+				pg.writeNode(b, "    ", "VPCNATGateway", ngwAlias, getResourceName(ngw.Tags, ngw.NatGatewayID), "nat-gateway")
+			}
+		}
+
+		pg.closeGroup(b, "  ")
+	}
+
+	pg.closeGroup(b, "")
+	b.WriteString("\n")
+}
+
+// openGroup starts a frame (plain) or AWS group macro containing nested elements
+func (pg *PlantUMLGenerator) openGroup(b *strings.Builder, indent, macro, alias, label, stereotype string) {
+	if pg.Plain || macro == "" {
+		if stereotype != "" {
+			fmt.Fprintf(b, "%sframe \"%s\" as %s <<%s>> {\n", indent, escapeLabel(label), alias, stereotype)
+		} else {
+			fmt.Fprintf(b, "%sframe \"%s\" as %s {\n", indent, escapeLabel(label), alias)
+		}
+		return
+	}
+	fmt.Fprintf(b, "%s%s(%s, \"%s\") {\n", indent, macro, alias, escapeLabel(label))
+}
+
+// closeGroup ends a frame or group
+func (pg *PlantUMLGenerator) closeGroup(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// writeNode emits a node (plain) or AWS icon macro; stereotype carries CIDRs or the resource kind
+func (pg *PlantUMLGenerator) writeNode(b *strings.Builder, indent, macro, alias, label, stereotype string) {
+	if pg.Plain || macro == "" {
+		fmt.Fprintf(b, "%snode \"%s\" as %s <<%s>>\n", indent, escapeLabel(label), alias, stereotype)
+		return
+	}
+	fmt.Fprintf(b, "%s%s(%s, \"%s\", \"%s\")\n", indent, macro, alias, escapeLabel(label), stereotype)
+}
+
+// alias derives a unique, deterministic PlantUML identifier from a resource ID
+func (pg *PlantUMLGenerator) alias(resourceID string) string {
+	return pg.aliases.Name(resourceID, "")
+}
+
+// routeEdges derives subnet -> gateway arrows from the route table associated with each subnet
+// Returns: Sorted, de-duplicated [from, to] alias pairs
+func routeEdges(subnets []vpc.SubnetInfo, routeTables []vpc.RouteTableInfo, aliasFor map[string]string, summarize bool) [][2]string {
+	// Index explicit associations and main route tables
+	explicit := make(map[string]vpc.RouteTableInfo)
+	mainTables := make(map[string]vpc.RouteTableInfo)
+	for _, rt := range routeTables {
+		for _, subnetID := range rt.SubnetIDs {
+			explicit[subnetID] = rt
+		}
+		if rt.IsMainRouteTable {
+			mainTables[rt.VpcID] = rt
+		}
+	}
+
+	seen := make(map[[2]string]bool)
+	var edges [][2]string
+	for _, subnet := range subnets {
+		from, ok := aliasFor[subnet.SubnetID]
+		if !ok {
+			continue
+		}
+		rt, ok := explicit[subnet.SubnetID]
+		if !ok {
+			if rt, ok = mainTables[subnet.VpcID]; !ok {
+				continue
+			}
+		}
+		for _, route := range rt.Routes {
+			to, ok := aliasFor[route.Target.ID]
+			if !ok || route.Target.ID == "" {
+				continue
+			}
+			edge := [2]string{from, to}
+			if !seen[edge] {
+				seen[edge] = true
+				edges = append(edges, edge)
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// attachmentEdges derives transit gateway -> VPC arrows from VPC attachments
+// Returns: Sorted [from, to, label] triples for attachments whose both ends are in the diagram
+func attachmentEdges(attachments []vpc.TransitGatewayAttachmentInfo, aliasFor map[string]string) [][3]string {
+	var edges [][3]string
+	for _, attachment := range attachments {
+		from, okFrom := aliasFor[attachment.TransitGatewayID]
+		to, okTo := aliasFor[attachment.ResourceID]
+		if !okFrom || !okTo {
+			continue
+		}
+		edges = append(edges, [3]string{from, to, attachment.State})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+// escapeLabel makes a value safe to use inside a double-quoted PlantUML label
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, "\"", "'")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return s
+}
+
+// getResourceName extracts a friendly name from tags, falling back to the resource ID
+func getResourceName(tags map[string]string, resourceID string) string {
+	if name, ok := tags["Name"]; ok && name != "" {
+		return name
+	}
+	return resourceID
+}
+
+// declarationPattern captures the alias of frame/node declarations and AWS macros
+var declarationPattern = regexp.MustCompile(`^\s*(?:(?:frame|node)\s+"[^"]*"\s+as\s+(\w+)|\w+\((\w+),)`)
+
+// Validate performs a syntactic sanity check on a generated PlantUML document
+// doc: PlantUML source to check
+// Returns: Error describing the first problem found (unbalanced markers or braces, duplicate aliases)
+func Validate(doc string) error {
+	lines := strings.Split(strings.TrimSpace(doc), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "@startuml" {
+		return fmt.Errorf("document must start with @startuml")
+	}
+	if strings.TrimSpace(lines[len(lines)-1]) != "@enduml" {
+		return fmt.Errorf("document must end with @enduml")
+	}
+
+	depth := 0
+	aliases := make(map[string]bool)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if i > 0 && (trimmed == "@startuml" || trimmed == "@enduml") && i != len(lines)-1 {
+			return fmt.Errorf("line %d: unexpected %s", i+1, trimmed)
+		}
+
+		if m := declarationPattern.FindStringSubmatch(line); m != nil {
+			alias := m[1] + m[2]
+			if aliases[alias] {
+				return fmt.Errorf("line %d: duplicate alias %q", i+1, alias)
+			}
+			aliases[alias] = true
+		}
+
+		// Braces only open/close groups in the generated output; skin parameters use them too
+		if strings.HasSuffix(trimmed, "{") {
+			depth++
+		}
+		if trimmed == "}" {
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("line %d: unbalanced closing brace", i+1)
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("%d unclosed group(s)", depth)
+	}
+	return nil
+}
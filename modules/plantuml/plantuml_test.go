@@ -0,0 +1,197 @@
+package plantuml
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/testgen"
+	"aws-documentor/modules/vpc"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fixture is a small environment: two VPCs, one with public and private subnets in two AZs, a NAT gateway,
+// an internet gateway and a transit gateway attachment, and a Name tag that needs escaping
+type fixture struct {
+	vpcs             []vpc.VPCInfo
+	subnets          []vpc.SubnetInfo
+	routeTables      []vpc.RouteTableInfo
+	internetGateways []vpc.InternetGatewayInfo
+	natGateways      []vpc.NatGatewayInfo
+	transitGateways  []vpc.TransitGatewayInfo
+	tgwAttachments   []vpc.TransitGatewayAttachmentInfo
+}
+
+func newFixture() fixture {
+	return fixture{
+		vpcs: []vpc.VPCInfo{
+			{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", Tags: map[string]string{"Name": "prod"}},
+			{VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/16", Tags: map[string]string{"Name": `shared "services"`}},
+		},
+		subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/24", AvailabilityZone: "eu-west-1a", MapPublicIpOnLaunch: true, Tags: map[string]string{"Name": "public-a"}},
+			{SubnetID: "subnet-0a2", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1b", MapPublicIpOnLaunch: true, Tags: map[string]string{"Name": "public-b"}},
+			{SubnetID: "subnet-0a3", VpcID: "vpc-0a1", CidrBlock: "10.0.10.0/24", AvailabilityZone: "eu-west-1a", Tags: map[string]string{"Name": "private-a"}},
+			{SubnetID: "subnet-0a4", VpcID: "vpc-0a1", CidrBlock: "10.0.11.0/24", AvailabilityZone: "eu-west-1b"},
+			{SubnetID: "subnet-0b1", VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/24", AvailabilityZone: "eu-west-1a"},
+		},
+		routeTables: []vpc.RouteTableInfo{
+			{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a1", "subnet-0a2"}, Routes: []vpc.RouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}},
+			}},
+			{RouteTableID: "rtb-0a2", VpcID: "vpc-0a1", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+				{DestinationCidrBlock: "0.0.0.0/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: "nat-0a1"}},
+				{DestinationCidrBlock: "10.1.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: "tgw-0c1"}},
+			}},
+			{RouteTableID: "rtb-0b1", VpcID: "vpc-0b2", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+				{DestinationCidrBlock: "10.0.0.0/16", Target: vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: "tgw-0c1"}},
+			}},
+		},
+		internetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1"}},
+		natGateways:      []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", VpcID: "vpc-0a1", SubnetID: "subnet-0a1"}},
+		transitGateways:  []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0c1", AmazonSideAsn: 64512, Tags: map[string]string{"Name": "core"}}},
+		tgwAttachments: []vpc.TransitGatewayAttachmentInfo{
+			{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0c1", ResourceID: "vpc-0a1", State: "available"},
+			{AttachmentID: "tgw-attach-0b2", TransitGatewayID: "tgw-0c1", ResourceID: "vpc-0b2", State: "pending"},
+		},
+	}
+}
+
+// generate runs the generator on the fixture
+func (f fixture) generate(t *testing.T, pg *PlantUMLGenerator) string {
+	t.Helper()
+	doc, err := pg.GenerateVPCDiagram(f.vpcs, f.subnets, f.routeTables, nil, f.internetGateways, f.natGateways, f.transitGateways, f.tgwAttachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// aliasPattern captures the alias of every declaration, whichever form it takes
+var aliasPattern = regexp.MustCompile(`(?m)^\s*(?:(?:frame|node)\s+"[^"]*"\s+as\s+(\w+)|\w+\((\w+),)`)
+
+// checkStructure checks, independently of Validate, that the document has one @startuml at the top,
+// one @enduml at the bottom, balanced groups and unique aliases
+func checkStructure(t *testing.T, doc string) {
+	t.Helper()
+	if strings.Count(doc, "@startuml") != 1 || !strings.HasPrefix(doc, "@startuml\n") {
+		t.Errorf("the document must open with its only @startuml")
+	}
+	if strings.Count(doc, "@enduml") != 1 || !strings.HasSuffix(doc, "@enduml\n") {
+		t.Errorf("the document must close with its only @enduml")
+	}
+	if open, closed := strings.Count(doc, "{\n"), strings.Count(doc, "}\n"); open != closed {
+		t.Errorf("%d groups opened, %d closed", open, closed)
+	}
+	seen := make(map[string]bool)
+	for _, m := range aliasPattern.FindAllStringSubmatch(doc, -1) {
+		alias := m[1] + m[2]
+		if seen[alias] {
+			t.Errorf("alias %q is declared twice", alias)
+		}
+		seen[alias] = true
+	}
+	if len(seen) == 0 {
+		t.Errorf("the document declares no aliases")
+	}
+}
+
+func TestGenerateVPCDiagramGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(pg *PlantUMLGenerator)
+	}{
+		{name: "aws"},
+		{name: "plain", setup: func(pg *PlantUMLGenerator) { pg.Plain = true }},
+		{name: "summarized", setup: func(pg *PlantUMLGenerator) { pg.Plain, pg.NodeThreshold = true, 5 }},
+		{name: "notes", setup: func(pg *PlantUMLGenerator) {
+			pg.Plain = true
+			pg.Notes = []string{"Truncated: 2 of 40 VPCs shown"}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := NewPlantUMLGenerator(false)
+			if tt.setup != nil {
+				tt.setup(pg)
+			}
+			doc := newFixture().generate(t, pg)
+			checkStructure(t, doc)
+
+			golden := filepath.Join("testdata", tt.name+".puml")
+			if *update {
+				if err := os.WriteFile(golden, []byte(doc), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if doc != string(want) {
+				t.Errorf("output differs from %s (run go test -update if the change is intended):\n%s", golden, doc)
+			}
+		})
+	}
+}
+
+// TestGenerateVPCDiagramDeterministic checks that a second generation gives the same document
+// The generator keeps its aliases between calls, so a reused generator must start over each time.
+func TestGenerateVPCDiagramDeterministic(t *testing.T) {
+	pg := NewPlantUMLGenerator(true)
+	f := newFixture()
+	if first, second := f.generate(t, pg), f.generate(t, pg); first != second {
+		t.Errorf("a second generation differs from the first")
+	}
+}
+
+// TestGenerateVPCDiagramLarge checks the structure and alias uniqueness of a generated environment in both modes
+func TestGenerateVPCDiagramLarge(t *testing.T) {
+	env := testgen.Generate(testgen.Medium)
+	for _, pg := range []*PlantUMLGenerator{NewPlantUMLGenerator(false), {Plain: true}} {
+		doc, err := pg.GenerateVPCDiagram(env.VPCs, env.Subnets, env.RouteTables, env.SecurityGroups,
+			env.InternetGateways, env.NatGateways, env.TransitGateways, env.TGWAttachments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkStructure(t, doc)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string // Part of the expected error ("" for a valid document)
+	}{
+		{name: "valid", doc: "@startuml\nframe \"a\" as a {\n  node \"b\" as b <<x>>\n}\n@enduml\n"},
+		{name: "missing @startuml", doc: "node \"b\" as b <<x>>\n@enduml\n", err: "must start with @startuml"},
+		{name: "missing @enduml", doc: "@startuml\nnode \"b\" as b <<x>>\n", err: "must end with @enduml"},
+		{name: "nested @startuml", doc: "@startuml\n@startuml\n@enduml\n", err: "unexpected @startuml"},
+		{name: "early @enduml", doc: "@startuml\n@enduml\nnode \"b\" as b <<x>>\n@enduml\n", err: "unexpected @enduml"},
+		{name: "unclosed group", doc: "@startuml\nframe \"a\" as a {\n@enduml\n", err: "1 unclosed group"},
+		{name: "extra closing brace", doc: "@startuml\n}\n@enduml\n", err: "unbalanced closing brace"},
+		{name: "duplicate node alias", doc: "@startuml\nnode \"b\" as b <<x>>\nnode \"c\" as b <<x>>\n@enduml\n", err: `duplicate alias "b"`},
+		{name: "duplicate macro alias", doc: "@startuml\nVPCGroup(v, \"a\") {\n}\nTransitGateway(v, \"t\", \"x\")\n@enduml\n", err: `duplicate alias "v"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.doc)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+// Package privateapi provides functionality for scanning private API Gateway REST APIs and the VPC endpoints
+// their resource policies admit
+package privateapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway/types"
+
+	"aws-documentor/modules/arnbuild"
+	"aws-documentor/modules/vpc"
+)
+
+// Condition keys of a resource policy that name the VPC endpoints and VPCs a request may come from
+const (
+	KeySourceVpce = "aws:SourceVpce" // ID of the VPC endpoint the request came through
+	KeySourceVpc  = "aws:SourceVpc"  // ID of the VPC of that endpoint
+)
+
+// PrivateAPIInfo contains a private REST API and the VPC endpoints bound to it or named in its resource policy
+type PrivateAPIInfo struct {
+	RestApiID            string            `json:"rest_api_id"`             // Unique identifier for the API
+	Arn                  string            `json:"arn"`                     // ARN of the API
+	Name                 string            `json:"name"`                    // Name of the API
+	Description          string            `json:"description"`             // Description of the API
+	VpcEndpointIDs       []string          `json:"vpc_endpoint_ids"`        // Endpoints of the endpoint configuration, which get Route 53 aliases for the API
+	Policy               string            `json:"policy"`                  // Resource policy as JSON text (empty if the API has none)
+	PolicyVpcEndpointIDs []string          `json:"policy_vpc_endpoint_ids"` // Endpoints the policy allows with aws:SourceVpce, as written (may contain wildcards)
+	PolicyVpcIDs         []string          `json:"policy_vpc_ids"`          // VPCs the policy allows with aws:SourceVpc, as written (may contain wildcards)
+	PolicyAllowsInvoke   bool              `json:"policy_allows_invoke"`    // Whether the policy has an Allow statement; without one the API cannot be invoked
+	PolicyUnrestricted   bool              `json:"policy_unrestricted"`     // Whether the policy allows invocation without an aws:SourceVpce or aws:SourceVpc condition
+	PolicyError          string            `json:"policy_error,omitempty"`  // Why the policy could not be parsed (empty if it was)
+	Tags                 map[string]string `json:"tags"`                    // Key-value tags associated with the API
+}
+
+// Scanner provides methods for retrieving private API information
+type Scanner struct {
+	client *apigateway.Client // Amazon API Gateway client for making API calls
+	region string             // Region of the client, for ARNs
+}
+
+// NewScanner creates a new private API scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials and region information
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		client: apigateway.NewFromConfig(cfg),
+		region: cfg.Region,
+	}
+}
+
+// GetPrivateAPIs retrieves the REST APIs with a private endpoint and parses their resource policies
+// Edge-optimized and regional APIs are skipped. A policy that cannot be parsed is kept as text with
+// PolicyError set, so one malformed policy does not fail the scan.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Private APIs sorted by name and ID, or error if the operation fails
+func (s *Scanner) GetPrivateAPIs(ctx context.Context) ([]PrivateAPIInfo, error) {
+	apis := []PrivateAPIInfo{}
+
+	paginator := apigateway.NewGetRestApisPaginator(s.client, &apigateway.GetRestApisInput{Limit: aws.Int32(500)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("apigateway", "REST APIs", "GetRestApis", err)
+		}
+		for _, api := range page.Items {
+			if !isPrivate(api.EndpointConfiguration) {
+				continue
+			}
+			info := PrivateAPIInfo{
+				RestApiID:            aws.ToString(api.Id),
+				Name:                 aws.ToString(api.Name),
+				Description:          aws.ToString(api.Description),
+				VpcEndpointIDs:       append([]string{}, api.EndpointConfiguration.VpcEndpointIds...),
+				PolicyVpcEndpointIDs: []string{},
+				PolicyVpcIDs:         []string{},
+				Tags:                 map[string]string{},
+			}
+			info.Arn = fmt.Sprintf("arn:%s:apigateway:%s::/restapis/%s", arnbuild.Partition(s.region), s.region, info.RestApiID)
+			for key, value := range api.Tags {
+				info.Tags[key] = value
+			}
+
+			policy, err := ParsePolicy(aws.ToString(api.Policy))
+			if err != nil {
+				info.Policy = aws.ToString(api.Policy)
+				info.PolicyError = err.Error()
+			} else {
+				info.Policy = policy.Text
+				info.PolicyVpcEndpointIDs = policy.VpcEndpointIDs
+				info.PolicyVpcIDs = policy.VpcIDs
+				info.PolicyAllowsInvoke = policy.AllowsInvoke
+				info.PolicyUnrestricted = policy.Unrestricted
+			}
+			apis = append(apis, info)
+		}
+	}
+
+	sort.Slice(apis, func(i, j int) bool {
+		if apis[i].Name != apis[j].Name {
+			return apis[i].Name < apis[j].Name
+		}
+		return apis[i].RestApiID < apis[j].RestApiID
+	})
+	return apis, nil
+}
+
+// isPrivate reports whether an endpoint configuration has the PRIVATE type
+func isPrivate(config *types.EndpointConfiguration) bool {
+	if config == nil {
+		return false
+	}
+	for _, endpointType := range config.Types {
+		if endpointType == types.EndpointTypePrivate {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy is what a resource policy says about the VPC endpoints and VPCs a request may come from
+type Policy struct {
+	Text           string   // Policy as JSON text, unescaped
+	VpcEndpointIDs []string // Endpoints allowed with aws:SourceVpce, sorted without duplicates
+	VpcIDs         []string // VPCs allowed with aws:SourceVpc, sorted without duplicates
+	Unrestricted   bool     // Whether an Allow statement has no source condition and no Deny statement restricts the source
+	AllowsInvoke   bool     // Whether the policy has an Allow statement at all
+}
+
+// policyDocument is the part of an IAM policy document the parser reads
+type policyDocument struct {
+	Statement oneOrMany[policyStatement] `json:"Statement"`
+}
+
+// policyStatement is the part of a policy statement the parser reads
+type policyStatement struct {
+	Effect    string                                  `json:"Effect"`
+	Condition map[string]map[string]oneOrMany[string] `json:"Condition"`
+}
+
+// oneOrMany is a policy element that may be given as a single value or as a list
+type oneOrMany[T any] []T
+
+// UnmarshalJSON accepts a single value as a list of one
+func (o *oneOrMany[T]) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		var values []T
+		if err := json.Unmarshal(data, &values); err != nil {
+			return err
+		}
+		*o = values
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*o = []T{value}
+	return nil
+}
+
+// policyUnescaper undoes the backslash escaping of the policies GetRestApis returns
+var policyUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\/`, `/`)
+
+// ParsePolicy extracts the VPC endpoints and VPCs a resource policy admits
+// API Gateway returns the policy with its quotes backslash-escaped, so text that is not JSON is unescaped first.
+// Sources are allowed by an Allow statement with a StringEquals or StringLike condition on aws:SourceVpce or
+// aws:SourceVpc, or by a Deny statement with a StringNotEquals or StringNotLike condition, which denies every other
+// source. Conditions of several statements are merged, although AWS requires a request to pass all of them.
+// policy: Policy text from GetRestApis (empty for an API without a policy)
+// Returns: The parsed policy, or error if the text is not a policy document
+func ParsePolicy(policy string) (*Policy, error) {
+	parsed := &Policy{VpcEndpointIDs: []string{}, VpcIDs: []string{}}
+	if policy == "" {
+		return parsed, nil
+	}
+
+	var doc policyDocument
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		unescaped := policyUnescaper.Replace(policy)
+		if json.Unmarshal([]byte(unescaped), &doc) != nil {
+			return nil, fmt.Errorf("policy is not JSON: %w", err)
+		}
+		policy = unescaped
+	}
+	parsed.Text = policy
+
+	openAllow, denyRestricts := false, false
+	for _, statement := range doc.Statement {
+		allow := strings.EqualFold(statement.Effect, "Allow")
+		if allow {
+			parsed.AllowsInvoke = true
+		}
+		restricted := false
+		for operator, keys := range statement.Condition {
+			grants := grantsSources(operator, allow)
+			for key, values := range keys {
+				if !strings.EqualFold(key, KeySourceVpce) && !strings.EqualFold(key, KeySourceVpc) {
+					continue
+				}
+				restricted = true
+				if !grants {
+					continue
+				}
+				for _, value := range values {
+					if strings.EqualFold(key, KeySourceVpce) {
+						parsed.VpcEndpointIDs = appendUnique(parsed.VpcEndpointIDs, value)
+					} else {
+						parsed.VpcIDs = appendUnique(parsed.VpcIDs, value)
+					}
+				}
+				if !allow {
+					denyRestricts = true
+				}
+			}
+		}
+		if allow && !restricted {
+			openAllow = true
+		}
+	}
+	sort.Strings(parsed.VpcEndpointIDs)
+	sort.Strings(parsed.VpcIDs)
+	parsed.Unrestricted = openAllow && !denyRestricts
+	return parsed, nil
+}
+
+// grantsSources reports whether the values of a condition operator are sources the statement lets through
+// operator: Condition operator, with an optional ForAnyValue: or ForAllValues: prefix and IfExists suffix
+// allow: Whether the statement is an Allow statement
+func grantsSources(operator string, allow bool) bool {
+	if i := strings.Index(operator, ":"); i >= 0 {
+		operator = operator[i+1:]
+	}
+	operator = strings.TrimSuffix(operator, "IfExists")
+	switch operator {
+	case "StringEquals", "StringLike", "StringEqualsIgnoreCase":
+		return allow
+	case "StringNotEquals", "StringNotLike", "StringNotEqualsIgnoreCase":
+		return !allow
+	}
+	return false
+}
+
+// appendUnique appends a value to a slice unless the slice already contains it
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,143 @@
+package privateapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// endpointPolicy is the resource policy the API Gateway console suggests for private APIs
+const endpointPolicy = `{"Version":"2012-10-17","Statement":[
+	{"Effect":"Allow","Principal":"*","Action":"execute-api:Invoke","Resource":"execute-api:/*"},
+	{"Effect":"Deny","Principal":"*","Action":"execute-api:Invoke","Resource":"execute-api:/*",
+	 "Condition":{"StringNotEquals":{"aws:SourceVpce":["vpce-0b2","vpce-0a1"]}}}]}`
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		want    Policy // Expected result, without Text
+		wantErr bool
+	}{
+		{name: "no policy", policy: "",
+			want: Policy{VpcEndpointIDs: []string{}, VpcIDs: []string{}}},
+		{name: "deny other endpoints", policy: endpointPolicy,
+			want: Policy{VpcEndpointIDs: []string{"vpce-0a1", "vpce-0b2"}, VpcIDs: []string{}, AllowsInvoke: true}},
+		{name: "escaped by API Gateway", policy: strings.ReplaceAll(endpointPolicy, `"`, `\"`),
+			want: Policy{VpcEndpointIDs: []string{"vpce-0a1", "vpce-0b2"}, VpcIDs: []string{}, AllowsInvoke: true}},
+		{name: "allow one endpoint and a VPC", policy: `{"Statement":[
+			{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"StringEquals":{"aws:sourcevpce":"vpce-0a1"}}},
+			{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"StringLike":{"aws:SourceVpc":"vpc-0c*"}}}]}`,
+			want: Policy{VpcEndpointIDs: []string{"vpce-0a1"}, VpcIDs: []string{"vpc-0c*"}, AllowsInvoke: true}},
+		{name: "statement as an object", policy: `{"Statement":{"Effect":"Allow","Action":"execute-api:Invoke",
+			"Condition":{"ForAnyValue:StringEqualsIfExists":{"aws:SourceVpce":["vpce-0a1"]}}}}`,
+			want: Policy{VpcEndpointIDs: []string{"vpce-0a1"}, VpcIDs: []string{}, AllowsInvoke: true}},
+		{name: "no aws:SourceVpce condition", policy: `{"Statement":[
+			{"Effect":"Allow","Principal":"*","Action":"execute-api:Invoke","Resource":"execute-api:/*"}]}`,
+			want: Policy{VpcEndpointIDs: []string{}, VpcIDs: []string{}, AllowsInvoke: true, Unrestricted: true}},
+		{name: "condition on other keys only", policy: `{"Statement":[
+			{"Effect":"Allow","Action":"execute-api:Invoke","Condition":{"IpAddress":{"aws:SourceIp":"10.0.0.0/8"}}}]}`,
+			want: Policy{VpcEndpointIDs: []string{}, VpcIDs: []string{}, AllowsInvoke: true, Unrestricted: true}},
+		{name: "denied endpoint grants nothing", policy: `{"Statement":[
+			{"Effect":"Allow","Action":"execute-api:Invoke"},
+			{"Effect":"Deny","Action":"execute-api:Invoke","Condition":{"StringEquals":{"aws:SourceVpce":"vpce-0d4"}}}]}`,
+			want: Policy{VpcEndpointIDs: []string{}, VpcIDs: []string{}, AllowsInvoke: true, Unrestricted: true}},
+		{name: "deny without allow", policy: `{"Statement":[
+			{"Effect":"Deny","Action":"execute-api:Invoke","Condition":{"StringNotEquals":{"aws:SourceVpce":"vpce-0a1"}}}]}`,
+			want: Policy{VpcEndpointIDs: []string{"vpce-0a1"}, VpcIDs: []string{}}},
+		{name: "not a policy", policy: `not json`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicy(tt.policy)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePolicy() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.policy != "" && !json.Valid([]byte(got.Text)) {
+				t.Errorf("Text is not JSON: %s", got.Text)
+			}
+			got.Text = ""
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParsePolicy() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGetPrivateAPIs checks that only private APIs are kept, from every page, with their policies parsed
+func TestGetPrivateAPIs(t *testing.T) {
+	pages := map[string]string{
+		"": `{"position":"page-2","item":[
+			{"id":"a1","name":"orders","endpointConfiguration":{"types":["PRIVATE"],"vpcEndpointIds":["vpce-0a1","vpce-0b2"]},
+			 "policy":` + strings.TrimSpace(mustQuote(t, strings.ReplaceAll(endpointPolicy, `"`, `\"`))) + `,"tags":{"team":"payments"}},
+			{"id":"r1","name":"public","endpointConfiguration":{"types":["REGIONAL"]}}]}`,
+		"page-2": `{"item":[
+			{"id":"b2","name":"billing","endpointConfiguration":{"types":["PRIVATE"]},"policy":"not json"},
+			{"id":"e1","name":"edge"}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/restapis" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, pages[r.URL.Query().Get("position")])
+	}))
+	t.Cleanup(server.Close)
+
+	scanner := NewScanner(aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+	apis, err := scanner.GetPrivateAPIs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apis) != 2 || apis[0].RestApiID != "b2" || apis[1].RestApiID != "a1" {
+		t.Fatalf("got %+v, want billing and orders", apis)
+	}
+
+	billing, orders := apis[0], apis[1]
+	if billing.PolicyError == "" || billing.Policy != "not json" {
+		t.Errorf("billing: policy %q with error %q, want the unparsed text and an error", billing.Policy, billing.PolicyError)
+	}
+	if want := []string{"vpce-0a1", "vpce-0b2"}; !reflect.DeepEqual(orders.VpcEndpointIDs, want) || !reflect.DeepEqual(orders.PolicyVpcEndpointIDs, want) {
+		t.Errorf("orders: bound %v and allowed %v, want %v for both", orders.VpcEndpointIDs, orders.PolicyVpcEndpointIDs, want)
+	}
+	if orders.Arn != "arn:aws:apigateway:eu-west-1::/restapis/a1" {
+		t.Errorf("orders: ARN %s", orders.Arn)
+	}
+	if !orders.PolicyAllowsInvoke || orders.PolicyUnrestricted || orders.Tags["team"] != "payments" {
+		t.Errorf("orders: %+v", orders)
+	}
+	if !json.Valid([]byte(orders.Policy)) {
+		t.Errorf("orders: policy was not unescaped: %s", orders.Policy)
+	}
+}
+
+// mustQuote returns s as a JSON string
+func mustQuote(t *testing.T, s string) string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,300 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// functions are the function names of the JMESPath standard library
+var functions = []string{
+	"abs", "avg", "ceil", "contains", "ends_with", "floor", "join", "keys", "length", "map", "max", "max_by",
+	"merge", "min", "min_by", "not_null", "reverse", "sort", "sort_by", "starts_with", "sum", "to_array",
+	"to_number", "to_string", "type", "values",
+}
+
+// CheckError is an expression that does not fit the report schema or the expression syntax
+type CheckError struct {
+	Expression string // The expression
+	Offset     int    // Byte offset of the problem in the expression
+	Message    string // What is wrong, with suggestions where there are any
+}
+
+// Error formats the message with the expression and a caret below the problem
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s\n  %s\n  %s^", e.Message, e.Expression, strings.Repeat(" ", e.Offset))
+}
+
+// checker checks the field lookups of an expression against the Go types of the report
+// A nil type stands for a value whose type is not known statically (literals, function results,
+// multi-select results); lookups on it are not checked.
+type checker struct {
+	expression string
+}
+
+// check follows an expression from a value of type t
+// path: Where the value comes from, for messages (subnets[], security_groups[].rules[]; empty at the top level)
+// Returns: Type and path of the result, or error at the first field the type does not have
+func (c *checker) check(n *node, t reflect.Type, path string) (reflect.Type, string, error) {
+	t = deref(t)
+	switch n.kind {
+	case nodeCurrent:
+		return t, path, nil
+	case nodeField:
+		return c.field(n, t, path)
+	case nodeSubexpression, nodePipe:
+		left, leftPath, err := c.check(n.children[0], t, path)
+		if err != nil {
+			return nil, "", err
+		}
+		return c.check(n.children[1], left, leftPath)
+	case nodeIndex:
+		left, leftPath, err := c.check(n.children[0], t, path)
+		if err != nil || left == nil || left.Kind() != reflect.Slice {
+			return nil, leftPath, err
+		}
+		return left.Elem(), leftPath + "[]", nil
+	case nodeProjection, nodeFilter:
+		left, leftPath, err := c.check(n.children[0], t, path)
+		if err != nil {
+			return nil, "", err
+		}
+		var elem reflect.Type
+		if left != nil {
+			if left.Kind() != reflect.Slice {
+				return nil, "", c.errorf(n.children[0], "%s is %s, not a list, so it cannot be projected with []", describePath(leftPath), typeName(left))
+			}
+			elem = left.Elem()
+		}
+		if n.kind == nodeFilter {
+			if _, _, err := c.check(n.children[2], elem, leftPath+"[]"); err != nil {
+				return nil, "", err
+			}
+		}
+		right, rightPath, err := c.check(n.children[1], elem, leftPath+"[]")
+		return sliceOf(right), rightPath, err
+	case nodeValueProjection:
+		left, leftPath, err := c.check(n.children[0], t, path)
+		if err != nil {
+			return nil, "", err
+		}
+		var elem reflect.Type
+		if left != nil && left.Kind() == reflect.Map {
+			elem = left.Elem()
+		}
+		right, rightPath, err := c.check(n.children[1], elem, leftPath+".*")
+		return sliceOf(right), rightPath, err
+	case nodeFlatten:
+		left, leftPath, err := c.check(n.children[0], t, path)
+		if err != nil || left == nil || left.Kind() != reflect.Slice {
+			return nil, leftPath, err
+		}
+		if deref(left.Elem()).Kind() == reflect.Slice {
+			return deref(left.Elem()), leftPath, nil
+		}
+		return left, leftPath, nil
+	case nodeHash, nodeList, nodeOperator:
+		for _, child := range n.children {
+			if _, _, err := c.check(child, t, path); err != nil {
+				return nil, "", err
+			}
+		}
+		return nil, path, nil
+	case nodeFunction:
+		return c.function(n, t, path)
+	case nodeExpref:
+		_, _, err := c.check(n.children[0], nil, "")
+		return nil, "", err
+	}
+	return nil, path, nil
+}
+
+// field checks a field lookup
+func (c *checker) field(n *node, t reflect.Type, path string) (reflect.Type, string, error) {
+	fieldPath := n.name
+	if path != "" {
+		fieldPath = path + "." + n.name
+	}
+	if t == nil {
+		return nil, fieldPath, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(t)
+		if field, ok := fields[n.name]; ok {
+			return field.Type, fieldPath, nil
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		what := fmt.Sprintf("unknown field %q in %s", n.name, path)
+		if path == "" {
+			what = fmt.Sprintf("unknown collection %q", n.name)
+		}
+		if suggestions := suggest(n.name, names); len(suggestions) > 0 {
+			return nil, "", c.errorf(n, "%s; did you mean %s?", what, strings.Join(suggestions, " or "))
+		}
+		return nil, "", c.errorf(n, "%s; run \"aws-documentor query -schema\" to list the fields", what)
+	case reflect.Map:
+		// Tags and other maps have free-form keys
+		return t.Elem(), fieldPath, nil
+	case reflect.Slice:
+		return nil, "", c.errorf(n, "%s is a list; project it to look up %q in each element: %s[].%s", describePath(path), n.name, path, n.name)
+	}
+	return nil, "", c.errorf(n, "%s is %s and has no field %q", describePath(path), typeName(t), n.name)
+}
+
+// function checks a function call
+// Expression references (&field) are checked against the elements of the first list argument, which
+// is what sort_by, max_by, min_by and map apply them to.
+func (c *checker) function(n *node, t reflect.Type, path string) (reflect.Type, string, error) {
+	known := false
+	for _, name := range functions {
+		known = known || name == n.name
+	}
+	if !known {
+		if suggestions := suggest(n.name, functions); len(suggestions) > 0 {
+			return nil, "", c.errorf(n, "unknown function %s(); did you mean %s?", n.name, strings.Join(suggestions, " or "))
+		}
+		return nil, "", c.errorf(n, "unknown function %s()", n.name)
+	}
+
+	var elem reflect.Type
+	elemPath := ""
+	for _, arg := range n.children {
+		if arg.kind == nodeExpref {
+			continue
+		}
+		argType, argPath, err := c.check(arg, t, path)
+		if err != nil {
+			return nil, "", err
+		}
+		if elemPath == "" && argType != nil && argType.Kind() == reflect.Slice {
+			elem, elemPath = argType.Elem(), argPath+"[]"
+		}
+	}
+	for _, arg := range n.children {
+		if arg.kind != nodeExpref {
+			continue
+		}
+		if _, _, err := c.check(arg.children[0], elem, elemPath); err != nil {
+			return nil, "", err
+		}
+	}
+	return nil, path, nil
+}
+
+// errorf returns a CheckError at a node
+func (c *checker) errorf(n *node, format string, args ...interface{}) error {
+	return &CheckError{Expression: c.expression, Offset: n.offset, Message: fmt.Sprintf(format, args...)}
+}
+
+// jsonFields returns the exported fields of a struct by JSON name
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// suggest returns the candidates closest to a misspelled name, closest first
+// A candidate is close when it is at most a third of the name's length in edits away, or when one
+// contains the other (cidr for cidr_block).
+func suggest(name string, candidates []string) []string {
+	type scored struct {
+		name     string
+		distance int
+	}
+	var matches []scored
+	for _, candidate := range candidates {
+		distance := editDistance(name, candidate)
+		if distance <= len(name)/3 || (len(name) >= 3 && (strings.Contains(candidate, name) || strings.Contains(name, candidate))) {
+			matches = append(matches, scored{candidate, distance})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+	var names []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		names = append(names, fmt.Sprintf("%q", matches[i].name))
+	}
+	return names
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// deref returns the type a pointer points to
+func deref(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// sliceOf returns the list type of an element type, nil for an unknown element type
+func sliceOf(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	return reflect.SliceOf(t)
+}
+
+// describePath names a value in messages
+func describePath(path string) string {
+	if path == "" {
+		return "the report"
+	}
+	return path
+}
+
+// typeName names a Go type the way the schema listing does
+func typeName(t reflect.Type) string {
+	t = deref(t)
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		return "a list"
+	}
+	return "an object"
+}
@@ -0,0 +1,158 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"aws-documentor/modules/output"
+)
+
+// Format selects how a query result is written
+type Format string
+
+const (
+	FormatJSON  Format = "json"  // Indented JSON, exactly as the expression produced it
+	FormatTable Format = "table" // Aligned text columns, one row per result element
+	FormatCSV   Format = "csv"   // CSV with a header row, one row per result element
+)
+
+// ParseFormat validates a -format flag value
+// s: The flag value (json, table or csv)
+// Returns: The matching Format, or error if the value is not recognized
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatTable:
+		return FormatTable, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("unknown format %q (expected json, table or csv)", s)
+}
+
+// Write writes a query result in the given format
+// For table and CSV, a list of objects becomes one row per object with a column per key, a list of
+// values one row per value, and a single object or value one row. Lists of values inside a cell are
+// joined with semicolons; nested objects are written as JSON.
+// w: Destination
+// result: Result of Query.Run
+// columns: Column order (Query.Columns); nil to sort the keys of the objects
+// format: Output format
+// Returns: Error if writing fails
+func Write(w io.Writer, result interface{}, columns []string, format Format) error {
+	if format == FormatJSON {
+		data, err := output.MarshalIndent(result, output.FieldStyleSnake)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	header, rows := tabulate(result, columns)
+	if format == FormatCSV {
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return writer.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tabulate turns a result into a header and rows
+func tabulate(result interface{}, columns []string) ([]string, [][]string) {
+	items, isList := result.([]interface{})
+	if !isList {
+		if result == nil {
+			items = nil
+		} else {
+			items = []interface{}{result}
+		}
+	}
+
+	objects := len(items) > 0
+	for _, item := range items {
+		if _, ok := item.(map[string]interface{}); !ok && item != nil {
+			objects = false
+		}
+	}
+	if !objects {
+		rows := make([][]string, 0, len(items))
+		for _, item := range items {
+			rows = append(rows, []string{cell(item)})
+		}
+		return []string{"value"}, rows
+	}
+
+	if columns == nil {
+		keys := make(map[string]bool)
+		for _, item := range items {
+			for key := range asObject(item) {
+				keys[key] = true
+			}
+		}
+		for key := range keys {
+			columns = append(columns, key)
+		}
+		sort.Strings(columns)
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		object := asObject(item)
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = cell(object[column])
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows
+}
+
+// asObject returns a result element as an object; null elements have no keys
+func asObject(item interface{}) map[string]interface{} {
+	object, _ := item.(map[string]interface{})
+	return object
+}
+
+// cell formats a value for a table or CSV cell
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, nested := item.(map[string]interface{}); nested {
+				data, _ := json.Marshal(v)
+				return string(data)
+			}
+			parts = append(parts, cell(item))
+		}
+		return strings.Join(parts, ";")
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
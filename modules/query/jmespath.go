@@ -0,0 +1,38 @@
+package query
+
+import (
+	"errors"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// jmesPathEvaluator evaluates JMESPath expressions with the reference Go implementation
+type jmesPathEvaluator struct{}
+
+// JMESPath returns the evaluator for JMESPath expressions (https://jmespath.org)
+func JMESPath() Evaluator {
+	return jmesPathEvaluator{}
+}
+
+// Compile parses a JMESPath expression
+func (jmesPathEvaluator) Compile(expression string) (Program, error) {
+	compiled, err := jmespath.Compile(expression)
+	if err != nil {
+		var syntaxErr jmespath.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, &CheckError{Expression: expression, Offset: syntaxErr.Offset, Message: syntaxErr.Error()}
+		}
+		return nil, &CheckError{Expression: expression, Message: err.Error()}
+	}
+	return jmesPathProgram{compiled}, nil
+}
+
+// jmesPathProgram is a compiled JMESPath expression
+type jmesPathProgram struct {
+	compiled *jmespath.JMESPath
+}
+
+// Run evaluates the expression
+func (p jmesPathProgram) Run(data interface{}) (interface{}, error) {
+	return p.compiled.Search(data)
+}
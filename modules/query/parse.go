@@ -0,0 +1,479 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The parser below follows the JMESPath grammar and binding powers, but keeps only what the schema
+// check needs: which fields are looked up on which values. Evaluation is left to the Evaluator.
+
+// tokenKind identifies a lexical token of an expression
+type tokenKind int
+
+const (
+	tokEOF        tokenKind = iota
+	tokIdentifier           // Unquoted or quoted identifier
+	tokLiteral              // `JSON literal` or 'raw string'
+	tokNumber               // Integer in an index or slice
+	tokDot                  // .
+	tokStar                 // *
+	tokLbracket             // [
+	tokFilter               // [?
+	tokFlatten              // []
+	tokRbracket             // ]
+	tokLbrace               // {
+	tokRbrace               // }
+	tokLparen               // (
+	tokRparen               // )
+	tokComma                // ,
+	tokColon                // :
+	tokPipe                 // |
+	tokOr                   // ||
+	tokAnd                  // &&
+	tokNot                  // !
+	tokExpref               // &
+	tokCurrent              // @
+	tokCompare              // == != < <= > >=
+)
+
+// bindingPowers are the left binding powers of the tokens, as in the JMESPath reference parser
+var bindingPowers = map[tokenKind]int{
+	tokPipe:     1,
+	tokOr:       2,
+	tokAnd:      3,
+	tokCompare:  5,
+	tokFlatten:  9,
+	tokStar:     20,
+	tokFilter:   21,
+	tokDot:      40,
+	tokNot:      45,
+	tokLbrace:   50,
+	tokLbracket: 55,
+	tokLparen:   60,
+}
+
+// token is a lexical token with its position in the expression
+type token struct {
+	kind   tokenKind
+	value  string // Identifier name (unquoted), literal text or number
+	offset int    // Byte offset in the expression
+}
+
+// nodeKind identifies a node of the parsed expression
+type nodeKind int
+
+const (
+	nodeField           nodeKind = iota // Field lookup: name
+	nodeSubexpression                   // children[0].children[1]
+	nodePipe                            // children[0] | children[1]
+	nodeIndex                           // children[0][n]
+	nodeProjection                      // children[0][*] (or a slice), children[1] applied to every element
+	nodeValueProjection                 // children[0].*, children[1] applied to every value of an object
+	nodeFilter                          // children[0][?children[2]], children[1] applied to the matching elements
+	nodeFlatten                         // children[0][]
+	nodeHash                            // {keys[i]: children[i], ...}
+	nodeList                            // [children[0], ...]
+	nodeOperator                        // Comparison, &&, || or !: children are operands
+	nodeFunction                        // name(children...)
+	nodeExpref                          // &children[0]
+	nodeLiteral                         // Literal value
+	nodeCurrent                         // @ or the implicit current value
+)
+
+// node is a parsed expression
+type node struct {
+	kind     nodeKind
+	name     string   // Field or function name
+	keys     []string // Keys of a multi-select hash, in expression order
+	offset   int      // Byte offset of the field or function name in the expression
+	children []*node
+}
+
+// parser is a Pratt parser over the tokens of one expression
+type parser struct {
+	tokens   []token
+	position int // Index of the current token
+}
+
+// parse parses an expression into a tree
+// Returns: The tree, or error if the expression uses syntax this parser does not know
+func parse(expression string) (*node, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	tree, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(0).kind != tokEOF {
+		return nil, p.errorf("unexpected token at the end of the expression")
+	}
+	return tree, nil
+}
+
+// expression parses an expression whose operators bind tighter than bindingPower
+func (p *parser) expression(bindingPower int) (*node, error) {
+	left, err := p.nud(p.next())
+	for err == nil && bindingPower < bindingPowers[p.peek(0).kind] {
+		left, err = p.led(p.next(), left)
+	}
+	return left, err
+}
+
+// nud parses a token at the start of an expression
+func (p *parser) nud(tok token) (*node, error) {
+	current := &node{kind: nodeCurrent}
+	switch tok.kind {
+	case tokLiteral:
+		return &node{kind: nodeLiteral}, nil
+	case tokIdentifier:
+		return &node{kind: nodeField, name: tok.value, offset: tok.offset}, nil
+	case tokStar:
+		right := current
+		if p.peek(0).kind != tokRbracket {
+			var err error
+			if right, err = p.projectionRHS(bindingPowers[tokStar]); err != nil {
+				return nil, err
+			}
+		}
+		return &node{kind: nodeValueProjection, children: []*node{current, right}}, nil
+	case tokFilter:
+		return p.filter(current)
+	case tokLbrace:
+		return p.hash()
+	case tokFlatten:
+		right, err := p.projectionRHS(bindingPowers[tokFlatten])
+		return &node{kind: nodeProjection, children: []*node{{kind: nodeFlatten, children: []*node{current}}, right}}, err
+	case tokLbracket:
+		switch {
+		case p.peek(0).kind == tokNumber || p.peek(0).kind == tokColon:
+			return p.index(current)
+		case p.peek(0).kind == tokStar && p.peek(1).kind == tokRbracket:
+			p.next()
+			p.next()
+			right, err := p.projectionRHS(bindingPowers[tokStar])
+			return &node{kind: nodeProjection, children: []*node{current, right}}, err
+		}
+		return p.list()
+	case tokCurrent:
+		return current, nil
+	case tokExpref:
+		child, err := p.expression(0)
+		return &node{kind: nodeExpref, children: []*node{child}}, err
+	case tokNot:
+		child, err := p.expression(bindingPowers[tokNot])
+		return &node{kind: nodeOperator, children: []*node{child}}, err
+	case tokLparen:
+		child, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		return child, p.match(tokRparen)
+	}
+	return nil, p.errorf("unexpected token")
+}
+
+// led parses a token that continues the expression left
+func (p *parser) led(tok token, left *node) (*node, error) {
+	switch tok.kind {
+	case tokDot:
+		if p.peek(0).kind == tokStar {
+			p.next()
+			right, err := p.projectionRHS(bindingPowers[tokDot])
+			return &node{kind: nodeValueProjection, children: []*node{left, right}}, err
+		}
+		right, err := p.dotRHS(bindingPowers[tokDot])
+		return &node{kind: nodeSubexpression, children: []*node{left, right}}, err
+	case tokPipe:
+		right, err := p.expression(bindingPowers[tokPipe])
+		return &node{kind: nodePipe, children: []*node{left, right}}, err
+	case tokOr, tokAnd, tokCompare:
+		right, err := p.expression(bindingPowers[tok.kind])
+		return &node{kind: nodeOperator, children: []*node{left, right}}, err
+	case tokLparen:
+		if left.kind != nodeField {
+			return nil, p.errorf("function name expected before (")
+		}
+		function := &node{kind: nodeFunction, name: left.name, offset: left.offset}
+		for p.peek(0).kind != tokRparen {
+			arg, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			function.children = append(function.children, arg)
+			if p.peek(0).kind == tokComma {
+				p.next()
+			}
+		}
+		return function, p.match(tokRparen)
+	case tokFilter:
+		return p.filter(left)
+	case tokFlatten:
+		right, err := p.projectionRHS(bindingPowers[tokFlatten])
+		return &node{kind: nodeProjection, children: []*node{{kind: nodeFlatten, children: []*node{left}}, right}}, err
+	case tokLbracket:
+		if p.peek(0).kind == tokNumber || p.peek(0).kind == tokColon {
+			return p.index(left)
+		}
+		if err := p.match(tokStar); err != nil {
+			return nil, err
+		}
+		if err := p.match(tokRbracket); err != nil {
+			return nil, err
+		}
+		right, err := p.projectionRHS(bindingPowers[tokStar])
+		return &node{kind: nodeProjection, children: []*node{left, right}}, err
+	}
+	return nil, p.errorf("unexpected token")
+}
+
+// index parses [n] or a slice [start:stop:step] after left; a slice projects like [*]
+func (p *parser) index(left *node) (*node, error) {
+	slice := false
+	for p.peek(0).kind == tokNumber || p.peek(0).kind == tokColon {
+		if p.next().kind == tokColon {
+			slice = true
+		}
+	}
+	if err := p.match(tokRbracket); err != nil {
+		return nil, err
+	}
+	if !slice {
+		return &node{kind: nodeIndex, children: []*node{left}}, nil
+	}
+	right, err := p.projectionRHS(bindingPowers[tokStar])
+	return &node{kind: nodeProjection, children: []*node{left, right}}, err
+}
+
+// filter parses the condition and right-hand side of left[?condition]
+func (p *parser) filter(left *node) (*node, error) {
+	condition, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.match(tokRbracket); err != nil {
+		return nil, err
+	}
+	right := &node{kind: nodeCurrent}
+	if p.peek(0).kind != tokFlatten {
+		if right, err = p.projectionRHS(bindingPowers[tokFilter]); err != nil {
+			return nil, err
+		}
+	}
+	return &node{kind: nodeFilter, children: []*node{left, right, condition}}, nil
+}
+
+// projectionRHS parses what a projection applies to each element
+func (p *parser) projectionRHS(bindingPower int) (*node, error) {
+	switch next := p.peek(0).kind; {
+	case bindingPowers[next] < 10:
+		return &node{kind: nodeCurrent}, nil
+	case next == tokLbracket || next == tokFilter:
+		return p.expression(bindingPower)
+	case next == tokDot:
+		p.next()
+		return p.dotRHS(bindingPower)
+	}
+	return nil, p.errorf("unexpected token after projection")
+}
+
+// dotRHS parses the right-hand side of a dot
+func (p *parser) dotRHS(bindingPower int) (*node, error) {
+	switch p.peek(0).kind {
+	case tokIdentifier, tokStar:
+		return p.expression(bindingPower)
+	case tokLbracket:
+		p.next()
+		return p.list()
+	case tokLbrace:
+		p.next()
+		return p.hash()
+	}
+	return nil, p.errorf("identifier, [ or { expected after .")
+}
+
+// list parses a multi-select list after its [
+func (p *parser) list() (*node, error) {
+	list := &node{kind: nodeList}
+	for {
+		child, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		list.children = append(list.children, child)
+		if p.peek(0).kind == tokRbracket {
+			p.next()
+			return list, nil
+		}
+		if err := p.match(tokComma); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// hash parses a multi-select hash after its {
+func (p *parser) hash() (*node, error) {
+	hash := &node{kind: nodeHash}
+	for {
+		key := p.next()
+		if key.kind != tokIdentifier {
+			return nil, p.errorf("key expected in {}")
+		}
+		if err := p.match(tokColon); err != nil {
+			return nil, err
+		}
+		value, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		hash.keys = append(hash.keys, key.value)
+		hash.children = append(hash.children, value)
+		if p.peek(0).kind == tokRbrace {
+			p.next()
+			return hash, nil
+		}
+		if err := p.match(tokComma); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// peek returns a token ahead of the current one without consuming it
+func (p *parser) peek(ahead int) token {
+	if p.position+ahead >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.position+ahead]
+}
+
+// next consumes and returns the current token
+func (p *parser) next() token {
+	tok := p.peek(0)
+	if p.position < len(p.tokens)-1 {
+		p.position++
+	}
+	return tok
+}
+
+// match consumes the current token if it has the given kind
+func (p *parser) match(kind tokenKind) error {
+	if p.peek(0).kind != kind {
+		return p.errorf("unexpected token")
+	}
+	p.next()
+	return nil
+}
+
+// errorf returns a syntax error at the current token
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at offset %d", fmt.Sprintf(format, args...), p.peek(0).offset)
+}
+
+// lex splits an expression into tokens, ending with tokEOF
+func lex(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		start := i
+		add := func(kind tokenKind, length int) {
+			tokens = append(tokens, token{kind: kind, value: expression[start : start+length], offset: start})
+			i += length
+		}
+		next := byte(0)
+		if i+1 < len(expression) {
+			next = expression[i+1]
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentifierStart(c):
+			for i++; i < len(expression) && isIdentifierPart(expression[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: tokIdentifier, value: expression[start:i], offset: start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			for i++; i < len(expression) && expression[i] >= '0' && expression[i] <= '9'; i++ {
+			}
+			tokens = append(tokens, token{kind: tokNumber, value: expression[start:i], offset: start})
+		case c == '"' || c == '\'' || c == '`':
+			end := closingQuote(expression, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed %c at offset %d", c, start)
+			}
+			i = end + 1
+			if c != '"' {
+				tokens = append(tokens, token{kind: tokLiteral, value: expression[start:i], offset: start})
+				continue
+			}
+			var name string
+			if err := json.Unmarshal([]byte(expression[start:i]), &name); err != nil {
+				return nil, fmt.Errorf("invalid quoted identifier at offset %d: %w", start, err)
+			}
+			tokens = append(tokens, token{kind: tokIdentifier, value: name, offset: start})
+		case c == '[' && next == '?':
+			add(tokFilter, 2)
+		case c == '[' && next == ']':
+			add(tokFlatten, 2)
+		case c == '|' && next == '|':
+			add(tokOr, 2)
+		case c == '&' && next == '&':
+			add(tokAnd, 2)
+		case (c == '<' || c == '>' || c == '!' || c == '=') && next == '=':
+			add(tokCompare, 2)
+		case c == '<' || c == '>':
+			add(tokCompare, 1)
+		default:
+			kind, ok := map[byte]tokenKind{
+				'.': tokDot, '*': tokStar, '[': tokLbracket, ']': tokRbracket, '{': tokLbrace, '}': tokRbrace,
+				'(': tokLparen, ')': tokRparen, ',': tokComma, ':': tokColon, '|': tokPipe, '!': tokNot,
+				'&': tokExpref, '@': tokCurrent,
+			}[c]
+			if !ok {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, start)
+			}
+			add(kind, 1)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, offset: len(expression)}), nil
+}
+
+// closingQuote returns the index of the quote that closes the one at start, skipping escaped quotes
+// Returns: Index of the closing quote, or -1 if it is missing
+func closingQuote(expression string, start int) int {
+	quote := expression[start]
+	for i := start + 1; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
+
+// columns returns the keys of the multi-select hash an expression ends in, in expression order
+// Returns: The keys, or nil if the result is not built by a multi-select hash
+func columns(n *node) []string {
+	for {
+		switch n.kind {
+		case nodeHash:
+			return n.keys
+		case nodeSubexpression, nodePipe, nodeProjection, nodeValueProjection, nodeFilter:
+			n = n.children[1]
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package query evaluates JMESPath expressions over a scan report
+// Expressions are checked against the report types before they run, so a misspelled field is an
+// error with suggestions instead of a silently empty result. The expression language sits behind
+// the Evaluator interface, so it can be replaced without touching the callers.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+// Report is the part of a scan report that queries run on
+// The field names match the report.json of the Lambda function, so its reports load directly.
+type Report struct {
+	ScannedAt        string                             `json:"scanned_at"`        // When the report's scan started
+	VPCs             []vpc.VPCInfo                      `json:"vpcs"`              // VPCs of the report
+	Subnets          []vpc.SubnetInfo                   `json:"subnets"`           // Subnets of the report
+	RouteTables      []vpc.RouteTableInfo               `json:"route_tables"`      // Route tables of the report
+	SecurityGroups   []vpc.SecurityGroupInfo            `json:"security_groups"`   // Security groups of the report
+	InternetGateways []vpc.InternetGatewayInfo          `json:"internet_gateways"` // Internet gateways of the report
+	NatGateways      []vpc.NatGatewayInfo               `json:"nat_gateways"`      // NAT gateways of the report
+	TransitGateways  []vpc.TransitGatewayInfo           `json:"transit_gateways"`  // Transit gateways of the report
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`   // Transit gateway attachments of the report (empty in reports without them)
+}
+
+// Evaluator compiles expressions of a query language
+type Evaluator interface {
+	// Compile parses an expression
+	// Returns: The compiled program, or a *CheckError if the expression is not valid syntax
+	Compile(expression string) (Program, error)
+}
+
+// Program is a compiled expression
+type Program interface {
+	// Run evaluates the expression over plain JSON values (maps, lists, strings, float64, bool, nil)
+	Run(data interface{}) (interface{}, error)
+}
+
+// Query is an expression checked against the report schema
+type Query struct {
+	Expression string   // The expression as given
+	Columns    []string // Keys of the multi-select hash the expression ends in, in expression order (nil if it ends in none)
+	program    Program
+}
+
+// LoadReport reads a JSON report written in any field style
+// Returns: Report, or error if the file cannot be read or is not a report
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var report Report
+	if err := output.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	if report.VPCs == nil {
+		return nil, fmt.Errorf("%s is not a scan report: it has no vpcs section", path)
+	}
+	return &report, nil
+}
+
+// Compile compiles an expression and checks every field it looks up against the Report types
+// expression: Expression over the report collections (vpcs, subnets, route_tables, ...)
+// evaluator: Query language, such as JMESPath()
+// Returns: The query, or a *CheckError for syntax errors, unknown fields and unknown functions
+func Compile(expression string, evaluator Evaluator) (*Query, error) {
+	program, err := evaluator.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parse(expression)
+	if err != nil {
+		// The evaluator accepts the expression, so this is syntax the checker does not follow; run it unchecked
+		return &Query{Expression: expression, program: program}, nil
+	}
+	c := &checker{expression: expression}
+	if _, _, err := c.check(tree, reflect.TypeOf(Report{}), ""); err != nil {
+		return nil, err
+	}
+	return &Query{Expression: expression, Columns: columns(tree), program: program}, nil
+}
+
+// Run evaluates the query over a report
+// Returns: The result as plain JSON values, or error if the evaluation fails (a function applied to the wrong type)
+func (q *Query) Run(report *Report) (interface{}, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	result, err := q.program.Run(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %s: %w", q.Expression, err)
+	}
+	return result, nil
+}
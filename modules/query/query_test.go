@@ -0,0 +1,201 @@
+package query
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+// testReport returns a report of two VPCs with public and private subnets and a group with SSH and HTTPS rules
+func testReport() *Report {
+	return &Report{
+		ScannedAt: "2026-03-01T12:00:00Z",
+		VPCs: []vpc.VPCInfo{
+			{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", Tags: map[string]string{"Name": "prod"}},
+			{VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/16", Tags: map[string]string{"Name": "dev"}},
+		},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", MapPublicIpOnLaunch: true, UtilizationPercent: 82.5},
+			{SubnetID: "subnet-0a2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24", UtilizationPercent: 91},
+			{SubnetID: "subnet-0b1", VpcID: "vpc-0b2", CidrBlock: "10.1.1.0/24", MapPublicIpOnLaunch: true, UtilizationPercent: 12},
+		},
+		SecurityGroups: []vpc.SecurityGroupInfo{
+			{GroupID: "sg-0web", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+				{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"},
+				{IpProtocol: "tcp", FromPort: 22, ToPort: 22, CidrBlock: "10.0.0.0/8"},
+			}},
+			{GroupID: "sg-0db", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+				{IpProtocol: "tcp", FromPort: 5432, ToPort: 5432, GroupID: "sg-0web"},
+			}},
+		},
+	}
+}
+
+// run compiles and runs an expression over the test report
+func run(t *testing.T, expression string) (*Query, interface{}) {
+	t.Helper()
+	q, err := Compile(expression, JMESPath())
+	if err != nil {
+		t.Fatalf("Compile(%s) = %v", expression, err)
+	}
+	result, err := q.Run(testReport())
+	if err != nil {
+		t.Fatalf("Run(%s) = %v", expression, err)
+	}
+	return q, result
+}
+
+// TestQuery checks filters, projections, nested collections, tags and functions
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		want        interface{}
+		wantColumns []string
+	}{
+		{name: "filter and projection",
+			expression:  "subnets[?map_public_ip_on_launch && utilization_percent > `70`].{id: subnet_id, vpc: vpc_id, cidr: cidr_block}",
+			want:        []interface{}{map[string]interface{}{"id": "subnet-0a1", "vpc": "vpc-0a1", "cidr": "10.0.1.0/24"}},
+			wantColumns: []string{"id", "vpc", "cidr"}},
+		{name: "field projection", expression: "subnets[].subnet_id",
+			want: []interface{}{"subnet-0a1", "subnet-0a2", "subnet-0b1"}},
+		{name: "nested collection", expression: "security_groups[].rules[?from_port == `22`].cidr_block",
+			want: []interface{}{[]interface{}{"10.0.0.0/8"}, []interface{}{}}},
+		{name: "flattened nested collection", expression: "security_groups[].rules[].from_port",
+			want: []interface{}{443.0, 22.0, 5432.0}},
+		{name: "nested projection with parent fields", expression: "security_groups[].{group: group_id, ports: rules[].to_port}",
+			want: []interface{}{
+				map[string]interface{}{"group": "sg-0web", "ports": []interface{}{443.0, 22.0}},
+				map[string]interface{}{"group": "sg-0db", "ports": []interface{}{5432.0}},
+			}, wantColumns: []string{"group", "ports"}},
+		{name: "tag lookup", expression: "vpcs[?tags.Name == 'dev'].vpc_id", want: []interface{}{"vpc-0b2"}},
+		{name: "function with expression reference", expression: "sort_by(subnets, &utilization_percent)[-1].subnet_id", want: "subnet-0a2"},
+		{name: "pipe", expression: "subnets[?vpc_id == 'vpc-0a1'] | length(@)", want: 2.0},
+		{name: "scan time", expression: "scanned_at", want: "2026-03-01T12:00:00Z"},
+		{name: "missing collection", expression: "nat_gateways[].nat_gateway_id", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, result := run(t, tt.expression)
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("result = %#v\nwant %#v", result, tt.want)
+			}
+			if !reflect.DeepEqual(q.Columns, tt.wantColumns) {
+				t.Errorf("columns = %q, want %q", q.Columns, tt.wantColumns)
+			}
+		})
+	}
+}
+
+// TestCompileErrors checks that typos are rejected with their position and suggestions instead of giving
+// empty results
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantOffset int
+		wantMsg    string
+	}{
+		{"subnet[].cidr_block", 0, `unknown collection "subnet"; did you mean "subnets"?`},
+		{"subnets[].cidr", 10, `unknown field "cidr" in subnets[]; did you mean "cidr_block"?`},
+		{"subnets[?utilization > `70`].subnet_id", 9, `unknown field "utilization" in subnets[]; did you mean "utilization_percent"?`},
+		{"security_groups[].rules[].form_port", 26, `unknown field "form_port" in security_groups[].rules[]; did you mean "from_port" or "to_port"?`},
+		{"subnets[].zzz", 10, `unknown field "zzz" in subnets[]; run "aws-documentor query -schema" to list the fields`},
+		{"subnets.cidr_block", 8, `subnets is a list; project it to look up "cidr_block" in each element: subnets[].cidr_block`},
+		{"subnets[].cidr_block.size", 21, `subnets[].cidr_block is a string and has no field "size"`},
+		{"scanned_at[*].x", 0, "scanned_at is a string, not a list, so it cannot be projected with []"},
+		{"lenght(vpcs)", 0, `unknown function lenght(); did you mean "length"?`},
+		{"sort_by(subnets, &cidr)", 18, `unknown field "cidr" in subnets[]; did you mean "cidr_block"?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Compile(tt.expression, JMESPath())
+			var checkErr *CheckError
+			if !errors.As(err, &checkErr) {
+				t.Fatalf("Compile() = %v, want a check error", err)
+			}
+			if checkErr.Message != tt.wantMsg || checkErr.Offset != tt.wantOffset {
+				t.Errorf("error = %q at %d, want %q at %d", checkErr.Message, checkErr.Offset, tt.wantMsg, tt.wantOffset)
+			}
+			if caret := "\n  " + tt.expression + "\n  " + strings.Repeat(" ", tt.wantOffset) + "^"; !strings.HasSuffix(err.Error(), caret) {
+				t.Errorf("Error() = %q, want the expression with a caret below the problem", err.Error())
+			}
+		})
+	}
+
+	_, err := Compile("subnets[?", JMESPath())
+	var checkErr *CheckError
+	if !errors.As(err, &checkErr) || checkErr.Expression != "subnets[?" {
+		t.Errorf("Compile() of a syntax error = %v, want a check error", err)
+	}
+}
+
+// TestWrite checks the table and CSV layout of objects, values and nested lists
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		format     Format
+		want       string
+	}{
+		{name: "objects in expression order", format: FormatCSV,
+			expression: "subnets[?vpc_id == 'vpc-0a1'].{id: subnet_id, cidr: cidr_block, public: map_public_ip_on_launch}",
+			want:       "id,cidr,public\nsubnet-0a1,10.0.1.0/24,true\nsubnet-0a2,10.0.2.0/24,false\n"},
+		{name: "values", format: FormatCSV, expression: "subnets[].utilization_percent",
+			want: "value\n82.5\n91\n12\n"},
+		{name: "nested lists joined", format: FormatCSV, expression: "security_groups[].{group: group_id, ports: rules[].from_port}",
+			want: "group,ports\nsg-0web,443;22\nsg-0db,5432\n"},
+		{name: "nested objects as JSON", format: FormatCSV, expression: "security_groups[?group_id == 'sg-0db'].{group: group_id, rules: rules[].{from: from_port}}",
+			want: "group,rules\nsg-0db,\"[{\"\"from\"\":5432}]\"\n"},
+		{name: "single object with sorted keys", format: FormatTable, expression: "vpcs[0].tags",
+			want: "Name\nprod\n"},
+		{name: "table", format: FormatTable, expression: "vpcs[].{id: vpc_id, cidr: cidr_block}",
+			want: "id       cidr\nvpc-0a1  10.0.0.0/16\nvpc-0b2  10.1.0.0/16\n"},
+		{name: "empty result", format: FormatCSV, expression: "nat_gateways", want: "value\n"},
+		{name: "JSON", format: FormatJSON, expression: "vpcs[0].{id: vpc_id}", want: "{\n  \"id\": \"vpc-0a1\"\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, result := run(t, tt.expression)
+			var buf bytes.Buffer
+			if err := Write(&buf, result, q.Columns, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+// TestParseFormat checks the accepted -format values
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"json", "TABLE", "csv"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("ParseFormat(%q) = %v", s, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("ParseFormat(yaml) = nil, want an error")
+	}
+}
+
+// TestWriteSchema checks that the schema lists every collection with the fields Compile accepts
+func TestWriteSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	schema := buf.String()
+	for _, want := range []string{
+		"\nscanned_at ", "\nvpcs[]\n", "\n  cidr_block ", "string\n", "\nsubnets[]\n", "\n  utilization_percent ", "number\n",
+		"\nsecurity_groups[]\n", "\n  rules[]\n", "\n    from_port ", "\n  tags ", "object of string (any key)\n", "\ntgw_attachments[]\n",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("schema does not contain %q", want)
+		}
+	}
+}
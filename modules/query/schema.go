@@ -0,0 +1,80 @@
+package query
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteSchema lists the collections of the report and the fields of their elements
+// The list is generated from the Report types, so it always matches what Compile accepts.
+// Lists of objects are shown as name[] with their fields indented below them.
+// w: Destination of the listing
+// Returns: Error if writing fails
+func WriteSchema(w io.Writer) error {
+	return WriteTypeSchema(w, "Collections of a report and the fields of their elements:", reflect.TypeOf(Report{}))
+}
+
+// WriteTypeSchema lists the JSON fields of another view of a report, such as the data of -template
+// w: Destination of the listing
+// title: First line of the listing
+// t: Struct type whose fields are listed
+// Returns: Error if writing fails
+func WriteTypeSchema(w io.Writer, title string, t reflect.Type) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, title)
+	writeFields(tw, t, 0)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Objects and lists have no type column, which tabwriter would still pad with spaces
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFields lists the fields of a struct, descending into nested objects and lists of objects
+func writeFields(w io.Writer, t reflect.Type, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if !field.IsExported() || name == "-" || name == "" {
+			continue
+		}
+
+		fieldType := deref(field.Type)
+		switch {
+		case fieldType.Kind() == reflect.Slice && deref(fieldType.Elem()).Kind() == reflect.Struct:
+			fmt.Fprintf(w, "%s%s[]\t\n", indent, name)
+			writeFields(w, deref(fieldType.Elem()), depth+1)
+		case fieldType.Kind() == reflect.Struct:
+			fmt.Fprintf(w, "%s%s\t\n", indent, name)
+			writeFields(w, fieldType, depth+1)
+		default:
+			fmt.Fprintf(w, "%s%s\t%s\n", indent, name, schemaType(fieldType))
+		}
+	}
+}
+
+// schemaType describes a field type in the schema listing
+func schemaType(t reflect.Type) string {
+	t = deref(t)
+	switch t.Kind() {
+	case reflect.Slice:
+		return "list of " + schemaType(t.Elem())
+	case reflect.Map:
+		return "object of " + schemaType(t.Elem()) + " (any key)"
+	case reflect.Struct:
+		return "object"
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(typeName(t), "a "), "an ")
+}
@@ -0,0 +1,314 @@
+// Package replication provides functionality for scanning cross-region DR replication and the network paths it relies on
+package replication
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Replication kinds reported in Relationship.Kind
+const (
+	KindRDSReadReplica = "rds-read-replica" // Cross-region RDS read replica of a source DB instance
+	KindEFSReplication = "efs-replication"  // EFS replication configuration to a file system in another region
+)
+
+// Network paths reported in Relationship.NetworkPath
+const (
+	PathPeering        = "peering"         // An active VPC peering connection joins the source and target VPCs
+	PathTransitGateway = "transit-gateway" // The VPCs are attached to the same or to peered transit gateways
+	PathUnknown        = "unknown"         // Either VPC is unknown, or nothing scanned connects them
+)
+
+// Endpoint is one side of a replication relationship
+type Endpoint struct {
+	ResourceID string   `json:"resource_id"` // ARN of the DB instance, or ID of the file system
+	Region     string   `json:"region"`      // Region of the resource
+	VpcID      string   `json:"vpc_id"`      // VPC the resource is placed in (empty if that region was not scanned)
+	SubnetIDs  []string `json:"subnet_ids"`  // Subnets of the DB subnet group or of the mount targets
+}
+
+// Relationship is a replication link between two regions
+type Relationship struct {
+	Kind        string   `json:"kind"`         // rds-read-replica or efs-replication
+	Source      Endpoint `json:"source"`       // Primary resource
+	Target      Endpoint `json:"target"`       // Replica resource
+	Status      string   `json:"status"`       // Replica or replication status as reported by the service
+	NetworkPath string   `json:"network_path"` // peering, transit-gateway or unknown
+	Via         string   `json:"via"`          // Peering connection or transit gateway (peering) attachment ID of the path
+}
+
+// Scanner provides methods for retrieving replication relationships in one region
+type Scanner struct {
+	region    string      // Region the clients are configured for
+	rdsClient *rds.Client // Amazon RDS client for making API calls
+	efsClient *efs.Client // Amazon EFS client for making API calls
+}
+
+// NewScanner creates a new replication scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials and region information
+func NewScanner(cfg aws.Config) *Scanner {
+	return &Scanner{
+		region:    cfg.Region,
+		rdsClient: rds.NewFromConfig(cfg),
+		efsClient: efs.NewFromConfig(cfg),
+	}
+}
+
+// GetRDSReplicas retrieves the cross-region read replica links of the DB instances in the region
+// A region sees only its own side of a link: replicas name their source, sources list their replicas.
+// Scanning both regions and combining the results with Merge fills in both VPCs.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: One relationship per cross-region source/replica pair, or error if the operation fails
+func (s *Scanner) GetRDSReplicas(ctx context.Context) ([]Relationship, error) {
+	relationships := []Relationship{}
+
+	paginator := rds.NewDescribeDBInstancesPaginator(s.rdsClient, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("rds", "DB instances", "DescribeDBInstances", err)
+		}
+
+		for _, db := range result.DBInstances {
+			local := Endpoint{ResourceID: aws.ToString(db.DBInstanceArn), Region: s.region, SubnetIDs: []string{}}
+			if db.DBSubnetGroup != nil {
+				local.VpcID = aws.ToString(db.DBSubnetGroup.VpcId)
+				for _, subnet := range db.DBSubnetGroup.Subnets {
+					local.SubnetIDs = append(local.SubnetIDs, aws.ToString(subnet.SubnetIdentifier))
+				}
+			}
+
+			// Same-region replicas are named by identifier, cross-region ones by ARN
+			if source := aws.ToString(db.ReadReplicaSourceDBInstanceIdentifier); arnRegion(source) != "" && arnRegion(source) != s.region {
+				relationships = append(relationships, Relationship{
+					Kind:   KindRDSReadReplica,
+					Source: Endpoint{ResourceID: source, Region: arnRegion(source), SubnetIDs: []string{}},
+					Target: local,
+					Status: replicaStatus(db.StatusInfos),
+				})
+			}
+			for _, replica := range db.ReadReplicaDBInstanceIdentifiers {
+				if arnRegion(replica) == "" || arnRegion(replica) == s.region {
+					continue
+				}
+				relationships = append(relationships, Relationship{
+					Kind:   KindRDSReadReplica,
+					Source: local,
+					Target: Endpoint{ResourceID: replica, Region: arnRegion(replica), SubnetIDs: []string{}},
+				})
+			}
+		}
+	}
+
+	return relationships, nil
+}
+
+// GetEFSReplications retrieves the replication configurations of the file systems in the region
+// The VPC of a file system in this region is taken from its mount targets.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: One relationship per replication destination, or error if the operation fails
+func (s *Scanner) GetEFSReplications(ctx context.Context) ([]Relationship, error) {
+	relationships := []Relationship{}
+
+	input := &efs.DescribeReplicationConfigurationsInput{}
+	for {
+		result, err := s.efsClient.DescribeReplicationConfigurations(ctx, input)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("elasticfilesystem", "EFS replication configurations", "DescribeReplicationConfigurations", err)
+		}
+
+		for _, configuration := range result.Replications {
+			for _, destination := range configuration.Destinations {
+				relationship := Relationship{
+					Kind: KindEFSReplication,
+					Source: Endpoint{
+						ResourceID: aws.ToString(configuration.SourceFileSystemId),
+						Region:     aws.ToString(configuration.SourceFileSystemRegion),
+						SubnetIDs:  []string{},
+					},
+					Target: Endpoint{
+						ResourceID: aws.ToString(destination.FileSystemId),
+						Region:     aws.ToString(destination.Region),
+						SubnetIDs:  []string{},
+					},
+					Status: string(destination.Status),
+				}
+				for _, side := range []*Endpoint{&relationship.Source, &relationship.Target} {
+					if side.Region != s.region {
+						continue
+					}
+					if err := s.placeFileSystem(ctx, side); err != nil {
+						return nil, err
+					}
+				}
+				relationships = append(relationships, relationship)
+			}
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return relationships, nil
+}
+
+// placeFileSystem fills in the VPC and subnets of a file system from its mount targets
+func (s *Scanner) placeFileSystem(ctx context.Context, side *Endpoint) error {
+	input := &efs.DescribeMountTargetsInput{FileSystemId: aws.String(side.ResourceID)}
+	for {
+		result, err := s.efsClient.DescribeMountTargets(ctx, input)
+		if err != nil {
+			return vpc.NewServiceScanError("elasticfilesystem", "mount targets of "+side.ResourceID, "DescribeMountTargets", err)
+		}
+		for _, target := range result.MountTargets {
+			side.VpcID = aws.ToString(target.VpcId)
+			side.SubnetIDs = append(side.SubnetIDs, aws.ToString(target.SubnetId))
+		}
+		if result.NextMarker == nil {
+			return nil
+		}
+		input.Marker = result.NextMarker
+	}
+}
+
+// Merge combines the relationships seen from several regions into one entry per link
+// Each side's VPC, subnets and the status are taken from whichever region reported them.
+// relationships: Relationships from the scanned regions, in any order
+// Returns: Deduplicated relationships sorted by kind and source
+func Merge(relationships ...[]Relationship) []Relationship {
+	merged := []Relationship{}
+	byKey := make(map[string]int)
+
+	for _, list := range relationships {
+		for _, relationship := range list {
+			key := relationship.Kind + "|" + relationship.Source.ResourceID + "|" + relationship.Target.ResourceID
+			i, ok := byKey[key]
+			if !ok {
+				byKey[key] = len(merged)
+				merged = append(merged, relationship)
+				continue
+			}
+			existing := &merged[i]
+			fillEndpoint(&existing.Source, relationship.Source)
+			fillEndpoint(&existing.Target, relationship.Target)
+			if existing.Status == "" {
+				existing.Status = relationship.Status
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Kind != merged[j].Kind {
+			return merged[i].Kind < merged[j].Kind
+		}
+		return merged[i].Source.ResourceID < merged[j].Source.ResourceID
+	})
+	return merged
+}
+
+// ClassifyPaths sets the network path of every relationship from the connectivity between its VPCs
+// Replication traffic itself is carried by the service; the path documents which network pieces
+// join the primary and DR VPCs, so they can be recognized as existing for DR.
+// relationships: Merged relationships, updated in place
+// peerings: VPC peering connections from the scanned regions
+// attachments: Transit gateway attachments from the scanned regions
+func ClassifyPaths(relationships []Relationship, peerings []vpc.VpcPeeringConnectionInfo, attachments []vpc.TransitGatewayAttachmentInfo) {
+	// Transit gateways each VPC is attached to, and the peering attachments between gateways
+	vpcGateways := make(map[string][]string)
+	for _, attachment := range attachments {
+		if attachment.ResourceType == "vpc" && attachment.State == "available" {
+			vpcGateways[attachment.ResourceID] = append(vpcGateways[attachment.ResourceID], attachment.TransitGatewayID)
+		}
+	}
+
+	for i := range relationships {
+		relationship := &relationships[i]
+		relationship.NetworkPath, relationship.Via = PathUnknown, ""
+		src, dst := relationship.Source.VpcID, relationship.Target.VpcID
+		if src == "" || dst == "" {
+			continue
+		}
+
+		for _, pcx := range peerings {
+			if pcx.Status != "active" {
+				continue
+			}
+			if (pcx.RequesterVpcID == src && pcx.AccepterVpcID == dst) || (pcx.RequesterVpcID == dst && pcx.AccepterVpcID == src) {
+				relationship.NetworkPath, relationship.Via = PathPeering, pcx.VpcPeeringConnectionID
+				break
+			}
+		}
+		if relationship.NetworkPath != PathUnknown {
+			continue
+		}
+
+		if via, ok := gatewayPath(vpcGateways[src], vpcGateways[dst], attachments); ok {
+			relationship.NetworkPath, relationship.Via = PathTransitGateway, via
+		}
+	}
+}
+
+// gatewayPath finds a transit gateway shared by both VPCs, or a peering attachment between their gateways
+// Returns: The shared gateway or the peering attachment ID, and whether one was found
+func gatewayPath(srcGateways, dstGateways []string, attachments []vpc.TransitGatewayAttachmentInfo) (string, bool) {
+	for _, s := range srcGateways {
+		for _, d := range dstGateways {
+			if s == d {
+				return s, true
+			}
+		}
+	}
+	for _, attachment := range attachments {
+		if attachment.ResourceType != "peering" || attachment.State != "available" {
+			continue
+		}
+		for _, s := range srcGateways {
+			for _, d := range dstGateways {
+				if (attachment.TransitGatewayID == s && attachment.ResourceID == d) || (attachment.TransitGatewayID == d && attachment.ResourceID == s) {
+					return attachment.AttachmentID, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// fillEndpoint copies the placement of an endpoint when the existing entry lacks it
+func fillEndpoint(existing *Endpoint, other Endpoint) {
+	if existing.VpcID == "" && other.VpcID != "" {
+		existing.VpcID = other.VpcID
+		existing.SubnetIDs = other.SubnetIDs
+	}
+	if existing.Region == "" {
+		existing.Region = other.Region
+	}
+}
+
+// arnRegion returns the region field of an ARN, or an empty string for anything that is not an ARN
+func arnRegion(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
+
+// replicaStatus returns the read replication status of a replica (replicating, error, stopped, ...)
+func replicaStatus(infos []rdstypes.DBInstanceStatusInfo) string {
+	for _, info := range infos {
+		if aws.ToString(info.StatusType) == "read replication" {
+			return aws.ToString(info.Status)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,223 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/vpc"
+)
+
+// newTestScanner returns a scanner for a region whose RDS and EFS endpoints answer from responses
+// RDS requests are keyed by action; EFS requests by path, followed by the file system ID of mount target requests.
+// Requests without an entry get an empty result.
+// region: Region of the scanner
+// responses: DescribeDBInstances result elements, or EFS response bodies
+func newTestScanner(t *testing.T, region string, responses map[string]string) *Scanner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if action := r.Form.Get("Action"); action != "" {
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<%sResponse><%sResult>%s</%sResult><ResponseMetadata><RequestId>req-1</RequestId></ResponseMetadata></%sResponse>`,
+				action, action, responses[action], action, action)
+			return
+		}
+		key := r.URL.Path
+		if id := r.URL.Query().Get("FileSystemId"); id != "" {
+			key += " " + id
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if body, ok := responses[key]; ok {
+			fmt.Fprint(w, body)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewScanner(aws.Config{
+		Region:       region,
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+}
+
+// dbInstance returns a DBInstance element of a DescribeDBInstances result
+func dbInstance(arn, vpcID string, subnetIDs []string, extra string) string {
+	subnets := ""
+	for _, id := range subnetIDs {
+		subnets += fmt.Sprintf("<Subnet><SubnetIdentifier>%s</SubnetIdentifier></Subnet>", id)
+	}
+	return fmt.Sprintf(`<DBInstance><DBInstanceArn>%s</DBInstanceArn><DBSubnetGroup><VpcId>%s</VpcId><Subnets>%s</Subnets></DBSubnetGroup>%s</DBInstance>`,
+		arn, vpcID, subnets, extra)
+}
+
+const (
+	ordersARN   = "arn:aws:rds:eu-west-1:111122223333:db:orders"
+	ordersDRARN = "arn:aws:rds:us-east-1:111122223333:db:orders-dr"
+	reportsARN  = "arn:aws:rds:eu-west-1:111122223333:db:reports"
+	reportsDR   = "arn:aws:rds:ap-southeast-2:111122223333:db:reports-dr"
+)
+
+// primaryResponses is the primary region: orders has a replica in us-east-1 and one in the same region,
+// reports has a replica in a region that is not scanned, and fs-0prim replicates to us-east-1
+var primaryResponses = map[string]string{
+	"DescribeDBInstances": `<DBInstances>` +
+		dbInstance(ordersARN, "vpc-0prim", []string{"subnet-0p1", "subnet-0p2"},
+			`<ReadReplicaDBInstanceIdentifiers><ReadReplicaDBInstanceIdentifier>`+ordersDRARN+`</ReadReplicaDBInstanceIdentifier>
+			<ReadReplicaDBInstanceIdentifier>orders-local</ReadReplicaDBInstanceIdentifier></ReadReplicaDBInstanceIdentifiers>`) +
+		dbInstance("arn:aws:rds:eu-west-1:111122223333:db:orders-local", "vpc-0prim", []string{"subnet-0p1"},
+			`<ReadReplicaSourceDBInstanceIdentifier>orders</ReadReplicaSourceDBInstanceIdentifier>`) +
+		dbInstance(reportsARN, "vpc-0prim", []string{"subnet-0p1"},
+			`<ReadReplicaDBInstanceIdentifiers><ReadReplicaDBInstanceIdentifier>`+reportsDR+`</ReadReplicaDBInstanceIdentifier></ReadReplicaDBInstanceIdentifiers>`) +
+		`</DBInstances>`,
+	"/2015-02-01/file-systems/replication-configurations": `{"Replications": [{"SourceFileSystemId": "fs-0prim", "SourceFileSystemRegion": "eu-west-1",
+		"Destinations": [{"FileSystemId": "fs-0dr", "Region": "us-east-1", "Status": "ENABLED"}]}]}`,
+	"/2015-02-01/mount-targets fs-0prim": `{"MountTargets": [{"MountTargetId": "fsmt-01", "FileSystemId": "fs-0prim", "SubnetId": "subnet-0p1", "VpcId": "vpc-0prim", "LifeCycleState": "available"},
+		{"MountTargetId": "fsmt-02", "FileSystemId": "fs-0prim", "SubnetId": "subnet-0p2", "VpcId": "vpc-0prim", "LifeCycleState": "available"}]}`,
+}
+
+// replicaResponses is the DR region: orders-dr names its source, and the EFS replication is listed from the destination side
+var replicaResponses = map[string]string{
+	"DescribeDBInstances": `<DBInstances>` +
+		dbInstance(ordersDRARN, "vpc-0dr", []string{"subnet-0d1"},
+			`<ReadReplicaSourceDBInstanceIdentifier>`+ordersARN+`</ReadReplicaSourceDBInstanceIdentifier>
+			<StatusInfos><DBInstanceStatusInfo><StatusType>read replication</StatusType><Status>replicating</Status><Normal>true</Normal></DBInstanceStatusInfo></StatusInfos>`) +
+		`</DBInstances>`,
+	"/2015-02-01/file-systems/replication-configurations": `{"Replications": [{"SourceFileSystemId": "fs-0prim", "SourceFileSystemRegion": "eu-west-1",
+		"Destinations": [{"FileSystemId": "fs-0dr", "Region": "us-east-1", "Status": "ENABLED"}]}]}`,
+	"/2015-02-01/mount-targets fs-0dr": `{"MountTargets": [{"MountTargetId": "fsmt-03", "FileSystemId": "fs-0dr", "SubnetId": "subnet-0d1", "VpcId": "vpc-0dr", "LifeCycleState": "available"}]}`,
+}
+
+// scanRegion returns the RDS and EFS relationships one region reports
+func scanRegion(t *testing.T, region string, responses map[string]string) []Relationship {
+	scanner := newTestScanner(t, region, responses)
+	replicas, err := scanner.GetRDSReplicas(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replications, err := scanner.GetEFSReplications(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(replicas, replications...)
+}
+
+// TestScanRegions checks the side of each cross-region link a single region sees
+func TestScanRegions(t *testing.T) {
+	tests := []struct {
+		name      string
+		region    string
+		responses map[string]string
+		want      []Relationship
+	}{
+		{
+			name:      "primary",
+			region:    "eu-west-1",
+			responses: primaryResponses,
+			want: []Relationship{
+				{Kind: KindRDSReadReplica,
+					Source: Endpoint{ResourceID: ordersARN, Region: "eu-west-1", VpcID: "vpc-0prim", SubnetIDs: []string{"subnet-0p1", "subnet-0p2"}},
+					Target: Endpoint{ResourceID: ordersDRARN, Region: "us-east-1", SubnetIDs: []string{}}},
+				{Kind: KindRDSReadReplica,
+					Source: Endpoint{ResourceID: reportsARN, Region: "eu-west-1", VpcID: "vpc-0prim", SubnetIDs: []string{"subnet-0p1"}},
+					Target: Endpoint{ResourceID: reportsDR, Region: "ap-southeast-2", SubnetIDs: []string{}}},
+				{Kind: KindEFSReplication, Status: "ENABLED",
+					Source: Endpoint{ResourceID: "fs-0prim", Region: "eu-west-1", VpcID: "vpc-0prim", SubnetIDs: []string{"subnet-0p1", "subnet-0p2"}},
+					Target: Endpoint{ResourceID: "fs-0dr", Region: "us-east-1", SubnetIDs: []string{}}},
+			},
+		},
+		{
+			name:      "replica",
+			region:    "us-east-1",
+			responses: replicaResponses,
+			want: []Relationship{
+				{Kind: KindRDSReadReplica, Status: "replicating",
+					Source: Endpoint{ResourceID: ordersARN, Region: "eu-west-1", SubnetIDs: []string{}},
+					Target: Endpoint{ResourceID: ordersDRARN, Region: "us-east-1", VpcID: "vpc-0dr", SubnetIDs: []string{"subnet-0d1"}}},
+				{Kind: KindEFSReplication, Status: "ENABLED",
+					Source: Endpoint{ResourceID: "fs-0prim", Region: "eu-west-1", SubnetIDs: []string{}},
+					Target: Endpoint{ResourceID: "fs-0dr", Region: "us-east-1", VpcID: "vpc-0dr", SubnetIDs: []string{"subnet-0d1"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanRegion(t, tt.region, tt.responses); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("relationships = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeAndClassify checks that a primary/replica pair scanned from both regions becomes one link over the
+// peering connection between their VPCs, and that a replica in a region that was not scanned has an unknown path
+func TestMergeAndClassify(t *testing.T) {
+	relationships := Merge(scanRegion(t, "eu-west-1", primaryResponses), scanRegion(t, "us-east-1", replicaResponses))
+	peerings := []vpc.VpcPeeringConnectionInfo{
+		{VpcPeeringConnectionID: "pcx-0old", RequesterVpcID: "vpc-0prim", AccepterVpcID: "vpc-0dr", Status: "deleted"},
+		{VpcPeeringConnectionID: "pcx-0dr", RequesterVpcID: "vpc-0dr", RequesterRegion: "us-east-1",
+			AccepterVpcID: "vpc-0prim", AccepterRegion: "eu-west-1", Status: "active"},
+	}
+	ClassifyPaths(relationships, peerings, nil)
+
+	want := []Relationship{
+		{Kind: KindEFSReplication, Status: "ENABLED", NetworkPath: PathPeering, Via: "pcx-0dr",
+			Source: Endpoint{ResourceID: "fs-0prim", Region: "eu-west-1", VpcID: "vpc-0prim", SubnetIDs: []string{"subnet-0p1", "subnet-0p2"}},
+			Target: Endpoint{ResourceID: "fs-0dr", Region: "us-east-1", VpcID: "vpc-0dr", SubnetIDs: []string{"subnet-0d1"}}},
+		{Kind: KindRDSReadReplica, Status: "replicating", NetworkPath: PathPeering, Via: "pcx-0dr",
+			Source: Endpoint{ResourceID: ordersARN, Region: "eu-west-1", VpcID: "vpc-0prim", SubnetIDs: []string{"subnet-0p1", "subnet-0p2"}},
+			Target: Endpoint{ResourceID: ordersDRARN, Region: "us-east-1", VpcID: "vpc-0dr", SubnetIDs: []string{"subnet-0d1"}}},
+		{Kind: KindRDSReadReplica, NetworkPath: PathUnknown,
+			Source: Endpoint{ResourceID: reportsARN, Region: "eu-west-1", VpcID: "vpc-0prim", SubnetIDs: []string{"subnet-0p1"}},
+			Target: Endpoint{ResourceID: reportsDR, Region: "ap-southeast-2", SubnetIDs: []string{}}},
+	}
+	if !reflect.DeepEqual(relationships, want) {
+		t.Errorf("relationships = %+v\nwant %+v", relationships, want)
+	}
+}
+
+// TestClassifyTransitGatewayPaths covers VPCs on a shared transit gateway, on peered gateways and on unconnected gateways
+func TestClassifyTransitGatewayPaths(t *testing.T) {
+	attachment := func(id, tgwID, resourceType, resourceID, state string) vpc.TransitGatewayAttachmentInfo {
+		return vpc.TransitGatewayAttachmentInfo{AttachmentID: id, TransitGatewayID: tgwID, ResourceType: resourceType, ResourceID: resourceID, State: state}
+	}
+	attachments := []vpc.TransitGatewayAttachmentInfo{
+		attachment("tgw-attach-01", "tgw-0eu", "vpc", "vpc-0a1", "available"),
+		attachment("tgw-attach-02", "tgw-0eu", "vpc", "vpc-0b2", "available"),
+		attachment("tgw-attach-03", "tgw-0us", "vpc", "vpc-0c3", "available"),
+		attachment("tgw-attach-04", "tgw-0eu", "peering", "tgw-0us", "available"),
+		attachment("tgw-attach-05", "tgw-0ap", "vpc", "vpc-0d4", "available"),
+		attachment("tgw-attach-06", "tgw-0ap", "peering", "tgw-0eu", "pendingAcceptance"),
+		attachment("tgw-attach-07", "tgw-0eu", "vpc", "vpc-0e5", "deleted"),
+	}
+	tests := []struct {
+		name     string
+		src, dst string
+		wantPath string
+		wantVia  string
+	}{
+		{name: "shared gateway", src: "vpc-0a1", dst: "vpc-0b2", wantPath: PathTransitGateway, wantVia: "tgw-0eu"},
+		{name: "peered gateways", src: "vpc-0c3", dst: "vpc-0a1", wantPath: PathTransitGateway, wantVia: "tgw-attach-04"},
+		{name: "peering not accepted", src: "vpc-0a1", dst: "vpc-0d4", wantPath: PathUnknown},
+		{name: "deleted attachment", src: "vpc-0a1", dst: "vpc-0e5", wantPath: PathUnknown},
+		{name: "VPC not scanned", src: "vpc-0a1", dst: "", wantPath: PathUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relationships := []Relationship{{Source: Endpoint{VpcID: tt.src}, Target: Endpoint{VpcID: tt.dst}, NetworkPath: "stale", Via: "stale"}}
+			ClassifyPaths(relationships, nil, attachments)
+			if got := relationships[0]; got.NetworkPath != tt.wantPath || got.Via != tt.wantVia {
+				t.Errorf("path = %s via %q, want %s via %q", got.NetworkPath, got.Via, tt.wantPath, tt.wantVia)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package report
+
+import (
+	"time"
+
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/privateapi"
+	"aws-documentor/modules/vpc"
+)
+
+// ResourceDiff is a resource that exists in both scans with different attributes
+type ResourceDiff[T any] struct {
+	ResourceID string   `json:"resource_id"` // ID of the resource
+	Name       string   `json:"name"`        // Name tag or name, falling back to the ID
+	Fields     []string `json:"fields"`      // JSON fields whose values differ
+	Before     T        `json:"before"`      // The resource in the earlier scan
+	After      T        `json:"after"`       // The resource in the later scan
+}
+
+// Modified resources of each type
+type (
+	VPCDiff              = ResourceDiff[vpc.VPCInfo]
+	SubnetDiff           = ResourceDiff[vpc.SubnetInfo]
+	RouteTableDiff       = ResourceDiff[vpc.RouteTableInfo]
+	SecurityGroupDiff    = ResourceDiff[vpc.SecurityGroupInfo]
+	InternetGatewayDiff  = ResourceDiff[vpc.InternetGatewayInfo]
+	NatGatewayDiff       = ResourceDiff[vpc.NatGatewayInfo]
+	RouteApplianceDiff   = ResourceDiff[vpc.RouteApplianceInfo]
+	TransitGatewayDiff   = ResourceDiff[vpc.TransitGatewayInfo]
+	TGWAttachmentDiff    = ResourceDiff[vpc.TransitGatewayAttachmentInfo]
+	VpcPeeringDiff       = ResourceDiff[vpc.VpcPeeringConnectionInfo]
+	NetworkACLDiff       = ResourceDiff[vpc.NetworkACLInfo]
+	CarrierGatewayDiff   = ResourceDiff[vpc.CarrierGatewayInfo]
+	VpnGatewayDiff       = ResourceDiff[vpc.VpnGatewayInfo]
+	CustomerGatewayDiff  = ResourceDiff[vpc.CustomerGatewayInfo]
+	VpnConnectionDiff    = ResourceDiff[vpc.VpnConnectionInfo]
+	DhcpOptionsDiff      = ResourceDiff[vpc.DhcpOptionsInfo]
+	NetworkInterfaceDiff = ResourceDiff[vpc.NetworkInterfaceInfo]
+	InstanceDiff         = ResourceDiff[vpc.InstanceInfo]
+	FlowLogDiff          = ResourceDiff[vpc.FlowLogInfo]
+	ElasticIPDiff        = ResourceDiff[vpc.ElasticIPInfo]
+	VpcEndpointDiff      = ResourceDiff[vpc.VpcEndpointInfo]
+	AutoScalingGroupDiff = ResourceDiff[asg.AutoScalingGroupInfo]
+	DirectoryDiff        = ResourceDiff[directory.DirectoryInfo]
+	CoreNetworkDiff      = ResourceDiff[cloudwan.CoreNetworkInfo]
+	PrivateAPIDiff       = ResourceDiff[privateapi.PrivateAPIInfo]
+)
+
+// Change is one added, removed or modified resource of a ScanDiff, whatever its type
+type Change struct {
+	Section    string      // Section of the scan result the resource is in (vpcs, subnets, ...)
+	Change     string      // added, removed or modified
+	ResourceID string      // ID of the resource
+	Name       string      // Name tag or name, falling back to the ID
+	Fields     []string    // JSON fields whose values differ (modified resources)
+	Before     interface{} // The resource in the earlier scan (nil if added)
+	After      interface{} // The resource in the later scan (nil if removed)
+}
+
+// ScanDiff lists the resources added, removed and modified between two scan results
+// Resources are matched by ID and listed by ID within each type. Sections that are null in either
+// scan, as those of optional scans that did not run, are not compared and are listed in SkippedSections.
+type ScanDiff struct {
+	BeforeScanTime            string                             `json:"before_scan_time"`             // When the earlier scan started
+	AfterScanTime             string                             `json:"after_scan_time"`              // When the later scan started
+	Region                    string                             `json:"region"`                       // Region of the later scan
+	AccountID                 string                             `json:"account_id"`                   // Account of the later scan (empty if unknown)
+	SkippedSections           []string                           `json:"skipped_sections"`             // Sections not compared because a scan has none
+	AddedVPCs                 []vpc.VPCInfo                      `json:"added_vpcs"`                   // VPCs only in the later scan
+	RemovedVPCs               []vpc.VPCInfo                      `json:"removed_vpcs"`                 // VPCs only in the earlier scan
+	ModifiedVPCs              []VPCDiff                          `json:"modified_vpcs"`                // VPCs in both scans with different attributes
+	AddedSubnets              []vpc.SubnetInfo                   `json:"added_subnets"`                // Subnets only in the later scan
+	RemovedSubnets            []vpc.SubnetInfo                   `json:"removed_subnets"`              // Subnets only in the earlier scan
+	ModifiedSubnets           []SubnetDiff                       `json:"modified_subnets"`             // Subnets in both scans with different attributes
+	AddedRouteTables          []vpc.RouteTableInfo               `json:"added_route_tables"`           // Route tables only in the later scan
+	RemovedRouteTables        []vpc.RouteTableInfo               `json:"removed_route_tables"`         // Route tables only in the earlier scan
+	ModifiedRouteTables       []RouteTableDiff                   `json:"modified_route_tables"`        // Route tables in both scans with different attributes
+	AddedSecurityGroups       []vpc.SecurityGroupInfo            `json:"added_security_groups"`        // Security groups only in the later scan
+	RemovedSecurityGroups     []vpc.SecurityGroupInfo            `json:"removed_security_groups"`      // Security groups only in the earlier scan
+	ModifiedSecurityGroups    []SecurityGroupDiff                `json:"modified_security_groups"`     // Security groups in both scans with different attributes
+	AddedInternetGateways     []vpc.InternetGatewayInfo          `json:"added_internet_gateways"`      // Internet gateways only in the later scan
+	RemovedInternetGateways   []vpc.InternetGatewayInfo          `json:"removed_internet_gateways"`    // Internet gateways only in the earlier scan
+	ModifiedInternetGateways  []InternetGatewayDiff              `json:"modified_internet_gateways"`   // Internet gateways in both scans with different attributes
+	AddedNatGateways          []vpc.NatGatewayInfo               `json:"added_nat_gateways"`           // NAT gateways only in the later scan
+	RemovedNatGateways        []vpc.NatGatewayInfo               `json:"removed_nat_gateways"`         // NAT gateways only in the earlier scan
+	ModifiedNatGateways       []NatGatewayDiff                   `json:"modified_nat_gateways"`        // NAT gateways in both scans with different attributes
+	AddedRouteAppliances      []vpc.RouteApplianceInfo           `json:"added_route_appliances"`       // Route appliances only in the later scan
+	RemovedRouteAppliances    []vpc.RouteApplianceInfo           `json:"removed_route_appliances"`     // Route appliances only in the earlier scan
+	ModifiedRouteAppliances   []RouteApplianceDiff               `json:"modified_route_appliances"`    // Route appliances in both scans with different attributes
+	AddedTransitGateways      []vpc.TransitGatewayInfo           `json:"added_transit_gateways"`       // Transit gateways only in the later scan
+	RemovedTransitGateways    []vpc.TransitGatewayInfo           `json:"removed_transit_gateways"`     // Transit gateways only in the earlier scan
+	ModifiedTransitGateways   []TransitGatewayDiff               `json:"modified_transit_gateways"`    // Transit gateways in both scans with different attributes
+	AddedTGWAttachments       []vpc.TransitGatewayAttachmentInfo `json:"added_tgw_attachments"`        // Transit gateway attachments only in the later scan
+	RemovedTGWAttachments     []vpc.TransitGatewayAttachmentInfo `json:"removed_tgw_attachments"`      // Transit gateway attachments only in the earlier scan
+	ModifiedTGWAttachments    []TGWAttachmentDiff                `json:"modified_tgw_attachments"`     // Transit gateway attachments in both scans with different attributes
+	AddedVpcPeerings          []vpc.VpcPeeringConnectionInfo     `json:"added_vpc_peerings"`           // VPC peering connections only in the later scan
+	RemovedVpcPeerings        []vpc.VpcPeeringConnectionInfo     `json:"removed_vpc_peerings"`         // VPC peering connections only in the earlier scan
+	ModifiedVpcPeerings       []VpcPeeringDiff                   `json:"modified_vpc_peerings"`        // VPC peering connections in both scans with different attributes
+	AddedNetworkACLs          []vpc.NetworkACLInfo               `json:"added_network_acls"`           // Network ACLs only in the later scan
+	RemovedNetworkACLs        []vpc.NetworkACLInfo               `json:"removed_network_acls"`         // Network ACLs only in the earlier scan
+	ModifiedNetworkACLs       []NetworkACLDiff                   `json:"modified_network_acls"`        // Network ACLs in both scans with different attributes
+	AddedCarrierGateways      []vpc.CarrierGatewayInfo           `json:"added_carrier_gateways"`       // Carrier gateways only in the later scan
+	RemovedCarrierGateways    []vpc.CarrierGatewayInfo           `json:"removed_carrier_gateways"`     // Carrier gateways only in the earlier scan
+	ModifiedCarrierGateways   []CarrierGatewayDiff               `json:"modified_carrier_gateways"`    // Carrier gateways in both scans with different attributes
+	AddedVpnGateways          []vpc.VpnGatewayInfo               `json:"added_vpn_gateways"`           // Virtual private gateways only in the later scan
+	RemovedVpnGateways        []vpc.VpnGatewayInfo               `json:"removed_vpn_gateways"`         // Virtual private gateways only in the earlier scan
+	ModifiedVpnGateways       []VpnGatewayDiff                   `json:"modified_vpn_gateways"`        // Virtual private gateways in both scans with different attributes
+	AddedCustomerGateways     []vpc.CustomerGatewayInfo          `json:"added_customer_gateways"`      // Customer gateways only in the later scan
+	RemovedCustomerGateways   []vpc.CustomerGatewayInfo          `json:"removed_customer_gateways"`    // Customer gateways only in the earlier scan
+	ModifiedCustomerGateways  []CustomerGatewayDiff              `json:"modified_customer_gateways"`   // Customer gateways in both scans with different attributes
+	AddedVpnConnections       []vpc.VpnConnectionInfo            `json:"added_vpn_connections"`        // Site-to-Site VPN connections only in the later scan
+	RemovedVpnConnections     []vpc.VpnConnectionInfo            `json:"removed_vpn_connections"`      // Site-to-Site VPN connections only in the earlier scan
+	ModifiedVpnConnections    []VpnConnectionDiff                `json:"modified_vpn_connections"`     // Site-to-Site VPN connections in both scans with different attributes
+	AddedDhcpOptions          []vpc.DhcpOptionsInfo              `json:"added_dhcp_options"`           // DHCP options sets only in the later scan
+	RemovedDhcpOptions        []vpc.DhcpOptionsInfo              `json:"removed_dhcp_options"`         // DHCP options sets only in the earlier scan
+	ModifiedDhcpOptions       []DhcpOptionsDiff                  `json:"modified_dhcp_options"`        // DHCP options sets in both scans with different attributes
+	AddedNetworkInterfaces    []vpc.NetworkInterfaceInfo         `json:"added_network_interfaces"`     // Network interfaces only in the later scan
+	RemovedNetworkInterfaces  []vpc.NetworkInterfaceInfo         `json:"removed_network_interfaces"`   // Network interfaces only in the earlier scan
+	ModifiedNetworkInterfaces []NetworkInterfaceDiff             `json:"modified_network_interfaces"`  // Network interfaces in both scans with different attributes
+	AddedInstances            []vpc.InstanceInfo                 `json:"added_instances"`              // Instances only in the later scan
+	RemovedInstances          []vpc.InstanceInfo                 `json:"removed_instances"`            // Instances only in the earlier scan
+	ModifiedInstances         []InstanceDiff                     `json:"modified_instances"`           // Instances in both scans with different attributes
+	AddedFlowLogs             []vpc.FlowLogInfo                  `json:"added_flow_logs"`              // Flow logs only in the later scan
+	RemovedFlowLogs           []vpc.FlowLogInfo                  `json:"removed_flow_logs"`            // Flow logs only in the earlier scan
+	ModifiedFlowLogs          []FlowLogDiff                      `json:"modified_flow_logs"`           // Flow logs in both scans with different attributes
+	AddedElasticIPs           []vpc.ElasticIPInfo                `json:"added_elastic_ips"`            // Elastic IPs only in the later scan
+	RemovedElasticIPs         []vpc.ElasticIPInfo                `json:"removed_elastic_ips"`          // Elastic IPs only in the earlier scan
+	ModifiedElasticIPs        []ElasticIPDiff                    `json:"modified_elastic_ips"`         // Elastic IPs in both scans with different attributes
+	AddedVpcEndpoints         []vpc.VpcEndpointInfo              `json:"added_vpc_endpoints"`          // VPC endpoints only in the later scan
+	RemovedVpcEndpoints       []vpc.VpcEndpointInfo              `json:"removed_vpc_endpoints"`        // VPC endpoints only in the earlier scan
+	ModifiedVpcEndpoints      []VpcEndpointDiff                  `json:"modified_vpc_endpoints"`       // VPC endpoints in both scans with different attributes
+	AddedAutoScalingGroups    []asg.AutoScalingGroupInfo         `json:"added_auto_scaling_groups"`    // Auto Scaling groups only in the later scan
+	RemovedAutoScalingGroups  []asg.AutoScalingGroupInfo         `json:"removed_auto_scaling_groups"`  // Auto Scaling groups only in the earlier scan
+	ModifiedAutoScalingGroups []AutoScalingGroupDiff             `json:"modified_auto_scaling_groups"` // Auto Scaling groups in both scans with different attributes
+	AddedDirectories          []directory.DirectoryInfo          `json:"added_directories"`            // Directory Service directories only in the later scan
+	RemovedDirectories        []directory.DirectoryInfo          `json:"removed_directories"`          // Directory Service directories only in the earlier scan
+	ModifiedDirectories       []DirectoryDiff                    `json:"modified_directories"`         // Directory Service directories in both scans with different attributes
+	AddedCoreNetworks         []cloudwan.CoreNetworkInfo         `json:"added_core_networks"`          // Cloud WAN core networks only in the later scan
+	RemovedCoreNetworks       []cloudwan.CoreNetworkInfo         `json:"removed_core_networks"`        // Cloud WAN core networks only in the earlier scan
+	ModifiedCoreNetworks      []CoreNetworkDiff                  `json:"modified_core_networks"`       // Cloud WAN core networks in both scans with different attributes
+	AddedPrivateAPIs          []privateapi.PrivateAPIInfo        `json:"added_private_apis"`           // Private API Gateway REST APIs only in the later scan
+	RemovedPrivateAPIs        []privateapi.PrivateAPIInfo        `json:"removed_private_apis"`         // Private API Gateway REST APIs only in the earlier scan
+	ModifiedPrivateAPIs       []PrivateAPIDiff                   `json:"modified_private_apis"`        // Private API Gateway REST APIs in both scans with different attributes
+
+	changes []Change // Every change in the order of the sections, for Changes
+}
+
+// Diff compares two scan results resource by resource
+// Attributes are compared as diff.Compare compares reports: the tag list, ARN, address counts, utilization and
+// manager are ignored, and rules and routes that only moved within their list are not a change.
+// before: The earlier scan result
+// after: The later scan result
+// Returns: The added, removed and modified resources of every type
+func Diff(before, after *ScanResult) *ScanDiff {
+	d := &ScanDiff{
+		BeforeScanTime:  before.ScanTime.UTC().Format(time.RFC3339),
+		AfterScanTime:   after.ScanTime.UTC().Format(time.RFC3339),
+		Region:          after.Region,
+		AccountID:       after.AccountID,
+		SkippedSections: []string{},
+	}
+	d.AddedVPCs, d.RemovedVPCs, d.ModifiedVPCs = section(d, "vpcs", before.VPCs, after.VPCs,
+		func(v vpc.VPCInfo) string { return v.VpcID }, func(v vpc.VPCInfo) string { return nameTag(v.Tags, v.VpcID) }, nil)
+	d.AddedSubnets, d.RemovedSubnets, d.ModifiedSubnets = section(d, "subnets", before.Subnets, after.Subnets,
+		func(v vpc.SubnetInfo) string { return v.SubnetID }, func(v vpc.SubnetInfo) string { return nameTag(v.Tags, v.SubnetID) }, nil)
+	d.AddedRouteTables, d.RemovedRouteTables, d.ModifiedRouteTables = section(d, "route_tables", before.RouteTables, after.RouteTables,
+		func(v vpc.RouteTableInfo) string { return v.RouteTableID }, func(v vpc.RouteTableInfo) string { return nameTag(v.Tags, v.RouteTableID) }, routeTableOrder)
+	d.AddedSecurityGroups, d.RemovedSecurityGroups, d.ModifiedSecurityGroups = section(d, "security_groups", before.SecurityGroups, after.SecurityGroups,
+		func(v vpc.SecurityGroupInfo) string { return v.GroupID }, func(v vpc.SecurityGroupInfo) string { return v.GroupName }, securityGroupOrder)
+	d.AddedInternetGateways, d.RemovedInternetGateways, d.ModifiedInternetGateways = section(d, "internet_gateways", before.InternetGateways, after.InternetGateways,
+		func(v vpc.InternetGatewayInfo) string { return v.InternetGatewayID }, func(v vpc.InternetGatewayInfo) string { return nameTag(v.Tags, v.InternetGatewayID) }, nil)
+	d.AddedNatGateways, d.RemovedNatGateways, d.ModifiedNatGateways = section(d, "nat_gateways", before.NatGateways, after.NatGateways,
+		func(v vpc.NatGatewayInfo) string { return v.NatGatewayID }, func(v vpc.NatGatewayInfo) string { return nameTag(v.Tags, v.NatGatewayID) }, nil)
+	d.AddedRouteAppliances, d.RemovedRouteAppliances, d.ModifiedRouteAppliances = section(d, "route_appliances", before.RouteAppliances, after.RouteAppliances,
+		func(v vpc.RouteApplianceInfo) string { return applianceID(v) }, func(v vpc.RouteApplianceInfo) string { return nameTag(v.Tags, applianceID(v)) }, nil)
+	d.AddedTransitGateways, d.RemovedTransitGateways, d.ModifiedTransitGateways = section(d, "transit_gateways", before.TransitGateways, after.TransitGateways,
+		func(v vpc.TransitGatewayInfo) string { return v.TransitGatewayID }, func(v vpc.TransitGatewayInfo) string { return nameTag(v.Tags, v.TransitGatewayID) }, nil)
+	d.AddedTGWAttachments, d.RemovedTGWAttachments, d.ModifiedTGWAttachments = section(d, "tgw_attachments", before.TGWAttachments, after.TGWAttachments,
+		func(v vpc.TransitGatewayAttachmentInfo) string { return v.AttachmentID }, func(v vpc.TransitGatewayAttachmentInfo) string { return nameTag(v.Tags, v.AttachmentID) }, nil)
+	d.AddedVpcPeerings, d.RemovedVpcPeerings, d.ModifiedVpcPeerings = section(d, "vpc_peerings", before.VpcPeerings, after.VpcPeerings,
+		func(v vpc.VpcPeeringConnectionInfo) string { return v.VpcPeeringConnectionID }, func(v vpc.VpcPeeringConnectionInfo) string { return nameTag(v.Tags, v.VpcPeeringConnectionID) }, nil)
+	d.AddedNetworkACLs, d.RemovedNetworkACLs, d.ModifiedNetworkACLs = section(d, "network_acls", before.NetworkACLs, after.NetworkACLs,
+		func(v vpc.NetworkACLInfo) string { return v.NetworkAclID }, func(v vpc.NetworkACLInfo) string { return nameTag(v.Tags, v.NetworkAclID) }, nil)
+	d.AddedCarrierGateways, d.RemovedCarrierGateways, d.ModifiedCarrierGateways = section(d, "carrier_gateways", before.CarrierGateways, after.CarrierGateways,
+		func(v vpc.CarrierGatewayInfo) string { return v.CarrierGatewayID }, func(v vpc.CarrierGatewayInfo) string { return nameTag(v.Tags, v.CarrierGatewayID) }, nil)
+	d.AddedVpnGateways, d.RemovedVpnGateways, d.ModifiedVpnGateways = section(d, "vpn_gateways", before.VpnGateways, after.VpnGateways,
+		func(v vpc.VpnGatewayInfo) string { return v.VpnGatewayID }, func(v vpc.VpnGatewayInfo) string { return nameTag(v.Tags, v.VpnGatewayID) }, nil)
+	d.AddedCustomerGateways, d.RemovedCustomerGateways, d.ModifiedCustomerGateways = section(d, "customer_gateways", before.CustomerGateways, after.CustomerGateways,
+		func(v vpc.CustomerGatewayInfo) string { return v.CustomerGatewayID }, func(v vpc.CustomerGatewayInfo) string { return nameTag(v.Tags, v.CustomerGatewayID) }, nil)
+	d.AddedVpnConnections, d.RemovedVpnConnections, d.ModifiedVpnConnections = section(d, "vpn_connections", before.VpnConnections, after.VpnConnections,
+		func(v vpc.VpnConnectionInfo) string { return v.VpnConnectionID }, func(v vpc.VpnConnectionInfo) string { return nameTag(v.Tags, v.VpnConnectionID) }, nil)
+	d.AddedDhcpOptions, d.RemovedDhcpOptions, d.ModifiedDhcpOptions = section(d, "dhcp_options", before.DhcpOptions, after.DhcpOptions,
+		func(v vpc.DhcpOptionsInfo) string { return v.DhcpOptionsID }, func(v vpc.DhcpOptionsInfo) string { return nameTag(v.Tags, v.DhcpOptionsID) }, nil)
+	d.AddedNetworkInterfaces, d.RemovedNetworkInterfaces, d.ModifiedNetworkInterfaces = section(d, "network_interfaces", before.NetworkInterfaces, after.NetworkInterfaces,
+		func(v vpc.NetworkInterfaceInfo) string { return v.NetworkInterfaceID }, func(v vpc.NetworkInterfaceInfo) string { return nameTag(v.Tags, v.NetworkInterfaceID) }, nil)
+	d.AddedInstances, d.RemovedInstances, d.ModifiedInstances = section(d, "instances", before.Instances, after.Instances,
+		func(v vpc.InstanceInfo) string { return v.InstanceID }, func(v vpc.InstanceInfo) string { return nameTag(v.Tags, v.InstanceID) }, nil)
+	d.AddedFlowLogs, d.RemovedFlowLogs, d.ModifiedFlowLogs = section(d, "flow_logs", before.FlowLogs, after.FlowLogs,
+		func(v vpc.FlowLogInfo) string { return v.FlowLogID }, func(v vpc.FlowLogInfo) string { return nameTag(v.Tags, v.FlowLogID) }, nil)
+	d.AddedElasticIPs, d.RemovedElasticIPs, d.ModifiedElasticIPs = section(d, "elastic_ips", before.ElasticIPs, after.ElasticIPs,
+		func(v vpc.ElasticIPInfo) string { return v.AllocationID }, func(v vpc.ElasticIPInfo) string { return nameTag(v.Tags, v.PublicIp) }, nil)
+	d.AddedVpcEndpoints, d.RemovedVpcEndpoints, d.ModifiedVpcEndpoints = section(d, "vpc_endpoints", before.VpcEndpoints, after.VpcEndpoints,
+		func(v vpc.VpcEndpointInfo) string { return v.VpcEndpointID }, func(v vpc.VpcEndpointInfo) string { return nameTag(v.Tags, v.VpcEndpointID) }, nil)
+	d.AddedAutoScalingGroups, d.RemovedAutoScalingGroups, d.ModifiedAutoScalingGroups = section(d, "auto_scaling_groups", before.AutoScalingGroups, after.AutoScalingGroups,
+		func(v asg.AutoScalingGroupInfo) string { return v.AutoScalingGroupName }, func(v asg.AutoScalingGroupInfo) string { return v.AutoScalingGroupName }, nil)
+	d.AddedDirectories, d.RemovedDirectories, d.ModifiedDirectories = section(d, "directories", before.Directories, after.Directories,
+		func(v directory.DirectoryInfo) string { return v.DirectoryID }, func(v directory.DirectoryInfo) string { return v.Name }, nil)
+	d.AddedCoreNetworks, d.RemovedCoreNetworks, d.ModifiedCoreNetworks = section(d, "core_networks", before.CoreNetworks, after.CoreNetworks,
+		func(v cloudwan.CoreNetworkInfo) string { return v.CoreNetworkID }, func(v cloudwan.CoreNetworkInfo) string { return nameTag(v.Tags, v.CoreNetworkID) }, nil)
+	d.AddedPrivateAPIs, d.RemovedPrivateAPIs, d.ModifiedPrivateAPIs = section(d, "private_apis", before.PrivateAPIs, after.PrivateAPIs,
+		func(v privateapi.PrivateAPIInfo) string { return v.RestApiID }, func(v privateapi.PrivateAPIInfo) string { return v.Name }, nil)
+	return d
+}
+
+// Changes lists every change of the diff: the sections in the order of the scan result, and within a
+// section the added, then the removed, then the modified resources, each by ID
+func (d *ScanDiff) Changes() []Change {
+	return d.changes
+}
+
+// Empty reports whether the compared sections of the scans hold the same resources with the same attributes
+func (d *ScanDiff) Empty() bool {
+	return len(d.changes) == 0
+}
+
+// section compares the resources of one section of two scan results with diff.Match and records their changes
+// name: JSON name of the section
+// id, label: ID and display name of a resource
+// orderOnly: Fields that differ only in the order of their elements (nil if order always matters)
+// Returns: The added, removed and modified resources, each sorted by ID and never nil
+func section[T any](d *ScanDiff, name string, before, after []T, id, label func(T) string, orderOnly func(before, after T) []string) ([]T, []T, []ResourceDiff[T]) {
+	modified := []ResourceDiff[T]{}
+	if before == nil || after == nil {
+		d.SkippedSections = append(d.SkippedSections, name)
+		return []T{}, []T{}, modified
+	}
+
+	added, removed, pairs := diff.Match(before, after, id)
+	for _, pair := range pairs {
+		fields := pair.Fields
+		if orderOnly != nil {
+			fields = withoutFields(fields, orderOnly(pair.Before, pair.After))
+		}
+		if len(fields) > 0 {
+			modified = append(modified, ResourceDiff[T]{ResourceID: pair.ResourceID, Name: label(pair.After), Fields: fields, Before: pair.Before, After: pair.After})
+		}
+	}
+	for _, resource := range added {
+		d.changes = append(d.changes, Change{Section: name, Change: diff.ChangeAdded, ResourceID: id(resource), Name: label(resource), Fields: []string{}, After: resource})
+	}
+	for _, resource := range removed {
+		d.changes = append(d.changes, Change{Section: name, Change: diff.ChangeRemoved, ResourceID: id(resource), Name: label(resource), Fields: []string{}, Before: resource})
+	}
+	for _, m := range modified {
+		d.changes = append(d.changes, Change{Section: name, Change: diff.ChangeModified, ResourceID: m.ResourceID, Name: m.Name, Fields: m.Fields, Before: m.Before, After: m.After})
+	}
+	return added, removed, modified
+}
+
+// securityGroupOrder returns the rules field of a security group whose rules only moved within the list
+func securityGroupOrder(before, after vpc.SecurityGroupInfo) []string {
+	if added, removed := diff.CompareLists(before.Rules, after.Rules); len(added)+len(removed) == 0 {
+		return []string{"rules"}
+	}
+	return nil
+}
+
+// routeTableOrder returns the routes field of a route table whose routes only moved within the list
+func routeTableOrder(before, after vpc.RouteTableInfo) []string {
+	if added, removed := diff.CompareLists(before.Routes, after.Routes); len(added)+len(removed) == 0 {
+		return []string{"routes"}
+	}
+	return nil
+}
+
+// withoutFields removes field names from a list
+func withoutFields(fields, remove []string) []string {
+	removed := make(map[string]bool, len(remove))
+	for _, field := range remove {
+		removed[field] = true
+	}
+	kept := []string{}
+	for _, field := range fields {
+		if !removed[field] {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// applianceID returns the instance ID of a route appliance, or its interface ID if it has no instance
+func applianceID(appliance vpc.RouteApplianceInfo) string {
+	if appliance.InstanceID != "" {
+		return appliance.InstanceID
+	}
+	return appliance.NetworkInterfaceID
+}
+
+// nameTag returns the Name tag, falling back to the given name
+func nameTag(tags map[string]string, fallback string) string {
+	if name := tags["Name"]; name != "" {
+		return name
+	}
+	return fallback
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"fmt"
+	"time"
+)
+
+// StaleError is a saved scan older than the maximum age a run accepts, or one whose scan time is unknown
+type StaleError struct {
+	ScannedAt time.Time     // When the scan started (zero if the report does not say)
+	Age       time.Duration // Age of the scan at the check
+	MaxAge    time.Duration // Maximum age the run accepts
+}
+
+// Error describes the scan time, the age and the limit
+func (e *StaleError) Error() string {
+	if e.ScannedAt.IsZero() {
+		return fmt.Sprintf("the report has no scan time, so it may be older than -max-age %s", e.MaxAge)
+	}
+	return fmt.Sprintf("scanned %s, %s ago, which is older than -max-age %s",
+		e.ScannedAt.UTC().Format(time.RFC3339), e.Age.Truncate(time.Second), e.MaxAge)
+}
+
+// CheckAge checks that a saved scan is at most maxAge old
+// A scan without a scan time counts as stale, as its age cannot be told.
+// scannedAt: When the scan started (zero if unknown)
+// maxAge: Maximum age; 0 accepts any age
+// now: Time of the check
+// Returns: A *StaleError if the scan is too old, nil otherwise
+func CheckAge(scannedAt time.Time, maxAge time.Duration, now time.Time) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	if scannedAt.IsZero() {
+		return &StaleError{MaxAge: maxAge}
+	}
+	if age := now.Sub(scannedAt); age > maxAge {
+		return &StaleError{ScannedAt: scannedAt, Age: age, MaxAge: maxAge}
+	}
+	return nil
+}
+
+// ParseScanTime parses the scanned_at field of a JSON report
+// value: RFC 3339 time, as the reports write it
+// Returns: The time, or zero if the field is empty or not a time
+func ParseScanTime(value string) time.Time {
+	scannedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return scannedAt
+}
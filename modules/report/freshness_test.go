@@ -0,0 +1,53 @@
+package report
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCheckAge checks the limit, the message of a stale scan and scans without a scan time
+func TestCheckAge(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		scannedAt time.Time
+		maxAge    time.Duration
+		want      string // Part of the error ("" for none)
+	}{
+		{name: "no limit", scannedAt: now.AddDate(-1, 0, 0)},
+		{name: "fresh", scannedAt: now.Add(-time.Hour), maxAge: 24 * time.Hour},
+		{name: "at the limit", scannedAt: now.Add(-24 * time.Hour), maxAge: 24 * time.Hour},
+		{name: "stale", scannedAt: now.Add(-36 * time.Hour), maxAge: 24 * time.Hour, want: "scanned 2024-03-09T00:00:00Z, 36h0m0s ago, which is older than -max-age 24h0m0s"},
+		{name: "no scan time", maxAge: 24 * time.Hour, want: "no scan time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckAge(tt.scannedAt, tt.maxAge, now)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("CheckAge() = %v, want nil", err)
+				}
+				return
+			}
+			var stale *StaleError
+			if !errors.As(err, &stale) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("CheckAge() = %v, want a *StaleError containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseScanTime checks that scan times parse and that missing or invalid ones are zero
+func TestParseScanTime(t *testing.T) {
+	if got := ParseScanTime("2024-03-10T12:00:00Z"); !got.Equal(time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseScanTime() = %v", got)
+	}
+	for _, value := range []string{"", "yesterday"} {
+		if got := ParseScanTime(value); !got.IsZero() {
+			t.Errorf("ParseScanTime(%q) = %v, want zero", value, got)
+		}
+	}
+}
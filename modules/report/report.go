@@ -0,0 +1,108 @@
+// Package report saves the resources of a scan to a file and loads them again, so outputs can be generated
+// without AWS credentials
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/privateapi"
+	"aws-documentor/modules/vpc"
+)
+
+// ScanResult is the file written by -save and read by -load: every resource a scan collected
+// The resources are saved as the scan printed them, with managers labeled and system tags hidden as requested.
+// Sections of optional scans that did not run are null.
+type ScanResult struct {
+	ScanTime               time.Time                          `json:"scan_time"`                // When the scan started
+	Region                 string                             `json:"region"`                   // Region that was scanned
+	AccountID              string                             `json:"account_id"`               // Account that was scanned (empty if unknown)
+	VPCs                   []vpc.VPCInfo                      `json:"vpcs"`                     // Scanned VPCs
+	Subnets                []vpc.SubnetInfo                   `json:"subnets"`                  // Subnets of the VPCs
+	RouteTables            []vpc.RouteTableInfo               `json:"route_tables"`             // Route tables of the VPCs
+	SecurityGroups         []vpc.SecurityGroupInfo            `json:"security_groups"`          // Security groups of the VPCs
+	InternetGateways       []vpc.InternetGatewayInfo          `json:"internet_gateways"`        // Internet gateways of the VPCs
+	NatGateways            []vpc.NatGatewayInfo               `json:"nat_gateways"`             // NAT gateways of the VPCs
+	RouteAppliances        []vpc.RouteApplianceInfo           `json:"route_appliances"`         // Instances and network interfaces that routes target
+	TransitGateways        []vpc.TransitGatewayInfo           `json:"transit_gateways"`         // Transit gateways of the region
+	TGWAttachments         []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`          // Transit gateway attachments of the region
+	VpcPeerings            []vpc.VpcPeeringConnectionInfo     `json:"vpc_peerings"`             // VPC peering connections
+	NetworkACLs            []vpc.NetworkACLInfo               `json:"network_acls"`             // Network ACLs of the VPCs
+	CarrierGateways        []vpc.CarrierGatewayInfo           `json:"carrier_gateways"`         // Carrier gateways of the Wavelength zone VPCs
+	VpnGateways            []vpc.VpnGatewayInfo               `json:"vpn_gateways"`             // Virtual private gateways of the region
+	CustomerGateways       []vpc.CustomerGatewayInfo          `json:"customer_gateways"`        // Customer gateways of the region
+	VpnConnections         []vpc.VpnConnectionInfo            `json:"vpn_connections"`          // Site-to-site VPN connections with their tunnel telemetry
+	DhcpOptions            []vpc.DhcpOptionsInfo              `json:"dhcp_options"`             // DHCP options sets, which VPCs reference by ID
+	NetworkInterfaces      []vpc.NetworkInterfaceInfo         `json:"network_interfaces"`       // Network interfaces of the region
+	Instances              []vpc.InstanceInfo                 `json:"instances"`                // Instances of the region, except terminated ones
+	FlowLogs               []vpc.FlowLogInfo                  `json:"flow_logs"`                // Flow logs of the region, keyed to their resource by resource_id
+	ElasticIPs             []vpc.ElasticIPInfo                `json:"elastic_ips"`              // Elastic IP addresses of the region
+	VpcEndpoints           []vpc.VpcEndpointInfo              `json:"vpc_endpoints"`            // VPC endpoints (null unless scanned)
+	VpcEndpointConnections []vpc.VpcEndpointConnectionInfo    `json:"vpc_endpoint_connections"` // Endpoints connected to the account's endpoint services (null unless scanned)
+	AutoScalingGroups      []asg.AutoScalingGroupInfo         `json:"auto_scaling_groups"`      // Auto Scaling groups (null unless -asgs)
+	Directories            []directory.DirectoryInfo          `json:"directories"`              // Directory Service directories (null unless -directories)
+	CoreNetworks           []cloudwan.CoreNetworkInfo         `json:"core_networks"`            // Cloud WAN core networks (null unless -cloudwan)
+	PrivateAPIs            []privateapi.PrivateAPIInfo        `json:"private_apis"`             // Private API Gateway REST APIs (null unless -private-apis)
+}
+
+// Save writes a scan result as JSON
+// path: File to write
+// Returns: Error if the result cannot be marshalled or written
+func Save(path string, result *ScanResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scan result: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scan result: %w", err)
+	}
+	return nil
+}
+
+// Load reads a scan result written by Save, or the same result converted to another field style
+// path: File to read
+// Returns: Scan result, or error if the file cannot be read, parsed or holds no scan
+func Load(path string) (*ScanResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan result: %w", err)
+	}
+	var result ScanResult
+	if err := output.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse scan result %s: %w", path, err)
+	}
+	// A report of another kind parses as well, but has no scan time
+	if result.ScanTime.IsZero() {
+		return nil, fmt.Errorf("%s is not a scan result written by -save", path)
+	}
+	for i := range result.Subnets {
+		result.Subnets[i].SetAddressUsage()
+	}
+	fillRegions(&result)
+	return &result, nil
+}
+
+// fillRegions sets the region of resources saved before every resource recorded its own
+// The resources of a scan result are all in the region of the result.
+func fillRegions(result *ScanResult) {
+	sections := reflect.ValueOf(result).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		if section.Kind() != reflect.Slice {
+			continue
+		}
+		for j := 0; j < section.Len(); j++ {
+			region := section.Index(j).FieldByName("Region")
+			if region.IsValid() && region.Kind() == reflect.String && region.String() == "" {
+				region.SetString(result.Region)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+)
+
+// testScan is a scan result with the fields whose camelCase names are overridden
+func testScan() *ScanResult {
+	return &ScanResult{
+		ScanTime:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Region:    "eu-west-1",
+		AccountID: "111122223333",
+		VPCs:      []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", Tags: map[string]string{"Name": "prod"}}},
+		Subnets:   []vpc.SubnetInfo{{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/24"}},
+		RouteTables: []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", Routes: []vpc.RouteInfo{
+			{DestinationIpv6Block: "::/0", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}},
+		}}},
+		NatGateways:    []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", SubnetID: "subnet-0a1", CreatedTime: "2023-12-01T00:00:00Z"}},
+		TGWAttachments: []vpc.TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0c1", ResourceID: "vpc-0a1"}},
+	}
+}
+
+// TestLoadFieldStyles checks that Load reads a saved scan back in every field style
+func TestLoadFieldStyles(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "scan.json")
+	if err := Save(saved, testScan()); err != nil {
+		t.Fatal(err)
+	}
+	want, err := Load(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, _ := json.Marshal(want)
+
+	for _, style := range []output.FieldStyle{output.FieldStyleSnake, output.FieldStyleCamel} {
+		t.Run(string(style), func(t *testing.T) {
+			data, err := output.MarshalIndent(testScan(), style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if style == output.FieldStyleCamel && !strings.Contains(string(data), `"transitGatewayAttachmentId"`) {
+				t.Fatalf("the camelCase scan has no transitGatewayAttachmentId:\n%s", data)
+			}
+			path := filepath.Join(dir, string(style)+".json")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotJSON, _ := json.Marshal(got); string(gotJSON) != string(wantJSON) {
+				t.Errorf("Load() =\n%s\nwant\n%s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+// TestLoadRejectsOtherReports checks that a JSON file without a scan time is not taken for a scan
+func TestLoadRejectsOtherReports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte(`{"vpcs": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "is not a scan result") {
+		t.Errorf("Load() error = %v, want one saying it is not a scan result", err)
+	}
+}
+
+// TestLoadFillsRegions checks that resources saved without a region get the region of the scan and others keep theirs
+func TestLoadFillsRegions(t *testing.T) {
+	scan := testScan()
+	scan.Subnets[0].Region = "us-east-1"
+	path := filepath.Join(t.TempDir(), "scan.json")
+	if err := Save(path, scan); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.VPCs[0].Region != "eu-west-1" || loaded.NatGateways[0].Region != "eu-west-1" || loaded.TGWAttachments[0].Region != "eu-west-1" {
+		t.Errorf("regions %q, %q, %q; want the region of the scan", loaded.VPCs[0].Region, loaded.NatGateways[0].Region, loaded.TGWAttachments[0].Region)
+	}
+	if loaded.Subnets[0].Region != "us-east-1" {
+		t.Errorf("subnet region = %q, want the saved us-east-1", loaded.Subnets[0].Region)
+	}
+}
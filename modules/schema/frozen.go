@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// frozen holds, per version, the field list and a sample document with every field set
+// They are written once when a version ships and never edited: fields may be added to a version,
+// but removing, renaming or retyping one needs a new version.
+//
+//go:embed frozen
+var frozen embed.FS
+
+// Check compares every version's report type with its frozen field list and sample document
+// A field that is removed or retyped without a new version shows up in the field list; a renamed
+// or retyped field also stops the sample from decoding. Added fields are allowed, as consumers
+// ignore fields they do not know; Added lists them.
+// Returns: One line per difference, empty if every version still has what was frozen
+func Check() []string {
+	var problems []string
+	for _, version := range Versions() {
+		list, err := frozen.ReadFile("frozen/v" + version + ".fields")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("v%s: no frozen field list: %v", version, err))
+			continue
+		}
+		problems = append(problems, compareFrozen(version, parseFrozen(list), Fields(version))...)
+
+		sample, err := frozen.ReadFile("frozen/v" + version + ".json")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("v%s: no frozen sample: %v", version, err))
+			continue
+		}
+		if err := decodeStrict(sample, versions[version]); err != nil {
+			problems = append(problems, fmt.Sprintf("v%s: the frozen sample no longer decodes: %v", version, err))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// Added lists the fields of a version that were added after it was frozen
+// version: Version from ParseVersion
+// Returns: Fields sorted by path, empty if the version is as frozen or has no frozen field list
+func Added(version string) []Field {
+	list, err := frozen.ReadFile("frozen/v" + version + ".fields")
+	if err != nil {
+		return []Field{}
+	}
+	frozenTypes := parseFrozen(list)
+	added := []Field{}
+	for _, field := range Fields(version) {
+		if _, ok := frozenTypes[field.Path]; !ok {
+			added = append(added, field)
+		}
+	}
+	return added
+}
+
+// parseFrozen reads a frozen field list: one "path type" line per field
+// Returns: Types by path
+func parseFrozen(list []byte) map[string]string {
+	types := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(list)), "\n") {
+		path, fieldType, _ := strings.Cut(line, " ")
+		types[path] = fieldType
+	}
+	return types
+}
+
+// compareFrozen lists the frozen fields a version no longer has with the same type
+// version: Version the fields belong to, for the messages
+// frozenTypes: Types by path from parseFrozen
+// fields: Fields of the version's report type
+func compareFrozen(version string, frozenTypes map[string]string, fields []Field) []string {
+	var problems []string
+	have := make(map[string]string, len(fields))
+	for _, field := range fields {
+		have[field.Path] = field.Type
+	}
+	for path, fieldType := range frozenTypes {
+		current, ok := have[path]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("v%s: frozen field %s %s is missing", version, path, fieldType))
+		case current != fieldType:
+			problems = append(problems, fmt.Sprintf("v%s: frozen field %s was %s and is now %s", version, path, fieldType, current))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// decodeStrict decodes a document into a new value of a report type, failing on fields the type does not have
+func decodeStrict(document []byte, reportType reflect.Type) error {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(reflect.New(reportType).Interface())
+}
+
+// Sample returns the frozen sample document of a version
+// version: Version from ParseVersion
+func Sample(version string) ([]byte, error) {
+	return frozen.ReadFile("frozen/v" + version + ".json")
+}
@@ -0,0 +1,143 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of a change between two schema versions
+const (
+	ChangeMoved   = "moved"   // The field or section has a new path; its contents are unchanged unless listed separately
+	ChangeRemoved = "removed" // The field is gone
+	ChangeRetyped = "retyped" // The field has the same path but another type
+	ChangeAdded   = "added"   // The field is new
+)
+
+// Change is one entry of the migration notes between two versions
+type Change struct {
+	Kind string `json:"kind"` // moved, removed, retyped or added
+	From string `json:"from"` // Path in the older version (empty for added fields)
+	To   string `json:"to"`   // Path in the newer version (empty for removed fields)
+	Type string `json:"type"` // Type in the newer version, or in the older one for removed fields
+	Note string `json:"note"` // What consumers have to do
+}
+
+// move is a section or field that changed its path from one version to the next
+type move struct {
+	from, to string
+	note     string
+}
+
+// moves lists the renames and moves of each version step; everything else is derived from the field lists
+var moves = map[string][]move{
+	"0>1": {
+		{"findings", "sg_reference_findings", "The security group reference findings; findings now holds the findings of every analysis"},
+	},
+}
+
+// StdoutSection maps a part of the legacy stdout output to the consolidated report
+type StdoutSection struct {
+	Heading string `json:"heading"` // Line that introduces the part in the legacy output
+	Path    string `json:"path"`    // Where the same data is in the report of version Current ("" if it is not in the report yet)
+	Note    string `json:"note"`    // How the legacy fragments differ
+}
+
+// StdoutSections are the parts of the legacy stdout output in the order a scan prints them
+// The resources print one pretty-printed object per resource, separated by "---" lines.
+var StdoutSections = []StdoutSection{
+	{"Found N VPCs:", "vpcs[]", "one object per VPC"},
+	{"Found N Subnets:", "subnets[]", "one object per subnet"},
+	{"Found N Route Tables:", "route_tables[]", "one object per route table"},
+	{"Found N Security Groups:", "security_groups[]", "one object per security group"},
+	{"Found N Internet Gateways:", "internet_gateways[]", "one object per internet gateway"},
+	{"Found N NAT Gateways:", "nat_gateways[]", "one object per NAT gateway"},
+	{"Found N Route Target Instances:", "", "not in version 1; the NAT instances among them are in legacy_nat_instances[]"},
+	{"Legacy NAT instances:", "legacy_nat_instances[]", "the legacy_nat_instances list of one object with all routed appliances"},
+	{"Found N Transit Gateways:", "transit_gateways[]", "one object per transit gateway"},
+	{"Found N Transit Gateway Attachments:", "tgw_attachments[]", "one object per attachment"},
+	{"Found N ... (peering connections, endpoints, containers, ...)", "", "not in version 1"},
+	{"Data-quality warnings:", "data_warnings[]", "one list"},
+	{"Security group references:", "sg_reference_findings[]", "the findings list of one object, printed with -sg-references"},
+	{"Egress profiles:", "egress_profiles[]", "the vpcs list of one object; its findings are in findings[]"},
+	{"Subnet network capacity:", "subnet_network[]", "the subnets list of one object; its findings are in findings[]"},
+	{"Other analysis sections (lifecycle, isolation, stability, ...)", "", "not in version 1; their findings are in findings[]"},
+}
+
+// Diff lists the changes from one schema version to another
+// Moves are declared per version step; removed, retyped and added fields are derived from the field
+// lists after applying them, so the notes cannot miss a change.
+// from, to: Versions from ParseVersion
+// Returns: Changes sorted by kind and path, or error if there is no migration between the versions
+func Diff(from, to string) ([]Change, error) {
+	first, _ := strconv.Atoi(from)
+	last, _ := strconv.Atoi(to)
+	if first >= last {
+		return nil, fmt.Errorf("migrations go from an older to a newer version, not v%s to v%s", from, to)
+	}
+	// Versions are consecutive integers, so the steps are 0>1, 1>2, ...
+	var steps []move
+	for v := first; v < last; v++ {
+		steps = append(steps, moves[fmt.Sprintf("%d>%d", v, v+1)]...)
+	}
+
+	var changes []Change
+	oldTypes := make(map[string]string)
+	for _, field := range Fields(from) {
+		path := field.Path
+		for _, m := range steps {
+			if path == m.from {
+				changes = append(changes, Change{Kind: ChangeMoved, From: m.from, To: m.to, Type: field.Type, Note: m.note})
+			}
+			if path == m.from || strings.HasPrefix(path, m.from+".") || strings.HasPrefix(path, m.from+"[]") {
+				path = m.to + strings.TrimPrefix(path, m.from)
+			}
+		}
+		oldTypes[path] = field.Type
+	}
+	newTypes := make(map[string]string)
+	for _, field := range Fields(to) {
+		newTypes[field.Path] = field.Type
+	}
+	// The fields of an added or removed object are listed with it, not one by one
+	for _, field := range Fields(to) {
+		oldType, ok := oldTypes[field.Path]
+		switch {
+		case !ok && !withinMissing(field.Path, oldTypes):
+			changes = append(changes, Change{Kind: ChangeAdded, To: field.Path, Type: field.Type, Note: "New; older consumers can ignore it"})
+		case ok && oldType != field.Type:
+			changes = append(changes, Change{Kind: ChangeRetyped, From: field.Path, To: field.Path, Type: field.Type, Note: "Was " + oldType})
+		}
+	}
+	for path, oldType := range oldTypes {
+		if _, ok := newTypes[path]; !ok && !withinMissing(path, newTypes) {
+			changes = append(changes, Change{Kind: ChangeRemoved, From: path, Type: oldType, Note: "No longer written"})
+		}
+	}
+
+	order := map[string]int{ChangeMoved: 0, ChangeRemoved: 1, ChangeRetyped: 2, ChangeAdded: 3}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if order[a.Kind] != order[b.Kind] {
+			return order[a.Kind] < order[b.Kind]
+		}
+		return a.From+a.To < b.From+b.To
+	})
+	return changes, nil
+}
+
+// withinMissing reports whether a field belongs to an object that is missing from the other version
+// path: Field path, such as egress_profiles[].violations[].rule
+// other: Types by path of the other version
+func withinMissing(path string, other map[string]string) bool {
+	for i := range path {
+		if path[i] != '.' && path[i] != '[' {
+			continue
+		}
+		if _, ok := other[path[:i]]; !ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+// Package schema defines the versioned shapes of the JSON report and how consumers migrate between them
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/vpc"
+)
+
+// Current is the version of the consolidated report the scan writes
+// Fields may be added to Report within a version. Removing, renaming or retyping one needs a new
+// version: a new Report type, its frozen field list and the migration from the previous version.
+const Current = "1"
+
+// ReportV0 is the report.json the Lambda function writes, the first document with all resources in one place
+// The stdout fragments of a scan hold the same resources; see StdoutSections.
+type ReportV0 struct {
+	Region             string                             `json:"region"`               // Region that was scanned
+	ScannedAt          string                             `json:"scanned_at"`           // Time the scan started
+	Partial            bool                               `json:"partial"`              // Whether some resource types are missing
+	VPCs               []vpc.VPCInfo                      `json:"vpcs"`                 // Scanned VPCs
+	Subnets            []vpc.SubnetInfo                   `json:"subnets"`              // Scanned subnets
+	RouteTables        []vpc.RouteTableInfo               `json:"route_tables"`         // Scanned route tables
+	SecurityGroups     []vpc.SecurityGroupInfo            `json:"security_groups"`      // Scanned security groups
+	InternetGateways   []vpc.InternetGatewayInfo          `json:"internet_gateways"`    // Scanned internet gateways
+	NatGateways        []vpc.NatGatewayInfo               `json:"nat_gateways"`         // Scanned NAT gateways
+	TransitGateways    []vpc.TransitGatewayInfo           `json:"transit_gateways"`     // Scanned transit gateways
+	TGWAttachments     []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`      // Scanned transit gateway attachments
+	LegacyNATInstances []vpc.RouteApplianceInfo           `json:"legacy_nat_instances"` // Routed instances that act as NAT instances
+	Findings           []analysis.SGReferenceFinding      `json:"findings"`             // Security group reference findings
+	DataWarnings       []vpc.DataWarning                  `json:"data_warnings"`        // Missing or unrecognized fields in the API responses
+	SubnetNetwork      []analysis.SubnetNetworkCapacity   `json:"subnet_network"`       // Network limits of the instances per subnet (null unless instance_network is set)
+}
+
+// Report is version 1 of the consolidated report: the whole scan in one document
+// The resource sections keep the names of ReportV0, so tools reading report.json read it too.
+type Report struct {
+	SchemaVersion       string                             `json:"schema_version"`        // "1"
+	Generator           string                             `json:"generator"`             // Tool and version that wrote the report, such as "aws-documentor 1.0.0"
+	Region              string                             `json:"region"`                // Region that was scanned
+	AccountID           string                             `json:"account_id"`            // Account that was scanned (empty if unknown)
+	ScannedAt           string                             `json:"scanned_at"`            // When the scan started (RFC 3339)
+	Partial             bool                               `json:"partial"`               // Whether scanners were skipped or cut short; scan_notes says which
+	VPCs                []vpc.VPCInfo                      `json:"vpcs"`                  // Scanned VPCs
+	Subnets             []vpc.SubnetInfo                   `json:"subnets"`               // Subnets of the VPCs
+	RouteTables         []vpc.RouteTableInfo               `json:"route_tables"`          // Route tables of the VPCs
+	SecurityGroups      []vpc.SecurityGroupInfo            `json:"security_groups"`       // Security groups of the VPCs
+	InternetGateways    []vpc.InternetGatewayInfo          `json:"internet_gateways"`     // Internet gateways of the VPCs
+	NatGateways         []vpc.NatGatewayInfo               `json:"nat_gateways"`          // NAT gateways of the VPCs
+	TransitGateways     []vpc.TransitGatewayInfo           `json:"transit_gateways"`      // Transit gateways of the region
+	TGWAttachments      []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`       // Transit gateway attachments of the region
+	LegacyNATInstances  []vpc.RouteApplianceInfo           `json:"legacy_nat_instances"`  // Routed instances that act as NAT instances
+	Findings            []Finding                          `json:"findings"`              // Findings of every analysis that ran, as in the PDF
+	SGReferenceFindings []analysis.SGReferenceFinding      `json:"sg_reference_findings"` // Security group reference findings (null unless analyzed)
+	EgressProfiles      []analysis.EgressProfile           `json:"egress_profiles"`       // Egress profile of every VPC (null unless analyzed)
+	SubnetNetwork       []analysis.SubnetNetworkCapacity   `json:"subnet_network"`        // Network limits of the instances per subnet (null unless analyzed)
+	DataWarnings        []vpc.DataWarning                  `json:"data_warnings"`         // Values the AWS APIs returned missing or unrecognized
+	ScanNotes           []string                           `json:"scan_notes"`            // Parts of the scan that were skipped or cut short
+}
+
+// Finding is a finding of any analysis
+type Finding struct {
+	Severity   string `json:"severity"`    // high, medium, low, or the kind of a recommendation
+	ResourceID string `json:"resource_id"` // Resource the finding is about
+	Title      string `json:"title"`       // One-line summary
+	Detail     string `json:"detail"`      // Explanation
+}
+
+// versions maps every schema version to its report type
+var versions = map[string]reflect.Type{
+	"0": reflect.TypeOf(ReportV0{}),
+	"1": reflect.TypeOf(Report{}),
+}
+
+// Versions returns the known schema versions, oldest first
+func Versions() []string {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseVersion accepts a version as "1" or "v1"
+// Returns: The version without the prefix, or error if it is not known
+func ParseVersion(s string) (string, error) {
+	version := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v")
+	if _, ok := versions[version]; !ok {
+		return "", fmt.Errorf("unknown schema version %q (known: v%s)", s, strings.Join(Versions(), ", v"))
+	}
+	return version, nil
+}
+
+// Field is one JSON field of a report version
+type Field struct {
+	Path string `json:"path"` // Dotted path; list elements are name[] and map values name.*
+	Type string `json:"type"` // string, integer, number, boolean, object, list of ..., or object of ... (any key)
+}
+
+// String formats a field as a line of the frozen field lists
+func (f Field) String() string {
+	return f.Path + " " + f.Type
+}
+
+// Fields lists every JSON field of a report version, sorted by path
+// version: Version from ParseVersion
+func Fields(version string) []Field {
+	return fieldsOf(versions[version])
+}
+
+// fieldsOf lists every JSON field of a struct type, sorted by path
+func fieldsOf(t reflect.Type) []Field {
+	var fields []Field
+	collectFields(t, "", map[reflect.Type]bool{}, &fields)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return fields
+}
+
+// collectFields adds the fields of a struct, following encoding/json: embedded structs without a tag are inlined
+// visiting guards against recursive types, whose repeated level is listed as a plain object.
+func collectFields(t reflect.Type, prefix string, visiting map[reflect.Type]bool, fields *[]Field) {
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" && deref(field.Type).Kind() == reflect.Struct {
+			collectFields(deref(field.Type), prefix, visiting, fields)
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+		path := prefix + name
+		*fields = append(*fields, Field{Path: path, Type: describe(field.Type)})
+		collectNested(field.Type, path, visiting, fields)
+	}
+}
+
+// collectNested adds the fields of the objects a field holds: itself, list elements or map values
+func collectNested(t reflect.Type, path string, visiting map[reflect.Type]bool, fields *[]Field) {
+	t = deref(t)
+	switch {
+	case t.Kind() == reflect.Struct && !visiting[t] && !opaque(t):
+		collectFields(t, path+".", visiting, fields)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		collectNested(t.Elem(), path+"[]", visiting, fields)
+	case t.Kind() == reflect.Map:
+		collectNested(t.Elem(), path+".*", visiting, fields)
+	}
+}
+
+// describe names the JSON type of a Go type
+func describe(t reflect.Type) string {
+	t = deref(t)
+	switch {
+	case opaque(t):
+		return "string"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	case t.Kind() == reflect.String:
+		return "string"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "number"
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return "list of " + describe(t.Elem())
+	case t.Kind() == reflect.Map:
+		return "object of " + describe(t.Elem()) + " (any key)"
+	case t.Kind() == reflect.Struct:
+		return "object"
+	}
+	return "any"
+}
+
+// opaque reports struct types that marshal as a string, such as time.Time
+func opaque(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == "time" && t.Name() == "Time"
+}
+
+// deref returns the type a pointer points to
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,207 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFrozenVersions is the compatibility gate: no version may lose or retype a field it shipped with
+func TestFrozenVersions(t *testing.T) {
+	if problems := Check(); len(problems) > 0 {
+		t.Errorf("a report type changed without a new schema version:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+// TestFrozenSamples checks that the sample of every version decodes into its report type without
+// unknown fields and sets every field of the frozen field list, so a rename cannot go unnoticed
+func TestFrozenSamples(t *testing.T) {
+	for _, version := range Versions() {
+		t.Run("v"+version, func(t *testing.T) {
+			sample, err := Sample(version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := decodeStrict(sample, versions[version]); err != nil {
+				t.Fatalf("the sample does not decode: %v", err)
+			}
+
+			list, err := frozen.ReadFile("frozen/v" + version + ".fields")
+			if err != nil {
+				t.Fatal(err)
+			}
+			frozenTypes := parseFrozen(list)
+			var document interface{}
+			if err := json.Unmarshal(sample, &document); err != nil {
+				t.Fatal(err)
+			}
+			set := make(map[string]bool)
+			samplePaths(document, "", frozenTypes, set)
+			for path := range frozenTypes {
+				if !set[path] {
+					t.Errorf("the sample does not set %s", path)
+				}
+			}
+		})
+	}
+}
+
+// samplePaths records the field paths a decoded JSON document sets, naming map values as the field lists do
+func samplePaths(value interface{}, path string, types map[string]string, set map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := key
+			if strings.HasPrefix(types[path], "object of") {
+				childPath = "*"
+			}
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			set[childPath] = true
+			samplePaths(child, childPath, types, set)
+		}
+	case []interface{}:
+		for _, child := range v {
+			samplePaths(child, path+"[]", types, set)
+		}
+	}
+}
+
+// Report types standing in for a version when it is changed in each of the ways Check must judge
+type (
+	frozenShape struct {
+		ID    string   `json:"id"`
+		Count int      `json:"count"`
+		Items []string `json:"items"`
+	}
+	addedShape struct {
+		ID    string   `json:"id"`
+		Count int      `json:"count"`
+		Items []string `json:"items"`
+		Extra bool     `json:"extra"`
+	}
+	removedShape struct {
+		ID    string   `json:"id"`
+		Items []string `json:"items"`
+	}
+	renamedShape struct {
+		ID    string   `json:"id"`
+		Total int      `json:"total"`
+		Items []string `json:"items"`
+	}
+	retypedShape struct {
+		ID    string `json:"id"`
+		Count string `json:"count"`
+		Items []int  `json:"items"`
+	}
+)
+
+func TestCompareFrozen(t *testing.T) {
+	var list []string
+	for _, field := range fieldsOf(reflect.TypeOf(frozenShape{})) {
+		list = append(list, field.String())
+	}
+	frozenTypes := parseFrozen([]byte(strings.Join(list, "\n") + "\n"))
+
+	tests := []struct {
+		name  string
+		shape interface{}
+		want  []string
+	}{
+		{name: "unchanged", shape: frozenShape{}},
+		{name: "field added", shape: addedShape{}},
+		{name: "field removed", shape: removedShape{},
+			want: []string{"v9: frozen field count integer is missing"}},
+		{name: "field renamed", shape: renamedShape{},
+			want: []string{"v9: frozen field count integer is missing"}},
+		{name: "fields retyped", shape: retypedShape{},
+			want: []string{"v9: frozen field count was integer and is now string", "v9: frozen field items was list of string and is now list of integer"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareFrozen("9", frozenTypes, fieldsOf(reflect.TypeOf(tt.shape)))
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("compareFrozen() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFrozenSampleRejectsRename checks that a sample no longer decodes once a field it sets is renamed
+func TestFrozenSampleRejectsRename(t *testing.T) {
+	sample := []byte(`{"id":"a","count":1,"items":["x"]}`)
+	if err := decodeStrict(sample, reflect.TypeOf(addedShape{})); err != nil {
+		t.Errorf("a sample without an added field should decode: %v", err)
+	}
+	if err := decodeStrict(sample, reflect.TypeOf(renamedShape{})); err == nil {
+		t.Error("a sample with a renamed field decoded")
+	}
+}
+
+// TestAdded checks that the fields added to a version since it was frozen are exactly those not in its field list
+func TestAdded(t *testing.T) {
+	for _, version := range Versions() {
+		list, err := frozen.ReadFile("frozen/v" + version + ".fields")
+		if err != nil {
+			t.Fatal(err)
+		}
+		frozenTypes := parseFrozen(list)
+		added := make(map[string]bool)
+		for _, field := range Added(version) {
+			if _, ok := frozenTypes[field.Path]; ok {
+				t.Errorf("v%s: %s is frozen but listed as added", version, field.Path)
+			}
+			added[field.Path] = true
+		}
+		for _, field := range Fields(version) {
+			if _, ok := frozenTypes[field.Path]; !ok && !added[field.Path] {
+				t.Errorf("v%s: %s is not frozen but not listed as added", version, field.Path)
+			}
+		}
+	}
+}
+
+// TestDiffV0V1 checks the migration notes from the Lambda report to version 1
+func TestDiffV0V1(t *testing.T) {
+	changes, err := Diff("0", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string][]string)
+	for _, change := range changes {
+		kinds[change.Kind] = append(kinds[change.Kind], change.From+">"+change.To)
+	}
+	if want := []string{"findings>sg_reference_findings"}; !reflect.DeepEqual(kinds[ChangeMoved], want) {
+		t.Errorf("moved = %q, want %q", kinds[ChangeMoved], want)
+	}
+	if len(kinds[ChangeRemoved]) > 0 || len(kinds[ChangeRetyped]) > 0 {
+		t.Errorf("v1 removed %q and retyped %q, but only moves and additions were made", kinds[ChangeRemoved], kinds[ChangeRetyped])
+	}
+	for _, path := range []string{"schema_version", "generator", "findings", "scan_notes"} {
+		found := false
+		for _, change := range changes {
+			found = found || change.Kind == ChangeAdded && change.To == path
+		}
+		if !found {
+			t.Errorf("%s is not listed as added", path)
+		}
+	}
+
+	if _, err := Diff("1", "0"); err == nil {
+		t.Error("Diff(1, 0) succeeded")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]string{"1": "1", "v1": "1", " V0 ": "0"}
+	for input, want := range tests {
+		if got, err := ParseVersion(input); err != nil || got != want {
+			t.Errorf("ParseVersion(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseVersion("v7"); err == nil {
+		t.Error("ParseVersion(\"v7\") succeeded")
+	}
+}
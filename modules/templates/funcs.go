@@ -0,0 +1,229 @@
+package templates
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+
+	"aws-documentor/modules/arnbuild"
+	"aws-documentor/modules/netcalc"
+)
+
+// idKeys are the ID fields of the report's resources, the more specific ones first
+// A subnet has a vpc_id and a NAT gateway a subnet_id, so its own ID must be found before those.
+var idKeys = []string{
+	"nat_gateway_id", "attachment_id", "route_table_id", "group_id", "internet_gateway_id",
+	"subnet_id", "transit_gateway_id", "vpc_id", "resource_id",
+}
+
+// consoleDomains are the AWS console hosts of the partitions
+var consoleDomains = map[string]string{
+	"aws":        "console.aws.amazon.com",
+	"aws-cn":     "console.amazonaws.cn",
+	"aws-us-gov": "console.amazonaws-us-gov.com",
+}
+
+// consolePages are the VPC console pages of the resource ID prefixes, longest prefix first
+var consolePages = []struct {
+	prefix string
+	page   string
+}{
+	{"tgw-attach-", "TransitGatewayAttachmentDetails:transitGatewayAttachmentId="},
+	{"tgw-", "TransitGatewayDetails:transitGatewayId="},
+	{"vpc-", "VpcDetails:VpcId="},
+	{"subnet-", "SubnetDetails:subnetId="},
+	{"rtb-", "RouteTableDetails:RouteTableId="},
+	{"sg-", "SecurityGroup:groupId="},
+	{"igw-", "InternetGatewayDetails:internetGatewayId="},
+	{"nat-", "NatGatewayDetails:natGatewayId="},
+}
+
+// Helpers describes the functions of FuncMap, for -template-schema
+var Helpers = [][2]string{
+	{"cidrContains OUTER INNER", "whether CIDR block INNER lies within OUTER"},
+	{"sortByName LIST", "the resources sorted by resourceName, then ID"},
+	{"filterByTag KEY VALUE LIST", `the resources whose tag KEY is VALUE ("" for any value)`},
+	{"humanizeBytes N", "a byte count such as 1.5 GiB"},
+	{"resourceName RESOURCE", "the Name tag, a security group's name, or the resource ID"},
+	{"consoleLink REGION RESOURCE", "AWS console URL of a resource or resource ID"},
+	{"default DEFAULT VALUE", `VALUE, or DEFAULT if it is null or ""`},
+	{"csvField VALUE", "the value quoted for a CSV file where needed"},
+	{"join SEP LIST", "the items of a list joined by SEP"},
+}
+
+// FuncMap returns the helper functions of user templates
+// The functions work on the JSON form of the report (maps, lists, strings, numbers), so other
+// exporters that render templates can register them as well and format resources the same way.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"cidrContains":  netcalc.CIDRContains,
+		"sortByName":    sortByName,
+		"filterByTag":   filterByTag,
+		"humanizeBytes": humanizeBytes,
+		"resourceName":  resourceName,
+		"consoleLink":   consoleLink,
+		"default":       orDefault,
+		"csvField":      csvField,
+		"join":          join,
+	}
+}
+
+// sortByName returns a sorted copy of a list of resources
+func sortByName(list interface{}) ([]interface{}, error) {
+	items, err := asList("sortByName", list)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]interface{}(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := resourceName(sorted[i]), resourceName(sorted[j])
+		if !strings.EqualFold(a, b) {
+			return strings.ToLower(a) < strings.ToLower(b)
+		}
+		return resourceID(sorted[i]) < resourceID(sorted[j])
+	})
+	return sorted, nil
+}
+
+// filterByTag returns the resources of a list with a tag
+// The list comes last, so the function can end a pipeline: {{.subnets | filterByTag "Tier" "private"}}.
+func filterByTag(key, value string, list interface{}) ([]interface{}, error) {
+	items, err := asList("filterByTag", list)
+	if err != nil {
+		return nil, err
+	}
+	var matches []interface{}
+	for _, item := range items {
+		resource, _ := item.(map[string]interface{})
+		tags, _ := resource["tags"].(map[string]interface{})
+		tag, ok := tags[key]
+		if ok && (value == "" || fmt.Sprint(tag) == value) {
+			matches = append(matches, item)
+		}
+	}
+	return matches, nil
+}
+
+// humanizeBytes formats a byte count with binary units
+func humanizeBytes(value interface{}) (string, error) {
+	var n float64
+	switch v := value.(type) {
+	case int:
+		n = float64(v)
+	case int64:
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		return "", fmt.Errorf("humanizeBytes: %v is not a number", value)
+	}
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	unit := 0
+	for (n >= 1024 || n <= -1024) && unit < len(units)-1 {
+		n /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f B", n), nil
+	}
+	return strings.Replace(fmt.Sprintf("%.1f %s", n, units[unit]), ".0 ", " ", 1), nil
+}
+
+// resourceName returns the display name of a resource: its Name tag, a security group's name, or its ID
+func resourceName(resource interface{}) string {
+	fields, _ := resource.(map[string]interface{})
+	if tags, ok := fields["tags"].(map[string]interface{}); ok {
+		if name, ok := tags["Name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	if name, ok := fields["group_name"].(string); ok && name != "" {
+		return name
+	}
+	return resourceID(resource)
+}
+
+// resourceID returns the ID of a resource, or the value itself if it is a string
+func resourceID(resource interface{}) string {
+	if id, ok := resource.(string); ok {
+		return id
+	}
+	fields, _ := resource.(map[string]interface{})
+	for _, key := range idKeys {
+		if id, ok := fields[key].(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// consoleLink returns the AWS console URL of a VPC resource
+// region: Region of the resource, such as .region
+// resource: A resource of the report or its ID
+// Returns: The URL, or error if the resource has no console page
+func consoleLink(region string, resource interface{}) (string, error) {
+	id := resourceID(resource)
+	domain, ok := consoleDomains[arnbuild.Partition(region)]
+	if !ok {
+		domain = consoleDomains["aws"]
+	}
+	for _, page := range consolePages {
+		if strings.HasPrefix(id, page.prefix) {
+			return fmt.Sprintf("https://%s/vpcconsole/home?region=%s#%s%s", domain, url.QueryEscape(region), page.page, url.QueryEscape(id)), nil
+		}
+	}
+	return "", fmt.Errorf("consoleLink: no console page for %q", id)
+}
+
+// orDefault returns a value, or the default for a null or empty value
+// Numbers are returned as they are, so a count of 0 is not replaced like it would be by {{with}}.
+func orDefault(def, value interface{}) interface{} {
+	if value == nil || value == "" {
+		return def
+	}
+	return value
+}
+
+// csvField quotes a value for a CSV file if it contains a comma, quote or line break
+func csvField(value interface{}) string {
+	s := ""
+	if value != nil {
+		s = fmt.Sprint(value)
+	}
+	if strings.ContainsAny(s, ",\"\r\n") {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
+
+// join joins the items of a list, such as a list of CIDR blocks
+func join(sep string, list interface{}) (string, error) {
+	items, err := asList("join", list)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = fmt.Sprint(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// asList accepts the lists of the report data; a missing list (JSON null) is empty
+func asList(function string, list interface{}) ([]interface{}, error) {
+	switch v := list.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%s: %T is not a list", function, list)
+}
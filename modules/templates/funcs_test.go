@@ -0,0 +1,98 @@
+package templates
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// TestHelpers runs every helper function through a template against the JSON form of the test report
+func TestHelpers(t *testing.T) {
+	data, err := plain(newData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{name: "cidrContains", template: `{{cidrContains "10.0.0.0/16" "10.0.2.0/24"}} {{cidrContains "10.0.2.0/24" "10.0.0.0/16"}}`,
+			want: "true false"},
+		{name: "sortByName by name, then ID", template: `{{range sortByName .subnets}}{{.subnet_id}} {{end}}`,
+			want: "subnet-0a2 subnet-0a1 subnet-0b1 "},
+		{name: "sortByName of IDs", template: `{{range sortByName .vpcs}}{{resourceName .}} {{end}}`, want: "prod vpc-0b2 "},
+		{name: "sortByName of null", template: `{{range sortByName .tgw_attachments}}x{{else}}none{{end}}`, want: "none"},
+		{name: "sortByName of a string", template: `{{sortByName .region}}`, wantErr: "sortByName: string is not a list"},
+		{name: "filterByTag any value", template: `{{range .subnets | filterByTag "Name" ""}}{{.subnet_id}} {{end}}`,
+			want: "subnet-0a2 subnet-0a1 "},
+		{name: "filterByTag value", template: `{{range .vpcs | filterByTag "Name" "prod"}}{{.vpc_id}}{{end}}`, want: "vpc-0a1"},
+		{name: "filterByTag no match", template: `{{len (.vpcs | filterByTag "Name" "dev")}}`, want: "0"},
+		{name: "humanizeBytes", template: `{{humanizeBytes 512}}|{{humanizeBytes 1024}}|{{humanizeBytes 1536}}|{{humanizeBytes 5368709120}}|{{humanizeBytes -2048}}`,
+			want: "512 B|1 KiB|1.5 KiB|5 GiB|-2 KiB"},
+		{name: "humanizeBytes of a report number", template: `{{range .subnets}}{{humanizeBytes (default 0 .available_ip_address_count)}} {{end}}`,
+			want: "0 B 240 B 0 B "},
+		{name: "humanizeBytes of a string", template: `{{humanizeBytes "1k"}}`, wantErr: "humanizeBytes: 1k is not a number"},
+		{name: "resourceName", template: `{{range .nat_gateways}}{{resourceName .}}{{end}} {{range .internet_gateways}}{{resourceName .}}{{end}} {{resourceName "vpc-0a1"}}`,
+			want: "egress igw-0a1 vpc-0a1"},
+		{name: "consoleLink of a resource", template: `{{range .route_tables}}{{consoleLink $.region .}}{{end}}`,
+			want: "https://console.aws.amazon.com/vpcconsole/home?region=eu-west-1#RouteTableDetails:RouteTableId=rtb-0a1"},
+		{name: "consoleLink of a NAT gateway, not its subnet", template: `{{range .nat_gateways}}{{consoleLink $.region .}}{{end}}`,
+			want: "https://console.aws.amazon.com/vpcconsole/home?region=eu-west-1#NatGatewayDetails:natGatewayId=nat-0a1"},
+		{name: "consoleLink of an attachment", template: `{{consoleLink "us-gov-west-1" "tgw-attach-0a1"}}`,
+			want: "https://console.amazonaws-us-gov.com/vpcconsole/home?region=us-gov-west-1#TransitGatewayAttachmentDetails:transitGatewayAttachmentId=tgw-attach-0a1"},
+		{name: "consoleLink in China", template: `{{consoleLink "cn-north-1" "sg-0a1"}}`,
+			want: "https://console.amazonaws.cn/vpcconsole/home?region=cn-north-1#SecurityGroup:groupId=sg-0a1"},
+		{name: "consoleLink without a page", template: `{{consoleLink "eu-west-1" "eni-0a1"}}`,
+			wantErr: `consoleLink: no console page for "eni-0a1"`},
+		{name: "default", template: `{{range .subnets}}{{default "-" .available_ip_address_count}} {{end}}{{default "none" ""}}`,
+			want: "0 240 - none"},
+		{name: "csvField", template: `{{csvField "plain"}}|{{csvField "a,b"}}|{{csvField "say \"hi\""}}|{{csvField "two\nlines"}}|{{csvField .scan_notes}}`,
+			want: "plain|\"a,b\"|\"say \"\"hi\"\"\"|\"two\nlines\"|"},
+		{name: "join", template: `{{range .vpcs}}{{join ", " .associate_cidr_blocks}};{{end}}{{join "," .scan_notes}}`,
+			want: "10.1.0.0/16;10.0.0.0/16, 100.64.0.0/16;"},
+		{name: "join of a string", template: `{{join "," .region}}`, wantErr: "join: string is not a list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Option("missingkey=error").Funcs(FuncMap()).Parse(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			err = tmpl.Execute(&out, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// TestHelpersListed checks that -template-schema describes exactly the functions of FuncMap
+func TestHelpersListed(t *testing.T) {
+	var listed, registered []string
+	for _, helper := range Helpers {
+		name, _, _ := strings.Cut(helper[0], " ")
+		listed = append(listed, name)
+	}
+	for name := range FuncMap() {
+		registered = append(registered, name)
+	}
+	sort.Strings(listed)
+	sort.Strings(registered)
+	if strings.Join(listed, " ") != strings.Join(registered, " ") {
+		t.Errorf("Helpers lists %v, FuncMap registers %v", listed, registered)
+	}
+}
@@ -0,0 +1,133 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/vpc"
+)
+
+// Data is the report a user template is executed against
+// Templates see the JSON form: {{range .subnets}}{{.subnet_id}}{{end}}. Numbers are integers where they
+// are whole, so they compare with literals such as {{if gt .available_ip_address_count 10}}.
+type Data struct {
+	Region           string                             `json:"region"`            // Region of the scan
+	AccountID        string                             `json:"account_id"`        // Account of the scan
+	ScannedAt        string                             `json:"scanned_at"`        // When the scan started (RFC 3339)
+	VPCs             []vpc.VPCInfo                      `json:"vpcs"`              // Scanned VPCs
+	Subnets          []vpc.SubnetInfo                   `json:"subnets"`           // Subnets of the VPCs
+	RouteTables      []vpc.RouteTableInfo               `json:"route_tables"`      // Route tables of the VPCs
+	SecurityGroups   []vpc.SecurityGroupInfo            `json:"security_groups"`   // Security groups of the VPCs
+	InternetGateways []vpc.InternetGatewayInfo          `json:"internet_gateways"` // Internet gateways of the VPCs
+	NatGateways      []vpc.NatGatewayInfo               `json:"nat_gateways"`      // NAT gateways of the VPCs
+	TransitGateways  []vpc.TransitGatewayInfo           `json:"transit_gateways"`  // Transit gateways of the region
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo `json:"tgw_attachments"`   // Transit gateway attachments of the region
+	Findings         []Finding                          `json:"findings"`          // Findings of the PDF report, all of them
+	DataWarnings     []vpc.DataWarning                  `json:"data_warnings"`     // Values the AWS APIs returned missing or unrecognized
+	ScanNotes        []string                           `json:"scan_notes"`        // Parts of the scan that were skipped or cut short
+	EgressProfiles   []analysis.EgressProfile           `json:"egress_profiles"`   // Egress profile of every VPC (null without -egress-profiles)
+	SubnetNetwork    []analysis.SubnetNetworkCapacity   `json:"subnet_network"`    // Network limits of the instances per subnet (null without -instance-network)
+}
+
+// Finding is a finding of the PDF report
+type Finding struct {
+	Severity   string `json:"severity"`    // high, medium, low, or the kind of a recommendation
+	ResourceID string `json:"resource_id"` // Resource the finding is about
+	Title      string `json:"title"`       // One-line summary
+	Detail     string `json:"detail"`      // Explanation
+}
+
+// Template is a parsed user template
+type Template struct {
+	name    string
+	execute func(w io.Writer, data interface{}) error
+}
+
+// Parse reads a user template
+// Files ending in .html or .htm are HTML templates, whose output is escaped for its context; all other
+// files are text templates. Both are strict: a field that the data does not have stops execution.
+// path: Template file
+// Returns: The template, or error with the line number if it does not parse or calls an unknown function
+func Parse(path string) (*Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read template: %w", err)
+	}
+	name := filepath.Base(path)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		t, err := htmltemplate.New(name).Option("missingkey=error").Funcs(htmltemplate.FuncMap(FuncMap())).Parse(string(content))
+		if err != nil {
+			return nil, err
+		}
+		return &Template{name: name, execute: t.Execute}, nil
+	}
+	t, err := template.New(name).Option("missingkey=error").Funcs(FuncMap()).Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	return &Template{name: name, execute: t.Execute}, nil
+}
+
+// Execute renders the template
+// Nothing is written if execution fails, so a broken template does not leave half a document.
+// w: Destination of the output
+// data: The report
+// Returns: Error with the template line number if a field is missing or a function fails
+func (t *Template) Execute(w io.Writer, data *Data) error {
+	values, err := plain(data)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := t.execute(&out, values); err != nil {
+		return err
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// plain converts the data to the maps, lists, strings, numbers and booleans of its JSON form
+func plain(data *Data) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode template data: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var values interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("cannot encode template data: %w", err)
+	}
+	return numbers(values), nil
+}
+
+// numbers replaces the json.Number values of decoded JSON by int64, or float64 for fractions
+func numbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = numbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = numbers(item)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
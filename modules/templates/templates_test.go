@@ -0,0 +1,167 @@
+package templates
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aws-documentor/modules/vpc"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// newData returns a small report: two VPCs, one with an internet gateway, a NAT gateway, three subnets and a
+// route table, a finding and a data warning, and names that need quoting in CSV
+func newData() *Data {
+	available := func(n int) *int { return &n }
+	return &Data{
+		Region:    "eu-west-1",
+		AccountID: "111122223333",
+		ScannedAt: "2026-01-02T03:04:05Z",
+		VPCs: []vpc.VPCInfo{
+			{VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/16", AssociateCidrBlocks: []string{"10.1.0.0/16"}},
+			{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", AssociateCidrBlocks: []string{"10.0.0.0/16", "100.64.0.0/16"},
+				Ipv6CidrBlocks: []string{"2001:db8:1::/56"}, Tags: map[string]string{"Name": "prod"}},
+		},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a2", VpcID: "vpc-0a1", CidrBlock: "10.0.2.0/24", AvailabilityZone: "eu-west-1b",
+				AvailableIpAddressCount: available(0), Tags: map[string]string{"Name": "private, b"}},
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1a",
+				AvailableIpAddressCount: available(240), Tags: map[string]string{"Name": `public "a"`}},
+			{SubnetID: "subnet-0b1", VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/24", AvailabilityZone: "eu-west-1a"},
+		},
+		RouteTables: []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", IsMainRouteTable: true, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", State: "active", Target: vpc.RouteTarget{Type: "local", ID: "local"}},
+			{DestinationCidrBlock: "0.0.0.0/0", State: "active", Target: vpc.RouteTarget{Type: "internet_gateway", ID: "igw-0a1"}},
+			{DestinationIpv6Block: "::/0", State: "blackhole", Target: vpc.RouteTarget{Type: "egress_only_internet_gateway", ID: "eigw-0a1"}},
+		}}},
+		InternetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1"}},
+		NatGateways: []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", VpcID: "vpc-0a1", SubnetID: "subnet-0a1",
+			ConnectivityType: "public", PublicIp: "203.0.113.10", Tags: map[string]string{"Name": "egress"}}},
+		Findings: []Finding{{Severity: "high", ResourceID: "subnet-0a2", Title: "Subnet is full", Detail: "No addresses are free."}},
+		DataWarnings: []vpc.DataWarning{{ResourceType: "subnet", ResourceID: "subnet-0b1", Field: "AvailableIpAddressCount",
+			Problem: vpc.ProblemMissing}},
+	}
+}
+
+// writeTemplate writes a template file to a temporary directory
+// Returns: Path of the file
+func writeTemplate(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestExamples renders the example templates and compares them with testdata/<template>.golden
+func TestExamples(t *testing.T) {
+	for _, name := range []string{"runbook.md.tmpl", "subnets.csv.tmpl"} {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := Parse(filepath.Join("..", "..", "examples", "templates", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := tmpl.Execute(&out, newData()); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("output differs from %s (run go test -update if the change is intended):\n%s", golden, out.String())
+			}
+		})
+	}
+}
+
+// TestStrictErrors checks that unknown functions fail parsing and missing fields fail execution, both with the
+// template line, and that a failed execution writes nothing
+func TestStrictErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		wantParse string // Expected in the Parse error, empty if parsing succeeds
+		wantExec  string // Expected in the Execute error
+	}{
+		{name: "unknown function", file: "report.txt", content: "VPCs:\n{{range .vpcs}}{{cidrContainz .cidr_block}}{{end}}\n",
+			wantParse: `report.txt:2: function "cidrContainz" not defined`},
+		{name: "missing field", file: "report.txt", content: "Subnets\n{{range .subnets}}\n{{.subnet_name}}\n{{end}}\n",
+			wantExec: `report.txt:3:2: executing "report.txt" at <.subnet_name>: map has no entry for key "subnet_name"`},
+		{name: "missing root field", file: "report.md", content: "# {{.account}}\n", wantExec: `report.md:1:4`},
+		{name: "missing field in HTML", file: "report.html", content: "<p>\n{{.acount_id}}</p>\n", wantExec: `report.html:2:2`},
+		{name: "failing helper", file: "report.txt", content: "partial\n{{consoleLink .region \"eni-0a1\"}}\n",
+			wantExec: `no console page for "eni-0a1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(writeTemplate(t, tt.file, tt.content))
+			if tt.wantParse != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantParse) {
+					t.Errorf("Parse() error = %v, want %q", err, tt.wantParse)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			err = tmpl.Execute(&out, newData())
+			if err == nil || !strings.Contains(err.Error(), tt.wantExec) {
+				t.Errorf("Execute() error = %v, want %q", err, tt.wantExec)
+			}
+			if out.Len() != 0 {
+				t.Errorf("Execute() wrote %q after failing", out.String())
+			}
+		})
+	}
+
+	if _, err := Parse(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil || !strings.Contains(err.Error(), "cannot read template") {
+		t.Errorf("Parse() of a missing file error = %v", err)
+	}
+}
+
+// TestTemplateKinds checks that HTML templates escape the data and text templates do not, and that whole numbers
+// compare with integer literals
+func TestTemplateKinds(t *testing.T) {
+	data := newData()
+	data.VPCs[0].Tags = map[string]string{"Name": "<b>lab</b> & test"}
+	content := `{{range .vpcs}}{{resourceName .}};{{end}}{{range .subnets}}{{if gt (default 0 .available_ip_address_count) 10}}{{.subnet_id}} {{end}}{{end}}`
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "vpcs.html", want: "&lt;b&gt;lab&lt;/b&gt; &amp; test;prod;subnet-0a1 "},
+		{file: "vpcs.HTM", want: "&lt;b&gt;lab&lt;/b&gt; &amp; test;prod;subnet-0a1 "},
+		{file: "vpcs.txt", want: "<b>lab</b> & test;prod;subnet-0a1 "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			tmpl, err := Parse(writeTemplate(t, tt.file, content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := tmpl.Execute(&out, data); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
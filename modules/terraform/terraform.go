@@ -0,0 +1,149 @@
+// Package terraform generates Terraform import blocks for the resources of a scan, so resources created by hand
+// can be brought under Terraform management
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/naming"
+	"aws-documentor/modules/report"
+)
+
+// TerraformExporter generates Terraform configuration from scan results
+type TerraformExporter struct {
+	SkipManagedBy []string // Managers (managed_by values) whose resources are left out, as they are already managed
+}
+
+// NewTerraformExporter creates an exporter that leaves out the resources Terraform already manages
+func NewTerraformExporter() *TerraformExporter {
+	return &TerraformExporter{SkipManagedBy: []string{analysis.ManagerTerraform}}
+}
+
+// importBlock is one import block: the resource address and the ID Terraform imports it by
+type importBlock struct {
+	resourceType string // Terraform resource type (aws_vpc, ...)
+	name         string // Terraform resource name, derived from the resource ID
+	id           string // Import ID
+	comment      string // Name tag of the resource (empty for none)
+}
+
+// GenerateImportBlocks emits Terraform 1.5+ import blocks for the VPCs, subnets, route tables and their subnet
+// associations, security groups, internet and NAT gateways and transit gateways of a scan
+// Resources are named after their IDs (aws_vpc.vpc_0abc...), so the addresses stay stable between scans.
+// Resources the SkipManagedBy managers created, resources other accounts own and NAT gateways and transit
+// gateways that are being deleted are left out, as Terraform cannot or should not import them.
+// "terraform plan -generate-config-out=generated.tf" writes the resource configuration for the blocks.
+// result: Scan result from the scan or -load
+// Returns: The import blocks as HCL
+func (te *TerraformExporter) GenerateImportBlocks(result *report.ScanResult) string {
+	skip := make(map[string]bool, len(te.SkipManagedBy))
+	for _, manager := range te.SkipManagedBy {
+		skip[manager] = true
+	}
+	foreign := func(ownerID string) bool {
+		return result.AccountID != "" && ownerID != "" && ownerID != result.AccountID
+	}
+
+	var sections [][]importBlock
+	var blocks []importBlock
+	for _, v := range result.VPCs {
+		if !skip[v.ManagedBy] {
+			blocks = append(blocks, newBlock("aws_vpc", v.VpcID, v.VpcID, v.Tags))
+		}
+	}
+	sections = append(sections, blocks)
+
+	blocks = nil
+	for _, subnet := range result.Subnets {
+		if !skip[subnet.ManagedBy] {
+			blocks = append(blocks, newBlock("aws_subnet", subnet.SubnetID, subnet.SubnetID, subnet.Tags))
+		}
+	}
+	sections = append(sections, blocks)
+
+	blocks = nil
+	var associations []importBlock
+	for _, rt := range result.RouteTables {
+		if skip[rt.ManagedBy] {
+			continue
+		}
+		blocks = append(blocks, newBlock("aws_route_table", rt.RouteTableID, rt.RouteTableID, rt.Tags))
+		// The main route table's implicit association is aws_main_route_table_association, which has no import
+		for _, subnetID := range rt.SubnetIDs {
+			associations = append(associations, newBlock("aws_route_table_association", subnetID, subnetID+"/"+rt.RouteTableID, nil))
+		}
+	}
+	sections = append(sections, blocks, associations)
+
+	blocks = nil
+	for _, sg := range result.SecurityGroups {
+		if !skip[sg.ManagedBy] && !foreign(sg.OwnerID) {
+			blocks = append(blocks, newBlock("aws_security_group", sg.GroupID, sg.GroupID, sg.Tags))
+		}
+	}
+	sections = append(sections, blocks)
+
+	blocks = nil
+	for _, igw := range result.InternetGateways {
+		if !skip[igw.ManagedBy] {
+			blocks = append(blocks, newBlock("aws_internet_gateway", igw.InternetGatewayID, igw.InternetGatewayID, igw.Tags))
+		}
+	}
+	sections = append(sections, blocks)
+
+	blocks = nil
+	for _, nat := range result.NatGateways {
+		if !skip[nat.ManagedBy] && nat.State != "deleting" && nat.State != "deleted" && nat.State != "failed" {
+			blocks = append(blocks, newBlock("aws_nat_gateway", nat.NatGatewayID, nat.NatGatewayID, nat.Tags))
+		}
+	}
+	sections = append(sections, blocks)
+
+	blocks = nil
+	for _, tgw := range result.TransitGateways {
+		if !skip[tgw.ManagedBy] && !foreign(tgw.OwnerID) && tgw.State != "deleting" && tgw.State != "deleted" {
+			blocks = append(blocks, newBlock("aws_ec2_transit_gateway", tgw.TransitGatewayID, tgw.TransitGatewayID, tgw.Tags))
+		}
+	}
+	sections = append(sections, blocks)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Terraform import blocks for the VPC resources of %s, scanned %s\n", scanScope(result), result.ScanTime.UTC().Format("2006-01-02T15:04:05Z"))
+	b.WriteString("# Requires Terraform 1.5 or later; write the resource configuration with:\n")
+	b.WriteString("#   terraform plan -generate-config-out=generated.tf\n")
+	for _, section := range sections {
+		sort.Slice(section, func(i, j int) bool { return section[i].name < section[j].name })
+		for _, block := range section {
+			b.WriteString("\n")
+			if block.comment != "" {
+				fmt.Fprintf(&b, "# %s\n", block.comment)
+			}
+			fmt.Fprintf(&b, "import {\n  to = %s.%s\n  id = %q\n}\n", block.resourceType, block.name, block.id)
+		}
+	}
+	return b.String()
+}
+
+// newBlock creates the import block of a resource
+// resourceID: ID the Terraform resource name is derived from
+// importID: ID Terraform imports the resource by
+// tags: Tags of the resource, whose Name tag becomes the comment (nil for none)
+func newBlock(resourceType, resourceID, importID string, tags map[string]string) importBlock {
+	return importBlock{
+		resourceType: resourceType,
+		name:         naming.Sanitize(strings.ReplaceAll(resourceID, "-", "_"), naming.StyleTerraform),
+		id:           importID,
+		comment:      strings.Join(strings.Fields(tags["Name"]), " "),
+	}
+}
+
+// scanScope names the account and region of a scan for the header
+func scanScope(result *report.ScanResult) string {
+	if result.AccountID == "" {
+		return result.Region
+	}
+	return result.AccountID + " " + result.Region
+}
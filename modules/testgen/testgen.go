@@ -0,0 +1,232 @@
+// Package testgen generates synthetic scan results of any size for benchmarks and load tests
+package testgen
+
+import (
+	"fmt"
+
+	"aws-documentor/modules/vpc"
+)
+
+// Size parameterizes a synthetic environment
+type Size struct {
+	Name                 string // Name of the size (small, medium, large)
+	VPCs                 int    // Number of VPCs
+	SubnetsPerVPC        int    // Subnets per VPC, alternating public and private (at most 256)
+	SecurityGroupsPerVPC int    // Security groups per VPC
+	RulesPerGroup        int    // Ingress rules per security group, besides the default egress rule
+	PeerRoutes           int    // Routes per private route table to other VPCs through the transit gateway
+}
+
+// Preset sizes; only the number of VPCs grows, so time per resource is comparable across sizes
+var (
+	Small  = Size{Name: "small", VPCs: 10, SubnetsPerVPC: 6, SecurityGroupsPerVPC: 20, RulesPerGroup: 10, PeerRoutes: 20}
+	Medium = Size{Name: "medium", VPCs: 100, SubnetsPerVPC: 6, SecurityGroupsPerVPC: 20, RulesPerGroup: 10, PeerRoutes: 20}
+	Large  = Size{Name: "large", VPCs: 500, SubnetsPerVPC: 6, SecurityGroupsPerVPC: 20, RulesPerGroup: 10, PeerRoutes: 20}
+)
+
+// Sizes lists the preset sizes from small to large
+var Sizes = []Size{Small, Medium, Large}
+
+// ParseSize returns the preset size with the given name
+// Returns: The size, or error if the name is not small, medium or large
+func ParseSize(name string) (Size, error) {
+	for _, size := range Sizes {
+		if size.Name == name {
+			return size, nil
+		}
+	}
+	return Size{}, fmt.Errorf("unknown size %q (expected small, medium or large)", name)
+}
+
+// Environment is a synthetic scan result with the core VPC resources
+type Environment struct {
+	VPCs             []vpc.VPCInfo                      // VPCs, each attached to the transit gateway
+	Subnets          []vpc.SubnetInfo                   // Subnets of every VPC
+	RouteTables      []vpc.RouteTableInfo               // Main, public and private route table of every VPC
+	SecurityGroups   []vpc.SecurityGroupInfo            // Security groups of every VPC
+	InternetGateways []vpc.InternetGatewayInfo          // One internet gateway per VPC
+	NatGateways      []vpc.NatGatewayInfo               // One NAT gateway per VPC, in its first public subnet
+	TransitGateways  []vpc.TransitGatewayInfo           // The transit gateway connecting the VPCs
+	TGWAttachments   []vpc.TransitGatewayAttachmentInfo // One attachment per VPC
+}
+
+// Resources returns the number of resources in the environment
+func (e *Environment) Resources() int {
+	return len(e.VPCs) + len(e.Subnets) + len(e.RouteTables) + len(e.SecurityGroups) +
+		len(e.InternetGateways) + len(e.NatGateways) + len(e.TransitGateways) + len(e.TGWAttachments)
+}
+
+// Account is the account ID of every generated resource
+const Account = "111122223333"
+
+// Generate builds a synthetic environment
+// The result is deterministic: the same size always gives the same resources. Security group rules
+// mix CIDR sources, references to groups of the same VPC, references to groups of the next VPC and
+// prefix lists, so reference analysis has work to do.
+// size: Number of resources to generate
+// Returns: The environment
+func Generate(size Size) *Environment {
+	env := &Environment{
+		TransitGateways: []vpc.TransitGatewayInfo{{
+			TransitGatewayID: "tgw-0000000000000001",
+			State:            "available",
+			OwnerID:          Account,
+			AmazonSideAsn:    64512,
+			Tags:             map[string]string{"Name": "core"},
+			TagList:          []vpc.Tag{},
+		}},
+	}
+	tgwID := env.TransitGateways[0].TransitGatewayID
+
+	for v := 0; v < size.VPCs; v++ {
+		vpcID := id("vpc", v)
+		cidr := vpcCIDR(v)
+		env.VPCs = append(env.VPCs, vpc.VPCInfo{
+			VpcID:               vpcID,
+			CidrBlock:           cidr,
+			State:               "available",
+			InstanceTenancy:     "default",
+			Tags:                map[string]string{"Name": fmt.Sprintf("vpc-%d", v)},
+			TagList:             []vpc.Tag{},
+			AssociateCidrBlocks: []string{cidr},
+			Ipv6CidrBlocks:      []string{},
+		})
+
+		igwID := id("igw", v)
+		env.InternetGateways = append(env.InternetGateways, vpc.InternetGatewayInfo{
+			InternetGatewayID: igwID, State: "available", VpcID: vpcID, Tags: map[string]string{}, TagList: []vpc.Tag{},
+		})
+
+		var publicSubnets, privateSubnets []string
+		for s := 0; s < size.SubnetsPerVPC; s++ {
+			subnetID := id("subnet", v*size.SubnetsPerVPC+s)
+			public := s%2 == 0
+			env.Subnets = append(env.Subnets, vpc.SubnetInfo{
+				SubnetID:            subnetID,
+				VpcID:               vpcID,
+				CidrBlock:           subnetCIDR(v, s),
+				AvailabilityZone:    fmt.Sprintf("eu-west-1%c", 'a'+rune(s/2%3)),
+				State:               "available",
+				MapPublicIpOnLaunch: public,
+				Tags:                map[string]string{"Name": fmt.Sprintf("vpc-%d-subnet-%d", v, s)},
+				TagList:             []vpc.Tag{},
+			})
+			if public {
+				publicSubnets = append(publicSubnets, subnetID)
+			} else {
+				privateSubnets = append(privateSubnets, subnetID)
+			}
+		}
+
+		natID := id("nat", v)
+		if len(publicSubnets) > 0 {
+			env.NatGateways = append(env.NatGateways, vpc.NatGatewayInfo{
+				NatGatewayID: natID, SubnetID: publicSubnets[0], VpcID: vpcID, State: "available",
+				ConnectivityType: "public", Tags: map[string]string{}, TagList: []vpc.Tag{},
+			})
+		}
+
+		local := vpc.RouteInfo{DestinationCidrBlock: cidr, GatewayID: "local", State: "active", Origin: "CreateRouteTable",
+			Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}}
+		env.RouteTables = append(env.RouteTables,
+			routeTable(id("rtb", 3*v), vpcID, true, nil, local),
+			routeTable(id("rtb", 3*v+1), vpcID, false, publicSubnets, local,
+				vpc.RouteInfo{DestinationCidrBlock: "0.0.0.0/0", GatewayID: igwID, State: "active", Origin: "CreateRoute",
+					Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: igwID}}))
+		private := []vpc.RouteInfo{local}
+		if len(publicSubnets) > 0 {
+			private = append(private, vpc.RouteInfo{DestinationCidrBlock: "0.0.0.0/0", NatGatewayID: natID, State: "active", Origin: "CreateRoute",
+				Target: vpc.RouteTarget{Type: vpc.RouteTargetNatGateway, ID: natID}})
+		}
+		for p := 1; p <= size.PeerRoutes && p < size.VPCs; p++ {
+			private = append(private, vpc.RouteInfo{DestinationCidrBlock: vpcCIDR((v + p) % size.VPCs), TransitGatewayID: tgwID, State: "active", Origin: "CreateRoute",
+				Target: vpc.RouteTarget{Type: vpc.RouteTargetTransitGateway, ID: tgwID}})
+		}
+		env.RouteTables = append(env.RouteTables, routeTable(id("rtb", 3*v+2), vpcID, false, privateSubnets, private...))
+
+		for g := 0; g < size.SecurityGroupsPerVPC; g++ {
+			env.SecurityGroups = append(env.SecurityGroups, securityGroup(size, v, g))
+		}
+
+		env.TGWAttachments = append(env.TGWAttachments, vpc.TransitGatewayAttachmentInfo{
+			AttachmentID:     id("tgw-attach", v),
+			TransitGatewayID: tgwID,
+			ResourceType:     "vpc",
+			ResourceID:       vpcID,
+			ResourceOwnerID:  Account,
+			State:            "available",
+			Association:      map[string]string{},
+			SubnetIDs:        privateSubnets,
+			Tags:             map[string]string{},
+			TagList:          []vpc.Tag{},
+		})
+	}
+	return env
+}
+
+// securityGroup builds group g of VPC v with its rules
+func securityGroup(size Size, v, g int) vpc.SecurityGroupInfo {
+	groupID := id("sg", v*size.SecurityGroupsPerVPC+g)
+	sg := vpc.SecurityGroupInfo{
+		GroupID:     groupID,
+		GroupName:   fmt.Sprintf("vpc-%d-group-%d", v, g),
+		Description: "synthetic",
+		VpcID:       id("vpc", v),
+		OwnerID:     Account,
+		Tags:        map[string]string{},
+		TagList:     []vpc.Tag{},
+	}
+	for r := 0; r < size.RulesPerGroup; r++ {
+		rule := vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: int32(1000 + r), ToPort: int32(1000 + r)}
+		switch r % 4 {
+		case 0:
+			rule.CidrBlock = vpcCIDR(v)
+		case 1:
+			rule.GroupID = id("sg", v*size.SecurityGroupsPerVPC+(g+1)%size.SecurityGroupsPerVPC)
+			rule.GroupOwnerID = Account
+		case 2:
+			next := (v + 1) % size.VPCs
+			rule.GroupID = id("sg", next*size.SecurityGroupsPerVPC+g)
+			rule.GroupOwnerID = Account
+			rule.GroupVpcID = id("vpc", next)
+		case 3:
+			rule.PrefixListID = id("pl", r)
+		}
+		sg.Rules = append(sg.Rules, rule)
+	}
+	sg.Rules = append(sg.Rules, vpc.SecurityGroupRule{IsEgress: true, IpProtocol: "-1", CidrBlock: "0.0.0.0/0", IsDefaultEgress: true})
+	sg.IngressRuleCount = size.RulesPerGroup
+	sg.EgressRuleCount = 1
+	return sg
+}
+
+// routeTable builds a route table
+func routeTable(routeTableID, vpcID string, main bool, subnetIDs []string, routes ...vpc.RouteInfo) vpc.RouteTableInfo {
+	if subnetIDs == nil {
+		subnetIDs = []string{}
+	}
+	return vpc.RouteTableInfo{
+		RouteTableID:     routeTableID,
+		VpcID:            vpcID,
+		Routes:           routes,
+		SubnetIDs:        subnetIDs,
+		IsMainRouteTable: main,
+		Tags:             map[string]string{},
+		TagList:          []vpc.Tag{},
+	}
+}
+
+// id builds a resource ID with the given prefix from a number
+func id(prefix string, n int) string {
+	return fmt.Sprintf("%s-%017x", prefix, n)
+}
+
+// vpcCIDR returns the /16 block of VPC v, counting up from 10.0.0.0/16
+func vpcCIDR(v int) string {
+	return fmt.Sprintf("%d.%d.0.0/16", 10+v/256, v%256)
+}
+
+// subnetCIDR returns the /24 block of subnet s in VPC v
+func subnetCIDR(v, s int) string {
+	return fmt.Sprintf("%d.%d.%d.0/24", 10+v/256, v%256, s)
+}
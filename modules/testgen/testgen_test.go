@@ -0,0 +1,135 @@
+package testgen
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestGenerateCounts checks that each preset gives the resources its size describes
+func TestGenerateCounts(t *testing.T) {
+	for _, size := range Sizes {
+		t.Run(size.Name, func(t *testing.T) {
+			env := Generate(size)
+			counts := map[string][2]int{
+				"VPCs":              {len(env.VPCs), size.VPCs},
+				"subnets":           {len(env.Subnets), size.VPCs * size.SubnetsPerVPC},
+				"route tables":      {len(env.RouteTables), 3 * size.VPCs},
+				"security groups":   {len(env.SecurityGroups), size.VPCs * size.SecurityGroupsPerVPC},
+				"internet gateways": {len(env.InternetGateways), size.VPCs},
+				"NAT gateways":      {len(env.NatGateways), size.VPCs},
+				"transit gateways":  {len(env.TransitGateways), 1},
+				"attachments":       {len(env.TGWAttachments), size.VPCs},
+			}
+			total := 0
+			for name, count := range counts {
+				if count[0] != count[1] {
+					t.Errorf("%d %s, want %d", count[0], name, count[1])
+				}
+				total += count[0]
+			}
+			if env.Resources() != total {
+				t.Errorf("Resources() = %d, want %d", env.Resources(), total)
+			}
+			for _, sg := range env.SecurityGroups {
+				if len(sg.Rules) != size.RulesPerGroup+1 || sg.IngressRuleCount != size.RulesPerGroup || sg.EgressRuleCount != 1 {
+					t.Fatalf("group %s has %d rules (%d ingress, %d egress), want %d ingress and 1 egress",
+						sg.GroupID, len(sg.Rules), sg.IngressRuleCount, sg.EgressRuleCount, size.RulesPerGroup)
+				}
+			}
+		})
+	}
+}
+
+// TestGenerateDeterministic checks that the same size always gives the same environment
+func TestGenerateDeterministic(t *testing.T) {
+	if !reflect.DeepEqual(Generate(Small), Generate(Small)) {
+		t.Error("two environments of the same size differ")
+	}
+}
+
+// TestGenerateReferences checks that IDs are unique and every reference points at a generated resource
+// The hot paths resolve these references, so a dangling one would time a path that finds nothing.
+func TestGenerateReferences(t *testing.T) {
+	env := Generate(Medium)
+	ids := make(map[string]bool)
+	add := func(id string) {
+		if ids[id] {
+			t.Errorf("duplicate ID %s", id)
+		}
+		ids[id] = true
+	}
+	for _, v := range env.VPCs {
+		add(v.VpcID)
+	}
+	for _, s := range env.Subnets {
+		add(s.SubnetID)
+	}
+	for _, rt := range env.RouteTables {
+		add(rt.RouteTableID)
+	}
+	for _, sg := range env.SecurityGroups {
+		add(sg.GroupID)
+	}
+	for _, igw := range env.InternetGateways {
+		add(igw.InternetGatewayID)
+	}
+	for _, ngw := range env.NatGateways {
+		add(ngw.NatGatewayID)
+	}
+	for _, tgw := range env.TransitGateways {
+		add(tgw.TransitGatewayID)
+	}
+	for _, a := range env.TGWAttachments {
+		add(a.AttachmentID)
+	}
+
+	check := func(owner, id string) {
+		if !ids[id] {
+			t.Errorf("%s references %s, which was not generated", owner, id)
+		}
+	}
+	for _, s := range env.Subnets {
+		check(s.SubnetID, s.VpcID)
+	}
+	for _, rt := range env.RouteTables {
+		check(rt.RouteTableID, rt.VpcID)
+		for _, subnetID := range rt.SubnetIDs {
+			check(rt.RouteTableID, subnetID)
+		}
+		for _, route := range rt.Routes {
+			if route.Target.ID != "local" {
+				check(rt.RouteTableID, route.Target.ID)
+			}
+		}
+	}
+	for _, sg := range env.SecurityGroups {
+		check(sg.GroupID, sg.VpcID)
+		for _, rule := range sg.Rules {
+			if rule.GroupID != "" {
+				check(sg.GroupID, rule.GroupID)
+			}
+			if rule.GroupVpcID != "" {
+				check(sg.GroupID, rule.GroupVpcID)
+			}
+		}
+	}
+	for _, ngw := range env.NatGateways {
+		check(ngw.NatGatewayID, ngw.SubnetID)
+	}
+	for _, a := range env.TGWAttachments {
+		check(a.AttachmentID, a.TransitGatewayID)
+		check(a.AttachmentID, a.ResourceID)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, size := range Sizes {
+		got, err := ParseSize(size.Name)
+		if err != nil || got != size {
+			t.Errorf("ParseSize(%q) = %+v, %v", size.Name, got, err)
+		}
+	}
+	if _, err := ParseSize("huge"); err == nil {
+		t.Error("ParseSize(\"huge\") succeeded")
+	}
+}
@@ -0,0 +1,210 @@
+package vpc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// asgNameTag is the tag Auto Scaling puts on the instances it launches
+const asgNameTag = "aws:autoscaling:groupName"
+
+// RouteApplianceInfo describes an instance or network interface that route tables send traffic to
+// These are NAT instances and other routing appliances that predate, or stand in for, managed gateways
+type RouteApplianceInfo struct {
+	InstanceID         string            `json:"instance_id"`          // ID of the instance (empty for a network interface without an instance)
+	NetworkInterfaceID string            `json:"network_interface_id"` // ID of the network interface the routes point to (empty when routes only name the instance)
+	Arn                string            `json:"arn"`                  // ARN of the instance, or of the interface without an instance (empty if the account is unknown)
+	Region             string            `json:"region"`               // Region of the instance or interface
+	SubnetID           string            `json:"subnet_id"`            // ID of the subnet the instance or interface is in
+	VpcID              string            `json:"vpc_id"`               // ID of the VPC the instance or interface is in
+	PrivateIp          string            `json:"private_ip"`           // Primary private IP address
+	InstanceType       string            `json:"instance_type"`        // Instance type (empty without an instance)
+	State              string            `json:"state"`                // Instance or interface state, or not-found if it no longer exists
+	SourceDestCheck    bool              `json:"source_dest_check"`    // Whether source/destination checking is enabled on the routed interface
+	IsRoutingAppliance bool              `json:"is_routing_appliance"` // Whether the check is disabled, so the interface can forward traffic
+	IsNATInstance      bool              `json:"is_nat_instance"`      // Whether it is a routing appliance that receives a default route
+	AutoScalingGroup   string            `json:"auto_scaling_group"`   // Name of the Auto Scaling group the instance belongs to (if any)
+	RouteTableIDs      []string          `json:"route_table_ids"`      // IDs of the route tables with routes to it
+	Destinations       []string          `json:"destinations"`         // Destinations routed to it
+	Tags               map[string]string `json:"tags"`                 // Key-value tags of the instance, or of the interface without an instance
+	TagList            []Tag             `json:"tag_list"`             // Tags in API order, including tags without a value
+}
+
+// GetRouteAppliances looks up the instances and network interfaces that routes target
+// Source/destination checking is read from the routed network interface when the route names one,
+// since it is a per-interface setting; otherwise from the instance (its primary interface)
+// ctx: Context for the request, allowing for timeout and cancellation
+// routeTables: Route tables from GetRouteTables
+// Returns: One entry per routed instance or standalone interface sorted by ID, or error if the operation fails
+func (s *Scanner) GetRouteAppliances(ctx context.Context, routeTables []RouteTableInfo) ([]RouteApplianceInfo, error) {
+	appliances := []RouteApplianceInfo{}
+
+	// Collect the routed instances and interfaces
+	eniIDs := make(map[string]bool)
+	instanceIDs := make(map[string]bool)
+	for _, rt := range routeTables {
+		for _, route := range rt.Routes {
+			if route.NetworkInterfaceID != "" {
+				eniIDs[route.NetworkInterfaceID] = true
+			}
+			if route.InstanceID != "" {
+				instanceIDs[route.InstanceID] = true
+			}
+		}
+	}
+	if len(eniIDs) == 0 && len(instanceIDs) == 0 {
+		return appliances, nil
+	}
+
+	// Filters instead of ID lists, so routes to deleted resources do not fail the whole call
+	enis := make(map[string]types.NetworkInterface)
+	if len(eniIDs) > 0 {
+		result, err := s.ec2Client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []types.Filter{{Name: aws.String("network-interface-id"), Values: sortedSet(eniIDs)}},
+		})
+		if err != nil {
+			return nil, newScanError("network interfaces", "DescribeNetworkInterfaces", err)
+		}
+		for _, eni := range result.NetworkInterfaces {
+			enis[aws.ToString(eni.NetworkInterfaceId)] = eni
+			if eni.Attachment != nil && aws.ToString(eni.Attachment.InstanceId) != "" {
+				instanceIDs[aws.ToString(eni.Attachment.InstanceId)] = true
+			}
+		}
+	}
+
+	instances := make(map[string]types.Instance)
+	if len(instanceIDs) > 0 {
+		result, err := s.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+			Filters: []types.Filter{{Name: aws.String("instance-id"), Values: sortedSet(instanceIDs)}},
+		})
+		if err != nil {
+			return nil, newScanError("instances", "DescribeInstances", err)
+		}
+		for _, reservation := range result.Reservations {
+			for _, instance := range reservation.Instances {
+				instances[aws.ToString(instance.InstanceId)] = instance
+			}
+		}
+	}
+
+	// Group the routes by the instance behind them, or by interface when there is no instance
+	byKey := make(map[string]*RouteApplianceInfo)
+	var keys []string
+	for _, rt := range routeTables {
+		for _, route := range rt.Routes {
+			if route.NetworkInterfaceID == "" && route.InstanceID == "" {
+				continue
+			}
+			instanceID := route.InstanceID
+			if eni, ok := enis[route.NetworkInterfaceID]; ok && instanceID == "" && eni.Attachment != nil {
+				instanceID = aws.ToString(eni.Attachment.InstanceId)
+			}
+			key := instanceID
+			if key == "" {
+				key = route.NetworkInterfaceID
+			}
+
+			appliance, ok := byKey[key]
+			if !ok {
+				appliance = newRouteAppliance(instanceID, route.NetworkInterfaceID, instances, enis)
+				byKey[key] = appliance
+				keys = append(keys, key)
+			}
+			if !containsString(appliance.RouteTableIDs, rt.RouteTableID) {
+				appliance.RouteTableIDs = append(appliance.RouteTableIDs, rt.RouteTableID)
+			}
+			destination := route.DestinationCidrBlock
+			if destination == "" {
+				destination = route.DestinationIpv6Block
+			}
+			if !containsString(appliance.Destinations, destination) {
+				appliance.Destinations = append(appliance.Destinations, destination)
+			}
+			if appliance.IsRoutingAppliance && (destination == "0.0.0.0/0" || destination == "::/0") {
+				appliance.IsNATInstance = appliance.InstanceID != ""
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		appliance := byKey[key]
+		appliance.Region = s.region
+		if appliance.InstanceID != "" {
+			appliance.Arn = s.arn(arnbuild.TypeInstance, "", appliance.InstanceID)
+		} else {
+			appliance.Arn = s.arn(arnbuild.TypeNetworkInterface, "", appliance.NetworkInterfaceID)
+		}
+		appliances = append(appliances, *appliance)
+	}
+	return appliances, nil
+}
+
+// newRouteAppliance fills in an appliance from its instance, or from its interface when there is no instance
+func newRouteAppliance(instanceID, eniID string, instances map[string]types.Instance, enis map[string]types.NetworkInterface) *RouteApplianceInfo {
+	appliance := &RouteApplianceInfo{
+		InstanceID:         instanceID,
+		NetworkInterfaceID: eniID,
+		State:              "not-found",
+		RouteTableIDs:      []string{},
+		Destinations:       []string{},
+		Tags:               make(map[string]string),
+		TagList:            []Tag{},
+	}
+
+	eni, eniFound := enis[eniID]
+	instance, instanceFound := instances[instanceID]
+	switch {
+	case instanceFound:
+		appliance.SubnetID = aws.ToString(instance.SubnetId)
+		appliance.VpcID = aws.ToString(instance.VpcId)
+		appliance.PrivateIp = aws.ToString(instance.PrivateIpAddress)
+		appliance.InstanceType = string(instance.InstanceType)
+		if instance.State != nil {
+			appliance.State = string(instance.State.Name)
+		}
+		appliance.SourceDestCheck = aws.ToBool(instance.SourceDestCheck)
+		appliance.Tags = convertTags(instance.Tags)
+		appliance.TagList = convertTagList(instance.Tags)
+		appliance.AutoScalingGroup = appliance.Tags[asgNameTag]
+	case eniFound:
+		appliance.SubnetID = aws.ToString(eni.SubnetId)
+		appliance.VpcID = aws.ToString(eni.VpcId)
+		appliance.PrivateIp = aws.ToString(eni.PrivateIpAddress)
+		appliance.State = string(eni.Status)
+		appliance.Tags = convertTags(eni.TagSet)
+		appliance.TagList = convertTagList(eni.TagSet)
+	}
+	if eniFound {
+		appliance.SourceDestCheck = aws.ToBool(eni.SourceDestCheck)
+	}
+	appliance.IsRoutingAppliance = (instanceFound || eniFound) && !appliance.SourceDestCheck
+	return appliance
+}
+
+// sortedSet returns the members of a set in sorted order
+func sortedSet(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for member := range set {
+		result = append(result, member)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// applianceResponses describe a NAT instance routed by instance and by interface, an appliance interface
+// without an instance, and an instance in an Auto Scaling group with source/destination checking enabled
+var applianceResponses = map[string]string{
+	"DescribeNetworkInterfaces": `<networkInterfaceSet>
+		<item><networkInterfaceId>eni-0nat</networkInterfaceId><subnetId>subnet-0public</subnetId><vpcId>vpc-0legacy</vpcId>
+			<privateIpAddress>10.0.0.10</privateIpAddress><status>in-use</status><sourceDestCheck>false</sourceDestCheck>
+			<attachment><instanceId>i-0nat</instanceId></attachment></item>
+		<item><networkInterfaceId>eni-0appliance</networkInterfaceId><subnetId>subnet-0inspect</subnetId><vpcId>vpc-0legacy</vpcId>
+			<privateIpAddress>10.0.5.20</privateIpAddress><status>available</status><sourceDestCheck>false</sourceDestCheck>
+			<tagSet><item><key>Name</key><value>firewall</value></item></tagSet></item>
+	</networkInterfaceSet>`,
+	"DescribeInstances": `<reservationSet><item><instancesSet>
+		<item><instanceId>i-0nat</instanceId><instanceType>t3.micro</instanceType><subnetId>subnet-0public</subnetId><vpcId>vpc-0legacy</vpcId>
+			<privateIpAddress>10.0.0.10</privateIpAddress><instanceState><code>16</code><name>running</name></instanceState>
+			<sourceDestCheck>true</sourceDestCheck><tagSet><item><key>Name</key><value>nat</value></item></tagSet></item>
+		<item><instanceId>i-0check</instanceId><instanceType>c5.large</instanceType><subnetId>subnet-0private</subnetId><vpcId>vpc-0legacy</vpcId>
+			<privateIpAddress>10.0.2.30</privateIpAddress><instanceState><code>16</code><name>running</name></instanceState>
+			<sourceDestCheck>true</sourceDestCheck><tagSet><item><key>aws:autoscaling:groupName</key><value>vpn</value></item></tagSet></item>
+	</instancesSet></item></reservationSet>`,
+}
+
+// TestGetRouteAppliances checks that routes are grouped by the instance behind them, that source/destination
+// checking is read from the routed interface, and that routes to deleted instances are kept as not-found
+func TestGetRouteAppliances(t *testing.T) {
+	scanner := newTestScanner(t, applianceResponses, 0)
+	scanner.SetAccountID("111122223333")
+	routeTables := []RouteTableInfo{
+		{RouteTableID: "rtb-0a", Routes: []RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", Target: RouteTarget{Type: RouteTargetLocal, ID: "local"}},
+			{DestinationCidrBlock: "0.0.0.0/0", InstanceID: "i-0nat", NetworkInterfaceID: "eni-0nat"},
+		}},
+		{RouteTableID: "rtb-0b", Routes: []RouteInfo{
+			{DestinationCidrBlock: "0.0.0.0/0", InstanceID: "i-0nat"},
+			{DestinationCidrBlock: "10.50.0.0/16", NetworkInterfaceID: "eni-0appliance"},
+		}},
+		{RouteTableID: "rtb-0c", Routes: []RouteInfo{
+			{DestinationCidrBlock: "0.0.0.0/0", NetworkInterfaceID: "eni-0appliance"},
+			{DestinationCidrBlock: "192.168.0.0/16", InstanceID: "i-0check"},
+			{DestinationCidrBlock: "172.16.0.0/12", InstanceID: "i-0gone"},
+		}},
+	}
+
+	got, err := scanner.GetRouteAppliances(context.Background(), routeTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RouteApplianceInfo{
+		{NetworkInterfaceID: "eni-0appliance", Arn: "arn:aws:ec2:eu-west-1:111122223333:network-interface/eni-0appliance", Region: "eu-west-1",
+			SubnetID: "subnet-0inspect", VpcID: "vpc-0legacy", PrivateIp: "10.0.5.20", State: "available", IsRoutingAppliance: true,
+			RouteTableIDs: []string{"rtb-0b", "rtb-0c"}, Destinations: []string{"10.50.0.0/16", "0.0.0.0/0"},
+			Tags: map[string]string{"Name": "firewall"}, TagList: []Tag{{Key: "Name", Value: "firewall"}}},
+		{InstanceID: "i-0check", Arn: "arn:aws:ec2:eu-west-1:111122223333:instance/i-0check", Region: "eu-west-1",
+			SubnetID: "subnet-0private", VpcID: "vpc-0legacy", PrivateIp: "10.0.2.30", InstanceType: "c5.large", State: "running",
+			SourceDestCheck: true, AutoScalingGroup: "vpn", RouteTableIDs: []string{"rtb-0c"}, Destinations: []string{"192.168.0.0/16"},
+			Tags: map[string]string{"aws:autoscaling:groupName": "vpn"}, TagList: []Tag{{Key: "aws:autoscaling:groupName", Value: "vpn"}}},
+		{InstanceID: "i-0gone", Arn: "arn:aws:ec2:eu-west-1:111122223333:instance/i-0gone", Region: "eu-west-1", State: "not-found",
+			RouteTableIDs: []string{"rtb-0c"}, Destinations: []string{"172.16.0.0/12"}, Tags: map[string]string{}, TagList: []Tag{}},
+		{InstanceID: "i-0nat", NetworkInterfaceID: "eni-0nat", Arn: "arn:aws:ec2:eu-west-1:111122223333:instance/i-0nat", Region: "eu-west-1",
+			SubnetID: "subnet-0public", VpcID: "vpc-0legacy", PrivateIp: "10.0.0.10", InstanceType: "t3.micro", State: "running",
+			IsRoutingAppliance: true, IsNATInstance: true, RouteTableIDs: []string{"rtb-0a", "rtb-0b"}, Destinations: []string{"0.0.0.0/0"},
+			Tags: map[string]string{"Name": "nat"}, TagList: []Tag{{Key: "Name", Value: "nat"}}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d appliances, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("appliance %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package vpc
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// CarrierGatewayInfo contains information about a carrier gateway, the path from the Wavelength zone subnets of a
+// VPC to the telecommunication carrier network
+type CarrierGatewayInfo struct {
+	CarrierGatewayID string            `json:"carrier_gateway_id"` // Unique identifier for the carrier gateway
+	Arn              string            `json:"arn"`                // ARN of the gateway
+	Region           string            `json:"region"`             // Region of the gateway
+	VpcID            string            `json:"vpc_id"`             // VPC the gateway belongs to
+	State            string            `json:"state"`              // State of the gateway (pending, available, deleting, deleted)
+	OwnerID          string            `json:"owner_id"`           // Account that owns the gateway
+	Tags             map[string]string `json:"tags"`               // Key-value tags associated with the gateway
+	TagList          []Tag             `json:"tag_list"`           // Tags in API order, including tags without a value
+	ManagedBy        string            `json:"managed_by"`         // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// GetCarrierGateways retrieves information about all carrier gateways in the configured AWS region
+// Routes to cagw-* targets lead here; their target has the type carrier-gateway.
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of CarrierGatewayInfo structs, or error if the operation fails
+func (s *Scanner) GetCarrierGateways(ctx context.Context, opts ...ScanOptions) ([]CarrierGatewayInfo, error) {
+	gateways := []CarrierGatewayInfo{}
+
+	paginator := ec2.NewDescribeCarrierGatewaysPaginator(s.ec2Client, &ec2.DescribeCarrierGatewaysInput{Filters: tagFilters(opts)})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("carrier gateways", "DescribeCarrierGateways", err)
+		}
+
+		for _, cagw := range result.CarrierGateways {
+			cagwID := s.required("carrier gateway", "", "CarrierGatewayId", cagw.CarrierGatewayId)
+			ownerID := aws.ToString(cagw.OwnerId)
+			gateways = append(gateways, CarrierGatewayInfo{
+				CarrierGatewayID: cagwID,
+				Arn:              s.arn(arnbuild.TypeCarrierGateway, ownerID, cagwID),
+				Region:           s.region,
+				VpcID:            s.required("carrier gateway", cagwID, "VpcId", cagw.VpcId),
+				State:            enumValue(s, "carrier gateway", cagwID, "State", cagw.State),
+				OwnerID:          ownerID,
+				Tags:             convertTags(cagw.Tags),
+				TagList:          convertTagList(cagw.Tags),
+			})
+		}
+	}
+
+	return gateways, nil
+}
@@ -0,0 +1,202 @@
+package vpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bareResources answers every scanned action with one resource that has no tags and no children:
+// a security group without rules, a route table without routes or associations, an attachment without an
+// association, and so on
+var bareResources = map[string]string{
+	"DescribeVpcs":                      `<vpcSet><item><vpcId>vpc-1</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item></vpcSet>`,
+	"DescribeSubnets":                   `<subnetSet><item><subnetId>subnet-1</subnetId><vpcId>vpc-1</vpcId><cidrBlock>10.0.0.0/24</cidrBlock></item></subnetSet>`,
+	"DescribeRouteTables":               `<routeTableSet><item><routeTableId>rtb-1</routeTableId><vpcId>vpc-1</vpcId></item></routeTableSet>`,
+	"DescribeSecurityGroups":            `<securityGroupInfo><item><groupId>sg-1</groupId><groupName>empty</groupName><vpcId>vpc-1</vpcId></item></securityGroupInfo>`,
+	"DescribeInternetGateways":          `<internetGatewaySet><item><internetGatewayId>igw-1</internetGatewayId></item></internetGatewaySet>`,
+	"DescribeNatGateways":               `<natGatewaySet><item><natGatewayId>nat-1</natGatewayId><vpcId>vpc-1</vpcId><subnetId>subnet-1</subnetId></item></natGatewaySet>`,
+	"DescribeTransitGateways":           `<transitGatewaySet><item><transitGatewayId>tgw-1</transitGatewayId></item></transitGatewaySet>`,
+	"DescribeTransitGatewayAttachments": `<transitGatewayAttachments><item><transitGatewayAttachmentId>tgw-attach-1</transitGatewayAttachmentId><transitGatewayId>tgw-1</transitGatewayId></item></transitGatewayAttachments>`,
+	"DescribeNetworkAcls":               `<networkAclSet><item><networkAclId>acl-1</networkAclId><vpcId>vpc-1</vpcId></item></networkAclSet>`,
+	"DescribeVpcPeeringConnections":     `<vpcPeeringConnectionSet><item><vpcPeeringConnectionId>pcx-1</vpcPeeringConnectionId></item></vpcPeeringConnectionSet>`,
+	"DescribeVpnGateways":               `<vpnGatewaySet><item><vpnGatewayId>vgw-1</vpnGatewayId></item></vpnGatewaySet>`,
+	"DescribeCustomerGateways":          `<customerGatewaySet><item><customerGatewayId>cgw-1</customerGatewayId></item></customerGatewaySet>`,
+	"DescribeVpnConnections":            `<vpnConnectionSet><item><vpnConnectionId>vpn-1</vpnConnectionId></item></vpnConnectionSet>`,
+	"DescribeCarrierGateways":           `<carrierGatewaySet><item><carrierGatewayId>cagw-1</carrierGatewayId><vpcId>vpc-1</vpcId></item></carrierGatewaySet>`,
+	"DescribeDhcpOptions":               `<dhcpOptionsSet><item><dhcpOptionsId>dopt-1</dhcpOptionsId></item></dhcpOptionsSet>`,
+	"DescribeNetworkInterfaces":         `<networkInterfaceSet><item><networkInterfaceId>eni-1</networkInterfaceId><vpcId>vpc-1</vpcId></item></networkInterfaceSet>`,
+	"DescribeFlowLogs":                  `<flowLogSet><item><flowLogId>fl-1</flowLogId><resourceId>vpc-1</resourceId></item></flowLogSet>`,
+	"DescribeAddresses":                 `<addressesSet><item><allocationId>eipalloc-1</allocationId><publicIp>203.0.113.1</publicIp></item></addressesSet>`,
+	"DescribeVpcEndpoints":              `<vpcEndpointSet><item><vpcEndpointId>vpce-1</vpcEndpointId><vpcId>vpc-1</vpcId></item></vpcEndpointSet>`,
+	"DescribeTransitGatewayRouteTables": `<transitGatewayRouteTables><item><transitGatewayRouteTableId>tgw-rtb-1</transitGatewayRouteTableId><transitGatewayId>tgw-1</transitGatewayId></item></transitGatewayRouteTables>`,
+	"DescribeInstances":                 `<reservationSet><item><instancesSet><item><instanceId>i-1</instanceId><subnetId>subnet-1</subnetId><vpcId>vpc-1</vpcId></item></instancesSet></item></reservationSet>`,
+}
+
+// nilCollections returns the paths of the nil slices and maps in v, which encoding/json writes as null
+func nilCollections(v reflect.Value, path string) []string {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return nilCollections(v.Elem(), path)
+	case reflect.Struct:
+		var found []string
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" {
+				found = append(found, nilCollections(v.Field(i), path)...)
+				continue
+			}
+			found = append(found, nilCollections(v.Field(i), path+"."+name)...)
+		}
+		return found
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return []string{path}
+		}
+		var found []string
+		if v.Kind() == reflect.Slice {
+			for i := 0; i < v.Len(); i++ {
+				found = append(found, nilCollections(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		} else {
+			for _, key := range v.MapKeys() {
+				found = append(found, nilCollections(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key))...)
+			}
+		}
+		return found
+	}
+	return nil
+}
+
+// TestCollectionsMarshalEmpty checks that no scanner returns a collection that marshals as null
+func TestCollectionsMarshalEmpty(t *testing.T) {
+	scanner := newTestScanner(t, bareResources, 0)
+	ctx := context.Background()
+
+	var routeTables []RouteTableInfo
+	scans := map[string]func() (interface{}, error){
+		"vpcs":    func() (interface{}, error) { return scanner.GetVPCs(ctx) },
+		"subnets": func() (interface{}, error) { return scanner.GetSubnets(ctx) },
+		"route_tables": func() (interface{}, error) {
+			tables, err := scanner.GetRouteTables(ctx)
+			routeTables = tables
+			return tables, err
+		},
+		"security_groups":    func() (interface{}, error) { return scanner.GetSecurityGroups(ctx) },
+		"internet_gateways":  func() (interface{}, error) { return scanner.GetInternetGateways(ctx) },
+		"nat_gateways":       func() (interface{}, error) { return scanner.GetNatGateways(ctx) },
+		"transit_gateways":   func() (interface{}, error) { return scanner.GetTransitGateways(ctx) },
+		"tgw_attachments":    func() (interface{}, error) { return scanner.GetTransitGatewayAttachments(ctx) },
+		"tgw_route_tables":   func() (interface{}, error) { return scanner.GetTransitGatewayRouteTables(ctx) },
+		"network_acls":       func() (interface{}, error) { return scanner.GetNetworkACLs(ctx) },
+		"vpc_peerings":       func() (interface{}, error) { return scanner.GetVpcPeeringConnections(ctx) },
+		"vpn_gateways":       func() (interface{}, error) { return scanner.GetVpnGateways(ctx) },
+		"customer_gateways":  func() (interface{}, error) { return scanner.GetCustomerGateways(ctx) },
+		"vpn_connections":    func() (interface{}, error) { return scanner.GetVpnConnections(ctx) },
+		"carrier_gateways":   func() (interface{}, error) { return scanner.GetCarrierGateways(ctx) },
+		"dhcp_options":       func() (interface{}, error) { return scanner.GetDhcpOptions(ctx) },
+		"network_interfaces": func() (interface{}, error) { return scanner.GetNetworkInterfaces(ctx) },
+		"flow_logs":          func() (interface{}, error) { return scanner.GetFlowLogs(ctx) },
+		"elastic_ips":        func() (interface{}, error) { return scanner.GetElasticIPs(ctx) },
+		"vpc_endpoints":      func() (interface{}, error) { return scanner.GetVpcEndpoints(ctx) },
+		"instances":          func() (interface{}, error) { return scanner.GetInstances(ctx) },
+	}
+
+	for _, name := range []string{"vpcs", "subnets", "route_tables", "security_groups", "internet_gateways", "nat_gateways",
+		"transit_gateways", "tgw_attachments", "tgw_route_tables", "network_acls", "vpc_peerings", "vpn_gateways",
+		"customer_gateways", "vpn_connections", "carrier_gateways", "dhcp_options", "network_interfaces", "flow_logs",
+		"elastic_ips", "vpc_endpoints", "instances"} {
+		t.Run(name, func(t *testing.T) {
+			resources, err := scans[name]()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.ValueOf(resources).Len() != 1 {
+				t.Fatalf("got %d resources, want the one of the fixture", reflect.ValueOf(resources).Len())
+			}
+			for _, path := range nilCollections(reflect.ValueOf(resources), name) {
+				t.Errorf("%s marshals as null", path)
+			}
+		})
+	}
+
+	t.Run("route_appliances", func(t *testing.T) {
+		appliances, err := scanner.GetRouteAppliances(ctx, routeTables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range nilCollections(reflect.ValueOf(appliances), "route_appliances") {
+			t.Errorf("%s marshals as null", path)
+		}
+	})
+}
+
+// TestEmptyCollectionsJSON checks the JSON of the resources the request named: an empty security group with
+// its rule counts, a route table without routes, an untagged VPC and an attachment without an association
+func TestEmptyCollectionsJSON(t *testing.T) {
+	scanner := newTestScanner(t, bareResources, 0)
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		scan func() (interface{}, error)
+		want []string // Fragments the JSON of the first resource must contain
+	}{
+		{name: "security group", scan: func() (interface{}, error) { return scanner.GetSecurityGroups(ctx) },
+			want: []string{`"rules":[]`, `"ingress_rule_count":0`, `"egress_rule_count":0`, `"tags":{}`}},
+		{name: "route table", scan: func() (interface{}, error) { return scanner.GetRouteTables(ctx) },
+			want: []string{`"routes":[]`, `"subnet_ids":[]`, `"tags":{}`}},
+		{name: "VPC", scan: func() (interface{}, error) { return scanner.GetVPCs(ctx) },
+			want: []string{`"associate_cidr_blocks":[]`, `"tags":{}`, `"tag_list":[]`}},
+		{name: "transit gateway attachment", scan: func() (interface{}, error) { return scanner.GetTransitGatewayAttachments(ctx) },
+			want: []string{`"association":{}`, `"subnet_ids":[]`, `"tags":{}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := tt.scan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(reflect.ValueOf(resources).Index(0).Interface())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("missing %s in %s", want, data)
+				}
+			}
+		})
+	}
+}
+
+// TestResourcesCarryRegion checks that the scanned resources record the region of the scanner
+func TestResourcesCarryRegion(t *testing.T) {
+	scanner := newTestScanner(t, bareResources, 0)
+	scan, err := scanner.ScanAll(context.Background(), ScanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := reflect.ValueOf(scan).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		if section.Kind() != reflect.Slice {
+			continue
+		}
+		for j := 0; j < section.Len(); j++ {
+			if region := section.Index(j).FieldByName("Region"); region.IsValid() && region.String() != scanner.region {
+				t.Errorf("%s[%d] has region %q, want %q", sections.Type().Field(i).Name, j, region.String(), scanner.region)
+			}
+		}
+	}
+}
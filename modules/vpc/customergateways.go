@@ -0,0 +1,66 @@
+package vpc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// CustomerGatewayInfo contains information about a customer gateway, the on-premises end of a site-to-site VPN
+type CustomerGatewayInfo struct {
+	CustomerGatewayID string            `json:"customer_gateway_id"` // Unique identifier for the customer gateway
+	Arn               string            `json:"arn"`                 // ARN of the gateway
+	Region            string            `json:"region"`              // Region of the gateway
+	BgpAsn            int64             `json:"bgp_asn"`             // BGP ASN of the customer side
+	IpAddress         string            `json:"ip_address"`          // Public IP address of the device's outside interface (empty with a certificate)
+	CertificateArn    string            `json:"certificate_arn"`     // ARN of the private certificate the device authenticates with (empty with an IP address)
+	DeviceName        string            `json:"device_name"`         // Name of the customer device (empty unless set)
+	State             string            `json:"state"`               // State of the gateway (pending, available, deleting, deleted)
+	Type              string            `json:"type"`                // Type of VPN connection the gateway supports (ipsec.1)
+	Tags              map[string]string `json:"tags"`                // Key-value tags associated with the gateway
+	TagList           []Tag             `json:"tag_list"`            // Tags in API order, including tags without a value
+	ManagedBy         string            `json:"managed_by"`          // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// GetCustomerGateways retrieves information about all customer gateways in the configured AWS region
+// VPN connections reference their customer gateway by ID.
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of CustomerGatewayInfo structs, or error if the operation fails
+func (s *Scanner) GetCustomerGateways(ctx context.Context, opts ...ScanOptions) ([]CustomerGatewayInfo, error) {
+	// DescribeCustomerGateways is not paginated
+	result, err := s.ec2Client.DescribeCustomerGateways(ctx, &ec2.DescribeCustomerGatewaysInput{Filters: tagFilters(opts)})
+	if err != nil {
+		return nil, newScanError("customer gateways", "DescribeCustomerGateways", err)
+	}
+
+	gateways := []CustomerGatewayInfo{}
+	for _, cgw := range result.CustomerGateways {
+		cgwID := s.required("customer gateway", "", "CustomerGatewayId", cgw.CustomerGatewayId)
+		info := CustomerGatewayInfo{
+			CustomerGatewayID: cgwID,
+			Arn:               s.arn(arnbuild.TypeCustomerGateway, "", cgwID),
+			Region:            s.region,
+			IpAddress:         aws.ToString(cgw.IpAddress),
+			CertificateArn:    aws.ToString(cgw.CertificateArn),
+			DeviceName:        aws.ToString(cgw.DeviceName),
+			State:             s.required("customer gateway", cgwID, "State", cgw.State),
+			Type:              s.required("customer gateway", cgwID, "Type", cgw.Type),
+			Tags:              convertTags(cgw.Tags),
+			TagList:           convertTagList(cgw.Tags),
+		}
+		// The API returns the ASN as a string, as 4-byte ASNs do not fit the int32 of other fields
+		if asn := s.required("customer gateway", cgwID, "BgpAsn", cgw.BgpAsn); asn != "" {
+			if info.BgpAsn, err = strconv.ParseInt(asn, 10, 64); err != nil {
+				s.warn("customer gateway", cgwID, "BgpAsn", asn, ProblemUnrecognized)
+			}
+		}
+		gateways = append(gateways, info)
+	}
+
+	return gateways, nil
+}
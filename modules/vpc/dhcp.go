@@ -0,0 +1,229 @@
+package vpc
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+	"aws-documentor/modules/netcalc"
+)
+
+// AmazonProvidedDNS is the domain-name-servers value that selects the VPC's own resolver
+const AmazonProvidedDNS = "AmazonProvidedDNS"
+
+// DhcpOptionsInfo contains information about a DHCP options set
+// VPCs reference their set by DhcpOptionsID; DhcpOptionsByID joins them.
+type DhcpOptionsInfo struct {
+	DhcpOptionsID      string                  `json:"dhcp_options_id"`      // Unique identifier for the options set
+	Arn                string                  `json:"arn"`                  // ARN of the options set
+	Region             string                  `json:"region"`               // Region of the options set
+	OwnerID            string                  `json:"owner_id"`             // Account that owns the options set
+	DomainName         string                  `json:"domain_name"`          // Domain name handed to instances (empty if not set)
+	DomainNameServers  []string                `json:"domain_name_servers"`  // DNS servers: AmazonProvidedDNS and/or IP addresses
+	NtpServers         []string                `json:"ntp_servers"`          // NTP server addresses
+	NetbiosNameServers []string                `json:"netbios_name_servers"` // NetBIOS name servers (WINS) addresses
+	NetbiosNodeType    string                  `json:"netbios_node_type"`    // NetBIOS node type (1, 2, 4 or 8; empty if not set)
+	Configurations     []DhcpConfigurationInfo `json:"configurations"`       // Every option of the set sorted by key, including keys without a field above
+	Tags               map[string]string       `json:"tags"`                 // Key-value tags associated with the options set
+	TagList            []Tag                   `json:"tag_list"`             // Tags in API order, including tags without a value
+	ManagedBy          string                  `json:"managed_by"`           // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// VPCWithDhcpOptions is a VPC with its DHCP options set resolved, as -verbose prints the VPCs
+type VPCWithDhcpOptions struct {
+	VPCInfo
+	DhcpOptions *DhcpOptionsInfo `json:"dhcp_options"` // The set dhcp_options_id names (null for "default" or a set that was not found)
+}
+
+// DhcpConfigurationInfo is one option of a DHCP options set
+type DhcpConfigurationInfo struct {
+	Key    string   `json:"key"`    // Option name (domain-name, domain-name-servers, ntp-servers, netbios-name-servers, ...)
+	Values []string `json:"values"` // Values in API order, which is the order clients try the servers in
+}
+
+// EffectiveDNS describes how instances in a VPC resolve names
+type EffectiveDNS struct {
+	DhcpOptionsID   string   `json:"dhcp_options_id"`  // DHCP options set of the VPC (default when none is associated)
+	ResolverAddress string   `json:"resolver_address"` // Address of the Amazon resolver (primary CIDR base + 2)
+	UsesAmazonDNS   bool     `json:"uses_amazon_dns"`  // Whether the DHCP options hand out the Amazon resolver
+	CustomServers   []string `json:"custom_servers"`   // DNS servers from the DHCP options other than AmazonProvidedDNS
+	DomainName      string   `json:"domain_name"`      // Domain name from the DHCP options (empty when not set)
+	DnsSupport      bool     `json:"dns_support"`      // Whether the Amazon resolver answers in the VPC (enableDnsSupport)
+	DnsHostnames    bool     `json:"dns_hostnames"`    // Whether instances with public IPs get public DNS hostnames (enableDnsHostnames)
+}
+
+// GetDhcpOptions retrieves information about all DHCP options sets in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of DhcpOptionsInfo structs, or error if the operation fails
+func (s *Scanner) GetDhcpOptions(ctx context.Context, opts ...ScanOptions) ([]DhcpOptionsInfo, error) {
+	options := []DhcpOptionsInfo{}
+
+	paginator := ec2.NewDescribeDhcpOptionsPaginator(s.ec2Client, &ec2.DescribeDhcpOptionsInput{Filters: tagFilters(opts)})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("DHCP options", "DescribeDhcpOptions", err)
+		}
+
+		for _, set := range result.DhcpOptions {
+			info := DhcpOptionsInfo{
+				DhcpOptionsID:      aws.ToString(set.DhcpOptionsId),
+				Arn:                s.arn(arnbuild.TypeDhcpOptions, aws.ToString(set.OwnerId), aws.ToString(set.DhcpOptionsId)),
+				Region:             s.region,
+				OwnerID:            aws.ToString(set.OwnerId),
+				DomainNameServers:  []string{},
+				NtpServers:         []string{},
+				NetbiosNameServers: []string{},
+				Configurations:     []DhcpConfigurationInfo{},
+				Tags:               convertTags(set.Tags),
+				TagList:            convertTagList(set.Tags),
+			}
+			for _, configuration := range set.DhcpConfigurations {
+				key := aws.ToString(configuration.Key)
+				values := attributeValues(configuration.Values)
+				switch key {
+				case "domain-name":
+					if len(values) > 0 {
+						info.DomainName = values[0]
+					}
+				case "domain-name-servers":
+					info.DomainNameServers = values
+				case "ntp-servers":
+					info.NtpServers = values
+				case "netbios-name-servers":
+					info.NetbiosNameServers = values
+				case "netbios-node-type":
+					if len(values) > 0 {
+						info.NetbiosNodeType = values[0]
+					}
+				}
+				info.Configurations = append(info.Configurations, DhcpConfigurationInfo{Key: key, Values: values})
+			}
+			// The API returns the options in no documented order; sorted keys keep the JSON of a set stable
+			sort.Slice(info.Configurations, func(i, j int) bool { return info.Configurations[i].Key < info.Configurations[j].Key })
+			options = append(options, info)
+		}
+	}
+
+	return options, nil
+}
+
+// AddEffectiveDNS scans the DHCP options and DNS attributes of the given VPCs and sets their EffectiveDNS
+// ctx: Context for the request, allowing for timeout and cancellation
+// vpcs: VPCs from GetVPCs, updated in place
+// Returns: Error if an operation fails; the VPCs are left unchanged in that case
+func (s *Scanner) AddEffectiveDNS(ctx context.Context, vpcs []VPCInfo) error {
+	options, err := s.GetDhcpOptions(ctx)
+	if err != nil {
+		return err
+	}
+	byID := DhcpOptionsByID(options)
+
+	settings := make([]*EffectiveDNS, len(vpcs))
+	for i, v := range vpcs {
+		support, err := s.vpcAttribute(ctx, v.VpcID, types.VpcAttributeNameEnableDnsSupport)
+		if err != nil {
+			return err
+		}
+		hostnames, err := s.vpcAttribute(ctx, v.VpcID, types.VpcAttributeNameEnableDnsHostnames)
+		if err != nil {
+			return err
+		}
+		settings[i] = ResolveEffectiveDNS(v, byID[v.DhcpOptionsID], support, hostnames)
+	}
+
+	for i := range vpcs {
+		vpcs[i].EffectiveDNS = settings[i]
+	}
+	return nil
+}
+
+// DhcpOptionsByID indexes DHCP options sets by ID, to join them to the DhcpOptionsID of VPCs
+// options: Sets from GetDhcpOptions
+// Returns: Map from set ID to the set in options
+func DhcpOptionsByID(options []DhcpOptionsInfo) map[string]*DhcpOptionsInfo {
+	byID := make(map[string]*DhcpOptionsInfo, len(options))
+	for i := range options {
+		byID[options[i].DhcpOptionsID] = &options[i]
+	}
+	return byID
+}
+
+// vpcAttribute reads one boolean DNS attribute of a VPC
+func (s *Scanner) vpcAttribute(ctx context.Context, vpcID string, attribute types.VpcAttributeName) (bool, error) {
+	result, err := s.ec2Client.DescribeVpcAttribute(ctx, &ec2.DescribeVpcAttributeInput{
+		VpcId:     aws.String(vpcID),
+		Attribute: attribute,
+	})
+	if err != nil {
+		return false, newScanError("VPC attribute "+string(attribute)+" of "+vpcID, "DescribeVpcAttribute", err)
+	}
+	switch attribute {
+	case types.VpcAttributeNameEnableDnsSupport:
+		return result.EnableDnsSupport != nil && aws.ToBool(result.EnableDnsSupport.Value), nil
+	case types.VpcAttributeNameEnableDnsHostnames:
+		return result.EnableDnsHostnames != nil && aws.ToBool(result.EnableDnsHostnames.Value), nil
+	}
+	return false, nil
+}
+
+// ResolveEffectiveDNS works out the DNS settings instances in a VPC receive
+// A VPC without DHCP options (DhcpOptionsID "default") or whose options set no servers uses the
+// Amazon resolver; a set listing AmazonProvidedDNS next to IP addresses hands out both.
+// v: The VPC
+// options: The VPC's DHCP options set, nil when none is associated or it was not found
+// dnsSupport: Value of the enableDnsSupport attribute
+// dnsHostnames: Value of the enableDnsHostnames attribute
+// Returns: Effective settings for the VPC
+func ResolveEffectiveDNS(v VPCInfo, options *DhcpOptionsInfo, dnsSupport, dnsHostnames bool) *EffectiveDNS {
+	settings := &EffectiveDNS{
+		DhcpOptionsID: v.DhcpOptionsID,
+		CustomServers: []string{},
+		DnsSupport:    dnsSupport,
+		DnsHostnames:  dnsHostnames,
+	}
+	if settings.DhcpOptionsID == "" {
+		settings.DhcpOptionsID = "default"
+	}
+
+	if address, err := netcalc.HostAddress(v.CidrBlock, 2); err != nil {
+		log.Printf("Warning: no resolver address for VPC %s: %v", v.VpcID, err)
+	} else {
+		settings.ResolverAddress = address
+	}
+
+	if options == nil || len(options.DomainNameServers) == 0 {
+		settings.UsesAmazonDNS = true
+		if options != nil {
+			settings.DomainName = options.DomainName
+		}
+		return settings
+	}
+
+	settings.DomainName = options.DomainName
+	for _, server := range options.DomainNameServers {
+		if server == AmazonProvidedDNS {
+			settings.UsesAmazonDNS = true
+			continue
+		}
+		settings.CustomServers = append(settings.CustomServers, server)
+	}
+	return settings
+}
+
+// attributeValues flattens the values of a DHCP configuration
+func attributeValues(values []types.AttributeValue) []string {
+	result := []string{}
+	for _, value := range values {
+		if aws.ToString(value.Value) != "" {
+			result = append(result, aws.ToString(value.Value))
+		}
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestResolveEffectiveDNS covers VPCs with the default options, options without servers, custom servers and a mixed set
+func TestResolveEffectiveDNS(t *testing.T) {
+	v := VPCInfo{VpcID: "vpc-0a1", CidrBlock: "10.20.0.0/16", DhcpOptionsID: "dopt-0a1"}
+	tests := []struct {
+		name    string
+		vpc     VPCInfo
+		options *DhcpOptionsInfo
+		want    *EffectiveDNS
+	}{
+		{
+			name: "default options",
+			vpc:  VPCInfo{VpcID: "vpc-0a1", CidrBlock: "10.20.0.0/16"},
+			want: &EffectiveDNS{DhcpOptionsID: "default", ResolverAddress: "10.20.0.2", UsesAmazonDNS: true, CustomServers: []string{}, DnsSupport: true},
+		},
+		{
+			name:    "domain name only",
+			vpc:     v,
+			options: &DhcpOptionsInfo{DhcpOptionsID: "dopt-0a1", DomainName: "eu-west-1.compute.internal", DomainNameServers: []string{}},
+			want: &EffectiveDNS{DhcpOptionsID: "dopt-0a1", ResolverAddress: "10.20.0.2", UsesAmazonDNS: true, CustomServers: []string{},
+				DomainName: "eu-west-1.compute.internal", DnsSupport: true},
+		},
+		{
+			name:    "custom servers",
+			vpc:     v,
+			options: &DhcpOptionsInfo{DhcpOptionsID: "dopt-0a1", DomainName: "corp.example.com", DomainNameServers: []string{"10.9.1.5", "10.9.2.5"}},
+			want: &EffectiveDNS{DhcpOptionsID: "dopt-0a1", ResolverAddress: "10.20.0.2", CustomServers: []string{"10.9.1.5", "10.9.2.5"},
+				DomainName: "corp.example.com", DnsSupport: true},
+		},
+		{
+			name:    "mixed",
+			vpc:     v,
+			options: &DhcpOptionsInfo{DhcpOptionsID: "dopt-0a1", DomainNameServers: []string{"10.9.1.5", AmazonProvidedDNS}},
+			want: &EffectiveDNS{DhcpOptionsID: "dopt-0a1", ResolverAddress: "10.20.0.2", UsesAmazonDNS: true, CustomServers: []string{"10.9.1.5"},
+				DnsSupport: true},
+		},
+		{
+			name: "no primary CIDR",
+			vpc:  VPCInfo{VpcID: "vpc-0a1", DhcpOptionsID: "default"},
+			want: &EffectiveDNS{DhcpOptionsID: "default", UsesAmazonDNS: true, CustomServers: []string{}, DnsSupport: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveEffectiveDNS(tt.vpc, tt.options, true, false); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveEffectiveDNS() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAddEffectiveDNS checks that the scanned DHCP options sets and DNS attributes end up on each VPC
+func TestAddEffectiveDNS(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{
+		"DescribeDhcpOptions": `<dhcpOptionsSet>
+			<item><dhcpOptionsId>dopt-0custom</dhcpOptionsId><ownerId>111122223333</ownerId><dhcpConfigurationSet>
+				<item><key>domain-name-servers</key><valueSet><item><value>10.9.1.5</value></item><item><value>10.9.2.5</value></item></valueSet></item>
+				<item><key>domain-name</key><valueSet><item><value>corp.example.com</value></item></valueSet></item>
+				<item><key>ntp-servers</key><valueSet><item><value>10.9.1.123</value></item></valueSet></item></dhcpConfigurationSet></item>
+			<item><dhcpOptionsId>dopt-0mixed</dhcpOptionsId><ownerId>111122223333</ownerId><dhcpConfigurationSet>
+				<item><key>domain-name-servers</key><valueSet><item><value>AmazonProvidedDNS</value></item><item><value>10.9.1.5</value></item></valueSet></item></dhcpConfigurationSet></item>
+		</dhcpOptionsSet>`,
+		// Both attributes in one response; each call reads the one it asked for
+		"DescribeVpcAttribute": `<vpcId>vpc-0a1</vpcId><enableDnsSupport><value>true</value></enableDnsSupport><enableDnsHostnames><value>true</value></enableDnsHostnames>`,
+	}, 0)
+	vpcs := []VPCInfo{
+		{VpcID: "vpc-0custom", CidrBlock: "10.1.0.0/16", DhcpOptionsID: "dopt-0custom"},
+		{VpcID: "vpc-0default", CidrBlock: "10.2.0.0/16", DhcpOptionsID: "default"},
+		{VpcID: "vpc-0mixed", CidrBlock: "10.3.0.0/16", DhcpOptionsID: "dopt-0mixed"},
+	}
+	if err := scanner.AddEffectiveDNS(context.Background(), vpcs); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*EffectiveDNS{
+		{DhcpOptionsID: "dopt-0custom", ResolverAddress: "10.1.0.2", CustomServers: []string{"10.9.1.5", "10.9.2.5"}, DomainName: "corp.example.com",
+			DnsSupport: true, DnsHostnames: true},
+		{DhcpOptionsID: "default", ResolverAddress: "10.2.0.2", UsesAmazonDNS: true, CustomServers: []string{}, DnsSupport: true, DnsHostnames: true},
+		{DhcpOptionsID: "dopt-0mixed", ResolverAddress: "10.3.0.2", UsesAmazonDNS: true, CustomServers: []string{"10.9.1.5"}, DnsSupport: true, DnsHostnames: true},
+	}
+	for i, v := range vpcs {
+		if !reflect.DeepEqual(v.EffectiveDNS, want[i]) {
+			t.Errorf("%s EffectiveDNS = %+v\nwant %+v", v.VpcID, v.EffectiveDNS, want[i])
+		}
+	}
+}
+
+// TestGetDhcpOptions checks that options sets keep every option sorted by key next to the named fields
+func TestGetDhcpOptions(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeDhcpOptions": `<dhcpOptionsSet>
+		<item><dhcpOptionsId>dopt-0a1</dhcpOptionsId><ownerId>111122223333</ownerId><dhcpConfigurationSet>
+			<item><key>netbios-node-type</key><valueSet><item><value>2</value></item></valueSet></item>
+			<item><key>domain-name</key><valueSet><item><value>corp.example.com</value></item></valueSet></item>
+			<item><key>ipv6-address-preferred-lease-time</key><valueSet><item><value>140000</value></item></valueSet></item></dhcpConfigurationSet>
+			<tagSet><item><key>Name</key><value>corp</value></item></tagSet></item>
+	</dhcpOptionsSet>`}, 0)
+	got, err := scanner.GetDhcpOptions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DhcpOptionsInfo{{
+		DhcpOptionsID: "dopt-0a1", Arn: "arn:aws:ec2:eu-west-1:111122223333:dhcp-options/dopt-0a1", Region: "eu-west-1", OwnerID: "111122223333",
+		DomainName:        "corp.example.com",
+		DomainNameServers: []string{}, NtpServers: []string{}, NetbiosNameServers: []string{}, NetbiosNodeType: "2",
+		Configurations: []DhcpConfigurationInfo{
+			{Key: "domain-name", Values: []string{"corp.example.com"}},
+			{Key: "ipv6-address-preferred-lease-time", Values: []string{"140000"}},
+			{Key: "netbios-node-type", Values: []string{"2"}},
+		},
+		Tags:    map[string]string{"Name": "corp"},
+		TagList: []Tag{{Key: "Name", Value: "corp"}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDhcpOptions() = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package vpc
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// ElasticIPInfo contains information about an Elastic IP address and what it is associated with
+type ElasticIPInfo struct {
+	AllocationID            string            `json:"allocation_id"`              // Unique identifier of the allocation
+	Arn                     string            `json:"arn"`                        // ARN of the Elastic IP
+	Region                  string            `json:"region"`                     // Region of the Elastic IP
+	PublicIp                string            `json:"public_ip"`                  // Public IPv4 address
+	PrivateIpAddress        string            `json:"private_ip_address"`         // Private IP address the public address maps to (empty if unattached)
+	AssociationID           string            `json:"association_id"`             // ID of the association with an instance or network interface (empty if unattached)
+	InstanceID              string            `json:"instance_id"`                // Instance the address is associated with (empty for none or a service)
+	NetworkInterfaceID      string            `json:"network_interface_id"`       // Network interface holding the address, such as the interface of a NAT gateway
+	NetworkInterfaceOwnerID string            `json:"network_interface_owner_id"` // Account that owns the network interface
+	Domain                  string            `json:"domain"`                     // vpc, or standard for EC2-Classic addresses
+	PublicIpv4Pool          string            `json:"public_ipv4_pool"`           // Pool the address was allocated from (amazon, or the ID of a BYOIP pool)
+	IsUnattached            bool              `json:"is_unattached"`              // Whether the address is associated with nothing; unattached addresses are billed by the hour
+	Tags                    map[string]string `json:"tags"`                       // Key-value tags associated with the Elastic IP
+	TagList                 []Tag             `json:"tag_list"`                   // Tags in API order, including tags without a value
+	ManagedBy               string            `json:"managed_by"`                 // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// GetElasticIPs retrieves information about all Elastic IP addresses in the configured AWS region
+// DescribeAddresses is not paginated; it returns every address of the region at once.
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of ElasticIPInfo structs, or error if the operation fails
+func (s *Scanner) GetElasticIPs(ctx context.Context, opts ...ScanOptions) ([]ElasticIPInfo, error) {
+	result, err := s.ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{Filters: tagFilters(opts)})
+	if err != nil {
+		return nil, newScanError("Elastic IP addresses", "DescribeAddresses", err)
+	}
+
+	addresses := []ElasticIPInfo{}
+	for _, address := range result.Addresses {
+		allocationID := s.required("Elastic IP", "", "AllocationId", address.AllocationId)
+		info := ElasticIPInfo{
+			AllocationID:            allocationID,
+			Arn:                     s.arn(arnbuild.TypeElasticIP, "", allocationID),
+			Region:                  s.region,
+			PublicIp:                aws.ToString(address.PublicIp),
+			PrivateIpAddress:        aws.ToString(address.PrivateIpAddress),
+			AssociationID:           aws.ToString(address.AssociationId),
+			InstanceID:              aws.ToString(address.InstanceId),
+			NetworkInterfaceID:      aws.ToString(address.NetworkInterfaceId),
+			NetworkInterfaceOwnerID: aws.ToString(address.NetworkInterfaceOwnerId),
+			Domain:                  enumValue(s, "Elastic IP", allocationID, "Domain", address.Domain),
+			PublicIpv4Pool:          aws.ToString(address.PublicIpv4Pool),
+			Tags:                    convertTags(address.Tags),
+			TagList:                 convertTagList(address.Tags),
+		}
+		info.IsUnattached = info.AssociationID == ""
+		addresses = append(addresses, info)
+	}
+
+	return addresses, nil
+}
+
+// NatGatewayElasticIPs finds the Elastic IPs of each NAT gateway
+// The address the gateway was created with matches its AllocationID; secondary addresses of a public
+// NAT gateway are associated with the same network interface and follow it.
+// natGateways: NAT gateways from GetNatGateways
+// elasticIPs: Elastic IPs from GetElasticIPs
+// Returns: Map from NAT gateway ID to its Elastic IPs, the primary first; gateways without one have no entry
+func NatGatewayElasticIPs(natGateways []NatGatewayInfo, elasticIPs []ElasticIPInfo) map[string][]ElasticIPInfo {
+	byGateway := make(map[string][]ElasticIPInfo)
+	for _, ngw := range natGateways {
+		var primary, secondary []ElasticIPInfo
+		for _, eip := range elasticIPs {
+			switch {
+			case ngw.AllocationID != "" && eip.AllocationID == ngw.AllocationID:
+				primary = append(primary, eip)
+			case ngw.NetworkInterfaceID != "" && eip.NetworkInterfaceID == ngw.NetworkInterfaceID:
+				secondary = append(secondary, eip)
+			}
+		}
+		if eips := append(primary, secondary...); len(eips) > 0 {
+			byGateway[ngw.NatGatewayID] = eips
+		}
+	}
+	return byGateway
+}
@@ -0,0 +1,292 @@
+package vpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// VPC endpoint types reported in VpcEndpointInfo.EndpointType
+const (
+	EndpointTypeGateway             = "Gateway"             // Route table entries for the service's prefix list (S3 and DynamoDB)
+	EndpointTypeInterface           = "Interface"           // Network interfaces in subnets, reached through private DNS
+	EndpointTypeGatewayLoadBalancer = "GatewayLoadBalancer" // Route target in front of a Gateway Load Balancer
+)
+
+// Kinds of endpoint DNS names reported in EndpointDNSEntry.Kind
+const (
+	EndpointDNSRegional = "regional" // Resolves to the endpoint's interfaces in every availability zone
+	EndpointDNSZonal    = "zonal"    // Resolves to the endpoint's interface in one availability zone
+	EndpointDNSPrivate  = "private"  // The service's own name, answered by the endpoint when private DNS is enabled
+)
+
+// EndpointDNSEntry is a DNS name created for an interface endpoint
+type EndpointDNSEntry struct {
+	DnsName      string `json:"dns_name"`       // The DNS name
+	HostedZoneID string `json:"hosted_zone_id"` // ID of the hosted zone the name is in
+	Kind         string `json:"kind"`           // regional, zonal or private
+}
+
+// VpcEndpointInfo contains information about an AWS VPC endpoint
+type VpcEndpointInfo struct {
+	VpcEndpointID       string             `json:"vpc_endpoint_id"`       // Unique identifier for the endpoint
+	Arn                 string             `json:"arn"`                   // ARN of the endpoint
+	Region              string             `json:"region"`                // Region of the endpoint
+	VpcID               string             `json:"vpc_id"`                // ID of the VPC the endpoint is in
+	ServiceName         string             `json:"service_name"`          // Full service name (com.amazonaws.<region>.s3, ...)
+	EndpointType        string             `json:"endpoint_type"`         // Gateway, Interface or GatewayLoadBalancer
+	State               string             `json:"state"`                 // State of the endpoint (PendingAcceptance, Pending, Available, Deleting, ...)
+	RouteTableIDs       []string           `json:"route_table_ids"`       // Route tables the endpoint adds routes to (gateway endpoints)
+	SubnetIDs           []string           `json:"subnet_ids"`            // Subnets with an endpoint network interface (interface endpoints)
+	NetworkInterfaceIDs []string           `json:"network_interface_ids"` // Network interfaces of the endpoint, one per subnet (interface endpoints)
+	PrivateDnsEnabled   bool               `json:"private_dns_enabled"`   // Whether the service's default DNS name resolves to the endpoint
+	DnsEntries          []EndpointDNSEntry `json:"dns_entries"`           // DNS names of the endpoint (interface endpoints)
+	SecurityGroupIDs    []string           `json:"security_group_ids"`    // Security groups of the endpoint network interfaces (interface endpoints)
+	PolicyDocument      string             `json:"policy_document"`       // Endpoint policy as JSON text (empty for types without policies)
+	OwnerID             string             `json:"owner_id"`              // AWS account ID that owns the endpoint
+	CreationTime        string             `json:"creation_time"`         // Time when the endpoint was created
+	Tags                map[string]string  `json:"tags"`                  // Key-value tags associated with the endpoint
+	TagList             []Tag              `json:"tag_list"`              // Tags in API order, including tags without a value
+}
+
+// EndpointServiceInfo contains information about a service that VPC endpoints can connect to
+type EndpointServiceInfo struct {
+	ServiceName    string `json:"service_name"`     // Full service name (com.amazonaws.vpce.<region>.vpce-svc-*, ...)
+	ServiceID      string `json:"service_id"`       // ID of the service (vpce-svc-*)
+	Arn            string `json:"arn"`              // ARN of the service in the provider's account (empty for AWS services)
+	Owner          string `json:"owner"`            // AWS account ID of the service provider, or "amazon" for AWS services
+	PrivateDnsName string `json:"private_dns_name"` // Private DNS name the provider verified for the service (empty if none)
+	ServiceType    string `json:"service_type"`     // Interface, Gateway or GatewayLoadBalancer
+}
+
+// GetVpcEndpoints retrieves information about all VPC endpoints in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of VpcEndpointInfo structs containing endpoint details, or error if the operation fails
+func (s *Scanner) GetVpcEndpoints(ctx context.Context, opts ...ScanOptions) ([]VpcEndpointInfo, error) {
+	endpoints := []VpcEndpointInfo{}
+
+	paginator := ec2.NewDescribeVpcEndpointsPaginator(s.ec2Client, &ec2.DescribeVpcEndpointsInput{Filters: tagFilters(opts)})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("VPC endpoints", "DescribeVpcEndpoints", err)
+		}
+
+		for _, endpoint := range result.VpcEndpoints {
+			info := VpcEndpointInfo{
+				VpcEndpointID:       aws.ToString(endpoint.VpcEndpointId),
+				Arn:                 s.arn(arnbuild.TypeVpcEndpoint, aws.ToString(endpoint.OwnerId), aws.ToString(endpoint.VpcEndpointId)),
+				Region:              s.region,
+				VpcID:               aws.ToString(endpoint.VpcId),
+				ServiceName:         aws.ToString(endpoint.ServiceName),
+				EndpointType:        string(endpoint.VpcEndpointType),
+				State:               string(endpoint.State),
+				RouteTableIDs:       append([]string{}, endpoint.RouteTableIds...),
+				SubnetIDs:           append([]string{}, endpoint.SubnetIds...),
+				NetworkInterfaceIDs: append([]string{}, endpoint.NetworkInterfaceIds...),
+				PrivateDnsEnabled:   aws.ToBool(endpoint.PrivateDnsEnabled),
+				DnsEntries:          []EndpointDNSEntry{},
+				SecurityGroupIDs:    []string{},
+				PolicyDocument:      aws.ToString(endpoint.PolicyDocument),
+				OwnerID:             aws.ToString(endpoint.OwnerId),
+				Tags:                convertTags(endpoint.Tags),
+				TagList:             convertTagList(endpoint.Tags),
+			}
+			if endpoint.CreationTimestamp != nil {
+				info.CreationTime = endpoint.CreationTimestamp.Format("2006-01-02T15:04:05Z")
+			}
+			for _, group := range endpoint.Groups {
+				info.SecurityGroupIDs = append(info.SecurityGroupIDs, aws.ToString(group.GroupId))
+			}
+			for _, entry := range endpoint.DnsEntries {
+				name := aws.ToString(entry.DnsName)
+				info.DnsEntries = append(info.DnsEntries, EndpointDNSEntry{
+					DnsName:      name,
+					HostedZoneID: aws.ToString(entry.HostedZoneId),
+					Kind:         endpointDNSKind(name),
+				})
+			}
+			endpoints = append(endpoints, info)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// EndpointInterfaceInfo describes a network interface of an interface or Gateway Load Balancer endpoint
+type EndpointInterfaceInfo struct {
+	NetworkInterfaceID string   `json:"network_interface_id"` // ID of the network interface
+	Arn                string   `json:"arn"`                  // ARN of the network interface
+	VpcEndpointID      string   `json:"vpc_endpoint_id"`      // Endpoint the interface belongs to
+	SubnetID           string   `json:"subnet_id"`            // Subnet of the interface
+	AvailabilityZone   string   `json:"availability_zone"`    // Availability zone of the interface
+	PrivateIps         []string `json:"private_ips"`          // IPv4 addresses the interface takes from the subnet
+	Ipv6Addresses      []string `json:"ipv6_addresses"`       // IPv6 addresses of the interface
+}
+
+// GetEndpointInterfaces retrieves the network interfaces of the given endpoints
+// Interfaces are found by their endpoint interface types, so one paginated call covers all endpoints.
+// ctx: Context for the request, allowing for timeout and cancellation
+// endpoints: Endpoints from GetVpcEndpoints, used to map interfaces to endpoints
+// Returns: Interfaces that belong to one of the endpoints, or error if the operation fails
+func (s *Scanner) GetEndpointInterfaces(ctx context.Context, endpoints []VpcEndpointInfo) ([]EndpointInterfaceInfo, error) {
+	interfaces := []EndpointInterfaceInfo{}
+
+	endpointOf := make(map[string]string)
+	for _, endpoint := range endpoints {
+		for _, eniID := range endpoint.NetworkInterfaceIDs {
+			endpointOf[eniID] = endpoint.VpcEndpointID
+		}
+	}
+	if len(endpointOf) == 0 {
+		return interfaces, nil
+	}
+
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(s.ec2Client, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []types.Filter{{Name: aws.String("interface-type"), Values: []string{"vpc_endpoint", "gateway_load_balancer_endpoint"}}},
+	})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("network interfaces", "DescribeNetworkInterfaces", err)
+		}
+
+		for _, eni := range result.NetworkInterfaces {
+			endpointID, ok := endpointOf[aws.ToString(eni.NetworkInterfaceId)]
+			if !ok {
+				continue
+			}
+			info := EndpointInterfaceInfo{
+				NetworkInterfaceID: aws.ToString(eni.NetworkInterfaceId),
+				Arn:                s.arn(arnbuild.TypeNetworkInterface, aws.ToString(eni.OwnerId), aws.ToString(eni.NetworkInterfaceId)),
+				VpcEndpointID:      endpointID,
+				SubnetID:           aws.ToString(eni.SubnetId),
+				AvailabilityZone:   aws.ToString(eni.AvailabilityZone),
+				PrivateIps:         []string{},
+				Ipv6Addresses:      []string{},
+			}
+			for _, private := range eni.PrivateIpAddresses {
+				info.PrivateIps = append(info.PrivateIps, aws.ToString(private.PrivateIpAddress))
+			}
+			for _, address := range eni.Ipv6Addresses {
+				info.Ipv6Addresses = append(info.Ipv6Addresses, aws.ToString(address.Ipv6Address))
+			}
+			interfaces = append(interfaces, info)
+		}
+	}
+
+	return interfaces, nil
+}
+
+// GetVpcEndpointServices retrieves the provider details of the given endpoint services
+// Services shared with the account by other providers are only listed when named explicitly.
+// ctx: Context for the request, allowing for timeout and cancellation
+// serviceNames: Full service names, typically those of the scanned endpoints
+// Returns: Slice of EndpointServiceInfo structs, or error if the operation fails
+func (s *Scanner) GetVpcEndpointServices(ctx context.Context, serviceNames []string) ([]EndpointServiceInfo, error) {
+	services := []EndpointServiceInfo{}
+	if len(serviceNames) == 0 {
+		return services, nil
+	}
+
+	input := &ec2.DescribeVpcEndpointServicesInput{ServiceNames: serviceNames}
+	for {
+		result, err := s.ec2Client.DescribeVpcEndpointServices(ctx, input)
+		if err != nil {
+			return nil, newScanError("VPC endpoint services", "DescribeVpcEndpointServices", err)
+		}
+
+		for _, detail := range result.ServiceDetails {
+			info := EndpointServiceInfo{
+				ServiceName:    aws.ToString(detail.ServiceName),
+				ServiceID:      aws.ToString(detail.ServiceId),
+				Owner:          aws.ToString(detail.Owner),
+				PrivateDnsName: aws.ToString(detail.PrivateDnsName),
+			}
+			// AWS services are owned by "amazon", which is not an account
+			if info.Owner != "amazon" {
+				info.Arn = s.arn(arnbuild.TypeVpcEndpointService, info.Owner, info.ServiceID)
+			}
+			if len(detail.ServiceType) > 0 {
+				info.ServiceType = string(detail.ServiceType[0].ServiceType)
+			}
+			services = append(services, info)
+		}
+
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return services, nil
+}
+
+// VpcEndpointConnectionInfo is an endpoint of another VPC connected to an endpoint service of the scanned account
+// It is the provider's view of a PrivateLink connection, whose consumer side is the VpcEndpointInfo in the endpoint owner's account.
+type VpcEndpointConnectionInfo struct {
+	VpcEndpointID    string `json:"vpc_endpoint_id"`    // ID of the consumer's endpoint
+	Region           string `json:"region"`             // Region of the endpoint service
+	ServiceID        string `json:"service_id"`         // ID of the endpoint service (vpce-svc-*)
+	VpcEndpointOwner string `json:"vpc_endpoint_owner"` // AWS account ID that owns the consumer's endpoint
+	State            string `json:"state"`              // State of the connection as the provider sees it (PendingAcceptance, Available, Rejected, ...)
+	CreationTime     string `json:"creation_time"`      // Time when the endpoint was created
+}
+
+// GetVpcEndpointConnections retrieves the endpoints connected to the endpoint services the account provides
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of VpcEndpointConnectionInfo structs (empty if the account provides no services), or error if the operation fails
+func (s *Scanner) GetVpcEndpointConnections(ctx context.Context) ([]VpcEndpointConnectionInfo, error) {
+	connections := []VpcEndpointConnectionInfo{}
+
+	paginator := ec2.NewDescribeVpcEndpointConnectionsPaginator(s.ec2Client, &ec2.DescribeVpcEndpointConnectionsInput{})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("VPC endpoint connections", "DescribeVpcEndpointConnections", err)
+		}
+
+		for _, connection := range result.VpcEndpointConnections {
+			info := VpcEndpointConnectionInfo{
+				VpcEndpointID:    aws.ToString(connection.VpcEndpointId),
+				Region:           s.region,
+				ServiceID:        aws.ToString(connection.ServiceId),
+				VpcEndpointOwner: aws.ToString(connection.VpcEndpointOwner),
+				State:            string(connection.VpcEndpointState),
+			}
+			if connection.CreationTimestamp != nil {
+				info.CreationTime = connection.CreationTimestamp.Format("2006-01-02T15:04:05Z")
+			}
+			connections = append(connections, info)
+		}
+	}
+
+	return connections, nil
+}
+
+// endpointDNSKind classifies an endpoint DNS name
+// Generated names end in vpce.amazonaws.com; zonal ones carry the availability zone at the end of
+// the first label (vpce-0123-abcd-us-east-1a.ec2.us-east-1.vpce.amazonaws.com)
+func endpointDNSKind(name string) string {
+	name = strings.TrimSuffix(name, ".")
+	if !strings.HasSuffix(name, ".vpce.amazonaws.com") {
+		return EndpointDNSPrivate
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) < 5 {
+		return EndpointDNSRegional
+	}
+	// The region is the label before "vpce"; a zonal first label ends in -<region><letter>
+	region := labels[len(labels)-4]
+	first := labels[0]
+	if i := strings.LastIndex(first, "-"+region); i > 0 && len(first) == i+len(region)+2 {
+		return EndpointDNSZonal
+	}
+	return EndpointDNSRegional
+}
@@ -0,0 +1,120 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestEndpointDNSKind covers the regional, zonal and private DNS names of interface endpoints
+func TestEndpointDNSKind(t *testing.T) {
+	tests := map[string]string{
+		"vpce-0a1b2c3d-4e5f6g7h.ssm.eu-west-1.vpce.amazonaws.com":             EndpointDNSRegional,
+		"vpce-0a1b2c3d-4e5f6g7h-eu-west-1a.ssm.eu-west-1.vpce.amazonaws.com":  EndpointDNSZonal,
+		"vpce-0a1b2c3d-4e5f6g7h-eu-west-1a.ssm.eu-west-1.vpce.amazonaws.com.": EndpointDNSZonal,
+		"*.vpce-0a1b2c3d-4e5f6g7h.s3.eu-west-1.vpce.amazonaws.com":            EndpointDNSRegional,
+		"vpce-0a1b2c3d-4e5f6g7h.execute-api.eu-west-1.vpce.amazonaws.com":     EndpointDNSRegional,
+		"ssm.eu-west-1.amazonaws.com":                                         EndpointDNSPrivate,
+		"*.s3.eu-west-1.amazonaws.com":                                        EndpointDNSPrivate,
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := endpointDNSKind(name); got != want {
+				t.Errorf("endpointDNSKind(%q) = %q, want %q", name, got, want)
+			}
+		})
+	}
+}
+
+// TestGetVpcEndpointsDNSEntries checks that interface endpoints carry their DNS entries with their kind
+func TestGetVpcEndpointsDNSEntries(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeVpcEndpoints": `<vpcEndpointSet><item>
+		<vpcEndpointId>vpce-0ssm</vpcEndpointId><vpcEndpointType>Interface</vpcEndpointType><vpcId>vpc-0a1</vpcId>
+		<serviceName>com.amazonaws.eu-west-1.ssm</serviceName><state>available</state><privateDnsEnabled>true</privateDnsEnabled>
+		<dnsEntrySet>
+			<item><dnsName>vpce-0ssm-1a2b.ssm.eu-west-1.vpce.amazonaws.com</dnsName><hostedZoneId>Z0REGIONAL</hostedZoneId></item>
+			<item><dnsName>vpce-0ssm-1a2b-eu-west-1b.ssm.eu-west-1.vpce.amazonaws.com</dnsName><hostedZoneId>Z0REGIONAL</hostedZoneId></item>
+			<item><dnsName>ssm.eu-west-1.amazonaws.com</dnsName><hostedZoneId>Z0PRIVATE</hostedZoneId></item>
+		</dnsEntrySet></item></vpcEndpointSet>`}, 0)
+	endpoints, err := scanner.GetVpcEndpoints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 1 {
+		t.Fatalf("got %d endpoints, want 1", len(endpoints))
+	}
+	want := []EndpointDNSEntry{
+		{DnsName: "vpce-0ssm-1a2b.ssm.eu-west-1.vpce.amazonaws.com", HostedZoneID: "Z0REGIONAL", Kind: EndpointDNSRegional},
+		{DnsName: "vpce-0ssm-1a2b-eu-west-1b.ssm.eu-west-1.vpce.amazonaws.com", HostedZoneID: "Z0REGIONAL", Kind: EndpointDNSZonal},
+		{DnsName: "ssm.eu-west-1.amazonaws.com", HostedZoneID: "Z0PRIVATE", Kind: EndpointDNSPrivate},
+	}
+	if got := endpoints[0].DnsEntries; !reflect.DeepEqual(got, want) {
+		t.Errorf("DnsEntries = %+v\nwant %+v", got, want)
+	}
+	if !endpoints[0].PrivateDnsEnabled || endpoints[0].EndpointType != EndpointTypeInterface {
+		t.Errorf("endpoint = %+v, want an interface endpoint with private DNS", endpoints[0])
+	}
+}
+
+// TestGetVpcEndpointServices checks that only services owned by an account get an ARN and that the
+// first service type is kept
+func TestGetVpcEndpointServices(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeVpcEndpointServices": `<serviceDetailSet>
+		<item><serviceName>com.amazonaws.vpce.eu-west-1.vpce-svc-0aws</serviceName><serviceId>vpce-svc-0aws</serviceId>
+			<owner>amazon</owner><serviceType><item><serviceType>Interface</serviceType></item></serviceType></item>
+		<item><serviceName>com.amazonaws.vpce.eu-west-1.vpce-svc-0datadog</serviceName><serviceId>vpce-svc-0datadog</serviceId>
+			<owner>464622532012</owner><privateDnsName>*.agent.datadoghq.eu</privateDnsName>
+			<serviceType><item><serviceType>Interface</serviceType></item><item><serviceType>GatewayLoadBalancer</serviceType></item></serviceType></item>
+		</serviceDetailSet>`}, 0)
+
+	if got, err := scanner.GetVpcEndpointServices(context.Background(), nil); err != nil || len(got) != 0 {
+		t.Errorf("GetVpcEndpointServices(nil) = %+v, %v, want no services and no call", got, err)
+	}
+	got, err := scanner.GetVpcEndpointServices(context.Background(), []string{"com.amazonaws.vpce.eu-west-1.vpce-svc-0aws", "com.amazonaws.vpce.eu-west-1.vpce-svc-0datadog"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EndpointServiceInfo{
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0aws", ServiceID: "vpce-svc-0aws", Owner: "amazon", ServiceType: "Interface"},
+		{ServiceName: "com.amazonaws.vpce.eu-west-1.vpce-svc-0datadog", ServiceID: "vpce-svc-0datadog", Owner: "464622532012",
+			Arn: "arn:aws:ec2:eu-west-1:464622532012:vpc-endpoint-service/vpce-svc-0datadog", PrivateDnsName: "*.agent.datadoghq.eu", ServiceType: "Interface"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetVpcEndpointServices() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestGetEndpointInterfaces maps the interfaces of the endpoint interface types to their endpoints and skips
+// interfaces of endpoints that were not passed in
+func TestGetEndpointInterfaces(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeNetworkInterfaces": `<networkInterfaceSet>
+		<item><networkInterfaceId>eni-0ssm1</networkInterfaceId><subnetId>subnet-0a</subnetId><availabilityZone>eu-west-1a</availabilityZone>
+			<privateIpAddressesSet><item><privateIpAddress>10.0.1.5</privateIpAddress></item></privateIpAddressesSet>
+			<ipv6AddressesSet><item><ipv6Address>2001:db8::5</ipv6Address></item></ipv6AddressesSet></item>
+		<item><networkInterfaceId>eni-0ssm2</networkInterfaceId><subnetId>subnet-0b</subnetId><availabilityZone>eu-west-1b</availabilityZone>
+			<privateIpAddressesSet><item><privateIpAddress>10.0.2.5</privateIpAddress></item></privateIpAddressesSet></item>
+		<item><networkInterfaceId>eni-0other</networkInterfaceId><subnetId>subnet-0a</subnetId><availabilityZone>eu-west-1a</availabilityZone></item>
+		</networkInterfaceSet>`}, 0)
+
+	endpoints := []VpcEndpointInfo{
+		{VpcEndpointID: "vpce-0ssm", NetworkInterfaceIDs: []string{"eni-0ssm1", "eni-0ssm2"}},
+		{VpcEndpointID: "vpce-0s3", NetworkInterfaceIDs: []string{}},
+	}
+	got, err := scanner.GetEndpointInterfaces(context.Background(), endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EndpointInterfaceInfo{
+		{NetworkInterfaceID: "eni-0ssm1", VpcEndpointID: "vpce-0ssm", SubnetID: "subnet-0a", AvailabilityZone: "eu-west-1a",
+			PrivateIps: []string{"10.0.1.5"}, Ipv6Addresses: []string{"2001:db8::5"}},
+		{NetworkInterfaceID: "eni-0ssm2", VpcEndpointID: "vpce-0ssm", SubnetID: "subnet-0b", AvailabilityZone: "eu-west-1b",
+			PrivateIps: []string{"10.0.2.5"}, Ipv6Addresses: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetEndpointInterfaces() = %+v\nwant %+v", got, want)
+	}
+
+	if got, err := scanner.GetEndpointInterfaces(context.Background(), endpoints[1:]); err != nil || len(got) != 0 {
+		t.Errorf("GetEndpointInterfaces() without interfaces = %+v, %v, want none", got, err)
+	}
+}
@@ -0,0 +1,136 @@
+package vpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+)
+
+// Error categories that ScanError values can be checked against with errors.Is
+var (
+	ErrAccessDenied   = errors.New("access denied")   // Credentials are invalid or lack permission for the operation
+	ErrThrottled      = errors.New("throttled")       // The API rejected the request because of rate limiting
+	ErrRegionDisabled = errors.New("region disabled") // The region is not enabled (opted in) for the account
+	ErrInvalidInput   = errors.New("invalid input")   // The request parameters or filters were rejected
+
+	ErrServiceUnavailable = errors.New("service not available in region") // The service has no endpoint in the region
+)
+
+// errorCodeCategories maps AWS API error codes to the error categories above
+var errorCodeCategories = map[string]error{
+	"UnauthorizedOperation":       ErrAccessDenied,
+	"AuthFailure":                 ErrAccessDenied,
+	"AccessDenied":                ErrAccessDenied,
+	"AccessDeniedException":       ErrAccessDenied,
+	"UnrecognizedClientException": ErrAccessDenied,
+	"InvalidClientTokenId":        ErrAccessDenied,
+	"ExpiredToken":                ErrAccessDenied,
+	"RequestLimitExceeded":        ErrThrottled,
+	"Throttling":                  ErrThrottled,
+	"ThrottlingException":         ErrThrottled,
+	"TooManyRequestsException":    ErrThrottled,
+	"OptInRequired":               ErrRegionDisabled,
+	"InvalidParameterValue":       ErrInvalidInput,
+	"InvalidParameterCombination": ErrInvalidInput,
+	"InvalidParameter":            ErrInvalidInput,
+	"InvalidFilter":               ErrInvalidInput,
+	"MissingParameter":            ErrInvalidInput,
+	"ValidationError":             ErrInvalidInput,
+}
+
+// ScanError describes a failed scanner call and wraps the underlying SDK error
+type ScanError struct {
+	ResourceType string // Resource type being scanned (VPCs, subnets, route tables, ...)
+	Service      string // IAM service prefix of the operation (ec2, networkmanager, ...)
+	Operation    string // AWS API operation that failed (DescribeVpcs, ...)
+	Category     error  // One of the Err* categories, or nil if the error is not recognized
+	Err          error  // Underlying error returned by the SDK
+}
+
+// Error returns a human-readable description of the failed scan
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("failed to describe %s: %v", e.ResourceType, e.Err)
+}
+
+// Unwrap returns the underlying SDK error so errors.As can reach smithy.APIError
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error belongs to the given category
+func (e *ScanError) Is(target error) bool {
+	return e.Category != nil && target == e.Category
+}
+
+// newScanError wraps an SDK error in a ScanError and classifies it
+// resourceType: Resource type being scanned, used in the error message
+// operation: AWS API operation that failed
+// err: Error returned by the SDK
+// Returns: ScanError with Category set from the API error code, if recognized
+func newScanError(resourceType, operation string, err error) *ScanError {
+	return NewServiceScanError("ec2", resourceType, operation, err)
+}
+
+// NewServiceScanError wraps an SDK error from a service other than EC2 in a ScanError and classifies it
+// service: IAM service prefix of the operation (networkmanager, ...)
+// resourceType: Resource type being scanned, used in the error message
+// operation: AWS API operation that failed
+// err: Error returned by the SDK
+// Returns: ScanError with Category set from the API error code, if recognized
+func NewServiceScanError(service, resourceType, operation string, err error) *ScanError {
+	return &ScanError{
+		ResourceType: resourceType,
+		Service:      service,
+		Operation:    operation,
+		Category:     ClassifyError(err),
+		Err:          err,
+	}
+}
+
+// ClassifyError maps an error returned by the AWS SDK to one of the error categories
+// A service without an endpoint in the region fails before any API error code: endpoint
+// resolution reports that no endpoint is known, or the endpoint's host name does not resolve.
+// Only the host names of AWS endpoints count; a proxy or custom endpoint that does not resolve
+// is a setup problem, not a missing service, and is left unclassified.
+// err: Error returned by the SDK (may be wrapped)
+// Returns: The matching Err* category, or nil if the error code is not recognized
+func ClassifyError(err error) error {
+	var notFound *aws.EndpointNotFoundError
+	if errors.As(err, &notFound) {
+		return ErrServiceUnavailable
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound && isEndpointHost(dnsErr.Name) {
+		return ErrServiceUnavailable
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+	return errorCodeCategories[apiErr.ErrorCode()]
+}
+
+// endpointDomains are the DNS domains of the AWS service endpoints of every partition
+var endpointDomains = []string{".amazonaws.com", ".amazonaws.com.cn", ".api.aws", ".c2s.ic.gov", ".sc2s.sgov.gov"}
+
+// isEndpointHost reports whether a host name is that of an AWS service endpoint
+func isEndpointHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, domain := range endpointDomains {
+		if strings.HasSuffix(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnavailable reports whether a scanner call failed because its service cannot be used in the region
+// Besides services without an endpoint, this covers regions the account has not opted in to.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrServiceUnavailable) || errors.Is(err, ErrRegionDisabled)
+}
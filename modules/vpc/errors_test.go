@@ -0,0 +1,92 @@
+package vpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+)
+
+// apiError returns an API error with a code, wrapped as the SDK returns it from an operation
+func apiError(code string) error {
+	return &smithy.OperationError{ServiceID: "EC2", OperationName: "DescribeVpcs", Err: &smithy.GenericAPIError{Code: code, Message: "test"}}
+}
+
+// dnsError returns the error of a request whose host name lookup failed, wrapped as the SDK returns it
+func dnsError(host string, notFound bool) error {
+	lookup := &net.DNSError{Err: "no such host", Name: host, IsNotFound: notFound, IsTimeout: !notFound}
+	return &smithy.OperationError{ServiceID: "NetworkManager", OperationName: "DescribeGlobalNetworks",
+		Err: &url.Error{Op: "Post", URL: "https://" + host + "/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: lookup}}}
+}
+
+// TestClassifyError covers the API error codes of every category, endpoint resolution and unrecognized errors
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "UnauthorizedOperation", err: apiError("UnauthorizedOperation"), want: ErrAccessDenied},
+		{name: "AuthFailure", err: apiError("AuthFailure"), want: ErrAccessDenied},
+		{name: "AccessDeniedException", err: apiError("AccessDeniedException"), want: ErrAccessDenied},
+		{name: "ExpiredToken", err: apiError("ExpiredToken"), want: ErrAccessDenied},
+		{name: "RequestLimitExceeded", err: apiError("RequestLimitExceeded"), want: ErrThrottled},
+		{name: "ThrottlingException", err: apiError("ThrottlingException"), want: ErrThrottled},
+		{name: "OptInRequired", err: apiError("OptInRequired"), want: ErrRegionDisabled},
+		{name: "InvalidParameterValue", err: apiError("InvalidParameterValue"), want: ErrInvalidInput},
+		{name: "unknown code", err: apiError("InternalError")},
+		{name: "wrapped again", err: fmt.Errorf("scan: %w", apiError("RequestLimitExceeded")), want: ErrThrottled},
+		{name: "no endpoint known", err: &aws.EndpointNotFoundError{Err: errors.New("no endpoint")}, want: ErrServiceUnavailable},
+		{name: "endpoint does not resolve", err: dnsError("networkmanager.ap-east-1.amazonaws.com", true), want: ErrServiceUnavailable},
+		{name: "China endpoint does not resolve", err: dnsError("ec2.cn-north-1.amazonaws.com.cn.", true), want: ErrServiceUnavailable},
+		{name: "dual-stack endpoint does not resolve", err: dnsError("ec2.eu-west-1.api.aws", true), want: ErrServiceUnavailable},
+		{name: "endpoint lookup timed out", err: dnsError("ec2.eu-west-1.amazonaws.com", false)},
+		{name: "proxy does not resolve", err: dnsError("proxy.corp.example", true)},
+		{name: "custom endpoint does not resolve", err: dnsError("localstack.internal", true)},
+		{name: "look-alike domain", err: dnsError("ec2.eu-west-1.amazonaws.com.example", true)},
+		{name: "other error", err: errors.New("connection reset")},
+		{name: "nil", err: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestScanErrorIs checks that a ScanError matches its category with errors.Is and that IsUnavailable
+// covers services without an endpoint and regions not opted in to
+func TestScanErrorIs(t *testing.T) {
+	throttled := newScanError("VPCs", "DescribeVpcs", apiError("RequestLimitExceeded"))
+	if !errors.Is(throttled, ErrThrottled) || errors.Is(throttled, ErrAccessDenied) {
+		t.Errorf("errors.Is(%v) does not match only ErrThrottled", throttled)
+	}
+	var apiErr smithy.APIError
+	if !errors.As(throttled, &apiErr) || apiErr.ErrorCode() != "RequestLimitExceeded" {
+		t.Errorf("errors.As(%v) does not reach the API error", throttled)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no endpoint", err: NewServiceScanError("networkmanager", "core networks", "ListCoreNetworks", dnsError("networkmanager.ap-east-1.amazonaws.com", true)), want: true},
+		{name: "opt-in region", err: newScanError("VPCs", "DescribeVpcs", apiError("OptInRequired")), want: true},
+		{name: "proxy does not resolve", err: newScanError("VPCs", "DescribeVpcs", dnsError("proxy.corp.example", true))},
+		{name: "access denied", err: newScanError("VPCs", "DescribeVpcs", apiError("UnauthorizedOperation"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUnavailable(tt.err); got != tt.want {
+				t.Errorf("IsUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
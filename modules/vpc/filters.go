@@ -0,0 +1,59 @@
+package vpc
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// ScanOptions narrows the resources the Get* methods retrieve
+// The zero value, like passing no options, retrieves every resource of the region.
+type ScanOptions struct {
+	TagFilters map[string]string // Tags a resource must all carry, tag key to value (nil for no filter)
+}
+
+// tagFilterValues merges the tag filters of options; a later option wins for the same key
+// Returns: Tag key to value, nil without tag filters
+func tagFilterValues(opts []ScanOptions) map[string]string {
+	var merged map[string]string
+	for _, opt := range opts {
+		for key, value := range opt.TagFilters {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// tagFilters returns the tag:<key> API filters for the tag filters of options
+// The filters are sorted by key, so the same options make the same request.
+// Returns: Filters to append to the input's Filters, nil without tag filters
+func tagFilters(opts []ScanOptions) []types.Filter {
+	values := tagFilterValues(opts)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var filters []types.Filter
+	for _, key := range keys {
+		filters = append(filters, types.Filter{Name: aws.String("tag:" + key), Values: []string{values[key]}})
+	}
+	return filters
+}
+
+// matchesTags reports whether a resource carries every tag filter of options
+// Used for the transit gateway APIs, which have no tag filters.
+// tags: Tags of the resource from convertTags
+func matchesTags(tags map[string]string, opts []ScanOptions) bool {
+	for key, value := range tagFilterValues(opts) {
+		if actual, ok := tags[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package vpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// FlowLogInfo contains information about a flow log and where it delivers the traffic records of its resource
+type FlowLogInfo struct {
+	FlowLogID              string            `json:"flow_log_id"`              // Unique identifier for the flow log
+	Arn                    string            `json:"arn"`                      // ARN of the flow log
+	Region                 string            `json:"region"`                   // Region of the flow log
+	ResourceID             string            `json:"resource_id"`              // VPC, subnet, network interface or transit gateway (attachment) the flow log records
+	ResourceType           string            `json:"resource_type"`            // Type of the resource, from its ID prefix (vpc, subnet, network-interface, transit-gateway, transit-gateway-attachment; empty if unknown)
+	TrafficType            string            `json:"traffic_type"`             // Traffic recorded: ACCEPT, REJECT or ALL
+	LogDestinationType     string            `json:"log_destination_type"`     // Where the records go: cloud-watch-logs, s3 or kinesis-data-firehose
+	LogDestination         string            `json:"log_destination"`          // ARN of the destination, or the log group name of older CloudWatch Logs flow logs
+	LogFormat              string            `json:"log_format"`               // Fields of a record, in ${field} notation
+	MaxAggregationInterval int32             `json:"max_aggregation_interval"` // Seconds a record aggregates traffic for at most (60 or 600)
+	FlowLogStatus          string            `json:"flow_log_status"`          // Status of the flow log (ACTIVE)
+	DeliverLogsStatus      string            `json:"deliver_logs_status"`      // Status of the delivery: SUCCESS or FAILED
+	DeliverLogsError       string            `json:"deliver_logs_error"`       // Why delivery failed, such as a missing permission or a rate limit (empty if it succeeds)
+	Tags                   map[string]string `json:"tags"`                     // Key-value tags associated with the flow log
+	TagList                []Tag             `json:"tag_list"`                 // Tags in API order, including tags without a value
+	ManagedBy              string            `json:"managed_by"`               // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// flowLogResourceTypes maps the ID prefixes of the resources a flow log can record to their type, longest first
+var flowLogResourceTypes = []struct {
+	prefix, resourceType string
+}{
+	{"tgw-attach-", "transit-gateway-attachment"},
+	{"tgw-", "transit-gateway"},
+	{"subnet-", "subnet"},
+	{"eni-", "network-interface"},
+	{"vpc-", "vpc"},
+}
+
+// GetFlowLogs retrieves information about all flow logs in the configured AWS region
+// The API does not say what kind of resource a flow log records, so ResourceType is derived from the resource ID.
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the flow logs must match (none for all flow logs)
+// Returns: Slice of FlowLogInfo structs, or error if the operation fails
+func (s *Scanner) GetFlowLogs(ctx context.Context, opts ...ScanOptions) ([]FlowLogInfo, error) {
+	flowLogs := []FlowLogInfo{}
+
+	paginator := ec2.NewDescribeFlowLogsPaginator(s.ec2Client, &ec2.DescribeFlowLogsInput{Filter: tagFilters(opts)})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("flow logs", "DescribeFlowLogs", err)
+		}
+
+		for _, fl := range result.FlowLogs {
+			flID := s.required("flow log", "", "FlowLogId", fl.FlowLogId)
+			info := FlowLogInfo{
+				FlowLogID:              flID,
+				Arn:                    s.arn(arnbuild.TypeFlowLog, "", flID),
+				Region:                 s.region,
+				ResourceID:             s.required("flow log", flID, "ResourceId", fl.ResourceId),
+				TrafficType:            enumValue(s, "flow log", flID, "TrafficType", fl.TrafficType),
+				LogDestinationType:     enumValue(s, "flow log", flID, "LogDestinationType", fl.LogDestinationType),
+				LogDestination:         aws.ToString(fl.LogDestination),
+				LogFormat:              aws.ToString(fl.LogFormat),
+				MaxAggregationInterval: aws.ToInt32(fl.MaxAggregationInterval),
+				FlowLogStatus:          aws.ToString(fl.FlowLogStatus),
+				DeliverLogsStatus:      aws.ToString(fl.DeliverLogsStatus),
+				DeliverLogsError:       aws.ToString(fl.DeliverLogsErrorMessage),
+				Tags:                   convertTags(fl.Tags),
+				TagList:                convertTagList(fl.Tags),
+			}
+			// Flow logs created before destinations were ARNs only name their log group
+			if info.LogDestination == "" {
+				info.LogDestination = aws.ToString(fl.LogGroupName)
+			}
+			for _, t := range flowLogResourceTypes {
+				if strings.HasPrefix(info.ResourceID, t.prefix) {
+					info.ResourceType = t.resourceType
+					break
+				}
+			}
+			flowLogs = append(flowLogs, info)
+		}
+	}
+
+	return flowLogs, nil
+}
+
+// FlowLogsByResource indexes flow logs by the ID of the resource they record
+// A VPC without an entry has no flow log of its own, though flow logs of its subnets or interfaces may cover part of it.
+// flowLogs: Flow logs from GetFlowLogs
+// Returns: Map from resource ID to its flow logs, in the order of flowLogs
+func FlowLogsByResource(flowLogs []FlowLogInfo) map[string][]FlowLogInfo {
+	byResource := make(map[string][]FlowLogInfo)
+	for _, fl := range flowLogs {
+		byResource[fl.ResourceID] = append(byResource[fl.ResourceID], fl)
+	}
+	return byResource
+}
@@ -0,0 +1,213 @@
+package vpc
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// instanceTypeBatchSize is the most instance types one DescribeInstanceTypes request accepts
+const instanceTypeBatchSize = 100
+
+// addressesPerPrefix is the number of IPv4 addresses of a delegated /28 prefix
+const addressesPerPrefix = 16
+
+// InstanceInfo describes an instance, where it sits in the network and the addresses it holds in its subnet
+type InstanceInfo struct {
+	InstanceID            string            `json:"instance_id"`              // ID of the instance
+	Arn                   string            `json:"arn"`                      // ARN of the instance (empty if the account is unknown)
+	Region                string            `json:"region"`                   // Region of the instance
+	Name                  string            `json:"name"`                     // Name tag of the instance (empty if untagged)
+	SubnetID              string            `json:"subnet_id"`                // Subnet of the primary network interface
+	VpcID                 string            `json:"vpc_id"`                   // VPC of the instance
+	AvailabilityZone      string            `json:"availability_zone"`        // Availability zone the instance runs in
+	InstanceType          string            `json:"instance_type"`            // Instance type, such as m5.large
+	State                 string            `json:"state"`                    // pending, running, stopping or stopped
+	PrivateIPAddress      string            `json:"private_ip_address"`       // Primary private IPv4 address
+	PublicIPAddress       string            `json:"public_ip_address"`        // Public IPv4 address of the primary interface (empty for none or while stopped)
+	SecurityGroupIDs      []string          `json:"security_group_ids"`       // Security groups of the instance's interfaces
+	IamInstanceProfileArn string            `json:"iam_instance_profile_arn"` // Instance profile whose role the instance assumes (empty for none)
+	NetworkInterfaces     int               `json:"network_interfaces"`       // Network interfaces attached now, in any subnet
+	PrivateIPv4InUse      int               `json:"private_ipv4_in_use"`      // Private IPv4 addresses of its interfaces in its subnet, 16 per delegated prefix
+	Tags                  map[string]string `json:"tags"`                     // Key-value tags of the instance
+	TagList               []Tag             `json:"tag_list"`                 // Tags in API order, including tags without a value
+	ManagedBy             string            `json:"managed_by"`               // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// InstanceTypeNetworkInfo is what an instance type allows in network interfaces, addresses and bandwidth
+type InstanceTypeNetworkInfo struct {
+	InstanceType              string `json:"instance_type"`                // Instance type, such as m5.large
+	NetworkPerformance        string `json:"network_performance"`          // Performance class, such as "Up to 10 Gigabit" or "25 Gigabit"
+	MaxNetworkInterfaces      int    `json:"max_network_interfaces"`       // Network interfaces the type can attach, across all network cards
+	IPv4AddressesPerInterface int    `json:"ipv4_addresses_per_interface"` // Private IPv4 addresses per interface
+	IPv6AddressesPerInterface int    `json:"ipv6_addresses_per_interface"` // IPv6 addresses per interface
+}
+
+// instanceTypesAPI is the part of the EC2 client DescribeInstanceTypes is called on
+type instanceTypesAPI interface {
+	DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error)
+}
+
+// InstanceTypeCache keeps the network properties of instance types for the whole run
+// They are the same in every region, so one cache can serve the scanners of all regions; each type
+// is described once, and types AWS does not know are remembered as well. Safe for concurrent use.
+type InstanceTypeCache struct {
+	mu      sync.Mutex
+	types   map[string]InstanceTypeNetworkInfo // Described types by name
+	unknown map[string]bool                    // Types DescribeInstanceTypes did not return
+	calls   int                                // DescribeInstanceTypes requests so far
+}
+
+// NewInstanceTypeCache creates an empty instance type cache
+func NewInstanceTypeCache() *InstanceTypeCache {
+	return &InstanceTypeCache{types: make(map[string]InstanceTypeNetworkInfo), unknown: make(map[string]bool)}
+}
+
+// Calls returns the number of DescribeInstanceTypes requests made through the cache
+func (c *InstanceTypeCache) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+// lookup returns the network properties of instance types, describing the ones not cached yet
+// Types are described in batches of 100, the API's limit, with every page of each batch read.
+// ctx: Context for the requests
+// client: EC2 client
+// names: Instance types to look up, duplicates allowed
+// Returns: Properties of the types AWS knows, or error if a request fails (types described before it stay cached)
+func (c *InstanceTypeCache) lookup(ctx context.Context, client instanceTypesAPI, names []string) (map[string]InstanceTypeNetworkInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	missing := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := c.types[name]; !ok && !c.unknown[name] && name != "" {
+			missing[name] = true
+		}
+	}
+	pending := sortedSet(missing)
+	for start := 0; start < len(pending); start += instanceTypeBatchSize {
+		batch := pending[start:min(start+instanceTypeBatchSize, len(pending))]
+		input := &ec2.DescribeInstanceTypesInput{InstanceTypes: make([]types.InstanceType, len(batch))}
+		for i, name := range batch {
+			input.InstanceTypes[i] = types.InstanceType(name)
+		}
+		paginator := ec2.NewDescribeInstanceTypesPaginator(client, input)
+		for paginator.HasMorePages() {
+			c.calls++
+			result, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, newScanError("instance types", "DescribeInstanceTypes", err)
+			}
+			for _, info := range result.InstanceTypes {
+				c.types[string(info.InstanceType)] = newInstanceTypeNetworkInfo(info)
+			}
+		}
+		for _, name := range batch {
+			if _, ok := c.types[name]; !ok {
+				c.unknown[name] = true
+			}
+		}
+	}
+
+	found := make(map[string]InstanceTypeNetworkInfo, len(names))
+	for _, name := range names {
+		if info, ok := c.types[name]; ok {
+			found[name] = info
+		}
+	}
+	return found, nil
+}
+
+// newInstanceTypeNetworkInfo converts the network part of an instance type description
+func newInstanceTypeNetworkInfo(info types.InstanceTypeInfo) InstanceTypeNetworkInfo {
+	network := InstanceTypeNetworkInfo{InstanceType: string(info.InstanceType)}
+	if info.NetworkInfo != nil {
+		network.NetworkPerformance = aws.ToString(info.NetworkInfo.NetworkPerformance)
+		network.MaxNetworkInterfaces = int(aws.ToInt32(info.NetworkInfo.MaximumNetworkInterfaces))
+		network.IPv4AddressesPerInterface = int(aws.ToInt32(info.NetworkInfo.Ipv4AddressesPerInterface))
+		network.IPv6AddressesPerInterface = int(aws.ToInt32(info.NetworkInfo.Ipv6AddressesPerInterface))
+	}
+	return network
+}
+
+// SetInstanceTypeCache makes the scanner share an instance type cache, such as with the scanners of other regions
+// cache: Cache of the run
+func (s *Scanner) SetInstanceTypeCache(cache *InstanceTypeCache) {
+	s.instanceTypes = cache
+}
+
+// GetInstanceTypes returns the network properties of instance types, described once per run
+// ctx: Context for the request, allowing for timeout and cancellation
+// names: Instance types, such as those of GetInstances; duplicates are fine
+// Returns: Properties by type of the types AWS knows, or error if the operation fails
+func (s *Scanner) GetInstanceTypes(ctx context.Context, names []string) (map[string]InstanceTypeNetworkInfo, error) {
+	if s.instanceTypes == nil {
+		s.instanceTypes = NewInstanceTypeCache()
+	}
+	return s.instanceTypes.lookup(ctx, s.ec2Client, names)
+}
+
+// GetInstances retrieves the instances that hold addresses: pending, running, stopping and stopped ones
+// Stopped instances keep their network interfaces and private addresses, so they count as well;
+// terminated and shutting-down instances are left out. The instances of all reservations are listed together.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Instances sorted by ID, or error if the operation fails
+func (s *Scanner) GetInstances(ctx context.Context) ([]InstanceInfo, error) {
+	instances := []InstanceInfo{}
+	paginator := ec2.NewDescribeInstancesPaginator(s.ec2Client, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}}},
+	})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("instances", "DescribeInstances", err)
+		}
+		for _, reservation := range result.Reservations {
+			for _, instance := range reservation.Instances {
+				info := InstanceInfo{
+					InstanceID:        aws.ToString(instance.InstanceId),
+					Region:            s.region,
+					SubnetID:          aws.ToString(instance.SubnetId),
+					VpcID:             aws.ToString(instance.VpcId),
+					InstanceType:      string(instance.InstanceType),
+					PrivateIPAddress:  aws.ToString(instance.PrivateIpAddress),
+					PublicIPAddress:   aws.ToString(instance.PublicIpAddress),
+					SecurityGroupIDs:  []string{},
+					NetworkInterfaces: len(instance.NetworkInterfaces),
+					Tags:              convertTags(instance.Tags),
+					TagList:           convertTagList(instance.Tags),
+				}
+				info.Name = info.Tags["Name"]
+				if instance.State != nil {
+					info.State = string(instance.State.Name)
+				}
+				if instance.Placement != nil {
+					info.AvailabilityZone = aws.ToString(instance.Placement.AvailabilityZone)
+				}
+				if instance.IamInstanceProfile != nil {
+					info.IamInstanceProfileArn = aws.ToString(instance.IamInstanceProfile.Arn)
+				}
+				for _, group := range instance.SecurityGroups {
+					info.SecurityGroupIDs = append(info.SecurityGroupIDs, aws.ToString(group.GroupId))
+				}
+				for _, eni := range instance.NetworkInterfaces {
+					if aws.ToString(eni.SubnetId) == info.SubnetID {
+						info.PrivateIPv4InUse += len(eni.PrivateIpAddresses) + addressesPerPrefix*len(eni.Ipv4Prefixes)
+					}
+				}
+				info.Arn = s.arn(arnbuild.TypeInstance, "", info.InstanceID)
+				instances = append(instances, info)
+			}
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].InstanceID < instances[j].InstanceID })
+	return instances, nil
+}
@@ -0,0 +1,195 @@
+package vpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// fakeInstanceTypes answers DescribeInstanceTypes in pages, leaving out the types ending in .retired
+type fakeInstanceTypes struct {
+	pageSize int        // Types per page of a request
+	failAt   int        // Request that fails, counting from 1 (0 for none)
+	requests [][]string // Types of every request, including those of later pages
+}
+
+func (f *fakeInstanceTypes) DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+	var names []string
+	for _, name := range params.InstanceTypes {
+		names = append(names, string(name))
+	}
+	f.requests = append(f.requests, names)
+	if len(f.requests) == f.failAt {
+		return nil, apiError("RequestLimitExceeded")
+	}
+
+	offset, _ := strconv.Atoi(aws.ToString(params.NextToken))
+	out := &ec2.DescribeInstanceTypesOutput{}
+	for _, name := range names[offset:min(offset+f.pageSize, len(names))] {
+		if !strings.HasSuffix(name, ".retired") {
+			out.InstanceTypes = append(out.InstanceTypes, types.InstanceTypeInfo{InstanceType: types.InstanceType(name),
+				NetworkInfo: &types.NetworkInfo{MaximumNetworkInterfaces: aws.Int32(3), Ipv4AddressesPerInterface: aws.Int32(10)}})
+		}
+	}
+	if offset+f.pageSize < len(names) {
+		out.NextToken = aws.String(strconv.Itoa(offset + f.pageSize))
+	}
+	return out, nil
+}
+
+// instanceTypeNames returns n instance type names in sorted order
+func instanceTypeNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("c%03d.large", i)
+	}
+	return names
+}
+
+// TestInstanceTypeCacheBatching checks that types are described in sorted batches of 100, every page of a
+// batch is read, duplicates and empty names are dropped, and described and unknown types are not asked for again
+func TestInstanceTypeCacheBatching(t *testing.T) {
+	names := append(instanceTypeNames(250), "x1.retired", "x2.retired")
+	client := &fakeInstanceTypes{pageSize: 60}
+	cache := NewInstanceTypeCache()
+
+	found, err := cache.lookup(context.Background(), client, append(append([]string{"", "c000.large"}, names...), "c249.large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 250 || found["c123.large"] != (InstanceTypeNetworkInfo{InstanceType: "c123.large", MaxNetworkInterfaces: 3, IPv4AddressesPerInterface: 10}) {
+		t.Errorf("lookup() found %d types, c123.large = %+v", len(found), found["c123.large"])
+	}
+	// Batches of 100, 100 and 52 types, the first two read in two pages of 60
+	var sizes []int
+	var requested []string
+	for _, request := range client.requests {
+		sizes = append(sizes, len(request))
+		if len(request) > instanceTypeBatchSize {
+			t.Errorf("request of %d types, want at most %d", len(request), instanceTypeBatchSize)
+		}
+	}
+	for _, i := range []int{0, 2, 4} {
+		requested = append(requested, client.requests[i]...)
+	}
+	if want := []int{100, 100, 100, 100, 52}; !reflect.DeepEqual(sizes, want) || cache.Calls() != 5 {
+		t.Errorf("requests of %v types (%d calls), want %v", sizes, cache.Calls(), want)
+	}
+	if !reflect.DeepEqual(requested, names) {
+		t.Errorf("batches = %v\nwant every type once, sorted: %v", requested, names)
+	}
+
+	client.requests = nil
+	found, err = cache.lookup(context.Background(), client, []string{"c001.large", "x1.retired", "m7i.large", "c001.large"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"m7i.large"}}; !reflect.DeepEqual(client.requests, want) || cache.Calls() != 6 {
+		t.Errorf("requests of a second lookup = %v (%d calls in all), want %v", client.requests, cache.Calls(), want)
+	}
+	var got []string
+	for name := range found {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	if want := []string{"c001.large", "m7i.large"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second lookup found %v, want %v", got, want)
+	}
+}
+
+// TestInstanceTypeCacheError checks that a failing request returns a scan error and that the batches
+// described before it are not asked for again
+func TestInstanceTypeCacheError(t *testing.T) {
+	names := instanceTypeNames(250)
+	client := &fakeInstanceTypes{pageSize: 100, failAt: 2}
+	cache := NewInstanceTypeCache()
+
+	_, err := cache.lookup(context.Background(), client, names)
+	var scanErr *ScanError
+	if !errors.As(err, &scanErr) || scanErr.Operation != "DescribeInstanceTypes" || !errors.Is(err, ErrThrottled) {
+		t.Fatalf("lookup() error = %v, want a throttled DescribeInstanceTypes scan error", err)
+	}
+
+	client.requests, client.failAt = nil, 0
+	found, err := cache.lookup(context.Background(), client, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 250 || len(client.requests) != 2 || client.requests[0][0] != "c100.large" || len(client.requests[1]) != 50 {
+		t.Errorf("retry found %d types with requests of %d types, want 250 from the two batches that failed", len(found), len(client.requests))
+	}
+}
+
+// TestGetInstanceTypes reads the network limits of a type and of a type without network information
+func TestGetInstanceTypes(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeInstanceTypes": `<instanceTypeSet>
+		<item><instanceType>m5.large</instanceType><networkInfo><networkPerformance>Up to 10 Gigabit</networkPerformance>
+			<maximumNetworkInterfaces>3</maximumNetworkInterfaces><ipv4AddressesPerInterface>10</ipv4AddressesPerInterface>
+			<ipv6AddressesPerInterface>10</ipv6AddressesPerInterface></networkInfo></item>
+		<item><instanceType>mac1.metal</instanceType></item>
+	</instanceTypeSet>`}, 0)
+
+	got, err := scanner.GetInstanceTypes(context.Background(), []string{"m5.large", "mac1.metal", "m5.large"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]InstanceTypeNetworkInfo{
+		"m5.large": {InstanceType: "m5.large", NetworkPerformance: "Up to 10 Gigabit", MaxNetworkInterfaces: 3,
+			IPv4AddressesPerInterface: 10, IPv6AddressesPerInterface: 10},
+		"mac1.metal": {InstanceType: "mac1.metal"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetInstanceTypes() = %+v\nwant %+v", got, want)
+	}
+}
+
+// TestGetInstances reads instances of two reservations and counts the addresses each holds in its own
+// subnet, with 16 per delegated prefix and none for an interface in another subnet
+func TestGetInstances(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeInstances": `<reservationSet>
+		<item><instancesSet><item><instanceId>i-0web</instanceId><instanceType>m5.large</instanceType>
+			<instanceState><name>running</name></instanceState><placement><availabilityZone>eu-west-1a</availabilityZone></placement>
+			<subnetId>subnet-0a1</subnetId><vpcId>vpc-0a1</vpcId><privateIpAddress>10.0.1.10</privateIpAddress><ipAddress>203.0.113.10</ipAddress>
+			<groupSet><item><groupId>sg-0web</groupId></item></groupSet>
+			<iamInstanceProfile><arn>arn:aws:iam::111122223333:instance-profile/web</arn></iamInstanceProfile>
+			<tagSet><item><key>Name</key><value>web-1</value></item></tagSet>
+			<networkInterfaceSet>
+				<item><subnetId>subnet-0a1</subnetId><privateIpAddressesSet><item><privateIpAddress>10.0.1.10</privateIpAddress></item>
+					<item><privateIpAddress>10.0.1.11</privateIpAddress></item></privateIpAddressesSet>
+					<ipv4PrefixSet><item><ipv4Prefix>10.0.1.32/28</ipv4Prefix></item></ipv4PrefixSet></item>
+				<item><subnetId>subnet-0b2</subnetId><privateIpAddressesSet><item><privateIpAddress>10.0.2.10</privateIpAddress></item></privateIpAddressesSet></item>
+			</networkInterfaceSet></item></instancesSet></item>
+		<item><instancesSet><item><instanceId>i-0batch</instanceId><instanceType>t3.micro</instanceType>
+			<instanceState><name>stopped</name></instanceState><subnetId>subnet-0b2</subnetId><vpcId>vpc-0a1</vpcId>
+			<privateIpAddress>10.0.2.20</privateIpAddress>
+			<networkInterfaceSet><item><subnetId>subnet-0b2</subnetId>
+				<privateIpAddressesSet><item><privateIpAddress>10.0.2.20</privateIpAddress></item></privateIpAddressesSet></item></networkInterfaceSet>
+		</item></instancesSet></item>
+	</reservationSet>`}, 0)
+
+	got, err := scanner.GetInstances(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InstanceInfo{
+		{InstanceID: "i-0batch", Region: "eu-west-1", SubnetID: "subnet-0b2", VpcID: "vpc-0a1", InstanceType: "t3.micro", State: "stopped",
+			PrivateIPAddress: "10.0.2.20", SecurityGroupIDs: []string{}, NetworkInterfaces: 1, PrivateIPv4InUse: 1,
+			Tags: map[string]string{}, TagList: []Tag{}},
+		{InstanceID: "i-0web", Region: "eu-west-1", Name: "web-1", SubnetID: "subnet-0a1", VpcID: "vpc-0a1", AvailabilityZone: "eu-west-1a",
+			InstanceType: "m5.large", State: "running", PrivateIPAddress: "10.0.1.10", PublicIPAddress: "203.0.113.10",
+			SecurityGroupIDs: []string{"sg-0web"}, IamInstanceProfileArn: "arn:aws:iam::111122223333:instance-profile/web",
+			NetworkInterfaces: 2, PrivateIPv4InUse: 18, Tags: map[string]string{"Name": "web-1"}, TagList: []Tag{{Key: "Name", Value: "web-1"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetInstances() = %+v\nwant %+v", got, want)
+	}
+}
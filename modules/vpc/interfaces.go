@@ -0,0 +1,60 @@
+package vpc
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// InterfaceGroupsInfo lists the security groups and addresses of one network interface
+type InterfaceGroupsInfo struct {
+	NetworkInterfaceID string   `json:"network_interface_id"` // ID of the network interface
+	InterfaceType      string   `json:"interface_type"`       // Interface type (interface, nat_gateway, vpc_endpoint, ...)
+	Description        string   `json:"description"`          // Description AWS or the owner set, names the managing service for requester-managed interfaces
+	VpcID              string   `json:"vpc_id"`               // VPC the interface belongs to
+	SubnetID           string   `json:"subnet_id"`            // Subnet the interface is in
+	PrivateIPs         []string `json:"private_ips"`          // Private IPv4 and IPv6 addresses of the interface
+	GroupIDs           []string `json:"group_ids"`            // Security groups attached to the interface
+}
+
+// GetInterfaceGroups retrieves the security groups attached to every network interface in the region
+// Interfaces of awsvpc ECS tasks and Fargate pods only exist while the task runs, so a group
+// without interfaces is not necessarily unused.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Slice of InterfaceGroupsInfo structs, or error if the operation fails
+func (s *Scanner) GetInterfaceGroups(ctx context.Context) ([]InterfaceGroupsInfo, error) {
+	interfaces := []InterfaceGroupsInfo{}
+
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(s.ec2Client, &ec2.DescribeNetworkInterfacesInput{})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("network interfaces", "DescribeNetworkInterfaces", err)
+		}
+
+		for _, eni := range result.NetworkInterfaces {
+			info := InterfaceGroupsInfo{
+				NetworkInterfaceID: aws.ToString(eni.NetworkInterfaceId),
+				InterfaceType:      string(eni.InterfaceType),
+				Description:        aws.ToString(eni.Description),
+				VpcID:              aws.ToString(eni.VpcId),
+				SubnetID:           aws.ToString(eni.SubnetId),
+				PrivateIPs:         []string{},
+				GroupIDs:           []string{},
+			}
+			for _, address := range eni.PrivateIpAddresses {
+				info.PrivateIPs = append(info.PrivateIPs, aws.ToString(address.PrivateIpAddress))
+			}
+			for _, address := range eni.Ipv6Addresses {
+				info.PrivateIPs = append(info.PrivateIPs, aws.ToString(address.Ipv6Address))
+			}
+			for _, group := range eni.Groups {
+				info.GroupIDs = append(info.GroupIDs, aws.ToString(group.GroupId))
+			}
+			interfaces = append(interfaces, info)
+		}
+	}
+
+	return interfaces, nil
+}
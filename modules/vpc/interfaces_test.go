@@ -0,0 +1,36 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestGetInterfaceGroups reads the groups and private addresses of an ECS task interface and of a
+// requester-managed interface without groups
+func TestGetInterfaceGroups(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeNetworkInterfaces": `<networkInterfaceSet>
+		<item><networkInterfaceId>eni-0task</networkInterfaceId><interfaceType>interface</interfaceType>
+			<description>arn:aws:ecs:eu-west-1:111122223333:attachment/1</description><vpcId>vpc-0a1</vpcId><subnetId>subnet-0a1</subnetId>
+			<privateIpAddressesSet><item><privateIpAddress>10.0.1.20</privateIpAddress></item><item><privateIpAddress>10.0.1.21</privateIpAddress></item></privateIpAddressesSet>
+			<ipv6AddressesSet><item><ipv6Address>2001:db8::20</ipv6Address></item></ipv6AddressesSet>
+			<groupSet><item><groupId>sg-0tasks</groupId><groupName>api-tasks</groupName></item><item><groupId>sg-0web</groupId></item></groupSet></item>
+		<item><networkInterfaceId>eni-0nat</networkInterfaceId><interfaceType>nat_gateway</interfaceType>
+			<description>Interface for NAT Gateway nat-0a1</description><vpcId>vpc-0a1</vpcId><subnetId>subnet-0b2</subnetId>
+			<privateIpAddressesSet><item><privateIpAddress>10.0.2.5</privateIpAddress></item></privateIpAddressesSet></item>
+	</networkInterfaceSet>`}, 0)
+
+	got, err := scanner.GetInterfaceGroups(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InterfaceGroupsInfo{
+		{NetworkInterfaceID: "eni-0task", InterfaceType: "interface", Description: "arn:aws:ecs:eu-west-1:111122223333:attachment/1",
+			VpcID: "vpc-0a1", SubnetID: "subnet-0a1", PrivateIPs: []string{"10.0.1.20", "10.0.1.21", "2001:db8::20"}, GroupIDs: []string{"sg-0tasks", "sg-0web"}},
+		{NetworkInterfaceID: "eni-0nat", InterfaceType: "nat_gateway", Description: "Interface for NAT Gateway nat-0a1",
+			VpcID: "vpc-0a1", SubnetID: "subnet-0b2", PrivateIPs: []string{"10.0.2.5"}, GroupIDs: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetInterfaceGroups() = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package vpc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// DefaultNACLRuleNumber is the rule number of the catch-all deny entry every network ACL ends with
+const DefaultNACLRuleNumber = 32767
+
+// NetworkACLInfo contains information about a network ACL, the stateless filter of the subnets it is associated with
+type NetworkACLInfo struct {
+	NetworkAclID string            `json:"network_acl_id"` // Unique identifier for the network ACL
+	Arn          string            `json:"arn"`            // ARN of the network ACL
+	Region       string            `json:"region"`         // Region of the network ACL
+	VpcID        string            `json:"vpc_id"`         // ID of the VPC that contains this network ACL
+	IsDefault    bool              `json:"is_default"`     // Whether this is the default network ACL of the VPC
+	SubnetIDs    []string          `json:"subnet_ids"`     // Subnets associated with the network ACL
+	Entries      []NetworkACLEntry `json:"entries"`        // Inbound entries by rule number, then outbound entries by rule number
+	OwnerID      string            `json:"owner_id"`       // AWS account ID that owns the network ACL
+	Tags         map[string]string `json:"tags"`           // Key-value tags associated with the network ACL
+	TagList      []Tag             `json:"tag_list"`       // Tags in API order, including tags without a value
+	ManagedBy    string            `json:"managed_by"`     // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// NetworkACLEntry is one numbered rule of a network ACL
+// Entries are evaluated in rule number order per direction, and the first match decides.
+type NetworkACLEntry struct {
+	RuleNumber    int32          `json:"rule_number"`     // Position of the rule; 32767 is the catch-all deny entry
+	Protocol      string         `json:"protocol"`        // Protocol number (6 for TCP, 17 for UDP, 1 for ICMP, -1 for all)
+	RuleAction    string         `json:"rule_action"`     // allow or deny
+	Egress        bool           `json:"egress"`          // Whether the rule applies to outbound traffic
+	CidrBlock     string         `json:"cidr_block"`      // IPv4 range the rule matches (empty for IPv6 rules)
+	Ipv6CidrBlock string         `json:"ipv6_cidr_block"` // IPv6 range the rule matches (empty for IPv4 rules)
+	PortRange     *NACLPortRange `json:"port_range"`      // Ports the rule matches (null for protocols without ports)
+	IcmpType      *int32         `json:"icmp_type"`       // ICMP type the rule matches, -1 for all (null unless ICMP)
+	IcmpCode      *int32         `json:"icmp_code"`       // ICMP code the rule matches, -1 for all (null unless ICMP)
+}
+
+// NACLPortRange is the port range of a network ACL entry
+type NACLPortRange struct {
+	From int32 `json:"from"` // First port of the range
+	To   int32 `json:"to"`   // Last port of the range
+}
+
+// GetNetworkACLs retrieves information about all network ACLs in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of NetworkACLInfo structs with their subnets and entries, or error if the operation fails
+func (s *Scanner) GetNetworkACLs(ctx context.Context, opts ...ScanOptions) ([]NetworkACLInfo, error) {
+	acls := []NetworkACLInfo{}
+
+	paginator := ec2.NewDescribeNetworkAclsPaginator(s.ec2Client, &ec2.DescribeNetworkAclsInput{Filters: tagFilters(opts)})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("network ACLs", "DescribeNetworkAcls", err)
+		}
+
+		for _, acl := range result.NetworkAcls {
+			aclID := s.required("network ACL", "", "NetworkAclId", acl.NetworkAclId)
+			info := NetworkACLInfo{
+				NetworkAclID: aclID,
+				Arn:          s.arn(arnbuild.TypeNetworkACL, aws.ToString(acl.OwnerId), aclID),
+				Region:       s.region,
+				VpcID:        s.required("network ACL", aclID, "VpcId", acl.VpcId),
+				IsDefault:    aws.ToBool(acl.IsDefault),
+				SubnetIDs:    []string{},
+				Entries:      []NetworkACLEntry{},
+				OwnerID:      aws.ToString(acl.OwnerId),
+				Tags:         convertTags(acl.Tags),
+				TagList:      convertTagList(acl.Tags),
+			}
+			for _, association := range acl.Associations {
+				if subnetID := aws.ToString(association.SubnetId); subnetID != "" {
+					info.SubnetIDs = append(info.SubnetIDs, subnetID)
+				}
+			}
+			sort.Strings(info.SubnetIDs)
+
+			for _, entry := range acl.Entries {
+				aclEntry := NetworkACLEntry{
+					RuleNumber:    aws.ToInt32(entry.RuleNumber),
+					Protocol:      aws.ToString(entry.Protocol),
+					RuleAction:    string(entry.RuleAction),
+					Egress:        aws.ToBool(entry.Egress),
+					CidrBlock:     aws.ToString(entry.CidrBlock),
+					Ipv6CidrBlock: aws.ToString(entry.Ipv6CidrBlock),
+				}
+				if entry.PortRange != nil {
+					aclEntry.PortRange = &NACLPortRange{From: aws.ToInt32(entry.PortRange.From), To: aws.ToInt32(entry.PortRange.To)}
+				}
+				if entry.IcmpTypeCode != nil {
+					aclEntry.IcmpType = entry.IcmpTypeCode.Type
+					aclEntry.IcmpCode = entry.IcmpTypeCode.Code
+				}
+				info.Entries = append(info.Entries, aclEntry)
+			}
+			SortNACLEntries(info.Entries)
+			acls = append(acls, info)
+		}
+	}
+
+	return acls, nil
+}
+
+// SortNACLEntries orders entries as they are evaluated: inbound before outbound, each by rule number
+// The IPv4 catch-all entry comes before the IPv6 one, which shares its rule number.
+func SortNACLEntries(entries []NetworkACLEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Egress != b.Egress {
+			return !a.Egress
+		}
+		if a.RuleNumber != b.RuleNumber {
+			return a.RuleNumber < b.RuleNumber
+		}
+		return a.CidrBlock > b.CidrBlock
+	})
+}
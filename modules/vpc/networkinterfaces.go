@@ -0,0 +1,138 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// NetworkInterfaceInfo contains information about an elastic network interface and what it is attached to
+type NetworkInterfaceInfo struct {
+	NetworkInterfaceID string            `json:"network_interface_id"` // Unique identifier for the network interface
+	Arn                string            `json:"arn"`                  // ARN of the network interface
+	Region             string            `json:"region"`               // Region of the network interface
+	OwnerID            string            `json:"owner_id"`             // Account that owns the interface
+	VpcID              string            `json:"vpc_id"`               // VPC the interface belongs to
+	SubnetID           string            `json:"subnet_id"`            // Subnet the interface is in
+	AvailabilityZone   string            `json:"availability_zone"`    // Availability zone of the subnet
+	Description        string            `json:"description"`          // Description AWS or the owner set, names the managing service for requester-managed interfaces
+	InterfaceType      string            `json:"interface_type"`       // Interface type (interface, nat_gateway, vpc_endpoint, lambda, ...)
+	Status             string            `json:"status"`               // Status of the interface (available, in-use, ...); available means unattached
+	InstanceID         string            `json:"instance_id"`          // Instance the interface is attached to (empty for none or a service)
+	DeviceIndex        int32             `json:"device_index"`         // Device index of the attachment on the instance (0 for the primary interface or none)
+	RequesterID        string            `json:"requester_id"`         // Service or account that created the interface on the owner's behalf (empty if the owner did)
+	RequesterManaged   bool              `json:"requester_managed"`    // Whether an AWS service manages the interface
+	PrivateIPAddress   string            `json:"private_ip_address"`   // Primary private IPv4 address
+	PrivateIPs         []string          `json:"private_ips"`          // All private IPv4 addresses, the primary first
+	IPv6Addresses      []string          `json:"ipv6_addresses"`       // IPv6 addresses of the interface
+	PublicIP           string            `json:"public_ip"`            // Public IPv4 address associated with the primary private address (empty for none)
+	SecurityGroupIDs   []string          `json:"security_group_ids"`   // Security groups attached to the interface
+	SourceDestCheck    bool              `json:"source_dest_check"`    // Whether traffic not addressed to the interface is dropped; false for appliances
+	Tags               map[string]string `json:"tags"`                 // Key-value tags associated with the network interface
+	TagList            []Tag             `json:"tag_list"`             // Tags in API order, including tags without a value
+	ManagedBy          string            `json:"managed_by"`           // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// GetNetworkInterfaces retrieves information about all network interfaces in the configured AWS region
+// Interfaces of awsvpc ECS tasks, Fargate pods and Lambda functions come and go with them, so the list
+// is a snapshot.
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of NetworkInterfaceInfo structs, or error if the operation fails
+func (s *Scanner) GetNetworkInterfaces(ctx context.Context, opts ...ScanOptions) ([]NetworkInterfaceInfo, error) {
+	interfaces, err := s.describeNetworkInterfaces(ctx, tagFilters(opts))
+	if err != nil {
+		return nil, newScanError("network interfaces", "DescribeNetworkInterfaces", err)
+	}
+	return interfaces, nil
+}
+
+// GetNetworkInterfacesByVPC retrieves information about all network interfaces within a specific VPC
+// ctx: Context for the request, allowing for timeout and cancellation
+// vpcID: The unique identifier of the VPC to filter network interfaces by
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of NetworkInterfaceInfo structs for interfaces in the specified VPC, or error if the operation fails
+func (s *Scanner) GetNetworkInterfacesByVPC(ctx context.Context, vpcID string, opts ...ScanOptions) ([]NetworkInterfaceInfo, error) {
+	filters := append([]types.Filter{
+		{
+			Name:   aws.String("vpc-id"),
+			Values: []string{vpcID},
+		},
+	}, tagFilters(opts)...)
+
+	interfaces, err := s.describeNetworkInterfaces(ctx, filters)
+	if err != nil {
+		return nil, newScanError("network interfaces", "DescribeNetworkInterfaces", fmt.Errorf("VPC %s: %w", vpcID, err))
+	}
+	return interfaces, nil
+}
+
+// describeNetworkInterfaces pages through DescribeNetworkInterfaces with the given filters
+// Returns: The interfaces, or the error of the failing page
+func (s *Scanner) describeNetworkInterfaces(ctx context.Context, filters []types.Filter) ([]NetworkInterfaceInfo, error) {
+	interfaces := []NetworkInterfaceInfo{}
+
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(s.ec2Client, &ec2.DescribeNetworkInterfacesInput{Filters: filters})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, eni := range result.NetworkInterfaces {
+			eniID := s.required("network interface", "", "NetworkInterfaceId", eni.NetworkInterfaceId)
+			ownerID := aws.ToString(eni.OwnerId)
+			info := NetworkInterfaceInfo{
+				NetworkInterfaceID: eniID,
+				Arn:                s.arn(arnbuild.TypeNetworkInterface, ownerID, eniID),
+				Region:             s.region,
+				OwnerID:            ownerID,
+				VpcID:              s.required("network interface", eniID, "VpcId", eni.VpcId),
+				SubnetID:           s.required("network interface", eniID, "SubnetId", eni.SubnetId),
+				AvailabilityZone:   aws.ToString(eni.AvailabilityZone),
+				Description:        aws.ToString(eni.Description),
+				InterfaceType:      enumValue(s, "network interface", eniID, "InterfaceType", eni.InterfaceType),
+				Status:             enumValue(s, "network interface", eniID, "Status", eni.Status),
+				RequesterID:        aws.ToString(eni.RequesterId),
+				RequesterManaged:   aws.ToBool(eni.RequesterManaged),
+				PrivateIPAddress:   aws.ToString(eni.PrivateIpAddress),
+				PrivateIPs:         []string{},
+				IPv6Addresses:      []string{},
+				SecurityGroupIDs:   []string{},
+				SourceDestCheck:    aws.ToBool(eni.SourceDestCheck),
+				Tags:               convertTags(eni.TagSet),
+				TagList:            convertTagList(eni.TagSet),
+			}
+			if eni.Attachment != nil {
+				info.InstanceID = aws.ToString(eni.Attachment.InstanceId)
+				info.DeviceIndex = aws.ToInt32(eni.Attachment.DeviceIndex)
+			}
+			if eni.Association != nil {
+				info.PublicIP = aws.ToString(eni.Association.PublicIp)
+			}
+			// The API lists the primary address first
+			for _, address := range eni.PrivateIpAddresses {
+				info.PrivateIPs = append(info.PrivateIPs, aws.ToString(address.PrivateIpAddress))
+			}
+			for _, address := range eni.Ipv6Addresses {
+				info.IPv6Addresses = append(info.IPv6Addresses, aws.ToString(address.Ipv6Address))
+			}
+			for _, group := range eni.Groups {
+				info.SecurityGroupIDs = append(info.SecurityGroupIDs, aws.ToString(group.GroupId))
+			}
+			interfaces = append(interfaces, info)
+		}
+	}
+
+	return interfaces, nil
+}
+
+// Unattached reports whether the network interface is attached to nothing (status available)
+func (n NetworkInterfaceInfo) Unattached() bool {
+	return n.Status == string(types.NetworkInterfaceStatusAvailable)
+}
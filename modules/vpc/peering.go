@@ -0,0 +1,69 @@
+package vpc
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// VpcPeeringConnectionInfo contains information about a VPC peering connection
+// Inter-region and cross-account connections are visible from both sides
+type VpcPeeringConnectionInfo struct {
+	VpcPeeringConnectionID string            `json:"vpc_peering_connection_id"` // Unique identifier for the peering connection
+	Arn                    string            `json:"arn"`                       // ARN of the connection in the scanned account (empty if the account is unknown)
+	Region                 string            `json:"region"`                    // Region the connection was scanned in, the requester or accepter region
+	RequesterVpcID         string            `json:"requester_vpc_id"`          // ID of the requester VPC
+	RequesterRegion        string            `json:"requester_region"`          // Region of the requester VPC
+	RequesterOwnerID       string            `json:"requester_owner_id"`        // AWS account ID that owns the requester VPC
+	AccepterVpcID          string            `json:"accepter_vpc_id"`           // ID of the accepter VPC
+	AccepterRegion         string            `json:"accepter_region"`           // Region of the accepter VPC
+	AccepterOwnerID        string            `json:"accepter_owner_id"`         // AWS account ID that owns the accepter VPC
+	Status                 string            `json:"status"`                    // Status of the connection (pending-acceptance, active, deleted, ...)
+	Tags                   map[string]string `json:"tags"`                      // Key-value tags associated with the peering connection
+	TagList                []Tag             `json:"tag_list"`                  // Tags in API order, including tags without a value
+}
+
+// GetVpcPeeringConnections retrieves information about all VPC peering connections in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of VpcPeeringConnectionInfo structs, or error if the operation fails
+func (s *Scanner) GetVpcPeeringConnections(ctx context.Context, opts ...ScanOptions) ([]VpcPeeringConnectionInfo, error) {
+	connections := []VpcPeeringConnectionInfo{}
+
+	paginator := ec2.NewDescribeVpcPeeringConnectionsPaginator(s.ec2Client, &ec2.DescribeVpcPeeringConnectionsInput{Filters: tagFilters(opts)})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newScanError("VPC peering connections", "DescribeVpcPeeringConnections", err)
+		}
+
+		for _, pcx := range result.VpcPeeringConnections {
+			info := VpcPeeringConnectionInfo{
+				VpcPeeringConnectionID: aws.ToString(pcx.VpcPeeringConnectionId),
+				Arn:                    s.arn(arnbuild.TypeVpcPeeringConnection, "", aws.ToString(pcx.VpcPeeringConnectionId)),
+				Region:                 s.region,
+				Tags:                   convertTags(pcx.Tags),
+				TagList:                convertTagList(pcx.Tags),
+			}
+			if pcx.RequesterVpcInfo != nil {
+				info.RequesterVpcID = aws.ToString(pcx.RequesterVpcInfo.VpcId)
+				info.RequesterRegion = aws.ToString(pcx.RequesterVpcInfo.Region)
+				info.RequesterOwnerID = aws.ToString(pcx.RequesterVpcInfo.OwnerId)
+			}
+			if pcx.AccepterVpcInfo != nil {
+				info.AccepterVpcID = aws.ToString(pcx.AccepterVpcInfo.VpcId)
+				info.AccepterRegion = aws.ToString(pcx.AccepterVpcInfo.Region)
+				info.AccepterOwnerID = aws.ToString(pcx.AccepterVpcInfo.OwnerId)
+			}
+			if pcx.Status != nil {
+				info.Status = string(pcx.Status.Code)
+			}
+			connections = append(connections, info)
+		}
+	}
+
+	return connections, nil
+}
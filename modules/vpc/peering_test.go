@@ -0,0 +1,31 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestGetVpcPeeringConnections checks that both sides of an inter-region connection and its status are read
+func TestGetVpcPeeringConnections(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeVpcPeeringConnections": `<vpcPeeringConnectionSet><item>
+		<vpcPeeringConnectionId>pcx-0dr</vpcPeeringConnectionId>
+		<requesterVpcInfo><vpcId>vpc-0prim</vpcId><region>eu-west-1</region><ownerId>111122223333</ownerId></requesterVpcInfo>
+		<accepterVpcInfo><vpcId>vpc-0dr</vpcId><region>us-east-1</region><ownerId>444455556666</ownerId></accepterVpcInfo>
+		<status><code>active</code><message>Active</message></status>
+		<tagSet><item><key>Name</key><value>prod-to-dr</value></item></tagSet>
+	</item></vpcPeeringConnectionSet>`}, 0)
+	got, err := scanner.GetVpcPeeringConnections(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VpcPeeringConnectionInfo{{
+		VpcPeeringConnectionID: "pcx-0dr", Region: "eu-west-1",
+		RequesterVpcID: "vpc-0prim", RequesterRegion: "eu-west-1", RequesterOwnerID: "111122223333",
+		AccepterVpcID: "vpc-0dr", AccepterRegion: "us-east-1", AccepterOwnerID: "444455556666",
+		Status: "active", Tags: map[string]string{"Name": "prod-to-dr"}, TagList: []Tag{{Key: "Name", Value: "prod-to-dr"}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetVpcPeeringConnections() = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+package vpc
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// Kinds of public IP addresses
+const (
+	PublicIPStatic    = "static"    // An Elastic IP (or BYOIP) address that stays with the account until released
+	PublicIPEphemeral = "ephemeral" // An address from the Amazon pool that changes when the resource stops or is replaced
+)
+
+// Kinds of resources a public IP address is attached to
+const (
+	PublicIPResourceInstance         = "instance"          // An EC2 instance
+	PublicIPResourceNatGateway       = "nat-gateway"       // A NAT gateway
+	PublicIPResourceLoadBalancer     = "load-balancer"     // A node of an Application, Network or Classic Load Balancer
+	PublicIPResourceNetworkInterface = "network-interface" // Any other network interface (the interface type is in the resource ID)
+	PublicIPResourceNone             = "none"              // An Elastic IP that is not associated with anything
+)
+
+// instanceFilterLimit is the maximum number of values in one EC2 filter
+const instanceFilterLimit = 200
+
+// natGatewayInterfaceType is the interface type DescribeNetworkInterfaces reports for NAT gateways
+// The SDK enum spells it natGateway, which only some request parameters use.
+const natGatewayInterfaceType types.NetworkInterfaceType = "nat_gateway"
+
+// checkpointPublicIPs is the checkpoint name of the network interface pagination of GetPublicIPs
+const checkpointPublicIPs = "public-ip-interfaces"
+
+// PublicIPInfo describes a public IPv4 address of the region and what it is attached to
+type PublicIPInfo struct {
+	PublicIp             string            `json:"public_ip"`              // Public IPv4 address
+	Kind                 string            `json:"kind"`                   // static or ephemeral
+	AllocationID         string            `json:"allocation_id"`          // Allocation ID of an Elastic IP
+	Arn                  string            `json:"arn"`                    // ARN of the Elastic IP (empty for ephemeral addresses)
+	AttachedResourceType string            `json:"attached_resource_type"` // instance, nat-gateway, load-balancer, network-interface or none
+	AttachedResourceID   string            `json:"attached_resource_id"`   // Instance ID, NAT gateway ID, load balancer (app/name/id), or interface ID and type
+	NetworkInterfaceID   string            `json:"network_interface_id"`   // Network interface holding the address (empty if unattached)
+	PrivateIp            string            `json:"private_ip"`             // Private IP address the public address maps to
+	VpcID                string            `json:"vpc_id"`                 // VPC of the network interface
+	SubnetID             string            `json:"subnet_id"`              // Subnet of the network interface
+	SecurityGroupIDs     []string          `json:"security_group_ids"`     // Security groups of the network interface
+	InstanceLaunchTime   string            `json:"instance_launch_time"`   // Last launch (start) time of an attached instance
+	Tags                 map[string]string `json:"tags"`                   // Tags of the Elastic IP, or of the network interface for ephemeral addresses
+	TagList              []Tag             `json:"tag_list"`               // Tags in API order, including tags without a value
+}
+
+// GetPublicIPs lists every public IPv4 address in the region with the resource it is attached to
+// Addresses are read from the network interfaces, which hold Elastic IPs and ephemeral addresses of
+// instances, NAT gateways and load balancer nodes alike; Elastic IPs not associated with anything are
+// added from DescribeAddresses. Load balancer addresses are the current ones of their nodes, as the
+// DNS name would resolve them, and change as nodes are replaced unless they are Elastic IPs.
+// The interface pagination is checkpointed after every page when the scanner has a checkpoint store.
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Addresses sorted by IP, or error if an operation fails
+func (s *Scanner) GetPublicIPs(ctx context.Context) ([]PublicIPInfo, error) {
+	addresses, err := s.ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, newScanError("Elastic IP addresses", "DescribeAddresses", err)
+	}
+	eips := make(map[string]types.Address, len(addresses.Addresses))
+	for _, address := range addresses.Addresses {
+		eips[aws.ToString(address.PublicIp)] = address
+	}
+
+	// Addresses from interfaces, continued from the checkpoint of an interrupted run
+	var interfaceIPs []PublicIPInfo
+	token, complete, err := s.checkpoints.Load(checkpointPublicIPs, &interfaceIPs)
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		input := &ec2.DescribeNetworkInterfacesInput{}
+		if token != "" {
+			input.NextToken = aws.String(token)
+		}
+		paginator := ec2.NewDescribeNetworkInterfacesPaginator(s.ec2Client, input)
+		for paginator.HasMorePages() {
+			result, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, newScanError("network interfaces", "DescribeNetworkInterfaces", err)
+			}
+
+			for _, eni := range result.NetworkInterfaces {
+				for _, private := range eni.PrivateIpAddresses {
+					association := private.Association
+					if association == nil || aws.ToString(association.PublicIp) == "" {
+						continue
+					}
+					interfaceIPs = append(interfaceIPs, *newInterfacePublicIP(eni, aws.ToString(private.PrivateIpAddress), association))
+				}
+			}
+			s.checkpoints.Save(checkpointPublicIPs, aws.ToString(result.NextToken), interfaceIPs)
+		}
+	}
+
+	byIP := make(map[string]*PublicIPInfo)
+	instanceIDs := make(map[string]bool)
+	for i := range interfaceIPs {
+		info := &interfaceIPs[i]
+		if eip, ok := eips[info.PublicIp]; ok {
+			info.Kind = PublicIPStatic
+			info.AllocationID = aws.ToString(eip.AllocationId)
+			info.Tags = convertTags(eip.Tags)
+			info.TagList = convertTagList(eip.Tags)
+		}
+		if info.AttachedResourceType == PublicIPResourceInstance {
+			instanceIDs[info.AttachedResourceID] = true
+		}
+		byIP[info.PublicIp] = info
+	}
+
+	// Elastic IPs the interfaces did not show are associated with nothing
+	for ip, eip := range eips {
+		if _, ok := byIP[ip]; ok {
+			continue
+		}
+		byIP[ip] = &PublicIPInfo{
+			PublicIp:             ip,
+			Kind:                 PublicIPStatic,
+			AllocationID:         aws.ToString(eip.AllocationId),
+			AttachedResourceType: PublicIPResourceNone,
+			SecurityGroupIDs:     []string{},
+			Tags:                 convertTags(eip.Tags),
+			TagList:              convertTagList(eip.Tags),
+		}
+	}
+
+	launchTimes, err := s.instanceLaunchTimes(ctx, sortedSet(instanceIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]string, 0, len(byIP))
+	for ip := range byIP {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	publicIPs := []PublicIPInfo{}
+	for _, ip := range ips {
+		info := byIP[ip]
+		if info.AttachedResourceType == PublicIPResourceInstance {
+			info.InstanceLaunchTime = launchTimes[info.AttachedResourceID]
+		}
+		if info.AllocationID != "" {
+			info.Arn = s.arn(arnbuild.TypeElasticIP, "", info.AllocationID)
+		}
+		publicIPs = append(publicIPs, *info)
+	}
+	return publicIPs, nil
+}
+
+// newInterfacePublicIP describes a public address associated with a private address of a network interface
+// Addresses from the Amazon pool are owned by "amazon"; Elastic IPs by the account or BYOIP pool owner.
+func newInterfacePublicIP(eni types.NetworkInterface, privateIP string, association *types.NetworkInterfaceAssociation) *PublicIPInfo {
+	info := &PublicIPInfo{
+		PublicIp:           aws.ToString(association.PublicIp),
+		Kind:               PublicIPStatic,
+		AllocationID:       aws.ToString(association.AllocationId),
+		NetworkInterfaceID: aws.ToString(eni.NetworkInterfaceId),
+		PrivateIp:          privateIP,
+		VpcID:              aws.ToString(eni.VpcId),
+		SubnetID:           aws.ToString(eni.SubnetId),
+		SecurityGroupIDs:   []string{},
+		Tags:               convertTags(eni.TagSet),
+		TagList:            convertTagList(eni.TagSet),
+	}
+	if aws.ToString(association.IpOwnerId) == "amazon" {
+		info.Kind = PublicIPEphemeral
+	}
+	for _, group := range eni.Groups {
+		info.SecurityGroupIDs = append(info.SecurityGroupIDs, aws.ToString(group.GroupId))
+	}
+	info.AttachedResourceType, info.AttachedResourceID = interfaceOwner(eni)
+	return info
+}
+
+// interfaceOwner identifies the resource a network interface belongs to from its type, attachment and description
+// Managed interfaces describe their owner: "Interface for NAT Gateway nat-...", "ELB app/name/id", "ELB name".
+func interfaceOwner(eni types.NetworkInterface) (string, string) {
+	description := aws.ToString(eni.Description)
+	switch {
+	case eni.InterfaceType == natGatewayInterfaceType || eni.InterfaceType == types.NetworkInterfaceTypeNatGateway:
+		fields := strings.Fields(description)
+		if len(fields) > 0 && strings.HasPrefix(fields[len(fields)-1], "nat-") {
+			return PublicIPResourceNatGateway, fields[len(fields)-1]
+		}
+		return PublicIPResourceNatGateway, aws.ToString(eni.NetworkInterfaceId)
+	case strings.HasPrefix(description, "ELB "):
+		return PublicIPResourceLoadBalancer, strings.TrimPrefix(description, "ELB ")
+	case eni.Attachment != nil && aws.ToString(eni.Attachment.InstanceId) != "":
+		return PublicIPResourceInstance, aws.ToString(eni.Attachment.InstanceId)
+	}
+	resourceID := aws.ToString(eni.NetworkInterfaceId)
+	if eni.InterfaceType != "" {
+		resourceID += " (" + string(eni.InterfaceType) + ")"
+	}
+	return PublicIPResourceNetworkInterface, resourceID
+}
+
+// instanceLaunchTimes returns the launch time of each instance in RFC 3339 format
+// Instances are looked up by filter in batches, so instances terminated since the interface scan are skipped.
+func (s *Scanner) instanceLaunchTimes(ctx context.Context, instanceIDs []string) (map[string]string, error) {
+	launchTimes := make(map[string]string, len(instanceIDs))
+	for start := 0; start < len(instanceIDs); start += instanceFilterLimit {
+		end := start + instanceFilterLimit
+		if end > len(instanceIDs) {
+			end = len(instanceIDs)
+		}
+		paginator := ec2.NewDescribeInstancesPaginator(s.ec2Client, &ec2.DescribeInstancesInput{
+			Filters: []types.Filter{{Name: aws.String("instance-id"), Values: instanceIDs[start:end]}},
+		})
+		for paginator.HasMorePages() {
+			result, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, newScanError("instances", "DescribeInstances", err)
+			}
+			for _, reservation := range result.Reservations {
+				for _, instance := range reservation.Instances {
+					if instance.LaunchTime != nil {
+						launchTimes[aws.ToString(instance.InstanceId)] = instance.LaunchTime.UTC().Format(time.RFC3339)
+					}
+				}
+			}
+		}
+	}
+	return launchTimes, nil
+}
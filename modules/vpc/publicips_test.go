@@ -0,0 +1,181 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/checkpoint"
+)
+
+// interfacePages are the DescribeNetworkInterfaces pages of the resume test, one NAT gateway interface each
+var interfacePages = []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"}
+
+// newPagingScanner returns a scanner whose EC2 endpoint pages the interfaces of interfacePages
+// The first request for page failAt fails, as a connection lost mid-pagination would.
+// requests: Counts the DescribeNetworkInterfaces requests
+func newPagingScanner(t *testing.T, failAt int, requests *atomic.Int32) *Scanner {
+	var failed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("Action")
+		w.Header().Set("Content-Type", "text/xml")
+		if action != "DescribeNetworkInterfaces" {
+			fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>req-1</requestId></%sResponse>`, action, action)
+			return
+		}
+		requests.Add(1)
+		page := 0
+		fmt.Sscanf(r.Form.Get("NextToken"), "page-%d", &page)
+		if page == failAt && !failed.Swap(true) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `<Response><Errors><Error><Code>Unavailable</Code><Message>connection lost</Message></Error></Errors><RequestID>req-1</RequestID></Response>`)
+			return
+		}
+		next := ""
+		if page+1 < len(interfacePages) {
+			next = fmt.Sprintf("<nextToken>page-%d</nextToken>", page+1)
+		}
+		fmt.Fprintf(w, `<DescribeNetworkInterfacesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><networkInterfaceSet><item>`+
+			`<networkInterfaceId>eni-%d</networkInterfaceId><interfaceType>nat_gateway</interfaceType><privateIpAddressesSet><item>`+
+			`<privateIpAddress>10.0.0.%d</privateIpAddress><association><publicIp>%s</publicIp><ipOwnerId>amazon</ipOwnerId></association>`+
+			`</item></privateIpAddressesSet></item></networkInterfaceSet>%s<requestId>req-1</requestId></DescribeNetworkInterfacesResponse>`,
+			page, page, interfacePages[page], next)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewScanner(aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+}
+
+// TestGetPublicIPsResumesCheckpoint interrupts the interface pagination of GetPublicIPs at every page and
+// checks that the resumed scan requests only the pages left and lists every address exactly once
+func TestGetPublicIPsResumesCheckpoint(t *testing.T) {
+	fingerprint := checkpoint.Fingerprint("123456789012", "eu-west-1", "")
+	for failAt := range interfacePages {
+		t.Run(fmt.Sprintf("interrupted at page %d", failAt+1), func(t *testing.T) {
+			dir := t.TempDir()
+			var requests atomic.Int32
+			scanner := newPagingScanner(t, failAt, &requests)
+
+			first, err := checkpoint.Open(dir, fingerprint, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scanner.SetCheckpoints(first)
+			if _, err := scanner.GetPublicIPs(context.Background()); err == nil {
+				t.Fatal("the interrupted scan did not fail")
+			}
+
+			requests.Store(0)
+			resumed, err := checkpoint.Open(dir, fingerprint, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scanner.SetCheckpoints(resumed)
+			ips, err := scanner.GetPublicIPs(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := int(requests.Load()); got != len(interfacePages)-failAt {
+				t.Errorf("the resumed scan requested %d pages, want the %d left", got, len(interfacePages)-failAt)
+			}
+			var got []string
+			for _, ip := range ips {
+				got = append(got, ip.PublicIp)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(interfacePages) {
+				t.Errorf("public IPs = %v, want %v once each", got, interfacePages)
+			}
+		})
+	}
+}
+
+// TestGetPublicIPs resolves the attachment of an address of every source: instances with an ephemeral
+// address and with an Elastic IP, a NAT gateway, an Application and a Network Load Balancer, another
+// managed interface, and an Elastic IP attached to nothing
+func TestGetPublicIPs(t *testing.T) {
+	eni := func(id, interfaceType, description, instanceID, group, privateIP, publicIP, owner, allocationID string) string {
+		attachment := ""
+		if instanceID != "" {
+			attachment = "<attachment><instanceId>" + instanceID + "</instanceId></attachment>"
+		}
+		groups := ""
+		if group != "" {
+			groups = "<groupSet><item><groupId>" + group + "</groupId></item></groupSet>"
+		}
+		allocation := ""
+		if allocationID != "" {
+			allocation = "<allocationId>" + allocationID + "</allocationId>"
+		}
+		return "<item><networkInterfaceId>" + id + "</networkInterfaceId><interfaceType>" + interfaceType + "</interfaceType>" +
+			"<description>" + description + "</description><vpcId>vpc-0a1</vpcId><subnetId>subnet-0pub</subnetId>" + attachment + groups +
+			"<privateIpAddressesSet><item><privateIpAddress>" + privateIP + "</privateIpAddress><association><publicIp>" + publicIP +
+			"</publicIp><ipOwnerId>" + owner + "</ipOwnerId>" + allocation + "</association></item>" +
+			"<item><privateIpAddress>10.0.9.9</privateIpAddress></item></privateIpAddressesSet></item>"
+	}
+	scanner := newTestScanner(t, map[string]string{
+		"DescribeAddresses": `<addressesSet>
+			<item><publicIp>198.51.100.10</publicIp><allocationId>eipalloc-0web</allocationId>
+				<tagSet><item><key>Name</key><value>web-eip</value></item></tagSet></item>
+			<item><publicIp>198.51.100.20</publicIp><allocationId>eipalloc-0nlb</allocationId></item>
+			<item><publicIp>198.51.100.30</publicIp><allocationId>eipalloc-0spare</allocationId></item>
+			</addressesSet>`,
+		"DescribeNetworkInterfaces": "<networkInterfaceSet>" +
+			eni("eni-0bastion", "interface", "", "i-0bastion", "sg-0ssh", "10.0.0.5", "203.0.113.5", "amazon", "") +
+			eni("eni-0web", "interface", "", "i-0web", "sg-0web", "10.0.0.10", "198.51.100.10", "111122223333", "eipalloc-0web") +
+			eni("eni-0nat", "nat_gateway", "Interface for NAT Gateway nat-0a1", "", "", "10.0.0.20", "203.0.113.20", "amazon", "") +
+			eni("eni-0alb", "interface", "ELB app/web/50dc6c495c0c9188", "", "sg-0alb", "10.0.0.30", "203.0.113.30", "amazon", "") +
+			eni("eni-0nlb", "network_load_balancer", "ELB net/ingress/73e2d6bc24d8a067", "", "", "10.0.0.40", "198.51.100.20", "111122223333", "eipalloc-0nlb") +
+			eni("eni-0vpn", "interface", "Client VPN endpoint", "", "sg-0vpn", "10.0.0.50", "203.0.113.50", "amazon", "") +
+			"</networkInterfaceSet>",
+		"DescribeInstances": `<reservationSet><item><instancesSet>
+			<item><instanceId>i-0bastion</instanceId><launchTime>2026-01-05T08:00:00.000Z</launchTime></item>
+			<item><instanceId>i-0web</instanceId><launchTime>2026-10-01T12:00:00.000Z</launchTime></item>
+			</instancesSet></item></reservationSet>`,
+	}, 0)
+	scanner.SetAccountID("111122223333")
+
+	got, err := scanner.GetPublicIPs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PublicIPInfo{
+		{PublicIp: "198.51.100.10", Kind: PublicIPStatic, AllocationID: "eipalloc-0web", Arn: "arn:aws:ec2:eu-west-1:111122223333:elastic-ip/eipalloc-0web",
+			AttachedResourceType: PublicIPResourceInstance, AttachedResourceID: "i-0web", NetworkInterfaceID: "eni-0web", PrivateIp: "10.0.0.10",
+			VpcID: "vpc-0a1", SubnetID: "subnet-0pub", SecurityGroupIDs: []string{"sg-0web"}, InstanceLaunchTime: "2026-10-01T12:00:00Z",
+			Tags: map[string]string{"Name": "web-eip"}, TagList: []Tag{{Key: "Name", Value: "web-eip"}}},
+		{PublicIp: "198.51.100.20", Kind: PublicIPStatic, AllocationID: "eipalloc-0nlb", Arn: "arn:aws:ec2:eu-west-1:111122223333:elastic-ip/eipalloc-0nlb",
+			AttachedResourceType: PublicIPResourceLoadBalancer, AttachedResourceID: "net/ingress/73e2d6bc24d8a067", NetworkInterfaceID: "eni-0nlb",
+			PrivateIp: "10.0.0.40", VpcID: "vpc-0a1", SubnetID: "subnet-0pub", SecurityGroupIDs: []string{}, Tags: map[string]string{}, TagList: []Tag{}},
+		{PublicIp: "198.51.100.30", Kind: PublicIPStatic, AllocationID: "eipalloc-0spare", Arn: "arn:aws:ec2:eu-west-1:111122223333:elastic-ip/eipalloc-0spare",
+			AttachedResourceType: PublicIPResourceNone, SecurityGroupIDs: []string{}, Tags: map[string]string{}, TagList: []Tag{}},
+		{PublicIp: "203.0.113.20", Kind: PublicIPEphemeral, AttachedResourceType: PublicIPResourceNatGateway, AttachedResourceID: "nat-0a1",
+			NetworkInterfaceID: "eni-0nat", PrivateIp: "10.0.0.20", VpcID: "vpc-0a1", SubnetID: "subnet-0pub", SecurityGroupIDs: []string{},
+			Tags: map[string]string{}, TagList: []Tag{}},
+		{PublicIp: "203.0.113.30", Kind: PublicIPEphemeral, AttachedResourceType: PublicIPResourceLoadBalancer, AttachedResourceID: "app/web/50dc6c495c0c9188",
+			NetworkInterfaceID: "eni-0alb", PrivateIp: "10.0.0.30", VpcID: "vpc-0a1", SubnetID: "subnet-0pub", SecurityGroupIDs: []string{"sg-0alb"},
+			Tags: map[string]string{}, TagList: []Tag{}},
+		{PublicIp: "203.0.113.5", Kind: PublicIPEphemeral, AttachedResourceType: PublicIPResourceInstance, AttachedResourceID: "i-0bastion",
+			NetworkInterfaceID: "eni-0bastion", PrivateIp: "10.0.0.5", VpcID: "vpc-0a1", SubnetID: "subnet-0pub", SecurityGroupIDs: []string{"sg-0ssh"},
+			InstanceLaunchTime: "2026-01-05T08:00:00Z", Tags: map[string]string{}, TagList: []Tag{}},
+		{PublicIp: "203.0.113.50", Kind: PublicIPEphemeral, AttachedResourceType: PublicIPResourceNetworkInterface, AttachedResourceID: "eni-0vpn (interface)",
+			NetworkInterfaceID: "eni-0vpn", PrivateIp: "10.0.0.50", VpcID: "vpc-0a1", SubnetID: "subnet-0pub", SecurityGroupIDs: []string{"sg-0vpn"},
+			Tags: map[string]string{}, TagList: []Tag{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPublicIPs() =\n%+v\nwant\n%+v", got, want)
+	}
+}
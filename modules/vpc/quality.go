@@ -0,0 +1,118 @@
+package vpc
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Data-quality problems reported in DataWarning.Problem
+const (
+	ProblemMissing      = "missing"      // A field every resource of the type has is absent or empty
+	ProblemUnrecognized = "unrecognized" // An enum field has a value this tool does not know
+	ProblemInconsistent = "inconsistent" // A field references a resource the scan did not return, even on a re-check
+)
+
+// UnknownValue is what the exporters show in place of a field with a data-quality warning
+const UnknownValue = "(unknown)"
+
+// DataWarning describes a field of an API response that was missing or had an unexpected value
+// The scan keeps going and stores the zero value (or the raw value for unrecognized enums), so
+// the warning is the only trace of the problem; reports list the warnings next to the resources.
+type DataWarning struct {
+	ResourceType string `json:"resource_type"` // Type of the resource (VPC, subnet, route, security group, ...)
+	ResourceID   string `json:"resource_id"`   // ID of the resource ("rtb-1 10.0.0.0/16" for routes, "(unknown)" if the ID itself is missing)
+	Field        string `json:"field"`         // API field name (CidrBlock, State, Description, ...)
+	RawValue     string `json:"raw_value"`     // Value as returned by the API (empty if missing; the referenced ID if inconsistent)
+	Problem      string `json:"problem"`       // missing, unrecognized or inconsistent
+}
+
+// String formats the warning for the log
+func (w DataWarning) String() string {
+	switch w.Problem {
+	case ProblemUnrecognized:
+		return fmt.Sprintf("%s %s: unrecognized %s %q", w.ResourceType, w.ResourceID, w.Field, w.RawValue)
+	case ProblemInconsistent:
+		return fmt.Sprintf("%s %s: %s %s was not returned by the scan", w.ResourceType, w.ResourceID, w.Field, w.RawValue)
+	}
+	return fmt.Sprintf("%s %s: %s is missing", w.ResourceType, w.ResourceID, w.Field)
+}
+
+// OrUnknown returns the value of a field, or UnknownValue if it is empty
+// Used by the exporters for fields that can carry a data-quality warning, so they show a
+// placeholder instead of an empty cell.
+func OrUnknown(value string) string {
+	if value == "" {
+		return UnknownValue
+	}
+	return value
+}
+
+// dataQuality collects the data-quality warnings of a scanner; safe for concurrent scans
+type dataQuality struct {
+	mu       sync.Mutex
+	warnings []DataWarning
+	seen     map[DataWarning]bool // Warnings already recorded, so a resource fetched twice is reported once
+}
+
+// DataWarnings returns the data-quality warnings recorded by the scans so far
+// Returns: Warnings in the order they were found (empty if the responses were complete)
+func (s *Scanner) DataWarnings() []DataWarning {
+	s.quality.mu.Lock()
+	defer s.quality.mu.Unlock()
+	return append([]DataWarning{}, s.quality.warnings...)
+}
+
+// warn records a data-quality warning
+func (s *Scanner) warn(resourceType, resourceID, field, rawValue, problem string) {
+	warning := DataWarning{
+		ResourceType: resourceType,
+		ResourceID:   OrUnknown(resourceID),
+		Field:        field,
+		RawValue:     rawValue,
+		Problem:      problem,
+	}
+	s.quality.mu.Lock()
+	defer s.quality.mu.Unlock()
+	if s.quality.seen[warning] {
+		return
+	}
+	if s.quality.seen == nil {
+		s.quality.seen = make(map[DataWarning]bool)
+	}
+	s.quality.seen[warning] = true
+	s.quality.warnings = append(s.quality.warnings, warning)
+}
+
+// required returns a string field, recording a warning if it is nil or empty
+// resourceType, resourceID: Resource the field belongs to
+// field: API field name
+// value: Field of the SDK response
+func (s *Scanner) required(resourceType, resourceID, field string, value *string) string {
+	if value == nil || *value == "" {
+		s.warn(resourceType, resourceID, field, "", ProblemMissing)
+		return ""
+	}
+	return *value
+}
+
+// enumValue returns an enum field as a string, recording a warning if it is empty or not one of
+// the values the SDK knows; unrecognized values are kept, so newer API values still show up
+// resourceType, resourceID: Resource the field belongs to
+// field: API field name
+// value: Field of the SDK response
+func enumValue[T interface {
+	~string
+	Values() []T
+}](s *Scanner, resourceType, resourceID, field string, value T) string {
+	if value == "" {
+		s.warn(resourceType, resourceID, field, "", ProblemMissing)
+		return ""
+	}
+	for _, known := range value.Values() {
+		if value == known {
+			return string(value)
+		}
+	}
+	s.warn(resourceType, resourceID, field, string(value), ProblemUnrecognized)
+	return string(value)
+}
@@ -0,0 +1,117 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// degenerateResponses are API results with the fields a third-party tool left out or set to values the SDK
+// does not know
+var degenerateResponses = map[string]string{
+	"DescribeVpcs": `<vpcSet>
+		<item><vpcId>vpc-0a1</vpcId><state>melting</state><instanceTenancy>default</instanceTenancy></item>
+		<item><cidrBlock>10.1.0.0/16</cidrBlock><state>available</state><instanceTenancy>default</instanceTenancy></item></vpcSet>`,
+	"DescribeRouteTables": `<routeTableSet><item><routeTableId>rtb-0a1</routeTableId><vpcId>vpc-0a1</vpcId><routeSet>
+		<item><gatewayId>igw-0a1</gatewayId><state>active</state><origin>CreateRoute</origin></item>
+		<item><destinationCidrBlock>10.9.0.0/16</destinationCidrBlock><state>active</state><origin>CreateRoute</origin></item>
+		<item><destinationCidrBlock>10.8.0.0/16</destinationCidrBlock><gatewayId>local</gatewayId><state>flapping</state><origin>Terraform</origin></item>
+		</routeSet></item></routeTableSet>`,
+	"DescribeSecurityGroups": `<securityGroupInfo><item><groupId>sg-0third</groupId><groupName>third-party</groupName><vpcId>vpc-0a1</vpcId>
+		<ipPermissions><item><fromPort>443</fromPort><toPort>443</toPort><ipRanges><item><cidrIp>10.0.0.0/8</cidrIp></item></ipRanges></item></ipPermissions>
+		</item></securityGroupInfo>`,
+	"DescribeNatGateways": `<natGatewaySet><item><natGatewayId>nat-0a1</natGatewayId><vpcId>vpc-0a1</vpcId><state>available</state>
+		<connectivityType>satellite</connectivityType></item></natGatewaySet>`,
+}
+
+// TestDataWarnings feeds degenerate responses through the scanner and checks that the fields keep their
+// empty or raw values and that each problem is recorded once, even when a resource is fetched twice
+func TestDataWarnings(t *testing.T) {
+	scanner := newTestScanner(t, degenerateResponses, 0)
+	ctx := context.Background()
+
+	vpcs, err := scanner.GetVPCs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanner.GetVPCs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	routeTables, err := scanner.GetRouteTables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := scanner.GetSecurityGroups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	natGateways, err := scanner.GetNatGateways(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vpcs[0].CidrBlock != "" || vpcs[0].State != "melting" || vpcs[1].VpcID != "" {
+		t.Errorf("VPCs = %+v, want the empty block, the raw state and the empty ID kept", vpcs)
+	}
+	if routes := routeTables[0].Routes; len(routes) != 3 || routes[1].Target.Type != RouteTargetUnknown || routes[2].State != "flapping" {
+		t.Errorf("routes = %+v, want all three routes, the second without a target", routes)
+	}
+	if groups[0].Description != "" || len(groups[0].Rules) != 1 || groups[0].Rules[0].IpProtocol != "" {
+		t.Errorf("security group = %+v, want the empty description and the rule without a protocol", groups[0])
+	}
+	if natGateways[0].ConnectivityType != "satellite" {
+		t.Errorf("connectivity type = %q, want the raw value", natGateways[0].ConnectivityType)
+	}
+
+	want := []DataWarning{
+		{ResourceType: "VPC", ResourceID: "vpc-0a1", Field: "CidrBlock", Problem: ProblemMissing},
+		{ResourceType: "VPC", ResourceID: "vpc-0a1", Field: "State", RawValue: "melting", Problem: ProblemUnrecognized},
+		{ResourceType: "VPC", ResourceID: UnknownValue, Field: "VpcId", Problem: ProblemMissing},
+		{ResourceType: "route", ResourceID: "rtb-0a1 unknown destination", Field: "DestinationCidrBlock", Problem: ProblemMissing},
+		{ResourceType: "route", ResourceID: "rtb-0a1 10.9.0.0/16", Field: "Target", Problem: ProblemMissing},
+		{ResourceType: "route", ResourceID: "rtb-0a1 10.8.0.0/16", Field: "State", RawValue: "flapping", Problem: ProblemUnrecognized},
+		{ResourceType: "route", ResourceID: "rtb-0a1 10.8.0.0/16", Field: "Origin", RawValue: "Terraform", Problem: ProblemUnrecognized},
+		{ResourceType: "security group", ResourceID: "sg-0third", Field: "Description", Problem: ProblemMissing},
+		{ResourceType: "security group rule", ResourceID: "sg-0third", Field: "IpProtocol", Problem: ProblemMissing},
+		{ResourceType: "NAT gateway", ResourceID: "nat-0a1", Field: "SubnetId", Problem: ProblemMissing},
+		{ResourceType: "NAT gateway", ResourceID: "nat-0a1", Field: "ConnectivityType", RawValue: "satellite", Problem: ProblemUnrecognized},
+	}
+	if got := scanner.DataWarnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DataWarnings() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// TestDataWarningsCompleteResponse checks that complete responses record no warnings
+func TestDataWarningsCompleteResponse(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeSecurityGroups": defaultEgressGroups}, 0)
+	if _, err := scanner.GetSecurityGroups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanner.DataWarnings(); got == nil || len(got) != 0 {
+		t.Errorf("DataWarnings() = %#v, want an empty list", got)
+	}
+}
+
+// TestDataWarningString checks the log line of each problem
+func TestDataWarningString(t *testing.T) {
+	tests := []struct {
+		warning DataWarning
+		want    string
+	}{
+		{DataWarning{ResourceType: "VPC", ResourceID: "vpc-0a1", Field: "CidrBlock", Problem: ProblemMissing}, "VPC vpc-0a1: CidrBlock is missing"},
+		{DataWarning{ResourceType: "route", ResourceID: "rtb-0a1 10.8.0.0/16", Field: "State", RawValue: "flapping", Problem: ProblemUnrecognized},
+			`route rtb-0a1 10.8.0.0/16: unrecognized State "flapping"`},
+		{DataWarning{ResourceType: "subnet", ResourceID: "subnet-0a1", Field: "VpcId", RawValue: "vpc-0gone", Problem: ProblemInconsistent},
+			"subnet subnet-0a1: VpcId vpc-0gone was not returned by the scan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.warning.Problem, func(t *testing.T) {
+			if got := tt.warning.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if OrUnknown("") != UnknownValue || OrUnknown("10.0.0.0/16") != "10.0.0.0/16" {
+		t.Errorf("OrUnknown() = %q and %q, want the placeholder for empty values only", OrUnknown(""), OrUnknown("10.0.0.0/16"))
+	}
+}
@@ -0,0 +1,30 @@
+package vpc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// GetEnabledRegions lists the regions the account can use: the regions enabled by default and the opt-in
+// regions it opted in to
+// ctx: Context for the request, allowing for timeout and cancellation
+// Returns: Region names sorted by name, or error if the operation fails
+func (s *Scanner) GetEnabledRegions(ctx context.Context) ([]string, error) {
+	// Without AllRegions, DescribeRegions leaves out the opt-in regions that are not enabled
+	result, err := s.ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, newScanError("regions", "DescribeRegions", err)
+	}
+
+	regions := make([]string, 0, len(result.Regions))
+	for _, region := range result.Regions {
+		if name := aws.ToString(region.RegionName); name != "" {
+			regions = append(regions, name)
+		}
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
@@ -0,0 +1,226 @@
+package vpc
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Route target types reported in RouteTarget.Type
+const (
+	RouteTargetLocal                     = "local"                        // Traffic stays within the VPC
+	RouteTargetInternetGateway           = "internet-gateway"             // igw-*
+	RouteTargetVpnGateway                = "vpn-gateway"                  // vgw-*
+	RouteTargetVpcEndpoint               = "vpc-endpoint"                 // vpce-* (gateway endpoints and Gateway Load Balancer endpoints)
+	RouteTargetGateway                   = "gateway"                      // Any other value of GatewayId
+	RouteTargetEgressOnlyInternetGateway = "egress-only-internet-gateway" // eigw-*
+	RouteTargetNatGateway                = "nat-gateway"                  // nat-*
+	RouteTargetTransitGateway            = "transit-gateway"              // tgw-*
+	RouteTargetVpcPeeringConnection      = "vpc-peering-connection"       // pcx-*
+	RouteTargetCarrierGateway            = "carrier-gateway"              // cagw-* (Wavelength zones)
+	RouteTargetLocalGateway              = "local-gateway"                // lgw-* (Outposts)
+	RouteTargetCoreNetwork               = "core-network"                 // AWS Cloud WAN core network ARN
+	RouteTargetNetworkInterface          = "network-interface"            // eni-*
+	RouteTargetInstance                  = "instance"                     // i-* (NAT instances and appliances)
+	RouteTargetUnknown                   = "unknown_target"               // A target field this tool does not map yet
+)
+
+// Local route types reported in RouteInfo.RouteType
+// Every CIDR block associated with a VPC gets its own local route, so multi-CIDR VPCs have several
+const (
+	RouteTypeLocalPrimaryCIDR      = "local-primary-cidr"      // Local route for the VPC's primary IPv4 CIDR block
+	RouteTypeLocalSecondaryCIDR    = "local-secondary-cidr"    // Local route for a secondary IPv4 CIDR block
+	RouteTypeLocalIPv6CIDR         = "local-ipv6-cidr"         // Local route for an IPv6 CIDR block
+	RouteTypeLocalUnassociatedCIDR = "local-unassociated-cidr" // Local route matching no CIDR block currently associated with the VPC
+)
+
+// RouteTarget identifies where a route sends traffic, independent of which SDK field held the target
+type RouteTarget struct {
+	Type  string `json:"type"`            // One of the RouteTarget* constants
+	ID    string `json:"id"`              // ID or ARN of the target
+	Field string `json:"field,omitempty"` // SDK field the target was read from (set for unknown targets only)
+}
+
+// String returns the target for display in diagrams and reports
+func (t RouteTarget) String() string {
+	switch {
+	case t.Type == RouteTargetUnknown && t.ID != "":
+		return fmt.Sprintf("%s (unknown target)", t.ID)
+	case t.ID == "":
+		return "unknown target"
+	}
+	return t.ID
+}
+
+// routeTargetFields lists the target fields of types.Route in priority order
+// A route normally has exactly one of these set; the first non-empty one wins
+var routeTargetFields = []struct {
+	field      string
+	targetType string
+	get        func(route types.Route) *string
+}{
+	{"GatewayId", RouteTargetGateway, func(r types.Route) *string { return r.GatewayId }},
+	{"NatGatewayId", RouteTargetNatGateway, func(r types.Route) *string { return r.NatGatewayId }},
+	{"TransitGatewayId", RouteTargetTransitGateway, func(r types.Route) *string { return r.TransitGatewayId }},
+	{"VpcPeeringConnectionId", RouteTargetVpcPeeringConnection, func(r types.Route) *string { return r.VpcPeeringConnectionId }},
+	{"EgressOnlyInternetGatewayId", RouteTargetEgressOnlyInternetGateway, func(r types.Route) *string { return r.EgressOnlyInternetGatewayId }},
+	{"CoreNetworkArn", RouteTargetCoreNetwork, func(r types.Route) *string { return r.CoreNetworkArn }},
+	{"CarrierGatewayId", RouteTargetCarrierGateway, func(r types.Route) *string { return r.CarrierGatewayId }},
+	{"LocalGatewayId", RouteTargetLocalGateway, func(r types.Route) *string { return r.LocalGatewayId }},
+	{"NetworkInterfaceId", RouteTargetNetworkInterface, func(r types.Route) *string { return r.NetworkInterfaceId }},
+	{"InstanceId", RouteTargetInstance, func(r types.Route) *string { return r.InstanceId }},
+}
+
+// routeNonTargetFields lists the string fields of types.Route that describe the route rather than its target
+var routeNonTargetFields = map[string]bool{
+	"DestinationCidrBlock":     true,
+	"DestinationIpv6CidrBlock": true,
+	"DestinationPrefixListId":  true,
+	"InstanceOwnerId":          true,
+}
+
+// gatewayTypes maps GatewayId prefixes to more specific target types
+var gatewayTypes = map[string]string{
+	"igw-":  RouteTargetInternetGateway,
+	"vgw-":  RouteTargetVpnGateway,
+	"vpce-": RouteTargetVpcEndpoint,
+}
+
+// resolveRouteTarget determines the target of a route
+// Known target fields are checked in priority order; any other populated string field is
+// captured as an unknown target so new AWS target types are surfaced rather than dropped; the
+// scanner records a data-quality warning for both unknown targets and routes without one
+// route: Route as returned by DescribeRouteTables
+// Returns: The route's target, with Type RouteTargetUnknown if no known field is set
+func resolveRouteTarget(route types.Route) RouteTarget {
+	known := make(map[string]bool, len(routeTargetFields))
+	for _, candidate := range routeTargetFields {
+		known[candidate.field] = true
+		id := aws.ToString(candidate.get(route))
+		if id == "" {
+			continue
+		}
+		if candidate.field == "GatewayId" {
+			return RouteTarget{Type: gatewayType(id), ID: id}
+		}
+		return RouteTarget{Type: candidate.targetType, ID: id}
+	}
+
+	// Fall back to any populated *string field we do not know about
+	value := reflect.ValueOf(route)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() || known[field.Name] || routeNonTargetFields[field.Name] {
+			continue
+		}
+		ptr, ok := value.Field(i).Interface().(*string)
+		if !ok || aws.ToString(ptr) == "" {
+			continue
+		}
+		return RouteTarget{Type: RouteTargetUnknown, ID: *ptr, Field: field.Name}
+	}
+
+	return RouteTarget{Type: RouteTargetUnknown}
+}
+
+// gatewayType classifies a GatewayId value by its prefix
+func gatewayType(id string) string {
+	if id == "local" {
+		return RouteTargetLocal
+	}
+	for prefix, targetType := range gatewayTypes {
+		if strings.HasPrefix(id, prefix) {
+			return targetType
+		}
+	}
+	return RouteTargetGateway
+}
+
+// routeDestination returns the destination of a route for data-quality warnings
+func routeDestination(route types.Route) string {
+	for _, dest := range []*string{route.DestinationCidrBlock, route.DestinationIpv6CidrBlock, route.DestinationPrefixListId} {
+		if aws.ToString(dest) != "" {
+			return *dest
+		}
+	}
+	return "unknown destination"
+}
+
+// ClassifyLocalRoutes sets the RouteType of every local route from the CIDR blocks of its VPC
+// Route tables of VPCs missing from vpcs are left unchanged.
+// vpcs: VPCs from GetVPCs
+// routeTables: Route tables from GetRouteTables, updated in place
+func ClassifyLocalRoutes(vpcs []VPCInfo, routeTables []RouteTableInfo) {
+	byID := make(map[string]VPCInfo, len(vpcs))
+	for _, v := range vpcs {
+		byID[v.VpcID] = v
+	}
+
+	for i := range routeTables {
+		v, ok := byID[routeTables[i].VpcID]
+		if !ok {
+			continue
+		}
+		for j := range routeTables[i].Routes {
+			route := &routeTables[i].Routes[j]
+			if route.Target.Type == RouteTargetLocal {
+				route.RouteType = LocalRouteType(v, *route)
+			}
+		}
+	}
+}
+
+// LocalRouteType classifies a local route by the VPC CIDR block it covers
+// Destinations are compared as networks, so differently written IPv6 blocks still match.
+// v: VPC of the route table
+// route: A route whose target is local
+// Returns: One of the RouteTypeLocal* constants
+func LocalRouteType(v VPCInfo, route RouteInfo) string {
+	if route.DestinationIpv6Block != "" {
+		for _, cidr := range v.Ipv6CidrBlocks {
+			if sameNetwork(cidr, route.DestinationIpv6Block) {
+				return RouteTypeLocalIPv6CIDR
+			}
+		}
+		return RouteTypeLocalUnassociatedCIDR
+	}
+
+	if sameNetwork(v.CidrBlock, route.DestinationCidrBlock) {
+		return RouteTypeLocalPrimaryCIDR
+	}
+	for _, cidr := range v.AssociateCidrBlocks {
+		if sameNetwork(cidr, route.DestinationCidrBlock) {
+			return RouteTypeLocalSecondaryCIDR
+		}
+	}
+	return RouteTypeLocalUnassociatedCIDR
+}
+
+// LocalRouteLabel describes the VPC CIDR block a local route covers, for diagrams and reports
+func LocalRouteLabel(routeType string) string {
+	switch routeType {
+	case RouteTypeLocalPrimaryCIDR:
+		return "primary CIDR"
+	case RouteTypeLocalSecondaryCIDR:
+		return "secondary CIDR"
+	case RouteTypeLocalIPv6CIDR:
+		return "IPv6 CIDR"
+	case RouteTypeLocalUnassociatedCIDR:
+		return "no associated CIDR, stale"
+	}
+	return "not classified"
+}
+
+// sameNetwork reports whether two CIDR strings describe the same network
+func sameNetwork(a, b string) bool {
+	_, netA, errA := net.ParseCIDR(a)
+	_, netB, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return netA.String() == netB.String()
+}
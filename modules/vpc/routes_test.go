@@ -0,0 +1,198 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// TestCarrierGatewayRoute checks that a route to a carrier gateway keeps its ID in carrier_gateway_id and its target
+func TestCarrierGatewayRoute(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{
+		"DescribeRouteTables": `<routeTableSet><item><routeTableId>rtb-1</routeTableId><vpcId>vpc-1</vpcId><routeSet>` +
+			`<item><destinationCidrBlock>0.0.0.0/0</destinationCidrBlock><carrierGatewayId>cagw-1</carrierGatewayId><state>active</state><origin>CreateRoute</origin></item>` +
+			`</routeSet></item></routeTableSet>`,
+	}, 0)
+
+	tables, err := scanner.GetRouteTables(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || len(tables[0].Routes) != 1 {
+		t.Fatalf("got %+v, want one route table with one route", tables)
+	}
+	route := tables[0].Routes[0]
+	if route.CarrierGatewayID != "cagw-1" {
+		t.Errorf("CarrierGatewayID = %q, want cagw-1", route.CarrierGatewayID)
+	}
+	if want := (RouteTarget{Type: RouteTargetCarrierGateway, ID: "cagw-1"}); route.Target != want {
+		t.Errorf("Target = %+v, want %+v", route.Target, want)
+	}
+}
+
+// TestResolveRouteTarget checks the target of a route with each target field set, including one with only CoreNetworkArn
+func TestResolveRouteTarget(t *testing.T) {
+	tests := []struct {
+		name  string
+		route types.Route
+		want  RouteTarget
+	}{
+		{name: "core network only", route: types.Route{CoreNetworkArn: aws.String("arn:aws:networkmanager::111122223333:core-network/core-network-0a1")},
+			want: RouteTarget{Type: RouteTargetCoreNetwork, ID: "arn:aws:networkmanager::111122223333:core-network/core-network-0a1"}},
+		{name: "local", route: types.Route{GatewayId: aws.String("local")}, want: RouteTarget{Type: RouteTargetLocal, ID: "local"}},
+		{name: "internet gateway", route: types.Route{GatewayId: aws.String("igw-0a1")}, want: RouteTarget{Type: RouteTargetInternetGateway, ID: "igw-0a1"}},
+		{name: "virtual private gateway", route: types.Route{GatewayId: aws.String("vgw-0a1")}, want: RouteTarget{Type: RouteTargetVpnGateway, ID: "vgw-0a1"}},
+		{name: "gateway endpoint", route: types.Route{GatewayId: aws.String("vpce-0a1")}, want: RouteTarget{Type: RouteTargetVpcEndpoint, ID: "vpce-0a1"}},
+		{name: "other gateway", route: types.Route{GatewayId: aws.String("xgw-0a1")}, want: RouteTarget{Type: RouteTargetGateway, ID: "xgw-0a1"}},
+		{name: "NAT gateway", route: types.Route{NatGatewayId: aws.String("nat-0a1")}, want: RouteTarget{Type: RouteTargetNatGateway, ID: "nat-0a1"}},
+		{name: "transit gateway", route: types.Route{TransitGatewayId: aws.String("tgw-0a1")}, want: RouteTarget{Type: RouteTargetTransitGateway, ID: "tgw-0a1"}},
+		{name: "peering", route: types.Route{VpcPeeringConnectionId: aws.String("pcx-0a1")}, want: RouteTarget{Type: RouteTargetVpcPeeringConnection, ID: "pcx-0a1"}},
+		{name: "egress-only internet gateway", route: types.Route{EgressOnlyInternetGatewayId: aws.String("eigw-0a1")},
+			want: RouteTarget{Type: RouteTargetEgressOnlyInternetGateway, ID: "eigw-0a1"}},
+		{name: "carrier gateway", route: types.Route{CarrierGatewayId: aws.String("cagw-0a1")}, want: RouteTarget{Type: RouteTargetCarrierGateway, ID: "cagw-0a1"}},
+		{name: "local gateway", route: types.Route{LocalGatewayId: aws.String("lgw-0a1")}, want: RouteTarget{Type: RouteTargetLocalGateway, ID: "lgw-0a1"}},
+		{name: "network interface", route: types.Route{NetworkInterfaceId: aws.String("eni-0a1")}, want: RouteTarget{Type: RouteTargetNetworkInterface, ID: "eni-0a1"}},
+		{name: "instance", route: types.Route{InstanceId: aws.String("i-0a1"), InstanceOwnerId: aws.String("111122223333")},
+			want: RouteTarget{Type: RouteTargetInstance, ID: "i-0a1"}},
+		// A NAT instance route reports both the interface and the instance; the interface is more specific
+		{name: "instance and its interface", route: types.Route{InstanceId: aws.String("i-0a1"), NetworkInterfaceId: aws.String("eni-0a1")},
+			want: RouteTarget{Type: RouteTargetNetworkInterface, ID: "eni-0a1"}},
+		{name: "empty strings", route: types.Route{GatewayId: aws.String(""), DestinationCidrBlock: aws.String("10.0.0.0/8")},
+			want: RouteTarget{Type: RouteTargetUnknown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveRouteTarget(tt.route); got != tt.want {
+				t.Errorf("resolveRouteTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResolveRouteTargetCoversEveryField checks that no string field of the SDK's Route is dropped: each one is
+// either a described target field, a field of the destination, or captured as an unknown target
+func TestResolveRouteTargetCoversEveryField(t *testing.T) {
+	routeType := reflect.TypeOf(types.Route{})
+	stringType := reflect.TypeOf((*string)(nil))
+	for i := 0; i < routeType.NumField(); i++ {
+		field := routeType.Field(i)
+		if !field.IsExported() || field.Type != stringType || routeNonTargetFields[field.Name] {
+			continue
+		}
+		t.Run(field.Name, func(t *testing.T) {
+			route := types.Route{}
+			reflect.ValueOf(&route).Elem().Field(i).Set(reflect.ValueOf(aws.String("target-0a1")))
+			got := resolveRouteTarget(route)
+			if got.ID != "target-0a1" {
+				t.Errorf("route with only %s set has target %+v, want the value surfaced", field.Name, got)
+			}
+			if got.Type == RouteTargetUnknown && got.Field != field.Name {
+				t.Errorf("unknown target of %s records field %q", field.Name, got.Field)
+			}
+		})
+	}
+}
+
+// TestCoreNetworkRoute checks that a scanned route with only a core network ARN keeps it as its target without a warning
+func TestCoreNetworkRoute(t *testing.T) {
+	const arn = "arn:aws:networkmanager::111122223333:core-network/core-network-0a1"
+	scanner := newTestScanner(t, map[string]string{
+		"DescribeRouteTables": `<routeTableSet><item><routeTableId>rtb-1</routeTableId><vpcId>vpc-1</vpcId><routeSet>` +
+			`<item><destinationCidrBlock>10.0.0.0/8</destinationCidrBlock><coreNetworkArn>` + arn + `</coreNetworkArn><state>active</state><origin>CreateRoute</origin></item>` +
+			`<item><destinationCidrBlock>192.168.0.0/16</destinationCidrBlock><state>blackhole</state><origin>CreateRoute</origin></item>` +
+			`</routeSet></item></routeTableSet>`,
+	}, 0)
+
+	tables, err := scanner.GetRouteTables(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || len(tables[0].Routes) != 2 {
+		t.Fatalf("got %+v, want one route table with two routes", tables)
+	}
+	route := tables[0].Routes[0]
+	if route.CoreNetworkArn != arn {
+		t.Errorf("CoreNetworkArn = %q, want %s", route.CoreNetworkArn, arn)
+	}
+	if want := (RouteTarget{Type: RouteTargetCoreNetwork, ID: arn}); route.Target != want {
+		t.Errorf("Target = %+v, want %+v", route.Target, want)
+	}
+	if route.Target.String() != arn {
+		t.Errorf("Target.String() = %q, want the ARN", route.Target.String())
+	}
+
+	// Only the route without a target is reported
+	want := []DataWarning{{ResourceType: "route", ResourceID: "rtb-1 192.168.0.0/16", Field: "Target", Problem: ProblemMissing}}
+	if got := scanner.DataWarnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DataWarnings() = %+v, want %+v", got, want)
+	}
+}
+
+// TestRouteTargetString checks how targets are shown in diagrams and reports
+func TestRouteTargetString(t *testing.T) {
+	tests := map[RouteTarget]string{
+		{Type: RouteTargetNatGateway, ID: "nat-0a1"}:                      "nat-0a1",
+		{Type: RouteTargetUnknown, ID: "odb-0a1", Field: "OdbNetworkArn"}: "odb-0a1 (unknown target)",
+		{Type: RouteTargetUnknown}:                                        "unknown target",
+	}
+	for target, want := range tests {
+		if got := target.String(); got != want {
+			t.Errorf("%+v.String() = %q, want %q", target, got, want)
+		}
+	}
+}
+
+// TestLocalRouteType covers local routes of single-CIDR, multi-CIDR and IPv6 VPCs and a route left after a disassociation
+func TestLocalRouteType(t *testing.T) {
+	single := VPCInfo{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", AssociateCidrBlocks: []string{"10.0.0.0/16"}}
+	multi := VPCInfo{VpcID: "vpc-0b2", CidrBlock: "10.1.0.0/16", AssociateCidrBlocks: []string{"10.1.0.0/16", "100.64.0.0/16"},
+		Ipv6CidrBlocks: []string{"2001:db8:1234:1a00::/56"}}
+	tests := []struct {
+		name  string
+		vpc   VPCInfo
+		route RouteInfo
+		want  string
+	}{
+		{name: "single CIDR", vpc: single, route: RouteInfo{DestinationCidrBlock: "10.0.0.0/16"}, want: RouteTypeLocalPrimaryCIDR},
+		{name: "primary of multi-CIDR", vpc: multi, route: RouteInfo{DestinationCidrBlock: "10.1.0.0/16"}, want: RouteTypeLocalPrimaryCIDR},
+		{name: "secondary", vpc: multi, route: RouteInfo{DestinationCidrBlock: "100.64.0.0/16"}, want: RouteTypeLocalSecondaryCIDR},
+		{name: "IPv6 written differently", vpc: multi, route: RouteInfo{DestinationIpv6Block: "2001:0db8:1234:1a00:0::/56"}, want: RouteTypeLocalIPv6CIDR},
+		{name: "stale secondary", vpc: single, route: RouteInfo{DestinationCidrBlock: "100.64.0.0/16"}, want: RouteTypeLocalUnassociatedCIDR},
+		{name: "stale IPv6", vpc: single, route: RouteInfo{DestinationIpv6Block: "2001:db8:1234:1a00::/56"}, want: RouteTypeLocalUnassociatedCIDR},
+		{name: "same base, other prefix", vpc: single, route: RouteInfo{DestinationCidrBlock: "10.0.0.0/17"}, want: RouteTypeLocalUnassociatedCIDR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LocalRouteType(tt.vpc, tt.route); got != tt.want {
+				t.Errorf("LocalRouteType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClassifyLocalRoutes checks that only local routes of scanned VPCs get a route type
+func TestClassifyLocalRoutes(t *testing.T) {
+	local := RouteTarget{Type: RouteTargetLocal, ID: "local"}
+	routeTables := []RouteTableInfo{
+		{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", Routes: []RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", Target: local},
+			{DestinationCidrBlock: "100.64.0.0/16", Target: local},
+			{DestinationCidrBlock: "0.0.0.0/0", Target: RouteTarget{Type: RouteTargetNatGateway, ID: "nat-0a1"}},
+		}},
+		{RouteTableID: "rtb-0gone", VpcID: "vpc-0gone", Routes: []RouteInfo{{DestinationCidrBlock: "10.9.0.0/16", Target: local}}},
+	}
+	ClassifyLocalRoutes([]VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16"}}, routeTables)
+
+	var got []string
+	for _, rt := range routeTables {
+		for _, route := range rt.Routes {
+			got = append(got, route.RouteType)
+		}
+	}
+	if want := []string{RouteTypeLocalPrimaryCIDR, RouteTypeLocalUnassociatedCIDR, "", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("route types = %q, want %q", got, want)
+	}
+}
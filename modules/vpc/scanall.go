@@ -0,0 +1,78 @@
+package vpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"aws-documentor/modules/awsconfig"
+)
+
+// ScanResult holds the core VPC resources of a region, as returned by ScanAll
+type ScanResult struct {
+	VPCs             []VPCInfo                      // VPCs of the region
+	Subnets          []SubnetInfo                   // Subnets of the region
+	RouteTables      []RouteTableInfo               // Route tables of the region
+	SecurityGroups   []SecurityGroupInfo            // Security groups of the region
+	InternetGateways []InternetGatewayInfo          // Internet gateways of the region
+	NatGateways      []NatGatewayInfo               // NAT gateways of the region
+	TransitGateways  []TransitGatewayInfo           // Transit gateways of the region
+	TGWAttachments   []TransitGatewayAttachmentInfo // Transit gateway attachments of the region
+}
+
+// ScanAll retrieves the core VPC resources of the region with concurrent API calls
+// The eight Get* calls are independent, so the scan takes as long as the slowest of them instead of their sum.
+// The first call to fail cancels the others. A call refused by the call budget does not: the others go on,
+// and the budget error is returned with the resources that were retrieved.
+// ctx: Context for the requests, allowing for timeout and cancellation
+// opts: Tag filters passed to every call (none for all resources)
+// Returns: The resources of the region, or the error of the first call that failed (with the partial
+// resources if it was refused by the call budget)
+func (s *Scanner) ScanAll(ctx context.Context, opts ...ScanOptions) (*ScanResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		result    ScanResult
+		wg        sync.WaitGroup
+		once      sync.Once
+		firstErr  error
+		budgetErr error
+		budgetMu  sync.Mutex
+	)
+	// run starts one call; each call writes its own field of result, so they need no lock
+	run := func(call func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := call()
+			if errors.Is(err, awsconfig.ErrCallBudgetExceeded) {
+				budgetMu.Lock()
+				budgetErr = err
+				budgetMu.Unlock()
+				return
+			}
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+
+	run(func() (err error) { result.VPCs, err = s.GetVPCs(ctx, opts...); return })
+	run(func() (err error) { result.Subnets, err = s.GetSubnets(ctx, opts...); return })
+	run(func() (err error) { result.RouteTables, err = s.GetRouteTables(ctx, opts...); return })
+	run(func() (err error) { result.SecurityGroups, err = s.GetSecurityGroups(ctx, opts...); return })
+	run(func() (err error) { result.InternetGateways, err = s.GetInternetGateways(ctx, opts...); return })
+	run(func() (err error) { result.NatGateways, err = s.GetNatGateways(ctx, opts...); return })
+	run(func() (err error) { result.TransitGateways, err = s.GetTransitGateways(ctx, opts...); return })
+	run(func() (err error) { result.TGWAttachments, err = s.GetTransitGatewayAttachments(ctx, opts...); return })
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return &result, budgetErr
+}
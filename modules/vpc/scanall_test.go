@@ -0,0 +1,80 @@
+package vpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/awsconfig"
+)
+
+// deniedResponse is the entry of an action that fails with UnauthorizedOperation
+const deniedResponse = "denied"
+
+// newTestScanner returns a scanner whose EC2 endpoint answers each action with its entry in responses
+// Actions without an entry get an empty result. Later pages are looked up as the action, a space and the
+// NextToken, and an entry of deniedResponse fails the call.
+// responses: Result elements by action, such as the vpcSet of DescribeVpcs
+// maxCalls: The call budget of the scanner (0 for no limit)
+func newTestScanner(t *testing.T, responses map[string]string, maxCalls int) *Scanner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("Action")
+		key := action
+		if token := r.Form.Get("NextToken"); token != "" {
+			key += " " + token
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		if responses[key] == deniedResponse {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Response><Errors><Error><Code>UnauthorizedOperation</Code><Message>not authorized</Message></Error></Errors><RequestID>req-1</RequestID></Response>`)
+			return
+		}
+		fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">%s<requestId>req-1</requestId></%sResponse>`, action, responses[key], action)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region:       "eu-west-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	}
+	budget := awsconfig.NewCallBudget(maxCalls)
+	cfg.APIOptions = append(cfg.APIOptions, budget.AddMiddleware)
+	return NewScanner(cfg)
+}
+
+// oneVPC is the DescribeVpcs result of the ScanAll tests
+var oneVPC = map[string]string{
+	"DescribeVpcs": `<vpcSet><item><vpcId>vpc-1</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item></vpcSet>`,
+}
+
+// TestScanAll checks that ScanAll returns the resources of every core call
+func TestScanAll(t *testing.T) {
+	scan, err := newTestScanner(t, oneVPC, 0).ScanAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.VPCs) != 1 || scan.VPCs[0].VpcID != "vpc-1" {
+		t.Errorf("VPCs = %+v, want vpc-1", scan.VPCs)
+	}
+}
+
+// TestScanAllKeepsResultsWhenBudgetRunsOut checks that calls refused by the call budget leave the others' results
+func TestScanAllKeepsResultsWhenBudgetRunsOut(t *testing.T) {
+	scan, err := newTestScanner(t, oneVPC, 3).ScanAll(context.Background())
+	if !errors.Is(err, awsconfig.ErrCallBudgetExceeded) {
+		t.Fatalf("error = %v, want the call budget error", err)
+	}
+	if scan == nil {
+		t.Fatal("ScanAll returned no result with the call budget error")
+	}
+}
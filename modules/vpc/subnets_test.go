@@ -0,0 +1,53 @@
+package vpc
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestSetAddressUsage(t *testing.T) {
+	count := func(n int) *int { return &n }
+	tests := []struct {
+		name        string
+		cidr        string
+		available   *int
+		wantTotal   int
+		wantPercent float64
+	}{
+		{name: "/24 nearly empty", cidr: "10.0.0.0/24", available: count(250), wantTotal: 251, wantPercent: 100.0 / 251},
+		{name: "/28 full", cidr: "10.0.0.0/28", available: count(0), wantTotal: 11, wantPercent: 100},
+		{name: "/20 half used", cidr: "10.0.0.0/20", available: count(2043), wantTotal: 4091, wantPercent: 2048.0 / 4091 * 100},
+		{name: "count unknown", cidr: "10.0.0.0/24", wantTotal: 251},
+		{name: "more free than usable", cidr: "10.0.0.0/24", available: count(300), wantTotal: 251},
+		{name: "no IPv4 block", available: count(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnet := SubnetInfo{CidrBlock: tt.cidr, AvailableIpAddressCount: tt.available}
+			subnet.SetAddressUsage()
+			if subnet.TotalIpAddressCount != tt.wantTotal || math.Abs(subnet.UtilizationPercent-tt.wantPercent) > 1e-9 {
+				t.Errorf("total %d, utilization %v; want %d, %v", subnet.TotalIpAddressCount, subnet.UtilizationPercent, tt.wantTotal, tt.wantPercent)
+			}
+		})
+	}
+}
+
+// TestSubnetJSONUtilization checks that the address total and utilization are written to the JSON
+func TestSubnetJSONUtilization(t *testing.T) {
+	available := 0
+	subnet := SubnetInfo{SubnetID: "subnet-0a1", CidrBlock: "10.0.0.0/28", AvailableIpAddressCount: &available}
+	subnet.SetAddressUsage()
+	data, err := json.Marshal(subnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["total_ip_address_count"] != 11.0 || fields["utilization_percent"] != 100.0 {
+		t.Errorf("JSON = %s", data)
+	}
+}
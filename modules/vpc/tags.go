@@ -0,0 +1,93 @@
+package vpc
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// SystemTagPrefix is the prefix of the tags AWS services set themselves (aws:cloudformation:stack-name, ...)
+const SystemTagPrefix = "aws:"
+
+// Tag is one tag of a resource as the API returned it
+type Tag struct {
+	Key   string `json:"key"`   // Tag key
+	Value string `json:"value"` // Tag value, empty if the tag has none
+}
+
+// convertTagList converts AWS tags to a list in API order
+// Unlike convertTags, tags without a value are kept with an empty value and duplicate keys are not merged.
+func convertTagList(tags []types.Tag) []Tag {
+	result := []Tag{}
+	for _, tag := range tags {
+		if tag.Key != nil {
+			result = append(result, Tag{Key: *tag.Key, Value: aws.ToString(tag.Value)})
+		}
+	}
+	return result
+}
+
+// NewTag creates a tag from the key and value pointers of an SDK tag type
+// Scanners of other services use it to build their tag lists.
+func NewTag(key, value *string) Tag {
+	return Tag{Key: aws.ToString(key), Value: aws.ToString(value)}
+}
+
+// IsSystemTag reports whether a tag key belongs to a tag AWS set itself
+func IsSystemTag(key string) bool {
+	return strings.HasPrefix(key, SystemTagPrefix)
+}
+
+// HideSystemTags removes the aws: system tags from the tag maps and tag lists of scanned resources
+// It walks the value recursively, so a pointer to any resource, slice of resources or struct of
+// them can be passed. Fields named Tags (map[string]string) and TagList ([]Tag) are filtered in place.
+// resources: Pointer to, or slice of, scanned resources
+func HideSystemTags(resources interface{}) {
+	hideSystemTags(reflect.ValueOf(resources))
+}
+
+// hideSystemTags filters the tags of one value and recurses into its elements and fields
+func hideSystemTags(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			hideSystemTags(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			hideSystemTags(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			switch tags := field.Interface().(type) {
+			case map[string]string:
+				if t.Field(i).Name == "Tags" {
+					for key := range tags {
+						if IsSystemTag(key) {
+							delete(tags, key)
+						}
+					}
+				}
+			case []Tag:
+				if field.CanSet() && tags != nil {
+					kept := []Tag{}
+					for _, tag := range tags {
+						if !IsSystemTag(tag.Key) {
+							kept = append(kept, tag)
+						}
+					}
+					field.Set(reflect.ValueOf(kept))
+				}
+			default:
+				hideSystemTags(field)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package vpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// apiTags are tags as EC2 returns them: a system tag, a tag without a value, an empty value and a key
+// that would sort first but comes last
+var apiTags = []types.Tag{
+	{Key: aws.String("Name"), Value: aws.String("prod")},
+	{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("network")},
+	{Key: aws.String("flag")},
+	{Key: aws.String("Owner"), Value: aws.String("")},
+	{Value: aws.String("keyless")},
+	{Key: aws.String("Environment"), Value: aws.String("prod")},
+}
+
+// TestConvertTagList checks that the tag list keeps API order, system tags and tags without a value, which
+// the tag map drops
+func TestConvertTagList(t *testing.T) {
+	wantList := []Tag{
+		{Key: "Name", Value: "prod"},
+		{Key: "aws:cloudformation:stack-name", Value: "network"},
+		{Key: "flag"},
+		{Key: "Owner"},
+		{Key: "Environment", Value: "prod"},
+	}
+	if got := convertTagList(apiTags); !reflect.DeepEqual(got, wantList) {
+		t.Errorf("convertTagList() = %+v\nwant %+v", got, wantList)
+	}
+	wantMap := map[string]string{"Name": "prod", "aws:cloudformation:stack-name": "network", "Owner": "", "Environment": "prod"}
+	if got := convertTags(apiTags); !reflect.DeepEqual(got, wantMap) {
+		t.Errorf("convertTags() = %v, want %v", got, wantMap)
+	}
+	if got := convertTagList(nil); got == nil || len(got) != 0 {
+		t.Errorf("convertTagList(nil) = %#v, want an empty list", got)
+	}
+}
+
+// TestTagJSONShape checks that resources encode the tags both as a sorted map and as a list of key and value objects
+func TestTagJSONShape(t *testing.T) {
+	info := VPCInfo{VpcID: "vpc-0a1", Tags: convertTags(apiTags[:4]), TagList: convertTagList(apiTags[:4])}
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	wantTags := `{"Name":"prod","Owner":"","aws:cloudformation:stack-name":"network"}`
+	if got := string(fields["tags"]); got != wantTags {
+		t.Errorf("tags = %s, want %s", got, wantTags)
+	}
+	wantList := `[{"key":"Name","value":"prod"},{"key":"aws:cloudformation:stack-name","value":"network"},{"key":"flag","value":""},{"key":"Owner","value":""}]`
+	if got := string(fields["tag_list"]); got != wantList {
+		t.Errorf("tag_list = %s, want %s", got, wantList)
+	}
+}
+
+// TestHideSystemTags removes system tags from resources in slices, behind pointers and nested in other
+// resources, keeps tags that merely contain aws: and leaves missing tag lists missing
+func TestHideSystemTags(t *testing.T) {
+	tagged := func() (map[string]string, []Tag) {
+		return map[string]string{"Name": "prod", "aws:cloudformation:stack-name": "network", "team:aws:owner": "net"},
+			[]Tag{{Key: "aws:cloudformation:stack-name", Value: "network"}, {Key: "Name", Value: "prod"}, {Key: "team:aws:owner", Value: "net"}}
+	}
+	wantMap := map[string]string{"Name": "prod", "team:aws:owner": "net"}
+	wantList := []Tag{{Key: "Name", Value: "prod"}, {Key: "team:aws:owner", Value: "net"}}
+
+	vpcTags, vpcList := tagged()
+	attachmentTags, attachmentList := tagged()
+	vpcs := []VPCInfo{{VpcID: "vpc-0a1", Tags: vpcTags, TagList: vpcList}, {VpcID: "vpc-0old"}}
+	appliance := &RouteApplianceInfo{InstanceID: "i-0fw"}
+	appliance.Tags, appliance.TagList = tagged()
+	nested := struct {
+		Attachments []*TransitGatewayAttachmentInfo
+		Labels      map[string]string
+	}{
+		Attachments: []*TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0a1", Tags: attachmentTags, TagList: attachmentList}, nil},
+		Labels:      map[string]string{"aws:kept": "not a Tags field"},
+	}
+
+	HideSystemTags(vpcs)
+	HideSystemTags(appliance)
+	HideSystemTags(&nested)
+
+	for name, got := range map[string]map[string]string{"VPC": vpcs[0].Tags, "appliance": appliance.Tags, "attachment": nested.Attachments[0].Tags} {
+		if !reflect.DeepEqual(got, wantMap) {
+			t.Errorf("%s tags = %v, want %v", name, got, wantMap)
+		}
+	}
+	for name, got := range map[string][]Tag{"VPC": vpcs[0].TagList, "appliance": appliance.TagList, "attachment": nested.Attachments[0].TagList} {
+		if !reflect.DeepEqual(got, wantList) {
+			t.Errorf("%s tag list = %+v, want %+v", name, got, wantList)
+		}
+	}
+	if vpcs[1].Tags != nil || vpcs[1].TagList != nil {
+		t.Errorf("untagged VPC = %+v, want its tags left nil", vpcs[1])
+	}
+	if len(nested.Labels) != 1 {
+		t.Errorf("Labels = %v, want maps not named Tags untouched", nested.Labels)
+	}
+}
@@ -0,0 +1,177 @@
+package vpc
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// TransitGatewayRouteInfo contains information about a route in a transit gateway route table
+type TransitGatewayRouteInfo struct {
+	DestinationCidrBlock string   `json:"destination_cidr_block"` // CIDR block for the route destination
+	PrefixListID         string   `json:"prefix_list_id"`         // ID of the prefix list for the route destination
+	Type                 string   `json:"type"`                   // How the route was created (static, propagated)
+	State                string   `json:"state"`                  // State of the route (active, blackhole)
+	AttachmentIDs        []string `json:"attachment_ids"`         // IDs of the attachments the route sends traffic to (more than one for ECMP)
+	ResourceIDs          []string `json:"resource_ids"`           // IDs of the resources behind those attachments, in the same order
+}
+
+// TransitGatewayRouteTableInfo contains information about a transit gateway route table and its routes
+type TransitGatewayRouteTableInfo struct {
+	RouteTableID              string                    `json:"route_table_id"`              // Unique identifier for the transit gateway route table
+	Arn                       string                    `json:"arn"`                         // ARN of the route table (empty if the account is unknown)
+	Region                    string                    `json:"region"`                      // Region of the route table
+	TransitGatewayID          string                    `json:"transit_gateway_id"`          // ID of the transit gateway
+	State                     string                    `json:"state"`                       // State of the route table (pending, available, deleting, deleted)
+	IsDefaultAssociation      bool                      `json:"is_default_association"`      // Whether this is the default association route table
+	IsDefaultPropagation      bool                      `json:"is_default_propagation"`      // Whether this is the default propagation route table
+	Routes                    []TransitGatewayRouteInfo `json:"routes"`                      // Active and blackhole routes of the route table
+	AdditionalRoutesAvailable bool                      `json:"additional_routes_available"` // Whether the route table has more routes than a single search returns
+	Tags                      map[string]string         `json:"tags"`                        // Key-value tags associated with the route table
+	TagList                   []Tag                     `json:"tag_list"`                    // Tags in API order, including tags without a value
+}
+
+// GetTransitGatewayRouteTables retrieves all transit gateway route tables and their routes in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the route tables must match (none for all); DescribeTransitGatewayRouteTables has no tag filter, so they are applied to the response
+// Returns: Slice of TransitGatewayRouteTableInfo structs containing route table details, or error if the operation fails
+func (s *Scanner) GetTransitGatewayRouteTables(ctx context.Context, opts ...ScanOptions) ([]TransitGatewayRouteTableInfo, error) {
+	// Prepare input for describing all transit gateway route tables (no filters applied)
+	input := &ec2.DescribeTransitGatewayRouteTablesInput{}
+
+	// Call AWS API to retrieve transit gateway route table information
+	result, err := s.ec2Client.DescribeTransitGatewayRouteTables(ctx, input)
+	if err != nil {
+		return nil, newScanError("transit gateway route tables", "DescribeTransitGatewayRouteTables", err)
+	}
+
+	routeTables := []TransitGatewayRouteTableInfo{}
+	for _, rt := range result.TransitGatewayRouteTables {
+		rtInfo := TransitGatewayRouteTableInfo{
+			RouteTableID:         aws.ToString(rt.TransitGatewayRouteTableId),
+			Arn:                  s.arn(arnbuild.TypeTransitGatewayRouteTable, "", aws.ToString(rt.TransitGatewayRouteTableId)),
+			Region:               s.region,
+			TransitGatewayID:     aws.ToString(rt.TransitGatewayId),
+			State:                string(rt.State),
+			IsDefaultAssociation: aws.ToBool(rt.DefaultAssociationRouteTable),
+			IsDefaultPropagation: aws.ToBool(rt.DefaultPropagationRouteTable),
+			Routes:               []TransitGatewayRouteInfo{},
+			Tags:                 convertTags(rt.Tags),
+			TagList:              convertTagList(rt.Tags),
+		}
+		if !matchesTags(rtInfo.Tags, opts) {
+			continue
+		}
+
+		// Route tables that are being created or deleted cannot be searched
+		if rt.State != types.TransitGatewayRouteTableStateAvailable {
+			routeTables = append(routeTables, rtInfo)
+			continue
+		}
+
+		// SearchTransitGatewayRoutes requires a filter; active and blackhole together cover every route
+		search, err := s.ec2Client.SearchTransitGatewayRoutes(ctx, &ec2.SearchTransitGatewayRoutesInput{
+			TransitGatewayRouteTableId: rt.TransitGatewayRouteTableId,
+			Filters: []types.Filter{
+				{Name: aws.String("state"), Values: []string{"active", "blackhole"}},
+			},
+		})
+		if err != nil {
+			return nil, newScanError("transit gateway routes", "SearchTransitGatewayRoutes", err)
+		}
+		rtInfo.AdditionalRoutesAvailable = aws.ToBool(search.AdditionalRoutesAvailable)
+
+		for _, route := range search.Routes {
+			routeInfo := TransitGatewayRouteInfo{
+				DestinationCidrBlock: aws.ToString(route.DestinationCidrBlock),
+				PrefixListID:         aws.ToString(route.PrefixListId),
+				Type:                 string(route.Type),
+				State:                string(route.State),
+				AttachmentIDs:        []string{},
+				ResourceIDs:          []string{},
+			}
+			for _, attachment := range route.TransitGatewayAttachments {
+				routeInfo.AttachmentIDs = append(routeInfo.AttachmentIDs, aws.ToString(attachment.TransitGatewayAttachmentId))
+				routeInfo.ResourceIDs = append(routeInfo.ResourceIDs, aws.ToString(attachment.ResourceId))
+			}
+			rtInfo.Routes = append(rtInfo.Routes, routeInfo)
+		}
+
+		routeTables = append(routeTables, rtInfo)
+	}
+
+	return routeTables, nil
+}
+
+// enrichVpcAttachments adds the subnets and appliance mode setting of VPC attachments
+// These are only returned by DescribeTransitGatewayVpcAttachments, not by the generic attachment listing
+// ctx: Context for the request, allowing for timeout and cancellation
+// attachments: Attachments from DescribeTransitGatewayAttachments, updated in place
+// Returns: Error if the operation fails
+func (s *Scanner) enrichVpcAttachments(ctx context.Context, attachments []TransitGatewayAttachmentInfo) error {
+	byID := make(map[string]*TransitGatewayAttachmentInfo)
+	for i := range attachments {
+		if attachments[i].ResourceType == string(types.TransitGatewayAttachmentResourceTypeVpc) {
+			byID[attachments[i].AttachmentID] = &attachments[i]
+		}
+	}
+	if len(byID) == 0 {
+		return nil
+	}
+
+	result, err := s.ec2Client.DescribeTransitGatewayVpcAttachments(ctx, &ec2.DescribeTransitGatewayVpcAttachmentsInput{})
+	if err != nil {
+		return newScanError("transit gateway VPC attachments", "DescribeTransitGatewayVpcAttachments", err)
+	}
+
+	for _, vpcAttachment := range result.TransitGatewayVpcAttachments {
+		attachment, ok := byID[aws.ToString(vpcAttachment.TransitGatewayAttachmentId)]
+		if !ok {
+			continue
+		}
+		attachment.SubnetIDs = append([]string{}, vpcAttachment.SubnetIds...)
+		if vpcAttachment.Options != nil {
+			attachment.ApplianceModeSupport = string(vpcAttachment.Options.ApplianceModeSupport)
+		}
+	}
+	return nil
+}
+
+// enrichPeeringAttachments adds the region of the peer transit gateway to peering attachments
+// Either side of the peering may be the requester, so the peer is the side that is not the attachment's gateway.
+func (s *Scanner) enrichPeeringAttachments(ctx context.Context, attachments []TransitGatewayAttachmentInfo) error {
+	byID := make(map[string]*TransitGatewayAttachmentInfo)
+	for i := range attachments {
+		if attachments[i].ResourceType == string(types.TransitGatewayAttachmentResourceTypePeering) {
+			byID[attachments[i].AttachmentID] = &attachments[i]
+		}
+	}
+	if len(byID) == 0 {
+		return nil
+	}
+
+	paginator := ec2.NewDescribeTransitGatewayPeeringAttachmentsPaginator(s.ec2Client, &ec2.DescribeTransitGatewayPeeringAttachmentsInput{})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return newScanError("transit gateway peering attachments", "DescribeTransitGatewayPeeringAttachments", err)
+		}
+
+		for _, peering := range result.TransitGatewayPeeringAttachments {
+			attachment, ok := byID[aws.ToString(peering.TransitGatewayAttachmentId)]
+			if !ok {
+				continue
+			}
+			for _, side := range []*types.PeeringTgwInfo{peering.RequesterTgwInfo, peering.AccepterTgwInfo} {
+				if side != nil && aws.ToString(side.TransitGatewayId) != attachment.TransitGatewayID {
+					attachment.PeerRegion = aws.ToString(side.Region)
+				}
+			}
+		}
+	}
+	return nil
+}
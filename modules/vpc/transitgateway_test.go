@@ -0,0 +1,196 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// TestGetTransitGatewayRouteTables checks that routes are searched for available route tables only, with every
+// attachment of an ECMP route
+func TestGetTransitGatewayRouteTables(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{
+		"DescribeTransitGatewayRouteTables": `<transitGatewayRouteTables>
+			<item><transitGatewayRouteTableId>tgw-rtb-0spoke</transitGatewayRouteTableId><transitGatewayId>tgw-0a1</transitGatewayId>
+				<state>available</state><defaultAssociationRouteTable>true</defaultAssociationRouteTable></item>
+			<item><transitGatewayRouteTableId>tgw-rtb-0old</transitGatewayRouteTableId><transitGatewayId>tgw-0a1</transitGatewayId>
+				<state>deleting</state></item>
+		</transitGatewayRouteTables>`,
+		"SearchTransitGatewayRoutes": `<routeSet>
+			<item><destinationCidrBlock>0.0.0.0/0</destinationCidrBlock><type>static</type><state>active</state>
+				<transitGatewayAttachments>
+					<item><transitGatewayAttachmentId>tgw-attach-0insp</transitGatewayAttachmentId><resourceId>vpc-0insp</resourceId><resourceType>vpc</resourceType></item>
+					<item><transitGatewayAttachmentId>tgw-attach-0insp2</transitGatewayAttachmentId><resourceId>vpc-0insp2</resourceId><resourceType>vpc</resourceType></item>
+				</transitGatewayAttachments></item>
+			<item><destinationCidrBlock>10.9.0.0/16</destinationCidrBlock><type>static</type><state>blackhole</state></item>
+		</routeSet><additionalRoutesAvailable>true</additionalRoutesAvailable>`,
+	}, 0)
+
+	got, err := scanner.GetTransitGatewayRouteTables(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d route tables, want 2", len(got))
+	}
+	spoke, old := got[0], got[1]
+	wantRoutes := []TransitGatewayRouteInfo{
+		{DestinationCidrBlock: "0.0.0.0/0", Type: "static", State: "active",
+			AttachmentIDs: []string{"tgw-attach-0insp", "tgw-attach-0insp2"}, ResourceIDs: []string{"vpc-0insp", "vpc-0insp2"}},
+		{DestinationCidrBlock: "10.9.0.0/16", Type: "static", State: "blackhole", AttachmentIDs: []string{}, ResourceIDs: []string{}},
+	}
+	if spoke.RouteTableID != "tgw-rtb-0spoke" || !spoke.IsDefaultAssociation || !spoke.AdditionalRoutesAvailable || !reflect.DeepEqual(spoke.Routes, wantRoutes) {
+		t.Errorf("spoke route table = %+v, want the default association table with routes %+v", spoke, wantRoutes)
+	}
+	if old.State != "deleting" || len(old.Routes) != 0 || old.Routes == nil {
+		t.Errorf("deleting route table = %+v, want no routes searched", old)
+	}
+}
+
+// TestTransitGatewayAttachmentEnrichment checks that VPC attachments get their subnets and appliance mode and
+// other attachments are left alone
+func TestTransitGatewayAttachmentEnrichment(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{
+		"DescribeTransitGatewayAttachments": `<transitGatewayAttachments>
+			<item><transitGatewayAttachmentId>tgw-attach-0insp</transitGatewayAttachmentId><transitGatewayId>tgw-0a1</transitGatewayId>
+				<resourceType>vpc</resourceType><resourceId>vpc-0insp</resourceId><state>available</state>
+				<association><transitGatewayRouteTableId>tgw-rtb-0firewall</transitGatewayRouteTableId><state>associated</state></association></item>
+			<item><transitGatewayAttachmentId>tgw-attach-0a1</transitGatewayAttachmentId><transitGatewayId>tgw-0a1</transitGatewayId>
+				<resourceType>vpc</resourceType><resourceId>vpc-0a1</resourceId><state>available</state></item>
+			<item><transitGatewayAttachmentId>tgw-attach-0vpn</transitGatewayAttachmentId><transitGatewayId>tgw-0a1</transitGatewayId>
+				<resourceType>vpn</resourceType><resourceId>vpn-0office</resourceId><state>available</state></item>
+		</transitGatewayAttachments>`,
+		"DescribeTransitGatewayVpcAttachments": `<transitGatewayVpcAttachments>
+			<item><transitGatewayAttachmentId>tgw-attach-0insp</transitGatewayAttachmentId><vpcId>vpc-0insp</vpcId>
+				<subnetIds><item>subnet-0insp-tgw-a</item><item>subnet-0insp-tgw-b</item></subnetIds>
+				<options><applianceModeSupport>enable</applianceModeSupport></options></item>
+			<item><transitGatewayAttachmentId>tgw-attach-0a1</transitGatewayAttachmentId><vpcId>vpc-0a1</vpcId>
+				<subnetIds><item>subnet-0a1</item></subnetIds>
+				<options><applianceModeSupport>disable</applianceModeSupport></options></item>
+			<item><transitGatewayAttachmentId>tgw-attach-0filtered</transitGatewayAttachmentId><vpcId>vpc-0b2</vpcId></item>
+		</transitGatewayVpcAttachments>`,
+	}, 0)
+
+	attachments, err := scanner.GetTransitGatewayAttachments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		subnetIDs     []string
+		applianceMode string
+		routeTableID  string
+	}{
+		{[]string{"subnet-0insp-tgw-a", "subnet-0insp-tgw-b"}, "enable", "tgw-rtb-0firewall"},
+		{[]string{"subnet-0a1"}, "disable", ""},
+		{[]string{}, "", ""},
+	}
+	if len(attachments) != len(tests) {
+		t.Fatalf("got %d attachments, want %d", len(attachments), len(tests))
+	}
+	for i, tt := range tests {
+		a := attachments[i]
+		if !reflect.DeepEqual(a.SubnetIDs, tt.subnetIDs) || a.ApplianceModeSupport != tt.applianceMode || a.Association["route_table_id"] != tt.routeTableID {
+			t.Errorf("%s: subnets %v, appliance mode %q, route table %q; want %v, %q, %q", a.AttachmentID,
+				a.SubnetIDs, a.ApplianceModeSupport, a.Association["route_table_id"], tt.subnetIDs, tt.applianceMode, tt.routeTableID)
+		}
+	}
+}
+
+// peeringAttachments are the DescribeTransitGatewayAttachments result of the peer region tests: peerings
+// requested by tgw-0a1, accepted by it and to a gateway of the same region
+const peeringAttachments = `<transitGatewayAttachments>
+	<item><transitGatewayAttachmentId>tgw-attach-0us</transitGatewayAttachmentId><transitGatewayId>tgw-0a1</transitGatewayId>
+		<resourceType>peering</resourceType><resourceId>tgw-0us</resourceId><resourceOwnerId>111122223333</resourceOwnerId><state>available</state></item>
+	<item><transitGatewayAttachmentId>tgw-attach-0ap</transitGatewayAttachmentId><transitGatewayId>tgw-0a1</transitGatewayId>
+		<resourceType>peering</resourceType><resourceId>tgw-0ap</resourceId><resourceOwnerId>444455556666</resourceOwnerId><state>available</state></item>
+	<item><transitGatewayAttachmentId>tgw-attach-0a1</transitGatewayAttachmentId><transitGatewayId>tgw-0a1</transitGatewayId>
+		<resourceType>vpc</resourceType><resourceId>vpc-0a1</resourceId><state>available</state></item>
+</transitGatewayAttachments>`
+
+// TestPeeringAttachmentRegions checks that peering attachments get the region of the side that is not their
+// own gateway from every page, and that without permission the regions stay empty
+func TestPeeringAttachmentRegions(t *testing.T) {
+	tests := []struct {
+		name     string
+		peerings map[string]string // DescribeTransitGatewayPeeringAttachments pages
+		want     []string          // Peer region of each attachment
+	}{
+		{name: "two pages", peerings: map[string]string{
+			"DescribeTransitGatewayPeeringAttachments": `<transitGatewayPeeringAttachments>
+				<item><transitGatewayAttachmentId>tgw-attach-0us</transitGatewayAttachmentId>
+					<requesterTgwInfo><transitGatewayId>tgw-0a1</transitGatewayId><region>eu-west-1</region></requesterTgwInfo>
+					<accepterTgwInfo><transitGatewayId>tgw-0us</transitGatewayId><region>us-east-1</region></accepterTgwInfo></item>
+				</transitGatewayPeeringAttachments><nextToken>page-2</nextToken>`,
+			"DescribeTransitGatewayPeeringAttachments page-2": `<transitGatewayPeeringAttachments>
+				<item><transitGatewayAttachmentId>tgw-attach-0ap</transitGatewayAttachmentId>
+					<requesterTgwInfo><transitGatewayId>tgw-0ap</transitGatewayId><region>ap-southeast-2</region></requesterTgwInfo>
+					<accepterTgwInfo><transitGatewayId>tgw-0a1</transitGatewayId><region>eu-west-1</region></accepterTgwInfo></item>
+				<item><transitGatewayAttachmentId>tgw-attach-0other</transitGatewayAttachmentId>
+					<requesterTgwInfo><transitGatewayId>tgw-0b2</transitGatewayId><region>eu-west-1</region></requesterTgwInfo>
+					<accepterTgwInfo><transitGatewayId>tgw-0c3</transitGatewayId><region>eu-central-1</region></accepterTgwInfo></item>
+				</transitGatewayPeeringAttachments>`,
+		}, want: []string{"us-east-1", "ap-southeast-2", ""}},
+		{name: "access denied", peerings: map[string]string{"DescribeTransitGatewayPeeringAttachments": deniedResponse}, want: []string{"", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := map[string]string{"DescribeTransitGatewayAttachments": peeringAttachments}
+			for action, page := range tt.peerings {
+				responses[action] = page
+			}
+			attachments, err := newTestScanner(t, responses, 0).GetTransitGatewayAttachments(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, attachment := range attachments {
+				got = append(got, attachment.PeerRegion)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("peer regions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDescribeByID checks that follow-up scans describe only the requested peer VPCs and transit gateways
+func TestDescribeByID(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("Action")
+		for key, values := range r.Form {
+			if strings.HasPrefix(key, "VpcId.") || strings.HasPrefix(key, "TransitGatewayIds.") {
+				requested = append(requested, action+" "+values[0])
+			}
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>req-1</requestId></%sResponse>`, action, action)
+	}))
+	t.Cleanup(server.Close)
+	scanner := NewScanner(aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		BaseEndpoint: aws.String(server.URL),
+		HTTPClient:   server.Client(),
+		Retryer:      func() aws.Retryer { return aws.NopRetryer{} },
+	})
+
+	if _, err := scanner.GetVPCsByID(context.Background(), []string{"vpc-0us"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanner.GetTransitGatewaysByID(context.Background(), []string{"tgw-0us"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DescribeVpcs vpc-0us", "DescribeTransitGateways tgw-0us"}
+	if !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested = %q, want %q", requested, want)
+	}
+}
@@ -3,28 +3,42 @@ package vpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/checkpoint"
+	"aws-documentor/modules/netcalc"
 )
 
 // VPCInfo contains comprehensive information about an AWS VPC
 type VPCInfo struct {
-	VpcID               string            `json:"vpc_id"`                // Unique identifier for the VPC
-	CidrBlock           string            `json:"cidr_block"`            // Primary CIDR block assigned to the VPC
-	State               string            `json:"state"`                 // Current state of the VPC (available, pending)
-	IsDefault           bool              `json:"is_default"`            // Whether this is the default VPC for the region
-	DhcpOptionsID       string            `json:"dhcp_options_id"`       // ID of the DHCP options set associated with the VPC
-	InstanceTenancy     string            `json:"instance_tenancy"`      // Tenancy of instances launched into the VPC (default, dedicated, host)
-	Tags                map[string]string `json:"tags"`                  // Key-value tags associated with the VPC
-	AssociateCidrBlocks []string          `json:"associate_cidr_blocks"` // Additional CIDR blocks associated with the VPC
+	VpcID               string            `json:"vpc_id"`                  // Unique identifier for the VPC
+	Arn                 string            `json:"arn"`                     // ARN of the VPC (empty if the account is unknown)
+	Region              string            `json:"region"`                  // Region of the VPC
+	CidrBlock           string            `json:"cidr_block"`              // Primary CIDR block assigned to the VPC
+	State               string            `json:"state"`                   // Current state of the VPC (available, pending)
+	IsDefault           bool              `json:"is_default"`              // Whether this is the default VPC for the region
+	DhcpOptionsID       string            `json:"dhcp_options_id"`         // ID of the DHCP options set associated with the VPC
+	InstanceTenancy     string            `json:"instance_tenancy"`        // Tenancy of instances launched into the VPC (default, dedicated, host)
+	Tags                map[string]string `json:"tags"`                    // Key-value tags associated with the VPC
+	TagList             []Tag             `json:"tag_list"`                // Tags in API order, including tags without a value
+	ManagedBy           string            `json:"managed_by"`              // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+	AssociateCidrBlocks []string          `json:"associate_cidr_blocks"`   // IPv4 CIDR blocks associated with the VPC, the primary one included
+	Ipv6CidrBlocks      []string          `json:"ipv6_cidr_blocks"`        // IPv6 CIDR blocks associated with the VPC
+	EffectiveDNS        *EffectiveDNS     `json:"effective_dns,omitempty"` // Name resolution settings from AddEffectiveDNS (nil if not scanned)
 }
 
 // SubnetInfo contains comprehensive information about an AWS subnet
 type SubnetInfo struct {
 	SubnetID                    string            `json:"subnet_id"`                       // Unique identifier for the subnet
+	Arn                         string            `json:"arn"`                             // ARN of the subnet
+	Region                      string            `json:"region"`                          // Region of the subnet
 	VpcID                       string            `json:"vpc_id"`                          // ID of the VPC that contains this subnet
 	CidrBlock                   string            `json:"cidr_block"`                      // CIDR block assigned to the subnet
 	AvailabilityZone            string            `json:"availability_zone"`               // Availability zone where the subnet is located
@@ -33,69 +47,102 @@ type SubnetInfo struct {
 	MapPublicIpOnLaunch         bool              `json:"map_public_ip_on_launch"`         // Whether instances launched in this subnet receive a public IP
 	AssignIpv6AddressOnCreation bool              `json:"assign_ipv6_address_on_creation"` // Whether instances receive an IPv6 address on creation
 	DefaultForAz                bool              `json:"default_for_az"`                  // Whether this is the default subnet for the availability zone
+	AvailableIpAddressCount     *int              `json:"available_ip_address_count"`      // Unused IPv4 addresses at scan time (null in reports of older versions)
+	TotalIpAddressCount         int               `json:"total_ip_address_count"`          // Usable IPv4 addresses of the CIDR block, less the 5 AWS reserves (0 without an IPv4 block); see SetAddressUsage
+	UtilizationPercent          float64           `json:"utilization_percent"`             // Share of the usable IPv4 addresses in use, 0 to 100 (0 if the unused addresses are unknown); see SetAddressUsage
 	Tags                        map[string]string `json:"tags"`                            // Key-value tags associated with the subnet
+	TagList                     []Tag             `json:"tag_list"`                        // Tags in API order, including tags without a value
+	ManagedBy                   string            `json:"managed_by"`                      // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
 }
 
 // RouteInfo contains information about an individual route in a route table
 type RouteInfo struct {
-	DestinationCidrBlock   string `json:"destination_cidr_block"`    // CIDR block for the route destination
-	DestinationIpv6Block   string `json:"destination_ipv6_block"`    // IPv6 CIDR block for the route destination
-	GatewayID              string `json:"gateway_id"`                // ID of the internet gateway or VPC gateway
-	InstanceID             string `json:"instance_id"`               // ID of a NAT instance
-	NatGatewayID           string `json:"nat_gateway_id"`            // ID of a NAT gateway
-	NetworkInterfaceID     string `json:"network_interface_id"`      // ID of the network interface
-	TransitGatewayID       string `json:"transit_gateway_id"`        // ID of the transit gateway
-	VpcPeeringConnectionID string `json:"vpc_peering_connection_id"` // ID of the VPC peering connection
-	State                  string `json:"state"`                     // State of the route (active, blackhole)
-	Origin                 string `json:"origin"`                    // How the route was created (CreateRouteTable, CreateRoute, EnableVgwRoutePropagation)
+	DestinationCidrBlock   string      `json:"destination_cidr_block"`      // CIDR block for the route destination
+	DestinationIpv6Block   string      `json:"destination_ipv6_block"`      // IPv6 CIDR block for the route destination
+	GatewayID              string      `json:"gateway_id"`                  // ID of the internet gateway or VPC gateway
+	InstanceID             string      `json:"instance_id"`                 // ID of a NAT instance
+	NatGatewayID           string      `json:"nat_gateway_id"`              // ID of a NAT gateway
+	NetworkInterfaceID     string      `json:"network_interface_id"`        // ID of the network interface
+	TransitGatewayID       string      `json:"transit_gateway_id"`          // ID of the transit gateway
+	CarrierGatewayID       string      `json:"carrier_gateway_id"`          // ID of the carrier gateway (Wavelength zones)
+	VpcPeeringConnectionID string      `json:"vpc_peering_connection_id"`   // ID of the VPC peering connection
+	CoreNetworkArn         string      `json:"core_network_arn"`            // ARN of the AWS Cloud WAN core network
+	State                  string      `json:"state"`                       // State of the route (active, blackhole)
+	Origin                 string      `json:"origin"`                      // How the route was created (CreateRouteTable, CreateRoute, EnableVgwRoutePropagation)
+	Target                 RouteTarget `json:"target"`                      // Target of the route, whichever field it was set in
+	RouteType              string      `json:"route_type,omitempty"`        // For local routes, which VPC CIDR block the route covers (set by ClassifyLocalRoutes)
+	DestinationLabel       string      `json:"destination_label,omitempty"` // Named range the destination falls within (set with -named-ranges)
 }
 
 // RouteTableInfo contains comprehensive information about an AWS route table
 type RouteTableInfo struct {
 	RouteTableID     string            `json:"route_table_id"`      // Unique identifier for the route table
+	Arn              string            `json:"arn"`                 // ARN of the route table (empty if the account is unknown)
+	Region           string            `json:"region"`              // Region of the route table
 	VpcID            string            `json:"vpc_id"`              // ID of the VPC that contains this route table
 	Routes           []RouteInfo       `json:"routes"`              // List of routes in the route table
 	SubnetIDs        []string          `json:"subnet_ids"`          // IDs of subnets explicitly associated with this route table
 	IsMainRouteTable bool              `json:"is_main_route_table"` // Whether this is the main route table for the VPC
 	Tags             map[string]string `json:"tags"`                // Key-value tags associated with the route table
+	TagList          []Tag             `json:"tag_list"`            // Tags in API order, including tags without a value
+	ManagedBy        string            `json:"managed_by"`          // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
 }
 
 // SecurityGroupRule contains information about a security group rule
 type SecurityGroupRule struct {
-	IsEgress      bool   `json:"is_egress"`       // Whether this is an egress rule (true) or ingress rule (false)
-	IpProtocol    string `json:"ip_protocol"`     // IP protocol (tcp, udp, icmp, or protocol number)
-	FromPort      int32  `json:"from_port"`       // Start of port range (or ICMP type)
-	ToPort        int32  `json:"to_port"`         // End of port range (or ICMP code)
-	CidrBlock     string `json:"cidr_block"`      // CIDR block for the rule
-	Ipv6CidrBlock string `json:"ipv6_cidr_block"` // IPv6 CIDR block for the rule
-	GroupID       string `json:"group_id"`        // ID of referenced security group
-	GroupOwnerID  string `json:"group_owner_id"`  // AWS account ID that owns the referenced security group
-	PrefixListID  string `json:"prefix_list_id"`  // ID of the prefix list
-	Description   string `json:"description"`     // Description of the rule
+	IsEgress               bool   `json:"is_egress"`                   // Whether this is an egress rule (true) or ingress rule (false)
+	IpProtocol             string `json:"ip_protocol"`                 // IP protocol (tcp, udp, icmp, or protocol number)
+	FromPort               int32  `json:"from_port"`                   // Start of port range (or ICMP type)
+	ToPort                 int32  `json:"to_port"`                     // End of port range (or ICMP code)
+	CidrBlock              string `json:"cidr_block"`                  // CIDR block for the rule
+	Ipv6CidrBlock          string `json:"ipv6_cidr_block"`             // IPv6 CIDR block for the rule
+	GroupID                string `json:"group_id"`                    // ID of referenced security group
+	GroupOwnerID           string `json:"group_owner_id"`              // AWS account ID that owns the referenced security group
+	GroupVpcID             string `json:"group_vpc_id"`                // ID of the VPC of the referenced security group (set for references across a peering connection)
+	VpcPeeringConnectionID string `json:"vpc_peering_connection_id"`   // ID of the peering connection the reference goes through (if any)
+	PeeringStatus          string `json:"peering_status"`              // Status of that peering connection as reported with the rule (active, deleted, ...)
+	PrefixListID           string `json:"prefix_list_id"`              // ID of the prefix list
+	Description            string `json:"description"`                 // Description of the rule
+	IsDefaultEgress        bool   `json:"is_default_egress"`           // Whether this is the allow-all egress rule AWS adds to every group (IPv4 or IPv6)
+	SourceLabel            string `json:"source_label,omitempty"`      // Named range the CIDR of an ingress rule falls within (set with -named-ranges)
+	DestinationLabel       string `json:"destination_label,omitempty"` // Named range the CIDR of an egress rule falls within (set with -named-ranges)
 }
 
 // SecurityGroupInfo contains comprehensive information about an AWS security group
 type SecurityGroupInfo struct {
-	GroupID     string              `json:"group_id"`    // Unique identifier for the security group
-	GroupName   string              `json:"group_name"`  // Name of the security group
-	Description string              `json:"description"` // Description of the security group
-	VpcID       string              `json:"vpc_id"`      // ID of the VPC that contains this security group
-	OwnerID     string              `json:"owner_id"`    // AWS account ID that owns the security group
-	Rules       []SecurityGroupRule `json:"rules"`       // List of all rules (ingress and egress) in the security group
-	Tags        map[string]string   `json:"tags"`        // Key-value tags associated with the security group
+	GroupID          string              `json:"group_id"`           // Unique identifier for the security group
+	Arn              string              `json:"arn"`                // ARN of the security group
+	Region           string              `json:"region"`             // Region of the security group
+	GroupName        string              `json:"group_name"`         // Name of the security group
+	Description      string              `json:"description"`        // Description of the security group
+	VpcID            string              `json:"vpc_id"`             // ID of the VPC that contains this security group
+	OwnerID          string              `json:"owner_id"`           // AWS account ID that owns the security group
+	Rules            []SecurityGroupRule `json:"rules"`              // List of all rules (ingress and egress) in the security group
+	IngressRuleCount int                 `json:"ingress_rule_count"` // Number of ingress rules (zero when the group has none)
+	EgressRuleCount  int                 `json:"egress_rule_count"`  // Number of egress rules (zero when the group has none)
+	EgressRestricted bool                `json:"egress_restricted"`  // Whether the default IPv4 allow-all egress rule has been removed
+	Tags             map[string]string   `json:"tags"`               // Key-value tags associated with the security group
+	TagList          []Tag               `json:"tag_list"`           // Tags in API order, including tags without a value
+	ManagedBy        string              `json:"managed_by"`         // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
 }
 
 // InternetGatewayInfo contains information about an AWS internet gateway
 type InternetGatewayInfo struct {
 	InternetGatewayID string            `json:"internet_gateway_id"` // Unique identifier for the internet gateway
+	Arn               string            `json:"arn"`                 // ARN of the internet gateway (empty if the account is unknown)
+	Region            string            `json:"region"`              // Region of the internet gateway
 	State             string            `json:"state"`               // State of the internet gateway (available, attached, detached, etc.)
 	VpcID             string            `json:"vpc_id"`              // ID of the VPC this gateway is attached to (empty if detached)
 	Tags              map[string]string `json:"tags"`                // Key-value tags associated with the internet gateway
+	TagList           []Tag             `json:"tag_list"`            // Tags in API order, including tags without a value
+	ManagedBy         string            `json:"managed_by"`          // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
 }
 
 // NatGatewayInfo contains information about an AWS NAT gateway
 type NatGatewayInfo struct {
 	NatGatewayID       string            `json:"nat_gateway_id"`       // Unique identifier for the NAT gateway
+	Arn                string            `json:"arn"`                  // ARN of the NAT gateway (empty if the account is unknown)
+	Region             string            `json:"region"`               // Region of the NAT gateway
 	SubnetID           string            `json:"subnet_id"`            // ID of the subnet the NAT gateway is in
 	VpcID              string            `json:"vpc_id"`               // ID of the VPC that contains this NAT gateway
 	State              string            `json:"state"`                // State of the NAT gateway (pending, failed, available, deleting, deleted)
@@ -106,11 +153,15 @@ type NatGatewayInfo struct {
 	NetworkInterfaceID string            `json:"network_interface_id"` // ID of the network interface for the NAT gateway
 	CreatedTime        string            `json:"created_time"`         // Time when the NAT gateway was created
 	Tags               map[string]string `json:"tags"`                 // Key-value tags associated with the NAT gateway
+	TagList            []Tag             `json:"tag_list"`             // Tags in API order, including tags without a value
+	ManagedBy          string            `json:"managed_by"`           // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
 }
 
 // TransitGatewayInfo contains information about an AWS Transit Gateway
 type TransitGatewayInfo struct {
 	TransitGatewayID             string            `json:"transit_gateway_id"`              // Unique identifier for the transit gateway
+	Arn                          string            `json:"arn"`                             // ARN of the transit gateway
+	Region                       string            `json:"region"`                          // Region of the transit gateway
 	State                        string            `json:"state"`                           // State of the transit gateway (pending, available, modifying, deleting, deleted)
 	OwnerID                      string            `json:"owner_id"`                        // AWS account ID that owns the transit gateway
 	Description                  string            `json:"description"`                     // Description of the transit gateway
@@ -124,67 +175,162 @@ type TransitGatewayInfo struct {
 	DnsSupport                   string            `json:"dns_support"`                     // Whether DNS support is enabled
 	MulticastSupport             string            `json:"multicast_support"`               // Whether multicast support is enabled
 	Tags                         map[string]string `json:"tags"`                            // Key-value tags associated with the transit gateway
+	TagList                      []Tag             `json:"tag_list"`                        // Tags in API order, including tags without a value
+	ManagedBy                    string            `json:"managed_by"`                      // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
 }
 
 // TransitGatewayAttachmentInfo contains information about a Transit Gateway attachment
 type TransitGatewayAttachmentInfo struct {
-	AttachmentID     string            `json:"attachment_id"`      // Unique identifier for the attachment
-	TransitGatewayID string            `json:"transit_gateway_id"` // ID of the transit gateway
-	ResourceType     string            `json:"resource_type"`      // Type of resource (vpc, vpn, direct-connect-gateway, peering)
-	ResourceID       string            `json:"resource_id"`        // ID of the attached resource
-	ResourceOwnerID  string            `json:"resource_owner_id"`  // AWS account ID that owns the resource
-	State            string            `json:"state"`              // State of the attachment (initiating, pendingAcceptance, rollingBack, pending, available, modifying, deleting, deleted, failed, rejected, rejecting, failing)
-	Association      map[string]string `json:"association"`        // Route table association information
-	CreationTime     string            `json:"creation_time"`      // Time when the attachment was created
-	Tags             map[string]string `json:"tags"`               // Key-value tags associated with the attachment
+	AttachmentID         string            `json:"attachment_id"`          // Unique identifier for the attachment
+	Arn                  string            `json:"arn"`                    // ARN of the attachment (empty if the account is unknown)
+	Region               string            `json:"region"`                 // Region of the attachment
+	TransitGatewayID     string            `json:"transit_gateway_id"`     // ID of the transit gateway
+	ResourceType         string            `json:"resource_type"`          // Type of resource (vpc, vpn, direct-connect-gateway, peering)
+	ResourceID           string            `json:"resource_id"`            // ID of the attached resource
+	ResourceOwnerID      string            `json:"resource_owner_id"`      // AWS account ID that owns the resource
+	State                string            `json:"state"`                  // State of the attachment (initiating, pendingAcceptance, rollingBack, pending, available, modifying, deleting, deleted, failed, rejected, rejecting, failing)
+	Association          map[string]string `json:"association"`            // Route table association information
+	SubnetIDs            []string          `json:"subnet_ids"`             // IDs of the subnets the attachment is in (VPC attachments only)
+	ApplianceModeSupport string            `json:"appliance_mode_support"` // Whether appliance mode is enabled (VPC attachments only: enable, disable)
+	PeerRegion           string            `json:"peer_region"`            // Region of the peer transit gateway (peering attachments only)
+	CreationTime         string            `json:"creation_time"`          // Time when the attachment was created
+	Tags                 map[string]string `json:"tags"`                   // Key-value tags associated with the attachment
+	TagList              []Tag             `json:"tag_list"`               // Tags in API order, including tags without a value
+	ManagedBy            string            `json:"managed_by"`             // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
 }
 
 // Scanner provides methods for retrieving VPC and related AWS networking information
 type Scanner struct {
-	ec2Client *ec2.Client // AWS EC2 client for making API calls
+	ec2Client     *ec2.Client        // AWS EC2 client for making API calls
+	checkpoints   *checkpoint.Store  // Progress of long paginations (nil to not checkpoint)
+	region        string             // Region of the client, for ARNs
+	accountID     string             // Account of the credentials, for ARNs of resources without an owner ID (empty if unknown)
+	quality       dataQuality        // Data-quality warnings of the scans so far
+	instanceTypes *InstanceTypeCache // Instance types described so far (nil until the first lookup or SetInstanceTypeCache)
+}
+
+// ScannerOption changes how NewScanner sets up a scanner
+type ScannerOption func(*scannerSettings)
+
+// scannerSettings are the settings the options of NewScanner collect
+type scannerSettings struct {
+	roleARN     string // Role to assume with the credentials of the config (empty for none)
+	sessionName string // Session name of the assumed role
+	externalID  string // External ID the role's trust policy requires (empty for none)
+}
+
+// WithRoleARN makes the scanner assume a role with the credentials of the config, to scan another account
+// The credentials are cached and the role assumed again before they expire.
+// arn: ARN of the role to assume
+// sessionName: Session name shown in CloudTrail (empty for awsconfig.DefaultRoleSessionName)
+func WithRoleARN(arn, sessionName string) ScannerOption {
+	return func(s *scannerSettings) {
+		s.roleARN = arn
+		s.sessionName = sessionName
+	}
+}
+
+// WithExternalID sets the external ID used with WithRoleARN, for roles that third parties assume
+func WithExternalID(externalID string) ScannerOption {
+	return func(s *scannerSettings) {
+		s.externalID = externalID
+	}
 }
 
 // NewScanner creates a new VPC scanner instance with the provided AWS configuration
 // cfg: AWS configuration containing credentials and region information
-func NewScanner(cfg aws.Config) *Scanner {
+// opts: Options such as WithRoleARN (none to use the credentials of cfg)
+func NewScanner(cfg aws.Config, opts ...ScannerOption) *Scanner {
+	var settings scannerSettings
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	if settings.roleARN != "" {
+		cfg = awsconfig.AssumeRoleSession(cfg, settings.roleARN, settings.sessionName, settings.externalID)
+	}
 	return &Scanner{
 		ec2Client: ec2.NewFromConfig(cfg),
+		region:    cfg.Region,
+	}
+}
+
+// SetCheckpoints makes the scanner checkpoint long paginations in store and resume them from it
+// store: Checkpoint store of the run (nil to not checkpoint)
+func (s *Scanner) SetCheckpoints(store *checkpoint.Store) {
+	s.checkpoints = store
+}
+
+// SetAccountID sets the account used in the ARNs of resources the API returns no owner for
+// accountID: Account of the credentials, from awsconfig.AccountID
+func (s *Scanner) SetAccountID(accountID string) {
+	s.accountID = accountID
+}
+
+// arn returns the ARN of a resource in the scanned region
+// resourceType: One of the arnbuild.Type* constants
+// ownerID: Account that owns the resource as reported by the API, empty to use the scanned account
+// id: Resource ID
+func (s *Scanner) arn(resourceType, ownerID, id string) string {
+	if ownerID == "" {
+		ownerID = s.accountID
 	}
+	return arnbuild.Build(resourceType, s.region, ownerID, id)
 }
 
 // GetVPCs retrieves information about all VPCs in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of VPCInfo structs containing VPC details, or error if the operation fails
+func (s *Scanner) GetVPCs(ctx context.Context, opts ...ScanOptions) ([]VPCInfo, error) {
+	return s.GetVPCsByID(ctx, nil, opts...)
+}
+
+// GetVPCsByID retrieves information about the given VPCs, for follow-up scans of peered VPCs in other regions
+// ctx: Context for the request, allowing for timeout and cancellation
+// vpcIDs: IDs of the VPCs to describe, nil for all VPCs
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of VPCInfo structs containing VPC details, or error if the operation fails
-func (s *Scanner) GetVPCs(ctx context.Context) ([]VPCInfo, error) {
-	// Prepare input for describing all VPCs (no filters applied)
-	input := &ec2.DescribeVpcsInput{}
+func (s *Scanner) GetVPCsByID(ctx context.Context, vpcIDs []string, opts ...ScanOptions) ([]VPCInfo, error) {
+	// Prepare input for describing the VPCs (all of them without IDs)
+	input := &ec2.DescribeVpcsInput{VpcIds: vpcIDs, Filters: tagFilters(opts)}
 
 	// Call AWS API to retrieve VPC information
 	result, err := s.ec2Client.DescribeVpcs(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe VPCs: %w", err)
+		return nil, newScanError("VPCs", "DescribeVpcs", err)
 	}
 
 	// Process each VPC from the API response
 	var vpcs []VPCInfo
 	for _, vpc := range result.Vpcs {
-		// Extract basic VPC information
+		// Extract basic VPC information; missing or unexpected values are recorded as data-quality warnings
+		vpcID := s.required("VPC", "", "VpcId", vpc.VpcId)
 		vpcInfo := VPCInfo{
-			VpcID:           aws.ToString(vpc.VpcId),
-			CidrBlock:       aws.ToString(vpc.CidrBlock),
-			State:           string(vpc.State),
-			IsDefault:       aws.ToBool(vpc.IsDefault),
-			DhcpOptionsID:   aws.ToString(vpc.DhcpOptionsId),
-			InstanceTenancy: string(vpc.InstanceTenancy),
-			Tags:            convertTags(vpc.Tags),
+			VpcID:               vpcID,
+			Arn:                 s.arn(arnbuild.TypeVPC, aws.ToString(vpc.OwnerId), vpcID),
+			Region:              s.region,
+			CidrBlock:           s.required("VPC", vpcID, "CidrBlock", vpc.CidrBlock),
+			State:               enumValue(s, "VPC", vpcID, "State", vpc.State),
+			IsDefault:           aws.ToBool(vpc.IsDefault),
+			DhcpOptionsID:       aws.ToString(vpc.DhcpOptionsId),
+			InstanceTenancy:     enumValue(s, "VPC", vpcID, "InstanceTenancy", vpc.InstanceTenancy),
+			Tags:                convertTags(vpc.Tags),
+			TagList:             convertTagList(vpc.Tags),
+			AssociateCidrBlocks: []string{},
+			Ipv6CidrBlocks:      []string{},
 		}
 
-		// Collect all associated CIDR blocks beyond the primary one
+		// Collect all associated CIDR blocks; disassociated blocks stay in the set for a while and are skipped
 		for _, cidr := range vpc.CidrBlockAssociationSet {
-			if cidr.CidrBlock != nil {
+			if cidr.CidrBlock != nil && (cidr.CidrBlockState == nil || cidrAssociated(string(cidr.CidrBlockState.State))) {
 				vpcInfo.AssociateCidrBlocks = append(vpcInfo.AssociateCidrBlocks, *cidr.CidrBlock)
 			}
 		}
+		for _, cidr := range vpc.Ipv6CidrBlockAssociationSet {
+			if cidr.Ipv6CidrBlock != nil && (cidr.Ipv6CidrBlockState == nil || cidrAssociated(string(cidr.Ipv6CidrBlockState.State))) {
+				vpcInfo.Ipv6CidrBlocks = append(vpcInfo.Ipv6CidrBlocks, *cidr.Ipv6CidrBlock)
+			}
+		}
 
 		vpcs = append(vpcs, vpcInfo)
 	}
@@ -194,33 +340,43 @@ func (s *Scanner) GetVPCs(ctx context.Context) ([]VPCInfo, error) {
 
 // GetSubnets retrieves information about all subnets across all VPCs in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of SubnetInfo structs containing subnet details, or error if the operation fails
-func (s *Scanner) GetSubnets(ctx context.Context) ([]SubnetInfo, error) {
-	// Prepare input for describing all subnets (no filters applied)
-	input := &ec2.DescribeSubnetsInput{}
+func (s *Scanner) GetSubnets(ctx context.Context, opts ...ScanOptions) ([]SubnetInfo, error) {
+	// Prepare input for describing all subnets (filtered by tag only with options)
+	input := &ec2.DescribeSubnetsInput{Filters: tagFilters(opts)}
 
 	// Call AWS API to retrieve subnet information
 	result, err := s.ec2Client.DescribeSubnets(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe subnets: %w", err)
+		return nil, newScanError("subnets", "DescribeSubnets", err)
 	}
 
 	// Process each subnet from the API response
 	var subnets []SubnetInfo
 	for _, subnet := range result.Subnets {
 		// Extract subnet information and convert AWS types to our struct format
+		subnetID := s.required("subnet", "", "SubnetId", subnet.SubnetId)
 		subnetInfo := SubnetInfo{
-			SubnetID:                    aws.ToString(subnet.SubnetId),
-			VpcID:                       aws.ToString(subnet.VpcId),
-			CidrBlock:                   aws.ToString(subnet.CidrBlock),
-			AvailabilityZone:            aws.ToString(subnet.AvailabilityZone),
+			SubnetID:                    subnetID,
+			Arn:                         aws.ToString(subnet.SubnetArn),
+			Region:                      s.region,
+			VpcID:                       s.required("subnet", subnetID, "VpcId", subnet.VpcId),
+			CidrBlock:                   s.required("subnet", subnetID, "CidrBlock", subnet.CidrBlock),
+			AvailabilityZone:            s.required("subnet", subnetID, "AvailabilityZone", subnet.AvailabilityZone),
 			AvailabilityZoneID:          aws.ToString(subnet.AvailabilityZoneId),
-			State:                       string(subnet.State),
+			State:                       enumValue(s, "subnet", subnetID, "State", subnet.State),
 			MapPublicIpOnLaunch:         aws.ToBool(subnet.MapPublicIpOnLaunch),
 			AssignIpv6AddressOnCreation: aws.ToBool(subnet.AssignIpv6AddressOnCreation),
 			DefaultForAz:                aws.ToBool(subnet.DefaultForAz),
 			Tags:                        convertTags(subnet.Tags),
+			TagList:                     convertTagList(subnet.Tags),
 		}
+		if subnet.AvailableIpAddressCount != nil {
+			available := int(*subnet.AvailableIpAddressCount)
+			subnetInfo.AvailableIpAddressCount = &available
+		}
+		subnetInfo.SetAddressUsage()
 		subnets = append(subnets, subnetInfo)
 	}
 
@@ -230,72 +386,105 @@ func (s *Scanner) GetSubnets(ctx context.Context) ([]SubnetInfo, error) {
 // GetSubnetsByVPC retrieves information about all subnets within a specific VPC
 // ctx: Context for the request, allowing for timeout and cancellation
 // vpcID: The unique identifier of the VPC to filter subnets by
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of SubnetInfo structs for subnets in the specified VPC, or error if the operation fails
-func (s *Scanner) GetSubnetsByVPC(ctx context.Context, vpcID string) ([]SubnetInfo, error) {
+func (s *Scanner) GetSubnetsByVPC(ctx context.Context, vpcID string, opts ...ScanOptions) ([]SubnetInfo, error) {
 	// Prepare input with VPC ID filter to retrieve only subnets in the specified VPC
 	input := &ec2.DescribeSubnetsInput{
-		Filters: []types.Filter{
+		Filters: append([]types.Filter{
 			{
 				Name:   aws.String("vpc-id"), // Filter by VPC ID
 				Values: []string{vpcID},
 			},
-		},
+		}, tagFilters(opts)...),
 	}
 
 	// Call AWS API to retrieve subnet information for the specific VPC
 	result, err := s.ec2Client.DescribeSubnets(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe subnets for VPC %s: %w", vpcID, err)
+		return nil, newScanError("subnets", "DescribeSubnets", fmt.Errorf("VPC %s: %w", vpcID, err))
 	}
 
 	// Process each subnet from the API response
 	var subnets []SubnetInfo
 	for _, subnet := range result.Subnets {
 		// Extract subnet information and convert AWS types to our struct format
+		subnetID := s.required("subnet", "", "SubnetId", subnet.SubnetId)
 		subnetInfo := SubnetInfo{
-			SubnetID:                    aws.ToString(subnet.SubnetId),
-			VpcID:                       aws.ToString(subnet.VpcId),
-			CidrBlock:                   aws.ToString(subnet.CidrBlock),
-			AvailabilityZone:            aws.ToString(subnet.AvailabilityZone),
+			SubnetID:                    subnetID,
+			Arn:                         aws.ToString(subnet.SubnetArn),
+			Region:                      s.region,
+			VpcID:                       s.required("subnet", subnetID, "VpcId", subnet.VpcId),
+			CidrBlock:                   s.required("subnet", subnetID, "CidrBlock", subnet.CidrBlock),
+			AvailabilityZone:            s.required("subnet", subnetID, "AvailabilityZone", subnet.AvailabilityZone),
 			AvailabilityZoneID:          aws.ToString(subnet.AvailabilityZoneId),
-			State:                       string(subnet.State),
+			State:                       enumValue(s, "subnet", subnetID, "State", subnet.State),
 			MapPublicIpOnLaunch:         aws.ToBool(subnet.MapPublicIpOnLaunch),
 			AssignIpv6AddressOnCreation: aws.ToBool(subnet.AssignIpv6AddressOnCreation),
 			DefaultForAz:                aws.ToBool(subnet.DefaultForAz),
 			Tags:                        convertTags(subnet.Tags),
+			TagList:                     convertTagList(subnet.Tags),
+		}
+		if subnet.AvailableIpAddressCount != nil {
+			available := int(*subnet.AvailableIpAddressCount)
+			subnetInfo.AvailableIpAddressCount = &available
 		}
+		subnetInfo.SetAddressUsage()
 		subnets = append(subnets, subnetInfo)
 	}
 
 	return subnets, nil
 }
 
+// SetAddressUsage sets the usable IPv4 addresses of a subnet from its CIDR block and the share of them in use
+// Both are derived from cidr_block and available_ip_address_count. Reports saved before they were written
+// do not have them, so code reading a saved report calls this to fill them in.
+func (subnet *SubnetInfo) SetAddressUsage() {
+	subnet.TotalIpAddressCount = netcalc.SubnetCapacity(subnet.CidrBlock)
+	if subnet.TotalIpAddressCount <= 0 || subnet.AvailableIpAddressCount == nil {
+		return
+	}
+	used := min(max(subnet.TotalIpAddressCount-*subnet.AvailableIpAddressCount, 0), subnet.TotalIpAddressCount)
+	subnet.UtilizationPercent = float64(used) / float64(subnet.TotalIpAddressCount) * 100
+}
+
 // GetRouteTables retrieves information about all route tables in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of RouteTableInfo structs containing route table details, or error if the operation fails
-func (s *Scanner) GetRouteTables(ctx context.Context) ([]RouteTableInfo, error) {
-	// Prepare input for describing all route tables (no filters applied)
-	input := &ec2.DescribeRouteTablesInput{}
+func (s *Scanner) GetRouteTables(ctx context.Context, opts ...ScanOptions) ([]RouteTableInfo, error) {
+	// Prepare input for describing all route tables (filtered by tag only with options)
+	input := &ec2.DescribeRouteTablesInput{Filters: tagFilters(opts)}
 
 	// Call AWS API to retrieve route table information
 	result, err := s.ec2Client.DescribeRouteTables(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe route tables: %w", err)
+		return nil, newScanError("route tables", "DescribeRouteTables", err)
 	}
 
 	// Process each route table from the API response
 	var routeTables []RouteTableInfo
 	for _, rt := range result.RouteTables {
 		// Extract basic route table information
+		routeTableID := s.required("route table", "", "RouteTableId", rt.RouteTableId)
 		routeTableInfo := RouteTableInfo{
-			RouteTableID:     aws.ToString(rt.RouteTableId),
-			VpcID:            aws.ToString(rt.VpcId),
+			RouteTableID:     routeTableID,
+			Arn:              s.arn(arnbuild.TypeRouteTable, aws.ToString(rt.OwnerId), routeTableID),
+			Region:           s.region,
+			VpcID:            s.required("route table", routeTableID, "VpcId", rt.VpcId),
 			IsMainRouteTable: false, // Will be determined by checking associations
 			Tags:             convertTags(rt.Tags),
+			TagList:          convertTagList(rt.Tags),
+			Routes:           []RouteInfo{},
+			SubnetIDs:        []string{},
 		}
 
 		// Process routes in the route table
 		for _, route := range rt.Routes {
+			routeID := routeTableID + " " + routeDestination(route)
+			if route.DestinationCidrBlock == nil && route.DestinationIpv6CidrBlock == nil && route.DestinationPrefixListId == nil {
+				s.warn("route", routeID, "DestinationCidrBlock", "", ProblemMissing)
+			}
 			routeInfo := RouteInfo{
 				DestinationCidrBlock:   aws.ToString(route.DestinationCidrBlock),
 				DestinationIpv6Block:   aws.ToString(route.DestinationIpv6CidrBlock),
@@ -304,9 +493,19 @@ func (s *Scanner) GetRouteTables(ctx context.Context) ([]RouteTableInfo, error)
 				NatGatewayID:           aws.ToString(route.NatGatewayId),
 				NetworkInterfaceID:     aws.ToString(route.NetworkInterfaceId),
 				TransitGatewayID:       aws.ToString(route.TransitGatewayId),
+				CarrierGatewayID:       aws.ToString(route.CarrierGatewayId),
 				VpcPeeringConnectionID: aws.ToString(route.VpcPeeringConnectionId),
-				State:                  string(route.State),
-				Origin:                 string(route.Origin),
+				CoreNetworkArn:         aws.ToString(route.CoreNetworkArn),
+				State:                  enumValue(s, "route", routeID, "State", route.State),
+				Origin:                 enumValue(s, "route", routeID, "Origin", route.Origin),
+				Target:                 resolveRouteTarget(route),
+			}
+			switch {
+			case routeInfo.Target.Type != RouteTargetUnknown:
+			case routeInfo.Target.ID != "":
+				s.warn("route", routeID, routeInfo.Target.Field, routeInfo.Target.ID, ProblemUnrecognized)
+			default:
+				s.warn("route", routeID, "Target", "", ProblemMissing)
 			}
 			routeTableInfo.Routes = append(routeTableInfo.Routes, routeInfo)
 		}
@@ -330,32 +529,39 @@ func (s *Scanner) GetRouteTables(ctx context.Context) ([]RouteTableInfo, error)
 
 // GetSecurityGroups retrieves information about all security groups in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of SecurityGroupInfo structs containing security group details, or error if the operation fails
-func (s *Scanner) GetSecurityGroups(ctx context.Context) ([]SecurityGroupInfo, error) {
-	// Prepare input for describing all security groups (no filters applied)
-	input := &ec2.DescribeSecurityGroupsInput{}
+func (s *Scanner) GetSecurityGroups(ctx context.Context, opts ...ScanOptions) ([]SecurityGroupInfo, error) {
+	// Prepare input for describing all security groups (filtered by tag only with options)
+	input := &ec2.DescribeSecurityGroupsInput{Filters: tagFilters(opts)}
 
 	// Call AWS API to retrieve security group information
 	result, err := s.ec2Client.DescribeSecurityGroups(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe security groups: %w", err)
+		return nil, newScanError("security groups", "DescribeSecurityGroups", err)
 	}
 
 	// Process each security group from the API response
 	var securityGroups []SecurityGroupInfo
 	for _, sg := range result.SecurityGroups {
-		// Extract basic security group information
+		// Extract basic security group information; groups created outside the console can lack a description
+		groupID := s.required("security group", "", "GroupId", sg.GroupId)
 		sgInfo := SecurityGroupInfo{
-			GroupID:     aws.ToString(sg.GroupId),
-			GroupName:   aws.ToString(sg.GroupName),
-			Description: aws.ToString(sg.Description),
-			VpcID:       aws.ToString(sg.VpcId),
+			GroupID:     groupID,
+			Arn:         s.arn(arnbuild.TypeSecurityGroup, aws.ToString(sg.OwnerId), groupID),
+			Region:      s.region,
+			GroupName:   s.required("security group", groupID, "GroupName", sg.GroupName),
+			Description: s.required("security group", groupID, "Description", sg.Description),
+			VpcID:       s.required("security group", groupID, "VpcId", sg.VpcId),
 			OwnerID:     aws.ToString(sg.OwnerId),
 			Tags:        convertTags(sg.Tags),
+			TagList:     convertTagList(sg.Tags),
+			Rules:       []SecurityGroupRule{},
 		}
 
 		// Process ingress rules
 		for _, rule := range sg.IpPermissions {
+			s.required("security group rule", groupID, "IpProtocol", rule.IpProtocol)
 			// Each rule can have multiple IP ranges/groups, so we create separate rule entries
 			for _, ipRange := range rule.IpRanges {
 				sgRule := SecurityGroupRule{
@@ -385,13 +591,16 @@ func (s *Scanner) GetSecurityGroups(ctx context.Context) ([]SecurityGroupInfo, e
 			// Process referenced security groups
 			for _, userIdGroupPair := range rule.UserIdGroupPairs {
 				sgRule := SecurityGroupRule{
-					IsEgress:     false,
-					IpProtocol:   aws.ToString(rule.IpProtocol),
-					FromPort:     aws.ToInt32(rule.FromPort),
-					ToPort:       aws.ToInt32(rule.ToPort),
-					GroupID:      aws.ToString(userIdGroupPair.GroupId),
-					GroupOwnerID: aws.ToString(userIdGroupPair.UserId),
-					Description:  aws.ToString(userIdGroupPair.Description),
+					IsEgress:               false,
+					IpProtocol:             aws.ToString(rule.IpProtocol),
+					FromPort:               aws.ToInt32(rule.FromPort),
+					ToPort:                 aws.ToInt32(rule.ToPort),
+					GroupID:                aws.ToString(userIdGroupPair.GroupId),
+					GroupOwnerID:           aws.ToString(userIdGroupPair.UserId),
+					GroupVpcID:             aws.ToString(userIdGroupPair.VpcId),
+					VpcPeeringConnectionID: aws.ToString(userIdGroupPair.VpcPeeringConnectionId),
+					PeeringStatus:          aws.ToString(userIdGroupPair.PeeringStatus),
+					Description:            aws.ToString(userIdGroupPair.Description),
 				}
 				sgInfo.Rules = append(sgInfo.Rules, sgRule)
 			}
@@ -412,6 +621,7 @@ func (s *Scanner) GetSecurityGroups(ctx context.Context) ([]SecurityGroupInfo, e
 
 		// Process egress rules (similar structure to ingress)
 		for _, rule := range sg.IpPermissionsEgress {
+			s.required("security group rule", groupID, "IpProtocol", rule.IpProtocol)
 			// Each rule can have multiple IP ranges/groups
 			for _, ipRange := range rule.IpRanges {
 				sgRule := SecurityGroupRule{
@@ -441,13 +651,16 @@ func (s *Scanner) GetSecurityGroups(ctx context.Context) ([]SecurityGroupInfo, e
 			// Process referenced security groups
 			for _, userIdGroupPair := range rule.UserIdGroupPairs {
 				sgRule := SecurityGroupRule{
-					IsEgress:     true,
-					IpProtocol:   aws.ToString(rule.IpProtocol),
-					FromPort:     aws.ToInt32(rule.FromPort),
-					ToPort:       aws.ToInt32(rule.ToPort),
-					GroupID:      aws.ToString(userIdGroupPair.GroupId),
-					GroupOwnerID: aws.ToString(userIdGroupPair.UserId),
-					Description:  aws.ToString(userIdGroupPair.Description),
+					IsEgress:               true,
+					IpProtocol:             aws.ToString(rule.IpProtocol),
+					FromPort:               aws.ToInt32(rule.FromPort),
+					ToPort:                 aws.ToInt32(rule.ToPort),
+					GroupID:                aws.ToString(userIdGroupPair.GroupId),
+					GroupOwnerID:           aws.ToString(userIdGroupPair.UserId),
+					GroupVpcID:             aws.ToString(userIdGroupPair.VpcId),
+					VpcPeeringConnectionID: aws.ToString(userIdGroupPair.VpcPeeringConnectionId),
+					PeeringStatus:          aws.ToString(userIdGroupPair.PeeringStatus),
+					Description:            aws.ToString(userIdGroupPair.Description),
 				}
 				sgInfo.Rules = append(sgInfo.Rules, sgRule)
 			}
@@ -466,6 +679,23 @@ func (s *Scanner) GetSecurityGroups(ctx context.Context) ([]SecurityGroupInfo, e
 			}
 		}
 
+		// Count rules per direction so groups without rules still report zero,
+		// and mark the default egress rules so reports can hide them
+		sgInfo.EgressRestricted = true
+		for i, rule := range sgInfo.Rules {
+			if rule.IsEgress {
+				sgInfo.EgressRuleCount++
+			} else {
+				sgInfo.IngressRuleCount++
+			}
+			if IsDefaultEgressRule(rule) {
+				sgInfo.Rules[i].IsDefaultEgress = true
+				if rule.CidrBlock != "" {
+					sgInfo.EgressRestricted = false
+				}
+			}
+		}
+
 		securityGroups = append(securityGroups, sgInfo)
 	}
 
@@ -474,32 +704,37 @@ func (s *Scanner) GetSecurityGroups(ctx context.Context) ([]SecurityGroupInfo, e
 
 // GetInternetGateways retrieves information about all internet gateways in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of InternetGatewayInfo structs containing internet gateway details, or error if the operation fails
-func (s *Scanner) GetInternetGateways(ctx context.Context) ([]InternetGatewayInfo, error) {
-	// Prepare input for describing all internet gateways (no filters applied)
-	input := &ec2.DescribeInternetGatewaysInput{}
+func (s *Scanner) GetInternetGateways(ctx context.Context, opts ...ScanOptions) ([]InternetGatewayInfo, error) {
+	// Prepare input for describing all internet gateways (filtered by tag only with options)
+	input := &ec2.DescribeInternetGatewaysInput{Filters: tagFilters(opts)}
 
 	// Call AWS API to retrieve internet gateway information
 	result, err := s.ec2Client.DescribeInternetGateways(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe internet gateways: %w", err)
+		return nil, newScanError("internet gateways", "DescribeInternetGateways", err)
 	}
 
 	// Process each internet gateway from the API response
 	var internetGateways []InternetGatewayInfo
 	for _, igw := range result.InternetGateways {
 		// Extract basic internet gateway information
+		igwID := s.required("internet gateway", "", "InternetGatewayId", igw.InternetGatewayId)
 		igwInfo := InternetGatewayInfo{
-			InternetGatewayID: aws.ToString(igw.InternetGatewayId),
+			InternetGatewayID: igwID,
+			Arn:               s.arn(arnbuild.TypeInternetGateway, aws.ToString(igw.OwnerId), igwID),
+			Region:            s.region,
 			Tags:              convertTags(igw.Tags),
+			TagList:           convertTagList(igw.Tags),
 		}
 
 		// Determine state and VPC association
 		if len(igw.Attachments) > 0 {
 			// Internet gateway is attached to a VPC
 			attachment := igw.Attachments[0] // IGW can only be attached to one VPC
-			igwInfo.State = string(attachment.State)
-			igwInfo.VpcID = aws.ToString(attachment.VpcId)
+			igwInfo.State = enumValue(s, "internet gateway", igwID, "Attachments.State", attachment.State)
+			igwInfo.VpcID = s.required("internet gateway", igwID, "Attachments.VpcId", attachment.VpcId)
 		} else {
 			// Internet gateway is not attached
 			igwInfo.State = "available"
@@ -513,28 +748,33 @@ func (s *Scanner) GetInternetGateways(ctx context.Context) ([]InternetGatewayInf
 
 // GetNatGateways retrieves information about all NAT gateways in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of NatGatewayInfo structs containing NAT gateway details, or error if the operation fails
-func (s *Scanner) GetNatGateways(ctx context.Context) ([]NatGatewayInfo, error) {
-	// Prepare input for describing all NAT gateways (no filters applied)
-	input := &ec2.DescribeNatGatewaysInput{}
+func (s *Scanner) GetNatGateways(ctx context.Context, opts ...ScanOptions) ([]NatGatewayInfo, error) {
+	// Prepare input for describing all NAT gateways (filtered by tag only with options)
+	input := &ec2.DescribeNatGatewaysInput{Filter: tagFilters(opts)}
 
 	// Call AWS API to retrieve NAT gateway information
 	result, err := s.ec2Client.DescribeNatGateways(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe NAT gateways: %w", err)
+		return nil, newScanError("NAT gateways", "DescribeNatGateways", err)
 	}
 
 	// Process each NAT gateway from the API response
 	var natGateways []NatGatewayInfo
 	for _, ngw := range result.NatGateways {
 		// Extract basic NAT gateway information
+		ngwID := s.required("NAT gateway", "", "NatGatewayId", ngw.NatGatewayId)
 		ngwInfo := NatGatewayInfo{
-			NatGatewayID:     aws.ToString(ngw.NatGatewayId),
-			SubnetID:         aws.ToString(ngw.SubnetId),
-			VpcID:            aws.ToString(ngw.VpcId),
-			State:            string(ngw.State),
-			ConnectivityType: string(ngw.ConnectivityType),
+			NatGatewayID:     ngwID,
+			Arn:              s.arn(arnbuild.TypeNatGateway, "", ngwID),
+			Region:           s.region,
+			SubnetID:         s.required("NAT gateway", ngwID, "SubnetId", ngw.SubnetId),
+			VpcID:            s.required("NAT gateway", ngwID, "VpcId", ngw.VpcId),
+			State:            enumValue(s, "NAT gateway", ngwID, "State", ngw.State),
+			ConnectivityType: enumValue(s, "NAT gateway", ngwID, "ConnectivityType", ngw.ConnectivityType),
 			Tags:             convertTags(ngw.Tags),
+			TagList:          convertTagList(ngw.Tags),
 		}
 
 		// Set creation time
@@ -566,27 +806,41 @@ func (s *Scanner) GetNatGateways(ctx context.Context) ([]NatGatewayInfo, error)
 
 // GetTransitGateways retrieves information about all transit gateways in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
 // Returns: Slice of TransitGatewayInfo structs containing transit gateway details, or error if the operation fails
-func (s *Scanner) GetTransitGateways(ctx context.Context) ([]TransitGatewayInfo, error) {
-	// Prepare input for describing all transit gateways (no filters applied)
-	input := &ec2.DescribeTransitGatewaysInput{}
+func (s *Scanner) GetTransitGateways(ctx context.Context, opts ...ScanOptions) ([]TransitGatewayInfo, error) {
+	return s.GetTransitGatewaysByID(ctx, nil, opts...)
+}
+
+// GetTransitGatewaysByID retrieves information about the given transit gateways, for follow-up scans of peer transit gateways
+// ctx: Context for the request, allowing for timeout and cancellation
+// transitGatewayIDs: IDs of the transit gateways to describe, nil for all transit gateways
+// opts: Tag filters the transit gateways must match (none for all); DescribeTransitGateways has no tag filter, so they are applied to the response
+// Returns: Slice of TransitGatewayInfo structs containing transit gateway details, or error if the operation fails
+func (s *Scanner) GetTransitGatewaysByID(ctx context.Context, transitGatewayIDs []string, opts ...ScanOptions) ([]TransitGatewayInfo, error) {
+	// Prepare input for describing the transit gateways (all of them without IDs)
+	input := &ec2.DescribeTransitGatewaysInput{TransitGatewayIds: transitGatewayIDs}
 
 	// Call AWS API to retrieve transit gateway information
 	result, err := s.ec2Client.DescribeTransitGateways(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe transit gateways: %w", err)
+		return nil, newScanError("transit gateways", "DescribeTransitGateways", err)
 	}
 
 	// Process each transit gateway from the API response
 	var transitGateways []TransitGatewayInfo
 	for _, tgw := range result.TransitGateways {
 		// Extract basic transit gateway information
+		tgwID := s.required("transit gateway", "", "TransitGatewayId", tgw.TransitGatewayId)
 		tgwInfo := TransitGatewayInfo{
-			TransitGatewayID: aws.ToString(tgw.TransitGatewayId),
-			State:            string(tgw.State),
+			TransitGatewayID: tgwID,
+			Arn:              aws.ToString(tgw.TransitGatewayArn),
+			Region:           s.region,
+			State:            enumValue(s, "transit gateway", tgwID, "State", tgw.State),
 			OwnerID:          aws.ToString(tgw.OwnerId),
 			Description:      aws.ToString(tgw.Description),
 			Tags:             convertTags(tgw.Tags),
+			TagList:          convertTagList(tgw.Tags),
 		}
 
 		// Set creation time
@@ -594,6 +848,10 @@ func (s *Scanner) GetTransitGateways(ctx context.Context) ([]TransitGatewayInfo,
 			tgwInfo.CreationTime = tgw.CreationTime.Format("2006-01-02T15:04:05Z")
 		}
 
+		if !matchesTags(tgwInfo.Tags, opts) {
+			continue
+		}
+
 		// Process transit gateway options
 		if tgw.Options != nil {
 			options := tgw.Options
@@ -615,30 +873,39 @@ func (s *Scanner) GetTransitGateways(ctx context.Context) ([]TransitGatewayInfo,
 
 // GetTransitGatewayAttachments retrieves information about all transit gateway attachments in the configured AWS region
 // ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the attachments must match (none for all); DescribeTransitGatewayAttachments has no tag filter, so they are applied to the response
 // Returns: Slice of TransitGatewayAttachmentInfo structs containing attachment details, or error if the operation fails
-func (s *Scanner) GetTransitGatewayAttachments(ctx context.Context) ([]TransitGatewayAttachmentInfo, error) {
+func (s *Scanner) GetTransitGatewayAttachments(ctx context.Context, opts ...ScanOptions) ([]TransitGatewayAttachmentInfo, error) {
 	// Prepare input for describing all transit gateway attachments (no filters applied)
 	input := &ec2.DescribeTransitGatewayAttachmentsInput{}
 
 	// Call AWS API to retrieve transit gateway attachment information
 	result, err := s.ec2Client.DescribeTransitGatewayAttachments(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe transit gateway attachments: %w", err)
+		return nil, newScanError("transit gateway attachments", "DescribeTransitGatewayAttachments", err)
 	}
 
 	// Process each attachment from the API response
 	var attachments []TransitGatewayAttachmentInfo
 	for _, attachment := range result.TransitGatewayAttachments {
 		// Extract basic attachment information
+		attachmentID := s.required("transit gateway attachment", "", "TransitGatewayAttachmentId", attachment.TransitGatewayAttachmentId)
 		attachmentInfo := TransitGatewayAttachmentInfo{
-			AttachmentID:     aws.ToString(attachment.TransitGatewayAttachmentId),
-			TransitGatewayID: aws.ToString(attachment.TransitGatewayId),
-			ResourceType:     string(attachment.ResourceType),
+			AttachmentID:     attachmentID,
+			Arn:              s.arn(arnbuild.TypeTransitGatewayAttachment, "", attachmentID),
+			Region:           s.region,
+			TransitGatewayID: s.required("transit gateway attachment", attachmentID, "TransitGatewayId", attachment.TransitGatewayId),
+			ResourceType:     enumValue(s, "transit gateway attachment", attachmentID, "ResourceType", attachment.ResourceType),
 			ResourceID:       aws.ToString(attachment.ResourceId),
 			ResourceOwnerID:  aws.ToString(attachment.ResourceOwnerId),
-			State:            string(attachment.State),
+			State:            enumValue(s, "transit gateway attachment", attachmentID, "State", attachment.State),
 			Tags:             convertTags(attachment.Tags),
+			TagList:          convertTagList(attachment.Tags),
 			Association:      make(map[string]string),
+			SubnetIDs:        []string{},
+		}
+		if !matchesTags(attachmentInfo.Tags, opts) {
+			continue
 		}
 
 		// Set creation time
@@ -656,9 +923,30 @@ func (s *Scanner) GetTransitGatewayAttachments(ctx context.Context) ([]TransitGa
 		attachments = append(attachments, attachmentInfo)
 	}
 
+	// Add subnets and appliance mode, which are needed to follow traffic through inspection VPCs
+	if err := s.enrichVpcAttachments(ctx, attachments); err != nil {
+		return nil, err
+	}
+	// Peering attachments get the peer region, so peers in regions that were not scanned can be found;
+	// without the permission the peer regions stay empty
+	if err := s.enrichPeeringAttachments(ctx, attachments); err != nil && !errors.Is(err, ErrAccessDenied) {
+		return nil, err
+	}
+
 	return attachments, nil
 }
 
+// IsDefaultEgressRule reports whether a rule is the allow-all egress rule AWS creates with every security group
+// The IPv4 rule allows all protocols to 0.0.0.0/0; VPCs with IPv6 also get a twin to ::/0
+// rule: Security group rule to check
+// Returns: True for either default egress rule
+func IsDefaultEgressRule(rule SecurityGroupRule) bool {
+	if !rule.IsEgress || rule.IpProtocol != "-1" || rule.GroupID != "" || rule.PrefixListID != "" {
+		return false
+	}
+	return (rule.CidrBlock == "0.0.0.0/0" && rule.Ipv6CidrBlock == "") || (rule.Ipv6CidrBlock == "::/0" && rule.CidrBlock == "")
+}
+
 // convertTags converts AWS tag format to a simple key-value map
 // tags: Slice of AWS Tag structs containing Key and Value pointers
 // Returns: Map of string keys to string values, skipping any nil keys or values
@@ -672,3 +960,9 @@ func convertTags(tags []types.Tag) map[string]string {
 	}
 	return result
 }
+
+// cidrAssociated reports whether a CIDR block association state counts as associated
+// state: The VpcCidrBlockStateCode (associating, associated, disassociating, disassociated, failing, failed)
+func cidrAssociated(state string) bool {
+	return state == "associated" || state == "associating"
+}
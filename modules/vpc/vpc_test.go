@@ -0,0 +1,127 @@
+package vpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestIsDefaultEgressRule checks that only the allow-all egress rules to 0.0.0.0/0 and ::/0 are default rules
+func TestIsDefaultEgressRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule SecurityGroupRule
+		want bool
+	}{
+		{name: "IPv4 default", rule: SecurityGroupRule{IsEgress: true, IpProtocol: "-1", CidrBlock: "0.0.0.0/0"}, want: true},
+		{name: "IPv6 default", rule: SecurityGroupRule{IsEgress: true, IpProtocol: "-1", Ipv6CidrBlock: "::/0"}, want: true},
+		{name: "ingress from anywhere", rule: SecurityGroupRule{IpProtocol: "-1", CidrBlock: "0.0.0.0/0"}},
+		{name: "HTTPS anywhere", rule: SecurityGroupRule{IsEgress: true, IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"}},
+		{name: "VPC CIDR", rule: SecurityGroupRule{IsEgress: true, IpProtocol: "-1", CidrBlock: "10.0.0.0/16"}},
+		{name: "group", rule: SecurityGroupRule{IsEgress: true, IpProtocol: "-1", GroupID: "sg-0db"}},
+		{name: "prefix list", rule: SecurityGroupRule{IsEgress: true, IpProtocol: "-1", PrefixListID: "pl-0s3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDefaultEgressRule(tt.rule); got != tt.want {
+				t.Errorf("IsDefaultEgressRule(%+v) = %v, want %v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+// defaultEgressGroups is the DescribeSecurityGroups result of a group with only the default egress rules, one
+// with extra egress rules next to them and one whose default rule was removed
+const defaultEgressGroups = `<securityGroupInfo>
+<item><ownerId>111122223333</ownerId><groupId>sg-0default</groupId><groupName>default</groupName><groupDescription>default VPC security group</groupDescription><vpcId>vpc-0a1</vpcId>
+	<ipPermissions/>
+	<ipPermissionsEgress><item><ipProtocol>-1</ipProtocol>
+		<ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges>
+		<ipv6Ranges><item><cidrIpv6>::/0</cidrIpv6></item></ipv6Ranges></item></ipPermissionsEgress></item>
+<item><ownerId>111122223333</ownerId><groupId>sg-0web</groupId><groupName>web</groupName><groupDescription>web servers</groupDescription><vpcId>vpc-0a1</vpcId>
+	<ipPermissions><item><ipProtocol>tcp</ipProtocol><fromPort>443</fromPort><toPort>443</toPort>
+		<ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges></item></ipPermissions>
+	<ipPermissionsEgress><item><ipProtocol>-1</ipProtocol>
+		<ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges></item>
+		<item><ipProtocol>tcp</ipProtocol><fromPort>5432</fromPort><toPort>5432</toPort>
+		<groups><item><groupId>sg-0db</groupId><userId>111122223333</userId></item></groups></item></ipPermissionsEgress></item>
+<item><ownerId>111122223333</ownerId><groupId>sg-0locked</groupId><groupName>locked</groupName><groupDescription>egress to the VPC only</groupDescription><vpcId>vpc-0a1</vpcId>
+	<ipPermissions/>
+	<ipPermissionsEgress><item><ipProtocol>-1</ipProtocol>
+		<ipRanges><item><cidrIp>10.0.0.0/16</cidrIp></item></ipRanges>
+		<ipv6Ranges><item><cidrIpv6>::/0</cidrIpv6></item></ipv6Ranges></item></ipPermissionsEgress></item>
+</securityGroupInfo>`
+
+// TestGetSecurityGroupsDefaultEgress checks that the default egress rules are marked on groups with only the
+// default rules and with extra rules, and that groups without the IPv4 default rule are egress restricted
+func TestGetSecurityGroupsDefaultEgress(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeSecurityGroups": defaultEgressGroups}, 0)
+	groups, err := scanner.GetSecurityGroups(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		groupID        string
+		wantDefault    []bool // IsDefaultEgress of each rule, in scan order
+		wantIngress    int
+		wantEgress     int
+		wantRestricted bool
+	}{
+		{groupID: "sg-0default", wantDefault: []bool{true, true}, wantEgress: 2},
+		{groupID: "sg-0web", wantDefault: []bool{false, true, false}, wantIngress: 1, wantEgress: 2},
+		// The IPv6 twin stays a default rule, but without the IPv4 rule the group is restricted
+		{groupID: "sg-0locked", wantDefault: []bool{false, true}, wantEgress: 2, wantRestricted: true},
+	}
+	if len(groups) != len(tests) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.groupID, func(t *testing.T) {
+			sg := groups[i]
+			if sg.GroupID != tt.groupID {
+				t.Fatalf("group %d = %s, want %s", i, sg.GroupID, tt.groupID)
+			}
+			if len(sg.Rules) != len(tt.wantDefault) {
+				t.Fatalf("got %d rules, want %d: %+v", len(sg.Rules), len(tt.wantDefault), sg.Rules)
+			}
+			for j, rule := range sg.Rules {
+				if rule.IsDefaultEgress != tt.wantDefault[j] {
+					t.Errorf("rule %d (%+v) IsDefaultEgress = %v, want %v", j, rule, rule.IsDefaultEgress, tt.wantDefault[j])
+				}
+			}
+			if sg.IngressRuleCount != tt.wantIngress || sg.EgressRuleCount != tt.wantEgress {
+				t.Errorf("rule counts = %d ingress, %d egress, want %d, %d", sg.IngressRuleCount, sg.EgressRuleCount, tt.wantIngress, tt.wantEgress)
+			}
+			if sg.EgressRestricted != tt.wantRestricted {
+				t.Errorf("EgressRestricted = %v, want %v", sg.EgressRestricted, tt.wantRestricted)
+			}
+		})
+	}
+}
+
+// TestGetVPCsCidrBlocks checks that secondary and IPv6 blocks are recorded and disassociated blocks are skipped
+func TestGetVPCsCidrBlocks(t *testing.T) {
+	scanner := newTestScanner(t, map[string]string{"DescribeVpcs": `<vpcSet><item><vpcId>vpc-0b2</vpcId><cidrBlock>10.1.0.0/16</cidrBlock><state>available</state>
+		<cidrBlockAssociationSet>
+			<item><cidrBlock>10.1.0.0/16</cidrBlock><associationId>vpc-cidr-assoc-01</associationId><cidrBlockState><state>associated</state></cidrBlockState></item>
+			<item><cidrBlock>100.64.0.0/16</cidrBlock><associationId>vpc-cidr-assoc-02</associationId><cidrBlockState><state>associated</state></cidrBlockState></item>
+			<item><cidrBlock>172.16.0.0/16</cidrBlock><associationId>vpc-cidr-assoc-03</associationId><cidrBlockState><state>disassociated</state></cidrBlockState></item>
+		</cidrBlockAssociationSet>
+		<ipv6CidrBlockAssociationSet>
+			<item><ipv6CidrBlock>2001:db8:1234:1a00::/56</ipv6CidrBlock><associationId>vpc-cidr-assoc-04</associationId><ipv6CidrBlockState><state>associated</state></ipv6CidrBlockState></item>
+		</ipv6CidrBlockAssociationSet></item></vpcSet>`}, 0)
+	vpcs, err := scanner.GetVPCs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vpcs) != 1 {
+		t.Fatalf("got %d VPCs, want 1", len(vpcs))
+	}
+	if want := []string{"10.1.0.0/16", "100.64.0.0/16"}; !reflect.DeepEqual(vpcs[0].AssociateCidrBlocks, want) {
+		t.Errorf("AssociateCidrBlocks = %v, want %v", vpcs[0].AssociateCidrBlocks, want)
+	}
+	if want := []string{"2001:db8:1234:1a00::/56"}; !reflect.DeepEqual(vpcs[0].Ipv6CidrBlocks, want) {
+		t.Errorf("Ipv6CidrBlocks = %v, want %v", vpcs[0].Ipv6CidrBlocks, want)
+	}
+}
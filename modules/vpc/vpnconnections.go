@@ -0,0 +1,138 @@
+package vpc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// TunnelStatusUp is the status of a VPN tunnel that is established
+const TunnelStatusUp = "UP"
+
+// VpnConnectionInfo contains information about a site-to-site VPN connection between a customer gateway and a
+// virtual private gateway or transit gateway
+// The customer gateway configuration the API returns holds the pre-shared keys of the tunnels and is never kept.
+type VpnConnectionInfo struct {
+	VpnConnectionID   string               `json:"vpn_connection_id"`   // Unique identifier for the VPN connection
+	Arn               string               `json:"arn"`                 // ARN of the VPN connection
+	Region            string               `json:"region"`              // Region of the VPN connection
+	State             string               `json:"state"`               // State of the connection (pending, available, deleting, deleted)
+	Type              string               `json:"type"`                // Type of the connection (ipsec.1)
+	Category          string               `json:"category"`            // VPN for current connections, VPN-Classic for legacy ones
+	CustomerGatewayID string               `json:"customer_gateway_id"` // Customer gateway at the on-premises end
+	VpnGatewayID      string               `json:"vpn_gateway_id"`      // Virtual private gateway at the AWS end (empty for transit gateways)
+	TransitGatewayID  string               `json:"transit_gateway_id"`  // Transit gateway at the AWS end (empty for virtual private gateways)
+	StaticRoutesOnly  bool                 `json:"static_routes_only"`  // Whether the connection uses static routes instead of BGP
+	Tunnels           []VpnTunnelInfo      `json:"tunnels"`             // Telemetry of the two tunnels, by outside IP address
+	StaticRoutes      []VpnStaticRouteInfo `json:"static_routes"`       // Static routes toward the customer gateway
+	Tags              map[string]string    `json:"tags"`                // Key-value tags associated with the VPN connection
+	TagList           []Tag                `json:"tag_list"`            // Tags in API order, including tags without a value
+	ManagedBy         string               `json:"managed_by"`          // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// VpnTunnelInfo is the telemetry of one tunnel of a VPN connection
+type VpnTunnelInfo struct {
+	OutsideIPAddress   string `json:"outside_ip_address"`   // Public IP address of the AWS end of the tunnel
+	Status             string `json:"status"`               // UP or DOWN
+	StatusMessage      string `json:"status_message"`       // Why the tunnel is in its status, such as the number of BGP routes (empty if none)
+	LastStatusChange   string `json:"last_status_change"`   // Time the status last changed (empty if unknown)
+	AcceptedRouteCount int32  `json:"accepted_route_count"` // Routes the AWS end accepted over the tunnel
+	CertificateArn     string `json:"certificate_arn"`      // Certificate the tunnel authenticates with (empty with pre-shared keys)
+	InsideCidr         string `json:"inside_cidr"`          // IPv4 inside CIDR of the tunnel, a /30 of 169.254.0.0/16 (empty for IPv6 tunnels)
+	InsideIpv6Cidr     string `json:"inside_ipv6_cidr"`     // IPv6 inside CIDR of the tunnel (empty for IPv4 tunnels)
+	PreSharedKeySet    bool   `json:"pre_shared_key_set"`   // Whether the tunnel authenticates with a pre-shared key; the key itself is never recorded
+}
+
+// VpnStaticRouteInfo is a static route of a VPN connection
+type VpnStaticRouteInfo struct {
+	DestinationCidrBlock string `json:"destination_cidr_block"` // On-premises range routed over the connection
+	Source               string `json:"source"`                 // Where the route came from (Static)
+	State                string `json:"state"`                  // State of the route (pending, available, deleting, deleted)
+}
+
+// GetVpnConnections retrieves information about all site-to-site VPN connections in the configured AWS region
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of VpnConnectionInfo structs with the telemetry of their tunnels, or error if the operation fails
+func (s *Scanner) GetVpnConnections(ctx context.Context, opts ...ScanOptions) ([]VpnConnectionInfo, error) {
+	// DescribeVpnConnections is not paginated
+	result, err := s.ec2Client.DescribeVpnConnections(ctx, &ec2.DescribeVpnConnectionsInput{Filters: tagFilters(opts)})
+	if err != nil {
+		return nil, newScanError("VPN connections", "DescribeVpnConnections", err)
+	}
+
+	connections := []VpnConnectionInfo{}
+	for _, conn := range result.VpnConnections {
+		connID := s.required("VPN connection", "", "VpnConnectionId", conn.VpnConnectionId)
+		info := VpnConnectionInfo{
+			VpnConnectionID:   connID,
+			Arn:               s.arn(arnbuild.TypeVpnConnection, "", connID),
+			Region:            s.region,
+			State:             enumValue(s, "VPN connection", connID, "State", conn.State),
+			Type:              enumValue(s, "VPN connection", connID, "Type", conn.Type),
+			Category:          aws.ToString(conn.Category),
+			CustomerGatewayID: s.required("VPN connection", connID, "CustomerGatewayId", conn.CustomerGatewayId),
+			VpnGatewayID:      aws.ToString(conn.VpnGatewayId),
+			TransitGatewayID:  aws.ToString(conn.TransitGatewayId),
+			Tunnels:           []VpnTunnelInfo{},
+			StaticRoutes:      []VpnStaticRouteInfo{},
+			Tags:              convertTags(conn.Tags),
+			TagList:           convertTagList(conn.Tags),
+		}
+		// Tunnel options and telemetry describe the same tunnels, matched by outside IP address
+		options := make(map[string]types.TunnelOption)
+		if conn.Options != nil {
+			info.StaticRoutesOnly = aws.ToBool(conn.Options.StaticRoutesOnly)
+			for _, option := range conn.Options.TunnelOptions {
+				options[aws.ToString(option.OutsideIpAddress)] = option
+			}
+		}
+
+		for _, telemetry := range conn.VgwTelemetry {
+			tunnel := VpnTunnelInfo{
+				OutsideIPAddress:   s.required("VPN connection", connID, "VgwTelemetry.OutsideIpAddress", telemetry.OutsideIpAddress),
+				Status:             enumValue(s, "VPN connection", connID, "VgwTelemetry.Status", telemetry.Status),
+				StatusMessage:      aws.ToString(telemetry.StatusMessage),
+				AcceptedRouteCount: aws.ToInt32(telemetry.AcceptedRouteCount),
+				CertificateArn:     aws.ToString(telemetry.CertificateArn),
+			}
+			if telemetry.LastStatusChange != nil {
+				tunnel.LastStatusChange = telemetry.LastStatusChange.Format("2006-01-02T15:04:05Z")
+			}
+			if option, ok := options[tunnel.OutsideIPAddress]; ok {
+				tunnel.InsideCidr = aws.ToString(option.TunnelInsideCidr)
+				tunnel.InsideIpv6Cidr = aws.ToString(option.TunnelInsideIpv6Cidr)
+				tunnel.PreSharedKeySet = aws.ToString(option.PreSharedKey) != ""
+			}
+			info.Tunnels = append(info.Tunnels, tunnel)
+		}
+		sort.Slice(info.Tunnels, func(i, j int) bool { return info.Tunnels[i].OutsideIPAddress < info.Tunnels[j].OutsideIPAddress })
+
+		for _, route := range conn.Routes {
+			info.StaticRoutes = append(info.StaticRoutes, VpnStaticRouteInfo{
+				DestinationCidrBlock: s.required("VPN connection", connID, "Routes.DestinationCidrBlock", route.DestinationCidrBlock),
+				Source:               string(route.Source),
+				State:                enumValue(s, "VPN connection", connID, "Routes.State", route.State),
+			})
+		}
+		connections = append(connections, info)
+	}
+
+	return connections, nil
+}
+
+// TunnelsUp counts the tunnels of a VPN connection whose status is UP
+func (c VpnConnectionInfo) TunnelsUp() int {
+	up := 0
+	for _, tunnel := range c.Tunnels {
+		if tunnel.Status == TunnelStatusUp {
+			up++
+		}
+	}
+	return up
+}
@@ -0,0 +1,70 @@
+package vpc
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"aws-documentor/modules/arnbuild"
+)
+
+// VpnGatewayInfo contains information about a virtual private gateway, the VPN and Direct Connect end of a VPC
+type VpnGatewayInfo struct {
+	VpnGatewayID     string                 `json:"vpn_gateway_id"`    // Unique identifier for the virtual private gateway
+	Arn              string                 `json:"arn"`               // ARN of the gateway
+	Region           string                 `json:"region"`            // Region of the gateway
+	State            string                 `json:"state"`             // State of the gateway (pending, available, deleting, deleted)
+	Type             string                 `json:"type"`              // Type of VPN connection the gateway supports (ipsec.1)
+	AmazonSideAsn    int64                  `json:"amazon_side_asn"`   // BGP ASN of the Amazon side
+	VpcAttachments   []VpnGatewayAttachment `json:"vpc_attachments"`   // VPCs the gateway is or was attached to
+	AvailabilityZone string                 `json:"availability_zone"` // Availability zone of the gateway (empty unless the API reports one)
+	Tags             map[string]string      `json:"tags"`              // Key-value tags associated with the gateway
+	TagList          []Tag                  `json:"tag_list"`          // Tags in API order, including tags without a value
+	ManagedBy        string                 `json:"managed_by"`        // Tool or controller that created the resource (eks-lb-controller, terraform, manual, ...; empty in older reports)
+}
+
+// VpnGatewayAttachment is the attachment of a virtual private gateway to a VPC
+type VpnGatewayAttachment struct {
+	VpcID string `json:"vpc_id"` // ID of the VPC
+	State string `json:"state"`  // State of the attachment (attaching, attached, detaching, detached)
+}
+
+// GetVpnGateways retrieves information about all virtual private gateways in the configured AWS region
+// Routes to vgw-* targets and route propagation from the gateway lead here.
+// ctx: Context for the request, allowing for timeout and cancellation
+// opts: Tag filters the resources must match (none for all resources)
+// Returns: Slice of VpnGatewayInfo structs with their VPC attachments, or error if the operation fails
+func (s *Scanner) GetVpnGateways(ctx context.Context, opts ...ScanOptions) ([]VpnGatewayInfo, error) {
+	// DescribeVpnGateways is not paginated
+	result, err := s.ec2Client.DescribeVpnGateways(ctx, &ec2.DescribeVpnGatewaysInput{Filters: tagFilters(opts)})
+	if err != nil {
+		return nil, newScanError("virtual private gateways", "DescribeVpnGateways", err)
+	}
+
+	gateways := []VpnGatewayInfo{}
+	for _, vgw := range result.VpnGateways {
+		vgwID := s.required("virtual private gateway", "", "VpnGatewayId", vgw.VpnGatewayId)
+		info := VpnGatewayInfo{
+			VpnGatewayID:     vgwID,
+			Arn:              s.arn(arnbuild.TypeVpnGateway, "", vgwID),
+			Region:           s.region,
+			State:            enumValue(s, "virtual private gateway", vgwID, "State", vgw.State),
+			Type:             enumValue(s, "virtual private gateway", vgwID, "Type", vgw.Type),
+			AmazonSideAsn:    aws.ToInt64(vgw.AmazonSideAsn),
+			VpcAttachments:   []VpnGatewayAttachment{},
+			AvailabilityZone: aws.ToString(vgw.AvailabilityZone),
+			Tags:             convertTags(vgw.Tags),
+			TagList:          convertTagList(vgw.Tags),
+		}
+		for _, attachment := range vgw.VpcAttachments {
+			info.VpcAttachments = append(info.VpcAttachments, VpnGatewayAttachment{
+				VpcID: s.required("virtual private gateway", vgwID, "VpcAttachments.VpcId", attachment.VpcId),
+				State: enumValue(s, "virtual private gateway", vgwID, "VpcAttachments.State", attachment.State),
+			})
+		}
+		gateways = append(gateways, info)
+	}
+
+	return gateways, nil
+}
@@ -0,0 +1,177 @@
+package whatsnew
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+
+	"aws-documentor/modules/lifecycle"
+	"aws-documentor/modules/vpc"
+)
+
+// TrailScanner provides methods for retrieving network changes from the CloudTrail event history
+type TrailScanner struct {
+	client *cloudtrail.Client // AWS CloudTrail client for making API calls
+}
+
+// NewTrailScanner creates a new CloudTrail scanner instance with the provided AWS configuration
+// cfg: AWS configuration containing credentials and region settings
+func NewTrailScanner(cfg aws.Config) *TrailScanner {
+	return &TrailScanner{
+		client: cloudtrail.NewFromConfig(cfg),
+	}
+}
+
+// GetNetworkEvents retrieves the EC2 write calls of the window that name a network resource
+// The event history covers the last 90 days of management events in the region, whether or not a trail
+// is configured. Read-only calls and calls on other resources (instances, volumes) are skipped.
+// ctx: Context for the request, allowing for timeout and cancellation
+// since: Start of the window
+// until: End of the window
+// Returns: Events in the order CloudTrail returns them (newest first), or error if the lookup fails
+func (s *TrailScanner) GetNetworkEvents(ctx context.Context, since, until time.Time) ([]TrailEvent, error) {
+	return s.lookupEvents(ctx, since, until, func(eventName, id string) bool {
+		_, ok := resourceTypeOf(id)
+		return ok
+	})
+}
+
+// GetCreationEvents retrieves the EC2 calls of the window that created a resource
+// These are the Create* calls and RunInstances, with every resource they name, instances and VPN
+// connections included. Like GetNetworkEvents, it only reaches back 90 days.
+// ctx: Context for the request, allowing for timeout and cancellation
+// since: Start of the window
+// until: End of the window
+// Returns: Events in the order CloudTrail returns them (newest first), or error if the lookup fails
+func (s *TrailScanner) GetCreationEvents(ctx context.Context, since, until time.Time) ([]TrailEvent, error) {
+	return s.lookupEvents(ctx, since, until, func(eventName, id string) bool {
+		return id != "" && (strings.HasPrefix(eventName, "Create") || eventName == "RunInstances")
+	})
+}
+
+// lookupEvents retrieves the EC2 write calls of the window, keeping the resources a call names that keep accepts
+// Calls that name no kept resource are skipped.
+func (s *TrailScanner) lookupEvents(ctx context.Context, since, until time.Time, keep func(eventName, id string) bool) ([]TrailEvent, error) {
+	events := []TrailEvent{}
+
+	paginator := cloudtrail.NewLookupEventsPaginator(s.client, &cloudtrail.LookupEventsInput{
+		LookupAttributes: []types.LookupAttribute{{
+			AttributeKey:   types.LookupAttributeKeyEventSource,
+			AttributeValue: aws.String("ec2.amazonaws.com"),
+		}},
+		StartTime: aws.Time(since),
+		EndTime:   aws.Time(until),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, vpc.NewServiceScanError("cloudtrail", "CloudTrail events", "LookupEvents", err)
+		}
+		for _, event := range page.Events {
+			if aws.ToString(event.ReadOnly) == "true" {
+				continue
+			}
+			var ids []string
+			for _, resource := range event.Resources {
+				if id := aws.ToString(resource.ResourceName); keep(aws.ToString(event.EventName), id) {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			events = append(events, TrailEvent{
+				Time:        aws.ToTime(event.EventTime),
+				EventName:   aws.ToString(event.EventName),
+				Principal:   principal(event),
+				ResourceIDs: ids,
+			})
+		}
+	}
+
+	return events, nil
+}
+
+// creationCalls maps the calls that create a resource to the ID prefix of the resource they create
+// A call names other resources too, such as the VPC of a new subnet, which it did not create.
+var creationCalls = map[string]string{
+	"CreateVpc":                             "vpc-",
+	"CreateDefaultVpc":                      "vpc-",
+	"CreateSubnet":                          "subnet-",
+	"CreateDefaultSubnet":                   "subnet-",
+	"CreateRouteTable":                      "rtb-",
+	"CreateSecurityGroup":                   "sg-",
+	"CreateInternetGateway":                 "igw-",
+	"CreateNatGateway":                      "nat-",
+	"CreateTransitGateway":                  "tgw-",
+	"CreateTransitGatewayVpcAttachment":     "tgw-attach-",
+	"CreateTransitGatewayPeeringAttachment": "tgw-attach-",
+	"CreateVpcPeeringConnection":            "pcx-",
+	"CreateVpnConnection":                   "vpn-",
+	"CreateVpcEndpoint":                     "vpce-",
+	"RunInstances":                          "i-",
+}
+
+// CreationSource resolves creation times for the lifecycle summary from the calls that created resources in the CloudTrail event history
+// The event history only reaches back 90 days, so older resources stay unknown.
+type CreationSource struct {
+	created map[string]time.Time // Time of the earliest creation call per resource ID
+}
+
+// NewCreationSource indexes the creation calls of the event history by the resource they created
+// events: Calls from GetCreationEvents
+func NewCreationSource(events []TrailEvent) *CreationSource {
+	source := &CreationSource{created: make(map[string]time.Time)}
+	for _, event := range events {
+		prefix, ok := creationCalls[event.EventName]
+		if !ok {
+			continue
+		}
+		for _, id := range event.ResourceIDs {
+			if idPrefix(id) != prefix {
+				continue
+			}
+			if at, seen := source.created[id]; !seen || event.Time.Before(at) {
+				source.created[id] = event.Time
+			}
+		}
+	}
+	return source
+}
+
+// idPrefix returns the part of a resource ID up to its last dash
+// The whole prefix counts: tgw-attach-0a1 is not a transit gateway although it starts with tgw-.
+func idPrefix(id string) string {
+	return id[:strings.LastIndex(id, "-")+1]
+}
+
+// Name returns the source identifier recorded on resolved ages
+func (s *CreationSource) Name() string {
+	return lifecycle.SourceCloudTrail
+}
+
+// CreationTime returns the time of the call that created a resource and whether the history has it
+func (s *CreationSource) CreationTime(resourceType, resourceID string) (time.Time, bool) {
+	created, ok := s.created[resourceID]
+	return created, ok
+}
+
+// principal returns the ARN of the caller, falling back to the user name
+// Events of assumed roles have the session name as user name, so the ARN from the event record
+// identifies the caller better.
+func principal(event types.Event) string {
+	var record struct {
+		UserIdentity struct {
+			Arn string `json:"arn"`
+		} `json:"userIdentity"`
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(event.CloudTrailEvent)), &record); err == nil && record.UserIdentity.Arn != "" {
+		return record.UserIdentity.Arn
+	}
+	return aws.ToString(event.Username)
+}
@@ -0,0 +1,48 @@
+package whatsnew
+
+import (
+	"testing"
+	"time"
+
+	"aws-documentor/modules/lifecycle"
+)
+
+// TestCreationSource checks that only the resource a call created gets its time, and the earliest call wins
+func TestCreationSource(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2024, 5, day, 12, 0, 0, 0, time.UTC) }
+	source := NewCreationSource([]TrailEvent{
+		{Time: at(20), EventName: "CreateSubnet", ResourceIDs: []string{"subnet-0a1", "vpc-0a1"}},
+		{Time: at(10), EventName: "CreateVpc", ResourceIDs: []string{"vpc-0b2"}},
+		{Time: at(12), EventName: "CreateTransitGatewayVpcAttachment", ResourceIDs: []string{"tgw-attach-0a1", "tgw-0a1", "vpc-0b2"}},
+		{Time: at(15), EventName: "RunInstances", ResourceIDs: []string{"i-0a1", "sg-0a1", "subnet-0a1"}},
+		{Time: at(14), EventName: "RunInstances", ResourceIDs: []string{"i-0a1"}},
+		{Time: at(16), EventName: "CreateVpnConnection", ResourceIDs: []string{"vpn-0a1", "vgw-0a1", "cgw-0a1"}},
+		{Time: at(17), EventName: "CreateTags", ResourceIDs: []string{"vpc-0c3"}},
+	})
+	var _ lifecycle.TimestampSource = source
+
+	tests := []struct {
+		id   string
+		want time.Time // Zero if the source has no time
+	}{
+		{"subnet-0a1", at(20)},
+		{"vpc-0a1", time.Time{}},
+		{"vpc-0b2", at(10)},
+		{"tgw-attach-0a1", at(12)},
+		{"tgw-0a1", time.Time{}},
+		{"i-0a1", at(14)},
+		{"sg-0a1", time.Time{}},
+		{"vpn-0a1", at(16)},
+		{"vgw-0a1", time.Time{}},
+		{"vpc-0c3", time.Time{}},
+	}
+	for _, tt := range tests {
+		created, ok := source.CreationTime("", tt.id)
+		if ok != !tt.want.IsZero() || !created.Equal(tt.want) {
+			t.Errorf("CreationTime(%s) = %v, %v; want %v", tt.id, created, ok, tt.want)
+		}
+	}
+	if source.Name() != lifecycle.SourceCloudTrail {
+		t.Errorf("Name() = %s", source.Name())
+	}
+}
@@ -0,0 +1,65 @@
+package whatsnew
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the report as Markdown, one section per week and VPC
+// Pipes in names are escaped so they do not break the tables.
+// w: Destination of the Markdown
+// report: Report from Build
+// Returns: Error if writing fails
+func WriteMarkdown(w io.Writer, report *Report) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "# What's new since %s\n\n", report.Since)
+	fmt.Fprintf(out, "Changes from %s to %s.\n\n", report.Since, report.Until)
+	fmt.Fprintf(out, "**Completeness:** %s\n\n", report.Completeness)
+
+	fmt.Fprintf(out, "| Source | Available | Coverage |\n|---|---|---|\n")
+	for _, source := range report.Sources {
+		available := "no"
+		if source.Available {
+			available = "yes"
+		}
+		fmt.Fprintf(out, "| %s | %s | %s |\n", source.Name, available, markdownCell(source.Detail))
+	}
+
+	if len(report.Events) == 0 {
+		fmt.Fprintf(out, "\nNo changes found in the window.\n")
+		return out.Flush()
+	}
+
+	for _, week := range report.Weeks {
+		fmt.Fprintf(out, "\n## Week of %s\n", week.Start)
+		for _, group := range week.VPCs {
+			vpcID := group.VpcID
+			if vpcID == "" {
+				vpcID = "Regional resources"
+			}
+			fmt.Fprintf(out, "\n### %s\n\n", vpcID)
+			fmt.Fprintf(out, "| Time | Change | Type | Resource | Details | Principal | Source |\n|---|---|---|---|---|---|---|\n")
+			for _, event := range group.Events {
+				when := event.Time
+				if event.NotBefore != "" {
+					when = "between " + event.NotBefore + " and " + event.Time
+				}
+				resource := event.ResourceID
+				if event.Name != "" && event.Name != event.ResourceID {
+					resource += " (" + event.Name + ")"
+				}
+				fmt.Fprintf(out, "| %s | %s | %s | %s | %s | %s | %s |\n",
+					when, event.Change, event.ResourceType, markdownCell(resource),
+					markdownCell(strings.Join(event.Details, ", ")), markdownCell(event.Principal), event.Source)
+			}
+		}
+	}
+	return out.Flush()
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,533 @@
+// Package whatsnew builds a chronological report of the network changes within a time window
+// Events come from the creation times the describe APIs report, from comparing successive JSON
+// reports and from CloudTrail; the report names the sources it had, so readers know how complete it is.
+package whatsnew
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/lifecycle"
+	"aws-documentor/modules/vpc"
+)
+
+// Kinds of changes
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+)
+
+// Sources of event times
+const (
+	SourceNative     = lifecycle.SourceNative     // Creation time reported by the describe API
+	SourceSnapshots  = "snapshots"                // Difference between two successive reports
+	SourceCloudTrail = lifecycle.SourceCloudTrail // Write API call recorded by CloudTrail
+)
+
+// Resource types of CloudTrail events that the reports have no section for
+const (
+	TypeTransitGatewayAttachment = "Transit gateway attachment"
+	TypeVpcPeeringConnection     = "VPC peering connection"
+	TypeVpcEndpoint              = "VPC endpoint"
+)
+
+// Event is one creation, modification or deletion of a resource
+type Event struct {
+	Time         string   `json:"time"`                 // When the change happened (RFC 3339); for snapshot events, the scan that first showed it
+	NotBefore    string   `json:"not_before,omitempty"` // For snapshot events, the earlier scan that did not show the change yet
+	Change       string   `json:"change"`               // created, modified or deleted
+	ResourceType string   `json:"resource_type"`        // VPC, Subnet, Route table, ...
+	ResourceID   string   `json:"resource_id"`          // ID of the resource
+	Name         string   `json:"name"`                 // Name tag or group name, falling back to the ID
+	VpcID        string   `json:"vpc_id"`               // VPC of the resource, empty for regional resources such as transit gateways
+	Details      []string `json:"details"`              // Changed attributes, rules and routes, and the CloudTrail API calls
+	Principal    string   `json:"principal"`            // Who made the change, empty without CloudTrail attribution
+	Source       string   `json:"source"`               // Where the time comes from: native, snapshots or cloudtrail
+
+	at        time.Time // Parsed Time
+	notBefore time.Time // Parsed NotBefore
+}
+
+// SourceStatus tells whether a source was available and what it covered
+type SourceStatus struct {
+	Name      string `json:"name"`      // native, snapshots or cloudtrail
+	Available bool   `json:"available"` // Whether the source contributed to the report
+	Detail    string `json:"detail"`    // What the source covered, or why it was not available
+}
+
+// VPCEvents are the events of one VPC within a week
+type VPCEvents struct {
+	VpcID  string  `json:"vpc_id"` // ID of the VPC, empty for regional resources
+	Events []Event `json:"events"` // Events in chronological order
+}
+
+// Week groups the events of a week (Monday to Sunday, UTC) by VPC
+type Week struct {
+	Start string      `json:"start"` // Monday of the week (YYYY-MM-DD)
+	VPCs  []VPCEvents `json:"vpcs"`  // Events per VPC, by VPC ID with regional resources last
+}
+
+// Report is the chronological list of changes within the window
+type Report struct {
+	Since        string         `json:"since"`        // Start of the window (RFC 3339)
+	Until        string         `json:"until"`        // End of the window, the time of the current scan (RFC 3339)
+	Completeness string         `json:"completeness"` // Which kinds of evidence the report is based on
+	Sources      []SourceStatus `json:"sources"`      // Availability of each source
+	Events       []Event        `json:"events"`       // All events in chronological order
+	Weeks        []Week         `json:"weeks"`        // The same events grouped by week and VPC
+}
+
+// TrailEvent is a write API call recorded by CloudTrail that names network resources
+type TrailEvent struct {
+	Time        time.Time // When the call was made
+	EventName   string    // API operation (CreateSubnet, AuthorizeSecurityGroupIngress, ...)
+	Principal   string    // ARN or user name of the caller
+	ResourceIDs []string  // Network resources the call names
+}
+
+// Input contains the sources of a report
+type Input struct {
+	Current     *diff.Snapshot                     // The current scan
+	Attachments []vpc.TransitGatewayAttachmentInfo // Transit gateway attachments of the current scan (nil when loaded from a report)
+	History     []*diff.Snapshot                   // Earlier reports, in any order
+	Trail       []TrailEvent                       // CloudTrail events of the window (nil if CloudTrail was not read)
+	TrailNote   string                             // Why CloudTrail was not read, when Trail is nil
+}
+
+// changeOrder orders events with identical times so that a creation comes before a modification
+var changeOrder = map[string]int{ChangeCreated: 0, ChangeModified: 1, ChangeDeleted: 2}
+
+// Build merges the sources into the events of the window
+// Snapshot events are dated by the scan that first showed the change, so they can only be placed
+// between two scans. A CloudTrail call on the same resource within that interval supplies the exact
+// time and the principal; calls that match no other event are listed on their own. Creation times
+// reported by the APIs take precedence over snapshot creations of the same resource. Resources created
+// before the window keep their modifications within it.
+// since: Start of the window
+// until: End of the window
+// in: Current scan, earlier reports and CloudTrail events
+// Returns: Report with the events in chronological order, grouped by week and VPC
+func Build(since, until time.Time, in Input) *Report {
+	vpcOf := vpcIndex(append([]*diff.Snapshot{in.Current}, in.History...), in.Attachments)
+
+	events := nativeEvents(in.Current, in.Attachments, vpcOf)
+	nativeCreated := make(map[string]bool)
+	for _, event := range events {
+		nativeCreated[event.ResourceID] = true
+	}
+	snapshots, scans := orderSnapshots(in.History, in.Current)
+	for _, event := range snapshotEvents(snapshots, vpcOf) {
+		if event.Change == ChangeCreated && nativeCreated[event.ResourceID] {
+			continue
+		}
+		events = append(events, event)
+	}
+	events = attribute(events, in.Trail, vpcOf)
+
+	report := &Report{
+		Since:   since.UTC().Format(time.RFC3339),
+		Until:   until.UTC().Format(time.RFC3339),
+		Sources: sourceStatuses(since, scans, in),
+		Events:  []Event{},
+		Weeks:   []Week{},
+	}
+	report.Completeness = completeness(report.Sources)
+
+	for _, event := range events {
+		if event.at.Before(since) || event.at.After(until) {
+			continue
+		}
+		report.Events = append(report.Events, event)
+	}
+	sortEvents(report.Events)
+	report.Weeks = groupByWeek(report.Events)
+	return report
+}
+
+// vpcIndex maps every resource of the snapshots to its VPC
+func vpcIndex(snapshots []*diff.Snapshot, attachments []vpc.TransitGatewayAttachmentInfo) map[string]string {
+	vpcOf := make(map[string]string)
+	for _, s := range snapshots {
+		if s == nil {
+			continue
+		}
+		for _, v := range s.VPCs {
+			vpcOf[v.VpcID] = v.VpcID
+		}
+		for _, subnet := range s.Subnets {
+			vpcOf[subnet.SubnetID] = subnet.VpcID
+		}
+		for _, rt := range s.RouteTables {
+			vpcOf[rt.RouteTableID] = rt.VpcID
+		}
+		for _, sg := range s.SecurityGroups {
+			vpcOf[sg.GroupID] = sg.VpcID
+		}
+		for _, igw := range s.InternetGateways {
+			vpcOf[igw.InternetGatewayID] = igw.VpcID
+		}
+		for _, ngw := range s.NatGateways {
+			vpcOf[ngw.NatGatewayID] = ngw.VpcID
+		}
+	}
+	for _, attachment := range attachments {
+		if attachment.ResourceType == "vpc" {
+			vpcOf[attachment.AttachmentID] = attachment.ResourceID
+		}
+	}
+	return vpcOf
+}
+
+// nativeEvents lists the creations of the resources whose describe API reports a creation time
+func nativeEvents(current *diff.Snapshot, attachments []vpc.TransitGatewayAttachmentInfo, vpcOf map[string]string) []Event {
+	var events []Event
+	add := func(resourceType, id, name, created string) {
+		at, err := time.Parse(time.RFC3339, created)
+		if err != nil {
+			return
+		}
+		events = append(events, Event{
+			Time:         at.UTC().Format(time.RFC3339),
+			Change:       ChangeCreated,
+			ResourceType: resourceType,
+			ResourceID:   id,
+			Name:         name,
+			VpcID:        vpcOf[id],
+			Details:      []string{},
+			Source:       SourceNative,
+			at:           at,
+		})
+	}
+	for _, ngw := range current.NatGateways {
+		add(diff.TypeNatGateway, ngw.NatGatewayID, nameTag(ngw.Tags, ngw.NatGatewayID), ngw.CreatedTime)
+	}
+	for _, tgw := range current.TransitGateways {
+		add(diff.TypeTransitGateway, tgw.TransitGatewayID, nameTag(tgw.Tags, tgw.TransitGatewayID), tgw.CreationTime)
+	}
+	for _, attachment := range attachments {
+		add(TypeTransitGatewayAttachment, attachment.AttachmentID, nameTag(attachment.Tags, attachment.AttachmentID), attachment.CreationTime)
+	}
+	return events
+}
+
+// datedSnapshot is a snapshot with its parsed scan time
+type datedSnapshot struct {
+	snapshot *diff.Snapshot
+	at       time.Time
+}
+
+// orderSnapshots sorts the earlier reports by scan time and appends the current scan
+// Reports without a scan time cannot be placed and are left out.
+// Returns: Snapshots in scan order, and the number of earlier reports used
+func orderSnapshots(history []*diff.Snapshot, current *diff.Snapshot) ([]datedSnapshot, int) {
+	var ordered []datedSnapshot
+	for _, s := range history {
+		if at, err := time.Parse(time.RFC3339, s.ScannedAt); err == nil {
+			ordered = append(ordered, datedSnapshot{s, at})
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].at.Before(ordered[j].at) })
+	scans := len(ordered)
+	if at, err := time.Parse(time.RFC3339, current.ScannedAt); err == nil && scans > 0 {
+		ordered = append(ordered, datedSnapshot{current, at})
+	}
+	return ordered, scans
+}
+
+// snapshotEvents compares each snapshot with the one before it
+func snapshotEvents(snapshots []datedSnapshot, vpcOf map[string]string) []Event {
+	var events []Event
+	for i := 1; i < len(snapshots); i++ {
+		previous, next := snapshots[i-1], snapshots[i]
+		for _, change := range diff.Compare(previous.snapshot, *next.snapshot).Resources {
+			event := Event{
+				Time:         next.at.UTC().Format(time.RFC3339),
+				NotBefore:    previous.at.UTC().Format(time.RFC3339),
+				ResourceType: change.ResourceType,
+				ResourceID:   change.ResourceID,
+				Name:         change.Name,
+				VpcID:        vpcOf[change.ResourceID],
+				Details:      changeDetails(change),
+				Source:       SourceSnapshots,
+				at:           next.at,
+				notBefore:    previous.at,
+			}
+			switch change.Change {
+			case diff.ChangeAdded:
+				event.Change = ChangeCreated
+			case diff.ChangeRemoved:
+				event.Change = ChangeDeleted
+			default:
+				event.Change = ChangeModified
+			}
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// changeDetails summarizes the changed attributes, rules and routes of a modification
+func changeDetails(change diff.ResourceChange) []string {
+	details := append([]string{}, change.Fields...)
+	if n := len(change.AddedRules) + len(change.RemovedRules); n > 0 {
+		details = append(details, fmt.Sprintf("rules +%d -%d", len(change.AddedRules), len(change.RemovedRules)))
+	}
+	if n := len(change.AddedRoutes) + len(change.RemovedRoutes); n > 0 {
+		details = append(details, fmt.Sprintf("routes +%d -%d", len(change.AddedRoutes), len(change.RemovedRoutes)))
+	}
+	return details
+}
+
+// deletionCalls maps the calls that delete a resource to the ID prefix of the resource they delete
+var deletionCalls = map[string]string{
+	"DeleteVpc":                             "vpc-",
+	"DeleteSubnet":                          "subnet-",
+	"DeleteRouteTable":                      "rtb-",
+	"DeleteSecurityGroup":                   "sg-",
+	"DeleteInternetGateway":                 "igw-",
+	"DeleteNatGateway":                      "nat-",
+	"DeleteTransitGateway":                  "tgw-",
+	"DeleteTransitGatewayVpcAttachment":     "tgw-attach-",
+	"DeleteTransitGatewayPeeringAttachment": "tgw-attach-",
+	"DeleteVpcPeeringConnection":            "pcx-",
+	"DeleteVpcEndpoints":                    "vpce-",
+}
+
+// attribute dates and attributes events with the CloudTrail calls on the same resource
+// A call matches a snapshot event of the same kind when it falls between the two scans, and a native
+// creation when it is within a minute of the reported time. A call that creates or deletes a resource
+// changes only that one; the others it names, such as the VPC of a new subnet, are left alone.
+func attribute(events []Event, trail []TrailEvent, vpcOf map[string]string) []Event {
+	sorted := append([]TrailEvent{}, trail...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	for _, call := range sorted {
+		lifecycleCall := false
+		for _, id := range call.ResourceIDs {
+			lifecycleCall = lifecycleCall || trailChange(call.EventName, id) != ChangeModified
+		}
+		for _, id := range call.ResourceIDs {
+			resourceType, ok := resourceTypeOf(id)
+			change := trailChange(call.EventName, id)
+			if !ok || (lifecycleCall && change == ChangeModified) {
+				continue
+			}
+			if match := findMatch(events, id, change, call.Time); match != nil {
+				match.Principal = call.Principal
+				match.Details = append(match.Details, call.EventName)
+				if match.Source == SourceSnapshots {
+					match.Time, match.NotBefore = call.Time.UTC().Format(time.RFC3339), ""
+					match.at, match.notBefore = call.Time, time.Time{}
+					match.Source = SourceCloudTrail
+				}
+				continue
+			}
+			events = append(events, Event{
+				Time:         call.Time.UTC().Format(time.RFC3339),
+				Change:       change,
+				ResourceType: resourceType,
+				ResourceID:   id,
+				Name:         id,
+				VpcID:        vpcOf[id],
+				Details:      []string{call.EventName},
+				Principal:    call.Principal,
+				Source:       SourceCloudTrail,
+				at:           call.Time,
+			})
+		}
+	}
+	return events
+}
+
+// findMatch returns the unattributed event that a CloudTrail call on a resource explains, or nil
+func findMatch(events []Event, id, change string, at time.Time) *Event {
+	for i := range events {
+		event := &events[i]
+		if event.ResourceID != id || event.Change != change || event.Principal != "" {
+			continue
+		}
+		switch event.Source {
+		case SourceSnapshots:
+			if at.After(event.notBefore) && !at.After(event.at) {
+				return event
+			}
+		case SourceNative:
+			if d := at.Sub(event.at); d > -time.Minute && d < time.Minute {
+				return event
+			}
+		}
+	}
+	return nil
+}
+
+// trailChange classifies an API call on one of the resources it names
+// Only the calls in creationCalls and deletionCalls create or delete, and only resources of their own
+// type; CreateRoute or CreateTags modify the route table or resource they name.
+func trailChange(eventName, id string) string {
+	if prefix, ok := creationCalls[eventName]; ok && idPrefix(id) == prefix {
+		return ChangeCreated
+	}
+	if prefix, ok := deletionCalls[eventName]; ok && idPrefix(id) == prefix {
+		return ChangeDeleted
+	}
+	return ChangeModified
+}
+
+// resourceTypeOf returns the resource type of a network resource ID
+// Returns: Type as named in the reports, and whether the ID is a network resource this report covers
+func resourceTypeOf(id string) (string, bool) {
+	// Longer prefixes first: tgw-attach- and tgw-rtb- also start with tgw-
+	prefixes := []struct{ prefix, resourceType string }{
+		{"tgw-attach-", TypeTransitGatewayAttachment},
+		{"tgw-rtb-", ""},
+		{"tgw-", diff.TypeTransitGateway},
+		{"vpce-svc-", ""},
+		{"vpce-", TypeVpcEndpoint},
+		{"vpc-", diff.TypeVPC},
+		{"subnet-", diff.TypeSubnet},
+		{"rtb-", diff.TypeRouteTable},
+		{"sg-", diff.TypeSecurityGroup},
+		{"igw-", diff.TypeInternetGateway},
+		{"nat-", diff.TypeNatGateway},
+		{"pcx-", TypeVpcPeeringConnection},
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(id, p.prefix) {
+			return p.resourceType, p.resourceType != ""
+		}
+	}
+	return "", false
+}
+
+// sourceStatuses describes what each source contributed
+func sourceStatuses(since time.Time, scans int, in Input) []SourceStatus {
+	statuses := []SourceStatus{{
+		Name:      SourceNative,
+		Available: true,
+		Detail:    "creation times of NAT gateways, transit gateways and their attachments; VPCs, subnets, route tables, security groups and internet gateways report none",
+	}}
+
+	snapshots := SourceStatus{Name: SourceSnapshots}
+	switch {
+	case scans == 0:
+		snapshots.Detail = "no earlier reports given (-history); modifications and deletions are not covered"
+	default:
+		first, last := "", ""
+		for _, s := range in.History {
+			if _, err := time.Parse(time.RFC3339, s.ScannedAt); err != nil {
+				continue
+			}
+			if first == "" || s.ScannedAt < first {
+				first = s.ScannedAt
+			}
+			if s.ScannedAt > last {
+				last = s.ScannedAt
+			}
+		}
+		snapshots.Available = true
+		snapshots.Detail = fmt.Sprintf("%d earlier reports from %s to %s; changes are dated by the scan that first showed them", scans, first, last)
+		if at, _ := time.Parse(time.RFC3339, first); at.After(since) {
+			snapshots.Detail += fmt.Sprintf("; changes before %s are not covered", first)
+		}
+	}
+
+	trail := SourceStatus{Name: SourceCloudTrail, Detail: in.TrailNote}
+	if in.Trail != nil {
+		trail.Available = true
+		trail.Detail = fmt.Sprintf("%d write calls on network resources; CloudTrail event history keeps 90 days", len(in.Trail))
+	}
+	return append(statuses, snapshots, trail)
+}
+
+// completeness names the kinds of evidence the available sources provide
+func completeness(statuses []SourceStatus) string {
+	available := make(map[string]bool)
+	for _, status := range statuses {
+		available[status.Name] = status.Available
+	}
+	switch {
+	case available[SourceCloudTrail] && available[SourceSnapshots]:
+		return "full attribution: native timestamps, snapshot history and CloudTrail principals"
+	case available[SourceCloudTrail]:
+		return "native timestamps and CloudTrail principals, without snapshot history"
+	case available[SourceSnapshots]:
+		return "native timestamps and snapshot history, without attribution"
+	default:
+		return "native timestamps only: creations of gateways and attachments, without modifications or attribution"
+	}
+}
+
+// sortEvents orders events by time; events with identical times are ordered by kind of change,
+// resource type and ID, so the report is the same on every run
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if !a.at.Equal(b.at) {
+			return a.at.Before(b.at)
+		}
+		if changeOrder[a.Change] != changeOrder[b.Change] {
+			return changeOrder[a.Change] < changeOrder[b.Change]
+		}
+		if a.ResourceType != b.ResourceType {
+			return a.ResourceType < b.ResourceType
+		}
+		if a.ResourceID != b.ResourceID {
+			return a.ResourceID < b.ResourceID
+		}
+		return a.Source < b.Source
+	})
+}
+
+// groupByWeek groups chronological events by the Monday of their week and by VPC
+func groupByWeek(events []Event) []Week {
+	weeks := []Week{}
+	for _, event := range events {
+		start := weekStart(event.at)
+		if len(weeks) == 0 || weeks[len(weeks)-1].Start != start {
+			weeks = append(weeks, Week{Start: start, VPCs: []VPCEvents{}})
+		}
+		week := &weeks[len(weeks)-1]
+		var group *VPCEvents
+		for i := range week.VPCs {
+			if week.VPCs[i].VpcID == event.VpcID {
+				group = &week.VPCs[i]
+			}
+		}
+		if group == nil {
+			week.VPCs = append(week.VPCs, VPCEvents{VpcID: event.VpcID})
+			group = &week.VPCs[len(week.VPCs)-1]
+		}
+		group.Events = append(group.Events, event)
+	}
+
+	// VPCs by ID, regional resources last
+	for _, week := range weeks {
+		sort.SliceStable(week.VPCs, func(i, j int) bool {
+			a, b := week.VPCs[i].VpcID, week.VPCs[j].VpcID
+			if (a == "") != (b == "") {
+				return b == ""
+			}
+			return a < b
+		})
+	}
+	return weeks
+}
+
+// weekStart returns the Monday of the UTC week of t
+func weekStart(t time.Time) string {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset).Format("2006-01-02")
+}
+
+// nameTag returns the Name tag, falling back to the resource ID
+func nameTag(tags map[string]string, resourceID string) string {
+	if name := tags["Name"]; name != "" {
+		return name
+	}
+	return resourceID
+}
@@ -0,0 +1,242 @@
+package whatsnew
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/vpc"
+)
+
+// update rewrites the golden files instead of comparing with them
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// day returns a time of the test window
+func day(s string) time.Time {
+	at, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return at
+}
+
+// history returns three scans of one VPC:
+//   - 2025-12-20, before the window: subnets subnet-0old and subnet-0gone, security group sg-0web with one rule
+//   - 2026-02-10: subnet-0new was added
+//   - 2026-04-01, the current scan: subnet-0gone was deleted, sg-0web got a second rule and nat-0a1 was
+//     created on 2026-03-02
+//
+// The route table and the transit gateway, created in 2025, are in every scan.
+func history() (current *diff.Snapshot, earlier []*diff.Snapshot) {
+	snapshot := func(scannedAt string, subnetIDs ...string) *diff.Snapshot {
+		s := &diff.Snapshot{
+			ScannedAt:       scannedAt,
+			VPCs:            []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", Tags: map[string]string{"Name": "prod"}}},
+			RouteTables:     []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1"}},
+			TransitGateways: []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0old", CreationTime: "2025-06-01T00:00:00Z"}},
+			SecurityGroups: []vpc.SecurityGroupInfo{{GroupID: "sg-0web", GroupName: "web", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+				{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"},
+			}}},
+		}
+		for _, id := range subnetIDs {
+			s.Subnets = append(s.Subnets, vpc.SubnetInfo{SubnetID: id, VpcID: "vpc-0a1", Tags: map[string]string{"Name": "app|" + id[7:]}})
+		}
+		return s
+	}
+	current = snapshot("2026-04-01T08:00:00Z", "subnet-0old", "subnet-0new")
+	current.SecurityGroups[0].Rules = append(current.SecurityGroups[0].Rules, vpc.SecurityGroupRule{IpProtocol: "tcp", FromPort: 80, ToPort: 80, CidrBlock: "0.0.0.0/0"})
+	current.NatGateways = []vpc.NatGatewayInfo{{NatGatewayID: "nat-0a1", SubnetID: "subnet-0new", VpcID: "vpc-0a1", CreatedTime: "2026-03-02T10:00:00Z"}}
+	// Given out of order, as files on the command line may be
+	return current, []*diff.Snapshot{snapshot("2026-02-10T08:00:00Z", "subnet-0old", "subnet-0gone", "subnet-0new"),
+		snapshot("2025-12-20T08:00:00Z", "subnet-0old", "subnet-0gone")}
+}
+
+// trail are the CloudTrail calls of the window and one from before it
+var trail = []TrailEvent{
+	{Time: day("2026-03-20T12:00:00Z"), EventName: "DeleteSubnet", Principal: "alice", ResourceIDs: []string{"subnet-0gone"}},
+	{Time: day("2026-01-05T09:00:00Z"), EventName: "CreateSubnet", Principal: "alice", ResourceIDs: []string{"subnet-0new", "vpc-0a1"}},
+	{Time: day("2026-03-02T10:00:20Z"), EventName: "CreateNatGateway", Principal: "bob", ResourceIDs: []string{"nat-0a1", "subnet-0new"}},
+	{Time: day("2026-03-02T10:00:00Z"), EventName: "CreateTags", Principal: "bob", ResourceIDs: []string{"tgw-0old"}},
+	{Time: day("2026-03-02T10:00:00Z"), EventName: "CreateRoute", Principal: "bob", ResourceIDs: []string{"rtb-0a1"}},
+	{Time: day("2026-03-15T12:00:00Z"), EventName: "AuthorizeSecurityGroupIngress", Principal: "carol", ResourceIDs: []string{"sg-0web"}},
+	{Time: day("2026-03-21T12:00:00Z"), EventName: "TerminateInstances", Principal: "dave", ResourceIDs: []string{"i-0a1"}},
+	{Time: day("2025-12-31T00:00:00Z"), EventName: "ModifyVpcAttribute", Principal: "erin", ResourceIDs: []string{"vpc-0a1"}},
+}
+
+// summarize formats events as one line each: time (with the earliest time of snapshot events), change,
+// type, ID, VPC, principal, source and details
+func summarize(events []Event) []string {
+	lines := []string{}
+	for _, e := range events {
+		when := e.Time
+		if e.NotBefore != "" {
+			when = e.NotBefore + ".." + e.Time
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s %s vpc=%s by=%s %s %v", when, e.Change, e.ResourceType, e.ResourceID, e.VpcID, e.Principal, e.Source, e.Details))
+	}
+	return lines
+}
+
+// TestBuild merges native creation times, snapshot differences and CloudTrail calls: calls date and attribute
+// the snapshot events between the scans that showed them, a native creation keeps its time and takes the
+// principal, other calls are listed on their own, and events with identical times are ordered by kind of change
+func TestBuild(t *testing.T) {
+	current, earlier := history()
+	report := Build(day("2026-01-01T08:00:00Z"), day("2026-04-01T08:00:00Z"), Input{Current: current, History: earlier, Trail: trail})
+
+	want := []string{
+		"2026-01-05T09:00:00Z created Subnet subnet-0new vpc=vpc-0a1 by=alice cloudtrail [CreateSubnet]",
+		"2026-03-02T10:00:00Z created NAT gateway nat-0a1 vpc=vpc-0a1 by=bob native [CreateNatGateway]",
+		"2026-03-02T10:00:00Z modified Route table rtb-0a1 vpc=vpc-0a1 by=bob cloudtrail [CreateRoute]",
+		"2026-03-02T10:00:00Z modified Transit gateway tgw-0old vpc= by=bob cloudtrail [CreateTags]",
+		"2026-03-15T12:00:00Z modified Security group sg-0web vpc=vpc-0a1 by=carol cloudtrail [rules +1 -0 AuthorizeSecurityGroupIngress]",
+		"2026-03-20T12:00:00Z deleted Subnet subnet-0gone vpc=vpc-0a1 by=alice cloudtrail [DeleteSubnet]",
+	}
+	if got := summarize(report.Events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var weeks []string
+	for _, week := range report.Weeks {
+		for _, group := range week.VPCs {
+			weeks = append(weeks, fmt.Sprintf("%s %s %d", week.Start, group.VpcID, len(group.Events)))
+		}
+	}
+	wantWeeks := []string{"2026-01-05 vpc-0a1 1", "2026-03-02 vpc-0a1 2", "2026-03-02  1", "2026-03-09 vpc-0a1 1", "2026-03-16 vpc-0a1 1"}
+	if !reflect.DeepEqual(weeks, wantWeeks) {
+		t.Errorf("weeks = %q, want %q", weeks, wantWeeks)
+	}
+	if report.Completeness != "full attribution: native timestamps, snapshot history and CloudTrail principals" {
+		t.Errorf("completeness = %q", report.Completeness)
+	}
+}
+
+// TestBuildWithoutCloudTrail dates snapshot events by the scans around them, keeps a creation whose earlier
+// scan is before the window, and orders the modification and deletion of the same scan by kind of change
+func TestBuildWithoutCloudTrail(t *testing.T) {
+	current, earlier := history()
+	report := Build(day("2026-01-01T08:00:00Z"), day("2026-04-01T08:00:00Z"),
+		Input{Current: current, History: earlier, TrailNote: "CloudTrail was not read (-cloudtrail=false)"})
+
+	want := []string{
+		"2025-12-20T08:00:00Z..2026-02-10T08:00:00Z created Subnet subnet-0new vpc=vpc-0a1 by= snapshots []",
+		"2026-03-02T10:00:00Z created NAT gateway nat-0a1 vpc=vpc-0a1 by= native []",
+		"2026-02-10T08:00:00Z..2026-04-01T08:00:00Z modified Security group sg-0web vpc=vpc-0a1 by= snapshots [rules +1 -0]",
+		"2026-02-10T08:00:00Z..2026-04-01T08:00:00Z deleted Subnet subnet-0gone vpc=vpc-0a1 by= snapshots []",
+	}
+	if got := summarize(report.Events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	wantSources := []SourceStatus{
+		{Name: SourceNative, Available: true, Detail: "creation times of NAT gateways, transit gateways and their attachments; VPCs, subnets, route tables, security groups and internet gateways report none"},
+		{Name: SourceSnapshots, Available: true, Detail: "2 earlier reports from 2025-12-20T08:00:00Z to 2026-02-10T08:00:00Z; changes are dated by the scan that first showed them"},
+		{Name: SourceCloudTrail, Detail: "CloudTrail was not read (-cloudtrail=false)"},
+	}
+	if !reflect.DeepEqual(report.Sources, wantSources) {
+		t.Errorf("sources = %+v\nwant %+v", report.Sources, wantSources)
+	}
+}
+
+// TestCompleteness checks the completeness statement and the snapshot coverage for each set of sources
+func TestCompleteness(t *testing.T) {
+	current, earlier := history()
+	tests := []struct {
+		name         string
+		in           Input
+		want         string
+		wantSnapshot string
+	}{
+		{name: "native only", in: Input{Current: current},
+			want:         "native timestamps only: creations of gateways and attachments, without modifications or attribution",
+			wantSnapshot: "no earlier reports given (-history); modifications and deletions are not covered"},
+		{name: "CloudTrail without history", in: Input{Current: current, Trail: []TrailEvent{}},
+			want:         "native timestamps and CloudTrail principals, without snapshot history",
+			wantSnapshot: "no earlier reports given (-history); modifications and deletions are not covered"},
+		{name: "history starting in the window", in: Input{Current: current, History: earlier[:1]},
+			want:         "native timestamps and snapshot history, without attribution",
+			wantSnapshot: "1 earlier reports from 2026-02-10T08:00:00Z to 2026-02-10T08:00:00Z; changes are dated by the scan that first showed them; changes before 2026-02-10T08:00:00Z are not covered"},
+		{name: "history without scan times", in: Input{Current: current, History: []*diff.Snapshot{{}}},
+			want:         "native timestamps only: creations of gateways and attachments, without modifications or attribution",
+			wantSnapshot: "no earlier reports given (-history); modifications and deletions are not covered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Build(day("2026-01-01T08:00:00Z"), day("2026-04-01T08:00:00Z"), tt.in)
+			if report.Completeness != tt.want {
+				t.Errorf("completeness = %q, want %q", report.Completeness, tt.want)
+			}
+			if report.Sources[1].Detail != tt.wantSnapshot {
+				t.Errorf("snapshot source = %q, want %q", report.Sources[1].Detail, tt.wantSnapshot)
+			}
+		})
+	}
+}
+
+// TestTrailChange checks that calls only create or delete resources of their own type
+func TestTrailChange(t *testing.T) {
+	tests := []struct {
+		eventName, id, want string
+	}{
+		{"CreateSubnet", "subnet-0a1", ChangeCreated},
+		{"CreateSubnet", "vpc-0a1", ChangeModified},
+		{"CreateRoute", "rtb-0a1", ChangeModified},
+		{"CreateTags", "vpc-0a1", ChangeModified},
+		{"CreateTransitGateway", "tgw-attach-0a1", ChangeModified},
+		{"CreateTransitGatewayPeeringAttachment", "tgw-attach-0a1", ChangeCreated},
+		{"DeleteRoute", "rtb-0a1", ChangeModified},
+		{"DeleteRouteTable", "rtb-0a1", ChangeDeleted},
+		{"DeleteVpcEndpoints", "vpce-0a1", ChangeDeleted},
+		{"ModifyVpcAttribute", "vpc-0a1", ChangeModified},
+		{"CreateVpc", "default", ChangeModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.eventName+" "+tt.id, func(t *testing.T) {
+			if got := trailChange(tt.eventName, tt.id); got != tt.want {
+				t.Errorf("trailChange() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteMarkdown compares the Markdown of the merged report with testdata/whatsnew.md
+// Run go test -update to rewrite the golden file after an intended change.
+func TestWriteMarkdown(t *testing.T) {
+	current, earlier := history()
+	report := Build(day("2026-01-01T08:00:00Z"), day("2026-04-01T08:00:00Z"), Input{Current: current, History: earlier[:1], Trail: trail[1:3]})
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "whatsnew.md")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("Markdown differs from %s:\n%s", golden, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, Build(day("2026-01-01T08:00:00Z"), day("2026-04-01T08:00:00Z"), Input{Current: &diff.Snapshot{}})); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "\nNo changes found in the window.\n") {
+		t.Errorf("empty report = %q, want the no changes note", buf.String())
+	}
+}
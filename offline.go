@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/mermaid"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/report"
+)
+
+// loadedOutput is where and how the resources of a saved scan are printed
+type loadedOutput struct {
+	w      io.Writer         // Progress and document output
+	result *RunResult        // Run result the resources are counted in
+	json   bool              // Whether the resources are printed, or only their counts
+	style  output.FieldStyle // Field naming style of the documents
+	format output.Format     // Syntax of the documents
+}
+
+// printLoadedScan prints the resources of a saved scan as the scan printed them, and counts them for the run result
+// Optional scanners that failed or were not requested saved null sections, which are left out.
+func printLoadedScan(out loadedOutput, scan *report.ScanResult) {
+	printLoaded(out, "vpcs", "VPCs", scan.VPCs)
+	printLoaded(out, "subnets", "Subnets", scan.Subnets)
+	printLoaded(out, "route_tables", "Route Tables", scan.RouteTables)
+	printLoaded(out, "security_groups", "Security Groups", scan.SecurityGroups)
+	printLoaded(out, "internet_gateways", "Internet Gateways", scan.InternetGateways)
+	printLoaded(out, "nat_gateways", "NAT Gateways", scan.NatGateways)
+	if scan.RouteAppliances != nil {
+		printLoaded(out, "route_appliances", "Route Target Instances", scan.RouteAppliances)
+	}
+	printLoaded(out, "transit_gateways", "Transit Gateways", scan.TransitGateways)
+	printLoaded(out, "tgw_attachments", "Transit Gateway Attachments", scan.TGWAttachments)
+	if scan.VpcPeerings != nil {
+		printLoaded(out, "vpc_peering_connections", "VPC Peering Connections", scan.VpcPeerings)
+	}
+	if scan.NetworkACLs != nil {
+		printLoaded(out, "network_acls", "Network ACLs", scan.NetworkACLs)
+	}
+	if scan.CarrierGateways != nil {
+		printLoaded(out, "carrier_gateways", "Carrier Gateways", scan.CarrierGateways)
+	}
+	if scan.VpnGateways != nil {
+		printLoaded(out, "vpn_gateways", "Virtual Private Gateways", scan.VpnGateways)
+	}
+	if scan.CustomerGateways != nil {
+		printLoaded(out, "customer_gateways", "Customer Gateways", scan.CustomerGateways)
+	}
+	if scan.VpnConnections != nil {
+		printLoaded(out, "vpn_connections", "VPN Connections", scan.VpnConnections)
+	}
+	if scan.DhcpOptions != nil {
+		printLoaded(out, "dhcp_options", "DHCP Options Sets", scan.DhcpOptions)
+	}
+	if scan.NetworkInterfaces != nil {
+		printLoaded(out, "network_interfaces", "Network Interfaces", scan.NetworkInterfaces)
+	}
+	if scan.Instances != nil {
+		printLoaded(out, "instances", "Instances", scan.Instances)
+	}
+	if scan.FlowLogs != nil {
+		printLoaded(out, "flow_logs", "Flow Logs", scan.FlowLogs)
+	}
+	if scan.ElasticIPs != nil {
+		printLoaded(out, "elastic_ips", "Elastic IPs", scan.ElasticIPs)
+	}
+	if scan.VpcEndpoints != nil {
+		printLoaded(out, "vpc_endpoints", "VPC Endpoints", scan.VpcEndpoints)
+	}
+	if scan.AutoScalingGroups != nil {
+		printLoaded(out, "auto_scaling_groups", "Auto Scaling Groups", scan.AutoScalingGroups)
+	}
+	if scan.Directories != nil {
+		printLoaded(out, "directories", "Directories", scan.Directories)
+	}
+	if scan.CoreNetworks != nil {
+		printLoaded(out, "core_networks", "Core Networks", scan.CoreNetworks)
+	}
+	if scan.PrivateAPIs != nil {
+		printLoaded(out, "private_apis", "Private APIs", scan.PrivateAPIs)
+	}
+}
+
+// printLoaded prints and counts one section of a saved scan
+// resourceType: Key of the resource count in the run result
+// title: Resource name of the "Found N ..." line
+func printLoaded[T any](out loadedOutput, resourceType, title string, items []T) {
+	out.result.count(resourceType, len(items))
+	if !out.json {
+		fmt.Fprintf(out.w, "Found %d %s\n", len(items), title)
+		return
+	}
+	fmt.Fprintln(out.w)
+	printResources(out.w, title, items, out.style, out.format)
+}
+
+// printResources prints a resource section of the legacy stdout output: the "Found N ...:" line and one
+// document per resource, each followed by a --- line
+// Scans and -load print every section with it, so the golden files of the legacy output cover both.
+// title: Resource name of the "Found N ..." line
+func printResources[T any](w io.Writer, title string, items []T, style output.FieldStyle, format output.Format) {
+	fmt.Fprintf(w, "Found %d %s:\n", len(items), title)
+	for _, item := range items {
+		itemJSON, _ := output.Marshal(item, style, format)
+		fmt.Fprintf(w, "%s\n", itemJSON)
+		fmt.Fprintln(w, "---")
+	}
+}
+
+// generateFromLoad prints the resources of a -load file and generates the outputs that can be drawn from them
+// Returns: Error if an output cannot be generated or written
+func (run *scanRun) generateFromLoad() error {
+	run.result.Region = run.loaded.Region
+	run.result.AccountID = run.loaded.AccountID
+	fmt.Fprintf(run.stdout, "Loading the scan of region %s from %s (scanned %s)\n", run.loaded.Region, *run.loadFile, run.loaded.ScanTime.UTC().Format(time.RFC3339))
+	stale, err := run.freshness.check(run.loaded.ScanTime, time.Now(), run.result.warnf)
+	if err != nil {
+		return err
+	}
+	run.result.Stale = stale
+	printLoadedScan(loadedOutput{w: run.stdout, result: run.result, json: run.opts.JSON, style: run.fieldStyle, format: run.format}, run.loaded)
+
+	if *run.generateDiagram && *run.diagramFormat == diagramMermaid {
+		fmt.Fprintln(run.stdout, "\nGenerating Mermaid diagram...")
+		mermaidGen := mermaid.NewMermaidGenerator()
+		doc, err := mermaidGen.GenerateVPCDiagram(run.loaded.VPCs, run.loaded.Subnets, run.loaded.RouteTables, run.loaded.SecurityGroups,
+			run.loaded.InternetGateways, run.loaded.NatGateways, run.loaded.TransitGateways, run.loaded.TGWAttachments)
+		if err != nil {
+			return fmt.Errorf("Failed to generate Mermaid diagram: %w", err)
+		}
+		if err := writeMermaidDiagram(run.stdout, run.result, mermaidGen.Markdown(doc)); err != nil {
+			return err
+		}
+	} else if *run.generateDiagram {
+		fmt.Fprintln(run.stdout, "\nGenerating draw.io diagram...")
+		diagramGen := diagram.NewDiagramGenerator()
+		diagramGen.SetCoreNetworks(run.loaded.CoreNetworks)
+		diagramGen.SetDirectories(run.loaded.Directories)
+		diagramGen.SetAutoScalingGroups(run.loaded.AutoScalingGroups)
+		diagramGen.SetRouteAppliances(run.loaded.RouteAppliances)
+		diagramGen.SetInstances(run.loaded.Instances)
+		diagramGen.SetVpcEndpoints(run.loaded.VpcEndpoints)
+		diagramGen.SetPrivateAPIs(run.loaded.PrivateAPIs)
+		diagramGen.SetVpcPeeringConnections(run.loaded.VpcPeerings)
+		diagramGen.SetVpnConnections(run.loaded.VpnConnections, run.loaded.CustomerGateways, run.loaded.VpnGateways)
+		diagramGen.SetElasticIPs(run.loaded.ElasticIPs)
+		diagramGen.SetHideDefaultEgress(*run.hideDefaultEgress)
+		diagramGen.SetScanContext(run.loaded.Region, run.loaded.AccountID)
+		diagramGen.SetPlainCells(*run.diagramPlain)
+		diagramGen.SetDimManaged(splitList(*run.dimManaged))
+		diagramXML, err := diagramGen.GenerateVPCDiagram(run.loaded.VPCs, run.loaded.Subnets, run.loaded.RouteTables, run.loaded.SecurityGroups,
+			run.loaded.InternetGateways, run.loaded.NatGateways, run.loaded.TransitGateways, run.loaded.TGWAttachments)
+		if err != nil {
+			return fmt.Errorf("Failed to generate diagram: %w", err)
+		}
+		if err := writeDiagram(run.stdout, run.result, diagramXML); err != nil {
+			return err
+		}
+	}
+
+	if *run.detailDiagrams != "" {
+		fmt.Fprintln(run.stdout, "\nGenerating draw.io detail diagrams...")
+		diagramGen := diagram.NewDiagramGenerator()
+		diagramGen.SetVpcEndpoints(run.loaded.VpcEndpoints)
+		diagramGen.SetPrivateAPIs(run.loaded.PrivateAPIs)
+		diagramGen.SetNetworkACLs(run.loaded.NetworkACLs)
+		diagramGen.SetHideDefaultEgress(*run.hideDefaultEgress)
+		diagramGen.SetScanContext(run.loaded.Region, run.loaded.AccountID)
+		diagramGen.SetPlainCells(*run.diagramPlain)
+		diagramGen.SetDimManaged(splitList(*run.dimManaged))
+		details, err := diagramGen.GenerateVPCDetailDiagrams(runtime.NumCPU(), run.loaded.VPCs, run.loaded.Subnets, run.loaded.RouteTables,
+			run.loaded.SecurityGroups, run.loaded.InternetGateways, run.loaded.NatGateways)
+		if err != nil {
+			return fmt.Errorf("Failed to generate detail diagrams: %w", err)
+		}
+		if err := writeDetailDiagrams(run.stdout, run.result, *run.detailDiagrams, details, run.loaded.VPCs); err != nil {
+			return err
+		}
+	}
+
+	if *run.terraformOut != "" {
+		if err := writeTerraformImports(run.stdout, run.result, *run.terraformOut, run.loaded); err != nil {
+			return err
+		}
+	}
+	if *run.securityReport {
+		writeSecurityReport(os.Stderr, analysis.AnalyzeSecurityGroups(run.loaded.SecurityGroups), run.loaded.ElasticIPs)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/vpc"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// legacyScan is a saved scan with one resource of each core type, a second subnet and a VPN connection;
+// the other optional sections are null, as when their scans did not run
+func legacyScan() *report.ScanResult {
+	available := 250
+	return &report.ScanResult{
+		ScanTime:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Region:    "eu-west-1",
+		AccountID: "111122223333",
+		VPCs: []vpc.VPCInfo{{VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/16", State: "available", DhcpOptionsID: "dopt-0a1", InstanceTenancy: "default",
+			Tags: map[string]string{"Name": "prod"}, TagList: []vpc.Tag{{Key: "Name", Value: "prod"}}, AssociateCidrBlocks: []string{"10.0.0.0/16"}, Ipv6CidrBlocks: []string{}}},
+		Subnets: []vpc.SubnetInfo{
+			{SubnetID: "subnet-0a1", VpcID: "vpc-0a1", CidrBlock: "10.0.0.0/24", AvailabilityZone: "eu-west-1a", State: "available", MapPublicIpOnLaunch: true, AvailableIpAddressCount: &available, Tags: map[string]string{"Name": "public-a"}},
+			{SubnetID: "subnet-0a2", VpcID: "vpc-0a1", CidrBlock: "10.0.1.0/24", AvailabilityZone: "eu-west-1b", State: "available"},
+		},
+		RouteTables: []vpc.RouteTableInfo{{RouteTableID: "rtb-0a1", VpcID: "vpc-0a1", SubnetIDs: []string{"subnet-0a1"}, Routes: []vpc.RouteInfo{
+			{DestinationCidrBlock: "10.0.0.0/16", GatewayID: "local", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetLocal, ID: "local"}, RouteType: "local-primary-cidr"},
+			{DestinationCidrBlock: "0.0.0.0/0", GatewayID: "igw-0a1", State: "active", Target: vpc.RouteTarget{Type: vpc.RouteTargetInternetGateway, ID: "igw-0a1"}},
+		}}},
+		SecurityGroups: []vpc.SecurityGroupInfo{{GroupID: "sg-0a1", GroupName: "web", VpcID: "vpc-0a1", Rules: []vpc.SecurityGroupRule{
+			{IpProtocol: "tcp", FromPort: 443, ToPort: 443, CidrBlock: "0.0.0.0/0"},
+		}, IngressRuleCount: 1}},
+		InternetGateways: []vpc.InternetGatewayInfo{{InternetGatewayID: "igw-0a1", VpcID: "vpc-0a1", State: "available"}},
+		NatGateways:      []vpc.NatGatewayInfo{},
+		TransitGateways:  []vpc.TransitGatewayInfo{{TransitGatewayID: "tgw-0c1", State: "available", AmazonSideAsn: 64512}},
+		TGWAttachments:   []vpc.TransitGatewayAttachmentInfo{{AttachmentID: "tgw-attach-0a1", TransitGatewayID: "tgw-0c1", ResourceType: "vpc", ResourceID: "vpc-0a1", State: "available"}},
+		VpnConnections: []vpc.VpnConnectionInfo{{VpnConnectionID: "vpn-0d1", CustomerGatewayID: "cgw-0d1", TransitGatewayID: "tgw-0c1", Type: "ipsec.1", State: "available", StaticRoutesOnly: true,
+			StaticRoutes: []vpc.VpnStaticRouteInfo{{DestinationCidrBlock: "192.168.0.0/16", State: "available"}}}},
+	}
+}
+
+// TestLegacyStdoutGolden keeps the legacy stdout output of the resource sections from drifting
+// Scans and -load print the sections with printResources, so the golden files stand for both.
+// Run go test -run LegacyStdout -update after a deliberate change and review the golden diff.
+func TestLegacyStdoutGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		style  output.FieldStyle
+		format output.Format
+	}{
+		{"legacy_stdout.json", output.FieldStyleSnake, output.FormatJSON},
+		{"legacy_stdout_camel.json", output.FieldStyleCamel, output.FormatJSON},
+		{"legacy_stdout.yaml", output.FieldStyleSnake, output.FormatYAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var out bytes.Buffer
+			printLoadedScan(loadedOutput{w: &out, result: newRunResult(), json: true, style: tt.style, format: tt.format}, legacyScan())
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("legacy stdout differs from %s:\n%s", path, out.String())
+			}
+		})
+	}
+}
+
+// TestLegacyStdoutCounts checks the counts-only form printed without -json and the run result counts
+func TestLegacyStdoutCounts(t *testing.T) {
+	var out bytes.Buffer
+	result := newRunResult()
+	printLoadedScan(loadedOutput{w: &out, result: result, style: output.FieldStyleSnake, format: output.FormatJSON}, legacyScan())
+
+	want := "Found 1 VPCs\nFound 2 Subnets\nFound 1 Route Tables\nFound 1 Security Groups\nFound 1 Internet Gateways\n" +
+		"Found 0 NAT Gateways\nFound 1 Transit Gateways\nFound 1 Transit Gateway Attachments\nFound 1 VPN Connections\n"
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+	if result.ResourceCounts["subnets"] != 2 || result.ResourceCounts["vpn_connections"] != 1 {
+		t.Errorf("counts = %v", result.ResourceCounts)
+	}
+	if _, ok := result.ResourceCounts["route_appliances"]; ok {
+		t.Error("the null route_appliances section was counted")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aws-documentor/modules/config"
+)
+
+// outputFlags is the part of the command line that decides what is written to stdout
+type outputFlags struct {
+	JSON          bool     // Value of -json
+	JSONSet       bool     // Whether -json was given explicitly
+	Silent        bool     // Value of -silent
+	Report        bool     // -legacy-stdout=false: the versioned report replaces the stdout output
+	FileOutputs   []string // File-producing flags that were given (-diagram, -pdf, -plantuml, ...)
+	StdoutReports []string // Flags whose only output is a report on stdout (-lifecycle, -sg-references, ...)
+}
+
+// outputOptions is the resolved output mode
+type outputOptions struct {
+	JSON   bool // Print resource JSON to stdout
+	Silent bool // Suppress all stdout output; warnings and errors still go to stderr
+	Report bool // Print only the versioned report to stdout; the legacy output goes to stderr
+}
+
+// resolveOutputOptions decides the output mode from the given flags
+// Resource JSON is printed by default only when no file output is requested; an explicit -json always wins.
+// -silent turns everything off and cannot be combined with flags that only print to stdout.
+// -legacy-stdout=false puts the versioned report on stdout in place of the resource JSON.
+// flags: Output-related flags from the command line
+// Returns: Resolved output mode, or error describing a conflicting combination
+func resolveOutputOptions(flags outputFlags) (outputOptions, error) {
+	if flags.Silent {
+		if flags.JSONSet && flags.JSON {
+			return outputOptions{}, fmt.Errorf("-silent cannot be combined with -json: -silent suppresses all stdout output")
+		}
+		if len(flags.StdoutReports) > 0 {
+			return outputOptions{}, fmt.Errorf("-silent cannot be combined with %s: these reports are only printed to stdout", strings.Join(flags.StdoutReports, ", "))
+		}
+		if flags.Report {
+			return outputOptions{}, fmt.Errorf("-silent cannot be combined with -legacy-stdout=false: -silent suppresses all stdout output (use -report-out to write the report to a file)")
+		}
+		return outputOptions{Silent: true}, nil
+	}
+
+	if flags.Report {
+		if flags.JSONSet && flags.JSON {
+			return outputOptions{}, fmt.Errorf("-json cannot be combined with -legacy-stdout=false: the versioned report holds the resources")
+		}
+		return outputOptions{Report: true}, nil
+	}
+
+	if flags.JSONSet {
+		return outputOptions{JSON: flags.JSON}, nil
+	}
+	return outputOptions{JSON: len(flags.FileOutputs) == 0}, nil
+}
+
+// flagDependencies maps flags that only take effect together with another flag to that flag
+var flagDependencies = map[string]string{
+	"include-dns-records":     "dns",
+	"endpoint-services":       "endpoint-coverage",
+	"dr-regions":              "dr-replication",
+	"plantuml-plain":          "plantuml",
+	"graph-format":            "graph-out",
+	"backstage-owner-tags":    "backstage-out",
+	"backstage-default-owner": "backstage-out",
+	"backstage-subnet-tiers":  "backstage-out",
+	"saas-catalog":            "third-party",
+	"resume":                  "checkpoint-dir",
+	"compare-with":            "pdf",
+	"template-out":            "template",
+	"path-properties":         "inspection-paths",
+	"mtu-threshold":           "inspection-paths",
+	"consistency-wait":        "consistency-recheck",
+	"profile-parallelism":     "profiles",
+	"profiles-dir":            "profiles",
+	"region-parallelism":      "all-regions",
+	"diagram-format":          "diagram",
+	"proxy-ports":             "egress-profiles",
+	"proxy-sg-tag":            "egress-profiles",
+	"flap-window":             "stability-history",
+	"flap-threshold":          "stability-history",
+	"dns-server":              "resolve-dns",
+	"dns-timeout":             "resolve-dns",
+	"dns-concurrency":         "resolve-dns",
+	"external-id":             "role-arn",
+}
+
+// checkFlagDependencies records a problem for every given flag whose companion flag was not given
+// v: Validator collecting the problems
+// given: Names of the flags that were given on the command line
+func checkFlagDependencies(v *config.Validator, given map[string]bool) {
+	names := make([]string, 0, len(flagDependencies))
+	for name := range flagDependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if required := flagDependencies[name]; given[name] && !given[required] {
+			v.Addf("-"+name, 0, "has no effect without -%s", required)
+		}
+	}
+}
+
+// repeatedFlag collects the values of a flag that can be given several times, such as -tag
+type repeatedFlag []string
+
+// String returns the values as the usage message shows them
+func (r *repeatedFlag) String() string {
+	return strings.Join(*r, ",")
+}
+
+// Set adds the value of one occurrence of the flag
+func (r *repeatedFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// parseTagFilters parses the KEY=VALUE pairs of -tag
+// values: One pair per -tag flag
+// Returns: Tag key to value (nil without pairs), or error for a pair without a key or "=", or a key given with two values
+func parseTagFilters(values []string) (map[string]string, error) {
+	var filters map[string]string
+	for _, pair := range values {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%q is not a KEY=VALUE pair", pair)
+		}
+		if previous, seen := filters[key]; seen && previous != value {
+			return nil, fmt.Errorf("tag %q is given with the values %q and %q; a resource carries only one", key, previous, value)
+		}
+		if filters == nil {
+			filters = make(map[string]string)
+		}
+		filters[key] = value
+	}
+	return filters, nil
+}
+
+// tagFilterKey normalizes the -tag filters into one string, the same whatever the order of the flags
+// It is part of the checkpoint fingerprint, so a resumed run with other filters starts over.
+// filters: Tag key to value from parseTagFilters
+// Returns: Sorted KEY=VALUE pairs joined by commas ("" without filters)
+func tagFilterKey(filters map[string]string) string {
+	pairs := make([]string, 0, len(filters))
+	for key, value := range filters {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// parsePorts parses a comma-separated list of TCP ports such as -proxy-ports
+// value: Flag value ("3128,8080")
+// Returns: The ports, or error for an entry that is not a port between 1 and 65535
+func parsePorts(value string) ([]int32, error) {
+	var ports []int32
+	for _, item := range splitList(value) {
+		port, err := strconv.Atoi(item)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("%q is not a port between 1 and 65535", item)
+		}
+		ports = append(ports, int32(port))
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("lists no ports")
+	}
+	return ports, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestResolveOutputOptions is the truth table of the output mode
+// The tree has no -output or -html flags; -diagram and -pdf stand for the file outputs, and the
+// stdout-only reports (-lifecycle, -public-ips, ...) for a report sent to stdout.
+func TestResolveOutputOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags outputFlags
+		want  outputOptions
+		err   string // Part of the usage error ("" for none)
+	}{
+		{name: "no flags", flags: outputFlags{JSON: true}, want: outputOptions{JSON: true}},
+		{name: "-json", flags: outputFlags{JSON: true, JSONSet: true}, want: outputOptions{JSON: true}},
+		{name: "-json=false", flags: outputFlags{JSON: false, JSONSet: true}, want: outputOptions{}},
+		{name: "-diagram", flags: outputFlags{JSON: true, FileOutputs: []string{"-diagram"}}, want: outputOptions{}},
+		{name: "-diagram -pdf", flags: outputFlags{JSON: true, FileOutputs: []string{"-diagram", "-pdf"}}, want: outputOptions{}},
+		{name: "-diagram -json", flags: outputFlags{JSON: true, JSONSet: true, FileOutputs: []string{"-diagram"}}, want: outputOptions{JSON: true}},
+		{name: "-diagram -json=false", flags: outputFlags{JSON: false, JSONSet: true, FileOutputs: []string{"-diagram"}}, want: outputOptions{}},
+		{name: "stdout report", flags: outputFlags{JSON: true, StdoutReports: []string{"-lifecycle"}}, want: outputOptions{JSON: true}},
+		{name: "stdout report -diagram", flags: outputFlags{JSON: true, FileOutputs: []string{"-diagram"}, StdoutReports: []string{"-lifecycle"}}, want: outputOptions{}},
+		{name: "-silent", flags: outputFlags{JSON: true, Silent: true}, want: outputOptions{Silent: true}},
+		{name: "-silent -diagram", flags: outputFlags{JSON: true, Silent: true, FileOutputs: []string{"-diagram"}}, want: outputOptions{Silent: true}},
+		{name: "-silent -json=false", flags: outputFlags{JSON: false, JSONSet: true, Silent: true}, want: outputOptions{Silent: true}},
+		{name: "-silent -json", flags: outputFlags{JSON: true, JSONSet: true, Silent: true}, err: "-silent cannot be combined with -json"},
+		{name: "-silent stdout report", flags: outputFlags{JSON: true, Silent: true, StdoutReports: []string{"-lifecycle", "-public-ips"}}, err: "-silent cannot be combined with -lifecycle, -public-ips"},
+		{name: "-silent -legacy-stdout=false", flags: outputFlags{JSON: true, Silent: true, Report: true}, err: "-silent cannot be combined with -legacy-stdout=false"},
+		{name: "-legacy-stdout=false", flags: outputFlags{JSON: true, Report: true}, want: outputOptions{Report: true}},
+		{name: "-legacy-stdout=false -diagram", flags: outputFlags{JSON: true, Report: true, FileOutputs: []string{"-diagram"}}, want: outputOptions{Report: true}},
+		{name: "-legacy-stdout=false -json=false", flags: outputFlags{JSON: false, JSONSet: true, Report: true}, want: outputOptions{Report: true}},
+		{name: "-legacy-stdout=false -json", flags: outputFlags{JSON: true, JSONSet: true, Report: true}, err: "-json cannot be combined with -legacy-stdout=false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveOutputOptions(tt.flags)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveOutputOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTagFilterKey checks that the same -tag filters give the same checkpoint key in any order
+func TestTagFilterKey(t *testing.T) {
+	first, err := parseTagFilters([]string{"env=prod", "team=network"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := parseTagFilters([]string{"team=network", "env=prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tagFilterKey(first) != tagFilterKey(second) {
+		t.Errorf("keys differ by flag order: %q and %q", tagFilterKey(first), tagFilterKey(second))
+	}
+	if key := tagFilterKey(first); key != "env=prod,team=network" {
+		t.Errorf("tagFilterKey() = %q, want %q", key, "env=prod,team=network")
+	}
+	if key := tagFilterKey(nil); key != "" {
+		t.Errorf("tagFilterKey(nil) = %q, want empty", key)
+	}
+}
+
+// TestParsePorts checks the -proxy-ports list and its errors
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int32
+		wantErr string
+	}{
+		{value: "3128,8080", want: []int32{3128, 8080}},
+		{value: " 3128 , 8443 ,", want: []int32{3128, 8443}},
+		{value: "65535", want: []int32{65535}},
+		{value: "", wantErr: "lists no ports"},
+		{value: "3128,squid", wantErr: `"squid" is not a port`},
+		{value: "0", wantErr: `"0" is not a port`},
+		{value: "65536", wantErr: `"65536" is not a port`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parsePorts(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parsePorts(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePorts(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
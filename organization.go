@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"aws-documentor/modules/crossaccount"
+	"aws-documentor/modules/report"
+)
+
+// organizationFile is the cross-account correlation written next to profiles.json and run.json
+const organizationFile = "organization.json"
+
+// savedScan is the -save file of one scan of a -profiles or run
+type savedScan struct {
+	name string // Profile, or target/region
+	path string // Path of the -save file
+}
+
+// loadAccountScans reads the -save files of the scans that finished
+// A file that cannot be read is logged and left out, so the other accounts are still correlated.
+// Returns: The scans that could be read
+func loadAccountScans(saved []savedScan) []crossaccount.AccountScan {
+	var scans []crossaccount.AccountScan
+	for _, s := range saved {
+		scan, err := report.Load(s.path)
+		if err != nil {
+			log.Printf("Warning: %s is left out of the cross-account correlation: %v", s.name, err)
+			continue
+		}
+		scans = append(scans, crossaccount.AccountScan{Name: s.name, Scan: scan})
+	}
+	return scans
+}
+
+// writeOrganization correlates the saved scans of several accounts, prints the organization-level findings
+// and writes organization.json to dir
+// options: Threshold for objects pending acceptance
+// Returns: The correlation, or error if organization.json cannot be written
+func writeOrganization(w io.Writer, dir string, scans []crossaccount.AccountScan, options crossaccount.Options) (*crossaccount.Correlation, error) {
+	correlation := crossaccount.Correlate(scans, options)
+
+	matched := 0
+	for _, obj := range correlation.Objects {
+		if obj.Status == crossaccount.StatusMatched {
+			matched++
+		}
+	}
+	fmt.Fprintf(w, "\nCross-account objects of %d scans: %d, %d matched on every side\n", len(scans), len(correlation.Objects), matched)
+	if len(correlation.Findings) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SEVERITY\tKIND\tID\tFINDING\tREASON")
+		for _, finding := range correlation.Findings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", finding.Severity, finding.Kind, finding.ID, finding.Classification, finding.Reason)
+		}
+		tw.Flush()
+	}
+
+	data, err := json.MarshalIndent(correlation, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, organizationFile), data, 0o644); err != nil {
+		return nil, err
+	}
+	return correlation, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/crossaccount"
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/vpc"
+)
+
+// TestWriteOrganization checks that the -save files of the profiles are correlated into organization.json,
+// and that a profile without a readable -save file is left out rather than failing the correlation
+func TestWriteOrganization(t *testing.T) {
+	dir := t.TempDir()
+	peering := vpc.VpcPeeringConnectionInfo{VpcPeeringConnectionID: "pcx-1", RequesterVpcID: "vpc-n", RequesterOwnerID: "111111111111", RequesterRegion: "eu-west-1",
+		AccepterVpcID: "vpc-w", AccepterOwnerID: "222222222222", AccepterRegion: "eu-west-1", Status: "active"}
+	saves := map[string]*report.ScanResult{
+		"network":  {ScanTime: time.Now().UTC(), AccountID: "111111111111", Region: "eu-west-1", VpcPeerings: []vpc.VpcPeeringConnectionInfo{peering}},
+		"workload": {ScanTime: time.Now().UTC(), AccountID: "222222222222", Region: "eu-west-1"},
+	}
+	var saved []savedScan
+	for _, name := range []string{"network", "workload"} {
+		path := filepath.Join(dir, name+".json")
+		if err := report.Save(path, saves[name]); err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, savedScan{name: name, path: path})
+	}
+	saved = append(saved, savedScan{name: "unreadable", path: filepath.Join(dir, "missing.json")})
+
+	scans := loadAccountScans(saved)
+	if len(scans) != 2 {
+		t.Fatalf("loadAccountScans() read %d scans, want 2", len(scans))
+	}
+	if _, err := writeOrganization(io.Discard, dir, scans, crossaccount.DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, organizationFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var correlation crossaccount.Correlation
+	if err := json.Unmarshal(data, &correlation); err != nil {
+		t.Fatal(err)
+	}
+	if len(correlation.Findings) != 1 || correlation.Findings[0].ID != "pcx-1" || correlation.Findings[0].Classification != crossaccount.FindingSideMissing {
+		t.Errorf("findings = %+v, want the missing accepter side of pcx-1", correlation.Findings)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/crossaccount"
+)
+
+// Profile scan outcomes
+const (
+	profileScanned   = "scanned"    // The scan of the profile finished
+	profileAuthError = "auth-error" // The profile's credentials could not be resolved, so it was not scanned
+	profileFailed    = "failed"     // The scan of the profile exited with an error
+)
+
+// profilesSummaryFile is the summary written to -profiles-dir after every profile was scanned
+const profilesSummaryFile = "profiles.json"
+
+// profileSession is a profile with its credentials resolved
+type profileSession struct {
+	Profile     string          // Name of the profile in the shared config files
+	AccountID   string          // Account of the credentials
+	Region      string          // Region to scan: -region, or the profile's region
+	Credentials aws.Credentials // Resolved credentials, handed to the scan of the profile
+}
+
+// ProfileResult is the outcome of the scan of one profile
+type ProfileResult struct {
+	Profile    string `json:"profile"`         // Name of the profile
+	AccountID  string `json:"account_id"`      // Account of the profile (empty if its credentials could not be resolved)
+	Region     string `json:"region"`          // Region that was scanned
+	Directory  string `json:"directory"`       // Directory with the outputs and scan.log of the profile (empty if not scanned)
+	Status     string `json:"status"`          // scanned, auth-error or failed
+	Error      string `json:"error,omitempty"` // Why the profile was not scanned or the scan failed
+	DurationMs int64  `json:"duration_ms"`     // Duration of the scan in milliseconds
+}
+
+// ProfilesSummary is written to profiles.json after a -profiles run
+type ProfilesSummary struct {
+	Profiles []ProfileResult `json:"profiles"` // Per-profile outcome, in the order of -profiles
+	Failed   int             `json:"failed"`   // Profiles that were not scanned or whose scan failed
+}
+
+// profileScan scans several profiles, each in its own directory
+type profileScan struct {
+	load        func(ctx context.Context, profile string) (profileSession, error)   // Resolves the credentials of a profile
+	scan        func(ctx context.Context, session profileSession, dir string) error // Scans one profile, writing its outputs to dir
+	parallelism int                                                                 // Profiles scanned at the same time
+	dir         string                                                              // Directory holding the per-profile directories
+	now         func() time.Time                                                    // Clock, replaceable for tests
+}
+
+// run resolves the credentials of every profile and then scans them concurrently
+// Credentials are resolved one profile after the other before any scan starts, so MFA token and
+// SSO prompts never interleave and the scans themselves run without a terminal. A profile whose
+// credentials cannot be resolved or whose scan fails is recorded and the others carry on.
+// ctx: Context for loading credentials and running the scans
+// profiles: Profile names, in the order of -profiles
+// Returns: Summary of every profile
+func (p *profileScan) run(ctx context.Context, profiles []string) *ProfilesSummary {
+	summary := &ProfilesSummary{Profiles: make([]ProfileResult, len(profiles))}
+	sessions := make([]profileSession, len(profiles))
+	for i, profile := range profiles {
+		summary.Profiles[i] = ProfileResult{Profile: profile}
+		session, err := p.load(ctx, profile)
+		if err != nil {
+			summary.Profiles[i].Status = profileAuthError
+			summary.Profiles[i].Error = err.Error()
+			continue
+		}
+		sessions[i] = session
+		summary.Profiles[i].AccountID = session.AccountID
+		summary.Profiles[i].Region = session.Region
+		summary.Profiles[i].Directory = filepath.Join(p.dir, profileDirName(session))
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(p.parallelism, 1))
+	for i := range profiles {
+		result := &summary.Profiles[i]
+		if result.Status == profileAuthError {
+			continue
+		}
+		wg.Add(1)
+		go func(session profileSession) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			start := p.now()
+			result.Status = profileScanned
+			if err := os.MkdirAll(result.Directory, 0o755); err != nil {
+				result.Status, result.Error = profileFailed, err.Error()
+			} else if err := p.scan(ctx, session, result.Directory); err != nil {
+				result.Status, result.Error = profileFailed, err.Error()
+			}
+			result.DurationMs = p.now().Sub(start).Milliseconds()
+		}(sessions[i])
+	}
+	wg.Wait()
+
+	for _, result := range summary.Profiles {
+		if result.Status != profileScanned {
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// profileDirName names the directory of a profile after the profile and its account
+// Characters that are not safe in file names are replaced with "_".
+func profileDirName(session profileSession) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_' {
+			return r
+		}
+		return '_'
+	}, session.Profile)
+	return name + "-" + session.AccountID
+}
+
+// loadProfileSession resolves the credentials and account of a profile
+// Retrieving the credentials is what prompts for an MFA token code, so callers must not run it concurrently.
+// roleARN: Role to assume with the profile's credentials (empty for none)
+// region: -region, or empty to use the profile's region
+// opts: Settings for the HTTP client
+func loadProfileSession(ctx context.Context, profile, roleARN, region string, opts awsconfig.HTTPOptions) (profileSession, error) {
+	cfg, _, err := awsconfig.LoadProfile(ctx, region, profile, opts)
+	if err != nil {
+		return profileSession{}, fmt.Errorf("failed to load profile: %w", err)
+	}
+	if roleARN != "" {
+		cfg = awsconfig.AssumeRole(cfg, roleARN)
+	}
+	if cfg.Region == "" {
+		return profileSession{}, fmt.Errorf("profile has no region; set one in the profile or use -region")
+	}
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return profileSession{}, fmt.Errorf("failed to retrieve credentials (for SSO profiles, run \"aws sso login --profile %s\" first): %w", profile, err)
+	}
+	accountID, err := awsconfig.AccountID(ctx, cfg)
+	if err != nil {
+		return profileSession{}, err
+	}
+	return profileSession{Profile: profile, AccountID: accountID, Region: cfg.Region, Credentials: credentials}, nil
+}
+
+// profileEnvironment returns the environment of the scan of a profile
+// Profile and credential variables of the parent are replaced by the profile's resolved credentials,
+// so the scan uses them without reading the shared config files or prompting again.
+func profileEnvironment(session profileSession) []string {
+	var env []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		switch name {
+		case "AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_REGION", "AWS_DEFAULT_REGION":
+			continue
+		}
+		env = append(env, variable)
+	}
+	env = append(env,
+		"AWS_ACCESS_KEY_ID="+session.Credentials.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY="+session.Credentials.SecretAccessKey,
+		"AWS_REGION="+session.Region,
+	)
+	if session.Credentials.SessionToken != "" {
+		env = append(env, "AWS_SESSION_TOKEN="+session.Credentials.SessionToken)
+	}
+	return env
+}
+
+// runProfileScan runs this program for one profile with the scan flags, in the profile's directory
+// Relative output paths therefore land in the profile's directory. Stdout and stderr go to scan.log there.
+// args: Flags of the scan, without the -profiles flags
+func runProfileScan(ctx context.Context, session profileSession, dir string, args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logFile, err := os.Create(filepath.Join(dir, "scan.log"))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Dir = dir
+	cmd.Env = profileEnvironment(session)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Run(); err != nil {
+		// A partial scan, a -fail-on finding or an exhausted -max-api-calls still wrote the outputs
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			switch exitErr.ExitCode() {
+			case exitPartial, exitFindings, exitBudgetExceeded:
+				return nil
+			}
+		}
+		return fmt.Errorf("scan exited with %v; see %s", err, filepath.Join(dir, "scan.log"))
+	}
+	return nil
+}
+
+// writeProfilesSummary prints the outcome of every profile and writes profiles.json to dir
+func writeProfilesSummary(w io.Writer, dir string, summary *ProfilesSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tACCOUNT\tREGION\tSTATUS\tDIRECTORY")
+	for _, result := range summary.Profiles {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.Profile, result.AccountID, result.Region, result.Status, result.Directory)
+	}
+	tw.Flush()
+	for _, result := range summary.Profiles {
+		if result.Error != "" {
+			fmt.Fprintf(w, "%s: %s\n", result.Profile, result.Error)
+		}
+	}
+
+	summaryJSON, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, profilesSummaryFile), summaryJSON, 0o644)
+}
+
+// scanProfiles resolves the -profiles one at a time and runs one scan per profile with the other flags
+// Returns: Error if the summary cannot be written or a profile was not scanned
+func (run *scanRun) scanProfiles() error {
+	ctx := context.Background()
+	args := profileScanArgs()
+	scans := &profileScan{
+		load: func(ctx context.Context, profile string) (profileSession, error) {
+			return loadProfileSession(ctx, profile, "", *run.region, run.httpOptions)
+		},
+		scan: func(ctx context.Context, session profileSession, dir string) error {
+			return runProfileScan(ctx, session, dir, args)
+		},
+		parallelism: *run.profileParallelism,
+		dir:         *run.profilesDir,
+		now:         time.Now,
+	}
+	summary := scans.run(ctx, splitList(*run.profiles))
+	if err := writeProfilesSummary(os.Stdout, *run.profilesDir, summary); err != nil {
+		return fmt.Errorf("Failed to write %s: %w", profilesSummaryFile, err)
+	}
+	run.result.output(filepath.Join(*run.profilesDir, profilesSummaryFile))
+	if err := run.correlateProfiles(summary); err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d profiles were not scanned; see %s", summary.Failed, len(summary.Profiles), filepath.Join(*run.profilesDir, profilesSummaryFile))
+	}
+	return nil
+}
+
+// correlateProfiles correlates the -save files of the scanned profiles and writes organization.json to -profiles-dir
+// Without -save there is nothing to correlate. Every organization-level finding is counted in the run result.
+// Returns: Error if organization.json cannot be written
+func (run *scanRun) correlateProfiles(summary *ProfilesSummary) error {
+	if *run.saveFile == "" {
+		fmt.Println("No cross-account correlation: use -save so every profile saves its scan")
+		return nil
+	}
+	var saved []savedScan
+	for _, result := range summary.Profiles {
+		if result.Status == profileScanned {
+			saved = append(saved, savedScan{name: result.Profile, path: filepath.Join(result.Directory, *run.saveFile)})
+		}
+	}
+	options := crossaccount.DefaultOptions()
+	options.PendingThreshold = *run.pendingThreshold
+	correlation, err := writeOrganization(os.Stdout, *run.profilesDir, loadAccountScans(saved), options)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %w", organizationFile, err)
+	}
+	run.result.output(filepath.Join(*run.profilesDir, organizationFile))
+	for _, finding := range correlation.Findings {
+		run.result.finding(finding.Severity)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// fakeProfiles drives profileScan with fake credentials and scans
+// It records how many loads and scans run at once and whether a scan started before every profile was loaded.
+type fakeProfiles struct {
+	mu          sync.Mutex
+	loading     int      // Loads running now
+	maxLoading  int      // Most loads that ran at once
+	scanning    int      // Scans running now
+	maxScanning int      // Most scans that ran at once
+	loaded      int      // Loads finished
+	earlyScan   bool     // Whether a scan started while profiles were still being loaded
+	dirs        []string // Directories the scans ran in
+	failAuth    string   // Profile whose credentials cannot be resolved
+	failScan    string   // Profile whose scan fails
+	total       int      // Number of profiles of the run
+}
+
+func (f *fakeProfiles) load(ctx context.Context, profile string) (profileSession, error) {
+	f.mu.Lock()
+	f.loading++
+	f.maxLoading = max(f.maxLoading, f.loading)
+	f.mu.Unlock()
+	// An MFA prompt takes a while; overlapping loads would show here
+	time.Sleep(5 * time.Millisecond)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loading--
+	f.loaded++
+	if profile == f.failAuth {
+		return profileSession{}, errors.New("failed to retrieve credentials: token expired")
+	}
+	account := map[string]string{"net-dev": "111111111111", "net-staging": "222222222222", "net/prod": "333333333333"}[profile]
+	return profileSession{Profile: profile, AccountID: account, Region: "eu-west-1",
+		Credentials: aws.Credentials{AccessKeyID: "ASIA" + account, SecretAccessKey: "secret"}}, nil
+}
+
+func (f *fakeProfiles) scan(ctx context.Context, session profileSession, dir string) error {
+	f.mu.Lock()
+	f.scanning++
+	f.maxScanning = max(f.maxScanning, f.scanning)
+	if f.loaded < f.total {
+		f.earlyScan = true
+	}
+	f.dirs = append(f.dirs, dir)
+	f.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scanning--
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	if session.Profile == f.failScan {
+		return errors.New("scan exited with exit status 1")
+	}
+	return nil
+}
+
+// fakeClock advances by one second on every call, from any goroutine
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+// TestProfileScan checks that credentials are resolved one profile at a time before any scan, that scans run
+// in parallel up to the limit in per-profile directories, and that a profile failing authentication or its scan
+// does not stop the others
+func TestProfileScan(t *testing.T) {
+	tests := []struct {
+		name        string
+		parallelism int
+		failAuth    string
+		failScan    string
+		want        []ProfileResult
+		wantFailed  int
+	}{
+		{name: "all scanned", parallelism: 3, wantFailed: 0, want: []ProfileResult{
+			{Profile: "net-dev", AccountID: "111111111111", Region: "eu-west-1", Directory: "net-dev-111111111111", Status: profileScanned},
+			{Profile: "net-staging", AccountID: "222222222222", Region: "eu-west-1", Directory: "net-staging-222222222222", Status: profileScanned},
+			{Profile: "net/prod", AccountID: "333333333333", Region: "eu-west-1", Directory: "net_prod-333333333333", Status: profileScanned},
+		}},
+		{name: "authentication failure", parallelism: 1, failAuth: "net-staging", wantFailed: 1, want: []ProfileResult{
+			{Profile: "net-dev", AccountID: "111111111111", Region: "eu-west-1", Directory: "net-dev-111111111111", Status: profileScanned},
+			{Profile: "net-staging", Status: profileAuthError, Error: "failed to retrieve credentials: token expired"},
+			{Profile: "net/prod", AccountID: "333333333333", Region: "eu-west-1", Directory: "net_prod-333333333333", Status: profileScanned},
+		}},
+		{name: "scan failure", parallelism: 2, failAuth: "net-dev", failScan: "net/prod", wantFailed: 2, want: []ProfileResult{
+			{Profile: "net-dev", Status: profileAuthError, Error: "failed to retrieve credentials: token expired"},
+			{Profile: "net-staging", AccountID: "222222222222", Region: "eu-west-1", Directory: "net-staging-222222222222", Status: profileScanned},
+			{Profile: "net/prod", AccountID: "333333333333", Region: "eu-west-1", Directory: "net_prod-333333333333", Status: profileFailed,
+				Error: "scan exited with exit status 1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			profiles := []string{"net-dev", "net-staging", "net/prod"}
+			fake := &fakeProfiles{failAuth: tt.failAuth, failScan: tt.failScan, total: len(profiles)}
+			scans := &profileScan{load: fake.load, scan: fake.scan, parallelism: tt.parallelism, dir: dir,
+				now: (&fakeClock{now: time.Unix(0, 0)}).Now}
+
+			summary := scans.run(context.Background(), profiles)
+			for i := range tt.want {
+				if tt.want[i].Directory != "" {
+					tt.want[i].Directory = filepath.Join(dir, tt.want[i].Directory)
+				}
+			}
+			// Scans running at once share the clock, so only the scanned profiles having a duration is checked
+			for i := range summary.Profiles {
+				if scanned := summary.Profiles[i].Directory != ""; (summary.Profiles[i].DurationMs > 0) != scanned {
+					t.Errorf("%s took %d ms", summary.Profiles[i].Profile, summary.Profiles[i].DurationMs)
+				}
+				summary.Profiles[i].DurationMs = 0
+			}
+			if !reflect.DeepEqual(summary.Profiles, tt.want) || summary.Failed != tt.wantFailed {
+				t.Errorf("run() = %+v (%d failed)\nwant %+v (%d failed)", summary.Profiles, summary.Failed, tt.want, tt.wantFailed)
+			}
+			if fake.maxLoading != 1 || fake.earlyScan {
+				t.Errorf("%d loads ran at once, scan before every profile was loaded: %v; want one at a time, before any scan", fake.maxLoading, fake.earlyScan)
+			}
+			scanned := 0
+			for _, result := range tt.want {
+				if result.Directory != "" {
+					scanned++
+				}
+			}
+			if fake.maxScanning > tt.parallelism || len(fake.dirs) != scanned {
+				t.Errorf("%d of %d scans ran at once, want at most %d", fake.maxScanning, len(fake.dirs), tt.parallelism)
+			}
+			if tt.parallelism > 1 && fake.maxScanning < 2 {
+				t.Errorf("scans ran one at a time, want up to %d in parallel", tt.parallelism)
+			}
+		})
+	}
+}
+
+// TestProfileEnvironment checks that the parent's profile and credential variables are replaced by the
+// resolved credentials of the profile
+func TestProfileEnvironment(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "net-dev")
+	t.Setenv("AWS_SESSION_TOKEN", "parent-token")
+	t.Setenv("AWS_DEFAULT_REGION", "us-east-1")
+	t.Setenv("AWS_SDK_LOAD_CONFIG", "1")
+
+	variables := func(env []string) map[string]string {
+		values := make(map[string]string)
+		for _, variable := range env {
+			name, value, _ := strings.Cut(variable, "=")
+			values[name] = value
+		}
+		return values
+	}
+	session := profileSession{Profile: "net-prod", AccountID: "333333333333", Region: "eu-west-1",
+		Credentials: aws.Credentials{AccessKeyID: "AKIAPROD", SecretAccessKey: "secret"}}
+	env := variables(profileEnvironment(session))
+	for name, want := range map[string]string{"AWS_ACCESS_KEY_ID": "AKIAPROD", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_REGION": "eu-west-1", "AWS_SDK_LOAD_CONFIG": "1"} {
+		if env[name] != want {
+			t.Errorf("%s = %q, want %q", name, env[name], want)
+		}
+	}
+	for _, name := range []string{"AWS_PROFILE", "AWS_SESSION_TOKEN", "AWS_DEFAULT_REGION"} {
+		if value, ok := env[name]; ok {
+			t.Errorf("%s = %q, want it removed", name, value)
+		}
+	}
+
+	session.Credentials.SessionToken = "profile-token"
+	if got := variables(profileEnvironment(session))["AWS_SESSION_TOKEN"]; got != "profile-token" {
+		t.Errorf("AWS_SESSION_TOKEN = %q, want the profile's session token", got)
+	}
+}
+
+// TestWriteProfilesSummary checks the printed table and errors, and the profiles.json written next to the profile directories
+func TestWriteProfilesSummary(t *testing.T) {
+	dir := t.TempDir()
+	summary := &ProfilesSummary{Failed: 1, Profiles: []ProfileResult{
+		{Profile: "net-dev", AccountID: "111111111111", Region: "eu-west-1", Directory: "profiles/net-dev-111111111111", Status: profileScanned, DurationMs: 1200},
+		{Profile: "net-staging", Status: profileAuthError, Error: "failed to retrieve credentials: token expired"},
+	}}
+	var out strings.Builder
+	if err := writeProfilesSummary(&out, dir, summary); err != nil {
+		t.Fatal(err)
+	}
+	want := `PROFILE      ACCOUNT       REGION     STATUS      DIRECTORY
+net-dev      111111111111  eu-west-1  scanned     profiles/net-dev-111111111111
+net-staging                           auth-error  
+net-staging: failed to retrieve credentials: token expired
+`
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, profilesSummaryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written ProfilesSummary
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&written, summary) {
+		t.Errorf("%s = %+v, want %+v", profilesSummaryFile, written, summary)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/query"
+)
+
+// runQuery implements "aws-documentor query [flags] EXPRESSION"
+// It evaluates a JMESPath expression over a saved JSON report, or over a fresh scan of the core VPC
+// resources, and prints the result as JSON, a table or CSV. Flags go before the expression.
+// args: Command-line arguments after the subcommand
+func runQuery(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	fromFile := flags.String("from-file", "", "JSON report from an earlier scan to query instead of scanning")
+	freshness := registerFreshnessFlags(flags, "-from-file")
+	formatFlag := flags.String("format", "json", "Output format: json, table or csv")
+	schema := flags.Bool("schema", false, "List the collections and fields expressions can use, and exit")
+	region := flags.String("region", "", "AWS region to scan (optional, uses default config if not specified)")
+	proxy := flags.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	format, err := query.ParseFormat(*formatFlag)
+	problems.Check("-format", err)
+	if *region != "" {
+		problems.Check("-region", config.CheckRegion(*region))
+	}
+	if *proxy != "" {
+		_, err := awsconfig.ParseProxy(*proxy)
+		problems.Check("-proxy", err)
+	}
+	if *fromFile != "" && (*region != "" || *proxy != "") {
+		problems.Addf("-from-file", 0, "cannot be combined with -region or -proxy")
+	}
+	freshness.validate(problems, *fromFile != "")
+	switch {
+	case *schema && flags.NArg() > 0:
+		problems.Addf("-schema", 0, "cannot be combined with an expression")
+	case !*schema && flags.NArg() == 0:
+		problems.Addf("EXPRESSION", 0, "missing, for example: query -format table 'subnets[].{id: subnet_id, cidr: cidr_block}'")
+	}
+	for _, arg := range flags.Args()[min(1, flags.NArg()):] {
+		problems.Addf(arg, 0, "unexpected argument (flags go before the expression, and the expression must be quoted)")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	if *schema {
+		if err := query.WriteSchema(os.Stdout); err != nil {
+			log.Fatalf("Failed to write schema: %v", err)
+		}
+		return
+	}
+
+	// Checked before scanning, so a typo does not cost a scan
+	q, err := query.Compile(flags.Arg(0), query.JMESPath())
+	if err != nil {
+		log.Fatalf("Invalid expression: %v", err)
+	}
+
+	var report *query.Report
+	if *fromFile != "" {
+		if report, err = query.LoadReport(*fromFile); err != nil {
+			log.Fatalf("Failed to load report: %v", err)
+		}
+		freshness.checkSubcommand(report.ScannedAt)
+	} else {
+		ctx := context.Background()
+		scannedAt := time.Now().UTC()
+		scan := scanCoreResources(ctx, loadSubcommandConfig(ctx, *region, *proxy))
+		report = &query.Report{
+			ScannedAt:        scannedAt.Format(time.RFC3339),
+			VPCs:             scan.VPCs,
+			Subnets:          scan.Subnets,
+			RouteTables:      scan.RouteTables,
+			SecurityGroups:   scan.SecurityGroups,
+			InternetGateways: scan.InternetGateways,
+			NatGateways:      scan.NatGateways,
+			TransitGateways:  scan.TransitGateways,
+			TGWAttachments:   scan.TGWAttachments,
+		}
+	}
+
+	result, err := q.Run(report)
+	if err != nil {
+		log.Fatalf("Query failed: %v", err)
+	}
+	if err := query.Write(os.Stdout, result, q.Columns, format); err != nil {
+		log.Fatalf("Failed to write result: %v", err)
+	}
+}
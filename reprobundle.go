@@ -0,0 +1,148 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/anonymize"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/output"
+)
+
+// runReproBundle implements "aws-documentor repro-bundle [flags]"
+// It anonymizes a saved JSON report, or a fresh scan of the core VPC resources, and writes a zip with
+// the anonymized report and the diagram generated from it, for attaching to bug reports.
+// args: Command-line arguments after the subcommand
+func runReproBundle(args []string) {
+	flags := flag.NewFlagSet("repro-bundle", flag.ExitOnError)
+	fromFile := flags.String("from-file", "", "JSON report from an earlier scan to anonymize instead of scanning")
+	freshness := registerFreshnessFlags(flags, "-from-file")
+	outputFile := flags.String("output", "repro-bundle.zip", "Zip file to write the anonymized report and diagram to")
+	region := flags.String("region", "", "AWS region to scan (optional, uses default config if not specified)")
+	proxy := flags.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	problems.Check("-output", config.CheckOutputFile(*outputFile))
+	if *region != "" {
+		problems.Check("-region", config.CheckRegion(*region))
+	}
+	if *proxy != "" {
+		_, err := awsconfig.ParseProxy(*proxy)
+		problems.Check("-proxy", err)
+	}
+	if *fromFile != "" && (*region != "" || *proxy != "") {
+		problems.Addf("-from-file", 0, "cannot be combined with -region or -proxy")
+	}
+	freshness.validate(problems, *fromFile != "")
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	var report *anonymize.Report
+	if *fromFile != "" {
+		var err error
+		if report, err = anonymize.LoadReport(*fromFile); err != nil {
+			log.Fatalf("Failed to load report: %v", err)
+		}
+		freshness.checkSubcommand(report.ScannedAt)
+	} else {
+		ctx := context.Background()
+		scannedAt := time.Now().UTC()
+		scan := scanCoreResources(ctx, loadSubcommandConfig(ctx, *region, *proxy))
+		report = &anonymize.Report{
+			ScannedAt:        scannedAt.Format(time.RFC3339),
+			VPCs:             scan.VPCs,
+			Subnets:          scan.Subnets,
+			RouteTables:      scan.RouteTables,
+			SecurityGroups:   scan.SecurityGroups,
+			InternetGateways: scan.InternetGateways,
+			NatGateways:      scan.NatGateways,
+			TransitGateways:  scan.TransitGateways,
+			TGWAttachments:   scan.TGWAttachments,
+		}
+	}
+
+	anonymized, err := anonymize.Anonymize(report)
+	if err != nil {
+		log.Fatalf("Failed to anonymize report: %v", err)
+	}
+
+	// The bundle is only useful if it reproduces the layout, so the two diagrams are compared
+	originalXML, err := reproDiagram(report)
+	if err != nil {
+		log.Fatalf("Failed to generate diagram of the original report: %v", err)
+	}
+	diagramXML, err := reproDiagram(anonymized)
+	if err != nil {
+		log.Fatalf("Failed to generate diagram of the anonymized report: %v", err)
+	}
+	originalStructure, originalCells, _ := diagram.Structure(originalXML)
+	structure, cells, _ := diagram.Structure(diagramXML)
+	if structure != originalStructure {
+		log.Printf("Warning: the anonymized diagram has %d cells and a different nesting than the original (%d cells); the bundle may not reproduce the layout", cells, originalCells)
+	}
+
+	reportJSON, err := output.MarshalIndent(anonymized, output.FieldStyleSnake)
+	if err != nil {
+		log.Fatalf("Failed to encode anonymized report: %v", err)
+	}
+	if err := writeReproBundle(*outputFile, reportJSON, diagramXML); err != nil {
+		log.Fatalf("Failed to write bundle: %v", err)
+	}
+	fmt.Printf("Anonymized report and diagram (%d cells) saved to: %s\n", cells, *outputFile)
+	fmt.Println("Check the report before sharing it: values this tool does not recognize as confidential are kept")
+}
+
+// reproDiagram generates the overview diagram of a report the way a scan with -diagram does
+func reproDiagram(report *anonymize.Report) (string, error) {
+	isolation := analysis.AnalyzeIsolation(report.VPCs, report.RouteTables, report.NatGateways, report.TGWAttachments, nil)
+	diagramGen := diagram.NewDiagramGenerator()
+	diagramGen.SetVPCConnectivity(isolation.VPCs)
+	return diagramGen.GenerateVPCDiagram(
+		report.VPCs,
+		report.Subnets,
+		report.RouteTables,
+		report.SecurityGroups,
+		report.InternetGateways,
+		report.NatGateways,
+		report.TransitGateways,
+		report.TGWAttachments,
+	)
+}
+
+// writeReproBundle writes the anonymized report and diagram to a zip archive
+// The names match the objects the Lambda function writes, so the report loads with -from-file as is.
+func writeReproBundle(path string, reportJSON []byte, diagramXML string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+	for name, content := range map[string][]byte{"report.json": reportJSON, "vpc-diagram.drawio": []byte(diagramXML)} {
+		w, err := archive.Create(name)
+		if err == nil {
+			_, err = w.Write(content)
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
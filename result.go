@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/vpc"
+)
+
+// Exit codes of a scan; scripts can rely on them, so existing codes never change meaning
+const (
+	exitSuccess        = 0 // The scan ran to the end and no finding reached -fail-on
+	exitFailed         = 1 // The run stopped on an error without a more specific code, such as an output that could not be written
+	exitUsage          = 2 // The command line could not be parsed; set by the flag package, before -result-file is known
+	exitPartial        = 3 // The scan ran to the end, but a requested scanner was skipped after an error or resumed paginations may have missed changes
+	exitFindings       = 4 // A finding reached the -fail-on severity, or the diff subcommand found changes
+	exitConfigError    = 5 // Invalid flags or config files, or a region the account has not enabled
+	exitAuthError      = 6 // No usable credentials, or they are denied an operation the scan needs
+	exitBudgetExceeded = 7 // -max-api-calls refused calls, so the outputs are partial
+	exitThrottled      = 8 // AWS kept throttling a call the scan cannot do without
+)
+
+// Run statuses of the -result-file, one for each exit code
+const (
+	statusSuccess        = "success"
+	statusFailed         = "failed"
+	statusPartial        = "partial"
+	statusFindings       = "findings"
+	statusConfigError    = "config-error"
+	statusAuthError      = "auth-error"
+	statusBudgetExceeded = "budget-exceeded"
+	statusThrottled      = "throttled"
+)
+
+// Error categories of a run besides those of vpc.ScanError, checked with errors.Is
+var (
+	errInvalidConfig = errors.New("invalid configuration") // The flags or config files are invalid
+	errNoCredentials = errors.New("no credentials")        // The AWS config or credentials could not be loaded
+)
+
+// runError is an error that stopped a scan, with the category that decides the exit code
+type runError struct {
+	Category error // errInvalidConfig or errNoCredentials
+	Err      error // The error itself
+	Logged   bool  // The error was printed already, so main does not log it again
+}
+
+// Error returns the message of the wrapped error
+func (e *runError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error, so errors.Is also reaches scanner categories
+func (e *runError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error belongs to the given category
+func (e *runError) Is(target error) bool {
+	return target == e.Category
+}
+
+// configError marks an error as a problem with the flags or config files
+func configError(err error) error {
+	return &runError{Category: errInvalidConfig, Err: err}
+}
+
+// runOutcome is what the status and exit code of a scan are decided from
+type runOutcome struct {
+	Err            error // Error that stopped the run (nil if it ran to the end)
+	Findings       bool  // A finding reached the -fail-on severity
+	BudgetExceeded bool  // -max-api-calls refused calls
+	Partial        bool  // A requested scanner was skipped after an error, or resumed paginations may have missed changes
+}
+
+// runStatus decides the status and exit code of a scan
+// This is the only place exit codes are chosen. An error decides alone, by its category. A run that
+// finished fails the -fail-on gate before it counts as incomplete, since the findings of a partial
+// scan are still real; of the incomplete runs, one cut short by -max-api-calls is the more specific.
+//
+//	Err                               Findings  BudgetExceeded  Partial    status           code
+//	invalid config, region disabled   -         -               -          config-error     5
+//	no credentials, access denied     -         -               -          auth-error       6
+//	throttled                         -         -               -          throttled        8
+//	API call budget exceeded          -         -               -          budget-exceeded  7
+//	any other error                   -         -               -          failed           1
+//	nil                               true      -               -          findings         4
+//	nil                               false     true            -          budget-exceeded  7
+//	nil                               false     false           true       partial          3
+//	nil                               false     false           false      success          0
+//
+// outcome: How the run ended
+// Returns: Status for the -result-file and the exit code
+func runStatus(outcome runOutcome) (string, int) {
+	if err := outcome.Err; err != nil {
+		category := vpc.ClassifyError(err)
+		switch {
+		case errors.Is(err, errInvalidConfig), errors.Is(err, vpc.ErrRegionDisabled), category == vpc.ErrRegionDisabled:
+			return statusConfigError, exitConfigError
+		case errors.Is(err, errNoCredentials), errors.Is(err, vpc.ErrAccessDenied), category == vpc.ErrAccessDenied:
+			return statusAuthError, exitAuthError
+		case errors.Is(err, vpc.ErrThrottled), category == vpc.ErrThrottled:
+			return statusThrottled, exitThrottled
+		case errors.Is(err, awsconfig.ErrCallBudgetExceeded):
+			return statusBudgetExceeded, exitBudgetExceeded
+		}
+		return statusFailed, exitFailed
+	}
+	switch {
+	case outcome.Findings:
+		return statusFindings, exitFindings
+	case outcome.BudgetExceeded:
+		return statusBudgetExceeded, exitBudgetExceeded
+	case outcome.Partial:
+		return statusPartial, exitPartial
+	}
+	return statusSuccess, exitSuccess
+}
+
+// severityRanks orders the severities -fail-on accepts; other finding kinds, such as recommendations, never fail a run
+var severityRanks = map[string]int{analysis.SeverityLow: 1, analysis.SeverityMedium: 2, analysis.SeverityHigh: 3}
+
+// RunResult is the -result-file summary of a scan
+// It is written however the run ends, except when the flags cannot be parsed, so automation can read
+// what happened instead of parsing stderr. Counts and outputs cover what was done before a failure.
+type RunResult struct {
+	Status             string         `json:"status"`               // success, partial, findings, budget-exceeded, config-error, auth-error, throttled or failed
+	ExitCode           int            `json:"exit_code"`            // Exit code of the process
+	Error              string         `json:"error,omitempty"`      // Error that stopped the run
+	Region             string         `json:"region"`               // Scanned region (empty if the run stopped before the AWS config was loaded)
+	AccountID          string         `json:"account_id"`           // Scanned account (empty if it could not be identified)
+	StartedAt          time.Time      `json:"started_at"`           // When the run started
+	DurationMs         int64          `json:"duration_ms"`          // Duration of the run in milliseconds
+	ResourceCounts     map[string]int `json:"resource_counts"`      // Resources per type, named as in the JSON output, for the types that were scanned
+	FindingsBySeverity map[string]int `json:"findings_by_severity"` // Findings of the PDF report per severity; collected with -pdf, -template, -fail-on or -result-file
+	Outputs            []string       `json:"outputs"`              // Files written, in the order they were written
+	Warnings           int            `json:"warnings"`             // Warnings logged to stderr
+	APICalls           int64          `json:"api_calls"`            // AWS API requests made, retries included
+	Connections        int64          `json:"connections"`          // Connections opened to AWS endpoints; far fewer than api_calls when connections are reused
+	Stale              bool           `json:"stale"`                // The outputs were generated from a -load scan older than -max-age, accepted with -allow-stale
+
+	file     string           // Path of the -result-file ("" for none)
+	failOn   int              // Rank of the -fail-on severity (0 for none)
+	partial  bool             // A requested scanner was skipped after an error
+	exceeded func() bool      // Whether -max-api-calls refused calls (nil before the budget exists)
+	now      func() time.Time // Clock, replaceable for tests
+}
+
+// newRunResult starts the summary of a run
+func newRunResult() *RunResult {
+	return &RunResult{
+		StartedAt:          time.Now(),
+		ResourceCounts:     map[string]int{},
+		FindingsBySeverity: map[string]int{},
+		Outputs:            []string{},
+		now:                time.Now,
+	}
+}
+
+// warnf logs a warning and counts it
+func (r *RunResult) warnf(format string, args ...interface{}) {
+	r.Warnings++
+	log.Printf("Warning: "+format, args...)
+}
+
+// skipf logs a warning about a requested scanner or lookup that was skipped, which makes the scan partial
+func (r *RunResult) skipf(format string, args ...interface{}) {
+	r.partial = true
+	r.warnf("skipping "+format, args...)
+}
+
+// count records the number of resources of a type
+func (r *RunResult) count(resourceType string, n int) {
+	r.ResourceCounts[resourceType] = n
+}
+
+// output records a file the run wrote
+func (r *RunResult) output(path string) {
+	r.Outputs = append(r.Outputs, path)
+}
+
+// finding records a finding of the run by its severity
+func (r *RunResult) finding(severity string) {
+	r.FindingsBySeverity[severity]++
+}
+
+// scanFailure turns a failed scanner call the scan cannot do without into the error that stops it
+// A call refused by -max-api-calls only logs a warning, so the scan continues to its outputs with
+// partial results. Recognized error categories get a remediation hint.
+// err: Error of the scanner call (nil if it succeeded)
+// Returns: Error that stops the run, or nil if the scan goes on
+func (r *RunResult) scanFailure(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, awsconfig.ErrCallBudgetExceeded) {
+		r.warnf("%v", err)
+		return nil
+	}
+	return withRemediation(err)
+}
+
+// exitOnScanError logs a failed scanner call of a subcommand with its remediation hint and exits
+// A call refused by -max-api-calls only logs a warning, as in a scan.
+func exitOnScanError(err error) {
+	if errors.Is(err, awsconfig.ErrCallBudgetExceeded) {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	log.Fatal(withRemediation(err))
+}
+
+// withRemediation adds a remediation hint to the error of a scanner call in a recognized error category
+func withRemediation(err error) error {
+	operation := "the ec2:Describe* APIs"
+	var scanErr *vpc.ScanError
+	if errors.As(err, &scanErr) {
+		operation = scanErr.Service + ":" + scanErr.Operation
+	}
+
+	switch {
+	case errors.Is(err, vpc.ErrAccessDenied):
+		return fmt.Errorf("%w\nCheck that your AWS credentials are valid and allow %s", err, operation)
+	case errors.Is(err, vpc.ErrThrottled):
+		return fmt.Errorf("%w\nAWS is throttling %s; wait and retry, or scan during a quieter period", err, operation)
+	case errors.Is(err, vpc.ErrRegionDisabled):
+		return fmt.Errorf("%w\nThe region is not enabled for this account; enable it or choose another with -region", err)
+	case errors.Is(err, vpc.ErrInvalidInput):
+		return fmt.Errorf("%w\nAWS rejected the request parameters for %s", err, operation)
+	}
+	return err
+}
+
+// finish decides the exit code and writes the -result-file
+// A result file that cannot be written is logged but does not change the exit code.
+// err: Error that stopped the run (nil if it ran to the end)
+// Returns: Exit code of the process
+func (r *RunResult) finish(err error) int {
+	outcome := runOutcome{Err: err, Partial: r.partial}
+	if r.exceeded != nil {
+		outcome.BudgetExceeded = r.exceeded()
+	}
+	if r.failOn > 0 {
+		for severity, n := range r.FindingsBySeverity {
+			if n > 0 && severityRanks[severity] >= r.failOn {
+				outcome.Findings = true
+			}
+		}
+	}
+	r.Status, r.ExitCode = runStatus(outcome)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.DurationMs = r.now().Sub(r.StartedAt).Milliseconds()
+
+	if r.file != "" {
+		resultJSON, jsonErr := json.MarshalIndent(r, "", "  ")
+		if jsonErr == nil {
+			jsonErr = os.WriteFile(r.file, append(resultJSON, '\n'), 0o644)
+		}
+		if jsonErr != nil {
+			log.Printf("Warning: failed to write -result-file: %v", jsonErr)
+		}
+	}
+	return r.ExitCode
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/vpc"
+)
+
+// apiError returns a scanner error for an AWS API error code, classified as the scanners classify it
+func apiError(code string) error {
+	return vpc.NewServiceScanError("ec2", "VPCs", "DescribeVpcs", &smithy.GenericAPIError{Code: code, Message: code})
+}
+
+// TestRunStatus covers every row of the truth table of runStatus, and that an error or a finding
+// decides alone whatever else the run recorded
+func TestRunStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		outcome    runOutcome
+		wantStatus string
+		wantCode   int
+	}{
+		// invalid config, region disabled
+		{name: "invalid config", outcome: runOutcome{Err: configError(errors.New("bad -tag"))}, wantStatus: statusConfigError, wantCode: exitConfigError},
+		{name: "region disabled category", outcome: runOutcome{Err: fmt.Errorf("scan: %w", vpc.ErrRegionDisabled)}, wantStatus: statusConfigError, wantCode: exitConfigError},
+		{name: "OptInRequired", outcome: runOutcome{Err: apiError("OptInRequired")}, wantStatus: statusConfigError, wantCode: exitConfigError},
+		{name: "unwrapped OptInRequired", outcome: runOutcome{Err: &smithy.GenericAPIError{Code: "OptInRequired"}}, wantStatus: statusConfigError, wantCode: exitConfigError},
+		// no credentials, access denied
+		{name: "no credentials", outcome: runOutcome{Err: &runError{Category: errNoCredentials, Err: errors.New("no profile")}}, wantStatus: statusAuthError, wantCode: exitAuthError},
+		{name: "access denied category", outcome: runOutcome{Err: fmt.Errorf("scan: %w", vpc.ErrAccessDenied)}, wantStatus: statusAuthError, wantCode: exitAuthError},
+		{name: "UnauthorizedOperation", outcome: runOutcome{Err: apiError("UnauthorizedOperation")}, wantStatus: statusAuthError, wantCode: exitAuthError},
+		{name: "unwrapped AuthFailure", outcome: runOutcome{Err: &smithy.GenericAPIError{Code: "AuthFailure"}}, wantStatus: statusAuthError, wantCode: exitAuthError},
+		// throttled
+		{name: "throttled category", outcome: runOutcome{Err: fmt.Errorf("scan: %w", vpc.ErrThrottled)}, wantStatus: statusThrottled, wantCode: exitThrottled},
+		{name: "RequestLimitExceeded", outcome: runOutcome{Err: apiError("RequestLimitExceeded")}, wantStatus: statusThrottled, wantCode: exitThrottled},
+		// API call budget exceeded
+		{name: "budget exceeded error", outcome: runOutcome{Err: fmt.Errorf("DescribeVpcs: %w", awsconfig.ErrCallBudgetExceeded)}, wantStatus: statusBudgetExceeded, wantCode: exitBudgetExceeded},
+		// any other error
+		{name: "other error", outcome: runOutcome{Err: errors.New("disk full")}, wantStatus: statusFailed, wantCode: exitFailed},
+		{name: "invalid input", outcome: runOutcome{Err: apiError("InvalidFilter")}, wantStatus: statusFailed, wantCode: exitFailed},
+		{name: "unknown API error", outcome: runOutcome{Err: apiError("InternalError")}, wantStatus: statusFailed, wantCode: exitFailed},
+		{name: "error with findings", outcome: runOutcome{Err: errors.New("disk full"), Findings: true, BudgetExceeded: true, Partial: true}, wantStatus: statusFailed, wantCode: exitFailed},
+		{name: "auth error with findings", outcome: runOutcome{Err: apiError("AuthFailure"), Findings: true}, wantStatus: statusAuthError, wantCode: exitAuthError},
+		// nil error
+		{name: "findings", outcome: runOutcome{Findings: true}, wantStatus: statusFindings, wantCode: exitFindings},
+		{name: "findings of a partial scan", outcome: runOutcome{Findings: true, Partial: true}, wantStatus: statusFindings, wantCode: exitFindings},
+		{name: "findings over budget", outcome: runOutcome{Findings: true, BudgetExceeded: true, Partial: true}, wantStatus: statusFindings, wantCode: exitFindings},
+		{name: "budget exceeded", outcome: runOutcome{BudgetExceeded: true}, wantStatus: statusBudgetExceeded, wantCode: exitBudgetExceeded},
+		{name: "budget exceeded and partial", outcome: runOutcome{BudgetExceeded: true, Partial: true}, wantStatus: statusBudgetExceeded, wantCode: exitBudgetExceeded},
+		{name: "partial", outcome: runOutcome{Partial: true}, wantStatus: statusPartial, wantCode: exitPartial},
+		{name: "success", outcome: runOutcome{}, wantStatus: statusSuccess, wantCode: exitSuccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := runStatus(tt.outcome)
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("runStatus() = %s, %d; want %s, %d", status, code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestFinishFailOn checks that finish counts only the findings at or above the -fail-on severity
+// and writes the -result-file with the decided status
+func TestFinishFailOn(t *testing.T) {
+	tests := []struct {
+		name     string
+		failOn   string // -fail-on severity ("" for none)
+		findings []string
+		partial  bool
+		exceeded bool
+		want     int
+	}{
+		{name: "no -fail-on", findings: []string{analysis.SeverityHigh}, want: exitSuccess},
+		{name: "below the gate", failOn: analysis.SeverityHigh, findings: []string{analysis.SeverityLow, analysis.SeverityMedium}, want: exitSuccess},
+		{name: "at the gate", failOn: analysis.SeverityMedium, findings: []string{analysis.SeverityMedium}, want: exitFindings},
+		{name: "above the gate", failOn: analysis.SeverityLow, findings: []string{analysis.SeverityHigh}, want: exitFindings},
+		{name: "other finding kinds", failOn: analysis.SeverityLow, findings: []string{"recommendation"}, want: exitSuccess},
+		{name: "partial below the gate", failOn: analysis.SeverityHigh, findings: []string{analysis.SeverityLow}, partial: true, want: exitPartial},
+		{name: "partial at the gate", failOn: analysis.SeverityHigh, findings: []string{analysis.SeverityHigh}, partial: true, want: exitFindings},
+		{name: "budget exceeded", partial: true, exceeded: true, want: exitBudgetExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newRunResult()
+			result.file = filepath.Join(t.TempDir(), "result.json")
+			result.failOn = severityRanks[tt.failOn]
+			result.partial = tt.partial
+			result.exceeded = func() bool { return tt.exceeded }
+			start := result.StartedAt
+			result.now = func() time.Time { return start.Add(1500 * time.Millisecond) }
+			for _, severity := range tt.findings {
+				result.finding(severity)
+			}
+
+			if code := result.finish(nil); code != tt.want {
+				t.Fatalf("finish() = %d, want %d", code, tt.want)
+			}
+			data, err := os.ReadFile(result.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var written RunResult
+			if err := json.Unmarshal(data, &written); err != nil {
+				t.Fatal(err)
+			}
+			if written.ExitCode != tt.want || written.Status != result.Status || written.DurationMs != 1500 {
+				t.Errorf("result file has status %s, exit code %d, duration %d; want %s, %d, 1500", written.Status, written.ExitCode, written.DurationMs, result.Status, tt.want)
+			}
+		})
+	}
+}
+
+// TestFinishError checks that the error that stopped a run is recorded with its exit code
+func TestFinishError(t *testing.T) {
+	result := newRunResult()
+	result.failOn = severityRanks[analysis.SeverityLow]
+	result.finding(analysis.SeverityHigh)
+	if code := result.finish(configError(errors.New("-max-age must be positive"))); code != exitConfigError {
+		t.Errorf("finish() = %d, want %d", code, exitConfigError)
+	}
+	if result.Status != statusConfigError || result.Error != "-max-age must be positive" {
+		t.Errorf("status %q, error %q", result.Status, result.Error)
+	}
+}
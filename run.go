@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/crossaccount"
+)
+
+// Outcomes of a scan of the run subcommand that never got to write a -result-file
+const (
+	targetAuthError = "auth-error" // The target's credentials could not be resolved, so it was not scanned
+	targetInvalid   = "invalid"    // The scan's flags did not pass its validation, so it was not started
+	targetFailed    = "failed"     // The scan exited with an error before writing its -result-file
+)
+
+// Files the run subcommand writes
+const (
+	runResultFile  = "result.json" // -result-file of every scan, in the directory of its region
+	runSummaryFile = "run.json"    // Outcome of every scan, in the output directory
+	runIndexFile   = "index.html"  // Page linking the outputs of every scan, in the output directory
+)
+
+// runInputFlags are scan flags naming files to read; relative paths are resolved against the project file
+var runInputFlags = map[string]bool{
+	"template": true, "path-properties": true, "saas-catalog": true, "named-ranges": true, "managed-by-rules": true, "compare-with": true,
+	"stability-history": true,
+}
+
+// runOutputFlags are the scan flags the outputs block of a project file may set; the run sets -result-file itself
+var runOutputFlags = []string{"pdf", "plantuml", "public-ips-csv", "scan-manifest", "graph-out", "backstage-out", "detail-diagrams", "checkpoint-dir", "template-out", "save"}
+
+// TargetScan is the outcome of the scan of one target in one region
+type TargetScan struct {
+	Target     string   `json:"target"`          // Name of the target
+	Region     string   `json:"region"`          // Scanned region
+	AccountID  string   `json:"account_id"`      // Account of the target's credentials (empty if they could not be resolved)
+	Directory  string   `json:"directory"`       // Directory with the outputs, scan.log and result.json of the scan
+	Status     string   `json:"status"`          // Status from the scan's result.json (success, partial, findings, ...), or auth-error, invalid or failed
+	Outputs    []string `json:"outputs"`         // Files the scan wrote, relative to the output directory of the run
+	Error      string   `json:"error,omitempty"` // Why the scan was not started or failed
+	DurationMs int64    `json:"duration_ms"`     // Duration of the scan in milliseconds
+}
+
+// RunSummary is written to run.json after the run subcommand
+type RunSummary struct {
+	Project string       `json:"project"` // Path of the project file
+	Scans   []TargetScan `json:"scans"`   // Scans by target, then region in the order of the project file
+	Failed  int          `json:"failed"`  // Scans that were not started or did not finish
+}
+
+// projectRun runs the targets of a project file, one scan per target and region
+type projectRun struct {
+	load        func(ctx context.Context, target config.ProjectTarget) (profileSession, error)     // Resolves the credentials of a target
+	validate    func(ctx context.Context, dir string, args []string) error                         // Checks the flags of a scan without calling AWS
+	scan        func(ctx context.Context, session profileSession, dir string, args []string) error // Scans one region, writing its outputs to dir
+	parallelism int                                                                                // Scans running at the same time
+	dir         string                                                                             // Output directory holding a directory per target and region
+	now         func() time.Time                                                                   // Clock, replaceable for tests
+}
+
+// run resolves the credentials of every target, checks every scan and then runs them
+// As with -profiles, credentials are resolved one target after the other before any scan starts, so
+// MFA and SSO prompts never interleave. A target whose credentials cannot be resolved, or a scan
+// whose flags are invalid or that fails, is recorded and the other scans carry on.
+// ctx: Context for loading credentials and running the scans
+// project: Project file with the targets
+// names: Targets to run
+// Returns: Outcome of every scan
+func (r *projectRun) run(ctx context.Context, project *config.Project, names []string) *RunSummary {
+	summary := &RunSummary{Scans: []TargetScan{}}
+	var sessions []profileSession
+	var args [][]string
+	for _, name := range names {
+		target, _ := project.Target(name)
+		session, loadErr := r.load(ctx, target)
+		for _, region := range target.Regions {
+			scan := TargetScan{Target: name, Region: region, Directory: filepath.Join(r.dir, name, region), Outputs: []string{}}
+			scanArgs := targetScanArgs(project, target, region)
+			switch {
+			case loadErr != nil:
+				scan.Status, scan.Error = targetAuthError, loadErr.Error()
+			default:
+				scan.AccountID = session.AccountID
+				if err := os.MkdirAll(scan.Directory, 0o755); err != nil {
+					scan.Status, scan.Error = targetFailed, err.Error()
+				} else if err := r.validate(ctx, scan.Directory, scanArgs); err != nil {
+					scan.Status, scan.Error = targetInvalid, err.Error()
+				}
+			}
+			summary.Scans = append(summary.Scans, scan)
+			sessions = append(sessions, session)
+			args = append(args, scanArgs)
+		}
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(r.parallelism, 1))
+	for i := range summary.Scans {
+		scan := &summary.Scans[i]
+		if scan.Status != "" {
+			continue
+		}
+		wg.Add(1)
+		go func(session profileSession, args []string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			// A result file of an earlier run must not be taken for the outcome of this one
+			os.Remove(filepath.Join(scan.Directory, runResultFile))
+			start := r.now()
+			scanErr := r.scan(ctx, session, scan.Directory, args)
+			scan.DurationMs = r.now().Sub(start).Milliseconds()
+			scan.Status = targetFailed
+			if result, err := readScanResult(filepath.Join(scan.Directory, runResultFile)); err == nil {
+				scan.Status = result.Status
+				for _, output := range result.Outputs {
+					scan.Outputs = append(scan.Outputs, r.relative(scan.Directory, output))
+				}
+			}
+			if scanErr != nil {
+				scan.Error = scanErr.Error()
+			}
+		}(sessions[i], args[i])
+	}
+	wg.Wait()
+
+	for _, scan := range summary.Scans {
+		if scanFailed(scan) {
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// relative returns an output of a scan relative to the output directory, for links from the index page
+func (r *projectRun) relative(scanDir, output string) string {
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(scanDir, output)
+	}
+	if rel, err := filepath.Rel(r.dir, output); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return output
+}
+
+// scanFailed reports whether a scan was not started or did not finish
+// A partial scan, a -fail-on finding or an exhausted -max-api-calls still wrote the outputs.
+func scanFailed(scan TargetScan) bool {
+	switch scan.Status {
+	case statusSuccess, statusPartial, statusFindings, statusBudgetExceeded:
+		return false
+	}
+	return true
+}
+
+// targetScanArgs returns the flags of the scan of a target in one region
+// Outputs are relative to the directory the scan runs in; input files are resolved against the
+// directory of the project file, since the scan does not run there.
+func targetScanArgs(project *config.Project, target config.ProjectTarget, region string) []string {
+	args := []string{"-region=" + region, "-result-file=" + runResultFile}
+	for _, name := range sortedFlagNames(target.Flags) {
+		value := target.Flags[name]
+		if runInputFlags[name] {
+			if abs, err := filepath.Abs(project.Path(value)); err == nil {
+				value = abs
+			}
+		}
+		args = append(args, "-"+name+"="+value)
+	}
+	for _, name := range sortedFlagNames(target.Outputs) {
+		args = append(args, "-"+name+"="+target.Outputs[name])
+	}
+	return args
+}
+
+// sortedFlagNames returns the keys of a flags or outputs block in order
+func sortedFlagNames(settings map[string]string) []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readScanResult reads the -result-file of a scan
+func readScanResult(path string) (*RunResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var result RunResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// validateTargetScan runs the validate subcommand with the flags of a scan, in the directory the scan will run in
+func validateTargetScan(ctx context.Context, dir string, args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, executable, append([]string{"validate"}, args...)...)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// runRun implements "aws-documentor run -target NAME[,NAME] | -all [flags]"
+// It scans the targets of a project file, every region of a target in its own directory below the
+// output directory, and writes run.json and an index page linking the outputs of every scan.
+// args: Command-line arguments after the subcommand
+func runRun(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	projectFile := flags.String("project", config.DefaultProjectFile, "Project file defining the targets")
+	targets := flags.String("target", "", "Comma-separated targets to run")
+	all := flags.Bool("all", false, "Run every target of the project file")
+	parallel := flags.Int("parallel", 1, "Scans running at the same time (1 runs them one after the other)")
+	pendingThreshold := flags.Duration("pending-threshold", crossaccount.DefaultPendingThreshold, "How long a peering, transit gateway attachment or shared transit gateway may wait for acceptance before organization.json reports it")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	project, err := config.LoadProject(*projectFile, runOutputFlags)
+	if err != nil {
+		log.Fatalf("Invalid project file:\n%v", err)
+	}
+	names := project.TargetNames()
+	switch {
+	case *all && *targets != "":
+		problems.Addf("-target", 0, "cannot be combined with -all")
+	case *all:
+	case *targets == "":
+		problems.Addf("-target", 0, "missing, for example: run -target prod, or run -all (targets: %s)", strings.Join(names, ", "))
+	default:
+		names = splitList(*targets)
+		for _, name := range names {
+			if _, ok := project.Targets[name]; !ok {
+				problems.Addf("-target", 0, "unknown target %q (targets: %s)", name, strings.Join(project.TargetNames(), ", "))
+			}
+		}
+	}
+	if *parallel < 1 {
+		problems.Addf("-parallel", 0, "must be at least 1")
+	}
+	if *pendingThreshold < 0 {
+		problems.Addf("-pending-threshold", 0, "must not be negative")
+	}
+	outputDir := project.Path(project.OutputDir)
+	problems.Check(*projectFile+": output_dir", config.CheckOutputDir(outputDir))
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	httpOptions := awsconfig.DefaultHTTPOptions()
+	runner := &projectRun{
+		load: func(ctx context.Context, target config.ProjectTarget) (profileSession, error) {
+			return loadProfileSession(ctx, target.Profile, target.RoleARN, target.Regions[0], httpOptions)
+		},
+		validate:    validateTargetScan,
+		scan:        runProfileScan,
+		parallelism: *parallel,
+		dir:         outputDir,
+		now:         time.Now,
+	}
+	summary := runner.run(context.Background(), project, names)
+	summary.Project = *projectFile
+	if err := writeRunSummary(os.Stdout, outputDir, summary); err != nil {
+		log.Fatalf("Failed to write the run summary: %v", err)
+	}
+	if saved := runSavedScans(project, summary); len(saved) > 0 {
+		options := crossaccount.DefaultOptions()
+		options.PendingThreshold = *pendingThreshold
+		if _, err := writeOrganization(os.Stdout, outputDir, loadAccountScans(saved), options); err != nil {
+			log.Fatalf("Failed to write %s: %v", organizationFile, err)
+		}
+	}
+	if summary.Failed > 0 {
+		log.Fatalf("%d of %d scans failed; see %s", summary.Failed, len(summary.Scans), filepath.Join(outputDir, runSummaryFile))
+	}
+}
+
+// runSavedScans returns the -save files of the scans that finished, for the cross-account correlation
+// Only targets whose outputs block sets save have one.
+func runSavedScans(project *config.Project, summary *RunSummary) []savedScan {
+	var saved []savedScan
+	for _, scan := range summary.Scans {
+		target, _ := project.Target(scan.Target)
+		if scanFailed(scan) || target.Outputs["save"] == "" {
+			continue
+		}
+		saved = append(saved, savedScan{name: scan.Target + "/" + scan.Region, path: filepath.Join(scan.Directory, target.Outputs["save"])})
+	}
+	return saved
+}
+
+// writeRunSummary prints the outcome of every scan and writes run.json and index.html to dir
+func writeRunSummary(w io.Writer, dir string, summary *RunSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tREGION\tACCOUNT\tSTATUS\tDIRECTORY")
+	for _, scan := range summary.Scans {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", scan.Target, scan.Region, scan.AccountID, scan.Status, scan.Directory)
+	}
+	tw.Flush()
+	for _, scan := range summary.Scans {
+		if scan.Error != "" {
+			fmt.Fprintf(w, "%s/%s: %s\n", scan.Target, scan.Region, scan.Error)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	summaryJSON, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, runSummaryFile), summaryJSON, 0o644); err != nil {
+		return err
+	}
+	var page bytes.Buffer
+	if err := runIndexTemplate.Execute(&page, summary); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, runIndexFile), page.Bytes(), 0o644)
+}
+
+// runIndexTemplate is the index page of a run, linking the outputs of every scan
+var runIndexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"failed":  scanFailed,
+	"started": func(scan TargetScan) bool { return scan.Status != targetAuthError && scan.Status != targetInvalid },
+	"log":     func(scan TargetScan) string { return scan.Target + "/" + scan.Region + "/scan.log" },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>AWS network documentation</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.failed { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>AWS network documentation</h1>
+<p>{{len .Scans}} scan(s) of {{.Project}}{{if .Failed}}, <span class="failed">{{.Failed}} failed</span>{{end}}</p>
+<table>
+<tr><th>Target</th><th>Region</th><th>Account</th><th>Status</th><th>Outputs</th></tr>
+{{- range .Scans}}
+<tr>
+<td>{{.Target}}</td>
+<td>{{.Region}}</td>
+<td>{{.AccountID}}</td>
+<td{{if failed .}} class="failed"{{end}}>{{.Status}}{{if .Error}}<br><small>{{.Error}}</small>{{end}}</td>
+<td>{{range .Outputs}}<a href="{{.}}">{{.}}</a><br>{{end}}{{if started .}}<a href="{{log .}}">scan.log</a>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aws-documentor/modules/config"
+)
+
+// fakeTargets drives projectRun with fake credentials, validation and scans
+// The scan of a successful target writes a result.json listing a relative and an absolute output, like a real scan.
+type fakeTargets struct {
+	mu          sync.Mutex
+	scanning    int                 // Scans running now
+	maxScanning int                 // Most scans that ran at once
+	args        map[string][]string // Flags of every scan by directory
+	failAuth    string              // Profile whose credentials cannot be resolved
+	failCheck   string              // Profile whose scans do not pass validation
+	failScan    string              // Profile whose scans exit before writing result.json
+}
+
+func (f *fakeTargets) load(ctx context.Context, target config.ProjectTarget) (profileSession, error) {
+	if target.Profile == f.failAuth {
+		return profileSession{}, errors.New("failed to retrieve credentials: token expired")
+	}
+	account := map[string]string{"net-prod": "333333333333", "net-stage": "222222222222"}[target.Profile]
+	return profileSession{Profile: target.Profile, AccountID: account, Region: target.Regions[0]}, nil
+}
+
+func (f *fakeTargets) validate(ctx context.Context, dir string, args []string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	if f.failCheck != "" && strings.Contains(dir, f.failCheck) {
+		return errors.New("-pdf: parent directory does not exist")
+	}
+	return nil
+}
+
+func (f *fakeTargets) scan(ctx context.Context, session profileSession, dir string, args []string) error {
+	f.mu.Lock()
+	f.scanning++
+	f.maxScanning = max(f.maxScanning, f.scanning)
+	f.args[dir] = args
+	f.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	f.mu.Lock()
+	f.scanning--
+	f.mu.Unlock()
+
+	if session.Profile == f.failScan {
+		return errors.New("exit status 1")
+	}
+	result := RunResult{Status: statusSuccess, Outputs: []string{"report.pdf", filepath.Join(dir, "graph")}}
+	data, _ := json.Marshal(result)
+	return os.WriteFile(filepath.Join(dir, runResultFile), data, 0o644)
+}
+
+// testProject returns a project with a prod target scanning two regions and a stage target inheriting one
+func testProject(dir string) *config.Project {
+	return &config.Project{
+		Dir:       dir,
+		OutputDir: "docs",
+		Defaults: config.ProjectTarget{Regions: []string{"eu-west-1"}, Outputs: map[string]string{"pdf": "report.pdf"},
+			Flags: map[string]string{"template": "docs.tmpl", "diagram": "true"}},
+		Targets: map[string]config.ProjectTarget{
+			"prod":  {Profile: "net-prod", Regions: []string{"eu-west-1", "us-east-1"}},
+			"stage": {Profile: "net-stage", Flags: map[string]string{"diagram": ""}},
+		},
+	}
+}
+
+// TestProjectRun runs two fake targets and checks that a target failing authentication, validation or its scan
+// is recorded while the other target is scanned, that a result.json of an earlier run is not taken for the
+// outcome, and that scans run in parallel up to the limit
+func TestProjectRun(t *testing.T) {
+	prodScans := func(dir string) []TargetScan {
+		return []TargetScan{
+			{Target: "prod", Region: "eu-west-1", AccountID: "333333333333", Directory: filepath.Join(dir, "prod", "eu-west-1"), Status: statusSuccess,
+				Outputs: []string{"prod/eu-west-1/report.pdf", "prod/eu-west-1/graph"}},
+			{Target: "prod", Region: "us-east-1", AccountID: "333333333333", Directory: filepath.Join(dir, "prod", "us-east-1"), Status: statusSuccess,
+				Outputs: []string{"prod/us-east-1/report.pdf", "prod/us-east-1/graph"}},
+		}
+	}
+	stageDir := func(dir string) string { return filepath.Join(dir, "stage", "eu-west-1") }
+	tests := []struct {
+		name        string
+		parallelism int
+		fake        *fakeTargets
+		wantStage   func(dir string) TargetScan
+		wantFailed  int
+		wantScanned int
+	}{
+		{name: "all succeed", parallelism: 3, fake: &fakeTargets{}, wantScanned: 3,
+			wantStage: func(dir string) TargetScan {
+				return TargetScan{Target: "stage", Region: "eu-west-1", AccountID: "222222222222", Directory: stageDir(dir), Status: statusSuccess,
+					Outputs: []string{"stage/eu-west-1/report.pdf", "stage/eu-west-1/graph"}}
+			}},
+		{name: "scan fails", parallelism: 2, fake: &fakeTargets{failScan: "net-stage"}, wantFailed: 1, wantScanned: 3,
+			wantStage: func(dir string) TargetScan {
+				return TargetScan{Target: "stage", Region: "eu-west-1", AccountID: "222222222222", Directory: stageDir(dir), Status: targetFailed,
+					Outputs: []string{}, Error: "exit status 1"}
+			}},
+		{name: "credentials fail", parallelism: 1, fake: &fakeTargets{failAuth: "net-stage"}, wantFailed: 1, wantScanned: 2,
+			wantStage: func(dir string) TargetScan {
+				return TargetScan{Target: "stage", Region: "eu-west-1", Directory: stageDir(dir), Status: targetAuthError,
+					Outputs: []string{}, Error: "failed to retrieve credentials: token expired"}
+			}},
+		{name: "validation fails", parallelism: 2, fake: &fakeTargets{failCheck: "stage"}, wantFailed: 1, wantScanned: 2,
+			wantStage: func(dir string) TargetScan {
+				return TargetScan{Target: "stage", Region: "eu-west-1", AccountID: "222222222222", Directory: stageDir(dir), Status: targetInvalid,
+					Outputs: []string{}, Error: "-pdf: parent directory does not exist"}
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// A result of an earlier run, which a scan failing this time must not report
+			if err := os.MkdirAll(stageDir(dir), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(stageDir(dir), runResultFile), []byte(`{"status": "success", "outputs": ["old.pdf"]}`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.fake.args = make(map[string][]string)
+			runner := &projectRun{load: tt.fake.load, validate: tt.fake.validate, scan: tt.fake.scan, parallelism: tt.parallelism, dir: dir,
+				now: (&fakeClock{now: time.Unix(0, 0)}).Now}
+			summary := runner.run(context.Background(), testProject(t.TempDir()), []string{"prod", "stage"})
+
+			for i := range summary.Scans {
+				if started := summary.Scans[i].Status != targetAuthError && summary.Scans[i].Status != targetInvalid; (summary.Scans[i].DurationMs > 0) != started {
+					t.Errorf("%s/%s took %d ms", summary.Scans[i].Target, summary.Scans[i].Region, summary.Scans[i].DurationMs)
+				}
+				summary.Scans[i].DurationMs = 0
+			}
+			want := append(prodScans(dir), tt.wantStage(dir))
+			if !reflect.DeepEqual(summary.Scans, want) || summary.Failed != tt.wantFailed {
+				t.Errorf("run() = %+v (%d failed)\nwant %+v (%d failed)", summary.Scans, summary.Failed, want, tt.wantFailed)
+			}
+			if len(tt.fake.args) != tt.wantScanned || tt.fake.maxScanning > tt.parallelism {
+				t.Errorf("%d scans, %d at once; want %d, at most %d at once", len(tt.fake.args), tt.fake.maxScanning, tt.wantScanned, tt.parallelism)
+			}
+			if tt.parallelism > 1 && tt.fake.maxScanning < 2 {
+				t.Errorf("scans ran one at a time, want up to %d in parallel", tt.parallelism)
+			}
+		})
+	}
+}
+
+// TestTargetScanArgs checks the flags of a scan: the region and result file first, then the flags with input
+// files resolved against the project file, then the outputs, relative to the directory of the region
+func TestTargetScanArgs(t *testing.T) {
+	projectDir := t.TempDir()
+	project := testProject(projectDir)
+	tests := []struct {
+		target string
+		region string
+		want   []string
+	}{
+		{target: "prod", region: "us-east-1", want: []string{"-region=us-east-1", "-result-file=result.json", "-diagram=true",
+			"-template=" + filepath.Join(projectDir, "docs.tmpl"), "-pdf=report.pdf"}},
+		{target: "stage", region: "eu-west-1", want: []string{"-region=eu-west-1", "-result-file=result.json",
+			"-template=" + filepath.Join(projectDir, "docs.tmpl"), "-pdf=report.pdf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			target, _ := project.Target(tt.target)
+			if got := targetScanArgs(project, target, tt.region); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targetScanArgs() = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteRunSummary checks the printed table, run.json and the index page linking the outputs and logs of the scans
+func TestWriteRunSummary(t *testing.T) {
+	dir := t.TempDir()
+	summary := &RunSummary{Project: "aws-documentor.json", Failed: 2, Scans: []TargetScan{
+		{Target: "prod", Region: "eu-west-1", AccountID: "333333333333", Directory: "docs/prod/eu-west-1", Status: statusSuccess,
+			Outputs: []string{"prod/eu-west-1/report.pdf"}, DurationMs: 1200},
+		{Target: "stage", Region: "eu-west-1", AccountID: "222222222222", Directory: "docs/stage/eu-west-1", Status: targetFailed,
+			Outputs: []string{}, Error: "exit status 1"},
+		{Target: "dev", Region: "eu-west-1", Directory: "docs/dev/eu-west-1", Status: targetAuthError, Outputs: []string{}, Error: "token expired"},
+	}}
+	var out strings.Builder
+	if err := writeRunSummary(&out, dir, summary); err != nil {
+		t.Fatal(err)
+	}
+	want := `TARGET  REGION     ACCOUNT       STATUS      DIRECTORY
+prod    eu-west-1  333333333333  success     docs/prod/eu-west-1
+stage   eu-west-1  222222222222  failed      docs/stage/eu-west-1
+dev     eu-west-1                auth-error  docs/dev/eu-west-1
+stage/eu-west-1: exit status 1
+dev/eu-west-1: token expired
+`
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, runSummaryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written RunSummary
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&written, summary) {
+		t.Errorf("%s = %+v, want %+v", runSummaryFile, written, summary)
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, runIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range []string{
+		`<p>3 scan(s) of aws-documentor.json, <span class="failed">2 failed</span></p>`,
+		`<td><a href="prod/eu-west-1/report.pdf">prod/eu-west-1/report.pdf</a><br><a href="prod/eu-west-1/scan.log">scan.log</a></td>`,
+		`<td class="failed">failed<br><small>exit status 1</small></td>`,
+		`<td><a href="stage/eu-west-1/scan.log">scan.log</a></td>`,
+	} {
+		if !strings.Contains(string(page), fragment) {
+			t.Errorf("%s does not contain %s", runIndexFile, fragment)
+		}
+	}
+	if strings.Contains(string(page), "dev/eu-west-1/scan.log") {
+		t.Errorf("%s links the log of a target that was not scanned", runIndexFile)
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/lifecycle"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/pdf"
+	"aws-documentor/modules/vpc"
+	"aws-documentor/modules/whatsnew"
+)
+
+// analyze runs the analyses the flags ask for over the scanned resources and prints their reports
+// ctx: Context for the AWS calls of the analyses that look up more (peers of -auto-expand-regions, DNS records)
+// Returns: Error that stopped the run
+func (run *scanRun) analyze(ctx context.Context) error {
+	var err error
+	// Summarize resource ages if requested
+	if *run.lifecycleReport {
+		fmt.Fprintln(run.stdout, "\nLifecycle summary:")
+		resources := lifecycle.CollectResources(run.vpcs, run.subnets, run.securityGroups, run.natGateways, run.transitGateways,
+			run.tgwAttachments, run.vpnConnections, run.vpcEndpoints, run.instances)
+		now := time.Now()
+		// The calls that created resources without a native creation time, as far back as the event history goes
+		var sources []lifecycle.TimestampSource
+		if lifecycle.NeedsSources(resources) {
+			events, err := whatsnew.NewTrailScanner(run.cfg).GetCreationEvents(ctx, now.Add(-90*24*time.Hour), now)
+			if err != nil {
+				run.result.skipf("CloudTrail creation times, ages without a native creation time are unknown: %v", err)
+			} else {
+				sources = append(sources, whatsnew.NewCreationSource(events))
+			}
+		}
+		summary := lifecycle.Analyze(now, resources, sources...)
+		summaryJSON, _ := output.Marshal(summary, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", summaryJSON)
+	}
+
+	// Check security group references if requested
+	if *run.sgReferences {
+		fmt.Fprintln(run.stdout, "\nSecurity group references:")
+		report := analysis.AnalyzeSecurityGroupReferences(run.securityGroups, run.routeTables)
+		reportJSON, _ := output.Marshal(report, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Check for shadowed and duplicate security group rules if requested
+	if *run.sgRedundancy {
+		fmt.Fprintln(run.stdout, "\nRedundant security group rules:")
+		report := analysis.AnalyzeRedundantRules(run.securityGroups)
+		reportJSON, _ := output.Marshal(report, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Ingress from named ranges marked untrusted, only when there is any
+	run.untrustedSourceFindings = run.rangeLabeler.AnalyzeUntrustedSources(run.securityGroups)
+	if len(run.untrustedSourceFindings) > 0 && run.opts.JSON {
+		fmt.Fprintln(run.stdout, "\nUntrusted source findings:")
+		findingsJSON, _ := output.Marshal(run.untrustedSourceFindings, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", findingsJSON)
+	} else if len(run.untrustedSourceFindings) > 0 {
+		fmt.Fprintf(run.stdout, "Found %d untrusted source findings\n", len(run.untrustedSourceFindings))
+	}
+
+	// Both the usage and the effective sources analyses need the groups of every network interface
+	var interfaceGroups []vpc.InterfaceGroupsInfo
+	if *run.sgUsage || *run.effectiveSources {
+		interfaceGroups, err = run.scanner.GetInterfaceGroups(ctx)
+		if err := run.result.scanFailure(err); err != nil {
+			return err
+		}
+	}
+
+	// Findings about resources of the -skip-managed managers are left out
+	run.skipManagers = splitList(*run.skipManaged)
+	run.managedBy = analysis.ManagedByID(run.vpcs, run.subnets, run.routeTables, run.securityGroups, run.internetGateways, run.natGateways, run.transitGateways, run.tgwAttachments)
+
+	// Map security groups to interfaces and container workloads if requested
+	if *run.sgUsage {
+		fmt.Fprintln(run.stdout, "\nSecurity group usage:")
+		run.sgUsageReport = analysis.AnalyzeSecurityGroupUsage(run.securityGroups, interfaceGroups, run.ecsServices, run.eksClusters)
+		run.sgUsageReport.Findings = analysis.WithoutManaged(run.sgUsageReport.Findings, func(f analysis.SGUsageFinding) string { return f.GroupID }, run.managedBy, run.skipManagers)
+		reportJSON, _ := output.Marshal(run.sgUsageReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Expand security group references into the addresses that can connect if requested
+	if *run.effectiveSources {
+		fmt.Fprintln(run.stdout, "\nEffective sources:")
+		run.effectiveSourcesReport = analysis.AnalyzeEffectiveSources(run.securityGroups, run.subnets, interfaceGroups)
+		run.rangeLabeler.LabelEffectiveSources(run.effectiveSourcesReport)
+		reportJSON, _ := output.Marshal(run.effectiveSourcesReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// List references to regions and accounts outside the scan, and describe same-account peers if requested
+	scannedRegions := []string{run.cfg.Region}
+	if *run.drReplication {
+		scannedRegions = append(scannedRegions, splitList(*run.drRegions)...)
+	}
+	scanGaps := analysis.FindScanGaps(scannedRegions, run.vpcs, run.transitGateways, run.peerings, run.tgwAttachments, run.relationships)
+	if *run.autoExpandRegions {
+		for _, scope := range analysis.FollowUpScopes(scanGaps) {
+			regionCfg := run.cfg.Copy()
+			regionCfg.Region = scope.Region
+			regionScanner := vpc.NewScanner(regionCfg)
+			regionScanner.SetAccountID(run.accountID)
+			var peerVPCs []vpc.VPCInfo
+			var peerGateways []vpc.TransitGatewayInfo
+			if len(scope.VpcIDs) > 0 {
+				if peerVPCs, err = regionScanner.GetVPCsByID(ctx, scope.VpcIDs); err != nil {
+					run.result.skipf("peer VPCs in %s: %v", scope.Region, err)
+				}
+			}
+			if len(scope.TransitGatewayIDs) > 0 {
+				if peerGateways, err = regionScanner.GetTransitGatewaysByID(ctx, scope.TransitGatewayIDs); err != nil {
+					run.result.skipf("peer transit gateways in %s: %v", scope.Region, err)
+				}
+			}
+			analysis.ResolveScanGaps(scanGaps, scope.Region, peerVPCs, peerGateways)
+		}
+	}
+	if run.unavailableServices != nil {
+		scanGaps.UnavailableServices = run.unavailableServices
+	}
+	if len(scanGaps.Gaps) > 0 || len(scanGaps.UnavailableServices) > 0 {
+		fmt.Fprintln(run.stdout, "\nScan gaps:")
+		reportJSON, _ := output.Marshal(scanGaps, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+		if len(scanGaps.MissingRegions) > 0 {
+			run.result.warnf("%d references lead to regions that were not scanned: %s", len(scanGaps.Gaps), strings.Join(scanGaps.MissingRegions, ", "))
+		}
+	}
+
+	// Trace inter-VPC paths through inspection VPCs if requested, with the MTU and bandwidth limits of each path
+	if *run.inspectionPaths {
+		fmt.Fprintln(run.stdout, "\nInspection paths:")
+		report := analysis.AnalyzeInspectionPaths(run.vpcs, run.routeTables, run.tgwAttachments, run.tgwRouteTables)
+		run.pathMTUFindings = analysis.AnnotatePathLimits(report, run.tgwAttachments, run.pathProperties)
+		reportJSON, _ := output.Marshal(report, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+
+		fmt.Fprintln(run.stdout, "\nPath MTU findings:")
+		findingsJSON, _ := output.Marshal(run.pathMTUFindings, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", findingsJSON)
+	}
+
+	// The findings of the PDF report are also rendered by -template, written to the versioned report and
+	// counted for -fail-on and -result-file
+	run.collectFindings = *run.pdfFile != "" || run.userTemplate != nil || *run.failOn != "" || *run.resultFile != "" || run.opts.Report || *run.reportOut != ""
+
+	// Classify the internet connectivity of the VPCs for -isolation, the isolated badge of the diagram
+	// and the isolation tag findings of the PDF
+	if *run.isolation || *run.generateDiagram || run.collectFindings {
+		run.isolationReport = analysis.AnalyzeIsolation(run.vpcs, run.routeTables, run.natGateways, run.tgwAttachments, run.tgwRouteTables)
+	}
+	if *run.isolation {
+		fmt.Fprintln(run.stdout, "\nVPC isolation:")
+		reportJSON, _ := output.Marshal(run.isolationReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Classify the egress of the VPCs if requested
+	if *run.egressProfiles {
+		fmt.Fprintln(run.stdout, "\nEgress profiles:")
+		run.egressReport = analysis.AnalyzeEgressProfiles(run.vpcs, run.routeTables, run.securityGroups, run.natGateways, run.vpcEndpoints, run.egressOptions)
+		reportJSON, _ := output.Marshal(run.egressReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Track the state history of the transit gateway attachments across earlier reports if requested
+	if *run.stabilityHistory != "" {
+		var history []analysis.AttachmentObservation
+		for _, snapshot := range loadSnapshotDir(*run.stabilityHistory) {
+			at, err := time.Parse(time.RFC3339, snapshot.ScannedAt)
+			if err != nil {
+				run.result.warnf("skipping a report in %s: invalid scan time %q", *run.stabilityHistory, snapshot.ScannedAt)
+				continue
+			}
+			history = append(history, analysis.AttachmentObservation{ScannedAt: at, Attachments: snapshot.TGWAttachments})
+		}
+		fmt.Fprintln(run.stdout, "\nAttachment stability:")
+		run.stabilityReport = analysis.AnalyzeAttachmentStability(history, run.tgwAttachments, run.scannedAt, run.stabilityOptions)
+		if run.stabilityReport.SkippedReports > 0 {
+			run.result.warnf("%d report(s) in %s have no transit gateway attachments and were skipped", run.stabilityReport.SkippedReports, *run.stabilityHistory)
+		}
+		reportJSON, _ := output.Marshal(run.stabilityReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Compare endpoint and NAT egress per service if requested
+	if *run.endpointCoverage {
+		fmt.Fprintln(run.stdout, "\nEndpoint coverage:")
+		run.endpointReport = analysis.AnalyzeEndpointCoverage(run.vpcs, run.subnets, run.routeTables, run.vpcEndpoints, splitList(*run.endpointServices))
+		reportJSON, _ := output.Marshal(run.endpointReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+
+		fmt.Fprintln(run.stdout, "\nEndpoint AZ coverage:")
+		run.endpointAZReport = analysis.AnalyzeEndpointAZCoverage(run.subnets, run.routeTables, run.vpcEndpoints, run.endpointInterfaces)
+		reportJSON, _ = output.Marshal(run.endpointAZReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Classify PrivateLink connectivity by provider if requested; service owners are optional like the endpoints
+	if *run.thirdParty {
+		seen := make(map[string]bool)
+		var serviceNames []string
+		for _, endpoint := range run.vpcEndpoints {
+			if endpoint.EndpointType != vpc.EndpointTypeGateway && !seen[endpoint.ServiceName] {
+				seen[endpoint.ServiceName] = true
+				serviceNames = append(serviceNames, endpoint.ServiceName)
+			}
+		}
+		services, err := run.scanner.GetVpcEndpointServices(ctx, serviceNames)
+		if err != nil {
+			run.result.skipf("endpoint service owners, endpoint services are classified by name only: %v", err)
+		}
+
+		fmt.Fprintln(run.stdout, "\nThird-party connectivity:")
+		run.thirdPartyReport = analysis.AnalyzeThirdPartyConnectivity(run.vpcEndpoints, services, run.catalog)
+		reportJSON, _ := output.Marshal(run.thirdPartyReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// List the endpoints and VPCs each private API can be invoked from if requested
+	if run.privateAPIs != nil {
+		fmt.Fprintln(run.stdout, "\nPrivate API ingress:")
+		run.privateAPIReport = analysis.AnalyzePrivateAPIs(run.privateAPIs, run.vpcEndpoints, run.vpcs)
+		reportJSON, _ := output.Marshal(run.privateAPIReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// List the public IP inventory if requested
+	if *run.publicIPs {
+		fmt.Fprintln(run.stdout, "\nPublic IPs:")
+		reportJSON, _ := output.Marshal(run.publicIPReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// List the network limits of the instances per subnet if requested
+	if *run.instanceNetwork {
+		fmt.Fprintln(run.stdout, "\nSubnet network capacity:")
+		reportJSON, _ := output.Marshal(run.subnetNetworkReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+	}
+
+	// Export the public IP inventory if requested
+	if *run.publicIPsCSV != "" {
+		file, err := os.Create(*run.publicIPsCSV)
+		if err != nil {
+			return fmt.Errorf("Failed to create public IP CSV file: %w", err)
+		}
+		if err := output.WritePublicIPsCSV(file, run.publicIPReport.PublicIPs); err != nil {
+			return fmt.Errorf("Failed to write public IP CSV file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("Failed to write public IP CSV file: %w", err)
+		}
+		run.result.output(*run.publicIPsCSV)
+		fmt.Fprintf(run.stdout, "Public IP inventory saved to: %s\n", *run.publicIPsCSV)
+	}
+	return nil
+}
+
+// buildFindings collects the findings of the PDF report, -template, the versioned report and -fail-on
+// Returns: Error that stopped the run
+func (run *scanRun) buildFindings() error {
+	// Security group reference problems, redundant rules and routed appliance problems are the findings
+	// of the PDF report, of -template and of the -fail-on gate
+	if run.collectFindings {
+		for _, finding := range analysis.AnalyzeSecurityGroupReferences(run.securityGroups, run.routeTables).Findings {
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Classification,
+				ResourceID: finding.GroupID,
+				Title:      fmt.Sprintf("Rule references %s", finding.ReferencedGroupID),
+				Detail:     finding.Reason,
+			})
+		}
+		for _, finding := range analysis.AnalyzeRedundantRules(run.securityGroups).Findings {
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: finding.GroupID,
+				Title:      fmt.Sprintf("%s rule %s", finding.Kind, finding.Rule),
+				Detail:     finding.Reason,
+			})
+		}
+		// Open rules are only findings when asked for, as nearly every group has the default egress rule
+		if *run.securityReport {
+			for _, finding := range analysis.AnalyzeSecurityGroups(run.securityGroups) {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.GroupID,
+					Title:      fmt.Sprintf("Open rule %s", finding.Rule),
+					Detail:     finding.Description,
+				})
+			}
+		}
+		for _, finding := range run.untrustedSourceFindings {
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: finding.GroupID,
+				Title:      "Ingress from untrusted range " + finding.Range,
+				Detail:     finding.Rule,
+			})
+		}
+
+		if run.sgUsageReport != nil {
+			for _, finding := range run.sgUsageReport.Findings {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.GroupID,
+					Title:      "Orphaned security group " + finding.GroupName,
+					Detail:     finding.Reason,
+				})
+			}
+		}
+
+		for _, finding := range run.pathMTUFindings {
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: finding.SourceVpcID,
+				Title:      fmt.Sprintf("Path MTU %d to %s", finding.MTU, finding.DestinationVpcID),
+				Detail:     finding.Reason,
+			})
+		}
+
+		for _, finding := range analysis.AnalyzeRouteAppliances(run.routeAppliances).Findings {
+			resourceID := finding.InstanceID
+			if resourceID == "" {
+				resourceID = finding.NetworkInterfaceID
+			}
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: resourceID,
+				Title:      finding.Classification,
+				Detail:     finding.Reason,
+			})
+		}
+
+		for _, finding := range run.isolationReport.Findings {
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: finding.VpcID,
+				Title:      fmt.Sprintf("Isolation %s but %s", finding.Intent, finding.Connectivity),
+				Detail:     finding.Reason,
+			})
+		}
+
+		if run.egressReport != nil {
+			run.egressProfileList = run.egressReport.VPCs
+			for _, finding := range run.egressReport.Findings {
+				resourceID := finding.GroupID
+				if resourceID == "" {
+					resourceID = finding.VpcID
+				}
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: resourceID,
+					Title:      finding.Classification,
+					Detail:     finding.Reason,
+				})
+			}
+		}
+
+		if run.stabilityReport != nil {
+			for _, finding := range run.stabilityReport.Findings {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.AttachmentID,
+					Title:      fmt.Sprintf("Flapping %s attachment", finding.ResourceType),
+					Detail:     finding.Reason,
+				})
+			}
+		}
+
+		if run.subnetNetworkReport != nil {
+			for _, finding := range run.subnetNetworkReport.Findings {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.SubnetID,
+					Title:      "Worst-case ENI demand exceeds free addresses",
+					Detail:     finding.Reason,
+				})
+			}
+		}
+
+		for _, finding := range run.localRouteFindings {
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: finding.RouteTableID,
+				Title:      fmt.Sprintf("Stale local route %s", finding.Destination),
+				Detail:     finding.Reason,
+			})
+		}
+
+		for _, finding := range run.routeTargetFindings {
+			detail := finding.Reason
+			if len(finding.SubnetIDs) > 0 {
+				detail += fmt.Sprintf(" (affects %s)", strings.Join(finding.SubnetIDs, ", "))
+			}
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: finding.RouteTableID,
+				Title:      fmt.Sprintf("Route %s to %s", finding.Destination, finding.TargetID),
+				Detail:     detail,
+			})
+		}
+
+		for _, finding := range run.dnsFindings {
+			run.findings = append(run.findings, pdf.Finding{
+				Severity:   finding.Severity,
+				ResourceID: finding.VpcID,
+				Title:      finding.Classification,
+				Detail:     finding.Reason,
+			})
+		}
+		if run.dnsReport != nil {
+			run.privateZones = run.dnsReport.PrivateZones
+		}
+		if run.endpointReport != nil {
+			for _, recommendation := range run.endpointReport.Recommendations {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   "endpoint-recommendation",
+					ResourceID: recommendation.VpcID,
+					Title:      fmt.Sprintf("%s traffic via NAT", recommendation.Service),
+					Detail:     recommendation.Recommendation,
+				})
+			}
+		}
+		if run.endpointAZReport != nil {
+			run.endpointAZs = run.endpointAZReport.Endpoints
+			for _, finding := range run.endpointAZReport.Findings {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.VpcEndpointID,
+					Title:      "Endpoint missing from " + strings.Join(finding.MissingAZs, ", "),
+					Detail:     finding.Reason,
+				})
+			}
+		}
+
+		if run.thirdPartyReport != nil {
+			run.vendors = run.thirdPartyReport.Vendors
+			for _, finding := range run.thirdPartyReport.Findings {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.VpcEndpointID,
+					Title:      "Endpoint to unknown third party",
+					Detail:     finding.Reason,
+				})
+			}
+		}
+
+		if run.privateAPIReport != nil {
+			for _, finding := range run.privateAPIReport.Findings {
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.RestApiID,
+					Title:      fmt.Sprintf("Private API %s: %s", finding.Name, finding.Classification),
+					Detail:     finding.Reason,
+				})
+			}
+		}
+
+		if run.publicIPReport != nil {
+			run.pdfPublicIPs = run.publicIPReport.PublicIPs
+			for _, finding := range run.publicIPReport.Findings {
+				detail := finding.Reason
+				if len(finding.DNSNames) > 0 {
+					detail += fmt.Sprintf(" (resolves as %s)", strings.Join(finding.DNSNames, ", "))
+				}
+				run.findings = append(run.findings, pdf.Finding{
+					Severity:   finding.Severity,
+					ResourceID: finding.InstanceID,
+					Title:      fmt.Sprintf("Ephemeral public IP %s on long-lived instance", finding.PublicIp),
+					Detail:     detail,
+				})
+			}
+		}
+
+		run.findings = analysis.WithoutManaged(run.findings, func(f pdf.Finding) string { return f.ResourceID }, run.managedBy, run.skipManagers)
+		for _, finding := range run.findings {
+			run.result.finding(finding.Severity)
+		}
+	}
+	return nil
+}
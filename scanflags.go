@@ -0,0 +1,492 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/crossaccount"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/graph"
+	"aws-documentor/modules/netcalc"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/templates"
+	"aws-documentor/modules/vpc"
+)
+
+// scanFlags holds the flags of the scan command
+type scanFlags struct {
+	region                *string         // -region
+	roleARN               *string         // -role-arn
+	externalID            *string         // -external-id
+	tagPairs              repeatedFlag    // -tag
+	generateDiagram       *bool           // -diagram
+	diagramFormat         *string         // -diagram-format
+	outputJSON            *bool           // -json
+	silent                *bool           // -silent
+	lifecycleReport       *bool           // -lifecycle
+	sgReferences          *bool           // -sg-references
+	sgRedundancy          *bool           // -sg-redundancy
+	securityReport        *bool           // -security-report
+	inspectionPaths       *bool           // -inspection-paths
+	isolation             *bool           // -isolation
+	egressProfiles        *bool           // -egress-profiles
+	proxyPorts            *string         // -proxy-ports
+	proxySGTag            *string         // -proxy-sg-tag
+	pathPropertiesFile    *string         // -path-properties
+	mtuThreshold          *int            // -mtu-threshold
+	endpointCoverage      *bool           // -endpoint-coverage
+	endpointServices      *string         // -endpoint-services
+	privateRanges         *string         // -private-ranges
+	publicIPs             *bool           // -public-ips
+	publicIPsCSV          *string         // -public-ips-csv
+	resolveDNS            *bool           // -resolve-dns
+	dnsServer             *string         // -dns-server
+	dnsTimeout            *time.Duration  // -dns-timeout
+	instanceNetwork       *bool           // -instance-network
+	dnsConcurrency        *int            // -dns-concurrency
+	thirdParty            *bool           // -third-party
+	saasCatalog           *string         // -saas-catalog
+	namedRangesFile       *string         // -named-ranges
+	managedByRules        *string         // -managed-by-rules
+	dimManaged            *string         // -dim-managed
+	skipManaged           *string         // -skip-managed
+	stabilityHistory      *string         // -stability-history
+	flapWindowFlag        *string         // -flap-window
+	flapThreshold         *int            // -flap-threshold
+	pdfFile               *string         // -pdf
+	compareWith           *string         // -compare-with
+	plantumlFile          *string         // -plantuml
+	templateFile          *string         // -template
+	templateOut           *string         // -template-out
+	templateSchema        *bool           // -template-schema
+	graphOut              *string         // -graph-out
+	graphFormatFlag       *string         // -graph-format
+	backstageOut          *string         // -backstage-out
+	backstageOwnerTags    *string         // -backstage-owner-tags
+	backstageDefaultOwner *string         // -backstage-default-owner
+	backstageSubnetTiers  *bool           // -backstage-subnet-tiers
+	detailDiagrams        *string         // -detail-diagrams
+	verbose               *bool           // -verbose
+	hideSystemTags        *bool           // -hide-system-tags
+	hideDefaultEgress     *bool           // -hide-default-egress
+	diagramPlain          *bool           // -diagram-plain
+	maxItemsPerSection    *int            // -max-items-per-section
+	sectionLimitsFlag     *string         // -section-limits
+	plantumlPlain         *bool           // -plantuml-plain
+	effectiveSources      *bool           // -effective-sources
+	scanContainers        *bool           // -containers
+	sgUsage               *bool           // -sg-usage
+	scanASGs              *bool           // -asgs
+	scanDirectories       *bool           // -directories
+	scanPrivateAPIs       *bool           // -private-apis
+	scanDNS               *bool           // -dns
+	includeDNSRecords     *bool           // -include-dns-records
+	drReplication         *bool           // -dr-replication
+	drRegions             *string         // -dr-regions
+	autoExpandRegions     *bool           // -auto-expand-regions
+	scanCloudWAN          *bool           // -cloudwan
+	httpTimeout           *time.Duration  // -http-timeout
+	maxIdleConns          *int            // -max-idle-conns
+	proxy                 *string         // -proxy
+	checkpointDir         *string         // -checkpoint-dir
+	resume                *bool           // -resume
+	consistencyRecheck    *bool           // -consistency-recheck
+	consistencyWait       *time.Duration  // -consistency-wait
+	maxAPICalls           *int            // -max-api-calls
+	scanManifest          *string         // -scan-manifest
+	saveFile              *string         // -save
+	terraformOut          *string         // -terraform-out
+	loadFile              *string         // -load
+	freshness             *freshnessFlags // -max-age and -allow-stale of -load
+	fieldStyleFlag        *string         // -field-style
+	formatFlag            *string         // -format
+	profiles              *string         // -profiles
+	profileParallelism    *int            // -profile-parallelism
+	profilesDir           *string         // -profiles-dir
+	pendingThreshold      *time.Duration  // -pending-threshold
+	allRegions            *bool           // -all-regions
+	regionParallelism     *int            // -region-parallelism
+	resultFile            *string         // -result-file
+	failOn                *string         // -fail-on
+	legacyStdout          *bool           // -legacy-stdout
+	reportOut             *string         // -report-out
+	validateOnly          *bool           // -validate-only
+}
+
+// registerScanFlags registers the flags of the scan command
+// Returns: The flag values, set once flag.Parse runs
+func registerScanFlags() *scanFlags {
+	f := &scanFlags{}
+	f.region = flag.String("region", "", "AWS region to scan (optional, uses default config if not specified)")
+	f.roleARN = flag.String("role-arn", "", "Assume this role before scanning, to scan another account such as a central network account")
+	f.externalID = flag.String("external-id", "", "External ID the -role-arn role requires, for roles that third parties assume")
+	flag.Var(&f.tagPairs, "tag", "Only scan resources carrying this tag, as KEY=VALUE; repeat for several tags, which a resource must all carry")
+	f.generateDiagram = flag.Bool("diagram", false, "Generate draw.io diagram file (saves to vpc-diagram.drawio)")
+	f.diagramFormat = flag.String("diagram-format", "drawio", "Format of the -diagram output: drawio (vpc-diagram.drawio) or mermaid (vpc-diagram.md, a Mermaid flowchart GitHub, GitLab and Notion render)")
+	f.outputJSON = flag.Bool("json", true, "Output JSON data to stdout (default: true unless a file output such as -diagram, -pdf or -plantuml is requested)")
+	f.silent = flag.Bool("silent", false, "Suppress all output except warnings and errors (for cron and scripts)")
+	f.lifecycleReport = flag.Bool("lifecycle", false, "Print a resource age and lifecycle summary after the scan")
+	f.sgReferences = flag.Bool("sg-references", false, "Report security group rules that reference deleted or unreachable foreign security groups")
+	f.sgRedundancy = flag.Bool("sg-redundancy", false, "Report security group rules that are shadowed by a broader rule or duplicate another rule")
+	f.securityReport = flag.Bool("security-report", false, "Print security group rules open to 0.0.0.0/0 or ::/0 for all traffic or sensitive ports (SSH, RDP, databases) to stderr after the scan output")
+	f.inspectionPaths = flag.Bool("inspection-paths", false, "Trace traffic between VPCs attached to the same transit gateway and report which pairs pass an inspection VPC")
+	f.isolation = flag.Bool("isolation", false, "Classify every VPC as internet-connected, egress-via-hub or isolated and report VPCs whose aws-documentor:isolation tag (required or forbidden) contradicts it")
+	f.egressProfiles = flag.Bool("egress-profiles", false, "Classify the egress of every VPC as unrestricted, proxy-only, endpoints-only or no-egress from its default routes and security group egress rules, list its choke points and the security groups that break the dominant pattern, and report VPCs whose aws-documentor:egress-profile tag it exceeds")
+	f.proxyPorts = flag.String("proxy-ports", "3128,8080", "Comma-separated ports of the forward proxies: -egress-profiles counts egress on only these ports to a specific host or network as going through a proxy")
+	f.proxySGTag = flag.String("proxy-sg-tag", "aws-documentor:egress-role=proxy", "Tag KEY=VALUE (or KEY for any value) of the proxy security groups, which -egress-profiles lets egress directly and counts egress to as going through a proxy")
+	f.pathPropertiesFile = flag.String("path-properties", "", "JSON file overriding the MTU and bandwidth cap of link types and the MTU threshold used by -inspection-paths")
+	f.mtuThreshold = flag.Int("mtu-threshold", 0, "Report -inspection-paths paths whose smallest MTU is below this many bytes (default: 8500, or mtu_threshold of -path-properties)")
+	f.endpointCoverage = flag.Bool("endpoint-coverage", false, "Report per VPC and route table whether traffic to AWS services uses a VPC endpoint or a NAT/internet gateway, with endpoint recommendations and the AZ coverage of interface endpoints")
+	f.endpointServices = flag.String("endpoint-services", strings.Join(analysis.DefaultEndpointServices, ","), "Comma-separated candidate services checked by -endpoint-coverage in addition to the services that already have endpoints")
+	f.privateRanges = flag.String("private-ranges", "", "Comma-separated corporate CIDR blocks treated as private in addition to RFC 1918 and fc00::/7 when reporting routes that send private ranges to an internet gateway and classifying -egress-profiles rules")
+	f.publicIPs = flag.Bool("public-ips", false, "List every public IPv4 address (Elastic IPs, instances, NAT gateways, load balancers, Global Accelerator) with its attachment and the ports open to the internet")
+	f.publicIPsCSV = flag.String("public-ips-csv", "", "Write the public IP inventory to this CSV file")
+	f.resolveDNS = flag.Bool("resolve-dns", false, "Attach the reverse DNS names of the public IPs, and the Route 53 public records pointing at them directly or through a load balancer alias, to the -public-ips inventory and findings (lookups are best effort and never fail the scan)")
+	f.dnsServer = flag.String("dns-server", "", "DNS server (host or host:port) for the -resolve-dns lookups (default: the system resolver)")
+	f.dnsTimeout = flag.Duration("dns-timeout", dns.DefaultLookupOptions().Timeout, "Timeout of each -resolve-dns lookup")
+	f.instanceNetwork = flag.Bool("instance-network", false, "Describe the instance types of the instances and roll up per subnet their network performance classes and the IPv4 addresses they could claim with every network interface attached, reporting subnets where that exceeds the free addresses")
+	f.dnsConcurrency = flag.Int("dns-concurrency", dns.DefaultLookupOptions().Concurrency, "-resolve-dns lookups running at the same time")
+	f.thirdParty = flag.Bool("third-party", false, "Classify interface endpoints as AWS service, internal, known SaaS or unknown third party and list the third parties by vendor")
+	f.saasCatalog = flag.String("saas-catalog", "", "JSON file of additional SaaS providers and internal accounts for -third-party")
+	f.namedRangesFile = flag.String("named-ranges", "", "JSON file naming corporate networks (offices, VPN pools, partners, scanners) by CIDR: security group rule, route and effective source CIDRs within them are labeled, and ingress from ranges marked untrusted is reported")
+	f.managedByRules = flag.String("managed-by-rules", "", "JSON file of rules recognizing the resources of further tools and controllers by tag or description, checked before the built-in ones")
+	f.dimManaged = flag.String("dim-managed", "", "Comma-separated managers (eks-lb-controller, karpenter, cloudformation, terraform, eks, manual or a -managed-by-rules label) whose resources are drawn muted in the diagrams")
+	f.skipManaged = flag.String("skip-managed", "", "Comma-separated managers whose resources get no findings, such as the orphaned security groups the load balancer controller cleans up itself")
+	f.stabilityHistory = flag.String("stability-history", "", "Directory of JSON reports from earlier scans (searched recursively): report how long every transit gateway attachment (VPC, VPN, peering, ...) has been in its state, how often it changed state and whether it is flapping")
+	f.flapWindowFlag = flag.String("flap-window", "30d", "How far back -stability-history counts the state changes of an attachment toward flapping (30d, 4w or a duration such as 720h)")
+	f.flapThreshold = flag.Int("flap-threshold", analysis.DefaultStabilityOptions().Threshold, "State changes within -flap-window that make -stability-history report an attachment as flapping")
+	f.pdfFile = flag.String("pdf", "", "Write a PDF report (summary, diagram, findings, per-VPC tables) to this file")
+	f.compareWith = flag.String("compare-with", "", "Compare the scan with an earlier JSON report (report.json of the Lambda function) and mark added, removed and changed resources in the PDF")
+	f.plantumlFile = flag.String("plantuml", "", "Write a PlantUML deployment diagram to this file")
+	f.templateFile = flag.String("template", "", "Go template (text, or HTML for .html files) to render the scan with into -template-out; see -template-schema for its data")
+	f.templateOut = flag.String("template-out", "", "Write the output of -template to this file")
+	f.templateSchema = flag.Bool("template-schema", false, "List the fields and helper functions -template can use, and exit")
+	f.graphOut = flag.String("graph-out", "", "Write the resources and their relationships as a graph database import to this directory")
+	f.graphFormatFlag = flag.String("graph-format", "cypher", "Graph export format for -graph-out: cypher (Cypher statements) or csv (neo4j-admin import files)")
+	f.backstageOut = flag.String("backstage-out", "", "Write the VPCs and transit gateways as Backstage catalog entities (one YAML file each, plus catalog-info.yaml listing them) to this directory")
+	f.backstageOwnerTags = flag.String("backstage-owner-tags", "Owner,team", "Comma-separated tag keys naming the owner of a resource for -backstage-out; the first one a resource has wins")
+	f.backstageDefaultOwner = flag.String("backstage-default-owner", "unknown", "Owner of the -backstage-out entities of resources without an owner tag")
+	f.backstageSubnetTiers = flag.Bool("backstage-subnet-tiers", false, "With -backstage-out, also write an entity per VPC and subnet tier (public, private, routed, isolated)")
+	f.detailDiagrams = flag.String("detail-diagrams", "", "Write a draw.io detail diagram per VPC (subnets, route tables, security groups) to this directory")
+	f.verbose = flag.Bool("verbose", false, "Embed the DHCP options set of each VPC (domain name, DNS, NTP and NetBIOS servers) in the VPC JSON output")
+	f.hideSystemTags = flag.Bool("hide-system-tags", false, "Leave the aws:-prefixed system tags (aws:cloudformation:stack-name, ...) out of the JSON output and reports")
+	f.hideDefaultEgress = flag.Bool("hide-default-egress", false, "Leave the default allow-all egress rules out of the PDF rule tables and diagram security group panels (JSON output keeps them)")
+	f.diagramPlain = flag.Bool("diagram-plain", false, "Write -diagram and -detail-diagrams cells as bare mxCell elements, without the object wrapper carrying resource attributes (resource_id, vpc_id, cidr, ...) and the full text of truncated labels")
+	f.maxItemsPerSection = flag.Int("max-items-per-section", 0, "Show at most this many items of each section (VPCs, subnets, findings, ...) in the PDF and diagrams, with a marker saying how many were left out; JSON and CSV outputs stay complete (default: no limit)")
+	f.sectionLimitsFlag = flag.String("section-limits", "", "Comma-separated section=limit overrides of -max-items-per-section, such as subnets=2000,findings=0 (0 for no limit)")
+	f.plantumlPlain = flag.Bool("plantuml-plain", false, "Use plain PlantUML frames and nodes instead of the AWS icon library")
+	f.effectiveSources = flag.Bool("effective-sources", false, "Expand the ingress sources of every security group per port range: CIDRs, plus the addresses of the network interfaces attached to referenced groups at scan time (can be large)")
+	f.scanContainers = flag.Bool("containers", false, "Scan the network configuration of ECS services and EKS node groups and Fargate profiles (skipped with a warning if not permitted)")
+	f.sgUsage = flag.Bool("sg-usage", false, "Report which network interfaces and container workloads use each security group and list the orphaned groups (scans containers as with -containers)")
+	f.scanASGs = flag.Bool("asgs", false, "Scan Auto Scaling groups and draw them across their subnets (skipped with a warning if not permitted)")
+	f.scanDirectories = flag.Bool("directories", false, "Scan Directory Service directories and WorkSpaces placement (skipped with a warning if not permitted)")
+	f.scanPrivateAPIs = flag.Bool("private-apis", false, "Scan private API Gateway REST APIs, list the VPC endpoints and VPCs their resource policies admit, and flag policies that admit endpoints outside the scan (skipped with a warning if not permitted)")
+	f.scanDNS = flag.Bool("dns", false, "Scan Route 53 private hosted zones, Resolver endpoints, DHCP options and endpoint DNS names and document how each VPC resolves names (skipped with a warning if not permitted)")
+	f.includeDNSRecords = flag.Bool("include-dns-records", false, "With -dns, list the name, type and alias target of every record in each private zone instead of only counts per type")
+	f.drReplication = flag.Bool("dr-replication", false, "Document cross-region RDS read replicas and EFS replication with the VPCs on both sides and the peering or transit gateway path between them (skipped with a warning if not permitted)")
+	f.drRegions = flag.String("dr-regions", "", "Comma-separated DR regions also scanned by -dr-replication, so replica VPCs and cross-region peering can be resolved")
+	f.autoExpandRegions = flag.Bool("auto-expand-regions", false, "Describe the peer VPCs and transit gateways of same-account peerings into regions that were not scanned, so the scan gaps show their names and CIDRs")
+	f.scanCloudWAN = flag.Bool("cloudwan", false, "Scan AWS Cloud WAN core networks and document segment routing for core network routes")
+	f.httpTimeout = flag.Duration("http-timeout", awsconfig.DefaultHTTPOptions().Timeout, "Timeout for each AWS API request, including reading the response")
+	f.maxIdleConns = flag.Int("max-idle-conns", awsconfig.DefaultHTTPOptions().MaxIdleConnsPerHost, "Idle connections kept open per AWS service endpoint for reuse")
+	f.proxy = flag.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	f.checkpointDir = flag.String("checkpoint-dir", "", "Checkpoint long paginations (network interfaces for -public-ips) to this directory after every page, so an interrupted scan can be resumed")
+	f.resume = flag.Bool("resume", false, "Continue the paginations checkpointed in -checkpoint-dir by an interrupted run for the same account and region instead of starting over")
+	f.consistencyRecheck = flag.Bool("consistency-recheck", false, "After the scan, fetch the resource types again whose references point at resources the scan did not return (EC2 eventual consistency right after changes), and report the gaps that remain as data-quality warnings")
+	f.consistencyWait = flag.Duration("consistency-wait", 15*time.Second, "How long -consistency-recheck waits before fetching again")
+	f.maxAPICalls = flag.Int("max-api-calls", 0, "Stop calling AWS after this many API requests (retries included) and write the outputs from the partial results (default: no limit)")
+	f.scanManifest = flag.String("scan-manifest", "", "Write the resource types this scan covered and their counts to this JSON file, for the coverage subcommand")
+	f.saveFile = flag.String("save", "", "Save every scanned resource to this JSON file, so -load can generate the outputs again later without AWS credentials")
+	f.terraformOut = flag.String("terraform-out", "", "Write Terraform 1.5+ import blocks for the VPCs, subnets, route tables and their associations, security groups, internet and NAT gateways and transit gateways to this file")
+	f.loadFile = flag.String("load", "", "Generate the outputs (stdout documents, -diagram, -detail-diagrams) from a file written by -save instead of scanning; no AWS calls are made")
+	f.freshness = registerFreshnessFlags(flag.CommandLine, "-load")
+	f.fieldStyleFlag = flag.String("field-style", "snake", "JSON field naming style: snake, camel (AWS-native names) or config (AWS Config configuration items)")
+	f.formatFlag = flag.String("format", "json", "Syntax of the documents printed to stdout: json or yaml (same fields in the same order, with --- between the resources)")
+	f.profiles = flag.String("profiles", "", "Comma-separated profiles of the shared AWS config files to scan in one run: their credentials are resolved one at a time (MFA and SSO prompts included), then the profiles are scanned in parallel, each into its own directory")
+	f.profileParallelism = flag.Int("profile-parallelism", 3, "Profiles scanned at the same time with -profiles")
+	f.profilesDir = flag.String("profiles-dir", ".", "Directory for the per-profile directories and profiles.json of -profiles")
+	f.pendingThreshold = flag.Duration("pending-threshold", crossaccount.DefaultPendingThreshold, "How long a peering, transit gateway attachment or shared transit gateway may wait for acceptance before -profiles reports it in organization.json")
+	f.allRegions = flag.Bool("all-regions", false, "Scan the core VPC resources (VPCs, subnets, route tables, security groups, gateways, transit gateways) of every region the account has enabled and print them grouped by region; -diagram draws each region as a container")
+	f.regionParallelism = flag.Int("region-parallelism", 5, "Regions scanned at the same time with -all-regions")
+	f.resultFile = flag.String("result-file", "", "Write the status, exit code, resource counts, findings per severity, duration and output files of the run to this JSON file, however the run ends")
+	f.failOn = flag.String("fail-on", "", "Exit with status 4 when a finding has at least this severity: high, medium or low (findings are collected as for -pdf)")
+	f.legacyStdout = flag.Bool("legacy-stdout", true, "Print the legacy stdout output (progress lines and one JSON object per resource); false prints one versioned report document (schema_version 1) to stdout instead and the rest to stderr")
+	f.reportOut = flag.String("report-out", "", "Write the versioned report document (schema_version 1, see the schema subcommand) to this file")
+	f.validateOnly = flag.Bool("validate-only", false, "Check the flags and config files, print every problem and exit without calling AWS (same as the validate subcommand)")
+	return f
+}
+
+// checkFlags collects every problem with the flags and config files before any AWS call
+// The values the later steps need (output options, tag filters, the -load file, ...) are parsed on the way.
+// Returns: The problems found, which runScan reports
+func (run *scanRun) checkFlags() *config.Validator {
+	var err error
+	// Collect every problem with the flags and config files before any AWS call
+	problems := &config.Validator{}
+	run.fieldStyle, err = output.ParseFieldStyle(*run.fieldStyleFlag)
+	problems.Check("-field-style", err)
+	run.format, err = output.ParseFormat(*run.formatFlag)
+	problems.Check("-format", err)
+	run.graphFormat, err = graph.ParseFormat(*run.graphFormatFlag)
+	problems.Check("-graph-format", err)
+	if *run.diagramFormat != diagramDrawio && *run.diagramFormat != diagramMermaid {
+		problems.Addf("-diagram-format", 0, "unknown format %q (expected drawio or mermaid)", *run.diagramFormat)
+	}
+
+	// Decide what goes to stdout from the flags that were actually given
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	flags := outputFlags{
+		JSON:    *run.outputJSON,
+		JSONSet: given["json"],
+		Silent:  *run.silent,
+		Report:  !*run.legacyStdout,
+	}
+	for _, name := range []string{"diagram", "detail-diagrams", "pdf", "plantuml", "graph-out", "backstage-out", "public-ips-csv", "template-out", "report-out", "save", "terraform-out"} {
+		if given[name] {
+			flags.FileOutputs = append(flags.FileOutputs, "-"+name)
+		}
+	}
+	for _, name := range []string{"lifecycle", "sg-references", "sg-redundancy", "inspection-paths", "isolation", "egress-profiles", "stability-history", "endpoint-coverage", "third-party", "private-apis", "dr-replication", "public-ips", "instance-network"} {
+		if given[name] {
+			flags.StdoutReports = append(flags.StdoutReports, "-"+name)
+		}
+	}
+	run.opts, err = resolveOutputOptions(flags)
+	problems.Check("flags", err)
+	if given["format"] && err == nil && !run.opts.JSON && !run.opts.Report {
+		problems.Addf("-format", 0, "has no effect, as no documents are printed to stdout (add -json)")
+	}
+	checkFlagDependencies(problems, given)
+
+	if *run.region != "" {
+		problems.Check("-region", config.CheckRegion(*run.region))
+	}
+	for _, drRegion := range splitList(*run.drRegions) {
+		problems.Check("-dr-regions", config.CheckRegion(drRegion))
+	}
+	if *run.proxy != "" {
+		_, err := awsconfig.ParseProxy(*run.proxy)
+		problems.Check("-proxy", err)
+	}
+	if *run.httpTimeout < 0 {
+		problems.Addf("-http-timeout", 0, "must not be negative")
+	}
+	if *run.maxIdleConns < 0 {
+		problems.Addf("-max-idle-conns", 0, "must not be negative")
+	}
+	if *run.consistencyWait < 0 {
+		problems.Addf("-consistency-wait", 0, "must not be negative")
+	}
+	if *run.resolveDNS && !*run.publicIPs && *run.publicIPsCSV == "" {
+		problems.Addf("-resolve-dns", 0, "has no effect without -public-ips or -public-ips-csv")
+	}
+	if *run.dnsTimeout <= 0 {
+		problems.Addf("-dns-timeout", 0, "must be positive")
+	}
+	if *run.dnsConcurrency < 1 {
+		problems.Addf("-dns-concurrency", 0, "must be at least 1")
+	}
+	if *run.maxAPICalls < 0 {
+		problems.Addf("-max-api-calls", 0, "must not be negative")
+	}
+	if *run.maxItemsPerSection < 0 {
+		problems.Addf("-max-items-per-section", 0, "must not be negative")
+	}
+	run.sectionLimits, err = output.ParseSectionLimits(*run.maxItemsPerSection, *run.sectionLimitsFlag)
+	problems.Check("-section-limits", err)
+	for _, block := range splitList(*run.privateRanges) {
+		if _, err := netcalc.PrefixLength(block); err != nil {
+			problems.Addf("-private-ranges", 0, "%v", err)
+		}
+	}
+	run.egressOptions = analysis.DefaultEgressOptions()
+	run.egressOptions.PrivateRanges = append(append([]string{}, analysis.DefaultPrivateRanges...), splitList(*run.privateRanges)...)
+	run.egressOptions.ProxyPorts, err = parsePorts(*run.proxyPorts)
+	problems.Check("-proxy-ports", err)
+	if *run.roleARN != "" {
+		problems.Check("-role-arn", config.CheckRoleARN(*run.roleARN))
+	}
+	run.tagFilters, err = parseTagFilters(run.tagPairs)
+	problems.Check("-tag", err)
+	run.scanOptions = vpc.ScanOptions{TagFilters: run.tagFilters}
+	run.egressOptions.ProxyTagKey, run.egressOptions.ProxyTagValue, _ = strings.Cut(*run.proxySGTag, "=")
+	if strings.TrimSpace(run.egressOptions.ProxyTagKey) == "" {
+		problems.Addf("-proxy-sg-tag", 0, "needs a tag key")
+	}
+	if given["endpoint-services"] && len(splitList(*run.endpointServices)) == 0 {
+		problems.Addf("-endpoint-services", 0, "lists no services")
+	}
+	if *run.pdfFile != "" {
+		problems.Check("-pdf", config.CheckOutputFile(*run.pdfFile))
+	}
+	if *run.plantumlFile != "" {
+		problems.Check("-plantuml", config.CheckOutputFile(*run.plantumlFile))
+	}
+	// Parsed before scanning, so a syntax error or unknown function does not cost a scan
+	if *run.templateFile != "" {
+		run.userTemplate, err = templates.Parse(*run.templateFile)
+		problems.Check("-template", err)
+		if *run.templateOut == "" {
+			problems.Addf("-template", 0, "needs -template-out for its output")
+		}
+	}
+	if *run.templateOut != "" {
+		problems.Check("-template-out", config.CheckOutputFile(*run.templateOut))
+	}
+	if *run.publicIPsCSV != "" {
+		problems.Check("-public-ips-csv", config.CheckOutputFile(*run.publicIPsCSV))
+	}
+	if *run.scanManifest != "" {
+		problems.Check("-scan-manifest", config.CheckOutputFile(*run.scanManifest))
+	}
+	if *run.resultFile != "" {
+		problems.Check("-result-file", config.CheckOutputFile(*run.resultFile))
+	}
+	if *run.saveFile != "" {
+		problems.Check("-save", config.CheckOutputFile(*run.saveFile))
+	}
+	if *run.terraformOut != "" {
+		problems.Check("-terraform-out", config.CheckOutputFile(*run.terraformOut))
+	}
+	if *run.reportOut != "" {
+		problems.Check("-report-out", config.CheckOutputFile(*run.reportOut))
+	}
+	if *run.failOn != "" {
+		if run.result.failOn = severityRanks[*run.failOn]; run.result.failOn == 0 {
+			problems.Addf("-fail-on", 0, "unknown severity %q (expected high, medium or low)", *run.failOn)
+		}
+	}
+	if *run.graphOut != "" {
+		problems.Check("-graph-out", config.CheckOutputDir(*run.graphOut))
+	}
+	if *run.backstageOut != "" {
+		problems.Check("-backstage-out", config.CheckOutputDir(*run.backstageOut))
+		if len(splitList(*run.backstageOwnerTags)) == 0 {
+			problems.Addf("-backstage-owner-tags", 0, "no tag keys given")
+		}
+		if *run.backstageDefaultOwner == "" {
+			problems.Addf("-backstage-default-owner", 0, "an owner is required, since every Backstage resource needs one")
+		}
+	}
+	if *run.detailDiagrams != "" {
+		problems.Check("-detail-diagrams", config.CheckOutputDir(*run.detailDiagrams))
+	}
+	if *run.checkpointDir != "" {
+		problems.Check("-checkpoint-dir", config.CheckOutputDir(*run.checkpointDir))
+	}
+	if *run.saasCatalog != "" {
+		problems.SaaSCatalogFile(*run.saasCatalog)
+	}
+	if *run.namedRangesFile != "" {
+		problems.NamedRangesFile(*run.namedRangesFile)
+	}
+	knownManagers := analysis.NewManagerClassifier(nil).Managers()
+	if *run.managedByRules != "" {
+		problems.ManagerRulesFile(*run.managedByRules)
+		if rules, err := config.LoadManagerRules(*run.managedByRules); err == nil {
+			knownManagers = analysis.NewManagerClassifier(rules).Managers()
+		}
+	}
+	for _, managerFlag := range []struct{ name, value string }{{"-dim-managed", *run.dimManaged}, {"-skip-managed", *run.skipManaged}} {
+		for _, manager := range splitList(managerFlag.value) {
+			if !slices.Contains(knownManagers, manager) {
+				problems.Addf(managerFlag.name, 0, "unknown manager %q (known: %s)", manager, strings.Join(knownManagers, ", "))
+			}
+		}
+	}
+	if *run.pathPropertiesFile != "" {
+		problems.PathPropertiesFile(*run.pathPropertiesFile)
+	}
+	if *run.profiles != "" {
+		seen := make(map[string]bool)
+		for _, profile := range splitList(*run.profiles) {
+			if seen[profile] {
+				problems.Addf("-profiles", 0, "lists %s twice", profile)
+			}
+			seen[profile] = true
+		}
+		if len(seen) == 0 {
+			problems.Addf("-profiles", 0, "lists no profiles")
+		}
+		if *run.profileParallelism < 1 {
+			problems.Addf("-profile-parallelism", 0, "must be at least 1")
+		}
+		problems.Check("-profiles-dir", config.CheckOutputDir(*run.profilesDir))
+		if *run.pendingThreshold < 0 {
+			problems.Addf("-pending-threshold", 0, "must not be negative")
+		}
+		// Every profile writes its outputs below its own directory, so they must not share a path
+		for _, name := range profileOutputFlags {
+			if value := flag.Lookup(name).Value.String(); filepath.IsAbs(value) {
+				problems.Addf("-"+name, 0, "must be a relative path with -profiles, as each profile writes it below its own directory")
+			}
+		}
+	}
+	if *run.allRegions {
+		if *run.regionParallelism < 1 {
+			problems.Addf("-region-parallelism", 0, "must be at least 1")
+		}
+		// Only the core scan runs per region, so the flags of the other scanners, analyses and outputs do nothing
+		flag.Visit(func(f *flag.Flag) {
+			if !allRegionsFlags[f.Name] {
+				problems.Addf("-"+f.Name, 0, "cannot be combined with -all-regions, which scans the core VPC resources only")
+			}
+		})
+	}
+	if *run.mtuThreshold < 0 || *run.mtuThreshold > 9001 {
+		problems.Addf("-mtu-threshold", 0, "must be between 0 and 9001")
+	}
+	run.freshness.validate(problems, *run.loadFile != "")
+	if *run.loadFile != "" {
+		run.loaded, err = report.Load(*run.loadFile)
+		problems.Check("-load", err)
+		// Nothing is scanned, so only the outputs generated from the saved resources can be asked for
+		flag.Visit(func(f *flag.Flag) {
+			if !loadFlags[f.Name] {
+				problems.Addf("-"+f.Name, 0, "cannot be combined with -load, which only prints the saved resources and draws the diagrams")
+			}
+		})
+	}
+	if *run.compareWith != "" {
+		run.earlierReport, err = diff.LoadSnapshot(*run.compareWith)
+		problems.Check("-compare-with", err)
+	}
+	run.stabilityOptions = analysis.DefaultStabilityOptions()
+	run.stabilityOptions.Window, err = config.ParseWindow(*run.flapWindowFlag)
+	problems.Check("-flap-window", err)
+	run.stabilityOptions.Threshold = *run.flapThreshold
+	if *run.flapThreshold < 1 {
+		problems.Addf("-flap-threshold", 0, "must be at least 1")
+	}
+	if *run.stabilityHistory != "" {
+		if info, err := os.Stat(*run.stabilityHistory); err != nil || !info.IsDir() {
+			problems.Addf("-stability-history", 0, "%s is not a directory", *run.stabilityHistory)
+		}
+	}
+
+	run.httpOptions = awsconfig.HTTPOptions{
+		Timeout:             *run.httpTimeout,
+		MaxIdleConnsPerHost: *run.maxIdleConns,
+		Proxy:               *run.proxy,
+	}
+
+	// Arguments after the flags are change policy files for validate; a scan takes none
+	for _, arg := range flag.Args() {
+		if run.validateCommand {
+			problems.PolicyFile(arg)
+		} else {
+			problems.Addf(arg, 0, "unexpected argument (flags must come before it, and policy files are only checked by the validate subcommand)")
+		}
+	}
+	return problems
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/backstage"
+	"aws-documentor/modules/coverage"
+	"aws-documentor/modules/diagram"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/graph"
+	"aws-documentor/modules/mermaid"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/pdf"
+	"aws-documentor/modules/plantuml"
+	"aws-documentor/modules/schema"
+	"aws-documentor/modules/templates"
+	"aws-documentor/modules/vpc"
+)
+
+// selectShown decides which items the human-oriented outputs show
+// The PDF and diagrams show at most -max-items-per-section items of each section, chosen in ID order.
+// The cuts are decided once here so every human-oriented output shows the same resources; the
+// JSON and CSV outputs, the analyses and the summary counts use the full data.
+func (run *scanRun) selectShown() {
+	run.truncations = output.Truncations{}
+	run.shownVPCs = output.Truncate(run.truncations, run.sectionLimits, output.SectionVPCs, run.vpcs, func(v vpc.VPCInfo) string { return v.VpcID })
+	run.shownSubnets, run.shownRouteTables, run.shownSecurityGroups, run.shownNatGateways = run.subnets, run.routeTables, run.securityGroups, run.natGateways
+	if len(run.shownVPCs) < len(run.vpcs) {
+		// Resources of VPCs that are left out would not be shown anywhere
+		inShownVPC := make(map[string]bool, len(run.shownVPCs))
+		for _, v := range run.shownVPCs {
+			inShownVPC[v.VpcID] = true
+		}
+		run.shownSubnets = output.Filter(run.truncations, output.SectionSubnets, run.subnets, func(s vpc.SubnetInfo) bool { return inShownVPC[s.VpcID] })
+		run.shownRouteTables = output.Filter(run.truncations, output.SectionRouteTables, run.routeTables, func(rt vpc.RouteTableInfo) bool { return inShownVPC[rt.VpcID] })
+		run.shownSecurityGroups = output.Filter(run.truncations, output.SectionSecurityGroups, run.securityGroups, func(sg vpc.SecurityGroupInfo) bool { return inShownVPC[sg.VpcID] })
+		run.shownNatGateways = output.Filter(run.truncations, output.SectionNatGateways, run.natGateways, func(ngw vpc.NatGatewayInfo) bool { return inShownVPC[ngw.VpcID] })
+	}
+	run.shownSubnets = output.Truncate(run.truncations, run.sectionLimits, output.SectionSubnets, run.shownSubnets, func(s vpc.SubnetInfo) string { return s.VpcID + " " + s.SubnetID })
+	run.shownRouteTables = output.Truncate(run.truncations, run.sectionLimits, output.SectionRouteTables, run.shownRouteTables, func(rt vpc.RouteTableInfo) string { return rt.VpcID + " " + rt.RouteTableID })
+	run.shownSecurityGroups = output.Truncate(run.truncations, run.sectionLimits, output.SectionSecurityGroups, run.shownSecurityGroups, func(sg vpc.SecurityGroupInfo) string { return sg.VpcID + " " + sg.GroupID })
+	run.shownNatGateways = output.Truncate(run.truncations, run.sectionLimits, output.SectionNatGateways, run.shownNatGateways, func(ngw vpc.NatGatewayInfo) string { return ngw.VpcID + " " + ngw.NatGatewayID })
+	run.shownTGWAttachments = output.Truncate(run.truncations, run.sectionLimits, output.SectionTGWAttachments, run.tgwAttachments, func(a vpc.TransitGatewayAttachmentInfo) string { return a.TransitGatewayID + " " + a.AttachmentID })
+	run.shownDataWarnings = output.Truncate(run.truncations, run.sectionLimits, output.SectionDataWarnings, run.dataWarnings, func(w vpc.DataWarning) string { return w.ResourceType + " " + w.ResourceID + " " + w.Field })
+}
+
+// writeDiagrams decides which items the human-oriented outputs show and writes -diagram and -detail-diagrams
+// Returns: Error if a diagram cannot be generated or written
+func (run *scanRun) writeDiagrams() error {
+	run.selectShown()
+	run.diagramNotes = run.truncations.Markers()
+	for _, marker := range run.diagramNotes {
+		log.Printf("Note: %s", marker)
+	}
+
+	// Generate diagram if requested, as a Mermaid flowchart with -diagram-format mermaid
+	if *run.generateDiagram && *run.diagramFormat == diagramMermaid {
+		fmt.Fprintln(run.stdout, "\nGenerating Mermaid diagram...")
+		mermaidGen := mermaid.NewMermaidGenerator()
+		mermaidGen.Notes = run.diagramNotes
+		doc, err := mermaidGen.GenerateVPCDiagram(
+			run.shownVPCs,
+			run.shownSubnets,
+			run.shownRouteTables,
+			run.shownSecurityGroups,
+			run.internetGateways,
+			run.shownNatGateways,
+			run.transitGateways,
+			run.shownTGWAttachments,
+		)
+		if err != nil {
+			return fmt.Errorf("Failed to generate Mermaid diagram: %w", err)
+		}
+		if err := writeMermaidDiagram(run.stdout, run.result, mermaidGen.Markdown(doc)); err != nil {
+			return err
+		}
+	} else if *run.generateDiagram {
+		fmt.Fprintln(run.stdout, "\nGenerating draw.io diagram...")
+		diagramGen := diagram.NewDiagramGenerator()
+		diagramGen.SetCoreNetworks(run.coreNetworks)
+		diagramGen.SetDirectories(run.directories)
+		diagramGen.SetAutoScalingGroups(run.autoScalingGroups)
+		diagramGen.SetRouteAppliances(run.routeAppliances)
+		diagramGen.SetInstances(run.instances)
+		diagramGen.SetVpcEndpoints(run.vpcEndpoints)
+		diagramGen.SetPrivateAPIs(run.privateAPIs)
+		diagramGen.SetVpcPeeringConnections(run.peerings)
+		diagramGen.SetVpnConnections(run.vpnConnections, run.customerGateways, run.vpnGateways)
+		diagramGen.SetElasticIPs(run.elasticIPs)
+		diagramGen.SetHideDefaultEgress(*run.hideDefaultEgress)
+		diagramGen.SetVPCConnectivity(run.isolationReport.VPCs)
+		if run.stabilityReport != nil {
+			diagramGen.SetAttachmentStability(run.stabilityReport.Attachments)
+		}
+		diagramGen.SetScanContext(run.cfg.Region, run.accountID)
+		diagramGen.SetPlainCells(*run.diagramPlain)
+		diagramGen.SetDimManaged(splitList(*run.dimManaged))
+		diagramGen.SetNotes(run.diagramNotes)
+
+		diagramXML, err := diagramGen.GenerateVPCDiagram(
+			run.shownVPCs,
+			run.shownSubnets,
+			run.shownRouteTables,
+			run.shownSecurityGroups,
+			run.internetGateways,
+			run.shownNatGateways,
+			run.transitGateways,
+			run.shownTGWAttachments,
+		)
+		if err != nil {
+			return fmt.Errorf("Failed to generate diagram: %w", err)
+		}
+		if err := writeDiagram(run.stdout, run.result, diagramXML); err != nil {
+			return err
+		}
+	}
+
+	// Generate per-VPC detail diagrams if requested, several at a time
+	if *run.detailDiagrams != "" {
+		fmt.Fprintln(run.stdout, "\nGenerating draw.io detail diagrams...")
+		diagramGen := diagram.NewDiagramGenerator()
+		diagramGen.SetVpcEndpoints(run.vpcEndpoints)
+		diagramGen.SetPrivateAPIs(run.privateAPIs)
+		diagramGen.SetNetworkACLs(run.networkACLs)
+		diagramGen.SetHideDefaultEgress(*run.hideDefaultEgress)
+		diagramGen.SetScanContext(run.cfg.Region, run.accountID)
+		diagramGen.SetPlainCells(*run.diagramPlain)
+		diagramGen.SetDimManaged(splitList(*run.dimManaged))
+		diagramGen.SetNotes(run.diagramNotes)
+
+		details, err := diagramGen.GenerateVPCDetailDiagrams(runtime.NumCPU(), run.shownVPCs, run.shownSubnets, run.shownRouteTables, run.shownSecurityGroups, run.internetGateways, run.shownNatGateways)
+		if err != nil {
+			return fmt.Errorf("Failed to generate detail diagrams: %w", err)
+		}
+		if err := writeDetailDiagrams(run.stdout, run.result, *run.detailDiagrams, details, run.vpcs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeReports writes the PDF report, -template, the PlantUML diagram, the graph and the Backstage catalog
+// Returns: Error if an output cannot be generated or written
+func (run *scanRun) writeReports() error {
+	// Generate PDF report if requested
+	if *run.pdfFile != "" {
+		fmt.Fprintln(run.stdout, "\nGenerating PDF report...")
+
+		// Selected after every finding is collected, so the title page counts all of them
+		shownFindings := output.Truncate(run.truncations, run.sectionLimits, output.SectionFindings, run.findings, findingOrder)
+		run.pdfPublicIPs = output.Truncate(run.truncations, run.sectionLimits, output.SectionPublicIPs, run.pdfPublicIPs, func(e analysis.PublicIPEntry) string { return e.PublicIp })
+
+		var changes *diff.Changes
+		if run.earlierReport != nil {
+			changes = diff.Compare(run.earlierReport, diff.Snapshot{
+				VPCs:             run.vpcs,
+				Subnets:          run.subnets,
+				RouteTables:      run.routeTables,
+				SecurityGroups:   run.securityGroups,
+				InternetGateways: run.internetGateways,
+				NatGateways:      run.natGateways,
+				TransitGateways:  run.transitGateways,
+			})
+			fmt.Fprintf(run.stdout, "Changes since %s: %d added, %d removed, %d modified\n", changes.Since,
+				changes.Count(diff.ChangeAdded), changes.Count(diff.ChangeRemoved), changes.Count(diff.ChangeModified))
+		}
+
+		pdfGen := pdf.NewReportGenerator()
+		pdfDoc, err := pdfGen.GenerateReport(&pdf.Report{
+			Region:            run.cfg.Region,
+			GeneratedAt:       time.Now(),
+			VPCs:              run.shownVPCs,
+			Subnets:           run.shownSubnets,
+			RouteTables:       run.shownRouteTables,
+			SecurityGroups:    run.shownSecurityGroups,
+			InternetGateways:  run.internetGateways,
+			NatGateways:       run.shownNatGateways,
+			TransitGateways:   run.transitGateways,
+			TGWAttachments:    run.shownTGWAttachments,
+			Findings:          shownFindings,
+			PrivateZones:      run.privateZones,
+			VpcEndpoints:      run.vpcEndpoints,
+			ThirdPartyVendors: run.vendors,
+			PublicIPs:         run.pdfPublicIPs,
+			EndpointAZs:       run.endpointAZs,
+			EgressProfiles:    run.egressProfileList,
+			EffectiveSources:  run.effectiveSourcesReport,
+			SubnetNetwork:     run.subnetNetwork,
+			ScanNotes:         run.scanNotes,
+			DataWarnings:      run.shownDataWarnings,
+			Changes:           changes,
+			HideDefaultEgress: *run.hideDefaultEgress,
+			Truncations:       run.truncations,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to generate PDF report: %w", err)
+		}
+
+		err = os.WriteFile(*run.pdfFile, pdfDoc, 0644)
+		if err != nil {
+			return fmt.Errorf("Failed to write PDF file: %w", err)
+		}
+
+		run.result.output(*run.pdfFile)
+		fmt.Fprintf(run.stdout, "PDF report saved to: %s\n", *run.pdfFile)
+	}
+
+	// Render the user template if requested; it gets the full data, as the JSON output does
+	if run.userTemplate != nil {
+		data := &templates.Data{
+			Region:           run.cfg.Region,
+			AccountID:        run.accountID,
+			ScannedAt:        run.scannedAt.UTC().Format(time.RFC3339),
+			VPCs:             run.vpcs,
+			Subnets:          run.subnets,
+			RouteTables:      run.routeTables,
+			SecurityGroups:   run.securityGroups,
+			InternetGateways: run.internetGateways,
+			NatGateways:      run.natGateways,
+			TransitGateways:  run.transitGateways,
+			TGWAttachments:   run.tgwAttachments,
+			DataWarnings:     run.dataWarnings,
+			ScanNotes:        run.scanNotes,
+		}
+		if run.egressReport != nil {
+			data.EgressProfiles = run.egressReport.VPCs
+		}
+		data.SubnetNetwork = run.subnetNetwork
+		for _, finding := range run.findings {
+			data.Findings = append(data.Findings, templates.Finding(finding))
+		}
+		var rendered bytes.Buffer
+		if err := run.userTemplate.Execute(&rendered, data); err != nil {
+			return fmt.Errorf("Failed to render template: %w", err)
+		}
+		if err := os.WriteFile(*run.templateOut, rendered.Bytes(), 0644); err != nil {
+			return fmt.Errorf("Failed to write template output: %w", err)
+		}
+		run.result.output(*run.templateOut)
+		fmt.Fprintf(run.stdout, "Template output saved to: %s\n", *run.templateOut)
+	}
+
+	// Generate PlantUML diagram if requested
+	if *run.plantumlFile != "" {
+		fmt.Fprintln(run.stdout, "\nGenerating PlantUML diagram...")
+		plantumlGen := plantuml.NewPlantUMLGenerator(*run.plantumlPlain)
+		plantumlGen.Notes = run.diagramNotes
+
+		plantumlDoc, err := plantumlGen.GenerateVPCDiagram(
+			run.shownVPCs,
+			run.shownSubnets,
+			run.shownRouteTables,
+			run.shownSecurityGroups,
+			run.internetGateways,
+			run.shownNatGateways,
+			run.transitGateways,
+			run.shownTGWAttachments,
+		)
+		if err != nil {
+			return fmt.Errorf("Failed to generate PlantUML diagram: %w", err)
+		}
+
+		err = os.WriteFile(*run.plantumlFile, []byte(plantumlDoc), 0644)
+		if err != nil {
+			return fmt.Errorf("Failed to write PlantUML file: %w", err)
+		}
+
+		run.result.output(*run.plantumlFile)
+		fmt.Fprintf(run.stdout, "PlantUML diagram saved to: %s\n", *run.plantumlFile)
+	}
+
+	// Export the graph if requested
+	if *run.graphOut != "" {
+		fmt.Fprintln(run.stdout, "\nExporting graph...")
+		g := graph.Build(
+			run.vpcs,
+			run.subnets,
+			run.routeTables,
+			run.securityGroups,
+			run.internetGateways,
+			run.natGateways,
+			run.transitGateways,
+			run.tgwAttachments,
+		)
+
+		files, err := graph.Export(g, *run.graphOut, run.graphFormat)
+		if err != nil {
+			return fmt.Errorf("Failed to export graph: %w", err)
+		}
+		for _, file := range files {
+			run.result.output(file)
+		}
+
+		fmt.Fprintf(run.stdout, "Graph with %d nodes and %d edges saved to: %s\n", len(g.Nodes), len(g.Edges), strings.Join(files, ", "))
+	}
+
+	// Export the Backstage catalog entities if requested
+	if *run.backstageOut != "" {
+		fmt.Fprintln(run.stdout, "\nExporting Backstage catalog...")
+		catalog := backstage.Build(run.vpcs, run.subnets, run.routeTables, run.transitGateways, run.tgwAttachments, run.peerings, backstage.Options{
+			Region:       run.cfg.Region,
+			AccountID:    run.accountID,
+			OwnerTags:    splitList(*run.backstageOwnerTags),
+			DefaultOwner: *run.backstageDefaultOwner,
+			SubnetTiers:  *run.backstageSubnetTiers,
+		})
+		files, err := backstage.Export(catalog, *run.backstageOut)
+		if err != nil {
+			return fmt.Errorf("Failed to export Backstage catalog: %w", err)
+		}
+		for _, file := range files {
+			run.result.output(file)
+		}
+		printRenames(run.stdout, catalog.Renames, ".yaml")
+
+		fmt.Fprintf(run.stdout, "%d Backstage entities saved to: %s (register %s)\n", len(catalog.Entities), *run.backstageOut, filepath.Join(*run.backstageOut, backstage.LocationFile))
+	}
+	return nil
+}
+
+// writeSummaries writes the scan manifest and the versioned report, prints the security report, repeats the
+// warnings and clears the checkpoints of a complete run
+// Returns: Error if an output cannot be written
+func (run *scanRun) writeSummaries() error {
+	// Record what was scanned for the coverage subcommand; scanners that failed or were skipped left nil slices
+	if *run.scanManifest != "" {
+		manifest := coverage.NewManifest(run.cfg.Region, time.Now())
+		manifest.Partial = run.budget.Exceeded()
+		for resourceType, count := range map[string]int{
+			"ec2:vpc":                        len(run.vpcs),
+			"ec2:subnet":                     len(run.subnets),
+			"ec2:route-table":                len(run.routeTables),
+			"ec2:security-group":             len(run.securityGroups),
+			"ec2:internet-gateway":           len(run.internetGateways),
+			"ec2:natgateway":                 len(run.natGateways),
+			"ec2:transit-gateway":            len(run.transitGateways),
+			"ec2:transit-gateway-attachment": len(run.tgwAttachments),
+		} {
+			manifest.Record(resourceType, count)
+		}
+		if run.peerings != nil {
+			manifest.Record("ec2:vpc-peering-connection", len(run.peerings))
+		}
+		if run.networkACLs != nil {
+			manifest.Record("ec2:network-acl", len(run.networkACLs))
+		}
+		if run.carrierGateways != nil {
+			manifest.Record("ec2:carrier-gateway", len(run.carrierGateways))
+		}
+		if run.vpnGateways != nil {
+			manifest.Record("ec2:vpn-gateway", len(run.vpnGateways))
+		}
+		if run.customerGateways != nil {
+			manifest.Record("ec2:customer-gateway", len(run.customerGateways))
+		}
+		if run.vpnConnections != nil {
+			manifest.Record("ec2:vpn-connection", len(run.vpnConnections))
+		}
+		if run.dhcpOptions != nil {
+			manifest.Record("ec2:dhcp-options", len(run.dhcpOptions))
+		}
+		if run.networkInterfaces != nil {
+			manifest.Record("ec2:network-interface", len(run.networkInterfaces))
+		}
+		if run.instances != nil {
+			manifest.Record("ec2:instance", len(run.instances))
+		} else if run.routeAppliances != nil {
+			manifest.Record("ec2:instance", len(run.routeAppliances))
+		}
+		if run.flowLogs != nil {
+			manifest.Record("ec2:vpc-flow-log", len(run.flowLogs))
+		}
+		if run.tgwRouteTables != nil {
+			manifest.Record("ec2:transit-gateway-route-table", len(run.tgwRouteTables))
+		}
+		if run.vpcEndpoints != nil {
+			manifest.Record("ec2:vpc-endpoint", len(run.vpcEndpoints))
+		}
+		if run.autoScalingGroups != nil {
+			manifest.Record("autoscaling:autoScalingGroup", len(run.autoScalingGroups))
+		}
+		if run.ecsServices != nil {
+			manifest.Record("ecs:service", len(run.ecsServices))
+		}
+		if run.eksClusters != nil {
+			nodegroups, fargateProfiles := 0, 0
+			for _, cluster := range run.eksClusters {
+				nodegroups += len(cluster.Nodegroups)
+				fargateProfiles += len(cluster.FargateProfiles)
+			}
+			manifest.Record("eks:cluster", len(run.eksClusters))
+			manifest.Record("eks:nodegroup", nodegroups)
+			manifest.Record("eks:fargateprofile", fargateProfiles)
+		}
+		if run.directories != nil {
+			manifest.Record("ds:directory", len(run.directories))
+		}
+		if run.privateAPIs != nil {
+			manifest.Record("apigateway:restapis", len(run.privateAPIs))
+		}
+		if run.dnsReport != nil {
+			manifest.Record("route53:hostedzone", len(run.dnsReport.PrivateZones))
+		}
+		if run.coreNetworks != nil {
+			manifest.Record("networkmanager:core-network", len(run.coreNetworks))
+		}
+		if run.elasticIPs != nil {
+			manifest.Record("ec2:elastic-ip", len(run.elasticIPs))
+		} else if run.publicIPReport != nil {
+			count := 0
+			for _, entry := range run.publicIPReport.PublicIPs {
+				if entry.Kind == vpc.PublicIPStatic && entry.AllocationID != "" {
+					count++
+				}
+			}
+			manifest.Record("ec2:elastic-ip", count)
+		}
+		if err := manifest.Write(*run.scanManifest); err != nil {
+			return err
+		}
+		run.result.output(*run.scanManifest)
+		fmt.Fprintf(run.stdout, "\nScan manifest saved to: %s\n", *run.scanManifest)
+	}
+
+	// Write the versioned report; its keys are fixed by the schema, so -field-style does not apply
+	if run.opts.Report || *run.reportOut != "" {
+		report := &schema.Report{
+			SchemaVersion:       schema.Current,
+			Generator:           "aws-documentor " + version,
+			Region:              run.cfg.Region,
+			AccountID:           run.accountID,
+			ScannedAt:           run.scannedAt.UTC().Format(time.RFC3339),
+			Partial:             run.result.partial || run.budget.Exceeded(),
+			VPCs:                run.vpcs,
+			Subnets:             run.subnets,
+			RouteTables:         run.routeTables,
+			SecurityGroups:      run.securityGroups,
+			InternetGateways:    run.internetGateways,
+			NatGateways:         run.natGateways,
+			TransitGateways:     run.transitGateways,
+			TGWAttachments:      run.tgwAttachments,
+			LegacyNATInstances:  run.legacyNATInstances,
+			Findings:            []schema.Finding{},
+			SGReferenceFindings: analysis.AnalyzeSecurityGroupReferences(run.securityGroups, run.routeTables).Findings,
+			SubnetNetwork:       run.subnetNetwork,
+			DataWarnings:        run.dataWarnings,
+			ScanNotes:           append([]string{}, run.scanNotes...),
+		}
+		for _, finding := range run.findings {
+			report.Findings = append(report.Findings, schema.Finding(finding))
+		}
+		if run.egressReport != nil {
+			report.EgressProfiles = run.egressReport.VPCs
+		}
+		if run.budget.Exceeded() {
+			report.ScanNotes = append(report.ScanNotes, "-max-api-calls was reached; refused: "+strings.Join(run.budget.Refused(), ", "))
+		}
+		reportJSON, _ := output.MarshalIndent(report, output.FieldStyleSnake)
+		if *run.reportOut != "" {
+			if err := os.WriteFile(*run.reportOut, append(reportJSON, '\n'), 0644); err != nil {
+				return fmt.Errorf("Failed to write report file: %w", err)
+			}
+			run.result.output(*run.reportOut)
+			fmt.Fprintf(run.stdout, "\nReport (schema version %s) saved to: %s\n", schema.Current, *run.reportOut)
+		}
+		if run.opts.Report {
+			stdoutReport, _ := output.Marshal(report, output.FieldStyleSnake, run.format)
+			fmt.Printf("%s\n", stdoutReport)
+		}
+	}
+
+	// The security report follows the scan output on stderr, so it stays out of piped documents
+	if *run.securityReport {
+		writeSecurityReport(os.Stderr, analysis.WithoutManaged(analysis.AnalyzeSecurityGroups(run.securityGroups),
+			func(f analysis.SecurityGroupFinding) string { return f.GroupID }, run.managedBy, run.skipManagers), run.elasticIPs)
+	}
+
+	// Repeated at the end so the warnings are not lost in the output above
+	for _, warning := range run.dataWarnings {
+		run.result.warnf("data quality: %s", warning)
+	}
+	if len(run.dataWarnings) > 0 {
+		run.result.warnf("%d data-quality warning(s); the affected fields are shown as %s in the outputs", len(run.dataWarnings), vpc.UnknownValue)
+	}
+
+	fmt.Fprintf(run.stdout, "\nAPI calls: %d (estimated %d) over %d connections\n", run.budget.Calls(), run.estimate, run.dials.Dials())
+	if run.budget.Exceeded() {
+		run.result.warnf("-max-api-calls %d reached; the outputs are partial. Refused calls (scanners skipped, or truncated if they had already started): %s",
+			*run.maxAPICalls, strings.Join(run.budget.Refused(), ", "))
+		return nil
+	}
+
+	// A complete run leaves nothing to resume
+	if err := run.checkpoints.Clear(); err != nil {
+		run.result.warnf("%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/testgen"
+	"aws-documentor/modules/vpc"
+)
+
+// TestSelectShown checks that the VPCs left out take their resources with them, that the same items are shown
+// whatever order the scan returned them in, and that the truncations keep the scanned totals
+func TestSelectShown(t *testing.T) {
+	env := testgen.Generate(testgen.Small)
+	selection := func(reverse bool) *scanRun {
+		run := &scanRun{sectionLimits: output.SectionLimits{Default: 4, Overrides: map[string]int{output.SectionSecurityGroups: 0}},
+			vpcs: append([]vpc.VPCInfo{}, env.VPCs...), subnets: append([]vpc.SubnetInfo{}, env.Subnets...),
+			routeTables: env.RouteTables, securityGroups: env.SecurityGroups, natGateways: env.NatGateways, tgwAttachments: env.TGWAttachments}
+		if reverse {
+			sort.Slice(run.vpcs, func(i, j int) bool { return run.vpcs[i].VpcID > run.vpcs[j].VpcID })
+			sort.Slice(run.subnets, func(i, j int) bool { return run.subnets[i].SubnetID > run.subnets[j].SubnetID })
+		}
+		run.selectShown()
+		return run
+	}
+
+	run := selection(false)
+	shownVPC := make(map[string]bool)
+	for _, v := range run.shownVPCs {
+		shownVPC[v.VpcID] = true
+	}
+	if len(run.shownVPCs) != 4 || len(run.shownSubnets) != 4 {
+		t.Fatalf("shown %d VPCs and %d subnets, want 4 of each", len(run.shownVPCs), len(run.shownSubnets))
+	}
+	for _, subnet := range run.shownSubnets {
+		if !shownVPC[subnet.VpcID] {
+			t.Errorf("subnet %s of %s is shown without its VPC", subnet.SubnetID, subnet.VpcID)
+		}
+	}
+	for _, sg := range run.shownSecurityGroups {
+		if !shownVPC[sg.VpcID] {
+			t.Errorf("security group %s of %s is shown without its VPC", sg.GroupID, sg.VpcID)
+		}
+	}
+
+	reversed := selection(true)
+	if !reflect.DeepEqual(reversed.shownVPCs, run.shownVPCs) || !reflect.DeepEqual(reversed.shownSubnets, run.shownSubnets) {
+		t.Errorf("the scan order changed the selection")
+	}
+
+	for section, total := range map[string]int{
+		output.SectionVPCs:           len(env.VPCs),
+		output.SectionSubnets:        len(env.Subnets),
+		output.SectionSecurityGroups: len(env.SecurityGroups),
+	} {
+		if got := run.truncations.Total(section, 0); got != total {
+			t.Errorf("Total(%s) = %d, want the %d scanned", section, got, total)
+		}
+	}
+	if want := len(env.SecurityGroups) * 4 / len(env.VPCs); len(run.shownSecurityGroups) != want {
+		t.Errorf("shown %d security groups, want the %d of the shown VPCs with no limit of their own", len(run.shownSecurityGroups), want)
+	}
+}
@@ -0,0 +1,960 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"aws-documentor/modules/accelerator"
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/checkpoint"
+	"aws-documentor/modules/cloudwan"
+	"aws-documentor/modules/consistency"
+	"aws-documentor/modules/containers"
+	"aws-documentor/modules/directory"
+	"aws-documentor/modules/dns"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/privateapi"
+	"aws-documentor/modules/replication"
+	"aws-documentor/modules/report"
+	"aws-documentor/modules/vpc"
+)
+
+// scanCore scans and prints the core resources: VPCs, subnets, route tables, security groups, gateways and
+// transit gateway attachments, with the route targets and the -consistency-recheck
+// ctx: Context for the AWS calls
+// Returns: Error of a core call the scan cannot do without
+func (run *scanRun) scanCore(ctx context.Context) error {
+	var err error
+	if *run.checkpointDir != "" {
+		run.checkpoints, err = checkpoint.Open(*run.checkpointDir, checkpoint.Fingerprint(run.accountID, run.cfg.Region, tagFilterKey(run.tagFilters)), *run.resume)
+		if err != nil {
+			return fmt.Errorf("Failed to open -checkpoint-dir: %w", err)
+		}
+		run.scanner.SetCheckpoints(run.checkpoints)
+	}
+
+	// The eight core calls are independent, so ScanAll makes them concurrently; the sections below print their results
+	fmt.Fprintln(run.stdout, "Scanning VPCs, subnets, route tables, security groups, gateways and transit gateway attachments...")
+	core, err := run.scanner.ScanAll(ctx, run.scanOptions)
+	if err := run.result.scanFailure(err); err != nil {
+		return err
+	}
+	run.vpcs = core.VPCs
+	run.prepareTags(run.vpcs)
+	run.result.count("vpcs", len(run.vpcs))
+
+	// The VPC count from the core scan scales the estimate for the per-VPC scanners
+	run.estimate = writeEstimate(run.stdout, scanSteps(scanSelection{
+		EffectiveDNS:    *run.scanDNS,
+		VPCDhcpOptions:  *run.verbose && run.opts.JSON,
+		PrivateDNS:      *run.scanDNS,
+		InspectionPaths: *run.inspectionPaths || *run.isolation,
+		Endpoints:       *run.endpointCoverage || *run.scanDNS || *run.thirdParty || *run.egressProfiles || *run.scanPrivateAPIs || *run.generateDiagram || *run.detailDiagrams != "" || *run.saveFile != "",
+		EndpointAZs:     *run.endpointCoverage,
+		ThirdParty:      *run.thirdParty,
+		ASGs:            *run.scanASGs,
+		Containers:      *run.scanContainers || *run.sgUsage,
+		SGUsage:         *run.sgUsage || *run.effectiveSources,
+		Directories:     *run.scanDirectories,
+		PrivateAPIs:     *run.scanPrivateAPIs,
+		DRReplication:   *run.drReplication,
+		DRRegions:       len(splitList(*run.drRegions)),
+		CloudWAN:        *run.scanCloudWAN,
+		PublicIPs:       *run.publicIPs || *run.publicIPsCSV != "",
+		PublicRecords:   *run.resolveDNS,
+		InstanceNetwork: *run.instanceNetwork,
+		AutoExpand:      *run.autoExpandRegions,
+		AssumeRole:      *run.roleARN != "",
+	}), scanScale{VPCs: len(run.vpcs)}, *run.maxAPICalls)
+
+	// Effective DNS settings belong in the VPC output, so they are added before it is printed
+	if *run.scanDNS {
+		if err := run.scanner.AddEffectiveDNS(ctx, run.vpcs); err != nil {
+			run.result.skipf("effective DNS settings: %v", err)
+		}
+	}
+
+	// -verbose resolves the DHCP options sets before the VPCs are printed; a set need not carry the -tag tags of its VPCs
+	var vpcDhcpOptions map[string]*vpc.DhcpOptionsInfo
+	if *run.verbose && run.opts.JSON {
+		sets, err := run.scanner.GetDhcpOptions(ctx)
+		run.prepareTags(sets)
+		if err != nil {
+			run.result.skipf("DHCP options of the VPCs: %v", err)
+		} else {
+			vpcDhcpOptions = vpc.DhcpOptionsByID(sets)
+		}
+	}
+
+	if run.opts.JSON && vpcDhcpOptions != nil {
+		verboseVPCs := make([]vpc.VPCWithDhcpOptions, 0, len(run.vpcs))
+		for _, v := range run.vpcs {
+			verboseVPCs = append(verboseVPCs, vpc.VPCWithDhcpOptions{VPCInfo: v, DhcpOptions: vpcDhcpOptions[v.DhcpOptionsID]})
+		}
+		printResources(run.stdout, "VPCs", verboseVPCs, run.fieldStyle, run.format)
+	} else if run.opts.JSON {
+		printResources(run.stdout, "VPCs", run.vpcs, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d VPCs\n", len(run.vpcs))
+	}
+
+	fmt.Fprintln(run.stdout)
+	run.subnets = core.Subnets
+	run.prepareTags(run.subnets)
+	run.result.count("subnets", len(run.subnets))
+
+	if run.opts.JSON {
+		printResources(run.stdout, "Subnets", run.subnets, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Subnets\n", len(run.subnets))
+	}
+
+	fmt.Fprintln(run.stdout)
+	run.routeTables = core.RouteTables
+	run.prepareTags(run.routeTables)
+	run.result.count("route_tables", len(run.routeTables))
+	vpc.ClassifyLocalRoutes(run.vpcs, run.routeTables)
+	run.rangeLabeler.LabelRoutes(run.routeTables)
+
+	if run.opts.JSON {
+		printResources(run.stdout, "Route Tables", run.routeTables, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Route Tables\n", len(run.routeTables))
+	}
+
+	// Stale local routes are rare, so the section only appears when there is one
+	run.localRouteFindings = analysis.AnalyzeLocalRoutes(run.routeTables)
+	if len(run.localRouteFindings) > 0 && run.opts.JSON {
+		fmt.Fprintln(run.stdout, "\nLocal route findings:")
+		findingsJSON, _ := output.Marshal(run.localRouteFindings, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", findingsJSON)
+	} else if len(run.localRouteFindings) > 0 {
+		fmt.Fprintf(run.stdout, "Found %d local route findings\n", len(run.localRouteFindings))
+	}
+
+	// Private ranges routed to an internet gateway and default routes to peering connections, likewise only when found
+	run.routeTargetFindings = analysis.AnalyzeRouteTargets(run.routeTables, run.subnets, append(append([]string{}, analysis.DefaultPrivateRanges...), splitList(*run.privateRanges)...))
+	if len(run.routeTargetFindings) > 0 && run.opts.JSON {
+		fmt.Fprintln(run.stdout, "\nRoute target findings:")
+		findingsJSON, _ := output.Marshal(run.routeTargetFindings, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", findingsJSON)
+	} else if len(run.routeTargetFindings) > 0 {
+		fmt.Fprintf(run.stdout, "Found %d route target findings\n", len(run.routeTargetFindings))
+	}
+
+	fmt.Fprintln(run.stdout)
+	run.securityGroups = core.SecurityGroups
+	run.prepareTags(run.securityGroups)
+	run.result.count("security_groups", len(run.securityGroups))
+	run.rangeLabeler.LabelRules(run.securityGroups)
+
+	if run.opts.JSON {
+		printResources(run.stdout, "Security Groups", run.securityGroups, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Security Groups\n", len(run.securityGroups))
+	}
+
+	fmt.Fprintln(run.stdout)
+	run.internetGateways = core.InternetGateways
+	run.prepareTags(run.internetGateways)
+	run.result.count("internet_gateways", len(run.internetGateways))
+
+	if run.opts.JSON {
+		printResources(run.stdout, "Internet Gateways", run.internetGateways, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Internet Gateways\n", len(run.internetGateways))
+	}
+
+	fmt.Fprintln(run.stdout)
+	run.natGateways = core.NatGateways
+	run.prepareTags(run.natGateways)
+	run.result.count("nat_gateways", len(run.natGateways))
+
+	if run.opts.JSON {
+		printResources(run.stdout, "NAT Gateways", run.natGateways, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d NAT Gateways\n", len(run.natGateways))
+	}
+
+	// Look up the instances and interfaces that routes target; optional because it needs instance permissions
+	fmt.Fprintln(run.stdout, "\nScanning Route Target Instances...")
+	run.routeAppliances, err = run.scanner.GetRouteAppliances(ctx, run.routeTables)
+	run.prepareTags(run.routeAppliances)
+	if err != nil {
+		run.result.skipf("route target instances: %v", err)
+	} else if run.opts.JSON {
+		printResources(run.stdout, "Route Target Instances", run.routeAppliances, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Route Target Instances\n", len(run.routeAppliances))
+	}
+
+	run.legacyNATInstances = []vpc.RouteApplianceInfo{}
+	if len(run.routeAppliances) > 0 {
+		fmt.Fprintln(run.stdout, "\nLegacy NAT instances:")
+		applianceReport := analysis.AnalyzeRouteAppliances(run.routeAppliances)
+		run.legacyNATInstances = applianceReport.LegacyNATInstances
+		applianceJSON, _ := output.Marshal(applianceReport, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", applianceJSON)
+	}
+
+	fmt.Fprintln(run.stdout)
+	run.transitGateways = core.TransitGateways
+	run.prepareTags(run.transitGateways)
+	run.result.count("transit_gateways", len(run.transitGateways))
+
+	if run.opts.JSON {
+		printResources(run.stdout, "Transit Gateways", run.transitGateways, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Transit Gateways\n", len(run.transitGateways))
+	}
+
+	fmt.Fprintln(run.stdout)
+	run.tgwAttachments = core.TGWAttachments
+	run.prepareTags(run.tgwAttachments)
+	run.result.count("tgw_attachments", len(run.tgwAttachments))
+
+	if run.opts.JSON {
+		printResources(run.stdout, "Transit Gateway Attachments", run.tgwAttachments, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Transit Gateway Attachments\n", len(run.tgwAttachments))
+	}
+
+	// A scan right after a change can see a resource before the resources it references; look again
+	if *run.consistencyRecheck {
+		fmt.Fprintln(run.stdout, "\nRe-checking references to resources the scan did not return...")
+		core := &consistency.Report{
+			VPCs:             run.vpcs,
+			Subnets:          run.subnets,
+			RouteTables:      run.routeTables,
+			SecurityGroups:   run.securityGroups,
+			InternetGateways: run.internetGateways,
+			NatGateways:      run.natGateways,
+			TransitGateways:  run.transitGateways,
+			TGWAttachments:   run.tgwAttachments,
+		}
+		recheck, err := consistency.Recheck(ctx, run.scanner, core, *run.consistencyWait, run.scanOptions)
+		// Collections fetched before a failure are merged already
+		run.vpcs, run.subnets, run.routeTables, run.securityGroups = core.VPCs, core.Subnets, core.RouteTables, core.SecurityGroups
+		run.internetGateways, run.natGateways, run.transitGateways = core.InternetGateways, core.NatGateways, core.TransitGateways
+		for _, resources := range []interface{}{run.vpcs, run.subnets, run.routeTables, run.securityGroups, run.internetGateways, run.natGateways, run.transitGateways} {
+			run.prepareTags(resources)
+		}
+		run.rangeLabeler.LabelRoutes(run.routeTables)
+		run.rangeLabeler.LabelRules(run.securityGroups)
+		for resourceType, n := range map[string]int{
+			"vpcs": len(run.vpcs), "subnets": len(run.subnets), "route_tables": len(run.routeTables), "security_groups": len(run.securityGroups),
+			"internet_gateways": len(run.internetGateways), "nat_gateways": len(run.natGateways), "transit_gateways": len(run.transitGateways),
+		} {
+			run.result.count(resourceType, n)
+		}
+		if err != nil {
+			if err := run.result.scanFailure(err); err != nil {
+				return err
+			}
+		} else if run.opts.JSON {
+			recheckJSON, _ := output.Marshal(recheck, run.fieldStyle, run.format)
+			fmt.Fprintf(run.stdout, "%s\n", recheckJSON)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d inconsistent references: %d resolved, %d persistent\n", recheck.Found, len(recheck.Resolved), len(recheck.Persistent))
+		}
+		if recheck != nil {
+			for _, inconsistency := range recheck.Persistent {
+				run.consistencyWarnings = append(run.consistencyWarnings, inconsistency.Warning())
+			}
+		}
+	}
+	return nil
+}
+
+// scanNetworkResources scans the optional EC2 resources: peerings, network ACLs, gateways, VPNs, DHCP options,
+// network interfaces, instances, flow logs, Elastic IPs, transit gateway routes and VPC endpoints
+// ctx: Context for the AWS calls
+// Returns: Error of a call the requested outputs cannot do without
+func (run *scanRun) scanNetworkResources(ctx context.Context) error {
+	var err error
+	// Peering connections are optional; they show which peers lie outside the scanned regions
+	fmt.Fprintln(run.stdout, "\nScanning VPC Peering Connections...")
+	run.peerings, err = run.scanner.GetVpcPeeringConnections(ctx, run.scanOptions)
+	run.prepareTags(run.peerings)
+	if err != nil {
+		run.result.skipf("VPC peering connections: %v", err)
+	} else if run.opts.JSON {
+		printResources(run.stdout, "VPC Peering Connections", run.peerings, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d VPC Peering Connections\n", len(run.peerings))
+	}
+
+	// Network ACLs are optional too; the detail diagrams draw them beside the security groups
+	fmt.Fprintln(run.stdout, "\nScanning Network ACLs...")
+	run.networkACLs, err = run.scanner.GetNetworkACLs(ctx, run.scanOptions)
+	run.prepareTags(run.networkACLs)
+	if err != nil {
+		run.result.skipf("network ACLs: %v", err)
+	} else {
+		run.result.count("network_acls", len(run.networkACLs))
+		if run.opts.JSON {
+			printResources(run.stdout, "Network ACLs", run.networkACLs, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Network ACLs\n", len(run.networkACLs))
+		}
+	}
+
+	// Carrier gateways connect Wavelength zone subnets to the carrier network; routes to cagw-* targets lead to them
+	fmt.Fprintln(run.stdout, "\nScanning Carrier Gateways...")
+	run.carrierGateways, err = run.scanner.GetCarrierGateways(ctx, run.scanOptions)
+	run.prepareTags(run.carrierGateways)
+	if err != nil {
+		run.result.skipf("carrier gateways: %v", err)
+	} else {
+		run.result.count("carrier_gateways", len(run.carrierGateways))
+		if run.opts.JSON {
+			printResources(run.stdout, "Carrier Gateways", run.carrierGateways, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Carrier Gateways\n", len(run.carrierGateways))
+		}
+	}
+
+	// Virtual private gateways are optional as well; routes to vgw-* targets and route propagation lead to them
+	fmt.Fprintln(run.stdout, "\nScanning Virtual Private Gateways...")
+	run.vpnGateways, err = run.scanner.GetVpnGateways(ctx, run.scanOptions)
+	run.prepareTags(run.vpnGateways)
+	if err != nil {
+		run.result.skipf("virtual private gateways: %v", err)
+	} else {
+		run.result.count("vpn_gateways", len(run.vpnGateways))
+		if run.opts.JSON {
+			printResources(run.stdout, "Virtual Private Gateways", run.vpnGateways, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Virtual Private Gateways\n", len(run.vpnGateways))
+		}
+	}
+
+	// Customer gateways are the on-premises side of the site-to-site VPNs; optional like the virtual private gateways
+	fmt.Fprintln(run.stdout, "\nScanning Customer Gateways...")
+	run.customerGateways, err = run.scanner.GetCustomerGateways(ctx, run.scanOptions)
+	run.prepareTags(run.customerGateways)
+	if err != nil {
+		run.result.skipf("customer gateways: %v", err)
+	} else {
+		run.result.count("customer_gateways", len(run.customerGateways))
+		if run.opts.JSON {
+			printResources(run.stdout, "Customer Gateways", run.customerGateways, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Customer Gateways\n", len(run.customerGateways))
+		}
+	}
+
+	// VPN connections join the customer gateways to the virtual private and transit gateways; the tunnel
+	// telemetry shows which tunnels are up
+	fmt.Fprintln(run.stdout, "\nScanning VPN Connections...")
+	run.vpnConnections, err = run.scanner.GetVpnConnections(ctx, run.scanOptions)
+	run.prepareTags(run.vpnConnections)
+	if err != nil {
+		run.result.skipf("VPN connections: %v", err)
+	} else {
+		run.result.count("vpn_connections", len(run.vpnConnections))
+		if run.opts.JSON {
+			printResources(run.stdout, "VPN Connections", run.vpnConnections, run.fieldStyle, run.format)
+		} else {
+			up, tunnels := 0, 0
+			for _, conn := range run.vpnConnections {
+				up += conn.TunnelsUp()
+				tunnels += len(conn.Tunnels)
+			}
+			fmt.Fprintf(run.stdout, "Found %d VPN Connections (%d of %d tunnels up)\n", len(run.vpnConnections), up, tunnels)
+		}
+	}
+
+	// DHCP options sets show what the dhcp_options_id of the VPCs hands out; optional like the VPN resources
+	fmt.Fprintln(run.stdout, "\nScanning DHCP Options Sets...")
+	run.dhcpOptions, err = run.scanner.GetDhcpOptions(ctx, run.scanOptions)
+	run.prepareTags(run.dhcpOptions)
+	if err != nil {
+		run.result.skipf("DHCP options sets: %v", err)
+	} else {
+		run.result.count("dhcp_options", len(run.dhcpOptions))
+		if run.opts.JSON {
+			printResources(run.stdout, "DHCP Options Sets", run.dhcpOptions, run.fieldStyle, run.format)
+		} else {
+			byID := vpc.DhcpOptionsByID(run.dhcpOptions)
+			used := make(map[string]bool)
+			for _, v := range run.vpcs {
+				if byID[v.DhcpOptionsID] != nil {
+					used[v.DhcpOptionsID] = true
+				}
+			}
+			fmt.Fprintf(run.stdout, "Found %d DHCP Options Sets (%d used by the VPCs)\n", len(run.dhcpOptions), len(used))
+		}
+	}
+
+	// Network interfaces show what sits in each subnet, including the interfaces AWS services create there
+	fmt.Fprintln(run.stdout, "\nScanning Network Interfaces...")
+	run.networkInterfaces, err = run.scanner.GetNetworkInterfaces(ctx, run.scanOptions)
+	run.prepareTags(run.networkInterfaces)
+	if err != nil {
+		run.result.skipf("network interfaces: %v", err)
+	} else {
+		run.result.count("network_interfaces", len(run.networkInterfaces))
+		if run.opts.JSON {
+			printResources(run.stdout, "Network Interfaces", run.networkInterfaces, run.fieldStyle, run.format)
+		} else {
+			unattached := 0
+			for _, eni := range run.networkInterfaces {
+				if eni.Unattached() {
+					unattached++
+				}
+			}
+			fmt.Fprintf(run.stdout, "Found %d Network Interfaces (%d unattached)\n", len(run.networkInterfaces), unattached)
+		}
+	}
+
+	// Instances show what runs in each subnet; -instance-network cannot do without them, so there a failure stops the scan
+	fmt.Fprintln(run.stdout, "\nScanning Instances...")
+	run.instances, err = run.scanner.GetInstances(ctx)
+	run.prepareTags(run.instances)
+	if err != nil && *run.instanceNetwork {
+		if err := run.result.scanFailure(err); err != nil {
+			return err
+		}
+	} else if err != nil {
+		run.result.skipf("instances: %v", err)
+	} else if run.opts.JSON {
+		printResources(run.stdout, "Instances", run.instances, run.fieldStyle, run.format)
+	} else {
+		fmt.Fprintf(run.stdout, "Found %d Instances\n", len(run.instances))
+	}
+
+	// Flow logs document which VPCs record their traffic; like the instances they ignore -tag, as a VPC
+	// whose flow log lacks the tags would otherwise count as one without a flow log
+	fmt.Fprintln(run.stdout, "\nScanning Flow Logs...")
+	run.flowLogs, err = run.scanner.GetFlowLogs(ctx)
+	run.prepareTags(run.flowLogs)
+	if err != nil {
+		run.result.skipf("flow logs: %v", err)
+	} else {
+		run.result.count("flow_logs", len(run.flowLogs))
+		if run.opts.JSON {
+			printResources(run.stdout, "Flow Logs", run.flowLogs, run.fieldStyle, run.format)
+		} else {
+			byResource := vpc.FlowLogsByResource(run.flowLogs)
+			var unlogged []string
+			for _, v := range run.vpcs {
+				if len(byResource[v.VpcID]) == 0 {
+					unlogged = append(unlogged, v.VpcID)
+				}
+			}
+			fmt.Fprintf(run.stdout, "Found %d Flow Logs (%d of %d VPCs without one)\n", len(run.flowLogs), len(unlogged), len(run.vpcs))
+			if len(unlogged) > 0 {
+				fmt.Fprintf(run.stdout, "VPCs without a flow log: %s\n", strings.Join(unlogged, ", "))
+			}
+		}
+	}
+
+	// Elastic IPs are billed by the hour while associated with nothing, and an address nobody knows about is
+	// an entry point nobody watches; the NAT gateways of the diagram are annotated with theirs
+	fmt.Fprintln(run.stdout, "\nScanning Elastic IPs...")
+	run.elasticIPs, err = run.scanner.GetElasticIPs(ctx, run.scanOptions)
+	run.prepareTags(run.elasticIPs)
+	if err != nil {
+		run.result.skipf("Elastic IPs: %v", err)
+	} else {
+		run.result.count("elastic_ips", len(run.elasticIPs))
+		if run.opts.JSON {
+			printResources(run.stdout, "Elastic IPs", run.elasticIPs, run.fieldStyle, run.format)
+		} else {
+			unattached := 0
+			for _, eip := range run.elasticIPs {
+				if eip.IsUnattached {
+					unattached++
+				}
+			}
+			fmt.Fprintf(run.stdout, "Found %d Elastic IPs (%d unattached)\n", len(run.elasticIPs), unattached)
+		}
+	}
+
+	// Transit gateway routes are only needed to trace inspection paths and hub egress
+	if *run.inspectionPaths || *run.isolation {
+		fmt.Fprintln(run.stdout, "\nScanning Transit Gateway Route Tables...")
+		run.tgwRouteTables, err = run.scanner.GetTransitGatewayRouteTables(ctx, run.scanOptions)
+		run.prepareTags(run.tgwRouteTables)
+		if err := run.result.scanFailure(err); err != nil {
+			return err
+		}
+		run.result.count("tgw_route_tables", len(run.tgwRouteTables))
+
+		if run.opts.JSON {
+			printResources(run.stdout, "Transit Gateway Route Tables", run.tgwRouteTables, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Transit Gateway Route Tables\n", len(run.tgwRouteTables))
+		}
+		for _, rt := range run.tgwRouteTables {
+			if rt.AdditionalRoutesAvailable {
+				run.result.warnf("transit gateway route table %s has more routes than were returned; inspection paths may be incomplete", rt.RouteTableID)
+			}
+		}
+	}
+
+	// Scan VPC endpoints for the coverage report, egress profiles and diagrams, and for the diagrams of -load; optional like the route target scan
+	if *run.endpointCoverage || *run.scanDNS || *run.thirdParty || *run.egressProfiles || *run.scanPrivateAPIs || *run.generateDiagram || *run.detailDiagrams != "" || *run.saveFile != "" {
+		fmt.Fprintln(run.stdout, "\nScanning VPC Endpoints...")
+		run.vpcEndpoints, err = run.scanner.GetVpcEndpoints(ctx, run.scanOptions)
+		run.prepareTags(run.vpcEndpoints)
+		if err != nil {
+			run.result.skipf("VPC endpoints: %v", err)
+		} else if run.opts.JSON {
+			printResources(run.stdout, "VPC Endpoints", run.vpcEndpoints, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d VPC Endpoints\n", len(run.vpcEndpoints))
+		}
+	}
+
+	// The provider side of the PrivateLink connections, which -profiles and run correlate with the consumers' endpoints
+	if *run.saveFile != "" {
+		fmt.Fprintln(run.stdout, "\nScanning VPC Endpoint Connections...")
+		if run.endpointConnections, err = run.scanner.GetVpcEndpointConnections(ctx); err != nil {
+			run.result.skipf("VPC endpoint connections: %v", err)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d VPC Endpoint Connections\n", len(run.endpointConnections))
+		}
+	}
+
+	// Scan the endpoint network interfaces for the AZ coverage; without them the endpoint subnets are used
+	if *run.endpointCoverage && len(run.vpcEndpoints) > 0 {
+		run.endpointInterfaces, err = run.scanner.GetEndpointInterfaces(ctx, run.vpcEndpoints)
+		if err != nil {
+			run.result.skipf("endpoint network interfaces, AZ coverage is derived from endpoint subnets: %v", err)
+		}
+	}
+	return nil
+}
+
+// skipOptional records an optional scanner that failed
+// Services without an endpoint in the region, or in a region the account has not enabled, are
+// recorded for the scan gaps report instead of warned about; other failures make the scan partial.
+// what: What was not scanned, for the log
+// region: Region of the scanner
+// err: Error of the scanner call
+func (run *scanRun) skipOptional(what, region string, err error) {
+	var scanErr *vpc.ScanError
+	if !vpc.IsUnavailable(err) || !errors.As(err, &scanErr) {
+		run.result.skipf("%s: %v", what, err)
+		return
+	}
+	reason := "is not available in this region"
+	if errors.Is(err, vpc.ErrRegionDisabled) {
+		reason = "needs the region enabled for the account"
+	}
+	run.unavailableServices = append(run.unavailableServices, analysis.UnavailableService{
+		Service: scanErr.Service, ResourceType: scanErr.ResourceType, Region: region, Reason: reason,
+	})
+	log.Printf("Note: skipping %s: %s %s", what, scanErr.Service, reason)
+}
+
+// scanServices runs the scanners of other services the flags ask for (Auto Scaling, containers, directories,
+// private APIs, DNS, replication, Cloud WAN, public IPs), each skipped with a warning when it fails
+// ctx: Context for the AWS calls
+// Returns: Error of a call the requested outputs cannot do without
+func (run *scanRun) scanServices(ctx context.Context) error {
+	var err error
+
+	// Scan Auto Scaling groups if requested; optional like the directory scan
+	if *run.scanASGs {
+		fmt.Fprintln(run.stdout, "\nScanning Auto Scaling Groups...")
+		run.autoScalingGroups, err = asg.NewScanner(run.cfg).GetAutoScalingGroups(ctx)
+		run.prepareTags(run.autoScalingGroups)
+		if err != nil {
+			run.skipOptional("Auto Scaling groups", run.cfg.Region, err)
+		} else if run.opts.JSON {
+			printResources(run.stdout, "Auto Scaling Groups", run.autoScalingGroups, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Auto Scaling Groups\n", len(run.autoScalingGroups))
+		}
+
+		if len(run.autoScalingGroups) > 0 {
+			fmt.Fprintln(run.stdout, "\nAuto Scaling group spread:")
+			asgFindings := analysis.AnalyzeAutoScalingGroups(run.autoScalingGroups, run.subnets)
+			findingsJSON, _ := output.Marshal(asgFindings, run.fieldStyle, run.format)
+			fmt.Fprintf(run.stdout, "%s\n", findingsJSON)
+		}
+	}
+
+	// Scan ECS services and EKS clusters if requested; their security groups count as used without task interfaces
+	if *run.scanContainers || *run.sgUsage {
+		containerScanner := containers.NewScanner(run.cfg)
+		fmt.Fprintln(run.stdout, "\nScanning ECS Services...")
+		run.ecsServices, err = containerScanner.GetECSServices(ctx)
+		if err != nil {
+			run.skipOptional("ECS services", run.cfg.Region, err)
+		} else if run.opts.JSON {
+			printResources(run.stdout, "ECS Services", run.ecsServices, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d ECS Services\n", len(run.ecsServices))
+		}
+
+		fmt.Fprintln(run.stdout, "\nScanning EKS Clusters...")
+		run.eksClusters, err = containerScanner.GetEKSClusters(ctx)
+		if err != nil {
+			run.skipOptional("EKS clusters", run.cfg.Region, err)
+		} else if run.opts.JSON {
+			printResources(run.stdout, "EKS Clusters", run.eksClusters, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d EKS Clusters\n", len(run.eksClusters))
+		}
+	}
+
+	// Scan directories and WorkSpaces if requested; these are optional, so failures only warn
+	if *run.scanDirectories {
+		directoryScanner := directory.NewScanner(run.cfg)
+		directoryScanner.SetAccountID(run.accountID)
+
+		fmt.Fprintln(run.stdout, "\nScanning Directory Service Directories...")
+		run.directories, err = directoryScanner.GetDirectories(ctx)
+		if err != nil {
+			run.skipOptional("directories", run.cfg.Region, err)
+		} else if run.opts.JSON {
+			printResources(run.stdout, "Directories", run.directories, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Directories\n", len(run.directories))
+		}
+
+		fmt.Fprintln(run.stdout, "\nScanning WorkSpaces...")
+		placements, err := directoryScanner.GetWorkspacePlacements(ctx)
+		if err != nil {
+			run.skipOptional("WorkSpaces", run.cfg.Region, err)
+		} else if run.opts.JSON {
+			printResources(run.stdout, "WorkSpaces placements", placements, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d WorkSpaces placements\n", len(placements))
+		}
+
+		if len(run.directories) > 0 {
+			fmt.Fprintln(run.stdout, "\nDirectory security groups:")
+			directoryFindings := analysis.AnalyzeDirectorySecurityGroups(run.directories, run.securityGroups, run.vpcs)
+			findingsJSON, _ := output.Marshal(directoryFindings, run.fieldStyle, run.format)
+			fmt.Fprintf(run.stdout, "%s\n", findingsJSON)
+		}
+	}
+
+	// Scan private APIs if requested; optional like the directory scan
+	if *run.scanPrivateAPIs {
+		fmt.Fprintln(run.stdout, "\nScanning API Gateway Private APIs...")
+		run.privateAPIs, err = privateapi.NewScanner(run.cfg).GetPrivateAPIs(ctx)
+		if err != nil {
+			run.skipOptional("private APIs", run.cfg.Region, err)
+		} else if run.opts.JSON {
+			printResources(run.stdout, "Private APIs", run.privateAPIs, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Private APIs\n", len(run.privateAPIs))
+		}
+	}
+
+	// Scan private DNS if requested; optional like the directory scan
+	if *run.scanDNS {
+		dnsScanner := dns.NewScanner(run.cfg)
+
+		var vpcIDs []string
+		for _, v := range run.vpcs {
+			vpcIDs = append(vpcIDs, v.VpcID)
+		}
+
+		fmt.Fprintln(run.stdout, "\nScanning Route 53 Private Hosted Zones...")
+		privateZones, err := dnsScanner.GetPrivateHostedZones(ctx, run.cfg.Region, vpcIDs, *run.includeDNSRecords)
+		if err != nil {
+			run.skipOptional("private hosted zones", run.cfg.Region, err)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Private Hosted Zones\n", len(privateZones))
+		}
+
+		fmt.Fprintln(run.stdout, "\nScanning Route 53 Resolver Endpoints...")
+		resolverEndpoints, err := dnsScanner.GetResolverEndpoints(ctx)
+		if err != nil {
+			run.skipOptional("Resolver endpoints", run.cfg.Region, err)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Resolver Endpoints\n", len(resolverEndpoints))
+		}
+
+		run.dnsFindings = analysis.AnalyzeCustomDNSServers(run.vpcs, run.subnets)
+		if privateZones != nil {
+			run.dnsReport = dns.BuildReport(privateZones, resolverEndpoints)
+			fmt.Fprintln(run.stdout, "\nDNS:")
+			reportJSON, _ := output.Marshal(run.dnsReport, run.fieldStyle, run.format)
+			fmt.Fprintf(run.stdout, "%s\n", reportJSON)
+			run.dnsFindings = append(run.dnsFindings, analysis.AnalyzePrivateDNS(run.vpcs, run.dnsReport)...)
+		}
+
+		fmt.Fprintln(run.stdout, "\nPrivate DNS findings:")
+		findingsJSON, _ := output.Marshal(run.dnsFindings, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", findingsJSON)
+	}
+
+	// Scan cross-region replication if requested; optional like the directory scan
+	if *run.drReplication {
+		fmt.Fprintln(run.stdout, "\nScanning DR Replication...")
+		drPeerings := append([]vpc.VpcPeeringConnectionInfo{}, run.peerings...)
+		attachments := run.tgwAttachments
+		var scanned [][]replication.Relationship
+
+		// The scanned region first, then the DR regions with the same credentials and HTTP client
+		regions := append([]string{run.cfg.Region}, splitList(*run.drRegions)...)
+		for _, drRegion := range regions {
+			regionCfg := run.cfg.Copy()
+			regionCfg.Region = drRegion
+			replicationScanner := replication.NewScanner(regionCfg)
+
+			replicas, err := replicationScanner.GetRDSReplicas(ctx)
+			if err != nil {
+				run.skipOptional("RDS read replicas in "+drRegion, drRegion, err)
+			} else {
+				scanned = append(scanned, replicas)
+			}
+			replications, err := replicationScanner.GetEFSReplications(ctx)
+			if err != nil {
+				run.skipOptional("EFS replication in "+drRegion, drRegion, err)
+			} else {
+				scanned = append(scanned, replications)
+			}
+
+			if drRegion == run.cfg.Region {
+				continue
+			}
+			regionScanner := vpc.NewScanner(regionCfg)
+			regionScanner.SetAccountID(run.accountID)
+			if regionPeerings, err := regionScanner.GetVpcPeeringConnections(ctx); err != nil {
+				run.result.skipf("VPC peering connections in %s: %v", drRegion, err)
+			} else {
+				drPeerings = append(drPeerings, regionPeerings...)
+			}
+			if regionAttachments, err := regionScanner.GetTransitGatewayAttachments(ctx); err != nil {
+				run.result.skipf("transit gateway attachments in %s: %v", drRegion, err)
+			} else {
+				attachments = append(attachments, regionAttachments...)
+			}
+		}
+
+		run.relationships = replication.Merge(scanned...)
+		replication.ClassifyPaths(run.relationships, drPeerings, attachments)
+		fmt.Fprintf(run.stdout, "Found %d Replication Relationships\n", len(run.relationships))
+
+		fmt.Fprintln(run.stdout, "\nDR replication:")
+		relationshipsJSON, _ := output.Marshal(run.relationships, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", relationshipsJSON)
+	}
+
+	// Scan Cloud WAN core networks if requested (global resources, independent of the region)
+	if *run.scanCloudWAN {
+		cloudwanScanner := cloudwan.NewScanner(run.cfg)
+
+		fmt.Fprintln(run.stdout, "\nScanning Cloud WAN Global Networks...")
+		globalNetworks, err := cloudwanScanner.GetGlobalNetworks(ctx)
+		run.prepareTags(globalNetworks)
+		if err := run.result.scanFailure(err); err != nil {
+			return err
+		}
+		run.result.count("global_networks", len(globalNetworks))
+
+		if run.opts.JSON {
+			printResources(run.stdout, "Global Networks", globalNetworks, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Global Networks\n", len(globalNetworks))
+		}
+
+		fmt.Fprintln(run.stdout, "\nScanning Cloud WAN Core Networks...")
+		run.coreNetworks, err = cloudwanScanner.GetCoreNetworks(ctx)
+		run.prepareTags(run.coreNetworks)
+		if err := run.result.scanFailure(err); err != nil {
+			return err
+		}
+		run.result.count("core_networks", len(run.coreNetworks))
+
+		if run.opts.JSON {
+			printResources(run.stdout, "Core Networks", run.coreNetworks, run.fieldStyle, run.format)
+		} else {
+			fmt.Fprintf(run.stdout, "Found %d Core Networks\n", len(run.coreNetworks))
+		}
+
+		fmt.Fprintln(run.stdout, "\nCore network routes:")
+		coreRoutes := cloudwan.AnalyzeRoutes(run.routeTables, run.vpcs, run.coreNetworks)
+		coreRoutesJSON, _ := output.Marshal(coreRoutes, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", coreRoutesJSON)
+	}
+
+	// Scan public IPs if requested; Global Accelerator is global and optional like the directory scan
+	if *run.publicIPs || *run.publicIPsCSV != "" {
+		fmt.Fprintln(run.stdout, "\nScanning Public IPs...")
+		regionIPs, err := run.scanner.GetPublicIPs(ctx)
+		run.prepareTags(regionIPs)
+		if err := run.result.scanFailure(err); err != nil {
+			return err
+		}
+		accelerators, err := accelerator.NewScanner(run.cfg).GetAccelerators(ctx)
+		if err != nil {
+			run.skipOptional("Global Accelerator static IPs", run.cfg.Region, err)
+		}
+		run.publicIPReport = analysis.AnalyzePublicIPs(time.Now(), regionIPs, accelerators, run.securityGroups)
+		fmt.Fprintf(run.stdout, "Found %d Public IPs\n", len(run.publicIPReport.PublicIPs))
+
+		// Names are best effort: failed lookups and denied Route 53 calls only leave names out
+		if *run.resolveDNS {
+			fmt.Fprintln(run.stdout, "Resolving public IP names...")
+			lookups := dns.NewLookups(dns.NewResolver(*run.dnsServer), dns.LookupOptions{Server: *run.dnsServer, Timeout: *run.dnsTimeout, Concurrency: *run.dnsConcurrency})
+			var addrs []string
+			for _, entry := range run.publicIPReport.PublicIPs {
+				addrs = append(addrs, entry.PublicIp)
+			}
+			reverse := lookups.Reverse(ctx, addrs)
+			records, err := dns.NewScanner(run.cfg).GetPublicRecords(ctx)
+			if err != nil {
+				run.result.warnf("public IPs are not matched to Route 53 public records: %v", err)
+			}
+			aliases := lookups.Forward(ctx, dns.AliasTargets(records))
+			run.publicIPReport.AddDNSNames(reverse, dns.MatchRecords(records, aliases))
+
+			failed := 0
+			for _, results := range []map[string]dns.LookupResult{reverse, aliases} {
+				for _, lookup := range results {
+					if lookup.Status == dns.LookupTimeout || lookup.Status == dns.LookupFailed {
+						failed++
+					}
+				}
+			}
+			if failed > 0 {
+				run.result.warnf("%d DNS lookups timed out or failed; their names are left out", failed)
+			}
+		}
+	}
+
+	// Roll up the network limits of the instances per subnet if requested
+	if *run.instanceNetwork {
+		fmt.Fprintln(run.stdout, "\nScanning Instance Types...")
+		var typeNames []string
+		for _, instance := range run.instances {
+			typeNames = append(typeNames, instance.InstanceType)
+		}
+		instanceTypes, err := run.scanner.GetInstanceTypes(ctx, typeNames)
+		if err != nil {
+			run.result.skipf("instance types, the instance network rollup has no demand: %v", err)
+		}
+		run.subnetNetworkReport = analysis.AnalyzeSubnetNetworkCapacity(run.subnets, run.instances, instanceTypes)
+		run.subnetNetwork = run.subnetNetworkReport.Subnets
+	}
+
+	fmt.Fprintln(run.stdout, "\nVPC infrastructure scan complete!")
+	return nil
+}
+
+// summarizeScan counts the scanned resources, collects the scan notes and data-quality warnings and writes
+// -save and -terraform-out
+// Returns: Error if the -save or -terraform-out file cannot be written
+func (run *scanRun) summarizeScan() error {
+	// Optional scanners that failed or were not requested left nil slices and are not counted
+	if run.peerings != nil {
+		run.result.count("vpc_peering_connections", len(run.peerings))
+	}
+	if run.routeAppliances != nil {
+		run.result.count("route_appliances", len(run.routeAppliances))
+	}
+	if run.vpcEndpoints != nil {
+		run.result.count("vpc_endpoints", len(run.vpcEndpoints))
+	}
+	if run.autoScalingGroups != nil {
+		run.result.count("auto_scaling_groups", len(run.autoScalingGroups))
+	}
+	if run.ecsServices != nil {
+		run.result.count("ecs_services", len(run.ecsServices))
+	}
+	if run.eksClusters != nil {
+		run.result.count("eks_clusters", len(run.eksClusters))
+	}
+	if run.directories != nil {
+		run.result.count("directories", len(run.directories))
+	}
+	if run.privateAPIs != nil {
+		run.result.count("private_apis", len(run.privateAPIs))
+	}
+	if run.dnsReport != nil {
+		run.result.count("private_hosted_zones", len(run.dnsReport.PrivateZones))
+	}
+	if run.relationships != nil {
+		run.result.count("replication_relationships", len(run.relationships))
+	}
+	if run.publicIPReport != nil {
+		run.result.count("public_ips", len(run.publicIPReport.PublicIPs))
+	}
+	if run.instances != nil {
+		run.result.count("instances", len(run.instances))
+	}
+
+	// Resumed paginations can miss changes made between the runs, which the report has to say
+	run.scanNotes = run.checkpoints.Notes()
+	for _, note := range run.scanNotes {
+		run.result.warnf("%s", note)
+		run.result.partial = true
+	}
+	for _, service := range run.unavailableServices {
+		run.scanNotes = append(run.scanNotes, fmt.Sprintf("%s were not scanned in %s: %s %s", service.ResourceType, service.Region, service.Service, service.Reason))
+	}
+	if err := run.checkpoints.Err(); err != nil {
+		run.result.warnf("checkpoints were not saved, an interrupted scan cannot be resumed: %v", err)
+	}
+
+	// Fields the API returned empty or with unexpected values, and references -consistency-recheck
+	// could not resolve; the outputs show empty fields as "(unknown)"
+	run.dataWarnings = append(run.scanner.DataWarnings(), run.consistencyWarnings...)
+	if run.opts.JSON && len(run.dataWarnings) > 0 {
+		fmt.Fprintln(run.stdout, "\nData-quality warnings:")
+		warningsJSON, _ := output.Marshal(run.dataWarnings, run.fieldStyle, run.format)
+		fmt.Fprintf(run.stdout, "%s\n", warningsJSON)
+	}
+
+	// Everything the scan collected, for -save (so -load can generate the outputs again without AWS credentials)
+	// and -terraform-out
+	scan := &report.ScanResult{
+		ScanTime:               run.scannedAt.UTC(),
+		Region:                 run.cfg.Region,
+		AccountID:              run.accountID,
+		VPCs:                   run.vpcs,
+		Subnets:                run.subnets,
+		RouteTables:            run.routeTables,
+		SecurityGroups:         run.securityGroups,
+		InternetGateways:       run.internetGateways,
+		NatGateways:            run.natGateways,
+		RouteAppliances:        run.routeAppliances,
+		TransitGateways:        run.transitGateways,
+		TGWAttachments:         run.tgwAttachments,
+		VpcPeerings:            run.peerings,
+		NetworkACLs:            run.networkACLs,
+		CarrierGateways:        run.carrierGateways,
+		VpnGateways:            run.vpnGateways,
+		CustomerGateways:       run.customerGateways,
+		VpnConnections:         run.vpnConnections,
+		DhcpOptions:            run.dhcpOptions,
+		NetworkInterfaces:      run.networkInterfaces,
+		Instances:              run.instances,
+		FlowLogs:               run.flowLogs,
+		ElasticIPs:             run.elasticIPs,
+		VpcEndpoints:           run.vpcEndpoints,
+		VpcEndpointConnections: run.endpointConnections,
+		AutoScalingGroups:      run.autoScalingGroups,
+		Directories:            run.directories,
+		CoreNetworks:           run.coreNetworks,
+		PrivateAPIs:            run.privateAPIs,
+	}
+	if *run.saveFile != "" {
+		if err := report.Save(*run.saveFile, scan); err != nil {
+			return fmt.Errorf("Failed to save scan: %w", err)
+		}
+		run.result.output(*run.saveFile)
+		fmt.Fprintf(run.stdout, "\nScan saved to: %s\n", *run.saveFile)
+	}
+	if *run.terraformOut != "" {
+		if err := writeTerraformImports(run.stdout, run.result, *run.terraformOut, scan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"aws-documentor/modules/analysis"
+	"aws-documentor/modules/asg"
+	"aws-documentor/modules/vpc"
+)
+
+// unresolvableEndpoints is an HTTP client for which no endpoint host name resolves, as in a region without the service
+type unresolvableEndpoints struct {
+	requests atomic.Int32 // Requests attempted, retries included
+}
+
+func (c *unresolvableEndpoints) Do(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return nil, &url.Error{Op: req.Method, URL: req.URL.String(), Err: &net.OpError{Op: "dial", Net: "tcp",
+		Err: &net.DNSError{Err: "no such host", Name: req.URL.Hostname(), IsNotFound: true}}}
+}
+
+// TestSkipOptional checks that optional scans of a service missing from the region or of a region that is not
+// enabled become unavailable services, while any other failure is still a warning that makes the scan partial
+func TestSkipOptional(t *testing.T) {
+	client := &unresolvableEndpoints{}
+	_, noEndpoint := asg.NewScanner(aws.Config{
+		Region:      "ap-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIAFAKE", "secret", ""),
+		HTTPClient:  client,
+		Retryer:     func() aws.Retryer { return retry.NewStandard() },
+	}).GetAutoScalingGroups(context.Background())
+	if noEndpoint == nil {
+		t.Fatal("GetAutoScalingGroups() without an endpoint succeeded")
+	}
+	if n := client.requests.Load(); n != 1 {
+		t.Errorf("the call without an endpoint was attempted %d times, want once without retries", n)
+	}
+
+	tests := []struct {
+		name        string
+		err         error
+		want        []analysis.UnavailableService
+		wantPartial bool
+	}{
+		{name: "service not in the region", err: noEndpoint, want: []analysis.UnavailableService{
+			{Service: "autoscaling", ResourceType: "Auto Scaling groups", Region: "ap-east-1", Reason: "is not available in this region"}}},
+		{name: "region not enabled", err: apiError("OptInRequired"), want: []analysis.UnavailableService{
+			{Service: "ec2", ResourceType: "VPCs", Region: "ap-east-1", Reason: "needs the region enabled for the account"}}},
+		{name: "access denied", err: apiError("AccessDeniedException"), wantPartial: true},
+		{name: "service error", err: apiError("InternalError"), wantPartial: true},
+		{name: "unavailable but not from a scanner", err: vpc.ErrServiceUnavailable, wantPartial: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &scanRun{result: newRunResult()}
+			run.skipOptional("Auto Scaling groups", "ap-east-1", tt.err)
+			if !reflect.DeepEqual(run.unavailableServices, tt.want) {
+				t.Errorf("unavailable services = %+v, want %+v", run.unavailableServices, tt.want)
+			}
+			if run.result.partial != tt.wantPartial || (run.result.Warnings == 1) != tt.wantPartial {
+				t.Errorf("partial %v with %d warnings, want partial %v", run.result.partial, run.result.Warnings, tt.wantPartial)
+			}
+		})
+	}
+	if !errors.Is(noEndpoint, vpc.ErrServiceUnavailable) {
+		t.Errorf("error without an endpoint = %v, want it classified as unavailable", noEndpoint)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/schema"
+)
+
+// schemaUsage lists the actions of the schema subcommand
+const schemaUsage = "fields, diff FROM TO, check or sample"
+
+// runSchema implements "aws-documentor schema fields|diff|check|sample [flags]"
+// fields lists the JSON fields of a report version, diff the changes between two versions with where each
+// legacy stdout section went, check compares the report types with their frozen field lists and samples
+// (exit status 4 if a frozen field was removed or retyped), and sample prints the frozen sample document of a version.
+// args: Command-line arguments after the subcommand
+func runSchema(args []string) {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	versionFlag := flags.String("version", schema.Current, "Report version for fields and sample, as 1 or v1")
+	outputJSON := flags.Bool("json", false, "Print fields and diff as JSON instead of a table")
+	action := ""
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	reportVersion, err := schema.ParseVersion(*versionFlag)
+	problems.Check("-version", err)
+	var from, to string
+	switch action {
+	case "fields", "sample", "check":
+		for _, arg := range flags.Args() {
+			problems.Addf(arg, 0, "unexpected argument")
+		}
+	case "diff":
+		if flags.NArg() != 2 {
+			problems.Addf("diff", 0, "needs the two versions to compare, for example: schema diff v0 v1")
+			break
+		}
+		from, err = schema.ParseVersion(flags.Arg(0))
+		problems.Check("FROM", err)
+		to, err = schema.ParseVersion(flags.Arg(1))
+		problems.Check("TO", err)
+	case "":
+		problems.Addf("schema", 0, "missing action: %s", schemaUsage)
+	default:
+		problems.Addf(action, 0, "unknown action (expected %s)", schemaUsage)
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	switch action {
+	case "fields":
+		fields := schema.Fields(reportVersion)
+		if *outputJSON {
+			fieldsJSON, _ := output.MarshalIndent(fields, output.FieldStyleSnake)
+			fmt.Printf("%s\n", fieldsJSON)
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "FIELD\tTYPE")
+		for _, field := range fields {
+			fmt.Fprintf(tw, "%s\t%s\n", field.Path, field.Type)
+		}
+		tw.Flush()
+
+	case "diff":
+		changes, err := schema.Diff(from, to)
+		if err != nil {
+			log.Fatalf("Failed to compare the versions: %v", err)
+		}
+		if *outputJSON {
+			diffJSON, _ := output.MarshalIndent(map[string]interface{}{"from": from, "to": to, "changes": changes, "stdout_sections": schema.StdoutSections}, output.FieldStyleSnake)
+			fmt.Printf("%s\n", diffJSON)
+			return
+		}
+		writeSchemaDiff(os.Stdout, from, to, changes)
+
+	case "check":
+		differences := schema.Check()
+		for _, problem := range differences {
+			fmt.Println(problem)
+		}
+		if len(differences) > 0 {
+			fmt.Printf("\n%d difference(s): a report type removed or retyped a field without a new schema version\n", len(differences))
+			os.Exit(exitFindings)
+		}
+		fmt.Printf("Report versions v%s keep every field of their frozen field lists and samples\n", strings.Join(schema.Versions(), ", v"))
+		for _, version := range schema.Versions() {
+			if added := schema.Added(version); len(added) > 0 {
+				fmt.Printf("v%s has %d field(s) added since it was frozen, which consumers can ignore\n", version, len(added))
+			}
+		}
+
+	case "sample":
+		sample, err := schema.Sample(reportVersion)
+		if err != nil {
+			log.Fatalf("Failed to read the sample: %v", err)
+		}
+		os.Stdout.Write(sample)
+	}
+}
+
+// writeSchemaDiff prints the changes between two report versions and where the legacy stdout sections are now
+func writeSchemaDiff(w io.Writer, from, to string, changes []schema.Change) {
+	fmt.Fprintf(w, "Changes from v%s to v%s:\n", from, to)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tFROM\tTO\tTYPE\tNOTE")
+	for _, change := range changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", change.Kind, orDash(change.From), orDash(change.To), change.Type, change.Note)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nLegacy stdout sections (-legacy-stdout) in v%s:\n", schema.Current)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HEADING\tPATH\tNOTE")
+	for _, section := range schema.StdoutSections {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", section.Heading, orDash(section.Path), section.Note)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,7 @@
+package main
+
+// Version of this build, checked for freshness by the doctor subcommand; keep both in step with the Version section of the README
+const (
+	version     = "1.0.0"      // Release of this build
+	releaseDate = "2026-10-16" // Day the release was cut
+)
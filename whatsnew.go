@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"aws-documentor/modules/awsconfig"
+	"aws-documentor/modules/config"
+	"aws-documentor/modules/diff"
+	"aws-documentor/modules/output"
+	"aws-documentor/modules/vpc"
+	"aws-documentor/modules/whatsnew"
+)
+
+// runWhatsNew implements "aws-documentor whatsnew [flags]"
+// It lists the network changes within a window ending at the current scan: creations the APIs date
+// themselves, changes between the earlier reports given with -history and, with -cloudtrail, the
+// principals of the changes.
+// args: Command-line arguments after the subcommand
+func runWhatsNew(args []string) {
+	flags := flag.NewFlagSet("whatsnew", flag.ExitOnError)
+	since := flags.String("since", "90d", "Length of the window ending at the current scan (90d, 12w or a duration such as 36h)")
+	fromFile := flags.String("from-file", "", "JSON report to use as the current scan instead of scanning")
+	freshness := registerFreshnessFlags(flags, "-from-file")
+	history := flags.String("history", "", "Comma-separated JSON reports of earlier scans, to find modifications and deletions")
+	useCloudTrail := flags.Bool("cloudtrail", false, "Read the CloudTrail event history for exact times and principals (needs cloudtrail:LookupEvents)")
+	outputJSON := flags.Bool("json", false, "Print the report as JSON instead of Markdown")
+	region := flags.String("region", "", "AWS region to scan (optional, uses default config if not specified)")
+	proxy := flags.String("proxy", "", "Proxy URL for AWS API requests (default: HTTPS_PROXY from the environment)")
+	flags.Parse(args)
+
+	problems := &config.Validator{}
+	window, err := config.ParseWindow(*since)
+	problems.Check("-since", err)
+	if *region != "" {
+		problems.Check("-region", config.CheckRegion(*region))
+	}
+	if *proxy != "" {
+		_, err := awsconfig.ParseProxy(*proxy)
+		problems.Check("-proxy", err)
+	}
+	if *fromFile != "" && !*useCloudTrail && (*region != "" || *proxy != "") {
+		problems.Addf("-from-file", 0, "cannot be combined with -region or -proxy unless -cloudtrail is set")
+	}
+	var earlier []*diff.Snapshot
+	if *history != "" {
+		for _, path := range strings.Split(*history, ",") {
+			snapshot, err := diff.LoadSnapshot(strings.TrimSpace(path))
+			if err != nil {
+				problems.Addf("-history", 0, "%v", err)
+				continue
+			}
+			if snapshot.ScannedAt == "" {
+				problems.Addf("-history", 0, "%s has no scan time, so its changes cannot be dated", path)
+				continue
+			}
+			earlier = append(earlier, snapshot)
+		}
+	}
+	freshness.validate(problems, *fromFile != "")
+	for _, arg := range flags.Args() {
+		problems.Addf(arg, 0, "unexpected argument")
+	}
+	if err := problems.Err(); err != nil {
+		log.Fatalf("Invalid flags:\n%v", err)
+	}
+
+	ctx := context.Background()
+	var cfg aws.Config
+	if *fromFile == "" || *useCloudTrail {
+		cfg = loadSubcommandConfig(ctx, *region, *proxy)
+	}
+
+	in := whatsnew.Input{History: earlier}
+	if *fromFile != "" {
+		if in.Current, err = diff.LoadSnapshot(*fromFile); err != nil {
+			log.Fatalf("Failed to load report: %v", err)
+		}
+		freshness.checkSubcommand(in.Current.ScannedAt)
+	} else {
+		scannedAt := time.Now().UTC()
+		scan := scanCoreResources(ctx, cfg)
+		in.Current = &diff.Snapshot{
+			ScannedAt:        scannedAt.Format(time.RFC3339),
+			VPCs:             scan.VPCs,
+			Subnets:          scan.Subnets,
+			RouteTables:      scan.RouteTables,
+			SecurityGroups:   scan.SecurityGroups,
+			InternetGateways: scan.InternetGateways,
+			NatGateways:      scan.NatGateways,
+			TransitGateways:  scan.TransitGateways,
+		}
+		in.Attachments = scan.TGWAttachments
+	}
+
+	until := time.Now().UTC()
+	if at, err := time.Parse(time.RFC3339, in.Current.ScannedAt); err == nil {
+		until = at
+	}
+	start := until.Add(-window)
+
+	if *useCloudTrail {
+		if window > 90*24*time.Hour {
+			log.Printf("Warning: the CloudTrail event history only keeps 90 days; older changes are not attributed")
+		}
+		in.Trail, err = whatsnew.NewTrailScanner(cfg).GetNetworkEvents(ctx, start, until)
+		switch {
+		case errors.Is(err, vpc.ErrAccessDenied):
+			log.Printf("Warning: %v; changes are not attributed", err)
+			in.Trail, in.TrailNote = nil, "access denied to cloudtrail:LookupEvents"
+		case err != nil:
+			exitOnScanError(err)
+		}
+	} else {
+		in.TrailNote = "not read (-cloudtrail not set); changes are not attributed"
+	}
+
+	report := whatsnew.Build(start, until, in)
+	if *outputJSON {
+		reportJSON, _ := output.MarshalIndent(report, output.FieldStyleSnake)
+		fmt.Printf("%s\n", reportJSON)
+		return
+	}
+	if err := whatsnew.WriteMarkdown(os.Stdout, report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}